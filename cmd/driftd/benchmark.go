@@ -0,0 +1,468 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/orchestrate"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/runner"
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/driftdhq/driftd/internal/worker"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// benchmarkOptions shape the synthetic repository and the scans run on it.
+type benchmarkOptions struct {
+	Stacks       int           `json:"stacks"`
+	Depth        int           `json:"depth"`
+	Modules      int           `json:"modules"`
+	ModuleDeps   int           `json:"module_deps"`
+	StackModules int           `json:"stack_modules"`
+	Scans        int           `json:"scans"`
+	Concurrency  int           `json:"concurrency"`
+	PlanTime     time.Duration `json:"plan_time_ns"`
+	DriftRate    float64       `json:"drift_rate"`
+	Timeout      time.Duration `json:"-"`
+}
+
+// benchmarkScan is the outcome of one benchmark scan.
+type benchmarkScan struct {
+	ScanID string `json:"scan_id"`
+	Stacks int    `json:"stacks"`
+	// Phases are the orchestrator's setup phases, in milliseconds.
+	Phases []benchmarkPhase `json:"phases"`
+	// SetupMS runs from the scan start to the end of the enqueue phase.
+	SetupMS float64 `json:"setup_ms"`
+	// TotalMS runs from the scan start to its last stack finishing.
+	TotalMS          float64 `json:"total_ms"`
+	StacksPerSecond  float64 `json:"stacks_per_second"`
+	QueueWaitP50MS   float64 `json:"queue_wait_p50_ms"`
+	QueueWaitP95MS   float64 `json:"queue_wait_p95_ms"`
+	QueueWaitMaxMS   float64 `json:"queue_wait_max_ms"`
+	QueueWaitSamples int     `json:"queue_wait_samples"`
+}
+
+type benchmarkPhase struct {
+	Name string  `json:"name"`
+	MS   float64 `json:"ms"`
+}
+
+// benchmarkReport is what driftd benchmark prints. Median is taken over the
+// scans after the first, which pays for the initial mirror clone, unless
+// only one scan ran.
+type benchmarkReport struct {
+	Options benchmarkOptions `json:"options"`
+	Scans   []benchmarkScan  `json:"scans"`
+	Median  benchmarkScan    `json:"median"`
+}
+
+func runBenchmark(args []string) {
+	fs := flag.NewFlagSet("benchmark", flag.ExitOnError)
+	var opts benchmarkOptions
+	fs.IntVar(&opts.Stacks, "stacks", 500, "number of stacks in the synthetic repository")
+	fs.IntVar(&opts.Depth, "depth", 3, "directory levels stacks are nested under")
+	fs.IntVar(&opts.Modules, "modules", 20, "number of local modules")
+	fs.IntVar(&opts.ModuleDeps, "module-deps", 2, "modules each module calls")
+	fs.IntVar(&opts.StackModules, "stack-modules", 2, "modules each stack calls")
+	fs.IntVar(&opts.Scans, "scans", 3, "full scans to run")
+	fs.IntVar(&opts.Concurrency, "concurrency", 8, "worker concurrency")
+	fs.DurationVar(&opts.PlanTime, "plan-time", 0, "time the fake runner spends on each stack")
+	fs.Float64Var(&opts.DriftRate, "drift-rate", 0.1, "fraction of stacks the fake runner reports drifted")
+	fs.DurationVar(&opts.Timeout, "timeout", 10*time.Minute, "give up on a scan after this long")
+	maxSetup := fs.Duration("max-setup", 0, "fail when the median setup time (clone, discovery, enqueue) exceeds this")
+	minThroughput := fs.Float64("min-throughput", 0, "fail when the median end-to-end throughput is below this many stacks per second")
+	jsonOut := fs.Bool("json", false, "print the report as JSON")
+	keep := fs.Bool("keep", false, "keep the generated repository and data directory")
+	fs.Parse(args)
+	if err := opts.validate(); err != nil {
+		log.Fatalf("benchmark: %v", err)
+	}
+
+	dir, err := os.MkdirTemp("", "driftd-benchmark-*")
+	if err != nil {
+		log.Fatalf("benchmark: %v", err)
+	}
+	if *keep {
+		fmt.Fprintf(os.Stderr, "Keeping benchmark files in %s\n", dir)
+	} else {
+		defer os.RemoveAll(dir)
+	}
+
+	report, err := benchmark(context.Background(), dir, opts)
+	if err != nil {
+		log.Fatalf("benchmark: %v", err)
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		printBenchmarkReport(os.Stdout, report)
+	}
+
+	failed := false
+	if *maxSetup > 0 && report.Median.SetupMS > float64(*maxSetup)/float64(time.Millisecond) {
+		fmt.Fprintf(os.Stderr, "benchmark: median setup %.0fms exceeds -max-setup %s\n", report.Median.SetupMS, *maxSetup)
+		failed = true
+	}
+	if *minThroughput > 0 && report.Median.StacksPerSecond < *minThroughput {
+		fmt.Fprintf(os.Stderr, "benchmark: median throughput %.1f stacks/s is below -min-throughput %.1f\n", report.Median.StacksPerSecond, *minThroughput)
+		failed = true
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func (o benchmarkOptions) validate() error {
+	switch {
+	case o.Stacks < 1:
+		return fmt.Errorf("-stacks must be at least 1")
+	case o.Depth < 1:
+		return fmt.Errorf("-depth must be at least 1")
+	case o.Modules < 0 || o.ModuleDeps < 0 || o.StackModules < 0:
+		return fmt.Errorf("-modules, -module-deps and -stack-modules must not be negative")
+	case o.Scans < 1:
+		return fmt.Errorf("-scans must be at least 1")
+	case o.Concurrency < 1:
+		return fmt.Errorf("-concurrency must be at least 1")
+	case o.DriftRate < 0 || o.DriftRate > 1:
+		return fmt.Errorf("-drift-rate must be between 0 and 1")
+	}
+	return nil
+}
+
+// benchmark generates a repository under dir and runs opts.Scans full scans
+// of it through the orchestrator, an in-memory queue and a worker whose
+// runner only sleeps.
+func benchmark(ctx context.Context, dir string, opts benchmarkOptions) (*benchmarkReport, error) {
+	repoDir := filepath.Join(dir, "repo")
+	if err := generateBenchmarkRepo(repoDir, opts); err != nil {
+		return nil, fmt.Errorf("generate repository: %w", err)
+	}
+
+	cfg := &config.Config{
+		DataDir: filepath.Join(dir, "data"),
+		Worker: config.WorkerConfig{
+			Concurrency: opts.Concurrency,
+			LockTTL:     2 * time.Minute,
+			ScanMaxAge:  opts.Timeout,
+			RenewEvery:  30 * time.Second,
+		},
+		Projects: []config.ProjectConfig{{
+			Name:        "benchmark",
+			URL:         "file://" + repoDir,
+			IgnorePaths: []string{"modules/**"},
+		}},
+	}
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		return nil, err
+	}
+	q := queue.NewMemory(cfg.Worker.LockTTL)
+	defer q.Close()
+
+	waits := newQueueWaitRecorder()
+	eventsCtx, cancelEvents := context.WithCancel(ctx)
+	defer cancelEvents()
+	events, err := q.SubscribeProjectEvents(eventsCtx, "")
+	if err != nil {
+		return nil, fmt.Errorf("subscribe to events: %w", err)
+	}
+	go func() {
+		for event := range events {
+			if event.Type == "stack_update" && event.Status == "running" && event.QueuedAt != nil && event.RunAt != nil {
+				waits.add(event.ScanID, event.RunAt.Sub(*event.QueuedAt))
+			}
+		}
+	}()
+
+	orch := orchestrate.New(cfg, q)
+	defer orch.Stop()
+	w := worker.New(q, &benchmarkRunner{planTime: opts.PlanTime, driftRate: opts.DriftRate}, opts.Concurrency, cfg, nil)
+	w.Start()
+	defer w.Stop()
+
+	report := &benchmarkReport{Options: opts}
+	projectCfg := &cfg.Projects[0]
+	for i := 0; i < opts.Scans; i++ {
+		// Scans store their start and end in whole seconds, so time them
+		// here.
+		started := time.Now()
+		scan, _, err := orch.StartAndEnqueue(ctx, projectCfg, "manual", "", "benchmark")
+		if err != nil {
+			return nil, fmt.Errorf("scan %d: %w", i+1, err)
+		}
+		done, err := waitForBenchmarkScan(ctx, q, scan.ID, opts.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("scan %d: %w", i+1, err)
+		}
+		total := time.Since(started)
+		// Running events are published before results; give the last
+		// ones a moment to arrive.
+		waits.waitFor(scan.ID, done.Total, time.Second)
+		report.Scans = append(report.Scans, summarizeBenchmarkScan(done, started, total, waits.get(scan.ID)))
+	}
+	measured := report.Scans
+	if len(measured) > 1 {
+		measured = measured[1:]
+	}
+	report.Median = medianBenchmarkScan(measured)
+	return report, nil
+}
+
+func waitForBenchmarkScan(ctx context.Context, q queue.Backend, scanID string, timeout time.Duration) (*queue.Scan, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		scan, err := q.GetScan(ctx, scanID)
+		if err != nil {
+			return nil, err
+		}
+		switch scan.Status {
+		case queue.ScanStatusCompleted:
+			return scan, nil
+		case queue.ScanStatusFailed, queue.ScanStatusCanceled:
+			return nil, fmt.Errorf("scan %s %s: %s", scanID, scan.Status, scan.Error)
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("scan %s still %s after %s (%d/%d stacks done)", scanID, scan.Status, timeout, scan.Completed+scan.Failed, scan.Total)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func summarizeBenchmarkScan(scan *queue.Scan, started time.Time, total time.Duration, waits []time.Duration) benchmarkScan {
+	out := benchmarkScan{ScanID: scan.ID, Stacks: scan.Total, QueueWaitSamples: len(waits)}
+	var setupEnd time.Time
+	for _, phase := range scan.Phases {
+		out.Phases = append(out.Phases, benchmarkPhase{Name: phase.Name, MS: ms(phase.Duration())})
+		if phase.EndedAt.After(setupEnd) {
+			setupEnd = phase.EndedAt
+		}
+	}
+	if !setupEnd.IsZero() {
+		out.SetupMS = ms(setupEnd.Sub(started))
+	}
+	out.TotalMS = ms(total)
+	if total > 0 {
+		out.StacksPerSecond = float64(scan.Total) / total.Seconds()
+	}
+	if len(waits) > 0 {
+		sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+		out.QueueWaitP50MS = ms(percentile(waits, 0.50))
+		out.QueueWaitP95MS = ms(percentile(waits, 0.95))
+		out.QueueWaitMaxMS = ms(waits[len(waits)-1])
+	}
+	return out
+}
+
+// medianBenchmarkScan takes the median of each measurement separately.
+func medianBenchmarkScan(scans []benchmarkScan) benchmarkScan {
+	if len(scans) == 0 {
+		return benchmarkScan{}
+	}
+	median := func(get func(benchmarkScan) float64) float64 {
+		values := make([]float64, len(scans))
+		for i, s := range scans {
+			values[i] = get(s)
+		}
+		sort.Float64s(values)
+		if n := len(values); n%2 == 0 {
+			return (values[n/2-1] + values[n/2]) / 2
+		}
+		return values[len(values)/2]
+	}
+	out := benchmarkScan{
+		Stacks:           scans[0].Stacks,
+		SetupMS:          median(func(s benchmarkScan) float64 { return s.SetupMS }),
+		TotalMS:          median(func(s benchmarkScan) float64 { return s.TotalMS }),
+		StacksPerSecond:  median(func(s benchmarkScan) float64 { return s.StacksPerSecond }),
+		QueueWaitP50MS:   median(func(s benchmarkScan) float64 { return s.QueueWaitP50MS }),
+		QueueWaitP95MS:   median(func(s benchmarkScan) float64 { return s.QueueWaitP95MS }),
+		QueueWaitMaxMS:   median(func(s benchmarkScan) float64 { return s.QueueWaitMaxMS }),
+		QueueWaitSamples: scans[0].QueueWaitSamples,
+	}
+	for _, phase := range scans[0].Phases {
+		name := phase.Name
+		out.Phases = append(out.Phases, benchmarkPhase{Name: name, MS: median(func(s benchmarkScan) float64 {
+			for _, p := range s.Phases {
+				if p.Name == name {
+					return p.MS
+				}
+			}
+			return 0
+		})})
+	}
+	return out
+}
+
+func printBenchmarkReport(out io.Writer, report *benchmarkReport) {
+	o := report.Options
+	fmt.Fprintf(out, "Repository: %d stacks, depth %d, %d modules (%d deps each, %d per stack)\n", o.Stacks, o.Depth, o.Modules, o.ModuleDeps, o.StackModules)
+	fmt.Fprintf(out, "Workers: concurrency %d, plan time %s, drift rate %.2f\n\n", o.Concurrency, o.PlanTime, o.DriftRate)
+	fmt.Fprintf(out, "%-8s %10s %10s %12s %12s %12s  %s\n", "SCAN", "SETUP", "TOTAL", "STACKS/S", "WAIT P50", "WAIT P95", "PHASES")
+	row := func(label string, s benchmarkScan) {
+		var phases []string
+		for _, p := range s.Phases {
+			phases = append(phases, fmt.Sprintf("%s=%.0fms", p.Name, p.MS))
+		}
+		fmt.Fprintf(out, "%-8s %8.0fms %8.0fms %12.1f %10.0fms %10.0fms  %s\n",
+			label, s.SetupMS, s.TotalMS, s.StacksPerSecond, s.QueueWaitP50MS, s.QueueWaitP95MS, strings.Join(phases, " "))
+	}
+	for i, s := range report.Scans {
+		row(fmt.Sprintf("#%d", i+1), s)
+	}
+	row("median", report.Median)
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// percentile returns the p-th percentile of sorted values.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	idx := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[max(idx, 0)]
+}
+
+// queueWaitRecorder collects stack scan queue waits per scan from events.
+type queueWaitRecorder struct {
+	mu    sync.Mutex
+	waits map[string][]time.Duration
+}
+
+func newQueueWaitRecorder() *queueWaitRecorder {
+	return &queueWaitRecorder{waits: map[string][]time.Duration{}}
+}
+
+func (r *queueWaitRecorder) add(scanID string, wait time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.waits[scanID] = append(r.waits[scanID], max(wait, 0))
+}
+
+func (r *queueWaitRecorder) get(scanID string) []time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]time.Duration(nil), r.waits[scanID]...)
+}
+
+func (r *queueWaitRecorder) waitFor(scanID string, n int, timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for len(r.get(scanID)) < n && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// benchmarkRunner stands in for terraform: it sleeps for planTime and
+// reports a stable subset of stacks as drifted.
+type benchmarkRunner struct {
+	planTime  time.Duration
+	driftRate float64
+}
+
+func (r *benchmarkRunner) Run(ctx context.Context, params *runner.RunParams) (*storage.RunResult, error) {
+	if r.planTime > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(r.planTime):
+		}
+	}
+	h := fnv.New32a()
+	h.Write([]byte(params.StackPath))
+	drifted := float64(h.Sum32()%1000) < r.driftRate*1000
+	result := &storage.RunResult{Drifted: drifted, RunAt: time.Now()}
+	if drifted {
+		result.Changed = 1
+	}
+	return result, nil
+}
+
+// generateBenchmarkRepo writes a git repository of opts.Stacks stacks nested
+// opts.Depth directories deep under stacks/, calling local modules that in
+// turn call later modules, so the module graph is a DAG.
+func generateBenchmarkRepo(dir string, opts benchmarkOptions) error {
+	for m := 0; m < opts.Modules; m++ {
+		var body strings.Builder
+		body.WriteString("variable \"name\" {\n  type    = string\n  default = \"x\"\n}\n\nresource \"null_resource\" \"this\" {\n  triggers = { name = var.name }\n}\n")
+		for d := 1; d <= opts.ModuleDeps && m+d < opts.Modules; d++ {
+			fmt.Fprintf(&body, "\nmodule \"dep_%d\" {\n  source = \"../%s\"\n  name   = var.name\n}\n", d, benchmarkModuleName(m+d))
+		}
+		if err := writeBenchmarkFile(filepath.Join(dir, "modules", benchmarkModuleName(m), "main.tf"), body.String()); err != nil {
+			return err
+		}
+	}
+
+	width := int(math.Ceil(math.Pow(float64(opts.Stacks), 1/float64(opts.Depth))))
+	for i := 0; i < opts.Stacks; i++ {
+		rel := benchmarkStackPath(i, opts.Depth, width)
+		stackDir := filepath.Join(dir, filepath.FromSlash(rel))
+		var body strings.Builder
+		body.WriteString("terraform {\n  required_version = \">= 1.5.0\"\n  backend \"local\" {}\n}\n")
+		for j := 0; j < opts.StackModules && j < opts.Modules; j++ {
+			m := (i*7 + j*13) % opts.Modules
+			source, err := filepath.Rel(stackDir, filepath.Join(dir, "modules", benchmarkModuleName(m)))
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&body, "\nmodule \"m%d\" {\n  source = %q\n  name   = %q\n}\n", j, filepath.ToSlash(source), rel)
+		}
+		if err := writeBenchmarkFile(filepath.Join(stackDir, "main.tf"), body.String()); err != nil {
+			return err
+		}
+	}
+
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		return err
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if err := wt.AddGlob("."); err != nil {
+		return err
+	}
+	_, err = wt.Commit("benchmark fixture", &git.CommitOptions{
+		Author: &object.Signature{Name: "driftd benchmark", Email: "benchmark@driftd.invalid", When: time.Now()},
+	})
+	return err
+}
+
+func benchmarkModuleName(m int) string {
+	return fmt.Sprintf("m%03d", m)
+}
+
+// benchmarkStackPath spreads stack i over depth-1 levels of group
+// directories, width entries each.
+func benchmarkStackPath(i, depth, width int) string {
+	parts := []string{"stacks"}
+	for level := depth - 1; level >= 1; level-- {
+		group := i / int(math.Pow(float64(width), float64(level))) % width
+		parts = append(parts, fmt.Sprintf("g%02d", group))
+	}
+	parts = append(parts, fmt.Sprintf("stack-%05d", i))
+	return strings.Join(parts, "/")
+}
+
+func writeBenchmarkFile(path, content string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
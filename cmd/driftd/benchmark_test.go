@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+func TestBenchmarkScansGeneratedRepo(t *testing.T) {
+	opts := benchmarkOptions{
+		Stacks:       12,
+		Depth:        2,
+		Modules:      3,
+		ModuleDeps:   1,
+		StackModules: 2,
+		Scans:        2,
+		Concurrency:  2,
+		DriftRate:    0.5,
+		Timeout:      time.Minute,
+	}
+	report, err := benchmark(context.Background(), t.TempDir(), opts)
+	if err != nil {
+		t.Fatalf("benchmark: %v", err)
+	}
+	if len(report.Scans) != 2 {
+		t.Fatalf("expected 2 scans, got %d", len(report.Scans))
+	}
+	for _, scan := range report.Scans {
+		// Modules are ignored, so only the generated stacks are scanned.
+		if scan.Stacks != 12 || scan.QueueWaitSamples != 12 {
+			t.Fatalf("expected 12 stacks with a queue wait each, got %+v", scan)
+		}
+		var names []string
+		for _, p := range scan.Phases {
+			names = append(names, p.Name)
+		}
+		if got := strings.Join(names, ","); !strings.Contains(got, queue.PhaseDiscover) || !strings.Contains(got, queue.PhaseEnqueue) {
+			t.Fatalf("expected discover and enqueue phases, got %s", got)
+		}
+		if scan.SetupMS <= 0 || scan.TotalMS < scan.SetupMS || scan.StacksPerSecond <= 0 {
+			t.Fatalf("unexpected timings %+v", scan)
+		}
+	}
+	if report.Median.Stacks != 12 {
+		t.Fatalf("unexpected median %+v", report.Median)
+	}
+}
+
+func TestBenchmarkStackPath(t *testing.T) {
+	if got := benchmarkStackPath(437, 3, 10); got != "stacks/g04/g03/stack-00437" {
+		t.Fatalf("unexpected path %q", got)
+	}
+	if got := benchmarkStackPath(5, 1, 10); got != "stacks/stack-00005" {
+		t.Fatalf("unexpected path %q", got)
+	}
+}
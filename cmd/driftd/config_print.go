@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+func runConfig(args []string) {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: driftd config print [-config path] [-env name] [-redact]")
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("config print", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "path to config file")
+	env := fs.String("env", os.Getenv(config.EnvironmentVar), "config overlay to merge")
+	redact := fs.Bool("redact", false, "replace secret values with "+config.Redacted)
+	fs.Parse(args[1:])
+
+	cfg, err := config.LoadEnv(*configPath, *env)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		os.Exit(1)
+	}
+	if err := config.Print(os.Stdout, cfg, *redact); err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+// importRecord is one result in an import-results file.
+type importRecord struct {
+	Project         string                   `json:"project"`
+	StackPath       string                   `json:"stack_path"`
+	RunAt           time.Time                `json:"run_at"`
+	Drifted         bool                     `json:"drifted"`
+	Added           int                      `json:"added"`
+	Changed         int                      `json:"changed"`
+	Destroyed       int                      `json:"destroyed"`
+	Error           string                   `json:"error,omitempty"`
+	PlanOutput      string                   `json:"plan_output,omitempty"`
+	CommitSHA       string                   `json:"commit_sha,omitempty"`
+	Tags            map[string]string        `json:"tags,omitempty"`
+	ResourceChanges []storage.ResourceChange `json:"resource_changes,omitempty"`
+}
+
+// importSummary counts what an import did, or would do in a dry run.
+type importSummary struct {
+	Records  int
+	Stacks   int
+	Projects int
+	Latest   int
+	History  int
+	Skipped  int
+}
+
+func runImportResults(args []string) {
+	fs := flag.NewFlagSet("import-results", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "path to config file")
+	dryRun := fs.Bool("dry-run", false, "validate the file and report what would be imported without writing")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatalf("import-results: usage: driftd import-results [-config file] [-dry-run] <file.json>")
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("failed to load config: %v", err)
+	}
+	if err := validateEncryptionKeyPolicy(cfg); err != nil {
+		log.Fatalf("invalid encryption key configuration: %v", err)
+	}
+
+	in, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatalf("import-results: %v", err)
+	}
+	defer in.Close()
+	records, err := readImportRecords(in)
+	if err != nil {
+		log.Fatalf("import-results: %v", err)
+	}
+	if problems := validateImportRecords(records, time.Now()); len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintln(os.Stderr, problem)
+		}
+		fmt.Fprintf(os.Stderr, "\n%s: %d problem(s) found, nothing imported\n", fs.Arg(0), len(problems))
+		os.Exit(1)
+	}
+	configured := map[string]bool{}
+	for _, project := range cfg.Projects {
+		configured[project.Name] = true
+	}
+	for _, name := range importProjects(records) {
+		if !configured[name] {
+			fmt.Fprintf(os.Stderr, "warning: project %s is not in the config; its results are only shown once it is added\n", name)
+		}
+	}
+
+	if *dryRun {
+		summary := summarizeImport(records)
+		fmt.Printf("dry run: %d result(s) for %d stack(s) in %d project(s) are valid\n", summary.Records, summary.Stacks, summary.Projects)
+		return
+	}
+
+	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+		log.Fatalf("failed to create data dir: %v", err)
+	}
+	store := storage.NewWithOptions(cfg.DataDir, storageOptions(cfg))
+	defer store.Close()
+	summary, err := importResults(store, records)
+	if err != nil {
+		log.Fatalf("import-results: %v", err)
+	}
+	fmt.Printf("imported %d result(s) for %d stack(s) in %d project(s): %d became the latest result, %d added to history, %d already present or past retention\n",
+		summary.Records, summary.Stacks, summary.Projects, summary.Latest, summary.History, summary.Skipped)
+}
+
+// readImportRecords decodes a JSON array of records, or an object with the
+// array under "results". Unknown fields are rejected so typos are caught.
+func readImportRecords(in io.Reader) ([]importRecord, error) {
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	var records []importRecord
+	if err := decodeStrict(data, &records); err == nil {
+		return records, nil
+	}
+	var wrapped struct {
+		Results []importRecord `json:"results"`
+	}
+	if err := decodeStrict(data, &wrapped); err != nil {
+		return nil, fmt.Errorf("decode results: %w", err)
+	}
+	return wrapped.Results, nil
+}
+
+func decodeStrict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// validateImportRecords returns every problem in records, each naming the
+// record by its position in the file.
+func validateImportRecords(records []importRecord, now time.Time) []string {
+	var problems []string
+	if len(records) == 0 {
+		return []string{"no results to import"}
+	}
+	seen := map[string]int{}
+	for i, rec := range records {
+		problem := func(format string, args ...any) {
+			problems = append(problems, fmt.Sprintf("results[%d]: %s", i, fmt.Sprintf(format, args...)))
+		}
+		if err := storage.ValidateResultKey(rec.Project, rec.StackPath); err != nil {
+			problem("%v (project %q, stack_path %q)", err, rec.Project, rec.StackPath)
+		}
+		if rec.RunAt.IsZero() {
+			problem("run_at is required")
+		} else if rec.RunAt.After(now.Add(5 * time.Minute)) {
+			problem("run_at %s is in the future", rec.RunAt.Format(time.RFC3339))
+		}
+		if rec.Added < 0 || rec.Changed < 0 || rec.Destroyed < 0 {
+			problem("added, changed and destroyed must be >= 0")
+		}
+		for _, change := range rec.ResourceChanges {
+			if change.Address == "" || !slices.Contains(config.SeverityActions, change.Action) {
+				problem("invalid resource change %q %q", change.Action, change.Address)
+			}
+		}
+		key := rec.Project + "\x00" + rec.StackPath + "\x00" + rec.RunAt.UTC().Format(time.RFC3339Nano)
+		if first, ok := seen[key]; ok {
+			problem("duplicates results[%d]", first)
+		} else {
+			seen[key] = i
+		}
+	}
+	return problems
+}
+
+// importResults writes records oldest first, so each stack ends on its
+// newest result unless driftd already has a newer one.
+func importResults(store *storage.Storage, records []importRecord) (importSummary, error) {
+	sorted := slices.Clone(records)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].RunAt.Before(sorted[j].RunAt) })
+	summary := summarizeImport(records)
+	for _, rec := range sorted {
+		outcome, err := store.ImportResult(rec.Project, rec.StackPath, &storage.RunResult{
+			Drifted:         rec.Drifted,
+			Added:           rec.Added,
+			Changed:         rec.Changed,
+			Destroyed:       rec.Destroyed,
+			PlanOutput:      rec.PlanOutput,
+			Error:           rec.Error,
+			RunAt:           rec.RunAt.UTC(),
+			Tags:            rec.Tags,
+			ResourceChanges: rec.ResourceChanges,
+			CommitSHA:       rec.CommitSHA,
+		})
+		if err != nil {
+			return summary, fmt.Errorf("%s %s at %s: %w", rec.Project, rec.StackPath, rec.RunAt.Format(time.RFC3339), err)
+		}
+		if outcome.Latest {
+			summary.Latest++
+		}
+		if outcome.History {
+			summary.History++
+		} else {
+			summary.Skipped++
+		}
+	}
+	return summary, nil
+}
+
+func summarizeImport(records []importRecord) importSummary {
+	stacks := map[string]bool{}
+	for _, rec := range records {
+		stacks[rec.Project+"\x00"+rec.StackPath] = true
+	}
+	return importSummary{
+		Records:  len(records),
+		Stacks:   len(stacks),
+		Projects: len(importProjects(records)),
+	}
+}
+
+func importProjects(records []importRecord) []string {
+	var names []string
+	for _, rec := range records {
+		if !slices.Contains(names, rec.Project) {
+			names = append(names, rec.Project)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
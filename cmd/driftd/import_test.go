@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestImportResults(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	file := `{"results": [
+  {"project": "infra", "stack_path": "envs/prod", "run_at": "` + now.Add(-time.Hour).Format(time.RFC3339) + `", "drifted": true, "changed": 2,
+   "plan_output": "~ aws_s3_bucket.logs", "resource_changes": [{"address": "aws_s3_bucket.logs", "action": "update"}]},
+  {"project": "infra", "stack_path": "envs/prod", "run_at": "` + now.Add(-48*time.Hour).Format(time.RFC3339) + `"},
+  {"project": "infra", "stack_path": "envs/dev", "run_at": "` + now.Add(-2*time.Hour).Format(time.RFC3339) + `", "error": "state locked"}
+]}`
+	records, err := readImportRecords(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if problems := validateImportRecords(records, now); len(problems) > 0 {
+		t.Fatalf("unexpected problems: %v", problems)
+	}
+
+	store := storage.New(t.TempDir())
+	summary, err := importResults(store, records)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if summary.Records != 3 || summary.Stacks != 2 || summary.Projects != 1 || summary.Latest != 3 || summary.History != 3 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+	result, err := store.GetResult("infra", "envs/prod")
+	if err != nil {
+		t.Fatalf("get result: %v", err)
+	}
+	if !result.Drifted || result.Changed != 2 || result.PlanOutput != "~ aws_s3_bucket.logs" {
+		t.Fatalf("expected the newest prod result to be current, got %+v", result)
+	}
+
+	// Importing the same file again adds nothing.
+	summary, err = importResults(store, records)
+	if err != nil {
+		t.Fatalf("re-import: %v", err)
+	}
+	if summary.Latest != 0 || summary.History != 0 || summary.Skipped != 3 {
+		t.Fatalf("expected re-import to be a no-op, got %+v", summary)
+	}
+	history, err := store.StackHistory("infra", "envs/prod", time.Time{})
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(history) != 2 || !history[0].RunAt.Before(history[1].RunAt) {
+		t.Fatalf("expected two prod runs oldest first, got %+v", history)
+	}
+}
+
+func TestValidateImportRecords(t *testing.T) {
+	now := time.Now()
+	if _, err := readImportRecords(strings.NewReader(`[{"project": "infra", "stack": "envs/prod"}]`)); err == nil {
+		t.Fatalf("expected unknown fields to be rejected")
+	}
+	records := []importRecord{
+		{Project: "bad name", StackPath: "envs/prod", RunAt: now},
+		{Project: "infra", StackPath: "../escape", RunAt: now},
+		{Project: "infra", StackPath: "envs/prod"},
+		{Project: "infra", StackPath: "envs/prod", RunAt: now.Add(time.Hour)},
+		{Project: "infra", StackPath: "envs/prod", RunAt: now, Added: -1},
+		{Project: "infra", StackPath: "envs/prod", RunAt: now.Add(-time.Minute), ResourceChanges: []storage.ResourceChange{{Address: "x.y", Action: "explode"}}},
+		{Project: "infra", StackPath: "envs/dev", RunAt: now},
+		{Project: "infra", StackPath: "envs/dev", RunAt: now},
+	}
+	problems := validateImportRecords(records, now)
+	if len(problems) != len(records)-1 {
+		t.Fatalf("expected %d problems, got %d: %v", len(records)-1, len(problems), problems)
+	}
+	if !strings.HasPrefix(problems[len(problems)-1], "results[7]: duplicates results[6]") {
+		t.Fatalf("unexpected duplicate problem: %q", problems[len(problems)-1])
+	}
+}
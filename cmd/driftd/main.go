@@ -15,15 +15,24 @@ import (
 	"time"
 
 	"github.com/driftdhq/driftd/internal/api"
+	"github.com/driftdhq/driftd/internal/canary"
 	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/jira"
+	"github.com/driftdhq/driftd/internal/maintenance"
+	"github.com/driftdhq/driftd/internal/notify"
 	"github.com/driftdhq/driftd/internal/orchestrate"
 	"github.com/driftdhq/driftd/internal/projects"
 	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/queuealarm"
+	"github.com/driftdhq/driftd/internal/report"
 	"github.com/driftdhq/driftd/internal/runner"
 	"github.com/driftdhq/driftd/internal/scheduler"
 	"github.com/driftdhq/driftd/internal/secrets"
+	"github.com/driftdhq/driftd/internal/severity"
 	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/driftdhq/driftd/internal/warehouse"
 	"github.com/driftdhq/driftd/internal/worker"
+	"github.com/driftdhq/driftd/internal/workspaceprune"
 )
 
 //go:embed templates/*.html
@@ -49,6 +58,18 @@ func main() {
 		runServe(os.Args[2:])
 	case "worker":
 		runWorker(os.Args[2:])
+	case "snapshot":
+		runSnapshot(os.Args[2:])
+	case "restore":
+		runRestore(os.Args[2:])
+	case "validate":
+		runValidate(os.Args[2:])
+	case "config":
+		runConfig(os.Args[2:])
+	case "import-results":
+		runImportResults(os.Args[2:])
+	case "benchmark":
+		runBenchmark(os.Args[2:])
 	case "help", "-h", "--help":
 		printUsage()
 	default:
@@ -67,18 +88,76 @@ Usage:
 Commands:
   serve    Start the web server (API + UI + scheduler)
   worker   Start a worker process (stack scan processing)
+  snapshot Export durable Redis scan history to a file
+  restore  Import a snapshot into Redis
+  validate Check a config file and report every problem found
+  config print
+           Print the effective config after overlays and ${VAR} expansion
+  import-results <file.json>
+           Load results exported from another drift tool into storage
+  benchmark
+           Scan a generated repository with a fake runner and report
+           setup time, throughput and queue latency
 
 Options:
   -config string   Path to config file (default "config.yaml")
+  -standalone      serve: run without Redis, keeping queue state in memory
+                   and processing stack scans in the same process
+  -space string    worker: space to work for when the config defines spaces
+
+Worker admin options (act on running workers, then exit):
+  -list                  List live workers
+  -drain string          Stop a worker (ID, hostname, or "all") claiming new stack scans
+  -wait duration         With -drain, wait for in-flight stack scans to finish
+  -resume string         Resume a drained worker
+  -set-concurrency int   Change concurrency of the worker given by -target (default "all")
+
+Snapshot/restore options:
+  -out string      snapshot: file to write ("-" for stdout)
+  -in string       restore: file to read ("-" for stdin)
+  -overwrite       restore: replace keys that already exist
+
+Validate options:
+  -check-redis     also verify the configured queue backend is reachable
+
+Config print options:
+  -env string      overlay to merge, e.g. "prod" for config.prod.yaml
+                   (default $DRIFTD_ENV)
+  -redact          replace passwords, tokens and secrets with REDACTED
+
+Import options:
+  -dry-run         import-results: validate the file without writing results
+
+Benchmark options:
+  -stacks int            stacks in the generated repository (default 500)
+  -depth int             directory levels stacks are nested under (default 3)
+  -modules int           local modules; -module-deps and -stack-modules set
+                         how many each module and stack calls (default 20)
+  -scans int             full scans to run (default 3)
+  -concurrency int       worker concurrency (default 8)
+  -plan-time duration    time the fake runner spends per stack
+  -max-setup duration    exit 1 when the median setup time exceeds this
+  -min-throughput float  exit 1 when median stacks/s falls below this
+  -json                  print the report as JSON
 
 Examples:
   driftd serve -config config.yaml
-  driftd worker -config config.yaml`)
+  driftd serve -config config.yaml -standalone
+  driftd worker -config config.yaml
+  driftd worker -config config.yaml -space staging
+  driftd worker -config config.yaml -drain $(hostname) -wait 30m
+  driftd snapshot -config config.yaml -out driftd-redis.json
+  driftd restore -config config.yaml -in driftd-redis.json
+  driftd validate -config config.yaml -check-redis
+  driftd config print -config config.yaml -env prod -redact
+  driftd import-results -config config.yaml -dry-run old-drift.json
+  driftd benchmark -stacks 2000 -depth 4 -max-setup 5s`)
 }
 
 func runServe(args []string) {
 	fs := flag.NewFlagSet("serve", flag.ExitOnError)
 	configPath := fs.String("config", "config.yaml", "path to config file")
+	standalone := fs.Bool("standalone", false, "run without Redis: keep queue state in memory and process stack scans in this process")
 	fs.Parse(args)
 
 	cfg, err := config.Load(*configPath)
@@ -95,18 +174,84 @@ func runServe(args []string) {
 		log.Fatalf("invalid encryption key configuration: %v", err)
 	}
 
+	var handler http.Handler
+	if len(cfg.Spaces) == 0 {
+		var stop func()
+		handler, stop = startServe(cfg, "", *standalone)
+		defer stop()
+	} else {
+		var routes []spaceRoute
+		for _, name := range cfg.SpaceNames() {
+			spaceCfg, err := cfg.Space(name)
+			if err != nil {
+				log.Fatalf("failed to set up space %s: %v", name, err)
+			}
+			log.Printf("Starting space %s with %d projects in %s", name, len(spaceCfg.Projects), spaceCfg.DataDir)
+			h, stop := startServe(spaceCfg, name, *standalone)
+			defer stop()
+			routes = append(routes, newSpaceRoute(cfg, name, h))
+		}
+		handler = newSpaceHandler(routes)
+	}
+
+	// Handle shutdown
+	done := make(chan os.Signal, 1)
+	signal.Notify(done, os.Interrupt, syscall.SIGTERM)
+
+	server := &http.Server{
+		Addr:              cfg.ListenAddr,
+		Handler:           handler,
+		ReadTimeout:       15 * time.Second,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+
+	go func() {
+		log.Printf("Starting driftd server on %s", cfg.ListenAddr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("server error: %v", err)
+		}
+	}()
+
+	<-done
+	log.Println("Shutting down server...")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = server.Shutdown(ctx)
+}
+
+// startServe starts the queue, scheduler, server and background services
+// for cfg and returns the server's handler and a function that stops them.
+// space names the space cfg belongs to, or is empty without spaces.
+func startServe(cfg *config.Config, space string, standalone bool) (http.Handler, func()) {
+	var stops []func()
+	stop := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+
 	if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
 		log.Fatalf("failed to create data dir: %v", err)
 	}
 
 	// Initialize components
-	store := storage.New(cfg.DataDir)
-
-	q, err := queue.New(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, cfg.Worker.LockTTL)
-	if err != nil {
-		log.Fatalf("failed to connect to redis: %v", err)
+	store := storage.NewWithOptions(cfg.DataDir, storageOptions(cfg))
+	stops = append(stops, func() { _ = store.Close() })
+
+	var q queue.Backend
+	var err error
+	if standalone {
+		q = queue.NewMemory(cfg.Worker.LockTTL)
+		log.Printf("Standalone mode: queue state is kept in memory and lost on restart")
+	} else {
+		q, err = openQueue(cfg)
+		if err != nil {
+			log.Fatalf("failed to connect to %s queue: %v", cfg.Queue.Backend, err)
+		}
 	}
-	defer q.Close()
+	stops = append(stops, func() { _ = q.Close() })
 
 	// Initialize encryption and project store
 	keyStore := secrets.NewKeyStore(cfg.DataDir)
@@ -141,68 +286,157 @@ func runServe(args []string) {
 
 	// Create shared scan orchestrator
 	orch := orchestrate.New(cfg, q)
-	defer orch.Stop()
+	orch.SetProjectProvider(projectProvider)
+	stops = append(stops, orch.Stop)
+
+	// No separate worker can reach an in-memory queue, so process stack
+	// scans here.
+	if standalone {
+		w := worker.New(q, canary.WrapRunner(runner.New(store)), cfg.Worker.Concurrency, cfg, projectProvider)
+		w.Start()
+		stops = append(stops, w.Stop)
+	}
+
+	// Maintenance state lives in the data directory so it stays readable
+	// while Redis is down for maintenance.
+	maint := maintenance.New(cfg.DataDir)
 
 	// Start scheduler
 	sched := scheduler.New(cfg, projectProvider, orch)
+	sched.SetMaintenance(maint)
+	sched.SetPauses(q)
+	// Every replica runs the cron entries; only the lease holder starts
+	// scans, so several serve replicas don't double-schedule.
+	elector := scheduler.NewElector(q, cfg.Scheduler.LeaderLeaseTTL)
+	elector.Start()
+	stops = append(stops, elector.Stop)
+	sched.SetElector(elector)
 	if err := sched.Start(); err != nil {
 		log.Fatalf("failed to start scheduler: %v", err)
 	}
-	defer sched.Stop()
+	stops = append(stops, sched.Stop)
 
-	srv, err := api.New(
-		cfg,
-		store,
-		q,
-		templatesFS,
-		staticFS,
+	serverOpts := []api.ServerOption{
 		api.WithProjectStore(projectStore),
 		api.WithIntegrationStore(intStore),
 		api.WithProjectProvider(projectProvider),
 		api.WithOrchestrator(orch),
+		api.WithMaintenance(maint),
+		api.WithSchedulerElector(elector),
 		api.WithSchedulerCallbacks(sched.OnProjectAdded, sched.OnProjectUpdated, sched.OnProjectDeleted),
-	)
-	if err != nil {
-		log.Fatalf("failed to create server: %v", err)
 	}
-	defer srv.Stop()
-
-	// Handle shutdown
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGTERM)
+	if cfg.Report.Enabled {
+		reports, err := report.New(cfg.Report, store, severity.New(cfg.Severity), cfg.DataDir, report.NewSMTPSender(cfg.Report.SMTP))
+		if err != nil {
+			log.Fatalf("failed to initialize drift report: %v", err)
+		}
+		if err := reports.Start(); err != nil {
+			log.Fatalf("failed to schedule drift report: %v", err)
+		}
+		stops = append(stops, reports.Stop)
+		serverOpts = append(serverOpts, api.WithReportService(reports))
+	}
+	if cfg.Jira.Enabled {
+		tickets, err := jira.New(cfg.Jira, store, cfg.DataDir, jira.NewClientFromConfig(cfg.Jira))
+		if err != nil {
+			log.Fatalf("failed to initialize jira sync: %v", err)
+		}
+		tickets.SetLeader(elector)
+		tickets.Start()
+		stops = append(stops, tickets.Stop)
+		log.Printf("Syncing drifted stacks to Jira every %s", cfg.Jira.Interval)
+	}
 
-	server := &http.Server{
-		Addr:              cfg.ListenAddr,
-		Handler:           srv.Handler(),
-		ReadTimeout:       15 * time.Second,
-		ReadHeaderTimeout: 10 * time.Second,
-		WriteTimeout:      30 * time.Second,
-		IdleTimeout:       60 * time.Second,
+	if space != "" {
+		serverOpts = append(serverOpts, api.WithSpace(space))
+	}
+	srv, err := api.New(cfg, store, q, templatesFS, staticFS, serverOpts...)
+	if err != nil {
+		log.Fatalf("failed to create server: %v", err)
 	}
+	stops = append(stops, srv.Stop)
 
-	go func() {
-		log.Printf("Starting driftd server on %s", cfg.ListenAddr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server error: %v", err)
+	// Started after api.New, which registers the metrics the canary reports.
+	if cfg.Canary.Enabled {
+		prober, err := canary.New(cfg, q, orch, maint)
+		if err != nil {
+			log.Fatalf("failed to set up canary: %v", err)
 		}
-	}()
+		prober.Start()
+		stops = append(stops, prober.Stop)
+		log.Printf("Canary scans every %s", cfg.Canary.Interval)
+	}
+	if cfg.QueueAlarm.Enabled {
+		alarm := queuealarm.New(cfg.QueueAlarm, q)
+		alarm.SetLeader(elector)
+		if err := alarm.Start(); err != nil {
+			log.Fatalf("failed to start queue alarm: %v", err)
+		}
+		stops = append(stops, alarm.Stop)
+		log.Printf("Queue starvation alarm at p95 wait over %s for %s", cfg.QueueAlarm.Threshold, cfg.QueueAlarm.For)
+	}
+	if cfg.Notifications.Enabled {
+		notifier := notify.New(cfg.Notifications, store, severity.New(cfg.Severity), q)
+		notifier.SetLeader(elector)
+		if err := notifier.Start(); err != nil {
+			log.Fatalf("failed to start notifications: %v", err)
+		}
+		stops = append(stops, notifier.Stop)
+		log.Printf("Sending drift notifications to %d channels", len(cfg.Notifications.Channels))
+	}
+	if cfg.Workspace.Prune.Enabled {
+		pruner := workspaceprune.New(cfg, q)
+		pruner.SetLeader(elector)
+		pruner.Start()
+		stops = append(stops, pruner.Stop)
+		log.Printf("Pruning failed scan workspaces after %s and completed after %s", cfg.Workspace.Prune.FailedAfter, cfg.Workspace.Prune.CompletedAfter)
+	}
+	if cfg.WarehouseExport.Enabled {
+		exporter, err := warehouse.New(cfg, store, q)
+		if err != nil {
+			log.Fatalf("failed to set up warehouse export: %v", err)
+		}
+		exporter.SetLeader(elector)
+		exporter.Start()
+		stops = append(stops, exporter.Stop)
+		log.Printf("Exporting results to %s every %s", cfg.WarehouseExport.Destination, cfg.WarehouseExport.Interval)
+	}
 
-	<-done
-	log.Println("Shutting down server...")
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	_ = server.Shutdown(ctx)
+	return srv.Handler(), stop
 }
 
 func runWorker(args []string) {
 	fs := flag.NewFlagSet("worker", flag.ExitOnError)
 	configPath := fs.String("config", "config.yaml", "path to config file")
+	var admin workerAdminOptions
+	fs.BoolVar(&admin.list, "list", false, "list live workers and exit")
+	fs.StringVar(&admin.drain, "drain", "", "stop a running worker (ID, hostname, or \"all\") from claiming new stack scans")
+	fs.StringVar(&admin.resume, "resume", "", "resume a drained worker (ID, hostname, or \"all\")")
+	fs.IntVar(&admin.setConcurrency, "set-concurrency", 0, "change concurrency of the worker given by -target")
+	fs.StringVar(&admin.target, "target", queue.WorkerTargetAll, "worker for -set-concurrency (ID, hostname, or \"all\")")
+	fs.DurationVar(&admin.wait, "wait", 0, "with -drain, wait up to this long for in-flight stack scans to finish")
+	space := fs.String("space", "", "space to work for when the config defines spaces")
 	fs.Parse(args)
 
 	cfg, err := config.Load(*configPath)
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
+	cfg, err = selectSpace(cfg, *space)
+	if err != nil {
+		log.Fatalf("invalid -space: %v", err)
+	}
+	if admin.requested() {
+		q, err := openQueue(cfg)
+		if err != nil {
+			log.Fatalf("failed to connect to %s queue: %v", cfg.Queue.Backend, err)
+		}
+		defer q.Close()
+		if err := runWorkerAdmin(context.Background(), q, admin, os.Stdout); err != nil {
+			log.Fatalf("worker admin: %v", err)
+		}
+		return
+	}
 	if err := validateEncryptionKeyPolicy(cfg); err != nil {
 		log.Fatalf("invalid encryption key configuration: %v", err)
 	}
@@ -212,12 +446,15 @@ func runWorker(args []string) {
 	}
 
 	// Initialize components
-	store := storage.New(cfg.DataDir)
-	run := runner.New(store)
+	store := storage.NewWithOptions(cfg.DataDir, storageOptions(cfg))
+	defer store.Close()
+	// Canary stacks never reach terraform, whether or not this worker's
+	// config enables the canary.
+	run := canary.WrapRunner(runner.New(store))
 
-	q, err := queue.New(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, cfg.Worker.LockTTL)
+	q, err := openQueue(cfg)
 	if err != nil {
-		log.Fatalf("failed to connect to redis: %v", err)
+		log.Fatalf("failed to connect to %s queue: %v", cfg.Queue.Backend, err)
 	}
 	defer q.Close()
 
@@ -355,3 +592,39 @@ func isLoopbackListenAddr(listenAddr string) bool {
 	ip := net.ParseIP(host)
 	return ip != nil && ip.IsLoopback()
 }
+
+// openQueue connects to the queue backend selected by queue.backend.
+func openQueue(cfg *config.Config) (queue.Backend, error) {
+	if cfg.Queue.Backend == config.QueueBackendNATS {
+		return queue.NewNATS(queue.NATSOptions{
+			URL:       cfg.Queue.NATS.URL,
+			CredsFile: cfg.Queue.NATS.CredsFile,
+			Prefix:    cfg.Queue.NATS.Prefix,
+			Replicas:  cfg.Queue.NATS.Replicas,
+		}, cfg.Worker.LockTTL)
+	}
+	q, err := queue.New(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, cfg.Worker.LockTTL)
+	if err != nil {
+		return nil, err
+	}
+	q.SetCompressAbove(cfg.Redis.CompressAboveBytes)
+	q.SetRetention(queue.Retention{
+		Scans:        cfg.Redis.Retention.Scans,
+		ScanHistory:  cfg.Redis.Retention.ScanHistory,
+		StackScans:   cfg.Redis.Retention.StackScans,
+		DriftChanges: cfg.Redis.Retention.DriftChanges,
+		OutboxEvents: cfg.Redis.Retention.OutboxEvents,
+	})
+	return q, nil
+}
+
+func storageOptions(cfg *config.Config) storage.Options {
+	opts := storage.Options{
+		Fsync:         cfg.Storage.Fsync,
+		FsyncInterval: cfg.Storage.FsyncInterval,
+	}
+	if cfg.Storage.CacheTTL > 0 {
+		opts.CacheTTL = cfg.Storage.CacheTTL
+	}
+	return opts
+}
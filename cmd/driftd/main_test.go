@@ -172,3 +172,12 @@ func TestValidateInsecureDevModeBind(t *testing.T) {
 		}
 	})
 }
+
+func TestSnapshotSupported(t *testing.T) {
+	if err := snapshotSupported(&config.Config{Queue: config.QueueConfig{Backend: config.QueueBackendRedis}}); err != nil {
+		t.Fatalf("expected redis to be supported, got %v", err)
+	}
+	if err := snapshotSupported(&config.Config{Queue: config.QueueConfig{Backend: config.QueueBackendNATS}}); err == nil {
+		t.Fatal("expected nats to be refused")
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+// spaceRoute sends requests for one space's hosts to its handler.
+type spaceRoute struct {
+	name    string
+	hosts   []string
+	handler http.Handler
+}
+
+func newSpaceRoute(cfg *config.Config, name string, handler http.Handler) spaceRoute {
+	route := spaceRoute{name: name, handler: handler}
+	for _, space := range cfg.Spaces {
+		if space.Name == name {
+			route.hosts = space.Hosts
+		}
+	}
+	return route
+}
+
+// newSpaceHandler routes each request to a space by its Host header.
+// Requests matching no space get 404.
+func newSpaceHandler(routes []spaceRoute) http.Handler {
+	byHost := map[string]http.Handler{}
+	for _, route := range routes {
+		for _, host := range route.hosts {
+			byHost[host] = route.handler
+		}
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, ok := byHost[requestHost(r)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+// selectSpace returns the config of the named space. A config without
+// spaces is returned as is and takes no name.
+func selectSpace(cfg *config.Config, name string) (*config.Config, error) {
+	if len(cfg.Spaces) == 0 {
+		if name != "" {
+			return nil, fmt.Errorf("config defines no spaces")
+		}
+		return cfg, nil
+	}
+	if name == "" {
+		return nil, fmt.Errorf("config defines spaces; choose one of: %s", strings.Join(cfg.SpaceNames(), ", "))
+	}
+	return cfg.Space(name)
+}
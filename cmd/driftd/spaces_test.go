@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+func TestSpaceHandler(t *testing.T) {
+	space := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Space", name)
+			_, _ = w.Write([]byte(r.URL.Path))
+		})
+	}
+	handler := newSpaceHandler([]spaceRoute{
+		{name: "staging", hosts: []string{"drift-staging.example.com"}, handler: space("staging")},
+		{name: "prod", hosts: []string{"drift.example.com"}, handler: space("prod")},
+	})
+
+	for _, tc := range []struct {
+		host, path string
+		space      string
+		gotPath    string
+		status     int
+	}{
+		{host: "drift.example.com:8080", path: "/api/projects", space: "prod", gotPath: "/api/projects", status: http.StatusOK},
+		{host: "DRIFT.example.com", path: "/staging/api/projects", space: "prod", gotPath: "/staging/api/projects", status: http.StatusOK},
+		{host: "drift-staging.example.com", path: "/", space: "staging", gotPath: "/", status: http.StatusOK},
+		{host: "localhost", path: "/staging/api/projects", status: http.StatusNotFound},
+		{host: "localhost", path: "/api/projects", status: http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Host = tc.host
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Fatalf("%s%s: status %d, want %d", tc.host, tc.path, rec.Code, tc.status)
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+		if got := rec.Header().Get("X-Space"); got != tc.space {
+			t.Fatalf("%s%s: routed to %q, want %q", tc.host, tc.path, got, tc.space)
+		}
+		if got := rec.Body.String(); got != tc.gotPath {
+			t.Fatalf("%s%s: handler saw path %q, want %q", tc.host, tc.path, got, tc.gotPath)
+		}
+	}
+}
+
+func TestSelectSpace(t *testing.T) {
+	db := 1
+	cfg := &config.Config{
+		DataDir: "/data",
+		Spaces:  []config.SpaceConfig{{Name: "staging", RedisDB: &db, DataSubdir: "staging"}},
+	}
+	if _, err := selectSpace(cfg, ""); err == nil {
+		t.Fatalf("expected error without -space when spaces are set")
+	}
+	got, err := selectSpace(cfg, "staging")
+	if err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	if got.Redis.DB != 1 || got.DataDir != "/data/staging" {
+		t.Fatalf("unexpected space config: db=%d data_dir=%s", got.Redis.DB, got.DataDir)
+	}
+	if _, err := selectSpace(&config.Config{}, "staging"); err == nil {
+		t.Fatalf("expected error for -space without spaces")
+	}
+}
@@ -0,0 +1,96 @@
+// Shared accessibility helpers for every page: a polite live region for
+// status announcements and keyboard navigation for role="grid" lists.
+(function () {
+    // driftdAnnounce reads a message through the layout's live region. The
+    // region is cleared first so repeating a message is announced again.
+    window.driftdAnnounce = (message) => {
+        const region = document.getElementById("live-region");
+        if (!region || !message) return;
+        region.textContent = "";
+        setTimeout(() => {
+            region.textContent = message;
+        }, 50);
+    };
+
+    const bodyRows = (grid) =>
+        Array.from(grid.querySelectorAll('[role="row"]')).filter(
+            (row) => !row.querySelector('[role="columnheader"]')
+        );
+
+    // Rows use a roving tabindex: one row per grid is in the tab order and
+    // the arrow keys move focus between rows.
+    const focusRow = (grid, row) => {
+        if (!row) return;
+        bodyRows(grid).forEach((other) => other.setAttribute("tabindex", other === row ? "0" : "-1"));
+        row.focus();
+    };
+
+    const initGrid = (grid) => {
+        const rows = bodyRows(grid);
+        rows.forEach((row, i) => row.setAttribute("tabindex", i === 0 ? "0" : "-1"));
+
+        grid.addEventListener("keydown", (e) => {
+            const row = e.target.closest('[role="row"]');
+            // Keys typed into checkboxes and links keep their own behavior,
+            // except arrows, which always move between rows.
+            if (!row || !grid.contains(row)) return;
+            const current = bodyRows(grid);
+            const index = current.indexOf(row);
+            if (index < 0) return;
+            const onRow = e.target === row;
+
+            switch (e.key) {
+                case "ArrowDown":
+                    focusRow(grid, current[Math.min(index + 1, current.length - 1)]);
+                    break;
+                case "ArrowUp":
+                    focusRow(grid, current[Math.max(index - 1, 0)]);
+                    break;
+                case "Home":
+                    if (!onRow) return;
+                    focusRow(grid, current[0]);
+                    break;
+                case "End":
+                    if (!onRow) return;
+                    focusRow(grid, current[current.length - 1]);
+                    break;
+                case "Enter": {
+                    if (!onRow) return;
+                    const link = row.querySelector("a[href]");
+                    if (link) link.click();
+                    break;
+                }
+                case " ": {
+                    if (!onRow) return;
+                    const box = row.querySelector('input[type="checkbox"]');
+                    if (!box) return;
+                    box.checked = !box.checked;
+                    box.dispatchEvent(new Event("change", { bubbles: true }));
+                    break;
+                }
+                default:
+                    return;
+            }
+            e.preventDefault();
+        });
+
+        // Clicking into a row makes it the tab stop, so Shift+Tab and Tab
+        // return to where the user left off.
+        grid.addEventListener("focusin", (e) => {
+            const row = e.target.closest('[role="row"]');
+            if (!row || !bodyRows(grid).includes(row)) return;
+            bodyRows(grid).forEach((other) => other.setAttribute("tabindex", other === row ? "0" : "-1"));
+        });
+    };
+
+    document.addEventListener("DOMContentLoaded", () => {
+        document.querySelectorAll('[role="grid"]').forEach(initGrid);
+
+        // Pages that reload after a scan leave their announcement here.
+        const pending = sessionStorage.getItem("driftd-announce");
+        if (pending) {
+            sessionStorage.removeItem("driftd-announce");
+            window.driftdAnnounce(pending);
+        }
+    });
+})();
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+func runValidate(args []string) {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "path to config file")
+	checkRedis := fs.Bool("check-redis", false, "also verify the configured queue backend is reachable")
+	fs.Parse(args)
+
+	data, err := os.ReadFile(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "validate: %v\n", err)
+		os.Exit(1)
+	}
+	if n := validateConfig(*configPath, data, *checkRedis, os.Stderr); n > 0 {
+		fmt.Fprintf(os.Stderr, "\n%s: %d problem(s) found\n", *configPath, n)
+		os.Exit(1)
+	}
+	fmt.Printf("%s: config OK\n", *configPath)
+}
+
+// validateConfig writes every problem in the config to out, each followed by
+// the offending source line, and returns the number of problems.
+func validateConfig(path string, data []byte, checkRedis bool, out io.Writer) int {
+	problems := config.Validate(data)
+
+	// The serve-time security checks and the queue probe need a fully loaded
+	// config, so they only run once the file itself is clean.
+	if len(problems) == 0 {
+		cfg, err := config.Load(path)
+		if err != nil {
+			problems = append(problems, config.Problem{Message: err.Error()})
+		} else {
+			if err := validateInsecureDevModeBind(cfg); err != nil {
+				problems = append(problems, config.Problem{Path: "insecure_dev_mode", Message: err.Error()})
+			}
+			if err := validateServeSecurity(cfg); err != nil {
+				problems = append(problems, config.Problem{Path: "auth", Message: err.Error()})
+			}
+			if checkRedis {
+				q, err := openQueue(cfg)
+				if err != nil {
+					path, addr := "redis", cfg.Redis.Addr
+					if cfg.Queue.Backend == config.QueueBackendNATS {
+						path, addr = "queue.nats", cfg.Queue.NATS.URL
+					}
+					problems = append(problems, config.Problem{Path: path, Message: fmt.Sprintf("cannot connect to %s: %v", addr, err)})
+				} else {
+					q.Close()
+				}
+			}
+		}
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for _, p := range problems {
+		msg := p.Message
+		if p.Path != "" {
+			msg = p.Path + ": " + msg
+		}
+		if p.Line > 0 {
+			fmt.Fprintf(out, "%s:%d: %s\n", path, p.Line, msg)
+			if p.Line <= len(lines) {
+				fmt.Fprintf(out, "    %d | %s\n", p.Line, strings.TrimRight(lines[p.Line-1], "\r"))
+			}
+		} else {
+			fmt.Fprintf(out, "%s: %s\n", path, msg)
+		}
+	}
+	return len(problems)
+}
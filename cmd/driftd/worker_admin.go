@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+// workerAdminOptions are the worker subcommand flags that control running
+// workers instead of starting one.
+type workerAdminOptions struct {
+	list           bool
+	drain          string
+	resume         string
+	target         string
+	setConcurrency int
+	wait           time.Duration
+}
+
+func (o workerAdminOptions) requested() bool {
+	return o.list || o.drain != "" || o.resume != "" || o.setConcurrency != 0
+}
+
+func (o workerAdminOptions) command() (queue.WorkerCommand, error) {
+	var cmds []queue.WorkerCommand
+	if o.drain != "" {
+		cmds = append(cmds, queue.WorkerCommand{Action: queue.WorkerActionDrain, Target: o.drain})
+	}
+	if o.resume != "" {
+		cmds = append(cmds, queue.WorkerCommand{Action: queue.WorkerActionResume, Target: o.resume})
+	}
+	if o.setConcurrency != 0 {
+		cmds = append(cmds, queue.WorkerCommand{Action: queue.WorkerActionSetConcurrency, Target: o.target, Concurrency: o.setConcurrency})
+	}
+	if len(cmds) != 1 {
+		return queue.WorkerCommand{}, fmt.Errorf("use only one of -drain, -resume, or -set-concurrency")
+	}
+	if err := cmds[0].Validate(); err != nil {
+		return queue.WorkerCommand{}, err
+	}
+	return cmds[0], nil
+}
+
+// runWorkerAdmin publishes a worker admin command, or lists workers, and
+// optionally waits for drained workers to go idle.
+func runWorkerAdmin(ctx context.Context, q queue.Backend, opts workerAdminOptions, out io.Writer) error {
+	if opts.list {
+		workers, err := q.ListWorkers(ctx)
+		if err != nil {
+			return err
+		}
+		printWorkers(out, workers)
+		return nil
+	}
+
+	cmd, err := opts.command()
+	if err != nil {
+		return err
+	}
+	workers, err := q.ListWorkers(ctx)
+	if err != nil {
+		return err
+	}
+	if !anyWorkerMatches(cmd, workers) {
+		return fmt.Errorf("no live worker matches %q", cmd.Target)
+	}
+	receivers, err := q.PublishWorkerCommand(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "sent %s to %s (%d worker processes listening)\n", cmd.Action, cmd.Target, receivers)
+
+	if cmd.Action != queue.WorkerActionDrain || opts.wait <= 0 {
+		return nil
+	}
+	return waitForDrain(ctx, q, cmd, opts.wait, out)
+}
+
+func waitForDrain(ctx context.Context, q queue.Backend, cmd queue.WorkerCommand, timeout time.Duration, out io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		workers, err := q.ListWorkers(ctx)
+		if err == nil {
+			idle := true
+			for _, w := range workers {
+				if cmd.Matches(w) && (!w.Draining || w.Active > 0) {
+					idle = false
+					break
+				}
+			}
+			if idle {
+				fmt.Fprintf(out, "%s drained\n", cmd.Target)
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %s to drain", cmd.Target)
+		case <-ticker.C:
+		}
+	}
+}
+
+func anyWorkerMatches(cmd queue.WorkerCommand, workers []queue.WorkerInfo) bool {
+	for _, w := range workers {
+		if cmd.Matches(w) {
+			return true
+		}
+	}
+	return false
+}
+
+func printWorkers(out io.Writer, workers []queue.WorkerInfo) {
+	if len(workers) == 0 {
+		fmt.Fprintln(out, "no live workers")
+		return
+	}
+	for _, w := range workers {
+		state := "active"
+		if w.Draining {
+			state = "draining"
+		}
+		fmt.Fprintf(out, "%s\t%s\tconcurrency=%d\tin_flight=%d\tlast_seen=%s\n",
+			w.ID, state, w.Concurrency, w.Active, time.Since(w.LastSeen).Round(time.Second))
+	}
+}
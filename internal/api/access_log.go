@@ -0,0 +1,167 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+const principalContextKey contextKey = "principal"
+
+// accessLog writes one line per HTTP request, as text or JSON, to stdout or
+// an append-only file.
+type accessLog struct {
+	mu      sync.Mutex
+	out     io.Writer
+	file    *os.File
+	json    bool
+	exclude []string
+}
+
+// accessRecord is one access log line.
+type accessRecord struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int       `json:"bytes"`
+	DurationMS float64   `json:"duration_ms"`
+	RemoteIP   string    `json:"remote_ip"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Auth       string    `json:"auth,omitempty"`
+	Principal  string    `json:"principal,omitempty"`
+	Project    string    `json:"project,omitempty"`
+	Stack      string    `json:"stack,omitempty"`
+}
+
+// requestPrincipal is filled in by whichever auth check accepts the
+// request, so the access log can name who made it.
+type requestPrincipal struct {
+	auth string
+	name string
+}
+
+func newAccessLog(cfg config.AccessLogConfig) (*accessLog, error) {
+	l := &accessLog{
+		out:     os.Stdout,
+		json:    cfg.Format == "json",
+		exclude: cfg.ExcludePaths,
+	}
+	if cfg.Path != "" {
+		f, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+		if err != nil {
+			return nil, fmt.Errorf("open access log: %w", err)
+		}
+		l.out = f
+		l.file = f
+	}
+	return l, nil
+}
+
+func (l *accessLog) excluded(path string) bool {
+	for _, prefix := range l.exclude {
+		if prefix != "" && strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *accessLog) write(rec accessRecord) {
+	var line []byte
+	if l.json {
+		line, _ = json.Marshal(rec)
+		line = append(line, '\n')
+	} else {
+		line = []byte(formatAccessRecord(rec))
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.out.Write(line)
+}
+
+func (l *accessLog) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+func formatAccessRecord(rec accessRecord) string {
+	principal := rec.Principal
+	if principal == "" {
+		principal = "-"
+	}
+	line := fmt.Sprintf("%s %s %s %q %d %dB %s",
+		rec.Time.Format(time.RFC3339), rec.RemoteIP, principal,
+		rec.Method+" "+rec.Path+" "+rec.Proto, rec.Status, rec.Bytes,
+		time.Duration(rec.DurationMS*float64(time.Millisecond)).Round(time.Microsecond))
+	if rec.Project != "" {
+		line += " project=" + rec.Project
+	}
+	if rec.Stack != "" {
+		line += " stack=" + rec.Stack
+	}
+	return line + "\n"
+}
+
+// accessLogMiddleware replaces chi's request logger. It records the status,
+// size and duration of each response together with the authenticated
+// principal and the project and stack the route addressed.
+func (s *Server) accessLogMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.accessLog == nil || s.accessLog.excluded(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		start := time.Now()
+		principal := &requestPrincipal{}
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), principalContextKey, principal)))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		rec := accessRecord{
+			Time:       start.UTC(),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Proto:      r.Proto,
+			Status:     status,
+			Bytes:      ww.BytesWritten(),
+			DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+			RemoteIP:   s.clientIP(r),
+			UserAgent:  r.UserAgent(),
+			Auth:       principal.auth,
+			Principal:  principal.name,
+		}
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if project := rctx.URLParam("project"); project != "" {
+				rec.Project = project
+				rec.Stack = rctx.URLParam("*")
+			}
+		}
+		s.accessLog.write(rec)
+	})
+}
+
+// setPrincipal records who a request was authenticated as for the access
+// log. auth names the method: session, basic, token, write_token or external.
+func setPrincipal(r *http.Request, auth, name string) {
+	if p, ok := r.Context().Value(principalContextKey).(*requestPrincipal); ok {
+		p.auth = auth
+		p.name = name
+	}
+}
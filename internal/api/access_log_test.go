@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+func TestAccessLogJSON(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "access.log")
+	_, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, func(cfg *config.Config) {
+		cfg.APIAuth.Token = "secret"
+		cfg.APIAuth.TokenHeader = "X-API-Token"
+		cfg.AccessLog = config.AccessLogConfig{Format: "json", Path: logPath, ExcludePaths: []string{"/api/health"}}
+	})
+	defer cleanup()
+
+	get := func(path string, token string) {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+path, nil)
+		if token != "" {
+			req.Header.Set("X-API-Token", token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+	get("/api/health", "")
+	get("/api/projects/project/stacks/envs/prod", "secret")
+	get("/api/projects/project/stacks", "")
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		t.Fatalf("open log: %v", err)
+	}
+	defer f.Close()
+	var records []accessRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var rec accessRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("decode %q: %v", scanner.Text(), err)
+		}
+		records = append(records, rec)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records (health excluded), got %d: %+v", len(records), records)
+	}
+
+	stack := records[0]
+	if stack.Path != "/api/projects/project/stacks/envs/prod" || stack.Method != http.MethodGet {
+		t.Fatalf("unexpected request fields: %+v", stack)
+	}
+	if stack.Auth != "token" || stack.Principal != "api_token" {
+		t.Fatalf("expected token principal, got %+v", stack)
+	}
+	if stack.Project != "project" || stack.Stack != "envs/prod" {
+		t.Fatalf("expected route params, got project=%q stack=%q", stack.Project, stack.Stack)
+	}
+	if stack.Bytes == 0 || stack.Status == 0 || stack.RemoteIP == "" {
+		t.Fatalf("expected status, size and remote ip, got %+v", stack)
+	}
+
+	denied := records[1]
+	if denied.Status != http.StatusUnauthorized || denied.Principal != "" {
+		t.Fatalf("expected anonymous 401, got %+v", denied)
+	}
+}
+
+func TestFormatAccessRecord(t *testing.T) {
+	line := formatAccessRecord(accessRecord{
+		Method:     http.MethodPost,
+		Path:       "/api/projects/infra/scan",
+		Proto:      "HTTP/1.1",
+		Status:     200,
+		Bytes:      42,
+		DurationMS: 1.5,
+		RemoteIP:   "10.0.0.1",
+		Principal:  "alice",
+		Project:    "infra",
+	})
+	want := `0001-01-01T00:00:00Z 10.0.0.1 alice "POST /api/projects/infra/scan HTTP/1.1" 200 42B 1.5ms project=infra` + "\n"
+	if line != want {
+		t.Fatalf("unexpected line:\n got %q\nwant %q", line, want)
+	}
+}
@@ -0,0 +1,43 @@
+package api
+
+import "net/http"
+
+// Cookies set by the settings page to override the configured display
+// modes for one browser.
+const (
+	motionCookie   = "driftd_motion"
+	contrastCookie = "driftd_contrast"
+)
+
+// accessibilityPrefs are rendered on the root element of every page, so the
+// first paint already honors them and no script has to run.
+type accessibilityPrefs struct {
+	ReducedMotion bool
+	HighContrast  bool
+}
+
+// accessibilityPrefs starts from api.accessibility and applies the
+// browser's overrides. Unknown cookie values are ignored.
+func (s *Server) accessibilityPrefs(r *http.Request) accessibilityPrefs {
+	prefs := accessibilityPrefs{
+		ReducedMotion: s.cfg.API.Accessibility.ReducedMotion,
+		HighContrast:  s.cfg.API.Accessibility.HighContrast,
+	}
+	if c, err := r.Cookie(motionCookie); err == nil {
+		switch c.Value {
+		case "reduce":
+			prefs.ReducedMotion = true
+		case "full":
+			prefs.ReducedMotion = false
+		}
+	}
+	if c, err := r.Cookie(contrastCookie); err == nil {
+		switch c.Value {
+		case "high":
+			prefs.HighContrast = true
+		case "normal":
+			prefs.HighContrast = false
+		}
+	}
+	return prefs
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+func TestAccessibilityPrefs(t *testing.T) {
+	s := &Server{cfg: &config.Config{}}
+	s.cfg.API.Accessibility.HighContrast = true
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if got := s.accessibilityPrefs(req); got.ReducedMotion || !got.HighContrast {
+		t.Fatalf("expected configured defaults, got %+v", got)
+	}
+
+	req.AddCookie(&http.Cookie{Name: motionCookie, Value: "reduce"})
+	req.AddCookie(&http.Cookie{Name: contrastCookie, Value: "normal"})
+	if got := s.accessibilityPrefs(req); !got.ReducedMotion || got.HighContrast {
+		t.Fatalf("expected cookies to override defaults, got %+v", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: contrastCookie, Value: "maybe"})
+	if got := s.accessibilityPrefs(req); !got.HighContrast {
+		t.Fatalf("expected unknown cookie values to be ignored, got %+v", got)
+	}
+}
+
+func TestProjectPaginationRowIndex(t *testing.T) {
+	p := projectPagination{Page: 3, PerPage: 25}
+	if got := p.RowIndex(0); got != 52 {
+		t.Fatalf("expected first row of page 3 at index 52, got %d", got)
+	}
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+type apiRunningStackScan struct {
+	ID             string `json:"id"`
+	ScanID         string `json:"scan_id"`
+	ProjectName    string `json:"project_name"`
+	StackPath      string `json:"stack_path"`
+	WorkerID       string `json:"worker_id"`
+	Trigger        string `json:"trigger,omitempty"`
+	StartedAt      int64  `json:"started_at"`
+	ElapsedSeconds int64  `json:"elapsed_seconds"`
+}
+
+type activityPageData struct {
+	pageAuth
+	Running []runningStackView
+}
+
+type runningStackView struct {
+	apiRunningStackScan
+	Elapsed string
+}
+
+// handleListStackScans lists stack scans across all projects. Only running
+// stack scans are indexed globally, so status=running is the only filter.
+func (s *Server) handleListStackScans(w http.ResponseWriter, r *http.Request) {
+	if status := r.URL.Query().Get("status"); status != "" && status != queue.StatusRunning {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "only status=running is supported"})
+		return
+	}
+	running, err := s.runningStackScans(r, time.Now())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	writeJSON(w, http.StatusOK, running)
+}
+
+func (s *Server) handleActivity(w http.ResponseWriter, r *http.Request) {
+	data := activityPageData{pageAuth: s.pageAuth(r)}
+	// Running stack scans only exist in the queue, so a read-only server
+	// shows the stale data banner alone.
+	if !data.Degraded.Degraded {
+		running, err := s.runningStackScans(r, time.Now())
+		if err != nil {
+			http.Error(w, "Failed to list running stack scans", http.StatusInternalServerError)
+			return
+		}
+		for _, st := range running {
+			data.Running = append(data.Running, runningStackView{
+				apiRunningStackScan: st,
+				Elapsed:             (time.Duration(st.ElapsedSeconds) * time.Second).String(),
+			})
+		}
+	}
+	if err := s.tmplActivity.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("template error: %v", err)
+	}
+}
+
+func (s *Server) runningStackScans(r *http.Request, now time.Time) ([]apiRunningStackScan, error) {
+	stackScans, err := s.queue.ListRunningStackScans(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	out := make([]apiRunningStackScan, 0, len(stackScans))
+	for _, st := range stackScans {
+		elapsed := now.Sub(st.StartedAt)
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		out = append(out, apiRunningStackScan{
+			ID:             st.ID,
+			ScanID:         st.ScanID,
+			ProjectName:    st.ProjectName,
+			StackPath:      st.StackPath,
+			WorkerID:       st.WorkerID,
+			Trigger:        st.Trigger,
+			StartedAt:      st.StartedAt.Unix(),
+			ElapsedSeconds: int64(elapsed / time.Second),
+		})
+	}
+	return out, nil
+}
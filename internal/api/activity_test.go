@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+func TestListRunningStackScans(t *testing.T) {
+	ts, q, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true)
+	defer cleanup()
+
+	ctx := context.Background()
+	if err := q.Enqueue(ctx, &queue.StackScan{ProjectName: "project", StackPath: "envs/prod", Trigger: "manual"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	dctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	job, err := q.Dequeue(dctx, "worker-a")
+	if err != nil {
+		t.Fatalf("dequeue: %v", err)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/stack-scans?status=running")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var running []apiRunningStackScan
+	if err := json.NewDecoder(resp.Body).Decode(&running); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(running) != 1 {
+		t.Fatalf("unexpected response %d: %+v", resp.StatusCode, running)
+	}
+	got := running[0]
+	if got.ID != job.ID || got.ProjectName != "project" || got.StackPath != "envs/prod" || got.WorkerID != "worker-a" || got.Trigger != "manual" || got.ElapsedSeconds < 0 {
+		t.Fatalf("unexpected running stack scan %+v", got)
+	}
+
+	resp, err = http.Get(ts.URL + "/api/stack-scans?status=failed")
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unsupported status, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/activity")
+	if err != nil {
+		t.Fatalf("activity page: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected activity page 200, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	badgeColorClean   = "#4c1"
+	badgeColorDrifted = "#e05d44"
+	badgeColorErrored = "#fe7d37"
+	badgeColorUnknown = "#9f9f9f"
+
+	defaultBadgeLabel = "drift"
+	maxBadgeLabelLen  = 40
+)
+
+// badge is one status image: a grey label on the left and a coloured
+// message on the right.
+type badge struct {
+	Label   string
+	Message string
+	Color   string
+}
+
+// handleBadge serves GET /badge/{project}.svg and
+// /badge/{project}/stacks/{stack}.svg. Stack paths contain slashes, so the
+// route is a wildcard. A stack without results gets a "no data" badge rather
+// than a broken image; an unknown project is a 404.
+func (s *Server) handleBadge(w http.ResponseWriter, r *http.Request) {
+	rest, ok := strings.CutSuffix(chi.URLParam(r, "*"), ".svg")
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	projectName, stackPath, isStack := strings.Cut(rest, "/stacks/")
+	if !isValidProjectName(projectName) || (isStack && stackPath == "") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if _, err := s.getProjectConfig(projectName); err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	stacks, err := s.storage.ListStacks(projectName)
+	if err != nil {
+		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	var b badge
+	if isStack {
+		var matched []storage.StackStatus
+		for _, st := range stacks {
+			if st.Path == stackPath {
+				matched = append(matched, st)
+				break
+			}
+		}
+		b = statusBadge(matched, time.Now())
+	} else {
+		b = statusBadge(filterParentStackStatuses(stacks), time.Now())
+	}
+	if label := strings.TrimSpace(r.URL.Query().Get("label")); label != "" {
+		if utf8.RuneCountInString(label) > maxBadgeLabelLen {
+			label = string([]rune(label)[:maxBadgeLabelLen])
+		}
+		b.Label = label
+	}
+	s.writeBadge(w, r, b)
+}
+
+// statusBadge summarizes stacks: drift wins over errors, and the age is
+// that of the most recent run.
+func statusBadge(stacks []storage.StackStatus, now time.Time) badge {
+	b := badge{Label: defaultBadgeLabel}
+	if len(stacks) == 0 {
+		b.Message, b.Color = "no data", badgeColorUnknown
+		return b
+	}
+	drifted, errored := 0, 0
+	var last time.Time
+	for _, st := range stacks {
+		if st.Drifted && !st.Suppressed {
+			drifted++
+		}
+		if st.Error != "" {
+			errored++
+		}
+		if st.RunAt.After(last) {
+			last = st.RunAt
+		}
+	}
+	switch {
+	case drifted > 0:
+		b.Message, b.Color = fmt.Sprintf("%d drifted", drifted), badgeColorDrifted
+		if len(stacks) == 1 {
+			b.Message = "drifted"
+		}
+	case errored > 0:
+		b.Message, b.Color = fmt.Sprintf("%d errored", errored), badgeColorErrored
+		if len(stacks) == 1 {
+			b.Message = "error"
+		}
+	default:
+		b.Message, b.Color = "clean", badgeColorClean
+	}
+	if !last.IsZero() {
+		b.Message += " · " + shortAge(now.Sub(last))
+	}
+	return b
+}
+
+func shortAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+	}
+}
+
+// writeBadge sends the SVG with caching headers. Badges for the same state
+// and age render identically, so the ETag lets caches revalidate cheaply.
+func (s *Server) writeBadge(w http.ResponseWriter, r *http.Request, b badge) {
+	svg := b.svg()
+	sum := sha256.Sum256([]byte(svg))
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	scope := "private"
+	if s.cfg.Badges.Public {
+		scope = "public"
+	}
+	if maxAge := s.cfg.Badges.CacheMaxAge; maxAge < 0 {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, int(maxAge.Seconds())))
+	}
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(svg))
+}
+
+// badgeTextWidth approximates the width of text in 11px Verdana.
+func badgeTextWidth(text string) int {
+	return utf8.RuneCountInString(text)*7 + 10
+}
+
+func (b badge) svg() string {
+	lw := badgeTextWidth(b.Label)
+	mw := badgeTextWidth(b.Message)
+	total := lw + mw
+	label := html.EscapeString(b.Label)
+	message := html.EscapeString(b.Message)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="20" role="img" aria-label="%s: %s">`, total, label, message)
+	fmt.Fprintf(&sb, `<title>%s: %s</title>`, label, message)
+	sb.WriteString(`<linearGradient id="s" x2="0" y2="100%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`)
+	fmt.Fprintf(&sb, `<clipPath id="r"><rect width="%d" height="20" rx="3" fill="#fff"/></clipPath>`, total)
+	fmt.Fprintf(&sb, `<g clip-path="url(#r)"><rect width="%d" height="20" fill="#555"/><rect x="%d" width="%d" height="20" fill="%s"/><rect width="%d" height="20" fill="url(#s)"/></g>`, lw, lw, mw, b.Color, total)
+	sb.WriteString(`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`)
+	fmt.Fprintf(&sb, `<text x="%d" y="14">%s</text><text x="%d" y="14">%s</text></g></svg>`, lw/2, label, lw+mw/2, message)
+	return sb.String()
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func getBadge(t *testing.T, url string, header http.Header) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get badge: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestBadges(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod", "envs/dev"}, false, nil, true, func(cfg *config.Config) {
+		cfg.Badges = config.BadgesConfig{Public: true, CacheMaxAge: time.Minute}
+		cfg.UIAuth = config.UIAuthConfig{Username: "admin", Password: "secret"}
+	})
+	defer cleanup()
+
+	resp, body := getBadge(t, ts.URL+"/badge/project.svg", nil)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, "drift: no data") {
+		t.Fatalf("expected public no-data badge, got %d %s", resp.StatusCode, body)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "image/svg+xml") {
+		t.Fatalf("content type = %q", ct)
+	}
+	if cc := resp.Header.Get("Cache-Control"); cc != "public, max-age=60" {
+		t.Fatalf("cache control = %q", cc)
+	}
+
+	now := time.Now()
+	if err := srv.storage.SaveResult("project", "envs/prod", &storage.RunResult{RunAt: now.Add(-2 * time.Hour), Drifted: true, Changed: 1}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := srv.storage.SaveResult("project", "envs/dev", &storage.RunResult{RunAt: now.Add(-3 * time.Hour)}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	resp, body = getBadge(t, ts.URL+"/badge/project.svg?label=infra", nil)
+	if !strings.Contains(body, "infra: 1 drifted · 2h ago") || !strings.Contains(body, badgeColorDrifted) {
+		t.Fatalf("unexpected project badge: %s", body)
+	}
+	etag := resp.Header.Get("ETag")
+	resp, _ = getBadge(t, ts.URL+"/badge/project.svg?label=infra", http.Header{"If-None-Match": {etag}})
+	if resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("expected 304 for matching etag, got %d", resp.StatusCode)
+	}
+
+	_, body = getBadge(t, ts.URL+"/badge/project/stacks/envs/dev.svg", nil)
+	if !strings.Contains(body, "drift: clean · 3h ago") || !strings.Contains(body, badgeColorClean) {
+		t.Fatalf("unexpected stack badge: %s", body)
+	}
+	_, body = getBadge(t, ts.URL+"/badge/project/stacks/envs/missing.svg", nil)
+	if !strings.Contains(body, "no data") {
+		t.Fatalf("unexpected missing stack badge: %s", body)
+	}
+
+	for _, path := range []string{"/badge/unknown.svg", "/badge/project.png", "/badge/project/other/x.svg"} {
+		if resp, _ := getBadge(t, ts.URL+path, nil); resp.StatusCode != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestBadgesRequireUIAuthUnlessPublic(t *testing.T) {
+	_, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, func(cfg *config.Config) {
+		cfg.UIAuth = config.UIAuthConfig{Username: "admin", Password: "secret"}
+	})
+	defer cleanup()
+
+	resp, _ := getBadge(t, ts.URL+"/badge/project.svg", nil)
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without auth, got %d", resp.StatusCode)
+	}
+	auth := http.Header{"Authorization": {"Basic " + base64.StdEncoding.EncodeToString([]byte("admin:secret"))}}
+	resp, _ = getBadge(t, ts.URL+"/badge/project.svg", auth)
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Cache-Control"), "private") {
+		t.Fatalf("expected private badge with auth, got %d %q", resp.StatusCode, resp.Header.Get("Cache-Control"))
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+// branchRoutePrefixes are the path families whose next segment is a project
+// name.
+var branchRoutePrefixes = []string{"/api/projects/", "/projects/"}
+
+type branchOption struct {
+	Branch  string
+	Project string
+	Current bool
+}
+
+// branchProjects returns the projects expanded from a multi-branch project,
+// in configuration order.
+func (s *Server) branchProjects(group string) []config.ProjectConfig {
+	var out []config.ProjectConfig
+	for _, project := range s.cfg.Projects {
+		if project.BranchGroup != "" && project.BranchGroup == group {
+			out = append(out, project)
+		}
+	}
+	return out
+}
+
+// branchOptions lists the sibling branches of a branch project for the UI
+// selector. It returns nil for projects that are not part of a group.
+func (s *Server) branchOptions(projectCfg *config.ProjectConfig) []branchOption {
+	if projectCfg == nil || projectCfg.BranchGroup == "" {
+		return nil
+	}
+	siblings := s.branchProjects(projectCfg.BranchGroup)
+	out := make([]branchOption, 0, len(siblings))
+	for _, sibling := range siblings {
+		out = append(out, branchOption{
+			Branch:  sibling.Branch,
+			Project: sibling.Name,
+			Current: sibling.Name == projectCfg.Name,
+		})
+	}
+	return out
+}
+
+// resolveBranchProject maps a multi-branch project name and an optional
+// branch onto the branch project that serves it. Without a branch the first
+// configured branch is used. ok is false when the branch is unknown.
+func (s *Server) resolveBranchProject(name, branch string) (string, bool) {
+	siblings := s.branchProjects(name)
+	if len(siblings) == 0 {
+		if branch == "" {
+			return name, true
+		}
+		if projectCfg, err := s.getProjectConfig(name); err == nil && projectCfg.Branch == branch {
+			return name, true
+		}
+		return "", false
+	}
+	if branch == "" {
+		return siblings[0].Name, true
+	}
+	for _, sibling := range siblings {
+		if sibling.Branch == branch {
+			return sibling.Name, true
+		}
+	}
+	return "", false
+}
+
+// branchRoutesMiddleware lets /projects/{project} and /api/projects/{project}
+// routes address a multi-branch project by its configured name plus
+// ?branch=, rewriting the path to the branch project before routing.
+func (s *Server) branchRoutesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix, name, rest, ok := splitProjectRoute(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		branch := strings.TrimSpace(r.URL.Query().Get("branch"))
+		target, found := s.resolveBranchProject(name, branch)
+		if !found {
+			http.Error(w, "Unknown branch", http.StatusNotFound)
+			return
+		}
+		if target == name {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = prefix + target + rest
+		r2.URL.RawPath = ""
+		if _, _, rawRest, ok := splitProjectRoute(r.URL.RawPath); ok {
+			r2.URL.RawPath = prefix + target + rawRest
+		}
+		r2.RequestURI = r2.URL.RequestURI()
+		next.ServeHTTP(w, r2)
+	})
+}
+
+func splitProjectRoute(path string) (prefix, name, rest string, ok bool) {
+	for _, p := range branchRoutePrefixes {
+		if !strings.HasPrefix(path, p) {
+			continue
+		}
+		tail := path[len(p):]
+		name, rest = tail, ""
+		if i := strings.IndexByte(tail, '/'); i >= 0 {
+			name, rest = tail[:i], tail[i:]
+		}
+		if name == "" {
+			return "", "", "", false
+		}
+		return p, name, rest, true
+	}
+	return "", "", "", false
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestBranchRoutesSelectBranchProject(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, func(cfg *config.Config) {
+		base := cfg.Projects[0]
+		cfg.Projects = nil
+		for _, branch := range []string{"release/prod", "release/staging"} {
+			p := base
+			p.Name = config.BranchProjectName("infra", branch)
+			p.Branch = branch
+			p.BranchGroup = "infra"
+			cfg.Projects = append(cfg.Projects, p)
+		}
+	})
+	defer cleanup()
+
+	if err := srv.storage.SaveResult("infra--release-prod", "envs/prod", &storage.RunResult{RunAt: time.Now()}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+	if err := srv.storage.SaveResult("infra--release-staging", "envs/prod", &storage.RunResult{RunAt: time.Now(), Drifted: true}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+
+	cases := []struct {
+		query       string
+		wantProject string
+		wantDrifted bool
+	}{
+		{"", "infra--release-prod", false},
+		{"?branch=release/prod", "infra--release-prod", false},
+		{"?branch=release/staging", "infra--release-staging", true},
+	}
+	for _, tc := range cases {
+		resp, err := http.Get(ts.URL + "/api/projects/infra/stacks/envs/prod/plan" + tc.query)
+		if err != nil {
+			t.Fatalf("plan %q: %v", tc.query, err)
+		}
+		var plan apiStackPlan
+		err = json.NewDecoder(resp.Body).Decode(&plan)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || err != nil {
+			t.Fatalf("plan %q: status %d, decode %v", tc.query, resp.StatusCode, err)
+		}
+		if plan.ProjectName != tc.wantProject || plan.Drifted != tc.wantDrifted {
+			t.Fatalf("plan %q: unexpected %+v", tc.query, plan)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/api/projects/infra/stacks/envs/prod/plan?branch=main")
+	if err != nil {
+		t.Fatalf("plan unknown branch: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown branch, got %d", resp.StatusCode)
+	}
+
+	// Branch projects stay directly addressable by their expanded name.
+	direct, err := http.Get(ts.URL + "/api/projects/infra--release-staging/stacks/envs/prod/plan")
+	if err != nil {
+		t.Fatalf("plan direct: %v", err)
+	}
+	direct.Body.Close()
+	if direct.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for direct branch project, got %d", direct.StatusCode)
+	}
+
+	opts := srv.branchOptions(srv.cfg.GetProject("infra--release-staging"))
+	if len(opts) != 2 || opts[0].Branch != "release/prod" || !opts[1].Current {
+		t.Fatalf("unexpected branch options: %+v", opts)
+	}
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/driftdhq/driftd/internal/pathutil"
+	"github.com/driftdhq/driftd/internal/severity"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+// maxBulkStatusStacks caps the stacks one bulk status request may ask for.
+const maxBulkStatusStacks = 1000
+
+// maxBulkStatusBody bounds the request body; 1000 long stack paths fit well
+// within it.
+const maxBulkStatusBody = 1 << 20
+
+type bulkStatusRequest struct {
+	Stacks []bulkStatusKey `json:"stacks"`
+}
+
+type bulkStatusKey struct {
+	Project   string `json:"project"`
+	StackPath string `json:"stack_path"`
+}
+
+// apiBulkStackStatus is the last result of one requested stack. Found is
+// false, with Error set, when the stack has no result or the key is invalid.
+type apiBulkStackStatus struct {
+	Project   string `json:"project"`
+	StackPath string `json:"stack_path"`
+	Found     bool   `json:"found"`
+	// Status is "drifted", "healthy" or "error".
+	Status        string `json:"status,omitempty"`
+	Drifted       bool   `json:"drifted"`
+	Added         int    `json:"added"`
+	Changed       int    `json:"changed"`
+	Destroyed     int    `json:"destroyed"`
+	Severity      int    `json:"severity"`
+	SeverityLevel string `json:"severity_level,omitempty"`
+	Suppressed    bool   `json:"suppressed,omitempty"`
+	Acknowledged  bool   `json:"acknowledged,omitempty"`
+	Error         string `json:"error,omitempty"`
+	RunAt         int64  `json:"run_at,omitempty"`
+}
+
+type bulkStatusResponse struct {
+	Stacks []apiBulkStackStatus `json:"stacks"`
+	// NotFound counts the requested stacks without a result.
+	NotFound int `json:"not_found"`
+}
+
+// handleBulkStatus serves POST /api/bulk/status: the current status of up to
+// maxBulkStatusStacks (project, stack_path) pairs, in request order. Sync
+// jobs use it instead of one GET per stack, and it takes a single token from
+// the per-IP rate limiter. Each project's stacks are read once.
+func (s *Server) handleBulkStatus(w http.ResponseWriter, r *http.Request) {
+	var req bulkStatusRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBulkStatusBody)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	if len(req.Stacks) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "stacks is required"})
+		return
+	}
+	if len(req.Stacks) > maxBulkStatusStacks {
+		writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("at most %d stacks per request", maxBulkStatusStacks)})
+		return
+	}
+
+	projects := map[string]map[string]storage.StackStatus{}
+	resp := bulkStatusResponse{Stacks: make([]apiBulkStackStatus, 0, len(req.Stacks))}
+	for _, key := range req.Stacks {
+		item := apiBulkStackStatus{Project: key.Project, StackPath: key.StackPath}
+		switch {
+		case !isValidProjectName(key.Project):
+			item.Error = "invalid project name"
+		case !pathutil.IsSafeStackPath(key.StackPath):
+			item.Error = "invalid stack path"
+		default:
+			stacks, ok := projects[key.Project]
+			if !ok {
+				stacks = s.bulkProjectStacks(key.Project)
+				projects[key.Project] = stacks
+			}
+			if st, found := stacks[key.StackPath]; found {
+				item = s.bulkStackStatus(key.Project, st)
+			} else {
+				item.Error = "not found"
+			}
+		}
+		if !item.Found {
+			resp.NotFound++
+		}
+		resp.Stacks = append(resp.Stacks, item)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// bulkProjectStacks indexes a configured project's stacks by path. Unknown
+// projects have none.
+func (s *Server) bulkProjectStacks(projectName string) map[string]storage.StackStatus {
+	if _, err := s.getProjectConfig(projectName); err != nil {
+		return nil
+	}
+	stacks, err := s.storage.ListStacks(projectName)
+	if err != nil {
+		return nil
+	}
+	byPath := make(map[string]storage.StackStatus, len(stacks))
+	for _, st := range stacks {
+		byPath[st.Path] = st
+	}
+	return byPath
+}
+
+func (s *Server) bulkStackStatus(projectName string, st storage.StackStatus) apiBulkStackStatus {
+	score := s.severity.Score(st)
+	item := apiBulkStackStatus{
+		Project:       projectName,
+		StackPath:     st.Path,
+		Found:         true,
+		Status:        "healthy",
+		Drifted:       st.Drifted,
+		Added:         st.Added,
+		Changed:       st.Changed,
+		Destroyed:     st.Destroyed,
+		Severity:      score,
+		SeverityLevel: severity.Level(score),
+		Suppressed:    st.Suppressed,
+		Acknowledged:  st.Acknowledged,
+		Error:         st.Error,
+		RunAt:         st.RunAt.Unix(),
+	}
+	switch {
+	case st.Error != "":
+		item.Status = "error"
+	case st.Drifted:
+		item.Status = "drifted"
+	}
+	return item
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestBulkStatus(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod", "envs/dev"}, false, nil, true, nil)
+	defer cleanup()
+
+	now := time.Now()
+	if err := srv.storage.SaveResult("project", "envs/prod", &storage.RunResult{RunAt: now, Drifted: true, Changed: 1}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+	if err := srv.storage.SaveResult("project", "envs/dev", &storage.RunResult{RunAt: now, Error: "plan failed"}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+
+	post := func(body string) (*http.Response, bulkStatusResponse) {
+		t.Helper()
+		resp, err := http.Post(ts.URL+"/api/bulk/status", "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("bulk status: %v", err)
+		}
+		defer resp.Body.Close()
+		var out bulkStatusResponse
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp, out
+	}
+
+	resp, out := post(`{"stacks": [
+		{"project": "project", "stack_path": "envs/dev"},
+		{"project": "project", "stack_path": "envs/prod"},
+		{"project": "project", "stack_path": "envs/missing"},
+		{"project": "other", "stack_path": "envs/prod"},
+		{"project": "project", "stack_path": "../etc"}
+	]}`)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if len(out.Stacks) != 5 || out.NotFound != 3 {
+		t.Fatalf("expected 5 stacks with 3 not found, got %+v", out)
+	}
+	if st := out.Stacks[0]; st.StackPath != "envs/dev" || st.Status != "error" || st.Error != "plan failed" {
+		t.Fatalf("unexpected dev status %+v", st)
+	}
+	if st := out.Stacks[1]; !st.Found || st.Status != "drifted" || st.Changed != 1 || st.SeverityLevel == "" {
+		t.Fatalf("unexpected prod status %+v", st)
+	}
+	if st := out.Stacks[4]; st.Found || st.Error != "invalid stack path" {
+		t.Fatalf("expected invalid path to be reported, got %+v", st)
+	}
+
+	var keys []string
+	for i := 0; i <= maxBulkStatusStacks; i++ {
+		keys = append(keys, fmt.Sprintf(`{"project": "project", "stack_path": "envs/s%d"}`, i))
+	}
+	body := `{"stacks": [` + keys[0]
+	for _, key := range keys[1:] {
+		body += "," + key
+	}
+	if resp, _ := post(body + "]}"); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 over the stack limit, got %d", resp.StatusCode)
+	}
+	if resp, _ := post(`{"stacks": []}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 without stacks, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+)
+
+// legacyRoutePrefixes maps the deprecated "repo" path families to their
+// "project" successors. Longer prefixes come first so the settings API is not
+// caught by the generic /api/repos rule.
+var legacyRoutePrefixes = []struct {
+	legacy    string
+	successor string
+}{
+	{"/api/settings/repos", "/api/settings/projects"},
+	{"/api/repos", "/api/projects"},
+	{"/settings/repos", "/settings/projects"},
+	{"/repos", "/projects"},
+}
+
+// legacyRouteSuccessor returns the /projects path for a legacy /repos path.
+func legacyRouteSuccessor(path string) (string, bool) {
+	for _, p := range legacyRoutePrefixes {
+		if path == p.legacy || strings.HasPrefix(path, p.legacy+"/") {
+			return p.successor + path[len(p.legacy):], true
+		}
+	}
+	return "", false
+}
+
+// legacyRoutesMiddleware serves the deprecated /repos paths by rewriting them
+// onto the /projects routes before routing, so both families share handlers,
+// auth and rate limits. Responses carry Deprecation and successor Link
+// headers. Disable with api.legacy_repo_routes: false.
+func (s *Server) legacyRoutesMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		successor, ok := legacyRouteSuccessor(r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Deprecation", "true")
+		w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = successor
+		r2.URL.RawPath, _ = legacyRouteSuccessor(r.URL.RawPath)
+		r2.RequestURI = r2.URL.RequestURI()
+		next.ServeHTTP(w, r2)
+	})
+}
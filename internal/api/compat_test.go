@@ -0,0 +1,81 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+func TestLegacyRepoRoutes(t *testing.T) {
+	_, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, nil)
+	defer cleanup()
+
+	resp, err := http.Get(ts.URL + "/api/repos/project/stacks")
+	if err != nil {
+		t.Fatalf("legacy request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 from legacy route, got %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Deprecation") != "true" {
+		t.Fatalf("expected Deprecation header")
+	}
+	if link := resp.Header.Get("Link"); link != `</api/projects/project/stacks>; rel="successor-version"` {
+		t.Fatalf("unexpected Link header: %q", link)
+	}
+
+	resp, err = http.Post(ts.URL+"/api/repos/project/scan", "application/json", bytes.NewBufferString(`{}`))
+	if err != nil {
+		t.Fatalf("legacy scan: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 from legacy scan route, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/api/projects/project/stacks")
+	if err != nil {
+		t.Fatalf("project request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Deprecation") != "" {
+		t.Fatalf("expected no Deprecation header on current routes")
+	}
+}
+
+func TestLegacyRepoRoutesDisabled(t *testing.T) {
+	disabled := false
+	_, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, func(cfg *config.Config) {
+		cfg.API.LegacyRepoRoutes = &disabled
+	})
+	defer cleanup()
+
+	resp, err := http.Get(ts.URL + "/api/repos/project/stacks")
+	if err != nil {
+		t.Fatalf("legacy request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 with legacy routes disabled, got %d", resp.StatusCode)
+	}
+}
+
+func TestLegacyRouteSuccessor(t *testing.T) {
+	tests := map[string]string{
+		"/api/settings/repos/foo": "/api/settings/projects/foo",
+		"/api/repos/foo/scan":     "/api/projects/foo/scan",
+		"/repos/foo":              "/projects/foo",
+		"/settings/repos":         "/settings/projects",
+	}
+	for in, want := range tests {
+		if got, ok := legacyRouteSuccessor(in); !ok || got != want {
+			t.Fatalf("legacyRouteSuccessor(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+	if _, ok := legacyRouteSuccessor("/repository/foo"); ok {
+		t.Fatalf("expected no match for unrelated prefix")
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/severity"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+// consoleLink is a resolved console link.
+type consoleLink struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// resourceConsoleLinks are the links of one drifted resource.
+type resourceConsoleLinks struct {
+	Address string        `json:"address"`
+	Action  string        `json:"action"`
+	Links   []consoleLink `json:"links"`
+}
+
+// stackConsoleLinks resolves the project's console links for one result.
+// Stack links come first; resource links are listed per drifted resource
+// that has at least one. Links with unresolved placeholders are left out.
+func stackConsoleLinks(projectCfg *config.ProjectConfig, stackPath string, result *storage.RunResult) ([]consoleLink, []resourceConsoleLinks) {
+	if projectCfg == nil || len(projectCfg.ConsoleLinks) == 0 || result == nil {
+		return nil, nil
+	}
+	values := map[string]string{"project": projectCfg.Name, "stack": stackPath}
+	for key, value := range result.Tags {
+		values["tag."+key] = value
+	}
+	for name, value := range result.LinkVars {
+		values["var."+name] = value
+	}
+
+	var stackLinks []consoleLink
+	for _, link := range projectCfg.ConsoleLinks {
+		if link.PerResource() {
+			continue
+		}
+		if url, ok := link.Expand(values); ok {
+			stackLinks = append(stackLinks, consoleLink{Name: link.Name, URL: url})
+		}
+	}
+
+	var resources []resourceConsoleLinks
+	if !result.Drifted {
+		return stackLinks, nil
+	}
+	for _, rc := range result.ResourceChanges {
+		if rc.Action == "read" {
+			continue
+		}
+		resourceType := severity.ResourceType(rc.Address)
+		values["address"] = rc.Address
+		values["type"] = resourceType
+		values["name"] = resourceName(rc.Address)
+		entry := resourceConsoleLinks{Address: rc.Address, Action: rc.Action}
+		for _, link := range projectCfg.ConsoleLinks {
+			if !link.PerResource() || !link.MatchesResourceType(resourceType) {
+				continue
+			}
+			if url, ok := link.Expand(values); ok {
+				entry.Links = append(entry.Links, consoleLink{Name: link.Name, URL: url})
+			}
+		}
+		if len(entry.Links) > 0 {
+			resources = append(resources, entry)
+		}
+	}
+	return stackLinks, resources
+}
+
+// resourceName returns the name label of a resource address, without any
+// count or for_each index: "module.db.aws_db_instance.main[0]" gives "main".
+func resourceName(address string) string {
+	if i := strings.Index(address, "["); i >= 0 {
+		address = address[:i]
+	}
+	return address[strings.LastIndex(address, ".")+1:]
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestStackPlanConsoleLinks(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, func(cfg *config.Config) {
+		cfg.Projects[0].ConsoleLinks = []config.ConsoleLink{
+			{Name: "Account", URL: "https://{var.region}.console.aws.amazon.com/console/home?account={tag.account}"},
+			{Name: "Security group", URL: "https://{var.region}.console.aws.amazon.com/ec2/home#SecurityGroups:search={name}", ResourceTypes: []string{"aws_security_group"}},
+			{Name: "Billing", URL: "https://example.com/{var.billing_id}"},
+		}
+	})
+	defer cleanup()
+
+	result := &storage.RunResult{
+		RunAt:    time.Now(),
+		Drifted:  true,
+		Tags:     map[string]string{"account": "123456789012"},
+		LinkVars: map[string]string{"region": "eu-west-1"},
+		ResourceChanges: []storage.ResourceChange{
+			{Address: `module.net.aws_security_group.web["a"]`, Action: "update"},
+			{Address: "aws_instance.app", Action: "update"},
+			{Address: "data.aws_security_group.default", Action: "read"},
+		},
+	}
+	if err := srv.storage.SaveResult("project", "envs/prod", result); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/projects/project/stacks/envs/prod/plan")
+	if err != nil {
+		t.Fatalf("stack plan: %v", err)
+	}
+	defer resp.Body.Close()
+	var plan apiStackPlan
+	if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+		t.Fatalf("decode plan: %v", err)
+	}
+
+	// Billing is hidden: the stack sets no billing_id.
+	if len(plan.ConsoleLinks) != 1 || plan.ConsoleLinks[0].URL != "https://eu-west-1.console.aws.amazon.com/console/home?account=123456789012" {
+		t.Fatalf("unexpected stack links %+v", plan.ConsoleLinks)
+	}
+	if len(plan.ResourceConsoleLinks) != 1 {
+		t.Fatalf("expected links for the security group only, got %+v", plan.ResourceConsoleLinks)
+	}
+	sg := plan.ResourceConsoleLinks[0]
+	if sg.Address != `module.net.aws_security_group.web["a"]` || sg.Links[0].URL != "https://eu-west-1.console.aws.amazon.com/ec2/home#SecurityGroups:search=web" {
+		t.Fatalf("unexpected resource links %+v", sg)
+	}
+}
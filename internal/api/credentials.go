@@ -0,0 +1,213 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/gitauth"
+	"github.com/driftdhq/driftd/internal/secrets"
+	"github.com/go-chi/chi/v5"
+)
+
+// minTokenLenForLast4 keeps token_last4 off short tokens, where four
+// characters would give away too much of the secret.
+const minTokenLenForLast4 = 12
+
+// CredentialPresence reports which credentials a project or integration has
+// without returning them.
+type CredentialPresence struct {
+	HasSSHKey       bool `json:"has_ssh_key"`
+	HasHTTPSToken   bool `json:"has_https_token"`
+	HasGitHubAppKey bool `json:"has_github_app_key"`
+	// KeyFingerprint is the SHA256 fingerprint of the SSH or GitHub App
+	// key's public half.
+	KeyFingerprint string `json:"key_fingerprint,omitempty"`
+	TokenLast4     string `json:"token_last4,omitempty"`
+	// HasWebhookSecret is set for projects whose webhooks verify with their
+	// own secret instead of the global one.
+	HasWebhookSecret bool `json:"has_webhook_secret,omitempty"`
+}
+
+type credentialVerifyRequest struct {
+	Credential string `json:"credential"`
+}
+
+type credentialVerifyResponse struct {
+	// Kind is the stored credential compared against: "ssh_key",
+	// "https_token" or "github_app_key".
+	Kind  string `json:"kind"`
+	Match bool   `json:"match"`
+}
+
+func credentialPresence(creds gitauth.Credentials) CredentialPresence {
+	presence := CredentialPresence{
+		HasSSHKey:       creds.SSHKey != "",
+		HasHTTPSToken:   creds.HTTPSToken != "",
+		HasGitHubAppKey: creds.GitHubAppKey != "",
+	}
+	switch {
+	case creds.SSHKey != "":
+		presence.KeyFingerprint = gitauth.KeyFingerprint(creds.SSHKey)
+	case creds.GitHubAppKey != "":
+		presence.KeyFingerprint = gitauth.KeyFingerprint(creds.GitHubAppKey)
+	}
+	if len(creds.HTTPSToken) >= minTokenLenForLast4 {
+		presence.TokenLast4 = creds.HTTPSToken[len(creds.HTTPSToken)-4:]
+	}
+	return presence
+}
+
+// projectCredentials resolves the credentials a project clones with. Config
+// projects win over dynamic projects of the same name, as in the settings
+// list.
+func (s *Server) projectCredentials(name string) (gitauth.Credentials, error) {
+	if project := s.cfg.GetProject(name); project != nil {
+		return gitauth.ResolveCredentials(project.Git), nil
+	}
+	if s.projectStore == nil {
+		return gitauth.Credentials{}, secrets.ErrProjectNotFound
+	}
+	entry, creds, err := s.projectStore.GetWithCredentials(name)
+	if err != nil {
+		return gitauth.Credentials{}, err
+	}
+	if entry.IntegrationID != "" {
+		return s.integrationCredentials(entry.IntegrationID)
+	}
+	var resolved gitauth.Credentials
+	switch entry.Git.Type {
+	case "ssh":
+		resolved.SSHKey = strings.TrimSpace(creds.SSHPrivateKey)
+	case "https":
+		resolved.HTTPSToken = strings.TrimSpace(creds.HTTPSToken)
+	case "github_app":
+		resolved.GitHubAppKey = strings.TrimSpace(creds.GitHubAppPrivateKey)
+	}
+	return resolved, nil
+}
+
+func (s *Server) integrationCredentials(id string) (gitauth.Credentials, error) {
+	if s.intStore == nil {
+		return gitauth.Credentials{}, secrets.ErrIntegrationNotFound
+	}
+	entry, err := s.intStore.Get(id)
+	if err != nil {
+		return gitauth.Credentials{}, err
+	}
+	return integrationEntryCredentials(entry), nil
+}
+
+func integrationEntryCredentials(entry *secrets.IntegrationEntry) gitauth.Credentials {
+	gitCfg, err := secrets.GitConfigFromIntegration(entry)
+	if err != nil {
+		return gitauth.Credentials{}
+	}
+	return gitauth.ResolveCredentials(gitCfg)
+}
+
+// projectCredentialPresence is credentialPresence for settings responses;
+// credentials that cannot be read show as absent.
+func (s *Server) projectCredentialPresence(name string) CredentialPresence {
+	creds, err := s.projectCredentials(name)
+	if err != nil {
+		return CredentialPresence{}
+	}
+	presence := credentialPresence(creds)
+	_, presence.HasWebhookSecret = s.projectWebhookSecrets(name)
+	return presence
+}
+
+// matchCredential compares a typed-in credential with the stored one. Keys
+// match on their public key, so a key pasted with different line endings or
+// in another encoding still matches. ok is false when nothing is stored.
+func matchCredential(stored gitauth.Credentials, typed string) (kind string, match, ok bool) {
+	typed = strings.TrimSpace(typed)
+	switch {
+	case stored.SSHKey != "":
+		return "ssh_key", keysMatch(stored.SSHKey, typed), true
+	case stored.GitHubAppKey != "":
+		return "github_app_key", keysMatch(stored.GitHubAppKey, typed), true
+	case stored.HTTPSToken != "":
+		return "https_token", secretsEqual(stored.HTTPSToken, typed), true
+	}
+	return "", false, false
+}
+
+func keysMatch(stored, typed string) bool {
+	want, got := gitauth.KeyFingerprint(stored), gitauth.KeyFingerprint(typed)
+	if want != "" && got != "" {
+		return want == got
+	}
+	return secretsEqual(stored, typed)
+}
+
+// secretsEqual compares digests so neither the contents nor the length of
+// the stored secret leak through timing.
+func secretsEqual(a, b string) bool {
+	da, db := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(da[:], db[:]) == 1
+}
+
+// handleVerifyProjectCredential reports whether a typed-in credential is
+// the one the project clones with, so the settings UI can confirm a secret
+// without ever displaying it.
+func (s *Server) handleVerifyProjectCredential(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid project name"})
+		return
+	}
+	creds, err := s.projectCredentials(projectName)
+	if err != nil {
+		if errors.Is(err, secrets.ErrProjectNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "project not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	s.writeCredentialMatch(w, r, creds)
+}
+
+// handleVerifyIntegrationCredential is handleVerifyProjectCredential for an
+// integration.
+func (s *Server) handleVerifyIntegrationCredential(w http.ResponseWriter, r *http.Request) {
+	if s.intStore == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error": "dynamic integration management not enabled",
+		})
+		return
+	}
+	creds, err := s.integrationCredentials(chi.URLParam(r, "integration"))
+	if err != nil {
+		if errors.Is(err, secrets.ErrIntegrationNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "integration not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	s.writeCredentialMatch(w, r, creds)
+}
+
+func (s *Server) writeCredentialMatch(w http.ResponseWriter, r *http.Request, stored gitauth.Credentials) {
+	var req credentialVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	if strings.TrimSpace(req.Credential) == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "credential is required"})
+		return
+	}
+	kind, match, ok := matchCredential(stored, req.Credential)
+	if !ok {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "no credential is stored"})
+		return
+	}
+	writeJSON(w, http.StatusOK, credentialVerifyResponse{Kind: kind, Match: match})
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// While the queue backend (Redis) is unreachable the server runs read-only:
+// pages and read endpoints serve the last results from storage with a stale
+// data banner and header, endpoints that only exist in the queue and every
+// write return 503, and a background probe restores full function once the
+// backend answers again.
+const (
+	queueProbeInterval = 5 * time.Second
+	queueProbeTimeout  = 2 * time.Second
+
+	// degradedHeader marks responses served while read-only.
+	degradedHeader = "X-Driftd-Degraded"
+)
+
+// queueHealth is the probe's view of the queue backend.
+type queueHealth struct {
+	mu      sync.Mutex
+	down    bool
+	since   time.Time
+	lastErr string
+}
+
+// degradedStatus is shown on pages while the server is read-only.
+type degradedStatus struct {
+	Degraded bool
+	Since    time.Time
+}
+
+func (s *Server) startQueueProbe() {
+	s.bgWG.Add(1)
+	go func() {
+		defer s.bgWG.Done()
+		ticker := time.NewTicker(queueProbeInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.bgCtx.Done():
+				return
+			case <-ticker.C:
+			}
+			s.checkQueue(s.bgCtx)
+		}
+	}()
+}
+
+// checkQueue pings the queue backend and updates the degraded state.
+func (s *Server) checkQueue(ctx context.Context) error {
+	pingCtx, cancel := context.WithTimeout(ctx, queueProbeTimeout)
+	defer cancel()
+	err := s.queue.Ping(pingCtx)
+	if ctx.Err() != nil {
+		// The caller went away; the result says nothing about the backend.
+		return err
+	}
+	s.setQueueError(err)
+	return err
+}
+
+// setQueueError records a probe result, logging when the state changes.
+func (s *Server) setQueueError(err error) {
+	h := &s.queueHealth
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if err != nil {
+		h.lastErr = err.Error()
+		if !h.down {
+			h.down = true
+			h.since = time.Now()
+			log.Printf("Queue backend unreachable, serving read-only until it recovers: %v", err)
+		}
+		return
+	}
+	if h.down {
+		log.Printf("Queue backend reachable again after %s; leaving read-only mode", time.Since(h.since).Round(time.Second))
+	}
+	h.down = false
+	h.since = time.Time{}
+	h.lastErr = ""
+}
+
+func (s *Server) degradedStatus() degradedStatus {
+	h := &s.queueHealth
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return degradedStatus{Degraded: h.down, Since: h.since}
+}
+
+// degradedMiddleware marks responses as stale while the queue backend is
+// unreachable and rejects writes. Login and logout keep working, since
+// sessions do not live in the queue.
+func (s *Server) degradedMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.degradedStatus().Degraded {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set(degradedHeader, "read-only")
+		w.Header().Set("Warning", `110 driftd "Response is stale"`)
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if r.URL.Path != "/login" && r.URL.Path != "/logout" {
+				s.rejectWhileDegraded(w)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireQueueMiddleware guards reads that have no stored fallback, such as
+// scan progress and worker lists.
+func (s *Server) requireQueueMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.degradedStatus().Degraded {
+			s.rejectWhileDegraded(w)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) rejectWhileDegraded(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(queueProbeInterval/time.Second)))
+	writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+		"error": "driftd is read-only: the queue backend is unreachable, so scans and changes are unavailable until it recovers",
+	})
+}
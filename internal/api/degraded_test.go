@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestDegradedReadOnlyMode(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, nil)
+	defer cleanup()
+
+	if err := srv.storage.SaveResult("project", "envs/prod", &storage.RunResult{RunAt: time.Now(), Drifted: true, Changed: 1}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+	srv.setQueueError(errors.New("dial tcp 10.0.0.1:6379: connect: connection refused"))
+
+	do := func(method, path string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := do(http.MethodGet, "/api/projects/project/stacks/envs/prod/plan")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get(degradedHeader) != "read-only" {
+		t.Fatalf("expected stored result marked stale, got %d %q", resp.StatusCode, resp.Header.Get(degradedHeader))
+	}
+	if resp := do(http.MethodGet, "/api/scans/project:1"); resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected queue-only read to return 503, got %d", resp.StatusCode)
+	}
+	resp = do(http.MethodPost, "/api/projects/project/scan")
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") == "" {
+		t.Fatalf("expected scan to return 503 with Retry-After, got %d", resp.StatusCode)
+	}
+
+	// The next successful probe leaves read-only mode.
+	if err := srv.checkQueue(context.Background()); err != nil {
+		t.Fatalf("probe: %v", err)
+	}
+	if srv.degradedStatus().Degraded {
+		t.Fatal("expected a successful probe to clear degraded mode")
+	}
+	resp = do(http.MethodGet, "/api/scans/project:1")
+	if resp.StatusCode != http.StatusNotFound || resp.Header.Get(degradedHeader) != "" {
+		t.Fatalf("expected normal handling after recovery, got %d %q", resp.StatusCode, resp.Header.Get(degradedHeader))
+	}
+}
+
+// unreachableQueue stands in for a backend that is down: the probe fails
+// and the lookups pages make fail the test, since against a real outage
+// each would wait for its timeout.
+type unreachableQueue struct {
+	queue.Backend
+	t *testing.T
+}
+
+var errUnreachable = errors.New("dial tcp 10.0.0.1:6379: connect: connection refused")
+
+func (q *unreachableQueue) lookup(name string) error {
+	q.t.Errorf("page called %s while read-only", name)
+	return errUnreachable
+}
+
+func (q *unreachableQueue) Ping(ctx context.Context) error { return errUnreachable }
+
+func (q *unreachableQueue) IsProjectLocked(ctx context.Context, projectName string) (bool, error) {
+	return false, q.lookup("IsProjectLocked")
+}
+
+func (q *unreachableQueue) GetActiveScan(ctx context.Context, projectName string) (*queue.Scan, error) {
+	return nil, q.lookup("GetActiveScan")
+}
+
+func (q *unreachableQueue) GetLastScan(ctx context.Context, projectName string) (*queue.Scan, error) {
+	return nil, q.lookup("GetLastScan")
+}
+
+func (q *unreachableQueue) GetScan(ctx context.Context, scanID string) (*queue.Scan, error) {
+	return nil, q.lookup("GetScan")
+}
+
+func (q *unreachableQueue) GetProjectPause(ctx context.Context, projectName string) (*queue.ProjectPause, error) {
+	return nil, q.lookup("GetProjectPause")
+}
+
+func (q *unreachableQueue) ListRunningStackScans(ctx context.Context) ([]*queue.StackScan, error) {
+	return nil, q.lookup("ListRunningStackScans")
+}
+
+func (q *unreachableQueue) ListProjectScans(ctx context.Context, projectName string, limit int) ([]*queue.Scan, error) {
+	return nil, q.lookup("ListProjectScans")
+}
+
+func TestDegradedPagesSkipQueueLookups(t *testing.T) {
+	cfg := &config.Config{
+		DataDir:  t.TempDir(),
+		Worker:   config.WorkerConfig{LockTTL: time.Minute},
+		Projects: []config.ProjectConfig{{Name: "project", URL: "file:///nonexistent"}},
+	}
+	store := storage.New(cfg.DataDir)
+	if err := store.SaveResult("project", "envs/prod", &storage.RunResult{RunAt: time.Now(), Drifted: true, Changed: 1}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+	mem := queue.NewMemory(cfg.Worker.LockTTL)
+	defer mem.Close()
+	srv, err := New(cfg, store, &unreachableQueue{Backend: mem, t: t}, os.DirFS("testdata"), os.DirFS("testdata"))
+	if err != nil {
+		t.Fatalf("server: %v", err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+	srv.setQueueError(errUnreachable)
+
+	for _, path := range []string{
+		"/",
+		"/projects/project",
+		"/projects/project/stacks/envs/prod",
+		"/projects/project/pipeline",
+		"/activity",
+		"/fragments/projects/project/card",
+		"/fragments/projects/project/progress",
+	} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || resp.Header.Get(degradedHeader) != "read-only" {
+			t.Errorf("GET %s: expected stale page, got %d %q", path, resp.StatusCode, resp.Header.Get(degradedHeader))
+		}
+	}
+}
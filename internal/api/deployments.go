@@ -0,0 +1,216 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/orchestrate"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/secrets"
+	"github.com/driftdhq/driftd/internal/vcs"
+)
+
+const deploymentTrigger = "deployment"
+
+// deploymentVerifyTimeout bounds how long a verification scan is waited on
+// before an error status is reported instead.
+const deploymentVerifyTimeout = time.Hour
+
+// deploymentPollEvery is how often the verification scan is checked.
+var deploymentPollEvery = 5 * time.Second
+
+// githubWebhookEvents lists the events auto-registered GitHub hooks send.
+func (s *Server) githubWebhookEvents() []string {
+	if s.cfg.Webhook.Deployments {
+		return []string{"push", "deployment_status"}
+	}
+	return []string{"push"}
+}
+
+// serveGitHubDeployment starts a verification scan of the stacks mapped to
+// a successfully deployed environment. The result is posted back to the
+// deployment once the scan ends.
+func (s *Server) serveGitHubDeployment(w http.ResponseWriter, r *http.Request, body []byte) {
+	if !s.cfg.Webhook.Deployments {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	dep, err := vcs.ParseGitHubDeploymentStatus(r, body)
+	if err != nil {
+		if errors.Is(err, vcs.ErrIgnoredEvent) {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	candidates, err := s.getReposByURL(dep.RepoURLs...)
+	if err != nil {
+		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if len(candidates) == 0 && isValidProjectName(dep.RepoName) {
+		projectCfg, lookupErr := s.getProjectConfig(dep.RepoName)
+		if lookupErr == nil && projectCfg != nil {
+			candidates = append(candidates, projectCfg)
+		} else if lookupErr != nil && lookupErr != secrets.ErrProjectNotFound {
+			http.Error(w, s.sanitizeErrorMessage(lookupErr.Error()), http.StatusInternalServerError)
+			return
+		}
+	}
+	if len(candidates) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(scanResponse{Error: "Project not configured"})
+		return
+	}
+
+	var (
+		apiScans []*apiScan
+		stackIDs []string
+		limitErr error
+	)
+	for _, projectCfg := range candidates {
+		scan, discovered, err := s.startScanWithCancel(r.Context(), projectCfg, deploymentTrigger, dep.SHA, dep.Actor)
+		if err != nil {
+			if err == queue.ErrProjectLocked {
+				continue
+			}
+			if scanLimitError(err) != nil {
+				limitErr = err
+				continue
+			}
+			http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+			return
+		}
+		targets := s.environmentStacks(discovered, dep.Environment)
+		if len(targets) == 0 {
+			_ = s.queue.FailScan(r.Context(), scan.ID, projectCfg.Name, fmt.Sprintf("no stacks mapped to environment %q", dep.Environment))
+			continue
+		}
+		enqResult, err := s.orchestrator.EnqueueStacks(r.Context(), scan, projectCfg, targets, deploymentTrigger, dep.SHA, dep.Actor)
+		if err != nil && err != orchestrate.ErrNoStacksEnqueued {
+			http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+			return
+		}
+
+		apiScans = append(apiScans, toAPIScan(scan))
+		if enqResult != nil {
+			stackIDs = append(stackIDs, enqResult.StackIDs...)
+		}
+		s.bgWG.Add(1)
+		go func(projectCfg *config.ProjectConfig, scanID string) {
+			defer s.bgWG.Done()
+			s.reportDeploymentVerification(projectCfg, scanID, dep)
+		}(projectCfg, scan.ID)
+	}
+
+	if len(apiScans) == 0 && writeScanLimited(w, limitErr) {
+		return
+	}
+	if len(apiScans) == 0 {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	resp := scanResponse{
+		Stacks:  stackIDs,
+		Scans:   apiScans,
+		Message: fmt.Sprintf("Enqueued %d stacks for environment %s", len(stackIDs), dep.Environment),
+	}
+	if len(apiScans) == 1 {
+		resp.Scan = apiScans[0]
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// environmentStacks keeps the stacks mapped to env, in discovery order.
+func (s *Server) environmentStacks(stacks []string, env string) []string {
+	var out []string
+	for _, stackPath := range stacks {
+		if s.cfg.StackEnvironment(stackPath) == env {
+			out = append(out, stackPath)
+		}
+	}
+	return out
+}
+
+// reportDeploymentVerification waits for the verification scan and posts
+// its outcome as a deployment status. Only projects that authenticate with
+// a GitHub App can report; for others the scan still runs.
+func (s *Server) reportDeploymentVerification(projectCfg *config.ProjectConfig, scanID string, dep *vcs.DeploymentEvent) {
+	ctx, cancel := context.WithTimeout(s.bgCtx, deploymentVerifyTimeout)
+	defer cancel()
+	scan, waitErr := s.waitForScan(ctx, scanID)
+	if s.bgCtx.Err() != nil {
+		return
+	}
+	status := deploymentStatusFor(scan, waitErr, dep.Environment)
+	if base := s.cfg.Webhook.PublicURL; base != "" {
+		status.LogURL = base + "/projects/" + url.PathEscape(projectCfg.Name) + "?env=" + url.QueryEscape(dep.Environment)
+	}
+
+	postCtx, postCancel := context.WithTimeout(s.bgCtx, webhookRegisterTimeout)
+	defer postCancel()
+	// The deployment belongs to the repository that sent it, which is the
+	// project's unless the project clones from a mirror.
+	repoURL := projectCfg.EffectiveCloneURL()
+	if len(dep.RepoURLs) > 0 {
+		repoURL = dep.RepoURLs[0]
+	}
+	// Deployment events only arrive on the GitHub webhook.
+	provider := vcs.ForURL(repoURL)
+	if provider == nil {
+		provider = vcs.ByName("github")
+	}
+	err := provider.PublishStatus(postCtx, projectCfg.Git, repoURL, dep.ID, status)
+	switch {
+	case errors.Is(err, vcs.ErrUnsupported):
+		log.Printf("deployment %d of project %s: %s (not reported, project does not use a GitHub App)", dep.ID, projectCfg.Name, status.Description)
+	case err != nil:
+		log.Printf("deployment %d of project %s: report status: %v", dep.ID, projectCfg.Name, err)
+	}
+}
+
+// waitForScan polls until the scan leaves the running state or ctx ends.
+func (s *Server) waitForScan(ctx context.Context, scanID string) (*queue.Scan, error) {
+	ticker := time.NewTicker(deploymentPollEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+		scan, err := s.queue.GetScan(ctx, scanID)
+		if err != nil || scan.Status == queue.ScanStatusRunning {
+			continue
+		}
+		return scan, nil
+	}
+}
+
+// deploymentStatusFor maps a finished verification scan to a deployment
+// status: success when no stack drifted, failure when any did, and error
+// when the scan could not verify every stack.
+func deploymentStatusFor(scan *queue.Scan, waitErr error, env string) vcs.DeploymentStatus {
+	prefix := vcs.DeploymentStatusPrefix + ": "
+	switch {
+	case waitErr != nil:
+		return vcs.DeploymentStatus{State: vcs.DeploymentStateError, Description: prefix + "scan did not finish in time"}
+	case scan.Status != queue.ScanStatusCompleted:
+		return vcs.DeploymentStatus{State: vcs.DeploymentStateError, Description: fmt.Sprintf("%sscan %s", prefix, scan.Status)}
+	case scan.Drifted > 0:
+		return vcs.DeploymentStatus{State: vcs.DeploymentStateFailure, Description: fmt.Sprintf("%s%d of %d %s stacks drifted", prefix, scan.Drifted, scan.Total, env)}
+	case scan.Failed > 0 || scan.Errored > 0:
+		return vcs.DeploymentStatus{State: vcs.DeploymentStateError, Description: fmt.Sprintf("%s%d of %d %s stacks could not be planned", prefix, scan.Failed+scan.Errored, scan.Total, env)}
+	default:
+		return vcs.DeploymentStatus{State: vcs.DeploymentStateSuccess, Description: fmt.Sprintf("%sno drift in %d %s stacks", prefix, scan.Total, env)}
+	}
+}
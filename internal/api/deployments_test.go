@@ -0,0 +1,171 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/vcs"
+)
+
+func deploymentStatusBody(state, description string) []byte {
+	body, _ := json.Marshal(map[string]any{
+		"deployment_status": map[string]any{"state": state, "description": description, "environment": "prod"},
+		"deployment":        map[string]any{"id": 99, "sha": "abc123", "ref": "main", "environment": "prod", "creator": map[string]any{"login": "deployer"}},
+		"repository":        map[string]any{"name": "project", "html_url": "https://github.com/acme/infra"},
+	})
+	return body
+}
+
+func postDeploymentStatus(t *testing.T, url string, body []byte) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodPost, url+"/api/webhooks/github", bytes.NewReader(body))
+	req.Header.Set("X-GitHub-Event", "deployment_status")
+	req.Header.Set("X-Hub-Signature-256", "sha256="+computeTestHMAC(body, "secret"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("post webhook: %v", err)
+	}
+	return resp
+}
+
+func TestDeploymentStatusWebhookVerifiesEnvironment(t *testing.T) {
+	prevPoll := deploymentPollEvery
+	deploymentPollEvery = 10 * time.Millisecond
+	defer func() { deploymentPollEvery = prevPoll }()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "app.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	var mu sync.Mutex
+	var statuses []vcs.DeploymentStatus
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/app/installations/4242/access_tokens":
+			json.NewEncoder(w).Encode(map[string]string{"token": "inst-token"})
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/acme/infra/deployments/99/statuses":
+			var status vcs.DeploymentStatus
+			json.NewDecoder(r.Body).Decode(&status)
+			statuses = append(statuses, status)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer github.Close()
+
+	runner := &fakeRunner{drifted: map[string]bool{"envs/prod": true}}
+	srv, ts, q, cleanup := newTestServerWithConfig(t, runner, []string{"envs/prod", "envs/dev"}, true, nil, true, func(cfg *config.Config) {
+		cfg.Webhook.Enabled = true
+		cfg.Webhook.GitHubSecret = "secret"
+		cfg.Webhook.Deployments = true
+		cfg.Webhook.PublicURL = "https://driftd.example.com"
+		cfg.Environments = []config.EnvironmentMapping{{Pattern: "envs/<env>"}}
+		cfg.Projects[0].Git = &config.GitAuthConfig{
+			Type: "github_app",
+			GitHubApp: &config.GitHubAppConfig{
+				AppID:          4141,
+				InstallationID: 4242,
+				PrivateKeyPath: keyPath,
+				APIBaseURL:     github.URL,
+			},
+		}
+	})
+	defer cleanup()
+	defer srv.Stop()
+
+	// Statuses driftd posted itself and non-success states are ignored.
+	for _, body := range [][]byte{
+		deploymentStatusBody("success", vcs.DeploymentStatusPrefix+": no drift in 1 prod stacks"),
+		deploymentStatusBody("failure", "deploy failed"),
+	} {
+		resp := postDeploymentStatus(t, ts.URL, body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("expected 202 for ignored status, got %d", resp.StatusCode)
+		}
+	}
+
+	resp := postDeploymentStatus(t, ts.URL, deploymentStatusBody("success", "Deployed"))
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var got scanResp
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Scan == nil || got.Scan.Trigger != deploymentTrigger || len(got.Stacks) != 1 {
+		t.Fatalf("expected one prod stack in a deployment scan, got %+v", got)
+	}
+	scan, err := q.GetScan(t.Context(), got.Scan.ID)
+	if err != nil || scan.Total != 1 || scan.Actor != "deployer" {
+		t.Fatalf("unexpected scan %+v (%v)", scan, err)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		mu.Lock()
+		n := len(statuses)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("deployment status was not reported")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	status := statuses[0]
+	if status.State != vcs.DeploymentStateFailure || !strings.Contains(status.Description, "1 of 1 prod stacks drifted") {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	if status.LogURL != "https://driftd.example.com/projects/project?env=prod" {
+		t.Fatalf("unexpected log url %q", status.LogURL)
+	}
+}
+
+func TestDeploymentStatusFor(t *testing.T) {
+	cases := []struct {
+		scan  *queue.Scan
+		state string
+	}{
+		{&queue.Scan{Status: queue.ScanStatusCompleted, Total: 2}, vcs.DeploymentStateSuccess},
+		{&queue.Scan{Status: queue.ScanStatusCompleted, Total: 2, Drifted: 1, Errored: 1}, vcs.DeploymentStateFailure},
+		{&queue.Scan{Status: queue.ScanStatusCompleted, Total: 2, Errored: 1}, vcs.DeploymentStateError},
+		{&queue.Scan{Status: queue.ScanStatusCanceled}, vcs.DeploymentStateError},
+	}
+	for _, tc := range cases {
+		if got := deploymentStatusFor(tc.scan, nil, "prod"); got.State != tc.state || !strings.HasPrefix(got.Description, vcs.DeploymentStatusPrefix) {
+			t.Fatalf("scan %+v: expected %s, got %+v", tc.scan, tc.state, got)
+		}
+	}
+	if got := deploymentStatusFor(nil, context.DeadlineExceeded, "prod"); got.State != vcs.DeploymentStateError {
+		t.Fatalf("expected error state on timeout, got %+v", got)
+	}
+}
@@ -0,0 +1,204 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/go-chi/chi/v5"
+)
+
+// diagnosticLogLines is how much of the end of a failed run's output the
+// diagnostics bundle includes.
+const diagnosticLogLines = 200
+
+// apiStackScanDiagnostics is what support needs to look at a failed stack
+// scan without access to the worker. Env holds variable names only.
+type apiStackScanDiagnostics struct {
+	StackScanID       string   `json:"stack_scan_id"`
+	ScanID            string   `json:"scan_id"`
+	ProjectName       string   `json:"project_name"`
+	StackPath         string   `json:"stack_path"`
+	Trigger           string   `json:"trigger,omitempty"`
+	WorkerID          string   `json:"worker_id,omitempty"`
+	Retries           int      `json:"retries"`
+	StartedAt         int64    `json:"started_at,omitempty"`
+	CompletedAt       int64    `json:"completed_at,omitempty"`
+	Error             string   `json:"error"`
+	CommitSHA         string   `json:"commit_sha,omitempty"`
+	TerraformVersion  string   `json:"terraform_version,omitempty"`
+	TerragruntVersion string   `json:"terragrunt_version,omitempty"`
+	BackendType       string   `json:"backend_type,omitempty"`
+	Env               []string `json:"env,omitempty"`
+	WorkspacePath     string   `json:"workspace_path,omitempty"`
+	WorkspaceExists   bool     `json:"workspace_exists"`
+	StackDirExists    bool     `json:"stack_dir_exists"`
+	// Log is the last diagnosticLogLines lines of the run's output, already
+	// redacted. LogTruncated is set when earlier lines were left out.
+	Log          []string `json:"log"`
+	LogTruncated bool     `json:"log_truncated,omitempty"`
+}
+
+// handleStackScanDiagnostics serves GET /api/stack-scans/{id}/diagnostics
+// for a failed stack scan, as JSON or, with ?format=text, as plain text to
+// paste into a support ticket. Stack scan IDs contain slashes, so the route
+// is a wildcard.
+func (s *Server) handleStackScanDiagnostics(w http.ResponseWriter, r *http.Request) {
+	rest := chi.URLParam(r, "*")
+	if !strings.HasSuffix(rest, "/diagnostics") {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	stackScan, err := s.queue.GetStackScan(r.Context(), strings.TrimSuffix(rest, "/diagnostics"))
+	if err != nil {
+		if errors.Is(err, queue.ErrStackScanNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "stack scan not found"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	if stackScan.Status != queue.StatusFailed {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "stack scan has not failed (status " + stackScan.Status + ")"})
+		return
+	}
+
+	diag := s.stackScanDiagnostics(r, stackScan)
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(diag.text()))
+		return
+	}
+	writeJSON(w, http.StatusOK, diag)
+}
+
+// stackScanDiagnostics gathers the bundle. Details the runner recorded come
+// from the stack's result for the scan; a scan that failed before planning,
+// e.g. on clone, has none and falls back to what the scan itself recorded.
+func (s *Server) stackScanDiagnostics(r *http.Request, stackScan *queue.StackScan) *apiStackScanDiagnostics {
+	diag := &apiStackScanDiagnostics{
+		StackScanID: stackScan.ID,
+		ScanID:      stackScan.ScanID,
+		ProjectName: stackScan.ProjectName,
+		StackPath:   stackScan.StackPath,
+		Trigger:     stackScan.Trigger,
+		WorkerID:    stackScan.WorkerID,
+		Retries:     stackScan.Retries,
+		Error:       stackScan.Error,
+		CommitSHA:   stackScan.Commit,
+		Log:         []string{},
+	}
+	if !stackScan.StartedAt.IsZero() {
+		diag.StartedAt = stackScan.StartedAt.Unix()
+	}
+	if !stackScan.CompletedAt.IsZero() {
+		diag.CompletedAt = stackScan.CompletedAt.Unix()
+	}
+
+	if scan, err := s.queue.GetScan(r.Context(), stackScan.ScanID); err == nil {
+		if scan.CommitSHA != "" {
+			diag.CommitSHA = scan.CommitSHA
+		}
+		diag.TerraformVersion = scan.TerraformVersion
+		if v, ok := scan.StackTFVersions[stackScan.StackPath]; ok {
+			diag.TerraformVersion = v
+		}
+		diag.TerragruntVersion = scan.TerragruntVersion
+		if v, ok := scan.StackTGVersions[stackScan.StackPath]; ok {
+			diag.TerragruntVersion = v
+		}
+		if scan.WorkspacePath != "" {
+			diag.WorkspacePath = scan.WorkspacePath
+			diag.WorkspaceExists = dirExists(scan.WorkspacePath)
+			diag.StackDirExists = dirExists(filepath.Join(scan.WorkspacePath, filepath.FromSlash(stackScan.StackPath)))
+		}
+	}
+
+	result, err := s.storage.GetScanResult(stackScan.ProjectName, stackScan.StackPath, stackScan.ScanID)
+	if err != nil {
+		return diag
+	}
+	if result.CommitSHA != "" {
+		diag.CommitSHA = result.CommitSHA
+	}
+	if result.TerraformVersion != "" {
+		diag.TerraformVersion = result.TerraformVersion
+	}
+	if result.TerragruntVersion != "" {
+		diag.TerragruntVersion = result.TerragruntVersion
+	}
+	diag.BackendType = result.BackendType
+	diag.Env = result.EnvNames
+	diag.Log, diag.LogTruncated = lastLines(ansiEscapePattern.ReplaceAllString(result.PlanOutput, ""), diagnosticLogLines)
+	return diag
+}
+
+// text renders the bundle for a support ticket.
+func (d *apiStackScanDiagnostics) text() string {
+	var b strings.Builder
+	field := func(name, value string) {
+		if value == "" {
+			value = "(unknown)"
+		}
+		fmt.Fprintf(&b, "%-20s %s\n", name+":", value)
+	}
+	b.WriteString("driftd stack scan diagnostics\n\n")
+	field("Stack scan", d.StackScanID)
+	field("Scan", d.ScanID)
+	field("Project", d.ProjectName)
+	field("Stack", d.StackPath)
+	field("Trigger", d.Trigger)
+	field("Worker", d.WorkerID)
+	field("Retries", fmt.Sprint(d.Retries))
+	if d.StartedAt > 0 {
+		field("Started", time.Unix(d.StartedAt, 0).UTC().Format(time.RFC3339))
+	}
+	if d.CompletedAt > 0 {
+		field("Failed", time.Unix(d.CompletedAt, 0).UTC().Format(time.RFC3339))
+	}
+	field("Error", d.Error)
+	field("Commit", d.CommitSHA)
+	field("Terraform", d.TerraformVersion)
+	field("Terragrunt", d.TerragruntVersion)
+	field("Backend", d.BackendType)
+	field("Workspace", d.WorkspacePath)
+	field("Workspace exists", fmt.Sprint(d.WorkspaceExists))
+	field("Stack dir exists", fmt.Sprint(d.StackDirExists))
+	field("Env (names only)", strings.Join(d.Env, ", "))
+	b.WriteString("\nLog")
+	if d.LogTruncated {
+		fmt.Fprintf(&b, " (last %d lines)", len(d.Log))
+	}
+	b.WriteString(":\n")
+	for _, line := range d.Log {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// lastLines returns the last n lines of output, without a trailing empty
+// line, and whether any were dropped.
+func lastLines(output string, n int) ([]string, bool) {
+	output = strings.TrimRight(output, "\n")
+	if output == "" {
+		return []string{}, false
+	}
+	lines := strings.Split(output, "\n")
+	if len(lines) <= n {
+		return lines, false
+	}
+	return lines[len(lines)-n:], true
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
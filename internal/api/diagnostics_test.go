@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestStackScanDiagnostics(t *testing.T) {
+	srv, ts, q, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, nil)
+	defer cleanup()
+
+	resp, err := http.Post(ts.URL+"/api/projects/project/scan", "application/json", bytes.NewBufferString(`{}`))
+	if err != nil {
+		t.Fatalf("scan request failed: %v", err)
+	}
+	var sr scanResp
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	resp.Body.Close()
+	if len(sr.Stacks) != 1 {
+		t.Fatalf("expected one stack id, got %v", sr.Stacks)
+	}
+	diagURL := ts.URL + "/api/stack-scans/" + sr.Stacks[0] + "/diagnostics"
+
+	resp, err = http.Get(diagURL)
+	if err != nil {
+		t.Fatalf("diagnostics: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 for a stack scan that has not failed, got %d", resp.StatusCode)
+	}
+
+	var log strings.Builder
+	for i := 1; i <= 250; i++ {
+		fmt.Fprintf(&log, "\x1b[31mline %d\x1b[0m\n", i)
+	}
+	if err := srv.storage.SaveResult("project", "envs/prod", &storage.RunResult{
+		RunAt:            time.Now(),
+		Error:            "plan failed with exit code 1",
+		PlanOutput:       log.String(),
+		ScanID:           sr.Scan.ID,
+		CommitSHA:        "abc123",
+		TerraformVersion: "1.6.2",
+		BackendType:      "s3",
+		EnvNames:         []string{"AWS_PROFILE", "TF_DATA_DIR"},
+	}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+	stackScan, err := q.GetStackScan(context.Background(), sr.Stacks[0])
+	if err != nil {
+		t.Fatalf("get stack scan: %v", err)
+	}
+	stackScan.MaxRetries = 0
+	if err := q.Fail(context.Background(), stackScan, "plan failed with exit code 1"); err != nil {
+		t.Fatalf("fail: %v", err)
+	}
+
+	resp, err = http.Get(diagURL)
+	if err != nil {
+		t.Fatalf("diagnostics: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var diag apiStackScanDiagnostics
+	if err := json.NewDecoder(resp.Body).Decode(&diag); err != nil {
+		t.Fatalf("decode diagnostics: %v", err)
+	}
+	if diag.Error != "plan failed with exit code 1" || diag.CommitSHA != "abc123" || diag.TerraformVersion != "1.6.2" || diag.BackendType != "s3" {
+		t.Fatalf("unexpected diagnostics: %+v", diag)
+	}
+	if len(diag.Env) != 2 || diag.Env[0] != "AWS_PROFILE" {
+		t.Fatalf("unexpected env names: %v", diag.Env)
+	}
+	if len(diag.Log) != diagnosticLogLines || !diag.LogTruncated || diag.Log[0] != "line 51" || diag.Log[len(diag.Log)-1] != "line 250" {
+		t.Fatalf("unexpected log tail: %d lines, first %q", len(diag.Log), diag.Log[0])
+	}
+
+	textResp, err := http.Get(diagURL + "?format=text")
+	if err != nil {
+		t.Fatalf("diagnostics text: %v", err)
+	}
+	defer textResp.Body.Close()
+	body, _ := io.ReadAll(textResp.Body)
+	if !strings.HasPrefix(textResp.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("expected text/plain, got %q", textResp.Header.Get("Content-Type"))
+	}
+	for _, want := range []string{"Backend:", "s3", "AWS_PROFILE, TF_DATA_DIR", "Log (last 200 lines):", "line 250"} {
+		if !strings.Contains(string(body), want) {
+			t.Fatalf("expected %q in text bundle:\n%s", want, body)
+		}
+	}
+
+	resp, err = http.Get(ts.URL + "/api/stack-scans/project:missing:1:2/diagnostics")
+	if err != nil {
+		t.Fatalf("diagnostics: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown stack scan, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/go-chi/chi/v5"
+)
+
+type driftChangesResponse struct {
+	ProjectName string              `json:"project_name"`
+	Since       time.Time           `json:"since"`
+	SinceScanID string              `json:"since_scan_id,omitempty"`
+	Changes     []queue.DriftChange `json:"changes"`
+}
+
+// handleDriftChanges returns stacks whose drift state changed after the
+// reference point given by ?since=, which may be a scan ID, an RFC3339
+// timestamp or Unix seconds.
+func (s *Server) handleDriftChanges(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Invalid project name"})
+		return
+	}
+	if _, err := s.getProjectConfig(projectName); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Project not found"})
+		return
+	}
+
+	since, scanID, err := s.resolveDriftSince(r.Context(), projectName, r.URL.Query().Get("since"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	changes, err := s.queue.DriftChangesSince(r.Context(), projectName, since, scanID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, driftChangesResponse{
+		ProjectName: projectName,
+		Since:       since.UTC(),
+		SinceScanID: scanID,
+		Changes:     changes,
+	})
+}
+
+// resolveDriftSince turns the since parameter into a point in time. A scan
+// reference resolves to the moment that scan finished, or started if it is
+// still running; its own changes are excluded by the caller.
+func (s *Server) resolveDriftSince(ctx context.Context, projectName, raw string) (time.Time, string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, "", fmt.Errorf("since is required")
+	}
+	if strings.HasPrefix(raw, projectName+":") {
+		scan, err := s.queue.GetScan(ctx, raw)
+		if err != nil || scan.ProjectName != projectName {
+			return time.Time{}, "", fmt.Errorf("unknown scan %q", raw)
+		}
+		if scan.EndedAt.Unix() > 0 {
+			return scan.EndedAt, scan.ID, nil
+		}
+		return scan.StartedAt, scan.ID, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, "", nil
+	}
+	if secs, err := strconv.ParseInt(raw, 10, 64); err == nil && secs >= 0 {
+		return time.Unix(secs, 0), "", nil
+	}
+	return time.Time{}, "", fmt.Errorf("since must be a scan ID, RFC3339 timestamp or Unix seconds")
+}
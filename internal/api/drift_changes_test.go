@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+func TestDriftChangesSinceScan(t *testing.T) {
+	runner := &fakeRunner{}
+	ts, q, cleanup := newTestServer(t, runner, []string{"envs/dev"}, false, nil, true)
+	defer cleanup()
+
+	ctx := context.Background()
+	runScan := func(drifted bool) *queue.Scan {
+		t.Helper()
+		scan, err := q.StartScan(ctx, "project", "manual", "", "", 1)
+		if err != nil {
+			t.Fatalf("start scan: %v", err)
+		}
+		if err := q.Enqueue(ctx, &queue.StackScan{ScanID: scan.ID, ProjectName: "project", ProjectURL: "file:///project", StackPath: "envs/dev"}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		dctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		stackScan, err := q.Dequeue(dctx, "worker-1")
+		if err != nil {
+			t.Fatalf("dequeue: %v", err)
+		}
+		if err := q.Complete(ctx, stackScan, drifted); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+		return scan
+	}
+
+	first := runScan(false)
+	second := runScan(true)
+
+	resp, err := http.Get(ts.URL + "/api/projects/project/drift/changes?since=" + first.ID)
+	if err != nil {
+		t.Fatalf("drift changes: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var body driftChangesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.SinceScanID != first.ID || len(body.Changes) != 1 {
+		t.Fatalf("unexpected response: %+v", body)
+	}
+	change := body.Changes[0]
+	if change.StackPath != "envs/dev" || change.Previous != queue.DriftStateHealthy || change.Current != queue.DriftStateDrifted || change.ScanID != second.ID {
+		t.Fatalf("unexpected change: %+v", change)
+	}
+
+	resp2, err := http.Get(ts.URL + "/api/projects/project/drift/changes?since=" + second.ID)
+	if err != nil {
+		t.Fatalf("drift changes: %v", err)
+	}
+	defer resp2.Body.Close()
+	var none driftChangesResponse
+	if err := json.NewDecoder(resp2.Body).Decode(&none); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(none.Changes) != 0 {
+		t.Fatalf("expected no changes since latest scan, got %+v", none.Changes)
+	}
+
+	for _, since := range []string{"", "yesterday", "other:123"} {
+		bad, err := http.Get(ts.URL + "/api/projects/project/drift/changes?since=" + since)
+		if err != nil {
+			t.Fatalf("drift changes: %v", err)
+		}
+		var errBody map[string]string
+		_ = json.NewDecoder(bad.Body).Decode(&errBody)
+		bad.Body.Close()
+		if bad.StatusCode != http.StatusBadRequest || errBody["error"] == "" {
+			t.Fatalf("since=%q: expected 400 with a JSON error, got %d %v", since, bad.StatusCode, errBody)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/severity"
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+// apiDriftedResource is one resource change in the last plan of a drifted
+// stack.
+type apiDriftedResource struct {
+	Address   string `json:"address"`
+	Type      string `json:"type"`
+	Provider  string `json:"provider"`
+	Action    string `json:"action"`
+	StackPath string `json:"stack_path"`
+	// Suppressed stacks are listed but not counted as drifted elsewhere.
+	Suppressed bool  `json:"suppressed,omitempty"`
+	RunAt      int64 `json:"run_at"`
+}
+
+type driftedResourcesResponse struct {
+	ProjectName string               `json:"project_name"`
+	Resources   []apiDriftedResource `json:"resources"`
+	// Stacks counts the drifted stacks with at least one listed resource.
+	Stacks int `json:"stacks"`
+}
+
+// handleDriftedResources lists the resource changes of every drifted stack
+// in a project, filtered by ?provider=, ?type= and ?action=. Each filter
+// takes a comma-separated list. Data source reads are left out.
+func (s *Server) handleDriftedResources(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid project name"})
+		return
+	}
+	if _, err := s.getProjectConfig(projectName); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "project not found"})
+		return
+	}
+	query := r.URL.Query()
+	providers := queryList(query.Get("provider"))
+	types := queryList(query.Get("type"))
+	actions := queryList(query.Get("action"))
+
+	stacks, err := s.storage.ListStacks(projectName)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	stacks = filterParentStackStatuses(stacks)
+	sort.Slice(stacks, func(i, j int) bool { return stacks[i].Path < stacks[j].Path })
+
+	resp := driftedResourcesResponse{ProjectName: projectName, Resources: []apiDriftedResource{}}
+	for _, st := range stacks {
+		if !st.Drifted || st.Error != "" {
+			continue
+		}
+		matched := false
+		for _, rc := range st.ResourceChanges {
+			if rc.Action == "read" {
+				continue
+			}
+			res := driftedResource(st, rc)
+			if !matchesList(providers, res.Provider) || !matchesList(types, res.Type) || !matchesList(actions, res.Action) {
+				continue
+			}
+			resp.Resources = append(resp.Resources, res)
+			matched = true
+		}
+		if matched {
+			resp.Stacks++
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func driftedResource(st storage.StackStatus, rc storage.ResourceChange) apiDriftedResource {
+	resourceType := severity.ResourceType(rc.Address)
+	provider, _, _ := strings.Cut(resourceType, "_")
+	return apiDriftedResource{
+		Address:    rc.Address,
+		Type:       resourceType,
+		Provider:   provider,
+		Action:     rc.Action,
+		StackPath:  st.Path,
+		Suppressed: st.Suppressed,
+		RunAt:      st.RunAt.Unix(),
+	}
+}
+
+// queryList splits a comma-separated query value, dropping empty items.
+func queryList(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// matchesList reports whether value is in list. An empty list matches
+// everything.
+func matchesList(list []string, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
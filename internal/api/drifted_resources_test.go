@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestDriftedResources(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod", "envs/dev", "envs/stage"}, false, nil, true, nil)
+	defer cleanup()
+
+	now := time.Now()
+	results := map[string]*storage.RunResult{
+		"envs/prod": {RunAt: now, Drifted: true, ResourceChanges: []storage.ResourceChange{
+			{Address: "aws_security_group.web", Action: "update"},
+			{Address: `module.db.aws_db_instance.main["a"]`, Action: "update"},
+			{Address: "data.aws_iam_policy_document.assume", Action: "read"},
+		}},
+		"envs/dev": {RunAt: now, Drifted: true, ResourceChanges: []storage.ResourceChange{
+			{Address: "module.net.aws_security_group.lb", Action: "delete"},
+			{Address: "google_storage_bucket.logs", Action: "create"},
+		}},
+		// Clean stacks are left out even if they list changes.
+		"envs/stage": {RunAt: now, NoisyClean: true, ResourceChanges: []storage.ResourceChange{
+			{Address: "aws_security_group.web", Action: "update"},
+		}},
+	}
+	for path, result := range results {
+		if err := srv.storage.SaveResult("project", path, result); err != nil {
+			t.Fatalf("save result: %v", err)
+		}
+	}
+
+	get := func(query string) driftedResourcesResponse {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/api/projects/project/drifted-resources" + query)
+		if err != nil {
+			t.Fatalf("drifted resources: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var out driftedResourcesResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return out
+	}
+
+	all := get("")
+	if len(all.Resources) != 4 || all.Stacks != 2 {
+		t.Fatalf("expected 4 resources in 2 stacks, got %+v", all)
+	}
+	if r := all.Resources[0]; r.StackPath != "envs/dev" || r.Type != "aws_security_group" || r.Provider != "aws" {
+		t.Fatalf("unexpected first resource %+v", r)
+	}
+
+	sgs := get("?type=aws_security_group")
+	if len(sgs.Resources) != 2 || sgs.Stacks != 2 {
+		t.Fatalf("expected security groups in both stacks, got %+v", sgs)
+	}
+	if got := get("?type=aws_security_group&action=delete"); len(got.Resources) != 1 || got.Resources[0].Address != "module.net.aws_security_group.lb" {
+		t.Fatalf("unexpected filtered resources %+v", got)
+	}
+	if got := get("?provider=google,azurerm"); len(got.Resources) != 1 || got.Resources[0].Type != "google_storage_bucket" {
+		t.Fatalf("unexpected provider filter result %+v", got)
+	}
+	if got := get("?type=aws_iam_role"); len(got.Resources) != 0 || got.Stacks != 0 {
+		t.Fatalf("expected no matches, got %+v", got)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/projects/missing/drifted-resources")
+	if err != nil {
+		t.Fatalf("missing project: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown project, got %d", resp.StatusCode)
+	}
+}
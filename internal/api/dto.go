@@ -1,6 +1,11 @@
 package api
 
-import "github.com/driftdhq/driftd/internal/queue"
+import (
+	"github.com/driftdhq/driftd/internal/orchestrate"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/stack"
+	"github.com/driftdhq/driftd/internal/storage"
+)
 
 type apiScan struct {
 	ID          string `json:"id"`
@@ -14,7 +19,9 @@ type apiScan struct {
 	EndedAt     int64  `json:"ended_at,omitempty"`
 	Error       string `json:"error,omitempty"`
 
-	CommitSHA string `json:"commit_sha,omitempty"`
+	CommitSHA    string         `json:"commit_sha,omitempty"`
+	CommitSkewed bool           `json:"commit_skewed,omitempty"`
+	CommitInfo   *apiCommitInfo `json:"commit_info,omitempty"`
 
 	Total     int `json:"total"`
 	Queued    int `json:"queued"`
@@ -23,6 +30,14 @@ type apiScan struct {
 	Failed    int `json:"failed"`
 	Drifted   int `json:"drifted"`
 	Errored   int `json:"errored"`
+	// SkippedStale counts stacks left out for having no recent commits.
+	SkippedStale int `json:"skipped_stale,omitempty"`
+	// Warnings are workspace problems such as committed secrets.
+	Warnings []queue.ScanWarning `json:"warnings,omitempty"`
+	// RetryOf is the failed scan this scan automatically retries, and
+	// RetryScanID the retry started for this one.
+	RetryOf     string `json:"retry_of,omitempty"`
+	RetryScanID string `json:"retry_scan_id,omitempty"`
 
 	TerraformVersion  string            `json:"terraform_version,omitempty"`
 	TerragruntVersion string            `json:"terragrunt_version,omitempty"`
@@ -30,21 +45,40 @@ type apiScan struct {
 	StackTGVersions   map[string]string `json:"stack_tg_versions,omitempty"`
 }
 
+// apiCommitInfo describes the commit a scan checked out.
+type apiCommitInfo struct {
+	Author  string `json:"author,omitempty"`
+	Summary string `json:"summary,omitempty"`
+	Time    int64  `json:"time"`
+}
+
+func toAPICommitInfo(info *storage.CommitInfo) *apiCommitInfo {
+	if info == nil {
+		return nil
+	}
+	return &apiCommitInfo{Author: info.Author, Summary: info.Summary, Time: info.Time.Unix()}
+}
+
 type apiStackScan struct {
-	ID          string `json:"id"`
-	ScanID      string `json:"scan_id"`
-	ProjectName string `json:"project_name"`
-	StackPath   string `json:"stack_path"`
-	Status      string `json:"status"`
-	Retries     int    `json:"retries"`
-	MaxRetries  int    `json:"max_retries"`
-	CreatedAt   int64  `json:"created_at"`
-	StartedAt   int64  `json:"started_at,omitempty"`
-	CompletedAt int64  `json:"completed_at,omitempty"`
-	Error       string `json:"error,omitempty"`
-	Trigger     string `json:"trigger,omitempty"`
-	Commit      string `json:"commit,omitempty"`
-	Actor       string `json:"actor,omitempty"`
+	ID          string            `json:"id"`
+	ScanID      string            `json:"scan_id"`
+	ProjectName string            `json:"project_name"`
+	StackPath   string            `json:"stack_path"`
+	Status      string            `json:"status"`
+	Retries     int               `json:"retries"`
+	MaxRetries  int               `json:"max_retries"`
+	CreatedAt   int64             `json:"created_at"`
+	StartedAt   int64             `json:"started_at,omitempty"`
+	CompletedAt int64             `json:"completed_at,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	Trigger     string            `json:"trigger,omitempty"`
+	Commit      string            `json:"commit,omitempty"`
+	Actor       string            `json:"actor,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	// DisplayName and Group come from the project's stack_names rules.
+	DisplayName string `json:"display_name,omitempty"`
+	Group       string `json:"group,omitempty"`
 }
 
 func toAPIScan(scan *queue.Scan) *apiScan {
@@ -63,6 +97,8 @@ func toAPIScan(scan *queue.Scan) *apiScan {
 		EndedAt:           scan.EndedAt.Unix(),
 		Error:             scan.Error,
 		CommitSHA:         scan.CommitSHA,
+		CommitSkewed:      scan.CommitSkewed,
+		CommitInfo:        toAPICommitInfo(scan.CommitInfo),
 		Total:             scan.Total,
 		Queued:            scan.Queued,
 		Running:           scan.Running,
@@ -74,6 +110,10 @@ func toAPIScan(scan *queue.Scan) *apiScan {
 		TerragruntVersion: scan.TerragruntVersion,
 		StackTFVersions:   scan.StackTFVersions,
 		StackTGVersions:   scan.StackTGVersions,
+		SkippedStale:      scan.SkippedStale,
+		Warnings:          scan.Warnings,
+		RetryOf:           scan.RetryOf,
+		RetryScanID:       scan.RetryScanID,
 	}
 }
 
@@ -98,3 +138,132 @@ func toAPIStackScan(scan *queue.StackScan) *apiStackScan {
 		Actor:       scan.Actor,
 	}
 }
+
+type apiDiscovery struct {
+	ProjectName       string                          `json:"project_name"`
+	CommitSHA         string                          `json:"commit_sha,omitempty"`
+	RootPath          string                          `json:"root_path,omitempty"`
+	IgnorePaths       []string                        `json:"ignore_paths,omitempty"`
+	Stacks            []string                        `json:"stacks"`
+	TerraformVersion  string                          `json:"terraform_version,omitempty"`
+	TerragruntVersion string                          `json:"terragrunt_version,omitempty"`
+	StackTFVersions   map[string]string               `json:"stack_tf_versions,omitempty"`
+	StackTGVersions   map[string]string               `json:"stack_tg_versions,omitempty"`
+	StackTags         map[string]map[string]string    `json:"stack_tags,omitempty"`
+	StackModules      map[string][]stack.ModuleSource `json:"stack_module_sources,omitempty"`
+	Ignored           []apiIgnoreMatch                `json:"ignored"`
+}
+
+type apiIgnoreMatch struct {
+	Path    string `json:"path"`
+	Pattern string `json:"pattern"`
+}
+
+func toAPIDiscovery(projectName, rootPath string, ignorePaths []string, result *orchestrate.DiscoveryResult) *apiDiscovery {
+	out := &apiDiscovery{
+		ProjectName:  projectName,
+		CommitSHA:    result.CommitSHA,
+		RootPath:     rootPath,
+		IgnorePaths:  ignorePaths,
+		Stacks:       result.Stacks,
+		StackTags:    result.Tags,
+		StackModules: result.ModuleSources,
+		Ignored:      make([]apiIgnoreMatch, 0, len(result.Ignored)),
+	}
+	if out.Stacks == nil {
+		out.Stacks = []string{}
+	}
+	if v := result.Versions; v != nil {
+		out.TerraformVersion = v.DefaultTerraform
+		out.TerragruntVersion = v.DefaultTerragrunt
+		out.StackTFVersions = v.StackTerraform
+		out.StackTGVersions = v.StackTerragrunt
+	}
+	for _, m := range result.Ignored {
+		out.Ignored = append(out.Ignored, apiIgnoreMatch{Path: m.Path, Pattern: m.Pattern})
+	}
+	return out
+}
+
+type apiStackPlan struct {
+	ProjectName string `json:"project_name"`
+	StackPath   string `json:"stack_path"`
+	DisplayName string `json:"display_name,omitempty"`
+	Group       string `json:"group,omitempty"`
+	Drifted     bool   `json:"drifted"`
+	// NoisyClean means the plan had changes, all recognized as no-ops.
+	NoisyClean bool              `json:"noisy_clean,omitempty"`
+	Added      int               `json:"added"`
+	Changed    int               `json:"changed"`
+	Destroyed  int               `json:"destroyed"`
+	Error      string            `json:"error,omitempty"`
+	RunAt      int64             `json:"run_at"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	// ProviderLockDrift is reported separately from resource drift.
+	ProviderLockDrift []storage.ProviderLockMismatch `json:"provider_lock_drift,omitempty"`
+	ModuleSources     []stack.ModuleSource           `json:"module_sources,omitempty"`
+	// ModuleSourceChanges lists module sources changed since the previous scan.
+	ModuleSourceChanges []stack.ModuleSourceChange `json:"module_source_changes,omitempty"`
+	ResourceChanges     []storage.ResourceChange   `json:"resource_changes,omitempty"`
+	Plan                string                     `json:"plan"`
+	// PlanBlocks locate each resource change within Plan by line.
+	PlanBlocks    []planBlock `json:"plan_blocks,omitempty"`
+	PlanTruncated bool        `json:"plan_truncated"`
+	PlanBytes     int         `json:"plan_bytes"`
+	RawURL        string      `json:"raw_url"`
+	// ScanID is the scan that produced this result; CommitSHA and the
+	// versions are what that scan planned with.
+	ScanID            string         `json:"scan_id,omitempty"`
+	CommitSHA         string         `json:"commit_sha,omitempty"`
+	CommitInfo        *apiCommitInfo `json:"commit_info,omitempty"`
+	TerraformVersion  string         `json:"terraform_version,omitempty"`
+	TerragruntVersion string         `json:"terragrunt_version,omitempty"`
+	// Severity scores the drift; SeverityLevel buckets it for display.
+	Severity      int    `json:"severity"`
+	SeverityLevel string `json:"severity_level,omitempty"`
+	// RootCauseHint guesses what caused the drift from the plan's shape.
+	RootCauseHint string `json:"root_cause_hint,omitempty"`
+	// Outputs are the stack's declared outputs; OutputChanges are the ones
+	// the plan would change, with the stacks that read them.
+	Outputs       []string               `json:"outputs,omitempty"`
+	OutputChanges []storage.OutputChange `json:"output_changes,omitempty"`
+	// ResourceOwners name the last commit to touch each drifted resource's
+	// block, for projects with blame_drift.
+	ResourceOwners []apiResourceOwner `json:"resource_owners,omitempty"`
+	// ObservedDuringFreeze means the drift was found while the stack's
+	// environment was frozen, so it did not notify.
+	ObservedDuringFreeze bool `json:"observed_during_freeze,omitempty"`
+	// ConsoleLinks and ResourceConsoleLinks are the project's console links
+	// resolved for this stack and its drifted resources.
+	ConsoleLinks         []consoleLink          `json:"console_links,omitempty"`
+	ResourceConsoleLinks []resourceConsoleLinks `json:"resource_console_links,omitempty"`
+}
+
+type apiResourceOwner struct {
+	Address string `json:"address"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Commit  string `json:"commit"`
+	Author  string `json:"author"`
+	Summary string `json:"summary,omitempty"`
+	Time    int64  `json:"time"`
+}
+
+func toAPIResourceOwners(owners []storage.ResourceOwner) []apiResourceOwner {
+	if len(owners) == 0 {
+		return nil
+	}
+	out := make([]apiResourceOwner, 0, len(owners))
+	for _, o := range owners {
+		out = append(out, apiResourceOwner{
+			Address: o.Address,
+			File:    o.File,
+			Line:    o.Line,
+			Commit:  o.Commit,
+			Author:  o.Author,
+			Summary: o.Summary,
+			Time:    o.Time.Unix(),
+		})
+	}
+	return out
+}
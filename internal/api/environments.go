@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+// environmentSummary counts a group of stacks that share an environment.
+// Suppressed stacks are not counted as drifted, matching project totals.
+type environmentSummary struct {
+	Name     string   `json:"name"`
+	Stacks   int      `json:"stacks"`
+	Drifted  int      `json:"drifted"`
+	Errored  int      `json:"errored"`
+	Projects []string `json:"projects,omitempty"`
+}
+
+func (s *Server) environmentsEnabled() bool {
+	return len(s.cfg.Environments) > 0
+}
+
+func (s *Server) filterStacksByEnvironment(stacks []storage.StackStatus, env string) []storage.StackStatus {
+	if env == "" {
+		return stacks
+	}
+	filtered := make([]storage.StackStatus, 0, len(stacks))
+	for _, st := range stacks {
+		if s.cfg.StackEnvironment(st.Path) == env {
+			filtered = append(filtered, st)
+		}
+	}
+	return filtered
+}
+
+// stackEnvironments maps each stack path to its environment, leaving out
+// stacks that match no mapping.
+func (s *Server) stackEnvironments(stacks []storage.StackStatus) map[string]string {
+	out := make(map[string]string, len(stacks))
+	for _, st := range stacks {
+		if env := s.cfg.StackEnvironment(st.Path); env != "" {
+			out[st.Path] = env
+		}
+	}
+	return out
+}
+
+// summarizeEnvironments groups stacks by environment, sorted by name. Stacks
+// outside every mapping are left out.
+func (s *Server) summarizeEnvironments(stacksByProject map[string][]storage.StackStatus) []environmentSummary {
+	byName := map[string]*environmentSummary{}
+	for projectName, stacks := range stacksByProject {
+		for _, st := range stacks {
+			env := s.cfg.StackEnvironment(st.Path)
+			if env == "" {
+				continue
+			}
+			sum, ok := byName[env]
+			if !ok {
+				sum = &environmentSummary{Name: env}
+				byName[env] = sum
+			}
+			sum.Stacks++
+			if st.Error != "" {
+				sum.Errored++
+			} else if st.Drifted && !st.Suppressed {
+				sum.Drifted++
+			}
+			if n := len(sum.Projects); n == 0 || sum.Projects[n-1] != projectName {
+				sum.Projects = append(sum.Projects, projectName)
+			}
+		}
+	}
+	out := make([]environmentSummary, 0, len(byName))
+	for _, sum := range byName {
+		sort.Strings(sum.Projects)
+		out = append(out, *sum)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// handleListEnvironments returns drift counts per environment across all
+// projects, or for one project with ?project=.
+func (s *Server) handleListEnvironments(w http.ResponseWriter, r *http.Request) {
+	stacksByProject := map[string][]storage.StackStatus{}
+	if projectName := r.URL.Query().Get("project"); projectName != "" {
+		if !isValidProjectName(projectName) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid project name"})
+			return
+		}
+		stacks, _ := s.storage.ListStacks(projectName)
+		stacksByProject[projectName] = filterParentStackStatuses(stacks)
+	} else {
+		projects, _ := s.storage.ListRepos()
+		for _, project := range projects {
+			stacks, _ := s.storage.ListStacks(project.Name)
+			stacksByProject[project.Name] = filterParentStackStatuses(stacks)
+		}
+	}
+	writeJSON(w, http.StatusOK, s.summarizeEnvironments(stacksByProject))
+}
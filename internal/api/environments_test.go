@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestEnvironmentSummariesAndFilter(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod/app", "envs/prod/db", "envs/dev/app", "shared/dns"}, false, nil, true, func(cfg *config.Config) {
+		cfg.Environments = []config.EnvironmentMapping{{Pattern: "envs/<env>/**"}}
+	})
+	defer cleanup()
+
+	now := time.Now()
+	results := map[string]*storage.RunResult{
+		"envs/prod/app": {RunAt: now, Drifted: true},
+		"envs/prod/db":  {RunAt: now, Error: "plan failed"},
+		"envs/dev/app":  {RunAt: now},
+		"shared/dns":    {RunAt: now, Drifted: true},
+	}
+	for path, result := range results {
+		if err := srv.storage.SaveResult("project", path, result); err != nil {
+			t.Fatalf("save result: %v", err)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/api/environments")
+	if err != nil {
+		t.Fatalf("environments: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var summaries []environmentSummary
+	if err := json.NewDecoder(resp.Body).Decode(&summaries); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := []environmentSummary{
+		{Name: "dev", Stacks: 1, Projects: []string{"project"}},
+		{Name: "prod", Stacks: 2, Drifted: 1, Errored: 1, Projects: []string{"project"}},
+	}
+	if !reflect.DeepEqual(summaries, want) {
+		t.Fatalf("summaries = %+v, want %+v", summaries, want)
+	}
+
+	page, err := http.Get(ts.URL + "/projects/project?env=prod")
+	if err != nil {
+		t.Fatalf("project page: %v", err)
+	}
+	defer page.Body.Close()
+	body, _ := io.ReadAll(page.Body)
+	if page.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", page.StatusCode, body)
+	}
+
+	filtered := srv.filterStacksByEnvironment([]storage.StackStatus{{Path: "envs/prod/app"}, {Path: "envs/dev/app"}, {Path: "shared/dns"}}, "prod")
+	if len(filtered) != 1 || filtered[0].Path != "envs/prod/app" {
+		t.Fatalf("unexpected filtered stacks: %+v", filtered)
+	}
+}
+
+func TestSortStacksByEnvironment(t *testing.T) {
+	env := func(path string) string {
+		if strings.HasPrefix(path, "envs/") {
+			return strings.Split(path, "/")[1]
+		}
+		return ""
+	}
+	stacks := []storage.StackStatus{{Path: "a/shared"}, {Path: "envs/prod/app"}, {Path: "envs/dev/app"}}
+	sorted := sortStacks(stacks, "environment", "asc", env)
+	got := []string{sorted[0].Path, sorted[1].Path, sorted[2].Path}
+	if want := []string{"envs/dev/app", "envs/prod/app", "a/shared"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("sorted = %v, want %v", got, want)
+	}
+}
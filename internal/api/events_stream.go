@@ -30,6 +30,8 @@ type ssePayload struct {
 	ActiveScan  *scanSummary          `json:"active_scan,omitempty"`
 	LastScan    *scanSummary          `json:"last_scan,omitempty"`
 	Stacks      []storage.StackStatus `json:"stacks,omitempty"`
+
+	DriftChanges []queue.DriftChange `json:"drift_changes,omitempty"`
 }
 
 type scanSummary struct {
@@ -76,6 +78,8 @@ func buildUpdatePayload(event *queue.ProjectEvent) ([]byte, error) {
 		RunAt:     event.RunAt,
 		StartedAt: event.StartedAt,
 		EndedAt:   event.EndedAt,
+
+		DriftChanges: event.DriftChanges,
 	}
 
 	switch event.Type {
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/driftdhq/driftd/internal/federation"
+)
+
+type apiFederationInstance struct {
+	Name          string                 `json:"name"`
+	URL           string                 `json:"url,omitempty"`
+	Local         bool                   `json:"local,omitempty"`
+	Stacks        int                    `json:"stacks"`
+	DriftedStacks int                    `json:"drifted_stacks"`
+	ErrorStacks   int                    `json:"error_stacks"`
+	FetchedAt     int64                  `json:"fetched_at,omitempty"`
+	Error         string                 `json:"error,omitempty"`
+	Projects      []apiFederationProject `json:"projects"`
+}
+
+type apiFederationProject struct {
+	federation.Project
+	// URL links to the project on the instance that owns it.
+	URL string `json:"url"`
+}
+
+type federationPageData struct {
+	pageAuth
+	Instances []federation.Instance
+}
+
+// localOverview summarizes this instance's projects for its peers.
+func (s *Server) localOverview(r *http.Request) *federation.Overview {
+	projects, _ := s.storage.ListRepos()
+	overview := &federation.Overview{Projects: make([]federation.Project, 0, len(projects))}
+	for _, project := range projects {
+		status, _ := s.projectStatus(r.Context(), project)
+		summary := federation.Project{
+			Name:          status.Name,
+			Drifted:       status.Drifted,
+			Stacks:        status.Stacks,
+			DriftedStacks: status.DriftedStacks,
+			ErrorStacks:   status.ErrorStacks,
+			Active:        status.Active,
+		}
+		if !status.LastRun.IsZero() {
+			summary.LastRun = status.LastRun.Unix()
+		}
+		overview.Projects = append(overview.Projects, summary)
+	}
+	return overview
+}
+
+// handleFederationOverview serves this instance's overview. Instances that
+// list this one as a peer read it with an API read token.
+func (s *Server) handleFederationOverview(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.localOverview(r))
+}
+
+// handleFederation returns the merged overview of this instance and its
+// peers.
+func (s *Server) handleFederation(w http.ResponseWriter, r *http.Request) {
+	if s.federation == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "federation not enabled"})
+		return
+	}
+	instances := s.federation.Merge(r.Context(), s.localOverview(r))
+	out := make([]apiFederationInstance, 0, len(instances))
+	for i, inst := range instances {
+		stacks, drifted, errored := inst.Totals()
+		item := apiFederationInstance{
+			Name:          inst.Name,
+			URL:           inst.URL,
+			Local:         i == 0,
+			Stacks:        stacks,
+			DriftedStacks: drifted,
+			ErrorStacks:   errored,
+			Error:         inst.Error,
+			Projects:      make([]apiFederationProject, 0, len(inst.Projects)),
+		}
+		if !inst.FetchedAt.IsZero() {
+			item.FetchedAt = inst.FetchedAt.Unix()
+		}
+		for _, project := range inst.Projects {
+			item.Projects = append(item.Projects, apiFederationProject{Project: project, URL: inst.ProjectURL(project.Name)})
+		}
+		out = append(out, item)
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleFederationUI renders the merged overview page.
+func (s *Server) handleFederationUI(w http.ResponseWriter, r *http.Request) {
+	if s.federation == nil {
+		http.NotFound(w, r)
+		return
+	}
+	data := federationPageData{
+		pageAuth:  s.pageAuth(r),
+		Instances: s.federation.Merge(r.Context(), s.localOverview(r)),
+	}
+	if err := s.tmplFederation.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("template error: %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+func TestFederationMergesPeers(t *testing.T) {
+	peer, _, peerCleanup := newTestServer(t, &fakeRunner{}, []string{"envs/dev"}, false, nil, false)
+	defer peerCleanup()
+
+	_, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/dev"}, false, nil, false, func(cfg *config.Config) {
+		cfg.Federation = config.FederationConfig{
+			Enabled:  true,
+			Name:     "platform",
+			Timeout:  time.Second,
+			CacheFor: time.Minute,
+			Peers:    []config.FederationPeer{{Name: "payments", URL: peer.URL, TokenHeader: "X-API-Token"}},
+		}
+	})
+	defer cleanup()
+
+	resp, err := http.Get(ts.URL + "/api/federation")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var instances []apiFederationInstance
+	if err := json.NewDecoder(resp.Body).Decode(&instances); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(instances) != 2 || instances[0].Name != "platform" || !instances[0].Local {
+		t.Fatalf("unexpected instances: %+v", instances)
+	}
+	if instances[1].Name != "payments" || instances[1].URL != peer.URL || instances[1].Error != "" {
+		t.Fatalf("unexpected peer: %+v", instances[1])
+	}
+
+	ui, err := http.Get(ts.URL + "/federation")
+	if err != nil {
+		t.Fatalf("ui request failed: %v", err)
+	}
+	ui.Body.Close()
+	if ui.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 from the UI page, got %d", ui.StatusCode)
+	}
+
+	disabled, err := http.Get(peer.URL + "/api/federation")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	disabled.Body.Close()
+	if disabled.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 without federation, got %d", disabled.StatusCode)
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/pathutil"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+// projectCardData is the dashboard row of one project.
+type projectCardData struct {
+	Name   string
+	URL    string
+	Status projectStatusData
+}
+
+// stackRowData is one row of the project page stack list.
+type stackRowData struct {
+	ProjectName string
+	Stack       storage.StackStatus
+	Environment string
+	Display     config.StackDisplay
+	// RowIndex is the row's aria-rowindex in the stack grid, or 0.
+	RowIndex int
+}
+
+func newProjectCard(project config.ProjectConfig, status projectStatusData) projectCardData {
+	return projectCardData{Name: project.Name, URL: project.URL, Status: status}
+}
+
+// withRowIndex sets the row's aria-rowindex. Rows rendered on their own,
+// such as refreshed fragments, leave it unset.
+func withRowIndex(row stackRowData, index int) stackRowData {
+	row.RowIndex = index
+	return row
+}
+
+func newStackRow(projectName string, stack storage.StackStatus, environments map[string]string, displays map[string]config.StackDisplay) stackRowData {
+	return stackRowData{ProjectName: projectName, Stack: stack, Environment: environments[stack.Path], Display: displays[stack.Path]}
+}
+
+// handleProjectCardFragment renders the dashboard row of a project so the
+// index page can refresh it in place when a scan finishes.
+func (s *Server) handleProjectCardFragment(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		http.Error(w, "Invalid project name", http.StatusBadRequest)
+		return
+	}
+	projectCfg, err := s.getProjectConfig(projectName)
+	if err != nil || projectCfg == nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	summary := storage.ProjectStatus{Name: projectName}
+	if projects, err := s.storage.ListRepos(); err == nil {
+		for _, project := range projects {
+			if project.Name == projectName {
+				summary = project
+				break
+			}
+		}
+	}
+	status, _ := s.projectStatus(r.Context(), summary)
+	s.renderFragment(w, "project-card", newProjectCard(*projectCfg, status))
+}
+
+// handleStackRowFragment renders one row of the project page stack list.
+func (s *Server) handleStackRowFragment(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	stackPath := chi.URLParam(r, "*")
+	if !isValidProjectName(projectName) || !pathutil.IsSafeStackPath(stackPath) {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	stacks, err := s.storage.ListStacks(projectName)
+	if err != nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	for i := range stacks {
+		if stacks[i].Path != stackPath {
+			continue
+		}
+		row := stacks[i : i+1]
+		s.severity.Apply(row)
+		projectCfg, _ := s.getProjectConfig(projectName)
+		s.renderFragment(w, "stack-row", newStackRow(projectName, row[0], s.stackEnvironments(row), stackDisplays(projectCfg, row)))
+		return
+	}
+	http.Error(w, "Stack not found", http.StatusNotFound)
+}
+
+// handleScanProgressFragment renders the progress bar of the project's
+// running scan, or an empty placeholder when none is running.
+func (s *Server) handleScanProgressFragment(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		http.Error(w, "Invalid project name", http.StatusBadRequest)
+		return
+	}
+	var activeScan *queue.Scan
+	if !s.degradedStatus().Degraded {
+		activeScan, _ = s.queue.GetActiveScan(r.Context(), projectName)
+	}
+	s.renderFragment(w, "scan-progress", activeScan)
+}
+
+func (s *Server) renderFragment(w http.ResponseWriter, name string, data any) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := s.tmplFragments.ExecuteTemplate(w, name, data); err != nil {
+		log.Printf("template error: %v", err)
+	}
+}
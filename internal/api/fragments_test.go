@@ -0,0 +1,65 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestFragments(t *testing.T) {
+	srv, ts, q, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod/app"}, false, nil, true, func(cfg *config.Config) {
+		cfg.Environments = []config.EnvironmentMapping{{Pattern: "envs/<env>/**"}}
+	})
+	defer cleanup()
+
+	if err := srv.storage.SaveResult("project", "envs/prod/app", &storage.RunResult{RunAt: time.Now(), Drifted: true, Added: 1}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("get %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusOK && !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+			t.Fatalf("expected HTML from %s, got %q", path, resp.Header.Get("Content-Type"))
+		}
+		return resp.StatusCode, strings.TrimSpace(string(body))
+	}
+
+	if code, body := get("/fragments/projects/project/card"); code != http.StatusOK || body != "project-card project drifted=1" {
+		t.Fatalf("unexpected card %d %q", code, body)
+	}
+	if code, body := get("/fragments/projects/project/stacks/envs/prod/app"); code != http.StatusOK || body != "stack-row envs/prod/app env=prod" {
+		t.Fatalf("unexpected stack row %d %q", code, body)
+	}
+	if code, _ := get("/fragments/projects/project/stacks/envs/missing"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown stack, got %d", code)
+	}
+	if code, _ := get("/fragments/projects/unknown/card"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown project, got %d", code)
+	}
+
+	if code, body := get("/fragments/projects/project/progress"); code != http.StatusOK || body != "scan-progress" {
+		t.Fatalf("expected an empty progress placeholder, got %d %q", code, body)
+	}
+	scan, err := q.StartScan(context.Background(), "project", "manual", "", "", 4)
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	if err := q.AdjustScanCounters(context.Background(), scan.ID, "project", "completed", 1); err != nil {
+		t.Fatalf("adjust counters: %v", err)
+	}
+	if code, body := get("/fragments/projects/project/progress"); code != http.StatusOK || body != "scan-progress 1/4" {
+		t.Fatalf("unexpected progress %d %q", code, body)
+	}
+}
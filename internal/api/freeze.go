@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/freeze"
+)
+
+// freezeAuditLimit bounds the audit entries returned with the freeze state.
+const freezeAuditLimit = 50
+
+var environmentNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type freezeRequest struct {
+	Environment string     `json:"environment"`
+	Enabled     bool       `json:"enabled"`
+	Reason      string     `json:"reason"`
+	Actor       string     `json:"actor"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	// Duration is an alternative to ExpiresAt, such as "2h".
+	Duration string `json:"duration"`
+}
+
+type freezeResponse struct {
+	Freezes []freeze.Freeze     `json:"freezes"`
+	Audit   []freeze.AuditEntry `json:"audit"`
+}
+
+// handleGetFreeze lists frozen environments and the latest audit entries.
+func (s *Server) handleGetFreeze(w http.ResponseWriter, r *http.Request) {
+	freezes, err := s.freeze.List()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	audit, err := s.freeze.Audit(freezeAuditLimit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	writeJSON(w, http.StatusOK, freezeResponse{Freezes: freezes, Audit: audit})
+}
+
+// handleSetFreeze freezes or unfreezes one environment. A freeze always
+// expires: at expires_at, after duration, or after freeze.DefaultDuration.
+func (s *Server) handleSetFreeze(w http.ResponseWriter, r *http.Request) {
+	var req freezeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	env := strings.TrimSpace(req.Environment)
+	if !environmentNamePattern.MatchString(env) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid environment"})
+		return
+	}
+	actor := strings.TrimSpace(req.Actor)
+	if actor == "" {
+		actor = s.uiActor(r)
+	}
+
+	if !req.Enabled {
+		if err := s.freeze.Unfreeze(env, actor); err != nil {
+			if errors.Is(err, freeze.ErrNotFrozen) {
+				writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	expiresAt := time.Now().Add(freeze.DefaultDuration)
+	switch {
+	case req.ExpiresAt != nil && req.Duration != "":
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "set expires_at or duration, not both"})
+		return
+	case req.ExpiresAt != nil:
+		expiresAt = *req.ExpiresAt
+	case req.Duration != "":
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid duration"})
+			return
+		}
+		expiresAt = time.Now().Add(d)
+	}
+	f, err := s.freeze.Freeze(env, strings.TrimSpace(req.Reason), actor, expiresAt)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, f)
+}
@@ -0,0 +1,149 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/freeze"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/runner"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+// freezeRecordingRunner reports every stack as drifted and records which
+// runs were told their environment is frozen.
+type freezeRecordingRunner struct {
+	mu     sync.Mutex
+	frozen map[string]bool
+}
+
+func (f *freezeRecordingRunner) Run(ctx context.Context, params *runner.RunParams) (*storage.RunResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.frozen[params.StackPath] = params.Frozen
+	return &storage.RunResult{Drifted: true, RunAt: time.Now(), ObservedDuringFreeze: params.Frozen}, nil
+}
+
+func TestEnvironmentFreezeAPI(t *testing.T) {
+	rec := &freezeRecordingRunner{frozen: map[string]bool{}}
+	srv, ts, _, cleanup := newTestServerWithConfig(t, rec, []string{"envs/prod", "envs/dev"}, true, nil, true, func(cfg *config.Config) {
+		cfg.Environments = []config.EnvironmentMapping{{Pattern: "envs/<env>"}}
+	})
+	defer cleanup()
+
+	resp, err := http.Post(ts.URL+"/api/admin/freeze", "application/json", bytes.NewBufferString(`{"environment":"prod","enabled":true,"reason":"incident","actor":"oncall","duration":"30m"}`))
+	if err != nil {
+		t.Fatalf("freeze: %v", err)
+	}
+	var f freeze.Freeze
+	if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+		t.Fatalf("decode freeze: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || f.Environment != "prod" || f.Actor != "oncall" {
+		t.Fatalf("unexpected freeze response %d: %+v", resp.StatusCode, f)
+	}
+	if d := time.Until(f.ExpiresAt); d < 29*time.Minute || d > 30*time.Minute {
+		t.Fatalf("expected freeze to expire in 30m, got %v", d)
+	}
+
+	resp, err = http.Post(ts.URL+"/api/projects/project/scan", "application/json", bytes.NewBufferString(`{}`))
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	var sr scanResp
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		t.Fatalf("decode scan: %v", err)
+	}
+	resp.Body.Close()
+	if sr.Scan == nil {
+		t.Fatalf("expected scan, got %d: %s", resp.StatusCode, sr.Error)
+	}
+	if scan := waitForScan(t, ts, sr.Scan.ID, 5*time.Second); scan.Status != queue.ScanStatusCompleted {
+		t.Fatalf("expected completed scan, got %s", scan.Status)
+	}
+	rec.mu.Lock()
+	if !rec.frozen["envs/prod"] || rec.frozen["envs/dev"] {
+		t.Fatalf("expected only prod frozen, got %v", rec.frozen)
+	}
+	rec.mu.Unlock()
+
+	// The fake runner does not save results; store what the runner would.
+	if err := srv.storage.SaveResult("project", "envs/prod", &storage.RunResult{Drifted: true, RunAt: time.Now(), ObservedDuringFreeze: true}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+
+	resp, err = http.Get(ts.URL + "/api/projects/project/stacks/envs/prod/plan")
+	if err != nil {
+		t.Fatalf("stack plan: %v", err)
+	}
+	var plan apiStackPlan
+	if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+		t.Fatalf("decode plan: %v", err)
+	}
+	resp.Body.Close()
+	if !plan.Drifted || !plan.ObservedDuringFreeze {
+		t.Fatalf("expected drift observed during freeze, got %+v", plan)
+	}
+
+	resp, err = http.Post(ts.URL+"/api/admin/freeze", "application/json", bytes.NewBufferString(`{"environment":"prod","enabled":false,"actor":"lead"}`))
+	if err != nil {
+		t.Fatalf("unfreeze: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected 204 for unfreeze, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(ts.URL + "/api/admin/freeze")
+	if err != nil {
+		t.Fatalf("get freeze: %v", err)
+	}
+	var state freezeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		t.Fatalf("decode state: %v", err)
+	}
+	resp.Body.Close()
+	if len(state.Freezes) != 0 {
+		t.Fatalf("expected no freezes, got %+v", state.Freezes)
+	}
+	if len(state.Audit) != 2 || state.Audit[0].Action != freeze.ActionUnfreeze || state.Audit[0].Actor != "lead" || state.Audit[1].Reason != "incident" {
+		t.Fatalf("unexpected audit %+v", state.Audit)
+	}
+}
+
+func TestEnvironmentFreezeValidation(t *testing.T) {
+	ts, _, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true)
+	defer cleanup()
+
+	for _, body := range []string{
+		`{"environment":"","enabled":true}`,
+		`{"environment":"prod","enabled":true,"duration":"soon"}`,
+		`{"environment":"prod","enabled":true,"duration":"720h"}`,
+		`{"environment":"prod","enabled":true,"duration":"1h","expires_at":"2030-01-01T00:00:00Z"}`,
+	} {
+		resp, err := http.Post(ts.URL+"/api/admin/freeze", "application/json", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("freeze: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", body, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Post(ts.URL+"/api/admin/freeze", "application/json", bytes.NewBufferString(`{"environment":"prod","enabled":false}`))
+	if err != nil {
+		t.Fatalf("unfreeze: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 unfreezing an unfrozen environment, got %d", resp.StatusCode)
+	}
+}
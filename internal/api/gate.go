@@ -0,0 +1,114 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+// Gate checks, reported on each failure.
+const (
+	gateCheckDrift    = "drift"
+	gateCheckStale    = "stale"
+	gateCheckError    = "error"
+	gateCheckNoResult = "no_results"
+)
+
+// gateResponse is the deployment gate verdict for a project. Pass is false
+// when any failure was found.
+type gateResponse struct {
+	ProjectName   string        `json:"project_name"`
+	Pass          bool          `json:"pass"`
+	EvaluatedAt   time.Time     `json:"evaluated_at"`
+	Policy        gatePolicy    `json:"policy"`
+	StacksChecked int           `json:"stacks_checked"`
+	Failures      []gateFailure `json:"failures"`
+}
+
+type gatePolicy struct {
+	Environments []string `json:"environments,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	MaxAge       string   `json:"max_age,omitempty"`
+	FailOnErrors bool     `json:"fail_on_errors"`
+}
+
+type gateFailure struct {
+	StackPath   string    `json:"stack_path,omitempty"`
+	Environment string    `json:"environment,omitempty"`
+	Check       string    `json:"check"`
+	Reason      string    `json:"reason"`
+	RunAt       time.Time `json:"run_at,omitempty"`
+}
+
+// handleProjectGate evaluates the gate policy against a project's latest
+// results, for CD pipelines to call before deploying.
+func (s *Server) handleProjectGate(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		http.Error(w, "Invalid project name", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.getProjectConfig(projectName); err != nil {
+		http.Error(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	stacks, err := s.storage.ListStacks(projectName)
+	if err != nil {
+		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.evaluateGate(projectName, filterParentStackStatuses(stacks), time.Now()))
+}
+
+func (s *Server) evaluateGate(projectName string, stacks []storage.StackStatus, now time.Time) gateResponse {
+	policy := s.cfg.Gate
+	resp := gateResponse{
+		ProjectName: projectName,
+		EvaluatedAt: now.UTC(),
+		Policy:      gatePolicy{Environments: policy.Environments, Tags: policy.Tags, FailOnErrors: policy.FailOnErrors},
+		Failures:    []gateFailure{},
+	}
+	if policy.MaxAge > 0 {
+		resp.Policy.MaxAge = policy.MaxAge.String()
+	}
+	if len(stacks) == 0 {
+		resp.Failures = append(resp.Failures, gateFailure{
+			Check:  gateCheckNoResult,
+			Reason: "project has no scan results",
+		})
+	}
+
+	sort.Slice(stacks, func(i, j int) bool { return stacks[i].Path < stacks[j].Path })
+	for _, st := range stacks {
+		env := s.cfg.StackEnvironment(st.Path)
+		if !policy.Covers(env, st.Tags) {
+			continue
+		}
+		resp.StacksChecked++
+		fail := func(check, reason string) {
+			resp.Failures = append(resp.Failures, gateFailure{
+				StackPath:   st.Path,
+				Environment: env,
+				Check:       check,
+				Reason:      reason,
+				RunAt:       st.RunAt,
+			})
+		}
+		if st.Drifted && !st.Suppressed {
+			fail(gateCheckDrift, fmt.Sprintf("drifted: %d to add, %d to change, %d to destroy", st.Added, st.Changed, st.Destroyed))
+		}
+		if policy.FailOnErrors && st.Error != "" {
+			fail(gateCheckError, "last scan errored: "+s.sanitizeErrorMessage(st.Error))
+		}
+		if policy.MaxAge > 0 && now.Sub(st.RunAt) > policy.MaxAge {
+			fail(gateCheckStale, fmt.Sprintf("last scanned %s ago, over %s", now.Sub(st.RunAt).Round(time.Minute), policy.MaxAge))
+		}
+	}
+	resp.Pass = len(resp.Failures) == 0
+	return resp
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestProjectGate(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod/app", "envs/prod/db", "envs/dev/app"}, false, nil, true, func(cfg *config.Config) {
+		cfg.Environments = []config.EnvironmentMapping{{Pattern: "envs/<env>/**"}}
+		cfg.Gate = config.GateConfig{Environments: []string{"prod"}, MaxAge: 24 * time.Hour, FailOnErrors: true}
+	})
+	defer cleanup()
+
+	getGate := func() gateResponse {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/api/projects/project/gate")
+		if err != nil {
+			t.Fatalf("gate: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var gate gateResponse
+		if err := json.NewDecoder(resp.Body).Decode(&gate); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return gate
+	}
+
+	if gate := getGate(); gate.Pass || len(gate.Failures) != 1 || gate.Failures[0].Check != gateCheckNoResult {
+		t.Fatalf("expected a project without results to fail, got %+v", gate)
+	}
+
+	now := time.Now()
+	save := func(path string, result *storage.RunResult) {
+		t.Helper()
+		if err := srv.storage.SaveResult("project", path, result); err != nil {
+			t.Fatalf("save result: %v", err)
+		}
+	}
+	save("envs/prod/app", &storage.RunResult{RunAt: now})
+	save("envs/prod/db", &storage.RunResult{RunAt: now})
+	save("envs/dev/app", &storage.RunResult{RunAt: now.Add(-72 * time.Hour), Drifted: true, Changed: 1})
+
+	gate := getGate()
+	if !gate.Pass || gate.StacksChecked != 2 || gate.Policy.MaxAge != "24h0m0s" {
+		t.Fatalf("expected clean prod stacks to pass, got %+v", gate)
+	}
+
+	save("envs/prod/app", &storage.RunResult{RunAt: now, Drifted: true, Added: 1})
+	save("envs/prod/db", &storage.RunResult{RunAt: now.Add(-48 * time.Hour), Error: "plan failed"})
+
+	gate = getGate()
+	if gate.Pass {
+		t.Fatalf("expected drifted prod stack to fail the gate")
+	}
+	checks := map[string]string{}
+	for _, failure := range gate.Failures {
+		checks[failure.Check] = failure.StackPath
+	}
+	want := map[string]string{
+		gateCheckDrift: "envs/prod/app",
+		gateCheckError: "envs/prod/db",
+		gateCheckStale: "envs/prod/db",
+	}
+	if len(checks) != len(want) || len(gate.Failures) != 3 {
+		t.Fatalf("failures = %+v, want %v", gate.Failures, want)
+	}
+	for check, path := range want {
+		if checks[check] != path {
+			t.Fatalf("failures = %+v, want %v", gate.Failures, want)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/api/projects/missing/gate")
+	if err != nil {
+		t.Fatalf("gate: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown project, got %d", resp.StatusCode)
+	}
+}
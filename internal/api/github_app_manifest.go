@@ -0,0 +1,298 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/gitauth"
+	"github.com/driftdhq/driftd/internal/secrets"
+	"github.com/driftdhq/driftd/internal/vcs"
+)
+
+const (
+	githubAppFlowPath    = "/settings/integrations/github-app"
+	githubAppStateCookie = "driftd_github_app"
+	// githubAppFlowTTL matches how long GitHub keeps a manifest code valid.
+	githubAppFlowTTL = time.Hour
+	// githubAppKeysDir holds private keys of apps created through the
+	// manifest flow, relative to data_dir.
+	githubAppKeysDir = "github-apps"
+	// GitHub rejects app names longer than this.
+	maxGitHubAppName = 34
+)
+
+var githubOrganizationPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+var githubAppManifestPage = template.Must(template.New("manifest").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Redirecting to GitHub</title></head>
+<body onload="document.forms[0].submit()">
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="manifest" value="{{.Manifest}}">
+<noscript><button type="submit">Continue to GitHub</button></noscript>
+</form>
+</body>
+</html>
+`))
+
+// handleGitHubAppManifest starts GitHub's App Manifest flow: it posts a
+// manifest describing a driftd app to GitHub, where the admin confirms the
+// app's creation.
+func (s *Server) handleGitHubAppManifest(w http.ResponseWriter, r *http.Request) {
+	if s.intStore == nil {
+		http.Error(w, "dynamic integration management not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		name = "driftd"
+	}
+	if len(name) > maxGitHubAppName {
+		http.Error(w, fmt.Sprintf("name must be at most %d characters", maxGitHubAppName), http.StatusBadRequest)
+		return
+	}
+	organization := strings.TrimSpace(r.FormValue("organization"))
+	if organization != "" && !githubOrganizationPattern.MatchString(organization) {
+		http.Error(w, "invalid organization", http.StatusBadRequest)
+		return
+	}
+	apiBaseURL := strings.TrimRight(strings.TrimSpace(r.FormValue("api_base_url")), "/")
+	if apiBaseURL != "" {
+		if u, err := url.Parse(apiBaseURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			http.Error(w, "api_base_url must be an http(s) URL", http.StatusBadRequest)
+			return
+		}
+	}
+
+	baseURL := s.publicBaseURL(r)
+	manifest, err := json.Marshal(vcs.GitHubAppManifest{
+		Name:               name,
+		URL:                baseURL,
+		RedirectURL:        baseURL + githubAppFlowPath + "/callback",
+		SetupURL:           baseURL + githubAppFlowPath + "/setup",
+		SetupOnUpdate:      true,
+		DefaultPermissions: s.githubAppPermissions(),
+	})
+	if err != nil {
+		http.Error(w, "failed to build manifest", http.StatusInternalServerError)
+		return
+	}
+
+	state := generateToken(24)
+	http.SetCookie(w, &http.Cookie{
+		Name:     githubAppStateCookie,
+		Value:    state + "." + base64.RawURLEncoding.EncodeToString([]byte(apiBaseURL)),
+		Path:     githubAppFlowPath,
+		MaxAge:   int(githubAppFlowTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	// The page posts a form to GitHub, which the default policy forbids.
+	webBaseURL := vcs.GitHubWebBaseURL(apiBaseURL)
+	w.Header().Set("Content-Security-Policy", strings.Replace(contentSecurityPolicy, "form-action 'self'", "form-action 'self' "+webBaseURL, 1))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := githubAppManifestPage.Execute(w, map[string]string{
+		"Action":   vcs.GitHubAppManifestURL(apiBaseURL, organization, state),
+		"Manifest": string(manifest),
+	}); err != nil {
+		log.Printf("template error: %v", err)
+	}
+}
+
+// handleGitHubAppCallback receives the manifest code once the app exists,
+// stores its credentials as an integration and sends the admin on to install
+// the app. The installation ID arrives later at handleGitHubAppSetup.
+func (s *Server) handleGitHubAppCallback(w http.ResponseWriter, r *http.Request) {
+	if s.intStore == nil {
+		http.Error(w, "dynamic integration management not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	state, apiBaseURL, ok := githubAppFlowState(r)
+	if !ok || subtle.ConstantTimeCompare([]byte(state), []byte(r.URL.Query().Get("state"))) != 1 {
+		http.Error(w, "GitHub App setup expired or was started in another browser; start again from Settings", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     githubAppStateCookie,
+		Path:     githubAppFlowPath,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Error(w, "missing code", http.StatusBadRequest)
+		return
+	}
+
+	creds, err := vcs.ConvertGitHubAppManifest(r.Context(), apiBaseURL, code)
+	if err != nil {
+		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusBadGateway)
+		return
+	}
+	id := newIntegrationID(creds.Slug)
+	keyPath, err := s.writeGitHubAppKey(id, creds.PEM)
+	if err != nil {
+		log.Printf("store github app key: %v", err)
+		http.Error(w, "failed to store the app's private key", http.StatusInternalServerError)
+		return
+	}
+	entry := &secrets.IntegrationEntry{
+		ID:   id,
+		Name: creds.Name,
+		Type: "github_app",
+		GitHubApp: &secrets.IntegrationGitHubApp{
+			AppID:          creds.ID,
+			PrivateKeyPath: keyPath,
+			APIBaseURL:     apiBaseURL,
+		},
+	}
+	if err := s.intStore.Add(entry); err != nil {
+		_ = os.Remove(keyPath)
+		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("created GitHub App %s (%d) for integration %s", creds.Slug, creds.ID, id)
+
+	installURL := strings.TrimRight(creds.HTMLURL, "/") + "/installations/new?state=" + url.QueryEscape(id)
+	http.Redirect(w, r, installURL, http.StatusSeeOther)
+}
+
+// handleGitHubAppSetup records the installation of an app created through
+// the manifest flow. GitHub passes the integration ID back as state; the
+// installation is checked with the app's own key before it is stored.
+func (s *Server) handleGitHubAppSetup(w http.ResponseWriter, r *http.Request) {
+	if s.intStore == nil {
+		http.Error(w, "dynamic integration management not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	query := r.URL.Query()
+	entry, err := s.intStore.Get(query.Get("state"))
+	if err != nil || entry.Type != "github_app" || entry.GitHubApp == nil {
+		http.Error(w, "integration not found", http.StatusNotFound)
+		return
+	}
+	// An installation that needs an organization owner's approval arrives
+	// without an ID; the integration stays pending until it is set.
+	if query.Get("installation_id") == "" {
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+	installationID, err := strconv.ParseInt(query.Get("installation_id"), 10, 64)
+	if err != nil || installationID <= 0 {
+		http.Error(w, "invalid installation_id", http.StatusBadRequest)
+		return
+	}
+
+	app := *entry.GitHubApp
+	token, err := gitauth.GitHubAppJWT(&config.GitHubAppConfig{
+		AppID:          app.AppID,
+		PrivateKeyPath: app.PrivateKeyPath,
+		PrivateKeyEnv:  app.PrivateKeyEnv,
+	})
+	if err != nil {
+		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	account, err := vcs.GitHubAppInstallationAccount(r.Context(), app.APIBaseURL, token, installationID)
+	if err != nil {
+		http.Error(w, "installation does not belong to this app: "+s.sanitizeErrorMessage(err.Error()), http.StatusBadRequest)
+		return
+	}
+	app.InstallationID = installationID
+	entry.GitHubApp = &app
+	if err := s.intStore.Update(entry.ID, entry); err != nil {
+		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("integration %s installed on %s (installation %d)", entry.ID, account, installationID)
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+// githubAppPermissions is the least the app needs: read access to code, plus
+// write access for the webhook features that are turned on.
+func (s *Server) githubAppPermissions() map[string]string {
+	perms := map[string]string{
+		"contents": "read",
+		"metadata": "read",
+	}
+	if s.cfg.Webhook.AutoRegister {
+		perms["repository_hooks"] = "write"
+	}
+	if s.cfg.Webhook.Deployments {
+		perms["deployments"] = "write"
+	}
+	return perms
+}
+
+// publicBaseURL is webhook.public_url, or the URL the request was made to.
+func (s *Server) publicBaseURL(r *http.Request) string {
+	if s.cfg.Webhook.PublicURL != "" {
+		return s.cfg.Webhook.PublicURL
+	}
+	scheme := "http"
+	if isHTTPSRequest(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func githubAppFlowState(r *http.Request) (state, apiBaseURL string, ok bool) {
+	cookie, err := r.Cookie(githubAppStateCookie)
+	if err != nil {
+		return "", "", false
+	}
+	state, encoded, found := strings.Cut(cookie.Value, ".")
+	if !found || state == "" {
+		return "", "", false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", false
+	}
+	return state, string(raw), true
+}
+
+// writeGitHubAppKey stores a private key returned by GitHub under data_dir
+// and returns its path.
+func (s *Server) writeGitHubAppKey(integrationID, pem string) (string, error) {
+	dir := filepath.Join(s.cfg.DataDir, githubAppKeysDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	keyPath := filepath.Join(dir, integrationID+".pem")
+	if err := os.WriteFile(keyPath, []byte(pem), 0600); err != nil {
+		return "", err
+	}
+	return keyPath, nil
+}
+
+// removeGitHubAppKey deletes the key of an app created through the manifest
+// flow. Keys the admin configured elsewhere are left alone.
+func (s *Server) removeGitHubAppKey(entry *secrets.IntegrationEntry) {
+	if entry.GitHubApp == nil || entry.GitHubApp.PrivateKeyPath == "" {
+		return
+	}
+	dir := filepath.Join(s.cfg.DataDir, githubAppKeysDir)
+	if filepath.Dir(entry.GitHubApp.PrivateKeyPath) != dir {
+		return
+	}
+	if err := os.Remove(entry.GitHubApp.PrivateKeyPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("remove github app key for integration %s: %v", entry.ID, err)
+	}
+}
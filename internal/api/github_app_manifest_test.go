@@ -0,0 +1,170 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/secrets"
+)
+
+func TestGitHubAppManifestFlow(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+
+	var github *httptest.Server
+	github = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v3/app-manifests/code-1/conversions":
+			json.NewEncoder(w).Encode(map[string]any{
+				"id":       int64(42),
+				"slug":     "driftd-test",
+				"name":     "driftd test",
+				"html_url": github.URL + "/apps/driftd-test",
+				"pem":      keyPEM,
+			})
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v3/app/installations/7":
+			if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"account": map[string]string{"login": "acme"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer github.Close()
+	apiBaseURL := github.URL + "/api/v3"
+
+	var intStore *secrets.IntegrationStore
+	_, ts, _, cleanup := newTestServerWithProjectStore(t, &fakeRunner{}, nil, false, func(_ *secrets.ProjectStore, store *secrets.IntegrationStore, _ string) {
+		intStore = store
+	}, func(cfg *config.Config) {
+		cfg.InsecureDevMode = true
+		cfg.Webhook.PublicURL = "https://driftd.example.com"
+		cfg.Webhook.AutoRegister = true
+	})
+	defer cleanup()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	form := url.Values{"name": {"driftd test"}, "organization": {"acme"}, "api_base_url": {apiBaseURL}}
+	resp, err := client.PostForm(ts.URL+githubAppFlowPath, form)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.StatusCode, body)
+	}
+	if csp := resp.Header.Get("Content-Security-Policy"); !strings.Contains(csp, "form-action 'self' "+github.URL) {
+		t.Fatalf("expected form-action to allow GitHub, got %q", csp)
+	}
+	page := string(body)
+	if !strings.Contains(page, github.URL+"/organizations/acme/settings/apps/new?state=") {
+		t.Fatalf("expected form to post to the organization's new app page:\n%s", page)
+	}
+	for _, want := range []string{"https://driftd.example.com/settings/integrations/github-app/callback", "repository_hooks", "contents"} {
+		if !strings.Contains(page, want) {
+			t.Fatalf("expected manifest to contain %q:\n%s", want, page)
+		}
+	}
+	var stateCookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == githubAppStateCookie {
+			stateCookie = c
+		}
+	}
+	if stateCookie == nil {
+		t.Fatal("expected state cookie")
+	}
+	state, _, _ := strings.Cut(stateCookie.Value, ".")
+
+	callback := func(query string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+githubAppFlowPath+"/callback?"+query, nil)
+		req.AddCookie(stateCookie)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("callback: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := callback("code=code-1&state=forged"); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected forged state to be rejected, got %d", resp.StatusCode)
+	}
+	resp = callback("code=code-1&state=" + url.QueryEscape(state))
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected redirect to install the app, got %d", resp.StatusCode)
+	}
+	entries := intStore.List()
+	if len(entries) != 1 {
+		t.Fatalf("expected one integration, got %d", len(entries))
+	}
+	entry := entries[0]
+	if entry.Type != "github_app" || entry.GitHubApp.AppID != 42 || entry.GitHubApp.InstallationID != 0 || entry.GitHubApp.APIBaseURL != apiBaseURL {
+		t.Fatalf("unexpected integration: %+v %+v", entry, entry.GitHubApp)
+	}
+	if want := github.URL + "/apps/driftd-test/installations/new?state=" + url.QueryEscape(entry.ID); resp.Header.Get("Location") != want {
+		t.Fatalf("expected redirect to %s, got %s", want, resp.Header.Get("Location"))
+	}
+	stored, err := os.ReadFile(entry.GitHubApp.PrivateKeyPath)
+	if err != nil || string(stored) != keyPEM {
+		t.Fatalf("expected private key to be stored: %v", err)
+	}
+	if info, _ := os.Stat(entry.GitHubApp.PrivateKeyPath); info.Mode().Perm() != 0600 {
+		t.Fatalf("expected key mode 0600, got %v", info.Mode().Perm())
+	}
+	if !integrationResponseFromEntry(entry).GitHubAppPending {
+		t.Fatal("expected integration to be pending installation")
+	}
+
+	setup := func(installationID int64) *http.Response {
+		t.Helper()
+		resp, err := client.Get(fmt.Sprintf("%s%s/setup?installation_id=%d&setup_action=install&state=%s", ts.URL, githubAppFlowPath, installationID, url.QueryEscape(entry.ID)))
+		if err != nil {
+			t.Fatalf("setup: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := setup(8); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected installation of another app to be rejected, got %d", resp.StatusCode)
+	}
+	if resp := setup(7); resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected redirect to settings, got %d", resp.StatusCode)
+	}
+	installed, err := intStore.Get(entry.ID)
+	if err != nil {
+		t.Fatalf("get integration: %v", err)
+	}
+	if installed.GitHubApp.InstallationID != 7 {
+		t.Fatalf("expected installation 7, got %d", installed.GitHubApp.InstallationID)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/api/settings/integrations/"+entry.ID, nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	resp.Body.Close()
+	if _, err := os.Stat(entry.GitHubApp.PrivateKeyPath); !os.IsNotExist(err) {
+		t.Fatalf("expected private key to be removed with the integration, got %v", err)
+	}
+}
@@ -6,16 +6,19 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/driftdhq/driftd/internal/config"
 	"github.com/driftdhq/driftd/internal/orchestrate"
 	"github.com/driftdhq/driftd/internal/pathutil"
 	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/stack"
 	"github.com/go-chi/chi/v5"
 )
 
+// handleHealth reports "degraded" with a 200 while the queue backend is
+// unreachable, so probes keep the server up to serve stored results.
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
-	if err := s.queue.Client().Ping(r.Context()).Err(); err != nil {
-		w.WriteHeader(http.StatusServiceUnavailable)
-		json.NewEncoder(w).Encode(map[string]string{"status": "unhealthy", "error": err.Error()})
+	if err := s.checkQueue(r.Context()); err != nil {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "degraded", "error": s.sanitizeErrorMessage(err.Error())})
 		return
 	}
 
@@ -37,8 +40,14 @@ func (s *Server) handleGetStackScan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	apiScan := toAPIStackScan(stackScan)
+	if projectCfg, err := s.getProjectConfig(stackScan.ProjectName); err == nil {
+		display := projectCfg.StackDisplay(stackScan.StackPath)
+		apiScan.DisplayName = display.Name
+		apiScan.Group = display.Group
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(toAPIStackScan(stackScan))
+	json.NewEncoder(w).Encode(apiScan)
 }
 
 func (s *Server) handleListProjectStackScans(w http.ResponseWriter, r *http.Request) {
@@ -48,6 +57,15 @@ func (s *Server) handleListProjectStackScans(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	tagFilters, err := parseTagFilters(r.URL.Query()["tag"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	env := r.URL.Query().Get("env")
+	group := r.URL.Query().Get("group")
+
 	stackScans, err := s.queue.ListProjectStackScans(r.Context(), projectName, 50)
 	if err != nil {
 		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
@@ -55,9 +73,27 @@ func (s *Server) handleListProjectStackScans(w http.ResponseWriter, r *http.Requ
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	tags := s.stackTags(projectName)
+	projectCfg, _ := s.getProjectConfig(projectName)
 	apiScans := make([]*apiStackScan, 0, len(stackScans))
 	for _, scan := range stackScans {
-		apiScans = append(apiScans, toAPIStackScan(scan))
+		if !stack.MatchTags(tags[scan.StackPath], tagFilters) {
+			continue
+		}
+		stackEnv := s.cfg.StackEnvironment(scan.StackPath)
+		if env != "" && stackEnv != env {
+			continue
+		}
+		display := projectCfg.StackDisplay(scan.StackPath)
+		if group != "" && display.Group != group {
+			continue
+		}
+		apiScan := toAPIStackScan(scan)
+		apiScan.Tags = tags[scan.StackPath]
+		apiScan.Environment = stackEnv
+		apiScan.DisplayName = display.Name
+		apiScan.Group = display.Group
+		apiScans = append(apiScans, apiScan)
 	}
 	json.NewEncoder(w).Encode(apiScans)
 }
@@ -83,6 +119,11 @@ type scanResponse struct {
 	ActiveScan *apiScan   `json:"active_scan,omitempty"`
 	Message    string     `json:"message,omitempty"`
 	Error      string     `json:"error,omitempty"`
+	// Acknowledged lists stacks a webhook acknowledged through driftd:resolve
+	// commit directives.
+	Acknowledged []string `json:"acknowledged,omitempty"`
+	// ResetAt is set on 429 responses: when the scan limit resets (RFC3339).
+	ResetAt string `json:"reset_at,omitempty"`
 }
 
 func (s *Server) handleScanProjectUI(w http.ResponseWriter, r *http.Request) {
@@ -101,6 +142,9 @@ func (s *Server) handleScanProjectUI(w http.ResponseWriter, r *http.Request) {
 	trigger := "manual"
 	_, enqResult, err := s.orchestrator.StartAndEnqueue(r.Context(), projectCfg, trigger, "", "")
 	if err != nil {
+		if writeScanLimitedText(w, err) {
+			return
+		}
 		if err == queue.ErrProjectLocked {
 			http.Redirect(w, r, "/projects/"+projectName, http.StatusSeeOther)
 			return
@@ -134,15 +178,28 @@ func (s *Server) handleScanRepo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	body, ok := s.readScanBody(w, r)
+	if !ok {
+		return
+	}
 	var req scanRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
+	idem, handled := s.claimIdempotencyKey(w, r, projectName, body)
+	if handled {
+		return
+	}
+	defer s.releaseIdempotencyKey(r.Context(), idem)
+
 	trigger := normalizeScanTrigger(req.Trigger)
 	scan, enqResult, err := s.orchestrator.StartAndEnqueue(r.Context(), projectCfg, trigger, req.Commit, req.Actor)
 	if err != nil {
+		if writeScanLimited(w, err) {
+			return
+		}
 		if err == queue.ErrProjectLocked {
 			activeScan, activeErr := s.queue.GetActiveScan(r.Context(), projectName)
 			if activeErr != nil {
@@ -177,10 +234,48 @@ func (s *Server) handleScanRepo(w http.ResponseWriter, r *http.Request) {
 	if len(enqResult.Errors) > 0 {
 		resp.Error = strings.Join(enqResult.Errors, "; ")
 	}
+	s.completeIdempotencyKey(r.Context(), idem, resp)
 
 	json.NewEncoder(w).Encode(resp)
 }
 
+func (s *Server) handleDiscoverProject(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		http.Error(w, "Invalid project name", http.StatusBadRequest)
+		return
+	}
+
+	tagFilters, err := parseTagFilters(r.URL.Query()["tag"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	projectCfg, err := s.getProjectConfig(projectName)
+	if err != nil || projectCfg == nil {
+		http.Error(w, "Project not configured", http.StatusNotFound)
+		return
+	}
+
+	result, err := s.orchestrator.Discover(r.Context(), projectCfg)
+	if err != nil {
+		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusUnprocessableEntity)
+		return
+	}
+	if len(tagFilters) > 0 {
+		matched := make([]string, 0, len(result.Stacks))
+		for _, stackPath := range result.Stacks {
+			if stack.MatchTags(result.Tags[stackPath], tagFilters) {
+				matched = append(matched, stackPath)
+			}
+		}
+		result.Stacks = matched
+	}
+
+	writeJSON(w, http.StatusOK, toAPIDiscovery(projectName, projectCfg.RootPath, projectCfg.IgnorePaths, result))
+}
+
 func (s *Server) handleScanStack(w http.ResponseWriter, r *http.Request) {
 	projectName := chi.URLParam(r, "project")
 	stackPath := chi.URLParam(r, "*")
@@ -196,15 +291,28 @@ func (s *Server) handleScanStack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	body, ok := s.readScanBody(w, r)
+	if !ok {
+		return
+	}
 	var req scanRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
+	idem, handled := s.claimIdempotencyKey(w, r, projectName, body)
+	if handled {
+		return
+	}
+	defer s.releaseIdempotencyKey(r.Context(), idem)
+
 	trigger := normalizeScanTrigger(req.Trigger)
 	scan, stacks, err := s.startScanWithCancel(r.Context(), projectCfg, trigger, req.Commit, req.Actor)
 	if err != nil {
+		if writeScanLimited(w, err) {
+			return
+		}
 		if err == queue.ErrProjectLocked {
 			activeScan, activeErr := s.queue.GetActiveScan(r.Context(), projectName)
 			if activeErr != nil {
@@ -240,11 +348,60 @@ func (s *Server) handleScanStack(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(scanResponse{
+	resp := scanResponse{
 		Stacks:  enqResult.StackIDs,
 		Scan:    toAPIScan(scan),
 		Message: "Stack enqueued",
-	})
+	}
+	s.completeIdempotencyKey(r.Context(), idem, resp)
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) handleStackBatch(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		http.Error(w, "Invalid project name", http.StatusBadRequest)
+		return
+	}
+
+	projectCfg, err := s.getProjectConfig(projectName)
+	if err != nil || projectCfg == nil {
+		http.Error(w, "Project not configured", http.StatusNotFound)
+		return
+	}
+
+	var req stackBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Action == batchActionScan && s.rejectDuringMaintenance(w) {
+		return
+	}
+	result, err := s.applyStackBatch(r.Context(), projectCfg, req)
+	if err != nil {
+		if result == nil {
+			result = &stackBatchResult{Action: req.Action}
+		}
+		result.Error = s.sanitizeErrorMessage(err.Error())
+		status := http.StatusInternalServerError
+		switch {
+		case isBatchClientError(err):
+			status = http.StatusBadRequest
+		case err == errNoKnownBatchStacks:
+			status = http.StatusUnprocessableEntity
+		case isBatchConflict(err):
+			status = http.StatusConflict
+		case scanLimitError(err) != nil:
+			setScanLimitRetryAfter(w, scanLimitError(err))
+			status = http.StatusTooManyRequests
+		}
+		writeJSON(w, status, result)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
 }
 
 func (s *Server) handleGetScan(w http.ResponseWriter, r *http.Request) {
@@ -292,20 +449,21 @@ func (s *Server) handleProjectEvents(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "event: snapshot\ndata: %s\n\n", payload)
 	flusher.Flush()
 
-	sub := s.queue.Client().Subscribe(r.Context(), "driftd:events:"+projectName)
-	defer sub.Close()
-
-	ch := sub.Channel()
+	events, err := s.queue.SubscribeProjectEvents(r.Context(), projectName)
+	if err != nil {
+		return
+	}
 	for {
 		select {
 		case <-r.Context().Done():
 			return
-		case msg, ok := <-ch:
+		case event, ok := <-events:
 			if !ok {
 				return
 			}
-			var event queue.ProjectEvent
-			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+			// Canary scans have no dashboard row; clients reload on
+			// unknown projects.
+			if event.ProjectName == config.CanaryProjectName {
 				continue
 			}
 			updatePayload, err := buildUpdatePayload(&event)
@@ -329,27 +487,25 @@ func (s *Server) handleGlobalEvents(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	events, err := s.queue.SubscribeProjectEvents(r.Context(), "")
+	if err != nil {
+		http.Error(w, "Failed to subscribe to events", http.StatusServiceUnavailable)
+		return
+	}
+
 	// Emit an initial SSE comment so headers are flushed and clients can
 	// establish the stream before the first project event is published.
 	fmt.Fprint(w, ": connected\n\n")
 	flusher.Flush()
 
-	sub := s.queue.Client().PSubscribe(r.Context(), "driftd:events:*")
-	defer sub.Close()
-
-	ch := sub.Channel()
 	for {
 		select {
 		case <-r.Context().Done():
 			return
-		case msg, ok := <-ch:
+		case event, ok := <-events:
 			if !ok {
 				return
 			}
-			var event queue.ProjectEvent
-			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
-				continue
-			}
 			updatePayload, err := buildUpdatePayload(&event)
 			if err != nil {
 				continue
@@ -6,10 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
+	"github.com/driftdhq/driftd/internal/config"
 	"github.com/driftdhq/driftd/internal/gitauth"
+	"github.com/driftdhq/driftd/internal/pathutil"
 	"github.com/driftdhq/driftd/internal/secrets"
 	"github.com/go-chi/chi/v5"
 	git "github.com/go-git/go-git/v5"
@@ -23,9 +26,16 @@ type ProjectRequest struct {
 	Name                       string   `json:"name"`
 	URL                        string   `json:"url"`
 	Branch                     *string  `json:"branch,omitempty"`
+	RootPath                   *string  `json:"root_path,omitempty"`
 	IgnorePaths                []string `json:"ignore_paths,omitempty"`
 	Schedule                   *string  `json:"schedule,omitempty"`
 	CancelInflightOnNewTrigger *bool    `json:"cancel_inflight_on_new_trigger,omitempty"`
+	// Chain replaces the project's chain links when set; [] clears them.
+	Chain []config.ChainTrigger `json:"chain,omitempty"`
+	// TerraformVersion and TerragruntVersion replace the project defaults
+	// when set; "" clears them.
+	TerraformVersion  *string `json:"terraform_version,omitempty"`
+	TerragruntVersion *string `json:"terragrunt_version,omitempty"`
 
 	AuthType      string  `json:"auth_type"` // "https", "ssh", "github_app"
 	IntegrationID *string `json:"integration_id,omitempty"`
@@ -43,12 +53,16 @@ type ProjectRequest struct {
 
 // ProjectResponse is the JSON response for a project.
 type ProjectResponse struct {
-	Name                       string   `json:"name"`
-	URL                        string   `json:"url"`
-	Branch                     string   `json:"branch,omitempty"`
-	IgnorePaths                []string `json:"ignore_paths,omitempty"`
-	Schedule                   string   `json:"schedule,omitempty"`
-	CancelInflightOnNewTrigger bool     `json:"cancel_inflight_on_new_trigger"`
+	Name                       string                `json:"name"`
+	URL                        string                `json:"url"`
+	Branch                     string                `json:"branch,omitempty"`
+	RootPath                   string                `json:"root_path,omitempty"`
+	IgnorePaths                []string              `json:"ignore_paths,omitempty"`
+	Schedule                   string                `json:"schedule,omitempty"`
+	CancelInflightOnNewTrigger bool                  `json:"cancel_inflight_on_new_trigger"`
+	Chain                      []config.ChainTrigger `json:"chain,omitempty"`
+	TerraformVersion           string                `json:"terraform_version,omitempty"`
+	TerragruntVersion          string                `json:"terragrunt_version,omitempty"`
 
 	AuthType             string `json:"auth_type"`
 	GitHubAppID          int64  `json:"github_app_id,omitempty"`
@@ -60,6 +74,13 @@ type ProjectResponse struct {
 	Source    string `json:"source"` // "config" or "dynamic"
 	CreatedAt string `json:"created_at,omitempty"`
 	UpdatedAt string `json:"updated_at,omitempty"`
+	// Revision is the ETag of a dynamic project; send it back in If-Match
+	// when updating.
+	Revision int64 `json:"revision,omitempty"`
+
+	// CredentialPresence shows what is configured; secrets are never
+	// returned.
+	CredentialPresence
 }
 
 // IntegrationRequest is the JSON request body for creating/updating an integration.
@@ -81,6 +102,10 @@ type IntegrationRequest struct {
 
 	HTTPSUsername string `json:"https_username,omitempty"`
 	HTTPSTokenEnv string `json:"https_token_env,omitempty"`
+
+	ProxyURL         string `json:"proxy_url,omitempty"`
+	ProxyPasswordEnv string `json:"proxy_password_env,omitempty"`
+	CABundlePath     string `json:"ca_bundle_path,omitempty"`
 }
 
 // IntegrationResponse is the JSON response for an integration.
@@ -94,6 +119,9 @@ type IntegrationResponse struct {
 	GitHubPrivateKeyPath string `json:"github_private_key_path,omitempty"`
 	GitHubPrivateKeyEnv  string `json:"github_private_key_env,omitempty"`
 	GitHubAPIBaseURL     string `json:"github_api_base_url,omitempty"`
+	// GitHubAppPending is set for an app created through the manifest flow
+	// that has not been installed yet.
+	GitHubAppPending bool `json:"github_app_pending,omitempty"`
 
 	SSHKeyPath               string `json:"ssh_key_path,omitempty"`
 	SSHKeyEnv                string `json:"ssh_key_env,omitempty"`
@@ -104,9 +132,18 @@ type IntegrationResponse struct {
 	HTTPSUsername string `json:"https_username,omitempty"`
 	HTTPSTokenEnv string `json:"https_token_env,omitempty"`
 
+	ProxyURL         string `json:"proxy_url,omitempty"`
+	ProxyPasswordEnv string `json:"proxy_password_env,omitempty"`
+	CABundlePath     string `json:"ca_bundle_path,omitempty"`
+
 	Source    string `json:"source"`
 	CreatedAt string `json:"created_at,omitempty"`
 	UpdatedAt string `json:"updated_at,omitempty"`
+	Revision  int64  `json:"revision,omitempty"`
+
+	// CredentialPresence shows what is configured; secrets are never
+	// returned.
+	CredentialPresence
 }
 
 // handleListSettingsRepos returns all configured repositories.
@@ -118,9 +155,13 @@ func (s *Server) handleListSettingsRepos(w http.ResponseWriter, r *http.Request)
 			Name:                       project.Name,
 			URL:                        project.URL,
 			Branch:                     project.Branch,
+			RootPath:                   project.RootPath,
 			IgnorePaths:                project.IgnorePaths,
 			Schedule:                   project.Schedule,
 			CancelInflightOnNewTrigger: project.CancelInflightEnabled(),
+			Chain:                      project.Chain,
+			TerraformVersion:           project.TerraformVersion,
+			TerragruntVersion:          project.TerragruntVersion,
 			Source:                     "config",
 		}
 		if project.Git != nil {
@@ -131,6 +172,7 @@ func (s *Server) handleListSettingsRepos(w http.ResponseWriter, r *http.Request)
 			}
 			resp.IntegrationType = project.Git.Type
 		}
+		resp.CredentialPresence = s.projectCredentialPresence(project.Name)
 		projects = append(projects, resp)
 	}
 
@@ -145,14 +187,19 @@ func (s *Server) handleListSettingsRepos(w http.ResponseWriter, r *http.Request)
 				Name:                       project.Name,
 				URL:                        project.URL,
 				Branch:                     project.Branch,
+				RootPath:                   project.RootPath,
 				IgnorePaths:                project.IgnorePaths,
 				Schedule:                   project.Schedule,
 				CancelInflightOnNewTrigger: project.CancelInflightOnNewTrigger,
+				Chain:                      project.Chain,
+				TerraformVersion:           project.TerraformVersion,
+				TerragruntVersion:          project.TerragruntVersion,
 				AuthType:                   project.Git.Type,
 				IntegrationID:              project.IntegrationID,
 				Source:                     "dynamic",
 				CreatedAt:                  project.CreatedAt.Format("2006-01-02T15:04:05Z"),
 				UpdatedAt:                  project.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+				Revision:                   project.Revision,
 			}
 			if project.Git.GitHubApp != nil {
 				resp.GitHubAppID = project.Git.GitHubApp.AppID
@@ -166,6 +213,7 @@ func (s *Server) handleListSettingsRepos(w http.ResponseWriter, r *http.Request)
 			} else if project.Git.Type != "" {
 				resp.IntegrationType = project.Git.Type
 			}
+			resp.CredentialPresence = s.projectCredentialPresence(project.Name)
 			projects = append(projects, resp)
 		}
 	}
@@ -182,9 +230,13 @@ func (s *Server) handleGetSettingsRepo(w http.ResponseWriter, r *http.Request) {
 			Name:                       project.Name,
 			URL:                        project.URL,
 			Branch:                     project.Branch,
+			RootPath:                   project.RootPath,
 			IgnorePaths:                project.IgnorePaths,
 			Schedule:                   project.Schedule,
 			CancelInflightOnNewTrigger: project.CancelInflightEnabled(),
+			Chain:                      project.Chain,
+			TerraformVersion:           project.TerraformVersion,
+			TerragruntVersion:          project.TerragruntVersion,
 			Source:                     "config",
 		}
 		if project.Git != nil {
@@ -195,6 +247,7 @@ func (s *Server) handleGetSettingsRepo(w http.ResponseWriter, r *http.Request) {
 			}
 			resp.IntegrationType = project.Git.Type
 		}
+		resp.CredentialPresence = s.projectCredentialPresence(project.Name)
 		writeJSON(w, http.StatusOK, resp)
 		return
 	}
@@ -206,14 +259,19 @@ func (s *Server) handleGetSettingsRepo(w http.ResponseWriter, r *http.Request) {
 				Name:                       project.Name,
 				URL:                        project.URL,
 				Branch:                     project.Branch,
+				RootPath:                   project.RootPath,
 				IgnorePaths:                project.IgnorePaths,
 				Schedule:                   project.Schedule,
 				CancelInflightOnNewTrigger: project.CancelInflightOnNewTrigger,
+				Chain:                      project.Chain,
+				TerraformVersion:           project.TerraformVersion,
+				TerragruntVersion:          project.TerragruntVersion,
 				AuthType:                   project.Git.Type,
 				IntegrationID:              project.IntegrationID,
 				Source:                     "dynamic",
 				CreatedAt:                  project.CreatedAt.Format("2006-01-02T15:04:05Z"),
 				UpdatedAt:                  project.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+				Revision:                   project.Revision,
 			}
 			if project.Git.GitHubApp != nil {
 				resp.GitHubAppID = project.Git.GitHubApp.AppID
@@ -227,6 +285,8 @@ func (s *Server) handleGetSettingsRepo(w http.ResponseWriter, r *http.Request) {
 			} else if project.Git.Type != "" {
 				resp.IntegrationType = project.Git.Type
 			}
+			resp.CredentialPresence = s.projectCredentialPresence(project.Name)
+			w.Header().Set("ETag", revisionETag(project.Revision))
 			writeJSON(w, http.StatusOK, resp)
 			return
 		}
@@ -277,16 +337,33 @@ func (s *Server) handleCreateSettingsRepo(w http.ResponseWriter, r *http.Request
 		})
 		return
 	}
+	if req.Name == config.CanaryProjectName {
+		writeJSON(w, http.StatusConflict, map[string]string{
+			"error": "project name is reserved for the canary",
+		})
+		return
+	}
 
 	entry := &secrets.ProjectEntry{
 		Name:                       req.Name,
 		URL:                        req.URL,
 		Branch:                     derefString(req.Branch),
+		RootPath:                   strings.Trim(strings.TrimSpace(derefString(req.RootPath)), "/"),
 		IgnorePaths:                req.IgnorePaths,
 		Schedule:                   derefString(req.Schedule),
 		CancelInflightOnNewTrigger: derefBool(req.CancelInflightOnNewTrigger, true),
 		Git:                        secrets.ProjectGitConfig{},
 	}
+	if !pathutil.IsSafeStackPath(entry.RootPath) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "root_path must be a relative path inside the repository"})
+		return
+	}
+	if !s.setProjectChain(w, entry, req.Chain) {
+		return
+	}
+	if !setProjectToolVersions(w, entry, req) {
+		return
+	}
 
 	var creds *secrets.ProjectCredentials
 
@@ -365,7 +442,7 @@ func (s *Server) handleCreateSettingsRepo(w http.ResponseWriter, r *http.Request
 		s.onProjectAdded(req.Name, entry.Schedule)
 	}
 
-	writeJSON(w, http.StatusCreated, map[string]string{"status": "created"})
+	writeJSON(w, http.StatusCreated, s.withWebhookRegistration(r.Context(), entry.Name, map[string]string{"status": "created"}))
 }
 
 // handleUpdateSettingsRepo updates an existing project configuration.
@@ -395,6 +472,10 @@ func (s *Server) handleUpdateSettingsRepo(w http.ResponseWriter, r *http.Request
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	revision, ok := ifMatchRevision(w, r, existing.Revision)
+	if !ok {
+		return
+	}
 
 	var req ProjectRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -421,15 +502,27 @@ func (s *Server) handleUpdateSettingsRepo(w http.ResponseWriter, r *http.Request
 		Name:                       existing.Name,
 		URL:                        req.URL,
 		Branch:                     existing.Branch,
+		RootPath:                   existing.RootPath,
 		IgnorePaths:                existing.IgnorePaths,
 		Schedule:                   existing.Schedule,
 		CancelInflightOnNewTrigger: existing.CancelInflightOnNewTrigger,
+		Chain:                      existing.Chain,
+		TerraformVersion:           existing.TerraformVersion,
+		TerragruntVersion:          existing.TerragruntVersion,
 		IntegrationID:              integrationID,
 		Git:                        secrets.ProjectGitConfig{Type: req.AuthType},
+		Revision:                   revision,
 	}
 	if req.Branch != nil {
 		entry.Branch = *req.Branch
 	}
+	if req.RootPath != nil {
+		entry.RootPath = strings.Trim(strings.TrimSpace(*req.RootPath), "/")
+	}
+	if !pathutil.IsSafeStackPath(entry.RootPath) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "root_path must be a relative path inside the repository"})
+		return
+	}
 	if req.IgnorePaths != nil {
 		entry.IgnorePaths = req.IgnorePaths
 	}
@@ -439,6 +532,12 @@ func (s *Server) handleUpdateSettingsRepo(w http.ResponseWriter, r *http.Request
 	if req.CancelInflightOnNewTrigger != nil {
 		entry.CancelInflightOnNewTrigger = *req.CancelInflightOnNewTrigger
 	}
+	if req.Chain != nil && !s.setProjectChain(w, entry, req.Chain) {
+		return
+	}
+	if !setProjectToolVersions(w, entry, req) {
+		return
+	}
 
 	authChanged := req.AuthType != "" && req.AuthType != existing.Git.Type
 	integrationChanged := integrationID != existing.IntegrationID
@@ -505,6 +604,12 @@ func (s *Server) handleUpdateSettingsRepo(w http.ResponseWriter, r *http.Request
 	}
 
 	if err := s.projectStore.Update(projectName, entry, creds); err != nil {
+		if errors.Is(err, secrets.ErrRevisionConflict) {
+			if current, err := s.projectStore.Get(projectName); err == nil {
+				writeRevisionConflict(w, current.Revision)
+				return
+			}
+		}
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
@@ -513,7 +618,12 @@ func (s *Server) handleUpdateSettingsRepo(w http.ResponseWriter, r *http.Request
 		s.onProjectUpdated(entry.Name, entry.Schedule)
 	}
 
-	writeJSON(w, http.StatusOK, map[string]string{"status": "updated"})
+	resp := map[string]string{"status": "updated"}
+	if entry.URL != existing.URL || authChanged || integrationChanged {
+		resp = s.withWebhookRegistration(r.Context(), entry.Name, resp)
+	}
+	w.Header().Set("ETag", revisionETag(entry.Revision))
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // handleDeleteSettingsRepo deletes a project configuration.
@@ -575,7 +685,7 @@ func (s *Server) handleTestProjectConnection(w http.ResponseWriter, r *http.Requ
 	testCtx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
 	defer cancel()
 
-	auth, err := gitauth.AuthMethod(testCtx, projectCfg)
+	conn, err := gitauth.Connect(testCtx, projectCfg)
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
 		return
@@ -591,7 +701,11 @@ func (s *Server) handleTestProjectConnection(w http.ResponseWriter, r *http.Requ
 		Name: "origin",
 		URLs: []string{cloneURL},
 	})
-	refs, err := remote.ListContext(testCtx, &git.ListOptions{Auth: auth})
+	refs, err := remote.ListContext(testCtx, &git.ListOptions{
+		Auth:         conn.Auth,
+		CABundle:     conn.CABundle,
+		ProxyOptions: conn.Proxy,
+	})
 	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
 		return
@@ -658,6 +772,7 @@ func (s *Server) handleGetSettingsIntegration(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	w.Header().Set("ETag", revisionETag(entry.Revision))
 	writeJSON(w, http.StatusOK, integrationResponseFromEntry(entry))
 }
 
@@ -723,6 +838,10 @@ func (s *Server) handleUpdateSettingsIntegration(w http.ResponseWriter, r *http.
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	revision, ok := ifMatchRevision(w, r, existing.Revision)
+	if !ok {
+		return
+	}
 
 	var req IntegrationRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -743,11 +862,19 @@ func (s *Server) handleUpdateSettingsIntegration(w http.ResponseWriter, r *http.
 		return
 	}
 
+	entry.Revision = revision
 	if err := s.intStore.Update(id, entry); err != nil {
+		if errors.Is(err, secrets.ErrRevisionConflict) {
+			if current, err := s.intStore.Get(id); err == nil {
+				writeRevisionConflict(w, current.Revision)
+				return
+			}
+		}
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
 
+	w.Header().Set("ETag", revisionETag(entry.Revision))
 	writeJSON(w, http.StatusOK, integrationResponseFromEntry(entry))
 }
 
@@ -772,7 +899,11 @@ func (s *Server) handleDeleteSettingsIntegration(w http.ResponseWriter, r *http.
 		}
 	}
 
-	if err := s.intStore.Delete(id); err != nil {
+	entry, err := s.intStore.Get(id)
+	if err == nil {
+		err = s.intStore.Delete(id)
+	}
+	if err != nil {
 		if errors.Is(err, secrets.ErrIntegrationNotFound) {
 			writeJSON(w, http.StatusNotFound, map[string]string{"error": "integration not found"})
 			return
@@ -780,6 +911,7 @@ func (s *Server) handleDeleteSettingsIntegration(w http.ResponseWriter, r *http.
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	s.removeGitHubAppKey(entry)
 
 	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
@@ -790,6 +922,51 @@ func writeJSON(w http.ResponseWriter, status int, v interface{}) {
 	json.NewEncoder(w).Encode(v)
 }
 
+// setProjectToolVersions validates and stores the default terraform and
+// terragrunt versions set in req. Fields left out of req are unchanged.
+func setProjectToolVersions(w http.ResponseWriter, entry *secrets.ProjectEntry, req ProjectRequest) bool {
+	if req.TerraformVersion != nil {
+		v := strings.TrimSpace(*req.TerraformVersion)
+		if err := config.ValidateToolVersion(v); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "terraform_version: " + err.Error()})
+			return false
+		}
+		entry.TerraformVersion = v
+	}
+	if req.TerragruntVersion != nil {
+		v := strings.TrimSpace(*req.TerragruntVersion)
+		if err := config.ValidateToolVersion(v); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "terragrunt_version: " + err.Error()})
+			return false
+		}
+		entry.TerragruntVersion = v
+	}
+	return true
+}
+
+// setProjectChain validates chain and stores it on entry. Links that would
+// close a loop with the chains of other projects are rejected.
+func (s *Server) setProjectChain(w http.ResponseWriter, entry *secrets.ProjectEntry, chain []config.ChainTrigger) bool {
+	normalized, err := config.NormalizeChain(entry.Name, chain)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return false
+	}
+	chains := map[string][]config.ChainTrigger{}
+	for _, project := range s.listConfiguredRepos() {
+		chains[project.Name] = project.Chain
+	}
+	chains[entry.Name] = normalized
+	if cycle := config.FindChainCycle(chains); cycle != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error": "chain would loop: " + strings.Join(cycle, " -> "),
+		})
+		return false
+	}
+	entry.Chain = normalized
+	return true
+}
+
 func derefString(v *string) string {
 	if v == nil {
 		return ""
@@ -841,9 +1018,24 @@ func newIntegrationID(name string) string {
 
 func integrationEntryFromRequest(id string, req IntegrationRequest) (*secrets.IntegrationEntry, error) {
 	entry := &secrets.IntegrationEntry{
-		ID:   id,
-		Name: req.Name,
-		Type: req.Type,
+		ID:               id,
+		Name:             req.Name,
+		Type:             req.Type,
+		ProxyURL:         strings.TrimSpace(req.ProxyURL),
+		ProxyPasswordEnv: req.ProxyPasswordEnv,
+		CABundlePath:     strings.TrimSpace(req.CABundlePath),
+	}
+	if entry.ProxyURL != "" {
+		if err := config.ValidateProxyURL(entry.ProxyURL); err != nil {
+			return nil, err
+		}
+		// Integrations are returned by the API, so the proxy password has to
+		// come from the environment rather than the stored URL.
+		if u, _ := url.Parse(entry.ProxyURL); u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				return nil, fmt.Errorf("proxy_url must not contain a password; use proxy_password_env")
+			}
+		}
 	}
 
 	switch req.Type {
@@ -892,12 +1084,16 @@ func integrationEntryFromRequest(id string, req IntegrationRequest) (*secrets.In
 
 func integrationResponseFromEntry(entry *secrets.IntegrationEntry) IntegrationResponse {
 	resp := IntegrationResponse{
-		ID:        entry.ID,
-		Name:      entry.Name,
-		Type:      entry.Type,
-		Source:    "dynamic",
-		CreatedAt: entry.CreatedAt.Format("2006-01-02T15:04:05Z"),
-		UpdatedAt: entry.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+		ID:               entry.ID,
+		Name:             entry.Name,
+		Type:             entry.Type,
+		ProxyURL:         entry.ProxyURL,
+		ProxyPasswordEnv: entry.ProxyPasswordEnv,
+		CABundlePath:     entry.CABundlePath,
+		Source:           "dynamic",
+		CreatedAt:        entry.CreatedAt.Format("2006-01-02T15:04:05Z"),
+		UpdatedAt:        entry.UpdatedAt.Format("2006-01-02T15:04:05Z"),
+		Revision:         entry.Revision,
 	}
 	if entry.GitHubApp != nil {
 		resp.GitHubAppID = entry.GitHubApp.AppID
@@ -905,6 +1101,7 @@ func integrationResponseFromEntry(entry *secrets.IntegrationEntry) IntegrationRe
 		resp.GitHubPrivateKeyPath = entry.GitHubApp.PrivateKeyPath
 		resp.GitHubPrivateKeyEnv = entry.GitHubApp.PrivateKeyEnv
 		resp.GitHubAPIBaseURL = entry.GitHubApp.APIBaseURL
+		resp.GitHubAppPending = entry.GitHubApp.InstallationID == 0
 	}
 	if entry.SSH != nil {
 		resp.SSHKeyPath = entry.SSH.KeyPath
@@ -917,5 +1114,6 @@ func integrationResponseFromEntry(entry *secrets.IntegrationEntry) IntegrationRe
 		resp.HTTPSUsername = entry.HTTPS.Username
 		resp.HTTPSTokenEnv = entry.HTTPS.TokenEnv
 	}
+	resp.CredentialPresence = credentialPresence(integrationEntryCredentials(entry))
 	return resp
 }
@@ -1,6 +1,8 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
@@ -20,6 +22,7 @@ import (
 )
 
 type indexData struct {
+	pageAuth
 	Projects      []projectStatusData
 	ConfigRepos   []config.ProjectConfig
 	ProjectByName map[string]projectStatusData
@@ -30,6 +33,7 @@ type indexData struct {
 	DriftedStacks int
 	ErrorStacks   int
 	ActiveScans   int
+	Environments  []environmentSummary
 }
 
 type projectStatusData struct {
@@ -42,21 +46,42 @@ type projectStatusData struct {
 	Locked        bool
 	LastRun       time.Time
 	CommitSHA     string
+	CommitInfo    *storage.CommitInfo
 	Active        bool
 	Progress      string
+	// Severity is the sum of the project's stack severity scores.
+	Severity int
 }
 
 type projectPageData struct {
+	pageAuth
 	Name       string
 	Stacks     []storage.StackStatus
 	Config     *config.ProjectConfig
 	Locked     bool
 	ActiveScan *queue.Scan
 	LastScan   *queue.Scan
-	CSRFToken  string
 	Pagination projectPagination
 	Sort       string
 	Order      string
+	TagFilters []string
+	Branches   []branchOption
+	// Environment is the active environment filter. Environments counts
+	// the project's stacks per environment and StackEnvironments maps each
+	// stack path to its environment.
+	Environment       string
+	Environments      []environmentSummary
+	StackEnvironments map[string]string
+	// Group is the active stack group filter and StackDisplays maps stack
+	// paths to the display names and groups of the project's stack_names.
+	Group         string
+	StackDisplays map[string]config.StackDisplay
+	// Metadata is the operator-maintained description, owner and links.
+	Metadata *storage.ProjectMetadata
+	// Pause is set while the project's stack scans are paused.
+	Pause *queue.ProjectPause
+	// Batch reports what the last UI batch action skipped or failed.
+	Batch *batchNotice
 }
 
 type projectPagination struct {
@@ -68,68 +93,50 @@ type projectPagination struct {
 	NextURL    string
 }
 
+// RowIndex is the aria-rowindex of the i-th stack on the page. Row 1 is the
+// header, so screen readers announce positions across the whole project.
+func (p projectPagination) RowIndex(i int) int {
+	return (p.Page-1)*p.PerPage + i + 2
+}
+
 type stackPageData struct {
+	pageAuth
 	ProjectName string
 	ProjectURL  string
 	Path        string
+	Display     config.StackDisplay
 	Result      *storage.RunResult
 	Scan        *queue.Scan
-	CSRFToken   string
 	PlanHTML    template.HTML
+	// PlanTailHTML is set when the plan was truncated for display.
+	PlanTailHTML     template.HTML
+	PlanTruncated    bool
+	PlanOmittedBytes int
+	// CommitSHA is the commit the shown result was planned at.
+	CommitSHA  string
+	CommitInfo *storage.CommitInfo
+	// PinnedScanID is set when the page shows the result of a past scan
+	// instead of the latest one.
+	PinnedScanID string
+	// Runs are recent runs that can be viewed as of their scan.
+	Runs []storage.HistoryEntry
+	// ConsoleLinks and ResourceLinks are the project's console links
+	// resolved for this stack.
+	ConsoleLinks  []consoleLink
+	ResourceLinks []resourceConsoleLinks
 }
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	projects, _ := s.storage.ListRepos()
 
 	var projectData []projectStatusData
+	stacksByProject := map[string][]storage.StackStatus{}
 	for _, project := range projects {
-		locked, _ := s.queue.IsProjectLocked(r.Context(), project.Name)
-		errorStacks := 0
-		if stacks, err := s.storage.ListStacks(project.Name); err == nil {
-			for _, stack := range stacks {
-				if stack.Error != "" {
-					errorStacks++
-				}
-			}
-		}
-		var lastScan *queue.Scan
-		if activeScan, err := s.queue.GetActiveScan(r.Context(), project.Name); err == nil {
-			lastScan = activeScan
-		} else if lastScanFound, err := s.queue.GetLastScan(r.Context(), project.Name); err == nil {
-			lastScan = lastScanFound
-		}
-
-		var progress string
-		var active bool
-		var lastRun time.Time
-		var commit string
-		if lastScan != nil {
-			commit = lastScan.CommitSHA
-			if lastScan.Status == queue.ScanStatusRunning {
-				active = true
-				progress = fmt.Sprintf("%d/%d", lastScan.Completed+lastScan.Failed, lastScan.Total)
-				lastRun = lastScan.StartedAt
-			} else {
-				lastRun = lastScan.EndedAt
-			}
-		}
-		healthyStacks := project.Stacks - project.DriftedStacks - errorStacks
-		if healthyStacks < 0 {
-			healthyStacks = 0
+		status, stacks := s.projectStatus(r.Context(), project)
+		if stacks != nil {
+			stacksByProject[project.Name] = stacks
 		}
-		projectData = append(projectData, projectStatusData{
-			Name:          project.Name,
-			Drifted:       project.Drifted,
-			Stacks:        project.Stacks,
-			DriftedStacks: project.DriftedStacks,
-			ErrorStacks:   errorStacks,
-			HealthyStacks: healthyStacks,
-			Locked:        locked,
-			LastRun:       lastRun,
-			CommitSHA:     commit,
-			Active:        active,
-			Progress:      progress,
-		})
+		projectData = append(projectData, status)
 	}
 
 	totalStacks := 0
@@ -155,6 +162,7 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 
 	configRepos := s.listConfiguredRepos()
 	data := indexData{
+		pageAuth:      s.pageAuth(r),
 		Projects:      projectData,
 		ConfigRepos:   configRepos,
 		ProjectByName: map[string]projectStatusData{},
@@ -169,6 +177,16 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	for _, project := range projectData {
 		data.ProjectByName[project.Name] = project
 	}
+	// Most severe drift first, then by name.
+	sort.SliceStable(data.ConfigRepos, func(i, j int) bool {
+		return data.ProjectByName[data.ConfigRepos[i].Name].Severity > data.ProjectByName[data.ConfigRepos[j].Name].Severity
+	})
+	if s.environmentsEnabled() {
+		for name, stacks := range stacksByProject {
+			stacksByProject[name] = filterParentStackStatuses(stacks)
+		}
+		data.Environments = s.summarizeEnvironments(stacksByProject)
+	}
 	for _, project := range configRepos {
 		data.ConfigByName[project.Name] = project
 	}
@@ -178,6 +196,77 @@ func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// projectStatus summarizes a project for the dashboard. The stacks are
+// returned as listed from storage, or nil when they could not be read.
+func (s *Server) projectStatus(ctx context.Context, project storage.ProjectStatus) (projectStatusData, []storage.StackStatus) {
+	// While read-only every queue lookup would only wait for the backend to
+	// time out, so the card shows what storage has.
+	queueUp := !s.degradedStatus().Degraded
+	var locked bool
+	if queueUp {
+		locked, _ = s.queue.IsProjectLocked(ctx, project.Name)
+	}
+	errorStacks := 0
+	projectSeverity := 0
+	stacks, err := s.storage.ListStacks(project.Name)
+	if err == nil {
+		for _, stack := range stacks {
+			if stack.Error != "" {
+				errorStacks++
+			}
+		}
+		for _, stack := range filterParentStackStatuses(stacks) {
+			projectSeverity += s.severity.Score(stack)
+		}
+	} else {
+		stacks = nil
+	}
+	var lastScan *queue.Scan
+	if queueUp {
+		if activeScan, err := s.queue.GetActiveScan(ctx, project.Name); err == nil {
+			lastScan = activeScan
+		} else if lastScanFound, err := s.queue.GetLastScan(ctx, project.Name); err == nil {
+			lastScan = lastScanFound
+		}
+	}
+
+	var progress string
+	var active bool
+	var lastRun time.Time
+	var commit string
+	var commitInfo *storage.CommitInfo
+	if lastScan != nil {
+		commit = lastScan.CommitSHA
+		commitInfo = lastScan.CommitInfo
+		if lastScan.Status == queue.ScanStatusRunning {
+			active = true
+			progress = fmt.Sprintf("%d/%d", lastScan.Completed+lastScan.Failed, lastScan.Total)
+			lastRun = lastScan.StartedAt
+		} else {
+			lastRun = lastScan.EndedAt
+		}
+	}
+	healthyStacks := project.Stacks - project.DriftedStacks - errorStacks
+	if healthyStacks < 0 {
+		healthyStacks = 0
+	}
+	return projectStatusData{
+		Name:          project.Name,
+		Drifted:       project.Drifted,
+		Stacks:        project.Stacks,
+		DriftedStacks: project.DriftedStacks,
+		ErrorStacks:   errorStacks,
+		HealthyStacks: healthyStacks,
+		Locked:        locked,
+		LastRun:       lastRun,
+		CommitSHA:     commit,
+		CommitInfo:    commitInfo,
+		Active:        active,
+		Progress:      progress,
+		Severity:      projectSeverity,
+	}, stacks
+}
+
 func (s *Server) handleRepo(w http.ResponseWriter, r *http.Request) {
 	projectName := chi.URLParam(r, "project")
 	if !isValidProjectName(projectName) {
@@ -185,28 +274,70 @@ func (s *Server) handleRepo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tagFilters, err := parseTagFilters(r.URL.Query()["tag"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	env := r.URL.Query().Get("env")
+	group := r.URL.Query().Get("group")
+	projectCfg, _ := s.getProjectConfig(projectName)
+
 	stacks, _ := s.storage.ListStacks(projectName)
 	stacks = filterParentStackStatuses(stacks)
+	stacks = filterStacksByTags(stacks, tagFilters)
+	var environments []environmentSummary
+	if s.environmentsEnabled() {
+		environments = s.summarizeEnvironments(map[string][]storage.StackStatus{projectName: stacks})
+	}
+	stacks = s.filterStacksByEnvironment(stacks, env)
+	stacks = filterStacksByGroup(projectCfg, stacks, group)
+	s.severity.Apply(stacks)
 	page, perPage, sortBy, sortOrder := parseProjectListParams(r)
-	stacks = sortStacks(stacks, sortBy, sortOrder)
-	pageStacks, pagination := paginateStacks(stacks, page, perPage, "/projects/"+projectName, sortBy, sortOrder)
-	csrfToken := csrfTokenFromContext(r.Context())
-	projectCfg, _ := s.getProjectConfig(projectName)
-	locked, _ := s.queue.IsProjectLocked(r.Context(), projectName)
-	activeScan, _ := s.queue.GetActiveScan(r.Context(), projectName)
-	lastScan, _ := s.queue.GetLastScan(r.Context(), projectName)
+	stacks = sortStacks(stacks, sortBy, sortOrder, s.cfg.StackEnvironment)
+	tags := tagFilterValues(tagFilters)
+	filters := url.Values{"tag": tags}
+	if env != "" {
+		filters.Set("env", env)
+	}
+	if group != "" {
+		filters.Set("group", group)
+	}
+	pageStacks, pagination := paginateStacks(stacks, page, perPage, "/projects/"+projectName, sortBy, sortOrder, filters)
+	var locked bool
+	var activeScan, lastScan *queue.Scan
+	var pause *queue.ProjectPause
+	if !s.degradedStatus().Degraded {
+		locked, _ = s.queue.IsProjectLocked(r.Context(), projectName)
+		activeScan, _ = s.queue.GetActiveScan(r.Context(), projectName)
+		lastScan, _ = s.queue.GetLastScan(r.Context(), projectName)
+		pause, _ = s.queue.GetProjectPause(r.Context(), projectName)
+	}
+	metadata, _ := s.storage.GetProjectMetadata(projectName)
 
 	data := projectPageData{
+		pageAuth:   s.pageAuth(r),
 		Name:       projectName,
 		Stacks:     pageStacks,
 		Config:     projectCfg,
 		Locked:     locked,
 		ActiveScan: activeScan,
 		LastScan:   lastScan,
-		CSRFToken:  csrfToken,
 		Pagination: pagination,
 		Sort:       sortBy,
 		Order:      sortOrder,
+		TagFilters: tags,
+		Branches:   s.branchOptions(projectCfg),
+
+		Environment:       env,
+		Environments:      environments,
+		StackEnvironments: s.stackEnvironments(pageStacks),
+		Group:             group,
+		StackDisplays:     stackDisplays(projectCfg, pageStacks),
+		Metadata:          metadata,
+		Pause:             pause,
+		Batch:             batchNoticeFromQuery(r.URL.Query()),
 	}
 
 	if err := s.tmplRepo.ExecuteTemplate(w, "layout", data); err != nil {
@@ -219,13 +350,10 @@ func parseProjectListParams(r *http.Request) (page, perPage int, sortBy, sortOrd
 	page = clampInt(parseInt(q.Get("page"), 1), 1, 10_000)
 	perPage = clampInt(parseInt(q.Get("per"), 50), 10, 200)
 	sortBy = q.Get("sort")
-	if sortBy == "" {
-		sortBy = "path"
-	}
 	switch sortBy {
-	case "path", "status", "last_run":
+	case "severity", "path", "status", "last_run", "environment":
 	default:
-		sortBy = "path"
+		sortBy = "severity"
 	}
 	sortOrder = strings.ToLower(q.Get("order"))
 	if sortOrder != "asc" && sortOrder != "desc" {
@@ -255,7 +383,9 @@ func clampInt(value, min, max int) int {
 	return value
 }
 
-func sortStacks(stacks []storage.StackStatus, sortBy, sortOrder string) []storage.StackStatus {
+// sortStacks orders stacks for the project page. Sorting by "environment"
+// groups stacks by environment(path); a nil environment leaves path order.
+func sortStacks(stacks []storage.StackStatus, sortBy, sortOrder string, environment func(string) string) []storage.StackStatus {
 	if len(stacks) < 2 {
 		return stacks
 	}
@@ -263,6 +393,16 @@ func sortStacks(stacks []storage.StackStatus, sortBy, sortOrder string) []storag
 	copy(sorted, stacks)
 	less := func(i, j int) bool {
 		switch sortBy {
+		case "severity":
+			// Most severe first; ties fall back to status.
+			if sorted[i].Severity != sorted[j].Severity {
+				return sorted[i].Severity > sorted[j].Severity
+			}
+			ai := statusRank(sorted[i])
+			aj := statusRank(sorted[j])
+			if ai != aj {
+				return ai < aj
+			}
 		case "status":
 			// error -> drifted -> healthy
 			ai := statusRank(sorted[i])
@@ -270,6 +410,19 @@ func sortStacks(stacks []storage.StackStatus, sortBy, sortOrder string) []storag
 			if ai != aj {
 				return ai < aj
 			}
+		case "environment":
+			if environment == nil {
+				break
+			}
+			ei := environment(sorted[i].Path)
+			ej := environment(sorted[j].Path)
+			if ei != ej {
+				// Stacks without an environment sort last.
+				if ei == "" || ej == "" {
+					return ej == ""
+				}
+				return ei < ej
+			}
 		case "last_run":
 			ti := sorted[i].RunAt
 			tj := sorted[j].RunAt
@@ -298,7 +451,7 @@ func statusRank(stack storage.StackStatus) int {
 	return 2
 }
 
-func paginateStacks(stacks []storage.StackStatus, page, perPage int, basePath, sortBy, sortOrder string) ([]storage.StackStatus, projectPagination) {
+func paginateStacks(stacks []storage.StackStatus, page, perPage int, basePath, sortBy, sortOrder string, filters url.Values) ([]storage.StackStatus, projectPagination) {
 	total := len(stacks)
 	totalPages := total / perPage
 	if total%perPage != 0 {
@@ -327,16 +480,22 @@ func paginateStacks(stacks []storage.StackStatus, page, perPage int, basePath, s
 		TotalPages: totalPages,
 	}
 	if page > 1 {
-		pagination.PrevURL = buildProjectListURL(basePath, page-1, perPage, sortBy, sortOrder)
+		pagination.PrevURL = buildProjectListURL(basePath, page-1, perPage, sortBy, sortOrder, filters)
 	}
 	if page < totalPages {
-		pagination.NextURL = buildProjectListURL(basePath, page+1, perPage, sortBy, sortOrder)
+		pagination.NextURL = buildProjectListURL(basePath, page+1, perPage, sortBy, sortOrder, filters)
 	}
 	return stacks[start:end], pagination
 }
 
-func buildProjectListURL(basePath string, page, perPage int, sortBy, sortOrder string) string {
+// buildProjectListURL keeps the page's filters (tag, env) in the URL.
+func buildProjectListURL(basePath string, page, perPage int, sortBy, sortOrder string, filters url.Values) string {
 	params := url.Values{}
+	for key, values := range filters {
+		for _, value := range values {
+			params.Add(key, value)
+		}
+	}
 	params.Set("page", strconv.Itoa(page))
 	params.Set("per", strconv.Itoa(perPage))
 	params.Set("sort", sortBy)
@@ -362,6 +521,9 @@ func (s *Server) handleScanStackUI(w http.ResponseWriter, r *http.Request) {
 
 	scan, stacks, err := s.startScanWithCancel(r.Context(), projectCfg, trigger, "", "")
 	if err != nil {
+		if writeScanLimitedText(w, err) {
+			return
+		}
 		if err == queue.ErrProjectLocked {
 			http.Redirect(w, r, "/projects/"+projectName, http.StatusSeeOther)
 			return
@@ -387,6 +549,56 @@ func (s *Server) handleScanStackUI(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/projects/"+projectName, http.StatusSeeOther)
 }
 
+func (s *Server) handleStackBatchUI(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	projectCfg, err := s.getProjectConfig(projectName)
+	if err != nil || projectCfg == nil {
+		http.Error(w, "Project not configured", http.StatusNotFound)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	req := stackBatchRequest{
+		Action:  r.PostForm.Get("action"),
+		Stacks:  r.PostForm["stacks"],
+		Trigger: "manual",
+		Actor:   s.uiActor(r),
+	}
+	if req.Action == batchActionScan && s.rejectDuringMaintenance(w) {
+		return
+	}
+	result, err := s.applyStackBatch(r.Context(), projectCfg, req)
+	if err != nil {
+		if writeScanLimitedText(w, err) {
+			return
+		}
+		switch {
+		case isBatchClientError(err):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err == errNoKnownBatchStacks, isBatchConflict(err):
+			// Reported on the project page through the redirect below.
+		default:
+			http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	target := "/projects/" + projectName
+	if q := batchNoticeQuery(req.Action, result, err); len(q) > 0 {
+		target += "?" + q.Encode()
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
 func filterParentStackStatuses(stacks []storage.StackStatus) []storage.StackStatus {
 	if len(stacks) < 2 {
 		return stacks
@@ -423,24 +635,60 @@ func (s *Server) handleStack(w http.ResponseWriter, r *http.Request) {
 	}
 
 	projectCfg, _ := s.getProjectConfig(projectName)
-	result, err := s.storage.GetResult(projectName, stackPath)
+	scanID := strings.TrimSpace(r.URL.Query().Get("scan"))
+	result, err := s.stackResult(projectName, stackPath, scanID)
 	if err != nil {
+		if errors.Is(err, storage.ErrScanResultNotFound) {
+			http.Error(w, "No result retained for this scan", http.StatusNotFound)
+			return
+		}
 		http.Error(w, "Stack not found", http.StatusNotFound)
 		return
 	}
-	lastScan, _ := s.queue.GetLastScan(r.Context(), projectName)
+	if r.URL.Query().Get("raw") == "1" {
+		writeRawPlan(w, stackPath, result)
+		return
+	}
+	var lastScan *queue.Scan
+	switch {
+	case s.degradedStatus().Degraded:
+		// Read-only; the result carries what the page needs.
+	case scanID != "":
+		// Nil once the scan has expired from the queue; the result carries
+		// what the page needs.
+		lastScan, _ = s.queue.GetScan(r.Context(), scanID)
+	default:
+		lastScan, _ = s.queue.GetLastScan(r.Context(), projectName)
+	}
 
+	plan := truncatePlan(result.PlanOutput, s.cfg.API.MaxInlinePlanBytes)
 	data := stackPageData{
+		pageAuth:    s.pageAuth(r),
 		ProjectName: projectName,
 		ProjectURL:  "",
 		Path:        stackPath,
 		Result:      result,
 		Scan:        lastScan,
-		CSRFToken:   csrfTokenFromContext(r.Context()),
-		PlanHTML:    formatPlanOutput(result.PlanOutput),
+		PlanHTML:    formatPlanOutput(plan.Head),
+		CommitSHA:   result.CommitSHA,
+		CommitInfo:  result.CommitInfo,
+
+		PinnedScanID: scanID,
+		Runs:         s.stackRunLinks(projectName, stackPath),
+	}
+	if data.CommitSHA == "" && lastScan != nil {
+		data.CommitSHA = lastScan.CommitSHA
+		data.CommitInfo = lastScan.CommitInfo
+	}
+	if plan.Truncated {
+		data.PlanTailHTML = formatPlanOutput(plan.Tail)
+		data.PlanTruncated = true
+		data.PlanOmittedBytes = plan.OmittedBytes
 	}
 	if projectCfg != nil {
 		data.ProjectURL = projectCfg.URL
+		data.Display = projectCfg.StackDisplay(stackPath)
+		data.ConsoleLinks, data.ResourceLinks = stackConsoleLinks(projectCfg, stackPath, result)
 	}
 
 	if err := s.tmplDrift.ExecuteTemplate(w, "layout", data); err != nil {
@@ -449,14 +697,14 @@ func (s *Server) handleStack(w http.ResponseWriter, r *http.Request) {
 }
 
 type settingsData struct {
-	CSRFToken                  string
+	pageAuth
 	DynamicReposEnabled        bool
 	DynamicIntegrationsEnabled bool
 }
 
 func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 	data := settingsData{
-		CSRFToken:                  csrfTokenFromContext(r.Context()),
+		pageAuth:                   s.pageAuth(r),
 		DynamicReposEnabled:        s.projectStore != nil,
 		DynamicIntegrationsEnabled: s.intStore != nil,
 	}
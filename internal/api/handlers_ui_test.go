@@ -3,6 +3,7 @@ package api
 import (
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -12,7 +13,7 @@ import (
 func TestParseProjectListParamsDefaults(t *testing.T) {
 	req := &http.Request{URL: &url.URL{RawQuery: ""}}
 	page, per, sortBy, order := parseProjectListParams(req)
-	if page != 1 || per != 50 || sortBy != "path" || order != "asc" {
+	if page != 1 || per != 50 || sortBy != "severity" || order != "asc" {
 		t.Fatalf("unexpected defaults: page=%d per=%d sort=%s order=%s", page, per, sortBy, order)
 	}
 }
@@ -20,7 +21,7 @@ func TestParseProjectListParamsDefaults(t *testing.T) {
 func TestParseProjectListParamsClampAndNormalize(t *testing.T) {
 	req := &http.Request{URL: &url.URL{RawQuery: "page=-2&per=500&sort=unknown&order=desc"}}
 	page, per, sortBy, order := parseProjectListParams(req)
-	if page != 1 || per != 200 || sortBy != "path" || order != "desc" {
+	if page != 1 || per != 200 || sortBy != "severity" || order != "desc" {
 		t.Fatalf("unexpected normalized params: page=%d per=%d sort=%s order=%s", page, per, sortBy, order)
 	}
 }
@@ -32,7 +33,7 @@ func TestSortStacksByStatus(t *testing.T) {
 		{Path: "a", Drifted: true, Error: ""},
 		{Path: "c", Drifted: false, Error: "boom", RunAt: now},
 	}
-	sorted := sortStacks(stacks, "status", "asc")
+	sorted := sortStacks(stacks, "status", "asc", nil)
 	if sorted[0].Error == "" || sorted[0].Path != "c" {
 		t.Fatalf("expected error first, got %v", sorted[0])
 	}
@@ -44,6 +45,23 @@ func TestSortStacksByStatus(t *testing.T) {
 	}
 }
 
+func TestSortStacksBySeverity(t *testing.T) {
+	stacks := []storage.StackStatus{
+		{Path: "a"},
+		{Path: "b", Drifted: true, Severity: 3},
+		{Path: "c", Error: "boom"},
+		{Path: "d", Drifted: true, Severity: 30},
+	}
+	sorted := sortStacks(stacks, "severity", "asc", nil)
+	var got []string
+	for _, st := range sorted {
+		got = append(got, st.Path)
+	}
+	if want := "d,b,c,a"; strings.Join(got, ",") != want {
+		t.Fatalf("expected %s, got %v", want, got)
+	}
+}
+
 func TestSortStacksByLastRunDesc(t *testing.T) {
 	t1 := time.Now().Add(-2 * time.Hour)
 	t2 := time.Now().Add(-1 * time.Hour)
@@ -51,7 +69,7 @@ func TestSortStacksByLastRunDesc(t *testing.T) {
 		{Path: "a", RunAt: t1},
 		{Path: "b", RunAt: t2},
 	}
-	sorted := sortStacks(stacks, "last_run", "desc")
+	sorted := sortStacks(stacks, "last_run", "desc", nil)
 	if sorted[0].Path != "b" {
 		t.Fatalf("expected most recent first, got %v", sorted[0])
 	}
@@ -61,7 +79,7 @@ func TestPaginateStacks(t *testing.T) {
 	stacks := []storage.StackStatus{
 		{Path: "a"}, {Path: "b"}, {Path: "c"}, {Path: "d"},
 	}
-	pageStacks, pagination := paginateStacks(stacks, 2, 2, "/projects/project", "path", "asc")
+	pageStacks, pagination := paginateStacks(stacks, 2, 2, "/projects/project", "path", "asc", nil)
 	if len(pageStacks) != 2 || pageStacks[0].Path != "c" {
 		t.Fatalf("unexpected page stacks: %+v", pageStacks)
 	}
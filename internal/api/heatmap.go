@@ -0,0 +1,209 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultHeatmapDays = 30
+
+	// A stack is flagged flaky once it has enough scans in the window and
+	// either drifts most of the time or keeps flipping between states.
+	flakyMinScans   = 5
+	flakyDriftPct   = 50
+	flakyMinFlips   = 4
+	heatmapMaxLevel = 4
+)
+
+type apiHeatmap struct {
+	ProjectName string            `json:"project_name"`
+	Days        int               `json:"days"`
+	Start       time.Time         `json:"start"`
+	End         time.Time         `json:"end"`
+	Stacks      []apiHeatmapStack `json:"stacks"`
+}
+
+type apiHeatmapStack struct {
+	StackPath string             `json:"stack_path"`
+	Scans     int                `json:"scans"`
+	Drifted   int                `json:"drifted"`
+	Errored   int                `json:"errored"`
+	DriftPct  int                `json:"drift_pct"`
+	Flips     int                `json:"flips"`
+	Flaky     bool               `json:"flaky"`
+	Days      []apiHeatmapBucket `json:"days"`
+}
+
+type apiHeatmapBucket struct {
+	Date    string `json:"date"`
+	Scans   int    `json:"scans"`
+	Drifted int    `json:"drifted"`
+	Errored int    `json:"errored"`
+	// Level is the drift intensity from 0 (no drift) to 4 (every scan drifted).
+	Level int `json:"level"`
+}
+
+type heatmapPageData struct {
+	pageAuth
+	Name    string
+	Heatmap *apiHeatmap
+	Flaky   int
+}
+
+func (s *Server) handleProjectHeatmap(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		http.Error(w, "Invalid project name", http.StatusBadRequest)
+		return
+	}
+	if projectCfg, err := s.getProjectConfig(projectName); err != nil || projectCfg == nil {
+		http.Error(w, "Project not configured", http.StatusNotFound)
+		return
+	}
+	days, err := parseHeatmapDays(r.URL.Query().Get("days"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	heatmap, err := s.buildHeatmap(projectName, days, time.Now())
+	if err != nil {
+		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, heatmap)
+}
+
+func (s *Server) handleProjectHeatmapUI(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		http.Error(w, "Invalid project name", http.StatusBadRequest)
+		return
+	}
+	if projectCfg, err := s.getProjectConfig(projectName); err != nil || projectCfg == nil {
+		http.Error(w, "Project not configured", http.StatusNotFound)
+		return
+	}
+
+	heatmap, err := s.buildHeatmap(projectName, defaultHeatmapDays, time.Now())
+	if err != nil {
+		http.Error(w, "Failed to load scan history", http.StatusInternalServerError)
+		return
+	}
+	data := heatmapPageData{
+		pageAuth: s.pageAuth(r),
+		Name:     projectName,
+		Heatmap:  heatmap,
+	}
+	for _, st := range heatmap.Stacks {
+		if st.Flaky {
+			data.Flaky++
+		}
+	}
+	if err := s.tmplHeatmap.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("template error: %v", err)
+	}
+}
+
+func parseHeatmapDays(raw string) (int, error) {
+	if raw == "" {
+		return defaultHeatmapDays, nil
+	}
+	days, err := strconv.Atoi(raw)
+	maxDays := int(storage.HistoryRetention / (24 * time.Hour))
+	if err != nil || days < 1 || days > maxDays {
+		return 0, fmt.Errorf("days must be between 1 and %d", maxDays)
+	}
+	return days, nil
+}
+
+// buildHeatmap buckets each stack's run history into calendar days (UTC).
+// Stacks are ordered by drift percentage, most drifted first.
+func (s *Server) buildHeatmap(projectName string, days int, now time.Time) (*apiHeatmap, error) {
+	end := now.UTC()
+	start := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -(days - 1))
+	heatmap := &apiHeatmap{
+		ProjectName: projectName,
+		Days:        days,
+		Start:       start,
+		End:         end,
+		Stacks:      []apiHeatmapStack{},
+	}
+
+	stacks, err := s.storage.ListStacks(projectName)
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range filterParentStackStatuses(stacks) {
+		history, err := s.storage.StackHistory(projectName, st.Path, start)
+		if err != nil {
+			return nil, err
+		}
+		heatmap.Stacks = append(heatmap.Stacks, buildHeatmapStack(st.Path, history, start, days))
+	}
+
+	sort.Slice(heatmap.Stacks, func(i, j int) bool {
+		a, b := heatmap.Stacks[i], heatmap.Stacks[j]
+		if a.DriftPct != b.DriftPct {
+			return a.DriftPct > b.DriftPct
+		}
+		if a.Flips != b.Flips {
+			return a.Flips > b.Flips
+		}
+		return a.StackPath < b.StackPath
+	})
+	return heatmap, nil
+}
+
+func buildHeatmapStack(stackPath string, history []storage.HistoryEntry, start time.Time, days int) apiHeatmapStack {
+	out := apiHeatmapStack{StackPath: stackPath, Days: make([]apiHeatmapBucket, days)}
+	for i := range out.Days {
+		out.Days[i].Date = start.AddDate(0, 0, i).Format("2006-01-02")
+	}
+
+	var prev *storage.HistoryEntry
+	for i := range history {
+		entry := history[i]
+		idx := int(entry.RunAt.UTC().Sub(start) / (24 * time.Hour))
+		if idx < 0 || idx >= days {
+			continue
+		}
+		bucket := &out.Days[idx]
+		bucket.Scans++
+		out.Scans++
+		if entry.Drifted {
+			bucket.Drifted++
+			out.Drifted++
+		}
+		if entry.Errored {
+			bucket.Errored++
+			out.Errored++
+		}
+		if !entry.Errored && prev != nil && prev.Drifted != entry.Drifted {
+			out.Flips++
+		}
+		if !entry.Errored {
+			prev = &history[i]
+		}
+	}
+
+	for i := range out.Days {
+		b := &out.Days[i]
+		if b.Scans > 0 && b.Drifted > 0 {
+			b.Level = 1 + (b.Drifted*(heatmapMaxLevel-1))/b.Scans
+		}
+	}
+	if out.Scans > 0 {
+		out.DriftPct = out.Drifted * 100 / out.Scans
+	}
+	out.Flaky = out.Scans >= flakyMinScans && (out.DriftPct >= flakyDriftPct || out.Flips >= flakyMinFlips)
+	return out
+}
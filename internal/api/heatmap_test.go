@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestProjectHeatmap(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod", "envs/dev"}, false, nil, true, nil)
+	defer cleanup()
+
+	now := time.Now()
+	for i := 0; i < 6; i++ {
+		runAt := now.Add(-time.Duration(6-i) * time.Hour)
+		if err := srv.storage.SaveResult("project", "envs/prod", &storage.RunResult{RunAt: runAt, Drifted: i%2 == 0}); err != nil {
+			t.Fatalf("save result: %v", err)
+		}
+		if err := srv.storage.SaveResult("project", "envs/dev", &storage.RunResult{RunAt: runAt}); err != nil {
+			t.Fatalf("save result: %v", err)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/api/projects/project/heatmap")
+	if err != nil {
+		t.Fatalf("heatmap: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var heatmap apiHeatmap
+	if err := json.NewDecoder(resp.Body).Decode(&heatmap); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if heatmap.Days != defaultHeatmapDays || len(heatmap.Stacks) != 2 {
+		t.Fatalf("unexpected heatmap: %+v", heatmap)
+	}
+
+	prod := heatmap.Stacks[0]
+	if prod.StackPath != "envs/prod" || prod.Scans != 6 || prod.Drifted != 3 || prod.DriftPct != 50 || prod.Flips != 5 || !prod.Flaky {
+		t.Fatalf("unexpected prod row: %+v", prod)
+	}
+	if len(prod.Days) != defaultHeatmapDays {
+		t.Fatalf("expected %d day buckets, got %d", defaultHeatmapDays, len(prod.Days))
+	}
+	dev := heatmap.Stacks[1]
+	if dev.StackPath != "envs/dev" || dev.DriftPct != 0 || dev.Flaky {
+		t.Fatalf("unexpected dev row: %+v", dev)
+	}
+
+	for _, days := range []string{"0", "31", "abc"} {
+		bad, err := http.Get(ts.URL + "/api/projects/project/heatmap?days=" + days)
+		if err != nil {
+			t.Fatalf("heatmap: %v", err)
+		}
+		bad.Body.Close()
+		if bad.StatusCode != http.StatusBadRequest {
+			t.Fatalf("days=%s: expected 400, got %d", days, bad.StatusCode)
+		}
+	}
+
+	for _, path := range []string{"/api/projects/missing/heatmap", "/projects/missing/heatmap"} {
+		missing, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("heatmap: %v", err)
+		}
+		missing.Body.Close()
+		if missing.StatusCode != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", path, missing.StatusCode)
+		}
+	}
+
+	page, err := http.Get(ts.URL + "/projects/project/heatmap")
+	if err != nil {
+		t.Fatalf("heatmap page: %v", err)
+	}
+	page.Body.Close()
+	if page.StatusCode != http.StatusOK {
+		t.Fatalf("expected heatmap page 200, got %d", page.StatusCode)
+	}
+}
+
+func TestBuildHeatmapStackLevels(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := []storage.HistoryEntry{
+		{RunAt: start.Add(time.Hour), Drifted: true},
+		{RunAt: start.Add(2 * time.Hour), Drifted: true},
+		{RunAt: start.Add(25 * time.Hour), Drifted: true},
+		{RunAt: start.Add(26 * time.Hour)},
+		{RunAt: start.Add(49 * time.Hour), Errored: true},
+	}
+	row := buildHeatmapStack("envs/prod", history, start, 3)
+	if row.Days[0].Level != heatmapMaxLevel || row.Days[1].Level != 2 || row.Days[2].Level != 0 {
+		t.Fatalf("unexpected levels: %+v", row.Days)
+	}
+	if row.Errored != 1 || row.Flips != 1 {
+		t.Fatalf("unexpected totals: %+v", row)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"html"
 	"html/template"
+	"net/http"
 	"os"
 	"regexp"
 	"sort"
@@ -11,8 +12,10 @@ import (
 	"time"
 
 	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/maintenance"
 	"github.com/driftdhq/driftd/internal/projects"
 	"github.com/driftdhq/driftd/internal/secrets"
+	"github.com/driftdhq/driftd/internal/vcs"
 )
 
 var projectNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
@@ -45,6 +48,61 @@ func (s *Server) sanitizeErrorMessage(msg string) string {
 	return msg
 }
 
+// uiActor returns the identity of the UI user for audit fields, if known.
+func (s *Server) uiActor(r *http.Request) string {
+	if s.useExternalAuth() {
+		for _, header := range []string{s.cfg.Auth.External.UserHeader, "X-Auth-Request-User", s.cfg.Auth.External.EmailHeader, "X-Auth-Request-Email"} {
+			if header = strings.TrimSpace(header); header == "" {
+				continue
+			}
+			if v := strings.TrimSpace(r.Header.Get(header)); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	if sess := sessionFromContext(r.Context()); sess != nil {
+		return sess.User
+	}
+	if username, _, ok := r.BasicAuth(); ok {
+		return username
+	}
+	return ""
+}
+
+// pageAuth carries the per-request state every UI page needs for the shared
+// layout (CSRF token for forms, current user, logout control, maintenance
+// banner).
+type pageAuth struct {
+	CSRFToken   string
+	User        string
+	CanLogout   bool
+	Maintenance maintenance.Status
+	// Federation shows the link to the merged overview of peer instances.
+	Federation bool
+	// Accessibility selects the display modes rendered on the root element.
+	Accessibility accessibilityPrefs
+	// Degraded shows the stale data banner while the queue is unreachable.
+	Degraded degradedStatus
+}
+
+func (s *Server) pageAuth(r *http.Request) pageAuth {
+	canLogout := sessionFromContext(r.Context()) != nil
+	if s.useExternalAuth() && strings.TrimSpace(s.cfg.Auth.Session.LogoutURL) != "" {
+		canLogout = true
+	}
+	return pageAuth{
+		CSRFToken:   csrfTokenFromContext(r.Context()),
+		User:        s.uiActor(r),
+		CanLogout:   canLogout,
+		Maintenance: s.maintenance.Status(),
+		Federation:  s.federation != nil,
+
+		Accessibility: s.accessibilityPrefs(r),
+		Degraded:      s.degradedStatus(),
+	}
+}
+
 func (s *Server) getProjectConfig(name string) (*config.ProjectConfig, error) {
 	if s.projectProvider != nil {
 		return s.projectProvider.Get(name)
@@ -78,9 +136,11 @@ func (s *Server) listConfiguredRepos() []config.ProjectConfig {
 			URL:                        entry.URL,
 			CloneURL:                   entry.URL,
 			Branch:                     entry.Branch,
+			RootPath:                   entry.RootPath,
 			IgnorePaths:                entry.IgnorePaths,
 			Schedule:                   entry.Schedule,
 			CancelInflightOnNewTrigger: &cancel,
+			Chain:                      entry.Chain,
 		}
 		if entry.Git.Type != "" {
 			project.Git = &config.GitAuthConfig{Type: entry.Git.Type}
@@ -153,28 +213,40 @@ func (s *Server) getReposByURL(urls ...string) ([]*config.ProjectConfig, error)
 	return matched, nil
 }
 
+// formatPlanOutput renders plan text one element per line, with each
+// resource change in a collapsible block anchored by its address.
 func formatPlanOutput(plan string) template.HTML {
 	if plan == "" {
 		return ""
 	}
 	clean := ansiEscapePattern.ReplaceAllString(plan, "")
 	lines := strings.Split(clean, "\n")
+	blocks := groupPlanBlocks(lines)
 	var b strings.Builder
-	for i, line := range lines {
-		class := planLineClass(line)
-		escaped := html.EscapeString(line)
-		if class != "" {
-			b.WriteString(`<span class="plan-line `)
-			b.WriteString(class)
-			b.WriteString(`">`)
-			b.WriteString(escaped)
-			b.WriteString(`</span>`)
-		} else {
-			b.WriteString(escaped)
+	for i := 0; i < len(lines); i++ {
+		if len(blocks) == 0 || blocks[0].StartLine != i+1 {
+			writePlanLine(&b, lines[i], false)
+			continue
 		}
-		if i < len(lines)-1 {
-			b.WriteString("\n")
+		block := blocks[0]
+		blocks = blocks[1:]
+		anchor := html.EscapeString(block.Anchor)
+		b.WriteString(`<details class="plan-block" id="`)
+		b.WriteString(anchor)
+		b.WriteString(`" data-action="`)
+		b.WriteString(html.EscapeString(block.Action))
+		b.WriteString(`" open><summary>`)
+		writePlanLine(&b, lines[i], false)
+		b.WriteString(`<a class="plan-anchor" href="#`)
+		b.WriteString(anchor)
+		b.WriteString(`" aria-label="Link to `)
+		b.WriteString(html.EscapeString(block.Address))
+		b.WriteString(`">#</a></summary>`)
+		for j := i + 1; j < block.EndLine; j++ {
+			writePlanLine(&b, lines[j], true)
 		}
+		b.WriteString(`</details>`)
+		i = block.EndLine - 1
 	}
 	return template.HTML(b.String())
 }
@@ -203,28 +275,7 @@ func planLineClass(line string) string {
 }
 
 func commitURL(projectURL, sha string) string {
-	if projectURL == "" || sha == "" {
-		return ""
-	}
-	clean := strings.TrimSuffix(projectURL, ".git")
-	switch {
-	case strings.HasPrefix(clean, "git@github.com:"):
-		clean = strings.TrimPrefix(clean, "git@github.com:")
-		return "https://github.com/" + clean + "/commit/" + sha
-	case strings.HasPrefix(clean, "git@gitlab.com:"):
-		clean = strings.TrimPrefix(clean, "git@gitlab.com:")
-		return "https://gitlab.com/" + clean + "/-/commit/" + sha
-	case strings.HasPrefix(clean, "https://github.com/"):
-		return clean + "/commit/" + sha
-	case strings.HasPrefix(clean, "http://github.com/"):
-		return strings.Replace(clean, "http://github.com/", "https://github.com/", 1) + "/commit/" + sha
-	case strings.HasPrefix(clean, "https://gitlab.com/"):
-		return clean + "/-/commit/" + sha
-	case strings.HasPrefix(clean, "http://gitlab.com/"):
-		return strings.Replace(clean, "http://gitlab.com/", "https://gitlab.com/", 1) + "/-/commit/" + sha
-	default:
-		return ""
-	}
+	return vcs.CommitURL(projectURL, sha)
 }
 
 func timeAgo(t time.Time) string {
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+const (
+	idempotencyHeader         = "Idempotency-Key"
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLen      = 255
+
+	// idempotencyClaimTTL bounds how long a key stays claimed if the server
+	// dies before the request completes.
+	idempotencyClaimTTL = time.Minute
+)
+
+// idempotencyClaim is a claimed Idempotency-Key for a request in progress.
+// A nil claim means the request carried no key.
+type idempotencyClaim struct {
+	key         string
+	fingerprint string
+	done        bool
+}
+
+// claimIdempotencyKey claims the request's Idempotency-Key, if any. When the
+// key was already used it writes the response itself (a replay of the
+// original scan or an error) and returns handled=true.
+func (s *Server) claimIdempotencyKey(w http.ResponseWriter, r *http.Request, projectName string, body []byte) (*idempotencyClaim, bool) {
+	key := r.Header.Get(idempotencyHeader)
+	if key == "" {
+		return nil, false
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "Idempotency-Key is too long"})
+		return nil, true
+	}
+
+	sum := sha256.New()
+	sum.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	sum.Write(body)
+	fingerprint := hex.EncodeToString(sum.Sum(nil))
+
+	scoped := projectName + ":" + key
+	record, claimed, err := s.queue.ClaimIdempotencyKey(r.Context(), scoped, fingerprint, idempotencyClaimTTL)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return nil, true
+	}
+	if claimed {
+		return &idempotencyClaim{key: scoped, fingerprint: fingerprint}, false
+	}
+
+	switch {
+	case record.Fingerprint != fingerprint:
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "Idempotency-Key was already used for a different request"})
+	case record.ScanID == "":
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "A request with this Idempotency-Key is still in progress"})
+	default:
+		resp := scanResponse{Stacks: record.Stacks, Message: record.Message}
+		if scan, err := s.queue.GetScan(r.Context(), record.ScanID); err == nil {
+			resp.Scan = toAPIScan(scan)
+		}
+		w.Header().Set(idempotencyReplayedHeader, "true")
+		writeJSON(w, http.StatusOK, resp)
+	}
+	return nil, true
+}
+
+// completeIdempotencyKey records the response for a claimed key so retries
+// within the idempotency window get it back.
+func (s *Server) completeIdempotencyKey(ctx context.Context, claim *idempotencyClaim, resp scanResponse) {
+	if claim == nil || resp.Scan == nil {
+		return
+	}
+	claim.done = true
+	record := queue.IdempotencyRecord{
+		Fingerprint: claim.fingerprint,
+		ScanID:      resp.Scan.ID,
+		Stacks:      resp.Stacks,
+		Message:     resp.Message,
+	}
+	if err := s.queue.CompleteIdempotencyKey(context.WithoutCancel(ctx), claim.key, record, s.cfg.API.IdempotencyWindow); err != nil {
+		log.Printf("idempotency key %s: %v", claim.key, err)
+	}
+}
+
+// releaseIdempotencyKey frees a claimed key whose request did not complete,
+// so the caller can retry with it. It is a no-op once the key is completed.
+func (s *Server) releaseIdempotencyKey(ctx context.Context, claim *idempotencyClaim) {
+	if claim == nil || claim.done {
+		return
+	}
+	if err := s.queue.ReleaseIdempotencyKey(context.WithoutCancel(ctx), claim.key); err != nil {
+		log.Printf("idempotency key %s: %v", claim.key, err)
+	}
+}
+
+// readScanBody reads the body of a scan request, capped at the webhook
+// payload limit, so hashing it for idempotency cannot exhaust memory.
+func (s *Server) readScanBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if limit := s.cfg.Webhook.MaxBodyBytes; limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Payload too large", http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "Failed to read body", http.StatusBadRequest)
+		}
+		return nil, false
+	}
+	return body, true
+}
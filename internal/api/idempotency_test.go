@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func postScanWithKey(t *testing.T, url, key, body string) (*http.Response, scanResponse) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(idempotencyHeader, key)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("post scan: %v", err)
+	}
+	defer resp.Body.Close()
+	var out scanResponse
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	return resp, out
+}
+
+func TestScanIdempotencyKey(t *testing.T) {
+	ts, _, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, false)
+	defer cleanup()
+
+	url := ts.URL + "/api/projects/project/scan"
+	first, firstBody := postScanWithKey(t, url, "ci-run-42", `{"actor":"ci"}`)
+	if first.StatusCode != http.StatusOK || firstBody.Scan == nil {
+		t.Fatalf("expected scan to start, got %d: %+v", first.StatusCode, firstBody)
+	}
+
+	second, secondBody := postScanWithKey(t, url, "ci-run-42", `{"actor":"ci"}`)
+	if second.StatusCode != http.StatusOK {
+		t.Fatalf("expected replay to succeed, got %d: %+v", second.StatusCode, secondBody)
+	}
+	if second.Header.Get(idempotencyReplayedHeader) != "true" {
+		t.Fatalf("expected replay header")
+	}
+	if secondBody.Scan == nil || secondBody.Scan.ID != firstBody.Scan.ID {
+		t.Fatalf("expected original scan %s, got %+v", firstBody.Scan.ID, secondBody.Scan)
+	}
+	if secondBody.Message != firstBody.Message || len(secondBody.Stacks) != len(firstBody.Stacks) {
+		t.Fatalf("expected original response, got %+v", secondBody)
+	}
+
+	mismatch, _ := postScanWithKey(t, url, "ci-run-42", `{"actor":"someone-else"}`)
+	if mismatch.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for reused key, got %d", mismatch.StatusCode)
+	}
+
+	// Without a key the project lock still refuses a second scan.
+	resp, err := http.Post(url, "application/json", bytes.NewBufferString(`{"actor":"ci"}`))
+	if err != nil {
+		t.Fatalf("post scan: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 without key, got %d", resp.StatusCode)
+	}
+}
+
+func TestScanIdempotencyKeyReleasedOnFailure(t *testing.T) {
+	ts, _, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, false)
+	defer cleanup()
+
+	url := ts.URL + "/api/projects/project/scan"
+	if resp, _ := postScanWithKey(t, url, "first", `{}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected scan to start, got %d", resp.StatusCode)
+	}
+
+	// The project is locked, so this request fails and must not keep its key.
+	if resp, _ := postScanWithKey(t, url, "second", `{}`); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 while locked, got %d", resp.StatusCode)
+	}
+	resp, _ := postScanWithKey(t, url, "second", `{}`)
+	if resp.StatusCode != http.StatusConflict || resp.Header.Get(idempotencyReplayedHeader) != "" {
+		t.Fatalf("expected a fresh attempt for the released key, got %d", resp.StatusCode)
+	}
+}
+
+func TestScanBodyIsCapped(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, nil)
+	defer cleanup()
+	srv.cfg.Webhook.MaxBodyBytes = 64
+
+	body := `{"actor":"` + strings.Repeat("x", 128) + `"}`
+	resp, _ := postScanWithKey(t, ts.URL+"/api/projects/project/scan", "ci-run-43", body)
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for an oversized scan request, got %d", resp.StatusCode)
+	}
+}
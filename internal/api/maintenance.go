@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type maintenanceRequest struct {
+	Enabled     bool       `json:"enabled"`
+	Reason      string     `json:"reason"`
+	Actor       string     `json:"actor"`
+	ExpectedEnd *time.Time `json:"expected_end"`
+}
+
+func (s *Server) handleGetMaintenance(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.maintenance.Status())
+}
+
+// handleSetMaintenance opens or closes a maintenance window. Scans already
+// running are left to finish; only new scans are refused.
+func (s *Server) handleSetMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req maintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	if !req.Enabled {
+		if err := s.maintenance.Disable(); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+			return
+		}
+		writeJSON(w, http.StatusOK, s.maintenance.Status())
+		return
+	}
+	if req.ExpectedEnd != nil && !req.ExpectedEnd.After(time.Now()) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "expected_end must be in the future"})
+		return
+	}
+	actor := strings.TrimSpace(req.Actor)
+	if actor == "" {
+		actor = s.uiActor(r)
+	}
+	status, err := s.maintenance.Enable(strings.TrimSpace(req.Reason), actor, req.ExpectedEnd)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// maintenanceMiddleware refuses requests that would start scans while a
+// maintenance window is open.
+func (s *Server) maintenanceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.rejectDuringMaintenance(w) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rejectDuringMaintenance writes a 503 with a Retry-After hint and returns
+// true when a maintenance window is open.
+func (s *Server) rejectDuringMaintenance(w http.ResponseWriter) bool {
+	status := s.maintenance.Status()
+	if !status.Enabled {
+		return false
+	}
+	retryAfter := status.RetryAfter(time.Now())
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter/time.Second)))
+	msg := "driftd is in maintenance mode; new scans are paused"
+	if status.Reason != "" {
+		msg += ": " + status.Reason
+	}
+	writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": msg})
+	return true
+}
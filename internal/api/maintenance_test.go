@@ -0,0 +1,110 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/maintenance"
+)
+
+func TestMaintenanceModeAPI(t *testing.T) {
+	ts, _, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true)
+	defer cleanup()
+
+	end := time.Now().Add(20 * time.Minute).UTC().Format(time.RFC3339)
+	body := `{"enabled":true,"reason":"redis upgrade","actor":"ops","expected_end":"` + end + `"}`
+	resp, err := http.Post(ts.URL+"/api/admin/maintenance", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("enable maintenance: %v", err)
+	}
+	var status maintenance.Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !status.Enabled || status.Reason != "redis upgrade" || status.Actor != "ops" {
+		t.Fatalf("unexpected enable response %d: %+v", resp.StatusCode, status)
+	}
+
+	resp, err = http.Post(ts.URL+"/api/projects/project/scan", "application/json", bytes.NewBufferString(`{}`))
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 during maintenance, got %d", resp.StatusCode)
+	}
+	retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || retryAfter < 60 || retryAfter > 20*60 {
+		t.Fatalf("unexpected Retry-After %q", resp.Header.Get("Retry-After"))
+	}
+
+	resp, err = http.Post(ts.URL+"/api/projects/project/stacks:batch", "application/json", bytes.NewBufferString(`{"action":"scan","stacks":["envs/prod"]}`))
+	if err != nil {
+		t.Fatalf("batch scan: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 for batch scan during maintenance, got %d", resp.StatusCode)
+	}
+
+	// Annotation batches don't start scans and stay available.
+	resp, err = http.Post(ts.URL+"/api/projects/project/stacks:batch", "application/json", bytes.NewBufferString(`{"action":"acknowledge","stacks":["envs/prod"]}`))
+	if err != nil {
+		t.Fatalf("batch acknowledge: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		t.Fatalf("expected acknowledge to bypass maintenance")
+	}
+
+	resp, err = http.Get(ts.URL + "/api/admin/maintenance")
+	if err != nil {
+		t.Fatalf("get maintenance: %v", err)
+	}
+	status = maintenance.Status{}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	resp.Body.Close()
+	if !status.Enabled || status.ExpectedEnd == nil {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	resp, err = http.Post(ts.URL+"/api/admin/maintenance", "application/json", bytes.NewBufferString(`{"enabled":false}`))
+	if err != nil {
+		t.Fatalf("disable maintenance: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 for disable, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(ts.URL+"/api/projects/project/scan", "application/json", bytes.NewBufferString(`{}`))
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected scan to start after maintenance, got %d", resp.StatusCode)
+	}
+}
+
+func TestMaintenanceModeRejectsPastExpectedEnd(t *testing.T) {
+	ts, _, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true)
+	defer cleanup()
+
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	resp, err := http.Post(ts.URL+"/api/admin/maintenance", "application/json", bytes.NewBufferString(`{"enabled":true,"expected_end":"`+past+`"}`))
+	if err != nil {
+		t.Fatalf("enable maintenance: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+}
@@ -34,18 +34,35 @@ func (s *Server) uiAuthMiddleware(next http.Handler) http.Handler {
 		return s.externalRoleMiddleware(roleViewer)(next)
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		username, password, ok := r.BasicAuth()
-		if !ok ||
-			subtle.ConstantTimeCompare([]byte(username), []byte(s.cfg.UIAuth.Username)) != 1 ||
-			subtle.ConstantTimeCompare([]byte(password), []byte(s.cfg.UIAuth.Password)) != 1 {
-			w.Header().Set("WWW-Authenticate", `Basic realm="driftd"`)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		if sess := s.sessionFromRequest(w, r); sess != nil {
+			ctx := context.WithValue(r.Context(), sessionContextKey, sess)
+			next.ServeHTTP(w, r.WithContext(ctx))
 			return
 		}
-		next.ServeHTTP(w, r)
+		// Non-browser clients may still authenticate each request with basic auth.
+		if s.uiBasicAuthorized(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+			http.Redirect(w, r, loginRedirectURL(r), http.StatusSeeOther)
+			return
+		}
+		// Only non-browser clients get a basic auth challenge; browsers
+		// would show their native prompt instead of the login page.
+		if !isBrowserRequest(r) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="driftd"`)
+		}
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 	})
 }
 
+// isBrowserRequest reports whether r comes from a browser page, which sends
+// Fetch Metadata headers and an HTML Accept header on navigation.
+func isBrowserRequest(r *http.Request) bool {
+	return r.Header.Get("Sec-Fetch-Mode") != "" || strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
 func (s *Server) uiWriteAuthMiddleware(next http.Handler) http.Handler {
 	if !s.useExternalAuth() {
 		return next
@@ -142,6 +159,7 @@ func (s *Server) externalRoleFromRequest(r *http.Request) (authRole, bool) {
 	if subject == "" {
 		return roleNone, false
 	}
+	setPrincipal(r, "external", subject)
 
 	role := s.parseExternalDefaultRole()
 	groups := s.parseExternalGroups(r.Header.Get(groupsHeader))
@@ -204,6 +222,7 @@ func (s *Server) apiAuthMiddleware(next http.Handler) http.Handler {
 		if s.cfg.APIAuth.Token != "" {
 			token := r.Header.Get(s.cfg.APIAuth.TokenHeader)
 			if token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.APIAuth.Token)) == 1 {
+				setPrincipal(r, "token", "api_token")
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -212,6 +231,7 @@ func (s *Server) apiAuthMiddleware(next http.Handler) http.Handler {
 		if s.cfg.APIAuth.WriteToken != "" {
 			writeToken := r.Header.Get(s.cfg.APIAuth.WriteTokenHeader)
 			if writeToken != "" && subtle.ConstantTimeCompare([]byte(writeToken), []byte(s.cfg.APIAuth.WriteToken)) == 1 {
+				setPrincipal(r, "write_token", "write_token")
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -239,7 +259,7 @@ func (s *Server) settingsAuthMiddleware(next http.Handler) http.Handler {
 		}
 
 		if s.cfg.UIAuth.Username != "" || s.cfg.UIAuth.Password != "" {
-			if s.uiBasicAuthorized(r) {
+			if s.uiBasicAuthorized(r) || s.sessionFromRequest(w, r) != nil {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -279,6 +299,7 @@ func (s *Server) apiWriteAuthMiddleware(next http.Handler) http.Handler {
 
 		writeToken := r.Header.Get(s.cfg.APIAuth.WriteTokenHeader)
 		if writeToken != "" && subtle.ConstantTimeCompare([]byte(writeToken), []byte(s.cfg.APIAuth.WriteToken)) == 1 {
+			setPrincipal(r, "write_token", "write_token")
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -293,14 +314,15 @@ func (s *Server) apiWriteAuthMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+const contentSecurityPolicy = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; font-src 'self' https://fonts.gstatic.com data:; img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'; base-uri 'self'; form-action 'self'"
+
 func (s *Server) securityHeadersMiddleware(next http.Handler) http.Handler {
-	const csp = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; font-src 'self' https://fonts.gstatic.com data:; img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'; base-uri 'self'; form-action 'self'"
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.Header().Set("X-Frame-Options", "DENY")
 		w.Header().Set("Referrer-Policy", "same-origin")
 		w.Header().Set("Permissions-Policy", "geolocation=(), microphone=(), camera=()")
-		w.Header().Set("Content-Security-Policy", csp)
+		w.Header().Set("Content-Security-Policy", contentSecurityPolicy)
 		if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
 			w.Header().Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
 		}
@@ -313,9 +335,13 @@ func (s *Server) apiBasicAuthorized(r *http.Request) bool {
 		return false
 	}
 	username, password, ok := r.BasicAuth()
-	return ok &&
-		subtle.ConstantTimeCompare([]byte(username), []byte(s.cfg.APIAuth.Username)) == 1 &&
-		subtle.ConstantTimeCompare([]byte(password), []byte(s.cfg.APIAuth.Password)) == 1
+	if !ok ||
+		subtle.ConstantTimeCompare([]byte(username), []byte(s.cfg.APIAuth.Username)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(password), []byte(s.cfg.APIAuth.Password)) != 1 {
+		return false
+	}
+	setPrincipal(r, "basic", username)
+	return true
 }
 
 func (s *Server) uiBasicAuthorized(r *http.Request) bool {
@@ -323,14 +349,25 @@ func (s *Server) uiBasicAuthorized(r *http.Request) bool {
 		return false
 	}
 	username, password, ok := r.BasicAuth()
-	return ok &&
-		subtle.ConstantTimeCompare([]byte(username), []byte(s.cfg.UIAuth.Username)) == 1 &&
-		subtle.ConstantTimeCompare([]byte(password), []byte(s.cfg.UIAuth.Password)) == 1
+	if !ok ||
+		subtle.ConstantTimeCompare([]byte(username), []byte(s.cfg.UIAuth.Username)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(password), []byte(s.cfg.UIAuth.Password)) != 1 {
+		return false
+	}
+	setPrincipal(r, "basic", username)
+	return true
 }
 
 func (s *Server) csrfMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token := s.ensureCSRFToken(w, r)
+		// Logged-in users get the token bound to their session; everyone else
+		// (login page, external auth) falls back to the double-submit cookie.
+		var token string
+		if sess := sessionFromContext(r.Context()); sess != nil {
+			token = sess.CSRF
+		} else {
+			token = s.ensureCSRFToken(w, r)
+		}
 		ctx := context.WithValue(r.Context(), csrfContextKey, token)
 
 		if r.Method == http.MethodPost && !s.shouldBypassCSRFCheck(r) {
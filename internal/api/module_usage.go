@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/stack"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+// apiModuleUsage lists the stacks whose last plan called a module source,
+// with their drift status, so the reach of a bad module release can be
+// read at a glance.
+type apiModuleUsage struct {
+	Source  string                `json:"source"`
+	Ref     string                `json:"ref,omitempty"`
+	Stacks  []apiModuleUsageStack `json:"stacks"`
+	Drifted int                   `json:"drifted"`
+	Errored int                   `json:"errored"`
+}
+
+type apiModuleUsageStack struct {
+	ProjectName string `json:"project_name"`
+	StackPath   string `json:"stack_path"`
+	// Modules are the addresses of the matching module calls, with the
+	// source and ref each one pins.
+	Modules    []apiModuleUsageCall `json:"modules"`
+	Drifted    bool                 `json:"drifted"`
+	Suppressed bool                 `json:"suppressed,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	RunAt      int64                `json:"run_at"`
+}
+
+type apiModuleUsageCall struct {
+	Module string `json:"module"`
+	Source string `json:"source"`
+	Ref    string `json:"ref,omitempty"`
+}
+
+// handleModuleUsage finds the stacks across all projects, or one project
+// with ?project=, whose last result calls ?source=, optionally pinned at
+// ?ref=.
+func (s *Server) handleModuleUsage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	source := strings.TrimSpace(query.Get("source"))
+	if source == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "source is required"})
+		return
+	}
+	ref := strings.TrimSpace(query.Get("ref"))
+
+	var projects []string
+	if projectName := query.Get("project"); projectName != "" {
+		if !isValidProjectName(projectName) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid project name"})
+			return
+		}
+		projects = []string{projectName}
+	} else {
+		repos, err := s.storage.ListRepos()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+			return
+		}
+		for _, repo := range repos {
+			projects = append(projects, repo.Name)
+		}
+		sort.Strings(projects)
+	}
+
+	usage := apiModuleUsage{Source: source, Ref: ref, Stacks: []apiModuleUsageStack{}}
+	for _, projectName := range projects {
+		stacks, _ := s.storage.ListStacks(projectName)
+		for _, st := range filterParentStackStatuses(stacks) {
+			result, err := s.storage.GetResult(projectName, st.Path)
+			if err != nil {
+				continue
+			}
+			entry, ok := moduleUsageEntry(projectName, st, result, source, ref)
+			if !ok {
+				continue
+			}
+			if entry.Error != "" {
+				usage.Errored++
+			} else if entry.Drifted && !entry.Suppressed {
+				usage.Drifted++
+			}
+			usage.Stacks = append(usage.Stacks, entry)
+		}
+	}
+	writeJSON(w, http.StatusOK, usage)
+}
+
+func moduleUsageEntry(projectName string, st storage.StackStatus, result *storage.RunResult, source, ref string) (apiModuleUsageStack, bool) {
+	entry := apiModuleUsageStack{
+		ProjectName: projectName,
+		StackPath:   st.Path,
+		Drifted:     st.Drifted,
+		Suppressed:  st.Suppressed,
+		Error:       st.Error,
+		RunAt:       st.RunAt.Unix(),
+	}
+	for _, m := range result.ModuleSources {
+		if !m.Uses(source, ref) {
+			continue
+		}
+		_, pinned := stack.SplitModuleSource(m.Source, m.Version)
+		entry.Modules = append(entry.Modules, apiModuleUsageCall{Module: m.Module, Source: m.Source, Ref: pinned})
+	}
+	return entry, len(entry.Modules) > 0
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/stack"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestModuleUsage(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod", "envs/dev", "shared"}, false, nil, true, nil)
+	defer cleanup()
+
+	vpc := func(ref string) []stack.ModuleSource {
+		return []stack.ModuleSource{{Module: "module.vpc", Source: "git::https://github.com/org/modules.git//vpc?ref=" + ref}}
+	}
+	now := time.Now()
+	results := map[string]*storage.RunResult{
+		"envs/prod": {RunAt: now, Drifted: true, ModuleSources: vpc("v1.2.0")},
+		"envs/dev":  {RunAt: now, ModuleSources: vpc("v1.3.0")},
+		"shared":    {RunAt: now, ModuleSources: []stack.ModuleSource{{Module: "module.dns", Source: "../modules/dns"}}},
+	}
+	for path, result := range results {
+		if err := srv.storage.SaveResult("project", path, result); err != nil {
+			t.Fatalf("save result: %v", err)
+		}
+	}
+
+	get := func(query url.Values) (int, apiModuleUsage) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/api/modules/usage?" + query.Encode())
+		if err != nil {
+			t.Fatalf("module usage: %v", err)
+		}
+		defer resp.Body.Close()
+		var usage apiModuleUsage
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&usage); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return resp.StatusCode, usage
+	}
+
+	status, usage := get(url.Values{"source": {"https://github.com/org/modules.git"}})
+	if status != http.StatusOK || len(usage.Stacks) != 2 || usage.Drifted != 1 {
+		t.Fatalf("expected both vpc callers with one drifted, got %d %+v", status, usage)
+	}
+
+	_, usage = get(url.Values{"source": {"https://github.com/org/modules.git"}, "ref": {"v1.2.0"}})
+	if len(usage.Stacks) != 1 {
+		t.Fatalf("expected one stack at v1.2.0, got %+v", usage.Stacks)
+	}
+	got := usage.Stacks[0]
+	if got.ProjectName != "project" || got.StackPath != "envs/prod" || !got.Drifted || len(got.Modules) != 1 || got.Modules[0].Ref != "v1.2.0" {
+		t.Fatalf("unexpected stack: %+v", got)
+	}
+
+	if status, _ := get(url.Values{}); status != http.StatusBadRequest {
+		t.Fatalf("expected 400 without source, got %d", status)
+	}
+}
@@ -0,0 +1,330 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/gitauth"
+	"github.com/driftdhq/driftd/internal/secrets"
+	"github.com/driftdhq/driftd/internal/stack"
+	git "github.com/go-git/go-git/v5"
+	gitcfg "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+const (
+	// probeDepth is how many directory levels the probe looks at. Stacks
+	// deeper than this are found by the first scan, not the probe.
+	probeDepth   = 2
+	probeTimeout = time.Minute
+
+	// Projects with more candidate stacks than this are suggested a daily
+	// schedule instead of every six hours.
+	probeLargeProjectStacks = 50
+	probeDefaultSchedule    = "0 */6 * * *"
+	probeDailySchedule      = "0 3 * * *"
+)
+
+// probeIgnoreDirs are directory names that usually hold reusable modules or
+// test fixtures rather than deployable stacks.
+var probeIgnoreDirs = []string{"modules", "examples", "example", "test", "tests", "fixtures"}
+
+var invalidProjectNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+type onboardingProbeRequest struct {
+	URL           string `json:"url"`
+	IntegrationID string `json:"integration_id,omitempty"`
+	// Branch overrides the remote's default branch.
+	Branch string `json:"branch,omitempty"`
+}
+
+type onboardingProbeResponse struct {
+	DefaultBranch string   `json:"default_branch"`
+	Branches      []string `json:"branches"`
+	CommitSHA     string   `json:"commit_sha"`
+	// Depth is how many directory levels were searched for stacks.
+	Depth int `json:"depth"`
+	// Stacks are the candidate stacks left after the suggested
+	// ignore_paths; Ignored are those the suggestions leave out.
+	Stacks   []string `json:"stacks"`
+	Ignored  []string `json:"ignored,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	// Project is a prefilled request for POST /api/settings/projects.
+	Project ProjectRequest `json:"project"`
+}
+
+// handleProbeProject inspects a repository before it is added: it lists the
+// remote's branches, reads the top of the default branch for candidate
+// stacks and suggests project settings. Nothing is stored.
+func (s *Server) handleProbeProject(w http.ResponseWriter, r *http.Request) {
+	var req onboardingProbeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	req.URL = strings.TrimSpace(req.URL)
+	if req.URL == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "url is required"})
+		return
+	}
+
+	entry := &secrets.ProjectEntry{
+		Name:          suggestProjectName(req.URL),
+		URL:           req.URL,
+		IntegrationID: strings.TrimSpace(req.IntegrationID),
+	}
+	var integration *secrets.IntegrationEntry
+	if entry.IntegrationID != "" {
+		found, err := s.getIntegration(entry.IntegrationID)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "integration_id not found"})
+			return
+		}
+		integration = found
+	}
+	projectCfg, err := secrets.ProjectConfigFromEntry(entry, nil, integration, s.cfg.DataDir)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), probeTimeout)
+	defer cancel()
+	resp, err := probeRepository(ctx, projectCfg, strings.TrimSpace(req.Branch))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+
+	resp.Project.Name = entry.Name
+	resp.Project.URL = req.URL
+	if entry.IntegrationID != "" {
+		resp.Project.IntegrationID = &entry.IntegrationID
+	}
+	if entry.Name == "" {
+		resp.Warnings = append(resp.Warnings, "could not derive a project name from the URL")
+	} else if s.projectNameTaken(entry.Name) {
+		resp.Warnings = append(resp.Warnings, fmt.Sprintf("a project named %q already exists; choose another name", entry.Name))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) projectNameTaken(name string) bool {
+	if s.cfg.GetProject(name) != nil || name == config.CanaryProjectName {
+		return true
+	}
+	if s.projectStore != nil {
+		if _, err := s.projectStore.Get(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// probeRepository lists the remote's refs, fetches the tip of branch (the
+// default branch when empty) without a checkout and discovers candidate
+// stacks in its top directories.
+func probeRepository(ctx context.Context, projectCfg *config.ProjectConfig, branch string) (*onboardingProbeResponse, error) {
+	conn, err := gitauth.Connect(ctx, projectCfg)
+	if err != nil {
+		return nil, err
+	}
+	cloneURL := strings.TrimSpace(projectCfg.EffectiveCloneURL())
+
+	remote := git.NewRemote(memory.NewStorage(), &gitcfg.RemoteConfig{Name: "origin", URLs: []string{cloneURL}})
+	refs, err := remote.ListContext(ctx, &git.ListOptions{
+		Auth:         conn.Auth,
+		CABundle:     conn.CABundle,
+		ProxyOptions: conn.Proxy,
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp := &onboardingProbeResponse{Depth: probeDepth, Branches: []string{}}
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference {
+			resp.DefaultBranch = ref.Target().Short()
+		}
+		if ref.Name().IsBranch() {
+			resp.Branches = append(resp.Branches, ref.Name().Short())
+		}
+	}
+	sort.Strings(resp.Branches)
+	if len(resp.Branches) == 0 {
+		return nil, fmt.Errorf("repository has no branches")
+	}
+	if resp.DefaultBranch == "" {
+		resp.DefaultBranch = guessDefaultBranch(resp.Branches)
+	}
+	if branch == "" {
+		branch = resp.DefaultBranch
+	} else if !containsString(resp.Branches, branch) {
+		return nil, fmt.Errorf("branch %q not found in remote", branch)
+	}
+
+	repo, err := git.CloneContext(ctx, memory.NewStorage(), nil, &git.CloneOptions{
+		URL:           cloneURL,
+		Auth:          conn.Auth,
+		CABundle:      conn.CABundle,
+		ProxyOptions:  conn.Proxy,
+		ReferenceName: plumbing.NewBranchReferenceName(branch),
+		SingleBranch:  true,
+		Depth:         1,
+		NoCheckout:    true,
+		Tags:          git.NoTags,
+	})
+	if err != nil {
+		return nil, err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	if err := listTreeFiles(tree, "", probeDepth, &files); err != nil {
+		return nil, err
+	}
+	candidates, err := stack.DiscoverFiles(files, "", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.CommitSHA = head.Hash().String()
+	ignorePaths := suggestIgnorePaths(candidates)
+	resp.Stacks, err = stack.DiscoverFiles(files, "", ignorePaths)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Stacks == nil {
+		resp.Stacks = []string{}
+	}
+	rootPath := suggestRootPath(resp.Stacks)
+	kept := make(map[string]struct{}, len(resp.Stacks))
+	for _, st := range resp.Stacks {
+		kept[st] = struct{}{}
+	}
+	for _, st := range candidates {
+		if _, ok := kept[st]; !ok {
+			resp.Ignored = append(resp.Ignored, st)
+		}
+	}
+	if len(candidates) == 0 {
+		resp.Warnings = append(resp.Warnings, fmt.Sprintf("no stacks found in the top %d directory levels; the first scan searches the whole repository", probeDepth))
+	}
+
+	schedule := probeDefaultSchedule
+	if len(resp.Stacks) > probeLargeProjectStacks {
+		schedule = probeDailySchedule
+	}
+	resp.Project = ProjectRequest{
+		Schedule:    &schedule,
+		IgnorePaths: ignorePaths,
+	}
+	if branch != resp.DefaultBranch {
+		resp.Project.Branch = &branch
+	}
+	if rootPath != "" {
+		resp.Project.RootPath = &rootPath
+	}
+	return resp, nil
+}
+
+// listTreeFiles appends the paths of the files in tree, descending at most
+// depth directories.
+func listTreeFiles(tree *object.Tree, prefix string, depth int, files *[]string) error {
+	for _, entry := range tree.Entries {
+		name := path.Join(prefix, entry.Name)
+		if entry.Mode != filemode.Dir {
+			*files = append(*files, name)
+			continue
+		}
+		if depth == 0 {
+			continue
+		}
+		sub, err := tree.Tree(entry.Name)
+		if err != nil {
+			return err
+		}
+		if err := listTreeFiles(sub, name, depth-1, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// guessDefaultBranch is used when the remote does not advertise HEAD.
+func guessDefaultBranch(branches []string) string {
+	for _, name := range []string{"main", "master"} {
+		if containsString(branches, name) {
+			return name
+		}
+	}
+	return branches[0]
+}
+
+// suggestProjectName derives a project name from the last path element of
+// a repository URL.
+func suggestProjectName(rawURL string) string {
+	name := strings.TrimSuffix(strings.TrimRight(rawURL, "/"), ".git")
+	if i := strings.LastIndexAny(name, "/:"); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.Trim(invalidProjectNameChars.ReplaceAllString(name, "-"), "-")
+}
+
+// suggestRootPath returns the top-level directory holding every candidate
+// stack, if there is exactly one.
+func suggestRootPath(stacks []string) string {
+	root := ""
+	for _, st := range stacks {
+		top, _, nested := strings.Cut(st, "/")
+		if !nested || (root != "" && top != root) {
+			return ""
+		}
+		root = top
+	}
+	return root
+}
+
+// suggestIgnorePaths returns ignore patterns for the module and test
+// directories that hold candidate stacks.
+func suggestIgnorePaths(stacks []string) []string {
+	var patterns []string
+	for _, dir := range probeIgnoreDirs {
+		for _, st := range stacks {
+			if containsString(strings.Split(st, "/"), dir) {
+				patterns = append(patterns, "**/"+dir+"/**")
+				break
+			}
+		}
+	}
+	return patterns
+}
+
+func containsString(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/secrets"
+)
+
+func TestProbeProjectSuggestsSettings(t *testing.T) {
+	var repoDir string
+	srv, ts, _, cleanup := newTestServerWithProjectStore(t, &fakeRunner{}, []string{"infra/dev", "infra/prod", "modules/vpc", "deep/a/b/c"}, false, func(store *secrets.ProjectStore, intStore *secrets.IntegrationStore, projectDir string) {
+		repoDir = projectDir
+		if err := intStore.Add(&secrets.IntegrationEntry{ID: "int-1", Name: "main", Type: "https", HTTPS: &secrets.IntegrationHTTPS{TokenEnv: "GIT_TOKEN"}}); err != nil {
+			t.Fatalf("add integration: %v", err)
+		}
+	}, nil)
+	defer cleanup()
+
+	probe := func(body map[string]string) (*http.Response, onboardingProbeResponse) {
+		t.Helper()
+		raw, _ := json.Marshal(body)
+		resp, err := http.Post(ts.URL+"/api/settings/onboarding/probe", "application/json", bytes.NewReader(raw))
+		if err != nil {
+			t.Fatalf("probe: %v", err)
+		}
+		defer resp.Body.Close()
+		var out onboardingProbeResponse
+		_ = json.NewDecoder(resp.Body).Decode(&out)
+		return resp, out
+	}
+
+	resp, got := probe(map[string]string{"url": repoDir})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	if got.DefaultBranch != "master" || len(got.CommitSHA) != 40 {
+		t.Fatalf("unexpected branch or commit: %+v", got)
+	}
+	if !reflect.DeepEqual(got.Stacks, []string{"infra/dev", "infra/prod"}) {
+		t.Fatalf("unexpected stacks: %v", got.Stacks)
+	}
+	if !reflect.DeepEqual(got.Ignored, []string{"modules/vpc"}) {
+		t.Fatalf("unexpected ignored stacks: %v", got.Ignored)
+	}
+	p := got.Project
+	if p.URL != repoDir || p.Name == "" || p.Branch != nil {
+		t.Fatalf("unexpected project request: %+v", p)
+	}
+	if p.RootPath == nil || *p.RootPath != "infra" {
+		t.Fatalf("expected root_path infra, got %v", p.RootPath)
+	}
+	if !reflect.DeepEqual(p.IgnorePaths, []string{"**/modules/**"}) {
+		t.Fatalf("unexpected ignore_paths: %v", p.IgnorePaths)
+	}
+	if p.Schedule == nil || *p.Schedule != probeDefaultSchedule {
+		t.Fatalf("unexpected schedule: %v", p.Schedule)
+	}
+
+	// The suggestion creates the project as is once an integration is chosen.
+	p.Name = "onboarded"
+	integrationID := "int-1"
+	p.IntegrationID = &integrationID
+	raw, _ := json.Marshal(p)
+	createResp, err := http.Post(ts.URL+"/api/settings/projects", "application/json", bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	createResp.Body.Close()
+	if createResp.StatusCode != http.StatusCreated && createResp.StatusCode != http.StatusOK {
+		t.Fatalf("expected project to be created, got %d", createResp.StatusCode)
+	}
+	projectCfg, err := srv.getProjectConfig("onboarded")
+	if err != nil {
+		t.Fatalf("get project: %v", err)
+	}
+	if projectCfg.RootPath != "infra" || projectCfg.Schedule != probeDefaultSchedule {
+		t.Fatalf("unexpected created project: %+v", projectCfg)
+	}
+
+	if resp, _ := probe(map[string]string{"url": repoDir, "branch": "missing"}); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown branch, got %d", resp.StatusCode)
+	}
+	if resp, _ := probe(map[string]string{"url": repoDir, "integration_id": "nope"}); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown integration, got %d", resp.StatusCode)
+	}
+	if resp, _ := probe(map[string]string{}); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a url, got %d", resp.StatusCode)
+	}
+}
+
+func TestCreateProjectRejectsUnsafeRootPath(t *testing.T) {
+	_, ts, _, cleanup := newTestServerWithProjectStore(t, &fakeRunner{}, nil, false, func(store *secrets.ProjectStore, intStore *secrets.IntegrationStore, projectDir string) {
+		if err := intStore.Add(&secrets.IntegrationEntry{ID: "int-1", Name: "main", Type: "https", HTTPS: &secrets.IntegrationHTTPS{TokenEnv: "GIT_TOKEN"}}); err != nil {
+			t.Fatalf("add integration: %v", err)
+		}
+	}, nil)
+	defer cleanup()
+
+	raw := []byte(`{"name":"escape","url":"https://example.com/escape.git","integration_id":"int-1","root_path":"../etc"}`)
+	resp, err := http.Post(ts.URL+"/api/settings/projects", "application/json", bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func TestSuggestProjectName(t *testing.T) {
+	cases := map[string]string{
+		"https://github.com/org/infra-live.git": "infra-live",
+		"git@github.com:org/platform.git":       "platform",
+		"https://example.com/team/My Repo/":     "My-Repo",
+	}
+	for url, want := range cases {
+		if got := suggestProjectName(url); got != want {
+			t.Errorf("suggestProjectName(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultOutboxLimit = 100
+	maxOutboxLimit     = 1000
+)
+
+var outboxConsumerPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type outboxResponse struct {
+	Events []queue.OutboxEvent `json:"events"`
+	// Next is the ID to commit once every returned event is handled, and
+	// to pass as after for the next page.
+	Next string `json:"next"`
+}
+
+type outboxOffsetRequest struct {
+	ID string `json:"id"`
+}
+
+type outboxOffsetResponse struct {
+	Consumer string `json:"consumer"`
+	Offset   string `json:"offset"`
+}
+
+// handleReadOutbox returns outbox events after ?after=, or after the
+// committed offset of ?consumer= when after is not given.
+func (s *Server) handleReadOutbox(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	projectName := query.Get("project")
+	if projectName != "" && !isValidProjectName(projectName) {
+		http.Error(w, "Invalid project name", http.StatusBadRequest)
+		return
+	}
+	limit := defaultOutboxLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxOutboxLimit {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	after := query.Get("after")
+	if consumer := query.Get("consumer"); consumer != "" && after == "" {
+		if !outboxConsumerPattern.MatchString(consumer) {
+			http.Error(w, "Invalid consumer name", http.StatusBadRequest)
+			return
+		}
+		offset, err := s.queue.OutboxOffset(r.Context(), consumer)
+		if err != nil {
+			s.writeOutboxError(w, err)
+			return
+		}
+		after = offset
+	}
+
+	events, next, err := s.queue.ReadOutbox(r.Context(), projectName, after, limit)
+	if err != nil {
+		s.writeOutboxError(w, err)
+		return
+	}
+	if events == nil {
+		events = []queue.OutboxEvent{}
+	}
+	writeJSON(w, http.StatusOK, outboxResponse{Events: events, Next: next})
+}
+
+func (s *Server) handleOutboxStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := s.queue.OutboxStatus(r.Context())
+	if err != nil {
+		s.writeOutboxError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleCommitOutboxOffset records the last event a consumer handled.
+func (s *Server) handleCommitOutboxOffset(w http.ResponseWriter, r *http.Request) {
+	consumer := chi.URLParam(r, "consumer")
+	if !outboxConsumerPattern.MatchString(consumer) {
+		http.Error(w, "Invalid consumer name", http.StatusBadRequest)
+		return
+	}
+	var req outboxOffsetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := s.queue.CommitOutboxOffset(r.Context(), consumer, req.ID); err != nil {
+		s.writeOutboxError(w, err)
+		return
+	}
+	offset, err := s.queue.OutboxOffset(r.Context(), consumer)
+	if err != nil {
+		s.writeOutboxError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, outboxOffsetResponse{Consumer: consumer, Offset: offset})
+}
+
+func (s *Server) handleDeleteOutboxConsumer(w http.ResponseWriter, r *http.Request) {
+	consumer := chi.URLParam(r, "consumer")
+	if !outboxConsumerPattern.MatchString(consumer) {
+		http.Error(w, "Invalid consumer name", http.StatusBadRequest)
+		return
+	}
+	if err := s.queue.DeleteOutboxConsumer(r.Context(), consumer); err != nil {
+		s.writeOutboxError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) writeOutboxError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, queue.ErrInvalidOutboxID):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+func TestOutboxConsumerFlow(t *testing.T) {
+	ts, q, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, path := range []string{"envs/a", "envs/b"} {
+		if err := q.PublishStackEvent(ctx, "project", queue.StackEvent{StackPath: path, Status: queue.StatusCompleted}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+	}
+
+	read := func() outboxResponse {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/api/outbox?consumer=mirror&limit=1")
+		if err != nil {
+			t.Fatalf("read outbox: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var out outboxResponse
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return out
+	}
+	commit := func(id string) int {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+"/api/outbox/consumers/mirror/offset", strings.NewReader(`{"id":"`+id+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("commit: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	first := read()
+	if len(first.Events) != 1 || first.Events[0].Event.StackPath != "envs/a" {
+		t.Fatalf("unexpected first page: %+v", first)
+	}
+	// Without a commit the consumer is handed the same event again.
+	if again := read(); again.Next != first.Next {
+		t.Fatalf("expected redelivery before commit, got %+v", again)
+	}
+	if code := commit(first.Next); code != http.StatusOK {
+		t.Fatalf("expected 200 for commit, got %d", code)
+	}
+	second := read()
+	if len(second.Events) != 1 || second.Events[0].Event.StackPath != "envs/b" {
+		t.Fatalf("unexpected second page: %+v", second)
+	}
+	if code := commit("bogus"); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an invalid id, got %d", code)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/outbox/consumers")
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	defer resp.Body.Close()
+	var status queue.OutboxStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if status.Length != 2 || len(status.Consumers) != 1 || status.Consumers[0].Offset != first.Next {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
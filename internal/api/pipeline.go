@@ -0,0 +1,225 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultPipelineScans = 10
+	maxPipelineScans     = 50
+
+	// Phases derived from a scan's stack scans rather than recorded by the
+	// orchestrator.
+	pipelinePhaseQueueWait = "queue_wait"
+	pipelinePhaseStacks    = "stacks"
+)
+
+type apiPipeline struct {
+	ProjectName string            `json:"project_name"`
+	Scans       []apiPipelineScan `json:"scans"`
+}
+
+// apiPipelineScan is one scan's timeline. Phase offsets are relative to the
+// start of its first phase.
+type apiPipelineScan struct {
+	ScanID              string             `json:"scan_id"`
+	Status              string             `json:"status"`
+	Trigger             string             `json:"trigger,omitempty"`
+	StartedAt           time.Time          `json:"started_at"`
+	EndedAt             *time.Time         `json:"ended_at,omitempty"`
+	Stacks              int                `json:"stacks"`
+	FirstStackStartedAt *time.Time         `json:"first_stack_started_at,omitempty"`
+	LastStackEndedAt    *time.Time         `json:"last_stack_ended_at,omitempty"`
+	DurationMs          int64              `json:"duration_ms"`
+	Phases              []apiPipelinePhase `json:"phases"`
+}
+
+type apiPipelinePhase struct {
+	Name       string    `json:"name"`
+	StartedAt  time.Time `json:"started_at"`
+	EndedAt    time.Time `json:"ended_at"`
+	OffsetMs   int64     `json:"offset_ms"`
+	DurationMs int64     `json:"duration_ms"`
+}
+
+type pipelinePageData struct {
+	pageAuth
+	Name  string
+	Scans []pipelineScanView
+}
+
+type pipelineScanView struct {
+	apiPipelineScan
+	Phases []pipelinePhaseView
+}
+
+// pipelinePhaseView places a phase on the waterfall as percentages of the
+// scan's timeline.
+type pipelinePhaseView struct {
+	apiPipelinePhase
+	LeftPct  float64
+	WidthPct float64
+}
+
+func (s *Server) handleProjectPipeline(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		http.Error(w, "Invalid project name", http.StatusBadRequest)
+		return
+	}
+	limit, err := parsePipelineLimit(r.URL.Query().Get("limit"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pipeline, err := s.buildPipeline(r.Context(), projectName, limit)
+	if err != nil {
+		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, pipeline)
+}
+
+func (s *Server) handleProjectPipelineUI(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		http.Error(w, "Invalid project name", http.StatusBadRequest)
+		return
+	}
+
+	data := pipelinePageData{pageAuth: s.pageAuth(r), Name: projectName}
+	// Scan timelines only exist in the queue, so a read-only server shows
+	// the stale data banner alone.
+	if !data.Degraded.Degraded {
+		pipeline, err := s.buildPipeline(r.Context(), projectName, defaultPipelineScans)
+		if err != nil {
+			http.Error(w, "Failed to load scans", http.StatusInternalServerError)
+			return
+		}
+		for _, scan := range pipeline.Scans {
+			data.Scans = append(data.Scans, newPipelineScanView(scan))
+		}
+	}
+	if err := s.tmplPipeline.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("template error: %v", err)
+	}
+}
+
+func parsePipelineLimit(raw string) (int, error) {
+	if raw == "" {
+		return defaultPipelineScans, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 1 || limit > maxPipelineScans {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPipelineScans)
+	}
+	return limit, nil
+}
+
+// buildPipeline returns the timelines of a project's most recent scans,
+// newest first.
+func (s *Server) buildPipeline(ctx context.Context, projectName string, limit int) (*apiPipeline, error) {
+	scans, err := s.queue.ListProjectScans(ctx, projectName, limit)
+	if err != nil {
+		return nil, err
+	}
+	pipeline := &apiPipeline{ProjectName: projectName, Scans: []apiPipelineScan{}}
+	for _, scan := range scans {
+		stackScans, err := s.queue.ListScanStackScans(ctx, scan.ID)
+		if err != nil {
+			return nil, err
+		}
+		pipeline.Scans = append(pipeline.Scans, buildPipelineScan(scan, stackScans))
+	}
+	return pipeline, nil
+}
+
+// buildPipelineScan lays out a scan's recorded setup phases followed by the
+// time its stacks waited in the queue and the time they ran.
+func buildPipelineScan(scan *queue.Scan, stackScans []*queue.StackScan) apiPipelineScan {
+	out := apiPipelineScan{
+		ScanID:    scan.ID,
+		Status:    scan.Status,
+		Trigger:   scan.Trigger,
+		StartedAt: scan.StartedAt,
+		Stacks:    len(stackScans),
+		Phases:    []apiPipelinePhase{},
+	}
+	if scan.EndedAt.Unix() > 0 {
+		ended := scan.EndedAt
+		out.EndedAt = &ended
+	}
+
+	phases := append([]queue.ScanPhase(nil), scan.Phases...)
+	var firstStart, lastEnd time.Time
+	for _, ss := range stackScans {
+		if !ss.StartedAt.IsZero() && (firstStart.IsZero() || ss.StartedAt.Before(firstStart)) {
+			firstStart = ss.StartedAt
+		}
+		if !ss.CompletedAt.IsZero() && ss.CompletedAt.After(lastEnd) {
+			lastEnd = ss.CompletedAt
+		}
+	}
+	if !firstStart.IsZero() {
+		out.FirstStackStartedAt = &firstStart
+		if len(phases) > 0 {
+			setupEnd := phases[len(phases)-1].EndedAt
+			if firstStart.After(setupEnd) {
+				phases = append(phases, queue.ScanPhase{Name: pipelinePhaseQueueWait, StartedAt: setupEnd, EndedAt: firstStart})
+			}
+		}
+		if lastEnd.After(firstStart) {
+			out.LastStackEndedAt = &lastEnd
+			phases = append(phases, queue.ScanPhase{Name: pipelinePhaseStacks, StartedAt: firstStart, EndedAt: lastEnd})
+		}
+	}
+	if len(phases) == 0 {
+		return out
+	}
+
+	base := phases[0].StartedAt
+	var end time.Time
+	for _, phase := range phases {
+		out.Phases = append(out.Phases, apiPipelinePhase{
+			Name:       phase.Name,
+			StartedAt:  phase.StartedAt,
+			EndedAt:    phase.EndedAt,
+			OffsetMs:   phase.StartedAt.Sub(base).Milliseconds(),
+			DurationMs: phase.Duration().Milliseconds(),
+		})
+		if phase.EndedAt.After(end) {
+			end = phase.EndedAt
+		}
+	}
+	out.DurationMs = end.Sub(base).Milliseconds()
+	return out
+}
+
+func newPipelineScanView(scan apiPipelineScan) pipelineScanView {
+	view := pipelineScanView{apiPipelineScan: scan}
+	for _, phase := range scan.Phases {
+		pv := pipelinePhaseView{apiPipelinePhase: phase}
+		if scan.DurationMs > 0 {
+			pv.LeftPct = float64(phase.OffsetMs) * 100 / float64(scan.DurationMs)
+			pv.WidthPct = float64(phase.DurationMs) * 100 / float64(scan.DurationMs)
+		}
+		// Keep sub-percent phases visible.
+		if pv.WidthPct < 0.5 {
+			pv.WidthPct = 0.5
+		}
+		if pv.LeftPct+pv.WidthPct > 100 {
+			pv.LeftPct = 100 - pv.WidthPct
+		}
+		view.Phases = append(view.Phases, pv)
+	}
+	return view
+}
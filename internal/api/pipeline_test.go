@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+func TestProjectPipeline(t *testing.T) {
+	ts, _, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod", "envs/dev"}, true, nil, true)
+	defer cleanup()
+
+	resp, err := http.Post(ts.URL+"/api/projects/project/scan", "application/json", bytes.NewBufferString(`{}`))
+	if err != nil {
+		t.Fatalf("scan request failed: %v", err)
+	}
+	var sr scanResp
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	resp.Body.Close()
+	if scan := waitForScan(t, ts, sr.Scan.ID, 5*time.Second); scan.Status != queue.ScanStatusCompleted {
+		t.Fatalf("expected completed, got %s", scan.Status)
+	}
+
+	resp, err = http.Get(ts.URL + "/api/projects/project/pipeline")
+	if err != nil {
+		t.Fatalf("pipeline: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var pipeline apiPipeline
+	if err := json.NewDecoder(resp.Body).Decode(&pipeline); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(pipeline.Scans) != 1 || pipeline.Scans[0].ScanID != sr.Scan.ID {
+		t.Fatalf("expected the completed scan, got %+v", pipeline.Scans)
+	}
+	scan := pipeline.Scans[0]
+	if scan.Stacks != 2 || scan.FirstStackStartedAt == nil || scan.LastStackEndedAt == nil {
+		t.Fatalf("expected stack timings, got %+v", scan)
+	}
+	names := map[string]bool{}
+	var prevOffset int64
+	for _, phase := range scan.Phases {
+		names[phase.Name] = true
+		if phase.OffsetMs < prevOffset || phase.DurationMs < 0 {
+			t.Fatalf("phases out of order: %+v", scan.Phases)
+		}
+		prevOffset = phase.OffsetMs
+	}
+	for _, name := range []string{queue.PhaseLock, queue.PhaseClone, queue.PhaseDiscover, queue.PhaseVersions, queue.PhaseEnqueue, pipelinePhaseStacks} {
+		if !names[name] {
+			t.Fatalf("expected phase %s, got %+v", name, scan.Phases)
+		}
+	}
+
+	bad, err := http.Get(ts.URL + "/api/projects/project/pipeline?limit=0")
+	if err != nil {
+		t.Fatalf("pipeline: %v", err)
+	}
+	bad.Body.Close()
+	if bad.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for limit=0, got %d", bad.StatusCode)
+	}
+}
+
+func TestBuildPipelineScanAddsQueueWait(t *testing.T) {
+	base := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	scan := &queue.Scan{
+		ID:        "project:1",
+		Status:    queue.ScanStatusCompleted,
+		StartedAt: base,
+		EndedAt:   base.Add(time.Minute),
+		Phases: []queue.ScanPhase{
+			{Name: queue.PhaseLock, StartedAt: base, EndedAt: base.Add(time.Second)},
+			{Name: queue.PhaseClone, StartedAt: base.Add(time.Second), EndedAt: base.Add(10 * time.Second)},
+			{Name: queue.PhaseEnqueue, StartedAt: base.Add(10 * time.Second), EndedAt: base.Add(11 * time.Second)},
+		},
+	}
+	stackScans := []*queue.StackScan{
+		{ScanID: scan.ID, StartedAt: base.Add(20 * time.Second), CompletedAt: base.Add(40 * time.Second)},
+		{ScanID: scan.ID, StartedAt: base.Add(25 * time.Second), CompletedAt: base.Add(50 * time.Second)},
+	}
+
+	got := buildPipelineScan(scan, stackScans)
+	if len(got.Phases) != 5 {
+		t.Fatalf("expected 5 phases, got %+v", got.Phases)
+	}
+	wait := got.Phases[3]
+	if wait.Name != pipelinePhaseQueueWait || wait.OffsetMs != 11000 || wait.DurationMs != 9000 {
+		t.Fatalf("unexpected queue wait: %+v", wait)
+	}
+	stacks := got.Phases[4]
+	if stacks.Name != pipelinePhaseStacks || stacks.DurationMs != 30000 {
+		t.Fatalf("unexpected stacks phase: %+v", stacks)
+	}
+	if got.DurationMs != 50000 {
+		t.Fatalf("expected 50s timeline, got %d ms", got.DurationMs)
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/runner"
+)
+
+// planAttributePattern matches "<marker> name = value" lines inside a
+// resource block, where name may be quoted (map keys).
+var planAttributePattern = regexp.MustCompile(`^(\s*(?:[-+~]|-/\+|\+/-)?\s*)("[^"]*"|[A-Za-z_][A-Za-z0-9_-]*)(\s*=\s*)(.*)$`)
+
+// planBlock is one resource change within plan output. Lines are 1-based and
+// inclusive, counted in the plan text the block was grouped from; StartLine is
+// the "# <address> ..." header.
+type planBlock struct {
+	Address   string `json:"address"`
+	Action    string `json:"action"`
+	Anchor    string `json:"anchor"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+}
+
+// groupPlanBlocks finds the resource blocks in ANSI-free plan lines. A block
+// runs from its header to the brace closing the resource body. Headers with
+// no body (imports, moves, forgets) end at the last line before the next
+// header or unindented text.
+func groupPlanBlocks(lines []string) []planBlock {
+	var blocks []planBlock
+	anchors := map[string]int{}
+	var cur *planBlock
+	closeCol := -1
+	lastContent := 0
+
+	finish := func(end int) {
+		cur.EndLine = end
+		blocks = append(blocks, *cur)
+		cur = nil
+	}
+
+	for i, line := range lines {
+		n := i + 1
+		if rc, ok := runner.ParseResourceHeader(line); ok {
+			if cur != nil {
+				finish(lastContent)
+			}
+			cur = &planBlock{
+				Address:   rc.Address,
+				Action:    rc.Action,
+				Anchor:    planAnchor(rc.Address, anchors),
+				StartLine: n,
+			}
+			closeCol = -1
+			lastContent = n
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			continue
+		case closeCol < 0 && strings.HasSuffix(trimmed, "{"):
+			// The closing brace lines up with the keyword after the
+			// action marker: "  ~ resource ... {" closes with "    }".
+			closeCol = len(line) - len(strings.TrimLeft(strings.TrimLeft(strings.TrimLeft(line, " \t"), "-+~/<="), " "))
+		case closeCol >= 0 && trimmed == "}" && lineIndent(line) == closeCol:
+			finish(n)
+			continue
+		case closeCol < 0 && lineIndent(line) == 0:
+			finish(lastContent)
+			continue
+		}
+		lastContent = n
+	}
+	if cur != nil {
+		finish(lastContent)
+	}
+	return blocks
+}
+
+func lineIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// planAnchor turns a resource address into an element ID, numbering repeats
+// such as deposed objects.
+func planAnchor(address string, seen map[string]int) string {
+	var b strings.Builder
+	b.WriteString("resource-")
+	dash := false
+	for _, r := range address {
+		ok := r == '.' || r == '_' || r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+		if !ok {
+			if !dash {
+				b.WriteByte('-')
+			}
+			dash = true
+			continue
+		}
+		b.WriteRune(r)
+		dash = false
+	}
+	anchor := strings.TrimRight(b.String(), "-")
+	seen[anchor]++
+	if n := seen[anchor]; n > 1 {
+		anchor += "-" + strconv.Itoa(n)
+	}
+	return anchor
+}
+
+// writePlanLine renders one line as a block element. Lines inside resource
+// blocks also get attribute names and values highlighted.
+func writePlanLine(b *strings.Builder, line string, inBlock bool) {
+	b.WriteString(`<span class="plan-line`)
+	if class := planLineClass(line); class != "" {
+		b.WriteString(" ")
+		b.WriteString(class)
+	}
+	b.WriteString(`">`)
+	if m := planAttributePattern.FindStringSubmatch(line); inBlock && m != nil {
+		b.WriteString(html.EscapeString(m[1]))
+		b.WriteString(`<span class="plan-attr">`)
+		b.WriteString(html.EscapeString(m[2]))
+		b.WriteString(`</span>`)
+		b.WriteString(html.EscapeString(m[3]))
+		if before, after, ok := strings.Cut(m[4], " -> "); ok {
+			writePlanValue(b, before)
+			b.WriteString(` <span class="plan-arrow">-&gt;</span> `)
+			writePlanValue(b, after)
+		} else {
+			writePlanValue(b, m[4])
+		}
+	} else {
+		b.WriteString(html.EscapeString(line))
+	}
+	b.WriteString("</span>")
+}
+
+func writePlanValue(b *strings.Builder, value string) {
+	if value == "" {
+		return
+	}
+	b.WriteString(`<span class="plan-value">`)
+	b.WriteString(html.EscapeString(value))
+	b.WriteString(`</span>`)
+}
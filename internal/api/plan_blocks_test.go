@@ -0,0 +1,105 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+const blockTestPlan = `Terraform will perform the following actions:
+
+  # aws_instance.web will be updated in-place
+  ~ resource "aws_instance" "web" {
+        id            = "i-123"
+      ~ instance_type = "t2.micro" -> "t2.small"
+      ~ tags          = {
+          + "Owner" = "ops"
+        }
+    }
+
+  # aws_security_group.sg must be replaced
+-/+ resource "aws_security_group" "sg" {
+      ~ name = "old" -> "new" # forces replacement
+    }
+
+  # aws_instance.imported will be imported
+  # aws_instance.old (deposed object 1a2b3c4d) will be destroyed
+  # aws_instance.old (deposed object 5e6f7a8b) will be destroyed
+
+Plan: 1 to import, 1 to add, 1 to change, 3 to destroy.
+`
+
+func TestGroupPlanBlocks(t *testing.T) {
+	got := groupPlanBlocks(strings.Split(blockTestPlan, "\n"))
+	want := []planBlock{
+		{Address: "aws_instance.web", Action: "update", Anchor: "resource-aws_instance.web", StartLine: 3, EndLine: 10},
+		{Address: "aws_security_group.sg", Action: "replace", Anchor: "resource-aws_security_group.sg", StartLine: 12, EndLine: 15},
+		{Address: "aws_instance.imported", Action: "import", Anchor: "resource-aws_instance.imported", StartLine: 17, EndLine: 17},
+		{Address: "aws_instance.old", Action: "delete", Anchor: "resource-aws_instance.old", StartLine: 18, EndLine: 18},
+		{Address: "aws_instance.old", Action: "delete", Anchor: "resource-aws_instance.old-2", StartLine: 19, EndLine: 19},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected blocks:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestPlanAnchorSanitizesAddresses(t *testing.T) {
+	seen := map[string]int{}
+	if got := planAnchor(`module.app["eu west"].aws_s3_bucket.logs`, seen); got != "resource-module.app-eu-west-.aws_s3_bucket.logs" {
+		t.Fatalf("unexpected anchor %q", got)
+	}
+}
+
+func TestFormatPlanOutputBlocks(t *testing.T) {
+	out := string(formatPlanOutput(blockTestPlan))
+	for _, want := range []string{
+		`<details class="plan-block" id="resource-aws_instance.web" data-action="update" open><summary>`,
+		`<a class="plan-anchor" href="#resource-aws_instance.web" aria-label="Link to aws_instance.web">#</a></summary>`,
+		`<span class="plan-attr">instance_type</span> = <span class="plan-value">&#34;t2.micro&#34;</span> <span class="plan-arrow">-&gt;</span> <span class="plan-value">&#34;t2.small&#34;</span>`,
+		`<span class="plan-attr">&#34;Owner&#34;</span>`,
+		`<span class="plan-line">Plan: 1 to import, 1 to add, 1 to change, 3 to destroy.</span>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "<details") != 5 || strings.Count(out, "</details>") != 5 {
+		t.Fatalf("expected five blocks:\n%s", out)
+	}
+	// Text outside blocks is not treated as attributes.
+	if strings.Contains(out, `<span class="plan-attr">Terraform`) {
+		t.Fatalf("unexpected attribute highlight outside a block")
+	}
+}
+
+func TestStackPlanAPIReturnsBlocks(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, nil)
+	defer cleanup()
+
+	plan := "\x1b[1m" + blockTestPlan
+	if err := srv.storage.SaveResult("project", "envs/prod", &storage.RunResult{Drifted: true, PlanOutput: plan, RunAt: time.Now()}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/projects/project/stacks/envs/prod/plan")
+	if err != nil {
+		t.Fatalf("get plan: %v", err)
+	}
+	defer resp.Body.Close()
+	var got apiStackPlan
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode plan: %v", err)
+	}
+	if len(got.PlanBlocks) != 5 {
+		t.Fatalf("expected 5 plan blocks, got %+v", got.PlanBlocks)
+	}
+	lines := strings.Split(got.Plan, "\n")
+	if first := got.PlanBlocks[0]; !strings.Contains(lines[first.StartLine-1], "# aws_instance.web") || strings.TrimSpace(lines[first.EndLine-1]) != "}" {
+		t.Fatalf("block lines do not match the plan text: %+v", first)
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/driftdhq/driftd/internal/pathutil"
+	"github.com/driftdhq/driftd/internal/severity"
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+// planView is a plan trimmed for inline display. When the plan exceeds the
+// inline limit, Head and Tail hold the first and last lines and the middle
+// is dropped.
+type planView struct {
+	Head         string
+	Tail         string
+	TotalBytes   int
+	OmittedBytes int
+	Truncated    bool
+}
+
+// Inline joins the head and tail with a marker noting the omitted bytes.
+func (p planView) Inline() string {
+	if !p.Truncated {
+		return p.Head
+	}
+	return p.Head + fmt.Sprintf("\n... [%d bytes omitted; download the raw plan for full output] ...\n\n", p.OmittedBytes) + p.Tail
+}
+
+// truncatePlan splits plans larger than limit into a head and tail of roughly
+// limit/2 bytes each, cut on line boundaries where possible.
+func truncatePlan(plan string, limit int) planView {
+	view := planView{Head: plan, TotalBytes: len(plan)}
+	if limit <= 0 || len(plan) <= limit {
+		return view
+	}
+	half := limit / 2
+
+	head := plan[:half]
+	if idx := strings.LastIndexByte(head, '\n'); idx > 0 {
+		head = head[:idx+1]
+	}
+	for len(head) > 0 && !utf8.RuneStart(plan[len(head)]) {
+		head = head[:len(head)-1]
+	}
+
+	tailStart := len(plan) - half
+	if idx := strings.IndexByte(plan[tailStart:], '\n'); idx >= 0 && tailStart+idx+1 < len(plan) {
+		tailStart += idx + 1
+	}
+	for tailStart < len(plan) && !utf8.RuneStart(plan[tailStart]) {
+		tailStart++
+	}
+
+	view.Head = head
+	view.Tail = plan[tailStart:]
+	view.OmittedBytes = tailStart - len(head)
+	view.Truncated = true
+	return view
+}
+
+func rawPlanURL(projectName, stackPath, scanID string) string {
+	u := "/api/projects/" + projectName + "/stacks/" + stackPath + "/plan/raw"
+	if scanID != "" {
+		u += "?scan=" + url.QueryEscape(scanID)
+	}
+	return u
+}
+
+// handleStackPlan serves GET /api/projects/{project}/stacks/{stack}/plan and
+// /plan/raw. Stack paths contain slashes, so the suffix is parsed by hand.
+// ?scan=<id> serves the result as recorded by that scan.
+func (s *Server) handleStackPlan(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	rest := chi.URLParam(r, "*")
+
+	raw := false
+	var stackPath string
+	switch {
+	case strings.HasSuffix(rest, "/plan/raw"):
+		stackPath = strings.TrimSuffix(rest, "/plan/raw")
+		raw = true
+	case strings.HasSuffix(rest, "/plan"):
+		stackPath = strings.TrimSuffix(rest, "/plan")
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	if !isValidProjectName(projectName) || !pathutil.IsSafeStackPath(stackPath) {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	scanID := strings.TrimSpace(r.URL.Query().Get("scan"))
+	result, err := s.stackResult(projectName, stackPath, scanID)
+	if err != nil {
+		if errors.Is(err, storage.ErrScanResultNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no result retained for this scan"})
+			return
+		}
+		http.Error(w, "Stack not found", http.StatusNotFound)
+		return
+	}
+
+	if raw {
+		writeRawPlan(w, stackPath, result)
+		return
+	}
+
+	view := truncatePlan(result.PlanOutput, s.cfg.API.MaxInlinePlanBytes)
+	inline := view.Inline()
+	score := s.severity.ScoreResult(result)
+	projectCfg, _ := s.getProjectConfig(projectName)
+	display := projectCfg.StackDisplay(stackPath)
+	stackLinks, resourceLinks := stackConsoleLinks(projectCfg, stackPath, result)
+	writeJSON(w, http.StatusOK, &apiStackPlan{
+		ProjectName:         projectName,
+		StackPath:           stackPath,
+		DisplayName:         display.Name,
+		Group:               display.Group,
+		Drifted:             result.Drifted,
+		NoisyClean:          result.NoisyClean,
+		RootCauseHint:       result.RootCauseHint,
+		Added:               result.Added,
+		Changed:             result.Changed,
+		Destroyed:           result.Destroyed,
+		Severity:            score,
+		SeverityLevel:       severity.Level(score),
+		Error:               result.Error,
+		RunAt:               result.RunAt.Unix(),
+		Tags:                result.Tags,
+		ProviderLockDrift:   result.ProviderLockDrift,
+		ModuleSources:       result.ModuleSources,
+		ModuleSourceChanges: result.ModuleSourceChanges,
+		ResourceChanges:     result.ResourceChanges,
+		Outputs:             result.Outputs,
+		OutputChanges:       result.OutputChanges,
+		ResourceOwners:      toAPIResourceOwners(result.ResourceOwners),
+		Plan:                inline,
+		PlanBlocks:          groupPlanBlocks(strings.Split(ansiEscapePattern.ReplaceAllString(inline, ""), "\n")),
+		PlanTruncated:       view.Truncated,
+		PlanBytes:           view.TotalBytes,
+		RawURL:              rawPlanURL(projectName, stackPath, scanID),
+		ScanID:              result.ScanID,
+		CommitSHA:           result.CommitSHA,
+		CommitInfo:          toAPICommitInfo(result.CommitInfo),
+		TerraformVersion:    result.TerraformVersion,
+		TerragruntVersion:   result.TerragruntVersion,
+
+		ObservedDuringFreeze: result.ObservedDuringFreeze,
+		ConsoleLinks:         stackLinks,
+		ResourceConsoleLinks: resourceLinks,
+	})
+}
+
+func writeRawPlan(w http.ResponseWriter, stackPath string, result *storage.RunResult) {
+	name := strings.ReplaceAll(path.Clean(stackPath), "/", "_") + ".plan.txt"
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(result.PlanOutput))
+}
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestTruncatePlan(t *testing.T) {
+	small := truncatePlan("line1\nline2\n", 100)
+	if small.Truncated || small.Head != "line1\nline2\n" {
+		t.Fatalf("expected small plan untouched, got %+v", small)
+	}
+
+	var b strings.Builder
+	for i := 0; i < 200; i++ {
+		b.WriteString("  ~ resource \"null_resource\" \"example\" {}\n")
+	}
+	plan := b.String()
+	view := truncatePlan(plan, 1000)
+	if !view.Truncated {
+		t.Fatalf("expected plan to be truncated")
+	}
+	if len(view.Head) > 500 || len(view.Tail) > 500 {
+		t.Fatalf("head/tail exceed half the limit: %d/%d", len(view.Head), len(view.Tail))
+	}
+	if !strings.HasSuffix(view.Head, "\n") || !strings.HasPrefix(view.Tail, "  ~") {
+		t.Fatalf("expected cuts on line boundaries, got head=%q tail=%q", view.Head[len(view.Head)-10:], view.Tail[:10])
+	}
+	if len(view.Head)+view.OmittedBytes+len(view.Tail) != len(plan) || view.TotalBytes != len(plan) {
+		t.Fatalf("byte accounting mismatch: %+v", view)
+	}
+}
+
+func TestStackPlanAPITruncatesAndServesRaw(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, func(cfg *config.Config) {
+		cfg.API.MaxInlinePlanBytes = 4096
+	})
+	defer cleanup()
+
+	plan := strings.Repeat("  + resource \"null_resource\" \"x\" {}\n", 1000)
+	if err := srv.storage.SaveResult("project", "envs/prod", &storage.RunResult{Drifted: true, Added: 1000, PlanOutput: plan, RunAt: time.Now()}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/projects/project/stacks/envs/prod/plan")
+	if err != nil {
+		t.Fatalf("get plan: %v", err)
+	}
+	var got apiStackPlan
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode plan: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !got.PlanTruncated || got.PlanBytes != len(plan) {
+		t.Fatalf("unexpected plan response: %d %+v", resp.StatusCode, got)
+	}
+	if len(got.Plan) > 4096+200 || !strings.Contains(got.Plan, "bytes omitted") {
+		t.Fatalf("expected truncated inline plan, got %d bytes", len(got.Plan))
+	}
+	if got.RawURL != "/api/projects/project/stacks/envs/prod/plan/raw" {
+		t.Fatalf("unexpected raw url %q", got.RawURL)
+	}
+
+	resp, err = http.Get(ts.URL + got.RawURL)
+	if err != nil {
+		t.Fatalf("get raw plan: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != plan {
+		t.Fatalf("expected full raw plan, got %d (%d bytes)", resp.StatusCode, len(body))
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain") {
+		t.Fatalf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+
+	resp, err = http.Get(ts.URL + "/api/projects/project/stacks/envs/missing/plan")
+	if err != nil {
+		t.Fatalf("get missing plan: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for missing stack, got %d", resp.StatusCode)
+	}
+}
+
+func TestStackPageServesRawPlan(t *testing.T) {
+	srv, _, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, nil)
+	defer cleanup()
+
+	if err := srv.storage.SaveResult("project", "envs/prod", &storage.RunResult{Drifted: true, PlanOutput: "full plan", RunAt: time.Now()}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/projects/project/stacks/envs/prod?raw=1", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "full plan" {
+		t.Fatalf("expected raw plan download, got %d %q", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Header().Get("Content-Disposition"), "envs_prod.plan.txt") {
+		t.Fatalf("unexpected content disposition %q", rec.Header().Get("Content-Disposition"))
+	}
+}
+
+func TestStackPlanPinnedToScan(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, nil)
+	defer cleanup()
+
+	runs := []*storage.RunResult{
+		{Drifted: true, Added: 1, PlanOutput: "Plan: 1 to add", RunAt: time.Now().Add(-time.Hour), ScanID: "project:1", CommitSHA: "aaa", TerraformVersion: "1.5.7"},
+		{PlanOutput: "No changes.", RunAt: time.Now(), ScanID: "project:2", CommitSHA: "bbb", TerraformVersion: "1.6.0"},
+	}
+	for _, run := range runs {
+		if err := srv.storage.SaveResult("project", "envs/prod", run); err != nil {
+			t.Fatalf("save result: %v", err)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/api/projects/project/stacks/envs/prod/plan?scan=project:1")
+	if err != nil {
+		t.Fatalf("get plan: %v", err)
+	}
+	var got apiStackPlan
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode plan: %v", err)
+	}
+	resp.Body.Close()
+	if !got.Drifted || got.Plan != "Plan: 1 to add" || got.ScanID != "project:1" || got.CommitSHA != "aaa" || got.TerraformVersion != "1.5.7" {
+		t.Fatalf("unexpected pinned plan: %+v", got)
+	}
+	if got.RawURL != "/api/projects/project/stacks/envs/prod/plan/raw?scan=project%3A1" {
+		t.Fatalf("unexpected raw url %q", got.RawURL)
+	}
+
+	resp, err = http.Get(ts.URL + got.RawURL)
+	if err != nil {
+		t.Fatalf("get raw plan: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "Plan: 1 to add" {
+		t.Fatalf("expected pinned raw plan, got %q", body)
+	}
+
+	resp, err = http.Get(ts.URL + "/api/projects/project/stacks/envs/prod/plan?scan=project:9")
+	if err != nil {
+		t.Fatalf("get plan: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown scan, got %d", resp.StatusCode)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/projects/project/stacks/envs/prod?scan=project:1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected pinned stack page, got %d", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/projects/project/stacks/envs/prod?scan=project:9", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown scan, got %d", rec.Code)
+	}
+}
+
+func TestStackPlanIncludesResourceOwners(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, nil)
+	defer cleanup()
+
+	changedAt := time.Unix(1700000000, 0).UTC()
+	if err := srv.storage.SaveResult("project", "envs/prod", &storage.RunResult{
+		Drifted:         true,
+		Changed:         1,
+		RunAt:           time.Now(),
+		ResourceChanges: []storage.ResourceChange{{Address: "aws_s3_bucket.logs", Action: "update"}},
+		ResourceOwners: []storage.ResourceOwner{{
+			Address: "aws_s3_bucket.logs",
+			File:    "envs/prod/main.tf",
+			Line:    3,
+			Commit:  "0123456789abcdef0123456789abcdef01234567",
+			Author:  "alice",
+			Summary: "Add logs bucket",
+			Time:    changedAt,
+		}},
+	}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/projects/project/stacks/envs/prod/plan")
+	if err != nil {
+		t.Fatalf("get plan: %v", err)
+	}
+	defer resp.Body.Close()
+	var got apiStackPlan
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode plan: %v", err)
+	}
+	if len(got.ResourceOwners) != 1 {
+		t.Fatalf("expected one resource owner, got %+v", got.ResourceOwners)
+	}
+	if o := got.ResourceOwners[0]; o.Author != "alice" || o.File != "envs/prod/main.tf" || o.Line != 3 || o.Time != changedAt.Unix() {
+		t.Fatalf("unexpected resource owner: %+v", o)
+	}
+}
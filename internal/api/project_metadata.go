@@ -0,0 +1,128 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/secrets"
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxProjectDescriptionLen = 2000
+	maxProjectOwnerLen       = 200
+	maxProjectLinkLen        = 2048
+)
+
+type projectMetadataRequest struct {
+	Description  string `json:"description"`
+	Owner        string `json:"owner"`
+	RunbookURL   string `json:"runbook_url"`
+	DashboardURL string `json:"dashboard_url"`
+	Actor        string `json:"actor"`
+}
+
+func (s *Server) handleGetProjectMetadata(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !s.requireMetadataProject(w, projectName) {
+		return
+	}
+	metadata, err := s.storage.GetProjectMetadata(projectName)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	writeJSON(w, http.StatusOK, metadata)
+}
+
+// handleSetProjectMetadata replaces a project's description, owner and
+// links. Omitted fields are cleared.
+func (s *Server) handleSetProjectMetadata(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !s.requireMetadataProject(w, projectName) {
+		return
+	}
+	var req projectMetadataRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	metadata, err := req.metadata()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	actor := strings.TrimSpace(req.Actor)
+	if actor == "" {
+		actor = s.uiActor(r)
+	}
+	if err := s.storage.SetProjectMetadata(projectName, metadata, actor); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	saved, err := s.storage.GetProjectMetadata(projectName)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	writeJSON(w, http.StatusOK, saved)
+}
+
+// requireMetadataProject writes a 404 unless the project is configured.
+func (s *Server) requireMetadataProject(w http.ResponseWriter, projectName string) bool {
+	if !isValidProjectName(projectName) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid project name"})
+		return false
+	}
+	if _, err := s.getProjectConfig(projectName); err != nil {
+		if errors.Is(err, secrets.ErrProjectNotFound) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "project not found"})
+			return false
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return false
+	}
+	return true
+}
+
+func (req projectMetadataRequest) metadata() (storage.ProjectMetadata, error) {
+	m := storage.ProjectMetadata{
+		Description:  strings.TrimSpace(req.Description),
+		Owner:        strings.TrimSpace(req.Owner),
+		RunbookURL:   strings.TrimSpace(req.RunbookURL),
+		DashboardURL: strings.TrimSpace(req.DashboardURL),
+	}
+	if len(m.Description) > maxProjectDescriptionLen {
+		return m, fmt.Errorf("description must be at most %d characters", maxProjectDescriptionLen)
+	}
+	if len(m.Owner) > maxProjectOwnerLen {
+		return m, fmt.Errorf("owner must be at most %d characters", maxProjectOwnerLen)
+	}
+	for field, link := range map[string]string{"runbook_url": m.RunbookURL, "dashboard_url": m.DashboardURL} {
+		if err := validateProjectLink(link); err != nil {
+			return m, fmt.Errorf("%s: %w", field, err)
+		}
+	}
+	return m, nil
+}
+
+// validateProjectLink only allows absolute http(s) URLs so links rendered on
+// the project page cannot carry javascript: or other schemes.
+func validateProjectLink(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	if len(raw) > maxProjectLinkLen {
+		return fmt.Errorf("must be at most %d characters", maxProjectLinkLen)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("must be an absolute http or https URL")
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestProjectMetadataAPI(t *testing.T) {
+	ts, _, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true)
+	defer cleanup()
+
+	put := func(project, body string) (*http.Response, storage.ProjectMetadata) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPut, ts.URL+"/api/settings/projects/"+project+"/metadata", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("put metadata: %v", err)
+		}
+		defer resp.Body.Close()
+		var m storage.ProjectMetadata
+		_ = json.NewDecoder(resp.Body).Decode(&m)
+		return resp, m
+	}
+
+	resp, m := put("project", `{"description":" Shared VPCs ","owner":"team-network","runbook_url":"https://wiki.example.com/vpc","actor":"alice"}`)
+	if resp.StatusCode != http.StatusOK || m.Description != "Shared VPCs" || m.Owner != "team-network" || m.UpdatedBy != "alice" {
+		t.Fatalf("unexpected update response %d: %+v", resp.StatusCode, m)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/settings/projects/project/metadata")
+	if err != nil {
+		t.Fatalf("get metadata: %v", err)
+	}
+	var got storage.ProjectMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	resp.Body.Close()
+	if got.RunbookURL != "https://wiki.example.com/vpc" || got.DashboardURL != "" {
+		t.Fatalf("unexpected metadata %+v", got)
+	}
+
+	if resp, _ := put("project", `{"runbook_url":"javascript:alert(1)"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a non-http link, got %d", resp.StatusCode)
+	}
+	if resp, _ := put("missing", `{"owner":"x"}`); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown project, got %d", resp.StatusCode)
+	}
+}
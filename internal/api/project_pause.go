@@ -0,0 +1,96 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/go-chi/chi/v5"
+)
+
+type projectPauseRequest struct {
+	Reason string `json:"reason"`
+	Actor  string `json:"actor"`
+}
+
+type projectResumeResponse struct {
+	Project string `json:"project"`
+	Resumed bool   `json:"resumed"`
+}
+
+// handlePauseProject stops workers claiming the project's stack scans.
+// Queued stack scans stay queued and running ones finish; scans can still
+// be started and their stacks wait for /resume. The body is optional.
+func (s *Server) handlePauseProject(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !s.requireMetadataProject(w, projectName) {
+		return
+	}
+	var req projectPauseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	actor := strings.TrimSpace(req.Actor)
+	if actor == "" {
+		actor = s.uiActor(r)
+	}
+	pause := queue.ProjectPause{Project: projectName, Actor: actor, Reason: strings.TrimSpace(req.Reason)}
+	if err := s.queue.PauseProject(r.Context(), pause); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	saved, err := s.queue.GetProjectPause(r.Context(), projectName)
+	if err != nil || saved == nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to read project pause"})
+		return
+	}
+	writeJSON(w, http.StatusOK, saved)
+}
+
+// handleResumeProject lets workers claim the project's stack scans again,
+// oldest first.
+func (s *Server) handleResumeProject(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !s.requireMetadataProject(w, projectName) {
+		return
+	}
+	resumed, err := s.queue.ResumeProject(r.Context(), projectName)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	writeJSON(w, http.StatusOK, projectResumeResponse{Project: projectName, Resumed: resumed})
+}
+
+// handlePauseProjectUI and handleResumeProjectUI back the pause toggle on
+// the project page.
+func (s *Server) handlePauseProjectUI(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		http.Error(w, "Invalid project name", http.StatusBadRequest)
+		return
+	}
+	pause := queue.ProjectPause{Project: projectName, Actor: s.uiActor(r), Reason: strings.TrimSpace(r.FormValue("reason"))}
+	if err := s.queue.PauseProject(r.Context(), pause); err != nil {
+		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/projects/"+projectName, http.StatusSeeOther)
+}
+
+func (s *Server) handleResumeProjectUI(w http.ResponseWriter, r *http.Request) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		http.Error(w, "Invalid project name", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.queue.ResumeProject(r.Context(), projectName); err != nil {
+		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/projects/"+projectName, http.StatusSeeOther)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+func TestPauseProjectHoldsStackScans(t *testing.T) {
+	ts, q, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod"}, true, nil, true)
+	defer cleanup()
+
+	resp, err := http.Post(ts.URL+"/api/projects/project/pause", "application/json", bytes.NewBufferString(`{"reason":"incident 42","actor":"alice"}`))
+	if err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	var pause queue.ProjectPause
+	if err := json.NewDecoder(resp.Body).Decode(&pause); err != nil {
+		t.Fatalf("decode pause: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || pause.Reason != "incident 42" || pause.Actor != "alice" {
+		t.Fatalf("unexpected pause response %d: %+v", resp.StatusCode, pause)
+	}
+
+	resp, err = http.Post(ts.URL+"/api/projects/project/scan", "application/json", bytes.NewBufferString(`{}`))
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	var sr scanResp
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil || sr.Scan == nil {
+		t.Fatalf("decode scan response: %v", err)
+	}
+	resp.Body.Close()
+
+	time.Sleep(1500 * time.Millisecond)
+	if scan := getScan(t, ts, sr.Scan.ID); scan.Status != queue.ScanStatusRunning || scan.Completed != 0 {
+		t.Fatalf("expected paused scan to stay queued, got %+v", scan)
+	}
+
+	resp, err = http.Post(ts.URL+"/api/projects/project/resume", "application/json", nil)
+	if err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	var resumed projectResumeResponse
+	_ = json.NewDecoder(resp.Body).Decode(&resumed)
+	resp.Body.Close()
+	if !resumed.Resumed {
+		t.Fatalf("expected resume to report a paused project")
+	}
+	if scan := waitForScan(t, ts, sr.Scan.ID, 5*time.Second); scan.Status != queue.ScanStatusCompleted {
+		t.Fatalf("expected scan completed after resume, got %s", scan.Status)
+	}
+	if pause, _ := q.GetProjectPause(t.Context(), "project"); pause != nil {
+		t.Fatalf("expected pause cleared, got %+v", pause)
+	}
+
+	resp, err = http.Post(ts.URL+"/api/projects/missing/pause", "application/json", nil)
+	if err != nil {
+		t.Fatalf("pause missing: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown project, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+// maxMemorySamples bounds ?samples= so one request cannot issue an
+// unbounded number of MEMORY USAGE calls.
+const maxMemorySamples = 1000
+
+// handleRedisMemory reports the Redis memory used by each family of driftd
+// keys, estimated from ?samples= keys per family.
+func (s *Server) handleRedisMemory(w http.ResponseWriter, r *http.Request) {
+	samples := queue.DefaultMemorySamples
+	if raw := r.URL.Query().Get("samples"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxMemorySamples {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "samples must be between 1 and " + strconv.Itoa(maxMemorySamples)})
+			return
+		}
+		samples = n
+	}
+	report, err := s.queue.KeyMemoryUsage(r.Context(), samples)
+	if err != nil {
+		if errors.Is(err, queue.ErrNotSupported) {
+			writeJSON(w, http.StatusNotImplemented, map[string]string{"error": "key memory usage needs the Redis or NATS queue backend"})
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
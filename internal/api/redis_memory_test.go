@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestRedisMemoryAPI(t *testing.T) {
+	mr := miniredis.RunT(t)
+	q, err := queue.New(mr.Addr(), "", 0, time.Minute)
+	if err != nil {
+		t.Fatalf("queue: %v", err)
+	}
+	defer q.Close()
+	cfg := &config.Config{DataDir: t.TempDir()}
+	srv, err := New(cfg, storage.New(cfg.DataDir), q, os.DirFS("testdata"), os.DirFS("testdata"))
+	if err != nil {
+		t.Fatalf("server: %v", err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/api/admin/redis/memory?samples=5")
+	if err != nil {
+		t.Fatalf("redis memory: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var report queue.KeyMemoryReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if report.SamplesPerFamily != 5 || len(report.Families) == 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	bad, err := http.Get(ts.URL + "/api/admin/redis/memory?samples=0")
+	if err != nil {
+		t.Fatalf("redis memory: %v", err)
+	}
+	bad.Body.Close()
+	if bad.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for samples=0, got %d", bad.StatusCode)
+	}
+}
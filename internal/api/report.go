@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/driftdhq/driftd/internal/report"
+)
+
+var unsubscribeTemplate = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>driftd drift report</title></head>
+<body style="font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;max-width:480px;margin:48px auto;color:#111827">
+{{if .Done}}<p>{{.Email}} will no longer receive the driftd drift report.</p>
+{{else}}<p>Stop sending the driftd drift report to {{.Email}}?</p>
+<form method="post"><button type="submit">Unsubscribe</button></form>
+{{end}}</body>
+</html>
+`))
+
+type reportRecipientsRequest struct {
+	Recipients []string `json:"recipients"`
+}
+
+func (s *Server) requireReport(w http.ResponseWriter) bool {
+	if s.report == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": "report email not enabled"})
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleGetReportSettings(w http.ResponseWriter, r *http.Request) {
+	if !s.requireReport(w) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.report.Status())
+}
+
+func (s *Server) handleUpdateReportRecipients(w http.ResponseWriter, r *http.Request) {
+	if !s.requireReport(w) {
+		return
+	}
+	var req reportRecipientsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	if err := s.report.SetRecipients(req.Recipients); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.report.Status())
+}
+
+func (s *Server) handleSendReport(w http.ResponseWriter, r *http.Request) {
+	if !s.requireReport(w) {
+		return
+	}
+	sent, err := s.report.Send(r.Context())
+	if errors.Is(err, report.ErrNoRecipients) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Drift report send failed: %v", err)
+		writeJSON(w, http.StatusBadGateway, map[string]any{"error": "failed to send report", "sent": sent})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"sent": sent})
+}
+
+func (s *Server) handlePreviewReport(w http.ResponseWriter, r *http.Request) {
+	if !s.requireReport(w) {
+		return
+	}
+	html, err := s.report.Preview(r.URL.Query().Get("recipient"))
+	if err != nil {
+		log.Printf("Drift report preview failed: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "failed to render report"})
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(html)
+}
+
+// handleReportUnsubscribe serves the link in report emails. GET asks for
+// confirmation so link scanners don't unsubscribe anyone; POST unsubscribes,
+// which also covers RFC 8058 one-click requests from mail clients.
+func (s *Server) handleReportUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	if s.report == nil {
+		http.NotFound(w, r)
+		return
+	}
+	email := r.URL.Query().Get("email")
+	token := r.URL.Query().Get("token")
+
+	if r.Method == http.MethodGet {
+		if err := s.report.VerifyToken(email, token); err != nil {
+			http.Error(w, "Invalid unsubscribe link", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_ = unsubscribeTemplate.Execute(w, map[string]any{"Email": email})
+		return
+	}
+
+	if err := s.report.Unsubscribe(email, token); err != nil {
+		if errors.Is(err, report.ErrInvalidToken) {
+			http.Error(w, "Invalid unsubscribe link", http.StatusBadRequest)
+			return
+		}
+		log.Printf("Drift report unsubscribe failed: %v", err)
+		http.Error(w, "Failed to unsubscribe", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = unsubscribeTemplate.Execute(w, map[string]any{"Email": email, "Done": true})
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/report"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestReportSettingsDisabled(t *testing.T) {
+	ts, _, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/dev"}, false, nil, false)
+	defer cleanup()
+
+	resp, err := http.Get(ts.URL + "/api/settings/report")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", resp.StatusCode)
+	}
+}
+
+func TestReportRecipientsAndUnsubscribe(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/dev"}, false, nil, false, nil)
+	defer cleanup()
+
+	svc, err := report.New(config.ReportConfig{
+		Enabled:   true,
+		Schedule:  "0 8 * * 1",
+		Weeks:     1,
+		PublicURL: ts.URL,
+	}, storage.New(srv.cfg.DataDir), nil, srv.cfg.DataDir, nil)
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	srv.report = svc
+
+	req, _ := http.NewRequest(http.MethodPut, ts.URL+"/api/settings/report/recipients", strings.NewReader(`{"recipients":["ops@example.com"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	var status report.Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(status.Recipients) != 1 {
+		t.Fatalf("unexpected response %d: %+v", resp.StatusCode, status)
+	}
+
+	badLink := ts.URL + "/report/unsubscribe?" + url.Values{"email": {"ops@example.com"}, "token": {"forged"}}.Encode()
+	resp, err = http.Post(badLink, "application/x-www-form-urlencoded", strings.NewReader("List-Unsubscribe=One-Click"))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for forged token, got %d", resp.StatusCode)
+	}
+
+	html, err := svc.Preview("ops@example.com")
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	start := strings.Index(string(html), ts.URL+"/report/unsubscribe?")
+	if start < 0 {
+		t.Fatalf("preview has no unsubscribe link")
+	}
+	link := string(html[start:])
+	link = strings.ReplaceAll(link[:strings.Index(link, `"`)], "&amp;", "&")
+
+	// Following the link only asks for confirmation.
+	resp, err = http.Get(link)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(svc.Recipients()) != 1 {
+		t.Fatalf("GET should not unsubscribe: %d %v", resp.StatusCode, svc.Recipients())
+	}
+
+	resp, err = http.Post(link, "application/x-www-form-urlencoded", strings.NewReader("List-Unsubscribe=One-Click"))
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(svc.Recipients()) != 0 {
+		t.Fatalf("expected unsubscribe, got %d %v", resp.StatusCode, svc.Recipients())
+	}
+
+	resp, err = http.Post(ts.URL+"/api/settings/report/send", "application/json", nil)
+	if err != nil {
+		t.Fatalf("post: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusConflict {
+		t.Fatalf("expected 409 with no recipients, got %d", resp.StatusCode)
+	}
+}
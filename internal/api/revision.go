@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// revisionETag formats a settings entry revision as a strong ETag.
+func revisionETag(revision int64) string {
+	return `"` + strconv.FormatInt(revision, 10) + `"`
+}
+
+// ifMatchRevision returns the revision a settings write is conditioned on.
+// The If-Match header must name the current revision, or be "*" to match
+// whatever is stored. Otherwise the error response is written and ok is
+// false: 428 when the header is missing, 409 with the current revision when
+// it is stale.
+func ifMatchRevision(w http.ResponseWriter, r *http.Request, current int64) (int64, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		w.Header().Set("ETag", revisionETag(current))
+		writeJSON(w, http.StatusPreconditionRequired, map[string]interface{}{
+			"error":    "If-Match header with the current revision is required",
+			"revision": current,
+		})
+		return 0, false
+	}
+	if header == "*" {
+		return current, true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.Trim(strings.TrimPrefix(strings.TrimSpace(tag), "W/"), `"`)
+		if revision, err := strconv.ParseInt(tag, 10, 64); err == nil && revision == current {
+			return revision, true
+		}
+	}
+	writeRevisionConflict(w, current)
+	return 0, false
+}
+
+// writeRevisionConflict reports that an entry changed since the client read
+// it, with the revision to re-read.
+func writeRevisionConflict(w http.ResponseWriter, current int64) {
+	w.Header().Set("ETag", revisionETag(current))
+	writeJSON(w, http.StatusConflict, map[string]interface{}{
+		"error":    "modified by someone else since it was read; reload and retry",
+		"revision": current,
+	})
+}
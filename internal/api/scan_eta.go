@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/scaneta"
+	"github.com/go-chi/chi/v5"
+)
+
+type apiScanETA struct {
+	ScanID      string `json:"scan_id"`
+	Status      string `json:"status"`
+	WorkerSlots int    `json:"worker_slots"`
+	QueueDepth  int    `json:"queue_depth"`
+	// FinishesAt and RemainingSeconds are omitted when the scan has ended
+	// or no worker is available.
+	FinishesAt       int64         `json:"finishes_at,omitempty"`
+	RemainingSeconds int64         `json:"remaining_seconds,omitempty"`
+	Stacks           []apiStackETA `json:"stacks"`
+}
+
+type apiStackETA struct {
+	StackScanID      string `json:"stack_scan_id"`
+	StackPath        string `json:"stack_path"`
+	Status           string `json:"status"`
+	Position         int    `json:"position"`
+	EstimatedSeconds int64  `json:"estimated_seconds"`
+	StartsAt         int64  `json:"starts_at,omitempty"`
+	FinishesAt       int64  `json:"finishes_at,omitempty"`
+}
+
+// handleScanETA estimates when a running scan's queued and running stacks
+// finish, from recent stack durations and the available worker slots.
+func (s *Server) handleScanETA(w http.ResponseWriter, r *http.Request) {
+	scan, err := s.queue.GetScan(r.Context(), chi.URLParam(r, "scanID"))
+	if err != nil {
+		if errors.Is(err, queue.ErrScanNotFound) {
+			http.Error(w, "Scan not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to get scan", http.StatusInternalServerError)
+		return
+	}
+	eta, err := scaneta.New(s.queue).Scan(r.Context(), scan)
+	if err != nil {
+		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	resp := apiScanETA{
+		ScanID:      eta.ScanID,
+		Status:      eta.Status,
+		WorkerSlots: eta.Slots,
+		QueueDepth:  eta.Pending,
+		Stacks:      make([]apiStackETA, 0, len(eta.Stacks)),
+	}
+	if !eta.FinishesAt.IsZero() {
+		resp.FinishesAt = eta.FinishesAt.Unix()
+		resp.RemainingSeconds = int64(max(time.Until(eta.FinishesAt), 0) / time.Second)
+	}
+	for _, st := range eta.Stacks {
+		out := apiStackETA{
+			StackScanID:      st.StackScanID,
+			StackPath:        st.StackPath,
+			Status:           st.Status,
+			Position:         st.Position,
+			EstimatedSeconds: int64(st.Duration / time.Second),
+		}
+		if !st.StartsAt.IsZero() {
+			out.StartsAt = st.StartsAt.Unix()
+			out.FinishesAt = st.FinishesAt.Unix()
+		}
+		resp.Stacks = append(resp.Stacks, out)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
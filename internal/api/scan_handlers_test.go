@@ -5,11 +5,14 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/driftdhq/driftd/internal/config"
 	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/storage"
 )
 
 func TestScanProjectCompletesScan(t *testing.T) {
@@ -250,40 +253,18 @@ func TestScanProjectNoStacksDiscovered(t *testing.T) {
 		t.Fatalf("expected 500, got %d", resp.StatusCode)
 	}
 
-	ctx := context.Background()
-	keys, err := q.Client().Keys(ctx, "driftd:scan:*").Result()
+	scans, err := q.ListProjectScans(context.Background(), "project", 1)
 	if err != nil {
-		t.Fatalf("list scan keys: %v", err)
+		t.Fatalf("list scans: %v", err)
 	}
-	var scanKey string
-	for _, key := range keys {
-		if strings.HasPrefix(key, "driftd:scan:stack_scans:") || strings.HasPrefix(key, "driftd:scan:last:") {
-			continue
-		}
-		if key == "driftd:scan:project:project" {
-			continue
-		}
-		scanKey = key
-		break
+	if len(scans) != 1 {
+		t.Fatalf("expected scan to exist")
 	}
-	if scanKey == "" {
-		t.Fatalf("expected scan key to exist")
+	if scans[0].Status != queue.ScanStatusFailed {
+		t.Fatalf("expected failed, got %s", scans[0].Status)
 	}
-
-	status, err := q.Client().HGet(ctx, scanKey, "status").Result()
-	if err != nil {
-		t.Fatalf("get scan status: %v", err)
-	}
-	if status != queue.ScanStatusFailed {
-		t.Fatalf("expected failed, got %s", status)
-	}
-
-	errMsg, err := q.Client().HGet(ctx, scanKey, "error").Result()
-	if err != nil {
-		t.Fatalf("get scan error: %v", err)
-	}
-	if errMsg != "no stacks discovered" {
-		t.Fatalf("expected error message, got %q", errMsg)
+	if scans[0].Error != "no stacks discovered" {
+		t.Fatalf("expected error message, got %q", scans[0].Error)
 	}
 }
 
@@ -504,3 +485,158 @@ func TestGetStackScanAndListProjectStackScans(t *testing.T) {
 		t.Fatalf("expected at least one stack scan in list response")
 	}
 }
+
+func TestDiscoverProjectDoesNotEnqueue(t *testing.T) {
+	runner := &fakeRunner{}
+	versions := &testVersions{rootTF: "1.6.2"}
+
+	_, ts, q, cleanup := newTestServerWithConfig(t, runner, []string{"envs/prod", "envs/dev", "modules/vpc"}, false, versions, true, func(cfg *config.Config) {
+		cfg.Projects[0].IgnorePaths = []string{"modules/**"}
+	})
+	defer cleanup()
+
+	resp, err := http.Post(ts.URL+"/api/projects/project/discover", "application/json", nil)
+	if err != nil {
+		t.Fatalf("discover request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	var got apiDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if strings.Join(got.Stacks, ",") != "envs/dev,envs/prod" {
+		t.Fatalf("unexpected stacks: %v", got.Stacks)
+	}
+	if got.CommitSHA == "" {
+		t.Fatalf("expected commit sha")
+	}
+	if got.TerraformVersion != "1.6.2" {
+		t.Fatalf("expected tf version 1.6.2, got %q", got.TerraformVersion)
+	}
+	if len(got.Ignored) == 0 || got.Ignored[0].Path != "modules" || got.Ignored[0].Pattern != "modules/**" {
+		t.Fatalf("expected modules ignore match, got %+v", got.Ignored)
+	}
+
+	if active, _ := q.GetActiveScan(context.Background(), "project"); active != nil {
+		t.Fatalf("expected no active scan after discovery, got %s", active.ID)
+	}
+	stackScans, err := q.ListProjectStackScans(context.Background(), "project", 10)
+	if err != nil {
+		t.Fatalf("list stack scans: %v", err)
+	}
+	if len(stackScans) != 0 {
+		t.Fatalf("expected no stack scans, got %d", len(stackScans))
+	}
+}
+
+func TestDiscoverProjectNotConfigured(t *testing.T) {
+	ts, _, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true)
+	defer cleanup()
+
+	resp, err := http.Post(ts.URL+"/api/projects/missing/discover", "application/json", nil)
+	if err != nil {
+		t.Fatalf("discover request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", resp.StatusCode)
+	}
+}
+
+func TestListProjectStackScansFiltersByTag(t *testing.T) {
+	runner := &fakeRunner{}
+	srv, ts, _, cleanup := newTestServerWithConfig(t, runner, []string{"envs/prod", "envs/dev"}, false, nil, true, nil)
+	defer cleanup()
+
+	if err := srv.storage.SaveResult("project", "envs/prod", &storage.RunResult{RunAt: time.Now(), Tags: map[string]string{"tier": "critical"}}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+	if err := srv.storage.SaveResult("project", "envs/dev", &storage.RunResult{RunAt: time.Now(), Tags: map[string]string{"tier": "low"}}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+
+	resp, err := http.Post(ts.URL+"/api/projects/project/scan", "application/json", bytes.NewBufferString(`{}`))
+	if err != nil {
+		t.Fatalf("scan request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	listResp, err := http.Get(ts.URL + "/api/projects/project/stacks?tag=tier:Critical")
+	if err != nil {
+		t.Fatalf("list project stack scans: %v", err)
+	}
+	defer listResp.Body.Close()
+	if listResp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", listResp.StatusCode)
+	}
+	var listed []apiStackScan
+	if err := json.NewDecoder(listResp.Body).Decode(&listed); err != nil {
+		t.Fatalf("decode list: %v", err)
+	}
+	if len(listed) != 1 || listed[0].StackPath != "envs/prod" || listed[0].Tags["tier"] != "critical" {
+		t.Fatalf("expected only the critical stack, got %+v", listed)
+	}
+
+	badResp, err := http.Get(ts.URL + "/api/projects/project/stacks?tag=" + url.QueryEscape("bad key!"))
+	if err != nil {
+		t.Fatalf("list with bad filter: %v", err)
+	}
+	badResp.Body.Close()
+	if badResp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid tag filter, got %d", badResp.StatusCode)
+	}
+}
+
+func TestScanETA(t *testing.T) {
+	ts, q, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod", "envs/dev"}, false, nil, false)
+	defer cleanup()
+
+	resp, err := http.Post(ts.URL+"/api/projects/project/scan", "application/json", bytes.NewBufferString(`{}`))
+	if err != nil {
+		t.Fatalf("scan request failed: %v", err)
+	}
+	var sr scanResp
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	resp.Body.Close()
+	if err := q.HeartbeatWorker(context.Background(), queue.WorkerInfo{ID: "w1", Concurrency: 1}); err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+
+	resp, err = http.Get(ts.URL + "/api/scans/" + sr.Scan.ID + "/eta")
+	if err != nil {
+		t.Fatalf("eta request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var eta apiScanETA
+	if err := json.NewDecoder(resp.Body).Decode(&eta); err != nil {
+		t.Fatalf("decode eta: %v", err)
+	}
+	// With no history each stack takes the default 3m, one after another.
+	if eta.WorkerSlots != 1 || len(eta.Stacks) != 2 || eta.Stacks[1].Position != 1 {
+		t.Fatalf("unexpected eta: %+v", eta)
+	}
+	if eta.RemainingSeconds < 350 || eta.RemainingSeconds > 360 {
+		t.Fatalf("expected ~6m remaining, got %ds", eta.RemainingSeconds)
+	}
+
+	missing, err := http.Get(ts.URL + "/api/scans/nope/eta")
+	if err != nil {
+		t.Fatalf("eta request failed: %v", err)
+	}
+	missing.Body.Close()
+	if missing.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", missing.StatusCode)
+	}
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/scanlimit"
+)
+
+type scanLimitsResponse struct {
+	Window string `json:"window"`
+	// Overrides are the limits saved through this endpoint.
+	Overrides scanlimit.Settings `json:"overrides"`
+	// Global and Projects are the limits in force, config merged with
+	// overrides. Projects without limits are omitted.
+	Global   map[string]int            `json:"global"`
+	Projects map[string]map[string]int `json:"projects,omitempty"`
+}
+
+func (s *Server) handleGetScanLimits(w http.ResponseWriter, r *http.Request) {
+	resp, err := s.scanLimitsStatus()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleSetScanLimits replaces the limit overrides. Each trigger named in an
+// override replaces the configured limit; 0 lifts it.
+func (s *Server) handleSetScanLimits(w http.ResponseWriter, r *http.Request) {
+	var req scanlimit.Settings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := s.scanLimits.SetSettings(req); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	s.handleGetScanLimits(w, r)
+}
+
+func (s *Server) scanLimitsStatus() (*scanLimitsResponse, error) {
+	overrides, err := s.scanLimits.Settings()
+	if err != nil {
+		return nil, err
+	}
+	resp := &scanLimitsResponse{
+		Window:    scanlimit.Window.String(),
+		Overrides: overrides,
+		Projects:  map[string]map[string]int{},
+	}
+	resp.Global, _ = s.scanLimits.Effective(nil)
+	for _, project := range s.listConfiguredRepos() {
+		_, limits := s.scanLimits.Effective(&project)
+		if len(limits) > 0 {
+			resp.Projects[project.Name] = limits
+		}
+	}
+	return resp, nil
+}
+
+// scanLimitError returns the limit that refused a scan start, if any.
+func scanLimitError(err error) *scanlimit.Error {
+	var limitErr *scanlimit.Error
+	if errors.As(err, &limitErr) {
+		return limitErr
+	}
+	return nil
+}
+
+func setScanLimitRetryAfter(w http.ResponseWriter, limitErr *scanlimit.Error) {
+	wait := math.Ceil(time.Until(limitErr.ResetAt).Seconds())
+	if wait < 1 {
+		wait = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(wait)))
+}
+
+// writeScanLimited answers with 429 and reports true when err is a scan
+// limit refusal.
+func writeScanLimited(w http.ResponseWriter, err error) bool {
+	limitErr := scanLimitError(err)
+	if limitErr == nil {
+		return false
+	}
+	setScanLimitRetryAfter(w, limitErr)
+	writeJSON(w, http.StatusTooManyRequests, scanResponse{
+		Error:   limitErr.Error(),
+		ResetAt: limitErr.ResetAt.UTC().Format(time.RFC3339),
+	})
+	return true
+}
+
+// writeScanLimitedText is writeScanLimited for UI form posts.
+func writeScanLimitedText(w http.ResponseWriter, err error) bool {
+	limitErr := scanLimitError(err)
+	if limitErr == nil {
+		return false
+	}
+	setScanLimitRetryAfter(w, limitErr)
+	http.Error(w, limitErr.Error(), http.StatusTooManyRequests)
+	return true
+}
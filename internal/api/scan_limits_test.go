@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+func TestScanLimitReturns429WithResetTime(t *testing.T) {
+	_, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, func(cfg *config.Config) {
+		cfg.ScanLimits.PerHour = map[string]int{"manual": 1}
+	})
+	defer cleanup()
+
+	resp, err := http.Post(ts.URL+"/api/projects/project/scan", "application/json", bytes.NewBufferString(`{}`))
+	if err != nil {
+		t.Fatalf("scan request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected first scan to start, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(ts.URL+"/api/projects/project/scan", "application/json", bytes.NewBufferString(`{}`))
+	if err != nil {
+		t.Fatalf("scan request 2 failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", resp.StatusCode)
+	}
+	if wait, err := strconv.Atoi(resp.Header.Get("Retry-After")); err != nil || wait < 1 || wait > 3600 {
+		t.Fatalf("unexpected Retry-After %q", resp.Header.Get("Retry-After"))
+	}
+	var body scanResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	resetAt, err := time.Parse(time.RFC3339, body.ResetAt)
+	if err != nil || !resetAt.After(time.Now()) {
+		t.Fatalf("unexpected reset_at %q", body.ResetAt)
+	}
+	if body.Error == "" {
+		t.Fatalf("expected an error message")
+	}
+
+}
+
+func TestScanLimitSettings(t *testing.T) {
+	_, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, func(cfg *config.Config) {
+		cfg.ScanLimits.PerHour = map[string]int{"webhook": 10, "manual": 4}
+	})
+	defer cleanup()
+
+	put := func(body string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPut, ts.URL+"/api/settings/scan-limits", bytes.NewBufferString(body))
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("put: %v", err)
+		}
+		return resp
+	}
+
+	resp := put(`{"global":{"manual":-1}}`)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a negative limit, got %d", resp.StatusCode)
+	}
+
+	resp = put(`{"global":{"manual":0},"projects":{"project":{"manual":1}}}`)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var got scanLimitsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Global["webhook"] != 10 || got.Global["manual"] != 0 {
+		t.Fatalf("unexpected effective global limits: %+v", got.Global)
+	}
+	if got.Projects["project"]["manual"] != 1 {
+		t.Fatalf("unexpected effective project limits: %+v", got.Projects)
+	}
+	if got.Overrides.Projects["project"]["manual"] != 1 {
+		t.Fatalf("unexpected overrides: %+v", got.Overrides)
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"strings"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+// maxStackRunLinks is how many past scans the stack page links to.
+const maxStackRunLinks = 20
+
+// stackResult returns the stack's latest result, or the result it recorded
+// in scanID when one is given. A scan the stack was not part of, or whose
+// snapshot was pruned, yields storage.ErrScanResultNotFound.
+func (s *Server) stackResult(projectName, stackPath, scanID string) (*storage.RunResult, error) {
+	if scanID = strings.TrimSpace(scanID); scanID != "" {
+		return s.storage.GetScanResult(projectName, stackPath, scanID)
+	}
+	return s.storage.GetResult(projectName, stackPath)
+}
+
+// stackRunLinks lists the stack's most recent runs that have a scan ID,
+// newest first, for navigating between past results.
+func (s *Server) stackRunLinks(projectName, stackPath string) []storage.HistoryEntry {
+	history, err := s.storage.StackHistory(projectName, stackPath, time.Time{})
+	if err != nil {
+		return nil
+	}
+	var links []storage.HistoryEntry
+	for i := len(history) - 1; i >= 0 && len(links) < maxStackRunLinks; i-- {
+		if history[i].ScanID != "" {
+			links = append(links, history[i])
+		}
+	}
+	return links
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/driftdhq/driftd/internal/scheduler"
+)
+
+// handleSchedulerLeader reports which serve replica currently fires cron
+// triggers, as recorded in the queue backend.
+func (s *Server) handleSchedulerLeader(w http.ResponseWriter, r *http.Request) {
+	if s.elector != nil {
+		status, err := s.elector.Status(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+		return
+	}
+
+	lease, err := s.queue.GetLeaderLease(r.Context(), scheduler.LeaderRole)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	var status scheduler.LeaderStatus
+	if lease != nil {
+		status.Leader = lease.Owner
+		if !lease.ExpiresAt.IsZero() {
+			status.ExpiresAt = &lease.ExpiresAt
+		}
+	}
+	writeJSON(w, http.StatusOK, status)
+}
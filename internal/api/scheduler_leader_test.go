@@ -0,0 +1,44 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/scheduler"
+)
+
+func TestSchedulerLeaderAPI(t *testing.T) {
+	ts, q, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true)
+	defer cleanup()
+
+	get := func() scheduler.LeaderStatus {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/api/scheduler/leader")
+		if err != nil {
+			t.Fatalf("get leader: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var status scheduler.LeaderStatus
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return status
+	}
+
+	if status := get(); status.Leader != "" {
+		t.Fatalf("expected no leader, got %+v", status)
+	}
+
+	if ok, err := q.AcquireLeaderLease(context.Background(), scheduler.LeaderRole, "replica-a", time.Minute); err != nil || !ok {
+		t.Fatalf("acquire: %v %v", ok, err)
+	}
+	if status := get(); status.Leader != "replica-a" || status.ExpiresAt == nil {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
@@ -1,19 +1,29 @@
 package api
 
 import (
+	"context"
 	"html/template"
 	"io/fs"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/federation"
+	"github.com/driftdhq/driftd/internal/freeze"
+	"github.com/driftdhq/driftd/internal/maintenance"
 	"github.com/driftdhq/driftd/internal/metrics"
 	"github.com/driftdhq/driftd/internal/orchestrate"
 	"github.com/driftdhq/driftd/internal/projects"
 	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/report"
+	"github.com/driftdhq/driftd/internal/scanlimit"
+	"github.com/driftdhq/driftd/internal/scheduler"
 	"github.com/driftdhq/driftd/internal/secrets"
+	"github.com/driftdhq/driftd/internal/severity"
 	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/driftdhq/driftd/internal/vcs"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -23,25 +33,51 @@ import (
 type Server struct {
 	cfg             *config.Config
 	storage         storage.Store
-	queue           *queue.Queue
+	queue           queue.Backend
 	projectStore    *secrets.ProjectStore
 	intStore        *secrets.IntegrationStore
 	projectProvider projects.Provider
 	orchestrator    *orchestrate.ScanOrchestrator
+	report          *report.Service
+	maintenance     *maintenance.Mode
+	freeze          *freeze.Store
+	scanLimits      *scanlimit.Limiter
+	severity        *severity.Policy
+	elector         *scheduler.Elector
+	federation      *federation.Federation
+	space           string
 	tmplIndex       *template.Template
 	tmplRepo        *template.Template
 	tmplDrift       *template.Template
+	tmplHeatmap     *template.Template
+	tmplPipeline    *template.Template
+	tmplActivity    *template.Template
+	tmplFederation  *template.Template
 	tmplSettings    *template.Template
+	tmplLogin       *template.Template
+	tmplFragments   *template.Template
 	staticFS        fs.FS
+	sessionKey      []byte
+	accessLog       *accessLog
 
 	rateLimitMu  sync.Mutex
 	rateLimiters map[string]*rateLimiterEntry
 	webhookMu    sync.Mutex
 	webhookSeen  map[string]time.Time
 
+	// bgCtx scopes work that outlives a request, such as waiting to report
+	// a deployment verification. Stop cancels it.
+	bgCtx    context.Context
+	bgCancel context.CancelFunc
+	bgWG     sync.WaitGroup
+
 	onProjectAdded   func(name, schedule string)
 	onProjectUpdated func(name, schedule string)
 	onProjectDeleted func(name string)
+
+	// queueHealth drives the read-only mode used while the queue backend
+	// is unreachable.
+	queueHealth queueHealth
 }
 
 type rateLimiterEntry struct {
@@ -89,7 +125,38 @@ func WithOrchestrator(orch *orchestrate.ScanOrchestrator) ServerOption {
 	}
 }
 
-func New(cfg *config.Config, s storage.Store, q *queue.Queue, templatesFS, staticFS fs.FS, opts ...ServerOption) (*Server, error) {
+// WithReportService enables the drift report settings and unsubscribe routes.
+func WithReportService(svc *report.Service) ServerOption {
+	return func(s *Server) {
+		s.report = svc
+	}
+}
+
+// WithMaintenance shares the maintenance state with the scheduler. Without
+// it the server reads the state from the data directory on its own.
+func WithMaintenance(m *maintenance.Mode) ServerOption {
+	return func(s *Server) {
+		s.maintenance = m
+	}
+}
+
+// WithSchedulerElector lets /api/scheduler/leader report this replica's view
+// of scheduler leadership.
+func WithSchedulerElector(e *scheduler.Elector) ServerOption {
+	return func(s *Server) {
+		s.elector = e
+	}
+}
+
+// WithSpace names the space the server hosts, which labels its queue
+// metrics.
+func WithSpace(name string) ServerOption {
+	return func(s *Server) {
+		s.space = name
+	}
+}
+
+func New(cfg *config.Config, s storage.Store, q queue.Backend, templatesFS, staticFS fs.FS, opts ...ServerOption) (*Server, error) {
 	funcMap := template.FuncMap{
 		"timeAgo": timeAgo,
 		"pluralize": func(singular, plural string, count int) string {
@@ -98,7 +165,9 @@ func New(cfg *config.Config, s storage.Store, q *queue.Queue, templatesFS, stati
 			}
 			return plural
 		},
-		"commitURL": commitURL,
+		"commitURL":     commitURL,
+		"severityLevel": severity.Level,
+		"join":          strings.Join,
 		"add": func(a, b int) int {
 			return a + b
 		},
@@ -111,13 +180,16 @@ func New(cfg *config.Config, s storage.Store, q *queue.Queue, templatesFS, stati
 			}
 			return a / b
 		},
+		"projectCard": newProjectCard,
+		"stackRow":    newStackRow,
+		"rowIndex":    withRowIndex,
 	}
 
-	tmplIndex, err := template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/layout.html", "templates/index.html")
+	tmplIndex, err := template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/layout.html", "templates/index.html", "templates/fragments.html")
 	if err != nil {
 		return nil, err
 	}
-	tmplRepo, err := template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/layout.html", "templates/project.html")
+	tmplRepo, err := template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/layout.html", "templates/project.html", "templates/fragments.html")
 	if err != nil {
 		return nil, err
 	}
@@ -125,23 +197,59 @@ func New(cfg *config.Config, s storage.Store, q *queue.Queue, templatesFS, stati
 	if err != nil {
 		return nil, err
 	}
+	tmplHeatmap, err := template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/layout.html", "templates/heatmap.html")
+	if err != nil {
+		return nil, err
+	}
+	tmplPipeline, err := template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/layout.html", "templates/pipeline.html")
+	if err != nil {
+		return nil, err
+	}
+	tmplActivity, err := template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/layout.html", "templates/activity.html")
+	if err != nil {
+		return nil, err
+	}
+	tmplFederation, err := template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/layout.html", "templates/federation.html")
+	if err != nil {
+		return nil, err
+	}
 	tmplSettings, err := template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/layout.html", "templates/settings.html")
 	if err != nil {
 		return nil, err
 	}
+	tmplLogin, err := template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/layout.html", "templates/login.html")
+	if err != nil {
+		return nil, err
+	}
+	tmplFragments, err := template.New("").Funcs(funcMap).ParseFS(templatesFS, "templates/fragments.html")
+	if err != nil {
+		return nil, err
+	}
 
 	srv := &Server{
-		cfg:          cfg,
-		storage:      s,
-		queue:        q,
-		tmplIndex:    tmplIndex,
-		tmplRepo:     tmplRepo,
-		tmplDrift:    tmplDrift,
-		tmplSettings: tmplSettings,
-		staticFS:     staticFS,
-		rateLimiters: make(map[string]*rateLimiterEntry),
-		webhookSeen:  make(map[string]time.Time),
+		cfg:            cfg,
+		storage:        s,
+		queue:          q,
+		tmplIndex:      tmplIndex,
+		tmplRepo:       tmplRepo,
+		tmplDrift:      tmplDrift,
+		tmplHeatmap:    tmplHeatmap,
+		tmplPipeline:   tmplPipeline,
+		tmplActivity:   tmplActivity,
+		tmplFederation: tmplFederation,
+		tmplSettings:   tmplSettings,
+		tmplLogin:      tmplLogin,
+		tmplFragments:  tmplFragments,
+		staticFS:       staticFS,
+		sessionKey:     loadSessionKey(cfg.Auth.Session.Secret),
+		rateLimiters:   make(map[string]*rateLimiterEntry),
+		webhookSeen:    make(map[string]time.Time),
 	}
+	srv.accessLog, err = newAccessLog(cfg.AccessLog)
+	if err != nil {
+		return nil, err
+	}
+	srv.bgCtx, srv.bgCancel = context.WithCancel(context.Background())
 
 	for _, opt := range opts {
 		opt(srv)
@@ -150,46 +258,99 @@ func New(cfg *config.Config, s storage.Store, q *queue.Queue, templatesFS, stati
 	if srv.orchestrator == nil {
 		srv.orchestrator = orchestrate.New(cfg, q)
 	}
-	metrics.Register(q)
+	if srv.maintenance == nil {
+		srv.maintenance = maintenance.New(cfg.DataDir)
+	}
+	srv.freeze = freeze.New(cfg.DataDir)
+	srv.scanLimits = scanlimit.New(cfg, q)
+	srv.severity = severity.New(cfg.Severity)
+	if cfg.Federation.Enabled {
+		srv.federation = federation.New(cfg.Federation)
+	}
+	metrics.Register(srv.space, q)
+	srv.startQueueProbe()
 
 	return srv, nil
 }
 
 // Stop gracefully shuts down background goroutines (e.g. lock renewals).
 func (s *Server) Stop() {
+	s.bgCancel()
+	s.bgWG.Wait()
 	s.orchestrator.Stop()
+	s.accessLog.Close()
 }
 
 func (s *Server) Handler() http.Handler {
 	r := chi.NewRouter()
-	r.Use(middleware.Logger)
+	r.Use(s.accessLogMiddleware)
 	r.Use(middleware.Recoverer)
 	r.Use(s.securityHeadersMiddleware)
+	r.Use(s.degradedMiddleware)
+	if s.cfg.API.LegacyRepoRoutesEnabled() {
+		r.Use(s.legacyRoutesMiddleware)
+	}
+	r.Use(s.branchRoutesMiddleware)
 
 	r.Get("/metrics", promhttp.Handler().ServeHTTP)
 
+	// Unsubscribe links are authenticated by their signed token.
+	r.With(s.rateLimitMiddleware).Get("/report/unsubscribe", s.handleReportUnsubscribe)
+	r.With(s.rateLimitMiddleware).Post("/report/unsubscribe", s.handleReportUnsubscribe)
+
+	// Badges are embedded in other sites' pages; with badges.public they
+	// need no login.
+	if s.cfg.Badges.Public {
+		r.Get("/badge/*", s.handleBadge)
+	}
+
+	r.Group(func(r chi.Router) {
+		r.Use(s.csrfMiddleware)
+		r.Get("/login", s.handleLoginPage)
+		r.With(s.rateLimitMiddleware).Post("/login", s.handleLogin)
+	})
+
 	r.Group(func(r chi.Router) {
 		if s.useExternalAuth() || s.cfg.UIAuth.Username != "" || s.cfg.UIAuth.Password != "" {
 			r.Use(s.uiAuthMiddleware)
 		}
 		r.Use(s.csrfMiddleware)
+		r.Post("/logout", s.handleLogout)
 		r.Get("/", s.handleIndex)
 		r.Get("/projects/{project}", s.handleRepo)
-		r.With(s.uiWriteAuthMiddleware).Post("/projects/{project}/scan", s.handleScanProjectUI)
+		r.With(s.uiWriteAuthMiddleware, s.maintenanceMiddleware).Post("/projects/{project}/scan", s.handleScanProjectUI)
+		r.With(s.uiWriteAuthMiddleware).Post("/projects/{project}/stacks:batch", s.handleStackBatchUI)
+		r.With(s.uiWriteAuthMiddleware).Post("/projects/{project}/pause", s.handlePauseProjectUI)
+		r.With(s.uiWriteAuthMiddleware).Post("/projects/{project}/resume", s.handleResumeProjectUI)
+		r.Get("/projects/{project}/heatmap", s.handleProjectHeatmapUI)
+		r.Get("/projects/{project}/pipeline", s.handleProjectPipelineUI)
+		r.Get("/activity", s.handleActivity)
+		r.Get("/federation", s.handleFederationUI)
+		if !s.cfg.Badges.Public {
+			r.Get("/badge/*", s.handleBadge)
+		}
+		r.Get("/fragments/projects/{project}/card", s.handleProjectCardFragment)
+		r.Get("/fragments/projects/{project}/progress", s.handleScanProgressFragment)
+		r.Get("/fragments/projects/{project}/stacks/*", s.handleStackRowFragment)
 		r.Get("/projects/{project}/stacks/*", s.handleStack)
-		r.With(s.uiWriteAuthMiddleware).Post("/projects/{project}/stacks/*", s.handleScanStackUI)
+		r.With(s.uiWriteAuthMiddleware, s.maintenanceMiddleware).Post("/projects/{project}/stacks/*", s.handleScanStackUI)
 		r.With(s.uiSettingsAuthMiddleware).Get("/settings", s.handleSettings)
 		r.With(s.uiSettingsAuthMiddleware).Get("/settings/projects", s.handleSettings)
+		r.With(s.uiSettingsAuthMiddleware).Post(githubAppFlowPath, s.handleGitHubAppManifest)
+		r.With(s.uiSettingsAuthMiddleware).Get(githubAppFlowPath+"/callback", s.handleGitHubAppCallback)
+		r.With(s.uiSettingsAuthMiddleware).Get(githubAppFlowPath+"/setup", s.handleGitHubAppSetup)
 	})
 
-	// SSE endpoints use UI auth (cookie/basic-auth) since EventSource
-	// doesn't support custom headers required by API token auth.
+	// SSE and WebSocket endpoints use UI auth (session cookie/basic-auth)
+	// since EventSource and browser WebSockets don't support custom headers
+	// required by API token auth.
 	r.Group(func(r chi.Router) {
 		if s.useExternalAuth() || s.cfg.UIAuth.Username != "" || s.cfg.UIAuth.Password != "" {
 			r.Use(s.uiAuthMiddleware)
 		}
-		r.Get("/api/projects/{project}/events", s.handleProjectEvents)
-		r.Get("/api/events", s.handleGlobalEvents)
+		r.With(s.requireQueueMiddleware).Get("/api/projects/{project}/events", s.handleProjectEvents)
+		r.With(s.requireQueueMiddleware).Get("/api/events", s.handleGlobalEvents)
+		r.With(s.requireQueueMiddleware).Get("/api/ws", s.handleWebSocket)
 	})
 
 	r.Route("/api", func(r chi.Router) {
@@ -198,15 +359,53 @@ func (s *Server) Handler() http.Handler {
 		}
 		r.Get("/health", s.handleHealth)
 		// Stack scan IDs can contain slashes (stack paths), so use a wildcard.
-		r.Get("/stacks/*", s.handleGetStackScan)
-		r.Get("/scans/{scanID}", s.handleGetScan)
-		r.Get("/projects/{project}/stacks", s.handleListProjectStackScans)
-		r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/projects/{project}/scan", s.handleScanRepo)
-		r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/projects/{project}/stacks/*", s.handleScanStack)
+		r.With(s.requireQueueMiddleware).Get("/stacks/*", s.handleGetStackScan)
+		r.With(s.requireQueueMiddleware).Get("/scans/{scanID}", s.handleGetScan)
+		r.With(s.requireQueueMiddleware).Get("/scans/{scanID}/eta", s.handleScanETA)
+		r.With(s.requireQueueMiddleware).Get("/projects/{project}/stacks", s.handleListProjectStackScans)
+		r.With(s.requireQueueMiddleware).Get("/projects/{project}/drift/changes", s.handleDriftChanges)
+		r.Get("/projects/{project}/drifted-resources", s.handleDriftedResources)
+		r.Get("/projects/{project}/heatmap", s.handleProjectHeatmap)
+		r.With(s.requireQueueMiddleware).Get("/projects/{project}/pipeline", s.handleProjectPipeline)
+		r.Get("/projects/{project}/gate", s.handleProjectGate)
+		r.Get("/projects/{project}/stacks/*", s.handleStackPlan)
+		r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware, s.maintenanceMiddleware).Post("/projects/{project}/scan", s.handleScanRepo)
+		r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/projects/{project}/discover", s.handleDiscoverProject)
+		r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/projects/{project}/stacks:batch", s.handleStackBatch)
+		r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/projects/{project}/pause", s.handlePauseProject)
+		r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/projects/{project}/resume", s.handleResumeProject)
+		r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware, s.maintenanceMiddleware).Post("/projects/{project}/stacks/*", s.handleScanStack)
+		r.With(s.rateLimitMiddleware).Post("/bulk/status", s.handleBulkStatus)
+		r.Get("/environments", s.handleListEnvironments)
+		r.Get("/modules/usage", s.handleModuleUsage)
+		r.With(s.requireQueueMiddleware).Get("/stack-scans", s.handleListStackScans)
+		r.With(s.requireQueueMiddleware).Get("/stack-scans/*", s.handleStackScanDiagnostics)
+		r.With(s.requireQueueMiddleware).Get("/workers", s.handleListWorkers)
+		r.With(s.requireQueueMiddleware).Get("/scheduler/leader", s.handleSchedulerLeader)
+		r.Get("/federation", s.handleFederation)
+		r.Get("/federation/overview", s.handleFederationOverview)
+		r.With(s.requireQueueMiddleware).Get("/outbox", s.handleReadOutbox)
+		r.With(s.requireQueueMiddleware).Get("/outbox/consumers", s.handleOutboxStatus)
+		r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Put("/outbox/consumers/{consumer}/offset", s.handleCommitOutboxOffset)
+		r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Delete("/outbox/consumers/{consumer}", s.handleDeleteOutboxConsumer)
+		r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/workers/{worker}/drain", s.handleWorkerCommand(queue.WorkerActionDrain))
+		r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/workers/{worker}/resume", s.handleWorkerCommand(queue.WorkerActionResume))
+		r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/workers/{worker}/concurrency", s.handleWorkerCommand(queue.WorkerActionSetConcurrency))
 		if s.cfg.Webhook.Enabled {
-			r.Post("/webhooks/github", s.handleGitHubWebhook)
+			for _, provider := range vcs.Providers() {
+				r.With(s.maintenanceMiddleware).Post("/webhooks/"+provider.Name(), s.handleWebhook(provider))
+			}
 		}
 
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(s.settingsAuthMiddleware)
+			r.Get("/maintenance", s.handleGetMaintenance)
+			r.Get("/freeze", s.handleGetFreeze)
+			r.With(s.requireQueueMiddleware).Get("/redis/memory", s.handleRedisMemory)
+			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/maintenance", s.handleSetMaintenance)
+			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/freeze", s.handleSetFreeze)
+		})
+
 		r.Route("/settings", func(r chi.Router) {
 			r.Use(s.settingsAuthMiddleware)
 			r.Get("/integrations", s.handleListSettingsIntegrations)
@@ -214,12 +413,25 @@ func (s *Server) Handler() http.Handler {
 			r.Get("/integrations/{integration}", s.handleGetSettingsIntegration)
 			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Put("/integrations/{integration}", s.handleUpdateSettingsIntegration)
 			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Delete("/integrations/{integration}", s.handleDeleteSettingsIntegration)
+			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/integrations/{integration}/credentials/verify", s.handleVerifyIntegrationCredential)
 			r.Get("/projects", s.handleListSettingsRepos)
 			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/projects", s.handleCreateSettingsRepo)
 			r.Get("/projects/{project}", s.handleGetSettingsRepo)
 			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Put("/projects/{project}", s.handleUpdateSettingsRepo)
 			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Delete("/projects/{project}", s.handleDeleteSettingsRepo)
 			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/projects/{project}/test", s.handleTestProjectConnection)
+			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/projects/{project}/credentials/verify", s.handleVerifyProjectCredential)
+			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Put("/projects/{project}/webhook-secret", s.handleSetProjectWebhookSecret)
+			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Delete("/projects/{project}/webhook-secret", s.handleDeleteProjectWebhookSecret)
+			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/onboarding/probe", s.handleProbeProject)
+			r.Get("/projects/{project}/metadata", s.handleGetProjectMetadata)
+			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Put("/projects/{project}/metadata", s.handleSetProjectMetadata)
+			r.Get("/scan-limits", s.handleGetScanLimits)
+			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Put("/scan-limits", s.handleSetScanLimits)
+			r.Get("/report", s.handleGetReportSettings)
+			r.Get("/report/preview", s.handlePreviewReport)
+			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Put("/report/recipients", s.handleUpdateReportRecipients)
+			r.With(s.rateLimitMiddleware, s.apiWriteAuthMiddleware).Post("/report/send", s.handleSendReport)
 		})
 	})
 
@@ -0,0 +1,310 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/secrets"
+)
+
+const (
+	sessionCookieName         = "driftd_session"
+	sessionContextKey         = contextKey("session")
+	defaultSessionMaxAge      = 12 * time.Hour
+	defaultSessionIdleTimeout = 30 * time.Minute
+	// sessionRefreshEvery limits how often the cookie is re-issued to record activity.
+	sessionRefreshEvery = time.Minute
+)
+
+var errInvalidSession = errors.New("invalid session")
+
+// uiSession is the signed payload stored in the session cookie.
+type uiSession struct {
+	// ID identifies the session so logout can revoke it on every replica.
+	ID       string `json:"sid"`
+	User     string `json:"u"`
+	IssuedAt int64  `json:"iat"`
+	LastSeen int64  `json:"seen"`
+	CSRF     string `json:"csrf"`
+}
+
+// sessionsEnabled reports whether the UI uses driftd-managed login sessions.
+// External mode relies on the upstream proxy for identity instead.
+func (s *Server) sessionsEnabled() bool {
+	return !s.useExternalAuth() && (s.cfg.UIAuth.Username != "" || s.cfg.UIAuth.Password != "")
+}
+
+func (s *Server) sessionMaxAge() time.Duration {
+	if s.cfg.Auth.Session.MaxAge > 0 {
+		return s.cfg.Auth.Session.MaxAge
+	}
+	return defaultSessionMaxAge
+}
+
+func (s *Server) sessionIdleTimeout() time.Duration {
+	if s.cfg.Auth.Session.IdleTimeout > 0 {
+		return s.cfg.Auth.Session.IdleTimeout
+	}
+	return defaultSessionIdleTimeout
+}
+
+// loadSessionKey picks the HMAC key for session cookies: the configured
+// secret, else a key derived from the encryption key so all replicas agree,
+// else a random per-process key.
+func loadSessionKey(secret string) []byte {
+	if secret != "" {
+		sum := sha256.Sum256([]byte(secret))
+		return sum[:]
+	}
+	if encoded := strings.TrimSpace(os.Getenv(secrets.EnvEncryptionKey)); encoded != "" {
+		if key, err := secrets.DecodeKey(encoded); err == nil {
+			mac := hmac.New(sha256.New, key)
+			mac.Write([]byte("driftd-session-v1"))
+			return mac.Sum(nil)
+		}
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Printf("session key generation failed: %v", err)
+	}
+	return key
+}
+
+func (s *Server) signSession(sess *uiSession) (string, error) {
+	payload, err := json.Marshal(sess)
+	if err != nil {
+		return "", err
+	}
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, s.sessionKey)
+	mac.Write([]byte(body))
+	return body + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (s *Server) parseSession(value string, now time.Time) (*uiSession, error) {
+	body, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return nil, errInvalidSession
+	}
+	gotSig, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return nil, errInvalidSession
+	}
+	mac := hmac.New(sha256.New, s.sessionKey)
+	mac.Write([]byte(body))
+	if !hmac.Equal(gotSig, mac.Sum(nil)) {
+		return nil, errInvalidSession
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, errInvalidSession
+	}
+	var sess uiSession
+	if err := json.Unmarshal(payload, &sess); err != nil {
+		return nil, errInvalidSession
+	}
+	if sess.ID == "" || sess.User == "" || sess.CSRF == "" {
+		return nil, errInvalidSession
+	}
+	if now.Sub(time.Unix(sess.IssuedAt, 0)) > s.sessionMaxAge() {
+		return nil, errInvalidSession
+	}
+	if now.Sub(time.Unix(sess.LastSeen, 0)) > s.sessionIdleTimeout() {
+		return nil, errInvalidSession
+	}
+	return &sess, nil
+}
+
+func (s *Server) writeSessionCookie(w http.ResponseWriter, sess *uiSession) error {
+	value, err := s.signSession(sess)
+	if err != nil {
+		return err
+	}
+	remaining := time.Unix(sess.IssuedAt, 0).Add(s.sessionMaxAge()).Sub(time.Now())
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   int(remaining.Seconds()),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+func clearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// sessionFromRequest validates the session cookie and, when enough time has
+// passed, re-issues it to extend the idle window.
+func (s *Server) sessionFromRequest(w http.ResponseWriter, r *http.Request) *uiSession {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	now := time.Now()
+	sess, err := s.parseSession(cookie.Value, now)
+	if err != nil {
+		return nil
+	}
+	if s.sessionRevoked(r.Context(), sess) {
+		return nil
+	}
+	if now.Sub(time.Unix(sess.LastSeen, 0)) >= sessionRefreshEvery {
+		sess.LastSeen = now.Unix()
+		if err := s.writeSessionCookie(w, sess); err != nil {
+			log.Printf("session refresh failed: %v", err)
+		}
+	}
+	setPrincipal(r, "session", sess.User)
+	return sess
+}
+
+// sessionRevoked reports whether the session was logged out. If the queue
+// backend cannot answer, sessions are refused unless the server is already
+// read-only, where a stale session cannot change anything. A read-only
+// server does not ask at all.
+func (s *Server) sessionRevoked(ctx context.Context, sess *uiSession) bool {
+	if s.degradedStatus().Degraded {
+		return false
+	}
+	revoked, err := s.queue.IsSessionRevoked(ctx, sess.ID)
+	if err != nil {
+		log.Printf("session revocation check failed: %v", err)
+		return !s.degradedStatus().Degraded
+	}
+	return revoked
+}
+
+func sessionFromContext(ctx context.Context) *uiSession {
+	if sess, ok := ctx.Value(sessionContextKey).(*uiSession); ok {
+		return sess
+	}
+	return nil
+}
+
+// safeRedirectTarget only allows local absolute paths as post-login targets.
+func safeRedirectTarget(next string) string {
+	if next == "" || !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	if strings.HasPrefix(next, "/login") || strings.HasPrefix(next, "/logout") {
+		return "/"
+	}
+	return next
+}
+
+func loginRedirectURL(r *http.Request) string {
+	target := r.URL.RequestURI()
+	if target == "" || target == "/" {
+		return "/login"
+	}
+	return "/login?next=" + url.QueryEscape(target)
+}
+
+type loginPageData struct {
+	pageAuth
+	Next  string
+	Error string
+}
+
+func (s *Server) handleLoginPage(w http.ResponseWriter, r *http.Request) {
+	if !s.sessionsEnabled() {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	if sess := s.sessionFromRequest(w, r); sess != nil {
+		http.Redirect(w, r, safeRedirectTarget(r.URL.Query().Get("next")), http.StatusSeeOther)
+		return
+	}
+	s.renderLogin(w, r, http.StatusOK, r.URL.Query().Get("next"), "")
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !s.sessionsEnabled() {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+	next := r.PostForm.Get("next")
+	username := r.PostForm.Get("username")
+	password := r.PostForm.Get("password")
+	if subtle.ConstantTimeCompare([]byte(username), []byte(s.cfg.UIAuth.Username)) != 1 ||
+		subtle.ConstantTimeCompare([]byte(password), []byte(s.cfg.UIAuth.Password)) != 1 {
+		s.renderLogin(w, r, http.StatusUnauthorized, next, "Invalid username or password")
+		return
+	}
+
+	now := time.Now().Unix()
+	sess := &uiSession{
+		ID:       generateToken(16),
+		User:     username,
+		IssuedAt: now,
+		LastSeen: now,
+		CSRF:     generateToken(32),
+	}
+	if err := s.writeSessionCookie(w, sess); err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, safeRedirectTarget(next), http.StatusSeeOther)
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	// Revoke the session server-side too, so a copied cookie stops working.
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if sess, err := s.parseSession(cookie.Value, time.Now()); err == nil {
+			remaining := time.Until(time.Unix(sess.IssuedAt, 0).Add(s.sessionMaxAge()))
+			if err := s.queue.RevokeSession(r.Context(), sess.ID, remaining); err != nil {
+				log.Printf("session revocation failed: %v", err)
+			}
+		}
+	}
+	clearSessionCookie(w)
+	target := "/"
+	if s.sessionsEnabled() {
+		target = "/login"
+	}
+	if logoutURL := strings.TrimSpace(s.cfg.Auth.Session.LogoutURL); logoutURL != "" {
+		target = logoutURL
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+func (s *Server) renderLogin(w http.ResponseWriter, r *http.Request, status int, next, errMsg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	data := loginPageData{
+		pageAuth: s.pageAuth(r),
+		Next:     safeRedirectTarget(next),
+		Error:    errMsg,
+	}
+	if err := s.tmplLogin.ExecuteTemplate(w, "layout", data); err != nil {
+		log.Printf("template error: %v", err)
+	}
+}
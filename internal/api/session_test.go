@@ -0,0 +1,231 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+func newSessionTestServer(t *testing.T) (*Server, *httptest.Server, func()) {
+	t.Helper()
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, func(cfg *config.Config) {
+		cfg.UIAuth.Username = "user"
+		cfg.UIAuth.Password = "pass"
+		cfg.Auth.Session.Secret = "test-secret"
+	})
+	return srv, ts, cleanup
+}
+
+func noRedirectClient() *http.Client {
+	return &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+}
+
+func findCookie(resp *http.Response, name string) *http.Cookie {
+	for _, c := range resp.Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestSessionLoginLogoutFlow(t *testing.T) {
+	_, ts, cleanup := newSessionTestServer(t)
+	defer cleanup()
+	client := noRedirectClient()
+
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/projects/project", nil)
+	req.Header.Set("Accept", "text/html")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("get project: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/login?next=%2Fprojects%2Fproject" {
+		t.Fatalf("expected redirect to login, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if resp.Header.Get("WWW-Authenticate") != "" {
+		t.Fatalf("browser requests should not trigger a basic auth prompt")
+	}
+
+	resp, err = client.Get(ts.URL + "/login")
+	if err != nil {
+		t.Fatalf("get login: %v", err)
+	}
+	resp.Body.Close()
+	csrf := findCookie(resp, csrfCookieName)
+	if csrf == nil {
+		t.Fatalf("expected csrf cookie on login page")
+	}
+
+	login := func(password string) *http.Response {
+		form := url.Values{"username": {"user"}, "password": {password}, "csrf_token": {csrf.Value}, "next": {"/projects/project"}}
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(csrf)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("post login: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if resp := login("wrong"); resp.StatusCode != http.StatusUnauthorized || findCookie(resp, sessionCookieName) != nil {
+		t.Fatalf("expected rejected login, got %d", resp.StatusCode)
+	}
+
+	resp = login("pass")
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/projects/project" {
+		t.Fatalf("expected redirect after login, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	session := findCookie(resp, sessionCookieName)
+	if session == nil || !session.HttpOnly || !session.Secure {
+		t.Fatalf("expected secure http-only session cookie, got %+v", session)
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, ts.URL+"/projects/project", nil)
+	req.AddCookie(session)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("get project with session: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 with session, got %d", resp.StatusCode)
+	}
+
+	// The double-submit cookie token is not valid once a session exists.
+	form := url.Values{"csrf_token": {csrf.Value}}
+	req, _ = http.NewRequest(http.MethodPost, ts.URL+"/logout", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(session)
+	req.AddCookie(csrf)
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatalf("post logout: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected csrf rejection for unbound token, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessionLogoutClearsCookie(t *testing.T) {
+	srv, ts, cleanup := newSessionTestServer(t)
+	defer cleanup()
+
+	now := time.Now().Unix()
+	value, err := srv.signSession(&uiSession{ID: "sess", User: "user", IssuedAt: now, LastSeen: now, CSRF: "tok"})
+	if err != nil {
+		t.Fatalf("sign session: %v", err)
+	}
+
+	form := url.Values{"csrf_token": {"tok"}}
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/logout", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: value})
+	resp, err := noRedirectClient().Do(req)
+	if err != nil {
+		t.Fatalf("post logout: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "/login" {
+		t.Fatalf("expected redirect to login, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	cleared := findCookie(resp, sessionCookieName)
+	if cleared == nil || cleared.MaxAge >= 0 {
+		t.Fatalf("expected session cookie to be cleared, got %+v", cleared)
+	}
+
+	// A copy of the cookie kept after logout no longer works.
+	req, _ = http.NewRequest(http.MethodGet, ts.URL+"/projects/project", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: value})
+	resp, err = noRedirectClient().Do(req)
+	if err != nil {
+		t.Fatalf("get with revoked session: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected revoked session to be rejected, got %d", resp.StatusCode)
+	}
+}
+
+func TestSessionUnauthorizedChallenge(t *testing.T) {
+	_, ts, cleanup := newSessionTestServer(t)
+	defer cleanup()
+
+	resp, err := noRedirectClient().Get(ts.URL + "/projects/project")
+	if err != nil {
+		t.Fatalf("get project: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") != `Basic realm="driftd"` {
+		t.Fatalf("expected basic auth challenge for non-browser client, got %d %q", resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/projects/project/scan", nil)
+	req.Header.Set("Sec-Fetch-Mode", "cors")
+	resp, err = noRedirectClient().Do(req)
+	if err != nil {
+		t.Fatalf("post from browser: %v", err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("WWW-Authenticate") != "" {
+		t.Fatalf("browser requests should not trigger a basic auth prompt")
+	}
+}
+
+func TestParseSessionRejectsTamperedAndExpired(t *testing.T) {
+	srv, _, cleanup := newSessionTestServer(t)
+	defer cleanup()
+	now := time.Now()
+
+	valid, _ := srv.signSession(&uiSession{ID: "sess", User: "user", IssuedAt: now.Unix(), LastSeen: now.Unix(), CSRF: "tok"})
+	if _, err := srv.parseSession(valid, now); err != nil {
+		t.Fatalf("expected valid session, got %v", err)
+	}
+
+	tampered := "x" + valid
+	if _, err := srv.parseSession(tampered, now); err == nil {
+		t.Fatalf("expected tampered session to be rejected")
+	}
+
+	idle, _ := srv.signSession(&uiSession{ID: "sess", User: "user", IssuedAt: now.Unix(), LastSeen: now.Add(-time.Hour).Unix(), CSRF: "tok"})
+	if _, err := srv.parseSession(idle, now); err == nil {
+		t.Fatalf("expected idle session to be rejected")
+	}
+
+	old, _ := srv.signSession(&uiSession{ID: "sess", User: "user", IssuedAt: now.Add(-13 * time.Hour).Unix(), LastSeen: now.Unix(), CSRF: "tok"})
+	if _, err := srv.parseSession(old, now); err == nil {
+		t.Fatalf("expected session past max age to be rejected")
+	}
+
+	other := &Server{cfg: srv.cfg, sessionKey: loadSessionKey("other-secret")}
+	if _, err := other.parseSession(valid, now); err == nil {
+		t.Fatalf("expected session signed with another key to be rejected")
+	}
+}
+
+func TestSafeRedirectTarget(t *testing.T) {
+	tests := map[string]string{
+		"":                     "/",
+		"/projects/x":          "/projects/x",
+		"//evil.example":       "/",
+		"/\\evil.example":      "/",
+		"https://evil.example": "/",
+		"/login?next=/":        "/",
+	}
+	for in, want := range tests {
+		if got := safeRedirectTarget(in); got != want {
+			t.Fatalf("safeRedirectTarget(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
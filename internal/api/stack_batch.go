@@ -0,0 +1,246 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/orchestrate"
+	"github.com/driftdhq/driftd/internal/pathutil"
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+const maxBatchStacks = 500
+
+const (
+	batchActionScan          = "scan"
+	batchActionSuppress      = "suppress"
+	batchActionUnsuppress    = "unsuppress"
+	batchActionAcknowledge   = "acknowledge"
+	batchActionUnacknowledge = "unacknowledge"
+)
+
+var (
+	errInvalidBatchAction = errors.New("invalid batch action")
+	errNoBatchStacks      = errors.New("no stacks selected")
+	errTooManyBatchStacks = fmt.Errorf("at most %d stacks per batch", maxBatchStacks)
+	errInvalidBatchStack  = errors.New("invalid stack path")
+	errNoKnownBatchStacks = errors.New("none of the selected stacks exist in the latest discovery")
+)
+
+type stackBatchRequest struct {
+	Action  string   `json:"action"`
+	Stacks  []string `json:"stacks"`
+	Trigger string   `json:"trigger,omitempty"`
+	Actor   string   `json:"actor,omitempty"`
+}
+
+type stackBatchResult struct {
+	Action   string   `json:"action"`
+	Applied  []string `json:"applied"`
+	Stale    []string `json:"stale,omitempty"`
+	StackIDs []string `json:"stack_ids,omitempty"`
+	Scan     *apiScan `json:"scan,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// normalizeBatchStacks trims, de-duplicates and validates the requested stack
+// paths, preserving request order.
+func normalizeBatchStacks(stacks []string) ([]string, error) {
+	seen := make(map[string]struct{}, len(stacks))
+	out := make([]string, 0, len(stacks))
+	for _, stackPath := range stacks {
+		stackPath = strings.TrimSpace(stackPath)
+		if stackPath == "" {
+			continue
+		}
+		if !pathutil.IsSafeStackPath(stackPath) {
+			return nil, errInvalidBatchStack
+		}
+		if _, ok := seen[stackPath]; ok {
+			continue
+		}
+		seen[stackPath] = struct{}{}
+		out = append(out, stackPath)
+	}
+	if len(out) == 0 {
+		return nil, errNoBatchStacks
+	}
+	if len(out) > maxBatchStacks {
+		return nil, errTooManyBatchStacks
+	}
+	return out, nil
+}
+
+func splitKnownStacks(requested, known []string) (valid, stale []string) {
+	for _, stackPath := range requested {
+		if containsStack(stackPath, known) {
+			valid = append(valid, stackPath)
+		} else {
+			stale = append(stale, stackPath)
+		}
+	}
+	return valid, stale
+}
+
+// applyStackBatch runs a bulk action over a project's stacks. Scans validate
+// paths against a fresh discovery of the project; annotation actions validate
+// against the stacks that have stored results. Unknown paths are reported as
+// stale and skipped.
+func (s *Server) applyStackBatch(ctx context.Context, projectCfg *config.ProjectConfig, req stackBatchRequest) (*stackBatchResult, error) {
+	stacks, err := normalizeBatchStacks(req.Stacks)
+	if err != nil {
+		return nil, err
+	}
+	result := &stackBatchResult{Action: req.Action, Applied: []string{}}
+
+	switch req.Action {
+	case batchActionScan:
+		trigger := normalizeScanTrigger(req.Trigger)
+		scan, discovered, err := s.startScanWithCancel(ctx, projectCfg, trigger, "", req.Actor)
+		if err != nil {
+			return nil, err
+		}
+		valid, stale := splitKnownStacks(stacks, discovered)
+		result.Stale = stale
+		if len(valid) == 0 {
+			_ = s.queue.FailScan(ctx, scan.ID, projectCfg.Name, "stack not found")
+			return result, errNoKnownBatchStacks
+		}
+		enqResult, err := s.orchestrator.EnqueueStacks(ctx, scan, projectCfg, valid, trigger, "", req.Actor)
+		if err != nil {
+			return result, err
+		}
+		result.Applied = valid
+		result.StackIDs = enqResult.StackIDs
+		result.Scan = toAPIScan(scan)
+		if len(enqResult.Errors) > 0 {
+			result.Error = strings.Join(enqResult.Errors, "; ")
+		}
+		return result, nil
+
+	case batchActionSuppress, batchActionUnsuppress, batchActionAcknowledge, batchActionUnacknowledge:
+		statuses, err := s.storage.ListStacks(projectCfg.Name)
+		if err != nil {
+			return nil, err
+		}
+		known := make([]string, 0, len(statuses))
+		for _, st := range filterParentStackStatuses(statuses) {
+			known = append(known, st.Path)
+		}
+		valid, stale := splitKnownStacks(stacks, known)
+		result.Stale = stale
+		if len(valid) == 0 {
+			return result, errNoKnownBatchStacks
+		}
+		for _, stackPath := range valid {
+			var err error
+			switch req.Action {
+			case batchActionSuppress, batchActionUnsuppress:
+				err = s.storage.SetStackSuppressed(projectCfg.Name, stackPath, req.Action == batchActionSuppress, req.Actor)
+			default:
+				err = s.storage.SetStackAcknowledged(projectCfg.Name, stackPath, req.Action == batchActionAcknowledge, req.Actor)
+			}
+			if err != nil {
+				return result, err
+			}
+			result.Applied = append(result.Applied, stackPath)
+		}
+		return result, nil
+
+	default:
+		return nil, errInvalidBatchAction
+	}
+}
+
+// isBatchClientError reports whether err stems from a malformed or
+// non-applicable batch request rather than a server-side failure.
+func isBatchClientError(err error) bool {
+	return errors.Is(err, errInvalidBatchAction) ||
+		errors.Is(err, errNoBatchStacks) ||
+		errors.Is(err, errTooManyBatchStacks) ||
+		errors.Is(err, errInvalidBatchStack)
+}
+
+func isBatchConflict(err error) bool {
+	return errors.Is(err, queue.ErrProjectLocked) || errors.Is(err, orchestrate.ErrNoStacksEnqueued)
+}
+
+// maxBatchNoticeStacks caps the skipped stacks named in the redirect after a
+// UI batch action; the rest are only counted.
+const maxBatchNoticeStacks = 20
+
+// batchNotice tells the project page what a UI batch action left undone.
+// It travels in the redirect's query string, so problems are fixed codes
+// rather than free text.
+type batchNotice struct {
+	Action       string
+	Problem      string
+	Skipped      []string
+	SkippedTotal int
+}
+
+// More is how many skipped stacks are not named.
+func (n *batchNotice) More() int {
+	return n.SkippedTotal - len(n.Skipped)
+}
+
+var batchProblems = map[string]string{
+	"stale":         "None of the selected stacks exist any more.",
+	"locked":        "A scan of this project is already running.",
+	"none_enqueued": "No stacks could be queued.",
+	"partial":       "Some stacks could not be queued.",
+}
+
+// batchNoticeQuery encodes the outcome of a batch action for the redirect.
+func batchNoticeQuery(action string, result *stackBatchResult, err error) url.Values {
+	q := url.Values{}
+	switch {
+	case errors.Is(err, errNoKnownBatchStacks):
+		q.Set("batch_error", "stale")
+	case errors.Is(err, queue.ErrProjectLocked):
+		q.Set("batch_error", "locked")
+	case errors.Is(err, orchestrate.ErrNoStacksEnqueued):
+		q.Set("batch_error", "none_enqueued")
+	case result != nil && result.Error != "":
+		q.Set("batch_error", "partial")
+	}
+	if result != nil && len(result.Stale) > 0 {
+		for i, stackPath := range result.Stale {
+			if i == maxBatchNoticeStacks {
+				break
+			}
+			q.Add("batch_skipped", stackPath)
+		}
+		q.Set("batch_skipped_total", strconv.Itoa(len(result.Stale)))
+	}
+	if len(q) > 0 {
+		q.Set("batch", action)
+	}
+	return q
+}
+
+// batchNoticeFromQuery reads the notice batchNoticeQuery wrote, or nil.
+func batchNoticeFromQuery(q url.Values) *batchNotice {
+	action := q.Get("batch")
+	switch action {
+	case batchActionScan, batchActionSuppress, batchActionUnsuppress, batchActionAcknowledge, batchActionUnacknowledge:
+	default:
+		return nil
+	}
+	notice := &batchNotice{Action: action, Problem: batchProblems[q.Get("batch_error")]}
+	for _, stackPath := range q["batch_skipped"] {
+		if pathutil.IsSafeStackPath(stackPath) && len(notice.Skipped) < maxBatchNoticeStacks {
+			notice.Skipped = append(notice.Skipped, stackPath)
+		}
+	}
+	notice.SkippedTotal = max(parseInt(q.Get("batch_skipped_total"), 0), len(notice.Skipped))
+	if notice.Problem == "" && notice.SkippedTotal == 0 {
+		return nil
+	}
+	return notice
+}
@@ -0,0 +1,190 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/go-chi/chi/v5"
+)
+
+func postStackBatch(t *testing.T, ts *httptest.Server, body string) (int, stackBatchResult) {
+	t.Helper()
+	resp, err := http.Post(ts.URL+"/api/projects/project/stacks:batch", "application/json", bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatalf("batch request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result stackBatchResult
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatalf("decode batch response: %v", err)
+		}
+	}
+	return resp.StatusCode, result
+}
+
+func TestStackBatchScanSkipsStalePaths(t *testing.T) {
+	runner := &fakeRunner{drifted: map[string]bool{"envs/prod": true}}
+	ts, _, cleanup := newTestServer(t, runner, []string{"envs/prod", "envs/dev"}, true, nil, true)
+	defer cleanup()
+
+	status, result := postStackBatch(t, ts, `{"action":"scan","stacks":["envs/prod","envs/gone","envs/prod"]}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%+v)", status, result)
+	}
+	if strings.Join(result.Applied, ",") != "envs/prod" {
+		t.Fatalf("expected only envs/prod applied, got %v", result.Applied)
+	}
+	if strings.Join(result.Stale, ",") != "envs/gone" {
+		t.Fatalf("expected envs/gone reported stale, got %v", result.Stale)
+	}
+	if result.Scan == nil || len(result.StackIDs) != 1 {
+		t.Fatalf("expected scan with one stack, got %+v", result)
+	}
+
+	scan := waitForScan(t, ts, result.Scan.ID, 5*time.Second)
+	if scan.Status != queue.ScanStatusCompleted || scan.Total != 1 {
+		t.Fatalf("unexpected scan state: status=%s total=%d", scan.Status, scan.Total)
+	}
+}
+
+func TestStackBatchScanAllStaleFailsScan(t *testing.T) {
+	ts, q, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true)
+	defer cleanup()
+
+	status, result := postStackBatch(t, ts, `{"action":"scan","stacks":["envs/gone"]}`)
+	if status != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d", status)
+	}
+	if strings.Join(result.Stale, ",") != "envs/gone" {
+		t.Fatalf("expected stale path in response, got %+v", result)
+	}
+	if locked, _ := q.IsProjectLocked(t.Context(), "project"); locked {
+		t.Fatalf("expected project lock released after stale batch")
+	}
+}
+
+func TestStackBatchSuppressAndAcknowledge(t *testing.T) {
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod", "envs/dev"}, false, nil, true, nil)
+	defer cleanup()
+
+	if err := srv.storage.SaveResult("project", "envs/prod", &storage.RunResult{Drifted: true, RunAt: time.Now()}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+
+	status, result := postStackBatch(t, ts, `{"action":"suppress","stacks":["envs/prod","envs/dev"],"actor":"alice"}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200, got %d (%+v)", status, result)
+	}
+	if strings.Join(result.Applied, ",") != "envs/prod" || strings.Join(result.Stale, ",") != "envs/dev" {
+		t.Fatalf("unexpected suppress result: %+v", result)
+	}
+
+	status, _ = postStackBatch(t, ts, `{"action":"acknowledge","stacks":["envs/prod"]}`)
+	if status != http.StatusOK {
+		t.Fatalf("expected 200 for acknowledge, got %d", status)
+	}
+
+	stacks, err := srv.storage.ListStacks("project")
+	if err != nil || len(stacks) != 1 {
+		t.Fatalf("list stacks: %v (%v)", err, stacks)
+	}
+	if !stacks[0].Suppressed || !stacks[0].Acknowledged {
+		t.Fatalf("expected suppressed and acknowledged stack, got %+v", stacks[0])
+	}
+}
+
+func TestStackBatchRejectsInvalidRequests(t *testing.T) {
+	ts, _, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true)
+	defer cleanup()
+
+	for _, body := range []string{
+		`{"action":"explode","stacks":["envs/prod"]}`,
+		`{"action":"suppress","stacks":[]}`,
+		`{"action":"suppress","stacks":["../etc"]}`,
+	} {
+		if status, _ := postStackBatch(t, ts, body); status != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", body, status)
+		}
+	}
+}
+
+func TestStackBatchUIRedirects(t *testing.T) {
+	srv, _, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, nil)
+	defer cleanup()
+
+	if err := srv.storage.SaveResult("project", "envs/prod", &storage.RunResult{Drifted: true, RunAt: time.Now()}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+
+	form := url.Values{"action": {"suppress"}, "stacks": {"envs/prod"}}
+	req := httptest.NewRequest(http.MethodPost, "/projects/project/stacks:batch", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	srv.handleStackBatchUI(rec, withProjectParam(req, "project"))
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303, got %d: %s", rec.Code, rec.Body.String())
+	}
+	stacks, _ := srv.storage.ListStacks("project")
+	if len(stacks) != 1 || !stacks[0].Suppressed {
+		t.Fatalf("expected stack suppressed via UI, got %+v", stacks)
+	}
+}
+
+func TestStackBatchUIReportsSkippedStacks(t *testing.T) {
+	srv, _, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true, nil)
+	defer cleanup()
+
+	if err := srv.storage.SaveResult("project", "envs/prod", &storage.RunResult{Drifted: true, RunAt: time.Now()}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+
+	form := url.Values{"action": {"acknowledge"}, "stacks": {"envs/prod", "envs/gone"}}
+	req := httptest.NewRequest(http.MethodPost, "/projects/project/stacks:batch", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	srv.handleStackBatchUI(rec, withProjectParam(req, "project"))
+
+	if rec.Code != http.StatusSeeOther {
+		t.Fatalf("expected 303, got %d: %s", rec.Code, rec.Body.String())
+	}
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("parse location: %v", err)
+	}
+	notice := batchNoticeFromQuery(loc.Query())
+	if notice == nil || notice.Action != "acknowledge" || notice.SkippedTotal != 1 || len(notice.Skipped) != 1 || notice.Skipped[0] != "envs/gone" {
+		t.Fatalf("expected skipped envs/gone in redirect %q, got %+v", loc, notice)
+	}
+
+	form = url.Values{"action": {"suppress"}, "stacks": {"envs/gone"}}
+	req = httptest.NewRequest(http.MethodPost, "/projects/project/stacks:batch", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	srv.handleStackBatchUI(rec, withProjectParam(req, "project"))
+	loc, _ = url.Parse(rec.Header().Get("Location"))
+	if notice := batchNoticeFromQuery(loc.Query()); notice == nil || notice.Problem == "" {
+		t.Fatalf("expected stale problem in redirect %q", loc)
+	}
+
+	if notice := batchNoticeFromQuery(url.Values{"batch": {"scan"}, "batch_error": {"<script>"}}); notice != nil {
+		t.Fatalf("expected unknown problem codes to be ignored, got %+v", notice)
+	}
+}
+
+func withProjectParam(r *http.Request, project string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("project", project)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, rctx))
+}
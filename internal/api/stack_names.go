@@ -0,0 +1,34 @@
+package api
+
+import (
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+// stackDisplays maps each stack path to its display name and group, leaving
+// out stacks that match no stack_names rule.
+func stackDisplays(projectCfg *config.ProjectConfig, stacks []storage.StackStatus) map[string]config.StackDisplay {
+	out := map[string]config.StackDisplay{}
+	if projectCfg == nil || len(projectCfg.StackNames) == 0 {
+		return out
+	}
+	for _, st := range stacks {
+		if display := projectCfg.StackDisplay(st.Path); display != (config.StackDisplay{}) {
+			out[st.Path] = display
+		}
+	}
+	return out
+}
+
+func filterStacksByGroup(projectCfg *config.ProjectConfig, stacks []storage.StackStatus, group string) []storage.StackStatus {
+	if group == "" {
+		return stacks
+	}
+	filtered := make([]storage.StackStatus, 0, len(stacks))
+	for _, st := range stacks {
+		if projectCfg.StackDisplay(st.Path).Group == group {
+			filtered = append(filtered, st)
+		}
+	}
+	return filtered
+}
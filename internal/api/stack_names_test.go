@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestStackDisplayNamesInAPIAndUI(t *testing.T) {
+	stacks := []string{"envs/prod/us-east-1/payments-api-v2-blue", "shared/dns"}
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, stacks, false, nil, true, func(cfg *config.Config) {
+		cfg.Projects[0].StackNames = []config.StackNameRule{
+			{Pattern: `^envs/(\w+)/[^/]+/(.+?)(-v\d+)?(-blue|-green)?$`, Name: "$2 ($1)", Group: "payments"},
+		}
+	})
+	defer cleanup()
+
+	for _, path := range stacks {
+		if err := srv.storage.SaveResult("project", path, &storage.RunResult{RunAt: time.Now(), Drifted: true}); err != nil {
+			t.Fatalf("save result: %v", err)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/api/projects/project/stacks/" + stacks[0] + "/plan")
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	defer resp.Body.Close()
+	var plan apiStackPlan
+	if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if plan.StackPath != stacks[0] || plan.DisplayName != "payments-api (prod)" || plan.Group != "payments" {
+		t.Fatalf("unexpected plan identity: path=%q name=%q group=%q", plan.StackPath, plan.DisplayName, plan.Group)
+	}
+
+	page, err := http.Get(ts.URL + "/projects/project?group=payments")
+	if err != nil {
+		t.Fatalf("project page: %v", err)
+	}
+	defer page.Body.Close()
+	body, _ := io.ReadAll(page.Body)
+	if page.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", page.StatusCode, body)
+	}
+
+	projectCfg, _ := srv.getProjectConfig("project")
+	filtered := filterStacksByGroup(projectCfg, []storage.StackStatus{{Path: stacks[0]}, {Path: stacks[1]}}, "payments")
+	if len(filtered) != 1 || filtered[0].Path != stacks[0] {
+		t.Fatalf("unexpected filtered stacks: %+v", filtered)
+	}
+	displays := stackDisplays(projectCfg, filtered)
+	if got := displays[stacks[0]].Name; !strings.HasPrefix(got, "payments-api") {
+		t.Fatalf("unexpected display name %q", got)
+	}
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/driftdhq/driftd/internal/stack"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+const maxTagFilters = 10
+
+// parseTagFilters turns ?tag=key:value query values into a filter map. Each
+// value may hold several filters separated by commas or spaces, which is what
+// the project page's filter box submits. Malformed keys are rejected.
+func parseTagFilters(values []string) (map[string]string, error) {
+	filters := map[string]string{}
+	for _, value := range values {
+		for _, raw := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+			key, value, ok := stack.ParseTagFilter(raw)
+			if !ok {
+				return nil, fmt.Errorf("invalid tag filter %q", raw)
+			}
+			filters[key] = value
+		}
+	}
+	if len(filters) > maxTagFilters {
+		return nil, fmt.Errorf("at most %d tag filters", maxTagFilters)
+	}
+	return filters, nil
+}
+
+// tagFilterValues renders filters back into sorted key:value query values.
+func tagFilterValues(filters map[string]string) []string {
+	out := make([]string, 0, len(filters))
+	for key, value := range filters {
+		if value == "" {
+			out = append(out, key)
+			continue
+		}
+		out = append(out, key+":"+value)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func filterStacksByTags(stacks []storage.StackStatus, filters map[string]string) []storage.StackStatus {
+	if len(filters) == 0 {
+		return stacks
+	}
+	filtered := make([]storage.StackStatus, 0, len(stacks))
+	for _, st := range stacks {
+		if stack.MatchTags(st.Tags, filters) {
+			filtered = append(filtered, st)
+		}
+	}
+	return filtered
+}
+
+// stackTags returns the last recorded tags for each of a project's stacks.
+func (s *Server) stackTags(projectName string) map[string]map[string]string {
+	statuses, err := s.storage.ListStacks(projectName)
+	if err != nil {
+		return nil
+	}
+	tags := make(map[string]map[string]string, len(statuses))
+	for _, st := range statuses {
+		if len(st.Tags) > 0 {
+			tags[st.Path] = st.Tags
+		}
+	}
+	return tags
+}
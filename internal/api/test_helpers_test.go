@@ -14,7 +14,6 @@ import (
 	"testing"
 	"time"
 
-	"github.com/alicebob/miniredis/v2"
 	"github.com/driftdhq/driftd/internal/config"
 	"github.com/driftdhq/driftd/internal/projects"
 	"github.com/driftdhq/driftd/internal/queue"
@@ -55,30 +54,21 @@ type scanResp struct {
 	Error      string     `json:"error"`
 }
 
-func newTestServer(t *testing.T, r worker.Runner, stacks []string, startWorker bool, versions *testVersions, cancelInflight bool) (*httptest.Server, *queue.Queue, func()) {
+func newTestServer(t *testing.T, r worker.Runner, stacks []string, startWorker bool, versions *testVersions, cancelInflight bool) (*httptest.Server, *queue.MemoryQueue, func()) {
 	t.Helper()
 	_, server, q, cleanup := newTestServerWithConfig(t, r, stacks, startWorker, versions, cancelInflight, nil)
 	return server, q, cleanup
 }
 
-func newTestServerWithConfig(t *testing.T, r worker.Runner, stacks []string, startWorker bool, versions *testVersions, cancelInflight bool, mutate func(*config.Config)) (*Server, *httptest.Server, *queue.Queue, func()) {
+func newTestServerWithConfig(t *testing.T, r worker.Runner, stacks []string, startWorker bool, versions *testVersions, cancelInflight bool, mutate func(*config.Config)) (*Server, *httptest.Server, *queue.MemoryQueue, func()) {
 	t.Helper()
 
-	mr, err := miniredis.Run()
-	if err != nil {
-		t.Fatalf("miniredis: %v", err)
-	}
-
 	projectDir := createTestRepo(t, stacks, versions)
 
 	cancelInflightFlag := cancelInflight
 
 	cfg := &config.Config{
 		DataDir: t.TempDir(),
-		Redis: config.RedisConfig{
-			Addr: mr.Addr(),
-			DB:   0,
-		},
 		Worker: config.WorkerConfig{
 			Concurrency: 1,
 			LockTTL:     2 * time.Minute,
@@ -86,6 +76,8 @@ func newTestServerWithConfig(t *testing.T, r worker.Runner, stacks []string, sta
 			ScanMaxAge:  1 * time.Minute,
 			RenewEvery:  10 * time.Second,
 		},
+		API:     config.APIConfig{IdempotencyWindow: 24 * time.Hour},
+		Webhook: config.WebhookConfig{MaxBodyBytes: 25 << 20},
 		Projects: []config.ProjectConfig{
 			{
 				Name:                       "project",
@@ -99,10 +91,7 @@ func newTestServerWithConfig(t *testing.T, r worker.Runner, stacks []string, sta
 		mutate(cfg)
 	}
 
-	q, err := queue.New(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, cfg.Worker.LockTTL)
-	if err != nil {
-		t.Fatalf("queue: %v", err)
-	}
+	q := queue.NewMemory(cfg.Worker.LockTTL)
 
 	store := storage.New(cfg.DataDir)
 	templatesFS := os.DirFS("testdata")
@@ -127,28 +116,18 @@ func newTestServerWithConfig(t *testing.T, r worker.Runner, stacks []string, sta
 		}
 		server.Close()
 		_ = q.Close()
-		mr.Close()
 	}
 
 	return srv, server, q, cleanup
 }
 
-func newTestServerWithProjectStore(t *testing.T, r worker.Runner, stacks []string, startWorker bool, setup func(store *secrets.ProjectStore, intStore *secrets.IntegrationStore, projectDir string), mutate func(*config.Config)) (*Server, *httptest.Server, *queue.Queue, func()) {
+func newTestServerWithProjectStore(t *testing.T, r worker.Runner, stacks []string, startWorker bool, setup func(store *secrets.ProjectStore, intStore *secrets.IntegrationStore, projectDir string), mutate func(*config.Config)) (*Server, *httptest.Server, *queue.MemoryQueue, func()) {
 	t.Helper()
 
-	mr, err := miniredis.Run()
-	if err != nil {
-		t.Fatalf("miniredis: %v", err)
-	}
-
 	projectDir := createTestRepo(t, stacks, nil)
 
 	cfg := &config.Config{
 		DataDir: t.TempDir(),
-		Redis: config.RedisConfig{
-			Addr: mr.Addr(),
-			DB:   0,
-		},
 		Worker: config.WorkerConfig{
 			Concurrency: 1,
 			LockTTL:     2 * time.Minute,
@@ -156,16 +135,15 @@ func newTestServerWithProjectStore(t *testing.T, r worker.Runner, stacks []strin
 			ScanMaxAge:  1 * time.Minute,
 			RenewEvery:  10 * time.Second,
 		},
+		API:      config.APIConfig{IdempotencyWindow: 24 * time.Hour},
+		Webhook:  config.WebhookConfig{MaxBodyBytes: 25 << 20},
 		Projects: nil,
 	}
 	if mutate != nil {
 		mutate(cfg)
 	}
 
-	q, err := queue.New(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, cfg.Worker.LockTTL)
-	if err != nil {
-		t.Fatalf("queue: %v", err)
-	}
+	q := queue.NewMemory(cfg.Worker.LockTTL)
 
 	store := storage.New(cfg.DataDir)
 	templatesFS := os.DirFS("testdata")
@@ -214,7 +192,6 @@ func newTestServerWithProjectStore(t *testing.T, r worker.Runner, stacks []strin
 		}
 		server.Close()
 		_ = q.Close()
-		mr.Close()
 	}
 
 	return srv, server, q, cleanup
@@ -1,136 +1,150 @@
 package api
 
 import (
-	"crypto/hmac"
+	"bytes"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"mime"
 	"net/http"
 	"path/filepath"
-	"sort"
 	"strings"
 	"time"
 
 	"github.com/driftdhq/driftd/internal/config"
 	"github.com/driftdhq/driftd/internal/orchestrate"
 	"github.com/driftdhq/driftd/internal/queue"
-	"github.com/driftdhq/driftd/internal/secrets"
+	"github.com/driftdhq/driftd/internal/vcs"
 )
 
 const webhookReplayWindow = 15 * time.Minute
 
-type gitHubPushPayload struct {
-	Ref        string `json:"ref"`
-	Repository struct {
-		Name          string `json:"name"`
-		FullName      string `json:"full_name"`
-		DefaultBranch string `json:"default_branch"`
-		CloneURL      string `json:"clone_url"`
-		SSHURL        string `json:"ssh_url"`
-		HTMLURL       string `json:"html_url"`
-	} `json:"repository"`
-	HeadCommit struct {
-		ID string `json:"id"`
-	} `json:"head_commit"`
-	Pusher struct {
-		Name string `json:"name"`
-	} `json:"pusher"`
-	Commits []struct {
-		Added    []string `json:"added"`
-		Modified []string `json:"modified"`
-		Removed  []string `json:"removed"`
-	} `json:"commits"`
+// handleWebhook serves push webhooks for one hosting provider. Changed files
+// are mapped to stacks so only affected stacks are re-planned.
+func (s *Server) handleWebhook(provider vcs.Provider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.serveWebhook(w, r, provider)
+	}
 }
 
-func (s *Server) handleGitHubWebhook(w http.ResponseWriter, r *http.Request) {
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Failed to read body", http.StatusBadRequest)
-		return
-	}
-	if !s.validateWebhookRequest(w, r, body) {
+func (s *Server) serveWebhook(w http.ResponseWriter, r *http.Request, provider vcs.Provider) {
+	body, auth, ok := s.readWebhookBody(w, r, provider)
+	if !ok {
 		return
 	}
-
-	event := r.Header.Get("X-GitHub-Event")
-	if event != "push" {
-		w.WriteHeader(http.StatusAccepted)
+	if provider.Name() == "github" && r.Header.Get("X-GitHub-Event") == "deployment_status" {
+		s.serveGitHubDeployment(w, r, body)
 		return
 	}
 
-	var payload gitHubPushPayload
-	if err := json.Unmarshal(body, &payload); err != nil {
+	push, err := provider.ParsePush(r, body)
+	if err != nil {
+		if errors.Is(err, vcs.ErrIgnoredEvent) {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
 		http.Error(w, "Invalid payload", http.StatusBadRequest)
 		return
 	}
 
-	if !strings.HasPrefix(payload.Ref, "refs/heads/") {
-		w.WriteHeader(http.StatusAccepted)
-		return
-	}
+	// Stacks named in driftd:resolve directives are acknowledged and
+	// re-planned to verify the fix even when the push changed nothing else.
+	resolved := parseResolveDirectives(push.CommitMessages)
 
-	branch := strings.TrimPrefix(payload.Ref, "refs/heads/")
+	// A truncated or force-pushed file list is replaced per project by a
+	// diff of the before..after range in the project's mirror.
+	useRange := push.BeforeCommit != "" && push.HeadCommit != "" && (push.FilesTruncated || push.Forced)
 
-	changedFiles := extractChangedFiles(payload, s.cfg.Webhook.MaxFiles)
-	if len(changedFiles) == 0 {
-		w.WriteHeader(http.StatusAccepted)
-		return
+	var changedFiles []string
+	if push.FilesKnown {
+		changedFiles = extractChangedFiles(push.ChangedFiles, s.cfg.Webhook.MaxFiles)
+		if len(changedFiles) == 0 && len(resolved) == 0 && !useRange {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
 	}
 
-	candidates, err := s.getReposByURL(payload.Repository.CloneURL, payload.Repository.SSHURL, payload.Repository.HTMLURL)
+	candidates, err := s.webhookCandidates(push)
 	if err != nil {
 		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
 		return
 	}
-	if len(candidates) == 0 && isValidProjectName(payload.Repository.Name) {
-		projectCfg, lookupErr := s.getProjectConfig(payload.Repository.Name)
-		if lookupErr == nil && projectCfg != nil {
-			candidates = append(candidates, projectCfg)
-		} else if lookupErr != nil && lookupErr != secrets.ErrProjectNotFound {
-			http.Error(w, s.sanitizeErrorMessage(lookupErr.Error()), http.StatusInternalServerError)
-			return
-		}
-	}
 	if len(candidates) == 0 {
 		w.WriteHeader(http.StatusNotFound)
 		json.NewEncoder(w).Encode(scanResponse{Error: "Project not configured"})
 		return
 	}
+	allowed := candidates[:0:0]
+	for _, projectCfg := range candidates {
+		if auth.allows(projectCfg.Name) {
+			allowed = append(allowed, projectCfg)
+		}
+	}
+	if len(allowed) == 0 {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+	candidates = allowed
 
 	trigger := "webhook"
 	var (
 		apiScans            []*apiScan
 		stackIDs            []string
+		acknowledged        []string
 		branchMatchedConfig bool
+		limitErr            error
 	)
 	for _, projectCfg := range candidates {
-		if !projectMatchesWebhookBranch(projectCfg, branch, payload.Repository.DefaultBranch) {
+		if !projectMatchesWebhookBranch(projectCfg, push.Branch, push.DefaultBranch) {
 			continue
 		}
-		if !projectPathMatchesWebhookChanges(projectCfg, changedFiles) {
+		projectFiles, filesKnown := changedFiles, push.FilesKnown
+		if useRange {
+			projectFiles, filesKnown = s.webhookRangeFiles(r, projectCfg, push)
+			if filesKnown && len(projectFiles) == 0 && len(resolved) == 0 {
+				continue
+			}
+		}
+		if filesKnown && !projectPathMatchesWebhookChanges(projectCfg, projectFiles) && !projectPathMatchesWebhookChanges(projectCfg, resolved) {
 			continue
 		}
 		branchMatchedConfig = true
 
-		scan, stacks, err := s.startScanWithCancel(r.Context(), projectCfg, trigger, payload.HeadCommit.ID, payload.Pusher.Name)
+		// Without a file list every discovered stack is affected.
+		var (
+			scan         *queue.Scan
+			targetStacks []string
+		)
+		switch {
+		case filesKnown && len(resolved) > 0:
+			scan, targetStacks, err = s.orchestrator.StartScanForChangesAndStacks(r.Context(), projectCfg, projectFiles, resolved, trigger, push.HeadCommit, push.Pusher)
+		case filesKnown:
+			scan, targetStacks, err = s.orchestrator.StartScanForChanges(r.Context(), projectCfg, projectFiles, trigger, push.HeadCommit, push.Pusher)
+		default:
+			scan, targetStacks, err = s.startScanWithCancel(r.Context(), projectCfg, trigger, push.HeadCommit, push.Pusher)
+		}
 		if err != nil {
 			if err == queue.ErrProjectLocked {
 				continue
 			}
+			if scanLimitError(err) != nil {
+				limitErr = err
+				continue
+			}
 			http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
 			return
 		}
-
-		targetStacks := selectStacksForChanges(stacks, changedFiles)
 		if len(targetStacks) == 0 {
 			_ = s.queue.FailScan(r.Context(), scan.ID, projectCfg.Name, "no matching stacks for webhook changes")
 			continue
 		}
 
-		enqResult, err := s.orchestrator.EnqueueStacks(r.Context(), scan, projectCfg, targetStacks, trigger, payload.HeadCommit.ID, payload.Pusher.Name)
+		enqResult, err := s.orchestrator.EnqueueStacks(r.Context(), scan, projectCfg, targetStacks, trigger, push.HeadCommit, push.Pusher)
 		if err != nil && err != orchestrate.ErrNoStacksEnqueued {
 			http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
 			return
@@ -140,8 +154,12 @@ func (s *Server) handleGitHubWebhook(w http.ResponseWriter, r *http.Request) {
 		if enqResult != nil {
 			stackIDs = append(stackIDs, enqResult.StackIDs...)
 		}
+		acknowledged = append(acknowledged, s.acknowledgeResolvedStacks(projectCfg, resolved, targetStacks, push.Pusher)...)
 	}
 
+	if len(apiScans) == 0 && writeScanLimited(w, limitErr) {
+		return
+	}
 	if !branchMatchedConfig || len(apiScans) == 0 {
 		w.WriteHeader(http.StatusAccepted)
 		return
@@ -149,9 +167,10 @@ func (s *Server) handleGitHubWebhook(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 	resp := scanResponse{
-		Stacks:  stackIDs,
-		Scans:   apiScans,
-		Message: fmt.Sprintf("Enqueued %d stacks", len(stackIDs)),
+		Stacks:       stackIDs,
+		Scans:        apiScans,
+		Acknowledged: acknowledged,
+		Message:      fmt.Sprintf("Enqueued %d stacks", len(stackIDs)),
 	}
 	if len(apiScans) == 1 {
 		resp.Scan = apiScans[0]
@@ -159,54 +178,40 @@ func (s *Server) handleGitHubWebhook(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
-func extractChangedFiles(payload gitHubPushPayload, maxFiles int) []string {
-	seen := map[string]struct{}{}
-	var files []string
-	for _, commit := range payload.Commits {
-		for _, path := range append(append(commit.Added, commit.Modified...), commit.Removed...) {
-			path = strings.TrimPrefix(path, "/")
-			if path == "" {
-				continue
-			}
-			if !isInfraFile(path) {
-				continue
-			}
-			if _, ok := seen[path]; ok {
-				continue
-			}
-			seen[path] = struct{}{}
-			files = append(files, filepath.ToSlash(path))
-			if maxFiles > 0 && len(files) >= maxFiles {
-				return files
-			}
-		}
+// webhookRangeFiles diffs the pushed commit range in the project's mirror.
+// When the range cannot be diffed, filesKnown is false and every stack is
+// scanned rather than trusting the incomplete payload.
+func (s *Server) webhookRangeFiles(r *http.Request, projectCfg *config.ProjectConfig, push *vcs.PushEvent) (files []string, filesKnown bool) {
+	diffed, err := s.orchestrator.ChangedFilesBetween(r.Context(), projectCfg, push.BeforeCommit, push.HeadCommit)
+	if err != nil {
+		log.Printf("webhook: project %s: diff %s..%s: %v; scanning all stacks", projectCfg.Name, push.BeforeCommit, push.HeadCommit, err)
+		return nil, false
 	}
-	return files
+	return extractChangedFiles(diffed, s.cfg.Webhook.MaxFiles), true
 }
 
-func selectStacksForChanges(stacks []string, changedFiles []string) []string {
-	if len(changedFiles) == 0 {
-		return nil
-	}
-	stackSet := map[string]struct{}{}
-	for _, stack := range stacks {
-		stackSet[stack] = struct{}{}
-	}
-	selected := map[string]struct{}{}
-	for _, file := range changedFiles {
-		for stack := range stackSet {
-			if stack != "" && !strings.HasPrefix(file, stack+"/") {
-				continue
-			}
-			selected[stack] = struct{}{}
+// extractChangedFiles keeps unique infrastructure files, up to maxFiles.
+func extractChangedFiles(paths []string, maxFiles int) []string {
+	seen := map[string]struct{}{}
+	var files []string
+	for _, path := range paths {
+		path = strings.TrimPrefix(path, "/")
+		if path == "" {
+			continue
+		}
+		if !isInfraFile(path) {
+			continue
+		}
+		if _, ok := seen[path]; ok {
+			continue
+		}
+		seen[path] = struct{}{}
+		files = append(files, filepath.ToSlash(path))
+		if maxFiles > 0 && len(files) >= maxFiles {
+			return files
 		}
 	}
-	var result []string
-	for stack := range selected {
-		result = append(result, stack)
-	}
-	sort.Strings(result)
-	return result
+	return files
 }
 
 func isInfraFile(path string) bool {
@@ -254,56 +259,111 @@ func projectPathMatchesWebhookChanges(projectCfg *config.ProjectConfig, changedF
 	return false
 }
 
-func (s *Server) validateWebhookRequest(w http.ResponseWriter, r *http.Request, body []byte) bool {
-	if s.cfg.Webhook.GitHubSecret != "" {
-		sig := r.Header.Get("X-Hub-Signature-256")
-		if sig == "" {
-			http.Error(w, "Missing signature", http.StatusUnauthorized)
-			return false
-		}
-		parts := strings.Split(sig, "=")
-		if len(parts) != 2 || parts[0] != "sha256" {
-			http.Error(w, "Invalid signature format", http.StatusUnauthorized)
-			return false
-		}
-		expected := computeHMACSHA256(body, []byte(s.cfg.Webhook.GitHubSecret))
-		provided, err := hex.DecodeString(parts[1])
-		if err != nil || !hmac.Equal(expected, provided) {
-			http.Error(w, "Invalid signature", http.StatusUnauthorized)
-			return false
+// readWebhookBody authenticates a webhook request and returns its body.
+// Bodies are capped at webhook.max_body_bytes and the global signature is
+// computed as the body streams in, so an oversized payload is rejected once
+// the cap is reached instead of being read into memory first. Pushes that
+// fail the global secret are checked against the secrets of the projects
+// their repository maps to.
+func (s *Server) readWebhookBody(w http.ResponseWriter, r *http.Request, provider vcs.Provider) ([]byte, webhookAuth, bool) {
+	if !isJSONContentType(r.Header.Get("Content-Type")) {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return nil, webhookAuth{}, false
+	}
+	limit := s.cfg.Webhook.MaxBodyBytes
+	if r.ContentLength > limit {
+		http.Error(w, "Payload too large", http.StatusRequestEntityTooLarge)
+		return nil, webhookAuth{}, false
+	}
+
+	var buf bytes.Buffer
+	body := &webhookBodyReader{r: io.TeeReader(http.MaxBytesReader(w, r.Body, limit), &buf)}
+	var globalErr error
+	if secret := s.webhookSecret(provider); secret != "" {
+		globalErr = provider.VerifySignature(r, body, secret)
+	} else if s.cfg.Webhook.Token == "" {
+		globalErr = errWebhookNotConfigured
+	} else {
+		token := r.Header.Get(s.cfg.Webhook.TokenHeader)
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Webhook.Token)) != 1 {
+			globalErr = errInvalidWebhookToken
 		}
-		if !s.recordWebhookDelivery(r, body) {
-			w.WriteHeader(http.StatusAccepted)
-			return false
+	}
+	if body.err == nil {
+		_, _ = io.Copy(io.Discard, body)
+	}
+	if body.err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(body.err, &tooLarge) {
+			http.Error(w, "Payload too large", http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, "Failed to read body", http.StatusBadRequest)
 		}
-		return true
+		return nil, webhookAuth{}, false
 	}
 
-	if s.cfg.Webhook.Token != "" {
-		token := r.Header.Get(s.cfg.Webhook.TokenHeader)
-		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Webhook.Token)) != 1 {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return false
-		}
-		if !s.recordWebhookDelivery(r, body) {
-			w.WriteHeader(http.StatusAccepted)
-			return false
+	auth := webhookAuth{
+		global:   globalErr == nil,
+		projects: s.verifyProjectWebhook(r, buf.Bytes(), provider),
+	}
+	if !auth.any() {
+		if len(auth.projects) > 0 {
+			globalErr = vcs.ErrInvalidSignature
 		}
-		return true
+		writeWebhookAuthError(w, globalErr)
+		return nil, webhookAuth{}, false
 	}
 
-	http.Error(w, "Webhook not configured", http.StatusUnauthorized)
-	return false
+	if !s.recordWebhookDelivery(r, buf.Bytes(), provider) {
+		w.WriteHeader(http.StatusAccepted)
+		return nil, webhookAuth{}, false
+	}
+	return buf.Bytes(), auth, true
 }
 
-func computeHMACSHA256(payload, key []byte) []byte {
-	h := hmac.New(sha256.New, key)
-	h.Write(payload)
-	return h.Sum(nil)
+// webhookBodyReader remembers the first read error so it can be told apart
+// from a signature failure.
+type webhookBodyReader struct {
+	r   io.Reader
+	err error
 }
 
-func (s *Server) recordWebhookDelivery(r *http.Request, body []byte) bool {
-	key := webhookReplayKey(r, body)
+func (b *webhookBodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF && b.err == nil {
+		b.err = err
+	}
+	return n, err
+}
+
+// isJSONContentType accepts application/json and +json media types. A
+// missing header is allowed; every supported provider sends JSON.
+func isJSONContentType(header string) bool {
+	if strings.TrimSpace(header) == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// webhookSecret returns the provider-native signing secret, if configured.
+func (s *Server) webhookSecret(provider vcs.Provider) string {
+	switch provider.Name() {
+	case "github":
+		return s.cfg.Webhook.GitHubSecret
+	case "gitlab":
+		return s.cfg.Webhook.GitLabToken
+	case "bitbucket":
+		return s.cfg.Webhook.BitbucketSecret
+	}
+	return ""
+}
+
+func (s *Server) recordWebhookDelivery(r *http.Request, body []byte, provider vcs.Provider) bool {
+	key := webhookReplayKey(r, body, provider)
 	now := time.Now().UTC()
 
 	s.webhookMu.Lock()
@@ -324,12 +384,9 @@ func (s *Server) recordWebhookDelivery(r *http.Request, body []byte) bool {
 	return true
 }
 
-func webhookReplayKey(r *http.Request, body []byte) string {
-	if delivery := strings.TrimSpace(r.Header.Get("X-GitHub-Delivery")); delivery != "" {
-		return "delivery:" + delivery
-	}
-	if sig := strings.TrimSpace(r.Header.Get("X-Hub-Signature-256")); sig != "" {
-		return "sig:" + sig
+func webhookReplayKey(r *http.Request, body []byte, provider vcs.Provider) string {
+	if delivery := provider.DeliveryID(r); delivery != "" {
+		return "delivery:" + provider.Name() + ":" + delivery
 	}
 	sum := sha256.Sum256(body)
 	return "body:" + hex.EncodeToString(sum[:])
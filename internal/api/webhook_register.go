@@ -0,0 +1,63 @@
+package api
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/vcs"
+)
+
+const webhookRegisterTimeout = 30 * time.Second
+
+// registerGitHubWebhook creates or updates the push webhook on the project's
+// repository when webhook.auto_register is on and the project authenticates
+// with a GitHub App. It returns "" when registration does not apply.
+func (s *Server) registerGitHubWebhook(ctx context.Context, projectName string) (string, error) {
+	if !s.cfg.Webhook.Enabled || !s.cfg.Webhook.AutoRegister {
+		return "", nil
+	}
+	projectCfg, err := s.getProjectConfig(projectName)
+	if err != nil {
+		return "", err
+	}
+	if projectCfg.Git == nil || projectCfg.Git.Type != "github_app" || projectCfg.Git.GitHubApp == nil {
+		return "", nil
+	}
+
+	secret := s.cfg.Webhook.GitHubSecret
+	if projectSecrets, ok := s.projectWebhookSecrets(projectName); ok && len(projectSecrets) > 0 {
+		secret = projectSecrets[0]
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookRegisterTimeout)
+	defer cancel()
+	token, err := vcs.ByName("github").AppToken(ctx, projectCfg.Git)
+	if err != nil {
+		return "", err
+	}
+	outcome, err := vcs.EnsureGitHubWebhook(ctx, projectCfg.Git.GitHubApp.APIBaseURL, token, projectCfg.EffectiveCloneURL(), vcs.GitHubWebhook{
+		TargetURL: s.cfg.Webhook.PublicURL + "/api/webhooks/github",
+		Secret:    secret,
+		Events:    s.githubWebhookEvents(),
+	})
+	if err != nil {
+		return "", err
+	}
+	log.Printf("webhook %s for project %s", outcome, projectName)
+	return outcome, nil
+}
+
+// withWebhookRegistration adds the registration outcome to a settings
+// response. Registration failures never fail the project write itself.
+func (s *Server) withWebhookRegistration(ctx context.Context, projectName string, resp map[string]string) map[string]string {
+	outcome, err := s.registerGitHubWebhook(ctx, projectName)
+	switch {
+	case err != nil:
+		log.Printf("webhook registration for project %s failed: %v", projectName, err)
+		resp["webhook_error"] = s.sanitizeErrorMessage(err.Error())
+	case outcome != "":
+		resp["webhook"] = outcome
+	}
+	return resp
+}
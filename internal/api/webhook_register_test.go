@@ -0,0 +1,109 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/secrets"
+)
+
+func TestSettingsCreateRegistersGitHubWebhook(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "app.pem")
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+
+	var mu sync.Mutex
+	var created []map[string]any
+	github := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/app/installations/4242/access_tokens":
+			json.NewEncoder(w).Encode(map[string]string{"token": "inst-token"})
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/infra/hooks":
+			json.NewEncoder(w).Encode([]any{})
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/acme/infra/hooks":
+			if r.Header.Get("Authorization") != "Bearer inst-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			var body map[string]any
+			json.NewDecoder(r.Body).Decode(&body)
+			created = append(created, body)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer github.Close()
+
+	_, ts, _, cleanup := newTestServerWithProjectStore(t, &fakeRunner{}, []string{"envs/dev"}, false, func(store *secrets.ProjectStore, intStore *secrets.IntegrationStore, projectDir string) {
+		if err := intStore.Add(&secrets.IntegrationEntry{
+			ID:   "gh-app",
+			Name: "GitHub App",
+			Type: "github_app",
+			GitHubApp: &secrets.IntegrationGitHubApp{
+				AppID:          4141,
+				InstallationID: 4242,
+				PrivateKeyPath: keyPath,
+				APIBaseURL:     github.URL,
+			},
+		}); err != nil {
+			t.Fatalf("add integration: %v", err)
+		}
+	}, func(cfg *config.Config) {
+		cfg.Webhook.Enabled = true
+		cfg.Webhook.GitHubSecret = "hook-secret"
+		cfg.Webhook.AutoRegister = true
+		cfg.Webhook.PublicURL = "https://driftd.example.com"
+	})
+	defer cleanup()
+
+	body, _ := json.Marshal(map[string]any{
+		"name":           "infra",
+		"url":            "https://github.com/acme/infra.git",
+		"integration_id": "gh-app",
+	})
+	resp, err := http.Post(ts.URL+"/api/settings/projects", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	var out map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out["webhook"] != "created" {
+		t.Fatalf("expected webhook created, got %v", out)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(created) != 1 {
+		t.Fatalf("expected one hook created, got %d", len(created))
+	}
+	hookCfg, _ := created[0]["config"].(map[string]any)
+	if hookCfg["url"] != "https://driftd.example.com/api/webhooks/github" || hookCfg["secret"] != "hook-secret" {
+		t.Fatalf("unexpected hook config: %v", hookCfg)
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/pathutil"
+)
+
+// resolveDirectivePattern matches "driftd:resolve <stack>" in a commit
+// message. The stack path is repository-relative, like webhook changed files.
+var resolveDirectivePattern = regexp.MustCompile(`\bdriftd:resolve\s+(\S+)`)
+
+// parseResolveDirectives returns the stack paths named by driftd:resolve
+// directives in messages, de-duplicated in order of appearance. Unsafe paths
+// are dropped.
+func parseResolveDirectives(messages []string) []string {
+	seen := map[string]struct{}{}
+	var stacks []string
+	for _, message := range messages {
+		for _, match := range resolveDirectivePattern.FindAllStringSubmatch(message, -1) {
+			stackPath := strings.Trim(strings.TrimRight(match[1], ".,;:"), "/")
+			if stackPath == "" || !pathutil.IsSafeStackPath(stackPath) {
+				continue
+			}
+			if _, ok := seen[stackPath]; ok {
+				continue
+			}
+			seen[stackPath] = struct{}{}
+			stacks = append(stacks, stackPath)
+		}
+	}
+	return stacks
+}
+
+// acknowledgeResolvedStacks acknowledges the drift on every resolved stack
+// that is about to be re-planned and currently reports drift. The
+// verification scan then clears the acknowledgement once the stack is clean,
+// or leaves it in place when drift remains. It returns the acknowledged
+// stacks.
+func (s *Server) acknowledgeResolvedStacks(projectCfg *config.ProjectConfig, resolved, targetStacks []string, actor string) []string {
+	if actor == "" {
+		actor = "git"
+	}
+	var acked []string
+	for _, stackPath := range resolved {
+		if !containsStack(stackPath, targetStacks) {
+			continue
+		}
+		result, err := s.storage.GetResult(projectCfg.Name, stackPath)
+		if err != nil || !result.Drifted {
+			continue
+		}
+		if err := s.storage.SetStackAcknowledged(projectCfg.Name, stackPath, true, actor); err != nil {
+			log.Printf("webhook: acknowledge %s/%s: %v", projectCfg.Name, stackPath, err)
+			continue
+		}
+		acked = append(acked, stackPath)
+	}
+	return acked
+}
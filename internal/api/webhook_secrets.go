@@ -0,0 +1,221 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/secrets"
+	"github.com/driftdhq/driftd/internal/vcs"
+	"github.com/go-chi/chi/v5"
+)
+
+// webhookAuth records which secrets verified a webhook request.
+type webhookAuth struct {
+	// global is set when the global provider secret or token matched.
+	global bool
+	// projects maps each candidate project that has its own webhook
+	// secrets to whether one of them matched.
+	projects map[string]bool
+}
+
+// allows reports whether the request may trigger project. Projects with
+// their own secrets only accept those; the rest accept the global secret.
+func (a webhookAuth) allows(project string) bool {
+	if verified, ok := a.projects[project]; ok {
+		return verified
+	}
+	return a.global
+}
+
+func (a webhookAuth) any() bool {
+	if a.global {
+		return true
+	}
+	for _, verified := range a.projects {
+		if verified {
+			return true
+		}
+	}
+	return false
+}
+
+// projectWebhookSecrets returns the secrets that replace the global webhook
+// secret for a project, current first. ok is false when the project has no
+// override. Config projects read theirs from webhook_secret_env; dynamic
+// projects keep theirs in the project store.
+func (s *Server) projectWebhookSecrets(name string) (webhookSecrets []string, ok bool) {
+	if project := s.cfg.GetProject(name); project != nil {
+		if project.WebhookSecretEnv == "" {
+			return nil, false
+		}
+		// A configured but unset variable still overrides, so a missing
+		// secret never falls back to the global one.
+		if secret := strings.TrimSpace(os.Getenv(project.WebhookSecretEnv)); secret != "" {
+			return []string{secret}, true
+		}
+		return nil, true
+	}
+	if s.projectStore == nil {
+		return nil, false
+	}
+	_, creds, err := s.projectStore.GetWithCredentials(name)
+	if err != nil || len(creds.WebhookSecrets) == 0 {
+		return nil, false
+	}
+	return creds.WebhookSecrets, true
+}
+
+// webhookCandidates returns the projects a push may trigger: those whose
+// repository URL matches the payload, or the project named like the
+// repository when none do.
+func (s *Server) webhookCandidates(push *vcs.PushEvent) ([]*config.ProjectConfig, error) {
+	candidates, err := s.getReposByURL(push.RepoURLs...)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 && isValidProjectName(push.RepoName) {
+		projectCfg, lookupErr := s.getProjectConfig(push.RepoName)
+		if lookupErr == nil && projectCfg != nil {
+			candidates = append(candidates, projectCfg)
+		} else if lookupErr != nil && lookupErr != secrets.ErrProjectNotFound {
+			return nil, lookupErr
+		}
+	}
+	return candidates, nil
+}
+
+// verifyProjectWebhook checks body against the webhook secrets of the
+// projects its repository maps to. Events other than pushes only verify
+// with the global secret.
+func (s *Server) verifyProjectWebhook(r *http.Request, body []byte, provider vcs.Provider) map[string]bool {
+	push, err := provider.ParsePush(r, body)
+	if err != nil {
+		return nil
+	}
+	candidates, err := s.webhookCandidates(push)
+	if err != nil {
+		return nil
+	}
+	var verified map[string]bool
+	for _, projectCfg := range candidates {
+		projectSecrets, ok := s.projectWebhookSecrets(projectCfg.Name)
+		if !ok {
+			continue
+		}
+		if verified == nil {
+			verified = make(map[string]bool)
+		}
+		verified[projectCfg.Name] = false
+		for _, secret := range projectSecrets {
+			if provider.VerifySignature(r, bytes.NewReader(body), secret) == nil {
+				verified[projectCfg.Name] = true
+				break
+			}
+		}
+	}
+	return verified
+}
+
+type webhookSecretRequest struct {
+	Secret string `json:"secret"`
+	// KeepPrevious keeps accepting the replaced secret until the next
+	// rotation, so deliveries signed with it still verify while the
+	// provider is updated.
+	KeepPrevious bool `json:"keep_previous"`
+}
+
+// handleSetProjectWebhookSecret sets or rotates a dynamic project's
+// webhook secret.
+func (s *Server) handleSetProjectWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	projectName, ok := s.dynamicWebhookSecretProject(w, r)
+	if !ok {
+		return
+	}
+	var req webhookSecretRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON"})
+		return
+	}
+	secret := strings.TrimSpace(req.Secret)
+	if secret == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "secret is required"})
+		return
+	}
+
+	webhookSecrets := []string{secret}
+	if req.KeepPrevious {
+		if current, ok := s.projectWebhookSecrets(projectName); ok && len(current) > 0 && current[0] != secret {
+			webhookSecrets = append(webhookSecrets, current[0])
+		}
+	}
+	if err := s.projectStore.SetWebhookSecrets(projectName, webhookSecrets); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	resp := map[string]string{"status": "updated"}
+	writeJSON(w, http.StatusOK, s.withWebhookRegistration(r.Context(), projectName, resp))
+}
+
+// handleDeleteProjectWebhookSecret removes a dynamic project's webhook
+// secrets; its webhooks verify with the global secret again.
+func (s *Server) handleDeleteProjectWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	projectName, ok := s.dynamicWebhookSecretProject(w, r)
+	if !ok {
+		return
+	}
+	if err := s.projectStore.SetWebhookSecrets(projectName, nil); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": s.sanitizeErrorMessage(err.Error())})
+		return
+	}
+	resp := map[string]string{"status": "deleted"}
+	writeJSON(w, http.StatusOK, s.withWebhookRegistration(r.Context(), projectName, resp))
+}
+
+func (s *Server) dynamicWebhookSecretProject(w http.ResponseWriter, r *http.Request) (string, bool) {
+	projectName := chi.URLParam(r, "project")
+	if !isValidProjectName(projectName) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid project name"})
+		return "", false
+	}
+	if s.cfg.GetProject(projectName) != nil {
+		writeJSON(w, http.StatusForbidden, map[string]string{
+			"error": "project is defined in static configuration; set webhook_secret_env there",
+		})
+		return "", false
+	}
+	if s.projectStore == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{
+			"error": "dynamic project management not enabled",
+		})
+		return "", false
+	}
+	if !s.projectStore.Exists(projectName) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "project not found"})
+		return "", false
+	}
+	return projectName, true
+}
+
+var (
+	errWebhookNotConfigured = errors.New("webhook not configured")
+	errInvalidWebhookToken  = errors.New("invalid token")
+)
+
+// writeWebhookAuthError answers a webhook request no secret verified.
+func writeWebhookAuthError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errWebhookNotConfigured):
+		http.Error(w, "Webhook not configured", http.StatusUnauthorized)
+	case errors.Is(err, errInvalidWebhookToken):
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+	case errors.Is(err, vcs.ErrInvalidSignature):
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+	default:
+		http.Error(w, "Missing signature", http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/secrets"
+)
+
+// postGitHubPush sends a push of envs/dev/main.tf to repoURL signed with
+// secret and returns the response status.
+func postGitHubPush(t *testing.T, ts *httptest.Server, repoURL, secret, delivery string) int {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{
+		"ref":        "refs/heads/main",
+		"repository": map[string]string{"name": "repo", "default_branch": "main", "clone_url": repoURL},
+		"commits":    []map[string]any{{"modified": []string{"envs/dev/main.tf"}}},
+	})
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/webhooks/github", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	req.Header.Set("X-GitHub-Event", "push")
+	req.Header.Set("X-GitHub-Delivery", delivery)
+	req.Header.Set("X-Hub-Signature-256", "sha256="+computeTestHMAC(body, secret))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestWebhookConfigProjectSecretReplacesGlobal(t *testing.T) {
+	t.Setenv("TEAM_HOOK_SECRET", "team-secret")
+	srv, ts, _, cleanup := newTestServerWithConfig(t, &fakeRunner{}, []string{"envs/dev"}, false, nil, true, func(cfg *config.Config) {
+		cfg.Webhook.Enabled = true
+		cfg.Webhook.GitHubSecret = "global-secret"
+		cfg.Projects[0].WebhookSecretEnv = "TEAM_HOOK_SECRET"
+	})
+	defer cleanup()
+	repoURL := srv.cfg.GetProject("project").URL
+
+	if status := postGitHubPush(t, ts, repoURL, "global-secret", "1"); status != http.StatusUnauthorized {
+		t.Fatalf("expected the global secret to be refused, got %d", status)
+	}
+	if status := postGitHubPush(t, ts, repoURL, "wrong", "2"); status != http.StatusUnauthorized {
+		t.Fatalf("expected a wrong secret to be refused, got %d", status)
+	}
+	if status := postGitHubPush(t, ts, repoURL, "team-secret", "3"); status != http.StatusOK {
+		t.Fatalf("expected the project secret to enqueue, got %d", status)
+	}
+}
+
+func TestProjectWebhookSecretRotation(t *testing.T) {
+	var repoURL string
+	srv, ts, _, cleanup := newTestServerWithProjectStore(t, &fakeRunner{}, []string{"envs/dev"}, false, func(store *secrets.ProjectStore, _ *secrets.IntegrationStore, projectDir string) {
+		repoURL = projectDir
+		if err := store.Add(&secrets.ProjectEntry{Name: "dyn-project", URL: projectDir}, nil); err != nil {
+			t.Fatalf("add project: %v", err)
+		}
+	}, func(cfg *config.Config) {
+		cfg.Projects = nil
+		cfg.Webhook.Enabled = true
+		cfg.Webhook.GitHubSecret = "global-secret"
+	})
+	defer cleanup()
+
+	setSecret := func(secret string, keepPrevious bool) {
+		t.Helper()
+		body, _ := json.Marshal(webhookSecretRequest{Secret: secret, KeepPrevious: keepPrevious})
+		req, _ := http.NewRequest(http.MethodPut, ts.URL+"/api/settings/projects/dyn-project/webhook-secret", bytes.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("put: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+	}
+
+	if status := postGitHubPush(t, ts, repoURL, "global-secret", "1"); status == http.StatusUnauthorized {
+		t.Fatal("expected the global secret to work without an override")
+	}
+	setSecret("first", false)
+	if presence := srv.projectCredentialPresence("dyn-project"); !presence.HasWebhookSecret {
+		t.Fatal("expected has_webhook_secret")
+	}
+	if status := postGitHubPush(t, ts, repoURL, "global-secret", "2"); status != http.StatusUnauthorized {
+		t.Fatalf("expected the global secret to be refused, got %d", status)
+	}
+
+	setSecret("second", true)
+	for i, secret := range []string{"first", "second"} {
+		if status := postGitHubPush(t, ts, repoURL, secret, "rotate-"+secret); status == http.StatusUnauthorized {
+			t.Fatalf("secret %d refused during rotation", i)
+		}
+	}
+	setSecret("third", false)
+	if status := postGitHubPush(t, ts, repoURL, "second", "3"); status != http.StatusUnauthorized {
+		t.Fatalf("expected the replaced secret to be refused, got %d", status)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/api/settings/projects/dyn-project/webhook-secret", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	resp.Body.Close()
+	if status := postGitHubPush(t, ts, repoURL, "global-secret", "4"); status == http.StatusUnauthorized {
+		t.Fatal("expected the global secret to work again after delete")
+	}
+}
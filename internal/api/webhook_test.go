@@ -4,11 +4,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/driftdhq/driftd/internal/config"
 	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/driftdhq/driftd/internal/vcs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 func TestWebhookIgnoresNonInfraFiles(t *testing.T) {
@@ -19,7 +28,7 @@ func TestWebhookIgnoresNonInfraFiles(t *testing.T) {
 	})
 	defer cleanup()
 
-	payload := gitHubPushPayload{
+	payload := vcs.GitHubPushPayload{
 		Ref: "refs/heads/main",
 		Repository: struct {
 			Name          string `json:"name"`
@@ -34,6 +43,7 @@ func TestWebhookIgnoresNonInfraFiles(t *testing.T) {
 			CloneURL:      srv.cfg.GetProject("project").URL,
 		},
 		Commits: []struct {
+			Message  string   `json:"message"`
 			Added    []string `json:"added"`
 			Modified []string `json:"modified"`
 			Removed  []string `json:"removed"`
@@ -70,7 +80,7 @@ func TestWebhookIgnoresUnmatchedInfraFiles(t *testing.T) {
 	})
 	defer cleanup()
 
-	payload := gitHubPushPayload{
+	payload := vcs.GitHubPushPayload{
 		Ref: "refs/heads/main",
 		Repository: struct {
 			Name          string `json:"name"`
@@ -85,6 +95,7 @@ func TestWebhookIgnoresUnmatchedInfraFiles(t *testing.T) {
 			CloneURL:      srv.cfg.GetProject("project").URL,
 		},
 		Commits: []struct {
+			Message  string   `json:"message"`
 			Added    []string `json:"added"`
 			Modified []string `json:"modified"`
 			Removed  []string `json:"removed"`
@@ -129,7 +140,7 @@ func TestWebhookMatchesByCloneURLWhenNameDiffers(t *testing.T) {
 	})
 	defer cleanup()
 
-	payload := gitHubPushPayload{
+	payload := vcs.GitHubPushPayload{
 		Ref: "refs/heads/main",
 		Repository: struct {
 			Name          string `json:"name"`
@@ -144,6 +155,7 @@ func TestWebhookMatchesByCloneURLWhenNameDiffers(t *testing.T) {
 			CloneURL:      srv.cfg.GetProject("configured-project").URL,
 		},
 		Commits: []struct {
+			Message  string   `json:"message"`
 			Added    []string `json:"added"`
 			Modified []string `json:"modified"`
 			Removed  []string `json:"removed"`
@@ -189,7 +201,7 @@ func TestWebhookUsesConfiguredBranchWhenSet(t *testing.T) {
 	})
 	defer cleanup()
 
-	payload := gitHubPushPayload{
+	payload := vcs.GitHubPushPayload{
 		Ref: "refs/heads/release",
 		Repository: struct {
 			Name          string `json:"name"`
@@ -204,6 +216,7 @@ func TestWebhookUsesConfiguredBranchWhenSet(t *testing.T) {
 			CloneURL:      srv.cfg.GetProject("project").URL,
 		},
 		Commits: []struct {
+			Message  string   `json:"message"`
 			Added    []string `json:"added"`
 			Modified []string `json:"modified"`
 			Removed  []string `json:"removed"`
@@ -258,7 +271,7 @@ func TestWebhookMonoprojectPrefiltersByRootPath(t *testing.T) {
 	})
 	defer cleanup()
 
-	payload := gitHubPushPayload{
+	payload := vcs.GitHubPushPayload{
 		Ref: "refs/heads/main",
 		Repository: struct {
 			Name          string `json:"name"`
@@ -273,6 +286,7 @@ func TestWebhookMonoprojectPrefiltersByRootPath(t *testing.T) {
 			CloneURL:      srv.cfg.GetProject("aws-dev").URL,
 		},
 		Commits: []struct {
+			Message  string   `json:"message"`
 			Added    []string `json:"added"`
 			Modified []string `json:"modified"`
 			Removed  []string `json:"removed"`
@@ -349,27 +363,6 @@ func TestGetReposByURLMatchesSSHAndHTMLForms(t *testing.T) {
 	}
 }
 
-func TestSelectStacksForChanges(t *testing.T) {
-	stacks := []string{"envs/prod", "envs/dev"}
-	changes := []string{"envs/prod/main.tf"}
-	selected := selectStacksForChanges(stacks, changes)
-	if len(selected) != 1 || selected[0] != "envs/prod" {
-		t.Fatalf("unexpected selection: %#v", selected)
-	}
-}
-
-func TestSelectStacksForChangesIncludesRoot(t *testing.T) {
-	stacks := []string{"", "envs/prod"}
-	changes := []string{"envs/prod/main.tf"}
-	selected := selectStacksForChanges(stacks, changes)
-	if len(selected) != 2 {
-		t.Fatalf("expected root + envs/prod, got %#v", selected)
-	}
-	if selected[0] != "" || selected[1] != "envs/prod" {
-		t.Fatalf("unexpected selection order/content: %#v", selected)
-	}
-}
-
 func TestIsInfraFile(t *testing.T) {
 	cases := map[string]bool{
 		"main.tf":            true,
@@ -399,7 +392,7 @@ func TestWebhookBranchMismatchReturnsAcceptedWithoutScan(t *testing.T) {
 	})
 	defer cleanup()
 
-	payload := gitHubPushPayload{
+	payload := vcs.GitHubPushPayload{
 		Ref: "refs/heads/main",
 		Repository: struct {
 			Name          string `json:"name"`
@@ -414,6 +407,7 @@ func TestWebhookBranchMismatchReturnsAcceptedWithoutScan(t *testing.T) {
 			CloneURL:      srv.cfg.GetProject("project").URL,
 		},
 		Commits: []struct {
+			Message  string   `json:"message"`
 			Added    []string `json:"added"`
 			Modified []string `json:"modified"`
 			Removed  []string `json:"removed"`
@@ -534,8 +528,9 @@ func TestProjectPathMatchesWebhookChanges(t *testing.T) {
 }
 
 func TestExtractChangedFilesDedupAndMaxFiles(t *testing.T) {
-	payload := gitHubPushPayload{
+	payload := vcs.GitHubPushPayload{
 		Commits: []struct {
+			Message  string   `json:"message"`
 			Added    []string `json:"added"`
 			Modified []string `json:"modified"`
 			Removed  []string `json:"removed"`
@@ -550,7 +545,7 @@ func TestExtractChangedFilesDedupAndMaxFiles(t *testing.T) {
 		},
 	}
 
-	got := extractChangedFiles(payload, 2)
+	got := extractChangedFiles(payload.ChangedFiles(), 2)
 	if len(got) != 2 {
 		t.Fatalf("expected 2 files due to max_files limit, got %d (%v)", len(got), got)
 	}
@@ -567,7 +562,7 @@ func TestWebhookDuplicateDeliveryIsIgnored(t *testing.T) {
 	})
 	defer cleanup()
 
-	payload := gitHubPushPayload{
+	payload := vcs.GitHubPushPayload{
 		Ref: "refs/heads/main",
 		Repository: struct {
 			Name          string `json:"name"`
@@ -582,6 +577,7 @@ func TestWebhookDuplicateDeliveryIsIgnored(t *testing.T) {
 			CloneURL:      srv.cfg.GetProject("project").URL,
 		},
 		Commits: []struct {
+			Message  string   `json:"message"`
 			Added    []string `json:"added"`
 			Modified []string `json:"modified"`
 			Removed  []string `json:"removed"`
@@ -624,3 +620,303 @@ func TestWebhookDuplicateDeliveryIsIgnored(t *testing.T) {
 		t.Fatalf("expected 202 from duplicate delivery, got %d", secondResp.StatusCode)
 	}
 }
+
+func TestWebhookGitLabPushEnqueuesChangedStacks(t *testing.T) {
+	runner := &fakeRunner{}
+	srv, ts, q, cleanup := newTestServerWithConfig(t, runner, []string{"envs/prod", "envs/dev"}, false, nil, true, func(cfg *config.Config) {
+		cfg.Webhook.Enabled = true
+		cfg.Webhook.GitLabToken = "gl-token"
+	})
+	defer cleanup()
+
+	body, _ := json.Marshal(map[string]any{
+		"object_kind":   "push",
+		"ref":           "refs/heads/main",
+		"checkout_sha":  "abc123",
+		"user_username": "alice",
+		"project": map[string]any{
+			"name":           "project",
+			"default_branch": "main",
+			"git_http_url":   srv.cfg.GetProject("project").URL,
+		},
+		"commits": []map[string]any{{"modified": []string{"envs/prod/main.tf"}}},
+	})
+	post := func(token string) *http.Response {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/webhooks/gitlab", bytes.NewBuffer(body))
+		req.Header.Set("X-Gitlab-Event", "Push Hook")
+		req.Header.Set("X-Gitlab-Token", token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		return resp
+	}
+
+	resp := post("wrong")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for bad token, got %d", resp.StatusCode)
+	}
+
+	resp = post("gl-token")
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var sr scanResp
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(sr.Stacks) != 1 {
+		t.Fatalf("expected only the changed stack enqueued, got %v", sr.Stacks)
+	}
+	scan, err := q.GetActiveScan(context.Background(), "project")
+	if err != nil {
+		t.Fatalf("expected active scan: %v", err)
+	}
+	if scan.Commit != "abc123" || scan.Actor != "alice" {
+		t.Fatalf("unexpected scan commit/actor: %q %q", scan.Commit, scan.Actor)
+	}
+}
+
+func TestWebhookBitbucketPushScansAllStacks(t *testing.T) {
+	runner := &fakeRunner{}
+	_, ts, _, cleanup := newTestServerWithConfig(t, runner, []string{"envs/prod", "envs/dev"}, false, nil, true, func(cfg *config.Config) {
+		cfg.Webhook.Enabled = true
+		cfg.Webhook.BitbucketSecret = "bb-secret"
+	})
+	defer cleanup()
+
+	body, _ := json.Marshal(map[string]any{
+		"actor":      map[string]any{"nickname": "bob"},
+		"repository": map[string]any{"name": "project", "mainbranch": map[string]any{"name": "main"}},
+		"push": map[string]any{"changes": []map[string]any{
+			{"new": map[string]any{"type": "branch", "name": "main", "target": map[string]any{"hash": "def456"}}},
+		}},
+	})
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/webhooks/bitbucket", bytes.NewBuffer(body))
+	req.Header.Set("X-Event-Key", "repo:push")
+	req.Header.Set("X-Hub-Signature", "sha256="+computeTestHMAC(body, "bb-secret"))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var sr scanResp
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(sr.Stacks) != 2 {
+		t.Fatalf("expected all stacks enqueued without a file list, got %v", sr.Stacks)
+	}
+}
+
+func TestWebhookResolveDirectiveAcknowledgesAndRescansStack(t *testing.T) {
+	runner := &fakeRunner{}
+	srv, ts, q, cleanup := newTestServerWithConfig(t, runner, []string{"envs/prod", "envs/dev"}, false, nil, true, func(cfg *config.Config) {
+		cfg.Webhook.Enabled = true
+		cfg.Webhook.GitLabToken = "gl-token"
+	})
+	defer cleanup()
+
+	if err := srv.storage.SaveResult("project", "envs/prod", &storage.RunResult{RunAt: time.Now(), Drifted: true}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+
+	body, _ := json.Marshal(map[string]any{
+		"object_kind":   "push",
+		"ref":           "refs/heads/main",
+		"checkout_sha":  "abc123",
+		"user_username": "alice",
+		"project": map[string]any{
+			"name":           "project",
+			"default_branch": "main",
+			"git_http_url":   srv.cfg.GetProject("project").URL,
+		},
+		"commits": []map[string]any{{
+			"message":  "Import manual SG change\n\ndriftd:resolve envs/prod",
+			"modified": []string{"README.md"},
+		}},
+	})
+	req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/webhooks/gitlab", bytes.NewBuffer(body))
+	req.Header.Set("X-Gitlab-Event", "Push Hook")
+	req.Header.Set("X-Gitlab-Token", "gl-token")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var sr scanResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(sr.Stacks) != 1 || len(sr.Acknowledged) != 1 || sr.Acknowledged[0] != "envs/prod" {
+		t.Fatalf("expected envs/prod acknowledged and rescanned, got stacks=%v acknowledged=%v", sr.Stacks, sr.Acknowledged)
+	}
+	stackScan, err := q.GetStackScan(context.Background(), sr.Stacks[0])
+	if err != nil {
+		t.Fatalf("get stack scan: %v", err)
+	}
+	if stackScan.StackPath != "envs/prod" {
+		t.Fatalf("expected envs/prod verification scan, got %q", stackScan.StackPath)
+	}
+	statuses, err := srv.storage.ListStacks("project")
+	if err != nil {
+		t.Fatalf("list stacks: %v", err)
+	}
+	if len(statuses) != 1 || !statuses[0].Acknowledged {
+		t.Fatalf("expected envs/prod acknowledged, got %+v", statuses)
+	}
+}
+
+func TestParseResolveDirectives(t *testing.T) {
+	got := parseResolveDirectives([]string{
+		"fix drift\n\ndriftd:resolve envs/prod/app-001\ndriftd:resolve envs/dev/,",
+		"driftd:resolve envs/prod/app-001 driftd:resolve ../etc",
+		"no directive here",
+	})
+	want := []string{"envs/prod/app-001", "envs/dev"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestWebhookRejectsOversizedAndNonJSONBodies(t *testing.T) {
+	runner := &fakeRunner{}
+	_, ts, q, cleanup := newTestServerWithConfig(t, runner, []string{"envs/prod"}, false, nil, true, func(cfg *config.Config) {
+		cfg.Webhook.Enabled = true
+		cfg.Webhook.GitHubSecret = "secret"
+		cfg.Webhook.MaxBodyBytes = 64
+	})
+	defer cleanup()
+
+	post := func(body []byte, contentType string, chunked bool) int {
+		t.Helper()
+		var reader io.Reader = bytes.NewReader(body)
+		if chunked {
+			// Hide the length so the limit is enforced while reading.
+			reader = io.MultiReader(reader)
+		}
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/webhooks/github", reader)
+		if err != nil {
+			t.Fatalf("new request: %v", err)
+		}
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		req.Header.Set("X-GitHub-Event", "push")
+		req.Header.Set("X-Hub-Signature-256", "sha256="+computeTestHMAC(body, "secret"))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	large := []byte(`{"ref":"refs/heads/main","padding":"` + strings.Repeat("x", 128) + `"}`)
+	if code := post(large, "application/json", false); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for declared oversized body, got %d", code)
+	}
+	if code := post(large, "application/json", true); code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for streamed oversized body, got %d", code)
+	}
+	if code := post([]byte(`payload=%7B%7D`), "application/x-www-form-urlencoded", false); code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for form body, got %d", code)
+	}
+	if code := post([]byte(`{"ref":"refs/tags/v1"}`), "application/json; charset=utf-8", false); code != http.StatusAccepted {
+		t.Fatalf("expected 202 for small ignored push, got %d", code)
+	}
+	if _, err := q.GetActiveScan(context.Background(), "project"); err != queue.ErrScanNotFound {
+		t.Fatalf("expected no active scan")
+	}
+}
+
+func TestWebhookTruncatedPushDiffsCommitRange(t *testing.T) {
+	runner := &fakeRunner{}
+	srv, ts, _, cleanup := newTestServerWithConfig(t, runner, []string{"envs/prod", "envs/dev"}, false, nil, true, func(cfg *config.Config) {
+		cfg.Webhook.Enabled = true
+		cfg.Webhook.GitLabToken = "gl-token"
+	})
+	defer cleanup()
+
+	projectDir := srv.cfg.GetProject("project").URL
+	project, err := git.PlainOpen(projectDir)
+	if err != nil {
+		t.Fatalf("open project: %v", err)
+	}
+	head, err := project.Head()
+	if err != nil {
+		t.Fatalf("head: %v", err)
+	}
+	before := head.Hash().String()
+	if err := os.WriteFile(filepath.Join(projectDir, "envs/prod/main.tf"), []byte("# changed\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	wt, _ := project.Worktree()
+	if _, err := wt.Add("envs/prod/main.tf"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	after, err := wt.Commit("change prod", &git.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}})
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	push := func(before string) scanResp {
+		t.Helper()
+		// GitLab lists at most 20 commits; the listed ones touch no
+		// infrastructure files.
+		body, _ := json.Marshal(map[string]any{
+			"object_kind":         "push",
+			"ref":                 "refs/heads/main",
+			"before":              before,
+			"checkout_sha":        after.String(),
+			"total_commits_count": 25,
+			"project": map[string]any{
+				"name":           "project",
+				"default_branch": "main",
+				"git_http_url":   projectDir,
+			},
+			"commits": []map[string]any{{"modified": []string{"README.md"}}},
+		})
+		req, _ := http.NewRequest(http.MethodPost, ts.URL+"/api/webhooks/gitlab", bytes.NewBuffer(body))
+		req.Header.Set("X-Gitlab-Event", "Push Hook")
+		req.Header.Set("X-Gitlab-Token", "gl-token")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.StatusCode)
+		}
+		var sr scanResp
+		if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if sr.Scan != nil {
+			_ = srv.queue.CancelScan(context.Background(), sr.Scan.ID, "project", "test")
+			srv.queue.ClearInflightForScan(context.Background(), sr.Scan.ID)
+		}
+		return sr
+	}
+
+	if sr := push(before); len(sr.Stacks) != 1 || !strings.Contains(sr.Stacks[0], "envs/prod") {
+		t.Fatalf("expected only envs/prod from the diffed range, got %v", sr.Stacks)
+	}
+	// A before commit missing from the mirror falls back to every stack.
+	if sr := push(strings.Repeat("1", 40)); len(sr.Stacks) != 2 {
+		t.Fatalf("expected all stacks when the range cannot be diffed, got %v", sr.Stacks)
+	}
+}
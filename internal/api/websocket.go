@@ -0,0 +1,206 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/queue"
+	"golang.org/x/net/websocket"
+)
+
+// maxWebSocketSubscriptions caps the projects and scans one connection can
+// follow at once.
+const maxWebSocketSubscriptions = 100
+
+// wsClientMessage is a subscription request sent by a WebSocket client. A
+// subscription naming neither a project nor a scan follows every project,
+// like /api/events.
+type wsClientMessage struct {
+	Action  string `json:"action"` // "subscribe" or "unsubscribe"
+	Project string `json:"project,omitempty"`
+	ScanID  string `json:"scan_id,omitempty"`
+}
+
+// wsServerMessage is one frame sent to a WebSocket client. Event matches the
+// SSE event names ("snapshot", "update") plus "subscribed", "unsubscribed"
+// and "error"; Data holds the same payload the SSE streams send.
+type wsServerMessage struct {
+	Event   string          `json:"event"`
+	Project string          `json:"project,omitempty"`
+	ScanID  string          `json:"scan_id,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// wsSubscriptions tracks what one connection follows.
+type wsSubscriptions struct {
+	all      bool
+	projects map[string]struct{}
+	scans    map[string]struct{}
+}
+
+func (subs *wsSubscriptions) matches(event *queue.ProjectEvent) bool {
+	if subs.all {
+		return true
+	}
+	if _, ok := subs.projects[event.ProjectName]; ok {
+		return true
+	}
+	_, ok := subs.scans[event.ScanID]
+	return ok
+}
+
+func (subs *wsSubscriptions) size() int {
+	return len(subs.projects) + len(subs.scans)
+}
+
+// handleWebSocket serves the scan and stack event stream over a WebSocket.
+// Clients choose what to follow with subscription messages.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	server := websocket.Server{Handshake: s.checkWebSocketOrigin, Handler: s.serveWebSocket}
+	server.ServeHTTP(w, r)
+}
+
+// checkWebSocketOrigin rejects browser connections from other sites, since
+// the endpoint is authenticated by the UI session cookie. Clients that send
+// no Origin are not browsers and are allowed.
+func (s *Server) checkWebSocketOrigin(_ *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin %q", origin)
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+	for _, allowed := range s.cfg.API.WebSocketOrigins {
+		if strings.EqualFold(strings.TrimRight(allowed, "/"), origin) {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q not allowed", origin)
+}
+
+func (s *Server) serveWebSocket(ws *websocket.Conn) {
+	defer ws.Close()
+	// The connection is hijacked, so the request context no longer ends
+	// when the client goes away; the reader below cancels instead.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ws.Request().Context()))
+	defer cancel()
+
+	events, err := s.queue.SubscribeProjectEvents(ctx, "")
+	if err != nil {
+		_ = websocket.JSON.Send(ws, wsServerMessage{Event: "error", Error: "failed to subscribe to events"})
+		return
+	}
+
+	requests := make(chan wsClientMessage)
+	go func() {
+		defer cancel()
+		for {
+			var msg wsClientMessage
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				return
+			}
+			select {
+			case requests <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	subs := &wsSubscriptions{projects: map[string]struct{}{}, scans: map[string]struct{}{}}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-requests:
+			for _, reply := range s.handleWebSocketMessage(ctx, subs, msg) {
+				if err := websocket.JSON.Send(ws, reply); err != nil {
+					return
+				}
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if !subs.matches(&event) {
+				continue
+			}
+			payload, err := buildUpdatePayload(&event)
+			if err != nil {
+				continue
+			}
+			if err := websocket.JSON.Send(ws, wsServerMessage{Event: "update", Project: event.ProjectName, ScanID: event.ScanID, Data: payload}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleWebSocketMessage applies a subscription request and returns the
+// frames to send back. Subscribing to a project also sends its snapshot, as
+// the per-project SSE stream does on connect.
+func (s *Server) handleWebSocketMessage(ctx context.Context, subs *wsSubscriptions, msg wsClientMessage) []wsServerMessage {
+	reply := func(event string) wsServerMessage {
+		return wsServerMessage{Event: event, Project: msg.Project, ScanID: msg.ScanID}
+	}
+	fail := func(errMsg string) []wsServerMessage {
+		out := reply("error")
+		out.Error = errMsg
+		return []wsServerMessage{out}
+	}
+	if msg.Project != "" && !isValidProjectName(msg.Project) {
+		return fail("invalid project name")
+	}
+
+	switch msg.Action {
+	case "subscribe":
+		switch {
+		case msg.Project == "" && msg.ScanID == "":
+			subs.all = true
+		case subs.size() >= maxWebSocketSubscriptions:
+			return fail(fmt.Sprintf("at most %d subscriptions per connection", maxWebSocketSubscriptions))
+		case msg.Project != "":
+			subs.projects[msg.Project] = struct{}{}
+		default:
+			subs.scans[msg.ScanID] = struct{}{}
+		}
+		out := []wsServerMessage{reply("subscribed")}
+		if msg.Project != "" {
+			activeScan, _ := s.queue.GetActiveScan(ctx, msg.Project)
+			lastScan, _ := s.queue.GetLastScan(ctx, msg.Project)
+			stacks, _ := s.storage.ListStacks(msg.Project)
+			payload, err := buildSnapshotPayload(msg.Project, activeScan, lastScan, stacks)
+			if err != nil {
+				log.Printf("websocket: snapshot for %s: %v", msg.Project, err)
+			} else {
+				snapshot := reply("snapshot")
+				snapshot.Data = payload
+				out = append(out, snapshot)
+			}
+		}
+		return out
+	case "unsubscribe":
+		switch {
+		case msg.Project == "" && msg.ScanID == "":
+			subs.all = false
+		case msg.Project != "":
+			delete(subs.projects, msg.Project)
+		default:
+			delete(subs.scans, msg.ScanID)
+		}
+		return []wsServerMessage{reply("unsubscribed")}
+	default:
+		return fail(fmt.Sprintf("unknown action %q", msg.Action))
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+	"golang.org/x/net/websocket"
+)
+
+func receiveWS(t *testing.T, ws *websocket.Conn) wsServerMessage {
+	t.Helper()
+	if err := ws.SetReadDeadline(time.Now().Add(3 * time.Second)); err != nil {
+		t.Fatalf("set deadline: %v", err)
+	}
+	var msg wsServerMessage
+	if err := websocket.JSON.Receive(ws, &msg); err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	return msg
+}
+
+func TestWebSocketProjectSubscription(t *testing.T) {
+	ts, q, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/dev"}, false, nil, true)
+	defer cleanup()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+	ws, err := websocket.Dial(wsURL, "", ts.URL)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer ws.Close()
+
+	if err := websocket.JSON.Send(ws, wsClientMessage{Action: "subscribe", Project: "project"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if msg := receiveWS(t, ws); msg.Event != "subscribed" || msg.Project != "project" {
+		t.Fatalf("expected subscribed, got %+v", msg)
+	}
+	if msg := receiveWS(t, ws); msg.Event != "snapshot" || !strings.Contains(string(msg.Data), `"kind":"snapshot"`) {
+		t.Fatalf("expected snapshot, got %+v", msg)
+	}
+
+	now := time.Now()
+	publish := func(project, scanID string) {
+		t.Helper()
+		if err := q.PublishScanEvent(context.Background(), project, queue.ScanEvent{
+			ProjectName: project,
+			ScanID:      scanID,
+			Status:      queue.ScanStatusRunning,
+			StartedAt:   &now,
+			Total:       1,
+		}); err != nil {
+			t.Fatalf("publish scan event: %v", err)
+		}
+	}
+	// Events for projects the client did not subscribe to are not sent.
+	publish("other", "scan-0")
+	publish("project", "scan-1")
+	msg := receiveWS(t, ws)
+	if msg.Event != "update" || msg.ScanID != "scan-1" || !strings.Contains(string(msg.Data), `"kind":"scan"`) {
+		t.Fatalf("expected scan update for scan-1, got %+v", msg)
+	}
+
+	if err := websocket.JSON.Send(ws, wsClientMessage{Action: "bogus"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if msg := receiveWS(t, ws); msg.Event != "error" {
+		t.Fatalf("expected error for unknown action, got %+v", msg)
+	}
+}
+
+func TestWebSocketRejectsCrossOrigin(t *testing.T) {
+	ts, _, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/dev"}, false, nil, true)
+	defer cleanup()
+
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/api/ws"
+	if ws, err := websocket.Dial(wsURL, "", "https://evil.example"); err == nil {
+		ws.Close()
+		t.Fatalf("expected cross-origin dial to fail")
+	}
+}
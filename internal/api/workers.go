@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/go-chi/chi/v5"
+)
+
+type apiWorker struct {
+	ID          string `json:"id"`
+	Hostname    string `json:"hostname"`
+	Concurrency int    `json:"concurrency"`
+	Active      int    `json:"active"`
+	SlotsUsed   int    `json:"slots_used"`
+	Draining    bool   `json:"draining"`
+	StartedAt   int64  `json:"started_at"`
+	LastSeen    int64  `json:"last_seen"`
+	JobVersions []int  `json:"job_versions,omitempty"`
+}
+
+type workerCommandRequest struct {
+	Concurrency int `json:"concurrency"`
+}
+
+type workerCommandResponse struct {
+	Action      string `json:"action"`
+	Target      string `json:"target"`
+	Concurrency int    `json:"concurrency,omitempty"`
+	Receivers   int64  `json:"receivers"`
+}
+
+func (s *Server) handleListWorkers(w http.ResponseWriter, r *http.Request) {
+	workers, err := s.queue.ListWorkers(r.Context())
+	if err != nil {
+		http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	out := make([]apiWorker, 0, len(workers))
+	for _, info := range workers {
+		out = append(out, apiWorker{
+			ID:          info.ID,
+			Hostname:    info.Hostname,
+			Concurrency: info.Concurrency,
+			Active:      info.Active,
+			SlotsUsed:   info.SlotsUsed,
+			Draining:    info.Draining,
+			StartedAt:   info.StartedAt.Unix(),
+			LastSeen:    info.LastSeen.Unix(),
+			JobVersions: info.JobVersions,
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleWorkerCommand publishes drain/resume/concurrency commands. The target
+// may be a worker ID, a hostname, or "all".
+func (s *Server) handleWorkerCommand(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cmd := queue.WorkerCommand{
+			Action: action,
+			Target: strings.TrimSpace(chi.URLParam(r, "worker")),
+		}
+		if action == queue.WorkerActionSetConcurrency {
+			var req workerCommandRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid JSON", http.StatusBadRequest)
+				return
+			}
+			cmd.Concurrency = req.Concurrency
+		}
+		if err := cmd.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		workers, err := s.queue.ListWorkers(r.Context())
+		if err != nil {
+			http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+			return
+		}
+		found := false
+		for _, info := range workers {
+			if cmd.Matches(info) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			http.Error(w, "Worker not found", http.StatusNotFound)
+			return
+		}
+
+		receivers, err := s.queue.PublishWorkerCommand(r.Context(), cmd)
+		if err != nil {
+			http.Error(w, s.sanitizeErrorMessage(err.Error()), http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, workerCommandResponse{
+			Action:      cmd.Action,
+			Target:      cmd.Target,
+			Concurrency: cmd.Concurrency,
+			Receivers:   receivers,
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+func TestWorkerAdminAPI(t *testing.T) {
+	ts, q, cleanup := newTestServer(t, &fakeRunner{}, []string{"envs/prod"}, false, nil, true)
+	defer cleanup()
+
+	if err := q.HeartbeatWorker(context.Background(), queue.WorkerInfo{ID: "host-1", Hostname: "host", Concurrency: 2}); err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+
+	resp, err := http.Get(ts.URL + "/api/workers")
+	if err != nil {
+		t.Fatalf("list workers: %v", err)
+	}
+	var workers []apiWorker
+	if err := json.NewDecoder(resp.Body).Decode(&workers); err != nil {
+		t.Fatalf("decode workers: %v", err)
+	}
+	resp.Body.Close()
+	if len(workers) != 1 || workers[0].ID != "host-1" || workers[0].Concurrency != 2 {
+		t.Fatalf("unexpected workers: %+v", workers)
+	}
+
+	resp, err = http.Post(ts.URL+"/api/workers/host/drain", "application/json", nil)
+	if err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected 202 for drain, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(ts.URL+"/api/workers/missing/drain", "application/json", nil)
+	if err != nil {
+		t.Fatalf("drain missing: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown worker, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(ts.URL+"/api/workers/all/concurrency", "application/json", bytes.NewBufferString(`{"concurrency":0}`))
+	if err != nil {
+		t.Fatalf("set concurrency: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid concurrency, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,246 @@
+// Package canary scans a generated fixture project on an interval to prove
+// the drift pipeline works end to end.
+//
+// A canary scan goes through the orchestrator, the queue and a worker like
+// any other scan. Only the plan itself is faked: workers hand canary stacks to
+// Runner instead of terraform, so the canary needs no cloud credentials and
+// never writes stack results.
+package canary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/maintenance"
+	"github.com/driftdhq/driftd/internal/metrics"
+	"github.com/driftdhq/driftd/internal/orchestrate"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/runner"
+	"github.com/driftdhq/driftd/internal/scanlimit"
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/driftdhq/driftd/internal/worker"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// StackPath is the only stack in the fixture repository.
+const StackPath = "canary"
+
+// Trigger marks canary scans in scan history and events.
+const Trigger = "canary"
+
+// Results recorded in the driftd_canary_runs_total metric.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	ResultTimeout = "timeout"
+	ResultSkipped = "skipped"
+)
+
+const pollEvery = time.Second
+
+// RepoDir is where the fixture repository is generated under dataDir.
+func RepoDir(dataDir string) string {
+	return filepath.Join(dataDir, "canary", "repo")
+}
+
+// Project returns the canary project config for the fixture repository.
+func Project(dataDir string) (*config.ProjectConfig, error) {
+	dir, err := filepath.Abs(RepoDir(dataDir))
+	if err != nil {
+		return nil, err
+	}
+	url := "file://" + filepath.ToSlash(dir)
+	return &config.ProjectConfig{
+		Name:     config.CanaryProjectName,
+		URL:      url,
+		CloneURL: url,
+	}, nil
+}
+
+// EnsureRepo creates the fixture repository in dir unless it already exists.
+func EnsureRepo(dir string) error {
+	if _, err := git.PlainOpen(dir); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Join(dir, StackPath), 0750); err != nil {
+		return fmt.Errorf("failed to create canary repository: %w", err)
+	}
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		return fmt.Errorf("failed to init canary repository: %w", err)
+	}
+	stack := "# Scanned by the driftd canary; never planned for real.\nresource \"null_resource\" \"canary\" {}\n"
+	if err := os.WriteFile(filepath.Join(dir, StackPath, "main.tf"), []byte(stack), 0600); err != nil {
+		return fmt.Errorf("failed to write canary stack: %w", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if _, err := wt.Add(StackPath); err != nil {
+		return err
+	}
+	_, err = wt.Commit("driftd canary fixture", &git.CommitOptions{
+		Author: &object.Signature{Name: "driftd", Email: "canary@driftd.local", When: time.Now()},
+	})
+	return err
+}
+
+// Runner fakes plans for the canary project and passes every other stack to
+// the wrapped runner.
+type Runner struct {
+	next worker.Runner
+}
+
+// WrapRunner returns a runner that serves canary stacks itself.
+func WrapRunner(next worker.Runner) *Runner {
+	return &Runner{next: next}
+}
+
+func (r *Runner) Run(ctx context.Context, params *runner.RunParams) (*storage.RunResult, error) {
+	if params.ProjectName != config.CanaryProjectName {
+		return r.next.Run(ctx, params)
+	}
+	// The workspace check proves the clone reached this worker.
+	if params.WorkspacePath != "" {
+		if _, err := os.Stat(filepath.Join(params.WorkspacePath, params.StackPath, "main.tf")); err != nil {
+			return &storage.RunResult{RunAt: time.Now(), Error: "canary stack missing from workspace"}, nil
+		}
+	}
+	return &storage.RunResult{
+		RunAt:      time.Now(),
+		PlanOutput: "No changes. Your infrastructure matches the configuration.",
+	}, nil
+}
+
+// Prober runs canary scans on the configured interval.
+type Prober struct {
+	cfg          config.CanaryConfig
+	queue        queue.Backend
+	orchestrator *orchestrate.ScanOrchestrator
+	maintenance  *maintenance.Mode
+	project      *config.ProjectConfig
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New generates the fixture repository under cfg.DataDir and returns a
+// prober for it. m may be nil.
+func New(cfg *config.Config, q queue.Backend, orch *orchestrate.ScanOrchestrator, m *maintenance.Mode) (*Prober, error) {
+	if err := EnsureRepo(RepoDir(cfg.DataDir)); err != nil {
+		return nil, err
+	}
+	project, err := Project(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Prober{
+		cfg:          cfg.Canary,
+		queue:        q,
+		orchestrator: orch,
+		maintenance:  m,
+		project:      project,
+		stop:         make(chan struct{}),
+	}, nil
+}
+
+// Start runs a canary scan immediately and then every interval.
+func (p *Prober) Start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			p.probe()
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop abandons the running canary scan, if any, and waits for the prober to
+// exit.
+func (p *Prober) Stop() {
+	close(p.stop)
+	p.wg.Wait()
+}
+
+func (p *Prober) probe() {
+	ctx, cancel := context.WithTimeout(context.Background(), p.cfg.Timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-p.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	start := time.Now()
+	result, err := p.RunOnce(ctx)
+	metrics.ObserveCanary(result, time.Since(start))
+	if err != nil {
+		log.Printf("canary: %s: %v", result, err)
+	}
+}
+
+// RunOnce starts a canary scan and waits for it to finish or for ctx to end.
+// It returns one of the Result constants.
+func (p *Prober) RunOnce(ctx context.Context) (string, error) {
+	if p.maintenance.Active() {
+		return ResultSkipped, nil
+	}
+	scan, _, err := p.orchestrator.StartAndEnqueue(ctx, p.project, Trigger, "", "")
+	if err != nil {
+		if err == queue.ErrProjectLocked {
+			// Another server's canary is running.
+			return ResultSkipped, nil
+		}
+		var limitErr *scanlimit.Error
+		if errors.As(err, &limitErr) {
+			return ResultSkipped, nil
+		}
+		return ResultFailure, fmt.Errorf("start scan: %w", err)
+	}
+
+	ticker := time.NewTicker(pollEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// The scan may be stuck in the queue; release the project lock so
+			// the next canary can start.
+			_ = p.queue.CancelScan(context.Background(), scan.ID, p.project.Name, "canary timed out")
+			return ResultTimeout, fmt.Errorf("scan %s did not finish in time", scan.ID)
+		case <-ticker.C:
+		}
+		current, err := p.queue.GetScan(ctx, scan.ID)
+		if err != nil {
+			continue
+		}
+		switch current.Status {
+		case queue.ScanStatusRunning:
+			continue
+		case queue.ScanStatusCompleted:
+			if current.Failed > 0 || current.Errored > 0 {
+				return ResultFailure, fmt.Errorf("scan %s completed with %d failed and %d errored stacks", scan.ID, current.Failed, current.Errored)
+			}
+			return ResultSuccess, nil
+		default:
+			return ResultFailure, fmt.Errorf("scan %s %s: %s", scan.ID, current.Status, current.Error)
+		}
+	}
+}
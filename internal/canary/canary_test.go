@@ -0,0 +1,126 @@
+package canary
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/maintenance"
+	"github.com/driftdhq/driftd/internal/orchestrate"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/runner"
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/driftdhq/driftd/internal/worker"
+)
+
+type recordingRunner struct {
+	calls []string
+}
+
+func (r *recordingRunner) Run(ctx context.Context, params *runner.RunParams) (*storage.RunResult, error) {
+	r.calls = append(r.calls, params.ProjectName)
+	return nil, errors.New("real runner called")
+}
+
+func newTestProber(t *testing.T, startWorker bool) (*Prober, *recordingRunner, *maintenance.Mode) {
+	t.Helper()
+	cfg := &config.Config{
+		DataDir: t.TempDir(),
+		Worker: config.WorkerConfig{
+			Concurrency: 1,
+			LockTTL:     2 * time.Minute,
+			ScanMaxAge:  time.Minute,
+			RenewEvery:  10 * time.Second,
+		},
+		Canary: config.CanaryConfig{Enabled: true, Interval: time.Minute, Timeout: 30 * time.Second},
+	}
+	q := queue.NewMemory(cfg.Worker.LockTTL)
+	orch := orchestrate.New(cfg, q)
+	next := &recordingRunner{}
+	if startWorker {
+		w := worker.New(q, WrapRunner(next), 1, cfg, nil)
+		w.Start()
+		t.Cleanup(w.Stop)
+	}
+	t.Cleanup(func() {
+		orch.Stop()
+		_ = q.Close()
+	})
+
+	mode := maintenance.New(cfg.DataDir)
+	p, err := New(cfg, q, orch, mode)
+	if err != nil {
+		t.Fatalf("new prober: %v", err)
+	}
+	return p, next, mode
+}
+
+func TestProberRunOnceSucceeds(t *testing.T) {
+	p, next, _ := newTestProber(t, true)
+
+	result, err := p.RunOnce(context.Background())
+	if err != nil || result != ResultSuccess {
+		t.Fatalf("expected success, got %s: %v", result, err)
+	}
+	if len(next.calls) != 0 {
+		t.Fatalf("canary stack reached the real runner: %v", next.calls)
+	}
+}
+
+func TestEnsureRepoIsIdempotent(t *testing.T) {
+	dir := RepoDir(t.TempDir())
+	for i := 0; i < 2; i++ {
+		if err := EnsureRepo(dir); err != nil {
+			t.Fatalf("ensure repo (attempt %d): %v", i+1, err)
+		}
+	}
+}
+
+func TestProberTimesOutWithoutWorkers(t *testing.T) {
+	p, _, _ := newTestProber(t, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	result, err := p.RunOnce(ctx)
+	if result != ResultTimeout || err == nil {
+		t.Fatalf("expected timeout, got %s: %v", result, err)
+	}
+
+	// The timed-out scan released the lock, so the next canary can start.
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if result, _ := p.RunOnce(ctx); result == ResultSkipped {
+		t.Fatalf("expected the project lock to be released after a timeout")
+	}
+}
+
+func TestProberSkipsDuringMaintenance(t *testing.T) {
+	p, _, mode := newTestProber(t, false)
+	if _, err := mode.Enable("redis upgrade", "ops", nil); err != nil {
+		t.Fatalf("enable maintenance: %v", err)
+	}
+	if result, err := p.RunOnce(context.Background()); result != ResultSkipped || err != nil {
+		t.Fatalf("expected skipped, got %s: %v", result, err)
+	}
+}
+
+func TestRunnerPassesOtherProjectsThrough(t *testing.T) {
+	next := &recordingRunner{}
+	r := WrapRunner(next)
+	if _, err := r.Run(context.Background(), &runner.RunParams{ProjectName: "infra", StackPath: "envs/prod"}); err == nil {
+		t.Fatalf("expected the wrapped runner's error")
+	}
+	if len(next.calls) != 1 || next.calls[0] != "infra" {
+		t.Fatalf("expected one call for infra, got %v", next.calls)
+	}
+
+	result, err := r.Run(context.Background(), &runner.RunParams{ProjectName: config.CanaryProjectName, StackPath: StackPath, WorkspacePath: t.TempDir()})
+	if err != nil {
+		t.Fatalf("canary run: %v", err)
+	}
+	if result.Error == "" {
+		t.Fatalf("expected an error result when the canary stack is missing from the workspace")
+	}
+}
@@ -0,0 +1,22 @@
+package config
+
+import "time"
+
+const defaultBadgeCacheMaxAge = 5 * time.Minute
+
+// BadgesConfig controls the SVG status badges under /badge.
+type BadgesConfig struct {
+	// Public serves badges without authentication so they can be embedded
+	// in READMEs and wikis. Badges only reveal drift counts and scan age.
+	Public bool `yaml:"public"`
+	// CacheMaxAge is the Cache-Control max-age of a badge. Negative sends
+	// no-cache.
+	CacheMaxAge time.Duration `yaml:"cache_max_age"`
+}
+
+func applyBadgeDefaults(cfg *Config) []error {
+	if cfg.Badges.CacheMaxAge == 0 {
+		cfg.Badges.CacheMaxAge = defaultBadgeCacheMaxAge
+	}
+	return nil
+}
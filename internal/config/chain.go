@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Chain outcomes a link can fire on.
+const (
+	ChainOnAny     = "any"
+	ChainOnClean   = "clean"
+	ChainOnDrifted = "drifted"
+)
+
+// ChainTrigger starts a scan of another project after a scan of this one
+// completes, for projects that read each other's remote state.
+type ChainTrigger struct {
+	Project string `yaml:"project" json:"project"`
+	// On is "clean", "drifted" or "any" (the default).
+	On string `yaml:"on,omitempty" json:"on,omitempty"`
+}
+
+// Fires reports whether the link fires for a completed scan that did or did
+// not find drift.
+func (c ChainTrigger) Fires(drifted bool) bool {
+	switch c.On {
+	case ChainOnClean:
+		return !drifted
+	case ChainOnDrifted:
+		return drifted
+	default:
+		return true
+	}
+}
+
+// NormalizeChain trims chain links, defaults On to "any" and checks them.
+func NormalizeChain(projectName string, chain []ChainTrigger) ([]ChainTrigger, error) {
+	if len(chain) == 0 {
+		return nil, nil
+	}
+	out := make([]ChainTrigger, 0, len(chain))
+	seen := make(map[string]struct{}, len(chain))
+	for _, link := range chain {
+		link.Project = strings.TrimSpace(link.Project)
+		link.On = strings.ToLower(strings.TrimSpace(link.On))
+		if link.On == "" {
+			link.On = ChainOnAny
+		}
+		if !isValidProjectName(link.Project) {
+			return nil, fmt.Errorf("chain: invalid project name %q", link.Project)
+		}
+		if link.Project == projectName {
+			return nil, fmt.Errorf("chain: project cannot trigger itself")
+		}
+		if link.On != ChainOnAny && link.On != ChainOnClean && link.On != ChainOnDrifted {
+			return nil, fmt.Errorf("chain: on must be any, clean or drifted")
+		}
+		key := link.Project + "\x00" + link.On
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, link)
+	}
+	return out, nil
+}
+
+// FindChainCycle returns the projects of a chain loop, starting and ending
+// with the same name, or nil when the chains are acyclic.
+func FindChainCycle(chains map[string][]ChainTrigger) []string {
+	names := make([]string, 0, len(chains))
+	for name := range chains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(chains))
+	var stack []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			for i, n := range stack {
+				if n == name {
+					return append(append([]string{}, stack[i:]...), name)
+				}
+			}
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		stack = append(stack, name)
+		for _, link := range chains[name] {
+			if cycle := visit(link.Project); cycle != nil {
+				return cycle
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = done
+		return nil
+	}
+	for _, name := range names {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
@@ -1,7 +1,9 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net/mail"
 	"os"
 	"path"
 	"path/filepath"
@@ -10,7 +12,7 @@ import (
 	"strings"
 	"time"
 
-	"gopkg.in/yaml.v3"
+	"github.com/robfig/cron/v3"
 )
 
 type Config struct {
@@ -20,6 +22,7 @@ type Config struct {
 	// Never enable this in shared or production environments.
 	InsecureDevMode bool            `yaml:"insecure_dev_mode"`
 	Redis           RedisConfig     `yaml:"redis"`
+	Queue           QueueConfig     `yaml:"queue"`
 	Worker          WorkerConfig    `yaml:"worker"`
 	Workspace       WorkspaceConfig `yaml:"workspace"`
 	Projects        []ProjectConfig `yaml:"projects"`
@@ -28,12 +31,75 @@ type Config struct {
 	APIAuth         APIAuthConfig   `yaml:"api_auth"`
 	Auth            AuthConfig      `yaml:"auth"`
 	API             APIConfig       `yaml:"api"`
+	Report          ReportConfig    `yaml:"report"`
+	Canary          CanaryConfig    `yaml:"canary"`
+	Scheduler       SchedulerConfig `yaml:"scheduler"`
+	Storage         StorageConfig   `yaml:"storage"`
+	AccessLog       AccessLogConfig `yaml:"access_log"`
+	Jira            JiraConfig      `yaml:"jira"`
+	// ScanLimits caps scan starts per trigger across all projects.
+	ScanLimits ScanLimitsConfig `yaml:"scan_limits"`
+	// Severity weighs drifted stacks so the worst drift is listed first.
+	Severity SeverityConfig `yaml:"severity"`
+	// Environments group stacks by path; the first matching mapping wins.
+	Environments []EnvironmentMapping `yaml:"environments"`
+	// Gate is the policy behind the deployment gate API.
+	Gate GateConfig `yaml:"gate"`
+	// QueueAlarm watches stack scan queue wait for starvation.
+	QueueAlarm QueueAlarmConfig `yaml:"queue_alarm"`
+	// Badges are embeddable SVG drift status images.
+	Badges BadgesConfig `yaml:"badges"`
+	// WarehouseExport writes result summaries for analytics.
+	WarehouseExport WarehouseExportConfig `yaml:"warehouse_export"`
+	// ScanRetry retries scans that fail before planning.
+	ScanRetry ScanRetryConfig `yaml:"scan_retry"`
+	// Federation merges the overviews of peer instances.
+	Federation FederationConfig `yaml:"federation"`
+	// Notifications send newly drifted stacks to webhook channels.
+	Notifications NotificationsConfig `yaml:"notifications"`
+	// ScanDefaults set retries, priority and concurrency per trigger.
+	ScanDefaults ScanDefaultsConfig `yaml:"scan_defaults"`
+	// Spaces host isolated environments, each with its own projects, in
+	// one serve process. Top-level projects must be empty when set.
+	Spaces []SpaceConfig `yaml:"spaces"`
 }
 
 type RedisConfig struct {
 	Addr     string `yaml:"addr"`
 	Password string `yaml:"password"`
 	DB       int    `yaml:"db"`
+	// CompressAboveBytes stores stack scans larger than this gzip-compressed.
+	// Zero uses the 4 KiB default and a negative value disables compression.
+	CompressAboveBytes int `yaml:"compress_above_bytes"`
+	// Retention bounds each family of driftd keys. Zero values keep the
+	// defaults.
+	Retention RedisRetentionConfig `yaml:"retention"`
+}
+
+// RedisRetentionConfig sets how long, or how many entries of, each family
+// of driftd keys Redis keeps.
+type RedisRetentionConfig struct {
+	Scans        time.Duration `yaml:"scans"`         // default 7 days
+	ScanHistory  int           `yaml:"scan_history"`  // scans indexed per project, default 200
+	StackScans   time.Duration `yaml:"stack_scans"`   // default 7 days
+	DriftChanges int           `yaml:"drift_changes"` // per project, default 1000
+	OutboxEvents int64         `yaml:"outbox_events"` // default 100000
+}
+
+// QueueConfig selects the queue backend. Redis is the default.
+type QueueConfig struct {
+	Backend string     `yaml:"backend"` // "redis" or "nats"
+	NATS    NATSConfig `yaml:"nats"`
+}
+
+// NATSConfig configures the NATS JetStream queue backend.
+type NATSConfig struct {
+	URL       string `yaml:"url"`
+	CredsFile string `yaml:"creds_file"`
+	// Prefix names the streams, buckets and subjects driftd creates, so
+	// several installations can share one NATS account.
+	Prefix   string `yaml:"prefix"`
+	Replicas int    `yaml:"replicas"`
 }
 
 type WorkerConfig struct {
@@ -50,19 +116,136 @@ type WorkerConfig struct {
 	// BlockExternalDataSource blocks scans when local stack config uses Terraform data "external".
 	// This is a defense-in-depth control to reduce arbitrary command execution risk during plan.
 	BlockExternalDataSource bool `yaml:"block_external_data_source"`
+	// WarmPool keeps hot stacks initialized between scans.
+	WarmPool WarmPoolConfig `yaml:"warm_pool"`
 }
 
 type WorkspaceConfig struct {
 	Retention        int   `yaml:"retention"`          // number of workspace snapshots to keep per project
 	CleanupAfterPlan *bool `yaml:"cleanup_after_plan"` // remove terraform/terragrunt artifacts from scan workspaces
+	// IncrementalMaxStacks is the largest number of affected stacks for which
+	// a webhook scan fetches only its branch and sparsely checks out the
+	// affected stacks and their local modules. 0 disables it; default 2.
+	IncrementalMaxStacks *int `yaml:"incremental_max_stacks"`
+	// Prune deletes old scan workspaces on an interval by scan status.
+	Prune WorkspacePruneConfig `yaml:"prune"`
+}
+
+// ReportConfig configures the scheduled drift report email.
+type ReportConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Schedule is a cron expression; the default sends on Mondays at 08:00.
+	Schedule string `yaml:"schedule"`
+	// Weeks is how many weeks of history the trend graphs cover (1-4).
+	Weeks      int      `yaml:"weeks"`
+	Recipients []string `yaml:"recipients"`
+	// PublicURL is the base URL used for links and unsubscribe links in the
+	// email. Defaults to webhook.public_url.
+	PublicURL string     `yaml:"public_url"`
+	SMTP      SMTPConfig `yaml:"smtp"`
+}
+
+// CanaryConfig configures the self-test project. When enabled, the server
+// scans a generated fixture repository on an interval through the real
+// queue and workers, with the plan replaced by a fake, so a broken pipeline
+// shows up in metrics instead of looking like "no drift".
+type CanaryConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	// Timeout is how long one canary scan may take before it counts as failed.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// SchedulerConfig configures cron-triggered scans. Serve replicas elect a
+// leader through the queue backend; only the leader starts scheduled scans.
+type SchedulerConfig struct {
+	// LeaderLeaseTTL is how long a leader keeps the lease without renewing
+	// it, and so the longest a failed leader delays scheduled scans.
+	LeaderLeaseTTL time.Duration `yaml:"leader_lease_ttl"`
+	// MaxJitter delays each project's scheduled scans by a fixed amount up
+	// to this long, derived from the project name, so projects sharing a
+	// schedule don't all start on the minute. Default 20s; negative turns
+	// jitter off. Projects can override it with schedule_jitter.
+	MaxJitter time.Duration `yaml:"max_jitter"`
+	// StaggerWindow spreads projects whose schedules fire in the same
+	// minute evenly across this window, in project name order, before the
+	// jitter is added. 0 turns staggering off.
+	StaggerWindow time.Duration `yaml:"stagger_window"`
+}
+
+// StorageConfig tunes how scan results are written to and read from
+// data_dir.
+type StorageConfig struct {
+	// Fsync is "always" (default) to sync each result file as it is
+	// written, "interval" to sync written files in one batch every
+	// FsyncInterval, or "never" to leave flushing to the OS.
+	Fsync         string        `yaml:"fsync"`
+	FsyncInterval time.Duration `yaml:"fsync_interval"`
+	// CacheTTL is how long stack listings are served from memory before
+	// data_dir is checked for results written by other processes. A
+	// negative value turns the cache off.
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// AccessLogConfig controls the HTTP access log.
+type AccessLogConfig struct {
+	// Format is "text" (default) or "json".
+	Format string `yaml:"format"`
+	// Path is a file the log is appended to. Empty writes to stdout.
+	Path string `yaml:"path"`
+	// ExcludePaths are path prefixes left out of the log. Defaults to
+	// /static/ and /api/health; an empty list logs everything.
+	ExcludePaths []string `yaml:"exclude_paths"`
+}
+
+// CanaryProjectName is reserved for the canary project.
+const CanaryProjectName = "driftd-canary"
+
+type SMTPConfig struct {
+	Host        string `yaml:"host"`
+	Port        int    `yaml:"port"`
+	Username    string `yaml:"username"`
+	Password    string `yaml:"password"`
+	PasswordEnv string `yaml:"password_env"`
+	From        string `yaml:"from"`
+	// TLS is "starttls" (default), "tls" for implicit TLS, or "none".
+	TLS string `yaml:"tls"`
 }
 
 type WebhookConfig struct {
-	Enabled      bool   `yaml:"enabled"`
-	GitHubSecret string `yaml:"github_secret"`
-	Token        string `yaml:"token"`
-	TokenHeader  string `yaml:"token_header"`
-	MaxFiles     int    `yaml:"max_files"`
+	Enabled         bool   `yaml:"enabled"`
+	GitHubSecret    string `yaml:"github_secret"`
+	GitLabToken     string `yaml:"gitlab_token"`
+	BitbucketSecret string `yaml:"bitbucket_secret"`
+	Token           string `yaml:"token"`
+	TokenHeader     string `yaml:"token_header"`
+	MaxFiles        int    `yaml:"max_files"`
+	// MaxBodyBytes caps the size of a webhook payload. Larger requests are
+	// rejected with 413 before they are buffered.
+	MaxBodyBytes int64 `yaml:"max_body_bytes"`
+	// AutoRegister creates or updates the GitHub push webhook on repositories
+	// of projects that authenticate with a GitHub App.
+	AutoRegister bool `yaml:"auto_register"`
+	// PublicURL is the externally reachable base URL of driftd, used as the
+	// target of auto-registered webhooks.
+	PublicURL string `yaml:"public_url"`
+	// Deployments scans the stacks of an environment after a successful
+	// GitHub deployment to it and reports the result back as a deployment
+	// status.
+	Deployments bool `yaml:"deployments"`
+}
+
+func (c WebhookConfig) hasProviderSecret() bool {
+	return c.GitHubSecret != "" || c.GitLabToken != "" || c.BitbucketSecret != ""
+}
+
+func hasProjectWebhookSecret(projects []ProjectConfig) bool {
+	for _, project := range projects {
+		if project.WebhookSecretEnv != "" {
+			return true
+		}
+	}
+	return false
 }
 
 type UIAuthConfig struct {
@@ -85,6 +268,21 @@ type AuthConfig struct {
 	// - "external": driftd trusts identity headers from an upstream auth proxy (e.g. oauth2-proxy).
 	Mode     string             `yaml:"mode"`
 	External ExternalAuthConfig `yaml:"external"`
+	Session  SessionConfig      `yaml:"session"`
+}
+
+// SessionConfig controls the signed cookie sessions used by the UI login.
+type SessionConfig struct {
+	// Secret signs session cookies. When empty, a key is derived from
+	// DRIFTD_ENCRYPTION_KEY, or generated per process (sessions then reset on restart).
+	Secret string `yaml:"secret"`
+	// MaxAge is the absolute session lifetime.
+	MaxAge time.Duration `yaml:"max_age"`
+	// IdleTimeout ends a session after this long without requests.
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	// LogoutURL is where /logout redirects. Useful in external mode to end
+	// the upstream proxy session (e.g. /oauth2/sign_out).
+	LogoutURL string `yaml:"logout_url"`
 }
 
 type ExternalAuthConfig struct {
@@ -110,21 +308,87 @@ type APIConfig struct {
 	// direct peer IP. Prefer leaving this false and relying on private/loopback
 	// proxy checks.
 	TrustProxy bool `yaml:"trust_proxy"`
+	// MaxInlinePlanBytes caps plan output rendered in the UI or returned by the
+	// plan API. Larger plans are cut to a head and tail; the full text stays
+	// available from the raw plan endpoint.
+	MaxInlinePlanBytes int `yaml:"max_inline_plan_bytes"`
+	// LegacyRepoRoutes serves the deprecated /repos path family alongside
+	// /projects. Defaults to true.
+	LegacyRepoRoutes *bool `yaml:"legacy_repo_routes"`
+	// IdempotencyWindow is how long a scan request's Idempotency-Key is
+	// remembered. Defaults to 24h.
+	IdempotencyWindow time.Duration `yaml:"idempotency_window"`
+	// WebSocketOrigins are extra browser origins ("https://host[:port]")
+	// allowed to open /api/ws. Same-host pages and clients that send no
+	// Origin header are always allowed.
+	WebSocketOrigins []string `yaml:"websocket_origins"`
+	// Accessibility sets the UI's default display modes. Each browser can
+	// override them from the settings page.
+	Accessibility AccessibilityConfig `yaml:"accessibility"`
+}
+
+// AccessibilityConfig holds the UI display modes rendered into every page.
+type AccessibilityConfig struct {
+	// ReducedMotion turns off transitions and animations.
+	ReducedMotion bool `yaml:"reduced_motion"`
+	// HighContrast strengthens borders, muted text and focus outlines.
+	HighContrast bool `yaml:"high_contrast"`
+}
+
+func (c APIConfig) LegacyRepoRoutesEnabled() bool {
+	if c.LegacyRepoRoutes == nil {
+		return true
+	}
+	return *c.LegacyRepoRoutes
 }
 
 const (
 	minLockTTL    = 2 * time.Minute
 	minRenewEvery = 10 * time.Second
 	maxCloneDepth = 1000
+
+	defaultMaxInlinePlanBytes = 1 << 20
+	defaultIdempotencyWindow  = 24 * time.Hour
+	// defaultMaxWebhookBodyBytes matches GitHub's own 25 MB payload cap.
+	defaultMaxWebhookBodyBytes = 25 << 20
+	minInlinePlanBytes         = 4 << 10
+
+	defaultIncrementalMaxStacks = 2
+
+	defaultReportSchedule = "0 8 * * 1"
+	defaultReportWeeks    = 4
+	maxReportWeeks        = 4
+
+	defaultCanaryInterval = 5 * time.Minute
+	defaultCanaryTimeout  = 2 * time.Minute
+	minCanaryInterval     = time.Minute
+
+	defaultLeaderLeaseTTL = 15 * time.Second
+	minLeaderLeaseTTL     = 3 * time.Second
+	defaultScheduleJitter = 20 * time.Second
+
+	defaultFsyncInterval   = time.Second
+	defaultStorageCacheTTL = 5 * time.Second
+)
+
+// Queue backends.
+const (
+	QueueBackendRedis = "redis"
+	QueueBackendNATS  = "nats"
 )
 
-var projectNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+var (
+	projectNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+	natsPrefixPattern  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
 
 type MonorepoProjectConfig struct {
 	Name        string   `yaml:"name"`
 	Path        string   `yaml:"path"`
 	Schedule    string   `yaml:"schedule,omitempty"`
 	IgnorePaths []string `yaml:"ignore_paths,omitempty"`
+	// Chain starts scans of other projects after this one completes.
+	Chain []ChainTrigger `yaml:"chain,omitempty"`
 }
 
 type ProjectConfig struct {
@@ -135,11 +399,112 @@ type ProjectConfig struct {
 	Schedule                   string                  `yaml:"schedule"` // cron expression, empty = no scheduled scans
 	CancelInflightOnNewTrigger *bool                   `yaml:"cancel_inflight_on_new_trigger"`
 	Git                        *GitAuthConfig          `yaml:"git"`
+	Terragrunt                 TerragruntConfig        `yaml:"terragrunt"`
+	Pulumi                     PulumiConfig            `yaml:"pulumi"`
+	Terraform                  TerraformArgsConfig     `yaml:"terraform"`
+	NoiseReduction             NoiseReductionConfig    `yaml:"noise_reduction"`
+	ScanLimits                 ScanLimitsConfig        `yaml:"scan_limits"`
+	RedactPatterns             []string                `yaml:"redact_patterns"`         // extra regexes scrubbed from plan output
+	CheckoutTriggerCommit      bool                    `yaml:"checkout_trigger_commit"` // scan the webhook/API commit instead of branch head when reachable
+	Runner                     *RunnerPluginConfig     `yaml:"runner,omitempty"`        // external runner binary used instead of terraform/terragrunt
 	Projects                   []MonorepoProjectConfig `yaml:"projects,omitempty"`
+	// Chain starts scans of other projects after a scan of this one
+	// completes clean or drifted.
+	Chain []ChainTrigger `yaml:"chain,omitempty"`
+	// Branches scans several long-lived branches of the same repository. Each
+	// branch becomes its own project named "<name>--<branch>" with
+	// independent scans and results. Mutually exclusive with branch.
+	Branches []string `yaml:"branches,omitempty"`
+	// TerraformVersion and TerragruntVersion are used for stacks with no
+	// .terraform-version / .terragrunt-version file in the repository,
+	// instead of whatever binary the worker has.
+	TerraformVersion  string `yaml:"terraform_version,omitempty"`
+	TerragruntVersion string `yaml:"terragrunt_version,omitempty"`
+	// SkipUntouchedDays leaves stacks out of scheduled scans when no commit
+	// in that many days changed them or a local module they call. 0 scans
+	// every stack.
+	SkipUntouchedDays int `yaml:"skip_untouched_days,omitempty"`
+	// StackNames give stacks display names and groups in the UI and API.
+	StackNames []StackNameRule `yaml:"stack_names,omitempty"`
+	// SecretScan flags secrets committed to the repository on every scan.
+	SecretScan SecretScanConfig `yaml:"secret_scan"`
+	// ScheduleJitter overrides scheduler.max_jitter for this project. A
+	// negative value starts its scheduled scans without jitter.
+	ScheduleJitter time.Duration `yaml:"schedule_jitter,omitempty"`
+	// CredentialCheck verifies the worker's cloud credentials are
+	// plan-only before stacks are planned.
+	CredentialCheck CredentialCheckConfig `yaml:"credential_check"`
+	// WebhookSecretEnv names an environment variable holding this project's
+	// webhook secret or token. It replaces the global webhook secret for
+	// pushes to the project's repository.
+	WebhookSecretEnv string `yaml:"webhook_secret_env,omitempty"`
+	// BlameDrift attributes each drifted resource to the last commit that
+	// touched its resource block, shown on the stack page.
+	BlameDrift bool `yaml:"blame_drift,omitempty"`
+	// ConsoleLinks are cloud console URL templates shown on the stack page.
+	ConsoleLinks []ConsoleLink `yaml:"console_links,omitempty"`
+	// ScanDefaults override the global scan_defaults per trigger.
+	ScanDefaults ScanDefaultsConfig `yaml:"scan_defaults"`
 
 	// Derived fields used internally after config load/expansion.
 	RootPath string `yaml:"-"`
 	CloneURL string `yaml:"-"`
+	// BranchGroup is the configured project name a branch project was
+	// expanded from.
+	BranchGroup string `yaml:"-"`
+}
+
+// TerragruntConfig holds per-project terragrunt behavior for plan-only scans.
+type TerragruntConfig struct {
+	// FetchDependencyOutputFromState reads dependency outputs directly from the
+	// upstream stacks' remote state instead of running "terragrunt output".
+	// Stacks whose dependencies have never been applied still need mock_outputs.
+	FetchDependencyOutputFromState bool `yaml:"fetch_dependency_output_from_state"`
+	// CacheDependencyOutputs shares dependency outputs between the stacks of
+	// one scan, so each upstream stack is initialized once per worker.
+	CacheDependencyOutputs bool `yaml:"cache_dependency_outputs"`
+}
+
+// SecretScanConfig checks the checked-out workspace for committed secrets
+// before stacks are planned. Findings are recorded as scan warnings; they do
+// not fail the scan.
+type SecretScanConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Patterns are extra regexes reported on any line they match.
+	Patterns []string `yaml:"patterns,omitempty"`
+}
+
+// NoiseReductionConfig selects heuristics that recognize plan changes with
+// no semantic effect. A plan made only of such changes is reported as
+// noisy-clean instead of drifted; its output is still kept. All are off by
+// default.
+type NoiseReductionConfig struct {
+	// Whitespace ignores string values and lines differing only in whitespace.
+	Whitespace bool `yaml:"whitespace"`
+	// JSON ignores string values holding equal JSON documents, e.g. policies
+	// re-marshaled with a different key order or formatting.
+	JSON bool `yaml:"json"`
+	// Ordering ignores list elements that only moved.
+	Ordering bool `yaml:"ordering"`
+}
+
+// RunnerPluginConfig points a project at an external runner binary. The
+// worker execs Command for every stack, writes the job as JSON to its stdin
+// and reads the result as JSON from its stdout (see runner.PluginJob).
+type RunnerPluginConfig struct {
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args,omitempty"`
+	// PassEnv names extra environment variables passed through to the
+	// plugin on top of the ones terraform runs get.
+	PassEnv []string `yaml:"pass_env,omitempty"`
+}
+
+// PulumiConfig holds per-project settings for stacks discovered from
+// Pulumi.yaml files.
+type PulumiConfig struct {
+	// Stack is the Pulumi stack previewed in every Pulumi project. When empty
+	// a project's only Pulumi.<stack>.yaml selects it.
+	Stack string `yaml:"stack"`
 }
 
 func (r *ProjectConfig) CancelInflightEnabled() bool {
@@ -166,6 +531,13 @@ func (w WorkspaceConfig) CleanupAfterPlanEnabled() bool {
 	return *w.CleanupAfterPlan
 }
 
+func (w WorkspaceConfig) IncrementalStackLimit() int {
+	if w.IncrementalMaxStacks == nil {
+		return defaultIncrementalMaxStacks
+	}
+	return *w.IncrementalMaxStacks
+}
+
 type GitAuthConfig struct {
 	Type string `yaml:"type"` // "ssh", "https", "github_app"
 
@@ -183,6 +555,41 @@ type GitAuthConfig struct {
 	HTTPSTokenEnv string `yaml:"https_token_env"`
 
 	GitHubApp *GitHubAppConfig `yaml:"github_app"`
+
+	// ProxyURL routes git and GitHub App token requests through an HTTP(S)
+	// or SOCKS5 proxy. Credentials may be embedded in the URL; the password
+	// can instead be read from ProxyPasswordEnv.
+	ProxyURL         string `yaml:"proxy_url"`
+	ProxyPasswordEnv string `yaml:"proxy_password_env"`
+	// CABundlePath is a PEM file of extra CA certificates trusted for the
+	// git remote, e.g. a GitHub Enterprise instance with a private CA.
+	CABundlePath string `yaml:"ca_bundle_path"`
+	// SSHCertificate has the SSH key signed by an SSH CA before each clone.
+	// Without ssh_key_path or ssh_key_env, a throwaway key is generated.
+	SSHCertificate *SSHCertificateConfig `yaml:"ssh_certificate"`
+}
+
+// SSHCertificateConfig fetches a short-lived SSH user certificate.
+type SSHCertificateConfig struct {
+	Signer string `yaml:"signer"` // "vault" or "command"
+	// Principals and TTL are requested from the signer; empty leaves them
+	// to the signer's defaults.
+	Principals []string      `yaml:"principals"`
+	TTL        time.Duration `yaml:"ttl"`
+
+	Vault *VaultSSHSignerConfig `yaml:"vault"`
+	// Command is run with the public key on stdin and must print the
+	// signed certificate in authorized_keys format.
+	Command []string `yaml:"command"`
+}
+
+// VaultSSHSignerConfig signs keys with Vault's SSH secrets engine.
+type VaultSSHSignerConfig struct {
+	Address   string `yaml:"address"`   // default $VAULT_ADDR
+	TokenEnv  string `yaml:"token_env"` // default VAULT_TOKEN
+	Mount     string `yaml:"mount"`     // default "ssh-client-signer"
+	Role      string `yaml:"role"`
+	Namespace string `yaml:"namespace"`
 }
 
 type GitHubAppConfig struct {
@@ -194,7 +601,16 @@ type GitHubAppConfig struct {
 	APIBaseURL     string `yaml:"api_base_url"`
 }
 
+// Load reads the config file at path. When DRIFTD_ENV is set, the matching
+// overlay (config.prod.yaml for DRIFTD_ENV=prod) is merged on top, and
+// ${VAR} or ${VAR:-default} in any value is replaced from the environment.
 func Load(path string) (*Config, error) {
+	return LoadEnv(path, os.Getenv(EnvironmentVar))
+}
+
+// LoadEnv is Load with the overlay environment given explicitly; an empty
+// env loads path alone.
+func LoadEnv(path, env string) (*Config, error) {
 	cfg := &Config{
 		DataDir:    "./data",
 		ListenAddr: ":8080",
@@ -220,16 +636,14 @@ func Load(path string) (*Config, error) {
 		return applyDefaults(cfg)
 	}
 
-	data, err := os.ReadFile(path)
+	root, err := readConfigNode(path, env)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return applyDefaults(cfg)
-		}
 		return nil, err
 	}
-
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return nil, err
+	if root != nil {
+		if err := root.Decode(cfg); err != nil {
+			return nil, err
+		}
 	}
 
 	return applyDefaults(cfg)
@@ -245,6 +659,7 @@ func (c *Config) GetProject(name string) *ProjectConfig {
 }
 
 func applyDefaults(cfg *Config) (*Config, error) {
+	var errs []error
 	// Apply defaults for unset values
 	if cfg.DataDir == "" {
 		cfg.DataDir = "./data"
@@ -255,6 +670,41 @@ func applyDefaults(cfg *Config) (*Config, error) {
 	if cfg.Redis.Addr == "" {
 		cfg.Redis.Addr = "localhost:6379"
 	}
+	if r := cfg.Redis.Retention; r.Scans < 0 || r.ScanHistory < 0 || r.StackScans < 0 || r.DriftChanges < 0 || r.OutboxEvents < 0 {
+		errs = append(errs, fmt.Errorf("redis.retention values must not be negative"))
+	}
+	// Scans and stack scans must outlive the runs that write them.
+	if r := cfg.Redis.Retention; (r.Scans > 0 && r.Scans < time.Hour) || (r.StackScans > 0 && r.StackScans < time.Hour) {
+		errs = append(errs, fmt.Errorf("redis.retention.scans and stack_scans must be at least 1h"))
+	}
+	for i := range cfg.Environments {
+		if err := cfg.Environments[i].compile(); err != nil {
+			errs = append(errs, fmt.Errorf("environments[%d]: %w", i, err))
+		}
+	}
+	switch cfg.Queue.Backend {
+	case "":
+		cfg.Queue.Backend = QueueBackendRedis
+	case QueueBackendRedis:
+	case QueueBackendNATS:
+		if strings.TrimSpace(cfg.Queue.NATS.URL) == "" {
+			errs = append(errs, fmt.Errorf("queue.nats.url is required when queue.backend is nats"))
+		}
+		if cfg.Queue.NATS.Prefix == "" {
+			cfg.Queue.NATS.Prefix = "driftd"
+		}
+		if !natsPrefixPattern.MatchString(cfg.Queue.NATS.Prefix) {
+			errs = append(errs, fmt.Errorf("queue.nats.prefix may only contain letters, digits, '-' and '_'"))
+		}
+		if cfg.Queue.NATS.Replicas == 0 {
+			cfg.Queue.NATS.Replicas = 1
+		}
+		if cfg.Queue.NATS.Replicas < 1 || cfg.Queue.NATS.Replicas > 5 {
+			errs = append(errs, fmt.Errorf("queue.nats.replicas must be between 1 and 5"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("queue.backend must be redis or nats (got %q)", cfg.Queue.Backend))
+	}
 	if cfg.Worker.Concurrency < 1 {
 		cfg.Worker.Concurrency = 5
 	}
@@ -268,10 +718,10 @@ func applyDefaults(cfg *Config) (*Config, error) {
 		cfg.Worker.CloneDepth = 1
 	}
 	if cfg.Worker.CloneDepth < 1 {
-		return nil, fmt.Errorf("worker.clone_depth must be >= 1")
+		errs = append(errs, fmt.Errorf("worker.clone_depth must be >= 1"))
 	}
 	if cfg.Worker.CloneDepth > maxCloneDepth {
-		return nil, fmt.Errorf("worker.clone_depth must be <= %d", maxCloneDepth)
+		errs = append(errs, fmt.Errorf("worker.clone_depth must be <= %d", maxCloneDepth))
 	}
 	if cfg.Worker.RenewEvery == 0 {
 		cfg.Worker.RenewEvery = cfg.Worker.LockTTL / 3
@@ -280,7 +730,7 @@ func applyDefaults(cfg *Config) (*Config, error) {
 		cfg.Worker.StackTimeout = 30 * time.Minute
 	}
 	if cfg.Worker.StackTimeout < time.Second {
-		return nil, fmt.Errorf("worker.stack_timeout must be at least 1s")
+		errs = append(errs, fmt.Errorf("worker.stack_timeout must be at least 1s"))
 	}
 	if cfg.Workspace.Retention <= 0 {
 		cfg.Workspace.Retention = 5
@@ -289,6 +739,12 @@ func applyDefaults(cfg *Config) (*Config, error) {
 		enabled := true
 		cfg.Workspace.CleanupAfterPlan = &enabled
 	}
+	if cfg.Workspace.IncrementalMaxStacks == nil {
+		limit := defaultIncrementalMaxStacks
+		cfg.Workspace.IncrementalMaxStacks = &limit
+	} else if *cfg.Workspace.IncrementalMaxStacks < 0 {
+		errs = append(errs, fmt.Errorf("workspace.incremental_max_stacks must be >= 0"))
+	}
 	if cfg.Webhook.TokenHeader == "" {
 		cfg.Webhook.TokenHeader = "X-Webhook-Token"
 	}
@@ -299,7 +755,19 @@ func applyDefaults(cfg *Config) (*Config, error) {
 	case "internal", "external":
 		cfg.Auth.Mode = strings.ToLower(strings.TrimSpace(cfg.Auth.Mode))
 	default:
-		return nil, fmt.Errorf("auth.mode must be one of: internal, external")
+		errs = append(errs, fmt.Errorf("auth.mode must be one of: internal, external"))
+	}
+	if cfg.Auth.Session.MaxAge == 0 {
+		cfg.Auth.Session.MaxAge = 12 * time.Hour
+	}
+	if cfg.Auth.Session.IdleTimeout == 0 {
+		cfg.Auth.Session.IdleTimeout = 30 * time.Minute
+	}
+	if cfg.Auth.Session.IdleTimeout < time.Minute {
+		errs = append(errs, fmt.Errorf("auth.session.idle_timeout must be at least 1m"))
+	}
+	if cfg.Auth.Session.MaxAge < cfg.Auth.Session.IdleTimeout {
+		errs = append(errs, fmt.Errorf("auth.session.max_age must be >= auth.session.idle_timeout"))
 	}
 	if cfg.Auth.External.UserHeader == "" {
 		cfg.Auth.External.UserHeader = "X-Auth-Request-User"
@@ -320,9 +788,9 @@ func applyDefaults(cfg *Config) (*Config, error) {
 	case "none", "viewer", "operator", "admin":
 		cfg.Auth.External.DefaultRole = strings.ToLower(strings.TrimSpace(cfg.Auth.External.DefaultRole))
 	default:
-		return nil, fmt.Errorf("auth.external.default_role must be one of: none, viewer, operator, admin")
+		errs = append(errs, fmt.Errorf("auth.external.default_role must be one of: none, viewer, operator, admin"))
 	}
-	if !cfg.Webhook.Enabled && (cfg.Webhook.GitHubSecret != "" || cfg.Webhook.Token != "") {
+	if !cfg.Webhook.Enabled && (cfg.Webhook.hasProviderSecret() || cfg.Webhook.Token != "") {
 		cfg.Webhook.Enabled = true
 	}
 	if cfg.APIAuth.TokenHeader == "" {
@@ -334,27 +802,103 @@ func applyDefaults(cfg *Config) (*Config, error) {
 	if cfg.Webhook.MaxFiles <= 0 {
 		cfg.Webhook.MaxFiles = 300
 	}
+	if cfg.Webhook.MaxBodyBytes == 0 {
+		cfg.Webhook.MaxBodyBytes = defaultMaxWebhookBodyBytes
+	}
+	if cfg.Webhook.MaxBodyBytes < 0 {
+		errs = append(errs, fmt.Errorf("webhook.max_body_bytes must be positive"))
+	}
 	if cfg.API.RateLimitPerMinute == 0 {
 		cfg.API.RateLimitPerMinute = 60
 	}
-	if cfg.Webhook.Enabled && cfg.Webhook.GitHubSecret == "" && cfg.Webhook.Token == "" {
-		return nil, fmt.Errorf("webhook enabled but github_secret and token are empty")
+	if cfg.API.MaxInlinePlanBytes == 0 {
+		cfg.API.MaxInlinePlanBytes = defaultMaxInlinePlanBytes
+	}
+	if cfg.API.MaxInlinePlanBytes < minInlinePlanBytes {
+		errs = append(errs, fmt.Errorf("api.max_inline_plan_bytes must be at least %d", minInlinePlanBytes))
+	}
+	if cfg.API.IdempotencyWindow == 0 {
+		cfg.API.IdempotencyWindow = defaultIdempotencyWindow
+	}
+	if cfg.API.IdempotencyWindow < 0 {
+		errs = append(errs, fmt.Errorf("api.idempotency_window must be positive"))
+	}
+	if cfg.Webhook.Enabled && !cfg.Webhook.hasProviderSecret() && cfg.Webhook.Token == "" && !hasProjectWebhookSecret(cfg.Projects) && !cfg.hasSpaceWebhookSecret() {
+		errs = append(errs, fmt.Errorf("webhook enabled but github_secret, gitlab_token, bitbucket_secret and token are empty"))
+	}
+	cfg.Webhook.PublicURL = strings.TrimRight(strings.TrimSpace(cfg.Webhook.PublicURL), "/")
+	if cfg.Webhook.AutoRegister {
+		if cfg.Webhook.GitHubSecret == "" {
+			errs = append(errs, fmt.Errorf("webhook.auto_register requires webhook.github_secret"))
+		}
+		if !strings.HasPrefix(cfg.Webhook.PublicURL, "https://") && !strings.HasPrefix(cfg.Webhook.PublicURL, "http://") {
+			errs = append(errs, fmt.Errorf("webhook.auto_register requires webhook.public_url to be an http(s) URL"))
+		}
 	}
 	if cfg.Worker.LockTTL < minLockTTL {
-		return nil, fmt.Errorf("worker.lock_ttl must be at least %s", minLockTTL)
+		errs = append(errs, fmt.Errorf("worker.lock_ttl must be at least %s", minLockTTL))
 	}
 	if cfg.Worker.RenewEvery < minRenewEvery {
-		return nil, fmt.Errorf("worker.renew_every must be at least %s", minRenewEvery)
+		errs = append(errs, fmt.Errorf("worker.renew_every must be at least %s", minRenewEvery))
 	}
 	if cfg.Worker.RenewEvery > cfg.Worker.LockTTL/2 {
-		return nil, fmt.Errorf("worker.renew_every must be <= lock_ttl/2")
+		errs = append(errs, fmt.Errorf("worker.renew_every must be <= lock_ttl/2"))
+	}
+	errs = append(errs, applyReportDefaults(cfg)...)
+	errs = append(errs, applyCanaryDefaults(cfg)...)
+	errs = append(errs, applyStorageDefaults(cfg)...)
+	errs = append(errs, applyAccessLogDefaults(cfg)...)
+	errs = append(errs, applyJiraDefaults(cfg)...)
+	errs = append(errs, applyQueueAlarmDefaults(cfg)...)
+	errs = append(errs, applyNotificationsDefaults(cfg)...)
+	errs = append(errs, applyWorkspacePruneDefaults(cfg)...)
+	errs = append(errs, applyBadgeDefaults(cfg)...)
+	errs = append(errs, applyWarehouseExportDefaults(cfg)...)
+	errs = append(errs, applyScanRetryDefaults(cfg)...)
+	errs = append(errs, applyFederationDefaults(cfg)...)
+	errs = append(errs, applyWarmPoolDefaults(cfg)...)
+	errs = append(errs, applySpaceDefaults(cfg)...)
+	if cfg.Scheduler.LeaderLeaseTTL == 0 {
+		cfg.Scheduler.LeaderLeaseTTL = defaultLeaderLeaseTTL
+	}
+	if cfg.Scheduler.LeaderLeaseTTL < minLeaderLeaseTTL {
+		errs = append(errs, fmt.Errorf("scheduler.leader_lease_ttl must be at least %s", minLeaderLeaseTTL))
+	}
+	if cfg.Scheduler.MaxJitter == 0 {
+		cfg.Scheduler.MaxJitter = defaultScheduleJitter
+	}
+	if cfg.Scheduler.StaggerWindow < 0 {
+		errs = append(errs, fmt.Errorf("scheduler.stagger_window must not be negative"))
+	}
+	if err := cfg.ScanLimits.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := cfg.ScanDefaults.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := cfg.Severity.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := cfg.Gate.validate(); err != nil {
+		errs = append(errs, err)
 	}
 	expandedProjects, err := expandMonorepos(cfg.Projects)
 	if err != nil {
-		return nil, err
+		errs = append(errs, err)
 	}
 	cfg.Projects = expandedProjects
+	chains := make(map[string][]ChainTrigger, len(cfg.Projects))
+	for _, project := range cfg.Projects {
+		chains[project.Name] = project.Chain
+	}
+	if cycle := FindChainCycle(chains); cycle != nil {
+		errs = append(errs, fmt.Errorf("projects: chain loop %s", strings.Join(cycle, " -> ")))
+	}
 
+	// Report every problem at once so a config can be fixed in one pass.
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
 	return cfg, nil
 }
 
@@ -367,28 +911,96 @@ func expandMonorepos(projects []ProjectConfig) ([]ProjectConfig, error) {
 		if !isValidProjectName(project.Name) {
 			return nil, fmt.Errorf("%s: invalid project name %q", source, project.Name)
 		}
+		if project.Name == CanaryProjectName {
+			return nil, fmt.Errorf("%s: project name %q is reserved for the canary", source, project.Name)
+		}
 		if strings.TrimSpace(project.URL) == "" {
 			return nil, fmt.Errorf("%s (%s): url is required", source, project.Name)
 		}
+		for _, pattern := range project.RedactPatterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("%s (%s): invalid redact pattern %q: %w", source, project.Name, pattern, err)
+			}
+		}
+		if project.Git != nil && project.Git.ProxyURL != "" {
+			if err := ValidateProxyURL(project.Git.ProxyURL); err != nil {
+				return nil, fmt.Errorf("%s (%s): git.proxy_url: %w", source, project.Name, err)
+			}
+		}
+		if err := project.Terraform.validate(); err != nil {
+			return nil, fmt.Errorf("%s (%s): %w", source, project.Name, err)
+		}
+		if err := project.ScanLimits.validate(); err != nil {
+			return nil, fmt.Errorf("%s (%s): %w", source, project.Name, err)
+		}
+		if err := project.ScanDefaults.validate(); err != nil {
+			return nil, fmt.Errorf("%s (%s): %w", source, project.Name, err)
+		}
+		if err := ValidateToolVersion(project.TerraformVersion); err != nil {
+			return nil, fmt.Errorf("%s (%s): terraform_version: %w", source, project.Name, err)
+		}
+		if err := ValidateToolVersion(project.TerragruntVersion); err != nil {
+			return nil, fmt.Errorf("%s (%s): terragrunt_version: %w", source, project.Name, err)
+		}
+		if project.SkipUntouchedDays < 0 {
+			return nil, fmt.Errorf("%s (%s): skip_untouched_days must not be negative", source, project.Name)
+		}
+		if err := validateStackNames(project.StackNames); err != nil {
+			return nil, fmt.Errorf("%s (%s): %w", source, project.Name, err)
+		}
+		if err := validateConsoleLinks(project.ConsoleLinks); err != nil {
+			return nil, fmt.Errorf("%s (%s): %w", source, project.Name, err)
+		}
+		for _, pattern := range project.SecretScan.Patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return nil, fmt.Errorf("%s (%s): invalid secret_scan pattern %q: %w", source, project.Name, pattern, err)
+			}
+		}
+		if err := project.CredentialCheck.applyDefaults(); err != nil {
+			return nil, fmt.Errorf("%s (%s): %w", source, project.Name, err)
+		}
+		chain, err := NormalizeChain(project.Name, project.Chain)
+		if err != nil {
+			return nil, fmt.Errorf("%s (%s): %w", source, project.Name, err)
+		}
+		project.Chain = chain
+		if len(chain) > 0 && len(project.Projects) > 0 {
+			return nil, fmt.Errorf("%s (%s): set chain on the entries of projects instead", source, project.Name)
+		}
+		if project.Runner != nil {
+			if strings.TrimSpace(project.Runner.Command) == "" {
+				return nil, fmt.Errorf("%s (%s): runner.command is required", source, project.Name)
+			}
+			for _, name := range project.Runner.PassEnv {
+				if name == "" || strings.ContainsAny(name, "= ") {
+					return nil, fmt.Errorf("%s (%s): invalid runner.pass_env name %q", source, project.Name, name)
+				}
+			}
+		}
 
+		projectRepos := []ProjectConfig{project}
 		if len(project.Projects) == 0 {
 			project.Projects = nil
 			project.RootPath = ""
 			project.CloneURL = project.URL
-			if err := appendExpandedProject(&expanded, seenNames, project, source); err != nil {
+			projectRepos[0] = project
+		} else {
+			var err error
+			projectRepos, err = expandProjectProjects(project, source)
+			if err != nil {
 				return nil, err
 			}
-			continue
-		}
-
-		projectRepos, err := expandProjectProjects(project, source)
-		if err != nil {
-			return nil, err
 		}
 		for _, projectRepo := range projectRepos {
-			if err := appendExpandedProject(&expanded, seenNames, projectRepo, source); err != nil {
+			branchRepos, err := expandProjectBranches(projectRepo, project.Branches, source)
+			if err != nil {
 				return nil, err
 			}
+			for _, branchRepo := range branchRepos {
+				if err := appendExpandedProject(&expanded, seenNames, branchRepo, source); err != nil {
+					return nil, err
+				}
+			}
 		}
 	}
 
@@ -415,8 +1027,13 @@ func expandProjectProjects(parent ProjectConfig, source string) ([]ProjectConfig
 		if err != nil {
 			return nil, fmt.Errorf("%s (%s): invalid project path %q: %w", source, parent.Name, project.Path, err)
 		}
+		chain, err := NormalizeChain(project.Name, project.Chain)
+		if err != nil {
+			return nil, fmt.Errorf("%s (%s): %s: %w", source, parent.Name, project.Name, err)
+		}
 		cleanPaths = append(cleanPaths, cleanPath)
 		parent.Projects[idx].Path = cleanPath
+		parent.Projects[idx].Chain = chain
 	}
 
 	if err := validateNoOverlappingProjectPaths(cleanPaths); err != nil {
@@ -442,6 +1059,24 @@ func expandProjectProjects(parent ProjectConfig, source string) ([]ProjectConfig
 			Schedule:                   schedule,
 			CancelInflightOnNewTrigger: copyBoolPtr(parent.CancelInflightOnNewTrigger),
 			Git:                        copyGitAuth(parent.Git),
+			Terragrunt:                 parent.Terragrunt,
+			Terraform:                  copyTerraformArgs(parent.Terraform),
+			NoiseReduction:             parent.NoiseReduction,
+			ScanLimits:                 copyScanLimits(parent.ScanLimits),
+			RedactPatterns:             copyStringSlice(parent.RedactPatterns),
+			CheckoutTriggerCommit:      parent.CheckoutTriggerCommit,
+			Chain:                      project.Chain,
+			TerraformVersion:           parent.TerraformVersion,
+			TerragruntVersion:          parent.TerragruntVersion,
+			SkipUntouchedDays:          parent.SkipUntouchedDays,
+			StackNames:                 copyStackNames(parent.StackNames),
+			SecretScan:                 copySecretScan(parent.SecretScan),
+			ScheduleJitter:             parent.ScheduleJitter,
+			CredentialCheck:            copyCredentialCheck(parent.CredentialCheck),
+			WebhookSecretEnv:           parent.WebhookSecretEnv,
+			BlameDrift:                 parent.BlameDrift,
+			ConsoleLinks:               copyConsoleLinks(parent.ConsoleLinks),
+			ScanDefaults:               copyScanDefaults(parent.ScanDefaults),
 			Projects:                   nil,
 			RootPath:                   project.Path,
 			CloneURL:                   parent.URL,
@@ -451,6 +1086,62 @@ func expandProjectProjects(parent ProjectConfig, source string) ([]ProjectConfig
 	return expanded, nil
 }
 
+// expandProjectBranches returns one project per configured branch, or the
+// project unchanged when no branches are configured.
+func expandProjectBranches(project ProjectConfig, branches []string, source string) ([]ProjectConfig, error) {
+	project.Branches = nil
+	if len(branches) == 0 {
+		return []ProjectConfig{project}, nil
+	}
+	if strings.TrimSpace(project.Branch) != "" {
+		return nil, fmt.Errorf("%s (%s): branch and branches are mutually exclusive", source, project.Name)
+	}
+
+	expanded := make([]ProjectConfig, 0, len(branches))
+	seen := make(map[string]struct{}, len(branches))
+	for _, branch := range branches {
+		branch = strings.TrimSpace(branch)
+		if branch == "" {
+			return nil, fmt.Errorf("%s (%s): empty branch name", source, project.Name)
+		}
+		name := BranchProjectName(project.Name, branch)
+		if !isValidProjectName(name) {
+			return nil, fmt.Errorf("%s (%s): branch %q yields invalid project name %q", source, project.Name, branch, name)
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("%s (%s): branches collide on project name %q", source, project.Name, name)
+		}
+		seen[name] = struct{}{}
+
+		branchProject := project
+		branchProject.Name = name
+		branchProject.Branch = branch
+		branchProject.BranchGroup = project.Name
+		branchProject.IgnorePaths = copyStringSlice(project.IgnorePaths)
+		branchProject.CancelInflightOnNewTrigger = copyBoolPtr(project.CancelInflightOnNewTrigger)
+		branchProject.Git = copyGitAuth(project.Git)
+		branchProject.RedactPatterns = copyStringSlice(project.RedactPatterns)
+		branchProject.Terraform = copyTerraformArgs(project.Terraform)
+		branchProject.StackNames = copyStackNames(project.StackNames)
+		branchProject.SecretScan = copySecretScan(project.SecretScan)
+		branchProject.CredentialCheck = copyCredentialCheck(project.CredentialCheck)
+		branchProject.ConsoleLinks = copyConsoleLinks(project.ConsoleLinks)
+		branchProject.ScanDefaults = copyScanDefaults(project.ScanDefaults)
+		expanded = append(expanded, branchProject)
+	}
+	return expanded, nil
+}
+
+var branchNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// BranchProjectName derives the project name used for one branch of a
+// multi-branch project, e.g. "infra" and "release/prod" give
+// "infra--release-prod".
+func BranchProjectName(project, branch string) string {
+	slug := strings.Trim(branchNameUnsafe.ReplaceAllString(branch, "-"), "-")
+	return project + "--" + slug
+}
+
 func normalizeProjectPath(raw string) (string, error) {
 	trimmed := strings.TrimSpace(raw)
 	if trimmed == "" {
@@ -495,6 +1186,14 @@ func copyBoolPtr(value *bool) *bool {
 	return &copied
 }
 
+func copyIntPtr(value *int) *int {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
+
 func copyStringSlice(values []string) []string {
 	if values == nil {
 		return nil
@@ -504,6 +1203,10 @@ func copyStringSlice(values []string) []string {
 	return copied
 }
 
+func copySecretScan(cfg SecretScanConfig) SecretScanConfig {
+	return SecretScanConfig{Enabled: cfg.Enabled, Patterns: copyStringSlice(cfg.Patterns)}
+}
+
 func copyGitAuth(cfg *GitAuthConfig) *GitAuthConfig {
 	if cfg == nil {
 		return nil
@@ -522,3 +1225,119 @@ func isValidProjectName(name string) bool {
 	}
 	return projectNamePattern.MatchString(name)
 }
+
+func applyReportDefaults(cfg *Config) []error {
+	r := &cfg.Report
+	if !r.Enabled {
+		return nil
+	}
+	var errs []error
+	if r.Schedule == "" {
+		r.Schedule = defaultReportSchedule
+	}
+	if _, err := cron.ParseStandard(r.Schedule); err != nil {
+		errs = append(errs, fmt.Errorf("report.schedule is not a valid cron expression: %v", err))
+	}
+	if r.Weeks == 0 {
+		r.Weeks = defaultReportWeeks
+	}
+	if r.Weeks < 1 || r.Weeks > maxReportWeeks {
+		// History is only retained for 30 days.
+		errs = append(errs, fmt.Errorf("report.weeks must be between 1 and %d", maxReportWeeks))
+	}
+	for _, addr := range r.Recipients {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			errs = append(errs, fmt.Errorf("report.recipients: invalid address %q", addr))
+		}
+	}
+	r.PublicURL = strings.TrimRight(strings.TrimSpace(r.PublicURL), "/")
+	if r.PublicURL == "" {
+		r.PublicURL = cfg.Webhook.PublicURL
+	}
+	if !strings.HasPrefix(r.PublicURL, "https://") && !strings.HasPrefix(r.PublicURL, "http://") {
+		errs = append(errs, fmt.Errorf("report.public_url (or webhook.public_url) must be an http(s) URL"))
+	}
+	if r.SMTP.Host == "" {
+		errs = append(errs, fmt.Errorf("report.smtp.host is required when report is enabled"))
+	}
+	if _, err := mail.ParseAddress(r.SMTP.From); err != nil {
+		errs = append(errs, fmt.Errorf("report.smtp.from must be a valid address"))
+	}
+	switch r.SMTP.TLS {
+	case "":
+		r.SMTP.TLS = "starttls"
+	case "starttls", "tls", "none":
+	default:
+		errs = append(errs, fmt.Errorf("report.smtp.tls must be starttls, tls or none"))
+	}
+	if r.SMTP.Port == 0 {
+		r.SMTP.Port = 587
+		if r.SMTP.TLS == "tls" {
+			r.SMTP.Port = 465
+		}
+	}
+	return errs
+}
+
+func applyCanaryDefaults(cfg *Config) []error {
+	c := &cfg.Canary
+	if !c.Enabled {
+		return nil
+	}
+	var errs []error
+	if c.Interval == 0 {
+		c.Interval = defaultCanaryInterval
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultCanaryTimeout
+	}
+	if c.Interval < minCanaryInterval {
+		errs = append(errs, fmt.Errorf("canary.interval must be at least %s", minCanaryInterval))
+	}
+	if c.Timeout < 10*time.Second {
+		errs = append(errs, fmt.Errorf("canary.timeout must be at least 10s"))
+	}
+	if c.Timeout > c.Interval {
+		errs = append(errs, fmt.Errorf("canary.timeout must be <= canary.interval"))
+	}
+	return errs
+}
+
+func applyStorageDefaults(cfg *Config) []error {
+	st := &cfg.Storage
+	var errs []error
+	switch st.Fsync {
+	case "":
+		st.Fsync = "always"
+	case "always", "interval", "never":
+	default:
+		errs = append(errs, fmt.Errorf("storage.fsync must be always, interval or never"))
+	}
+	if st.FsyncInterval == 0 {
+		st.FsyncInterval = defaultFsyncInterval
+	}
+	if st.FsyncInterval < 0 {
+		errs = append(errs, fmt.Errorf("storage.fsync_interval must be positive"))
+	}
+	if st.CacheTTL == 0 {
+		st.CacheTTL = defaultStorageCacheTTL
+	}
+	return errs
+}
+
+func applyAccessLogDefaults(cfg *Config) []error {
+	al := &cfg.AccessLog
+	var errs []error
+	switch al.Format {
+	case "":
+		al.Format = "text"
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("access_log.format must be text or json"))
+	}
+	al.Path = strings.TrimSpace(al.Path)
+	if al.ExcludePaths == nil {
+		al.ExcludePaths = []string{"/static/", "/api/health"}
+	}
+	return errs
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -25,6 +26,9 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.Worker.CloneDepth != 1 {
 		t.Fatalf("expected clone_depth default 1, got %d", cfg.Worker.CloneDepth)
 	}
+	if cfg.Auth.Session.IdleTimeout != 30*time.Minute || cfg.Auth.Session.MaxAge != 12*time.Hour {
+		t.Fatalf("unexpected session defaults: %+v", cfg.Auth.Session)
+	}
 }
 
 func TestLoadValidation(t *testing.T) {
@@ -49,6 +53,108 @@ func TestLoadValidation(t *testing.T) {
 		}
 	})
 
+	t.Run("invalid_redact_pattern", func(t *testing.T) {
+		path := writeTempConfig(t, "projects:\n  - name: infra\n    url: https://example.com/infra.git\n    redact_patterns: [\"(\"]\n")
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "invalid redact pattern") {
+			t.Fatalf("expected invalid redact pattern error, got %v", err)
+		}
+	})
+
+	t.Run("max_inline_plan_bytes_too_small", func(t *testing.T) {
+		path := writeTempConfig(t, "api:\n  max_inline_plan_bytes: 100\n")
+		if _, err := Load(path); err == nil {
+			t.Fatalf("expected error for small max_inline_plan_bytes")
+		}
+	})
+
+	t.Run("idempotency_window", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, "api: {}\n"))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if cfg.API.IdempotencyWindow != 24*time.Hour {
+			t.Fatalf("expected 24h default, got %s", cfg.API.IdempotencyWindow)
+		}
+		if _, err := Load(writeTempConfig(t, "api:\n  idempotency_window: -1h\n")); err == nil {
+			t.Fatalf("expected error for negative idempotency_window")
+		}
+	})
+
+	t.Run("chain", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, "projects:\n  - name: network\n    url: https://example.com/network.git\n    chain:\n      - project: apps\n  - name: apps\n    url: https://example.com/apps.git\n"))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if chain := cfg.GetProject("network").Chain; len(chain) != 1 || chain[0].On != ChainOnAny {
+			t.Fatalf("expected on to default to any, got %+v", chain)
+		}
+		_, err = Load(writeTempConfig(t, "projects:\n  - name: network\n    url: https://example.com/network.git\n    chain:\n      - project: apps\n  - name: apps\n    url: https://example.com/apps.git\n    chain:\n      - project: network\n        on: drifted\n"))
+		if err == nil || !strings.Contains(err.Error(), "apps -> network -> apps") {
+			t.Fatalf("expected chain loop error, got %v", err)
+		}
+		if _, err := Load(writeTempConfig(t, "projects:\n  - name: network\n    url: https://example.com/network.git\n    chain:\n      - project: network\n")); err == nil {
+			t.Fatalf("expected self-chain error")
+		}
+	})
+
+	t.Run("jira", func(t *testing.T) {
+		if _, err := Load(writeTempConfig(t, "jira:\n  enabled: true\n  url: https://example.atlassian.net\n  api_token: t\n")); err == nil || !strings.Contains(err.Error(), "mappings") {
+			t.Fatalf("expected mappings error, got %v", err)
+		}
+		cfg, err := Load(writeTempConfig(t, "jira:\n  enabled: true\n  url: https://example.atlassian.net/\n  api_token: t\n  mappings:\n    - path: \"envs/**\"\n      jira_project: OPS\n      issue_type: Bug\n"))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if cfg.Jira.URL != "https://example.atlassian.net" || cfg.Jira.Interval != 5*time.Minute || cfg.Jira.CloseTransition != "Done" {
+			t.Fatalf("unexpected defaults %+v", cfg.Jira)
+		}
+		if cfg.Jira.Mapping("infra", "envs/prod/db") == nil || cfg.Jira.Mapping("infra", "modules/vpc") != nil {
+			t.Fatalf("unexpected mapping match")
+		}
+	})
+
+	t.Run("webhook_max_body_bytes", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, "webhook: {}\n"))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if cfg.Webhook.MaxBodyBytes != 25<<20 {
+			t.Fatalf("expected 25MB default, got %d", cfg.Webhook.MaxBodyBytes)
+		}
+		if _, err := Load(writeTempConfig(t, "webhook:\n  max_body_bytes: -1\n")); err == nil {
+			t.Fatalf("expected error for negative max_body_bytes")
+		}
+	})
+
+	t.Run("webhook_auto_register_requires_public_url", func(t *testing.T) {
+		path := writeTempConfig(t, "webhook:\n  github_secret: s\n  auto_register: true\n")
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "public_url") {
+			t.Fatalf("expected public_url error, got %v", err)
+		}
+		path = writeTempConfig(t, "webhook:\n  github_secret: s\n  auto_register: true\n  public_url: https://driftd.example.com/\n")
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if cfg.Webhook.PublicURL != "https://driftd.example.com" {
+			t.Fatalf("expected trailing slash trimmed, got %q", cfg.Webhook.PublicURL)
+		}
+	})
+
+	t.Run("session_idle_timeout_too_small", func(t *testing.T) {
+		path := writeTempConfig(t, "auth:\n  session:\n    idle_timeout: 10s\n")
+		if _, err := Load(path); err == nil {
+			t.Fatalf("expected error for small idle_timeout")
+		}
+	})
+
+	t.Run("session_max_age_below_idle_timeout", func(t *testing.T) {
+		path := writeTempConfig(t, "auth:\n  session:\n    idle_timeout: 1h\n    max_age: 30m\n")
+		if _, err := Load(path); err == nil {
+			t.Fatalf("expected error for max_age < idle_timeout")
+		}
+	})
+
 	t.Run("block_external_data_source_flag", func(t *testing.T) {
 		path := writeTempConfig(t, "worker:\n  block_external_data_source: true\n")
 		cfg, err := Load(path)
@@ -60,6 +166,92 @@ func TestLoadValidation(t *testing.T) {
 		}
 	})
 
+	t.Run("queue_backend", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, "queue:\n  backend: nats\n  nats:\n    url: nats://127.0.0.1:4222\n"))
+		if err != nil {
+			t.Fatalf("load config: %v", err)
+		}
+		if cfg.Queue.NATS.Prefix != "driftd" || cfg.Queue.NATS.Replicas != 1 {
+			t.Fatalf("expected nats defaults, got %+v", cfg.Queue.NATS)
+		}
+		for _, body := range []string{
+			"queue:\n  backend: nats\n",
+			"queue:\n  backend: nats\n  nats:\n    url: nats://x\n    prefix: a.b\n",
+			"queue:\n  backend: nats\n  nats:\n    url: nats://x\n    replicas: 7\n",
+			"queue:\n  backend: kafka\n",
+		} {
+			if _, err := Load(writeTempConfig(t, body)); err == nil {
+				t.Fatalf("expected error for %q", body)
+			}
+		}
+	})
+
+	t.Run("incremental_max_stacks", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, "workspace:\n  retention: 3\n"))
+		if err != nil {
+			t.Fatalf("load config: %v", err)
+		}
+		if cfg.Workspace.IncrementalStackLimit() != 2 {
+			t.Fatalf("expected default incremental_max_stacks=2, got %d", cfg.Workspace.IncrementalStackLimit())
+		}
+		cfg, err = Load(writeTempConfig(t, "workspace:\n  incremental_max_stacks: 0\n"))
+		if err != nil || cfg.Workspace.IncrementalStackLimit() != 0 {
+			t.Fatalf("expected incremental_max_stacks=0 to disable, got %v", err)
+		}
+		if _, err := Load(writeTempConfig(t, "workspace:\n  incremental_max_stacks: -1\n")); err == nil {
+			t.Fatalf("expected error for negative incremental_max_stacks")
+		}
+	})
+
+	t.Run("runner_plugin", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, "projects:\n  - name: cdk\n    url: https://example.com/cdk.git\n    runner:\n      command: /usr/local/bin/driftd-cdktf\n      args: [--synth]\n      pass_env: [PULUMI_ACCESS_TOKEN]\n"))
+		if err != nil {
+			t.Fatalf("load config: %v", err)
+		}
+		if r := cfg.Projects[0].Runner; r == nil || r.Command != "/usr/local/bin/driftd-cdktf" || len(r.Args) != 1 || r.PassEnv[0] != "PULUMI_ACCESS_TOKEN" {
+			t.Fatalf("unexpected runner config: %+v", cfg.Projects[0].Runner)
+		}
+		if _, err := Load(writeTempConfig(t, "projects:\n  - name: cdk\n    url: https://example.com/cdk.git\n    runner:\n      args: [--synth]\n")); err == nil {
+			t.Fatalf("expected error for runner without command")
+		}
+		if _, err := Load(writeTempConfig(t, "projects:\n  - name: cdk\n    url: https://example.com/cdk.git\n    runner:\n      command: plugin\n      pass_env: [\"A=B\"]\n")); err == nil {
+			t.Fatalf("expected error for invalid pass_env name")
+		}
+	})
+
+	t.Run("report", func(t *testing.T) {
+		reportConfig := func(report, smtp string) string {
+			return "webhook:\n  public_url: https://driftd.example.com/\nreport:\n  enabled: true\n" + report +
+				"  smtp:\n    from: driftd@example.com\n" + smtp
+		}
+		cfg, err := Load(writeTempConfig(t, reportConfig("  recipients: [ops@example.com]\n", "    host: smtp.example.com\n")))
+		if err != nil {
+			t.Fatalf("load config: %v", err)
+		}
+		r := cfg.Report
+		if r.Schedule != "0 8 * * 1" || r.Weeks != 4 || r.SMTP.TLS != "starttls" || r.SMTP.Port != 587 {
+			t.Fatalf("unexpected report defaults: %+v", r)
+		}
+		if r.PublicURL != "https://driftd.example.com" {
+			t.Fatalf("expected public_url from webhook, got %q", r.PublicURL)
+		}
+		cfg, err = Load(writeTempConfig(t, reportConfig("", "    host: smtp.example.com\n    tls: tls\n")))
+		if err != nil || cfg.Report.SMTP.Port != 465 {
+			t.Fatalf("expected port 465 for implicit TLS, got %v", err)
+		}
+		for _, bad := range []struct{ report, smtp string }{
+			{"  weeks: 5\n", "    host: smtp.example.com\n"},
+			{"  schedule: every monday\n", "    host: smtp.example.com\n"},
+			{"  recipients: [not-an-address]\n", "    host: smtp.example.com\n"},
+			{"", ""},
+			{"", "    host: smtp.example.com\n    tls: ssl\n"},
+		} {
+			if _, err := Load(writeTempConfig(t, reportConfig(bad.report, bad.smtp))); err == nil {
+				t.Fatalf("expected error for report config %+v", bad)
+			}
+		}
+	})
+
 	t.Run("clone_depth_configured", func(t *testing.T) {
 		path := writeTempConfig(t, "worker:\n  clone_depth: 5\n")
 		cfg, err := Load(path)
@@ -139,6 +331,11 @@ projects:
     ignore_paths:
       - "**/modules/**"
     cancel_inflight_on_new_trigger: false
+    terragrunt:
+      fetch_dependency_output_from_state: true
+    redact_patterns:
+      - "corp-[0-9a-f]{8}"
+    checkout_trigger_commit: true
     projects:
       - name: account-a
         path: aws/accountA
@@ -178,6 +375,15 @@ projects:
 		if len(accountA.IgnorePaths) != 1 || accountA.IgnorePaths[0] != "**/modules/**" {
 			t.Fatalf("expected ignore paths inherited, got %v", accountA.IgnorePaths)
 		}
+		if !accountA.Terragrunt.FetchDependencyOutputFromState {
+			t.Fatalf("expected terragrunt settings inherited")
+		}
+		if len(accountA.RedactPatterns) != 1 || accountA.RedactPatterns[0] != "corp-[0-9a-f]{8}" {
+			t.Fatalf("expected redact patterns inherited, got %v", accountA.RedactPatterns)
+		}
+		if !accountA.CheckoutTriggerCommit {
+			t.Fatalf("expected checkout_trigger_commit inherited")
+		}
 
 		accountB := cfg.GetProject("account-b")
 		if accountB == nil {
@@ -195,6 +401,57 @@ projects:
 		}
 	})
 
+	t.Run("branches_expand_projects", func(t *testing.T) {
+		path := writeTempConfig(t, `
+projects:
+  - name: infra
+    url: https://example.com/infra.git
+    schedule: "0 * * * *"
+    branches:
+      - release/prod
+      - release/staging
+`)
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("load config: %v", err)
+		}
+		if len(cfg.Projects) != 2 {
+			t.Fatalf("expected 2 branch projects, got %d", len(cfg.Projects))
+		}
+		prod := cfg.GetProject("infra--release-prod")
+		if prod == nil {
+			t.Fatalf("expected infra--release-prod project")
+		}
+		if prod.Branch != "release/prod" || prod.BranchGroup != "infra" || prod.Schedule != "0 * * * *" || prod.CloneURL != "https://example.com/infra.git" {
+			t.Fatalf("unexpected branch project: %+v", prod)
+		}
+		if cfg.GetProject("infra--release-staging") == nil {
+			t.Fatalf("expected infra--release-staging project")
+		}
+		if cfg.GetProject("infra") != nil {
+			t.Fatalf("multi-branch parent should not be scannable after expansion")
+		}
+	})
+
+	t.Run("branches_reject_branch_and_collisions", func(t *testing.T) {
+		for _, yaml := range []string{`
+projects:
+  - name: infra
+    url: https://example.com/infra.git
+    branch: main
+    branches: [release/prod]
+`, `
+projects:
+  - name: infra
+    url: https://example.com/infra.git
+    branches: [release/prod, release-prod]
+`} {
+			if _, err := Load(writeTempConfig(t, yaml)); err == nil {
+				t.Fatalf("expected branches validation error for %s", yaml)
+			}
+		}
+	})
+
 	t.Run("monorepo_rejects_overlapping_paths", func(t *testing.T) {
 		path := writeTempConfig(t, `
 projects:
@@ -225,6 +482,356 @@ projects:
 		}
 	})
 
+	t.Run("git_proxy_and_ca_bundle", func(t *testing.T) {
+		path := writeTempConfig(t, `
+projects:
+  - name: ghe
+    url: https://ghe.example.com/org/infra.git
+    git:
+      type: https
+      https_token_env: GHE_TOKEN
+      proxy_url: http://proxy.example.com:3128
+      proxy_password_env: PROXY_PASSWORD
+      ca_bundle_path: /etc/ssl/ghe-ca.pem
+`)
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		git := cfg.Projects[0].Git
+		if git.ProxyURL != "http://proxy.example.com:3128" || git.ProxyPasswordEnv != "PROXY_PASSWORD" || git.CABundlePath != "/etc/ssl/ghe-ca.pem" {
+			t.Fatalf("unexpected git transport settings: %+v", git)
+		}
+
+		path = writeTempConfig(t, `
+projects:
+  - name: ghe
+    url: https://ghe.example.com/org/infra.git
+    git:
+      proxy_url: ftp://proxy.example.com
+`)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "proxy_url") {
+			t.Fatalf("expected proxy_url validation error, got %v", err)
+		}
+	})
+
+	t.Run("canary_defaults_and_reserved_name", func(t *testing.T) {
+		path := writeTempConfig(t, `
+canary:
+  enabled: true
+`)
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if cfg.Canary.Interval != 5*time.Minute || cfg.Canary.Timeout != 2*time.Minute {
+			t.Fatalf("unexpected canary defaults: %+v", cfg.Canary)
+		}
+
+		path = writeTempConfig(t, `
+canary:
+  enabled: true
+  interval: 2m
+  timeout: 5m
+`)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "canary.timeout") {
+			t.Fatalf("expected canary.timeout error, got %v", err)
+		}
+
+		path = writeTempConfig(t, `
+projects:
+  - name: driftd-canary
+    url: https://example.com/infra.git
+`)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "reserved") {
+			t.Fatalf("expected reserved name error, got %v", err)
+		}
+	})
+
+	t.Run("scheduler_leader_lease_ttl", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, "listen_addr: \":8080\"\n"))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if cfg.Scheduler.LeaderLeaseTTL != 15*time.Second {
+			t.Fatalf("expected default lease ttl 15s, got %s", cfg.Scheduler.LeaderLeaseTTL)
+		}
+		path := writeTempConfig(t, `
+scheduler:
+  leader_lease_ttl: 1s
+`)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "scheduler.leader_lease_ttl") {
+			t.Fatalf("expected leader_lease_ttl error, got %v", err)
+		}
+	})
+
+	t.Run("redis_retention", func(t *testing.T) {
+		path := writeTempConfig(t, `
+redis:
+  retention:
+    stack_scans: 10m
+`)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "redis.retention") {
+			t.Fatalf("expected retention error, got %v", err)
+		}
+		path = writeTempConfig(t, `
+redis:
+  retention:
+    scans: 48h
+    drift_changes: -1
+`)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "must not be negative") {
+			t.Fatalf("expected negative retention error, got %v", err)
+		}
+	})
+
+	t.Run("scheduler_jitter_and_stagger", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, "listen_addr: \":8080\"\n"))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if cfg.Scheduler.MaxJitter != 20*time.Second || cfg.Scheduler.StaggerWindow != 0 {
+			t.Fatalf("expected 20s jitter and no stagger, got %s and %s", cfg.Scheduler.MaxJitter, cfg.Scheduler.StaggerWindow)
+		}
+		path := writeTempConfig(t, `
+scheduler:
+  stagger_window: -1m
+`)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "scheduler.stagger_window") {
+			t.Fatalf("expected stagger_window error, got %v", err)
+		}
+	})
+
+	t.Run("workspace_prune", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, `
+workspace:
+  prune:
+    enabled: true
+    failed_after: -1s
+`))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		p := cfg.Workspace.Prune
+		if p.Interval != time.Hour || p.FailedAfter != -time.Second || p.CompletedAfter != 7*24*time.Hour {
+			t.Fatalf("unexpected prune defaults: %+v", p)
+		}
+		path := writeTempConfig(t, `
+workspace:
+  prune:
+    enabled: true
+    interval: 10s
+`)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "workspace.prune.interval") {
+			t.Fatalf("expected interval error, got %v", err)
+		}
+	})
+
+	t.Run("warehouse_export", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, `
+warehouse_export:
+  enabled: true
+  destination: s3://analytics/driftd
+`))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		w := cfg.WarehouseExport
+		if w.Interval != time.Hour || w.S3.Region != "us-east-1" || w.S3.AccessKeyIDEnv != "AWS_ACCESS_KEY_ID" {
+			t.Fatalf("unexpected warehouse export defaults: %+v", w)
+		}
+		path := writeTempConfig(t, `
+warehouse_export:
+  enabled: true
+  destination: gs://analytics
+`)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "warehouse_export.destination") {
+			t.Fatalf("expected destination error, got %v", err)
+		}
+	})
+
+	t.Run("credential_check", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, `
+projects:
+  - name: infra
+    url: https://github.com/org/infra.git
+    credential_check:
+      mode: Strict
+      aws: {}
+    projects:
+      - name: app
+        path: app
+`))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		c := cfg.Projects[0].CredentialCheck
+		if !c.Strict() || c.CacheFor != time.Hour || len(c.AWS.Actions) != len(DefaultCredentialCheckActions) {
+			t.Fatalf("unexpected credential check defaults: %+v", c)
+		}
+		path := writeTempConfig(t, `
+projects:
+  - name: infra
+    url: https://github.com/org/infra.git
+    credential_check:
+      mode: warn
+`)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "aws or command is required") {
+			t.Fatalf("expected missing check error, got %v", err)
+		}
+	})
+
+	t.Run("project_webhook_secret", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, `
+webhook:
+  enabled: true
+projects:
+  - name: infra
+    url: https://github.com/org/infra.git
+    webhook_secret_env: INFRA_HOOK
+    projects:
+      - name: app
+        path: app
+`))
+		if err != nil {
+			t.Fatalf("expected a project webhook secret to satisfy webhook auth: %v", err)
+		}
+		if cfg.Projects[0].WebhookSecretEnv != "INFRA_HOOK" {
+			t.Fatalf("webhook_secret_env not inherited: %+v", cfg.Projects[0])
+		}
+	})
+
+	t.Run("scan_retry", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, `
+scan_retry:
+  enabled: true
+`))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if r := cfg.ScanRetry; r.After != 10*time.Minute || r.MaxPerDay != 3 {
+			t.Fatalf("unexpected scan retry defaults: %+v", r)
+		}
+		path := writeTempConfig(t, `
+scan_retry:
+  enabled: true
+  after: -1m
+`)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "scan_retry.after") {
+			t.Fatalf("expected after error, got %v", err)
+		}
+	})
+
+	t.Run("federation", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, `
+federation:
+  enabled: true
+  peers:
+    - name: payments
+      url: https://driftd.payments.example.com/
+      token_env: PAYMENTS_DRIFTD_TOKEN
+`))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		f := cfg.Federation
+		if f.Name != "local" || f.Timeout != 10*time.Second || f.CacheFor != time.Minute {
+			t.Fatalf("unexpected federation defaults: %+v", f)
+		}
+		if peer := f.Peers[0]; peer.URL != "https://driftd.payments.example.com" || peer.TokenHeader != "X-API-Token" {
+			t.Fatalf("unexpected peer: %+v", peer)
+		}
+		path := writeTempConfig(t, `
+federation:
+  enabled: true
+  name: payments
+  peers:
+    - name: payments
+      url: driftd.payments.example.com
+`)
+		_, err = Load(path)
+		if err == nil || !strings.Contains(err.Error(), "not unique") || !strings.Contains(err.Error(), "http(s) URL") {
+			t.Fatalf("expected peer errors, got %v", err)
+		}
+	})
+
+	t.Run("blame_drift", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, `
+projects:
+  - name: infra
+    url: https://example.com/infra.git
+    blame_drift: true
+    projects:
+      - name: prod
+        path: envs/prod
+`))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if len(cfg.Projects) != 1 || !cfg.Projects[0].BlameDrift {
+			t.Fatalf("expected monorepo child to inherit blame_drift, got %+v", cfg.Projects)
+		}
+	})
+
+	t.Run("terraform_args", func(t *testing.T) {
+		path := writeTempConfig(t, `
+projects:
+  - name: infra
+    url: https://example.com/infra.git
+    terraform:
+      init_args: ["-backend-config=backend.hcl"]
+      stacks:
+        - pattern: "envs/prod/**"
+          init_args: ["-reconfigure"]
+    projects:
+      - name: infra-app
+        path: app
+`)
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		initArgs, _ := cfg.GetProject("infra-app").StackArgs("envs/prod/vpc")
+		if strings.Join(initArgs, " ") != "-backend-config=backend.hcl -reconfigure" {
+			t.Fatalf("expected monorepo projects to inherit terraform args, got %v", initArgs)
+		}
+
+		path = writeTempConfig(t, `
+projects:
+  - name: infra
+    url: https://example.com/infra.git
+    terraform:
+      stacks:
+        - pattern: "envs/**"
+          plan_args: ["-out=plan.bin"]
+`)
+		if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "terraform.stacks[0].plan_args") {
+			t.Fatalf("expected plan_args error, got %v", err)
+		}
+	})
+
+	t.Run("noise_reduction", func(t *testing.T) {
+		path := writeTempConfig(t, `
+projects:
+  - name: infra
+    url: https://example.com/infra.git
+    noise_reduction:
+      json: true
+      ordering: true
+    projects:
+      - name: infra-app
+        path: app
+`)
+		cfg, err := Load(path)
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		got := cfg.GetProject("infra-app").NoiseReduction
+		if got != (NoiseReductionConfig{JSON: true, Ordering: true}) {
+			t.Fatalf("expected monorepo projects to inherit noise_reduction, got %+v", got)
+		}
+	})
+
 	t.Run("monorepo_rejects_duplicate_expanded_names", func(t *testing.T) {
 		path := writeTempConfig(t, `
 projects:
@@ -240,6 +847,308 @@ projects:
 			t.Fatalf("expected duplicate expanded name error")
 		}
 	})
+
+	t.Run("scan_limits", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, `
+scan_limits:
+  per_hour:
+    webhook: 10
+    manual: 4
+projects:
+  - name: infra-monorepo
+    url: https://example.com/infra.git
+    scan_limits:
+      per_hour:
+        manual: 2
+    projects:
+      - name: aws
+        path: aws
+`))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if cfg.ScanLimits.PerHour["webhook"] != 10 || cfg.ScanLimits.PerHour["manual"] != 4 {
+			t.Fatalf("unexpected global scan limits: %+v", cfg.ScanLimits)
+		}
+		project := cfg.GetProject("aws")
+		if project == nil || project.ScanLimits.PerHour["manual"] != 2 {
+			t.Fatalf("expected monorepo project to inherit scan_limits, got %+v", project)
+		}
+
+		if _, err := Load(writeTempConfig(t, "scan_limits:\n  per_hour:\n    manual: -1\n")); err == nil {
+			t.Fatalf("expected error for negative scan limit")
+		}
+	})
+
+	t.Run("scan_defaults", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, `
+worker:
+  retry_once: true
+scan_defaults:
+  triggers:
+    webhook:
+      retries: 2
+      priority: high
+    scheduled:
+      retries: 0
+      priority: low
+      concurrency: 10
+projects:
+  - name: infra-monorepo
+    url: https://example.com/infra.git
+    scan_defaults:
+      triggers:
+        scheduled:
+          concurrency: 3
+    projects:
+      - name: aws
+        path: aws
+`))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		project := cfg.GetProject("aws")
+		if project == nil {
+			t.Fatalf("expected monorepo project aws")
+		}
+		if got := cfg.ScanSettings(project, "webhook"); got != (ScanSettings{Retries: 2, Priority: ScanPriorityHigh}) {
+			t.Fatalf("webhook settings = %+v", got)
+		}
+		if got := cfg.ScanSettings(project, "scheduled"); got != (ScanSettings{Retries: 0, Priority: ScanPriorityLow, Concurrency: 3}) {
+			t.Fatalf("scheduled settings = %+v", got)
+		}
+		if got := cfg.ScanSettings(project, "manual"); got != (ScanSettings{Retries: 1}) {
+			t.Fatalf("manual settings = %+v", got)
+		}
+
+		for _, bad := range []string{
+			"scan_defaults:\n  triggers:\n    webhook:\n      priority: urgent\n",
+			"scan_defaults:\n  triggers:\n    webhook:\n      retries: -1\n",
+			"projects:\n  - name: a\n    url: https://example.com/a.git\n    scan_defaults:\n      triggers:\n        manual:\n          concurrency: -2\n",
+		} {
+			if _, err := Load(writeTempConfig(t, bad)); err == nil || !strings.Contains(err.Error(), "scan_defaults") {
+				t.Fatalf("expected scan_defaults error for %q, got %v", bad, err)
+			}
+		}
+	})
+
+	t.Run("spaces", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, `
+data_dir: /var/lib/driftd
+spaces:
+  - name: staging
+    hosts: [Drift-Staging.example.com]
+    redis_db: 1
+    projects:
+      - name: infra
+        url: https://example.com/infra.git
+  - name: prod
+    hosts: [drift.example.com]
+    redis_db: 2
+    data_subdir: production
+    projects:
+      - name: infra
+        url: https://example.com/infra-prod.git
+`))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if len(cfg.Projects) != 0 {
+			t.Fatalf("expected no top-level projects, got %d", len(cfg.Projects))
+		}
+		staging := cfg.Spaces[0]
+		if staging.Hosts[0] != "drift-staging.example.com" || staging.DataSubdir != "staging" {
+			t.Fatalf("unexpected staging space: %+v", staging)
+		}
+
+		prod, err := cfg.Space("prod")
+		if err != nil {
+			t.Fatalf("space: %v", err)
+		}
+		if prod.Redis.DB != 2 || prod.DataDir != filepath.Join("/var/lib/driftd", "production") || len(prod.Spaces) != 0 {
+			t.Fatalf("unexpected prod config: db=%d data_dir=%s", prod.Redis.DB, prod.DataDir)
+		}
+		if p := prod.GetProject("infra"); p == nil || p.URL != "https://example.com/infra-prod.git" {
+			t.Fatalf("expected prod infra project, got %+v", p)
+		}
+		if _, err := cfg.Space("dev"); err == nil {
+			t.Fatalf("expected error for unknown space")
+		}
+
+		for _, bad := range []string{
+			"projects:\n  - name: a\n    url: https://example.com/a.git\nspaces:\n  - name: s\n    hosts: [s.example.com]\n    redis_db: 1\n",
+			"spaces:\n  - name: s\n    redis_db: 1\n",
+			"spaces:\n  - name: s\n    hosts: [s.example.com]\n",
+			"spaces:\n  - name: s\n    hosts: [s.example.com]\n    redis_db: 1\n    key_prefix: s\n",
+			"spaces:\n  - name: a\n    hosts: [a.example.com]\n    redis_db: 1\n  - name: b\n    hosts: [b.example.com]\n    redis_db: 1\n",
+			"spaces:\n  - name: a\n    hosts: [x.example.com]\n    redis_db: 1\n  - name: b\n    hosts: [X.example.com]\n    redis_db: 2\n",
+			"spaces:\n  - name: a\n    hosts: [a.example.com]\n    redis_db: 1\n    data_subdir: ../a\n",
+			"spaces:\n  - name: a\n    hosts: [a.example.com]\n    redis_db: 1\n    projects:\n      - name: bad name\n        url: https://example.com/a.git\n",
+		} {
+			if _, err := Load(writeTempConfig(t, bad)); err == nil {
+				t.Fatalf("expected error for %q", bad)
+			}
+		}
+
+		natsCfg, err := Load(writeTempConfig(t, `
+queue:
+  backend: nats
+  nats:
+    url: nats://localhost:4222
+spaces:
+  - name: staging
+    hosts: [drift-staging.example.com]
+`))
+		if err != nil {
+			t.Fatalf("load nats: %v", err)
+		}
+		space, err := natsCfg.Space("staging")
+		if err != nil {
+			t.Fatalf("space: %v", err)
+		}
+		if space.Queue.NATS.Prefix != "driftd_staging" {
+			t.Fatalf("nats prefix = %q, want driftd_staging", space.Queue.NATS.Prefix)
+		}
+	})
+
+	t.Run("severity", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, `
+severity:
+  actions:
+    delete: 20
+  resource_types:
+    "aws_iam_*": 5
+  tags:
+    tier=critical: 4
+`))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if cfg.Severity.Actions["delete"] != 20 || cfg.Severity.ResourceTypes["aws_iam_*"] != 5 || cfg.Severity.Tags["tier=critical"] != 4 {
+			t.Fatalf("unexpected severity policy: %+v", cfg.Severity)
+		}
+
+		for _, bad := range []string{
+			"severity:\n  actions:\n    explode: 1\n",
+			"severity:\n  actions:\n    delete: -1\n",
+			"severity:\n  resource_types:\n    \"aws_[\": 2\n",
+			"severity:\n  tags:\n    critical: 2\n",
+		} {
+			if _, err := Load(writeTempConfig(t, bad)); err == nil {
+				t.Fatalf("expected error for %q", bad)
+			}
+		}
+	})
+
+	t.Run("gate", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, `
+gate:
+  environments: [prod]
+  tags: [tier=critical]
+  max_age: 24h
+  fail_on_errors: true
+`))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if cfg.Gate.MaxAge != 24*time.Hour || !cfg.Gate.FailOnErrors {
+			t.Fatalf("unexpected gate policy: %+v", cfg.Gate)
+		}
+		if !cfg.Gate.Covers("prod", nil) || !cfg.Gate.Covers("", map[string]string{"tier": "critical"}) || cfg.Gate.Covers("dev", map[string]string{"tier": "low"}) {
+			t.Fatalf("unexpected gate coverage: %+v", cfg.Gate)
+		}
+
+		for _, bad := range []string{
+			"gate:\n  tags: [critical]\n",
+			"gate:\n  max_age: -1h\n",
+		} {
+			if _, err := Load(writeTempConfig(t, bad)); err == nil {
+				t.Fatalf("expected error for %q", bad)
+			}
+		}
+	})
+
+	t.Run("project tool versions", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, `
+projects:
+  - name: infra
+    url: https://example.com/infra.git
+    terraform_version: 1.6.2
+    terragrunt_version: 0.56.4
+    projects:
+      - name: infra-prod
+        path: envs/prod
+`))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		project := cfg.GetProject("infra-prod")
+		if project == nil || project.TerraformVersion != "1.6.2" || project.TerragruntVersion != "0.56.4" {
+			t.Fatalf("expected monorepo project to inherit default versions, got %+v", project)
+		}
+
+		bad := "projects:\n  - name: infra\n    url: https://example.com/infra.git\n    terraform_version: latest\n"
+		if _, err := Load(writeTempConfig(t, bad)); err == nil {
+			t.Fatal("expected error for non-semver terraform_version")
+		}
+	})
+
+	t.Run("skip untouched days", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, "projects:\n  - name: infra\n    url: https://example.com/infra.git\n    skip_untouched_days: 90\n"))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if got := cfg.GetProject("infra").SkipUntouchedDays; got != 90 {
+			t.Fatalf("expected skip_untouched_days 90, got %d", got)
+		}
+		if _, err := Load(writeTempConfig(t, "projects:\n  - name: infra\n    url: https://example.com/infra.git\n    skip_untouched_days: -1\n")); err == nil {
+			t.Fatal("expected error for negative skip_untouched_days")
+		}
+	})
+
+	t.Run("storage", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, "redis:\n  addr: localhost:6379\n"))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if cfg.Storage.Fsync != "always" || cfg.Storage.FsyncInterval != time.Second || cfg.Storage.CacheTTL != 5*time.Second {
+			t.Fatalf("unexpected storage defaults: %+v", cfg.Storage)
+		}
+
+		cfg, err = Load(writeTempConfig(t, "storage:\n  fsync: interval\n  fsync_interval: 250ms\n  cache_ttl: -1s\n"))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if cfg.Storage.Fsync != "interval" || cfg.Storage.FsyncInterval != 250*time.Millisecond || cfg.Storage.CacheTTL >= 0 {
+			t.Fatalf("unexpected storage config: %+v", cfg.Storage)
+		}
+
+		if _, err := Load(writeTempConfig(t, "storage:\n  fsync: sometimes\n")); err == nil {
+			t.Fatalf("expected error for unknown fsync policy")
+		}
+	})
+
+	t.Run("access_log", func(t *testing.T) {
+		cfg, err := Load(writeTempConfig(t, "redis:\n  addr: localhost:6379\n"))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if cfg.AccessLog.Format != "text" || cfg.AccessLog.Path != "" || len(cfg.AccessLog.ExcludePaths) != 2 {
+			t.Fatalf("unexpected access log defaults: %+v", cfg.AccessLog)
+		}
+
+		cfg, err = Load(writeTempConfig(t, "access_log:\n  format: json\n  path: /var/log/driftd/access.log\n  exclude_paths: []\n"))
+		if err != nil {
+			t.Fatalf("load: %v", err)
+		}
+		if cfg.AccessLog.Format != "json" || len(cfg.AccessLog.ExcludePaths) != 0 {
+			t.Fatalf("unexpected access log config: %+v", cfg.AccessLog)
+		}
+
+		if _, err := Load(writeTempConfig(t, "access_log:\n  format: xml\n")); err == nil {
+			t.Fatalf("expected error for unknown access log format")
+		}
+	})
 }
 
 func writeTempConfig(t *testing.T, contents string) string {
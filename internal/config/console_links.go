@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ConsoleLink is a URL template rendered on the stack page, such as the
+// cloud console view of a stack's account or of one drifted resource. URL
+// placeholders are written {name}:
+//
+//	{project} {stack}          project name and stack path
+//	{tag.<key>}                a stack tag (see Stack Tags)
+//	{var.<name>}               a string variable from the stack's tfvars or
+//	                           terragrunt inputs
+//	{address} {type} {name}    the drifted resource's address, type and name
+//
+// A link using a resource placeholder, or listing ResourceTypes, is shown
+// next to each matching drifted resource; any other link is shown once for
+// the stack. Links with a placeholder the stack cannot resolve are hidden.
+type ConsoleLink struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	// ResourceTypes limits a resource link to these resource types. Entries
+	// may be globs such as "aws_security_group*".
+	ResourceTypes []string `yaml:"resource_types,omitempty"`
+}
+
+var consoleLinkPlaceholder = regexp.MustCompile(`\{([^{}]*)\}`)
+
+var consoleLinkResourceKeys = map[string]bool{"address": true, "type": true, "name": true}
+
+func validateConsoleLinks(links []ConsoleLink) error {
+	for i, link := range links {
+		if strings.TrimSpace(link.Name) == "" {
+			return fmt.Errorf("console_links[%d]: name is required", i)
+		}
+		u, err := url.Parse(consoleLinkPlaceholder.ReplaceAllString(link.URL, "x"))
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("console_links[%d] (%s): url must be an absolute http(s) URL", i, link.Name)
+		}
+		for _, m := range consoleLinkPlaceholder.FindAllStringSubmatch(link.URL, -1) {
+			if !validConsoleLinkKey(m[1]) {
+				return fmt.Errorf("console_links[%d] (%s): unknown placeholder {%s}", i, link.Name, m[1])
+			}
+		}
+		for _, pattern := range link.ResourceTypes {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("console_links[%d] (%s): invalid resource type %q", i, link.Name, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+func validConsoleLinkKey(key string) bool {
+	switch {
+	case key == "project" || key == "stack" || consoleLinkResourceKeys[key]:
+		return true
+	case strings.HasPrefix(key, "tag."):
+		return consoleLinkTagKey.MatchString(strings.TrimPrefix(key, "tag."))
+	case strings.HasPrefix(key, "var."):
+		return consoleLinkVarName.MatchString(strings.TrimPrefix(key, "var."))
+	}
+	return false
+}
+
+var (
+	consoleLinkTagKey  = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+	consoleLinkVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+)
+
+// PerResource reports whether the link is shown next to each drifted
+// resource rather than once for the stack.
+func (l ConsoleLink) PerResource() bool {
+	if len(l.ResourceTypes) > 0 {
+		return true
+	}
+	for _, m := range consoleLinkPlaceholder.FindAllStringSubmatch(l.URL, -1) {
+		if consoleLinkResourceKeys[m[1]] {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesResourceType reports whether a resource link applies to
+// resourceType. Links without ResourceTypes apply to every type.
+func (l ConsoleLink) MatchesResourceType(resourceType string) bool {
+	if len(l.ResourceTypes) == 0 {
+		return true
+	}
+	for _, pattern := range l.ResourceTypes {
+		if ok, _ := path.Match(pattern, resourceType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Expand fills the URL's placeholders from values, keyed like the
+// placeholders ("stack", "tag.region", "var.account_id"). Values are
+// path-escaped. Tag keys are matched case-insensitively, like stack tags.
+// It reports false when a placeholder has no value.
+func (l ConsoleLink) Expand(values map[string]string) (string, bool) {
+	ok := true
+	out := consoleLinkPlaceholder.ReplaceAllStringFunc(l.URL, func(m string) string {
+		key := m[1 : len(m)-1]
+		if strings.HasPrefix(key, "tag.") {
+			key = strings.ToLower(key)
+		}
+		value, found := values[key]
+		if !found || value == "" {
+			ok = false
+			return ""
+		}
+		return url.PathEscape(value)
+	})
+	if !ok {
+		return "", false
+	}
+	return out, true
+}
+
+// ConsoleLinkVars lists the variable names the links reference, so runs
+// only record the tfvars that links need.
+func ConsoleLinkVars(links []ConsoleLink) []string {
+	seen := map[string]struct{}{}
+	var names []string
+	for _, link := range links {
+		for _, m := range consoleLinkPlaceholder.FindAllStringSubmatch(link.URL, -1) {
+			name, ok := strings.CutPrefix(m[1], "var.")
+			if !ok {
+				continue
+			}
+			if _, dup := seen[name]; !dup {
+				seen[name] = struct{}{}
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func copyConsoleLinks(links []ConsoleLink) []ConsoleLink {
+	if links == nil {
+		return nil
+	}
+	out := make([]ConsoleLink, len(links))
+	for i, link := range links {
+		out[i] = link
+		out[i].ResourceTypes = copyStringSlice(link.ResourceTypes)
+	}
+	return out
+}
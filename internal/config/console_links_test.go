@@ -0,0 +1,60 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConsoleLinkExpand(t *testing.T) {
+	link := ConsoleLink{Name: "EC2", URL: "https://{var.region}.console.aws.amazon.com/ec2/home?region={var.region}#SecurityGroups:search={name}"}
+	if !link.PerResource() {
+		t.Fatal("expected a link using {name} to be per resource")
+	}
+	got, ok := link.Expand(map[string]string{"var.region": "us-east-1", "name": "web sg"})
+	if !ok || got != "https://us-east-1.console.aws.amazon.com/ec2/home?region=us-east-1#SecurityGroups:search=web%20sg" {
+		t.Fatalf("unexpected expansion %q (%v)", got, ok)
+	}
+	if _, ok := link.Expand(map[string]string{"name": "web"}); ok {
+		t.Fatal("expected a missing variable to hide the link")
+	}
+
+	account := ConsoleLink{Name: "Account", URL: "https://console.aws.amazon.com/?account={tag.Account}"}
+	if account.PerResource() {
+		t.Fatal("expected a link without resource placeholders to be per stack")
+	}
+	if got, ok := account.Expand(map[string]string{"tag.account": "1234"}); !ok || !strings.HasSuffix(got, "account=1234") {
+		t.Fatalf("expected tag keys to match case-insensitively, got %q (%v)", got, ok)
+	}
+
+	typed := ConsoleLink{Name: "SG", URL: "https://example.com/", ResourceTypes: []string{"aws_security_group*"}}
+	if !typed.PerResource() || !typed.MatchesResourceType("aws_security_group_rule") || typed.MatchesResourceType("aws_instance") {
+		t.Fatal("unexpected resource type matching")
+	}
+
+	vars := ConsoleLinkVars([]ConsoleLink{link, account, {Name: "x", URL: "https://x/{var.account_id}/{var.region}"}})
+	if !reflect.DeepEqual(vars, []string{"account_id", "region"}) {
+		t.Fatalf("unexpected link vars %v", vars)
+	}
+}
+
+func TestConsoleLinksValidation(t *testing.T) {
+	for _, tc := range []struct {
+		link ConsoleLink
+		err  string
+	}{
+		{ConsoleLink{URL: "https://example.com"}, "name is required"},
+		{ConsoleLink{Name: "x", URL: "/relative/{stack}"}, "absolute http(s) URL"},
+		{ConsoleLink{Name: "x", URL: "javascript:alert(1)"}, "absolute http(s) URL"},
+		{ConsoleLink{Name: "x", URL: "https://example.com/{region}"}, "unknown placeholder {region}"},
+		{ConsoleLink{Name: "x", URL: "https://example.com/", ResourceTypes: []string{"aws_["}}, "invalid resource type"},
+	} {
+		err := validateConsoleLinks([]ConsoleLink{tc.link})
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%+v: expected error containing %q, got %v", tc.link, tc.err, err)
+		}
+	}
+	if err := validateConsoleLinks([]ConsoleLink{{Name: "x", URL: "https://{var.region}.console.aws.amazon.com/{stack}?t={tag.team}&a={address}"}}); err != nil {
+		t.Fatalf("expected valid link, got %v", err)
+	}
+}
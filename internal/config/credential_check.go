@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Credential check modes.
+const (
+	CredentialCheckOff    = "off"
+	CredentialCheckWarn   = "warn"
+	CredentialCheckStrict = "strict"
+)
+
+const defaultCredentialCheckCacheFor = time.Hour
+
+// DefaultCredentialCheckActions are the AWS write actions simulated when
+// credential_check.aws.actions is empty. A plan-only principal should be
+// denied all of them.
+var DefaultCredentialCheckActions = []string{
+	"s3:PutObject",
+	"s3:DeleteBucket",
+	"ec2:TerminateInstances",
+	"iam:CreateAccessKey",
+	"iam:AttachRolePolicy",
+}
+
+// CredentialCheckConfig checks, before a project's stacks are planned, that
+// the worker's cloud credentials cannot make changes. In warn mode an
+// overly-privileged principal is logged; in strict mode its stack scans
+// fail instead of running.
+type CredentialCheckConfig struct {
+	// Mode is off (default), warn or strict.
+	Mode string `yaml:"mode,omitempty"`
+	// AWS simulates canary write actions against the caller's IAM policies.
+	AWS *AWSCredentialCheckConfig `yaml:"aws,omitempty"`
+	// Command runs instead of a built-in check. Exit 0 means the
+	// credentials are read-only; any other exit status means they are not,
+	// with the output as the reason.
+	Command []string `yaml:"command,omitempty"`
+	// CacheFor is how long a worker reuses a result for the project.
+	// Default 1h.
+	CacheFor time.Duration `yaml:"cache_for,omitempty"`
+}
+
+// AWSCredentialCheckConfig configures the AWS simulate-principal-policy check.
+type AWSCredentialCheckConfig struct {
+	// Actions are the write actions that must be denied. Defaults to
+	// DefaultCredentialCheckActions.
+	Actions []string `yaml:"actions,omitempty"`
+	// PrincipalARN is the IAM user or role to simulate. Defaults to the
+	// role behind "aws sts get-caller-identity"; set it for roles with a
+	// path, which an assumed-role ARN does not include.
+	PrincipalARN string `yaml:"principal_arn,omitempty"`
+}
+
+// Enabled reports whether the check runs at all.
+func (c CredentialCheckConfig) Enabled() bool {
+	return c.Mode == CredentialCheckWarn || c.Mode == CredentialCheckStrict
+}
+
+// Strict reports whether privileged credentials block scans.
+func (c CredentialCheckConfig) Strict() bool {
+	return c.Mode == CredentialCheckStrict
+}
+
+func (c *CredentialCheckConfig) applyDefaults() error {
+	c.Mode = strings.ToLower(strings.TrimSpace(c.Mode))
+	switch c.Mode {
+	case "":
+		c.Mode = CredentialCheckOff
+	case CredentialCheckOff, CredentialCheckWarn, CredentialCheckStrict:
+	default:
+		return fmt.Errorf("credential_check.mode must be off, warn or strict")
+	}
+	if c.CacheFor < 0 {
+		return fmt.Errorf("credential_check.cache_for must not be negative")
+	}
+	if c.CacheFor == 0 {
+		c.CacheFor = defaultCredentialCheckCacheFor
+	}
+	if !c.Enabled() {
+		return nil
+	}
+	if c.AWS != nil && len(c.Command) > 0 {
+		return fmt.Errorf("credential_check: set aws or command, not both")
+	}
+	if c.AWS == nil && len(c.Command) == 0 {
+		return fmt.Errorf("credential_check: aws or command is required")
+	}
+	if len(c.Command) > 0 && strings.TrimSpace(c.Command[0]) == "" {
+		return fmt.Errorf("credential_check.command is empty")
+	}
+	if c.AWS != nil {
+		if len(c.AWS.Actions) == 0 {
+			c.AWS.Actions = copyStringSlice(DefaultCredentialCheckActions)
+		}
+		for _, action := range c.AWS.Actions {
+			if !strings.Contains(action, ":") {
+				return fmt.Errorf("credential_check.aws.actions: %q is not service:Action", action)
+			}
+		}
+	}
+	return nil
+}
+
+func copyCredentialCheck(c CredentialCheckConfig) CredentialCheckConfig {
+	out := c
+	out.Command = copyStringSlice(c.Command)
+	if c.AWS != nil {
+		aws := *c.AWS
+		aws.Actions = copyStringSlice(c.AWS.Actions)
+		out.AWS = &aws
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// envPlaceholder captures the environment name from one path segment.
+const envPlaceholder = "<env>"
+
+// EnvironmentMapping assigns stacks to an environment by stack path. Pattern
+// is a glob relative to the project root where "*" matches within a path
+// segment and "**" matches any number of segments. "<env>" in the pattern
+// captures the environment name; otherwise Name is used.
+type EnvironmentMapping struct {
+	Pattern string `yaml:"pattern"`
+	Name    string `yaml:"name"`
+
+	re *regexp.Regexp
+}
+
+func (m *EnvironmentMapping) compile() error {
+	pattern := strings.Trim(strings.TrimSpace(m.Pattern), "/")
+	if pattern == "" {
+		return fmt.Errorf("pattern is required")
+	}
+	if n := strings.Count(pattern, envPlaceholder); n > 1 {
+		return fmt.Errorf("pattern %q has more than one %s", m.Pattern, envPlaceholder)
+	} else if n == 0 && strings.TrimSpace(m.Name) == "" {
+		return fmt.Errorf("pattern %q needs %s or a name", m.Pattern, envPlaceholder)
+	}
+	re, err := regexp.Compile(environmentPatternRegexp(pattern))
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %v", m.Pattern, err)
+	}
+	m.re = re
+	return nil
+}
+
+// match returns the environment for stackPath, or "" when the mapping does
+// not apply.
+func (m *EnvironmentMapping) match(stackPath string) string {
+	re := m.re
+	if re == nil {
+		// Configs built in code skip applyDefaults; compile a copy so
+		// concurrent readers never write to the shared mapping.
+		compiled := *m
+		if err := compiled.compile(); err != nil {
+			return ""
+		}
+		re = compiled.re
+	}
+	sub := re.FindStringSubmatch(strings.Trim(stackPath, "/"))
+	if sub == nil {
+		return ""
+	}
+	if len(sub) > 1 && sub[1] != "" {
+		return sub[1]
+	}
+	return strings.TrimSpace(m.Name)
+}
+
+// environmentPatternRegexp translates a glob into an anchored regexp.
+func environmentPatternRegexp(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	var b strings.Builder
+	b.WriteString("^")
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		if seg == "**" {
+			switch {
+			case len(segments) == 1:
+				b.WriteString(".*")
+			case last:
+				b.WriteString("(?:/.*)?")
+			case i == 0:
+				b.WriteString("(?:.*/)?")
+			default:
+				b.WriteString("/(?:.*/)?")
+			}
+			continue
+		}
+		if i > 0 && segments[i-1] != "**" {
+			b.WriteString("/")
+		}
+		for j := 0; j < len(seg); j++ {
+			switch {
+			case strings.HasPrefix(seg[j:], envPlaceholder):
+				b.WriteString("([^/]+)")
+				j += len(envPlaceholder) - 1
+			case seg[j] == '*':
+				b.WriteString("[^/]*")
+			case seg[j] == '?':
+				b.WriteString("[^/]")
+			default:
+				b.WriteString(regexp.QuoteMeta(seg[j : j+1]))
+			}
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// StackEnvironment returns the environment of the first mapping matching
+// stackPath, or "" when none does.
+func (c *Config) StackEnvironment(stackPath string) string {
+	if c == nil {
+		return ""
+	}
+	for i := range c.Environments {
+		if env := c.Environments[i].match(stackPath); env != "" {
+			return env
+		}
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStackEnvironment(t *testing.T) {
+	cfg := &Config{Environments: []EnvironmentMapping{
+		{Pattern: "live/prod-*/**", Name: "production"},
+		{Pattern: "envs/<env>/**"},
+		{Pattern: "**/stage-<env>"},
+		{Pattern: "accounts/*/<env>/**"},
+	}}
+	tests := map[string]string{
+		"live/prod-eu/vpc":          "production",
+		"envs/prod":                 "prod",
+		"envs/dev/app/db":           "dev",
+		"teams/a/stage-qa":          "qa",
+		"stage-uat":                 "uat",
+		"accounts/123/staging/net":  "staging",
+		"accounts/staging":          "",
+		"environments/prod/app":     "",
+		"live/prod/vpc":             "",
+		"teams/a/stage-qa/extra/db": "",
+	}
+	for path, want := range tests {
+		if got := cfg.StackEnvironment(path); got != want {
+			t.Errorf("StackEnvironment(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestEnvironmentMappingValidation(t *testing.T) {
+	for _, tc := range []struct {
+		mapping EnvironmentMapping
+		err     string
+	}{
+		{EnvironmentMapping{}, "pattern is required"},
+		{EnvironmentMapping{Pattern: "envs/*"}, "needs <env> or a name"},
+		{EnvironmentMapping{Pattern: "<env>/<env>"}, "more than one"},
+	} {
+		_, err := applyDefaults(&Config{Environments: []EnvironmentMapping{tc.mapping}})
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("pattern %q: expected error containing %q, got %v", tc.mapping.Pattern, tc.err, err)
+		}
+	}
+}
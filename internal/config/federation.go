@@ -0,0 +1,78 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultFederationName     = "local"
+	defaultFederationTimeout  = 10 * time.Second
+	defaultFederationCacheFor = time.Minute
+)
+
+// FederationConfig merges the overviews of peer driftd instances, e.g. one
+// per business unit, into a read-only overview on this one.
+type FederationConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Name labels this instance's projects in the merged overview.
+	// Default "local".
+	Name  string           `yaml:"name"`
+	Peers []FederationPeer `yaml:"peers"`
+	// Timeout bounds each request to a peer. Default 10s.
+	Timeout time.Duration `yaml:"timeout"`
+	// CacheFor is how long a peer's overview is reused. Default 1m.
+	CacheFor time.Duration `yaml:"cache_for"`
+}
+
+// FederationPeer is another driftd instance whose overview is merged in.
+type FederationPeer struct {
+	Name string `yaml:"name"`
+	// URL is the peer's base URL; project links point there.
+	URL string `yaml:"url"`
+	// TokenEnv names the variable holding the peer's API read token.
+	TokenEnv string `yaml:"token_env"`
+	// TokenHeader is the peer's api_auth.token_header. Default X-API-Token.
+	TokenHeader string `yaml:"token_header"`
+}
+
+func applyFederationDefaults(cfg *Config) []error {
+	f := &cfg.Federation
+	if !f.Enabled {
+		return nil
+	}
+	if f.Name = strings.TrimSpace(f.Name); f.Name == "" {
+		f.Name = defaultFederationName
+	}
+	if f.Timeout == 0 {
+		f.Timeout = defaultFederationTimeout
+	}
+	if f.CacheFor == 0 {
+		f.CacheFor = defaultFederationCacheFor
+	}
+	var errs []error
+	if f.Timeout < 0 || f.CacheFor < 0 {
+		errs = append(errs, fmt.Errorf("federation.timeout and federation.cache_for must be positive"))
+	}
+	seen := map[string]bool{f.Name: true}
+	for i := range f.Peers {
+		peer := &f.Peers[i]
+		peer.Name = strings.TrimSpace(peer.Name)
+		peer.URL = strings.TrimRight(strings.TrimSpace(peer.URL), "/")
+		if peer.TokenHeader == "" {
+			peer.TokenHeader = "X-API-Token"
+		}
+		switch {
+		case peer.Name == "":
+			errs = append(errs, fmt.Errorf("federation.peers[%d].name is required", i))
+		case seen[peer.Name]:
+			errs = append(errs, fmt.Errorf("federation.peers[%d].name %q is not unique", i, peer.Name))
+		}
+		seen[peer.Name] = true
+		if !strings.HasPrefix(peer.URL, "https://") && !strings.HasPrefix(peer.URL, "http://") {
+			errs = append(errs, fmt.Errorf("federation.peers[%d].url must be an http(s) URL", i))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GateConfig is the deployment gate policy that CD pipelines check through
+// GET /api/projects/{project}/gate. A project fails the gate when any stack
+// the policy covers is drifted, is older than MaxAge, or, with FailOnErrors,
+// errored on its last scan.
+type GateConfig struct {
+	// Environments limits the gate to stacks in these environments (see
+	// environments). Empty covers every stack.
+	Environments []string `yaml:"environments,omitempty"`
+	// Tags limits the gate to stacks with any of these "key=value" tags.
+	// When both Environments and Tags are set, a stack matching either is
+	// covered.
+	Tags []string `yaml:"tags,omitempty"`
+	// MaxAge fails covered stacks whose last result is older. Zero skips the
+	// check.
+	MaxAge time.Duration `yaml:"max_age"`
+	// FailOnErrors fails covered stacks whose last scan errored.
+	FailOnErrors bool `yaml:"fail_on_errors"`
+}
+
+func (c GateConfig) validate() error {
+	for _, tag := range c.Tags {
+		if key, _, ok := strings.Cut(tag, "="); !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("gate.tags: %q must be key=value", tag)
+		}
+	}
+	for _, env := range c.Environments {
+		if strings.TrimSpace(env) == "" {
+			return fmt.Errorf("gate.environments: empty environment name")
+		}
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("gate.max_age must be >= 0")
+	}
+	return nil
+}
+
+// Covers reports whether the gate applies to a stack in environment env with
+// the given tags.
+func (c GateConfig) Covers(env string, tags map[string]string) bool {
+	if len(c.Environments) == 0 && len(c.Tags) == 0 {
+		return true
+	}
+	for _, name := range c.Environments {
+		if env != "" && name == env {
+			return true
+		}
+	}
+	for _, tag := range c.Tags {
+		key, value, _ := strings.Cut(tag, "=")
+		if got, ok := tags[key]; ok && got == value {
+			return true
+		}
+	}
+	return false
+}
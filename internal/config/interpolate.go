@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvironmentVar selects the config overlay Load merges on top of the base
+// file, e.g. DRIFTD_ENV=prod merges config.prod.yaml into config.yaml.
+const EnvironmentVar = "DRIFTD_ENV"
+
+// envRefPattern matches ${VAR} and ${VAR:-default}, and the $${ escape.
+// Only upper-case names are expanded so that stack_names templates such as
+// "${svc}" pass through untouched.
+var envRefPattern = regexp.MustCompile(`\$\$\{|\$\{([A-Z_][A-Z0-9_]*)(?::-([^}]*))?\}`)
+
+// OverlayPath returns the overlay file for env next to path:
+// config.yaml with env "prod" becomes config.prod.yaml.
+func OverlayPath(path, env string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + env + ext
+}
+
+// readConfigNode reads path, merges the overlay for env if env is set and
+// expands environment references. It returns nil when there is nothing to
+// decode. A missing base file is not an error; a missing overlay is.
+func readConfigNode(path, env string) (*yaml.Node, error) {
+	root, err := readYAMLNode(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if env = strings.TrimSpace(env); env != "" {
+		if strings.ContainsAny(env, `/\`) || strings.HasPrefix(env, ".") {
+			return nil, fmt.Errorf("invalid %s %q", EnvironmentVar, env)
+		}
+		overlayPath := OverlayPath(path, env)
+		overlay, err := readYAMLNode(overlayPath)
+		if err != nil {
+			return nil, fmt.Errorf("config overlay %s: %w", overlayPath, err)
+		}
+		root = mergeNodes(root, overlay)
+	}
+	if root == nil {
+		return nil, nil
+	}
+	if errs := interpolate(root); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return root, nil
+}
+
+func readYAMLNode(path string) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	if root.Kind == 0 {
+		return nil, nil
+	}
+	return &root, nil
+}
+
+// mergeNodes merges overlay into base. Mappings merge key by key; any other
+// value in the overlay replaces the base value, so lists are not appended.
+func mergeNodes(base, overlay *yaml.Node) *yaml.Node {
+	if base == nil {
+		return overlay
+	}
+	if overlay == nil {
+		return base
+	}
+	if base.Kind == yaml.DocumentNode && overlay.Kind == yaml.DocumentNode &&
+		len(base.Content) == 1 && len(overlay.Content) == 1 {
+		base.Content[0] = mergeNodes(base.Content[0], overlay.Content[0])
+		return base
+	}
+	if base.Kind != yaml.MappingNode || overlay.Kind != yaml.MappingNode {
+		return overlay
+	}
+	for i := 0; i+1 < len(overlay.Content); i += 2 {
+		key, value := overlay.Content[i], overlay.Content[i+1]
+		found := false
+		for j := 0; j+1 < len(base.Content); j += 2 {
+			if base.Content[j].Value == key.Value {
+				base.Content[j+1] = mergeNodes(base.Content[j+1], value)
+				found = true
+				break
+			}
+		}
+		if !found {
+			base.Content = append(base.Content, key, value)
+		}
+	}
+	return base
+}
+
+// interpolate expands environment references in every scalar value under
+// node. Keys are left alone. Each unset variable without a default is
+// reported with its line.
+func interpolate(node *yaml.Node) []error {
+	var errs []error
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			errs = append(errs, interpolate(child)...)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			errs = append(errs, interpolate(node.Content[i])...)
+		}
+	case yaml.ScalarNode:
+		value, missing := expandEnv(node.Value)
+		for _, name := range missing {
+			errs = append(errs, fmt.Errorf("line %d: environment variable %s is not set", node.Line, name))
+		}
+		if value != node.Value {
+			node.Value = value
+			// Let the decoder resolve the expanded value, so "${PORT}"
+			// can fill an int and "${ENABLED}" a bool.
+			if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+				node.Tag = ""
+			}
+		}
+	}
+	return errs
+}
+
+// expandEnv replaces ${VAR} and ${VAR:-default} in s and returns the names
+// of referenced variables that are unset and have no default.
+func expandEnv(s string) (string, []string) {
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+	var missing []string
+	out := envRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$${" {
+			return "${"
+		}
+		m := envRefPattern.FindStringSubmatch(ref)
+		if value, ok := os.LookupEnv(m[1]); ok && (value != "" || !strings.Contains(ref, ":-")) {
+			return value
+		}
+		if strings.Contains(ref, ":-") {
+			return m[2]
+		}
+		missing = append(missing, m[1])
+		return ""
+	})
+	return out, missing
+}
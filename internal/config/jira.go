@@ -0,0 +1,128 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+const (
+	defaultJiraInterval = 5 * time.Minute
+	minJiraInterval     = 30 * time.Second
+)
+
+// JiraConfig opens a Jira issue for each drifted stack, comments on it as
+// later scans change the drift, and transitions it once the stack is clean.
+type JiraConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// URL is the Jira base URL, such as https://example.atlassian.net.
+	URL string `yaml:"url"`
+	// Email and APIToken authenticate against Jira Cloud. Without an email
+	// the token is sent as a bearer token (Jira Data Center PATs).
+	Email       string `yaml:"email"`
+	APIToken    string `yaml:"api_token"`
+	APITokenEnv string `yaml:"api_token_env"`
+	// Interval is how often stack results are synced to Jira.
+	Interval time.Duration `yaml:"interval"`
+	// Labels are added to every issue driftd creates.
+	Labels []string `yaml:"labels"`
+	// CloseTransition is the workflow transition applied when drift clears.
+	// Defaults to "Done".
+	CloseTransition string `yaml:"close_transition"`
+	// ReopenTransition is applied when a stack drifts again after its issue
+	// was closed. Without it a new issue is opened.
+	ReopenTransition string `yaml:"reopen_transition"`
+	// PublicURL is the base URL used for stack links in issues. Defaults to
+	// webhook.public_url.
+	PublicURL string `yaml:"public_url"`
+	// Mappings route stacks to Jira projects; the first match wins and
+	// stacks matching none are not ticketed.
+	Mappings []JiraMapping `yaml:"mappings"`
+}
+
+// JiraMapping selects the Jira project and issue type for matching stacks.
+type JiraMapping struct {
+	// Project is a shell pattern on the driftd project name. Empty matches all.
+	Project string `yaml:"project"`
+	// Path is a glob on the stack path; "**" crosses directories. Empty
+	// matches all.
+	Path        string `yaml:"path"`
+	JiraProject string `yaml:"jira_project"`
+	IssueType   string `yaml:"issue_type"`
+}
+
+// Matches reports whether the mapping applies to stackPath in projectName.
+func (m JiraMapping) Matches(projectName, stackPath string) bool {
+	if m.Project != "" {
+		if ok, _ := path.Match(m.Project, projectName); !ok {
+			return false
+		}
+	}
+	if m.Path != "" {
+		if ok, _ := doublestar.Match(strings.Trim(m.Path, "/"), stackPath); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Mapping returns the first mapping for the stack, or nil.
+func (c JiraConfig) Mapping(projectName, stackPath string) *JiraMapping {
+	for i := range c.Mappings {
+		if c.Mappings[i].Matches(projectName, stackPath) {
+			return &c.Mappings[i]
+		}
+	}
+	return nil
+}
+
+func applyJiraDefaults(cfg *Config) []error {
+	j := &cfg.Jira
+	if !j.Enabled {
+		return nil
+	}
+	var errs []error
+	j.URL = strings.TrimRight(strings.TrimSpace(j.URL), "/")
+	if !strings.HasPrefix(j.URL, "https://") && !strings.HasPrefix(j.URL, "http://") {
+		errs = append(errs, fmt.Errorf("jira.url must be an http(s) URL"))
+	}
+	if j.APIToken == "" && j.APITokenEnv == "" {
+		errs = append(errs, fmt.Errorf("jira.api_token or jira.api_token_env is required when jira is enabled"))
+	}
+	if j.Interval == 0 {
+		j.Interval = defaultJiraInterval
+	}
+	if j.Interval < minJiraInterval {
+		errs = append(errs, fmt.Errorf("jira.interval must be at least %s", minJiraInterval))
+	}
+	for _, label := range j.Labels {
+		if label == "" || strings.ContainsAny(label, " \t") {
+			errs = append(errs, fmt.Errorf("jira.labels: %q must be non-empty without spaces", label))
+		}
+	}
+	if j.CloseTransition == "" {
+		j.CloseTransition = "Done"
+	}
+	j.PublicURL = strings.TrimRight(strings.TrimSpace(j.PublicURL), "/")
+	if j.PublicURL == "" {
+		j.PublicURL = cfg.Webhook.PublicURL
+	}
+	if len(j.Mappings) == 0 {
+		errs = append(errs, fmt.Errorf("jira.mappings needs at least one entry"))
+	}
+	for i, m := range j.Mappings {
+		if strings.TrimSpace(m.JiraProject) == "" || strings.TrimSpace(m.IssueType) == "" {
+			errs = append(errs, fmt.Errorf("jira.mappings[%d]: jira_project and issue_type are required", i))
+		}
+		if _, err := path.Match(m.Project, ""); err != nil {
+			errs = append(errs, fmt.Errorf("jira.mappings[%d]: invalid project pattern %q", i, m.Project))
+		}
+		if m.Path != "" && !doublestar.ValidatePattern(m.Path) {
+			errs = append(errs, fmt.Errorf("jira.mappings[%d]: invalid path pattern %q", i, m.Path))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Notification payload formats.
+const (
+	NotificationFormatJSON  = "json"
+	NotificationFormatSlack = "slack"
+)
+
+// notificationSeverities are the severity levels channels can filter on,
+// from least to most severe.
+var notificationSeverities = []string{"low", "medium", "high", "critical"}
+
+// NotificationsConfig sends newly drifted stacks to webhook channels.
+type NotificationsConfig struct {
+	Enabled  bool                  `yaml:"enabled"`
+	Channels []NotificationChannel `yaml:"channels"`
+	// PublicURL is the base URL used for stack links. Defaults to
+	// webhook.public_url.
+	PublicURL string `yaml:"public_url"`
+}
+
+// NotificationChannel is one webhook receiving drift notifications.
+type NotificationChannel struct {
+	Name string `yaml:"name"`
+	// WebhookURL receives a POST per notification or digest.
+	WebhookURL string `yaml:"webhook_url"`
+	// WebhookSecret signs JSON bodies with HMAC-SHA256 in the
+	// X-Driftd-Signature-256 header.
+	WebhookSecret string `yaml:"webhook_secret"`
+	// Format is "json" (default) or "slack" for a Slack incoming webhook.
+	Format string `yaml:"format"`
+	// Projects are shell patterns on the project name. Empty matches all.
+	Projects []string `yaml:"projects"`
+	// MinSeverity drops drift below this level. Empty sends every level.
+	MinSeverity string `yaml:"min_severity"`
+	// BatchWindow collects notifications into one digest sent when the
+	// window, started by the first notification, ends. Zero sends each
+	// notification on its own.
+	BatchWindow time.Duration `yaml:"batch_window"`
+	// QuietHours hold notifications until the quiet period ends and send
+	// them as one digest.
+	QuietHours *QuietHours `yaml:"quiet_hours"`
+	// UrgentSeverity sends drift at or above this level at once, even in
+	// quiet hours or a batch window. Empty holds every level.
+	UrgentSeverity string `yaml:"urgent_severity"`
+}
+
+// QuietHours is a daily period, such as 22:00 to 07:00, in a time zone.
+type QuietHours struct {
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+	// Timezone is an IANA zone name. Defaults to UTC.
+	Timezone string `yaml:"timezone"`
+
+	start, end int
+	loc        *time.Location
+}
+
+// MatchesProject reports whether the channel receives drift in projectName.
+func (c NotificationChannel) MatchesProject(projectName string) bool {
+	if len(c.Projects) == 0 {
+		return true
+	}
+	for _, pattern := range c.Projects {
+		if ok, _ := path.Match(pattern, projectName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SeverityRank orders severity levels for MinSeverity and UrgentSeverity:
+// 0 for none or unknown, 1 for low up to 4 for critical.
+func SeverityRank(level string) int {
+	for i, l := range notificationSeverities {
+		if l == level {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Active reports whether now falls in the quiet period and, if so, when the
+// period ends.
+func (q *QuietHours) Active(now time.Time) (time.Time, bool) {
+	if q == nil || q.loc == nil || q.start == q.end {
+		return time.Time{}, false
+	}
+	local := now.In(q.loc)
+	minute := local.Hour()*60 + local.Minute()
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, q.loc)
+	at := func(day, minutes int) time.Time {
+		return time.Date(midnight.Year(), midnight.Month(), midnight.Day()+day, minutes/60, minutes%60, 0, 0, q.loc)
+	}
+	if q.start < q.end {
+		if minute >= q.start && minute < q.end {
+			return at(0, q.end), true
+		}
+		return time.Time{}, false
+	}
+	// The period crosses midnight.
+	switch {
+	case minute >= q.start:
+		return at(1, q.end), true
+	case minute < q.end:
+		return at(0, q.end), true
+	}
+	return time.Time{}, false
+}
+
+// Parse resolves the start, end and time zone. Loading the config parses
+// every channel's quiet hours; Active is false until then.
+func (q *QuietHours) Parse() error {
+	var err error
+	if q.start, err = parseClock(q.Start); err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	if q.end, err = parseClock(q.End); err != nil {
+		return fmt.Errorf("end: %w", err)
+	}
+	if q.start == q.end {
+		return fmt.Errorf("start and end must differ")
+	}
+	q.loc = time.UTC
+	if q.Timezone != "" {
+		if q.loc, err = time.LoadLocation(q.Timezone); err != nil {
+			return fmt.Errorf("timezone: %w", err)
+		}
+	}
+	return nil
+}
+
+// parseClock returns the minutes past midnight of an "HH:MM" time.
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an HH:MM time", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func applyNotificationsDefaults(cfg *Config) []error {
+	n := &cfg.Notifications
+	if !n.Enabled {
+		return nil
+	}
+	var errs []error
+	n.PublicURL = strings.TrimRight(strings.TrimSpace(n.PublicURL), "/")
+	if n.PublicURL == "" {
+		n.PublicURL = cfg.Webhook.PublicURL
+	}
+	if len(n.Channels) == 0 {
+		errs = append(errs, fmt.Errorf("notifications.channels is required when notifications are enabled"))
+	}
+	seen := map[string]bool{}
+	for i := range n.Channels {
+		c := &n.Channels[i]
+		c.Name = strings.TrimSpace(c.Name)
+		label := fmt.Sprintf("notifications.channels[%d]", i)
+		if c.Name == "" {
+			errs = append(errs, fmt.Errorf("%s: name is required", label))
+		} else {
+			label += " (" + c.Name + ")"
+			if seen[c.Name] {
+				errs = append(errs, fmt.Errorf("%s: duplicate name", label))
+			}
+			seen[c.Name] = true
+		}
+		c.WebhookURL = strings.TrimSpace(c.WebhookURL)
+		if !strings.HasPrefix(c.WebhookURL, "https://") && !strings.HasPrefix(c.WebhookURL, "http://") {
+			errs = append(errs, fmt.Errorf("%s: webhook_url must be an http(s) URL", label))
+		}
+		if c.Format == "" {
+			c.Format = NotificationFormatJSON
+		}
+		if c.Format != NotificationFormatJSON && c.Format != NotificationFormatSlack {
+			errs = append(errs, fmt.Errorf("%s: format must be %q or %q", label, NotificationFormatJSON, NotificationFormatSlack))
+		}
+		for _, pattern := range c.Projects {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Errorf("%s: invalid project pattern %q", label, pattern))
+			}
+		}
+		if c.MinSeverity != "" && SeverityRank(c.MinSeverity) == 0 {
+			errs = append(errs, fmt.Errorf("%s: min_severity must be one of %s", label, strings.Join(notificationSeverities, ", ")))
+		}
+		if c.UrgentSeverity != "" && SeverityRank(c.UrgentSeverity) == 0 {
+			errs = append(errs, fmt.Errorf("%s: urgent_severity must be one of %s", label, strings.Join(notificationSeverities, ", ")))
+		}
+		if c.BatchWindow < 0 {
+			errs = append(errs, fmt.Errorf("%s: batch_window must not be negative", label))
+		}
+		if c.QuietHours != nil {
+			if err := c.QuietHours.Parse(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: quiet_hours.%w", label, err))
+			}
+		}
+	}
+	return errs
+}
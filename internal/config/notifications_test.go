@@ -0,0 +1,50 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuietHoursActive(t *testing.T) {
+	q := &QuietHours{Start: "22:00", End: "07:00", Timezone: "Europe/Berlin"}
+	if err := q.Parse(); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	end, ok := q.Active(time.Date(2026, 3, 10, 23, 30, 0, 0, berlin))
+	if !ok || !end.Equal(time.Date(2026, 3, 11, 7, 0, 0, 0, berlin)) {
+		t.Fatalf("expected quiet until next morning, got %s (%v)", end, ok)
+	}
+	end, ok = q.Active(time.Date(2026, 3, 11, 3, 0, 0, 0, berlin))
+	if !ok || !end.Equal(time.Date(2026, 3, 11, 7, 0, 0, 0, berlin)) {
+		t.Fatalf("expected quiet until this morning, got %s (%v)", end, ok)
+	}
+	if _, ok := q.Active(time.Date(2026, 3, 11, 7, 0, 0, 0, berlin)); ok {
+		t.Fatal("expected quiet hours to end at 07:00")
+	}
+	if _, ok := q.Active(time.Date(2026, 3, 11, 12, 0, 0, 0, time.UTC)); ok {
+		t.Fatal("expected midday to be outside quiet hours")
+	}
+}
+
+func TestNotificationsValidation(t *testing.T) {
+	cfg := &Config{Notifications: NotificationsConfig{
+		Enabled: true,
+		Channels: []NotificationChannel{
+			{Name: "oncall", WebhookURL: "https://hooks.example.com/x", UrgentSeverity: "severe", QuietHours: &QuietHours{Start: "25:00", End: "07:00"}},
+			{Name: "oncall", WebhookURL: "ftp://example.com", Format: "xml"},
+		},
+	}}
+	var msgs []string
+	for _, err := range applyNotificationsDefaults(cfg) {
+		msgs = append(msgs, err.Error())
+	}
+	joined := strings.Join(msgs, "\n")
+	for _, want := range []string{"urgent_severity must be one of", "quiet_hours.start", "duplicate name", "webhook_url must be", "format must be"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("expected error containing %q, got:\n%s", want, joined)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Redacted replaces secret values in printed configs.
+const Redacted = "REDACTED"
+
+// secretKeySuffixes mark keys whose values are credentials. Webhook URLs
+// count, since a Slack incoming webhook URL is its own credential. Keys that
+// name where a secret lives (_env, _path, _header) are printed as is.
+var secretKeySuffixes = []string{"password", "secret", "token", "private_key", "passphrase", "webhook_url"}
+
+// Print writes cfg as YAML, as Load resolved it. With redact set, secret
+// values and passwords embedded in URLs are replaced by Redacted.
+func Print(w io.Writer, cfg *Config, redact bool) error {
+	var root yaml.Node
+	if err := root.Encode(cfg); err != nil {
+		return err
+	}
+	if redact {
+		redactNode(&root)
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func redactNode(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			redactNode(child)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if value.Kind == yaml.ScalarNode && value.Value != "" && isSecretKey(key.Value) {
+				value.Value, value.Tag, value.Style = Redacted, "!!str", 0
+				continue
+			}
+			redactNode(value)
+		}
+	case yaml.ScalarNode:
+		if strings.Contains(node.Value, "://") {
+			if u, err := url.Parse(node.Value); err == nil && redactURL(u) {
+				node.Value = u.String()
+			}
+		}
+	}
+}
+
+// secretQueryParams mark query parameters whose values are credentials,
+// such as token, api_key or X-Amz-Signature.
+var secretQueryParams = []string{"token", "key", "sig", "secret", "password"}
+
+// redactURL replaces the userinfo password and secret-looking query values
+// of u, and reports whether it changed anything.
+func redactURL(u *url.URL) bool {
+	changed := false
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), Redacted)
+			changed = true
+		}
+	}
+	if u.RawQuery == "" {
+		return changed
+	}
+	query := u.Query()
+	for name, values := range query {
+		lower := strings.ToLower(name)
+		for _, secret := range secretQueryParams {
+			if strings.Contains(lower, secret) {
+				for i := range values {
+					values[i] = Redacted
+				}
+				changed = true
+				break
+			}
+		}
+	}
+	if changed {
+		u.RawQuery = query.Encode()
+	}
+	return changed
+}
+
+func isSecretKey(key string) bool {
+	for _, suffix := range []string{"_env", "_path", "_header"} {
+		if strings.HasSuffix(key, suffix) {
+			return false
+		}
+	}
+	for _, suffix := range secretKeySuffixes {
+		if key == suffix || strings.HasSuffix(key, "_"+suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultQueueAlarmThreshold = 10 * time.Minute
+	defaultQueueAlarmFor       = 5 * time.Minute
+	defaultQueueAlarmWindow    = 15 * time.Minute
+	defaultQueueAlarmInterval  = 30 * time.Second
+	minQueueAlarmInterval      = 5 * time.Second
+)
+
+// QueueAlarmConfig raises a starvation alarm when stack scans wait too long
+// between enqueue and claim, and can call a webhook so worker capacity can
+// scale with load.
+type QueueAlarmConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Threshold is the p95 queue wait above which the queue is starved.
+	Threshold time.Duration `yaml:"p95_threshold"`
+	// For is how long the wait must stay above Threshold before the alarm
+	// fires.
+	For time.Duration `yaml:"for"`
+	// Window is how far back claimed stack scans count toward the p95.
+	Window time.Duration `yaml:"window"`
+	// Interval is how often the wait is evaluated.
+	Interval time.Duration `yaml:"interval"`
+	// WebhookURL receives a JSON POST when the alarm fires and when it
+	// clears.
+	WebhookURL string `yaml:"webhook_url"`
+	// WebhookSecret signs webhook bodies with HMAC-SHA256 in the
+	// X-Driftd-Signature-256 header.
+	WebhookSecret string `yaml:"webhook_secret"`
+}
+
+func applyQueueAlarmDefaults(cfg *Config) []error {
+	a := &cfg.QueueAlarm
+	if !a.Enabled {
+		return nil
+	}
+	var errs []error
+	if a.Threshold == 0 {
+		a.Threshold = defaultQueueAlarmThreshold
+	}
+	if a.For == 0 {
+		a.For = defaultQueueAlarmFor
+	}
+	if a.Window == 0 {
+		a.Window = defaultQueueAlarmWindow
+	}
+	if a.Interval == 0 {
+		a.Interval = defaultQueueAlarmInterval
+	}
+	if a.Threshold < 0 || a.For < 0 || a.Window < 0 {
+		errs = append(errs, fmt.Errorf("queue_alarm: p95_threshold, for and window must not be negative"))
+	}
+	if a.Interval < minQueueAlarmInterval {
+		errs = append(errs, fmt.Errorf("queue_alarm.interval must be at least %s", minQueueAlarmInterval))
+	}
+	a.WebhookURL = strings.TrimSpace(a.WebhookURL)
+	if a.WebhookURL != "" && !strings.HasPrefix(a.WebhookURL, "https://") && !strings.HasPrefix(a.WebhookURL, "http://") {
+		errs = append(errs, fmt.Errorf("queue_alarm.webhook_url must be an http(s) URL"))
+	}
+	return errs
+}
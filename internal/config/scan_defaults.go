@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Scan priorities, lowest first. Workers claim the stack scans of higher
+// priority scans first, and a new scan may supersede an in-flight scan of
+// the same or lower priority.
+const (
+	ScanPriorityLow    = "low"
+	ScanPriorityNormal = "normal"
+	ScanPriorityHigh   = "high"
+)
+
+// ScanDefaultsConfig sets how scans run per trigger. Keys of Triggers are
+// trigger names such as "webhook", "scheduled" or "manual".
+type ScanDefaultsConfig struct {
+	Triggers map[string]TriggerScanDefaults `yaml:"triggers,omitempty"`
+}
+
+// TriggerScanDefaults are the settings of scans started by one trigger.
+// Unset fields fall back to the global entry for the trigger, then to the
+// built-in behavior.
+type TriggerScanDefaults struct {
+	// Retries is how many times a failed stack plan is retried. Defaults to
+	// 1 with worker.retry_once, otherwise 0.
+	Retries *int `yaml:"retries,omitempty"`
+	// Priority is "low", "normal" or "high". Defaults to low for scheduled,
+	// cron and chain scans and normal for the rest.
+	Priority string `yaml:"priority,omitempty"`
+	// Concurrency caps how many stacks of one scan run at once across all
+	// workers. 0 is no cap.
+	Concurrency *int `yaml:"concurrency,omitempty"`
+}
+
+// ScanSettings are the resolved settings of a scan. Priority is empty when
+// neither the project nor the global config sets one.
+type ScanSettings struct {
+	Retries     int
+	Priority    string
+	Concurrency int
+}
+
+// ScanSettings resolves the settings of a scan of project started by
+// trigger: the project's entry for the trigger, then the global one.
+func (c *Config) ScanSettings(project *ProjectConfig, trigger string) ScanSettings {
+	var settings ScanSettings
+	if c == nil {
+		return settings
+	}
+	if c.Worker.RetryOnce {
+		settings.Retries = 1
+	}
+	layers := []TriggerScanDefaults{c.ScanDefaults.Triggers[trigger]}
+	if project != nil {
+		layers = append(layers, project.ScanDefaults.Triggers[trigger])
+	}
+	for _, d := range layers {
+		if d.Retries != nil {
+			settings.Retries = *d.Retries
+		}
+		if d.Priority != "" {
+			settings.Priority = d.Priority
+		}
+		if d.Concurrency != nil {
+			settings.Concurrency = *d.Concurrency
+		}
+	}
+	return settings
+}
+
+func (d ScanDefaultsConfig) validate() error {
+	triggers := make([]string, 0, len(d.Triggers))
+	for trigger := range d.Triggers {
+		triggers = append(triggers, trigger)
+	}
+	sort.Strings(triggers)
+	for _, trigger := range triggers {
+		if strings.TrimSpace(trigger) == "" {
+			return fmt.Errorf("scan_defaults.triggers: trigger name is required")
+		}
+		t := d.Triggers[trigger]
+		if t.Retries != nil && *t.Retries < 0 {
+			return fmt.Errorf("scan_defaults.triggers.%s.retries must be >= 0", trigger)
+		}
+		switch t.Priority {
+		case "", ScanPriorityLow, ScanPriorityNormal, ScanPriorityHigh:
+		default:
+			return fmt.Errorf("scan_defaults.triggers.%s.priority must be %q, %q or %q", trigger, ScanPriorityLow, ScanPriorityNormal, ScanPriorityHigh)
+		}
+		if t.Concurrency != nil && *t.Concurrency < 0 {
+			return fmt.Errorf("scan_defaults.triggers.%s.concurrency must be >= 0", trigger)
+		}
+	}
+	return nil
+}
+
+func copyScanDefaults(d ScanDefaultsConfig) ScanDefaultsConfig {
+	if d.Triggers == nil {
+		return ScanDefaultsConfig{}
+	}
+	out := ScanDefaultsConfig{Triggers: make(map[string]TriggerScanDefaults, len(d.Triggers))}
+	for trigger, t := range d.Triggers {
+		out.Triggers[trigger] = TriggerScanDefaults{
+			Retries:     copyIntPtr(t.Retries),
+			Priority:    t.Priority,
+			Concurrency: copyIntPtr(t.Concurrency),
+		}
+	}
+	return out
+}
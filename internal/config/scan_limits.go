@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ScanLimitsConfig caps how many scans may start per trigger within a
+// clock hour. Keys are trigger names such as "manual", "webhook" or
+// "scheduled"; a missing key or 0 means no cap.
+type ScanLimitsConfig struct {
+	PerHour map[string]int `yaml:"per_hour,omitempty"`
+}
+
+// ValidateScanLimits reports the first negative or unnamed limit.
+func ValidateScanLimits(perHour map[string]int) error {
+	triggers := make([]string, 0, len(perHour))
+	for trigger := range perHour {
+		triggers = append(triggers, trigger)
+	}
+	sort.Strings(triggers)
+	for _, trigger := range triggers {
+		if strings.TrimSpace(trigger) == "" {
+			return fmt.Errorf("trigger name is required")
+		}
+		if perHour[trigger] < 0 {
+			return fmt.Errorf("%s must be >= 0", trigger)
+		}
+	}
+	return nil
+}
+
+func (l ScanLimitsConfig) validate() error {
+	if err := ValidateScanLimits(l.PerHour); err != nil {
+		return fmt.Errorf("scan_limits.per_hour: %w", err)
+	}
+	return nil
+}
+
+func copyScanLimits(l ScanLimitsConfig) ScanLimitsConfig {
+	if l.PerHour == nil {
+		return ScanLimitsConfig{}
+	}
+	out := ScanLimitsConfig{PerHour: make(map[string]int, len(l.PerHour))}
+	for trigger, limit := range l.PerHour {
+		out.PerHour[trigger] = limit
+	}
+	return out
+}
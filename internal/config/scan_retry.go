@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultScanRetryAfter     = 10 * time.Minute
+	defaultScanRetryMaxPerDay = 3
+)
+
+// ScanRetryConfig retries a scan once, in full, when it fails before any
+// stack is planned: the repository could not be cloned or no stacks were
+// discovered. Such failures are often transient, e.g. a Git host outage or
+// an unreachable submodule.
+type ScanRetryConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// After is how long to wait before the retry. Default 10m.
+	After time.Duration `yaml:"after"`
+	// MaxPerDay caps automatic retries per project per UTC day. Default 3.
+	MaxPerDay int `yaml:"max_per_day"`
+}
+
+func applyScanRetryDefaults(cfg *Config) []error {
+	r := &cfg.ScanRetry
+	if r.After == 0 {
+		r.After = defaultScanRetryAfter
+	}
+	if r.MaxPerDay == 0 {
+		r.MaxPerDay = defaultScanRetryMaxPerDay
+	}
+	var errs []error
+	if r.After < 0 {
+		errs = append(errs, fmt.Errorf("scan_retry.after must be positive"))
+	}
+	if r.MaxPerDay < 0 {
+		errs = append(errs, fmt.Errorf("scan_retry.max_per_day must be positive"))
+	}
+	return errs
+}
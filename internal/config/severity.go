@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// SeverityActions are the resource actions a severity policy can weigh.
+var SeverityActions = []string{"create", "update", "replace", "delete", "read", "import", "forget", "move"}
+
+// SeverityConfig is the drift severity scoring policy. Every entry replaces
+// the built-in weight with the same key; keys left out keep the default.
+type SeverityConfig struct {
+	// Actions is the points each resource action adds to a stack's score.
+	Actions map[string]int `yaml:"actions,omitempty"`
+	// ResourceTypes multiplies the points of changes to matching resource
+	// types. Keys are shell patterns such as "aws_iam_*".
+	ResourceTypes map[string]int `yaml:"resource_types,omitempty"`
+	// Tags multiplies the score of stacks with a matching "key=value" tag.
+	Tags map[string]int `yaml:"tags,omitempty"`
+}
+
+func (c SeverityConfig) validate() error {
+	for _, action := range sortedKeys(c.Actions) {
+		if !isSeverityAction(action) {
+			return fmt.Errorf("severity.actions: unknown action %q", action)
+		}
+		if c.Actions[action] < 0 {
+			return fmt.Errorf("severity.actions.%s must be >= 0", action)
+		}
+	}
+	for _, pattern := range sortedKeys(c.ResourceTypes) {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return fmt.Errorf("severity.resource_types: invalid pattern %q", pattern)
+		}
+		if c.ResourceTypes[pattern] < 0 {
+			return fmt.Errorf("severity.resource_types.%s must be >= 0", pattern)
+		}
+	}
+	for _, tag := range sortedKeys(c.Tags) {
+		if key, _, ok := strings.Cut(tag, "="); !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("severity.tags: %q must be key=value", tag)
+		}
+		if c.Tags[tag] < 0 {
+			return fmt.Errorf("severity.tags.%s must be >= 0", tag)
+		}
+	}
+	return nil
+}
+
+func isSeverityAction(action string) bool {
+	for _, known := range SeverityActions {
+		if action == known {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
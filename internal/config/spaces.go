@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// SpaceConfig is an isolated driftd environment hosted by one serve process,
+// such as staging next to prod. Each space has its own projects, queue and
+// data directory; requests are routed to it by hostname.
+type SpaceConfig struct {
+	Name string `yaml:"name"`
+	// Hosts route requests whose Host header matches, port ignored.
+	// Spaces are not routed by path: the UI, redirects and session cookies
+	// all assume the space is served at the root of its host.
+	Hosts []string `yaml:"hosts,omitempty"`
+	// RedisDB is the Redis logical database holding the space's queue and
+	// scan state. Required with the redis backend and unique per space.
+	RedisDB *int `yaml:"redis_db,omitempty"`
+	// KeyPrefix names the space's streams, buckets and subjects with the
+	// nats backend. Defaults to queue.nats.prefix followed by "_<name>".
+	KeyPrefix string `yaml:"key_prefix,omitempty"`
+	// DataSubdir is the space's directory under data_dir. Defaults to the
+	// space name.
+	DataSubdir string          `yaml:"data_subdir,omitempty"`
+	Projects   []ProjectConfig `yaml:"projects"`
+}
+
+// SpaceNames returns the names of the configured spaces in config order.
+func (c *Config) SpaceNames() []string {
+	names := make([]string, 0, len(c.Spaces))
+	for _, space := range c.Spaces {
+		names = append(names, space.Name)
+	}
+	return names
+}
+
+// Space returns the config a space runs with: the shared settings with the
+// space's projects, data directory and queue isolation applied.
+func (c *Config) Space(name string) (*Config, error) {
+	for _, space := range c.Spaces {
+		if space.Name != name {
+			continue
+		}
+		out := *c
+		out.Spaces = nil
+		out.Projects = space.Projects
+		out.DataDir = filepath.Join(c.DataDir, filepath.FromSlash(space.DataSubdir))
+		if space.RedisDB != nil {
+			out.Redis.DB = *space.RedisDB
+		}
+		if space.KeyPrefix != "" {
+			out.Queue.NATS.Prefix = space.KeyPrefix
+		}
+		return &out, nil
+	}
+	return nil, fmt.Errorf("unknown space %q (configured: %s)", name, strings.Join(c.SpaceNames(), ", "))
+}
+
+func (c *Config) hasSpaceWebhookSecret() bool {
+	for _, space := range c.Spaces {
+		if hasProjectWebhookSecret(space.Projects) {
+			return true
+		}
+	}
+	return false
+}
+
+func applySpaceDefaults(cfg *Config) []error {
+	if len(cfg.Spaces) == 0 {
+		return nil
+	}
+	var errs []error
+	if len(cfg.Projects) > 0 {
+		errs = append(errs, fmt.Errorf("projects: move projects into spaces when spaces are set"))
+	}
+	names := map[string]bool{}
+	hosts := map[string]string{}
+	subdirs := map[string]string{}
+	redisDBs := map[int]string{}
+	natsPrefixes := map[string]string{}
+	for i := range cfg.Spaces {
+		s := &cfg.Spaces[i]
+		source := fmt.Sprintf("spaces[%d]", i)
+		if !natsPrefixPattern.MatchString(s.Name) {
+			errs = append(errs, fmt.Errorf("%s: name may only contain letters, digits, '-' and '_' (got %q)", source, s.Name))
+			continue
+		}
+		if names[s.Name] {
+			errs = append(errs, fmt.Errorf("%s: duplicate space name %q", source, s.Name))
+			continue
+		}
+		names[s.Name] = true
+		source = fmt.Sprintf("%s (%s)", source, s.Name)
+
+		for j, host := range s.Hosts {
+			host = strings.ToLower(strings.TrimSpace(host))
+			if host == "" || strings.ContainsAny(host, "/: ") {
+				errs = append(errs, fmt.Errorf("%s: invalid host %q", source, s.Hosts[j]))
+				continue
+			}
+			if other, ok := hosts[host]; ok {
+				errs = append(errs, fmt.Errorf("%s: host %q is already used by space %q", source, host, other))
+			}
+			hosts[host] = s.Name
+			s.Hosts[j] = host
+		}
+		if len(s.Hosts) == 0 {
+			errs = append(errs, fmt.Errorf("%s: hosts is required", source))
+		}
+
+		if s.DataSubdir == "" {
+			s.DataSubdir = s.Name
+		}
+		if subdir, err := normalizeProjectPath(s.DataSubdir); err != nil {
+			errs = append(errs, fmt.Errorf("%s: data_subdir: %w", source, err))
+		} else {
+			if other, ok := subdirs[subdir]; ok {
+				errs = append(errs, fmt.Errorf("%s: data_subdir %q is already used by space %q", source, subdir, other))
+			}
+			subdirs[subdir] = s.Name
+			s.DataSubdir = subdir
+		}
+
+		switch cfg.Queue.Backend {
+		case QueueBackendRedis:
+			if s.KeyPrefix != "" {
+				errs = append(errs, fmt.Errorf("%s: key_prefix applies to the nats backend; isolate redis spaces with redis_db", source))
+			}
+			if s.RedisDB == nil {
+				errs = append(errs, fmt.Errorf("%s: redis_db is required with the redis backend", source))
+			} else if *s.RedisDB < 0 {
+				errs = append(errs, fmt.Errorf("%s: redis_db must be >= 0", source))
+			} else {
+				if other, ok := redisDBs[*s.RedisDB]; ok {
+					errs = append(errs, fmt.Errorf("%s: redis_db %d is already used by space %q", source, *s.RedisDB, other))
+				}
+				redisDBs[*s.RedisDB] = s.Name
+			}
+		case QueueBackendNATS:
+			if s.KeyPrefix == "" {
+				s.KeyPrefix = cfg.Queue.NATS.Prefix + "_" + s.Name
+			}
+			if !natsPrefixPattern.MatchString(s.KeyPrefix) {
+				errs = append(errs, fmt.Errorf("%s: key_prefix may only contain letters, digits, '-' and '_'", source))
+			}
+			if other, ok := natsPrefixes[s.KeyPrefix]; ok {
+				errs = append(errs, fmt.Errorf("%s: key_prefix %q is already used by space %q", source, s.KeyPrefix, other))
+			}
+			natsPrefixes[s.KeyPrefix] = s.Name
+		}
+
+		projects, err := expandMonorepos(s.Projects)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source, err))
+			continue
+		}
+		s.Projects = projects
+		chains := make(map[string][]ChainTrigger, len(projects))
+		for _, project := range projects {
+			chains[project.Name] = project.Chain
+		}
+		if cycle := FindChainCycle(chains); cycle != nil {
+			errs = append(errs, fmt.Errorf("%s: projects: chain loop %s", source, strings.Join(cycle, " -> ")))
+		}
+	}
+	return errs
+}
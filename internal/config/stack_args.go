@@ -0,0 +1,181 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// TerraformArgsConfig adds arguments to the terraform init and plan commands
+// of a project's stacks. Stack entries add their arguments after the project
+// ones for every stack whose path matches Pattern, in order.
+type TerraformArgsConfig struct {
+	InitArgs []string            `yaml:"init_args,omitempty"`
+	PlanArgs []string            `yaml:"plan_args,omitempty"`
+	Stacks   []StackArgsOverride `yaml:"stacks,omitempty"`
+}
+
+// StackArgsOverride adds init and plan arguments for stacks matching Pattern,
+// a glob relative to the project root using the environments syntax.
+type StackArgsOverride struct {
+	Pattern  string   `yaml:"pattern"`
+	InitArgs []string `yaml:"init_args,omitempty"`
+	PlanArgs []string `yaml:"plan_args,omitempty"`
+	// Weight is how many worker concurrency slots a scan of a matching
+	// stack takes, so a worker runs fewer heavy plans at once. Zero means 1.
+	Weight int `yaml:"weight,omitempty"`
+}
+
+// allowedInitFlags and allowedPlanFlags list the flags projects may set.
+// true means the flag takes a value ("-flag=value"); false means it must be
+// bare. Flags that write files, change what is planned for apply or bypass
+// driftd's own -input/-detailed-exitcode handling are left out.
+var (
+	allowedInitFlags = map[string]bool{
+		"-backend-config": true,
+		"-reconfigure":    false,
+		"-upgrade":        false,
+		"-lockfile":       true,
+		"-lock":           true,
+		"-lock-timeout":   true,
+		"-get":            true,
+	}
+	allowedPlanFlags = map[string]bool{
+		"-var":              true,
+		"-var-file":         true,
+		"-lock":             true,
+		"-lock-timeout":     true,
+		"-parallelism":      true,
+		"-refresh":          true,
+		"-compact-warnings": false,
+	}
+)
+
+// fileValueFlags take a path, which must stay inside the workspace.
+var fileValueFlags = map[string]struct{}{
+	"-backend-config": {},
+	"-var-file":       {},
+}
+
+// ValidateInitArgs reports the first init argument outside the allowlist.
+func ValidateInitArgs(args []string) error {
+	return validateTerraformArgs(args, allowedInitFlags)
+}
+
+// ValidatePlanArgs reports the first plan argument outside the allowlist.
+func ValidatePlanArgs(args []string) error {
+	return validateTerraformArgs(args, allowedPlanFlags)
+}
+
+func validateTerraformArgs(args []string, allowed map[string]bool) error {
+	for _, arg := range args {
+		name, value, hasValue := strings.Cut(arg, "=")
+		takesValue, ok := allowed[name]
+		if !ok {
+			return fmt.Errorf("argument %q is not allowed", arg)
+		}
+		if takesValue != hasValue {
+			if takesValue {
+				return fmt.Errorf("argument %q needs a value as %s=<value>", arg, name)
+			}
+			return fmt.Errorf("argument %q does not take a value", arg)
+		}
+		if takesValue && value == "" {
+			return fmt.Errorf("argument %q has an empty value", arg)
+		}
+		if _, ok := fileValueFlags[name]; ok && !strings.Contains(value, "=") {
+			// -backend-config also accepts key=value pairs; anything else is
+			// a file read relative to the stack. The runner checks that it
+			// stays inside the checkout.
+			if path.IsAbs(value) || strings.HasPrefix(value, "~") {
+				return fmt.Errorf("argument %q must use a path relative to the stack", arg)
+			}
+		}
+	}
+	return nil
+}
+
+func (t *TerraformArgsConfig) validate() error {
+	if err := ValidateInitArgs(t.InitArgs); err != nil {
+		return fmt.Errorf("terraform.init_args: %w", err)
+	}
+	if err := ValidatePlanArgs(t.PlanArgs); err != nil {
+		return fmt.Errorf("terraform.plan_args: %w", err)
+	}
+	for i, s := range t.Stacks {
+		pattern := strings.Trim(strings.TrimSpace(s.Pattern), "/")
+		if pattern == "" {
+			return fmt.Errorf("terraform.stacks[%d]: pattern is required", i)
+		}
+		if _, err := regexp.Compile(environmentPatternRegexp(pattern)); err != nil {
+			return fmt.Errorf("terraform.stacks[%d]: invalid pattern %q: %v", i, s.Pattern, err)
+		}
+		if err := ValidateInitArgs(s.InitArgs); err != nil {
+			return fmt.Errorf("terraform.stacks[%d].init_args: %w", i, err)
+		}
+		if err := ValidatePlanArgs(s.PlanArgs); err != nil {
+			return fmt.Errorf("terraform.stacks[%d].plan_args: %w", i, err)
+		}
+		if s.Weight < 0 {
+			return fmt.Errorf("terraform.stacks[%d].weight must be >= 0", i)
+		}
+	}
+	return nil
+}
+
+// StackArgs returns the extra init and plan arguments for stackPath.
+func (r *ProjectConfig) StackArgs(stackPath string) (initArgs, planArgs []string) {
+	if r == nil {
+		return nil, nil
+	}
+	initArgs = append(initArgs, r.Terraform.InitArgs...)
+	planArgs = append(planArgs, r.Terraform.PlanArgs...)
+	stackPath = strings.Trim(stackPath, "/")
+	for _, s := range r.Terraform.Stacks {
+		re, err := regexp.Compile(environmentPatternRegexp(strings.Trim(strings.TrimSpace(s.Pattern), "/")))
+		if err != nil || !re.MatchString(stackPath) {
+			continue
+		}
+		initArgs = append(initArgs, s.InitArgs...)
+		planArgs = append(planArgs, s.PlanArgs...)
+	}
+	return initArgs, planArgs
+}
+
+// StackWeight returns how many worker slots a scan of stackPath takes: the
+// largest weight among the matching terraform.stacks entries, or 1.
+func (r *ProjectConfig) StackWeight(stackPath string) int {
+	weight := 1
+	if r == nil {
+		return weight
+	}
+	stackPath = strings.Trim(stackPath, "/")
+	for _, s := range r.Terraform.Stacks {
+		if s.Weight <= weight {
+			continue
+		}
+		re, err := regexp.Compile(environmentPatternRegexp(strings.Trim(strings.TrimSpace(s.Pattern), "/")))
+		if err != nil || !re.MatchString(stackPath) {
+			continue
+		}
+		weight = s.Weight
+	}
+	return weight
+}
+
+func copyTerraformArgs(t TerraformArgsConfig) TerraformArgsConfig {
+	out := TerraformArgsConfig{
+		InitArgs: copyStringSlice(t.InitArgs),
+		PlanArgs: copyStringSlice(t.PlanArgs),
+	}
+	for _, s := range t.Stacks {
+		out.Stacks = append(out.Stacks, StackArgsOverride{
+			Pattern:  s.Pattern,
+			InitArgs: copyStringSlice(s.InitArgs),
+			PlanArgs: copyStringSlice(s.PlanArgs),
+			Weight:   s.Weight,
+		})
+	}
+	return out
+}
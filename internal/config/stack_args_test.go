@@ -0,0 +1,91 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateTerraformArgs(t *testing.T) {
+	cases := []struct {
+		name string
+		init []string
+		plan []string
+		err  string
+	}{
+		{name: "allowed", init: []string{"-backend-config=backend.hcl", "-reconfigure"}, plan: []string{"-var-file=prod.tfvars", "-lock=false"}},
+		{name: "backend key value", init: []string{"-backend-config=bucket=state"}},
+		{name: "unknown flag", plan: []string{"-out=plan.bin"}, err: "not allowed"},
+		{name: "apply flag", plan: []string{"-destroy"}, err: "not allowed"},
+		{name: "separate value", init: []string{"-backend-config", "backend.hcl"}, err: "needs a value"},
+		{name: "bare flag with value", init: []string{"-reconfigure=true"}, err: "does not take a value"},
+		{name: "absolute file", plan: []string{"-var-file=/etc/secrets.tfvars"}, err: "relative to the stack"},
+		{name: "plan flag at init", init: []string{"-var-file=prod.tfvars"}, err: "not allowed"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := (&TerraformArgsConfig{InitArgs: tc.init, PlanArgs: tc.plan}).validate()
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestProjectStackArgs(t *testing.T) {
+	project := &ProjectConfig{Terraform: TerraformArgsConfig{
+		InitArgs: []string{"-backend-config=backend.hcl"},
+		PlanArgs: []string{"-lock=false"},
+		Stacks: []StackArgsOverride{
+			{Pattern: "envs/prod/**", InitArgs: []string{"-reconfigure"}},
+			{Pattern: "envs/*/network", PlanArgs: []string{"-parallelism=2"}},
+		},
+	}}
+
+	initArgs, planArgs := project.StackArgs("envs/prod/network")
+	if want := []string{"-backend-config=backend.hcl", "-reconfigure"}; !reflect.DeepEqual(initArgs, want) {
+		t.Fatalf("init args = %v, want %v", initArgs, want)
+	}
+	if want := []string{"-lock=false", "-parallelism=2"}; !reflect.DeepEqual(planArgs, want) {
+		t.Fatalf("plan args = %v, want %v", planArgs, want)
+	}
+
+	initArgs, _ = project.StackArgs("envs/dev/app")
+	if want := []string{"-backend-config=backend.hcl"}; !reflect.DeepEqual(initArgs, want) {
+		t.Fatalf("init args = %v, want %v", initArgs, want)
+	}
+
+	// Appending stack args must not write into the project's slices.
+	project.StackArgs("envs/prod/app")
+	if len(project.Terraform.InitArgs) != 1 {
+		t.Fatalf("project init args modified: %v", project.Terraform.InitArgs)
+	}
+}
+
+func TestProjectStackWeight(t *testing.T) {
+	project := &ProjectConfig{Terraform: TerraformArgsConfig{
+		Stacks: []StackArgsOverride{
+			{Pattern: "envs/prod/**", Weight: 2},
+			{Pattern: "envs/*/data", Weight: 4},
+		},
+	}}
+	for path, want := range map[string]int{
+		"envs/prod/data": 4,
+		"envs/prod/app":  2,
+		"envs/dev/data":  4,
+		"envs/dev/app":   1,
+	} {
+		if got := project.StackWeight(path); got != want {
+			t.Errorf("StackWeight(%q) = %d, want %d", path, got, want)
+		}
+	}
+	if got := (*ProjectConfig)(nil).StackWeight("envs/prod/app"); got != 1 {
+		t.Fatalf("nil project weight = %d, want 1", got)
+	}
+}
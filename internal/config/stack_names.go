@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// StackNameRule gives the stacks whose path matches Pattern, a regular
+// expression, a display name and a group for the UI and API. Name and Group
+// may reference capture groups as $1 or ${name}. Scans always use the
+// canonical stack path.
+type StackNameRule struct {
+	Pattern string `yaml:"pattern"`
+	Name    string `yaml:"name,omitempty"`
+	Group   string `yaml:"group,omitempty"`
+}
+
+// StackDisplay is how a stack is presented. Empty fields mean no rule set
+// them; callers fall back to the stack path.
+type StackDisplay struct {
+	Name  string
+	Group string
+}
+
+func validateStackNames(rules []StackNameRule) error {
+	for i, rule := range rules {
+		if strings.TrimSpace(rule.Pattern) == "" {
+			return fmt.Errorf("stack_names[%d]: pattern is required", i)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("stack_names[%d]: invalid pattern %q: %v", i, rule.Pattern, err)
+		}
+		if strings.TrimSpace(rule.Name) == "" && strings.TrimSpace(rule.Group) == "" {
+			return fmt.Errorf("stack_names[%d]: pattern %q needs a name or a group", i, rule.Pattern)
+		}
+	}
+	return nil
+}
+
+// StackDisplay returns the display name and group of stackPath. Rules are
+// tried in order; the first rule setting a field wins, so a later rule can
+// still supply the group of a stack an earlier rule named.
+func (r *ProjectConfig) StackDisplay(stackPath string) StackDisplay {
+	var out StackDisplay
+	if r == nil {
+		return out
+	}
+	stackPath = strings.Trim(stackPath, "/")
+	for _, rule := range r.StackNames {
+		if out.Name != "" && out.Group != "" {
+			break
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			continue
+		}
+		match := re.FindStringSubmatchIndex(stackPath)
+		if match == nil {
+			continue
+		}
+		if out.Name == "" && rule.Name != "" {
+			out.Name = strings.TrimSpace(string(re.ExpandString(nil, rule.Name, stackPath, match)))
+		}
+		if out.Group == "" && rule.Group != "" {
+			out.Group = strings.TrimSpace(string(re.ExpandString(nil, rule.Group, stackPath, match)))
+		}
+	}
+	return out
+}
+
+func copyStackNames(rules []StackNameRule) []StackNameRule {
+	if rules == nil {
+		return nil
+	}
+	return append([]StackNameRule(nil), rules...)
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStackDisplay(t *testing.T) {
+	project := &ProjectConfig{StackNames: []StackNameRule{
+		{Pattern: `^envs/(?P<env>[^/]+)/[^/]+/(?P<svc>.+?)(-v\d+)?(-blue|-green)?$`, Name: "${svc} (${env})"},
+		{Pattern: `^envs/[^/]+/[^/]+/payments-`, Group: "payments"},
+		{Pattern: `^shared/`, Group: "platform"},
+	}}
+	tests := map[string]StackDisplay{
+		"envs/prod/us-east-1/payments-api-v2-blue": {Name: "payments-api (prod)", Group: "payments"},
+		"envs/dev/eu-west-1/search":                {Name: "search (dev)"},
+		"/shared/dns/":                             {Group: "platform"},
+		"other/stack":                              {},
+	}
+	for path, want := range tests {
+		if got := project.StackDisplay(path); got != want {
+			t.Errorf("StackDisplay(%q) = %+v, want %+v", path, got, want)
+		}
+	}
+
+	var none *ProjectConfig
+	if got := none.StackDisplay("envs/prod/app"); got != (StackDisplay{}) {
+		t.Errorf("nil project: got %+v", got)
+	}
+}
+
+func TestStackNamesValidation(t *testing.T) {
+	for _, tc := range []struct {
+		rule StackNameRule
+		err  string
+	}{
+		{StackNameRule{Name: "x"}, "pattern is required"},
+		{StackNameRule{Pattern: "envs/("}, "invalid pattern"},
+		{StackNameRule{Pattern: "envs/"}, "needs a name or a group"},
+	} {
+		err := validateStackNames([]StackNameRule{tc.rule})
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("rule %+v: expected error containing %q, got %v", tc.rule, tc.err, err)
+		}
+	}
+	if err := validateStackNames([]StackNameRule{{Pattern: "^envs/", Group: "envs"}}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
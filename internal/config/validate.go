@@ -0,0 +1,302 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is one issue found by Validate. Line is 1-based and zero when the
+// problem cannot be tied to a location in the file.
+type Problem struct {
+	Line    int
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	var b strings.Builder
+	if p.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", p.Line)
+	}
+	if p.Path != "" {
+		b.WriteString(p.Path + ": ")
+	}
+	b.WriteString(p.Message)
+	return b.String()
+}
+
+var (
+	yamlLinePattern   = regexp.MustCompile(`line (\d+): (.*)`)
+	errorKeyPattern   = regexp.MustCompile(`^([a-z_]+(?:\.[a-z_]+)+)`)
+	projectErrPattern = regexp.MustCompile(`^projects\[(\d+)\]`)
+	scpURLPattern     = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:[^/].*`)
+)
+
+// Validate checks a config file without applying it and reports every
+// problem it finds: YAML syntax, unknown keys, the checks Load performs, cron
+// expressions, repository URL formats and git auth completeness.
+func Validate(data []byte) []Problem {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return yamlProblems(err)
+	}
+
+	var problems []Problem
+	for _, err := range interpolate(&root) {
+		problems = append(problems, yamlProblems(err)...)
+	}
+	// Unknown keys are checked on the file as written, types on the values
+	// after ${VAR} expansion.
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&Config{}); err != nil && !errors.Is(err, io.EOF) {
+		for _, p := range yamlProblems(err) {
+			if strings.Contains(p.Message, "not found in type") {
+				problems = append(problems, p)
+			}
+		}
+	}
+	// Decode what we can so the remaining checks still run.
+	cfg := &Config{}
+	if root.Kind != 0 {
+		if err := root.Decode(cfg); err != nil {
+			problems = append(problems, yamlProblems(err)...)
+		}
+	}
+
+	doc := &root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+
+	for i := range cfg.Projects {
+		problems = append(problems, validateProjectEntry(doc, i, &cfg.Projects[i])...)
+	}
+
+	if _, err := applyDefaults(cfg); err != nil {
+		for _, e := range unwrapJoined(err) {
+			p := Problem{Message: e.Error()}
+			if m := errorKeyPattern.FindStringSubmatch(p.Message); m != nil {
+				p.Line = lookupLine(doc, strings.Split(m[1], ".")...)
+			} else if m := projectErrPattern.FindStringSubmatch(p.Message); m != nil {
+				p.Line = lookupLine(doc, "projects", m[1])
+			}
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
+
+func validateProjectEntry(doc *yaml.Node, idx int, project *ProjectConfig) []Problem {
+	var problems []Problem
+	base := []string{"projects", strconv.Itoa(idx)}
+	label := fmt.Sprintf("projects[%d]", idx)
+	if project.Name != "" {
+		label += " (" + project.Name + ")"
+	}
+	add := func(field, format string, args ...any) {
+		keys := append(append([]string{}, base...), strings.Split(field, ".")...)
+		line := lookupLine(doc, keys...)
+		if line == 0 {
+			line = lookupLine(doc, base...)
+		}
+		problems = append(problems, Problem{Line: line, Path: label + "." + field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if project.URL != "" {
+		if err := validateRepoURL(project.URL); err != nil {
+			add("url", "%v", err)
+		}
+	}
+	if err := validateSchedule(project.Schedule); err != nil {
+		add("schedule", "%v", err)
+	}
+	for i, sub := range project.Projects {
+		if err := validateSchedule(sub.Schedule); err != nil {
+			add(fmt.Sprintf("projects.%d.schedule", i), "%v", err)
+		}
+	}
+	if project.Git != nil {
+		for _, msg := range gitAuthProblems(project.Git) {
+			add("git", "%s", msg)
+		}
+	}
+	return problems
+}
+
+func validateSchedule(schedule string) error {
+	if strings.TrimSpace(schedule) == "" {
+		return nil
+	}
+	if _, err := cron.ParseStandard(schedule); err != nil {
+		return fmt.Errorf("invalid cron expression %q: %v", schedule, err)
+	}
+	return nil
+}
+
+// validateRepoURL accepts http(s), ssh, git and file URLs, scp-style
+// "user@host:path" addresses and absolute local paths.
+func validateRepoURL(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if scpURLPattern.MatchString(raw) || strings.HasPrefix(raw, "/") {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid repository url %q: %v", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "ssh", "git":
+		if u.Host == "" {
+			return fmt.Errorf("repository url %q has no host", raw)
+		}
+	case "file":
+	default:
+		return fmt.Errorf("repository url %q must use https, ssh, git or file, or be user@host:path", raw)
+	}
+	return nil
+}
+
+func gitAuthProblems(git *GitAuthConfig) []string {
+	var out []string
+	switch git.Type {
+	case "", "none":
+	case "ssh":
+		if git.SSHCertificate != nil {
+			out = append(out, sshCertificateProblems(git.SSHCertificate)...)
+		} else if git.SSHKeyPath == "" && git.SSHKeyEnv == "" {
+			out = append(out, "ssh auth requires ssh_key_path, ssh_key_env or ssh_certificate")
+		}
+	case "https":
+		if git.HTTPSToken == "" && git.HTTPSTokenEnv == "" {
+			out = append(out, "https auth requires https_token or https_token_env")
+		}
+	case "github_app":
+		app := git.GitHubApp
+		if app == nil {
+			out = append(out, "github_app auth requires a github_app block")
+			break
+		}
+		if app.AppID == 0 {
+			out = append(out, "github_app.app_id is required")
+		}
+		if app.InstallationID == 0 {
+			out = append(out, "github_app.installation_id is required")
+		}
+		if app.PrivateKey == "" && app.PrivateKeyPath == "" && app.PrivateKeyEnv == "" {
+			out = append(out, "github_app requires private_key, private_key_path or private_key_env")
+		}
+	default:
+		out = append(out, fmt.Sprintf("unknown auth type %q (want ssh, https or github_app)", git.Type))
+	}
+	if git.ProxyURL != "" {
+		if err := ValidateProxyURL(git.ProxyURL); err != nil {
+			out = append(out, "proxy_url: "+err.Error())
+		}
+	}
+	return out
+}
+
+func sshCertificateProblems(cert *SSHCertificateConfig) []string {
+	var out []string
+	switch cert.Signer {
+	case "vault":
+		if cert.Vault == nil || cert.Vault.Role == "" {
+			out = append(out, "ssh_certificate.vault.role is required")
+		}
+	case "command":
+		if len(cert.Command) == 0 {
+			out = append(out, "ssh_certificate.command is required")
+		}
+	default:
+		out = append(out, fmt.Sprintf("unknown ssh_certificate.signer %q (want vault or command)", cert.Signer))
+	}
+	if cert.TTL < 0 {
+		out = append(out, "ssh_certificate.ttl must not be negative")
+	}
+	return out
+}
+
+// ValidateProxyURL accepts http, https and socks5 proxy URLs with a host.
+func ValidateProxyURL(raw string) error {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("invalid proxy url: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("proxy url must use http, https or socks5")
+	}
+	if u.Host == "" {
+		return fmt.Errorf("proxy url has no host")
+	}
+	return nil
+}
+
+// yamlProblems converts yaml.v3 errors, which embed "line N:" in each
+// message, into problems.
+func yamlProblems(err error) []Problem {
+	var msgs []string
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		msgs = typeErr.Errors
+	} else {
+		msgs = []string{strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+	problems := make([]Problem, 0, len(msgs))
+	for _, msg := range msgs {
+		p := Problem{Message: msg}
+		if m := yamlLinePattern.FindStringSubmatch(msg); m != nil {
+			p.Line, _ = strconv.Atoi(m[1])
+			p.Message = m[2]
+		}
+		problems = append(problems, p)
+	}
+	return problems
+}
+
+// lookupLine follows mapping keys and sequence indexes from node and returns
+// the line of the deepest node found, or 0 if the first key is missing.
+func lookupLine(node *yaml.Node, keys ...string) int {
+	line := 0
+	for _, key := range keys {
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					line = node.Content[i].Line
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if idx, err := strconv.Atoi(key); err == nil && idx >= 0 && idx < len(node.Content) {
+				next = node.Content[idx]
+				line = next.Line
+			}
+		}
+		if next == nil {
+			return line
+		}
+		node = next
+	}
+	return line
+}
+
+func unwrapJoined(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateReportsAllProblemsWithLines(t *testing.T) {
+	data := []byte(`worker:
+  clone_depth: -1
+  bogus_key: true
+projects:
+  - name: infra
+    url: ftp://example.com/infra.git
+    schedule: "every day"
+    git:
+      type: ssh
+  - name: ok
+    url: git@github.com:org/ok.git
+    schedule: "0 * * * *"
+`)
+	problems := Validate(data)
+
+	want := map[string]int{
+		"bogus_key":         3,
+		"clone_depth":       2,
+		"repository url":    6,
+		"invalid cron":      7,
+		"ssh auth requires": 8,
+	}
+	for needle, line := range want {
+		found := false
+		for _, p := range problems {
+			if strings.Contains(p.Message, needle) {
+				found = true
+				if p.Line != line {
+					t.Errorf("%q reported on line %d, want %d", needle, p.Line, line)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("missing problem %q in %v", needle, problems)
+		}
+	}
+	for _, p := range problems {
+		if strings.Contains(p.Path, "(ok)") {
+			t.Errorf("unexpected problem for valid project: %v", p)
+		}
+	}
+}
+
+func TestValidateCleanConfig(t *testing.T) {
+	if problems := Validate([]byte("projects:\n  - name: infra\n    url: https://example.com/infra.git\n")); len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+	if problems := Validate(nil); len(problems) != 0 {
+		t.Fatalf("expected no problems for empty config, got %v", problems)
+	}
+}
+
+func TestValidateSyntaxError(t *testing.T) {
+	problems := Validate([]byte("projects:\n  - name: infra\n   url: x\n"))
+	if len(problems) != 1 || problems[0].Line == 0 {
+		t.Fatalf("expected one located syntax problem, got %v", problems)
+	}
+}
+
+func TestValidateSSHCertificate(t *testing.T) {
+	problems := Validate([]byte(`projects:
+  - name: ca
+    url: git@github.com:org/ca.git
+    git:
+      type: ssh
+      ssh_certificate:
+        signer: vault
+        vault:
+          role: driftd
+  - name: bad
+    url: git@github.com:org/bad.git
+    git:
+      type: ssh
+      ssh_certificate:
+        signer: command
+`))
+	if len(problems) != 1 || !strings.Contains(problems[0].Message, "ssh_certificate.command") {
+		t.Fatalf("expected only the missing command, got %v", problems)
+	}
+}
+
+func TestValidateInterpolatesEnv(t *testing.T) {
+	t.Setenv("DRIFTD_TEST_CONCURRENCY", "4")
+	problems := Validate([]byte(`worker:
+  concurrency: ${DRIFTD_TEST_CONCURRENCY}
+redis:
+  password: ${DRIFTD_TEST_UNSET}
+`))
+	if len(problems) != 1 || problems[0].Line != 4 || !strings.Contains(problems[0].Message, "DRIFTD_TEST_UNSET is not set") {
+		t.Fatalf("expected only the unset variable problem, got %v", problems)
+	}
+}
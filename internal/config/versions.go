@@ -0,0 +1,19 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var toolVersionPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.]+)?$`)
+
+// ValidateToolVersion checks a terraform or terragrunt version such as
+// "1.6.2" or "1.7.0-rc1". The empty string means no default and is valid.
+// Versions name a directory in the binary cache, so anything else is
+// rejected.
+func ValidateToolVersion(v string) error {
+	if v == "" || toolVersionPattern.MatchString(v) {
+		return nil
+	}
+	return fmt.Errorf("invalid version %q (expected e.g. 1.6.2)", v)
+}
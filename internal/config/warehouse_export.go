@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultWarehouseExportInterval = time.Hour
+	minWarehouseExportInterval     = time.Minute
+)
+
+// WarehouseExportConfig writes scan and stack result summaries as
+// newline-delimited JSON to a date-partitioned path for analytics.
+type WarehouseExportConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is how often new results are exported.
+	Interval time.Duration `yaml:"interval"`
+	// Destination is s3://bucket/prefix, or a local directory (optionally
+	// file://) such as a mounted bucket.
+	Destination string `yaml:"destination"`
+	// S3 configures s3:// destinations. Any S3-compatible store works,
+	// including GCS through its interoperability endpoint.
+	S3 S3ExportConfig `yaml:"s3"`
+}
+
+// S3ExportConfig holds the endpoint and credentials for S3 uploads.
+type S3ExportConfig struct {
+	Endpoint           string `yaml:"endpoint"` // default https://s3.<region>.amazonaws.com
+	Region             string `yaml:"region"`   // default us-east-1
+	AccessKeyIDEnv     string `yaml:"access_key_id_env"`
+	SecretAccessKeyEnv string `yaml:"secret_access_key_env"`
+	SessionTokenEnv    string `yaml:"session_token_env"`
+}
+
+func applyWarehouseExportDefaults(cfg *Config) []error {
+	w := &cfg.WarehouseExport
+	if !w.Enabled {
+		return nil
+	}
+	var errs []error
+	if w.Interval == 0 {
+		w.Interval = defaultWarehouseExportInterval
+	}
+	if w.Interval < minWarehouseExportInterval {
+		errs = append(errs, fmt.Errorf("warehouse_export.interval must be at least %s", minWarehouseExportInterval))
+	}
+	w.Destination = strings.TrimSpace(w.Destination)
+	switch {
+	case w.Destination == "":
+		errs = append(errs, fmt.Errorf("warehouse_export.destination is required"))
+	case strings.HasPrefix(w.Destination, "s3://"):
+		if strings.Trim(strings.TrimPrefix(w.Destination, "s3://"), "/") == "" {
+			errs = append(errs, fmt.Errorf("warehouse_export.destination must name a bucket"))
+		}
+		if w.S3.Region == "" {
+			w.S3.Region = "us-east-1"
+		}
+		if w.S3.AccessKeyIDEnv == "" {
+			w.S3.AccessKeyIDEnv = "AWS_ACCESS_KEY_ID"
+		}
+		if w.S3.SecretAccessKeyEnv == "" {
+			w.S3.SecretAccessKeyEnv = "AWS_SECRET_ACCESS_KEY"
+		}
+		if w.S3.SessionTokenEnv == "" {
+			w.S3.SessionTokenEnv = "AWS_SESSION_TOKEN"
+		}
+	case strings.Contains(w.Destination, "://") && !strings.HasPrefix(w.Destination, "file://"):
+		errs = append(errs, fmt.Errorf("warehouse_export.destination must be s3://, file:// or a path"))
+	}
+	return errs
+}
@@ -0,0 +1,44 @@
+package config
+
+import "fmt"
+
+const (
+	defaultWarmPoolStacks       = 10
+	defaultWarmPoolMaxDiskBytes = 5 << 30
+)
+
+// WarmPoolConfig keeps a terraform data directory initialized per hot stack
+// on each worker, so scans of the stacks a worker plans most often skip
+// terraform init while the stack's init inputs are unchanged.
+type WarmPoolConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Stacks is how many of the worker's most frequently planned stacks
+	// are kept warm. Default 10.
+	Stacks int `yaml:"stacks"`
+	// MaxDiskBytes caps the disk the pool uses; the least frequently
+	// planned stacks are dropped first. Default 5 GiB.
+	MaxDiskBytes int64 `yaml:"max_disk_bytes"`
+	// Dir holds the pool. Defaults to a directory under the system temp dir.
+	Dir string `yaml:"dir"`
+}
+
+func applyWarmPoolDefaults(cfg *Config) []error {
+	p := &cfg.Worker.WarmPool
+	if !p.Enabled {
+		return nil
+	}
+	var errs []error
+	if p.Stacks == 0 {
+		p.Stacks = defaultWarmPoolStacks
+	}
+	if p.MaxDiskBytes == 0 {
+		p.MaxDiskBytes = defaultWarmPoolMaxDiskBytes
+	}
+	if p.Stacks < 0 {
+		errs = append(errs, fmt.Errorf("worker.warm_pool.stacks must be positive"))
+	}
+	if p.MaxDiskBytes < 0 {
+		errs = append(errs, fmt.Errorf("worker.warm_pool.max_disk_bytes must be positive"))
+	}
+	return errs
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	defaultWorkspacePruneInterval       = time.Hour
+	defaultWorkspacePruneFailedAfter    = 24 * time.Hour
+	defaultWorkspacePruneCompletedAfter = 7 * 24 * time.Hour
+	minWorkspacePruneInterval           = time.Minute
+)
+
+// WorkspacePruneConfig deletes scan workspaces on an interval by the status
+// of the scan that created them, independently of workspace.retention.
+type WorkspacePruneConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Interval is how often workspaces are checked.
+	Interval time.Duration `yaml:"interval"`
+	// FailedAfter is how long workspaces of failed and canceled scans are
+	// kept after the scan ended. Negative keeps them.
+	FailedAfter time.Duration `yaml:"failed_after"`
+	// CompletedAfter is how long workspaces of completed scans, and of
+	// scans that have expired from the queue, are kept. Negative keeps them.
+	CompletedAfter time.Duration `yaml:"completed_after"`
+}
+
+func applyWorkspacePruneDefaults(cfg *Config) []error {
+	p := &cfg.Workspace.Prune
+	if !p.Enabled {
+		return nil
+	}
+	var errs []error
+	if p.Interval == 0 {
+		p.Interval = defaultWorkspacePruneInterval
+	}
+	if p.FailedAfter == 0 {
+		p.FailedAfter = defaultWorkspacePruneFailedAfter
+	}
+	if p.CompletedAfter == 0 {
+		p.CompletedAfter = defaultWorkspacePruneCompletedAfter
+	}
+	if p.Interval < minWorkspacePruneInterval {
+		errs = append(errs, fmt.Errorf("workspace.prune.interval must be at least %s", minWorkspacePruneInterval))
+	}
+	return errs
+}
@@ -0,0 +1,215 @@
+// Package credcheck verifies that the cloud credentials a worker plans with
+// cannot make changes, as a safety rail against running drift scans with
+// admin access.
+package credcheck
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+const (
+	checkTimeout    = time.Minute
+	maxReasonLength = 512
+)
+
+// Result is the outcome of one credential check.
+type Result struct {
+	// Principal is the identity that was checked, when known.
+	Principal string
+	// Allowed lists the canary write actions the principal may perform.
+	Allowed []string
+	// Reason is the output of a custom check command that failed.
+	Reason    string
+	CheckedAt time.Time
+}
+
+// ReadOnly reports whether the credentials passed the check.
+func (r *Result) ReadOnly() bool {
+	return len(r.Allowed) == 0 && r.Reason == ""
+}
+
+// Problem describes why the credentials are not read-only.
+func (r *Result) Problem() string {
+	switch {
+	case len(r.Allowed) > 0 && r.Principal != "":
+		return fmt.Sprintf("%s is allowed write actions: %s", r.Principal, strings.Join(r.Allowed, ", "))
+	case len(r.Allowed) > 0:
+		return fmt.Sprintf("credentials are allowed write actions: %s", strings.Join(r.Allowed, ", "))
+	case r.Reason != "":
+		return "credentials are not read-only: " + r.Reason
+	}
+	return ""
+}
+
+// Checker runs credential checks and caches their results per project.
+// Failed checks are not cached.
+type Checker struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+
+	awsCLI string
+	now    func() time.Time
+}
+
+type entry struct {
+	mu     sync.Mutex
+	result *Result
+}
+
+// New returns a Checker that calls the aws CLI on PATH.
+func New() *Checker {
+	return &Checker{
+		entries: make(map[string]*entry),
+		awsCLI:  "aws",
+		now:     time.Now,
+	}
+}
+
+// Check returns the cached or fresh result of project's credential check.
+func (c *Checker) Check(ctx context.Context, project *config.ProjectConfig) (*Result, error) {
+	cfg := project.CredentialCheck
+	cacheFor := cfg.CacheFor
+	if cacheFor <= 0 {
+		cacheFor = time.Hour
+	}
+
+	c.mu.Lock()
+	e, ok := c.entries[project.Name]
+	if !ok {
+		e = &entry{}
+		c.entries[project.Name] = e
+	}
+	c.mu.Unlock()
+
+	// Stacks of one scan start together; let one of them run the check.
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.result != nil && c.now().Sub(e.result.CheckedAt) < cacheFor {
+		return e.result, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	var (
+		result *Result
+		err    error
+	)
+	switch {
+	case len(cfg.Command) > 0:
+		result, err = runCommand(ctx, cfg.Command)
+	case cfg.AWS != nil:
+		result, err = c.checkAWS(ctx, cfg.AWS)
+	default:
+		return nil, fmt.Errorf("no credential check configured")
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.CheckedAt = c.now()
+	e.result = result
+	return result, nil
+}
+
+func runCommand(ctx context.Context, argv []string) (*Result, error) {
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		return &Result{}, nil
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || ctx.Err() != nil {
+		return nil, fmt.Errorf("credential check command: %w", err)
+	}
+	reason := strings.TrimSpace(string(out))
+	if reason == "" {
+		reason = exitErr.Error()
+	}
+	if len(reason) > maxReasonLength {
+		reason = reason[:maxReasonLength] + "..."
+	}
+	return &Result{Reason: reason}, nil
+}
+
+func (c *Checker) checkAWS(ctx context.Context, cfg *config.AWSCredentialCheckConfig) (*Result, error) {
+	principal := cfg.PrincipalARN
+	if principal == "" {
+		var identity struct {
+			Arn string `json:"Arn"`
+		}
+		if err := c.aws(ctx, &identity, "sts", "get-caller-identity"); err != nil {
+			return nil, err
+		}
+		if identity.Arn == "" {
+			return nil, fmt.Errorf("aws sts get-caller-identity returned no ARN")
+		}
+		principal = principalARN(identity.Arn)
+	}
+
+	actions := cfg.Actions
+	if len(actions) == 0 {
+		actions = config.DefaultCredentialCheckActions
+	}
+	args := []string{"iam", "simulate-principal-policy", "--policy-source-arn", principal, "--action-names"}
+	args = append(args, actions...)
+	var sim struct {
+		EvaluationResults []struct {
+			EvalActionName string `json:"EvalActionName"`
+			EvalDecision   string `json:"EvalDecision"`
+		} `json:"EvaluationResults"`
+	}
+	if err := c.aws(ctx, &sim, args...); err != nil {
+		return nil, err
+	}
+	if len(sim.EvaluationResults) == 0 {
+		return nil, fmt.Errorf("aws iam simulate-principal-policy returned no results")
+	}
+
+	result := &Result{Principal: principal}
+	for _, eval := range sim.EvaluationResults {
+		if eval.EvalDecision == "allowed" {
+			result.Allowed = append(result.Allowed, eval.EvalActionName)
+		}
+	}
+	return result, nil
+}
+
+func (c *Checker) aws(ctx context.Context, out any, args ...string) error {
+	args = append(args, "--output", "json")
+	cmd := exec.CommandContext(ctx, c.awsCLI, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return fmt.Errorf("aws %s %s: %w", args[0], args[1], err)
+		}
+		return fmt.Errorf("aws %s %s: %w: %s", args[0], args[1], err, msg)
+	}
+	if err := json.Unmarshal(stdout, out); err != nil {
+		return fmt.Errorf("aws %s %s: decode output: %w", args[0], args[1], err)
+	}
+	return nil
+}
+
+// principalARN maps an STS assumed-role ARN to the IAM role ARN that
+// simulate-principal-policy accepts. Other ARNs are returned unchanged.
+func principalARN(arn string) string {
+	// arn:<partition>:sts::<account>:assumed-role/<role>/<session>
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[2] != "sts" || !strings.HasPrefix(parts[5], "assumed-role/") {
+		return arn
+	}
+	role, _, _ := strings.Cut(strings.TrimPrefix(parts[5], "assumed-role/"), "/")
+	return fmt.Sprintf("arn:%s:iam::%s:role/%s", parts[1], parts[4], role)
+}
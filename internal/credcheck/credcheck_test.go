@@ -0,0 +1,144 @@
+package credcheck
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+// fakeAWS writes an aws CLI stand-in that answers get-caller-identity and
+// allows the actions in allowed, logging its arguments to calls.
+func fakeAWS(t *testing.T, allowed string) (bin, calls string) {
+	t.Helper()
+	dir := t.TempDir()
+	calls = filepath.Join(dir, "calls")
+	bin = filepath.Join(dir, "aws")
+	script := `#!/bin/sh
+echo "$@" >> ` + calls + `
+case "$1 $2" in
+"sts get-caller-identity")
+  echo '{"Arn":"arn:aws:sts::123456789012:assumed-role/driftd-plan/i-abc"}' ;;
+"iam simulate-principal-policy")
+  shift 5
+  sep=""
+  printf '{"EvaluationResults":['
+  for a in "$@"; do
+    [ "$a" = "--output" ] && break
+    d=implicitDeny
+    case " ` + allowed + ` " in *" $a "*) d=allowed ;; esac
+    printf '%s{"EvalActionName":"%s","EvalDecision":"%s"}' "$sep" "$a" "$d"
+    sep=","
+  done
+  echo ']}' ;;
+*) echo "unexpected $*" >&2; exit 2 ;;
+esac
+`
+	if err := os.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatalf("write fake aws: %v", err)
+	}
+	return bin, calls
+}
+
+func TestCheckAWS(t *testing.T) {
+	bin, calls := fakeAWS(t, "iam:CreateAccessKey")
+	c := New()
+	c.awsCLI = bin
+	project := &config.ProjectConfig{Name: "infra", CredentialCheck: config.CredentialCheckConfig{
+		Mode: config.CredentialCheckStrict,
+		AWS:  &config.AWSCredentialCheckConfig{Actions: []string{"s3:PutObject", "iam:CreateAccessKey"}},
+	}}
+
+	res, err := c.Check(context.Background(), project)
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if res.ReadOnly() || len(res.Allowed) != 1 || res.Allowed[0] != "iam:CreateAccessKey" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if res.Principal != "arn:aws:iam::123456789012:role/driftd-plan" {
+		t.Fatalf("principal = %q", res.Principal)
+	}
+	if !strings.Contains(res.Problem(), "allowed write actions: iam:CreateAccessKey") {
+		t.Fatalf("problem = %q", res.Problem())
+	}
+
+	// Cached until cache_for passes.
+	if _, err := c.Check(context.Background(), project); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	data, _ := os.ReadFile(calls)
+	if n := strings.Count(string(data), "\n"); n != 2 {
+		t.Fatalf("expected one cached check (2 aws calls), got %d:\n%s", n, data)
+	}
+	c.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, err := c.Check(context.Background(), project); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	data, _ = os.ReadFile(calls)
+	if n := strings.Count(string(data), "\n"); n != 4 {
+		t.Fatalf("expected a fresh check after expiry, got %d calls", n)
+	}
+}
+
+func TestCheckAWSReadOnlyWithPrincipal(t *testing.T) {
+	bin, calls := fakeAWS(t, "")
+	c := New()
+	c.awsCLI = bin
+	project := &config.ProjectConfig{Name: "infra", CredentialCheck: config.CredentialCheckConfig{
+		Mode: config.CredentialCheckWarn,
+		AWS:  &config.AWSCredentialCheckConfig{PrincipalARN: "arn:aws:iam::1:role/ops/plan"},
+	}}
+	res, err := c.Check(context.Background(), project)
+	if err != nil || !res.ReadOnly() {
+		t.Fatalf("expected read-only, got %+v %v", res, err)
+	}
+	data, _ := os.ReadFile(calls)
+	if strings.Contains(string(data), "get-caller-identity") || !strings.Contains(string(data), "arn:aws:iam::1:role/ops/plan --action-names s3:PutObject") {
+		t.Fatalf("unexpected aws calls:\n%s", data)
+	}
+}
+
+func TestCheckCommand(t *testing.T) {
+	c := New()
+	project := &config.ProjectConfig{Name: "gcp", CredentialCheck: config.CredentialCheckConfig{
+		Mode:    config.CredentialCheckStrict,
+		Command: []string{"sh", "-c", "echo roles/owner granted; exit 1"},
+	}}
+	res, err := c.Check(context.Background(), project)
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if res.ReadOnly() || res.Reason != "roles/owner granted" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	project.Name = "ok"
+	project.CredentialCheck.Command = []string{"true"}
+	if res, err := c.Check(context.Background(), project); err != nil || !res.ReadOnly() {
+		t.Fatalf("expected read-only, got %+v %v", res, err)
+	}
+
+	project.Name = "missing"
+	project.CredentialCheck.Command = []string{filepath.Join(t.TempDir(), "nope")}
+	if _, err := c.Check(context.Background(), project); err == nil {
+		t.Fatal("expected an error when the command cannot run")
+	}
+}
+
+func TestPrincipalARN(t *testing.T) {
+	cases := map[string]string{
+		"arn:aws:sts::123:assumed-role/plan/session":    "arn:aws:iam::123:role/plan",
+		"arn:aws-cn:sts::123:assumed-role/plan/session": "arn:aws-cn:iam::123:role/plan",
+		"arn:aws:iam::123:user/ci":                      "arn:aws:iam::123:user/ci",
+	}
+	for in, want := range cases {
+		if got := principalARN(in); got != want {
+			t.Errorf("principalARN(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package federation merges the project overviews of peer driftd instances
+// into one read-only view.
+//
+// Every instance serves its own Overview at OverviewPath. An instance with
+// federation enabled fetches its peers' overviews with their API read
+// tokens, caches them for federation.cache_for, and lists each project with
+// a link to the instance that owns it.
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+// OverviewPath is the API route that serves an instance's Overview.
+const OverviewPath = "/api/federation/overview"
+
+// maxOverviewBytes caps the size of a peer's response.
+const maxOverviewBytes = 8 << 20
+
+// Overview summarizes the projects of one instance.
+type Overview struct {
+	Projects []Project `json:"projects"`
+}
+
+// Project is one project's drift summary.
+type Project struct {
+	Name          string `json:"name"`
+	Drifted       bool   `json:"drifted"`
+	Stacks        int    `json:"stacks"`
+	DriftedStacks int    `json:"drifted_stacks"`
+	ErrorStacks   int    `json:"error_stacks"`
+	// LastRun is when the last scan ended, or started if one is running,
+	// in Unix seconds.
+	LastRun int64 `json:"last_run,omitempty"`
+	Active  bool  `json:"active,omitempty"`
+}
+
+// LastRunAt returns LastRun as a time; zero when the project never ran.
+func (p Project) LastRunAt() time.Time {
+	if p.LastRun == 0 {
+		return time.Time{}
+	}
+	return time.Unix(p.LastRun, 0)
+}
+
+// Instance is one instance's part of the merged view.
+type Instance struct {
+	Name string
+	// URL is the instance's base URL; empty for this instance, whose links
+	// are relative.
+	URL       string
+	Projects  []Project
+	FetchedAt time.Time
+	// Error is set when the peer could not be reached. Projects then hold
+	// its last good overview, if any.
+	Error string
+}
+
+// ProjectURL links to a project on the instance that owns it.
+func (i Instance) ProjectURL(name string) string {
+	return i.URL + "/projects/" + url.PathEscape(name)
+}
+
+// Totals adds up the instance's stack counts.
+func (i Instance) Totals() (stacks, drifted, errored int) {
+	for _, p := range i.Projects {
+		stacks += p.Stacks
+		drifted += p.DriftedStacks
+		errored += p.ErrorStacks
+	}
+	return stacks, drifted, errored
+}
+
+// Federation fetches and caches peer overviews.
+type Federation struct {
+	cfg  config.FederationConfig
+	http *http.Client
+	now  func() time.Time
+
+	mu    sync.Mutex
+	peers map[string]*peerState
+}
+
+type peerState struct {
+	mu        sync.Mutex
+	overview  *Overview
+	fetchedAt time.Time
+	checkedAt time.Time
+	err       error
+}
+
+// New returns a Federation for cfg's peers.
+func New(cfg config.FederationConfig) *Federation {
+	return &Federation{
+		cfg:   cfg,
+		http:  &http.Client{Timeout: cfg.Timeout},
+		now:   time.Now,
+		peers: make(map[string]*peerState),
+	}
+}
+
+// Merge returns local as this instance followed by every peer, in config
+// order. Peers are queried concurrently; one that fails is reported with
+// its error instead of failing the view.
+func (f *Federation) Merge(ctx context.Context, local *Overview) []Instance {
+	out := make([]Instance, len(f.cfg.Peers)+1)
+	out[0] = Instance{Name: f.cfg.Name, Projects: local.Projects, FetchedAt: f.now()}
+	var wg sync.WaitGroup
+	for i, peer := range f.cfg.Peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out[i+1] = f.peer(ctx, peer)
+		}()
+	}
+	wg.Wait()
+	for i := range out {
+		sort.Slice(out[i].Projects, func(a, b int) bool {
+			return out[i].Projects[a].Name < out[i].Projects[b].Name
+		})
+	}
+	return out
+}
+
+func (f *Federation) peer(ctx context.Context, peer config.FederationPeer) Instance {
+	f.mu.Lock()
+	st, ok := f.peers[peer.Name]
+	if !ok {
+		st = &peerState{}
+		f.peers[peer.Name] = st
+	}
+	f.mu.Unlock()
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.checkedAt.IsZero() || f.now().Sub(st.checkedAt) >= f.cfg.CacheFor {
+		overview, err := f.fetch(ctx, peer)
+		st.checkedAt = f.now()
+		st.err = err
+		if err == nil {
+			st.overview = overview
+			st.fetchedAt = st.checkedAt
+		}
+	}
+
+	inst := Instance{Name: peer.Name, URL: peer.URL, FetchedAt: st.fetchedAt}
+	if st.overview != nil {
+		inst.Projects = append([]Project(nil), st.overview.Projects...)
+	}
+	if st.err != nil {
+		inst.Error = st.err.Error()
+	}
+	return inst
+}
+
+func (f *Federation) fetch(ctx context.Context, peer config.FederationPeer) (*Overview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, peer.URL+OverviewPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if peer.TokenEnv != "" {
+		if token := strings.TrimSpace(os.Getenv(peer.TokenEnv)); token != "" {
+			req.Header.Set(peer.TokenHeader, token)
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := f.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("peer %s: %w", peer.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("peer %s: %s", peer.Name, resp.Status)
+	}
+	var overview Overview
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxOverviewBytes)).Decode(&overview); err != nil {
+		return nil, fmt.Errorf("peer %s: decode overview: %w", peer.Name, err)
+	}
+	return &overview, nil
+}
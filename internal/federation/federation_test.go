@@ -0,0 +1,82 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+func TestMerge(t *testing.T) {
+	t.Setenv("PAYMENTS_TOKEN", "s3cret")
+	var calls atomic.Int32
+	var healthy atomic.Bool
+	healthy.Store(true)
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != OverviewPath || r.Header.Get("X-API-Token") != "s3cret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !healthy.Load() {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Overview{Projects: []Project{
+			{Name: "ledger", Stacks: 4, DriftedStacks: 1, Drifted: true},
+			{Name: "billing", Stacks: 2},
+		}})
+	}))
+	defer peer.Close()
+
+	f := New(config.FederationConfig{
+		Name:     "platform",
+		Timeout:  time.Second,
+		CacheFor: time.Minute,
+		Peers: []config.FederationPeer{
+			{Name: "payments", URL: peer.URL, TokenEnv: "PAYMENTS_TOKEN", TokenHeader: "X-API-Token"},
+			{Name: "retail", URL: peer.URL, TokenHeader: "X-API-Token"},
+		},
+	})
+	now := time.Unix(100000, 0)
+	f.now = func() time.Time { return now }
+	local := &Overview{Projects: []Project{{Name: "infra", Stacks: 3}}}
+
+	got := f.Merge(context.Background(), local)
+	if len(got) != 3 || got[0].Name != "platform" || got[0].ProjectURL("infra") != "/projects/infra" {
+		t.Fatalf("unexpected local instance: %+v", got)
+	}
+	payments := got[1]
+	if payments.Error != "" || len(payments.Projects) != 2 || payments.Projects[0].Name != "billing" {
+		t.Fatalf("unexpected payments instance: %+v", payments)
+	}
+	if payments.ProjectURL("ledger") != peer.URL+"/projects/ledger" {
+		t.Fatalf("project url = %q", payments.ProjectURL("ledger"))
+	}
+	if stacks, drifted, _ := payments.Totals(); stacks != 6 || drifted != 1 {
+		t.Fatalf("totals = %d/%d", stacks, drifted)
+	}
+	// retail has no token and is refused.
+	if got[2].Error == "" || len(got[2].Projects) != 0 {
+		t.Fatalf("expected retail to fail: %+v", got[2])
+	}
+
+	// Cached until cache_for passes.
+	f.Merge(context.Background(), local)
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("expected cached overviews, got %d calls", n)
+	}
+
+	// A peer that goes down keeps its last overview, flagged with the error.
+	healthy.Store(false)
+	now = now.Add(2 * time.Minute)
+	got = f.Merge(context.Background(), local)
+	if got[1].Error == "" || len(got[1].Projects) != 2 || !got[1].FetchedAt.Equal(time.Unix(100000, 0)) {
+		t.Fatalf("expected stale payments overview: %+v", got[1])
+	}
+}
@@ -0,0 +1,299 @@
+// Package freeze tracks environments whose drift is frozen, for example
+// during an incident. Scans keep running and recording results while an
+// environment is frozen, but drift they find is marked as observed during
+// the freeze and does not notify anyone.
+//
+// Like maintenance mode, the state lives in files under the data directory
+// so that servers and workers sharing it agree on which environments are
+// frozen. Every freeze, unfreeze and expiry is appended to an audit log next
+// to the state file.
+package freeze
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	stateFileName = "freeze.json"
+	auditFileName = "freeze_audit.jsonl"
+)
+
+const (
+	// DefaultDuration is how long a freeze lasts when no expiry is given.
+	DefaultDuration = 4 * time.Hour
+	// MaxDuration bounds a freeze so a forgotten one cannot silence an
+	// environment indefinitely.
+	MaxDuration = 7 * 24 * time.Hour
+)
+
+// Audit actions.
+const (
+	ActionFreeze   = "freeze"
+	ActionUnfreeze = "unfreeze"
+	ActionExpire   = "expire"
+)
+
+// ErrNotFrozen is returned when unfreezing an environment that is not frozen.
+var ErrNotFrozen = errors.New("environment is not frozen")
+
+// Freeze is an active freeze of one environment.
+type Freeze struct {
+	Environment string    `json:"environment"`
+	Reason      string    `json:"reason,omitempty"`
+	Actor       string    `json:"actor,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// AuditEntry records one change to the freeze state.
+type AuditEntry struct {
+	Time        time.Time  `json:"time"`
+	Action      string     `json:"action"`
+	Environment string     `json:"environment"`
+	Actor       string     `json:"actor,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// Store reads and writes the freeze state. Like maintenance.Mode it re-reads
+// the file on every call.
+type Store struct {
+	dir string
+	mu  sync.Mutex
+	now func() time.Time
+}
+
+// New returns a Store backed by files in dataDir.
+func New(dataDir string) *Store {
+	return &Store{dir: dataDir, now: time.Now}
+}
+
+// Frozen returns the active freeze of env. Expired freezes are removed and
+// audited on the way.
+func (s *Store) Frozen(env string) (Freeze, bool) {
+	if s == nil || env == "" {
+		return Freeze{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, err := s.activeLocked()
+	if err != nil {
+		return Freeze{}, false
+	}
+	f, ok := state[env]
+	return f, ok
+}
+
+// List returns the active freezes sorted by environment.
+func (s *Store) List() ([]Freeze, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, err := s.activeLocked()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Freeze, 0, len(state))
+	for _, f := range state {
+		out = append(out, f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Environment < out[j].Environment })
+	return out, nil
+}
+
+// Freeze freezes env until expiresAt. Freezing a frozen environment replaces
+// its reason and expiry but keeps the original start time.
+func (s *Store) Freeze(env, reason, actor string, expiresAt time.Time) (Freeze, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now().UTC()
+	if !expiresAt.After(now) {
+		return Freeze{}, fmt.Errorf("expiry must be in the future")
+	}
+	if expiresAt.Sub(now) > MaxDuration {
+		return Freeze{}, fmt.Errorf("freeze cannot last longer than %s", MaxDuration)
+	}
+	state, err := s.activeLocked()
+	if err != nil {
+		return Freeze{}, err
+	}
+	f := Freeze{
+		Environment: env,
+		Reason:      reason,
+		Actor:       actor,
+		StartedAt:   now,
+		ExpiresAt:   expiresAt.UTC(),
+	}
+	if current, ok := state[env]; ok {
+		f.StartedAt = current.StartedAt
+	}
+	state[env] = f
+	if err := s.saveLocked(state); err != nil {
+		return Freeze{}, err
+	}
+	s.auditLocked(AuditEntry{Time: now, Action: ActionFreeze, Environment: env, Actor: actor, Reason: reason, ExpiresAt: &f.ExpiresAt})
+	return f, nil
+}
+
+// Unfreeze lifts the freeze of env.
+func (s *Store) Unfreeze(env, actor string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state, err := s.activeLocked()
+	if err != nil {
+		return err
+	}
+	if _, ok := state[env]; !ok {
+		return ErrNotFrozen
+	}
+	delete(state, env)
+	if err := s.saveLocked(state); err != nil {
+		return err
+	}
+	s.auditLocked(AuditEntry{Time: s.now().UTC(), Action: ActionUnfreeze, Environment: env, Actor: actor})
+	return nil
+}
+
+// Audit returns up to limit audit entries, newest first. limit <= 0 returns
+// them all.
+func (s *Store) Audit(limit int) ([]AuditEntry, error) {
+	if s == nil {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Record pending expiries before reading the log.
+	if _, err := s.activeLocked(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(s.dir, auditFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []AuditEntry{}, nil
+		}
+		return nil, fmt.Errorf("failed to read freeze audit log: %w", err)
+	}
+	defer f.Close()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e AuditEntry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read freeze audit log: %w", err)
+	}
+	out := make([]AuditEntry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		out = append(out, entries[i])
+	}
+	return out, nil
+}
+
+// activeLocked loads the state and drops freezes that have expired, saving
+// and auditing the change.
+func (s *Store) activeLocked() (map[string]Freeze, error) {
+	state, err := s.loadLocked()
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	var expired []Freeze
+	for env, f := range state {
+		if !now.Before(f.ExpiresAt) {
+			expired = append(expired, f)
+			delete(state, env)
+		}
+	}
+	if len(expired) == 0 {
+		return state, nil
+	}
+	if err := s.saveLocked(state); err != nil {
+		return nil, err
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].Environment < expired[j].Environment })
+	for _, f := range expired {
+		expiresAt := f.ExpiresAt
+		s.auditLocked(AuditEntry{Time: f.ExpiresAt, Action: ActionExpire, Environment: f.Environment, ExpiresAt: &expiresAt})
+	}
+	return state, nil
+}
+
+func (s *Store) loadLocked() (map[string]Freeze, error) {
+	state := make(map[string]Freeze)
+	raw, err := os.ReadFile(filepath.Join(s.dir, stateFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, fmt.Errorf("failed to read freeze state: %w", err)
+	}
+	var list []Freeze
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse freeze state: %w", err)
+	}
+	for _, f := range list {
+		state[f.Environment] = f
+	}
+	return state, nil
+}
+
+func (s *Store) saveLocked(state map[string]Freeze) error {
+	path := filepath.Join(s.dir, stateFileName)
+	if len(state) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove freeze state: %w", err)
+		}
+		return nil
+	}
+	list := make([]Freeze, 0, len(state))
+	for _, f := range state {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Environment < list[j].Environment })
+	raw, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal freeze state: %w", err)
+	}
+	if err := os.MkdirAll(s.dir, 0750); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write freeze state: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// auditLocked appends e to the audit log. A failed write is not fatal: the
+// state change has already been saved.
+func (s *Store) auditLocked(e AuditEntry) {
+	raw, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(s.dir, 0750); err != nil {
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, auditFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	_, _ = f.Write(append(raw, '\n'))
+}
@@ -0,0 +1,92 @@
+package freeze
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStoreFreezeAndUnfreeze(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir)
+	if _, ok := s.Frozen("prod"); ok {
+		t.Fatalf("expected prod unfrozen by default")
+	}
+
+	expires := time.Now().Add(time.Hour)
+	f, err := s.Freeze("prod", "incident 42", "oncall", expires)
+	if err != nil {
+		t.Fatalf("freeze: %v", err)
+	}
+	if f.StartedAt.IsZero() || !f.ExpiresAt.Equal(expires.UTC()) {
+		t.Fatalf("unexpected freeze %+v", f)
+	}
+
+	// A second Store over the same directory sees the freeze.
+	other := New(dir)
+	got, ok := other.Frozen("prod")
+	if !ok || got.Reason != "incident 42" || got.Actor != "oncall" {
+		t.Fatalf("expected shared freeze, got %+v ok=%v", got, ok)
+	}
+	if _, ok := other.Frozen("staging"); ok {
+		t.Fatalf("expected staging unfrozen")
+	}
+
+	// Extending keeps the original start time.
+	again, err := s.Freeze("prod", "still investigating", "oncall", expires.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("extend: %v", err)
+	}
+	if !again.StartedAt.Equal(f.StartedAt) {
+		t.Fatalf("expected start time kept, got %v want %v", again.StartedAt, f.StartedAt)
+	}
+
+	if err := other.Unfreeze("prod", "lead"); err != nil {
+		t.Fatalf("unfreeze: %v", err)
+	}
+	if _, ok := s.Frozen("prod"); ok {
+		t.Fatalf("expected prod unfrozen")
+	}
+	if err := s.Unfreeze("prod", "lead"); !errors.Is(err, ErrNotFrozen) {
+		t.Fatalf("expected ErrNotFrozen, got %v", err)
+	}
+
+	audit, err := s.Audit(0)
+	if err != nil {
+		t.Fatalf("audit: %v", err)
+	}
+	if len(audit) != 3 || audit[0].Action != ActionUnfreeze || audit[0].Actor != "lead" || audit[2].Action != ActionFreeze {
+		t.Fatalf("unexpected audit %+v", audit)
+	}
+	if limited, _ := s.Audit(1); len(limited) != 1 {
+		t.Fatalf("expected limit to apply, got %d", len(limited))
+	}
+}
+
+func TestStoreExpiry(t *testing.T) {
+	s := New(t.TempDir())
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	if _, err := s.Freeze("prod", "", "oncall", now.Add(time.Minute)); err != nil {
+		t.Fatalf("freeze: %v", err)
+	}
+	if _, err := s.Freeze("dev", "", "oncall", now.Add(-time.Minute)); err == nil {
+		t.Fatalf("expected past expiry to be rejected")
+	}
+	if _, err := s.Freeze("dev", "", "oncall", now.Add(MaxDuration+time.Hour)); err == nil {
+		t.Fatalf("expected overlong freeze to be rejected")
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := s.Frozen("prod"); ok {
+		t.Fatalf("expected freeze to expire")
+	}
+	list, err := s.List()
+	if err != nil || len(list) != 0 {
+		t.Fatalf("expected no freezes, got %+v err=%v", list, err)
+	}
+	audit, _ := s.Audit(0)
+	if len(audit) != 2 || audit[0].Action != ActionExpire || audit[0].Environment != "prod" {
+		t.Fatalf("expected one expiry entry, got %+v", audit)
+	}
+}
@@ -21,7 +21,7 @@ func AuthMethod(ctx context.Context, project *config.ProjectConfig) (transport.A
 
 	switch project.Git.Type {
 	case "ssh":
-		return sshAuth(project.Git)
+		return sshAuth(ctx, project.Git)
 	case "https":
 		return httpsAuth(project.Git)
 	case "github_app":
@@ -31,23 +31,10 @@ func AuthMethod(ctx context.Context, project *config.ProjectConfig) (transport.A
 	}
 }
 
-func sshAuth(cfg *config.GitAuthConfig) (transport.AuthMethod, error) {
-	keyPath := cfg.SSHKeyPath
-	if keyPath == "" && cfg.SSHKeyEnv != "" {
-		keyPath = os.Getenv(cfg.SSHKeyEnv)
-	}
-	if keyPath == "" {
-		return nil, fmt.Errorf("ssh_key_path or ssh_key_env required")
-	}
-
-	passphrase := ""
-	if cfg.SSHKeyPassphraseEnv != "" {
-		passphrase = os.Getenv(cfg.SSHKeyPassphraseEnv)
-	}
-
-	auth, err := gitssh.NewPublicKeysFromFile("git", keyPath, passphrase)
+func sshAuth(ctx context.Context, cfg *config.GitAuthConfig) (transport.AuthMethod, error) {
+	auth, err := sshPublicKeys(ctx, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("load SSH key from %s: %w", keyPath, err)
+		return nil, err
 	}
 
 	if cfg.SSHInsecureIgnoreHostKey {
@@ -67,6 +54,35 @@ func sshAuth(cfg *config.GitAuthConfig) (transport.AuthMethod, error) {
 	return nil, fmt.Errorf("ssh_known_hosts_path required unless ssh_insecure_ignore_host_key is true")
 }
 
+func sshPublicKeys(ctx context.Context, cfg *config.GitAuthConfig) (*gitssh.PublicKeys, error) {
+	if cfg.SSHCertificate != nil {
+		signer, err := sshCertSigner(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return &gitssh.PublicKeys{User: "git", Signer: signer}, nil
+	}
+
+	keyPath := cfg.SSHKeyPath
+	if keyPath == "" && cfg.SSHKeyEnv != "" {
+		keyPath = os.Getenv(cfg.SSHKeyEnv)
+	}
+	if keyPath == "" {
+		return nil, fmt.Errorf("ssh_key_path or ssh_key_env required")
+	}
+
+	passphrase := ""
+	if cfg.SSHKeyPassphraseEnv != "" {
+		passphrase = os.Getenv(cfg.SSHKeyPassphraseEnv)
+	}
+
+	auth, err := gitssh.NewPublicKeysFromFile("git", keyPath, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("load SSH key from %s: %w", keyPath, err)
+	}
+	return auth, nil
+}
+
 func httpsAuth(cfg *config.GitAuthConfig) (transport.AuthMethod, error) {
 	token := cfg.HTTPSToken
 	if token == "" && cfg.HTTPSTokenEnv != "" {
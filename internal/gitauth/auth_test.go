@@ -1,6 +1,7 @@
 package gitauth
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -45,7 +46,7 @@ func TestSSHAuthFromPath(t *testing.T) {
 		SSHInsecureIgnoreHostKey: true,
 	}
 
-	auth, err := sshAuth(cfg)
+	auth, err := sshAuth(context.Background(), cfg)
 	if err != nil {
 		t.Fatalf("ssh auth: %v", err)
 	}
@@ -64,7 +65,7 @@ func TestSSHAuthFromEnv(t *testing.T) {
 		SSHInsecureIgnoreHostKey: true,
 	}
 
-	auth, err := sshAuth(cfg)
+	auth, err := sshAuth(context.Background(), cfg)
 	if err != nil {
 		t.Fatalf("ssh auth: %v", err)
 	}
@@ -80,7 +81,7 @@ func TestSSHAuthRequiresKnownHosts(t *testing.T) {
 		SSHKeyPath: keyPath,
 	}
 
-	if _, err := sshAuth(cfg); err == nil {
+	if _, err := sshAuth(context.Background(), cfg); err == nil {
 		t.Fatalf("expected error when known_hosts missing")
 	}
 }
@@ -0,0 +1,81 @@
+package gitauth
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+// Credentials is the secret material a git auth config resolves to on this
+// server. Only the field for cfg.Type is set; a field left empty is not
+// configured or could not be read.
+type Credentials struct {
+	SSHKey       string
+	HTTPSToken   string
+	GitHubAppKey string
+}
+
+// ResolveCredentials reads the credentials cfg points at, with the same
+// precedence AuthMethod uses.
+func ResolveCredentials(cfg *config.GitAuthConfig) Credentials {
+	var creds Credentials
+	if cfg == nil {
+		return creds
+	}
+	switch cfg.Type {
+	case "ssh":
+		keyPath := cfg.SSHKeyPath
+		if keyPath == "" && cfg.SSHKeyEnv != "" {
+			keyPath = os.Getenv(cfg.SSHKeyEnv)
+		}
+		if keyPath != "" {
+			if data, err := os.ReadFile(keyPath); err == nil {
+				creds.SSHKey = string(data)
+			}
+		}
+	case "https":
+		creds.HTTPSToken = cfg.HTTPSToken
+		if creds.HTTPSToken == "" && cfg.HTTPSTokenEnv != "" {
+			creds.HTTPSToken = os.Getenv(cfg.HTTPSTokenEnv)
+		}
+	case "github_app":
+		if app := cfg.GitHubApp; app != nil {
+			switch {
+			case app.PrivateKey != "":
+				creds.GitHubAppKey = app.PrivateKey
+			case app.PrivateKeyEnv != "":
+				creds.GitHubAppKey = os.Getenv(app.PrivateKeyEnv)
+			case app.PrivateKeyPath != "":
+				if data, err := os.ReadFile(app.PrivateKeyPath); err == nil {
+					creds.GitHubAppKey = string(data)
+				}
+			}
+		}
+	}
+	creds.SSHKey = strings.TrimSpace(creds.SSHKey)
+	creds.HTTPSToken = strings.TrimSpace(creds.HTTPSToken)
+	creds.GitHubAppKey = strings.TrimSpace(creds.GitHubAppKey)
+	return creds
+}
+
+// KeyFingerprint returns the OpenSSH SHA256 fingerprint of a PEM or OpenSSH
+// private key's public half, as `ssh-keygen -lf` prints it. Encrypted
+// OpenSSH keys still yield a fingerprint; keys that cannot be parsed yield
+// "".
+func KeyFingerprint(privateKey string) string {
+	if strings.TrimSpace(privateKey) == "" {
+		return ""
+	}
+	signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+	if err == nil {
+		return ssh.FingerprintSHA256(signer.PublicKey())
+	}
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) && missing.PublicKey != nil {
+		return ssh.FingerprintSHA256(missing.PublicKey)
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package gitauth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestKeyFingerprint(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatalf("public key: %v", err)
+	}
+	want := ssh.FingerprintSHA256(sshPub)
+
+	plain, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	if got := KeyFingerprint(string(pem.EncodeToMemory(plain))); got != want {
+		t.Fatalf("fingerprint = %q, want %q", got, want)
+	}
+
+	encrypted, err := ssh.MarshalPrivateKeyWithPassphrase(priv, "", []byte("hunter2"))
+	if err != nil {
+		t.Fatalf("marshal encrypted key: %v", err)
+	}
+	if got := KeyFingerprint(string(pem.EncodeToMemory(encrypted))); got != want {
+		t.Fatalf("encrypted key fingerprint = %q, want %q", got, want)
+	}
+
+	if got := KeyFingerprint("not a key"); got != "" {
+		t.Fatalf("expected no fingerprint for garbage, got %q", got)
+	}
+}
+
+func TestResolveCredentials(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, []byte("key-material\n"), 0600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	t.Setenv("DRIFTD_TEST_KEY_PATH", keyPath)
+	t.Setenv("DRIFTD_TEST_TOKEN", "ghp_token1234")
+
+	if got := ResolveCredentials(&config.GitAuthConfig{Type: "ssh", SSHKeyEnv: "DRIFTD_TEST_KEY_PATH"}); got.SSHKey != "key-material" {
+		t.Fatalf("expected ssh key from env path, got %+v", got)
+	}
+	if got := ResolveCredentials(&config.GitAuthConfig{Type: "https", HTTPSTokenEnv: "DRIFTD_TEST_TOKEN"}); got.HTTPSToken != "ghp_token1234" {
+		t.Fatalf("expected token from env, got %+v", got)
+	}
+	// Only the configured type's credential is resolved.
+	got := ResolveCredentials(&config.GitAuthConfig{Type: "https", SSHKeyPath: keyPath})
+	if got != (Credentials{}) {
+		t.Fatalf("expected no credentials, got %+v", got)
+	}
+	if got := ResolveCredentials(&config.GitAuthConfig{Type: "ssh", SSHKeyPath: filepath.Join(t.TempDir(), "missing")}); got.SSHKey != "" {
+		t.Fatalf("expected unreadable key to be absent, got %+v", got)
+	}
+}
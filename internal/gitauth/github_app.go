@@ -59,7 +59,11 @@ func githubAppAuth(ctx context.Context, cfg *config.GitAuthConfig) (transport.Au
 	if cfg.GitHubApp == nil {
 		return nil, fmt.Errorf("github_app config required")
 	}
-	token, err := GitHubAppToken(ctx, cfg.GitHubApp)
+	client, err := httpClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	token, err := githubAppToken(ctx, cfg.GitHubApp, client)
 	if err != nil {
 		return nil, err
 	}
@@ -67,6 +71,10 @@ func githubAppAuth(ctx context.Context, cfg *config.GitAuthConfig) (transport.Au
 }
 
 func GitHubAppToken(ctx context.Context, cfg *config.GitHubAppConfig) (string, error) {
+	return githubAppToken(ctx, cfg, &http.Client{Timeout: 30 * time.Second})
+}
+
+func githubAppToken(ctx context.Context, cfg *config.GitHubAppConfig, client *http.Client) (string, error) {
 	if cfg.AppID == 0 || cfg.InstallationID == 0 {
 		return "", fmt.Errorf("github_app app_id and installation_id required")
 	}
@@ -90,23 +98,11 @@ func GitHubAppToken(ctx context.Context, cfg *config.GitHubAppConfig) (string, e
 		}
 	}
 
-	key, err := loadPrivateKey(cfg)
+	signed, err := GitHubAppJWT(cfg)
 	if err != nil {
 		return "", err
 	}
 
-	now := time.Now()
-	claims := jwt.MapClaims{
-		"iat": now.Add(-1 * time.Minute).Unix(),
-		"exp": now.Add(9 * time.Minute).Unix(),
-		"iss": cfg.AppID,
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
-	signed, err := token.SignedString(key)
-	if err != nil {
-		return "", fmt.Errorf("sign jwt: %w", err)
-	}
-
 	baseURL := cfg.APIBaseURL
 	if baseURL == "" {
 		baseURL = "https://api.github.com"
@@ -120,7 +116,6 @@ func GitHubAppToken(ctx context.Context, cfg *config.GitHubAppConfig) (string, e
 	req.Header.Set("Authorization", "Bearer "+signed)
 	req.Header.Set("Accept", "application/vnd.github+json")
 
-	client := &http.Client{Timeout: 30 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
@@ -147,6 +142,28 @@ func GitHubAppToken(ctx context.Context, cfg *config.GitHubAppConfig) (string, e
 	return body.Token, nil
 }
 
+// GitHubAppJWT signs a short-lived JWT that authenticates as the app itself
+// rather than as one of its installations.
+func GitHubAppJWT(cfg *config.GitHubAppConfig) (string, error) {
+	key, err := loadPrivateKey(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iat": now.Add(-1 * time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": cfg.AppID,
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("sign jwt: %w", err)
+	}
+	return signed, nil
+}
+
 func loadPrivateKey(cfg *config.GitHubAppConfig) (*rsa.PrivateKey, error) {
 	var keyData string
 	switch {
@@ -0,0 +1,249 @@
+package gitauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultVaultSSHMount     = "ssh-client-signer"
+	defaultVaultTokenEnv     = "VAULT_TOKEN"
+	sshCertSignTimeout       = 30 * time.Second
+	sshCertRenewBeforeExpiry = 2 * time.Minute
+)
+
+type sshCertCache struct {
+	mu          sync.Mutex
+	signer      ssh.Signer
+	validBefore time.Time
+}
+
+var certCache sync.Map
+
+func clearCertCache() {
+	certCache = sync.Map{}
+}
+
+// sshCertSigner returns a signer that presents a certificate from the
+// configured SSH CA. Certificates are reused until shortly before they
+// expire, so a clone burst asks the CA once.
+func sshCertSigner(ctx context.Context, cfg *config.GitAuthConfig) (ssh.Signer, error) {
+	cert := cfg.SSHCertificate
+	keyPath := cfg.SSHKeyPath
+	if keyPath == "" && cfg.SSHKeyEnv != "" {
+		keyPath = os.Getenv(cfg.SSHKeyEnv)
+	}
+
+	cacheKey := sshCertCacheKey(cert, keyPath)
+	cached, _ := certCache.LoadOrStore(cacheKey, &sshCertCache{})
+	c := cached.(*sshCertCache)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.signer != nil && time.Until(c.validBefore) > sshCertRenewBeforeExpiry {
+		return c.signer, nil
+	}
+
+	key, err := loadOrGenerateSSHKey(cfg, keyPath)
+	if err != nil {
+		return nil, err
+	}
+	pub := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key.PublicKey())))
+
+	ctx, cancel := context.WithTimeout(ctx, sshCertSignTimeout)
+	defer cancel()
+	var signed string
+	switch cert.Signer {
+	case "vault":
+		signed, err = vaultSignSSHKey(ctx, cert, pub)
+	case "command":
+		signed, err = commandSignSSHKey(ctx, cert, pub)
+	default:
+		return nil, fmt.Errorf("unsupported ssh_certificate signer: %q", cert.Signer)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	certificate, err := parseSSHCertificate(signed, key.PublicKey())
+	if err != nil {
+		return nil, err
+	}
+	certSigner, err := ssh.NewCertSigner(certificate, key)
+	if err != nil {
+		return nil, fmt.Errorf("ssh certificate: %w", err)
+	}
+	c.signer = certSigner
+	c.validBefore = time.Unix(int64(certificate.ValidBefore), 0)
+	if certificate.ValidBefore == ssh.CertTimeInfinity {
+		c.validBefore = time.Now().Add(24 * time.Hour)
+	}
+	return certSigner, nil
+}
+
+func sshCertCacheKey(cert *config.SSHCertificateConfig, keyPath string) string {
+	parts := []string{cert.Signer, keyPath, strings.Join(cert.Principals, ","), cert.TTL.String()}
+	if cert.Vault != nil {
+		parts = append(parts, cert.Vault.Address, cert.Vault.Namespace, cert.Vault.Mount, cert.Vault.Role)
+	}
+	parts = append(parts, cert.Command...)
+	return strings.Join(parts, "\x00")
+}
+
+// loadOrGenerateSSHKey loads the configured key, or generates an ed25519 key
+// that lives only in memory when none is configured. A new key is generated
+// for every certificate.
+func loadOrGenerateSSHKey(cfg *config.GitAuthConfig, keyPath string) (ssh.Signer, error) {
+	if keyPath == "" {
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generate ssh key: %w", err)
+		}
+		return ssh.NewSignerFromKey(priv)
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("read SSH key from %s: %w", keyPath, err)
+	}
+	var key ssh.Signer
+	if cfg.SSHKeyPassphraseEnv != "" {
+		key, err = ssh.ParsePrivateKeyWithPassphrase(data, []byte(os.Getenv(cfg.SSHKeyPassphraseEnv)))
+	} else {
+		key, err = ssh.ParsePrivateKey(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load SSH key from %s: %w", keyPath, err)
+	}
+	return key, nil
+}
+
+// vaultSignSSHKey calls Vault's SSH secrets engine sign endpoint.
+func vaultSignSSHKey(ctx context.Context, cert *config.SSHCertificateConfig, publicKey string) (string, error) {
+	vault := cert.Vault
+	if vault == nil || vault.Role == "" {
+		return "", fmt.Errorf("ssh_certificate.vault.role required")
+	}
+	addr := vault.Address
+	if addr == "" {
+		addr = os.Getenv("VAULT_ADDR")
+	}
+	if addr == "" {
+		return "", fmt.Errorf("ssh_certificate.vault.address or VAULT_ADDR required")
+	}
+	tokenEnv := vault.TokenEnv
+	if tokenEnv == "" {
+		tokenEnv = defaultVaultTokenEnv
+	}
+	token := os.Getenv(tokenEnv)
+	if token == "" {
+		return "", fmt.Errorf("vault token missing: %s is empty", tokenEnv)
+	}
+	mount := strings.Trim(vault.Mount, "/")
+	if mount == "" {
+		mount = defaultVaultSSHMount
+	}
+
+	reqBody := map[string]string{"public_key": publicKey, "cert_type": "user"}
+	if len(cert.Principals) > 0 {
+		reqBody["valid_principals"] = strings.Join(cert.Principals, ",")
+	}
+	if cert.TTL > 0 {
+		reqBody["ttl"] = cert.TTL.String()
+	}
+	payload, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", err
+	}
+	url := fmt.Sprintf("%s/v1/%s/sign/%s", strings.TrimRight(addr, "/"), mount, vault.Role)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Vault-Token", token)
+	if vault.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", vault.Namespace)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("vault ssh sign: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", fmt.Errorf("vault ssh sign failed: %s", resp.Status)
+	}
+	var body struct {
+		Data struct {
+			SignedKey string `json:"signed_key"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode vault ssh sign response: %w", err)
+	}
+	if body.Data.SignedKey == "" {
+		return "", fmt.Errorf("vault ssh sign response missing signed_key")
+	}
+	return body.Data.SignedKey, nil
+}
+
+// commandSignSSHKey runs the configured hook with the public key on stdin.
+// The principals and TTL are passed in DRIFTD_SSH_PRINCIPALS and
+// DRIFTD_SSH_TTL for hooks that want them.
+func commandSignSSHKey(ctx context.Context, cert *config.SSHCertificateConfig, publicKey string) (string, error) {
+	if len(cert.Command) == 0 {
+		return "", fmt.Errorf("ssh_certificate.command required")
+	}
+	cmd := exec.CommandContext(ctx, cert.Command[0], cert.Command[1:]...)
+	cmd.Stdin = strings.NewReader(publicKey + "\n")
+	cmd.Env = append(os.Environ(),
+		"DRIFTD_SSH_PRINCIPALS="+strings.Join(cert.Principals, ","),
+		"DRIFTD_SSH_TTL="+cert.TTL.String(),
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			return "", fmt.Errorf("ssh certificate command: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("ssh certificate command: %w", err)
+	}
+	return string(out), nil
+}
+
+// parseSSHCertificate checks that signed is a current user certificate for
+// key.
+func parseSSHCertificate(signed string, key ssh.PublicKey) (*ssh.Certificate, error) {
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(signed)))
+	if err != nil {
+		return nil, fmt.Errorf("parse ssh certificate: %w", err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("signer returned a public key, not a certificate")
+	}
+	if cert.CertType != ssh.UserCert {
+		return nil, fmt.Errorf("signer returned a host certificate")
+	}
+	if !bytes.Equal(cert.Key.Marshal(), key.Marshal()) {
+		return nil, fmt.Errorf("ssh certificate is for a different key")
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity && time.Now().Unix() >= int64(cert.ValidBefore) {
+		return nil, fmt.Errorf("ssh certificate already expired")
+	}
+	return cert, nil
+}
@@ -0,0 +1,162 @@
+package gitauth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+func newTestCA(t *testing.T) ssh.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ca: %v", err)
+	}
+	ca, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("ca signer: %v", err)
+	}
+	return ca
+}
+
+func signUserKey(t *testing.T, ca ssh.Signer, authorizedKey string, validFor time.Duration) string {
+	t.Helper()
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+	if err != nil {
+		t.Fatalf("parse public key: %v", err)
+	}
+	cert := &ssh.Certificate{
+		Key:             pub,
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{"git"},
+		ValidAfter:      uint64(time.Now().Add(-time.Minute).Unix()),
+		ValidBefore:     uint64(time.Now().Add(validFor).Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatalf("sign cert: %v", err)
+	}
+	return string(ssh.MarshalAuthorizedKey(cert))
+}
+
+func TestSSHAuthVaultCertificate(t *testing.T) {
+	clearCertCache()
+	t.Cleanup(clearCertCache)
+	ca := newTestCA(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != "/v1/ssh-client-signer/sign/driftd" || r.Header.Get("X-Vault-Token") != "s.token" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		var req map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req["valid_principals"] != "git" || req["ttl"] != "1h0m0s" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]string{"signed_key": signUserKey(t, ca, req["public_key"], time.Hour)},
+		})
+	}))
+	defer srv.Close()
+	t.Setenv("VAULT_TOKEN", "s.token")
+
+	cfg := &config.GitAuthConfig{
+		Type:                     "ssh",
+		SSHInsecureIgnoreHostKey: true,
+		SSHCertificate: &config.SSHCertificateConfig{
+			Signer:     "vault",
+			Principals: []string{"git"},
+			TTL:        time.Hour,
+			Vault:      &config.VaultSSHSignerConfig{Address: srv.URL, Role: "driftd"},
+		},
+	}
+	auth, err := sshAuth(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("ssh auth: %v", err)
+	}
+	keys, ok := auth.(*gitssh.PublicKeys)
+	if !ok {
+		t.Fatalf("unexpected auth type %T", auth)
+	}
+	if _, ok := keys.Signer.PublicKey().(*ssh.Certificate); !ok {
+		t.Fatalf("signer does not present a certificate: %T", keys.Signer.PublicKey())
+	}
+
+	if _, err := sshAuth(context.Background(), cfg); err != nil {
+		t.Fatalf("second ssh auth: %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected the certificate to be reused, vault called %d times", calls.Load())
+	}
+}
+
+func TestSSHAuthCommandCertificate(t *testing.T) {
+	clearCertCache()
+	t.Cleanup(clearCertCache)
+	ca := newTestCA(t)
+	keyPath := writeKeyFile(t)
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatalf("read key: %v", err)
+	}
+	key, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		t.Fatalf("parse key: %v", err)
+	}
+	certPath := filepath.Join(t.TempDir(), "id_rsa-cert.pub")
+	cert := signUserKey(t, ca, string(ssh.MarshalAuthorizedKey(key.PublicKey())), time.Hour)
+	if err := os.WriteFile(certPath, []byte(cert), 0600); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+
+	cfg := &config.GitAuthConfig{
+		Type:                     "ssh",
+		SSHKeyPath:               keyPath,
+		SSHInsecureIgnoreHostKey: true,
+		SSHCertificate: &config.SSHCertificateConfig{
+			Signer:  "command",
+			Command: []string{"cat", certPath},
+		},
+	}
+	if _, err := sshAuth(context.Background(), cfg); err != nil {
+		t.Fatalf("ssh auth: %v", err)
+	}
+
+	// A certificate for some other key is refused.
+	clearCertCache()
+	cfg.SSHKeyPath = writeKeyFile(t)
+	if _, err := sshAuth(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "different key") {
+		t.Fatalf("expected different key error, got %v", err)
+	}
+}
+
+func TestSSHAuthCommandCertificateFailure(t *testing.T) {
+	clearCertCache()
+	t.Cleanup(clearCertCache)
+	cfg := &config.GitAuthConfig{
+		Type:                     "ssh",
+		SSHInsecureIgnoreHostKey: true,
+		SSHCertificate: &config.SSHCertificateConfig{
+			Signer:  "command",
+			Command: []string{"sh", "-c", "echo signer offline >&2; exit 1"},
+		},
+	}
+	if _, err := sshAuth(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "signer offline") {
+		t.Fatalf("expected command error, got %v", err)
+	}
+}
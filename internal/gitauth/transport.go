@@ -0,0 +1,111 @@
+package gitauth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// Connection holds everything go-git needs to reach a project's remote:
+// credentials plus the optional proxy and extra CA certificates.
+type Connection struct {
+	Auth     transport.AuthMethod
+	CABundle []byte
+	Proxy    transport.ProxyOptions
+}
+
+// Connect resolves the auth method, proxy and CA bundle for project.
+func Connect(ctx context.Context, project *config.ProjectConfig) (*Connection, error) {
+	var git *config.GitAuthConfig
+	if project != nil {
+		git = project.Git
+	}
+	conn, err := transportOptions(git)
+	if err != nil {
+		return nil, err
+	}
+	conn.Auth, err = AuthMethod(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// transportOptions reads the CA bundle and splits any credentials out of the
+// proxy URL, since go-git adds them back itself.
+func transportOptions(cfg *config.GitAuthConfig) (*Connection, error) {
+	conn := &Connection{}
+	if cfg == nil {
+		return conn, nil
+	}
+	if cfg.CABundlePath != "" {
+		data, err := os.ReadFile(cfg.CABundlePath)
+		if err != nil {
+			return nil, fmt.Errorf("read ca_bundle_path: %w", err)
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("ca_bundle_path %s contains no PEM certificates", cfg.CABundlePath)
+		}
+		conn.CABundle = data
+	}
+	if cfg.ProxyURL != "" {
+		if err := config.ValidateProxyURL(cfg.ProxyURL); err != nil {
+			return nil, err
+		}
+		u, err := url.Parse(strings.TrimSpace(cfg.ProxyURL))
+		if err != nil {
+			return nil, err
+		}
+		if u.User != nil {
+			conn.Proxy.Username = u.User.Username()
+			conn.Proxy.Password, _ = u.User.Password()
+			u.User = nil
+		}
+		if cfg.ProxyPasswordEnv != "" {
+			conn.Proxy.Password = os.Getenv(cfg.ProxyPasswordEnv)
+		}
+		conn.Proxy.URL = u.String()
+	}
+	return conn, nil
+}
+
+// httpClient returns a client for GitHub API calls that uses the same proxy
+// and CA bundle as git operations.
+func httpClient(cfg *config.GitAuthConfig) (*http.Client, error) {
+	conn, err := transportOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	if len(conn.CABundle) == 0 && conn.Proxy.URL == "" {
+		return client, nil
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	if len(conn.CABundle) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		pool.AppendCertsFromPEM(conn.CABundle)
+		tr.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	if conn.Proxy.URL != "" {
+		proxyURL, err := conn.Proxy.FullURL()
+		if err != nil {
+			return nil, err
+		}
+		tr.Proxy = http.ProxyURL(proxyURL)
+	}
+	client.Transport = tr
+	return client, nil
+}
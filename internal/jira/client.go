@@ -0,0 +1,161 @@
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Issue is the part of a Jira issue driftd reads back.
+type Issue struct {
+	Key string
+	// Done is set when the issue's status is in the "done" category.
+	Done bool
+}
+
+// NewIssue holds the fields of an issue to create.
+type NewIssue struct {
+	Project     string
+	IssueType   string
+	Summary     string
+	Description string
+	Labels      []string
+}
+
+// Client talks to the Jira REST API (v2, which accepts plain-text bodies).
+type Client struct {
+	baseURL string
+	email   string
+	token   string
+	http    *http.Client
+}
+
+// NewClient returns a client for baseURL. With an empty email, token is sent
+// as a bearer token.
+func NewClient(baseURL, email, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		email:   email,
+		token:   token,
+		http:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// CreateIssue opens an issue and returns its key.
+func (c *Client) CreateIssue(ctx context.Context, issue NewIssue) (string, error) {
+	req := map[string]any{
+		"fields": map[string]any{
+			"project":     map[string]string{"key": issue.Project},
+			"issuetype":   map[string]string{"name": issue.IssueType},
+			"summary":     issue.Summary,
+			"description": issue.Description,
+			"labels":      issue.Labels,
+		},
+	}
+	var resp struct {
+		Key string `json:"key"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/rest/api/2/issue", req, &resp); err != nil {
+		return "", err
+	}
+	if resp.Key == "" {
+		return "", fmt.Errorf("jira: create issue returned no key")
+	}
+	return resp.Key, nil
+}
+
+// AddComment comments on an issue.
+func (c *Client) AddComment(ctx context.Context, key, body string) error {
+	return c.do(ctx, http.MethodPost, "/rest/api/2/issue/"+url.PathEscape(key)+"/comment", map[string]string{"body": body}, nil)
+}
+
+// Transition applies the workflow transition named name to an issue.
+func (c *Client) Transition(ctx context.Context, key, name string) error {
+	path := "/rest/api/2/issue/" + url.PathEscape(key) + "/transitions"
+	var available struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"transitions"`
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &available); err != nil {
+		return err
+	}
+	for _, t := range available.Transitions {
+		if strings.EqualFold(t.Name, name) {
+			return c.do(ctx, http.MethodPost, path, map[string]any{"transition": map[string]string{"id": t.ID}}, nil)
+		}
+	}
+	return fmt.Errorf("jira: transition %q not available on %s", name, key)
+}
+
+// FindByLabel returns the most recently created issue carrying label, or nil.
+func (c *Client) FindByLabel(ctx context.Context, label string) (*Issue, error) {
+	q := url.Values{}
+	q.Set("jql", fmt.Sprintf("labels = %q ORDER BY created DESC", label))
+	q.Set("fields", "status")
+	q.Set("maxResults", "1")
+	var resp struct {
+		Issues []struct {
+			Key    string `json:"key"`
+			Fields struct {
+				Status struct {
+					StatusCategory struct {
+						Key string `json:"key"`
+					} `json:"statusCategory"`
+				} `json:"status"`
+			} `json:"fields"`
+		} `json:"issues"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/rest/api/2/search?"+q.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Issues) == 0 {
+		return nil, nil
+	}
+	found := resp.Issues[0]
+	return &Issue{Key: found.Key, Done: found.Fields.Status.StatusCategory.Key == "done"}, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.email != "" {
+		req.SetBasicAuth(c.email, c.token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("jira: %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("jira: %s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
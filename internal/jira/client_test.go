@@ -0,0 +1,67 @@
+package jira
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientCreateAndTransition(t *testing.T) {
+	var transitioned string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "bot@example.com" || pass != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue":
+			var req struct {
+				Fields struct {
+					Project struct {
+						Key string `json:"key"`
+					} `json:"project"`
+				} `json:"fields"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			w.Write([]byte(`{"key":"` + req.Fields.Project.Key + `-1"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/issue/OPS-1/transitions":
+			w.Write([]byte(`{"transitions":[{"id":"11","name":"In Progress"},{"id":"31","name":"Done"}]}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/rest/api/2/issue/OPS-1/transitions":
+			var req struct {
+				Transition struct {
+					ID string `json:"id"`
+				} `json:"transition"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&req)
+			transitioned = req.Transition.ID
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodGet && r.URL.Path == "/rest/api/2/search":
+			w.Write([]byte(`{"issues":[{"key":"OPS-1","fields":{"status":{"statusCategory":{"key":"done"}}}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL+"/", "bot@example.com", "token")
+	ctx := context.Background()
+	key, err := c.CreateIssue(ctx, NewIssue{Project: "OPS", IssueType: "Bug", Summary: "s"})
+	if err != nil || key != "OPS-1" {
+		t.Fatalf("create: %q %v", key, err)
+	}
+	if err := c.Transition(ctx, "OPS-1", "done"); err != nil || transitioned != "31" {
+		t.Fatalf("transition: %q %v", transitioned, err)
+	}
+	if err := c.Transition(ctx, "OPS-1", "Reopen"); err == nil {
+		t.Fatalf("expected error for unknown transition")
+	}
+	found, err := c.FindByLabel(ctx, "driftd-abc")
+	if err != nil || found == nil || found.Key != "OPS-1" || !found.Done {
+		t.Fatalf("find: %+v %v", found, err)
+	}
+	if _, err := NewClient(ts.URL, "bot@example.com", "wrong").CreateIssue(ctx, NewIssue{}); err == nil {
+		t.Fatalf("expected auth error")
+	}
+}
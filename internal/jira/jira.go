@@ -0,0 +1,306 @@
+// Package jira keeps one Jira issue per drifted stack: it opens an issue when
+// a stack drifts, comments as later scans change the drift, and transitions
+// the issue once the stack is clean again.
+package jira
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+const (
+	maxSummaryLen   = 255
+	maxListedChange = 50
+)
+
+// Tracker is the subset of the Jira API the service uses.
+type Tracker interface {
+	CreateIssue(ctx context.Context, issue NewIssue) (string, error)
+	AddComment(ctx context.Context, key, body string) error
+	Transition(ctx context.Context, key, name string) error
+	FindByLabel(ctx context.Context, label string) (*Issue, error)
+}
+
+// Leader reports whether this replica should sync. The scheduler's elector
+// satisfies it.
+type Leader interface {
+	IsLeader() bool
+}
+
+// SyncResult counts the issue changes of one sync.
+type SyncResult struct {
+	Opened  int `json:"opened"`
+	Updated int `json:"updated"`
+	Closed  int `json:"closed"`
+}
+
+// Service syncs stack results to Jira on an interval.
+type Service struct {
+	cfg     config.JiraConfig
+	store   storage.Store
+	tracker Tracker
+	ledger  *Ledger
+	leader  Leader
+
+	syncMu sync.Mutex
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewClientFromConfig returns a Client for cfg, reading the API token from
+// api_token_env when api_token is empty.
+func NewClientFromConfig(cfg config.JiraConfig) *Client {
+	token := cfg.APIToken
+	if token == "" && cfg.APITokenEnv != "" {
+		token = os.Getenv(cfg.APITokenEnv)
+	}
+	return NewClient(cfg.URL, cfg.Email, token)
+}
+
+// New loads the issue ledger from dataDir. tracker is typically
+// NewClientFromConfig(cfg).
+func New(cfg config.JiraConfig, store storage.Store, dataDir string, tracker Tracker) (*Service, error) {
+	ledger, err := LoadLedger(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		cfg:     cfg,
+		store:   store,
+		tracker: tracker,
+		ledger:  ledger,
+		stop:    make(chan struct{}),
+	}, nil
+}
+
+// SetLeader limits syncing to the replica holding the scheduler lease, so
+// several serve replicas don't open duplicate issues.
+func (s *Service) SetLeader(l Leader) {
+	s.leader = l
+}
+
+// Start syncs every interval until Stop.
+func (s *Service) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+			}
+			if s.leader != nil && !s.leader.IsLeader() {
+				continue
+			}
+			res, err := s.Sync(context.Background())
+			if err != nil {
+				log.Printf("Jira sync: %v", err)
+			}
+			if res.Opened+res.Updated+res.Closed > 0 {
+				log.Printf("Jira sync: %d opened, %d updated, %d closed", res.Opened, res.Updated, res.Closed)
+			}
+		}
+	}()
+}
+
+// Stop waits for a running sync to finish.
+func (s *Service) Stop() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+// Entries returns the tracked issues.
+func (s *Service) Entries() []Entry {
+	return s.ledger.Entries()
+}
+
+// Sync reconciles every mapped stack with its issue. A failing stack does not
+// stop the others; their errors are joined.
+func (s *Service) Sync(ctx context.Context) (SyncResult, error) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	var res SyncResult
+	projects, err := s.store.ListRepos()
+	if err != nil {
+		return res, err
+	}
+	var errs []error
+	for _, project := range projects {
+		stacks, err := s.store.ListStacks(project.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", project.Name, err))
+			continue
+		}
+		for _, st := range stacks {
+			if err := s.syncStack(ctx, project.Name, st, &res); err != nil {
+				errs = append(errs, fmt.Errorf("%s/%s: %w", project.Name, st.Path, err))
+			}
+		}
+	}
+	return res, errors.Join(errs...)
+}
+
+func (s *Service) syncStack(ctx context.Context, projectName string, st storage.StackStatus, res *SyncResult) error {
+	// A failed plan says nothing about drift.
+	if st.Error != "" {
+		return nil
+	}
+	mapping := s.cfg.Mapping(projectName, st.Path)
+	if mapping == nil {
+		return nil
+	}
+	entry := s.ledger.Get(projectName, st.Path)
+	if !st.Drifted || st.Suppressed {
+		if entry == nil || entry.Closed || !st.RunAt.After(entry.RunAt) {
+			return nil
+		}
+		if err := s.tracker.AddComment(ctx, entry.IssueKey, s.resolvedComment(projectName, st)); err != nil {
+			return err
+		}
+		if s.cfg.CloseTransition != "" {
+			if err := s.tracker.Transition(ctx, entry.IssueKey, s.cfg.CloseTransition); err != nil {
+				return err
+			}
+		}
+		entry.RunAt = st.RunAt
+		entry.Closed = true
+		res.Closed++
+		return s.ledger.Put(*entry)
+	}
+
+	// Drift seen during an environment freeze is recorded but not
+	// reported; the next scan after the freeze opens or updates the issue.
+	if st.ObservedDuringFreeze {
+		return nil
+	}
+
+	if entry == nil {
+		// The ledger may have been lost; the stack label finds the issue
+		// opened before.
+		found, err := s.tracker.FindByLabel(ctx, stackLabel(projectName, st.Path))
+		if err != nil {
+			return err
+		}
+		if found != nil {
+			entry = &Entry{Project: projectName, StackPath: st.Path, IssueKey: found.Key, Closed: found.Done}
+		}
+	}
+
+	switch {
+	case entry == nil || (entry.Closed && s.cfg.ReopenTransition == ""):
+		key, err := s.tracker.CreateIssue(ctx, NewIssue{
+			Project:     mapping.JiraProject,
+			IssueType:   mapping.IssueType,
+			Summary:     issueSummary(projectName, st.Path),
+			Description: s.driftDescription(projectName, st),
+			Labels:      append([]string{"driftd", stackLabel(projectName, st.Path)}, s.cfg.Labels...),
+		})
+		if err != nil {
+			return err
+		}
+		entry = &Entry{Project: projectName, StackPath: st.Path, IssueKey: key}
+		res.Opened++
+	case entry.Closed:
+		if err := s.tracker.Transition(ctx, entry.IssueKey, s.cfg.ReopenTransition); err != nil {
+			return err
+		}
+		if err := s.tracker.AddComment(ctx, entry.IssueKey, "Drift is back.\n\n"+s.driftDescription(projectName, st)); err != nil {
+			return err
+		}
+		res.Opened++
+	case st.RunAt.After(entry.RunAt):
+		if err := s.tracker.AddComment(ctx, entry.IssueKey, "Drift as of the latest scan.\n\n"+s.driftDescription(projectName, st)); err != nil {
+			return err
+		}
+		res.Updated++
+	default:
+		return nil
+	}
+	entry.RunAt = st.RunAt
+	entry.Closed = false
+	return s.ledger.Put(*entry)
+}
+
+func (s *Service) driftDescription(projectName string, st storage.StackStatus) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "driftd detected drift in stack %s of project %s.\n\n", st.Path, projectName)
+	fmt.Fprintf(&b, "Plan: %d to add, %d to change, %d to destroy.\n", st.Added, st.Changed, st.Destroyed)
+	if st.RootCauseHint != "" {
+		fmt.Fprintf(&b, "Root-cause hint: %s.\n", st.RootCauseHint)
+	}
+	if len(st.ResourceChanges) > 0 {
+		b.WriteString("\nDrifted resources:\n")
+		for i, rc := range st.ResourceChanges {
+			if i == maxListedChange {
+				fmt.Fprintf(&b, "* ... and %d more\n", len(st.ResourceChanges)-maxListedChange)
+				break
+			}
+			fmt.Fprintf(&b, "* %s (%s)\n", rc.Address, rc.Action)
+		}
+	}
+	if len(st.OutputChanges) > 0 {
+		b.WriteString("\nChanged outputs (read by other stacks and services through remote state):\n")
+		for _, oc := range st.OutputChanges {
+			if len(oc.Consumers) > 0 {
+				fmt.Fprintf(&b, "* %s (%s), read by %s\n", oc.Name, oc.Action, strings.Join(oc.Consumers, ", "))
+				continue
+			}
+			fmt.Fprintf(&b, "* %s (%s)\n", oc.Name, oc.Action)
+		}
+	}
+	if !st.RunAt.IsZero() {
+		fmt.Fprintf(&b, "\nScanned at %s.\n", st.RunAt.UTC().Format(time.RFC3339))
+	}
+	if link := s.stackURL(projectName, st.Path); link != "" {
+		fmt.Fprintf(&b, "Stack: %s\n", link)
+	}
+	return b.String()
+}
+
+func (s *Service) resolvedComment(projectName string, st storage.StackStatus) string {
+	msg := fmt.Sprintf("driftd no longer detects drift in stack %s of project %s", st.Path, projectName)
+	if st.Suppressed {
+		msg = fmt.Sprintf("Stack %s of project %s was suppressed in driftd", st.Path, projectName)
+	}
+	if !st.RunAt.IsZero() {
+		msg += " as of " + st.RunAt.UTC().Format(time.RFC3339)
+	}
+	return msg + "."
+}
+
+func (s *Service) stackURL(projectName, stackPath string) string {
+	if s.cfg.PublicURL == "" {
+		return ""
+	}
+	return s.cfg.PublicURL + "/projects/" + url.PathEscape(projectName) + "/stacks/" + stackPath
+}
+
+func issueSummary(projectName, stackPath string) string {
+	summary := "Drift in " + projectName + ": " + stackPath
+	if len(summary) > maxSummaryLen {
+		summary = summary[:maxSummaryLen-3] + "..."
+	}
+	return summary
+}
+
+// stackLabel is a stable, space-free label identifying a stack's issue.
+func stackLabel(projectName, stackPath string) string {
+	sum := sha256.Sum256([]byte(ledgerKey(projectName, stackPath)))
+	return "driftd-" + hex.EncodeToString(sum[:6])
+}
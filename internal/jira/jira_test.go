@@ -0,0 +1,192 @@
+package jira
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+type fakeTracker struct {
+	created     []NewIssue
+	comments    map[string][]string
+	transitions map[string][]string
+	byLabel     map[string]*Issue
+}
+
+func newFakeTracker() *fakeTracker {
+	return &fakeTracker{comments: map[string][]string{}, transitions: map[string][]string{}, byLabel: map[string]*Issue{}}
+}
+
+func (f *fakeTracker) CreateIssue(_ context.Context, issue NewIssue) (string, error) {
+	f.created = append(f.created, issue)
+	return fmt.Sprintf("OPS-%d", len(f.created)), nil
+}
+
+func (f *fakeTracker) AddComment(_ context.Context, key, body string) error {
+	f.comments[key] = append(f.comments[key], body)
+	return nil
+}
+
+func (f *fakeTracker) Transition(_ context.Context, key, name string) error {
+	f.transitions[key] = append(f.transitions[key], name)
+	return nil
+}
+
+func (f *fakeTracker) FindByLabel(_ context.Context, label string) (*Issue, error) {
+	return f.byLabel[label], nil
+}
+
+func testJiraConfig() config.JiraConfig {
+	return config.JiraConfig{
+		Enabled:         true,
+		CloseTransition: "Done",
+		PublicURL:       "https://driftd.example.com",
+		Labels:          []string{"infra"},
+		Mappings:        []config.JiraMapping{{Project: "infra", Path: "envs/**", JiraProject: "OPS", IssueType: "Bug"}},
+	}
+}
+
+func saveResult(t *testing.T, store storage.Store, stackPath string, drifted bool, at time.Time) {
+	t.Helper()
+	result := &storage.RunResult{Drifted: drifted, RunAt: at}
+	if drifted {
+		result.Changed = 1
+		result.ResourceChanges = []storage.ResourceChange{{Address: "aws_db_instance.main", Action: "update"}}
+		result.RootCauseHint = "likely manual console edit"
+		result.OutputChanges = []storage.OutputChange{{Name: "db_endpoint", Action: "update", Consumers: []string{"envs/app"}}}
+	}
+	if err := store.SaveResult("infra", stackPath, result); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+}
+
+func syncOnce(t *testing.T, svc *Service) SyncResult {
+	t.Helper()
+	res, err := svc.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	return res
+}
+
+func TestSyncLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	store := storage.New(dir)
+	tracker := newFakeTracker()
+	svc, err := New(testJiraConfig(), store, dir, tracker)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	saveResult(t, store, "envs/prod", true, start)
+	saveResult(t, store, "modules/vpc", true, start)
+	if res := syncOnce(t, svc); res != (SyncResult{Opened: 1}) {
+		t.Fatalf("expected one issue opened, got %+v", res)
+	}
+	issue := tracker.created[0]
+	if issue.Project != "OPS" || issue.IssueType != "Bug" || issue.Summary != "Drift in infra: envs/prod" {
+		t.Fatalf("unexpected issue %+v", issue)
+	}
+	if !strings.Contains(issue.Description, "aws_db_instance.main (update)") || !strings.Contains(issue.Description, "https://driftd.example.com/projects/infra/stacks/envs/prod") {
+		t.Fatalf("expected resources and link in description, got %q", issue.Description)
+	}
+	if !strings.Contains(issue.Description, "Root-cause hint: likely manual console edit.") {
+		t.Fatalf("expected root-cause hint in description, got %q", issue.Description)
+	}
+	if !strings.Contains(issue.Description, "* db_endpoint (update), read by envs/app") {
+		t.Fatalf("expected changed outputs in description, got %q", issue.Description)
+	}
+	if len(issue.Labels) != 3 || issue.Labels[1] != stackLabel("infra", "envs/prod") || issue.Labels[2] != "infra" {
+		t.Fatalf("unexpected labels %v", issue.Labels)
+	}
+
+	// An unchanged result is not reported twice.
+	if res := syncOnce(t, svc); res != (SyncResult{}) {
+		t.Fatalf("expected no changes, got %+v", res)
+	}
+
+	saveResult(t, store, "envs/prod", true, start.Add(time.Hour))
+	if res := syncOnce(t, svc); res != (SyncResult{Updated: 1}) || len(tracker.created) != 1 || len(tracker.comments["OPS-1"]) != 1 {
+		t.Fatalf("expected the existing issue updated, got %+v", res)
+	}
+
+	saveResult(t, store, "envs/prod", false, start.Add(2*time.Hour))
+	if res := syncOnce(t, svc); res != (SyncResult{Closed: 1}) {
+		t.Fatalf("expected issue closed, got %+v", res)
+	}
+	if got := tracker.transitions["OPS-1"]; len(got) != 1 || got[0] != "Done" {
+		t.Fatalf("expected Done transition, got %v", got)
+	}
+
+	// Without a reopen transition, drift after close opens a new issue.
+	saveResult(t, store, "envs/prod", true, start.Add(3*time.Hour))
+	if res := syncOnce(t, svc); res != (SyncResult{Opened: 1}) || len(tracker.created) != 2 {
+		t.Fatalf("expected a new issue, got %+v", res)
+	}
+	if e := svc.ledger.Get("infra", "envs/prod"); e == nil || e.IssueKey != "OPS-2" || e.Closed {
+		t.Fatalf("expected ledger to track OPS-2, got %+v", e)
+	}
+}
+
+func TestSyncReopensAndAdoptsExistingIssue(t *testing.T) {
+	dir := t.TempDir()
+	store := storage.New(dir)
+	tracker := newFakeTracker()
+	tracker.byLabel[stackLabel("infra", "envs/prod")] = &Issue{Key: "OPS-7", Done: true}
+	cfg := testJiraConfig()
+	cfg.ReopenTransition = "Reopen"
+	svc, err := New(cfg, store, dir, tracker)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	saveResult(t, store, "envs/prod", true, time.Now())
+	if res := syncOnce(t, svc); res != (SyncResult{Opened: 1}) {
+		t.Fatalf("expected the found issue reopened, got %+v", res)
+	}
+	if len(tracker.created) != 0 {
+		t.Fatalf("expected no new issue, got %+v", tracker.created)
+	}
+	if got := tracker.transitions["OPS-7"]; len(got) != 1 || got[0] != "Reopen" {
+		t.Fatalf("expected Reopen transition, got %v", got)
+	}
+
+	// The ledger survives a restart.
+	reloaded, err := LoadLedger(dir)
+	if err != nil {
+		t.Fatalf("load ledger: %v", err)
+	}
+	if e := reloaded.Get("infra", "envs/prod"); e == nil || e.IssueKey != "OPS-7" {
+		t.Fatalf("expected persisted entry, got %+v", e)
+	}
+}
+
+func TestSyncSkipsDriftObservedDuringFreeze(t *testing.T) {
+	dir := t.TempDir()
+	store := storage.New(dir)
+	tracker := newFakeTracker()
+	svc, err := New(testJiraConfig(), store, dir, tracker)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	now := time.Now()
+	if err := store.SaveResult("infra", "envs/prod", &storage.RunResult{Drifted: true, Changed: 1, RunAt: now, ObservedDuringFreeze: true}); err != nil {
+		t.Fatalf("save result: %v", err)
+	}
+	if res := syncOnce(t, svc); res.Opened != 0 || len(tracker.created) != 0 {
+		t.Fatalf("expected no issue during freeze, got %+v", res)
+	}
+
+	// The first scan after the freeze reports the drift as usual.
+	saveResult(t, store, "envs/prod", true, now.Add(time.Minute))
+	if res := syncOnce(t, svc); res.Opened != 1 {
+		t.Fatalf("expected issue after freeze, got %+v", res)
+	}
+}
@@ -0,0 +1,113 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const ledgerFileName = "jira.json"
+
+// Entry records the issue tracking one stack's drift.
+type Entry struct {
+	Project   string `json:"project"`
+	StackPath string `json:"stack_path"`
+	IssueKey  string `json:"issue_key"`
+	// RunAt is the stack result last reported on the issue.
+	RunAt  time.Time `json:"run_at"`
+	Closed bool      `json:"closed,omitempty"`
+}
+
+type ledgerData struct {
+	Version int               `json:"version"`
+	Entries map[string]*Entry `json:"entries"`
+}
+
+// Ledger persists the stack-to-issue mapping under the data directory so
+// repeated scans update one issue per stack.
+type Ledger struct {
+	path string
+	mu   sync.Mutex
+	data ledgerData
+}
+
+// LoadLedger reads the ledger from dataDir.
+func LoadLedger(dataDir string) (*Ledger, error) {
+	l := &Ledger{path: filepath.Join(dataDir, ledgerFileName)}
+	raw, err := os.ReadFile(l.path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read jira ledger: %w", err)
+	default:
+		if err := json.Unmarshal(raw, &l.data); err != nil {
+			return nil, fmt.Errorf("failed to parse jira ledger: %w", err)
+		}
+	}
+	if l.data.Entries == nil {
+		l.data.Entries = map[string]*Entry{}
+	}
+	return l, nil
+}
+
+// Get returns a copy of the entry for a stack, or nil.
+func (l *Ledger) Get(projectName, stackPath string) *Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.data.Entries[ledgerKey(projectName, stackPath)]
+	if !ok {
+		return nil
+	}
+	cp := *e
+	return &cp
+}
+
+// Put stores the entry for its stack.
+func (l *Ledger) Put(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.data.Entries[ledgerKey(e.Project, e.StackPath)] = &e
+	return l.saveLocked()
+}
+
+// Entries returns every entry, ordered by project and stack path.
+func (l *Ledger) Entries() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]Entry, 0, len(l.data.Entries))
+	for _, e := range l.data.Entries {
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Project != out[j].Project {
+			return out[i].Project < out[j].Project
+		}
+		return out[i].StackPath < out[j].StackPath
+	})
+	return out
+}
+
+func (l *Ledger) saveLocked() error {
+	l.data.Version = 1
+	raw, err := json.MarshalIndent(l.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal jira ledger: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0750); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write jira ledger: %w", err)
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// Project names cannot contain "/", so the first one splits the key.
+func ledgerKey(projectName, stackPath string) string {
+	return projectName + "/" + stackPath
+}
@@ -0,0 +1,136 @@
+// Package maintenance tracks whether driftd is in a maintenance window.
+//
+// The state lives in a file under the data directory rather than in Redis so
+// it stays readable while Redis itself is being maintained, and so every
+// server sharing the data directory sees the same window.
+package maintenance
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const stateFileName = "maintenance.json"
+
+// DefaultRetryAfter is suggested to clients when the window has no expected
+// end.
+const DefaultRetryAfter = 5 * time.Minute
+
+// Status describes the current maintenance window.
+type Status struct {
+	Enabled     bool       `json:"enabled"`
+	Reason      string     `json:"reason,omitempty"`
+	Actor       string     `json:"actor,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	ExpectedEnd *time.Time `json:"expected_end,omitempty"`
+}
+
+// RetryAfter returns how long clients should wait before retrying a request
+// refused during the window, never less than a minute.
+func (s Status) RetryAfter(now time.Time) time.Duration {
+	if s.ExpectedEnd == nil {
+		return DefaultRetryAfter
+	}
+	wait := s.ExpectedEnd.Sub(now).Round(time.Second)
+	if wait < time.Minute {
+		return time.Minute
+	}
+	return wait
+}
+
+// Mode reads and writes the maintenance state file. The file is re-read on
+// every call so that a window opened through one server applies to all of
+// them.
+type Mode struct {
+	path string
+	mu   sync.Mutex
+}
+
+// New returns a Mode backed by a state file in dataDir.
+func New(dataDir string) *Mode {
+	return &Mode{path: filepath.Join(dataDir, stateFileName)}
+}
+
+// Status returns the current state. A missing or unreadable file means
+// maintenance is off.
+func (m *Mode) Status() Status {
+	if m == nil {
+		return Status{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status, _ := m.loadLocked()
+	return status
+}
+
+// Active reports whether a maintenance window is open.
+func (m *Mode) Active() bool {
+	return m.Status().Enabled
+}
+
+// Enable opens a maintenance window. expectedEnd is advisory: it drives the
+// Retry-After hint but the window stays open until Disable is called.
+func (m *Mode) Enable(reason, actor string, expectedEnd *time.Time) (Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	status := Status{
+		Enabled:     true,
+		Reason:      reason,
+		Actor:       actor,
+		StartedAt:   &now,
+		ExpectedEnd: expectedEnd,
+	}
+	if current, err := m.loadLocked(); err == nil && current.Enabled && current.StartedAt != nil {
+		status.StartedAt = current.StartedAt
+	}
+	if err := m.saveLocked(status); err != nil {
+		return Status{}, err
+	}
+	return status, nil
+}
+
+// Disable closes the maintenance window.
+func (m *Mode) Disable() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err := os.Remove(m.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove maintenance state: %w", err)
+	}
+	return nil
+}
+
+func (m *Mode) loadLocked() (Status, error) {
+	raw, err := os.ReadFile(m.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Status{}, nil
+		}
+		return Status{}, fmt.Errorf("failed to read maintenance state: %w", err)
+	}
+	var status Status
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return Status{}, fmt.Errorf("failed to parse maintenance state: %w", err)
+	}
+	return status, nil
+}
+
+func (m *Mode) saveLocked(status Status) error {
+	raw, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal maintenance state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0750); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write maintenance state: %w", err)
+	}
+	return os.Rename(tmp, m.path)
+}
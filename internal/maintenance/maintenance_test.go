@@ -0,0 +1,64 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+)
+
+func TestModeEnableDisable(t *testing.T) {
+	dir := t.TempDir()
+	m := New(dir)
+	if m.Active() {
+		t.Fatalf("expected maintenance off by default")
+	}
+
+	end := time.Now().Add(30 * time.Minute).UTC()
+	status, err := m.Enable("redis upgrade", "ops", &end)
+	if err != nil {
+		t.Fatalf("enable: %v", err)
+	}
+	if !status.Enabled || status.StartedAt == nil {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	// A second Mode over the same directory sees the window.
+	other := New(dir)
+	got := other.Status()
+	if !got.Enabled || got.Reason != "redis upgrade" || got.Actor != "ops" {
+		t.Fatalf("expected shared state, got %+v", got)
+	}
+
+	// Re-enabling keeps the original start time.
+	again, err := m.Enable("still upgrading", "ops", nil)
+	if err != nil {
+		t.Fatalf("re-enable: %v", err)
+	}
+	if !again.StartedAt.Equal(*status.StartedAt) {
+		t.Fatalf("expected start time kept, got %v want %v", again.StartedAt, status.StartedAt)
+	}
+
+	if err := other.Disable(); err != nil {
+		t.Fatalf("disable: %v", err)
+	}
+	if m.Active() {
+		t.Fatalf("expected maintenance off after disable")
+	}
+	if err := m.Disable(); err != nil {
+		t.Fatalf("disable twice: %v", err)
+	}
+}
+
+func TestStatusRetryAfter(t *testing.T) {
+	now := time.Now()
+	if got := (Status{Enabled: true}).RetryAfter(now); got != DefaultRetryAfter {
+		t.Fatalf("expected default retry-after, got %v", got)
+	}
+	end := now.Add(10 * time.Minute)
+	if got := (Status{Enabled: true, ExpectedEnd: &end}).RetryAfter(now); got != 10*time.Minute {
+		t.Fatalf("expected 10m, got %v", got)
+	}
+	past := now.Add(-time.Minute)
+	if got := (Status{Enabled: true, ExpectedEnd: &past}).RetryAfter(now); got != time.Minute {
+		t.Fatalf("expected one minute floor, got %v", got)
+	}
+}
@@ -2,7 +2,7 @@ package metrics
 
 import (
 	"context"
-	"encoding/json"
+	"log"
 	"sync"
 	"time"
 
@@ -10,11 +10,30 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-const projectEventsPattern = "driftd:events:*"
-
 var (
 	registerOnce sync.Once
 
+	canaryRuns        *prometheus.CounterVec
+	canaryDuration    prometheus.Histogram
+	canaryLastSuccess prometheus.Gauge
+
+	queueWaitP95 prometheus.Gauge
+	queueStarved prometheus.Gauge
+
+	workspacesPruned        *prometheus.CounterVec
+	workspaceBytesReclaimed *prometheus.CounterVec
+	warehouseExported       *prometheus.CounterVec
+	warehouseExportErrors   prometheus.Counter
+
+	// spaces holds the spaces whose queue metrics are registered.
+	spacesMu sync.Mutex
+	spaces   = map[string]bool{}
+)
+
+// queueMetrics are the metrics of one queue backend: gauges that read it on
+// each scrape and counters fed by its project events. Each space registers
+// its own set.
+type queueMetrics struct {
 	activeScans *prometheus.GaugeVec
 
 	scansCompleted *prometheus.CounterVec
@@ -26,254 +45,421 @@ var (
 	stackDrifted   *prometheus.CounterVec
 
 	stackDuration *prometheus.HistogramVec
-)
+	queueWait     prometheus.Histogram
 
-type eventState struct {
 	mu          sync.Mutex
 	scanStatus  map[string]string
 	stackStatus map[string]string
 	stackStart  map[string]time.Time
 }
 
-func Register(q *queue.Queue) {
-	registerOnce.Do(func() {
-		if q == nil {
-			return
-		}
+// Register exposes the metrics of q. A serve process hosting spaces calls
+// it once per space with the space's name, which labels that space's queue
+// metrics with space; otherwise space is empty. Later calls for a space that
+// is already registered do nothing.
+func Register(space string, q queue.Backend) {
+	if q == nil {
+		return
+	}
+	registerOnce.Do(registerProcessMetrics)
+
+	spacesMu.Lock()
+	defer spacesMu.Unlock()
+	if spaces[space] {
+		return
+	}
+	spaces[space] = true
+	m := newQueueMetrics()
+	spaceRegisterer(prometheus.DefaultRegisterer, space).MustRegister(m.collectors(q)...)
+	go m.consumeEvents(q)
+}
 
-		activeScans = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+// spaceRegisterer labels the metrics registered through it with space, when
+// one is set.
+func spaceRegisterer(reg prometheus.Registerer, space string) prometheus.Registerer {
+	if space == "" {
+		return reg
+	}
+	return prometheus.WrapRegistererWith(prometheus.Labels{"space": space}, reg)
+}
+
+// registerProcessMetrics registers the metrics shared by every space.
+func registerProcessMetrics() {
+	canaryRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "driftd",
+		Name:      "canary_runs_total",
+		Help:      "Canary scans by result (success, failure, timeout, skipped).",
+	}, []string{"result"})
+	canaryDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "driftd",
+		Name:      "canary_duration_seconds",
+		Help:      "End-to-end duration of canary scans in seconds.",
+		Buckets:   prometheus.DefBuckets,
+	})
+	canaryLastSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "driftd",
+		Name:      "canary_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful canary scan.",
+	})
+
+	queueWaitP95 = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "driftd",
+		Name:      "queue_wait_p95_seconds",
+		Help:      "p95 queue wait of recent stack scans, or the oldest pending stack scan's wait if longer.",
+	})
+	queueStarved = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "driftd",
+		Name:      "queue_starved",
+		Help:      "1 while the queue starvation alarm is firing.",
+	})
+
+	workspacesPruned = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "driftd",
+		Name:      "workspaces_pruned_total",
+		Help:      "Scan workspaces deleted by the pruner, by scan status.",
+	}, []string{"status"})
+	workspaceBytesReclaimed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "driftd",
+		Name:      "workspace_pruned_bytes_total",
+		Help:      "Disk space reclaimed by the workspace pruner in bytes, by scan status.",
+	}, []string{"status"})
+	warehouseExported = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "driftd",
+		Name:      "warehouse_export_records_total",
+		Help:      "Records written by the warehouse export, by record type.",
+	}, []string{"type"})
+	warehouseExportErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "driftd",
+		Name:      "warehouse_export_errors_total",
+		Help:      "Warehouse export files that could not be written.",
+	})
+
+	prometheus.MustRegister(
+		canaryRuns,
+		canaryDuration,
+		canaryLastSuccess,
+		queueWaitP95,
+		queueStarved,
+		workspacesPruned,
+		workspaceBytesReclaimed,
+		warehouseExported,
+		warehouseExportErrors,
+	)
+}
+
+func newQueueMetrics() *queueMetrics {
+	return &queueMetrics{
+		activeScans: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: "driftd",
 			Name:      "active_scans",
 			Help:      "Number of active scans per repository.",
-		}, []string{"project"})
+		}, []string{"project"}),
 
-		scansCompleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		scansCompleted: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "driftd",
 			Name:      "scans_completed_total",
 			Help:      "Number of scans completed successfully.",
-		}, []string{"project"})
-		scansFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		}, []string{"project"}),
+		scansFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "driftd",
 			Name:      "scans_failed_total",
 			Help:      "Number of scans that failed.",
-		}, []string{"project"})
-		scansCanceled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		}, []string{"project"}),
+		scansCanceled: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "driftd",
 			Name:      "scans_canceled_total",
 			Help:      "Number of scans that were canceled.",
-		}, []string{"project"})
+		}, []string{"project"}),
 
-		stackCompleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		stackCompleted: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "driftd",
 			Name:      "stack_scans_completed_total",
 			Help:      "Number of stack scans completed successfully.",
-		}, []string{"project"})
-		stackFailed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		}, []string{"project"}),
+		stackFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "driftd",
 			Name:      "stack_scans_failed_total",
 			Help:      "Number of stack scans that failed.",
-		}, []string{"project"})
-		stackDrifted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		}, []string{"project"}),
+		stackDrifted: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: "driftd",
 			Name:      "stack_scans_drifted_total",
 			Help:      "Number of stack scans that detected drift.",
-		}, []string{"project"})
+		}, []string{"project"}),
 
-		stackDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		stackDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: "driftd",
 			Name:      "stack_scan_duration_seconds",
 			Help:      "Duration of stack scans in seconds.",
 			Buckets:   prometheus.DefBuckets,
-		}, []string{"project"})
-
-		prometheus.MustRegister(
-			activeScans,
-			scansCompleted,
-			scansFailed,
-			scansCanceled,
-			stackCompleted,
-			stackFailed,
-			stackDrifted,
-			stackDuration,
-			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
-				Namespace: "driftd",
-				Name:      "running_stack_scans",
-				Help:      "Number of stack scans currently marked running.",
-			}, func() float64 {
-				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-				defer cancel()
-				val, err := q.RunningStackScanCount(ctx)
-				if err != nil {
-					return 0
-				}
-				return float64(val)
-			}),
-			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
-				Namespace: "driftd",
-				Name:      "oldest_running_stack_scan_age_seconds",
-				Help:      "Age of the oldest running stack scan in seconds.",
-			}, func() float64 {
-				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-				defer cancel()
-				age, err := q.OldestRunningStackScanAge(ctx)
-				if err != nil {
-					return 0
-				}
-				return age.Seconds()
-			}),
-			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
-				Namespace: "driftd",
-				Name:      "running_scans",
-				Help:      "Number of scans currently marked running.",
-			}, func() float64 {
-				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-				defer cancel()
-				val, err := q.RunningScanCount(ctx)
-				if err != nil {
-					return 0
-				}
-				return float64(val)
-			}),
-			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
-				Namespace: "driftd",
-				Name:      "oldest_running_scan_age_seconds",
-				Help:      "Age of the oldest running scan in seconds.",
-			}, func() float64 {
-				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-				defer cancel()
-				age, err := q.OldestRunningScanAge(ctx)
-				if err != nil {
-					return 0
-				}
-				return age.Seconds()
-			}),
-			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
-				Namespace: "driftd",
-				Name:      "queue_depth",
-				Help:      "Number of pending stack scans in the queue.",
-			}, func() float64 {
-				ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-				defer cancel()
-				val, err := q.QueueDepth(ctx)
-				if err != nil {
-					return 0
-				}
-				return float64(val)
-			}),
-		)
-
-		state := &eventState{
-			scanStatus:  make(map[string]string),
-			stackStatus: make(map[string]string),
-			stackStart:  make(map[string]time.Time),
-		}
-		go consumeEvents(q, state)
-	})
+		}, []string{"project"}),
+		queueWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "driftd",
+			Name:      "queue_wait_seconds",
+			Help:      "Time stack scans waited between enqueue and claim in seconds.",
+			Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+		}),
+
+		scanStatus:  make(map[string]string),
+		stackStatus: make(map[string]string),
+		stackStart:  make(map[string]time.Time),
+	}
 }
 
-func consumeEvents(q *queue.Queue, state *eventState) {
-	pubsub := q.Client().PSubscribe(context.Background(), projectEventsPattern)
-	for msg := range pubsub.Channel() {
-		var event queue.ProjectEvent
-		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
-			continue
-		}
-		handleEvent(state, &event)
+// collectors returns m's metrics and the gauges that read q on scrape.
+func (m *queueMetrics) collectors(q queue.Backend) []prometheus.Collector {
+	return []prometheus.Collector{
+		m.activeScans,
+		m.scansCompleted,
+		m.scansFailed,
+		m.scansCanceled,
+		m.stackCompleted,
+		m.stackFailed,
+		m.stackDrifted,
+		m.stackDuration,
+		m.queueWait,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "driftd",
+			Name:      "running_stack_scans",
+			Help:      "Number of stack scans currently marked running.",
+		}, func() float64 {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			val, err := q.RunningStackScanCount(ctx)
+			if err != nil {
+				return 0
+			}
+			return float64(val)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "driftd",
+			Name:      "oldest_running_stack_scan_age_seconds",
+			Help:      "Age of the oldest running stack scan in seconds.",
+		}, func() float64 {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			age, err := q.OldestRunningStackScanAge(ctx)
+			if err != nil {
+				return 0
+			}
+			return age.Seconds()
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "driftd",
+			Name:      "running_scans",
+			Help:      "Number of scans currently marked running.",
+		}, func() float64 {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			val, err := q.RunningScanCount(ctx)
+			if err != nil {
+				return 0
+			}
+			return float64(val)
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "driftd",
+			Name:      "oldest_running_scan_age_seconds",
+			Help:      "Age of the oldest running scan in seconds.",
+		}, func() float64 {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			age, err := q.OldestRunningScanAge(ctx)
+			if err != nil {
+				return 0
+			}
+			return age.Seconds()
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "driftd",
+			Name:      "oldest_pending_stack_scan_age_seconds",
+			Help:      "Wait of the oldest unclaimed stack scan in seconds.",
+		}, func() float64 {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			age, err := q.OldestPendingStackScanAge(ctx)
+			if err != nil {
+				return 0
+			}
+			return age.Seconds()
+		}),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "driftd",
+			Name:      "queue_depth",
+			Help:      "Number of pending stack scans in the queue.",
+		}, func() float64 {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			val, err := q.QueueDepth(ctx)
+			if err != nil {
+				return 0
+			}
+			return float64(val)
+		}),
+	}
+}
+
+// ObserveCanary records one canary scan. Skipped runs don't count toward
+// duration.
+func ObserveCanary(result string, duration time.Duration) {
+	if canaryRuns == nil {
+		return
+	}
+	canaryRuns.WithLabelValues(result).Inc()
+	if result == "skipped" {
+		return
+	}
+	canaryDuration.Observe(duration.Seconds())
+	if result == "success" {
+		canaryLastSuccess.SetToCurrentTime()
+	}
+}
+
+// SetQueueWait records the queue alarm's latest reading.
+func SetQueueWait(p95 time.Duration, starved bool) {
+	if queueWaitP95 == nil {
+		return
+	}
+	queueWaitP95.Set(p95.Seconds())
+	if starved {
+		queueStarved.Set(1)
+	} else {
+		queueStarved.Set(0)
+	}
+}
+
+// ObserveWorkspacePrune records one workspace deleted by the pruner.
+func ObserveWorkspacePrune(status string, bytes int64) {
+	if workspacesPruned == nil {
+		return
 	}
+	workspacesPruned.WithLabelValues(status).Inc()
+	workspaceBytesReclaimed.WithLabelValues(status).Add(float64(bytes))
 }
 
-func handleEvent(state *eventState, event *queue.ProjectEvent) {
+// ObserveWarehouseExport counts records the warehouse export wrote.
+func ObserveWarehouseExport(recordType string, n int) {
+	if warehouseExported == nil {
+		return
+	}
+	warehouseExported.WithLabelValues(recordType).Add(float64(n))
+}
+
+// ObserveWarehouseExportError counts a file the warehouse export could not
+// write.
+func ObserveWarehouseExportError() {
+	if warehouseExportErrors == nil {
+		return
+	}
+	warehouseExportErrors.Inc()
+}
+
+func (m *queueMetrics) consumeEvents(q queue.Backend) {
+	events, err := q.SubscribeProjectEvents(context.Background(), "")
+	if err != nil {
+		log.Printf("metrics: failed to subscribe to project events: %v", err)
+		return
+	}
+	for event := range events {
+		m.handleEvent(&event)
+	}
+}
+
+func (m *queueMetrics) handleEvent(event *queue.ProjectEvent) {
 	switch event.Type {
 	case "scan_update":
-		updateScanMetrics(state, event)
+		m.updateScanMetrics(event)
 	case "stack_update":
-		updateStackMetrics(state, event)
+		m.updateStackMetrics(event)
 	}
 }
 
-func updateScanMetrics(state *eventState, event *queue.ProjectEvent) {
+func (m *queueMetrics) updateScanMetrics(event *queue.ProjectEvent) {
 	if event.ProjectName == "" || event.ScanID == "" || event.Status == "" {
 		return
 	}
 
-	state.mu.Lock()
-	defer state.mu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	prev := state.scanStatus[event.ScanID]
+	prev := m.scanStatus[event.ScanID]
 	if prev == event.Status {
 		return
 	}
-	state.scanStatus[event.ScanID] = event.Status
+	m.scanStatus[event.ScanID] = event.Status
 
 	switch event.Status {
 	case "running":
-		activeScans.WithLabelValues(event.ProjectName).Inc()
+		m.activeScans.WithLabelValues(event.ProjectName).Inc()
 	case "completed":
 		if prev == "running" {
-			activeScans.WithLabelValues(event.ProjectName).Dec()
+			m.activeScans.WithLabelValues(event.ProjectName).Dec()
 		}
-		scansCompleted.WithLabelValues(event.ProjectName).Inc()
-		delete(state.scanStatus, event.ScanID)
+		m.scansCompleted.WithLabelValues(event.ProjectName).Inc()
+		delete(m.scanStatus, event.ScanID)
 	case "failed":
 		if prev == "running" {
-			activeScans.WithLabelValues(event.ProjectName).Dec()
+			m.activeScans.WithLabelValues(event.ProjectName).Dec()
 		}
-		scansFailed.WithLabelValues(event.ProjectName).Inc()
-		delete(state.scanStatus, event.ScanID)
+		m.scansFailed.WithLabelValues(event.ProjectName).Inc()
+		delete(m.scanStatus, event.ScanID)
 	case "canceled":
 		if prev == "running" {
-			activeScans.WithLabelValues(event.ProjectName).Dec()
+			m.activeScans.WithLabelValues(event.ProjectName).Dec()
 		}
-		scansCanceled.WithLabelValues(event.ProjectName).Inc()
-		delete(state.scanStatus, event.ScanID)
+		m.scansCanceled.WithLabelValues(event.ProjectName).Inc()
+		delete(m.scanStatus, event.ScanID)
 	}
 }
 
-func updateStackMetrics(state *eventState, event *queue.ProjectEvent) {
+func (m *queueMetrics) updateStackMetrics(event *queue.ProjectEvent) {
 	if event.ProjectName == "" || event.StackPath == "" || event.Status == "" {
 		return
 	}
 
 	key := event.ProjectName + "|" + event.ScanID + "|" + event.StackPath
 
-	state.mu.Lock()
-	defer state.mu.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	prev := state.stackStatus[key]
+	prev := m.stackStatus[key]
 	if prev == event.Status {
 		return
 	}
-	state.stackStatus[key] = event.Status
+	m.stackStatus[key] = event.Status
 
 	switch event.Status {
 	case "running":
 		if event.RunAt != nil {
-			state.stackStart[key] = *event.RunAt
+			m.stackStart[key] = *event.RunAt
 		} else {
-			state.stackStart[key] = time.Now()
+			m.stackStart[key] = time.Now()
+		}
+		if event.QueuedAt != nil {
+			m.queueWait.Observe(m.stackStart[key].Sub(*event.QueuedAt).Seconds())
 		}
 	case "completed":
-		stackCompleted.WithLabelValues(event.ProjectName).Inc()
+		m.stackCompleted.WithLabelValues(event.ProjectName).Inc()
 		if event.Drifted != nil && *event.Drifted {
-			stackDrifted.WithLabelValues(event.ProjectName).Inc()
+			m.stackDrifted.WithLabelValues(event.ProjectName).Inc()
 		}
-		observeStackDuration(state, key, event.ProjectName)
-		delete(state.stackStatus, key)
+		m.observeStackDuration(key, event.ProjectName)
+		delete(m.stackStatus, key)
 	case "failed":
-		stackFailed.WithLabelValues(event.ProjectName).Inc()
-		observeStackDuration(state, key, event.ProjectName)
-		delete(state.stackStatus, key)
+		m.stackFailed.WithLabelValues(event.ProjectName).Inc()
+		m.observeStackDuration(key, event.ProjectName)
+		delete(m.stackStatus, key)
 	case "canceled":
-		observeStackDuration(state, key, event.ProjectName)
-		delete(state.stackStatus, key)
+		m.observeStackDuration(key, event.ProjectName)
+		delete(m.stackStatus, key)
 	}
 }
 
-func observeStackDuration(state *eventState, key, project string) {
-	start, ok := state.stackStart[key]
+func (m *queueMetrics) observeStackDuration(key, project string) {
+	start, ok := m.stackStart[key]
 	if !ok {
 		return
 	}
-	delete(state.stackStart, key)
-	stackDuration.WithLabelValues(project).Observe(time.Since(start).Seconds())
+	delete(m.stackStart, key)
+	m.stackDuration.WithLabelValues(project).Observe(time.Since(start).Seconds())
 }
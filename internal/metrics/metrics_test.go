@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"context"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -11,47 +13,48 @@ import (
 )
 
 func TestMetricsHandleEvents(t *testing.T) {
-	activeScans = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "active_scans"}, []string{"project"})
-	scansCompleted = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "scans_completed_total"}, []string{"project"})
-	scansFailed = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "scans_failed_total"}, []string{"project"})
-	scansCanceled = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "scans_canceled_total"}, []string{"project"})
-	stackCompleted = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "stack_scans_completed_total"}, []string{"project"})
-	stackFailed = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "stack_scans_failed_total"}, []string{"project"})
-	stackDrifted = prometheus.NewCounterVec(prometheus.CounterOpts{Name: "stack_scans_drifted_total"}, []string{"project"})
-	stackDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "stack_scan_duration_seconds"}, []string{"project"})
+	m := newQueueMetrics()
+	m.queueWait = prometheus.NewHistogram(prometheus.HistogramOpts{Name: "queue_wait_seconds", Help: "wait", Buckets: []float64{60, 120}})
 
-	state := &eventState{
-		scanStatus:  map[string]string{},
-		stackStatus: map[string]string{},
-		stackStart:  map[string]time.Time{},
-	}
-
-	updateScanMetrics(state, &queue.ProjectEvent{Type: "scan_update", ProjectName: "project", ScanID: "scan1", Status: "running"})
-	if got := testutil.ToFloat64(activeScans.WithLabelValues("project")); got != 1 {
+	m.updateScanMetrics(&queue.ProjectEvent{Type: "scan_update", ProjectName: "project", ScanID: "scan1", Status: "running"})
+	if got := testutil.ToFloat64(m.activeScans.WithLabelValues("project")); got != 1 {
 		t.Fatalf("active scans: got %v, want 1", got)
 	}
 
-	updateScanMetrics(state, &queue.ProjectEvent{Type: "scan_update", ProjectName: "project", ScanID: "scan1", Status: "completed"})
-	if got := testutil.ToFloat64(activeScans.WithLabelValues("project")); got != 0 {
+	m.updateScanMetrics(&queue.ProjectEvent{Type: "scan_update", ProjectName: "project", ScanID: "scan1", Status: "completed"})
+	if got := testutil.ToFloat64(m.activeScans.WithLabelValues("project")); got != 0 {
 		t.Fatalf("active scans after complete: got %v, want 0", got)
 	}
-	if got := testutil.ToFloat64(scansCompleted.WithLabelValues("project")); got != 1 {
+	if got := testutil.ToFloat64(m.scansCompleted.WithLabelValues("project")); got != 1 {
 		t.Fatalf("completed scans: got %v, want 1", got)
 	}
 
 	now := time.Now()
-	updateStackMetrics(state, &queue.ProjectEvent{Type: "stack_update", ProjectName: "project", ScanID: "scan1", StackPath: "stack", Status: "running", RunAt: &now})
+	queuedAt := now.Add(-90 * time.Second)
+	m.updateStackMetrics(&queue.ProjectEvent{Type: "stack_update", ProjectName: "project", ScanID: "scan1", StackPath: "stack", Status: "running", RunAt: &now, QueuedAt: &queuedAt})
+	expectedWait := `
+# HELP queue_wait_seconds wait
+# TYPE queue_wait_seconds histogram
+queue_wait_seconds_bucket{le="60"} 0
+queue_wait_seconds_bucket{le="120"} 1
+queue_wait_seconds_bucket{le="+Inf"} 1
+queue_wait_seconds_sum 90
+queue_wait_seconds_count 1
+`
+	if err := testutil.CollectAndCompare(m.queueWait, strings.NewReader(expectedWait)); err != nil {
+		t.Fatalf("queue wait: %v", err)
+	}
 	drifted := true
-	updateStackMetrics(state, &queue.ProjectEvent{Type: "stack_update", ProjectName: "project", ScanID: "scan1", StackPath: "stack", Status: "completed", Drifted: &drifted})
+	m.updateStackMetrics(&queue.ProjectEvent{Type: "stack_update", ProjectName: "project", ScanID: "scan1", StackPath: "stack", Status: "completed", Drifted: &drifted})
 
-	if got := testutil.ToFloat64(stackCompleted.WithLabelValues("project")); got != 1 {
+	if got := testutil.ToFloat64(m.stackCompleted.WithLabelValues("project")); got != 1 {
 		t.Fatalf("completed stacks: got %v, want 1", got)
 	}
-	if got := testutil.ToFloat64(stackDrifted.WithLabelValues("project")); got != 1 {
+	if got := testutil.ToFloat64(m.stackDrifted.WithLabelValues("project")); got != 1 {
 		t.Fatalf("drifted stacks: got %v, want 1", got)
 	}
 
-	if count := testutil.CollectAndCount(stackDuration); count == 0 {
+	if count := testutil.CollectAndCount(m.stackDuration); count == 0 {
 		t.Fatalf("expected histogram to be collected")
 	}
 }
@@ -80,3 +83,32 @@ func TestMetricsEndpointExposesQueueDepth(t *testing.T) {
 		t.Fatalf("expected driftd_queue_depth in metrics")
 	}
 }
+
+func TestSpaceQueueGaugesReadTheirOwnQueue(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	ctx := context.Background()
+	for space, depth := range map[string]int{"staging": 1, "prod": 2} {
+		q := queue.NewMemory(time.Minute)
+		t.Cleanup(func() { _ = q.Close() })
+		for i := 0; i < depth; i++ {
+			scan, err := q.StartScan(ctx, fmt.Sprintf("project-%d", i), "manual", "", "", 1)
+			if err != nil {
+				t.Fatalf("start scan: %v", err)
+			}
+			if err := q.Enqueue(ctx, &queue.StackScan{ScanID: scan.ID, ProjectName: scan.ProjectName, StackPath: "stack"}); err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+		}
+		spaceRegisterer(reg, space).MustRegister(newQueueMetrics().collectors(q)...)
+	}
+
+	expected := `
+# HELP driftd_queue_depth Number of pending stack scans in the queue.
+# TYPE driftd_queue_depth gauge
+driftd_queue_depth{space="prod"} 2
+driftd_queue_depth{space="staging"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "driftd_queue_depth"); err != nil {
+		t.Fatalf("queue depth: %v", err)
+	}
+}
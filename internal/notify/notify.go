@@ -0,0 +1,362 @@
+// Package notify sends newly drifted stacks to webhook channels. Each channel
+// can batch notifications into digests and hold them through quiet hours, so
+// low-severity drift found at night arrives as one message in the morning
+// while drift at or above the channel's urgent severity is sent at once.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/severity"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+// Webhook events.
+const (
+	EventDrift       = "drift"
+	EventDriftDigest = "drift_digest"
+)
+
+// Reasons a digest was held.
+const (
+	ReasonBatch      = "batch"
+	ReasonQuietHours = "quiet_hours"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the webhook body, prefixed
+// with "sha256=", when a channel has a webhook secret.
+const SignatureHeader = "X-Driftd-Signature-256"
+
+const (
+	webhookTimeout = 10 * time.Second
+	flushInterval  = 30 * time.Second
+)
+
+// Leader reports whether this replica should send notifications.
+type Leader interface {
+	IsLeader() bool
+}
+
+// Notification is one newly drifted stack.
+type Notification struct {
+	Project   string    `json:"project"`
+	StackPath string    `json:"stack_path"`
+	ScanID    string    `json:"scan_id,omitempty"`
+	Severity  string    `json:"severity"`
+	Score     int       `json:"score"`
+	Added     int       `json:"added"`
+	Changed   int       `json:"changed"`
+	Destroyed int       `json:"destroyed"`
+	URL       string    `json:"url,omitempty"`
+	DriftedAt time.Time `json:"drifted_at"`
+}
+
+// Payload is the JSON webhook body.
+type Payload struct {
+	Event   string `json:"event"`
+	Channel string `json:"channel"`
+	// Reason is why a digest was held: "batch" or "quiet_hours".
+	Reason        string         `json:"reason,omitempty"`
+	Notifications []Notification `json:"notifications"`
+	Timestamp     time.Time      `json:"timestamp"`
+}
+
+// digest collects a channel's held notifications until due.
+type digest struct {
+	reason string
+	due    time.Time
+	items  []Notification
+}
+
+// Notifier routes drift to channels. Every replica may run one; only the
+// leader sends, and held digests live in the leader's memory.
+type Notifier struct {
+	cfg    config.NotificationsConfig
+	store  storage.Store
+	policy *severity.Policy
+	queue  queue.Backend
+	client *http.Client
+	leader Leader
+
+	mu      sync.Mutex
+	pending map[string]*digest
+
+	stop   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a notifier for cfg. Results are read from store and scored
+// with policy.
+func New(cfg config.NotificationsConfig, store storage.Store, policy *severity.Policy, q queue.Backend) *Notifier {
+	return &Notifier{
+		cfg:     cfg,
+		store:   store,
+		policy:  policy,
+		queue:   q,
+		client:  &http.Client{Timeout: webhookTimeout},
+		pending: map[string]*digest{},
+		stop:    make(chan struct{}),
+	}
+}
+
+// SetLeader limits notifications to the replica holding the scheduler
+// lease, so several serve replicas don't each notify.
+func (n *Notifier) SetLeader(l Leader) {
+	n.leader = l
+}
+
+// Start follows scan events and sends due digests until Stop.
+func (n *Notifier) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := n.queue.SubscribeProjectEvents(ctx, "")
+	if err != nil {
+		cancel()
+		return fmt.Errorf("subscribe to events: %w", err)
+	}
+	n.cancel = cancel
+
+	n.wg.Add(2)
+	go func() {
+		defer n.wg.Done()
+		for event := range events {
+			n.HandleEvent(ctx, event, time.Now())
+		}
+	}()
+	go func() {
+		defer n.wg.Done()
+		ticker := time.NewTicker(flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-n.stop:
+				return
+			case <-ticker.C:
+			}
+			n.Flush(ctx, time.Now())
+		}
+	}()
+	return nil
+}
+
+// Stop ends delivery and waits for a webhook in flight. Held digests are
+// dropped.
+func (n *Notifier) Stop() {
+	close(n.stop)
+	if n.cancel != nil {
+		n.cancel()
+	}
+	n.wg.Wait()
+}
+
+// HandleEvent notifies on the stacks a finished scan newly found drifted.
+// Drift observed during an environment freeze is skipped.
+func (n *Notifier) HandleEvent(ctx context.Context, event queue.ProjectEvent, now time.Time) {
+	if event.Type != "scan_update" || len(event.DriftChanges) == 0 {
+		return
+	}
+	if n.leader != nil && !n.leader.IsLeader() {
+		return
+	}
+	for _, change := range event.DriftChanges {
+		if change.Current != "drifted" {
+			continue
+		}
+		result, err := n.store.GetResult(event.ProjectName, change.StackPath)
+		if err != nil {
+			log.Printf("notify: load %s/%s: %v", event.ProjectName, change.StackPath, err)
+			continue
+		}
+		if !result.Drifted || result.ObservedDuringFreeze {
+			continue
+		}
+		score := n.policy.ScoreResult(result)
+		item := Notification{
+			Project:   event.ProjectName,
+			StackPath: change.StackPath,
+			ScanID:    change.ScanID,
+			Severity:  severity.Level(score),
+			Score:     score,
+			Added:     result.Added,
+			Changed:   result.Changed,
+			Destroyed: result.Destroyed,
+			DriftedAt: change.ChangedAt,
+		}
+		if n.cfg.PublicURL != "" {
+			item.URL = n.cfg.PublicURL + "/projects/" + url.PathEscape(event.ProjectName) + "/stacks/" + change.StackPath
+		}
+		n.Notify(ctx, item, now)
+	}
+}
+
+// Notify routes one notification to every matching channel: sent at once
+// when urgent or when the channel neither batches nor is quiet, otherwise
+// added to the channel's digest.
+func (n *Notifier) Notify(ctx context.Context, item Notification, now time.Time) {
+	rank := config.SeverityRank(item.Severity)
+	for _, ch := range n.cfg.Channels {
+		if !ch.MatchesProject(item.Project) || rank < config.SeverityRank(ch.MinSeverity) {
+			continue
+		}
+		urgent := ch.UrgentSeverity != "" && rank >= config.SeverityRank(ch.UrgentSeverity)
+		quietEnd, quiet := ch.QuietHours.Active(now)
+		if urgent || (!quiet && ch.BatchWindow == 0) {
+			n.send(ctx, ch, Payload{Event: EventDrift, Channel: ch.Name, Notifications: []Notification{item}, Timestamp: now})
+			continue
+		}
+		n.hold(ch.Name, item, now, quietEnd, quiet, ch.BatchWindow)
+	}
+}
+
+func (n *Notifier) hold(channel string, item Notification, now, quietEnd time.Time, quiet bool, window time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	d := n.pending[channel]
+	if d == nil {
+		d = &digest{reason: ReasonBatch, due: now.Add(window)}
+		n.pending[channel] = d
+	}
+	// A digest held into quiet hours waits for them to end.
+	if quiet && quietEnd.After(d.due) {
+		d.reason = ReasonQuietHours
+		d.due = quietEnd
+	}
+	d.items = append(d.items, item)
+}
+
+// Flush sends the digests due by now. A batch coming due in quiet hours is
+// held until they end.
+func (n *Notifier) Flush(ctx context.Context, now time.Time) {
+	var due []Payload
+	n.mu.Lock()
+	for _, ch := range n.cfg.Channels {
+		d := n.pending[ch.Name]
+		if d == nil || d.due.After(now) {
+			continue
+		}
+		if quietEnd, quiet := ch.QuietHours.Active(now); quiet {
+			d.reason = ReasonQuietHours
+			d.due = quietEnd
+			continue
+		}
+		delete(n.pending, ch.Name)
+		due = append(due, Payload{Event: EventDriftDigest, Channel: ch.Name, Reason: d.reason, Notifications: d.items, Timestamp: now})
+	}
+	n.mu.Unlock()
+
+	for _, payload := range due {
+		n.send(ctx, n.channel(payload.Channel), payload)
+	}
+}
+
+func (n *Notifier) channel(name string) config.NotificationChannel {
+	for _, ch := range n.cfg.Channels {
+		if ch.Name == name {
+			return ch
+		}
+	}
+	return config.NotificationChannel{}
+}
+
+// Pending returns the number of notifications held for channel.
+func (n *Notifier) Pending(channel string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if d := n.pending[channel]; d != nil {
+		return len(d.items)
+	}
+	return 0
+}
+
+func (n *Notifier) send(ctx context.Context, ch config.NotificationChannel, payload Payload) {
+	if err := n.post(ctx, ch, payload); err != nil {
+		log.Printf("notify: %v", err)
+	}
+}
+
+// post sends payload to the channel's webhook. Errors name the channel but
+// never the URL: a Slack incoming webhook URL is the credential itself.
+
+func (n *Notifier) post(ctx context.Context, ch config.NotificationChannel, payload Payload) error {
+	var body []byte
+	var err error
+	if ch.Format == config.NotificationFormatSlack {
+		body, err = json.Marshal(map[string]string{"text": slackText(payload)})
+	} else {
+		body, err = json.Marshal(payload)
+	}
+	if err != nil {
+		return fmt.Errorf("channel %s: %w", ch.Name, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ch.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("channel %s: %w", ch.Name, withoutURL(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if ch.WebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(ch.WebhookSecret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("channel %s: %w", ch.Name, withoutURL(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("channel %s: webhook returned %s", ch.Name, resp.Status)
+	}
+	return nil
+}
+
+// withoutURL drops the request URL a *url.Error carries, keeping the
+// operation and the underlying error.
+func withoutURL(err error) error {
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		return fmt.Errorf("%s: %w", uerr.Op, uerr.Err)
+	}
+	return err
+}
+
+func slackText(payload Payload) string {
+	var b strings.Builder
+	if payload.Event == EventDriftDigest {
+		held := "in the last batch"
+		if payload.Reason == ReasonQuietHours {
+			held = "during quiet hours"
+		}
+		fmt.Fprintf(&b, "%d stacks drifted %s:\n", len(payload.Notifications), held)
+	}
+	for i, item := range payload.Notifications {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if payload.Event == EventDriftDigest {
+			b.WriteString("• ")
+		} else {
+			b.WriteString("Drift detected: ")
+		}
+		stack := item.Project + "/" + item.StackPath
+		if item.URL != "" {
+			stack = "<" + item.URL + "|" + stack + ">"
+		}
+		fmt.Fprintf(&b, "%s (%s, +%d ~%d -%d)", stack, item.Severity, item.Added, item.Changed, item.Destroyed)
+	}
+	return b.String()
+}
@@ -0,0 +1,195 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/severity"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+type receiver struct {
+	mu       sync.Mutex
+	payloads []Payload
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var p Payload
+	_ = json.NewDecoder(req.Body).Decode(&p)
+	r.mu.Lock()
+	r.payloads = append(r.payloads, p)
+	r.mu.Unlock()
+}
+
+func (r *receiver) take() []Payload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := r.payloads
+	r.payloads = nil
+	return out
+}
+
+func TestQuietHoursDigestAndUrgentBypass(t *testing.T) {
+	rcv := &receiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	quiet := &config.QuietHours{Start: "22:00", End: "07:00"}
+	if err := quiet.Parse(); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	n := New(config.NotificationsConfig{Channels: []config.NotificationChannel{{
+		Name:           "oncall",
+		WebhookURL:     srv.URL,
+		QuietHours:     quiet,
+		UrgentSeverity: severity.LevelCritical,
+	}}}, nil, severity.New(config.SeverityConfig{}), nil)
+	ctx := context.Background()
+
+	night := time.Date(2026, 3, 10, 3, 0, 0, 0, time.UTC)
+	n.Notify(ctx, Notification{Project: "infra", StackPath: "envs/dev", Severity: severity.LevelLow}, night)
+	n.Notify(ctx, Notification{Project: "infra", StackPath: "envs/qa", Severity: severity.LevelMedium}, night.Add(time.Hour))
+	if got := rcv.take(); len(got) != 0 {
+		t.Fatalf("expected low severity drift to be held, got %+v", got)
+	}
+
+	n.Notify(ctx, Notification{Project: "infra", StackPath: "envs/prod", Severity: severity.LevelCritical}, night)
+	got := rcv.take()
+	if len(got) != 1 || got[0].Event != EventDrift || got[0].Notifications[0].StackPath != "envs/prod" {
+		t.Fatalf("expected critical drift to bypass quiet hours, got %+v", got)
+	}
+
+	n.Flush(ctx, night.Add(3*time.Hour))
+	if got := rcv.take(); len(got) != 0 {
+		t.Fatalf("expected digest to wait for quiet hours to end, got %+v", got)
+	}
+	n.Flush(ctx, time.Date(2026, 3, 10, 7, 0, 0, 0, time.UTC))
+	got = rcv.take()
+	if len(got) != 1 || got[0].Event != EventDriftDigest || got[0].Reason != ReasonQuietHours || len(got[0].Notifications) != 2 {
+		t.Fatalf("expected one quiet hours digest of two stacks, got %+v", got)
+	}
+	if n.Pending("oncall") != 0 {
+		t.Fatal("expected digest to be cleared after sending")
+	}
+}
+
+func TestBatchWindowHeldIntoQuietHours(t *testing.T) {
+	rcv := &receiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	quiet := &config.QuietHours{Start: "22:00", End: "07:00"}
+	if err := quiet.Parse(); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	n := New(config.NotificationsConfig{Channels: []config.NotificationChannel{{
+		Name:        "team",
+		WebhookURL:  srv.URL,
+		BatchWindow: 10 * time.Minute,
+		QuietHours:  quiet,
+	}}}, nil, severity.New(config.SeverityConfig{}), nil)
+	ctx := context.Background()
+
+	noon := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	n.Notify(ctx, Notification{Project: "infra", StackPath: "a", Severity: severity.LevelHigh}, noon)
+	n.Notify(ctx, Notification{Project: "infra", StackPath: "b", Severity: severity.LevelLow}, noon.Add(5*time.Minute))
+	n.Flush(ctx, noon.Add(9*time.Minute))
+	if got := rcv.take(); len(got) != 0 {
+		t.Fatalf("expected batch to wait for its window, got %+v", got)
+	}
+	n.Flush(ctx, noon.Add(10*time.Minute))
+	got := rcv.take()
+	if len(got) != 1 || got[0].Reason != ReasonBatch || len(got[0].Notifications) != 2 {
+		t.Fatalf("expected one batch digest of two stacks, got %+v", got)
+	}
+
+	late := time.Date(2026, 3, 10, 21, 55, 0, 0, time.UTC)
+	n.Notify(ctx, Notification{Project: "infra", StackPath: "c", Severity: severity.LevelLow}, late)
+	n.Flush(ctx, late.Add(10*time.Minute))
+	if got := rcv.take(); len(got) != 0 {
+		t.Fatalf("expected a batch coming due in quiet hours to be held, got %+v", got)
+	}
+	n.Flush(ctx, time.Date(2026, 3, 11, 7, 0, 0, 0, time.UTC))
+	if got := rcv.take(); len(got) != 1 || got[0].Reason != ReasonQuietHours {
+		t.Fatalf("expected the batch to be sent when quiet hours end, got %+v", got)
+	}
+}
+
+func TestHandleEventSkipsFrozenDrift(t *testing.T) {
+	rcv := &receiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	store := storage.New(t.TempDir())
+	if err := store.SaveResult("infra", "envs/prod", &storage.RunResult{Drifted: true, Changed: 1, RunAt: time.Now()}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := store.SaveResult("infra", "envs/dev", &storage.RunResult{Drifted: true, Changed: 1, ObservedDuringFreeze: true, RunAt: time.Now()}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	n := New(config.NotificationsConfig{
+		PublicURL: "https://driftd.example.com",
+		Channels:  []config.NotificationChannel{{Name: "all", WebhookURL: srv.URL}},
+	}, store, severity.New(config.SeverityConfig{}), nil)
+
+	n.HandleEvent(context.Background(), queue.ProjectEvent{
+		Type:        "scan_update",
+		ProjectName: "infra",
+		DriftChanges: []queue.DriftChange{
+			{StackPath: "envs/prod", Previous: "healthy", Current: "drifted"},
+			{StackPath: "envs/dev", Previous: "healthy", Current: "drifted"},
+			{StackPath: "envs/qa", Previous: "drifted", Current: "healthy"},
+		},
+	}, time.Now())
+	got := rcv.take()
+	if len(got) != 1 || got[0].Notifications[0].StackPath != "envs/prod" {
+		t.Fatalf("expected only the unfrozen stack to notify, got %+v", got)
+	}
+	if item := got[0].Notifications[0]; item.Severity != severity.LevelLow || item.URL != "https://driftd.example.com/projects/infra/stacks/envs/prod" {
+		t.Fatalf("unexpected notification %+v", item)
+	}
+}
+
+func TestFailedSendKeepsWebhookURLOutOfLogs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no_service", http.StatusNotFound)
+	}))
+	defer srv.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	for _, webhookURL := range []string{
+		srv.URL + "/services/T000/B000/SECRETSECRET",
+		down.URL + "/services/T000/B000/SECRETSECRET",
+	} {
+		ch := config.NotificationChannel{Name: "slack", WebhookURL: webhookURL, Format: config.NotificationFormatSlack}
+		n := New(config.NotificationsConfig{Channels: []config.NotificationChannel{ch}}, nil, severity.New(config.SeverityConfig{}), nil)
+		payload := Payload{Event: EventDrift, Notifications: []Notification{{Project: "infra", StackPath: "envs/prod"}}}
+		err := n.post(context.Background(), ch, payload)
+		if err == nil || !strings.Contains(err.Error(), "channel slack") {
+			t.Fatalf("expected an error naming the channel, got %v", err)
+		}
+		if strings.Contains(err.Error(), "SECRETSECRET") {
+			t.Fatalf("error contains the webhook URL: %v", err)
+		}
+		n.send(context.Background(), ch, payload)
+	}
+	if out := logs.String(); out == "" || strings.Contains(out, "SECRETSECRET") {
+		t.Fatalf("expected failures logged without the webhook URL, got %q", out)
+	}
+}
@@ -0,0 +1,83 @@
+package orchestrate
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/projects"
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+// TriggerChain marks scans started by another project's chain.
+const TriggerChain = "chain"
+
+// SetProjectProvider lets chains start scans of projects managed through the
+// settings API. Without it only projects from the config file are found.
+func (o *ScanOrchestrator) SetProjectProvider(p projects.Provider) {
+	o.projects = p
+}
+
+// runChain starts the chained scans of projectCfg once scanID has finished.
+// Only completed scans fire links. A project already in lineage is skipped,
+// so a loop introduced after the chains were validated still ends.
+func (o *ScanOrchestrator) runChain(scanID string, projectCfg *config.ProjectConfig, lineage []string) {
+	if len(projectCfg.Chain) == 0 || o.ctx.Err() != nil {
+		return
+	}
+	scan, err := o.queue.GetScan(o.ctx, scanID)
+	if err != nil || scan.Status != queue.ScanStatusCompleted {
+		return
+	}
+	drifted := scan.Drifted > 0
+	lineage = append(slices.Clone(lineage), projectCfg.Name)
+	started := map[string]struct{}{}
+	for _, link := range projectCfg.Chain {
+		if !link.Fires(drifted) {
+			continue
+		}
+		if _, ok := started[link.Project]; ok {
+			continue
+		}
+		started[link.Project] = struct{}{}
+		if slices.Contains(lineage, link.Project) {
+			log.Printf("scan %s: chain loop %s -> %s, not starting", scanID, strings.Join(lineage, " -> "), link.Project)
+			continue
+		}
+		target, err := o.lookupProject(link.Project)
+		if err != nil {
+			log.Printf("scan %s: chained project %s: %v", scanID, link.Project, err)
+			continue
+		}
+		if err := o.startChained(target, projectCfg.Name, lineage); err != nil {
+			log.Printf("scan %s: chained scan of %s: %v", scanID, link.Project, err)
+		}
+	}
+}
+
+func (o *ScanOrchestrator) startChained(target *config.ProjectConfig, upstream string, lineage []string) error {
+	actor := "chain:" + upstream
+	scan, stacks, err := o.startScan(o.ctx, target, TriggerChain, "", actor, nil, scanOrigin{lineage: lineage})
+	if err != nil {
+		if errors.Is(err, queue.ErrProjectLocked) {
+			return errors.New("project already has a scan running")
+		}
+		return err
+	}
+	if _, err := o.EnqueueStacks(o.ctx, scan, target, stacks, TriggerChain, "", actor); err != nil && !errors.Is(err, ErrNoStacksEnqueued) {
+		return err
+	}
+	return nil
+}
+
+func (o *ScanOrchestrator) lookupProject(name string) (*config.ProjectConfig, error) {
+	if o.projects != nil {
+		return o.projects.Get(name)
+	}
+	if project := o.cfg.GetProject(name); project != nil {
+		return project, nil
+	}
+	return nil, errors.New("project not found")
+}
@@ -0,0 +1,81 @@
+package orchestrate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+func finishScan(t *testing.T, q *queue.MemoryQueue, projectName string, drifted bool) string {
+	t.Helper()
+	ctx := context.Background()
+	scan, err := q.StartScan(ctx, projectName, "manual", "", "", 1)
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	if err := q.Enqueue(ctx, &queue.StackScan{ScanID: scan.ID, ProjectName: projectName, StackPath: "envs/dev"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	job, err := q.Dequeue(ctx, "worker")
+	if err != nil {
+		t.Fatalf("dequeue: %v", err)
+	}
+	if err := q.Complete(ctx, job, drifted); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	return scan.ID
+}
+
+func TestRunChainStartsDownstreamScans(t *testing.T) {
+	downstreamDir := t.TempDir()
+	initGitRepo(t, downstreamDir)
+
+	q := queue.NewMemory(time.Minute)
+	defer q.Close()
+
+	downstream := config.ProjectConfig{Name: "app", URL: "file://" + downstreamDir}
+	upstream := config.ProjectConfig{
+		Name: "network",
+		URL:  "file:///unused",
+		Chain: []config.ChainTrigger{
+			{Project: "app", On: config.ChainOnDrifted},
+			{Project: "missing", On: config.ChainOnClean},
+		},
+	}
+	cfg := &config.Config{
+		DataDir:  t.TempDir(),
+		Projects: []config.ProjectConfig{upstream, downstream},
+		Worker: config.WorkerConfig{
+			LockTTL:    time.Minute,
+			ScanMaxAge: time.Hour,
+			RenewEvery: time.Minute,
+		},
+	}
+	orch := New(cfg, q)
+	defer orch.Stop()
+	ctx := context.Background()
+
+	// A clean result does not fire the drifted link.
+	orch.runChain(finishScan(t, q, "network", false), &upstream, nil)
+	if _, err := q.GetActiveScan(ctx, "app"); err != queue.ErrScanNotFound {
+		t.Fatalf("expected no chained scan after clean upstream, got %v", err)
+	}
+
+	// A project already in the lineage is not scanned again.
+	orch.runChain(finishScan(t, q, "network", true), &upstream, []string{"app"})
+	if _, err := q.GetActiveScan(ctx, "app"); err != queue.ErrScanNotFound {
+		t.Fatalf("expected loop to be skipped, got %v", err)
+	}
+
+	orch.runChain(finishScan(t, q, "network", true), &upstream, nil)
+	chained, err := q.GetActiveScan(ctx, "app")
+	if err != nil {
+		t.Fatalf("expected chained scan: %v", err)
+	}
+	if chained.Trigger != TriggerChain || chained.Actor != "chain:network" || chained.Total != 1 {
+		t.Fatalf("unexpected chained scan %+v", chained)
+	}
+}
@@ -0,0 +1,85 @@
+package orchestrate
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/gitauth"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ChangedFilesBetween fetches the project's shared mirror and returns the
+// paths whose content differs between the before and after commits, sorted.
+// Webhooks use it when the push payload's file list cannot be trusted: the
+// provider truncated the commit list, or a force push rewrote history so the
+// listed commits no longer describe what changed on the branch. An error
+// means either commit is missing from the mirror, and callers should fall
+// back to scanning every stack.
+func (o *ScanOrchestrator) ChangedFilesBetween(ctx context.Context, projectCfg *config.ProjectConfig, before, after string) (files []string, err error) {
+	cloneURL := projectCfg.EffectiveCloneURL()
+	if strings.TrimSpace(cloneURL) == "" {
+		return nil, fmt.Errorf("project clone URL is empty")
+	}
+	conn, err := gitauth.Connect(ctx, projectCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	urlHash := hashCloneURL(cloneURL)
+	ctx, releaseCloneLock, err := o.holdCloneLock(ctx, urlHash, "diff:"+projectCfg.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if releaseErr := releaseCloneLock(); releaseErr != nil && err == nil {
+			err = releaseErr
+		}
+	}()
+
+	mirrorPath := filepath.Join(o.cfg.DataDir, "workspaces", "_shared", urlHash, "mirror.git")
+	mirror, err := o.openOrCreateMirror(ctx, mirrorPath, cloneURL, conn)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.fetchMirror(ctx, mirror, conn, allBranchesRefSpec); err != nil {
+		return nil, err
+	}
+
+	trees := make([]*object.Tree, 2)
+	for i, sha := range []string{before, after} {
+		hash, ok := resolveCommit(mirror, sha)
+		if !ok {
+			return nil, fmt.Errorf("commit %s not found in mirror", sha)
+		}
+		commit, err := mirror.CommitObject(hash)
+		if err != nil {
+			return nil, err
+		}
+		if trees[i], err = commit.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	changes, err := object.DiffTreeWithOptions(ctx, trees[0], trees[1], object.DefaultDiffTreeOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{})
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name == "" {
+				continue
+			}
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				files = append(files, name)
+			}
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
@@ -0,0 +1,62 @@
+package orchestrate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/gitauth"
+	"github.com/driftdhq/driftd/internal/stack"
+	"github.com/driftdhq/driftd/internal/version"
+)
+
+// DiscoveryResult is the outcome of a dry discovery run.
+type DiscoveryResult struct {
+	CommitSHA string
+	Stacks    []string
+	Versions  *version.Versions
+	Ignored   []stack.IgnoreMatch
+	// Tags maps stack paths to the metadata tags declared in their files.
+	Tags map[string]map[string]string
+	// ModuleSources maps stack paths to the module sources they reference.
+	ModuleSources map[string][]stack.ModuleSource
+}
+
+// Discover checks out the project's target branch through the shared mirror
+// and reports the stacks and versions a scan would use, without taking the
+// project lock or enqueueing anything. The temporary checkout is removed
+// before returning.
+func (o *ScanOrchestrator) Discover(ctx context.Context, projectCfg *config.ProjectConfig) (*DiscoveryResult, error) {
+	conn, err := gitauth.Connect(ctx, projectCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	discoveryID := fmt.Sprintf("discover-%d", time.Now().UnixNano())
+	workspacePath, commitSHA, err := o.cloneWorkspace(ctx, projectCfg, discoveryID, conn, "")
+	defer os.RemoveAll(filepath.Join(o.cfg.DataDir, "workspaces", "scans", projectCfg.Name, discoveryID))
+	if err != nil {
+		return nil, err
+	}
+
+	stacks, ignored, err := stack.DiscoverWithIgnored(workspacePath, projectCfg.RootPath, projectCfg.IgnorePaths)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := version.DetectWithDefaults(workspacePath, stacks, projectCfg.TerraformVersion, projectCfg.TerragruntVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DiscoveryResult{
+		CommitSHA:     commitSHA,
+		Stacks:        stacks,
+		Versions:      versions,
+		Ignored:       ignored,
+		Tags:          stack.DiscoverTags(workspacePath, stacks),
+		ModuleSources: stack.DiscoverModuleSources(workspacePath, stacks),
+	}, nil
+}
@@ -6,8 +6,10 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -17,23 +19,26 @@ import (
 	"github.com/driftdhq/driftd/internal/gitauth"
 	"github.com/driftdhq/driftd/internal/projects"
 	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/scanlimit"
 	"github.com/driftdhq/driftd/internal/stack"
+	"github.com/driftdhq/driftd/internal/storage"
 	"github.com/driftdhq/driftd/internal/version"
 	"github.com/go-git/go-git/v5"
 	gitcfg "github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/transport"
 )
 
 // ScanOrchestrator handles the full lifecycle of starting a scan:
 // acquiring the project lock, cloning the workspace, discovering stacks,
 // detecting versions, and spawning the lock renewal goroutine.
 type ScanOrchestrator struct {
-	cfg    *config.Config
-	queue  *queue.Queue
-	ctx    context.Context
-	cancel context.CancelFunc
-	wg     sync.WaitGroup
+	cfg      *config.Config
+	queue    queue.Backend
+	limiter  *scanlimit.Limiter
+	projects projects.Provider
+	ctx      context.Context
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
 }
 
 const (
@@ -42,13 +47,14 @@ const (
 	minCloneRenewEvery  = 5 * time.Second
 )
 
-func New(cfg *config.Config, q *queue.Queue) *ScanOrchestrator {
+func New(cfg *config.Config, q queue.Backend) *ScanOrchestrator {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &ScanOrchestrator{
-		cfg:    cfg,
-		queue:  q,
-		ctx:    ctx,
-		cancel: cancel,
+		cfg:     cfg,
+		queue:   q,
+		limiter: scanlimit.New(cfg, q),
+		ctx:     ctx,
+		cancel:  cancel,
 	}
 }
 
@@ -62,12 +68,55 @@ func (o *ScanOrchestrator) Stop() {
 // clones the workspace, discovers stacks, detects versions, and spawns a
 // background lock renewal goroutine. On any failure, the scan is marked failed.
 func (o *ScanOrchestrator) StartScan(ctx context.Context, projectCfg *config.ProjectConfig, trigger, commit, actor string) (*queue.Scan, []string, error) {
+	return o.startScan(ctx, projectCfg, trigger, commit, actor, nil, scanOrigin{})
+}
+
+// StartScanForChanges behaves like StartScan for a push that changed
+// changedFiles and returns only the stacks affected by them: stacks that
+// contain a changed file and stacks calling a local module that does. When at most
+// workspace.incremental_max_stacks stacks are affected, the workspace is a
+// sparse checkout of those stacks and their local dependencies instead of the
+// whole repository.
+func (o *ScanOrchestrator) StartScanForChanges(ctx context.Context, projectCfg *config.ProjectConfig, changedFiles []string, trigger, commit, actor string) (*queue.Scan, []string, error) {
+	scan, stacks, err := o.startScan(ctx, projectCfg, trigger, commit, actor, changedFiles, scanOrigin{})
+	if err != nil {
+		return nil, nil, err
+	}
+	selected := SelectStacksForChanges(stacks, changedFiles)
+	return scan, addModuleDependents(selected, stack.BuildModuleIndex(scan.WorkspacePath, stacks), changedFiles), nil
+}
+
+// StartScanForChangesAndStacks behaves like StartScanForChanges and also
+// selects the named stacks that exist in the project. The workspace is always
+// a full checkout so stacks outside the changed files can be planned.
+func (o *ScanOrchestrator) StartScanForChangesAndStacks(ctx context.Context, projectCfg *config.ProjectConfig, changedFiles, stacks []string, trigger, commit, actor string) (*queue.Scan, []string, error) {
+	scan, all, err := o.startScan(ctx, projectCfg, trigger, commit, actor, nil, scanOrigin{})
+	if err != nil {
+		return nil, nil, err
+	}
+	selected := SelectStacksForChanges(all, changedFiles)
+	selected = addModuleDependents(selected, stack.BuildModuleIndex(scan.WorkspacePath, all), changedFiles)
+	for _, s := range stacks {
+		if slices.Contains(all, s) && !slices.Contains(selected, s) {
+			selected = append(selected, s)
+		}
+	}
+	sort.Strings(selected)
+	return scan, selected, nil
+}
+
+func (o *ScanOrchestrator) startScan(ctx context.Context, projectCfg *config.ProjectConfig, trigger, commit, actor string, changedFiles []string, origin scanOrigin) (*queue.Scan, []string, error) {
+	phases := newPhaseTimer()
+	release, err := o.limiter.Reserve(ctx, projectCfg, trigger)
+	if err != nil {
+		return nil, nil, err
+	}
 	scan, err := o.queue.StartScan(ctx, projectCfg.Name, trigger, commit, actor, 0)
 	if err != nil {
 		if err == queue.ErrProjectLocked && projectCfg.CancelInflightEnabled() {
 			activeScan, activeErr := o.queue.GetActiveScan(ctx, projectCfg.Name)
 			if activeErr == nil && activeScan != nil {
-				if queue.TriggerPriority(trigger) >= queue.TriggerPriority(activeScan.Trigger) {
+				if o.scanPriority(projectCfg, trigger) >= o.scanPriority(projectCfg, activeScan.Trigger) {
 					scan, err = o.queue.CancelAndStartScan(ctx, activeScan.ID, projectCfg.Name, "superseded by new trigger", trigger, commit, actor, 0)
 					if err == nil {
 						o.queue.ClearInflightForScan(ctx, activeScan.ID)
@@ -76,9 +125,17 @@ func (o *ScanOrchestrator) StartScan(ctx context.Context, projectCfg *config.Pro
 			}
 		}
 		if err != nil {
+			release()
 			return nil, nil, err
 		}
 	}
+	phases.mark(queue.PhaseLock)
+	defer o.recordPhases(ctx, scan.ID, phases)
+	if origin.retryOf != "" {
+		if err := o.queue.LinkScanRetry(ctx, origin.retryOf, scan.ID); err != nil {
+			log.Printf("scan %s: link retry of %s: %v", scan.ID, origin.retryOf, err)
+		}
+	}
 	_ = o.queue.PublishScanEvent(ctx, projectCfg.Name, queue.ScanEvent{
 		ProjectName: projectCfg.Name,
 		ScanID:      scan.ID,
@@ -91,36 +148,77 @@ func (o *ScanOrchestrator) StartScan(ctx context.Context, projectCfg *config.Pro
 	go func() {
 		defer o.wg.Done()
 		o.queue.RenewScanLock(o.ctx, scan.ID, projectCfg.Name, o.cfg.Worker.ScanMaxAge, o.cfg.Worker.RenewEvery)
+		o.runChain(scan.ID, projectCfg, origin.lineage)
 	}()
 
-	auth, err := gitauth.AuthMethod(ctx, projectCfg)
+	conn, err := gitauth.Connect(ctx, projectCfg)
 	if err != nil {
-		_ = o.queue.FailScan(ctx, scan.ID, projectCfg.Name, err.Error())
+		o.failEarly(ctx, scan, projectCfg, origin, err.Error())
 		return nil, nil, err
 	}
 
-	workspacePath, commitSHA, err := o.cloneWorkspace(ctx, projectCfg, scan.ID, auth)
-	if err != nil {
-		_ = o.queue.FailScan(ctx, scan.ID, projectCfg.Name, err.Error())
-		return nil, nil, err
+	pinCommit := ""
+	if projectCfg.CheckoutTriggerCommit {
+		pinCommit = commit
+	}
+
+	var (
+		workspacePath string
+		commitSHA     string
+		stacks        []string
+	)
+	if len(changedFiles) > 0 && o.cfg.Workspace.IncrementalStackLimit() > 0 {
+		workspacePath, commitSHA, stacks, err = o.sparseWorkspace(ctx, projectCfg, scan.ID, conn, pinCommit, changedFiles)
+		if err != nil {
+			if !errors.Is(err, errSparseNotApplicable) {
+				log.Printf("scan %s: incremental workspace failed, using a full checkout: %v", scan.ID, err)
+			}
+			workspacePath = ""
+		}
+	}
+	if workspacePath == "" {
+		workspacePath, commitSHA, err = o.cloneWorkspace(ctx, projectCfg, scan.ID, conn, pinCommit)
+		if err != nil {
+			o.failEarly(ctx, scan, projectCfg, origin, err.Error())
+			return nil, nil, err
+		}
+	}
+	if queue.CommitSkewed(commit, commitSHA) {
+		log.Printf("scan %s: commit skew, trigger requested %s but workspace is at %s", scan.ID, commit, commitSHA)
 	}
 
 	if err := o.queue.SetScanWorkspace(ctx, scan.ID, workspacePath, commitSHA); err != nil {
 		_ = o.queue.FailScan(ctx, scan.ID, projectCfg.Name, fmt.Sprintf("failed to set workspace: %v", err))
 		return nil, nil, err
 	}
+	scan.WorkspacePath = workspacePath
+	scan.CommitSHA = commitSHA
+	if info, err := readCommitInfo(workspacePath, commitSHA); err != nil {
+		log.Printf("scan %s: read commit %s: %v", scan.ID, commitSHA, err)
+	} else if err := o.queue.SetScanCommitInfo(ctx, scan.ID, info); err != nil {
+		log.Printf("scan %s: record commit info: %v", scan.ID, err)
+	}
+	phases.mark(queue.PhaseClone)
 	go o.cleanupWorkspaces(projectCfg.Name)
 
-	stacks, err := stack.Discover(workspacePath, projectCfg.RootPath, projectCfg.IgnorePaths)
-	if err != nil {
-		_ = o.queue.FailScan(ctx, scan.ID, projectCfg.Name, err.Error())
-		return nil, nil, err
+	if projectCfg.SecretScan.Enabled {
+		o.scanWorkspaceSecrets(ctx, scan, projectCfg)
+		phases.mark(queue.PhaseSecrets)
 	}
+
+	if stacks == nil {
+		stacks, err = stack.Discover(workspacePath, projectCfg.RootPath, projectCfg.IgnorePaths)
+		if err != nil {
+			o.failEarly(ctx, scan, projectCfg, origin, err.Error())
+			return nil, nil, err
+		}
+	}
+	phases.mark(queue.PhaseDiscover)
 	if len(stacks) == 0 {
-		_ = o.queue.FailScan(ctx, scan.ID, projectCfg.Name, "no stacks discovered")
+		o.failEarly(ctx, scan, projectCfg, origin, "no stacks discovered")
 		return nil, nil, fmt.Errorf("no stacks discovered")
 	}
-	versions, err := version.Detect(workspacePath, stacks)
+	versions, err := version.DetectWithDefaults(workspacePath, stacks, projectCfg.TerraformVersion, projectCfg.TerragruntVersion)
 	if err != nil {
 		_ = o.queue.FailScan(ctx, scan.ID, projectCfg.Name, err.Error())
 		return nil, nil, err
@@ -129,6 +227,7 @@ func (o *ScanOrchestrator) StartScan(ctx context.Context, projectCfg *config.Pro
 		_ = o.queue.FailScan(ctx, scan.ID, projectCfg.Name, fmt.Sprintf("failed to set versions: %v", err))
 		return nil, nil, err
 	}
+	phases.mark(queue.PhaseVersions)
 	return scan, stacks, nil
 }
 
@@ -138,6 +237,13 @@ func (o *ScanOrchestrator) StartAndEnqueue(ctx context.Context, projectCfg *conf
 	if err != nil {
 		return nil, nil, err
 	}
+	if trigger == "scheduled" {
+		stacks = o.skipUntouchedStacks(ctx, scan, projectCfg, stacks)
+		if len(stacks) == 0 {
+			_ = o.queue.CancelScan(ctx, scan.ID, projectCfg.Name, fmt.Sprintf("no stacks changed in the last %d days", projectCfg.SkipUntouchedDays))
+			return scan, &EnqueueStacksResult{}, ErrNoStacksEnqueued
+		}
+	}
 	result, err := o.EnqueueStacks(ctx, scan, projectCfg, stacks, trigger, commit, actor)
 	return scan, result, err
 }
@@ -152,6 +258,16 @@ func (o *ScanOrchestrator) StartAndEnqueueStacks(ctx context.Context, projectCfg
 	return scan, result, err
 }
 
+// scanPriority is the queue priority of scans of projectCfg started by
+// trigger: the configured scan_defaults priority, else the trigger's
+// built-in one.
+func (o *ScanOrchestrator) scanPriority(projectCfg *config.ProjectConfig, trigger string) int {
+	if p := queue.ParsePriority(o.cfg.ScanSettings(projectCfg, trigger).Priority); p != 0 {
+		return p
+	}
+	return queue.TriggerPriority(trigger)
+}
+
 // EnqueueStacksResult holds the outcome of an enqueue operation.
 type EnqueueStacksResult struct {
 	StackIDs []string
@@ -165,10 +281,10 @@ var ErrNoStacksEnqueued = errors.New("no stacks enqueued")
 // scan counters for any skips or failures. Returns ErrNoStacksEnqueued if
 // nothing was successfully enqueued (scan is auto-cancelled in that case).
 func (o *ScanOrchestrator) EnqueueStacks(ctx context.Context, scan *queue.Scan, projectCfg *config.ProjectConfig, stacks []string, trigger, commit, actor string) (*EnqueueStacksResult, error) {
-	maxRetries := 0
-	if o.cfg != nil && o.cfg.Worker.RetryOnce {
-		maxRetries = 1
-	}
+	phases := newPhaseTimer()
+	defer o.recordPhases(ctx, scan.ID, phases)
+	settings := o.cfg.ScanSettings(projectCfg, trigger)
+	priority := o.scanPriority(projectCfg, trigger)
 
 	if err := o.queue.SetScanTotal(ctx, scan.ID, len(stacks)); err != nil {
 		_ = o.queue.FailScan(ctx, scan.ID, projectCfg.Name, fmt.Sprintf("failed to set scan total: %v", err))
@@ -178,15 +294,22 @@ func (o *ScanOrchestrator) EnqueueStacks(ctx context.Context, scan *queue.Scan,
 	// Build StackScan objects
 	batch := make([]*queue.StackScan, len(stacks))
 	for i, stackPath := range stacks {
+		initArgs, planArgs := projectCfg.StackArgs(stackPath)
 		batch[i] = &queue.StackScan{
 			ScanID:      scan.ID,
 			ProjectName: projectCfg.Name,
 			ProjectURL:  projectCfg.URL,
 			StackPath:   stackPath,
-			MaxRetries:  maxRetries,
+			MaxRetries:  settings.Retries,
 			Trigger:     trigger,
 			Commit:      commit,
 			Actor:       actor,
+			InitArgs:    initArgs,
+			PlanArgs:    planArgs,
+			Weight:      projectCfg.StackWeight(stackPath),
+
+			Priority:        priority,
+			ScanConcurrency: settings.Concurrency,
 		}
 	}
 
@@ -216,6 +339,7 @@ func (o *ScanOrchestrator) EnqueueStacks(ctx context.Context, scan *queue.Scan,
 		}
 		_ = o.queue.AdjustScanCounters(ctx, scan.ID, projectCfg.Name, deltas...)
 	}
+	phases.mark(queue.PhaseEnqueue)
 
 	if len(result.StackIDs) == 0 {
 		_ = o.queue.CancelScan(ctx, scan.ID, projectCfg.Name, "all stacks inflight")
@@ -225,7 +349,9 @@ func (o *ScanOrchestrator) EnqueueStacks(ctx context.Context, scan *queue.Scan,
 	return result, nil
 }
 
-func (o *ScanOrchestrator) cloneWorkspace(ctx context.Context, projectCfg *config.ProjectConfig, scanID string, auth transport.AuthMethod) (workspacePath, commitSHA string, err error) {
+// cloneWorkspace syncs the shared mirror and checks out a per-scan workspace at
+// the branch head, or at pinCommit when it is set and present in the mirror.
+func (o *ScanOrchestrator) cloneWorkspace(ctx context.Context, projectCfg *config.ProjectConfig, scanID string, conn *gitauth.Connection, pinCommit string) (workspacePath, commitSHA string, err error) {
 	cloneURL := projectCfg.EffectiveCloneURL()
 	if strings.TrimSpace(cloneURL) == "" {
 		return "", "", fmt.Errorf("project clone URL is empty")
@@ -238,48 +364,24 @@ func (o *ScanOrchestrator) cloneWorkspace(ctx context.Context, projectCfg *confi
 	mirrorPath := filepath.Join(o.cfg.DataDir, "workspaces", "_shared", urlHash, "mirror.git")
 	scanWorkspace := filepath.Join(o.cfg.DataDir, "workspaces", "scans", projectCfg.Name, scanID, "project")
 
-	var releaseCloneLock func() error
-	if o.queue != nil {
-		owner := scanID
-		lockTTL := o.cfg.Worker.LockTTL
-		if lockTTL <= 0 {
-			lockTTL = defaultCloneLockTTL
-		}
-		if err := o.acquireCloneLock(ctx, urlHash, owner, lockTTL); err != nil {
-			return "", "", err
-		}
-
-		lockCtx, cancelLockCtx := context.WithCancel(ctx)
-		ctx = lockCtx
-		stopRenewal := o.startCloneLockRenewal(lockCtx, urlHash, owner, lockTTL, cancelLockCtx)
-		releaseCloneLock = func() error {
-			defer cancelLockCtx()
-			if err := stopRenewal(); err != nil {
-				return err
-			}
-			if err := o.queue.ReleaseCloneLock(context.Background(), urlHash, owner); err != nil {
-				return fmt.Errorf("release clone lock: %w", err)
+	ctx, releaseCloneLock, err := o.holdCloneLock(ctx, urlHash, scanID)
+	if err != nil {
+		return "", "", err
+	}
+	defer func() {
+		if releaseErr := releaseCloneLock(); releaseErr != nil {
+			// Preserve the first meaningful error for caller handling.
+			if err == nil {
+				err = releaseErr
 			}
-			return nil
 		}
-		defer func() {
-			if releaseCloneLock == nil {
-				return
-			}
-			if releaseErr := releaseCloneLock(); releaseErr != nil {
-				// Preserve the first meaningful error for caller handling.
-				if err == nil {
-					err = releaseErr
-				}
-			}
-		}()
-	}
+	}()
 
-	mirrorRepo, err := o.openOrCreateMirror(ctx, mirrorPath, cloneURL, auth)
+	mirrorRepo, err := o.openOrCreateMirror(ctx, mirrorPath, cloneURL, conn)
 	if err != nil {
 		return "", "", err
 	}
-	if err := o.fetchMirror(ctx, mirrorRepo, auth); err != nil {
+	if err := o.fetchMirror(ctx, mirrorRepo, conn, allBranchesRefSpec); err != nil {
 		return "", "", err
 	}
 
@@ -287,6 +389,13 @@ func (o *ScanOrchestrator) cloneWorkspace(ctx context.Context, projectCfg *confi
 	if err != nil {
 		return "", "", err
 	}
+	if pinCommit != "" {
+		if pinned, ok := resolveCommit(mirrorRepo, pinCommit); ok {
+			hash = pinned
+		} else {
+			log.Printf("project %s: commit %s not reachable in mirror, using branch head %s", projectCfg.Name, pinCommit, hash)
+		}
+	}
 
 	if err := o.checkoutScanWorkspace(ctx, mirrorPath, scanWorkspace, hash); err != nil {
 		return "", "", err
@@ -294,6 +403,36 @@ func (o *ScanOrchestrator) cloneWorkspace(ctx context.Context, projectCfg *confi
 	return scanWorkspace, hash.String(), nil
 }
 
+// holdCloneLock acquires the clone lock for urlHash and renews it until
+// release is called. The returned context is canceled if renewal fails.
+// Without a queue no lock is taken.
+func (o *ScanOrchestrator) holdCloneLock(ctx context.Context, urlHash, owner string) (context.Context, func() error, error) {
+	if o.queue == nil {
+		return ctx, func() error { return nil }, nil
+	}
+	lockTTL := o.cfg.Worker.LockTTL
+	if lockTTL <= 0 {
+		lockTTL = defaultCloneLockTTL
+	}
+	if err := o.acquireCloneLock(ctx, urlHash, owner, lockTTL); err != nil {
+		return nil, nil, err
+	}
+
+	lockCtx, cancelLockCtx := context.WithCancel(ctx)
+	stopRenewal := o.startCloneLockRenewal(lockCtx, urlHash, owner, lockTTL, cancelLockCtx)
+	release := func() error {
+		defer cancelLockCtx()
+		if err := stopRenewal(); err != nil {
+			return err
+		}
+		if err := o.queue.ReleaseCloneLock(context.Background(), urlHash, owner); err != nil {
+			return fmt.Errorf("release clone lock: %w", err)
+		}
+		return nil
+	}
+	return lockCtx, release, nil
+}
+
 func (o *ScanOrchestrator) acquireCloneLock(ctx context.Context, urlHash, owner string, ttl time.Duration) error {
 	ticker := time.NewTicker(cloneLockRetryEvery)
 	defer ticker.Stop()
@@ -314,7 +453,7 @@ func (o *ScanOrchestrator) acquireCloneLock(ctx context.Context, urlHash, owner
 	}
 }
 
-func (o *ScanOrchestrator) openOrCreateMirror(ctx context.Context, mirrorPath, cloneURL string, auth transport.AuthMethod) (*git.Repository, error) {
+func (o *ScanOrchestrator) openOrCreateMirror(ctx context.Context, mirrorPath, cloneURL string, conn *gitauth.Connection) (*git.Repository, error) {
 	if err := os.MkdirAll(filepath.Dir(mirrorPath), 0755); err != nil {
 		return nil, err
 	}
@@ -330,22 +469,28 @@ func (o *ScanOrchestrator) openOrCreateMirror(ctx context.Context, mirrorPath, c
 	cloneCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 	return git.PlainCloneContext(cloneCtx, mirrorPath, true, &git.CloneOptions{
-		URL:        cloneURL,
-		Auth:       auth,
-		NoCheckout: true,
+		URL:          cloneURL,
+		Auth:         conn.Auth,
+		CABundle:     conn.CABundle,
+		ProxyOptions: conn.Proxy,
+		NoCheckout:   true,
 	})
 }
 
-func (o *ScanOrchestrator) fetchMirror(ctx context.Context, project *git.Repository, auth transport.AuthMethod) error {
+const allBranchesRefSpec = gitcfg.RefSpec("+refs/heads/*:refs/heads/*")
+
+func (o *ScanOrchestrator) fetchMirror(ctx context.Context, project *git.Repository, conn *gitauth.Connection, refSpec gitcfg.RefSpec) error {
 	fetchCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 	err := project.FetchContext(fetchCtx, &git.FetchOptions{
-		RemoteName: "origin",
-		Auth:       auth,
-		Tags:       git.NoTags,
-		Force:      true,
-		Prune:      true,
-		RefSpecs:   []gitcfg.RefSpec{"+refs/heads/*:refs/heads/*"},
+		RemoteName:   "origin",
+		Auth:         conn.Auth,
+		CABundle:     conn.CABundle,
+		ProxyOptions: conn.Proxy,
+		Tags:         git.NoTags,
+		Force:        true,
+		Prune:        true,
+		RefSpecs:     []gitcfg.RefSpec{refSpec},
 	})
 	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
 		return err
@@ -476,6 +621,41 @@ func resolveTargetRef(project *git.Repository, branch string) (plumbing.Hash, er
 	return head.Hash(), nil
 }
 
+// readCommitInfo reads the author, summary line and time of a commit in a
+// scan workspace.
+func readCommitInfo(workspacePath, sha string) (*storage.CommitInfo, error) {
+	project, err := git.PlainOpen(workspacePath)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := project.CommitObject(plumbing.NewHash(sha))
+	if err != nil {
+		return nil, err
+	}
+	summary, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+	return &storage.CommitInfo{
+		Author:  commit.Author.Name,
+		Summary: strings.TrimSpace(summary),
+		Time:    commit.Author.When.UTC(),
+	}, nil
+}
+
+// resolveCommit looks up a full or abbreviated commit SHA in the mirror.
+func resolveCommit(project *git.Repository, sha string) (plumbing.Hash, bool) {
+	sha = strings.TrimSpace(sha)
+	if sha == "" {
+		return plumbing.ZeroHash, false
+	}
+	hash, err := project.ResolveRevision(plumbing.Revision(sha))
+	if err != nil || hash == nil {
+		return plumbing.ZeroHash, false
+	}
+	if _, err := project.CommitObject(*hash); err != nil {
+		return plumbing.ZeroHash, false
+	}
+	return *hash, true
+}
+
 func hashCloneURL(cloneURL string) string {
 	identity := strings.TrimSpace(cloneURL)
 	if canonical, ok := projects.CanonicalURL(identity); ok {
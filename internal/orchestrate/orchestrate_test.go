@@ -5,11 +5,13 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/gitauth"
 	"github.com/driftdhq/driftd/internal/queue"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing/object"
@@ -77,6 +79,52 @@ func TestStartScanCreatesScanAndStacks(t *testing.T) {
 	if state.WorkspacePath != expectedWorkspace {
 		t.Fatalf("expected workspace path %s, got %s", expectedWorkspace, state.WorkspacePath)
 	}
+	info := state.CommitInfo
+	if info == nil || info.Author != "tester" || info.Summary != "init" || info.Time.IsZero() {
+		t.Fatalf("expected commit info for %s, got %+v", state.CommitSHA, info)
+	}
+}
+
+func TestEnqueueStacksResolvesTerraformArgs(t *testing.T) {
+	projectDir := t.TempDir()
+	initGitRepo(t, projectDir)
+
+	q := queue.NewMemory(time.Minute)
+	defer q.Close()
+
+	cfg := &config.Config{
+		DataDir: t.TempDir(),
+		Worker: config.WorkerConfig{
+			LockTTL:    time.Minute,
+			ScanMaxAge: time.Hour,
+			RenewEvery: time.Minute,
+		},
+	}
+	orch := New(cfg, q)
+	defer orch.Stop()
+
+	projectCfg := &config.ProjectConfig{
+		Name: "project",
+		URL:  "file://" + projectDir,
+		Terraform: config.TerraformArgsConfig{
+			InitArgs: []string{"-backend-config=backend.hcl"},
+			Stacks: []config.StackArgsOverride{
+				{Pattern: "**", PlanArgs: []string{"-lock=false"}},
+				{Pattern: "envs/**", PlanArgs: []string{"-parallelism=2"}},
+			},
+		},
+	}
+	if _, _, err := orch.StartAndEnqueue(context.Background(), projectCfg, "manual", "", ""); err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+
+	job, err := q.Dequeue(context.Background(), "worker-1")
+	if err != nil {
+		t.Fatalf("dequeue: %v", err)
+	}
+	if strings.Join(job.InitArgs, " ") != "-backend-config=backend.hcl" || strings.Join(job.PlanArgs, " ") != "-lock=false" {
+		t.Fatalf("unexpected args on stack scan: init=%v plan=%v", job.InitArgs, job.PlanArgs)
+	}
 }
 
 func TestCloneWorkspaceFetchesUpdates(t *testing.T) {
@@ -99,7 +147,7 @@ func TestCloneWorkspaceFetchesUpdates(t *testing.T) {
 		URL:  "file://" + projectDir,
 	}
 
-	workspace, commit1, err := orch.cloneWorkspace(context.Background(), projectCfg, "scan-a", nil)
+	workspace, commit1, err := orch.cloneWorkspace(context.Background(), projectCfg, "scan-a", &gitauth.Connection{}, "")
 	if err != nil {
 		t.Fatalf("clone workspace: %v", err)
 	}
@@ -124,7 +172,7 @@ func TestCloneWorkspaceFetchesUpdates(t *testing.T) {
 		t.Fatalf("commit: %v", err)
 	}
 
-	workspace2, commit2, err := orch.cloneWorkspace(context.Background(), projectCfg, "scan-b", nil)
+	workspace2, commit2, err := orch.cloneWorkspace(context.Background(), projectCfg, "scan-b", &gitauth.Connection{}, "")
 	if err != nil {
 		t.Fatalf("clone workspace (update): %v", err)
 	}
@@ -142,6 +190,54 @@ func TestCloneWorkspaceFetchesUpdates(t *testing.T) {
 	}
 }
 
+func TestCloneWorkspacePinsTriggerCommit(t *testing.T) {
+	projectDir := t.TempDir()
+	project := initGitRepo(t, projectDir)
+	head, err := project.Head()
+	if err != nil {
+		t.Fatalf("head: %v", err)
+	}
+	firstCommit := head.Hash().String()
+
+	if err := os.WriteFile(filepath.Join(projectDir, "second.tf"), []byte(`resource "null_resource" "second" {}`), 0644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	wt, err := project.Worktree()
+	if err != nil {
+		t.Fatalf("worktree: %v", err)
+	}
+	if _, err := wt.Add("second.tf"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if _, err := wt.Commit("second", &git.CommitOptions{
+		Author: &object.Signature{Name: "tester", Email: "tester@example.com", When: time.Now()},
+	}); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	orch := New(&config.Config{DataDir: t.TempDir()}, nil)
+	projectCfg := &config.ProjectConfig{Name: "project", URL: "file://" + projectDir}
+
+	workspace, commit, err := orch.cloneWorkspace(context.Background(), projectCfg, "scan-pinned", &gitauth.Connection{}, firstCommit[:10])
+	if err != nil {
+		t.Fatalf("clone workspace: %v", err)
+	}
+	if commit != firstCommit {
+		t.Fatalf("expected workspace pinned to %s, got %s", firstCommit, commit)
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "second.tf")); err == nil {
+		t.Fatalf("expected pinned workspace without later changes")
+	}
+
+	_, commit, err = orch.cloneWorkspace(context.Background(), projectCfg, "scan-unknown", &gitauth.Connection{}, strings.Repeat("f", 40))
+	if err != nil {
+		t.Fatalf("clone workspace: %v", err)
+	}
+	if commit == firstCommit || !queue.CommitSkewed(strings.Repeat("f", 40), commit) {
+		t.Fatalf("expected fallback to branch head for unknown commit, got %s", commit)
+	}
+}
+
 func initGitRepo(t *testing.T, dir string) *git.Repository {
 	t.Helper()
 
@@ -0,0 +1,35 @@
+package orchestrate
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+// phaseTimer times consecutive scan setup phases: each phase starts where
+// the previous one ended.
+type phaseTimer struct {
+	last   time.Time
+	phases []queue.ScanPhase
+}
+
+func newPhaseTimer() *phaseTimer {
+	return &phaseTimer{last: time.Now()}
+}
+
+// mark ends the current phase under name and starts the next one.
+func (p *phaseTimer) mark(name string) {
+	now := time.Now()
+	p.phases = append(p.phases, queue.ScanPhase{Name: name, StartedAt: p.last, EndedAt: now})
+	p.last = now
+}
+
+// recordPhases stores the phases completed so far. Timings are diagnostic,
+// so failures are only logged.
+func (o *ScanOrchestrator) recordPhases(ctx context.Context, scanID string, p *phaseTimer) {
+	if err := o.queue.RecordScanPhases(context.WithoutCancel(ctx), scanID, p.phases...); err != nil {
+		log.Printf("scan %s: record phase timings: %v", scanID, err)
+	}
+}
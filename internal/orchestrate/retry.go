@@ -0,0 +1,94 @@
+package orchestrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+// scanOrigin records how a scan came about.
+type scanOrigin struct {
+	// lineage lists the projects whose chains led to the scan, oldest first.
+	lineage []string
+	// retryOf is the failed scan this scan automatically retries.
+	retryOf string
+}
+
+// failEarly fails a scan that stopped before any stack was planned and
+// schedules its automatic retry when scan_retry is enabled.
+func (o *ScanOrchestrator) failEarly(ctx context.Context, scan *queue.Scan, projectCfg *config.ProjectConfig, origin scanOrigin, errMsg string) {
+	_ = o.queue.FailScan(ctx, scan.ID, projectCfg.Name, errMsg)
+	o.scheduleRetry(scan, projectCfg, origin)
+}
+
+// scheduleRetry starts one full scan of the project after scan_retry.after.
+// Retries are not retried again, and each project gets at most
+// scan_retry.max_per_day of them per UTC day. A pending retry is dropped
+// when the process stops.
+func (o *ScanOrchestrator) scheduleRetry(failed *queue.Scan, projectCfg *config.ProjectConfig, origin scanOrigin) {
+	if o.cfg == nil || !o.cfg.ScanRetry.Enabled || origin.retryOf != "" || o.ctx.Err() != nil {
+		return
+	}
+	if projectCfg.Name == config.CanaryProjectName {
+		return
+	}
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	key := fmt.Sprintf("retry:%s:%d", projectCfg.Name, day.Unix())
+	ok, err := o.queue.AcquireScanStart(o.ctx, key, o.cfg.ScanRetry.MaxPerDay, 25*time.Hour)
+	if err != nil {
+		log.Printf("scan %s: schedule retry: %v", failed.ID, err)
+		return
+	}
+	if !ok {
+		log.Printf("scan %s: not retrying, project %s reached %d retries today", failed.ID, projectCfg.Name, o.cfg.ScanRetry.MaxPerDay)
+		return
+	}
+
+	after := o.cfg.ScanRetry.After
+	log.Printf("scan %s failed before planning, retrying in %s", failed.ID, after)
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		timer := time.NewTimer(after)
+		defer timer.Stop()
+		select {
+		case <-o.ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if err := o.retryScan(failed, projectCfg); err != nil {
+			log.Printf("scan %s: retry not started: %v", failed.ID, err)
+		}
+	}()
+}
+
+func (o *ScanOrchestrator) retryScan(failed *queue.Scan, projectCfg *config.ProjectConfig) error {
+	ctx := o.ctx
+	if current, err := o.lookupProject(projectCfg.Name); err == nil {
+		projectCfg = current
+	}
+	// Any scan started since the failure, finished or not, makes the
+	// retry unnecessary.
+	recent, err := o.queue.ListProjectScans(ctx, projectCfg.Name, 1)
+	if err != nil {
+		return err
+	}
+	if len(recent) > 0 && recent[0].ID != failed.ID {
+		return fmt.Errorf("scan %s started since", recent[0].ID)
+	}
+
+	scan, stacks, err := o.startScan(ctx, projectCfg, failed.Trigger, failed.Commit, failed.Actor, nil, scanOrigin{retryOf: failed.ID})
+	if err != nil {
+		return err
+	}
+	log.Printf("scan %s retries failed scan %s", scan.ID, failed.ID)
+	if _, err := o.EnqueueStacks(ctx, scan, projectCfg, stacks, failed.Trigger, failed.Commit, failed.Actor); err != nil && !errors.Is(err, ErrNoStacksEnqueued) {
+		return err
+	}
+	return nil
+}
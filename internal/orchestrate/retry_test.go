@@ -0,0 +1,88 @@
+package orchestrate
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+// latestScan returns the project's most recently started scan, or nil.
+func latestScan(t *testing.T, q queue.Backend, project string) *queue.Scan {
+	t.Helper()
+	scans, err := q.ListProjectScans(context.Background(), project, 1)
+	if err != nil {
+		t.Fatalf("list scans: %v", err)
+	}
+	if len(scans) == 0 {
+		return nil
+	}
+	return scans[0]
+}
+
+func waitForLastScan(t *testing.T, q queue.Backend, project string, done func(*queue.Scan) bool) *queue.Scan {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if scan := latestScan(t, q, project); scan != nil && done(scan) {
+			return scan
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for scan")
+	return nil
+}
+
+func TestScanRetryAfterCloneFailure(t *testing.T) {
+	q := queue.NewMemory(time.Minute)
+	defer q.Close()
+
+	cfg := &config.Config{
+		DataDir: t.TempDir(),
+		Worker: config.WorkerConfig{
+			LockTTL:    time.Minute,
+			ScanMaxAge: time.Hour,
+			RenewEvery: time.Minute,
+		},
+		ScanRetry: config.ScanRetryConfig{Enabled: true, After: 50 * time.Millisecond, MaxPerDay: 1},
+	}
+	orch := New(cfg, q)
+	defer orch.Stop()
+
+	projectCfg := &config.ProjectConfig{
+		Name: "project",
+		URL:  "file://" + filepath.Join(t.TempDir(), "missing"),
+	}
+	if _, _, err := orch.StartScan(context.Background(), projectCfg, "scheduled", "", ""); err == nil {
+		t.Fatal("expected clone failure")
+	}
+	first := waitForLastScan(t, q, "project", func(s *queue.Scan) bool { return s.Status == queue.ScanStatusFailed })
+
+	retry := waitForLastScan(t, q, "project", func(s *queue.Scan) bool {
+		return s.ID != first.ID && s.Status == queue.ScanStatusFailed
+	})
+	if retry.RetryOf != first.ID || retry.Trigger != "scheduled" {
+		t.Fatalf("unexpected retry scan: %+v", retry)
+	}
+	first, err := q.GetScan(context.Background(), first.ID)
+	if err != nil {
+		t.Fatalf("get scan: %v", err)
+	}
+	if first.RetryScanID != retry.ID {
+		t.Fatalf("original scan not linked to retry: %q", first.RetryScanID)
+	}
+
+	// The failed retry is not retried again, and the daily cap of one stops
+	// a retry of the next failure.
+	if _, _, err := orch.StartScan(context.Background(), projectCfg, "manual", "", ""); err == nil {
+		t.Fatal("expected clone failure")
+	}
+	third := waitForLastScan(t, q, "project", func(s *queue.Scan) bool { return s.ID != retry.ID })
+	time.Sleep(300 * time.Millisecond)
+	if last := latestScan(t, q, "project"); last.ID != third.ID || last.RetryScanID != "" {
+		t.Fatalf("expected no further retries, last scan %+v", last)
+	}
+}
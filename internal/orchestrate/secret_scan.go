@@ -0,0 +1,43 @@
+package orchestrate
+
+import (
+	"context"
+	"log"
+	"regexp"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/stack"
+)
+
+// scanWorkspaceSecrets records committed secrets found in the scan's
+// workspace as scan warnings. It never fails the scan; errors are logged.
+func (o *ScanOrchestrator) scanWorkspaceSecrets(ctx context.Context, scan *queue.Scan, projectCfg *config.ProjectConfig) {
+	extra := make([]*regexp.Regexp, 0, len(projectCfg.SecretScan.Patterns))
+	for _, pattern := range projectCfg.SecretScan.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("scan %s: skip secret_scan pattern %q: %v", scan.ID, pattern, err)
+			continue
+		}
+		extra = append(extra, re)
+	}
+	findings, err := stack.FindSecrets(scan.WorkspacePath, projectCfg.RootPath, extra)
+	if err != nil {
+		log.Printf("scan %s: secret scan: %v", scan.ID, err)
+		return
+	}
+	if len(findings) == 0 {
+		return
+	}
+	warnings := make([]queue.ScanWarning, 0, len(findings))
+	for _, f := range findings {
+		warnings = append(warnings, queue.ScanWarning{Kind: queue.ScanWarningSecret, Path: f.Path, Line: f.Line, Rule: f.Rule})
+	}
+	if err := o.queue.SetScanWarnings(ctx, scan.ID, warnings); err != nil {
+		log.Printf("scan %s: record scan warnings: %v", scan.ID, err)
+		return
+	}
+	scan.Warnings = warnings
+	log.Printf("scan %s: %d possible committed secrets in %s", scan.ID, len(warnings), projectCfg.Name)
+}
@@ -0,0 +1,44 @@
+package orchestrate
+
+import (
+	"context"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/go-git/go-git/v5"
+)
+
+func TestScanRecordsCommittedSecrets(t *testing.T) {
+	projectDir := t.TempDir()
+	project, err := git.PlainInit(projectDir, false)
+	if err != nil {
+		t.Fatalf("init project: %v", err)
+	}
+	commitFiles(t, project, projectDir, map[string]string{
+		"envs/prod/main.tf":          "variable \"db_password\" {}\n",
+		"envs/prod/terraform.tfvars": "db_password = \"hunter22\"\n",
+	})
+
+	orch, q := newSparseOrchestrator(t, 0)
+	projectCfg := &config.ProjectConfig{
+		Name:       "project",
+		URL:        "file://" + projectDir,
+		SecretScan: config.SecretScanConfig{Enabled: true},
+	}
+	scan, result, err := orch.StartAndEnqueue(context.Background(), projectCfg, "manual", "", "")
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	if len(result.StackIDs) != 1 {
+		t.Fatalf("expected the stack to be planned despite the warning, got %v", result.StackIDs)
+	}
+	state, err := q.GetScan(context.Background(), scan.ID)
+	if err != nil {
+		t.Fatalf("get scan: %v", err)
+	}
+	want := queue.ScanWarning{Kind: queue.ScanWarningSecret, Path: "envs/prod/terraform.tfvars", Line: 1, Rule: "tfvars_secret"}
+	if len(state.Warnings) != 1 || state.Warnings[0] != want {
+		t.Fatalf("expected %+v, got %+v", want, state.Warnings)
+	}
+}
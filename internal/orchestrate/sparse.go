@@ -0,0 +1,431 @@
+package orchestrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/gitauth"
+	"github.com/driftdhq/driftd/internal/stack"
+	"github.com/go-git/go-git/v5"
+	gitcfg "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// errSparseNotApplicable means the push cannot be served by an incremental
+// workspace and the scan should use a full checkout.
+var errSparseNotApplicable = errors.New("incremental workspace not applicable")
+
+var (
+	// localRefPattern matches attributes that can point at other directories
+	// of the repository: module and terragrunt sources, dependency and
+	// include paths.
+	localRefPattern = regexp.MustCompile(`(?m)^\s*(?:source|config_path|path)\s*=\s*"([^"]+)"`)
+	// localFuncPattern matches functions that read other repository files.
+	localFuncPattern = regexp.MustCompile(`\b(?:read_terragrunt_config|file|templatefile)\(\s*"([^"]+)"`)
+	// dirPrefixes are interpolations that resolve to the directory of the
+	// file being read.
+	dirPrefixes = []string{"${get_terragrunt_dir()}/", "${path.module}/", "./"}
+)
+
+// SelectStacksForChanges returns the stacks that contain at least one of
+// changedFiles. A root stack ("") matches every file.
+func SelectStacksForChanges(stacks []string, changedFiles []string) []string {
+	if len(changedFiles) == 0 {
+		return nil
+	}
+	stackSet := map[string]struct{}{}
+	for _, stack := range stacks {
+		stackSet[stack] = struct{}{}
+	}
+	selected := map[string]struct{}{}
+	for _, file := range changedFiles {
+		for stack := range stackSet {
+			if stack != "" && !strings.HasPrefix(file, stack+"/") {
+				continue
+			}
+			selected[stack] = struct{}{}
+		}
+	}
+	var result []string
+	for stack := range selected {
+		result = append(result, stack)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// addModuleDependents adds to selected the stacks that call a local module
+// containing one of changedFiles.
+func addModuleDependents(selected []string, index stack.ModuleIndex, changedFiles []string) []string {
+	if len(index) == 0 {
+		return selected
+	}
+	set := make(map[string]struct{}, len(selected))
+	for _, s := range selected {
+		set[s] = struct{}{}
+	}
+	for _, file := range changedFiles {
+		for _, s := range index.Dependents(file) {
+			if _, ok := set[s]; !ok {
+				set[s] = struct{}{}
+				selected = append(selected, s)
+			}
+		}
+	}
+	sort.Strings(selected)
+	return selected
+}
+
+// changesOutsideStacks reports whether a changed Terraform or terragrunt file
+// lies outside every stack, such as a shared module. Its dependents are only
+// known from a full checkout.
+func changesOutsideStacks(stacks, changedFiles []string) bool {
+	for _, file := range changedFiles {
+		if !isConfigFile(file) {
+			continue
+		}
+		inside := false
+		for _, s := range stacks {
+			if s == "" || strings.HasPrefix(file, s+"/") {
+				inside = true
+				break
+			}
+		}
+		if !inside {
+			return true
+		}
+	}
+	return false
+}
+
+// sparseWorkspace fetches only the project branch into the existing mirror
+// and checks out the stacks affected by changedFiles plus the files they
+// depend on. It returns the affected stacks, or errSparseNotApplicable when
+// the mirror does not exist yet, the push touches too many stacks or
+// Terraform files outside every stack, or a root-level stack is affected.
+func (o *ScanOrchestrator) sparseWorkspace(ctx context.Context, projectCfg *config.ProjectConfig, scanID string, conn *gitauth.Connection, pinCommit string, changedFiles []string) (workspacePath, commitSHA string, stacks []string, err error) {
+	cloneURL := projectCfg.EffectiveCloneURL()
+	if strings.TrimSpace(cloneURL) == "" {
+		return "", "", nil, fmt.Errorf("project clone URL is empty")
+	}
+
+	urlHash := hashCloneURL(cloneURL)
+	mirrorPath := filepath.Join(o.cfg.DataDir, "workspaces", "_shared", urlHash, "mirror.git")
+	scanWorkspace := filepath.Join(o.cfg.DataDir, "workspaces", "scans", projectCfg.Name, scanID, "project")
+
+	if _, statErr := os.Stat(mirrorPath); statErr != nil {
+		return "", "", nil, errSparseNotApplicable
+	}
+
+	ctx, releaseCloneLock, err := o.holdCloneLock(ctx, urlHash, scanID)
+	if err != nil {
+		return "", "", nil, err
+	}
+	defer func() {
+		if releaseErr := releaseCloneLock(); releaseErr != nil && err == nil {
+			err = releaseErr
+		}
+	}()
+
+	mirrorRepo, err := git.PlainOpen(mirrorPath)
+	if err != nil {
+		return "", "", nil, errSparseNotApplicable
+	}
+	refSpec := allBranchesRefSpec
+	if projectCfg.Branch != "" {
+		ref := plumbing.NewBranchReferenceName(projectCfg.Branch)
+		refSpec = gitcfg.RefSpec(fmt.Sprintf("+%s:%s", ref, ref))
+	}
+	if err := o.fetchMirror(ctx, mirrorRepo, conn, refSpec); err != nil {
+		return "", "", nil, err
+	}
+
+	hash, err := resolveTargetRef(mirrorRepo, projectCfg.Branch)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if pinCommit != "" {
+		if pinned, ok := resolveCommit(mirrorRepo, pinCommit); ok {
+			hash = pinned
+		} else {
+			log.Printf("project %s: commit %s not reachable in mirror, using branch head %s", projectCfg.Name, pinCommit, hash)
+		}
+	}
+
+	commit, err := mirrorRepo.CommitObject(hash)
+	if err != nil {
+		return "", "", nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", "", nil, err
+	}
+	repo, err := readRepoTree(tree)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	all, err := stack.DiscoverFiles(repo.files, projectCfg.RootPath, projectCfg.IgnorePaths)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if changesOutsideStacks(all, changedFiles) {
+		return "", "", nil, errSparseNotApplicable
+	}
+	stacks = SelectStacksForChanges(all, changedFiles)
+	if len(stacks) == 0 || len(stacks) > o.cfg.Workspace.IncrementalStackLimit() {
+		return "", "", nil, errSparseNotApplicable
+	}
+	for _, s := range stacks {
+		if s == "" {
+			return "", "", nil, errSparseNotApplicable
+		}
+	}
+
+	paths := repo.sparsePaths(stacks)
+	if err := checkoutSparseWorkspace(ctx, mirrorPath, scanWorkspace, hash, paths); err != nil {
+		return "", "", nil, err
+	}
+	log.Printf("scan %s: incremental workspace with %d stack(s) and %d path(s) at %s", scanID, len(stacks), len(paths), hash)
+	return scanWorkspace, hash.String(), stacks, nil
+}
+
+// checkoutSparseWorkspace creates a workspace that borrows objects from the
+// mirror and only materializes paths. Entries ending in "/" are directories.
+func checkoutSparseWorkspace(ctx context.Context, mirrorPath, scanWorkspace string, hash plumbing.Hash, paths []string) error {
+	if err := os.MkdirAll(filepath.Dir(scanWorkspace), 0755); err != nil {
+		return err
+	}
+	_ = os.RemoveAll(scanWorkspace)
+
+	cloneCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	project, err := git.PlainCloneContext(cloneCtx, scanWorkspace, false, &git.CloneOptions{
+		URL:        mirrorPath,
+		NoCheckout: true,
+		Shared:     true,
+	})
+	if err != nil {
+		return err
+	}
+
+	wt, err := project.Worktree()
+	if err != nil {
+		return err
+	}
+	return wt.Checkout(&git.CheckoutOptions{
+		Hash:                      hash,
+		Force:                     true,
+		SparseCheckoutDirectories: paths,
+	})
+}
+
+// repoTree indexes the file list of a commit without reading blobs.
+type repoTree struct {
+	tree  *object.Tree
+	files []string
+	set   map[string]struct{}
+	dirs  map[string][]string // directory ("" for the root) -> direct files
+}
+
+func readRepoTree(tree *object.Tree) (*repoTree, error) {
+	r := &repoTree{tree: tree, set: map[string]struct{}{}, dirs: map[string][]string{}}
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry.Mode == filemode.Dir || entry.Mode == filemode.Submodule {
+			continue
+		}
+		r.files = append(r.files, name)
+		r.set[name] = struct{}{}
+		dir := cleanDir(path.Dir(name))
+		r.dirs[dir] = append(r.dirs[dir], name)
+	}
+	return r, nil
+}
+
+func (r *repoTree) isDir(dir string) bool {
+	if _, ok := r.dirs[dir]; ok {
+		return true
+	}
+	prefix := dir + "/"
+	for d := range r.dirs {
+		if strings.HasPrefix(d, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// sparsePaths returns the checkout paths for stacks: each stack directory,
+// every local directory or file they reference (transitively), and the files
+// directly inside every ancestor directory so parent terragrunt configs and
+// version files are present.
+func (r *repoTree) sparsePaths(stacks []string) []string {
+	dirs := map[string]struct{}{}
+	files := map[string]struct{}{}
+	var pending []string
+	addDir := func(dir string) {
+		if _, ok := dirs[dir]; !ok {
+			dirs[dir] = struct{}{}
+			pending = append(pending, dir+"/")
+		}
+	}
+	addFile := func(file string) {
+		if _, ok := files[file]; !ok {
+			files[file] = struct{}{}
+			pending = append(pending, file)
+		}
+	}
+	for _, s := range stacks {
+		addDir(s)
+	}
+
+	for len(pending) > 0 {
+		next := pending[0]
+		pending = pending[1:]
+
+		configs := []string{next}
+		if dir, ok := strings.CutSuffix(next, "/"); ok {
+			configs = nil
+			for d, list := range r.dirs {
+				if d == dir || strings.HasPrefix(d, next) {
+					configs = append(configs, list...)
+				}
+			}
+		}
+		for _, file := range configs {
+			if !isConfigFile(file) {
+				continue
+			}
+			for _, ref := range r.localRefs(file) {
+				if r.isDir(ref) {
+					addDir(ref)
+				} else if r.hasFile(ref) {
+					addFile(ref)
+				}
+			}
+		}
+	}
+
+	seen := map[string]struct{}{}
+	var out []string
+	// add records p and the files directly inside the ancestors of dir.
+	add := func(p, dir string) {
+		if _, ok := seen[p]; !ok {
+			seen[p] = struct{}{}
+			out = append(out, p)
+		}
+		for _, ancestor := range ancestors(dir) {
+			for _, file := range r.dirs[ancestor] {
+				if _, ok := seen[file]; !ok {
+					seen[file] = struct{}{}
+					out = append(out, file)
+				}
+			}
+		}
+	}
+	for dir := range dirs {
+		add(dir+"/", dir)
+	}
+	for file := range files {
+		add(file, cleanDir(path.Dir(file)))
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (r *repoTree) hasFile(name string) bool {
+	_, ok := r.set[name]
+	return ok
+}
+
+// localRefs returns the repository paths referenced by relative paths in
+// file. References leaving the repository are dropped.
+func (r *repoTree) localRefs(file string) []string {
+	f, err := r.tree.File(file)
+	if err != nil {
+		return nil
+	}
+	content, err := f.Contents()
+	if err != nil {
+		return nil
+	}
+	dir := cleanDir(path.Dir(file))
+
+	var refs []string
+	for _, pattern := range []*regexp.Regexp{localRefPattern, localFuncPattern} {
+		for _, m := range pattern.FindAllStringSubmatch(content, -1) {
+			if ref, ok := resolveLocalRef(dir, m[1]); ok {
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
+
+// resolveLocalRef resolves a relative reference found in a file in dir.
+// Terragrunt's "//" subdirectory separator is treated as a path separator.
+func resolveLocalRef(dir, ref string) (string, bool) {
+	relative := strings.HasPrefix(ref, "../")
+	for _, prefix := range dirPrefixes {
+		if strings.HasPrefix(ref, prefix) {
+			ref = strings.TrimPrefix(ref, prefix)
+			relative = true
+			break
+		}
+	}
+	if !relative || strings.Contains(ref, "${") {
+		return "", false
+	}
+	ref = strings.ReplaceAll(ref, "//", "/")
+	resolved := path.Clean(path.Join(dir, ref))
+	if resolved == "." || resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", false
+	}
+	return resolved, true
+}
+
+func isConfigFile(name string) bool {
+	return strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".hcl") || strings.HasSuffix(name, ".tf.json")
+}
+
+// ancestors returns dir's parent directories up to and including the root.
+func ancestors(dir string) []string {
+	out := []string{""}
+	if dir == "" {
+		return out
+	}
+	parts := strings.Split(dir, "/")
+	for i := 1; i < len(parts); i++ {
+		out = append(out, strings.Join(parts[:i], "/"))
+	}
+	return out
+}
+
+func cleanDir(dir string) string {
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
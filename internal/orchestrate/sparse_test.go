@@ -0,0 +1,242 @@
+package orchestrate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/gitauth"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestSelectStacksForChanges(t *testing.T) {
+	stacks := []string{"envs/prod", "envs/dev"}
+	changes := []string{"envs/prod/main.tf"}
+	selected := SelectStacksForChanges(stacks, changes)
+	if len(selected) != 1 || selected[0] != "envs/prod" {
+		t.Fatalf("unexpected selection: %#v", selected)
+	}
+}
+
+func TestSelectStacksForChangesIncludesRoot(t *testing.T) {
+	stacks := []string{"", "envs/prod"}
+	changes := []string{"envs/prod/main.tf"}
+	selected := SelectStacksForChanges(stacks, changes)
+	if len(selected) != 2 {
+		t.Fatalf("expected root + envs/prod, got %#v", selected)
+	}
+	if selected[0] != "" || selected[1] != "envs/prod" {
+		t.Fatalf("unexpected selection order/content: %#v", selected)
+	}
+}
+
+func TestResolveLocalRef(t *testing.T) {
+	cases := []struct {
+		dir, ref, want string
+		ok             bool
+	}{
+		{"envs/dev", "../../modules/vpc", "modules/vpc", true},
+		{"envs/dev", "../../modules//vpc", "modules/vpc", true},
+		{"envs/dev", "${get_terragrunt_dir()}/../common.hcl", "envs/common.hcl", true},
+		{"envs/dev", "./files/policy.json", "envs/dev/files/policy.json", true},
+		{"envs/dev", "../../../outside", "", false},
+		{"envs/dev", "git::https://example.com/modules.git", "", false},
+		{"envs/dev", "${local.root}/modules", "", false},
+	}
+	for _, tc := range cases {
+		got, ok := resolveLocalRef(tc.dir, tc.ref)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("resolveLocalRef(%q, %q) = %q, %v; want %q, %v", tc.dir, tc.ref, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestStartScanForChangesUsesSparseWorkspace(t *testing.T) {
+	projectDir := t.TempDir()
+	project := initSparseRepo(t, projectDir)
+	orch, q := newSparseOrchestrator(t, 2)
+	projectCfg := &config.ProjectConfig{
+		Name:        "project",
+		URL:         "file://" + projectDir,
+		IgnorePaths: []string{"modules/**"},
+	}
+
+	// The first scan of a project creates the shared mirror.
+	if _, _, err := orch.cloneWorkspace(context.Background(), projectCfg, "seed", &gitauth.Connection{}, ""); err != nil {
+		t.Fatalf("seed mirror: %v", err)
+	}
+	head := commitFiles(t, project, projectDir, map[string]string{
+		"envs/dev/main.tf": "module \"vpc\" {\n  source = \"../../modules/vpc\"\n}\n# changed\n",
+	})
+
+	scan, stacks, err := orch.StartScanForChanges(context.Background(), projectCfg, []string{"envs/dev/main.tf"}, "webhook", head, "")
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	if !reflect.DeepEqual(stacks, []string{"envs/dev"}) {
+		t.Fatalf("expected envs/dev, got %v", stacks)
+	}
+	state, err := q.GetScan(context.Background(), scan.ID)
+	if err != nil {
+		t.Fatalf("get scan: %v", err)
+	}
+	if state.CommitSHA != head || state.TerraformVersion != "1.7.5" {
+		t.Fatalf("expected commit %s and tf 1.7.5, got %s %q", head, state.CommitSHA, state.TerraformVersion)
+	}
+
+	for _, present := range []string{"envs/dev/main.tf", "modules/vpc/main.tf", "modules/shared/main.tf", ".terraform-version", "envs/env.hcl"} {
+		if _, err := os.Stat(filepath.Join(state.WorkspacePath, present)); err != nil {
+			t.Errorf("expected %s in sparse workspace: %v", present, err)
+		}
+	}
+	for _, absent := range []string{"envs/prod/main.tf", "modules/unused/main.tf"} {
+		if _, err := os.Stat(filepath.Join(state.WorkspacePath, absent)); err == nil {
+			t.Errorf("expected %s to be left out of the sparse workspace", absent)
+		}
+	}
+}
+
+func TestStartScanForChangesFallsBackToFullCheckout(t *testing.T) {
+	projectDir := t.TempDir()
+	initSparseRepo(t, projectDir)
+	orch, q := newSparseOrchestrator(t, 1)
+	projectCfg := &config.ProjectConfig{
+		Name:        "project",
+		URL:         "file://" + projectDir,
+		IgnorePaths: []string{"modules/**"},
+	}
+
+	// No mirror yet, and more stacks changed than the limit allows.
+	changed := []string{"envs/dev/main.tf", "envs/prod/main.tf"}
+	scan, stacks, err := orch.StartScanForChanges(context.Background(), projectCfg, changed, "webhook", "", "")
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	if !reflect.DeepEqual(stacks, []string{"envs/dev", "envs/prod"}) {
+		t.Fatalf("expected both stacks, got %v", stacks)
+	}
+	state, err := q.GetScan(context.Background(), scan.ID)
+	if err != nil {
+		t.Fatalf("get scan: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(state.WorkspacePath, "modules/unused/main.tf")); err != nil {
+		t.Fatalf("expected a full checkout: %v", err)
+	}
+}
+
+func TestStartScanForChangesFansOutModuleChanges(t *testing.T) {
+	projectDir := t.TempDir()
+	project := initSparseRepo(t, projectDir)
+	orch, q := newSparseOrchestrator(t, 2)
+	projectCfg := &config.ProjectConfig{
+		Name:        "project",
+		URL:         "file://" + projectDir,
+		IgnorePaths: []string{"modules/**"},
+	}
+	if _, _, err := orch.cloneWorkspace(context.Background(), projectCfg, "seed", &gitauth.Connection{}, ""); err != nil {
+		t.Fatalf("seed mirror: %v", err)
+	}
+	head := commitFiles(t, project, projectDir, map[string]string{
+		"modules/shared/main.tf": "resource \"null_resource\" \"shared\" {}\n# changed\n",
+	})
+
+	// modules/shared is called from envs/dev through modules/vpc.
+	scan, stacks, err := orch.StartScanForChanges(context.Background(), projectCfg, []string{"modules/shared/main.tf"}, "webhook", head, "")
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	if !reflect.DeepEqual(stacks, []string{"envs/dev"}) {
+		t.Fatalf("expected envs/dev, got %v", stacks)
+	}
+	state, err := q.GetScan(context.Background(), scan.ID)
+	if err != nil {
+		t.Fatalf("get scan: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(state.WorkspacePath, "envs/prod/main.tf")); err != nil {
+		t.Fatalf("expected a full checkout for a module change: %v", err)
+	}
+}
+
+func newSparseOrchestrator(t *testing.T, limit int) (*ScanOrchestrator, *queue.Queue) {
+	t.Helper()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	t.Cleanup(mr.Close)
+	q, err := queue.New(mr.Addr(), "", 0, time.Minute)
+	if err != nil {
+		t.Fatalf("queue: %v", err)
+	}
+	t.Cleanup(func() { q.Close() })
+
+	cfg := &config.Config{
+		DataDir: t.TempDir(),
+		Worker: config.WorkerConfig{
+			LockTTL:    time.Minute,
+			ScanMaxAge: time.Hour,
+			RenewEvery: time.Minute,
+		},
+		Workspace: config.WorkspaceConfig{IncrementalMaxStacks: &limit},
+	}
+	orch := New(cfg, q)
+	t.Cleanup(orch.Stop)
+	return orch, q
+}
+
+func initSparseRepo(t *testing.T, dir string) *git.Repository {
+	t.Helper()
+	project, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("init project: %v", err)
+	}
+	commitFiles(t, project, dir, map[string]string{
+		".terraform-version":     "1.7.5\n",
+		"envs/env.hcl":           "locals {}\n",
+		"envs/dev/main.tf":       "module \"vpc\" {\n  source = \"../../modules/vpc\"\n}\n",
+		"envs/prod/main.tf":      "resource \"null_resource\" \"prod\" {}\n",
+		"modules/vpc/main.tf":    "module \"shared\" {\n  source = \"../shared\"\n}\n",
+		"modules/shared/main.tf": "resource \"null_resource\" \"shared\" {}\n",
+		"modules/unused/main.tf": "resource \"null_resource\" \"unused\" {}\n",
+	})
+	return project
+}
+
+func commitFiles(t *testing.T, project *git.Repository, dir string, files map[string]string) string {
+	t.Helper()
+	return commitFilesAt(t, project, dir, files, time.Now())
+}
+
+func commitFilesAt(t *testing.T, project *git.Repository, dir string, files map[string]string, when time.Time) string {
+	t.Helper()
+	wt, err := project.Worktree()
+	if err != nil {
+		t.Fatalf("worktree: %v", err)
+	}
+	for name, content := range files {
+		full := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		if _, err := wt.Add(name); err != nil {
+			t.Fatalf("add %s: %v", name, err)
+		}
+	}
+	hash, err := wt.Commit("update", &git.CommitOptions{
+		Author: &object.Signature{Name: "tester", Email: "tester@example.com", When: when},
+	})
+	if err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	return hash.String()
+}
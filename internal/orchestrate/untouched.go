@@ -0,0 +1,97 @@
+package orchestrate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/stack"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// skipUntouchedStacks drops stacks with no commit touching them, or a local
+// module they call, in the last skip_untouched_days days and records how
+// many were dropped on the scan. Errors reading history keep every stack.
+func (o *ScanOrchestrator) skipUntouchedStacks(ctx context.Context, scan *queue.Scan, projectCfg *config.ProjectConfig, stacks []string) []string {
+	if projectCfg.SkipUntouchedDays <= 0 || scan.WorkspacePath == "" || scan.CommitSHA == "" {
+		return stacks
+	}
+	since := time.Now().AddDate(0, 0, -projectCfg.SkipUntouchedDays)
+	files, err := filesChangedSince(scan.WorkspacePath, scan.CommitSHA, since)
+	if err != nil {
+		log.Printf("scan %s: read history for untouched stacks: %v", scan.ID, err)
+		return stacks
+	}
+	touched := addModuleDependents(SelectStacksForChanges(stacks, files), stack.BuildModuleIndex(scan.WorkspacePath, stacks), files)
+	skipped := len(stacks) - len(touched)
+	if skipped == 0 {
+		return stacks
+	}
+	if err := o.queue.SetScanSkippedStale(ctx, scan.ID, skipped); err != nil {
+		log.Printf("scan %s: record skipped stacks: %v", scan.ID, err)
+	}
+	return touched
+}
+
+// filesChangedSince returns the paths changed by commits reachable from head
+// that were committed after since. Each commit is compared with its first
+// parent; the walk stops at the first older commit in committer-time order.
+func filesChangedSince(workspacePath, head string, since time.Time) ([]string, error) {
+	project, err := git.PlainOpen(workspacePath)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := project.Log(&git.LogOptions{From: plumbing.NewHash(head), Order: git.LogOrderCommitterTime})
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	seen := map[string]struct{}{}
+	var files []string
+	add := func(name string) {
+		if _, ok := seen[name]; ok || name == "" {
+			return
+		}
+		seen[name] = struct{}{}
+		files = append(files, name)
+	}
+	err = iter.ForEach(func(c *object.Commit) error {
+		if c.Committer.When.Before(since) {
+			return storer.ErrStop
+		}
+		tree, err := c.Tree()
+		if err != nil {
+			return err
+		}
+		var parentTree *object.Tree
+		if c.NumParents() > 0 {
+			parent, err := c.Parent(0)
+			if err != nil {
+				return err
+			}
+			if parentTree, err = parent.Tree(); err != nil {
+				return err
+			}
+		}
+		changes, err := object.DiffTree(parentTree, tree)
+		if err != nil {
+			return fmt.Errorf("diff %s: %w", c.Hash, err)
+		}
+		for _, change := range changes {
+			add(change.From.Name)
+			add(change.To.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
@@ -0,0 +1,90 @@
+package orchestrate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/go-git/go-git/v5"
+)
+
+func TestScheduledScanSkipsUntouchedStacks(t *testing.T) {
+	projectDir := t.TempDir()
+	project, err := git.PlainInit(projectDir, false)
+	if err != nil {
+		t.Fatalf("init project: %v", err)
+	}
+	old := time.Now().AddDate(0, 0, -200)
+	commitFilesAt(t, project, projectDir, map[string]string{
+		"envs/dev/main.tf":       "module \"vpc\" {\n  source = \"../../modules/vpc\"\n}\n",
+		"envs/prod/main.tf":      "resource \"null_resource\" \"prod\" {}\n",
+		"envs/stage/main.tf":     "resource \"null_resource\" \"stage\" {}\n",
+		"modules/vpc/main.tf":    "resource \"null_resource\" \"vpc\" {}\n",
+		"modules/unused/main.tf": "resource \"null_resource\" \"unused\" {}\n",
+	}, old)
+	// envs/dev only changes through its module; envs/stage directly.
+	commitFiles(t, project, projectDir, map[string]string{
+		"modules/vpc/main.tf": "resource \"null_resource\" \"vpc\" {}\n# changed\n",
+	})
+	commitFiles(t, project, projectDir, map[string]string{
+		"envs/stage/main.tf": "resource \"null_resource\" \"stage\" {}\n# changed\n",
+	})
+
+	orch, q := newSparseOrchestrator(t, 0)
+	projectCfg := &config.ProjectConfig{
+		Name:              "project",
+		URL:               "file://" + projectDir,
+		IgnorePaths:       []string{"modules/**"},
+		SkipUntouchedDays: 30,
+	}
+
+	scan, result, err := orch.StartAndEnqueue(context.Background(), projectCfg, "scheduled", "", "")
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	if len(result.StackIDs) != 2 {
+		t.Fatalf("expected envs/dev and envs/stage enqueued, got %v", result.StackIDs)
+	}
+	state, err := q.GetScan(context.Background(), scan.ID)
+	if err != nil {
+		t.Fatalf("get scan: %v", err)
+	}
+	if state.Total != 2 || state.SkippedStale != 1 {
+		t.Fatalf("expected total=2 skipped_stale=1, got total=%d skipped_stale=%d", state.Total, state.SkippedStale)
+	}
+	for _, id := range result.StackIDs {
+		job, err := q.GetStackScan(context.Background(), id)
+		if err != nil {
+			t.Fatalf("get stack scan: %v", err)
+		}
+		if job.StackPath == "envs/prod" {
+			t.Fatalf("expected envs/prod to be skipped")
+		}
+	}
+}
+
+func TestManualScanKeepsUntouchedStacks(t *testing.T) {
+	projectDir := t.TempDir()
+	project, err := git.PlainInit(projectDir, false)
+	if err != nil {
+		t.Fatalf("init project: %v", err)
+	}
+	commitFilesAt(t, project, projectDir, map[string]string{
+		"envs/prod/main.tf": "resource \"null_resource\" \"prod\" {}\n",
+	}, time.Now().AddDate(0, 0, -200))
+
+	orch, _ := newSparseOrchestrator(t, 0)
+	projectCfg := &config.ProjectConfig{
+		Name:              "project",
+		URL:               "file://" + projectDir,
+		SkipUntouchedDays: 30,
+	}
+	_, result, err := orch.StartAndEnqueue(context.Background(), projectCfg, "manual", "", "")
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	if len(result.StackIDs) != 1 {
+		t.Fatalf("expected the untouched stack to be scanned manually, got %v", result.StackIDs)
+	}
+}
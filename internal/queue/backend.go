@@ -0,0 +1,178 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// Backend is the queue and scan-state store used by the server, workers and
+// orchestrator. Queue (Redis) is the production implementation; NewMemory
+// returns an in-process backend for standalone installs and tests.
+type Backend interface {
+	Ping(ctx context.Context) error
+	Close() error
+
+	// Stack scan queue.
+	Enqueue(ctx context.Context, stackScan *StackScan) error
+	EnqueueBatch(ctx context.Context, stacks []*StackScan) (*EnqueueBatchResult, error)
+	Dequeue(ctx context.Context, workerID string) (*StackScan, error)
+	Complete(ctx context.Context, stackScan *StackScan, drifted bool) error
+	Fail(ctx context.Context, stackScan *StackScan, errMsg string) error
+	CancelStackScan(ctx context.Context, stackScan *StackScan, reason string) error
+	GetStackScan(ctx context.Context, stackScanID string) (*StackScan, error)
+	ListProjectStackScans(ctx context.Context, projectName string, limit int) ([]*StackScan, error)
+	ListScanStackScans(ctx context.Context, scanID string) ([]*StackScan, error)
+	ListRunningStackScans(ctx context.Context) ([]*StackScan, error)
+	ListPendingStackScans(ctx context.Context) ([]*StackScan, error)
+	QueueDepth(ctx context.Context) (int64, error)
+
+	// Project pauses.
+	PauseProject(ctx context.Context, pause ProjectPause) error
+	ResumeProject(ctx context.Context, projectName string) (bool, error)
+	GetProjectPause(ctx context.Context, projectName string) (*ProjectPause, error)
+	ListPausedProjects(ctx context.Context) ([]ProjectPause, error)
+
+	// Scans and project locks.
+	StartScan(ctx context.Context, projectName, trigger, commit, actor string, total int) (*Scan, error)
+	CancelAndStartScan(ctx context.Context, oldScanID, projectName, cancelReason, trigger, commit, actor string, total int) (*Scan, error)
+	CancelScan(ctx context.Context, scanID, projectName, reason string) error
+	FailScan(ctx context.Context, scanID, projectName, errMsg string) error
+	GetScan(ctx context.Context, scanID string) (*Scan, error)
+	GetActiveScan(ctx context.Context, projectName string) (*Scan, error)
+	GetLastScan(ctx context.Context, projectName string) (*Scan, error)
+	ListProjectScans(ctx context.Context, projectName string, limit int) ([]*Scan, error)
+	SetScanTotal(ctx context.Context, scanID string, total int) error
+	SetScanVersions(ctx context.Context, scanID, tfVersion, tgVersion string, stackTF, stackTG map[string]string) error
+	SetScanWorkspace(ctx context.Context, scanID, workspacePath, commitSHA string) error
+	SetScanCommitInfo(ctx context.Context, scanID string, info *storage.CommitInfo) error
+	SetScanSkippedStale(ctx context.Context, scanID string, skipped int) error
+	SetScanWarnings(ctx context.Context, scanID string, warnings []ScanWarning) error
+	LinkScanRetry(ctx context.Context, scanID, retryScanID string) error
+	RecordScanPhases(ctx context.Context, scanID string, phases ...ScanPhase) error
+	AdjustScanCounters(ctx context.Context, scanID, projectName string, deltas ...any) error
+	ClearInflightForScan(ctx context.Context, scanID string)
+	IsProjectLocked(ctx context.Context, projectName string) (bool, error)
+	RenewScanLock(ctx context.Context, scanID, projectName string, maxAge, renewEvery time.Duration)
+
+	// Clone locks.
+	AcquireCloneLock(ctx context.Context, urlHash, owner string, ttl time.Duration) (bool, error)
+	RenewCloneLock(ctx context.Context, urlHash, owner string, ttl time.Duration) error
+	ReleaseCloneLock(ctx context.Context, urlHash, owner string) error
+
+	// Leader leases.
+	AcquireLeaderLease(ctx context.Context, role, owner string, ttl time.Duration) (bool, error)
+	RenewLeaderLease(ctx context.Context, role, owner string, ttl time.Duration) error
+	ReleaseLeaderLease(ctx context.Context, role, owner string) error
+	GetLeaderLease(ctx context.Context, role string) (*LeaderLease, error)
+
+	// Scan start limits.
+	AcquireScanStart(ctx context.Context, key string, limit int, ttl time.Duration) (bool, error)
+	ReleaseScanStart(ctx context.Context, key string) error
+
+	// Idempotency keys.
+	ClaimIdempotencyKey(ctx context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, key string, record IdempotencyRecord, ttl time.Duration) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+
+	// UI session revocation.
+	RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error
+	IsSessionRevoked(ctx context.Context, sessionID string) (bool, error)
+
+	// Recovery.
+	RebuildRunningScansIndex(ctx context.Context) (int, error)
+	RecoverStaleScans(ctx context.Context, maxAge time.Duration) (int, error)
+	RecoverStaleStackScans(ctx context.Context, maxAge time.Duration) (int, error)
+	RecoverOrphanedStackScans(ctx context.Context) (int, error)
+
+	// Metrics.
+	RunningScanCount(ctx context.Context) (int, error)
+	RunningStackScanCount(ctx context.Context) (int, error)
+	OldestRunningScanAge(ctx context.Context) (time.Duration, error)
+	OldestRunningStackScanAge(ctx context.Context) (time.Duration, error)
+	OldestPendingStackScanAge(ctx context.Context) (time.Duration, error)
+
+	// Drift transitions.
+	DriftChangesSince(ctx context.Context, projectName string, since time.Time, skipScanID string) ([]DriftChange, error)
+	ScanDriftChanges(ctx context.Context, projectName, scanID string) ([]DriftChange, error)
+
+	// Events.
+	PublishScanEvent(ctx context.Context, projectName string, event ScanEvent) error
+	PublishStackEvent(ctx context.Context, projectName string, event StackEvent) error
+	SubscribeProjectEvents(ctx context.Context, projectName string) (<-chan ProjectEvent, error)
+	SubscribeScanCancels(ctx context.Context) (<-chan ScanCancel, error)
+
+	// Event outbox.
+	ReadOutbox(ctx context.Context, projectName, after string, limit int) ([]OutboxEvent, string, error)
+	OutboxOffset(ctx context.Context, consumer string) (string, error)
+	CommitOutboxOffset(ctx context.Context, consumer, id string) error
+	DeleteOutboxConsumer(ctx context.Context, consumer string) error
+	OutboxStatus(ctx context.Context) (*OutboxStatus, error)
+
+	// Worker registry and admin commands.
+	HeartbeatWorker(ctx context.Context, info WorkerInfo) error
+	RemoveWorker(ctx context.Context, workerID string) error
+	ListWorkers(ctx context.Context) ([]WorkerInfo, error)
+	PublishWorkerCommand(ctx context.Context, cmd WorkerCommand) (int64, error)
+	SubscribeWorkerCommands(ctx context.Context) (<-chan WorkerCommand, error)
+
+	// Key memory usage.
+	KeyMemoryUsage(ctx context.Context, samples int) (*KeyMemoryReport, error)
+
+	// Snapshot and restore.
+	Snapshot(ctx context.Context) (*Snapshot, error)
+	Restore(ctx context.Context, snap *Snapshot, overwrite bool) (RestoreStats, error)
+}
+
+var _ Backend = (*Queue)(nil)
+
+// Ping checks that the backing store is reachable.
+func (q *Queue) Ping(ctx context.Context) error {
+	return q.client.Ping(ctx).Err()
+}
+
+// SubscribeProjectEvents delivers events for one project, or for every
+// project when projectName is empty, until ctx is canceled. The returned
+// channel is ready once the subscription is confirmed.
+func (q *Queue) SubscribeProjectEvents(ctx context.Context, projectName string) (<-chan ProjectEvent, error) {
+	var pubsub *redis.PubSub
+	if projectName == "" {
+		pubsub = q.client.PSubscribe(ctx, projectEventsPrefix+"*")
+	} else {
+		pubsub = q.client.Subscribe(ctx, projectEventsPrefix+projectName)
+	}
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	out := make(chan ProjectEvent)
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				var event ProjectEvent
+				if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+					continue
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,550 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+// backendFactories returns every Backend implementation so the same
+// behavioural tests run against each of them.
+func backendFactories() map[string]func(t *testing.T) Backend {
+	return map[string]func(t *testing.T) Backend{
+		"redis":  func(t *testing.T) Backend { return newTestQueue(t) },
+		"nats":   func(t *testing.T) Backend { return newTestNATSQueue(t) },
+		"memory": func(t *testing.T) Backend { return newMemoryQueue(t) },
+	}
+}
+
+func forEachBackend(t *testing.T, fn func(t *testing.T, q Backend)) {
+	for name, newBackend := range backendFactories() {
+		t.Run(name, func(t *testing.T) {
+			fn(t, newBackend(t))
+		})
+	}
+}
+
+func dequeueWithin(t *testing.T, q Backend, workerID string) *StackScan {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	job, err := q.Dequeue(ctx, workerID)
+	if err != nil {
+		t.Fatalf("dequeue: %v", err)
+	}
+	return job
+}
+
+func TestBackendScanLifecycle(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+
+		scan, err := q.StartScan(ctx, "project", "manual", "", "alice", 2)
+		if err != nil {
+			t.Fatalf("start scan: %v", err)
+		}
+		if _, err := q.StartScan(ctx, "project", "manual", "", "", 1); !errors.Is(err, ErrProjectLocked) {
+			t.Fatalf("expected ErrProjectLocked, got %v", err)
+		}
+		if locked, err := q.IsProjectLocked(ctx, "project"); err != nil || !locked {
+			t.Fatalf("expected project locked, got %v %v", locked, err)
+		}
+
+		for _, stack := range []string{"envs/dev", "envs/prod"} {
+			if err := q.Enqueue(ctx, &StackScan{ScanID: scan.ID, ProjectName: "project", StackPath: stack}); err != nil {
+				t.Fatalf("enqueue %s: %v", stack, err)
+			}
+		}
+		if err := q.Enqueue(ctx, &StackScan{ScanID: scan.ID, ProjectName: "project", StackPath: "envs/dev"}); !errors.Is(err, ErrStackScanInflight) {
+			t.Fatalf("expected ErrStackScanInflight, got %v", err)
+		}
+		listed, err := q.ListProjectStackScans(ctx, "project", 10)
+		if err != nil || len(listed) != 2 {
+			t.Fatalf("expected 2 listed stack scans, got %d (%v)", len(listed), err)
+		}
+
+		first := dequeueWithin(t, q, "worker-1")
+		second := dequeueWithin(t, q, "worker-1")
+		if first.Status != StatusRunning || first.WorkerID != "worker-1" {
+			t.Fatalf("expected running stack scan owned by worker-1, got %+v", first)
+		}
+		if n, _ := q.RunningStackScanCount(ctx); n != 2 {
+			t.Fatalf("expected 2 running stack scans, got %d", n)
+		}
+
+		if err := q.Complete(ctx, first, true); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+		if err := q.Fail(ctx, second, "boom"); err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+
+		final, err := q.GetScan(ctx, scan.ID)
+		if err != nil {
+			t.Fatalf("get scan: %v", err)
+		}
+		if final.Status != ScanStatusFailed || final.Completed != 1 || final.Failed != 1 || final.Drifted != 1 || final.Running != 0 {
+			t.Fatalf("unexpected final scan: %+v", final)
+		}
+		if final.EndedAt.Unix() <= 0 {
+			t.Fatalf("expected ended_at to be set")
+		}
+		if locked, _ := q.IsProjectLocked(ctx, "project"); locked {
+			t.Fatalf("expected project lock released")
+		}
+		if _, err := q.GetActiveScan(ctx, "project"); !errors.Is(err, ErrScanNotFound) {
+			t.Fatalf("expected no active scan, got %v", err)
+		}
+		last, err := q.GetLastScan(ctx, "project")
+		if err != nil || last.ID != scan.ID {
+			t.Fatalf("expected last scan %s, got %v %v", scan.ID, last, err)
+		}
+		if n, _ := q.RunningScanCount(ctx); n != 0 {
+			t.Fatalf("expected no running scans, got %d", n)
+		}
+
+		changes, err := q.ScanDriftChanges(ctx, "project", scan.ID)
+		if err != nil || len(changes) != 2 {
+			t.Fatalf("expected 2 drift changes, got %v (%v)", changes, err)
+		}
+		if err := q.Enqueue(ctx, &StackScan{ProjectName: "project", StackPath: "envs/dev"}); err != nil {
+			t.Fatalf("expected inflight marker released, got %v", err)
+		}
+	})
+}
+
+func TestBackendRetry(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+		job := &StackScan{ProjectName: "project", StackPath: "envs/dev", MaxRetries: 1}
+		if err := q.Enqueue(ctx, job); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		if err := q.Fail(ctx, dequeueWithin(t, q, "worker-1"), "boom"); err != nil {
+			t.Fatalf("fail: %v", err)
+		}
+		retry := dequeueWithin(t, q, "worker-2")
+		if retry.Retries != 1 || retry.WorkerID != "worker-2" {
+			t.Fatalf("expected retry claimed by worker-2, got %+v", retry)
+		}
+		if err := q.Complete(ctx, retry, false); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+		final, err := q.GetStackScan(ctx, job.ID)
+		if err != nil || final.Status != StatusCompleted {
+			t.Fatalf("expected completed, got %+v %v", final, err)
+		}
+	})
+}
+
+func TestBackendListRunningStackScans(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+		for _, path := range []string{"envs/dev", "envs/prod"} {
+			if err := q.Enqueue(ctx, &StackScan{ProjectName: "project", StackPath: path}); err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+		}
+		first := dequeueWithin(t, q, "worker-1")
+		second := dequeueWithin(t, q, "worker-2")
+		if err := q.Complete(ctx, second, false); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+
+		running, err := q.ListRunningStackScans(ctx)
+		if err != nil {
+			t.Fatalf("list running: %v", err)
+		}
+		if len(running) != 1 || running[0].ID != first.ID || running[0].WorkerID != "worker-1" || running[0].StartedAt.IsZero() {
+			t.Fatalf("expected only %s running on worker-1, got %+v", first.ID, running)
+		}
+	})
+}
+
+func TestBackendClaimIsExclusive(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+		if err := q.Enqueue(ctx, &StackScan{ProjectName: "project", StackPath: "envs/dev"}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			claimed int
+		)
+		for _, worker := range []string{"a", "b", "c"} {
+			wg.Add(1)
+			go func(worker string) {
+				defer wg.Done()
+				dctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+				defer cancel()
+				if _, err := q.Dequeue(dctx, worker); err == nil {
+					mu.Lock()
+					claimed++
+					mu.Unlock()
+				}
+			}(worker)
+		}
+		wg.Wait()
+		if claimed != 1 {
+			t.Fatalf("expected exactly one claim, got %d", claimed)
+		}
+	})
+}
+
+func TestBackendCancelAndStartScan(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+		old, err := q.StartScan(ctx, "project", "scheduled", "", "", 1)
+		if err != nil {
+			t.Fatalf("start scan: %v", err)
+		}
+		if _, err := q.CancelAndStartScan(ctx, "project:other", "project", "superseded", "manual", "", "", 1); !errors.Is(err, ErrProjectLocked) {
+			t.Fatalf("expected ErrProjectLocked for wrong owner, got %v", err)
+		}
+		next, err := q.CancelAndStartScan(ctx, old.ID, "project", "superseded", "manual", "", "", 1)
+		if err != nil {
+			t.Fatalf("cancel and start: %v", err)
+		}
+
+		canceled, err := q.GetScan(ctx, old.ID)
+		if err != nil || canceled.Status != ScanStatusCanceled || canceled.Error != "superseded" {
+			t.Fatalf("expected old scan canceled, got %+v %v", canceled, err)
+		}
+		active, err := q.GetActiveScan(ctx, "project")
+		if err != nil || active.ID != next.ID {
+			t.Fatalf("expected active scan %s, got %v %v", next.ID, active, err)
+		}
+		if err := q.CancelScan(ctx, next.ID, "project", ""); err != nil {
+			t.Fatalf("cancel: %v", err)
+		}
+		if locked, _ := q.IsProjectLocked(ctx, "project"); locked {
+			t.Fatalf("expected lock released after cancel")
+		}
+	})
+}
+
+func TestBackendCloneLocks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+		if ok, err := q.AcquireCloneLock(ctx, "abc", "owner-1", time.Minute); err != nil || !ok {
+			t.Fatalf("acquire: %v %v", ok, err)
+		}
+		if ok, _ := q.AcquireCloneLock(ctx, "abc", "owner-2", time.Minute); ok {
+			t.Fatalf("expected second acquire to fail")
+		}
+		if err := q.RenewCloneLock(ctx, "abc", "owner-2", time.Minute); !errors.Is(err, ErrCloneLockNotOwned) {
+			t.Fatalf("expected ErrCloneLockNotOwned, got %v", err)
+		}
+		if err := q.RenewCloneLock(ctx, "abc", "owner-1", time.Minute); err != nil {
+			t.Fatalf("renew: %v", err)
+		}
+		if err := q.ReleaseCloneLock(ctx, "abc", "owner-1"); err != nil {
+			t.Fatalf("release: %v", err)
+		}
+		if ok, _ := q.AcquireCloneLock(ctx, "abc", "owner-2", time.Minute); !ok {
+			t.Fatalf("expected acquire after release")
+		}
+	})
+}
+
+func TestBackendEventsAndWorkers(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		events, err := q.SubscribeProjectEvents(ctx, "")
+		if err != nil {
+			t.Fatalf("subscribe: %v", err)
+		}
+		if err := q.PublishStackEvent(ctx, "project", StackEvent{StackPath: "envs/dev", Status: StatusRunning}); err != nil {
+			t.Fatalf("publish: %v", err)
+		}
+		select {
+		case event := <-events:
+			if event.ProjectName != "project" || event.StackPath != "envs/dev" || event.Type != "stack_update" {
+				t.Fatalf("unexpected event: %+v", event)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no event received")
+		}
+
+		if err := q.HeartbeatWorker(ctx, WorkerInfo{ID: "host-1", Hostname: "host", Concurrency: 2}); err != nil {
+			t.Fatalf("heartbeat: %v", err)
+		}
+		workers, err := q.ListWorkers(ctx)
+		if err != nil || len(workers) != 1 || workers[0].Concurrency != 2 {
+			t.Fatalf("expected one worker, got %v %v", workers, err)
+		}
+		if err := q.RemoveWorker(ctx, "host-1"); err != nil {
+			t.Fatalf("remove: %v", err)
+		}
+		if workers, _ := q.ListWorkers(ctx); len(workers) != 0 {
+			t.Fatalf("expected no workers, got %v", workers)
+		}
+	})
+}
+
+func TestBackendScanCommitInfo(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+		scan, err := q.StartScan(ctx, "project", "manual", "", "", 1)
+		if err != nil {
+			t.Fatalf("start scan: %v", err)
+		}
+		when := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+		info := &storage.CommitInfo{Author: "jane", Summary: "Add prod RDS", Time: when}
+		if err := q.SetScanCommitInfo(ctx, scan.ID, info); err != nil {
+			t.Fatalf("set commit info: %v", err)
+		}
+		got, err := q.GetScan(ctx, scan.ID)
+		if err != nil {
+			t.Fatalf("get scan: %v", err)
+		}
+		if got.CommitInfo == nil || got.CommitInfo.Author != "jane" || got.CommitInfo.Summary != "Add prod RDS" || !got.CommitInfo.Time.Equal(when) {
+			t.Fatalf("expected commit info round-trip, got %+v", got.CommitInfo)
+		}
+	})
+}
+
+func TestBackendScanPhases(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+		scan, err := q.StartScan(ctx, "project", "manual", "", "", 1)
+		if err != nil {
+			t.Fatalf("start scan: %v", err)
+		}
+		base := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+		lock := ScanPhase{Name: PhaseLock, StartedAt: base, EndedAt: base.Add(10 * time.Millisecond)}
+		clone := ScanPhase{Name: PhaseClone, StartedAt: lock.EndedAt, EndedAt: lock.EndedAt.Add(2 * time.Second)}
+		if err := q.RecordScanPhases(ctx, scan.ID, clone, lock); err != nil {
+			t.Fatalf("record phases: %v", err)
+		}
+		enqueue := ScanPhase{Name: PhaseEnqueue, StartedAt: clone.EndedAt, EndedAt: clone.EndedAt.Add(time.Millisecond)}
+		retimedLock := ScanPhase{Name: PhaseLock, StartedAt: base, EndedAt: base.Add(20 * time.Millisecond)}
+		if err := q.RecordScanPhases(ctx, scan.ID, enqueue, retimedLock); err != nil {
+			t.Fatalf("record phases: %v", err)
+		}
+
+		got, err := q.GetScan(ctx, scan.ID)
+		if err != nil {
+			t.Fatalf("get scan: %v", err)
+		}
+		if len(got.Phases) != 3 {
+			t.Fatalf("expected 3 phases, got %+v", got.Phases)
+		}
+		for i, name := range []string{PhaseLock, PhaseClone, PhaseEnqueue} {
+			if got.Phases[i].Name != name {
+				t.Fatalf("expected phase %d to be %s, got %+v", i, name, got.Phases)
+			}
+		}
+		if got.Phases[0].Duration() != 20*time.Millisecond || got.Phases[1].Duration() != 2*time.Second {
+			t.Fatalf("unexpected phase durations: %+v", got.Phases)
+		}
+	})
+}
+
+func TestBackendListProjectScans(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+		first, err := q.StartScan(ctx, "project", "manual", "", "", 1)
+		if err != nil {
+			t.Fatalf("start scan: %v", err)
+		}
+		if err := q.Enqueue(ctx, &StackScan{ScanID: first.ID, ProjectName: "project", StackPath: "envs/prod"}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		second, err := q.CancelAndStartScan(ctx, first.ID, "project", "superseded", "manual", "", "", 0)
+		if err != nil {
+			t.Fatalf("cancel and start: %v", err)
+		}
+
+		scans, err := q.ListProjectScans(ctx, "project", 10)
+		if err != nil {
+			t.Fatalf("list scans: %v", err)
+		}
+		if len(scans) != 2 || scans[0].ID != second.ID || scans[1].ID != first.ID {
+			t.Fatalf("expected newest scan first, got %+v", scans)
+		}
+		if scans, err := q.ListProjectScans(ctx, "project", 1); err != nil || len(scans) != 1 {
+			t.Fatalf("expected limit to apply, got %d scans (%v)", len(scans), err)
+		}
+
+		stackScans, err := q.ListScanStackScans(ctx, first.ID)
+		if err != nil {
+			t.Fatalf("list scan stack scans: %v", err)
+		}
+		if len(stackScans) != 1 || stackScans[0].StackPath != "envs/prod" {
+			t.Fatalf("expected the enqueued stack scan, got %+v", stackScans)
+		}
+	})
+}
+
+func TestBackendScanWarnings(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+		scan, err := q.StartScan(ctx, "project", "manual", "", "", 1)
+		if err != nil {
+			t.Fatalf("start scan: %v", err)
+		}
+		warnings := []ScanWarning{{Kind: ScanWarningSecret, Path: "keys/id_rsa", Line: 1, Rule: "private_key"}}
+		if err := q.SetScanWarnings(ctx, scan.ID, warnings); err != nil {
+			t.Fatalf("set warnings: %v", err)
+		}
+		got, err := q.GetScan(ctx, scan.ID)
+		if err != nil {
+			t.Fatalf("get scan: %v", err)
+		}
+		if len(got.Warnings) != 1 || got.Warnings[0] != warnings[0] {
+			t.Fatalf("expected %+v, got %+v", warnings, got.Warnings)
+		}
+	})
+}
+
+func TestBackendProjectPause(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+		if err := q.PauseProject(ctx, ProjectPause{Project: "paused", Actor: "alice", Reason: "incident"}); err != nil {
+			t.Fatalf("pause: %v", err)
+		}
+		pause, err := q.GetProjectPause(ctx, "paused")
+		if err != nil || pause == nil || pause.Actor != "alice" || pause.PausedAt.IsZero() {
+			t.Fatalf("expected pause by alice, got %+v (%v)", pause, err)
+		}
+		if pause, err := q.GetProjectPause(ctx, "other"); err != nil || pause != nil {
+			t.Fatalf("expected other project not paused, got %+v (%v)", pause, err)
+		}
+		if pauses, err := q.ListPausedProjects(ctx); err != nil || len(pauses) != 1 || pauses[0].Project != "paused" {
+			t.Fatalf("expected one paused project, got %+v (%v)", pauses, err)
+		}
+
+		if err := q.Enqueue(ctx, &StackScan{ProjectName: "paused", StackPath: "envs/dev"}); err != nil {
+			t.Fatalf("enqueue paused: %v", err)
+		}
+		if err := q.Enqueue(ctx, &StackScan{ProjectName: "other", StackPath: "envs/dev"}); err != nil {
+			t.Fatalf("enqueue other: %v", err)
+		}
+		if job := dequeueWithin(t, q, "worker-1"); job.ProjectName != "other" {
+			t.Fatalf("expected other project's stack scan, got %s", job.ProjectName)
+		}
+		waitCtx, cancel := context.WithTimeout(ctx, 1500*time.Millisecond)
+		job, err := q.Dequeue(waitCtx, "worker-1")
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected paused stack scan to stay queued, got %+v (%v)", job, err)
+		}
+
+		if resumed, err := q.ResumeProject(ctx, "paused"); err != nil || !resumed {
+			t.Fatalf("resume: %v %v", resumed, err)
+		}
+		if resumed, _ := q.ResumeProject(ctx, "paused"); resumed {
+			t.Fatalf("expected second resume to report not paused")
+		}
+		// NATS redelivers the held message after its backoff.
+		waitCtx, cancel = context.WithTimeout(ctx, 3*natsClaimBackoff)
+		defer cancel()
+		job, err = q.Dequeue(waitCtx, "worker-1")
+		if err != nil || job.ProjectName != "paused" || job.Status != StatusRunning {
+			t.Fatalf("expected resumed stack scan, got %+v (%v)", job, err)
+		}
+	})
+}
+
+func TestBackendOldestPendingStackScanAge(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+		if age, err := q.OldestPendingStackScanAge(ctx); err != nil || age != 0 {
+			t.Fatalf("expected zero age on empty queue, got %s (%v)", age, err)
+		}
+		if err := q.Enqueue(ctx, &StackScan{ProjectName: "waiting", StackPath: "envs/dev"}); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if age, err := q.OldestPendingStackScanAge(ctx); err != nil || age < 20*time.Millisecond {
+			t.Fatalf("expected pending age, got %s (%v)", age, err)
+		}
+
+		if err := q.PauseProject(ctx, ProjectPause{Project: "waiting"}); err != nil {
+			t.Fatalf("pause: %v", err)
+		}
+		if age, err := q.OldestPendingStackScanAge(ctx); err != nil || age != 0 {
+			t.Fatalf("expected paused project left out, got %s (%v)", age, err)
+		}
+	})
+}
+
+func TestBackendListPendingStackScans(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+
+		for _, ss := range []*StackScan{
+			{ScanID: "s1", ProjectName: "project", StackPath: "a"},
+			{ScanID: "s2", ProjectName: "paused", StackPath: "b"},
+			{ScanID: "s1", ProjectName: "project", StackPath: "c"},
+		} {
+			if err := q.Enqueue(ctx, ss); err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+		}
+		if err := q.PauseProject(ctx, ProjectPause{Project: "paused"}); err != nil {
+			t.Fatalf("pause: %v", err)
+		}
+
+		pending, err := q.ListPendingStackScans(ctx)
+		if err != nil {
+			t.Fatalf("list pending: %v", err)
+		}
+		if len(pending) != 2 || pending[0].StackPath != "a" || pending[1].StackPath != "c" {
+			t.Fatalf("unexpected pending stack scans: %+v", pending)
+		}
+	})
+}
+
+func TestBackendScanConcurrencyUnderContention(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+
+		scan, err := q.StartScan(ctx, "project", "manual", "", "", 4)
+		if err != nil {
+			t.Fatalf("start scan: %v", err)
+		}
+		for _, stack := range []string{"a", "b", "c", "d"} {
+			if err := q.Enqueue(ctx, &StackScan{ScanID: scan.ID, ProjectName: "project", StackPath: stack, ScanConcurrency: 1}); err != nil {
+				t.Fatalf("enqueue %s: %v", stack, err)
+			}
+		}
+
+		claimCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+		defer cancel()
+		var mu sync.Mutex
+		claimed := 0
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := q.Dequeue(claimCtx, "worker"); err == nil {
+					mu.Lock()
+					claimed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		if claimed != 1 {
+			t.Fatalf("expected 1 claim under a concurrency of 1, got %d", claimed)
+		}
+		got, err := q.GetScan(ctx, scan.ID)
+		if err != nil {
+			t.Fatalf("get scan: %v", err)
+		}
+		if got.Running != 1 || got.Queued != 3 {
+			t.Fatalf("expected 1 running and 3 queued, got %d running %d queued", got.Running, got.Queued)
+		}
+	})
+}
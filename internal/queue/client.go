@@ -11,6 +11,13 @@ import (
 type Queue struct {
 	client  *redis.Client
 	lockTTL time.Duration
+
+	// compressAbove is the stack scan size above which payloads are stored
+	// compressed. Zero means defaultCompressAbove; negative disables it.
+	compressAbove int
+
+	// retention overrides the default lifetimes and caps of key families.
+	retention Retention
 }
 
 func New(addr, password string, db int, lockTTL time.Duration) (*Queue, error) {
@@ -38,7 +45,15 @@ func (q *Queue) Close() error {
 }
 
 func (q *Queue) QueueDepth(ctx context.Context) (int64, error) {
-	return q.client.LLen(ctx, keyQueue).Result()
+	var depth int64
+	for _, key := range workLists {
+		n, err := q.client.LLen(ctx, key).Result()
+		if err != nil {
+			return 0, err
+		}
+		depth += n
+	}
+	return depth, nil
 }
 
 // IsProjectLocked checks if a project scan is in progress.
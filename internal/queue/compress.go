@@ -0,0 +1,128 @@
+package queue
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// defaultCompressAbove is the serialized size above which stack scans are
+// stored gzip-compressed in Redis.
+const defaultCompressAbove = 4 << 10
+
+// encodingGzip marks a stack scan stored as a gzip envelope.
+const encodingGzip = "gzip"
+
+// compressedJobSchemaVersion is the lowest schema version that may be stored
+// as an envelope. Builds that only understand plain JSON claim up to version
+// 1, so they leave compressed jobs for newer workers.
+const compressedJobSchemaVersion = 2
+
+// envelopePrefix is how every stored envelope starts. Plain stack scans start
+// with "id", so a prefix check tells the two apart without a second decode.
+var envelopePrefix = []byte(`{"encoding":`)
+
+// stackScanEnvelope is the stored form of a compressed stack scan. Status,
+// schema version and project are kept in the clear so the claim script can
+// check them without decompressing.
+type stackScanEnvelope struct {
+	Encoding      string `json:"encoding"`
+	Status        string `json:"status"`
+	SchemaVersion int    `json:"schema_version"`
+	ProjectName   string `json:"project_name,omitempty"`
+	Data          []byte `json:"data"`
+
+	// ScanID, ScanConcurrency and Priority are read by the claim and
+	// resume scripts.
+	ScanID          string `json:"scan_id,omitempty"`
+	ScanConcurrency int    `json:"scan_concurrency,omitempty"`
+	Priority        int    `json:"priority,omitempty"`
+}
+
+// SetCompressAbove sets the serialized size in bytes above which stack scans
+// are stored compressed. Zero restores the default and a negative value
+// turns compression off. Stored values are always readable either way.
+func (q *Queue) SetCompressAbove(n int) {
+	q.compressAbove = n
+}
+
+func (q *Queue) compressThreshold() int {
+	if q.compressAbove == 0 {
+		return defaultCompressAbove
+	}
+	return q.compressAbove
+}
+
+// encodeStackScan serializes a stack scan for Redis, compressing it when it
+// is larger than the queue's threshold.
+func (q *Queue) encodeStackScan(stackScan *StackScan) ([]byte, error) {
+	data, err := json.Marshal(stackScan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stack scan: %w", err)
+	}
+	threshold := q.compressThreshold()
+	if threshold < 0 || len(data) <= threshold {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress stack scan: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress stack scan: %w", err)
+	}
+
+	version := stackScan.SchemaVersion
+	if version < compressedJobSchemaVersion {
+		version = compressedJobSchemaVersion
+	}
+	envelope, err := json.Marshal(stackScanEnvelope{
+		Encoding:      encodingGzip,
+		Status:        stackScan.Status,
+		SchemaVersion: version,
+		ProjectName:   stackScan.ProjectName,
+		Data:          buf.Bytes(),
+
+		ScanID:          stackScan.ScanID,
+		ScanConcurrency: stackScan.ScanConcurrency,
+		Priority:        stackScan.Priority,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal stack scan: %w", err)
+	}
+	if len(envelope) >= len(data) {
+		return data, nil
+	}
+	return envelope, nil
+}
+
+// decodeStackScan reads a stack scan stored as plain JSON or as an envelope.
+func decodeStackScan(data []byte) (*StackScan, error) {
+	if bytes.HasPrefix(data, envelopePrefix) {
+		var envelope stackScanEnvelope
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stack scan: %w", err)
+		}
+		if envelope.Encoding != encodingGzip {
+			return nil, fmt.Errorf("unsupported stack scan encoding %q", envelope.Encoding)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(envelope.Data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress stack scan: %w", err)
+		}
+		defer zr.Close()
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, fmt.Errorf("failed to decompress stack scan: %w", err)
+		}
+	}
+
+	var stackScan StackScan
+	if err := json.Unmarshal(data, &stackScan); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stack scan: %w", err)
+	}
+	return &stackScan, nil
+}
@@ -0,0 +1,97 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLargeStackScansAreStoredCompressed(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	args := make([]string, 500)
+	for i := range args {
+		args[i] = "-var-file=" + strings.Repeat("envs/prod/", 4) + "terraform.tfvars"
+	}
+	stackScan := &StackScan{
+		ProjectName: "project",
+		ProjectURL:  "file:///project",
+		StackPath:   "envs/prod",
+		PlanArgs:    args,
+	}
+	if err := q.Enqueue(ctx, stackScan); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	raw, err := q.client.Get(ctx, keyStackScanPrefix+stackScan.ID).Bytes()
+	if err != nil {
+		t.Fatalf("get raw: %v", err)
+	}
+	if !bytes.HasPrefix(raw, envelopePrefix) {
+		t.Fatalf("expected compressed envelope, got %.40s", raw)
+	}
+	plain, _ := json.Marshal(stackScan)
+	if len(raw) >= len(plain) {
+		t.Fatalf("expected compressed payload smaller than %d bytes, got %d", len(plain), len(raw))
+	}
+
+	dequeueCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	job, err := q.Dequeue(dequeueCtx, "worker-1")
+	if err != nil {
+		t.Fatalf("dequeue: %v", err)
+	}
+	if job.ID != stackScan.ID || len(job.PlanArgs) != len(args) || job.PlanArgs[0] != args[0] {
+		t.Fatalf("dequeued stack scan does not match: %+v", job)
+	}
+	if job.Status != StatusRunning || job.SchemaVersion != JobSchemaVersion {
+		t.Fatalf("unexpected status %q version %d", job.Status, job.SchemaVersion)
+	}
+}
+
+func TestStackScanCompressionKeepsPlainPayloadsReadable(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	small := &StackScan{ID: "small", ProjectName: "project", StackPath: "envs/dev", Status: StatusPending}
+	if err := q.saveStackScan(ctx, small); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	raw, err := q.client.Get(ctx, keyStackScanPrefix+"small").Bytes()
+	if err != nil {
+		t.Fatalf("get raw: %v", err)
+	}
+	if bytes.HasPrefix(raw, envelopePrefix) {
+		t.Fatalf("expected small stack scan stored as plain JSON")
+	}
+
+	// A value written by a build that predates compression.
+	legacy := `{"id":"legacy","project_name":"project","stack_path":"envs/old","status":"pending"}`
+	if err := q.client.Set(ctx, keyStackScanPrefix+"legacy", legacy, 0).Err(); err != nil {
+		t.Fatalf("set legacy: %v", err)
+	}
+	got, err := q.GetStackScan(ctx, "legacy")
+	if err != nil {
+		t.Fatalf("get legacy: %v", err)
+	}
+	if got.StackPath != "envs/old" || got.Status != StatusPending {
+		t.Fatalf("unexpected legacy stack scan: %+v", got)
+	}
+
+	q.SetCompressAbove(-1)
+	big := &StackScan{ID: "big", StackPath: "envs/prod", PlanArgs: []string{strings.Repeat("x", 8<<10)}}
+	if err := q.saveStackScan(ctx, big); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	raw, err = q.client.Get(ctx, keyStackScanPrefix+"big").Bytes()
+	if err != nil {
+		t.Fatalf("get raw: %v", err)
+	}
+	if bytes.HasPrefix(raw, envelopePrefix) {
+		t.Fatalf("expected compression disabled")
+	}
+}
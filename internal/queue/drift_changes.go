@@ -0,0 +1,157 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Drift states tracked per stack for change detection.
+const (
+	DriftStateHealthy = "healthy"
+	DriftStateDrifted = "drifted"
+	DriftStateError   = "error"
+)
+
+const (
+	keyDriftState   = "driftd:drift:state:"
+	keyDriftChanges = "driftd:drift:changes:"
+
+	// maxDriftChanges bounds the per-project change log.
+	maxDriftChanges = 1000
+)
+
+// DriftChange records a stack moving from one drift state to another.
+// Previous is empty the first time a stack is seen.
+type DriftChange struct {
+	StackPath string    `json:"stack_path"`
+	Previous  string    `json:"previous,omitempty"`
+	Current   string    `json:"current"`
+	ScanID    string    `json:"scan_id,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// driftStateSwapScript stores the new state for a stack and returns the one
+// it replaced.
+var driftStateSwapScript = redis.NewScript(`
+local prev = redis.call('HGET', KEYS[1], ARGV[1])
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+return prev or ''
+`)
+
+// recordDriftState updates the stack's last known drift state and appends a
+// change entry when it differs. A first observation only counts as a change
+// when the stack is not healthy.
+func (q *Queue) recordDriftState(ctx context.Context, stackScan *StackScan, state string) error {
+	if stackScan.ProjectName == "" || stackScan.StackPath == "" {
+		return nil
+	}
+	prev, err := driftStateSwapScript.Run(ctx, q.client,
+		[]string{keyDriftState + stackScan.ProjectName}, stackScan.StackPath, state).Text()
+	if err != nil {
+		return err
+	}
+	if prev == state || (prev == "" && state == DriftStateHealthy) {
+		return nil
+	}
+
+	change := DriftChange{
+		StackPath: stackScan.StackPath,
+		Previous:  prev,
+		Current:   state,
+		ScanID:    stackScan.ScanID,
+		ChangedAt: time.Now().UTC(),
+	}
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	key := keyDriftChanges + stackScan.ProjectName
+	pipe := q.client.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(change.ChangedAt.UnixMilli()), Member: data})
+	pipe.ZRemRangeByRank(ctx, key, 0, -int64(q.driftChangesCap())-1)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// DriftChangesSince returns the net drift change per stack after since.
+// Stacks that changed and then returned to their original state are omitted.
+// Changes made by skipScanID are ignored, which lets callers use a scan as
+// the reference point without its own transitions leaking in.
+func (q *Queue) DriftChangesSince(ctx context.Context, projectName string, since time.Time, skipScanID string) ([]DriftChange, error) {
+	raw, err := q.client.ZRangeByScore(ctx, keyDriftChanges+projectName, &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(since.UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	changes := decodeDriftChanges(raw)
+	if skipScanID != "" {
+		kept := changes[:0]
+		for _, change := range changes {
+			if change.ScanID != skipScanID {
+				kept = append(kept, change)
+			}
+		}
+		changes = kept
+	}
+	return netDriftChanges(changes, ""), nil
+}
+
+// ScanDriftChanges returns the net drift changes produced by a single scan.
+func (q *Queue) ScanDriftChanges(ctx context.Context, projectName, scanID string) ([]DriftChange, error) {
+	raw, err := q.client.ZRange(ctx, keyDriftChanges+projectName, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	return netDriftChanges(decodeDriftChanges(raw), scanID), nil
+}
+
+func decodeDriftChanges(raw []string) []DriftChange {
+	changes := make([]DriftChange, 0, len(raw))
+	for _, item := range raw {
+		var change DriftChange
+		if json.Unmarshal([]byte(item), &change) == nil {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// netDriftChanges collapses ordered changes into one entry per stack, keeping
+// the earliest previous state and the latest current state. When scanID is
+// set, only changes from that scan are considered.
+func netDriftChanges(changes []DriftChange, scanID string) []DriftChange {
+	byStack := map[string]*DriftChange{}
+	for _, change := range changes {
+		if scanID != "" && change.ScanID != scanID {
+			continue
+		}
+		if existing, ok := byStack[change.StackPath]; ok {
+			existing.Current = change.Current
+			existing.ScanID = change.ScanID
+			existing.ChangedAt = change.ChangedAt
+			continue
+		}
+		c := change
+		byStack[change.StackPath] = &c
+	}
+
+	out := make([]DriftChange, 0, len(byStack))
+	for _, change := range byStack {
+		if change.Previous == change.Current {
+			continue
+		}
+		if change.Previous == "" && change.Current == DriftStateHealthy {
+			continue
+		}
+		out = append(out, *change)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].StackPath < out[j].StackPath })
+	return out
+}
@@ -0,0 +1,130 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func runDriftScan(t *testing.T, q *Queue, results map[string]bool) *Scan {
+	t.Helper()
+	ctx := context.Background()
+
+	scan, err := q.StartScan(ctx, "project", "manual", "", "", len(results))
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	for stackPath := range results {
+		if err := q.Enqueue(ctx, &StackScan{ScanID: scan.ID, ProjectName: "project", ProjectURL: "file:///project", StackPath: stackPath}); err != nil {
+			t.Fatalf("enqueue %s: %v", stackPath, err)
+		}
+	}
+	for range results {
+		stackScan := dequeueStackScan(t, q)
+		if err := q.Complete(ctx, stackScan, results[stackScan.StackPath]); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+	}
+	return scan
+}
+
+func TestDriftChangesTracksTransitions(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+	start := time.Now().Add(-time.Second)
+
+	first := runDriftScan(t, q, map[string]bool{"envs/dev": true, "envs/prod": false})
+
+	changes, err := q.ScanDriftChanges(ctx, "project", first.ID)
+	if err != nil {
+		t.Fatalf("scan changes: %v", err)
+	}
+	if len(changes) != 1 || changes[0].StackPath != "envs/dev" || changes[0].Previous != "" || changes[0].Current != DriftStateDrifted {
+		t.Fatalf("unexpected first scan changes: %+v", changes)
+	}
+
+	sub := q.Client().Subscribe(ctx, projectEventsPrefix+"project")
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	second := runDriftScan(t, q, map[string]bool{"envs/dev": false, "envs/prod": true})
+
+	// The final scan_update carries the delta for that scan.
+	var final *ProjectEvent
+	deadline := time.After(2 * time.Second)
+	for final == nil {
+		select {
+		case msg := <-sub.Channel():
+			var event ProjectEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				t.Fatalf("decode event: %v", err)
+			}
+			if event.Type == "scan_update" && event.ScanID == second.ID && event.EndedAt != nil {
+				final = &event
+			}
+		case <-deadline:
+			t.Fatal("timed out waiting for completion event")
+		}
+	}
+	if len(final.DriftChanges) != 2 {
+		t.Fatalf("expected 2 drift changes on completion, got %+v", final.DriftChanges)
+	}
+
+	since, err := q.DriftChangesSince(ctx, "project", start, first.ID)
+	if err != nil {
+		t.Fatalf("changes since: %v", err)
+	}
+	want := map[string][2]string{
+		"envs/dev":  {DriftStateDrifted, DriftStateHealthy},
+		"envs/prod": {DriftStateHealthy, DriftStateDrifted},
+	}
+	if len(since) != len(want) {
+		t.Fatalf("unexpected changes since first scan: %+v", since)
+	}
+	for _, change := range since {
+		w := want[change.StackPath]
+		if change.Previous != w[0] || change.Current != w[1] || change.ScanID != second.ID {
+			t.Fatalf("unexpected change: %+v", change)
+		}
+	}
+
+	// Across both scans envs/dev went nowhere -> drifted -> healthy, which nets
+	// out, while envs/prod became drifted.
+	all, err := q.DriftChangesSince(ctx, "project", start, "")
+	if err != nil {
+		t.Fatalf("all changes: %v", err)
+	}
+	if len(all) != 1 || all[0].StackPath != "envs/prod" || all[0].Current != DriftStateDrifted {
+		t.Fatalf("unexpected net changes: %+v", all)
+	}
+}
+
+func TestDriftChangesRecordsFailures(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	runDriftScan(t, q, map[string]bool{"envs/dev": false})
+
+	scan, err := q.StartScan(ctx, "project", "manual", "", "", 1)
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	if err := q.Enqueue(ctx, &StackScan{ScanID: scan.ID, ProjectName: "project", ProjectURL: "file:///project", StackPath: "envs/dev"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	stackScan := dequeueStackScan(t, q)
+	if err := q.Fail(ctx, stackScan, "boom"); err != nil {
+		t.Fatalf("fail: %v", err)
+	}
+
+	changes, err := q.ScanDriftChanges(ctx, "project", scan.ID)
+	if err != nil {
+		t.Fatalf("scan changes: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Previous != DriftStateHealthy || changes[0].Current != DriftStateError {
+		t.Fatalf("unexpected changes: %+v", changes)
+	}
+}
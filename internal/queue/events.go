@@ -25,7 +25,22 @@ type ProjectEvent struct {
 	Failed      int        `json:"failed,omitempty"`
 	Total       int        `json:"total,omitempty"`
 	DriftedCnt  int        `json:"drifted_count,omitempty"`
-	Timestamp   time.Time  `json:"timestamp"`
+	// DriftChanges lists stacks whose drift state changed during the scan.
+	// It is only set on the final scan_update.
+	DriftChanges []DriftChange `json:"drift_changes,omitempty"`
+	Timestamp    time.Time     `json:"timestamp"`
+	// RootCauseHint is set on drifted stack updates whose plan matched a
+	// root-cause heuristic.
+	RootCauseHint string `json:"root_cause_hint,omitempty"`
+	// QueuedAt is when a stack scan reported running was enqueued, so its
+	// queue wait can be measured.
+	QueuedAt *time.Time `json:"queued_at,omitempty"`
+	// OutputChanges names the outputs a completed stack's plan would
+	// change, which other stacks and services may read.
+	OutputChanges []string `json:"output_changes,omitempty"`
+	// Frozen is set on drifted stack updates found while the stack's
+	// environment was frozen. Notifiers should not alert on them.
+	Frozen bool `json:"frozen,omitempty"`
 }
 
 type ScanEvent struct {
@@ -39,6 +54,8 @@ type ScanEvent struct {
 	DriftedCnt  int
 	StartedAt   *time.Time
 	EndedAt     *time.Time
+
+	DriftChanges []DriftChange
 }
 
 type StackEvent struct {
@@ -49,21 +66,27 @@ type StackEvent struct {
 	Drifted     *bool
 	Error       string
 	RunAt       *time.Time
+
+	RootCauseHint string
+	QueuedAt      *time.Time
+	OutputChanges []string
+	Frozen        bool
 }
 
 func (e ScanEvent) ToProjectEvent() ProjectEvent {
 	return ProjectEvent{
-		Type:        "scan_update",
-		ProjectName: e.ProjectName,
-		ScanID:      e.ScanID,
-		CommitSHA:   e.CommitSHA,
-		Status:      e.Status,
-		Completed:   e.Completed,
-		Failed:      e.Failed,
-		Total:       e.Total,
-		DriftedCnt:  e.DriftedCnt,
-		StartedAt:   e.StartedAt,
-		EndedAt:     e.EndedAt,
+		Type:         "scan_update",
+		ProjectName:  e.ProjectName,
+		ScanID:       e.ScanID,
+		CommitSHA:    e.CommitSHA,
+		Status:       e.Status,
+		Completed:    e.Completed,
+		Failed:       e.Failed,
+		Total:        e.Total,
+		DriftedCnt:   e.DriftedCnt,
+		StartedAt:    e.StartedAt,
+		EndedAt:      e.EndedAt,
+		DriftChanges: e.DriftChanges,
 	}
 }
 
@@ -77,6 +100,11 @@ func (e StackEvent) ToProjectEvent() ProjectEvent {
 		Drifted:     e.Drifted,
 		Error:       e.Error,
 		RunAt:       e.RunAt,
+
+		RootCauseHint: e.RootCauseHint,
+		QueuedAt:      e.QueuedAt,
+		OutputChanges: e.OutputChanges,
+		Frozen:        e.Frozen,
 	}
 }
 
@@ -92,6 +120,11 @@ func (q *Queue) PublishEvent(ctx context.Context, projectName string, event Proj
 	if err != nil {
 		return fmt.Errorf("marshal event: %w", err)
 	}
+	// Append to the outbox first so a failure is reported instead of the
+	// event only reaching live subscribers.
+	if err := q.appendOutbox(ctx, data); err != nil {
+		return fmt.Errorf("append outbox: %w", err)
+	}
 	return q.client.Publish(ctx, projectEventsPrefix+projectName, data).Err()
 }
 
@@ -99,6 +132,9 @@ func (q *Queue) PublishScanEvent(ctx context.Context, projectName string, event
 	if projectName == "" {
 		projectName = event.ProjectName
 	}
+	if event.Status == ScanStatusCanceled && event.ScanID != "" {
+		_ = q.publishScanCancel(ctx, projectName, event.ScanID)
+	}
 	return q.PublishEvent(ctx, projectName, event.ToProjectEvent())
 }
 
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// IdempotencyRecord is what a request carrying an Idempotency-Key leaves
+// behind, so a retry with the same key gets the original scan back.
+type IdempotencyRecord struct {
+	// Fingerprint identifies the request the key was first used with.
+	Fingerprint string `json:"fingerprint"`
+	// ScanID is empty while the first request is still being handled.
+	ScanID  string   `json:"scan_id,omitempty"`
+	Stacks  []string `json:"stacks,omitempty"`
+	Message string   `json:"message,omitempty"`
+}
+
+// claimIdempotencyScript returns the record stored at KEYS[1], or stores
+// ARGV[1] there for ARGV[2] milliseconds and returns nil.
+var claimIdempotencyScript = redis.NewScript(`
+local existing = redis.call('GET', KEYS[1])
+if existing then
+  return existing
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return false
+`)
+
+// ClaimIdempotencyKey claims key for a request with the given fingerprint.
+// It returns nil and true when the key was unused; the claim lapses after
+// ttl unless CompleteIdempotencyKey records the result. Otherwise it
+// returns the record already stored.
+func (q *Queue) ClaimIdempotencyKey(ctx context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	data, err := json.Marshal(IdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, false, err
+	}
+	existing, err := claimIdempotencyScript.Run(ctx, q.client, []string{keyIdempotencyPrefix + key}, data, ttl.Milliseconds()).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	var record IdempotencyRecord
+	if err := json.Unmarshal([]byte(existing), &record); err != nil {
+		return nil, false, err
+	}
+	return &record, false, nil
+}
+
+// CompleteIdempotencyKey stores the outcome of the request that claimed key
+// and keeps it for ttl.
+func (q *Queue) CompleteIdempotencyKey(ctx context.Context, key string, record IdempotencyRecord, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return q.client.Set(ctx, keyIdempotencyPrefix+key, data, ttl).Err()
+}
+
+// ReleaseIdempotencyKey frees a key whose request failed, so it can be
+// retried.
+func (q *Queue) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	return q.client.Del(ctx, keyIdempotencyPrefix+key).Err()
+}
@@ -0,0 +1,47 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBackendIdempotencyKeys(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+		record, claimed, err := q.ClaimIdempotencyKey(ctx, "infra:abc", "fp1", time.Minute)
+		if err != nil || !claimed || record != nil {
+			t.Fatalf("first claim: record=%v claimed=%v err=%v", record, claimed, err)
+		}
+
+		record, claimed, err = q.ClaimIdempotencyKey(ctx, "infra:abc", "fp2", time.Minute)
+		if err != nil || claimed {
+			t.Fatalf("second claim: claimed=%v err=%v", claimed, err)
+		}
+		if record.Fingerprint != "fp1" || record.ScanID != "" {
+			t.Fatalf("expected pending record for fp1, got %+v", record)
+		}
+
+		done := IdempotencyRecord{Fingerprint: "fp1", ScanID: "scan-1", Stacks: []string{"a"}, Message: "Enqueued 1 stacks"}
+		if err := q.CompleteIdempotencyKey(ctx, "infra:abc", done, time.Hour); err != nil {
+			t.Fatalf("complete: %v", err)
+		}
+		record, claimed, err = q.ClaimIdempotencyKey(ctx, "infra:abc", "fp1", time.Minute)
+		if err != nil || claimed {
+			t.Fatalf("claim after complete: claimed=%v err=%v", claimed, err)
+		}
+		if record.ScanID != "scan-1" || len(record.Stacks) != 1 || record.Message != done.Message {
+			t.Fatalf("unexpected record %+v", record)
+		}
+
+		if err := q.ReleaseIdempotencyKey(ctx, "infra:abc"); err != nil {
+			t.Fatalf("release: %v", err)
+		}
+		if _, claimed, err = q.ClaimIdempotencyKey(ctx, "infra:abc", "fp2", time.Minute); err != nil || !claimed {
+			t.Fatalf("expected claim after release: claimed=%v err=%v", claimed, err)
+		}
+		if err := q.ReleaseIdempotencyKey(ctx, "infra:missing"); err != nil {
+			t.Fatalf("release missing key: %v", err)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBackendSkipsUnsupportedJobVersions(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+		future := &StackScan{
+			ProjectName:   "project",
+			ProjectURL:    "file:///project",
+			StackPath:     "envs/prod",
+			SchemaVersion: JobSchemaVersion + 1,
+		}
+		if err := q.Enqueue(ctx, future); err != nil {
+			t.Fatalf("enqueue future job: %v", err)
+		}
+		current := &StackScan{
+			ProjectName: "project",
+			ProjectURL:  "file:///project",
+			StackPath:   "envs/dev",
+		}
+		if err := q.Enqueue(ctx, current); err != nil {
+			t.Fatalf("enqueue current job: %v", err)
+		}
+		if current.SchemaVersion != JobSchemaVersion {
+			t.Fatalf("expected enqueue to stamp version %d, got %d", JobSchemaVersion, current.SchemaVersion)
+		}
+
+		dequeueCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		job, err := q.Dequeue(dequeueCtx, "worker-1")
+		cancel()
+		if err != nil {
+			t.Fatalf("dequeue: %v", err)
+		}
+		if job.ID != current.ID {
+			t.Fatalf("expected supported job %s, got %s", current.ID, job.ID)
+		}
+
+		dequeueCtx, cancel = context.WithTimeout(ctx, time.Second)
+		_, err = q.Dequeue(dequeueCtx, "worker-1")
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected no claimable job, got %v", err)
+		}
+		stored, err := q.GetStackScan(ctx, future.ID)
+		if err != nil {
+			t.Fatalf("get future job: %v", err)
+		}
+		if stored.Status != StatusPending || stored.WorkerID != "" {
+			t.Fatalf("expected future job to stay pending, got %s on %q", stored.Status, stored.WorkerID)
+		}
+	})
+}
+
+func TestSupportsJobVersion(t *testing.T) {
+	if !SupportsJobVersion(0) || !SupportsJobVersion(JobSchemaVersion) {
+		t.Fatalf("expected legacy and current versions to be supported")
+	}
+	if SupportsJobVersion(JobSchemaVersion + 1) {
+		t.Fatalf("expected newer version to be unsupported")
+	}
+	versions := SupportedJobVersions()
+	if len(versions) == 0 || versions[len(versions)-1] != JobSchemaVersion {
+		t.Fatalf("unexpected supported versions %v", versions)
+	}
+}
@@ -0,0 +1,167 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Key families reported by KeyMemoryUsage.
+const (
+	KeyFamilyScans      = "scans"
+	KeyFamilyStackScans = "stack_scans"
+	KeyFamilyResults    = "results"
+	KeyFamilyQueues     = "queues"
+	KeyFamilyEvents     = "events"
+	KeyFamilyOther      = "other"
+)
+
+// DefaultMemorySamples is how many keys of each family KeyMemoryUsage
+// measures when the caller does not say.
+const DefaultMemorySamples = 50
+
+// keyFamilyPrefixes maps key prefixes to families. The first match wins, so
+// queue bookkeeping under the stack scan prefix is listed before it.
+var keyFamilyPrefixes = []struct {
+	prefix string
+	family string
+}{
+	{keyQueue, KeyFamilyQueues},
+	{keyPausedQueuePrefix, KeyFamilyQueues},
+	{keyPausedProjects, KeyFamilyQueues},
+	{keyStackScanPending, KeyFamilyQueues},
+	{keyStackScanInflight, KeyFamilyQueues},
+	{keyRunningStackScans, KeyFamilyQueues},
+	{keyClaimPrefix, KeyFamilyQueues},
+	{keyStackScanPrefix, KeyFamilyStackScans},
+	{keyProjectStackScans, KeyFamilyStackScans},
+	{keyScanStartsPrefix, KeyFamilyScans},
+	{keyScanPrefix, KeyFamilyScans},
+	{keyScanHistory, KeyFamilyScans},
+	{keyDriftState, KeyFamilyResults},
+	{keyDriftChanges, KeyFamilyResults},
+	{keyOutbox, KeyFamilyEvents},
+}
+
+// KeyFamilyUsage is the Redis memory used by one family of driftd keys.
+// Bytes are estimated from a sample of the family's keys, measured with
+// MEMORY USAGE, scaled up to the family's key count.
+type KeyFamilyUsage struct {
+	Family         string `json:"family"`
+	Keys           int64  `json:"keys"`
+	SampledKeys    int    `json:"sampled_keys"`
+	SampledBytes   int64  `json:"sampled_bytes"`
+	EstimatedBytes int64  `json:"estimated_bytes"`
+	// Retention describes what bounds the family, e.g. "168h0m0s".
+	Retention string `json:"retention,omitempty"`
+}
+
+// KeyMemoryReport is the memory used by driftd's keys, by family.
+type KeyMemoryReport struct {
+	Families       []KeyFamilyUsage `json:"families"`
+	TotalKeys      int64            `json:"total_keys"`
+	EstimatedBytes int64            `json:"estimated_bytes"`
+	// SamplesPerFamily is how many keys of each family were measured.
+	SamplesPerFamily int `json:"samples_per_family"`
+}
+
+// keyFamily returns the family a driftd key belongs to.
+func keyFamily(key string) string {
+	for _, p := range keyFamilyPrefixes {
+		if strings.HasPrefix(key, p.prefix) {
+			return p.family
+		}
+	}
+	return KeyFamilyOther
+}
+
+// KeyMemoryUsage walks every driftd key with SCAN and measures up to samples
+// keys per family. It reads every key name once, so on a large instance it
+// takes a while; it does not block Redis.
+func (q *Queue) KeyMemoryUsage(ctx context.Context, samples int) (*KeyMemoryReport, error) {
+	if samples <= 0 {
+		samples = DefaultMemorySamples
+	}
+	counts := map[string]int64{}
+	sampled := map[string][]string{}
+	var cursor uint64
+	for {
+		keys, next, err := q.client.Scan(ctx, cursor, "driftd:*", 1000).Result()
+		if err != nil {
+			return nil, fmt.Errorf("scan keys: %w", err)
+		}
+		for _, key := range keys {
+			family := keyFamily(key)
+			counts[family]++
+			if len(sampled[family]) < samples {
+				sampled[family] = append(sampled[family], key)
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+
+	report := &KeyMemoryReport{SamplesPerFamily: samples}
+	for _, family := range []string{KeyFamilyScans, KeyFamilyStackScans, KeyFamilyResults, KeyFamilyQueues, KeyFamilyEvents, KeyFamilyOther} {
+		usage := KeyFamilyUsage{Family: family, Keys: counts[family], Retention: q.familyRetention(family)}
+		if keys := sampled[family]; len(keys) > 0 {
+			bytes, measured, err := q.sampleMemory(ctx, keys)
+			if err != nil {
+				return nil, err
+			}
+			usage.SampledKeys = measured
+			usage.SampledBytes = bytes
+			if measured > 0 {
+				usage.EstimatedBytes = bytes * usage.Keys / int64(measured)
+			}
+		}
+		report.Families = append(report.Families, usage)
+		report.TotalKeys += usage.Keys
+		report.EstimatedBytes += usage.EstimatedBytes
+	}
+	return report, nil
+}
+
+// sampleMemory sums MEMORY USAGE over keys. Keys that expired since they
+// were listed are left out of the count.
+func (q *Queue) sampleMemory(ctx context.Context, keys []string) (int64, int, error) {
+	pipe := q.client.Pipeline()
+	cmds := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.MemoryUsage(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return 0, 0, fmt.Errorf("memory usage: %w", err)
+	}
+	var total int64
+	measured := 0
+	for _, cmd := range cmds {
+		n, err := cmd.Result()
+		if err != nil {
+			continue
+		}
+		total += n
+		measured++
+	}
+	return total, measured, nil
+}
+
+func (q *Queue) familyRetention(family string) string {
+	switch family {
+	case KeyFamilyScans:
+		return fmt.Sprintf("%s, %d per project indexed", q.scanTTL(), q.scanHistoryCap())
+	case KeyFamilyStackScans:
+		return q.stackScanTTL().String()
+	case KeyFamilyResults:
+		return fmt.Sprintf("%d drift changes per project", q.driftChangesCap())
+	case KeyFamilyEvents:
+		return fmt.Sprintf("%d outbox events", q.outboxCap())
+	case KeyFamilyQueues:
+		return "until processed"
+	}
+	return ""
+}
@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestKeyMemoryUsageByFamily(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+	runDriftScan(t, q, map[string]bool{"envs/dev": true, "envs/prod": false})
+	if err := q.client.Set(ctx, "unrelated:key", "x", 0).Err(); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	report, err := q.KeyMemoryUsage(ctx, 1)
+	if err != nil {
+		t.Fatalf("key memory usage: %v", err)
+	}
+	families := map[string]KeyFamilyUsage{}
+	for _, f := range report.Families {
+		families[f.Family] = f
+	}
+	if len(families) != 6 {
+		t.Fatalf("expected every family listed, got %+v", report.Families)
+	}
+	for _, name := range []string{KeyFamilyScans, KeyFamilyStackScans, KeyFamilyResults} {
+		f := families[name]
+		if f.Keys == 0 || f.SampledKeys != 1 || f.EstimatedBytes < f.SampledBytes || f.SampledBytes <= 0 {
+			t.Fatalf("unexpected %s usage: %+v", name, f)
+		}
+	}
+	var total int64
+	for _, f := range report.Families {
+		total += f.Keys
+	}
+	if report.TotalKeys != total {
+		t.Fatalf("total keys %d, want %d", report.TotalKeys, total)
+	}
+	if n, _ := q.client.DBSize(ctx).Result(); report.TotalKeys != n-1 {
+		t.Fatalf("expected only driftd keys counted: %d of %d", report.TotalKeys, n)
+	}
+}
+
+func TestKeyFamily(t *testing.T) {
+	for key, want := range map[string]string{
+		keyQueue:                              KeyFamilyQueues,
+		keyStackScanPending:                   KeyFamilyQueues,
+		keyStackScanInflight + "project:envs": KeyFamilyQueues,
+		keyStackScanPrefix + "project:envs:1": KeyFamilyStackScans,
+		keyProjectStackScansOrdered + "p":     KeyFamilyStackScans,
+		keyScanPrefix + "project:1":           KeyFamilyScans,
+		keyScanStartsPrefix + "global":        KeyFamilyScans,
+		keyDriftChanges + "project":           KeyFamilyResults,
+		keyOutboxOffsets:                      KeyFamilyEvents,
+		keyLockPrefix + "project":             KeyFamilyOther,
+	} {
+		if got := keyFamily(key); got != want {
+			t.Errorf("%s: family %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestRetentionOverrides(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+	q.SetRetention(Retention{StackScans: 2 * time.Hour, Scans: 3 * time.Hour, DriftChanges: 1})
+
+	scan := runDriftScan(t, q, map[string]bool{"envs/dev": true})
+	if ttl := q.client.TTL(ctx, keyScanPrefix+scan.ID).Val(); ttl <= 2*time.Hour || ttl > 3*time.Hour {
+		t.Fatalf("expected scan ttl near 3h, got %s", ttl)
+	}
+	stackScans, err := q.ListScanStackScans(ctx, scan.ID)
+	if err != nil || len(stackScans) != 1 {
+		t.Fatalf("list stack scans: %v %v", stackScans, err)
+	}
+	if ttl := q.client.TTL(ctx, keyStackScanPrefix+stackScans[0].ID).Val(); ttl <= time.Hour || ttl > 2*time.Hour {
+		t.Fatalf("expected stack scan ttl near 2h, got %s", ttl)
+	}
+
+	runDriftScan(t, q, map[string]bool{"envs/dev": false})
+	if n := q.client.ZCard(ctx, keyDriftChanges+"project").Val(); n != 1 {
+		t.Fatalf("expected drift changes capped at 1, got %d", n)
+	}
+}
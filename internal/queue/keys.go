@@ -13,11 +13,14 @@ const (
 	StatusCanceled  = "canceled"
 
 	keyQueue                    = "driftd:queue:workitems"
+	keyQueueHigh                = "driftd:queue:workitems:high"
+	keyQueueLow                 = "driftd:queue:workitems:low"
 	keyStackScanPrefix          = "driftd:stack_scan:"
 	keyStackScanInflight        = "driftd:stack_scan:inflight:"
 	keyStackScanPending         = "driftd:stack_scan:pending"
 	keyLockPrefix               = "driftd:lock:project:"
 	keyCloneLockPrefix          = "driftd:lock:clone:"
+	keyLeaderPrefix             = "driftd:leader:"
 	keyProjectStackScans        = "driftd:stack_scans:project:"
 	keyProjectStackScansOrdered = "driftd:stack_scans:project:ordered:"
 	keyRunningStackScans        = "driftd:stack_scans:running"
@@ -25,10 +28,16 @@ const (
 	keyScanRepo                 = "driftd:scan:project:"
 	keyScanStackScans           = "driftd:scan:stack_scans:"
 	keyScanLast                 = "driftd:scan:last:"
+	keyScanHistory              = "driftd:scans:history:"
 	keyRunningScans             = "driftd:scan:running"
+	keyScanStartsPrefix         = "driftd:scan_starts:"
+	keyIdempotencyPrefix        = "driftd:idempotency:"
+	keyRevokedSessionPrefix     = "driftd:session:revoked:"
 
 	stackScanRetention = 7 * 24 * time.Hour // 7 days
 	scanRetention      = 7 * 24 * time.Hour // 7 days
+	// scanHistoryLimit caps how many recent scans are indexed per project.
+	scanHistoryLimit = 200
 )
 
 var (
@@ -0,0 +1,71 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrLeaderLeaseNotOwned is returned when renewing or releasing a leader
+// lease held by another replica, or one that has expired.
+var ErrLeaderLeaseNotOwned = errors.New("leader lease not owned by caller")
+
+// LeaderLease describes the current holder of a leader lease.
+type LeaderLease struct {
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queue) AcquireLeaderLease(ctx context.Context, role, owner string, ttl time.Duration) (bool, error) {
+	return q.client.SetNX(ctx, keyLeaderPrefix+role, owner, ttl).Result()
+}
+
+// The lease scripts are the clone lock scripts: compare the owner, then
+// extend or delete.
+func (q *Queue) RenewLeaderLease(ctx context.Context, role, owner string, ttl time.Duration) error {
+	renewed, err := renewCloneLockScript.Run(ctx, q.client, []string{keyLeaderPrefix + role}, owner, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if renewed == 0 {
+		return ErrLeaderLeaseNotOwned
+	}
+	return nil
+}
+
+func (q *Queue) ReleaseLeaderLease(ctx context.Context, role, owner string) error {
+	released, err := releaseCloneLockScript.Run(ctx, q.client, []string{keyLeaderPrefix + role}, owner).Int64()
+	if err != nil {
+		return err
+	}
+	if released == 0 {
+		return ErrLeaderLeaseNotOwned
+	}
+	return nil
+}
+
+// GetLeaderLease returns the live lease for role, or nil when no replica
+// holds it.
+func (q *Queue) GetLeaderLease(ctx context.Context, role string) (*LeaderLease, error) {
+	key := keyLeaderPrefix + role
+	pipe := q.client.Pipeline()
+	ownerCmd := pipe.Get(ctx, key)
+	ttlCmd := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
+	}
+	owner, err := ownerCmd.Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	lease := &LeaderLease{Owner: owner}
+	if ttl := ttlCmd.Val(); ttl > 0 {
+		lease.ExpiresAt = time.Now().Add(ttl)
+	}
+	return lease, nil
+}
@@ -0,0 +1,43 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLeaderLeaseLifecycle(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	lease, err := q.GetLeaderLease(ctx, "scheduler")
+	if err != nil || lease != nil {
+		t.Fatalf("expected no lease, got %+v (%v)", lease, err)
+	}
+
+	if ok, err := q.AcquireLeaderLease(ctx, "scheduler", "replica-a", time.Minute); err != nil || !ok {
+		t.Fatalf("acquire: %v %v", ok, err)
+	}
+	if ok, err := q.AcquireLeaderLease(ctx, "scheduler", "replica-b", time.Minute); err != nil || ok {
+		t.Fatalf("expected second replica to wait: %v %v", ok, err)
+	}
+	if err := q.RenewLeaderLease(ctx, "scheduler", "replica-b", time.Minute); !errors.Is(err, ErrLeaderLeaseNotOwned) {
+		t.Fatalf("expected ErrLeaderLeaseNotOwned, got %v", err)
+	}
+
+	lease, err = q.GetLeaderLease(ctx, "scheduler")
+	if err != nil || lease == nil || lease.Owner != "replica-a" {
+		t.Fatalf("unexpected lease %+v (%v)", lease, err)
+	}
+	if until := time.Until(lease.ExpiresAt); until <= 0 || until > time.Minute {
+		t.Fatalf("unexpected lease expiry %s", lease.ExpiresAt)
+	}
+
+	if err := q.ReleaseLeaderLease(ctx, "scheduler", "replica-a"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if ok, err := q.AcquireLeaderLease(ctx, "scheduler", "replica-b", time.Minute); err != nil || !ok {
+		t.Fatalf("expected takeover after release: %v %v", ok, err)
+	}
+}
@@ -0,0 +1,392 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// memoryClaimPoll is how often a waiting Dequeue re-checks stack scans
+	// that could not be claimed, such as those held back by an expired
+	// claim or an unsupported job version.
+	memoryClaimPoll = time.Second
+	// memorySweepInterval limits how often expired state is dropped.
+	memorySweepInterval = time.Minute
+	// memorySubscriptionBuffer is how many undelivered messages a slow
+	// subscriber may fall behind before messages to it are dropped, like a
+	// Redis pub/sub client that stops reading.
+	memorySubscriptionBuffer = 64
+)
+
+// MemoryQueue is a Backend that keeps all state in maps in this process,
+// for standalone installs, benchmarks and tests. One mutex guards every
+// map, so each operation is atomic the way the Redis scripts are. State is
+// lost on exit and no other process can reach it.
+type MemoryQueue struct {
+	lockTTL time.Duration
+
+	mu           sync.Mutex
+	stackScans   map[string]*StackScan
+	scans        map[string]*Scan
+	work         map[int][]string // priority -> stack scan IDs, oldest first
+	locks        map[string]memoryLock
+	counters     map[string]memoryCounter
+	idempotency  map[string]memoryIdempotency
+	revoked      map[string]time.Time // session ID -> revocation expiry
+	active       map[string]string    // project -> running scan ID
+	last         map[string]string    // project -> last finished scan ID
+	pauses       map[string]ProjectPause
+	driftState   map[string]string // memoryKey(project, stack) -> drift state
+	driftChanges map[string][]DriftChange
+	workers      map[string]WorkerInfo
+	outbox       []OutboxEvent
+	outboxLast   outboxID
+	offsets      map[string]string
+	swept        time.Time
+	// wake is closed and replaced whenever a stack scan may have become
+	// claimable, so waiting Dequeue calls look again.
+	wake chan struct{}
+
+	subsMu sync.Mutex
+	subs   map[*memorySub]struct{}
+}
+
+var _ Backend = (*MemoryQueue)(nil)
+
+// NewMemory returns an empty in-process backend.
+func NewMemory(lockTTL time.Duration) *MemoryQueue {
+	return &MemoryQueue{
+		lockTTL:      lockTTL,
+		stackScans:   map[string]*StackScan{},
+		scans:        map[string]*Scan{},
+		work:         map[int][]string{},
+		locks:        map[string]memoryLock{},
+		counters:     map[string]memoryCounter{},
+		idempotency:  map[string]memoryIdempotency{},
+		revoked:      map[string]time.Time{},
+		active:       map[string]string{},
+		last:         map[string]string{},
+		pauses:       map[string]ProjectPause{},
+		driftState:   map[string]string{},
+		driftChanges: map[string][]DriftChange{},
+		workers:      map[string]WorkerInfo{},
+		offsets:      map[string]string{},
+		wake:         make(chan struct{}),
+		subs:         map[*memorySub]struct{}{},
+	}
+}
+
+func (m *MemoryQueue) Ping(ctx context.Context) error { return nil }
+
+// Close is a no-op; subscriptions end with their contexts.
+func (m *MemoryQueue) Close() error { return nil }
+
+func (m *MemoryQueue) QueueDepth(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var depth int64
+	for _, ids := range m.work {
+		depth += int64(len(ids))
+	}
+	return depth, nil
+}
+
+// memoryKey joins key parts with a byte that cannot appear in project
+// names or stack paths.
+func memoryKey(parts ...string) string {
+	return strings.Join(parts, "\x00")
+}
+
+func memoryProjectLockKey(projectName string) string { return memoryKey("project", projectName) }
+func memoryCloneLockKey(urlHash string) string       { return memoryKey("clone", urlHash) }
+func memoryClaimKey(stackScanID string) string       { return memoryKey("claim", stackScanID) }
+func memoryLeaderKey(role string) string             { return memoryKey("leader", role) }
+func memoryInflightKey(projectName, stackPath string) string {
+	return memoryKey("inflight", projectName, stackPath)
+}
+
+// memoryCopy deep-copies v through JSON, so callers never share state with
+// the maps, as with a value read back from Redis.
+func memoryCopy[T any](v *T) *T {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out T
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil
+	}
+	return &out
+}
+
+// signal wakes every waiting Dequeue. Callers hold m.mu.
+func (m *MemoryQueue) signal() {
+	close(m.wake)
+	m.wake = make(chan struct{})
+}
+
+// sweep drops expired locks, records and workers, and finished scans and
+// stack scans past their retention, at most once per memorySweepInterval.
+// Callers hold m.mu.
+func (m *MemoryQueue) sweep(now time.Time) {
+	if now.Sub(m.swept) < memorySweepInterval {
+		return
+	}
+	m.swept = now
+	for key, lock := range m.locks {
+		if !now.Before(lock.expiresAt) {
+			delete(m.locks, key)
+		}
+	}
+	for key, c := range m.counters {
+		if !now.Before(c.expiresAt) {
+			delete(m.counters, key)
+		}
+	}
+	for key, rec := range m.idempotency {
+		if !now.Before(rec.expiresAt) {
+			delete(m.idempotency, key)
+		}
+	}
+	for id, until := range m.revoked {
+		if !now.Before(until) {
+			delete(m.revoked, id)
+		}
+	}
+	for id, info := range m.workers {
+		if now.Sub(info.LastSeen) > WorkerStaleAfter {
+			delete(m.workers, id)
+		}
+	}
+	for id, ss := range m.stackScans {
+		if ss.Status != StatusPending && ss.Status != StatusRunning && now.Sub(ss.CompletedAt) > stackScanRetention {
+			delete(m.stackScans, id)
+		}
+	}
+	m.sweepScans(now)
+}
+
+// sweepScans drops finished scans past scanRetention, and the oldest
+// finished scans of projects with more than scanHistoryLimit.
+func (m *MemoryQueue) sweepScans(now time.Time) {
+	byProject := map[string][]*Scan{}
+	for id, scan := range m.scans {
+		if scan.Status == ScanStatusRunning || m.last[scan.ProjectName] == id {
+			continue
+		}
+		if now.Sub(scan.EndedAt) > scanRetention {
+			delete(m.scans, id)
+			continue
+		}
+		byProject[scan.ProjectName] = append(byProject[scan.ProjectName], scan)
+	}
+	for _, scans := range byProject {
+		if len(scans) <= scanHistoryLimit {
+			continue
+		}
+		sortScansNewestFirst(scans)
+		for _, scan := range scans[scanHistoryLimit:] {
+			delete(m.scans, scan.ID)
+		}
+	}
+}
+
+// memoryLock is a lock with its owner and expiry.
+type memoryLock struct {
+	owner     string
+	expiresAt time.Time
+}
+
+// liveLock returns the unexpired lock at key. Callers hold m.mu.
+func (m *MemoryQueue) liveLock(key string) (memoryLock, bool) {
+	lock, ok := m.locks[key]
+	if !ok || !time.Now().Before(lock.expiresAt) {
+		return memoryLock{}, false
+	}
+	return lock, true
+}
+
+// acquireLock is SET NX PX: it takes the lock when it is free or expired.
+func (m *MemoryQueue) acquireLock(key, owner string, ttl time.Duration) bool {
+	if _, held := m.liveLock(key); held {
+		return false
+	}
+	m.locks[key] = memoryLock{owner: owner, expiresAt: time.Now().Add(ttl)}
+	return true
+}
+
+// renewLock extends the lock if owner still holds it.
+func (m *MemoryQueue) renewLock(key, owner string, ttl time.Duration) bool {
+	lock, held := m.liveLock(key)
+	if !held || lock.owner != owner {
+		return false
+	}
+	m.locks[key] = memoryLock{owner: owner, expiresAt: time.Now().Add(ttl)}
+	return true
+}
+
+// releaseLock deletes the lock only if owner still holds it.
+func (m *MemoryQueue) releaseLock(key, owner string) bool {
+	lock, held := m.liveLock(key)
+	if !held || lock.owner != owner {
+		return false
+	}
+	delete(m.locks, key)
+	return true
+}
+
+func (m *MemoryQueue) IsProjectLocked(ctx context.Context, projectName string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, held := m.liveLock(memoryProjectLockKey(projectName))
+	return held, nil
+}
+
+func (m *MemoryQueue) AcquireCloneLock(ctx context.Context, urlHash, owner string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.acquireLock(memoryCloneLockKey(urlHash), owner, ttl), nil
+}
+
+func (m *MemoryQueue) RenewCloneLock(ctx context.Context, urlHash, owner string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.renewLock(memoryCloneLockKey(urlHash), owner, ttl) {
+		return ErrCloneLockNotOwned
+	}
+	return nil
+}
+
+func (m *MemoryQueue) ReleaseCloneLock(ctx context.Context, urlHash, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.releaseLock(memoryCloneLockKey(urlHash), owner) {
+		return ErrCloneLockNotOwned
+	}
+	return nil
+}
+
+func (m *MemoryQueue) AcquireLeaderLease(ctx context.Context, role, owner string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.acquireLock(memoryLeaderKey(role), owner, ttl), nil
+}
+
+func (m *MemoryQueue) RenewLeaderLease(ctx context.Context, role, owner string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.renewLock(memoryLeaderKey(role), owner, ttl) {
+		return ErrLeaderLeaseNotOwned
+	}
+	return nil
+}
+
+func (m *MemoryQueue) ReleaseLeaderLease(ctx context.Context, role, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.releaseLock(memoryLeaderKey(role), owner) {
+		return ErrLeaderLeaseNotOwned
+	}
+	return nil
+}
+
+func (m *MemoryQueue) GetLeaderLease(ctx context.Context, role string) (*LeaderLease, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lock, held := m.liveLock(memoryLeaderKey(role))
+	if !held {
+		return nil, nil
+	}
+	return &LeaderLease{Owner: lock.owner, ExpiresAt: lock.expiresAt}, nil
+}
+
+// memoryCounter is a scan start counter that expires as a whole.
+type memoryCounter struct {
+	count     int
+	expiresAt time.Time
+}
+
+func (m *MemoryQueue) AcquireScanStart(ctx context.Context, key string, limit int, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	c := m.counters[key]
+	if !now.Before(c.expiresAt) {
+		c = memoryCounter{}
+	}
+	if c.count >= limit {
+		return false, nil
+	}
+	if c.count == 0 {
+		c.expiresAt = now.Add(ttl)
+	}
+	c.count++
+	m.counters[key] = c
+	return true, nil
+}
+
+func (m *MemoryQueue) ReleaseScanStart(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.counters[key]
+	if !ok || !time.Now().Before(c.expiresAt) || c.count == 0 {
+		return nil
+	}
+	c.count--
+	m.counters[key] = c
+	return nil
+}
+
+// memoryIdempotency is an idempotency record with its expiry.
+type memoryIdempotency struct {
+	record    IdempotencyRecord
+	expiresAt time.Time
+}
+
+func (m *MemoryQueue) ClaimIdempotencyKey(ctx context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if existing, ok := m.idempotency[key]; ok && now.Before(existing.expiresAt) {
+		return memoryCopy(&existing.record), false, nil
+	}
+	m.idempotency[key] = memoryIdempotency{
+		record:    IdempotencyRecord{Fingerprint: fingerprint},
+		expiresAt: now.Add(ttl),
+	}
+	return nil, true, nil
+}
+
+func (m *MemoryQueue) CompleteIdempotencyKey(ctx context.Context, key string, record IdempotencyRecord, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.idempotency[key] = memoryIdempotency{record: *memoryCopy(&record), expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *MemoryQueue) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.idempotency, key)
+	return nil
+}
+
+func (m *MemoryQueue) RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.revoked[sessionID] = time.Now().Add(ttl)
+	return nil
+}
+
+func (m *MemoryQueue) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	until, ok := m.revoked[sessionID]
+	return ok && time.Now().Before(until), nil
+}
@@ -0,0 +1,310 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// memoryTopic names the channels subscribers listen on.
+type memoryTopic int
+
+const (
+	memoryTopicEvents memoryTopic = iota
+	memoryTopicScanCancels
+	memoryTopicWorkerCommands
+)
+
+// memorySub is one subscription. project filters events; "" receives every
+// project's.
+type memorySub struct {
+	topic   memoryTopic
+	project string
+	msgs    chan []byte
+}
+
+// publish hands data to every matching subscriber without blocking, and
+// returns how many there were.
+func (m *MemoryQueue) publish(topic memoryTopic, project string, data []byte) int64 {
+	m.subsMu.Lock()
+	defer m.subsMu.Unlock()
+	var receivers int64
+	for sub := range m.subs {
+		if sub.topic != topic || (sub.project != "" && sub.project != project) {
+			continue
+		}
+		receivers++
+		select {
+		case sub.msgs <- data:
+		default:
+		}
+	}
+	return receivers
+}
+
+// memorySubscribe decodes JSON messages on topic until ctx is canceled.
+func memorySubscribe[T any](ctx context.Context, m *MemoryQueue, topic memoryTopic, project string) <-chan T {
+	sub := &memorySub{topic: topic, project: project, msgs: make(chan []byte, memorySubscriptionBuffer)}
+	m.subsMu.Lock()
+	m.subs[sub] = struct{}{}
+	m.subsMu.Unlock()
+
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		defer func() {
+			m.subsMu.Lock()
+			delete(m.subs, sub)
+			m.subsMu.Unlock()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case data := <-sub.msgs:
+				var value T
+				if err := json.Unmarshal(data, &value); err != nil {
+					continue
+				}
+				select {
+				case out <- value:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+func (m *MemoryQueue) PublishEvent(ctx context.Context, projectName string, event ProjectEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.publishEvent(projectName, event)
+}
+
+// publishEvent records the event in the outbox and sends it to
+// subscribers. Callers hold m.mu.
+func (m *MemoryQueue) publishEvent(projectName string, event ProjectEvent) error {
+	if projectName == "" {
+		return nil
+	}
+	event.ProjectName = projectName
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	m.appendOutbox(event)
+	m.publish(memoryTopicEvents, projectName, data)
+	return nil
+}
+
+func (m *MemoryQueue) PublishScanEvent(ctx context.Context, projectName string, event ScanEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.publishScanEvent(projectName, event)
+}
+
+// publishScanEvent also announces canceled scans to workers. Callers hold
+// m.mu.
+func (m *MemoryQueue) publishScanEvent(projectName string, event ScanEvent) error {
+	if projectName == "" {
+		projectName = event.ProjectName
+	}
+	if event.Status == ScanStatusCanceled && event.ScanID != "" {
+		if data, err := json.Marshal(ScanCancel{ScanID: event.ScanID, ProjectName: projectName}); err == nil {
+			m.publish(memoryTopicScanCancels, "", data)
+		}
+	}
+	return m.publishEvent(projectName, event.ToProjectEvent())
+}
+
+func (m *MemoryQueue) PublishStackEvent(ctx context.Context, projectName string, event StackEvent) error {
+	if projectName == "" {
+		projectName = event.ProjectName
+	}
+	return m.PublishEvent(ctx, projectName, event.ToProjectEvent())
+}
+
+func (m *MemoryQueue) SubscribeProjectEvents(ctx context.Context, projectName string) (<-chan ProjectEvent, error) {
+	return memorySubscribe[ProjectEvent](ctx, m, memoryTopicEvents, projectName), nil
+}
+
+func (m *MemoryQueue) SubscribeScanCancels(ctx context.Context) (<-chan ScanCancel, error) {
+	return memorySubscribe[ScanCancel](ctx, m, memoryTopicScanCancels, ""), nil
+}
+
+func (m *MemoryQueue) SubscribeWorkerCommands(ctx context.Context) (<-chan WorkerCommand, error) {
+	return memorySubscribe[WorkerCommand](ctx, m, memoryTopicWorkerCommands, ""), nil
+}
+
+// appendOutbox records an event under the next ID, trimming the oldest
+// events past outboxMaxLen. Callers hold m.mu.
+func (m *MemoryQueue) appendOutbox(event ProjectEvent) {
+	id := outboxID{ms: uint64(time.Now().UnixMilli())}
+	if !id.after(m.outboxLast) {
+		id = outboxID{ms: m.outboxLast.ms, seq: m.outboxLast.seq + 1}
+	}
+	m.outboxLast = id
+	m.outbox = append(m.outbox, OutboxEvent{ID: id.String(), Event: event})
+	if len(m.outbox) > outboxMaxLen {
+		m.outbox = append([]OutboxEvent(nil), m.outbox[len(m.outbox)-outboxMaxLen:]...)
+	}
+}
+
+// ReadOutbox returns up to limit events published after the event ID after,
+// oldest first, for one project or for every project when projectName is
+// empty. next is the last ID examined, as with the Redis backend.
+func (m *MemoryQueue) ReadOutbox(ctx context.Context, projectName, after string, limit int) ([]OutboxEvent, string, error) {
+	if after == "" {
+		after = "0-0"
+	}
+	from, err := parseOutboxID(after)
+	if err != nil {
+		return nil, "", err
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	start := sort.Search(len(m.outbox), func(i int) bool {
+		id, _ := parseOutboxID(m.outbox[i].ID)
+		return id.after(from)
+	})
+	next := after
+	var events []OutboxEvent
+	for _, entry := range m.outbox[start:] {
+		if len(events) == limit {
+			break
+		}
+		next = entry.ID
+		if projectName != "" && entry.Event.ProjectName != projectName {
+			continue
+		}
+		events = append(events, entry)
+	}
+	return events, next, nil
+}
+
+// OutboxOffset returns the last event ID consumer committed, or "" when it
+// has not committed one.
+func (m *MemoryQueue) OutboxOffset(ctx context.Context, consumer string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.offsets[consumer], nil
+}
+
+// CommitOutboxOffset records that consumer has handled every event up to
+// and including id. Offsets only move forward.
+func (m *MemoryQueue) CommitOutboxOffset(ctx context.Context, consumer, id string) error {
+	next, err := parseOutboxID(id)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if current, ok := m.offsets[consumer]; ok {
+		if cur, err := parseOutboxID(current); err == nil && !next.after(cur) {
+			return nil
+		}
+	}
+	m.offsets[consumer] = id
+	return nil
+}
+
+// DeleteOutboxConsumer forgets consumer's offset.
+func (m *MemoryQueue) DeleteOutboxConsumer(ctx context.Context, consumer string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.offsets, consumer)
+	return nil
+}
+
+// OutboxStatus reports the retained range of the outbox and every
+// consumer's offset, sorted by name.
+func (m *MemoryQueue) OutboxStatus(ctx context.Context) (*OutboxStatus, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := &OutboxStatus{Length: int64(len(m.outbox)), Consumers: []OutboxConsumer{}}
+	if len(m.outbox) > 0 {
+		status.FirstID = m.outbox[0].ID
+		status.LastID = m.outbox[len(m.outbox)-1].ID
+	}
+	for name, offset := range m.offsets {
+		status.Consumers = append(status.Consumers, OutboxConsumer{
+			Name:     name,
+			Offset:   offset,
+			CaughtUp: status.LastID == "" || offset == status.LastID,
+		})
+	}
+	sort.Slice(status.Consumers, func(i, j int) bool { return status.Consumers[i].Name < status.Consumers[j].Name })
+	return status, nil
+}
+
+func (m *MemoryQueue) HeartbeatWorker(ctx context.Context, info WorkerInfo) error {
+	if info.LastSeen.IsZero() {
+		info.LastSeen = time.Now()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.workers[info.ID] = info
+	return nil
+}
+
+func (m *MemoryQueue) RemoveWorker(ctx context.Context, workerID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.workers, workerID)
+	return nil
+}
+
+func (m *MemoryQueue) ListWorkers(ctx context.Context) ([]WorkerInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	workers := make([]WorkerInfo, 0, len(m.workers))
+	for id, info := range m.workers {
+		if now.Sub(info.LastSeen) > WorkerStaleAfter {
+			delete(m.workers, id)
+			continue
+		}
+		workers = append(workers, info)
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].ID < workers[j].ID })
+	return workers, nil
+}
+
+// PublishWorkerCommand sends an admin command and returns how many
+// subscribers received it.
+func (m *MemoryQueue) PublishWorkerCommand(ctx context.Context, cmd WorkerCommand) (int64, error) {
+	if err := cmd.Validate(); err != nil {
+		return 0, err
+	}
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return 0, fmt.Errorf("marshal worker command: %w", err)
+	}
+	return m.publish(memoryTopicWorkerCommands, "", data), nil
+}
+
+// KeyMemoryUsage reports Redis key families; the in-process backend has
+// none.
+func (m *MemoryQueue) KeyMemoryUsage(ctx context.Context, samples int) (*KeyMemoryReport, error) {
+	return nil, fmt.Errorf("key memory usage: %w", ErrNotSupported)
+}
+
+// Snapshot and Restore are not implemented for the in-process backend,
+// whose state ends with the process anyway.
+func (m *MemoryQueue) Snapshot(ctx context.Context) (*Snapshot, error) {
+	return nil, fmt.Errorf("snapshot: %w", ErrNotSupported)
+}
+
+func (m *MemoryQueue) Restore(ctx context.Context, snap *Snapshot, overwrite bool) (RestoreStats, error) {
+	return RestoreStats{}, fmt.Errorf("restore: %w", ErrNotSupported)
+}
@@ -0,0 +1,401 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func (m *MemoryQueue) StartScan(ctx context.Context, projectName, trigger, commit, actor string, total int) (*Scan, error) {
+	if total < 0 {
+		total = 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(time.Now())
+
+	scanID := fmt.Sprintf("%s:%d", projectName, time.Now().UnixNano())
+	if !m.acquireLock(memoryProjectLockKey(projectName), scanID, m.lockTTL) {
+		return nil, ErrProjectLocked
+	}
+	return m.createScan(scanID, projectName, trigger, commit, actor, total), nil
+}
+
+// CancelAndStartScan hands the project lock from the old scan to the new one
+// and cancels the old scan, in one step like cancelAndAcquireScript.
+func (m *MemoryQueue) CancelAndStartScan(ctx context.Context, oldScanID, projectName, cancelReason, trigger, commit, actor string, total int) (*Scan, error) {
+	if total < 0 {
+		total = 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lockKey := memoryProjectLockKey(projectName)
+	lock, held := m.liveLock(lockKey)
+	if held && lock.owner != oldScanID {
+		return nil, ErrProjectLocked
+	}
+	newScanID := fmt.Sprintf("%s:%d", projectName, time.Now().UnixNano())
+	m.locks[lockKey] = memoryLock{owner: newScanID, expiresAt: time.Now().Add(m.lockTTL)}
+
+	endedAt := time.Unix(time.Now().Unix(), 0)
+	if old, ok := m.scans[oldScanID]; ok {
+		old.Status = ScanStatusCanceled
+		old.EndedAt = endedAt
+		old.Error = cancelReason
+	}
+	m.last[projectName] = oldScanID
+	m.publishScanEvent(projectName, ScanEvent{
+		ProjectName: projectName,
+		ScanID:      oldScanID,
+		Status:      ScanStatusCanceled,
+		EndedAt:     &endedAt,
+	})
+	return m.createScan(newScanID, projectName, trigger, commit, actor, total), nil
+}
+
+// createScan stores a running scan and makes it the project's active scan.
+// Callers hold m.mu and the project lock.
+func (m *MemoryQueue) createScan(scanID, projectName, trigger, commit, actor string, total int) *Scan {
+	now := time.Unix(time.Now().Unix(), 0)
+	scan := &Scan{
+		ID:          scanID,
+		ProjectName: projectName,
+		Trigger:     trigger,
+		Commit:      commit,
+		Actor:       actor,
+		Status:      ScanStatusRunning,
+		CreatedAt:   now,
+		StartedAt:   now,
+		EndedAt:     time.Unix(0, 0),
+		Total:       total,
+		Queued:      total,
+	}
+	m.scans[scanID] = scan
+	m.active[projectName] = scanID
+	return normalizeScan(memoryCopy(scan))
+}
+
+// updateScan applies fn to the stored scan. Callers hold m.mu.
+func (m *MemoryQueue) updateScan(scanID string, fn func(*Scan)) error {
+	scan, ok := m.scans[scanID]
+	if !ok {
+		return ErrScanNotFound
+	}
+	fn(scan)
+	return nil
+}
+
+// setScan locks m.mu around updateScan.
+func (m *MemoryQueue) setScan(scanID string, fn func(*Scan)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.updateScan(scanID, fn)
+}
+
+// getScan returns a copy of the stored scan. Callers hold m.mu.
+func (m *MemoryQueue) getScan(scanID string) (*Scan, error) {
+	scan, ok := m.scans[scanID]
+	if !ok {
+		return nil, ErrScanNotFound
+	}
+	return normalizeScan(memoryCopy(scan)), nil
+}
+
+func (m *MemoryQueue) GetScan(ctx context.Context, scanID string) (*Scan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.getScan(scanID)
+}
+
+func (m *MemoryQueue) GetActiveScan(ctx context.Context, projectName string) (*Scan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	scanID, ok := m.active[projectName]
+	if !ok {
+		return nil, ErrScanNotFound
+	}
+	return m.getScan(scanID)
+}
+
+func (m *MemoryQueue) GetLastScan(ctx context.Context, projectName string) (*Scan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	scanID, ok := m.last[projectName]
+	if !ok {
+		return nil, ErrScanNotFound
+	}
+	return m.getScan(scanID)
+}
+
+// ListProjectScans returns a project's most recent scans, newest first.
+func (m *MemoryQueue) ListProjectScans(ctx context.Context, projectName string, limit int) ([]*Scan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var scans []*Scan
+	for _, scan := range m.scans {
+		if scan.ProjectName == projectName {
+			scans = append(scans, normalizeScan(memoryCopy(scan)))
+		}
+	}
+	sortScansNewestFirst(scans)
+	if limit > 0 && len(scans) > limit {
+		scans = scans[:limit]
+	}
+	return scans, nil
+}
+
+func sortScansNewestFirst(scans []*Scan) {
+	sort.Slice(scans, func(i, j int) bool {
+		if !scans[i].StartedAt.Equal(scans[j].StartedAt) {
+			return scans[i].StartedAt.After(scans[j].StartedAt)
+		}
+		return scans[i].ID > scans[j].ID
+	})
+}
+
+func (m *MemoryQueue) SetScanVersions(ctx context.Context, scanID, tfVersion, tgVersion string, stackTF, stackTG map[string]string) error {
+	return m.setScan(scanID, func(s *Scan) {
+		s.TerraformVersion = tfVersion
+		s.TerragruntVersion = tgVersion
+		s.StackTFVersions = stackTF
+		s.StackTGVersions = stackTG
+	})
+}
+
+func (m *MemoryQueue) SetScanTotal(ctx context.Context, scanID string, total int) error {
+	return m.setScan(scanID, func(s *Scan) {
+		s.Total = total
+		s.Queued = total
+	})
+}
+
+func (m *MemoryQueue) SetScanWorkspace(ctx context.Context, scanID, workspacePath, commitSHA string) error {
+	return m.setScan(scanID, func(s *Scan) {
+		s.WorkspacePath = workspacePath
+		s.CommitSHA = commitSHA
+	})
+}
+
+func (m *MemoryQueue) SetScanCommitInfo(ctx context.Context, scanID string, info *storage.CommitInfo) error {
+	return m.setScan(scanID, func(s *Scan) {
+		s.CommitInfo = info
+	})
+}
+
+func (m *MemoryQueue) SetScanSkippedStale(ctx context.Context, scanID string, skipped int) error {
+	return m.setScan(scanID, func(s *Scan) {
+		s.SkippedStale = skipped
+	})
+}
+
+func (m *MemoryQueue) LinkScanRetry(ctx context.Context, scanID, retryScanID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.updateScan(scanID, func(s *Scan) { s.RetryScanID = retryScanID }); err != nil {
+		return err
+	}
+	return m.updateScan(retryScanID, func(s *Scan) { s.RetryOf = scanID })
+}
+
+func (m *MemoryQueue) SetScanWarnings(ctx context.Context, scanID string, warnings []ScanWarning) error {
+	return m.setScan(scanID, func(s *Scan) {
+		s.Warnings = warnings
+	})
+}
+
+func (m *MemoryQueue) RecordScanPhases(ctx context.Context, scanID string, phases ...ScanPhase) error {
+	if len(phases) == 0 {
+		return nil
+	}
+	return m.setScan(scanID, func(s *Scan) {
+		s.Phases = mergeScanPhases(s.Phases, phases)
+	})
+}
+
+func (m *MemoryQueue) FailScan(ctx context.Context, scanID, projectName, errMsg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.endScan(scanID, projectName, ScanStatusFailed, errMsg, false)
+	return nil
+}
+
+func (m *MemoryQueue) CancelScan(ctx context.Context, scanID, projectName, reason string) error {
+	if reason == "" {
+		reason = "canceled"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.endScan(scanID, projectName, ScanStatusCanceled, reason, true)
+	return nil
+}
+
+// endScan finishes a scan with status and releases its project. Callers
+// hold m.mu.
+func (m *MemoryQueue) endScan(scanID, projectName, status, errMsg string, setLast bool) {
+	endedAt := time.Now()
+	_ = m.updateScan(scanID, func(s *Scan) {
+		s.Status = status
+		s.EndedAt = endedAt
+		s.Error = errMsg
+	})
+	delete(m.active, projectName)
+	if setLast {
+		m.last[projectName] = scanID
+	}
+	m.releaseLock(memoryProjectLockKey(projectName), scanID)
+	m.publishScanEvent(projectName, ScanEvent{
+		ProjectName: projectName,
+		ScanID:      scanID,
+		Status:      status,
+		EndedAt:     &endedAt,
+	})
+}
+
+func (m *MemoryQueue) RenewScanLock(ctx context.Context, scanID, projectName string, maxAge, renewEvery time.Duration) {
+	start := time.Now()
+	if maxAge <= 0 {
+		maxAge = 6 * time.Hour
+	}
+	interval := renewEvery
+	if interval <= 0 {
+		interval = m.lockTTL / 3
+	}
+	if interval < scanRenewIntervalMin {
+		interval = scanRenewIntervalMin
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if time.Since(start) > maxAge {
+			_ = m.FailScan(context.Background(), scanID, projectName, "scan exceeded maximum duration")
+			return
+		}
+
+		m.mu.Lock()
+		renewed := false
+		if scan, ok := m.scans[scanID]; ok && scan.Status == ScanStatusRunning {
+			renewed = m.renewLock(memoryProjectLockKey(projectName), scanID, m.lockTTL)
+		}
+		m.mu.Unlock()
+		if !renewed {
+			return
+		}
+	}
+}
+
+// runScanTransition is the equivalent of scanTransitionScript: it applies
+// counter deltas and finishes the scan once every stack scan is done.
+// Callers hold m.mu.
+func (m *MemoryQueue) runScanTransition(scanID, projectName string, deltas ...any) error {
+	scan, ok := m.scans[scanID]
+	if !ok {
+		return ErrScanNotFound
+	}
+	applyScanDeltas(scan, deltas)
+
+	event := ScanEvent{
+		ProjectName: projectName,
+		ScanID:      scanID,
+		Completed:   scan.Completed,
+		Failed:      scan.Failed,
+		Total:       scan.Total,
+		DriftedCnt:  scan.Drifted,
+	}
+	if scan.Status == ScanStatusRunning && (scan.Total == 0 || scan.Completed+scan.Failed >= scan.Total) {
+		scan.Status = ScanStatusCompleted
+		if scan.Failed > 0 {
+			scan.Status = ScanStatusFailed
+		}
+		now := time.Unix(time.Now().Unix(), 0)
+		scan.EndedAt = now
+		m.releaseLock(memoryProjectLockKey(projectName), scanID)
+		delete(m.active, projectName)
+		m.last[projectName] = scanID
+		event.EndedAt = &now
+		event.DriftChanges = netDriftChanges(m.driftChangeLog(projectName), scanID)
+	}
+	event.Status = scan.Status
+	m.publishScanEvent(projectName, event)
+	return nil
+}
+
+// markScan runs a transition on a stack scan's scan. Callers hold m.mu.
+func (m *MemoryQueue) markScan(scanID string, deltas ...any) error {
+	scan, ok := m.scans[scanID]
+	if !ok {
+		return fmt.Errorf("failed to get project for scan %s: %w", scanID, ErrScanNotFound)
+	}
+	return m.runScanTransition(scanID, scan.ProjectName, deltas...)
+}
+
+func (m *MemoryQueue) AdjustScanCounters(ctx context.Context, scanID, projectName string, deltas ...any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.runScanTransition(scanID, projectName, deltas...)
+}
+
+// RecoverStaleScans finds running scans older than maxAge and marks them failed.
+func (m *MemoryQueue) RecoverStaleScans(ctx context.Context, maxAge time.Duration) (int, error) {
+	if maxAge <= 0 {
+		return 0, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cutoff := time.Now().Add(-maxAge)
+	recovered := 0
+	for _, scan := range m.runningScans() {
+		if scan.StartedAt.After(cutoff) {
+			continue
+		}
+		m.endScan(scan.ID, scan.ProjectName, ScanStatusFailed, "scan exceeded maximum duration", false)
+		recovered++
+	}
+	return recovered, nil
+}
+
+// RebuildRunningScansIndex has nothing to rebuild: running scans are found
+// by their status.
+func (m *MemoryQueue) RebuildRunningScansIndex(ctx context.Context) (int, error) {
+	return 0, nil
+}
+
+// runningScans returns the stored running scans. Callers hold m.mu.
+func (m *MemoryQueue) runningScans() []*Scan {
+	var scans []*Scan
+	for _, scan := range m.scans {
+		if scan.Status == ScanStatusRunning {
+			scans = append(scans, scan)
+		}
+	}
+	return scans
+}
+
+func (m *MemoryQueue) RunningScanCount(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.runningScans()), nil
+}
+
+func (m *MemoryQueue) OldestRunningScanAge(ctx context.Context) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var oldest time.Time
+	for _, scan := range m.runningScans() {
+		if oldest.IsZero() || scan.StartedAt.Before(oldest) {
+			oldest = scan.StartedAt
+		}
+	}
+	return pendingAge(oldest), nil
+}
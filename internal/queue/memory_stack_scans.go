@@ -0,0 +1,518 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// memoryPriorities is the order Dequeue takes the work lists in.
+var memoryPriorities = []int{PriorityHigh, PriorityNormal, PriorityLow}
+
+// memoryPriority maps a stack scan priority onto one of the work lists,
+// like queueKeyFor.
+func memoryPriority(priority int) int {
+	switch {
+	case priority >= PriorityHigh:
+		return PriorityHigh
+	case priority == PriorityLow:
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
+
+func (m *MemoryQueue) Enqueue(ctx context.Context, stackScan *StackScan) error {
+	stackScan.Status = StatusPending
+	stackScan.CreatedAt = time.Now()
+	if stackScan.SchemaVersion == 0 {
+		stackScan.SchemaVersion = JobSchemaVersion
+	}
+	if stackScan.ID == "" {
+		stackScan.ID = fmt.Sprintf("%s:%s:%d:%d", stackScan.ProjectName, stackScan.StackPath, stackScan.CreatedAt.UnixNano(), rand.Int31())
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(time.Now())
+	if !m.enqueueStackScan(stackScan) {
+		return ErrStackScanInflight
+	}
+	return nil
+}
+
+func (m *MemoryQueue) EnqueueBatch(ctx context.Context, stacks []*StackScan) (*EnqueueBatchResult, error) {
+	if len(stacks) == 0 {
+		return &EnqueueBatchResult{}, nil
+	}
+
+	now := time.Now()
+	for _, ss := range stacks {
+		ss.Status = StatusPending
+		ss.CreatedAt = now
+		if ss.SchemaVersion == 0 {
+			ss.SchemaVersion = JobSchemaVersion
+		}
+		if ss.ID == "" {
+			ss.ID = fmt.Sprintf("%s:%s:%d:%d", ss.ProjectName, ss.StackPath, now.UnixNano(), rand.Int31())
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweep(now)
+	result := &EnqueueBatchResult{}
+	for _, ss := range stacks {
+		if !m.enqueueStackScan(ss) {
+			result.Skipped++
+			continue
+		}
+		result.Enqueued = append(result.Enqueued, ss)
+	}
+	return result, nil
+}
+
+// enqueueStackScan takes the stack's inflight lock and queues the stack
+// scan. It reports false when the stack already has one in flight. Callers
+// hold m.mu.
+func (m *MemoryQueue) enqueueStackScan(stackScan *StackScan) bool {
+	if !m.acquireLock(memoryInflightKey(stackScan.ProjectName, stackScan.StackPath), stackScan.ID, stackScanRetention) {
+		return false
+	}
+	m.stackScans[stackScan.ID] = memoryCopy(stackScan)
+	m.push(stackScan)
+	return true
+}
+
+// push appends a stack scan to its work list and wakes waiting workers.
+// Callers hold m.mu.
+func (m *MemoryQueue) push(stackScan *StackScan) {
+	priority := memoryPriority(stackScan.Priority)
+	m.work[priority] = append(m.work[priority], stackScan.ID)
+	m.signal()
+}
+
+// Dequeue blocks until a stack scan is available and claims it.
+func (m *MemoryQueue) Dequeue(ctx context.Context, workerID string) (*StackScan, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		m.mu.Lock()
+		stackScan := m.claim(workerID)
+		wake := m.wake
+		m.mu.Unlock()
+		if stackScan != nil {
+			return stackScan, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-wake:
+		case <-time.After(memoryClaimPoll):
+		}
+	}
+}
+
+// claim takes the first claimable stack scan in priority order and marks it
+// running, skipping the same stack scans as dequeueClaimScript. Stack scans
+// that are gone or no longer pending are dropped from the lists. The scan's
+// running counter is raised in the same step as the concurrency check, so
+// two workers cannot both take the last slot. Callers hold m.mu.
+func (m *MemoryQueue) claim(workerID string) *StackScan {
+	for _, priority := range memoryPriorities {
+		ids := m.work[priority]
+		for i := 0; i < len(ids); i++ {
+			stackScan, ok := m.stackScans[ids[i]]
+			if !ok || stackScan.Status != StatusPending {
+				ids = append(ids[:i], ids[i+1:]...)
+				i--
+				continue
+			}
+			if !SupportsJobVersion(stackScan.SchemaVersion) {
+				continue
+			}
+			if _, paused := m.pauses[stackScan.ProjectName]; paused {
+				continue
+			}
+			if stackScan.ScanConcurrency > 0 && stackScan.ScanID != "" {
+				if scan, ok := m.scans[stackScan.ScanID]; ok && scan.Running >= stackScan.ScanConcurrency {
+					continue
+				}
+			}
+			if !m.acquireLock(memoryClaimKey(stackScan.ID), workerID, stackScanClaimTTL) {
+				continue
+			}
+			m.work[priority] = append(ids[:i], ids[i+1:]...)
+
+			stackScan.Status = StatusRunning
+			stackScan.StartedAt = time.Now()
+			stackScan.WorkerID = workerID
+			if stackScan.ScanID != "" {
+				_ = m.markScan(stackScan.ScanID, "running", 1, "queued", -1)
+			}
+			return memoryCopy(stackScan)
+		}
+		m.work[priority] = ids
+	}
+	return nil
+}
+
+// PauseProject stops workers claiming the project's stack scans until
+// ResumeProject.
+func (m *MemoryQueue) PauseProject(ctx context.Context, pause ProjectPause) error {
+	if pause.PausedAt.IsZero() {
+		pause.PausedAt = time.Now()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pauses[pause.Project] = pause
+	return nil
+}
+
+// ResumeProject clears the project's pause and wakes waiting workers.
+func (m *MemoryQueue) ResumeProject(ctx context.Context, projectName string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, paused := m.pauses[projectName]; !paused {
+		return false, nil
+	}
+	delete(m.pauses, projectName)
+	m.signal()
+	return true, nil
+}
+
+func (m *MemoryQueue) GetProjectPause(ctx context.Context, projectName string) (*ProjectPause, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pause, ok := m.pauses[projectName]
+	if !ok {
+		return nil, nil
+	}
+	return &pause, nil
+}
+
+func (m *MemoryQueue) ListPausedProjects(ctx context.Context) ([]ProjectPause, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pauses := make([]ProjectPause, 0, len(m.pauses))
+	for _, pause := range m.pauses {
+		pauses = append(pauses, pause)
+	}
+	sort.Slice(pauses, func(i, j int) bool { return pauses[i].Project < pauses[j].Project })
+	return pauses, nil
+}
+
+func (m *MemoryQueue) GetStackScan(ctx context.Context, stackScanID string) (*StackScan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stackScan, ok := m.stackScans[stackScanID]
+	if !ok {
+		return nil, ErrStackScanNotFound
+	}
+	return memoryCopy(stackScan), nil
+}
+
+// listStackScans returns copies of the stored stack scans that match keep.
+// Callers hold m.mu.
+func (m *MemoryQueue) listStackScans(keep func(*StackScan) bool) []*StackScan {
+	var stackScans []*StackScan
+	for _, stackScan := range m.stackScans {
+		if keep(stackScan) {
+			stackScans = append(stackScans, memoryCopy(stackScan))
+		}
+	}
+	return stackScans
+}
+
+// ListProjectStackScans returns the project's unfinished stack scans, newest
+// first.
+func (m *MemoryQueue) ListProjectStackScans(ctx context.Context, projectName string, limit int) ([]*StackScan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stackScans := m.listStackScans(func(ss *StackScan) bool {
+		return ss.ProjectName == projectName && (ss.Status == StatusPending || ss.Status == StatusRunning)
+	})
+	sort.Slice(stackScans, func(i, j int) bool {
+		if !stackScans[i].CreatedAt.Equal(stackScans[j].CreatedAt) {
+			return stackScans[i].CreatedAt.After(stackScans[j].CreatedAt)
+		}
+		return stackScans[i].ID > stackScans[j].ID
+	})
+	if limit > 0 && len(stackScans) > limit {
+		stackScans = stackScans[:limit]
+	}
+	return stackScans, nil
+}
+
+func (m *MemoryQueue) ListScanStackScans(ctx context.Context, scanID string) ([]*StackScan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.listStackScans(func(ss *StackScan) bool { return ss.ScanID == scanID }), nil
+}
+
+func (m *MemoryQueue) ListRunningStackScans(ctx context.Context) ([]*StackScan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stackScans := m.listStackScans(func(ss *StackScan) bool { return ss.Status == StatusRunning })
+	sort.Slice(stackScans, func(i, j int) bool {
+		if !stackScans[i].StartedAt.Equal(stackScans[j].StartedAt) {
+			return stackScans[i].StartedAt.Before(stackScans[j].StartedAt)
+		}
+		return stackScans[i].ID < stackScans[j].ID
+	})
+	return stackScans, nil
+}
+
+// ListPendingStackScans returns the unclaimed stack scans oldest first.
+// Stack scans of paused projects are left out.
+func (m *MemoryQueue) ListPendingStackScans(ctx context.Context) ([]*StackScan, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stackScans := m.listStackScans(m.claimablePending)
+	sort.Slice(stackScans, func(i, j int) bool {
+		if !stackScans[i].CreatedAt.Equal(stackScans[j].CreatedAt) {
+			return stackScans[i].CreatedAt.Before(stackScans[j].CreatedAt)
+		}
+		return stackScans[i].ID < stackScans[j].ID
+	})
+	return stackScans, nil
+}
+
+// claimablePending reports a pending stack scan of a project that is not
+// paused. Callers hold m.mu.
+func (m *MemoryQueue) claimablePending(ss *StackScan) bool {
+	if ss.Status != StatusPending {
+		return false
+	}
+	_, paused := m.pauses[ss.ProjectName]
+	return !paused
+}
+
+// finish stores a stack scan that reached a final status and drops its
+// claim and inflight lock. Callers hold m.mu.
+func (m *MemoryQueue) finish(stackScan *StackScan) {
+	m.stackScans[stackScan.ID] = memoryCopy(stackScan)
+	delete(m.locks, memoryClaimKey(stackScan.ID))
+	delete(m.locks, memoryInflightKey(stackScan.ProjectName, stackScan.StackPath))
+	m.signal()
+}
+
+func (m *MemoryQueue) CancelStackScan(ctx context.Context, stackScan *StackScan, reason string) error {
+	stackScan.Status = StatusCanceled
+	stackScan.CompletedAt = time.Now()
+	stackScan.Error = reason
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.finish(stackScan)
+	return nil
+}
+
+func (m *MemoryQueue) Complete(ctx context.Context, stackScan *StackScan, drifted bool) error {
+	stackScan.Status = StatusCompleted
+	stackScan.CompletedAt = time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.finish(stackScan)
+	state := DriftStateHealthy
+	if drifted {
+		state = DriftStateDrifted
+	}
+	m.recordDriftState(stackScan, state)
+	if stackScan.ScanID != "" {
+		deltas := []any{"running", -1, "completed", 1}
+		if drifted {
+			deltas = append(deltas, "drifted", 1)
+		}
+		return m.markScan(stackScan.ScanID, deltas...)
+	}
+	return nil
+}
+
+func (m *MemoryQueue) Fail(ctx context.Context, stackScan *StackScan, errMsg string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.fail(stackScan, errMsg)
+}
+
+// fail re-queues the stack scan while it has retries left and fails it
+// otherwise. Callers hold m.mu.
+func (m *MemoryQueue) fail(stackScan *StackScan, errMsg string) error {
+	stackScan.Error = errMsg
+	stackScan.Retries++
+
+	if stackScan.Retries <= stackScan.MaxRetries {
+		stackScan.Status = StatusPending
+		stackScan.StartedAt = time.Time{}
+		stackScan.WorkerID = ""
+		m.stackScans[stackScan.ID] = memoryCopy(stackScan)
+		// Drop the claim so the retry can be claimed by a worker.
+		delete(m.locks, memoryClaimKey(stackScan.ID))
+		if stackScan.ScanID != "" {
+			if err := m.markScan(stackScan.ScanID, "running", -1, "queued", 1); err != nil {
+				return err
+			}
+		}
+		m.push(stackScan)
+		return nil
+	}
+
+	stackScan.Status = StatusFailed
+	stackScan.CompletedAt = time.Now()
+	m.finish(stackScan)
+	m.recordDriftState(stackScan, DriftStateError)
+	if stackScan.ScanID != "" {
+		return m.markScan(stackScan.ScanID, "running", -1, "failed", 1, "errored", 1)
+	}
+	return nil
+}
+
+// ClearInflightForScan removes inflight markers for all stack scans belonging to a scan.
+func (m *MemoryQueue) ClearInflightForScan(ctx context.Context, scanID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, stackScan := range m.stackScans {
+		if stackScan.ScanID == scanID {
+			delete(m.locks, memoryInflightKey(stackScan.ProjectName, stackScan.StackPath))
+		}
+	}
+}
+
+// RecoverOrphanedStackScans re-queues pending stack scans missing from the
+// work lists. The lists live beside the stack scans, so there are normally
+// none.
+func (m *MemoryQueue) RecoverOrphanedStackScans(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	queued := map[string]bool{}
+	for _, ids := range m.work {
+		for _, id := range ids {
+			queued[id] = true
+		}
+	}
+	recovered := 0
+	for id, stackScan := range m.stackScans {
+		if stackScan.Status != StatusPending || queued[id] {
+			continue
+		}
+		m.acquireLock(memoryInflightKey(stackScan.ProjectName, stackScan.StackPath), id, stackScanRetention)
+		m.push(stackScan)
+		recovered++
+	}
+	return recovered, nil
+}
+
+// RecoverStaleStackScans finds running stack scans older than maxAge and
+// marks them as failed (or re-queued if retries remain).
+func (m *MemoryQueue) RecoverStaleStackScans(ctx context.Context, maxAge time.Duration) (int, error) {
+	if maxAge <= 0 {
+		return 0, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cutoff := time.Now().Add(-maxAge)
+	recovered := 0
+	for _, stackScan := range m.listStackScans(func(ss *StackScan) bool { return ss.Status == StatusRunning }) {
+		if stackScan.StartedAt.After(cutoff) {
+			continue
+		}
+		if err := m.fail(stackScan, "stale stack scan exceeded max age"); err != nil {
+			continue
+		}
+		recovered++
+	}
+	return recovered, nil
+}
+
+func (m *MemoryQueue) RunningStackScanCount(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, stackScan := range m.stackScans {
+		if stackScan.Status == StatusRunning {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (m *MemoryQueue) OldestRunningStackScanAge(ctx context.Context) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var oldest time.Time
+	for _, stackScan := range m.stackScans {
+		if stackScan.Status == StatusRunning && (oldest.IsZero() || stackScan.StartedAt.Before(oldest)) {
+			oldest = stackScan.StartedAt
+		}
+	}
+	return pendingAge(oldest), nil
+}
+
+func (m *MemoryQueue) OldestPendingStackScanAge(ctx context.Context) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var oldest time.Time
+	for _, stackScan := range m.stackScans {
+		if m.claimablePending(stackScan) && (oldest.IsZero() || stackScan.CreatedAt.Before(oldest)) {
+			oldest = stackScan.CreatedAt
+		}
+	}
+	return pendingAge(oldest), nil
+}
+
+// recordDriftState swaps the stack's drift state and appends a change entry
+// when the state moved. Callers hold m.mu.
+func (m *MemoryQueue) recordDriftState(stackScan *StackScan, state string) {
+	if stackScan.ProjectName == "" || stackScan.StackPath == "" {
+		return
+	}
+	key := memoryKey(stackScan.ProjectName, stackScan.StackPath)
+	prev := m.driftState[key]
+	m.driftState[key] = state
+	if prev == state || (prev == "" && state == DriftStateHealthy) {
+		return
+	}
+
+	changes := append(m.driftChanges[stackScan.ProjectName], DriftChange{
+		StackPath: stackScan.StackPath,
+		Previous:  prev,
+		Current:   state,
+		ScanID:    stackScan.ScanID,
+		ChangedAt: time.Now().UTC(),
+	})
+	if len(changes) > maxDriftChanges {
+		changes = changes[len(changes)-maxDriftChanges:]
+	}
+	m.driftChanges[stackScan.ProjectName] = changes
+}
+
+// driftChangeLog returns a copy of the project's drift changes, oldest
+// first. Callers hold m.mu.
+func (m *MemoryQueue) driftChangeLog(projectName string) []DriftChange {
+	return append([]DriftChange(nil), m.driftChanges[projectName]...)
+}
+
+func (m *MemoryQueue) DriftChangesSince(ctx context.Context, projectName string, since time.Time, skipScanID string) ([]DriftChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var kept []DriftChange
+	for _, change := range m.driftChangeLog(projectName) {
+		if change.ChangedAt.UnixMilli() <= since.UnixMilli() {
+			continue
+		}
+		if skipScanID != "" && change.ScanID == skipScanID {
+			continue
+		}
+		kept = append(kept, change)
+	}
+	return netDriftChanges(kept, ""), nil
+}
+
+func (m *MemoryQueue) ScanDriftChanges(ctx context.Context, projectName, scanID string) ([]DriftChange, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return netDriftChanges(m.driftChangeLog(projectName), scanID), nil
+}
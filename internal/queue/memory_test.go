@@ -0,0 +1,141 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newMemoryQueue(t *testing.T) *MemoryQueue {
+	t.Helper()
+	q := NewMemory(time.Minute)
+	t.Cleanup(func() { _ = q.Close() })
+	return q
+}
+
+func TestMemoryQueueScanFlow(t *testing.T) {
+	q := newMemoryQueue(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := q.SubscribeProjectEvents(ctx, "project")
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	scan, err := q.StartScan(ctx, "project", "manual", "", "", 1)
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	job := &StackScan{
+		ScanID:      scan.ID,
+		ProjectName: "project",
+		ProjectURL:  "file:///project",
+		StackPath:   "envs/dev",
+	}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := q.Complete(ctx, dequeueWithin(t, q, "worker"), true); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+
+	final, err := q.GetScan(ctx, scan.ID)
+	if err != nil {
+		t.Fatalf("get scan: %v", err)
+	}
+	if final.Status != ScanStatusCompleted || final.Drifted != 1 {
+		t.Fatalf("expected completed scan with 1 drifted, got %s/%d", final.Status, final.Drifted)
+	}
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event.Type == "scan_update" && event.Status == ScanStatusCompleted {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("did not receive completed scan event")
+		}
+	}
+}
+
+func TestMemoryQueueScanConcurrencyUnderContention(t *testing.T) {
+	q := newMemoryQueue(t)
+	ctx := context.Background()
+
+	scan, err := q.StartScan(ctx, "project", "manual", "", "", 4)
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	for _, stack := range []string{"a", "b", "c", "d"} {
+		if err := q.Enqueue(ctx, &StackScan{ScanID: scan.ID, ProjectName: "project", StackPath: stack, ScanConcurrency: 1}); err != nil {
+			t.Fatalf("enqueue %s: %v", stack, err)
+		}
+	}
+
+	claimCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	var mu sync.Mutex
+	claimed := 0
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := q.Dequeue(claimCtx, "worker"); err == nil {
+				mu.Lock()
+				claimed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if claimed != 1 {
+		t.Fatalf("expected 1 claim under a concurrency of 1, got %d", claimed)
+	}
+}
+
+func TestMemoryQueueSweepDropsExpiredState(t *testing.T) {
+	q := newMemoryQueue(t)
+	ctx := context.Background()
+
+	scan, err := q.StartScan(ctx, "project", "manual", "", "", 0)
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	if err := q.AdjustScanCounters(ctx, scan.ID, "project"); err != nil {
+		t.Fatalf("finish scan: %v", err)
+	}
+	latest, err := q.StartScan(ctx, "project", "manual", "", "", 0)
+	if err != nil {
+		t.Fatalf("start second scan: %v", err)
+	}
+	if err := q.CancelScan(ctx, latest.ID, "project", ""); err != nil {
+		t.Fatalf("cancel scan: %v", err)
+	}
+	if _, err := q.AcquireCloneLock(ctx, "hash", "owner", time.Millisecond); err != nil {
+		t.Fatalf("clone lock: %v", err)
+	}
+
+	q.mu.Lock()
+	q.scans[scan.ID].EndedAt = time.Now().Add(-scanRetention - time.Hour)
+	time.Sleep(2 * time.Millisecond)
+	q.swept = time.Time{}
+	q.sweep(time.Now())
+	_, keptOld := q.scans[scan.ID]
+	_, keptLock := q.locks[memoryCloneLockKey("hash")]
+	q.mu.Unlock()
+
+	if keptOld {
+		t.Fatalf("expected scan past retention to be dropped")
+	}
+	if keptLock {
+		t.Fatalf("expected expired clone lock to be dropped")
+	}
+	if _, err := q.GetLastScan(ctx, "project"); err != nil {
+		t.Fatalf("expected last scan to be kept: %v", err)
+	}
+}
@@ -0,0 +1,692 @@
+package queue
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// ErrNotSupported is returned for operations a backend does not implement.
+var ErrNotSupported = errors.New("not supported by this queue backend")
+
+// NATSOptions configures the JetStream backend.
+type NATSOptions struct {
+	URL       string
+	CredsFile string
+	// Prefix names every stream, bucket and subject the backend creates.
+	Prefix   string
+	Replicas int
+}
+
+// NATSQueue is a Backend on NATS JetStream. Stack scan IDs flow through a
+// work-queue stream and project events through an outbox stream; scans,
+// stack scans, locks and indexes live in KV buckets. The Redis Lua scripts
+// become compare-and-swap loops on KV revisions, and TTL'd Redis keys become
+// lock records carrying their own expiry, so lock semantics depend on
+// reasonably synchronized clocks.
+type NATSQueue struct {
+	nc      *nats.Conn
+	js      jetstream.JetStream
+	lockTTL time.Duration
+	prefix  string
+
+	work       jetstream.Consumer
+	workStream jetstream.Stream
+	outbox     jetstream.Stream // project events, as the Redis outbox stream
+
+	stackScans jetstream.KeyValue // stack scan ID -> StackScan
+	scans      jetstream.KeyValue // scan ID -> Scan
+	locks      jetstream.KeyValue // project, clone, claim and inflight locks; scan start counters
+	index      jetstream.KeyValue // pending/running sets, project indexes, scan pointers
+	state      jetstream.KeyValue // drift state, drift changes, worker registry, project pauses
+}
+
+var _ Backend = (*NATSQueue)(nil)
+
+const (
+	natsWorkConsumer  = "workers"
+	natsAckWait       = time.Minute
+	natsClaimBackoff  = 5 * time.Second
+	natsCASAttempts   = 50
+	natsSetupTimeout  = 10 * time.Second
+	stackScanClaimTTL = 30 * time.Minute
+)
+
+// NewNATS connects to NATS and creates the stream, consumer and buckets the
+// backend needs if they do not exist yet.
+func NewNATS(opts NATSOptions, lockTTL time.Duration) (*NATSQueue, error) {
+	if opts.Prefix == "" {
+		opts.Prefix = "driftd"
+	}
+	if opts.Replicas < 1 {
+		opts.Replicas = 1
+	}
+	connectOpts := []nats.Option{nats.Name("driftd"), nats.MaxReconnects(-1)}
+	if opts.CredsFile != "" {
+		connectOpts = append(connectOpts, nats.UserCredentials(opts.CredsFile))
+	}
+	nc, err := nats.Connect(opts.URL, connectOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to nats: %w", err)
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, fmt.Errorf("failed to open jetstream: %w", err)
+	}
+
+	n := &NATSQueue{nc: nc, js: js, lockTTL: lockTTL, prefix: opts.Prefix}
+	ctx, cancel := context.WithTimeout(context.Background(), natsSetupTimeout)
+	defer cancel()
+	if err := n.setup(ctx, opts.Replicas); err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *NATSQueue) setup(ctx context.Context, replicas int) error {
+	var err error
+	n.workStream, err = n.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:      n.prefix + "_work",
+		Subjects:  []string{n.workSubject()},
+		Retention: jetstream.WorkQueuePolicy,
+		Storage:   jetstream.FileStorage,
+		Replicas:  replicas,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create work stream: %w", err)
+	}
+	n.work, err = n.workStream.CreateOrUpdateConsumer(ctx, jetstream.ConsumerConfig{
+		Durable:       natsWorkConsumer,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       natsAckWait,
+		MaxAckPending: -1,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create work consumer: %w", err)
+	}
+	n.outbox, err = n.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:      n.prefix + "_outbox",
+		Subjects:  []string{n.prefix + ".outbox.*"},
+		Retention: jetstream.LimitsPolicy,
+		MaxMsgs:   outboxMaxLen,
+		Discard:   jetstream.DiscardOld,
+		Storage:   jetstream.FileStorage,
+		Replicas:  replicas,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create outbox stream: %w", err)
+	}
+
+	buckets := []struct {
+		kv     *jetstream.KeyValue
+		name   string
+		maxAge time.Duration
+	}{
+		{&n.stackScans, "stack_scans", stackScanRetention},
+		{&n.scans, "scans", scanRetention},
+		{&n.locks, "locks", stackScanRetention},
+		{&n.index, "index", scanRetention},
+		{&n.state, "state", 0},
+	}
+	for _, b := range buckets {
+		kv, err := n.js.CreateOrUpdateKeyValue(ctx, jetstream.KeyValueConfig{
+			Bucket:   n.prefix + "_" + b.name,
+			TTL:      b.maxAge,
+			Storage:  jetstream.FileStorage,
+			Replicas: replicas,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create %s bucket: %w", b.name, err)
+		}
+		*b.kv = kv
+	}
+	return nil
+}
+
+func (n *NATSQueue) Close() error {
+	n.nc.Close()
+	return nil
+}
+
+// Ping checks the connection with a server round trip.
+func (n *NATSQueue) Ping(ctx context.Context) error {
+	return natsFlush(ctx, n.nc)
+}
+
+// natsFlush waits for the server to process everything sent so far.
+// FlushWithContext refuses contexts without a deadline, so those get the
+// client library's default timeout.
+func natsFlush(ctx context.Context, nc *nats.Conn) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, nats.DefaultTimeout)
+		defer cancel()
+	}
+	return nc.FlushWithContext(ctx)
+}
+
+func (n *NATSQueue) QueueDepth(ctx context.Context) (int64, error) {
+	info, err := n.workStream.Info(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return int64(info.State.Msgs), nil
+}
+
+func (n *NATSQueue) workSubject() string {
+	return n.prefix + ".work"
+}
+
+func (n *NATSQueue) eventsSubject(projectName string) string {
+	return n.prefix + ".events." + natsToken(projectName)
+}
+
+func (n *NATSQueue) outboxSubject(projectName string) string {
+	return n.prefix + ".outbox." + natsToken(projectName)
+}
+
+func (n *NATSQueue) workerAdminSubject() string {
+	return n.prefix + ".workers.admin"
+}
+
+func (n *NATSQueue) scanCancelSubject() string {
+	return n.prefix + ".scans.canceled"
+}
+
+// natsToken encodes an arbitrary string as a single KV key or subject token.
+func natsToken(s string) string {
+	if s == "" {
+		return "="
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(s))
+}
+
+func natsUntoken(token string) string {
+	if token == "=" {
+		return ""
+	}
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+func natsKey(kind string, parts ...string) string {
+	var b strings.Builder
+	b.WriteString(kind)
+	for _, p := range parts {
+		b.WriteByte('.')
+		b.WriteString(natsToken(p))
+	}
+	return b.String()
+}
+
+// Lock keys.
+func natsProjectLockKey(projectName string) string { return natsKey("project", projectName) }
+func natsCloneLockKey(urlHash string) string       { return natsKey("clone", urlHash) }
+func natsClaimKey(stackScanID string) string       { return natsKey("claim", stackScanID) }
+func natsLeaderKey(role string) string             { return natsKey("leader", role) }
+func natsScanStartsKey(key string) string          { return natsKey("scan_starts", key) }
+func natsIdempotencyKey(key string) string         { return natsKey("idempotency", key) }
+func natsRevokedSessionKey(id string) string       { return natsKey("revoked_session", id) }
+func natsInflightKey(projectName, stackPath string) string {
+	return natsKey("inflight", projectName, stackPath)
+}
+
+// Index keys.
+func natsPendingKey(id string) string         { return natsKey("pending", id) }
+func natsRunningStackKey(id string) string    { return natsKey("running_stack", id) }
+func natsRunningScanKey(scanID string) string { return natsKey("running_scan", scanID) }
+func natsActiveScanKey(projectName string) string {
+	return natsKey("active", projectName)
+}
+func natsLastScanKey(projectName string) string { return natsKey("last", projectName) }
+func natsProjectStackScanKey(projectName, id string) string {
+	return natsKey("project", projectName, id)
+}
+func natsScanStackScanKey(scanID, id string) string { return natsKey("scan", scanID, id) }
+func natsScanHistoryKey(projectName, scanID string) string {
+	return natsKey("history", projectName, scanID)
+}
+
+// State keys.
+func natsDriftStateKey(projectName, stackPath string) string {
+	return natsKey("drift", projectName, stackPath)
+}
+func natsDriftChangesKey(projectName string) string { return natsKey("changes", projectName) }
+func natsWorkerKey(workerID string) string          { return natsKey("worker", workerID) }
+func natsPauseKey(projectName string) string        { return natsKey("paused", projectName) }
+func natsOutboxOffsetKey(consumer string) string    { return natsKey("outbox_offset", consumer) }
+
+func isKeyMissing(err error) bool {
+	return errors.Is(err, jetstream.ErrKeyNotFound) || errors.Is(err, jetstream.ErrKeyDeleted)
+}
+
+// isWrongRevision reports a failed compare-and-swap.
+func isWrongRevision(err error) bool {
+	if errors.Is(err, jetstream.ErrKeyExists) {
+		return true
+	}
+	var apiErr *jetstream.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode == jetstream.JSErrCodeStreamWrongLastSequence
+}
+
+// listKeys returns the keys of kv matching filter, or nil when none match.
+func listKeys(ctx context.Context, kv jetstream.KeyValue, filter string) ([]string, error) {
+	lister, err := kv.ListKeysFiltered(ctx, filter)
+	if err != nil {
+		if errors.Is(err, jetstream.ErrNoKeysFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var keys []string
+	for key := range lister.Keys() {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// lastToken returns the decoded final token of a key.
+func lastToken(key string) string {
+	if i := strings.LastIndexByte(key, '.'); i >= 0 {
+		return natsUntoken(key[i+1:])
+	}
+	return ""
+}
+
+func deleteKey(ctx context.Context, kv jetstream.KeyValue, key string) error {
+	if err := kv.Delete(ctx, key); err != nil && !isKeyMissing(err) {
+		return err
+	}
+	return nil
+}
+
+// updateJSON applies fn to the JSON value at key with optimistic
+// concurrency, retrying when another writer got there first. fn returns
+// false to leave the value unchanged. When create is set a missing key
+// starts from the zero value; otherwise notFound is returned.
+func updateJSON[T any](ctx context.Context, kv jetstream.KeyValue, key string, create bool, notFound error, fn func(*T) bool) (*T, error) {
+	for attempt := 0; attempt < natsCASAttempts; attempt++ {
+		var value T
+		var revision uint64
+		entry, err := kv.Get(ctx, key)
+		switch {
+		case err == nil:
+			if err := json.Unmarshal(entry.Value(), &value); err != nil {
+				return nil, fmt.Errorf("decode %s: %w", key, err)
+			}
+			revision = entry.Revision()
+		case isKeyMissing(err) && create:
+		case isKeyMissing(err):
+			return nil, notFound
+		default:
+			return nil, err
+		}
+
+		if !fn(&value) {
+			return &value, nil
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if revision == 0 {
+			_, err = kv.Create(ctx, key, data)
+		} else {
+			_, err = kv.Update(ctx, key, data, revision)
+		}
+		if err == nil {
+			return &value, nil
+		}
+		if !isWrongRevision(err) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("update %s: too many concurrent writers", key)
+}
+
+func getJSON[T any](ctx context.Context, kv jetstream.KeyValue, key string, notFound error) (*T, error) {
+	entry, err := kv.Get(ctx, key)
+	if err != nil {
+		if isKeyMissing(err) {
+			return nil, notFound
+		}
+		return nil, err
+	}
+	var value T
+	if err := json.Unmarshal(entry.Value(), &value); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", key, err)
+	}
+	return &value, nil
+}
+
+func putJSON(ctx context.Context, kv jetstream.KeyValue, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = kv.Put(ctx, key, data)
+	return err
+}
+
+// natsLock is the value stored for every lock. Locks expire at ExpiresAt
+// (Unix milliseconds) rather than through a per-key TTL.
+type natsLock struct {
+	Owner     string `json:"owner"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+func (l natsLock) expired(now time.Time) bool {
+	return l.ExpiresAt > 0 && now.UnixMilli() >= l.ExpiresAt
+}
+
+func newNATSLock(owner string, ttl time.Duration) []byte {
+	data, _ := json.Marshal(natsLock{Owner: owner, ExpiresAt: time.Now().Add(ttl).UnixMilli()})
+	return data
+}
+
+// getLock returns the live lock at key and its revision. ok is false when
+// the key is missing or the lock has expired.
+func (n *NATSQueue) getLock(ctx context.Context, key string) (lock natsLock, revision uint64, ok bool, err error) {
+	entry, err := n.locks.Get(ctx, key)
+	if err != nil {
+		if isKeyMissing(err) {
+			return natsLock{}, 0, false, nil
+		}
+		return natsLock{}, 0, false, err
+	}
+	if err := json.Unmarshal(entry.Value(), &lock); err != nil {
+		return natsLock{}, entry.Revision(), false, nil
+	}
+	return lock, entry.Revision(), !lock.expired(time.Now()), nil
+}
+
+// acquireLock is SET NX PX: it takes the lock when it is free or expired.
+func (n *NATSQueue) acquireLock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	for attempt := 0; attempt < natsCASAttempts; attempt++ {
+		_, revision, live, err := n.getLock(ctx, key)
+		if err != nil {
+			return false, err
+		}
+		if live {
+			return false, nil
+		}
+		if revision == 0 {
+			_, err = n.locks.Create(ctx, key, newNATSLock(owner, ttl))
+		} else {
+			_, err = n.locks.Update(ctx, key, newNATSLock(owner, ttl), revision)
+		}
+		if err == nil {
+			return true, nil
+		}
+		if !isWrongRevision(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// renewLock extends the lock if owner still holds it.
+func (n *NATSQueue) renewLock(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	lock, revision, live, err := n.getLock(ctx, key)
+	if err != nil || !live || lock.Owner != owner {
+		return false, err
+	}
+	if _, err := n.locks.Update(ctx, key, newNATSLock(owner, ttl), revision); err != nil {
+		if isWrongRevision(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// releaseLock deletes the lock only if owner still holds it.
+func (n *NATSQueue) releaseLock(ctx context.Context, key, owner string) (bool, error) {
+	lock, revision, live, err := n.getLock(ctx, key)
+	if err != nil || !live || lock.Owner != owner {
+		return false, err
+	}
+	if err := n.locks.Delete(ctx, key, jetstream.LastRevision(revision)); err != nil {
+		if isWrongRevision(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// transferLock hands a lock from one owner to another in a single
+// compare-and-swap.
+func (n *NATSQueue) transferLock(ctx context.Context, key, from, to string, ttl time.Duration) (bool, error) {
+	lock, revision, live, err := n.getLock(ctx, key)
+	if err != nil || !live || lock.Owner != from {
+		return false, err
+	}
+	if _, err := n.locks.Update(ctx, key, newNATSLock(to, ttl), revision); err != nil {
+		if isWrongRevision(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (n *NATSQueue) IsProjectLocked(ctx context.Context, projectName string) (bool, error) {
+	_, _, live, err := n.getLock(ctx, natsProjectLockKey(projectName))
+	return live, err
+}
+
+func (n *NATSQueue) AcquireCloneLock(ctx context.Context, urlHash, owner string, ttl time.Duration) (bool, error) {
+	return n.acquireLock(ctx, natsCloneLockKey(urlHash), owner, ttl)
+}
+
+func (n *NATSQueue) RenewCloneLock(ctx context.Context, urlHash, owner string, ttl time.Duration) error {
+	renewed, err := n.renewLock(ctx, natsCloneLockKey(urlHash), owner, ttl)
+	if err != nil {
+		return err
+	}
+	if !renewed {
+		return ErrCloneLockNotOwned
+	}
+	return nil
+}
+
+func (n *NATSQueue) ReleaseCloneLock(ctx context.Context, urlHash, owner string) error {
+	released, err := n.releaseLock(ctx, natsCloneLockKey(urlHash), owner)
+	if err != nil {
+		return err
+	}
+	if !released {
+		return ErrCloneLockNotOwned
+	}
+	return nil
+}
+
+func (n *NATSQueue) AcquireLeaderLease(ctx context.Context, role, owner string, ttl time.Duration) (bool, error) {
+	return n.acquireLock(ctx, natsLeaderKey(role), owner, ttl)
+}
+
+func (n *NATSQueue) RenewLeaderLease(ctx context.Context, role, owner string, ttl time.Duration) error {
+	renewed, err := n.renewLock(ctx, natsLeaderKey(role), owner, ttl)
+	if err != nil {
+		return err
+	}
+	if !renewed {
+		return ErrLeaderLeaseNotOwned
+	}
+	return nil
+}
+
+func (n *NATSQueue) ReleaseLeaderLease(ctx context.Context, role, owner string) error {
+	released, err := n.releaseLock(ctx, natsLeaderKey(role), owner)
+	if err != nil {
+		return err
+	}
+	if !released {
+		return ErrLeaderLeaseNotOwned
+	}
+	return nil
+}
+
+func (n *NATSQueue) GetLeaderLease(ctx context.Context, role string) (*LeaderLease, error) {
+	lock, _, live, err := n.getLock(ctx, natsLeaderKey(role))
+	if err != nil || !live {
+		return nil, err
+	}
+	return &LeaderLease{Owner: lock.Owner, ExpiresAt: time.UnixMilli(lock.ExpiresAt)}, nil
+}
+
+// natsCounter is a scan start counter in the locks bucket. Like locks it
+// expires at ExpiresAt (Unix milliseconds).
+type natsCounter struct {
+	Count     int   `json:"count"`
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// getCounter returns the live counter at key and its revision; an expired
+// or missing counter reads as zero.
+func (n *NATSQueue) getCounter(ctx context.Context, key string) (natsCounter, uint64, error) {
+	entry, err := n.locks.Get(ctx, key)
+	if err != nil {
+		if isKeyMissing(err) {
+			return natsCounter{}, 0, nil
+		}
+		return natsCounter{}, 0, err
+	}
+	var c natsCounter
+	if json.Unmarshal(entry.Value(), &c) != nil || (c.ExpiresAt > 0 && time.Now().UnixMilli() >= c.ExpiresAt) {
+		return natsCounter{}, entry.Revision(), nil
+	}
+	return c, entry.Revision(), nil
+}
+
+// updateCounter applies fn to the counter at key with compare-and-swap.
+// fn returns false to leave the counter unchanged.
+func (n *NATSQueue) updateCounter(ctx context.Context, key string, fn func(*natsCounter) bool) (bool, error) {
+	for attempt := 0; attempt < natsCASAttempts; attempt++ {
+		c, revision, err := n.getCounter(ctx, key)
+		if err != nil {
+			return false, err
+		}
+		if !fn(&c) {
+			return false, nil
+		}
+		data, _ := json.Marshal(c)
+		if revision == 0 {
+			_, err = n.locks.Create(ctx, key, data)
+		} else {
+			_, err = n.locks.Update(ctx, key, data, revision)
+		}
+		if err == nil {
+			return true, nil
+		}
+		if !isWrongRevision(err) {
+			return false, err
+		}
+	}
+	return false, fmt.Errorf("counter %s: too many concurrent updates", key)
+}
+
+func (n *NATSQueue) AcquireScanStart(ctx context.Context, key string, limit int, ttl time.Duration) (bool, error) {
+	return n.updateCounter(ctx, natsScanStartsKey(key), func(c *natsCounter) bool {
+		if c.Count >= limit {
+			return false
+		}
+		if c.Count == 0 {
+			c.ExpiresAt = time.Now().Add(ttl).UnixMilli()
+		}
+		c.Count++
+		return true
+	})
+}
+
+func (n *NATSQueue) ReleaseScanStart(ctx context.Context, key string) error {
+	_, err := n.updateCounter(ctx, natsScanStartsKey(key), func(c *natsCounter) bool {
+		if c.Count == 0 {
+			return false
+		}
+		c.Count--
+		return true
+	})
+	return err
+}
+
+// natsIdempotency is an idempotency record in the locks bucket. Like locks
+// it expires at ExpiresAt (Unix milliseconds).
+type natsIdempotency struct {
+	Record    IdempotencyRecord `json:"record"`
+	ExpiresAt int64             `json:"expires_at"`
+}
+
+func (n *NATSQueue) ClaimIdempotencyKey(ctx context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	k := natsIdempotencyKey(key)
+	data, err := json.Marshal(natsIdempotency{
+		Record:    IdempotencyRecord{Fingerprint: fingerprint},
+		ExpiresAt: time.Now().Add(ttl).UnixMilli(),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	for attempt := 0; attempt < natsCASAttempts; attempt++ {
+		entry, err := n.locks.Get(ctx, k)
+		switch {
+		case err == nil:
+			var existing natsIdempotency
+			if json.Unmarshal(entry.Value(), &existing) == nil && time.Now().UnixMilli() < existing.ExpiresAt {
+				return &existing.Record, false, nil
+			}
+			_, err = n.locks.Update(ctx, k, data, entry.Revision())
+		case isKeyMissing(err):
+			_, err = n.locks.Create(ctx, k, data)
+		default:
+			return nil, false, err
+		}
+		if err == nil {
+			return nil, true, nil
+		}
+		if !isWrongRevision(err) {
+			return nil, false, err
+		}
+	}
+	return nil, false, fmt.Errorf("idempotency key %s: too many concurrent updates", key)
+}
+
+func (n *NATSQueue) CompleteIdempotencyKey(ctx context.Context, key string, record IdempotencyRecord, ttl time.Duration) error {
+	return putJSON(ctx, n.locks, natsIdempotencyKey(key), natsIdempotency{
+		Record:    record,
+		ExpiresAt: time.Now().Add(ttl).UnixMilli(),
+	})
+}
+
+func (n *NATSQueue) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	return deleteKey(ctx, n.locks, natsIdempotencyKey(key))
+}
+
+// RevokeSession records a revoked session in the locks bucket until the
+// session would have expired anyway.
+func (n *NATSQueue) RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	return putJSON(ctx, n.locks, natsRevokedSessionKey(sessionID), natsLock{
+		Owner:     sessionID,
+		ExpiresAt: time.Now().Add(ttl).UnixMilli(),
+	})
+}
+
+func (n *NATSQueue) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	lock, err := getJSON[natsLock](ctx, n.locks, natsRevokedSessionKey(sessionID), nil)
+	if err != nil || lock == nil {
+		return false, err
+	}
+	return time.Now().UnixMilli() < lock.ExpiresAt, nil
+}
@@ -0,0 +1,343 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	natsSubscriptionBuffer = 64
+	// natsOutboxReaderIdle is how long the server keeps a ReadOutbox
+	// consumer that was not deleted, e.g. after a dropped connection.
+	natsOutboxReaderIdle = time.Minute
+)
+
+func (n *NATSQueue) PublishEvent(ctx context.Context, projectName string, event ProjectEvent) error {
+	if projectName == "" {
+		return nil
+	}
+	event.ProjectName = projectName
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+	// Append to the outbox first so a failure is reported instead of the
+	// event only reaching live subscribers.
+	if _, err := n.js.Publish(ctx, n.outboxSubject(projectName), data); err != nil {
+		return fmt.Errorf("append outbox: %w", err)
+	}
+	return n.nc.Publish(n.eventsSubject(projectName), data)
+}
+
+func (n *NATSQueue) PublishScanEvent(ctx context.Context, projectName string, event ScanEvent) error {
+	if projectName == "" {
+		projectName = event.ProjectName
+	}
+	if event.Status == ScanStatusCanceled && event.ScanID != "" {
+		if data, err := json.Marshal(ScanCancel{ScanID: event.ScanID, ProjectName: projectName}); err == nil {
+			_ = n.nc.Publish(n.scanCancelSubject(), data)
+		}
+	}
+	return n.PublishEvent(ctx, projectName, event.ToProjectEvent())
+}
+
+func (n *NATSQueue) PublishStackEvent(ctx context.Context, projectName string, event StackEvent) error {
+	if projectName == "" {
+		projectName = event.ProjectName
+	}
+	return n.PublishEvent(ctx, projectName, event.ToProjectEvent())
+}
+
+func (n *NATSQueue) SubscribeProjectEvents(ctx context.Context, projectName string) (<-chan ProjectEvent, error) {
+	subject := n.prefix + ".events.*"
+	if projectName != "" {
+		subject = n.eventsSubject(projectName)
+	}
+	return natsSubscribe[ProjectEvent](ctx, n.nc, subject)
+}
+
+func (n *NATSQueue) SubscribeWorkerCommands(ctx context.Context) (<-chan WorkerCommand, error) {
+	return natsSubscribe[WorkerCommand](ctx, n.nc, n.workerAdminSubject())
+}
+
+func (n *NATSQueue) SubscribeScanCancels(ctx context.Context) (<-chan ScanCancel, error) {
+	return natsSubscribe[ScanCancel](ctx, n.nc, n.scanCancelSubject())
+}
+
+// natsSubscribe decodes JSON messages on subject until ctx is canceled. The
+// returned channel is ready once the server has confirmed the subscription.
+func natsSubscribe[T any](ctx context.Context, nc *nats.Conn, subject string) (<-chan T, error) {
+	msgs := make(chan *nats.Msg, natsSubscriptionBuffer)
+	sub, err := nc.ChanSubscribe(subject, msgs)
+	if err != nil {
+		return nil, err
+	}
+	if err := natsFlush(ctx, nc); err != nil {
+		_ = sub.Unsubscribe()
+		return nil, err
+	}
+
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		defer sub.Unsubscribe()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-msgs:
+				var value T
+				if err := json.Unmarshal(msg.Data, &value); err != nil {
+					continue
+				}
+				select {
+				case out <- value:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (n *NATSQueue) HeartbeatWorker(ctx context.Context, info WorkerInfo) error {
+	if info.LastSeen.IsZero() {
+		info.LastSeen = time.Now()
+	}
+	return putJSON(ctx, n.state, natsWorkerKey(info.ID), info)
+}
+
+func (n *NATSQueue) RemoveWorker(ctx context.Context, workerID string) error {
+	return deleteKey(ctx, n.state, natsWorkerKey(workerID))
+}
+
+func (n *NATSQueue) ListWorkers(ctx context.Context) ([]WorkerInfo, error) {
+	keys, err := listKeys(ctx, n.state, "worker.*")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	workers := make([]WorkerInfo, 0, len(keys))
+	for _, key := range keys {
+		info, err := getJSON[WorkerInfo](ctx, n.state, key, ErrNotSupported)
+		if err != nil || now.Sub(info.LastSeen) > WorkerStaleAfter {
+			_ = deleteKey(ctx, n.state, key)
+			continue
+		}
+		workers = append(workers, *info)
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].ID < workers[j].ID })
+	return workers, nil
+}
+
+// PublishWorkerCommand sends an admin command. NATS does not report how many
+// subscribers received a message, so the count is the number of live workers
+// in the registry.
+func (n *NATSQueue) PublishWorkerCommand(ctx context.Context, cmd WorkerCommand) (int64, error) {
+	if err := cmd.Validate(); err != nil {
+		return 0, err
+	}
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return 0, fmt.Errorf("marshal worker command: %w", err)
+	}
+	if err := n.nc.Publish(n.workerAdminSubject(), data); err != nil {
+		return 0, err
+	}
+	if err := natsFlush(ctx, n.nc); err != nil {
+		return 0, err
+	}
+	workers, err := n.ListWorkers(ctx)
+	if err != nil {
+		return 0, nil
+	}
+	return int64(len(workers)), nil
+}
+
+// KeyMemoryUsage reports the JetStream storage behind each key family.
+// Streams and buckets know their own size, so nothing is sampled and the
+// bytes are exact; samples is ignored. Keys counts stored messages,
+// including KV history and delete markers not yet purged.
+func (n *NATSQueue) KeyMemoryUsage(ctx context.Context, samples int) (*KeyMemoryReport, error) {
+	families := []struct {
+		family    string
+		retention string
+		streams   []jetstream.Stream
+		buckets   []jetstream.KeyValue
+	}{
+		{family: KeyFamilyScans, retention: scanRetention.String(), buckets: []jetstream.KeyValue{n.scans}},
+		{family: KeyFamilyStackScans, retention: stackScanRetention.String(), buckets: []jetstream.KeyValue{n.stackScans}},
+		{family: KeyFamilyResults, buckets: []jetstream.KeyValue{n.state}},
+		{family: KeyFamilyQueues, retention: "until processed", streams: []jetstream.Stream{n.workStream}, buckets: []jetstream.KeyValue{n.index}},
+		{family: KeyFamilyEvents, retention: fmt.Sprintf("%d outbox events", outboxMaxLen), streams: []jetstream.Stream{n.outbox}},
+		{family: KeyFamilyOther, buckets: []jetstream.KeyValue{n.locks}},
+	}
+
+	report := &KeyMemoryReport{}
+	for _, f := range families {
+		usage := KeyFamilyUsage{Family: f.family, Retention: f.retention}
+		for _, stream := range f.streams {
+			info, err := stream.Info(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("key memory usage: %w", err)
+			}
+			usage.Keys += int64(info.State.Msgs)
+			usage.EstimatedBytes += int64(info.State.Bytes)
+		}
+		for _, kv := range f.buckets {
+			status, err := kv.Status(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("key memory usage: %w", err)
+			}
+			usage.Keys += int64(status.Values())
+			usage.EstimatedBytes += int64(status.Bytes())
+		}
+		report.Families = append(report.Families, usage)
+		report.TotalKeys += usage.Keys
+		report.EstimatedBytes += usage.EstimatedBytes
+	}
+	return report, nil
+}
+
+// Snapshot is not implemented for NATS; back up the streams and buckets with
+// the NATS tooling instead.
+func (n *NATSQueue) Snapshot(ctx context.Context) (*Snapshot, error) {
+	return nil, fmt.Errorf("snapshot: %w", ErrNotSupported)
+}
+
+func (n *NATSQueue) Restore(ctx context.Context, snap *Snapshot, overwrite bool) (RestoreStats, error) {
+	return RestoreStats{}, fmt.Errorf("restore: %w", ErrNotSupported)
+}
+
+// ReadOutbox returns up to limit events published after the event ID after,
+// oldest first, for one project or for every project when projectName is
+// empty. IDs are "0-<stream sequence>", so they order like the Redis ones.
+// Each read goes through a short-lived consumer filtered to the project.
+func (n *NATSQueue) ReadOutbox(ctx context.Context, projectName, after string, limit int) ([]OutboxEvent, string, error) {
+	if after == "" {
+		after = "0-0"
+	}
+	from, err := parseOutboxID(after)
+	if err != nil {
+		return nil, "", err
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	subject := n.prefix + ".outbox.*"
+	if projectName != "" {
+		subject = n.outboxSubject(projectName)
+	}
+	consumer, err := n.outbox.CreateConsumer(ctx, jetstream.ConsumerConfig{
+		FilterSubject:     subject,
+		DeliverPolicy:     jetstream.DeliverByStartSequencePolicy,
+		OptStartSeq:       from.seq + 1,
+		AckPolicy:         jetstream.AckNonePolicy,
+		InactiveThreshold: natsOutboxReaderIdle,
+		MemoryStorage:     true,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("read outbox: %w", err)
+	}
+	defer func() { _ = n.outbox.DeleteConsumer(ctx, consumer.CachedInfo().Name) }()
+
+	batch, err := consumer.FetchNoWait(limit)
+	if err != nil {
+		return nil, "", fmt.Errorf("read outbox: %w", err)
+	}
+	next := after
+	var events []OutboxEvent
+	for msg := range batch.Messages() {
+		meta, err := msg.Metadata()
+		if err != nil {
+			continue
+		}
+		id := outboxID{seq: meta.Sequence.Stream}.String()
+		next = id
+		var event ProjectEvent
+		if err := json.Unmarshal(msg.Data(), &event); err != nil {
+			continue
+		}
+		events = append(events, OutboxEvent{ID: id, Event: event})
+	}
+	if err := batch.Error(); err != nil {
+		return nil, "", fmt.Errorf("read outbox: %w", err)
+	}
+	return events, next, nil
+}
+
+// OutboxOffset returns the last event ID consumer committed, or "" when it
+// has not committed one.
+func (n *NATSQueue) OutboxOffset(ctx context.Context, consumer string) (string, error) {
+	offset, err := getJSON[string](ctx, n.state, natsOutboxOffsetKey(consumer), nil)
+	if err != nil || offset == nil {
+		return "", err
+	}
+	return *offset, nil
+}
+
+// CommitOutboxOffset records that consumer has handled every event up to
+// and including id. Offsets only move forward.
+func (n *NATSQueue) CommitOutboxOffset(ctx context.Context, consumer, id string) error {
+	next, err := parseOutboxID(id)
+	if err != nil {
+		return err
+	}
+	_, err = updateJSON[string](ctx, n.state, natsOutboxOffsetKey(consumer), true, nil, func(offset *string) bool {
+		if current, err := parseOutboxID(*offset); err == nil && !next.after(current) {
+			return false
+		}
+		*offset = id
+		return true
+	})
+	return err
+}
+
+// DeleteOutboxConsumer forgets consumer's offset.
+func (n *NATSQueue) DeleteOutboxConsumer(ctx context.Context, consumer string) error {
+	return deleteKey(ctx, n.state, natsOutboxOffsetKey(consumer))
+}
+
+// OutboxStatus reports the retained range of the outbox stream and every
+// consumer's offset, sorted by name.
+func (n *NATSQueue) OutboxStatus(ctx context.Context) (*OutboxStatus, error) {
+	info, err := n.outbox.Info(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status := &OutboxStatus{Length: int64(info.State.Msgs), Consumers: []OutboxConsumer{}}
+	if info.State.Msgs > 0 {
+		status.FirstID = outboxID{seq: info.State.FirstSeq}.String()
+		status.LastID = outboxID{seq: info.State.LastSeq}.String()
+	}
+	keys, err := listKeys(ctx, n.state, "outbox_offset.*")
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		offset, err := getJSON[string](ctx, n.state, key, nil)
+		if err != nil {
+			return nil, err
+		}
+		if offset == nil {
+			continue
+		}
+		status.Consumers = append(status.Consumers, OutboxConsumer{
+			Name:     lastToken(key),
+			Offset:   *offset,
+			CaughtUp: status.LastID == "" || *offset == status.LastID,
+		})
+	}
+	sort.Slice(status.Consumers, func(i, j int) bool { return status.Consumers[i].Name < status.Consumers[j].Name })
+	return status, nil
+}
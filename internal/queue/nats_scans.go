@@ -0,0 +1,604 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func (n *NATSQueue) StartScan(ctx context.Context, projectName, trigger, commit, actor string, total int) (*Scan, error) {
+	if total < 0 {
+		total = 0
+	}
+
+	scanID := fmt.Sprintf("%s:%d", projectName, time.Now().UnixNano())
+	acquired, err := n.acquireLock(ctx, natsProjectLockKey(projectName), scanID, n.lockTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire project lock for %s: %w", projectName, err)
+	}
+	if !acquired {
+		return nil, ErrProjectLocked
+	}
+
+	scan, err := n.createScan(ctx, scanID, projectName, trigger, commit, actor, total)
+	if err != nil {
+		_, _ = n.releaseLock(ctx, natsProjectLockKey(projectName), scanID)
+		return nil, fmt.Errorf("failed to create scan: %w", err)
+	}
+	return scan, nil
+}
+
+// CancelAndStartScan hands the project lock from the old scan to the new one
+// in one compare-and-swap, then cancels the old scan.
+func (n *NATSQueue) CancelAndStartScan(ctx context.Context, oldScanID, projectName, cancelReason, trigger, commit, actor string, total int) (*Scan, error) {
+	if total < 0 {
+		total = 0
+	}
+
+	newScanID := fmt.Sprintf("%s:%d", projectName, time.Now().UnixNano())
+	transferred, err := n.transferLock(ctx, natsProjectLockKey(projectName), oldScanID, newScanID, n.lockTTL)
+	if err != nil {
+		return nil, fmt.Errorf("cancel-and-acquire failed: %w", err)
+	}
+	if !transferred {
+		return nil, ErrProjectLocked
+	}
+
+	endedAt := time.Unix(time.Now().Unix(), 0)
+	_, _ = n.updateScan(ctx, oldScanID, func(s *Scan) bool {
+		s.Status = ScanStatusCanceled
+		s.EndedAt = endedAt
+		s.Error = cancelReason
+		return true
+	})
+	_ = deleteKey(ctx, n.index, natsRunningScanKey(oldScanID))
+	_, _ = n.index.Put(ctx, natsLastScanKey(projectName), []byte(oldScanID))
+	_ = n.PublishScanEvent(ctx, projectName, ScanEvent{
+		ProjectName: projectName,
+		ScanID:      oldScanID,
+		Status:      ScanStatusCanceled,
+		EndedAt:     &endedAt,
+	})
+
+	scan, err := n.createScan(ctx, newScanID, projectName, trigger, commit, actor, total)
+	if err != nil {
+		_, _ = n.releaseLock(ctx, natsProjectLockKey(projectName), newScanID)
+		return nil, fmt.Errorf("failed to create scan after cancel: %w", err)
+	}
+	return scan, nil
+}
+
+func (n *NATSQueue) createScan(ctx context.Context, scanID, projectName, trigger, commit, actor string, total int) (*Scan, error) {
+	now := time.Unix(time.Now().Unix(), 0)
+	scan := &Scan{
+		ID:          scanID,
+		ProjectName: projectName,
+		Trigger:     trigger,
+		Commit:      commit,
+		Actor:       actor,
+		Status:      ScanStatusRunning,
+		CreatedAt:   now,
+		StartedAt:   now,
+		EndedAt:     time.Unix(0, 0),
+		Total:       total,
+		Queued:      total,
+	}
+	if err := putJSON(ctx, n.scans, natsToken(scanID), scan); err != nil {
+		return nil, err
+	}
+	if _, err := n.index.Put(ctx, natsActiveScanKey(projectName), []byte(scanID)); err != nil {
+		return nil, err
+	}
+	if _, err := n.index.Put(ctx, natsRunningScanKey(scanID), []byte(strconv.FormatInt(now.Unix(), 10))); err != nil {
+		return nil, err
+	}
+	if _, err := n.index.Put(ctx, natsScanHistoryKey(projectName, scanID), []byte(strconv.FormatInt(now.Unix(), 10))); err != nil {
+		return nil, err
+	}
+	return scan, nil
+}
+
+func (n *NATSQueue) updateScan(ctx context.Context, scanID string, fn func(*Scan) bool) (*Scan, error) {
+	scan, err := updateJSON(ctx, n.scans, natsToken(scanID), false, ErrScanNotFound, fn)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeScan(scan), nil
+}
+
+// normalizeScan matches the Redis backend, which stores times as Unix
+// seconds and reports an unfinished scan's EndedAt as the Unix epoch.
+func normalizeScan(scan *Scan) *Scan {
+	scan.CreatedAt = time.Unix(scan.CreatedAt.Unix(), 0)
+	scan.StartedAt = time.Unix(scan.StartedAt.Unix(), 0)
+	if scan.EndedAt.IsZero() {
+		scan.EndedAt = time.Unix(0, 0)
+	} else {
+		scan.EndedAt = time.Unix(scan.EndedAt.Unix(), 0)
+	}
+	scan.CommitSkewed = CommitSkewed(scan.Commit, scan.CommitSHA)
+	return scan
+}
+
+func (n *NATSQueue) GetScan(ctx context.Context, scanID string) (*Scan, error) {
+	scan, err := getJSON[Scan](ctx, n.scans, natsToken(scanID), ErrScanNotFound)
+	if err != nil {
+		if errors.Is(err, ErrScanNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to get scan: %w", err)
+	}
+	return normalizeScan(scan), nil
+}
+
+func (n *NATSQueue) scanFromPointer(ctx context.Context, key string) (*Scan, error) {
+	entry, err := n.index.Get(ctx, key)
+	if err != nil {
+		if isKeyMissing(err) {
+			return nil, ErrScanNotFound
+		}
+		return nil, fmt.Errorf("failed to get scan id: %w", err)
+	}
+	return n.GetScan(ctx, string(entry.Value()))
+}
+
+func (n *NATSQueue) GetActiveScan(ctx context.Context, projectName string) (*Scan, error) {
+	return n.scanFromPointer(ctx, natsActiveScanKey(projectName))
+}
+
+func (n *NATSQueue) GetLastScan(ctx context.Context, projectName string) (*Scan, error) {
+	return n.scanFromPointer(ctx, natsLastScanKey(projectName))
+}
+
+// ListProjectScans returns a project's most recent scans, newest first. The
+// history index expires with the index bucket rather than being capped.
+func (n *NATSQueue) ListProjectScans(ctx context.Context, projectName string, limit int) ([]*Scan, error) {
+	keys, err := listKeys(ctx, n.index, "history."+natsToken(projectName)+".*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scan IDs: %w", err)
+	}
+	var scans []*Scan
+	for _, key := range keys {
+		scan, err := n.GetScan(ctx, lastToken(key))
+		if err != nil {
+			continue
+		}
+		scans = append(scans, scan)
+	}
+	sort.Slice(scans, func(i, j int) bool {
+		if !scans[i].StartedAt.Equal(scans[j].StartedAt) {
+			return scans[i].StartedAt.After(scans[j].StartedAt)
+		}
+		return scans[i].ID > scans[j].ID
+	})
+	if limit > 0 && len(scans) > limit {
+		scans = scans[:limit]
+	}
+	return scans, nil
+}
+
+func (n *NATSQueue) SetScanVersions(ctx context.Context, scanID, tfVersion, tgVersion string, stackTF, stackTG map[string]string) error {
+	_, err := n.updateScan(ctx, scanID, func(s *Scan) bool {
+		s.TerraformVersion = tfVersion
+		s.TerragruntVersion = tgVersion
+		s.StackTFVersions = stackTF
+		s.StackTGVersions = stackTG
+		return true
+	})
+	return err
+}
+
+func (n *NATSQueue) SetScanTotal(ctx context.Context, scanID string, total int) error {
+	_, err := n.updateScan(ctx, scanID, func(s *Scan) bool {
+		s.Total = total
+		s.Queued = total
+		return true
+	})
+	return err
+}
+
+func (n *NATSQueue) SetScanWorkspace(ctx context.Context, scanID, workspacePath, commitSHA string) error {
+	_, err := n.updateScan(ctx, scanID, func(s *Scan) bool {
+		s.WorkspacePath = workspacePath
+		s.CommitSHA = commitSHA
+		return true
+	})
+	return err
+}
+
+func (n *NATSQueue) SetScanCommitInfo(ctx context.Context, scanID string, info *storage.CommitInfo) error {
+	_, err := n.updateScan(ctx, scanID, func(s *Scan) bool {
+		s.CommitInfo = info
+		return true
+	})
+	return err
+}
+
+func (n *NATSQueue) SetScanSkippedStale(ctx context.Context, scanID string, skipped int) error {
+	_, err := n.updateScan(ctx, scanID, func(s *Scan) bool {
+		s.SkippedStale = skipped
+		return true
+	})
+	return err
+}
+
+func (n *NATSQueue) LinkScanRetry(ctx context.Context, scanID, retryScanID string) error {
+	if _, err := n.updateScan(ctx, scanID, func(s *Scan) bool {
+		s.RetryScanID = retryScanID
+		return true
+	}); err != nil {
+		return err
+	}
+	_, err := n.updateScan(ctx, retryScanID, func(s *Scan) bool {
+		s.RetryOf = scanID
+		return true
+	})
+	return err
+}
+
+func (n *NATSQueue) SetScanWarnings(ctx context.Context, scanID string, warnings []ScanWarning) error {
+	_, err := n.updateScan(ctx, scanID, func(s *Scan) bool {
+		s.Warnings = warnings
+		return true
+	})
+	return err
+}
+
+func (n *NATSQueue) RecordScanPhases(ctx context.Context, scanID string, phases ...ScanPhase) error {
+	if len(phases) == 0 {
+		return nil
+	}
+	_, err := n.updateScan(ctx, scanID, func(s *Scan) bool {
+		s.Phases = mergeScanPhases(s.Phases, phases)
+		return true
+	})
+	return err
+}
+
+func (n *NATSQueue) FailScan(ctx context.Context, scanID, projectName, errMsg string) error {
+	return n.endScan(ctx, scanID, projectName, ScanStatusFailed, errMsg, false)
+}
+
+func (n *NATSQueue) CancelScan(ctx context.Context, scanID, projectName, reason string) error {
+	if reason == "" {
+		reason = "canceled"
+	}
+	return n.endScan(ctx, scanID, projectName, ScanStatusCanceled, reason, true)
+}
+
+func (n *NATSQueue) endScan(ctx context.Context, scanID, projectName, status, errMsg string, setLast bool) error {
+	endedAt := time.Now()
+	if _, err := n.updateScan(ctx, scanID, func(s *Scan) bool {
+		s.Status = status
+		s.EndedAt = endedAt
+		s.Error = errMsg
+		return true
+	}); err != nil && !errors.Is(err, ErrScanNotFound) {
+		return err
+	}
+	if err := deleteKey(ctx, n.index, natsActiveScanKey(projectName)); err != nil {
+		return err
+	}
+	if setLast {
+		if _, err := n.index.Put(ctx, natsLastScanKey(projectName), []byte(scanID)); err != nil {
+			return err
+		}
+	}
+	if err := deleteKey(ctx, n.index, natsRunningScanKey(scanID)); err != nil {
+		return err
+	}
+	if _, err := n.releaseLock(ctx, natsProjectLockKey(projectName), scanID); err != nil {
+		return err
+	}
+	_ = n.PublishScanEvent(ctx, projectName, ScanEvent{
+		ProjectName: projectName,
+		ScanID:      scanID,
+		Status:      status,
+		EndedAt:     &endedAt,
+	})
+	return nil
+}
+
+func (n *NATSQueue) RenewScanLock(ctx context.Context, scanID, projectName string, maxAge, renewEvery time.Duration) {
+	start := time.Now()
+	if maxAge <= 0 {
+		maxAge = 6 * time.Hour
+	}
+	interval := renewEvery
+	if interval <= 0 {
+		interval = n.lockTTL / 3
+	}
+	if interval < scanRenewIntervalMin {
+		interval = scanRenewIntervalMin
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if time.Since(start) > maxAge {
+			_ = n.FailScan(context.Background(), scanID, projectName, "scan exceeded maximum duration")
+			return
+		}
+
+		scan, err := n.GetScan(ctx, scanID)
+		if err != nil {
+			if errors.Is(err, ErrScanNotFound) {
+				return
+			}
+			continue
+		}
+		if scan.Status != ScanStatusRunning {
+			return
+		}
+
+		renewed, err := n.renewLock(ctx, natsProjectLockKey(projectName), scanID, n.lockTTL)
+		if err != nil {
+			continue
+		}
+		if !renewed {
+			return
+		}
+	}
+}
+
+// runScanTransition is the equivalent of scanTransitionScript: it applies
+// counter deltas and finishes the scan once every stack scan is done. Only
+// the writer whose compare-and-swap finishes the scan releases the lock and
+// moves the pointers.
+func (n *NATSQueue) runScanTransition(ctx context.Context, scanID, projectName string, deltas ...any) error {
+	var finished bool
+	now := time.Unix(time.Now().Unix(), 0)
+	scan, err := n.updateScan(ctx, scanID, func(s *Scan) bool {
+		finished = false
+		applyScanDeltas(s, deltas)
+		if s.Status == ScanStatusRunning && (s.Total == 0 || s.Completed+s.Failed >= s.Total) {
+			s.Status = ScanStatusCompleted
+			if s.Failed > 0 {
+				s.Status = ScanStatusFailed
+			}
+			s.EndedAt = now
+			finished = true
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	state := scanTransitionState{
+		Status:    scan.Status,
+		Completed: scan.Completed,
+		Failed:    scan.Failed,
+		Total:     scan.Total,
+		Drifted:   scan.Drifted,
+	}
+	if finished {
+		_, _ = n.releaseLock(ctx, natsProjectLockKey(projectName), scanID)
+		_ = deleteKey(ctx, n.index, natsActiveScanKey(projectName))
+		_, _ = n.index.Put(ctx, natsLastScanKey(projectName), []byte(scanID))
+		_ = deleteKey(ctx, n.index, natsRunningScanKey(scanID))
+		state.EndedAt = &now
+	}
+
+	var changes []DriftChange
+	if state.EndedAt != nil {
+		changes, _ = n.ScanDriftChanges(ctx, projectName, scanID)
+	}
+	_ = n.PublishScanEvent(ctx, projectName, ScanEvent{
+		ProjectName:  projectName,
+		ScanID:       scanID,
+		Status:       state.Status,
+		Completed:    state.Completed,
+		Failed:       state.Failed,
+		Total:        state.Total,
+		DriftedCnt:   state.Drifted,
+		EndedAt:      state.EndedAt,
+		DriftChanges: changes,
+	})
+	return nil
+}
+
+// applyScanDeltas applies (field, delta) pairs, clamping counters at zero
+// like the Redis script does.
+func applyScanDeltas(s *Scan, deltas []any) {
+	for i := 0; i+1 < len(deltas); i += 2 {
+		field, _ := deltas[i].(string)
+		var counter *int
+		switch field {
+		case "total":
+			counter = &s.Total
+		case "queued":
+			counter = &s.Queued
+		case "running":
+			counter = &s.Running
+		case "completed":
+			counter = &s.Completed
+		case "failed":
+			counter = &s.Failed
+		case "drifted":
+			counter = &s.Drifted
+		case "errored":
+			counter = &s.Errored
+		default:
+			continue
+		}
+		*counter += deltaInt(deltas[i+1])
+		if *counter < 0 {
+			*counter = 0
+		}
+	}
+}
+
+func deltaInt(v any) int {
+	switch d := v.(type) {
+	case int:
+		return d
+	case int64:
+		return int(d)
+	case string:
+		i, _ := strconv.Atoi(d)
+		return i
+	default:
+		return 0
+	}
+}
+
+func (n *NATSQueue) scanProject(ctx context.Context, scanID string) (string, error) {
+	scan, err := n.GetScan(ctx, scanID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get project for scan %s: %w", scanID, err)
+	}
+	return scan.ProjectName, nil
+}
+
+func (n *NATSQueue) markScan(ctx context.Context, scanID string, deltas ...any) error {
+	projectName, err := n.scanProject(ctx, scanID)
+	if err != nil {
+		return err
+	}
+	return n.runScanTransition(ctx, scanID, projectName, deltas...)
+}
+
+func (n *NATSQueue) AdjustScanCounters(ctx context.Context, scanID, projectName string, deltas ...any) error {
+	return n.runScanTransition(ctx, scanID, projectName, deltas...)
+}
+
+// RecoverStaleScans finds running scans older than maxAge and marks them failed.
+func (n *NATSQueue) RecoverStaleScans(ctx context.Context, maxAge time.Duration) (int, error) {
+	if maxAge <= 0 {
+		return 0, nil
+	}
+	running, err := n.runningIndex(ctx, "running_scan.*")
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-maxAge).Unix()
+	recovered := 0
+	for id, startedAt := range running {
+		if startedAt > cutoff {
+			continue
+		}
+		scan, err := n.GetScan(ctx, id)
+		if err != nil || scan.Status != ScanStatusRunning {
+			_ = deleteKey(ctx, n.index, natsRunningScanKey(id))
+			continue
+		}
+		if err := n.FailScan(ctx, scan.ID, scan.ProjectName, "scan exceeded maximum duration"); err != nil {
+			continue
+		}
+		recovered++
+	}
+	return recovered, nil
+}
+
+// RebuildRunningScansIndex re-adds running scans missing from the index.
+func (n *NATSQueue) RebuildRunningScansIndex(ctx context.Context) (int, error) {
+	keys, err := listKeys(ctx, n.scans, ">")
+	if err != nil {
+		return 0, err
+	}
+	rebuilt := 0
+	for _, key := range keys {
+		scanID := natsUntoken(key)
+		scan, err := n.GetScan(ctx, scanID)
+		if err != nil || scan.Status != ScanStatusRunning || scan.StartedAt.Unix() <= 0 {
+			continue
+		}
+		_, err = n.index.Create(ctx, natsRunningScanKey(scanID), []byte(strconv.FormatInt(scan.StartedAt.Unix(), 10)))
+		if err == nil {
+			rebuilt++
+		}
+	}
+	return rebuilt, nil
+}
+
+// runningIndex returns ID -> start time (Unix seconds) for a running index.
+func (n *NATSQueue) runningIndex(ctx context.Context, filter string) (map[string]int64, error) {
+	keys, err := listKeys(ctx, n.index, filter)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]int64, len(keys))
+	for _, key := range keys {
+		entry, err := n.index.Get(ctx, key)
+		if err != nil {
+			continue
+		}
+		startedAt, _ := strconv.ParseInt(string(entry.Value()), 10, 64)
+		out[lastToken(key)] = startedAt
+	}
+	return out, nil
+}
+
+func (n *NATSQueue) RunningScanCount(ctx context.Context) (int, error) {
+	keys, err := listKeys(ctx, n.index, "running_scan.*")
+	return len(keys), err
+}
+
+func (n *NATSQueue) RunningStackScanCount(ctx context.Context) (int, error) {
+	keys, err := listKeys(ctx, n.index, "running_stack.*")
+	return len(keys), err
+}
+
+func (n *NATSQueue) OldestRunningScanAge(ctx context.Context) (time.Duration, error) {
+	return n.oldestRunning(ctx, "running_scan.*")
+}
+
+func (n *NATSQueue) OldestRunningStackScanAge(ctx context.Context) (time.Duration, error) {
+	return n.oldestRunning(ctx, "running_stack.*")
+}
+
+func (n *NATSQueue) OldestPendingStackScanAge(ctx context.Context) (time.Duration, error) {
+	keys, err := listKeys(ctx, n.index, "pending.*")
+	if err != nil {
+		return 0, err
+	}
+	var oldest time.Time
+	for _, key := range keys {
+		stackScan, err := n.GetStackScan(ctx, lastToken(key))
+		if err != nil || stackScan.Status != StatusPending {
+			continue
+		}
+		if pause, err := n.GetProjectPause(ctx, stackScan.ProjectName); err != nil || pause != nil {
+			continue
+		}
+		if oldest.IsZero() || stackScan.CreatedAt.Before(oldest) {
+			oldest = stackScan.CreatedAt
+		}
+	}
+	return pendingAge(oldest), nil
+}
+
+func (n *NATSQueue) oldestRunning(ctx context.Context, filter string) (time.Duration, error) {
+	running, err := n.runningIndex(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	var oldest int64
+	for _, startedAt := range running {
+		if oldest == 0 || startedAt < oldest {
+			oldest = startedAt
+		}
+	}
+	if oldest == 0 {
+		return 0, nil
+	}
+	started := time.Unix(oldest, 0)
+	if started.After(time.Now()) {
+		return 0, nil
+	}
+	return time.Since(started), nil
+}
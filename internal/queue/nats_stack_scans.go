@@ -0,0 +1,652 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func (n *NATSQueue) Enqueue(ctx context.Context, stackScan *StackScan) error {
+	stackScan.Status = StatusPending
+	stackScan.CreatedAt = time.Now()
+	if stackScan.SchemaVersion == 0 {
+		stackScan.SchemaVersion = JobSchemaVersion
+	}
+	if stackScan.ID == "" {
+		stackScan.ID = fmt.Sprintf("%s:%s:%d:%d", stackScan.ProjectName, stackScan.StackPath, stackScan.CreatedAt.UnixNano(), rand.Int31())
+	}
+
+	enqueued, err := n.enqueueStackScan(ctx, stackScan)
+	if err != nil {
+		return err
+	}
+	if !enqueued {
+		return ErrStackScanInflight
+	}
+	return nil
+}
+
+func (n *NATSQueue) EnqueueBatch(ctx context.Context, stacks []*StackScan) (*EnqueueBatchResult, error) {
+	if len(stacks) == 0 {
+		return &EnqueueBatchResult{}, nil
+	}
+
+	now := time.Now()
+	for _, ss := range stacks {
+		ss.Status = StatusPending
+		ss.CreatedAt = now
+		if ss.SchemaVersion == 0 {
+			ss.SchemaVersion = JobSchemaVersion
+		}
+		if ss.ID == "" {
+			ss.ID = fmt.Sprintf("%s:%s:%d:%d", ss.ProjectName, ss.StackPath, now.UnixNano(), rand.Int31())
+		}
+	}
+
+	result := &EnqueueBatchResult{}
+	for _, ss := range stacks {
+		enqueued, err := n.enqueueStackScan(ctx, ss)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", ss.StackPath, err))
+			continue
+		}
+		if !enqueued {
+			result.Skipped++
+			continue
+		}
+		result.Enqueued = append(result.Enqueued, ss)
+	}
+	return result, nil
+}
+
+// enqueueStackScan takes the stack's inflight lock, writes the stack scan
+// and its index entries, then publishes its ID to the work stream. Any
+// failure after the lock is taken undoes the partial writes.
+func (n *NATSQueue) enqueueStackScan(ctx context.Context, stackScan *StackScan) (enqueued bool, err error) {
+	inflight := natsInflightKey(stackScan.ProjectName, stackScan.StackPath)
+	acquired, err := n.acquireLock(ctx, inflight, stackScan.ID, stackScanRetention)
+	if err != nil {
+		return false, fmt.Errorf("failed to enqueue stack scan: %w", err)
+	}
+	if !acquired {
+		return false, nil
+	}
+
+	var written []string
+	defer func() {
+		if err == nil {
+			return
+		}
+		cleanup := context.Background()
+		for _, key := range written {
+			_ = deleteKey(cleanup, n.index, key)
+		}
+		_ = deleteKey(cleanup, n.stackScans, natsToken(stackScan.ID))
+		_, _ = n.releaseLock(cleanup, inflight, stackScan.ID)
+		err = fmt.Errorf("failed to enqueue stack scan: %w", err)
+	}()
+
+	if err = putJSON(ctx, n.stackScans, natsToken(stackScan.ID), stackScan); err != nil {
+		return false, err
+	}
+	created := []byte(strconv.FormatInt(stackScan.CreatedAt.Unix(), 10))
+	keys := []string{
+		natsProjectStackScanKey(stackScan.ProjectName, stackScan.ID),
+		natsPendingKey(stackScan.ID),
+	}
+	if stackScan.ScanID != "" {
+		keys = append(keys, natsScanStackScanKey(stackScan.ScanID, stackScan.ID))
+	}
+	for _, key := range keys {
+		if _, err = n.index.Put(ctx, key, created); err != nil {
+			return false, err
+		}
+		written = append(written, key)
+	}
+	if _, err = n.js.Publish(ctx, n.workSubject(), []byte(stackScan.ID)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Dequeue blocks until a stack scan is available and claims it. A message is
+// acknowledged, removing it from the work stream, once the claim succeeds or
+// the stack scan turns out to be gone or no longer pending. Messages whose
+// claim is held elsewhere are redelivered after a short delay.
+func (n *NATSQueue) Dequeue(ctx context.Context, workerID string) (*StackScan, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		msg, err := n.work.Next(jetstream.FetchMaxWait(time.Second))
+		if err != nil {
+			if errors.Is(err, nats.ErrTimeout) || errors.Is(err, jetstream.ErrNoMessages) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("failed to dequeue: %w", err)
+		}
+		// The fetch is not interrupted by cancellation; hand the message
+		// straight back if the caller stopped claiming while we waited.
+		if ctx.Err() != nil {
+			_ = msg.Nak()
+			return nil, ctx.Err()
+		}
+
+		// Use a background context so a canceled dequeue cannot strand an
+		// item between claim and ack.
+		claimCtx := context.Background()
+		stackScanID := string(msg.Data())
+		stackScan, err := n.GetStackScan(claimCtx, stackScanID)
+		if err != nil {
+			if errors.Is(err, ErrStackScanNotFound) {
+				_ = msg.Ack()
+			} else {
+				_ = msg.NakWithDelay(natsClaimBackoff)
+			}
+			continue
+		}
+		if stackScan.Status != StatusPending {
+			_ = msg.Ack()
+			continue
+		}
+		// Leave stack scans from a newer or older build to workers that
+		// can process them.
+		if !SupportsJobVersion(stackScan.SchemaVersion) {
+			_ = msg.NakWithDelay(natsClaimBackoff)
+			continue
+		}
+		// Paused projects keep their messages in the stream; they are
+		// redelivered until the project is resumed.
+		if pause, err := n.GetProjectPause(claimCtx, stackScan.ProjectName); err != nil || pause != nil {
+			_ = msg.NakWithDelay(natsClaimBackoff)
+			continue
+		}
+		// Stack scans of a scan at its concurrency cap wait their turn. The
+		// slot is reserved on the scan before the claim, so workers claiming
+		// at once cannot both take the last one.
+		reserved := false
+		if stackScan.ScanConcurrency > 0 && stackScan.ScanID != "" {
+			ok, err := n.reserveScanSlot(claimCtx, stackScan.ScanID, stackScan.ScanConcurrency)
+			if err != nil || !ok {
+				_ = msg.NakWithDelay(natsClaimBackoff)
+				continue
+			}
+			reserved = true
+		}
+
+		claimKey := natsClaimKey(stackScanID)
+		claimed, err := n.acquireLock(claimCtx, claimKey, workerID, stackScanClaimTTL)
+		if err != nil || !claimed {
+			if reserved {
+				n.releaseScanSlot(claimCtx, stackScan.ScanID)
+			}
+			_ = msg.NakWithDelay(natsClaimBackoff)
+			continue
+		}
+		if err := n.markRunning(claimCtx, stackScan, workerID, reserved); err != nil {
+			_, _ = n.releaseLock(claimCtx, claimKey, workerID)
+			if reserved {
+				n.releaseScanSlot(claimCtx, stackScan.ScanID)
+			}
+			_ = msg.NakWithDelay(natsClaimBackoff)
+			continue
+		}
+		// The stack scan is ours even if the ack is lost; a redelivered copy
+		// is dropped because it is no longer pending.
+		_ = msg.DoubleAck(claimCtx)
+		return stackScan, nil
+	}
+}
+
+// PauseProject stops workers claiming the project's stack scans until
+// ResumeProject.
+func (n *NATSQueue) PauseProject(ctx context.Context, pause ProjectPause) error {
+	if pause.PausedAt.IsZero() {
+		pause.PausedAt = time.Now()
+	}
+	return putJSON(ctx, n.state, natsPauseKey(pause.Project), pause)
+}
+
+// ResumeProject clears the project's pause. Its stack scans are claimed
+// again as their messages are redelivered.
+func (n *NATSQueue) ResumeProject(ctx context.Context, projectName string) (bool, error) {
+	pause, err := n.GetProjectPause(ctx, projectName)
+	if err != nil || pause == nil {
+		return false, err
+	}
+	return true, deleteKey(ctx, n.state, natsPauseKey(projectName))
+}
+
+func (n *NATSQueue) GetProjectPause(ctx context.Context, projectName string) (*ProjectPause, error) {
+	pause, err := getJSON[ProjectPause](ctx, n.state, natsPauseKey(projectName), nil)
+	if err != nil || pause == nil {
+		return nil, err
+	}
+	return pause, nil
+}
+
+func (n *NATSQueue) ListPausedProjects(ctx context.Context) ([]ProjectPause, error) {
+	keys, err := listKeys(ctx, n.state, "paused.*")
+	if err != nil {
+		return nil, err
+	}
+	pauses := make([]ProjectPause, 0, len(keys))
+	for _, key := range keys {
+		pause, err := getJSON[ProjectPause](ctx, n.state, key, nil)
+		if err != nil || pause == nil {
+			continue
+		}
+		pauses = append(pauses, *pause)
+	}
+	sort.Slice(pauses, func(i, j int) bool { return pauses[i].Project < pauses[j].Project })
+	return pauses, nil
+}
+
+// reserveScanSlot counts one more of the scan's stack scans as running if
+// fewer than limit are. The update is revision-checked, so concurrent
+// reservations see each other.
+func (n *NATSQueue) reserveScanSlot(ctx context.Context, scanID string, limit int) (bool, error) {
+	reserved := false
+	_, err := n.updateScan(ctx, scanID, func(s *Scan) bool {
+		reserved = s.Running < limit
+		if reserved {
+			applyScanDeltas(s, []any{"running", 1, "queued", -1})
+		}
+		return reserved
+	})
+	return reserved, err
+}
+
+// releaseScanSlot hands back a slot reserveScanSlot took for a claim that
+// did not go through.
+func (n *NATSQueue) releaseScanSlot(ctx context.Context, scanID string) {
+	_, _ = n.updateScan(ctx, scanID, func(s *Scan) bool {
+		applyScanDeltas(s, []any{"running", -1, "queued", 1})
+		return true
+	})
+}
+
+// markRunning records the claimed stack scan as running. reserved means its
+// scan already counts it, through reserveScanSlot.
+func (n *NATSQueue) markRunning(ctx context.Context, stackScan *StackScan, workerID string, reserved bool) error {
+	stackScan.Status = StatusRunning
+	stackScan.StartedAt = time.Now()
+	stackScan.WorkerID = workerID
+	if err := n.saveStackScan(ctx, stackScan); err != nil {
+		return err
+	}
+	_ = deleteKey(ctx, n.index, natsPendingKey(stackScan.ID))
+	if _, err := n.index.Put(ctx, natsRunningStackKey(stackScan.ID), []byte(strconv.FormatInt(stackScan.StartedAt.Unix(), 10))); err != nil {
+		return err
+	}
+	switch {
+	case stackScan.ScanID == "":
+		return nil
+	case reserved:
+		// Publish the progress the reservation did not.
+		return n.markScan(ctx, stackScan.ScanID)
+	default:
+		return n.markScan(ctx, stackScan.ScanID, "running", 1, "queued", -1)
+	}
+}
+
+func (n *NATSQueue) saveStackScan(ctx context.Context, stackScan *StackScan) error {
+	return putJSON(ctx, n.stackScans, natsToken(stackScan.ID), stackScan)
+}
+
+func (n *NATSQueue) GetStackScan(ctx context.Context, stackScanID string) (*StackScan, error) {
+	stackScan, err := getJSON[StackScan](ctx, n.stackScans, natsToken(stackScanID), ErrStackScanNotFound)
+	if err != nil && !errors.Is(err, ErrStackScanNotFound) {
+		return nil, fmt.Errorf("failed to get stack scan: %w", err)
+	}
+	return stackScan, err
+}
+
+// ListProjectStackScans returns the project's unfinished stack scans, newest
+// first.
+func (n *NATSQueue) ListProjectStackScans(ctx context.Context, projectName string, limit int) ([]*StackScan, error) {
+	keys, err := listKeys(ctx, n.index, "project."+natsToken(projectName)+".*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stack scan IDs: %w", err)
+	}
+	var stackScans []*StackScan
+	for _, key := range keys {
+		stackScan, err := n.GetStackScan(ctx, lastToken(key))
+		if err != nil {
+			continue
+		}
+		stackScans = append(stackScans, stackScan)
+	}
+	sort.Slice(stackScans, func(i, j int) bool {
+		if !stackScans[i].CreatedAt.Equal(stackScans[j].CreatedAt) {
+			return stackScans[i].CreatedAt.After(stackScans[j].CreatedAt)
+		}
+		return stackScans[i].ID > stackScans[j].ID
+	})
+	if limit > 0 && len(stackScans) > limit {
+		stackScans = stackScans[:limit]
+	}
+	return stackScans, nil
+}
+
+func (n *NATSQueue) ListScanStackScans(ctx context.Context, scanID string) ([]*StackScan, error) {
+	keys, err := listKeys(ctx, n.index, "scan."+natsToken(scanID)+".*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stack scan IDs: %w", err)
+	}
+	var stackScans []*StackScan
+	for _, key := range keys {
+		stackScan, err := n.GetStackScan(ctx, lastToken(key))
+		if err != nil {
+			continue
+		}
+		stackScans = append(stackScans, stackScan)
+	}
+	return stackScans, nil
+}
+
+func (n *NATSQueue) ListRunningStackScans(ctx context.Context) ([]*StackScan, error) {
+	running, err := n.runningIndex(ctx, "running_stack.*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running stack scans: %w", err)
+	}
+	var stackScans []*StackScan
+	for id := range running {
+		stackScan, err := n.GetStackScan(ctx, id)
+		if err != nil || stackScan.Status != StatusRunning {
+			continue
+		}
+		stackScans = append(stackScans, stackScan)
+	}
+	sort.Slice(stackScans, func(i, j int) bool {
+		if !stackScans[i].StartedAt.Equal(stackScans[j].StartedAt) {
+			return stackScans[i].StartedAt.Before(stackScans[j].StartedAt)
+		}
+		return stackScans[i].ID < stackScans[j].ID
+	})
+	return stackScans, nil
+}
+
+// ListPendingStackScans returns the unclaimed stack scans oldest first,
+// the order the work stream delivers them in. Stack scans of paused
+// projects are left out.
+func (n *NATSQueue) ListPendingStackScans(ctx context.Context) ([]*StackScan, error) {
+	keys, err := listKeys(ctx, n.index, "pending.*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pending stack scans: %w", err)
+	}
+	var stackScans []*StackScan
+	for _, key := range keys {
+		stackScan, err := n.GetStackScan(ctx, lastToken(key))
+		if err != nil || stackScan.Status != StatusPending {
+			continue
+		}
+		if pause, err := n.GetProjectPause(ctx, stackScan.ProjectName); err != nil || pause != nil {
+			continue
+		}
+		stackScans = append(stackScans, stackScan)
+	}
+	sort.Slice(stackScans, func(i, j int) bool {
+		if !stackScans[i].CreatedAt.Equal(stackScans[j].CreatedAt) {
+			return stackScans[i].CreatedAt.Before(stackScans[j].CreatedAt)
+		}
+		return stackScans[i].ID < stackScans[j].ID
+	})
+	return stackScans, nil
+}
+
+func (n *NATSQueue) removeStackScanRefs(ctx context.Context, stackScan *StackScan) error {
+	return deleteKey(ctx, n.index, natsProjectStackScanKey(stackScan.ProjectName, stackScan.ID))
+}
+
+// releaseStackScan drops the claim, inflight lock and pending entry of a
+// finished stack scan.
+func (n *NATSQueue) releaseStackScan(ctx context.Context, stackScan *StackScan) {
+	_ = deleteKey(ctx, n.locks, natsClaimKey(stackScan.ID))
+	_ = deleteKey(ctx, n.locks, natsInflightKey(stackScan.ProjectName, stackScan.StackPath))
+	_ = deleteKey(ctx, n.index, natsPendingKey(stackScan.ID))
+}
+
+func (n *NATSQueue) CancelStackScan(ctx context.Context, stackScan *StackScan, reason string) error {
+	stackScan.Status = StatusCanceled
+	stackScan.CompletedAt = time.Now()
+	stackScan.Error = reason
+	if err := n.saveStackScan(ctx, stackScan); err != nil {
+		return err
+	}
+	if err := deleteKey(ctx, n.index, natsRunningStackKey(stackScan.ID)); err != nil {
+		return err
+	}
+	_ = deleteKey(ctx, n.locks, natsInflightKey(stackScan.ProjectName, stackScan.StackPath))
+	return n.removeStackScanRefs(ctx, stackScan)
+}
+
+func (n *NATSQueue) Complete(ctx context.Context, stackScan *StackScan, drifted bool) error {
+	stackScan.Status = StatusCompleted
+	stackScan.CompletedAt = time.Now()
+	if err := n.saveStackScan(ctx, stackScan); err != nil {
+		return err
+	}
+	n.releaseStackScan(ctx, stackScan)
+	if err := deleteKey(ctx, n.index, natsRunningStackKey(stackScan.ID)); err != nil {
+		return err
+	}
+	if err := n.removeStackScanRefs(ctx, stackScan); err != nil {
+		return err
+	}
+	state := DriftStateHealthy
+	if drifted {
+		state = DriftStateDrifted
+	}
+	_ = n.recordDriftState(ctx, stackScan, state)
+	if stackScan.ScanID != "" {
+		deltas := []any{"running", -1, "completed", 1}
+		if drifted {
+			deltas = append(deltas, "drifted", 1)
+		}
+		return n.markScan(ctx, stackScan.ScanID, deltas...)
+	}
+	return nil
+}
+
+func (n *NATSQueue) Fail(ctx context.Context, stackScan *StackScan, errMsg string) error {
+	stackScan.Error = errMsg
+	stackScan.Retries++
+
+	if stackScan.Retries <= stackScan.MaxRetries {
+		stackScan.Status = StatusPending
+		stackScan.StartedAt = time.Time{}
+		stackScan.WorkerID = ""
+		if err := n.saveStackScan(ctx, stackScan); err != nil {
+			return err
+		}
+		// Drop the claim so the retry can be claimed by a worker.
+		_ = deleteKey(ctx, n.locks, natsClaimKey(stackScan.ID))
+		if _, err := n.index.Put(ctx, natsPendingKey(stackScan.ID), []byte(strconv.FormatInt(stackScan.CreatedAt.Unix(), 10))); err != nil {
+			return err
+		}
+		if err := deleteKey(ctx, n.index, natsRunningStackKey(stackScan.ID)); err != nil {
+			return err
+		}
+		if stackScan.ScanID != "" {
+			if err := n.markScan(ctx, stackScan.ScanID, "running", -1, "queued", 1); err != nil {
+				return err
+			}
+		}
+		_, err := n.js.Publish(ctx, n.workSubject(), []byte(stackScan.ID))
+		return err
+	}
+
+	stackScan.Status = StatusFailed
+	stackScan.CompletedAt = time.Now()
+	if err := n.saveStackScan(ctx, stackScan); err != nil {
+		return err
+	}
+	n.releaseStackScan(ctx, stackScan)
+	if err := deleteKey(ctx, n.index, natsRunningStackKey(stackScan.ID)); err != nil {
+		return err
+	}
+	if err := n.removeStackScanRefs(ctx, stackScan); err != nil {
+		return err
+	}
+	_ = n.recordDriftState(ctx, stackScan, DriftStateError)
+	if stackScan.ScanID != "" {
+		return n.markScan(ctx, stackScan.ScanID, "running", -1, "failed", 1, "errored", 1)
+	}
+	return nil
+}
+
+// ClearInflightForScan removes inflight markers for all stack scans belonging to a scan.
+func (n *NATSQueue) ClearInflightForScan(ctx context.Context, scanID string) {
+	keys, err := listKeys(ctx, n.index, "scan."+natsToken(scanID)+".*")
+	if err != nil {
+		return
+	}
+	for _, key := range keys {
+		stackScan, err := n.GetStackScan(ctx, lastToken(key))
+		if err != nil {
+			continue
+		}
+		_ = deleteKey(ctx, n.locks, natsInflightKey(stackScan.ProjectName, stackScan.StackPath))
+	}
+}
+
+// RecoverOrphanedStackScans re-publishes pending stack scans. Duplicates are
+// harmless: Dequeue drops any copy whose stack scan is no longer pending.
+func (n *NATSQueue) RecoverOrphanedStackScans(ctx context.Context) (int, error) {
+	keys, err := listKeys(ctx, n.index, "pending.*")
+	if err != nil {
+		return 0, err
+	}
+	recovered := 0
+	for _, key := range keys {
+		id := lastToken(key)
+		stackScan, err := n.GetStackScan(ctx, id)
+		if err != nil || stackScan.Status != StatusPending {
+			_ = deleteKey(ctx, n.index, key)
+			continue
+		}
+		_, _ = n.acquireLock(ctx, natsInflightKey(stackScan.ProjectName, stackScan.StackPath), stackScan.ID, stackScanRetention)
+		if _, err := n.js.Publish(ctx, n.workSubject(), []byte(stackScan.ID)); err != nil {
+			continue
+		}
+		recovered++
+	}
+	return recovered, nil
+}
+
+// RecoverStaleStackScans finds running stack scans older than maxAge and
+// marks them as failed (or re-queued if retries remain).
+func (n *NATSQueue) RecoverStaleStackScans(ctx context.Context, maxAge time.Duration) (int, error) {
+	if maxAge <= 0 {
+		return 0, nil
+	}
+	running, err := n.runningIndex(ctx, "running_stack.*")
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	recovered := 0
+	for id, startedAt := range running {
+		if startedAt > cutoff.Unix() {
+			continue
+		}
+		stackScan, err := n.GetStackScan(ctx, id)
+		if err != nil || stackScan.Status != StatusRunning {
+			_ = deleteKey(ctx, n.index, natsRunningStackKey(id))
+			continue
+		}
+		if stackScan.StartedAt.After(cutoff) {
+			continue
+		}
+		if err := n.Fail(ctx, stackScan, "stale stack scan exceeded max age"); err != nil {
+			continue
+		}
+		recovered++
+	}
+	return recovered, nil
+}
+
+// recordDriftState mirrors the Redis backend: it swaps the stack's drift
+// state and appends a change entry when the state moved.
+func (n *NATSQueue) recordDriftState(ctx context.Context, stackScan *StackScan, state string) error {
+	if stackScan.ProjectName == "" || stackScan.StackPath == "" {
+		return nil
+	}
+	var prev string
+	if _, err := updateJSON(ctx, n.state, natsDriftStateKey(stackScan.ProjectName, stackScan.StackPath), true, nil, func(current *string) bool {
+		prev = *current
+		*current = state
+		return prev != state
+	}); err != nil {
+		return err
+	}
+	if prev == state || (prev == "" && state == DriftStateHealthy) {
+		return nil
+	}
+
+	change := DriftChange{
+		StackPath: stackScan.StackPath,
+		Previous:  prev,
+		Current:   state,
+		ScanID:    stackScan.ScanID,
+		ChangedAt: time.Now().UTC(),
+	}
+	_, err := updateJSON(ctx, n.state, natsDriftChangesKey(stackScan.ProjectName), true, nil, func(changes *[]DriftChange) bool {
+		*changes = append(*changes, change)
+		if len(*changes) > maxDriftChanges {
+			*changes = (*changes)[len(*changes)-maxDriftChanges:]
+		}
+		return true
+	})
+	return err
+}
+
+func (n *NATSQueue) driftChanges(ctx context.Context, projectName string) ([]DriftChange, error) {
+	changes, err := getJSON[[]DriftChange](ctx, n.state, natsDriftChangesKey(projectName), ErrScanNotFound)
+	if err != nil {
+		if errors.Is(err, ErrScanNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	sort.SliceStable(*changes, func(i, j int) bool { return (*changes)[i].ChangedAt.Before((*changes)[j].ChangedAt) })
+	return *changes, nil
+}
+
+func (n *NATSQueue) DriftChangesSince(ctx context.Context, projectName string, since time.Time, skipScanID string) ([]DriftChange, error) {
+	changes, err := n.driftChanges(ctx, projectName)
+	if err != nil {
+		return nil, err
+	}
+	kept := changes[:0]
+	for _, change := range changes {
+		if change.ChangedAt.UnixMilli() <= since.UnixMilli() {
+			continue
+		}
+		if skipScanID != "" && change.ScanID == skipScanID {
+			continue
+		}
+		kept = append(kept, change)
+	}
+	return netDriftChanges(kept, ""), nil
+}
+
+func (n *NATSQueue) ScanDriftChanges(ctx context.Context, projectName, scanID string) ([]DriftChange, error) {
+	changes, err := n.driftChanges(ctx, projectName)
+	if err != nil {
+		return nil, err
+	}
+	return netDriftChanges(changes, scanID), nil
+}
@@ -0,0 +1,165 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+func newTestNATSQueue(t *testing.T) *NATSQueue {
+	t.Helper()
+	ns, err := server.NewServer(&server.Options{
+		Host:      "127.0.0.1",
+		Port:      -1,
+		JetStream: true,
+		StoreDir:  t.TempDir(),
+		NoLog:     true,
+		NoSigs:    true,
+	})
+	if err != nil {
+		t.Fatalf("nats server: %v", err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(10 * time.Second) {
+		t.Fatalf("nats server not ready")
+	}
+
+	q, err := NewNATS(NATSOptions{URL: ns.ClientURL()}, time.Minute)
+	if err != nil {
+		ns.Shutdown()
+		t.Fatalf("queue: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = q.Close()
+		ns.Shutdown()
+	})
+	return q
+}
+
+func TestNATSExpiredLockCanBeTaken(t *testing.T) {
+	q := newTestNATSQueue(t)
+	ctx := context.Background()
+
+	if ok, err := q.AcquireCloneLock(ctx, "abc", "owner-1", 50*time.Millisecond); err != nil || !ok {
+		t.Fatalf("acquire: %v %v", ok, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if ok, err := q.AcquireCloneLock(ctx, "abc", "owner-2", time.Minute); err != nil || !ok {
+		t.Fatalf("expected expired lock to be taken over: %v %v", ok, err)
+	}
+	if err := q.RenewCloneLock(ctx, "abc", "owner-1", time.Minute); !errors.Is(err, ErrCloneLockNotOwned) {
+		t.Fatalf("expected previous owner to lose the lock, got %v", err)
+	}
+}
+
+func TestNATSLeaderLease(t *testing.T) {
+	q := newTestNATSQueue(t)
+	ctx := context.Background()
+
+	if ok, err := q.AcquireLeaderLease(ctx, "scheduler", "replica-a", 50*time.Millisecond); err != nil || !ok {
+		t.Fatalf("acquire: %v %v", ok, err)
+	}
+	lease, err := q.GetLeaderLease(ctx, "scheduler")
+	if err != nil || lease == nil || lease.Owner != "replica-a" {
+		t.Fatalf("unexpected lease %+v (%v)", lease, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if lease, err := q.GetLeaderLease(ctx, "scheduler"); err != nil || lease != nil {
+		t.Fatalf("expected expired lease to be reported as free, got %+v (%v)", lease, err)
+	}
+	if ok, err := q.AcquireLeaderLease(ctx, "scheduler", "replica-b", time.Minute); err != nil || !ok {
+		t.Fatalf("expected takeover of expired lease: %v %v", ok, err)
+	}
+	if err := q.RenewLeaderLease(ctx, "scheduler", "replica-a", time.Minute); !errors.Is(err, ErrLeaderLeaseNotOwned) {
+		t.Fatalf("expected previous leader to lose the lease, got %v", err)
+	}
+}
+
+func TestNATSRecoverStaleStackScan(t *testing.T) {
+	q := newTestNATSQueue(t)
+	ctx := context.Background()
+
+	job := &StackScan{ProjectName: "project", StackPath: "envs/dev", MaxRetries: 1}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	running := dequeueWithin(t, q, "worker-1")
+	running.StartedAt = time.Now().Add(-time.Hour)
+	if err := q.saveStackScan(ctx, running); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := q.index.Put(ctx, natsRunningStackKey(running.ID), []byte("1")); err != nil {
+		t.Fatalf("index: %v", err)
+	}
+
+	recovered, err := q.RecoverStaleStackScans(ctx, time.Minute)
+	if err != nil || recovered != 1 {
+		t.Fatalf("expected 1 recovered, got %d (%v)", recovered, err)
+	}
+	retry := dequeueWithin(t, q, "worker-2")
+	if retry.ID != job.ID || retry.Retries != 1 {
+		t.Fatalf("expected the stale stack scan to be retried, got %+v", retry)
+	}
+}
+
+func TestNATSSnapshotNotSupported(t *testing.T) {
+	q := newTestNATSQueue(t)
+	if _, err := q.Snapshot(context.Background()); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected ErrNotSupported, got %v", err)
+	}
+}
+
+func TestNATSKeyMemoryUsage(t *testing.T) {
+	q := newTestNATSQueue(t)
+	ctx := context.Background()
+
+	if err := q.Enqueue(ctx, &StackScan{ProjectName: "project", StackPath: "envs/dev"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := q.PublishStackEvent(ctx, "project", StackEvent{StackPath: "envs/dev", Status: StatusPending}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	report, err := q.KeyMemoryUsage(ctx, 1)
+	if err != nil {
+		t.Fatalf("key memory usage: %v", err)
+	}
+	usage := map[string]KeyFamilyUsage{}
+	for _, f := range report.Families {
+		usage[f.Family] = f
+	}
+	for _, family := range []string{KeyFamilyStackScans, KeyFamilyQueues, KeyFamilyEvents} {
+		if usage[family].Keys == 0 || usage[family].EstimatedBytes == 0 {
+			t.Fatalf("expected %s to be reported, got %+v", family, usage[family])
+		}
+	}
+	if len(report.Families) != 6 || report.EstimatedBytes == 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+}
+
+func TestNATSSubscribeScanCancels(t *testing.T) {
+	q := newTestNATSQueue(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cancels, err := q.SubscribeScanCancels(ctx)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	if err := q.PublishScanEvent(ctx, "project", ScanEvent{ScanID: "scan-1", Status: ScanStatusCanceled}); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+
+	select {
+	case got := <-cancels:
+		if got.ScanID != "scan-1" || got.ProjectName != "project" {
+			t.Fatalf("unexpected cancel %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for scan cancel")
+	}
+}
@@ -0,0 +1,216 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	keyOutbox        = "driftd:outbox"
+	keyOutboxOffsets = "driftd:outbox:offsets"
+
+	// outboxMaxLen bounds the outbox. Redis trims it approximately, so a
+	// few more entries may be kept.
+	outboxMaxLen = 100000
+	// outboxScanBatch is how many entries ReadOutbox reads per round trip
+	// while filtering by project.
+	outboxScanBatch = 500
+)
+
+var (
+	// ErrInvalidOutboxID is returned for an offset that is not an outbox
+	// event ID.
+	ErrInvalidOutboxID = errors.New("invalid outbox event id")
+
+	outboxIDPattern = regexp.MustCompile(`^[0-9]+-[0-9]+$`)
+)
+
+// OutboxEvent is a project event as recorded in the outbox. IDs increase in
+// publish order; consumers commit the ID of the last event they handled.
+type OutboxEvent struct {
+	ID    string       `json:"id"`
+	Event ProjectEvent `json:"event"`
+}
+
+// OutboxConsumer is a named consumer and the last event ID it committed.
+type OutboxConsumer struct {
+	Name   string `json:"name"`
+	Offset string `json:"offset"`
+	// CaughtUp is set when no event was published after Offset.
+	CaughtUp bool `json:"caught_up"`
+}
+
+// OutboxStatus describes the outbox and its consumers.
+type OutboxStatus struct {
+	Length    int64            `json:"length"`
+	FirstID   string           `json:"first_id,omitempty"`
+	LastID    string           `json:"last_id,omitempty"`
+	Consumers []OutboxConsumer `json:"consumers"`
+}
+
+// outboxID is an outbox event ID in the Redis stream form
+// "<milliseconds>-<sequence>".
+type outboxID struct {
+	ms, seq uint64
+}
+
+func (id outboxID) String() string {
+	return strconv.FormatUint(id.ms, 10) + "-" + strconv.FormatUint(id.seq, 10)
+}
+
+func (id outboxID) after(other outboxID) bool {
+	return id.ms > other.ms || (id.ms == other.ms && id.seq > other.seq)
+}
+
+func parseOutboxID(s string) (outboxID, error) {
+	if !outboxIDPattern.MatchString(s) {
+		return outboxID{}, ErrInvalidOutboxID
+	}
+	msPart, seqPart, _ := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return outboxID{}, ErrInvalidOutboxID
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return outboxID{}, ErrInvalidOutboxID
+	}
+	return outboxID{ms: ms, seq: seq}, nil
+}
+
+// commitOutboxOffsetScript stores ARGV[2] as the offset of consumer ARGV[1]
+// unless the stored offset is already at or past it. Returns 1 when stored.
+var commitOutboxOffsetScript = redis.NewScript(`
+local current = redis.call('HGET', KEYS[1], ARGV[1])
+if current then
+  local cms, cseq = string.match(current, '^(%d+)-(%d+)$')
+  local nms, nseq = string.match(ARGV[2], '^(%d+)-(%d+)$')
+  cms, cseq, nms, nseq = tonumber(cms), tonumber(cseq), tonumber(nms), tonumber(nseq)
+  if nms < cms or (nms == cms and nseq <= cseq) then
+    return 0
+  end
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+return 1
+`)
+
+// appendOutbox records an encoded event in the outbox stream.
+func (q *Queue) appendOutbox(ctx context.Context, data []byte) error {
+	return q.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: keyOutbox,
+		MaxLen: q.outboxCap(),
+		Approx: true,
+		Values: map[string]any{"event": data},
+	}).Err()
+}
+
+// ReadOutbox returns up to limit events published after the event ID after,
+// oldest first, for one project or for every project when projectName is
+// empty. An empty after reads from the oldest retained event. next is the
+// last ID examined, which may be past the last event returned when other
+// projects' events were skipped; committing it is safe.
+func (q *Queue) ReadOutbox(ctx context.Context, projectName, after string, limit int) ([]OutboxEvent, string, error) {
+	if after == "" {
+		after = "0-0"
+	}
+	if !outboxIDPattern.MatchString(after) {
+		return nil, "", ErrInvalidOutboxID
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+	next := after
+	var events []OutboxEvent
+	for len(events) < limit {
+		count := int64(limit - len(events))
+		if projectName != "" {
+			count = outboxScanBatch
+		}
+		msgs, err := q.client.XRangeN(ctx, keyOutbox, "("+next, "+", count).Result()
+		if err != nil {
+			return nil, "", fmt.Errorf("read outbox: %w", err)
+		}
+		for _, msg := range msgs {
+			if len(events) == limit {
+				break
+			}
+			next = msg.ID
+			raw, _ := msg.Values["event"].(string)
+			var event ProjectEvent
+			if err := json.Unmarshal([]byte(raw), &event); err != nil {
+				continue
+			}
+			if projectName != "" && event.ProjectName != projectName {
+				continue
+			}
+			events = append(events, OutboxEvent{ID: msg.ID, Event: event})
+		}
+		if int64(len(msgs)) < count {
+			break
+		}
+	}
+	return events, next, nil
+}
+
+// OutboxOffset returns the last event ID consumer committed, or "" when it
+// has not committed one.
+func (q *Queue) OutboxOffset(ctx context.Context, consumer string) (string, error) {
+	offset, err := q.client.HGet(ctx, keyOutboxOffsets, consumer).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return offset, err
+}
+
+// CommitOutboxOffset records that consumer has handled every event up to
+// and including id. Offsets only move forward, so a late or repeated commit
+// of an older ID is ignored.
+func (q *Queue) CommitOutboxOffset(ctx context.Context, consumer, id string) error {
+	if !outboxIDPattern.MatchString(id) {
+		return ErrInvalidOutboxID
+	}
+	return commitOutboxOffsetScript.Run(ctx, q.client, []string{keyOutboxOffsets}, consumer, id).Err()
+}
+
+// DeleteOutboxConsumer forgets consumer's offset.
+func (q *Queue) DeleteOutboxConsumer(ctx context.Context, consumer string) error {
+	return q.client.HDel(ctx, keyOutboxOffsets, consumer).Err()
+}
+
+// OutboxStatus reports the retained range of the outbox and every
+// consumer's offset, sorted by name.
+func (q *Queue) OutboxStatus(ctx context.Context) (*OutboxStatus, error) {
+	status := &OutboxStatus{Consumers: []OutboxConsumer{}}
+	length, err := q.client.XLen(ctx, keyOutbox).Result()
+	if err != nil {
+		return nil, err
+	}
+	status.Length = length
+	if first, err := q.client.XRangeN(ctx, keyOutbox, "-", "+", 1).Result(); err == nil && len(first) > 0 {
+		status.FirstID = first[0].ID
+	}
+	if last, err := q.client.XRevRangeN(ctx, keyOutbox, "+", "-", 1).Result(); err == nil && len(last) > 0 {
+		status.LastID = last[0].ID
+	}
+	offsets, err := q.client.HGetAll(ctx, keyOutboxOffsets).Result()
+	if err != nil {
+		return nil, err
+	}
+	for name, offset := range offsets {
+		status.Consumers = append(status.Consumers, OutboxConsumer{
+			Name:     name,
+			Offset:   offset,
+			CaughtUp: status.LastID == "" || offset == status.LastID,
+		})
+	}
+	sort.Slice(status.Consumers, func(i, j int) bool { return status.Consumers[i].Name < status.Consumers[j].Name })
+	return status, nil
+}
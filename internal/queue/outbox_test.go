@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestOutboxReadsAndCommitsOffsets(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+
+		for _, event := range []StackEvent{
+			{ProjectName: "alpha", StackPath: "envs/a", Status: StatusCompleted},
+			{ProjectName: "beta", StackPath: "envs/b", Status: StatusCompleted},
+			{ProjectName: "alpha", StackPath: "envs/c", Status: StatusFailed},
+		} {
+			if err := q.PublishStackEvent(ctx, "", event); err != nil {
+				t.Fatalf("publish: %v", err)
+			}
+		}
+
+		all, next, err := q.ReadOutbox(ctx, "", "", 10)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if len(all) != 3 || all[0].Event.StackPath != "envs/a" || all[2].Event.StackPath != "envs/c" || next != all[2].ID {
+			t.Fatalf("unexpected outbox: %+v next %s", all, next)
+		}
+
+		alpha, next, err := q.ReadOutbox(ctx, "alpha", all[0].ID, 10)
+		if err != nil {
+			t.Fatalf("read alpha: %v", err)
+		}
+		if len(alpha) != 1 || alpha[0].Event.StackPath != "envs/c" || next != all[2].ID {
+			t.Fatalf("unexpected alpha events after first: %+v next %s", alpha, next)
+		}
+
+		page, next, err := q.ReadOutbox(ctx, "", "", 2)
+		if err != nil || len(page) != 2 || next != all[1].ID {
+			t.Fatalf("unexpected first page: %+v next %s err %v", page, next, err)
+		}
+
+		if err := q.CommitOutboxOffset(ctx, "mirror", all[1].ID); err != nil {
+			t.Fatalf("commit: %v", err)
+		}
+		// Offsets never move back.
+		if err := q.CommitOutboxOffset(ctx, "mirror", all[0].ID); err != nil {
+			t.Fatalf("commit older: %v", err)
+		}
+		offset, err := q.OutboxOffset(ctx, "mirror")
+		if err != nil || offset != all[1].ID {
+			t.Fatalf("offset = %q, %v; want %s", offset, err, all[1].ID)
+		}
+		if err := q.CommitOutboxOffset(ctx, "mirror", "latest"); !errors.Is(err, ErrInvalidOutboxID) {
+			t.Fatalf("expected ErrInvalidOutboxID, got %v", err)
+		}
+
+		status, err := q.OutboxStatus(ctx)
+		if err != nil {
+			t.Fatalf("status: %v", err)
+		}
+		if status.Length != 3 || status.FirstID != all[0].ID || status.LastID != all[2].ID {
+			t.Fatalf("unexpected status: %+v", status)
+		}
+		if len(status.Consumers) != 1 || status.Consumers[0].Name != "mirror" || status.Consumers[0].CaughtUp {
+			t.Fatalf("unexpected consumers: %+v", status.Consumers)
+		}
+
+		if err := q.DeleteOutboxConsumer(ctx, "mirror"); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		if offset, _ := q.OutboxOffset(ctx, "mirror"); offset != "" {
+			t.Fatalf("expected deleted consumer to have no offset, got %q", offset)
+		}
+	})
+}
@@ -1,12 +1,45 @@
 package queue
 
+// Stack scan priorities. Workers claim higher priority stack scans first.
+const (
+	PriorityLow    = 1
+	PriorityNormal = 2
+	PriorityHigh   = 3
+)
+
+// TriggerPriority is the built-in priority of scans started by trigger.
 func TriggerPriority(trigger string) int {
 	switch trigger {
-	case "scheduled", "cron":
-		return 1
+	case "scheduled", "cron", "chain":
+		return PriorityLow
 	case "manual", "webhook":
-		return 2
+		return PriorityNormal
 	default:
-		return 2
+		return PriorityNormal
+	}
+}
+
+// ParsePriority converts a configured priority name ("low", "normal" or
+// "high") to its value. Unknown names, including "", return 0.
+func ParsePriority(name string) int {
+	switch name {
+	case "low":
+		return PriorityLow
+	case "normal":
+		return PriorityNormal
+	case "high":
+		return PriorityHigh
+	}
+	return 0
+}
+
+// queueKeyFor returns the work list holding stack scans of priority.
+func queueKeyFor(priority int) string {
+	switch {
+	case priority >= PriorityHigh:
+		return keyQueueHigh
+	case priority == PriorityLow:
+		return keyQueueLow
 	}
+	return keyQueue
 }
@@ -1,6 +1,13 @@
 package queue
 
-import "testing"
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
 
 func TestTriggerPriority(t *testing.T) {
 	tests := []struct {
@@ -23,3 +30,133 @@ func TestTriggerPriority(t *testing.T) {
 		})
 	}
 }
+
+func TestDequeueClaimsHigherPriorityFirst(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	for _, job := range []*StackScan{
+		{ProjectName: "project", StackPath: "low", Priority: PriorityLow},
+		{ProjectName: "project", StackPath: "normal"},
+		{ProjectName: "project", StackPath: "high", Priority: PriorityHigh},
+	} {
+		if err := q.Enqueue(ctx, job); err != nil {
+			t.Fatalf("enqueue %s: %v", job.StackPath, err)
+		}
+	}
+
+	pending, err := q.ListPendingStackScans(ctx)
+	if err != nil {
+		t.Fatalf("list pending: %v", err)
+	}
+	var listed []string
+	for _, ss := range pending {
+		listed = append(listed, ss.StackPath)
+	}
+	if got := strings.Join(listed, ","); got != "high,normal,low" {
+		t.Fatalf("pending order = %s, want high,normal,low", got)
+	}
+	if depth, err := q.QueueDepth(ctx); err != nil || depth != 3 {
+		t.Fatalf("queue depth = %d, %v; want 3", depth, err)
+	}
+
+	for _, want := range []string{"high", "normal", "low"} {
+		deqCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+		ss, err := q.Dequeue(deqCtx, "worker-1")
+		cancel()
+		if err != nil {
+			t.Fatalf("dequeue: %v", err)
+		}
+		if ss.StackPath != want {
+			t.Fatalf("dequeued %s, want %s", ss.StackPath, want)
+		}
+	}
+}
+
+func TestDequeueHoldsStacksOfScanAtConcurrencyCap(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	scan, err := q.StartScan(ctx, "capped", "manual", "", "", 0)
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	if err := q.SetScanTotal(ctx, scan.ID, 2); err != nil {
+		t.Fatalf("set total: %v", err)
+	}
+	for _, job := range []*StackScan{
+		{ScanID: scan.ID, ProjectName: "capped", StackPath: "a", ScanConcurrency: 1},
+		{ScanID: scan.ID, ProjectName: "capped", StackPath: "b", ScanConcurrency: 1},
+		{ProjectName: "other", StackPath: "c"},
+	} {
+		if err := q.Enqueue(ctx, job); err != nil {
+			t.Fatalf("enqueue %s: %v", job.StackPath, err)
+		}
+	}
+
+	dequeue := func(timeout time.Duration) (*StackScan, error) {
+		deqCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return q.Dequeue(deqCtx, "worker-1")
+	}
+	first, err := dequeue(3 * time.Second)
+	if err != nil || first.StackPath != "a" {
+		t.Fatalf("first dequeue = %v, %v; want a", first, err)
+	}
+	// b waits for a, so the other project's stack is claimed past it.
+	second, err := dequeue(3 * time.Second)
+	if err != nil || second.StackPath != "c" {
+		t.Fatalf("second dequeue = %v, %v; want c", second, err)
+	}
+	if _, err := dequeue(1500 * time.Millisecond); err == nil {
+		t.Fatal("expected b to stay queued while a runs")
+	}
+
+	if err := q.Complete(ctx, first, false); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+	third, err := dequeue(3 * time.Second)
+	if err != nil || third.StackPath != "b" {
+		t.Fatalf("third dequeue = %v, %v; want b", third, err)
+	}
+}
+
+func TestDequeueCountsRunningStackScanInClaim(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	scan, err := q.StartScan(ctx, "project", "manual", "", "", 4)
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	for _, stack := range []string{"a", "b", "c", "d"} {
+		if err := q.Enqueue(ctx, &StackScan{ScanID: scan.ID, ProjectName: "project", StackPath: stack, ScanConcurrency: 1}); err != nil {
+			t.Fatalf("enqueue %s: %v", stack, err)
+		}
+	}
+
+	claimCtx, cancel := context.WithTimeout(ctx, 500*time.Millisecond)
+	defer cancel()
+	var claimed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := q.Dequeue(claimCtx, "worker"); err == nil {
+				claimed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := claimed.Load(); n != 1 {
+		t.Fatalf("expected 1 claim under a concurrency of 1, got %d", n)
+	}
+	got, err := q.GetScan(ctx, scan.ID)
+	if err != nil {
+		t.Fatalf("get scan: %v", err)
+	}
+	if got.Running != 1 || got.Queued != 3 {
+		t.Fatalf("expected 1 running and 3 queued, got %d and %d", got.Running, got.Queued)
+	}
+}
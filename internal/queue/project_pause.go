@@ -0,0 +1,112 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// keyPausedProjects maps each paused project to its ProjectPause.
+	keyPausedProjects = "driftd:projects:paused"
+	// keyPausedQueuePrefix holds, per paused project, the stack scans
+	// workers popped while it was paused, newest first.
+	keyPausedQueuePrefix = "driftd:queue:paused:"
+)
+
+// ProjectPause records who paused a project's stack scans and why. While a
+// project is paused its stack scans stay queued but no worker claims them.
+type ProjectPause struct {
+	Project  string    `json:"project"`
+	Actor    string    `json:"actor,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+	PausedAt time.Time `json:"paused_at"`
+}
+
+// resumeProjectScript clears a project's pause and returns its parked stack
+// scans to the work list of their priority (KEYS[3] low, KEYS[4] normal,
+// KEYS[5] high), oldest at the end workers pop from.
+var resumeProjectScript = redis.NewScript(`
+local existed = redis.call('HDEL', KEYS[1], ARGV[1])
+local ids = redis.call('LRANGE', KEYS[2], 0, -1)
+for i = 1, #ids do
+  local list = KEYS[4]
+  local data = redis.call('GET', ARGV[2] .. ids[i])
+  if data then
+    local priority = tonumber(cjson.decode(data)['priority']) or 0
+    if priority == 1 then
+      list = KEYS[3]
+    elseif priority >= 3 then
+      list = KEYS[5]
+    end
+  end
+  redis.call('RPUSH', list, ids[i])
+end
+redis.call('DEL', KEYS[2])
+return existed
+`)
+
+// PauseProject stops workers claiming the project's stack scans until
+// ResumeProject. Pausing an already paused project replaces its record.
+func (q *Queue) PauseProject(ctx context.Context, pause ProjectPause) error {
+	if pause.PausedAt.IsZero() {
+		pause.PausedAt = time.Now()
+	}
+	data, err := json.Marshal(pause)
+	if err != nil {
+		return fmt.Errorf("marshal project pause: %w", err)
+	}
+	return q.client.HSet(ctx, keyPausedProjects, pause.Project, data).Err()
+}
+
+// ResumeProject lets workers claim the project's stack scans again. It
+// reports whether the project was paused.
+func (q *Queue) ResumeProject(ctx context.Context, projectName string) (bool, error) {
+	existed, err := resumeProjectScript.Run(ctx, q.client,
+		[]string{keyPausedProjects, keyPausedQueuePrefix + projectName, keyQueueLow, keyQueue, keyQueueHigh},
+		projectName,
+		keyStackScanPrefix,
+	).Int64()
+	if err != nil {
+		return false, err
+	}
+	return existed == 1, nil
+}
+
+// GetProjectPause returns the project's pause, or nil when it is not paused.
+func (q *Queue) GetProjectPause(ctx context.Context, projectName string) (*ProjectPause, error) {
+	data, err := q.client.HGet(ctx, keyPausedProjects, projectName).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var pause ProjectPause
+	if err := json.Unmarshal(data, &pause); err != nil {
+		return nil, fmt.Errorf("decode project pause: %w", err)
+	}
+	return &pause, nil
+}
+
+// ListPausedProjects returns every paused project, by name.
+func (q *Queue) ListPausedProjects(ctx context.Context) ([]ProjectPause, error) {
+	values, err := q.client.HGetAll(ctx, keyPausedProjects).Result()
+	if err != nil {
+		return nil, err
+	}
+	pauses := make([]ProjectPause, 0, len(values))
+	for _, data := range values {
+		var pause ProjectPause
+		if err := json.Unmarshal([]byte(data), &pause); err != nil {
+			continue
+		}
+		pauses = append(pauses, pause)
+	}
+	sort.Slice(pauses, func(i, j int) bool { return pauses[i].Project < pauses[j].Project })
+	return pauses, nil
+}
@@ -0,0 +1,59 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPausedProjectParksCompressedStackScans(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	if err := q.PauseProject(ctx, ProjectPause{Project: "project"}); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	stackScan := &StackScan{
+		ProjectName: "project",
+		StackPath:   "envs/prod",
+		PlanArgs:    []string{strings.Repeat("-var=a=b ", 2000)},
+	}
+	if err := q.Enqueue(ctx, stackScan); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, 1500*time.Millisecond)
+	_, err := q.Dequeue(waitCtx, "worker-1")
+	cancel()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected no claimable stack scan, got %v", err)
+	}
+	if depth, _ := q.QueueDepth(ctx); depth != 0 {
+		t.Fatalf("expected main queue empty, got %d", depth)
+	}
+	parked, err := q.client.LRange(ctx, keyPausedQueuePrefix+"project", 0, -1).Result()
+	if err != nil || len(parked) != 1 || parked[0] != stackScan.ID {
+		t.Fatalf("expected stack scan parked, got %v (%v)", parked, err)
+	}
+
+	// A recovery pass can push the parked ID again; it is parked once.
+	if _, err := q.RecoverOrphanedStackScans(ctx); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	waitCtx, cancel = context.WithTimeout(ctx, 1500*time.Millisecond)
+	_, _ = q.Dequeue(waitCtx, "worker-1")
+	cancel()
+	if n, _ := q.client.LLen(ctx, keyPausedQueuePrefix+"project").Result(); n != 1 {
+		t.Fatalf("expected one parked entry, got %d", n)
+	}
+
+	if _, err := q.ResumeProject(ctx, "project"); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	job := dequeueWithin(t, q, "worker-1")
+	if job.ID != stackScan.ID || job.Status != StatusRunning {
+		t.Fatalf("expected parked stack scan claimed, got %+v", job)
+	}
+}
@@ -0,0 +1,62 @@
+package queue
+
+import "time"
+
+// Retention bounds how long, or how many entries of, each Redis key family
+// is kept. Zero fields keep the defaults. Lower values shrink the Redis
+// footprint at the cost of history: scans and stack scans past their
+// retention disappear from the API, and trimmed drift changes and outbox
+// events can no longer be read.
+type Retention struct {
+	// Scans is how long finished scans and their project indexes are kept.
+	Scans time.Duration
+	// ScanHistory caps how many recent scans are indexed per project.
+	ScanHistory int
+	// StackScans is how long stack scans are kept.
+	StackScans time.Duration
+	// DriftChanges caps the drift change log of each project.
+	DriftChanges int
+	// OutboxEvents caps the event outbox stream.
+	OutboxEvents int64
+}
+
+// SetRetention sets the retention of newly written keys. Keys already in
+// Redis keep the expiry they were written with.
+func (q *Queue) SetRetention(r Retention) {
+	q.retention = r
+}
+
+func (q *Queue) scanTTL() time.Duration {
+	if q.retention.Scans > 0 {
+		return q.retention.Scans
+	}
+	return scanRetention
+}
+
+func (q *Queue) scanHistoryCap() int {
+	if q.retention.ScanHistory > 0 {
+		return q.retention.ScanHistory
+	}
+	return scanHistoryLimit
+}
+
+func (q *Queue) stackScanTTL() time.Duration {
+	if q.retention.StackScans > 0 {
+		return q.retention.StackScans
+	}
+	return stackScanRetention
+}
+
+func (q *Queue) driftChangesCap() int {
+	if q.retention.DriftChanges > 0 {
+		return q.retention.DriftChanges
+	}
+	return maxDriftChanges
+}
+
+func (q *Queue) outboxCap() int64 {
+	if q.retention.OutboxEvents > 0 {
+		return q.retention.OutboxEvents
+	}
+	return outboxMaxLen
+}
@@ -0,0 +1,63 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+const scanCancelChannel = "driftd:scans:canceled"
+
+// ScanCancel announces that a scan was canceled, so workers can stop the
+// stack scans of it they are running.
+type ScanCancel struct {
+	ScanID      string `json:"scan_id"`
+	ProjectName string `json:"project"`
+}
+
+// publishScanCancel is called for every canceled scan event. Delivery is
+// best effort; workers also poll the scan status while planning.
+func (q *Queue) publishScanCancel(ctx context.Context, projectName, scanID string) error {
+	data, err := json.Marshal(ScanCancel{ScanID: scanID, ProjectName: projectName})
+	if err != nil {
+		return fmt.Errorf("marshal scan cancel: %w", err)
+	}
+	return q.client.Publish(ctx, scanCancelChannel, data).Err()
+}
+
+// SubscribeScanCancels delivers scan cancellations until ctx is canceled.
+// The returned channel is ready once the subscription is confirmed.
+func (q *Queue) SubscribeScanCancels(ctx context.Context) (<-chan ScanCancel, error) {
+	pubsub := q.client.Subscribe(ctx, scanCancelChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	out := make(chan ScanCancel)
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				var cancel ScanCancel
+				if err := json.Unmarshal([]byte(msg.Payload), &cancel); err != nil || cancel.ScanID == "" {
+					continue
+				}
+				select {
+				case out <- cancel:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,45 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSubscribeScanCancels(t *testing.T) {
+	q := newTestQueue(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cancels, err := q.SubscribeScanCancels(ctx)
+	if err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	scan, err := q.StartScan(ctx, "project", "manual", "", "", 1)
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	if err := q.CancelScan(ctx, scan.ID, "project", "test cancel"); err != nil {
+		t.Fatalf("cancel scan: %v", err)
+	}
+
+	select {
+	case got := <-cancels:
+		if got.ScanID != scan.ID || got.ProjectName != "project" {
+			t.Fatalf("unexpected cancel %+v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for scan cancel")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-cancels:
+		if ok {
+			t.Fatal("expected channel to close after ctx cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel not closed after ctx cancel")
+	}
+}
@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// addScanHistory indexes a new scan under its project, keeping the newest
+// entries up to the scan history limit.
+func (q *Queue) addScanHistory(ctx context.Context, pipe redis.Pipeliner, scan *Scan) {
+	key := keyScanHistory + scan.ProjectName
+	pipe.ZAdd(ctx, key, redis.Z{
+		Score:  float64(scan.StartedAt.UnixNano()),
+		Member: scan.ID,
+	})
+	pipe.ZRemRangeByRank(ctx, key, 0, -int64(q.scanHistoryCap()+1))
+	pipe.Expire(ctx, key, q.scanTTL())
+}
+
+// ListProjectScans returns a project's most recent scans, newest first.
+// Expired scans are skipped.
+func (q *Queue) ListProjectScans(ctx context.Context, projectName string, limit int) ([]*Scan, error) {
+	stop := int64(-1)
+	if limit > 0 {
+		stop = int64(limit - 1)
+	}
+	scanIDs, err := q.client.ZRevRange(ctx, keyScanHistory+projectName, 0, stop).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to list scan IDs: %w", err)
+	}
+
+	pipe := q.client.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(scanIDs))
+	for i, id := range scanIDs {
+		cmds[i] = pipe.HGetAll(ctx, keyScanPrefix+id)
+	}
+	if len(cmds) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+			return nil, fmt.Errorf("failed to fetch scans: %w", err)
+		}
+	}
+
+	var scans []*Scan
+	for _, cmd := range cmds {
+		values, err := cmd.Result()
+		if err != nil || len(values) == 0 {
+			continue // scan expired
+		}
+		scan, err := scanFromHash(values)
+		if err != nil {
+			continue
+		}
+		scans = append(scans, scan)
+	}
+	return scans, nil
+}
+
+// ListScanStackScans returns the stack scans enqueued for a scan, in no
+// particular order. Expired stack scans are skipped.
+func (q *Queue) ListScanStackScans(ctx context.Context, scanID string) ([]*StackScan, error) {
+	stackScanIDs, err := q.client.SMembers(ctx, keyScanStackScans+scanID).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stack scan IDs: %w", err)
+	}
+	var stackScans []*StackScan
+	for _, id := range stackScanIDs {
+		stackScan, err := q.GetStackScan(ctx, id)
+		if err != nil {
+			continue
+		}
+		stackScans = append(stackScans, stackScan)
+	}
+	return stackScans, nil
+}
@@ -2,6 +2,7 @@ package queue
 
 import (
 	"context"
+	"slices"
 	"time"
 )
 
@@ -50,3 +51,41 @@ func (q *Queue) OldestRunningScanAge(ctx context.Context) (time.Duration, error)
 	}
 	return time.Since(startedAt), nil
 }
+
+// OldestPendingStackScanAge is how long the oldest unclaimed stack scan has
+// waited since it was enqueued. Stack scans of paused projects are left out.
+func (q *Queue) OldestPendingStackScanAge(ctx context.Context) (time.Duration, error) {
+	paused, err := q.client.HKeys(ctx, keyPausedProjects).Result()
+	if err != nil {
+		return 0, err
+	}
+	var cursor uint64
+	var oldest time.Time
+	for {
+		ids, next, err := q.client.SScan(ctx, keyStackScanPending, cursor, "*", 200).Result()
+		if err != nil {
+			return 0, err
+		}
+		for _, id := range ids {
+			stackScan, err := q.GetStackScan(ctx, id)
+			if err != nil || stackScan.Status != StatusPending || slices.Contains(paused, stackScan.ProjectName) {
+				continue
+			}
+			if oldest.IsZero() || stackScan.CreatedAt.Before(oldest) {
+				oldest = stackScan.CreatedAt
+			}
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	return pendingAge(oldest), nil
+}
+
+func pendingAge(oldest time.Time) time.Duration {
+	if oldest.IsZero() || oldest.After(time.Now()) {
+		return 0
+	}
+	return time.Since(oldest)
+}
@@ -0,0 +1,100 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Setup phases recorded on a scan by the orchestrator, in execution order.
+const (
+	// PhaseLock covers scan start limits and acquiring the project lock,
+	// including cancelling a superseded scan.
+	PhaseLock = "lock"
+	// PhaseClone covers syncing the mirror and checking out the workspace.
+	PhaseClone = "clone"
+	// PhaseSecrets covers the committed secrets check of projects with
+	// secret_scan enabled.
+	PhaseSecrets = "secrets"
+	// PhaseDiscover covers stack discovery in the workspace.
+	PhaseDiscover = "discover"
+	// PhaseVersions covers Terraform and Terragrunt version detection.
+	PhaseVersions = "versions"
+	// PhaseEnqueue covers enqueueing the scan's stack scans.
+	PhaseEnqueue = "enqueue"
+)
+
+const scanPhaseFieldPrefix = "phase:"
+
+// ScanPhase is the wall-clock span of one setup phase of a scan.
+type ScanPhase struct {
+	Name      string    `json:"name"`
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+}
+
+// Duration returns how long the phase took.
+func (p ScanPhase) Duration() time.Duration {
+	return p.EndedAt.Sub(p.StartedAt)
+}
+
+// RecordScanPhases stores phase timings on a scan. A phase recorded again
+// replaces the earlier timing.
+func (q *Queue) RecordScanPhases(ctx context.Context, scanID string, phases ...ScanPhase) error {
+	if len(phases) == 0 {
+		return nil
+	}
+	fields := make(map[string]any, len(phases))
+	for _, phase := range phases {
+		data, err := json.Marshal(phase)
+		if err != nil {
+			return err
+		}
+		fields[scanPhaseFieldPrefix+phase.Name] = string(data)
+	}
+	return q.client.HSet(ctx, keyScanPrefix+scanID, fields).Err()
+}
+
+// scanPhasesFromHash decodes the phase fields of a scan hash, ordered by
+// start time.
+func scanPhasesFromHash(values map[string]string) []ScanPhase {
+	var phases []ScanPhase
+	for field, raw := range values {
+		if !strings.HasPrefix(field, scanPhaseFieldPrefix) {
+			continue
+		}
+		var phase ScanPhase
+		if json.Unmarshal([]byte(raw), &phase) == nil {
+			phases = append(phases, phase)
+		}
+	}
+	sortScanPhases(phases)
+	return phases
+}
+
+// mergeScanPhases replaces phases in existing by name and appends new ones.
+func mergeScanPhases(existing []ScanPhase, phases []ScanPhase) []ScanPhase {
+	for _, phase := range phases {
+		replaced := false
+		for i := range existing {
+			if existing[i].Name == phase.Name {
+				existing[i] = phase
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			existing = append(existing, phase)
+		}
+	}
+	sortScanPhases(existing)
+	return existing
+}
+
+func sortScanPhases(phases []ScanPhase) {
+	sort.SliceStable(phases, func(i, j int) bool {
+		return phases[i].StartedAt.Before(phases[j].StartedAt)
+	})
+}
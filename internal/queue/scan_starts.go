@@ -0,0 +1,47 @@
+package queue
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// acquireScanStartScript increments a counter unless it already reached
+// ARGV[1]. The first increment sets the expiry to ARGV[2] milliseconds.
+var acquireScanStartScript = redis.NewScript(`
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+if count >= tonumber(ARGV[1]) then
+  return 0
+end
+redis.call('INCR', KEYS[1])
+if redis.call('PTTL', KEYS[1]) < 0 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 1
+`)
+
+var releaseScanStartScript = redis.NewScript(`
+local count = tonumber(redis.call('GET', KEYS[1]) or '0')
+if count > 0 then
+  redis.call('DECR', KEYS[1])
+end
+return 1
+`)
+
+// AcquireScanStart counts one scan start against the counter at key and
+// reports false, without counting, once limit starts were counted. The
+// counter expires ttl after its first start.
+func (q *Queue) AcquireScanStart(ctx context.Context, key string, limit int, ttl time.Duration) (bool, error) {
+	ok, err := acquireScanStartScript.Run(ctx, q.client, []string{keyScanStartsPrefix + key}, limit, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return ok == 1, nil
+}
+
+// ReleaseScanStart gives back a start counted by AcquireScanStart for a
+// scan that did not start after all.
+func (q *Queue) ReleaseScanStart(ctx context.Context, key string) error {
+	return releaseScanStartScript.Run(ctx, q.client, []string{keyScanStartsPrefix + key}).Err()
+}
@@ -0,0 +1,43 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBackendScanStarts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+		for i := 0; i < 2; i++ {
+			ok, err := q.AcquireScanStart(ctx, "project:infra:manual:0", 2, time.Hour)
+			if err != nil || !ok {
+				t.Fatalf("acquire %d: ok=%v err=%v", i, ok, err)
+			}
+		}
+		ok, err := q.AcquireScanStart(ctx, "project:infra:manual:0", 2, time.Hour)
+		if err != nil {
+			t.Fatalf("acquire over limit: %v", err)
+		}
+		if ok {
+			t.Fatalf("expected third start to be refused")
+		}
+
+		ok, err = q.AcquireScanStart(ctx, "project:other:manual:0", 2, time.Hour)
+		if err != nil || !ok {
+			t.Fatalf("expected other key to have its own budget: ok=%v err=%v", ok, err)
+		}
+
+		if err := q.ReleaseScanStart(ctx, "project:infra:manual:0"); err != nil {
+			t.Fatalf("release: %v", err)
+		}
+		ok, err = q.AcquireScanStart(ctx, "project:infra:manual:0", 2, time.Hour)
+		if err != nil || !ok {
+			t.Fatalf("expected start after release: ok=%v err=%v", ok, err)
+		}
+
+		if err := q.ReleaseScanStart(ctx, "project:missing:manual:0"); err != nil {
+			t.Fatalf("release missing key: %v", err)
+		}
+	})
+}
@@ -6,8 +6,10 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/driftdhq/driftd/internal/storage"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -85,6 +87,11 @@ type Scan struct {
 	StackTGVersions   map[string]string `json:"stack_tg_versions,omitempty"`
 	WorkspacePath     string            `json:"workspace_path,omitempty"`
 	CommitSHA         string            `json:"commit_sha,omitempty"`
+	// CommitSkewed is set when the trigger named a commit (Commit) but the
+	// workspace was checked out at a different one (CommitSHA).
+	CommitSkewed bool `json:"commit_skewed,omitempty"`
+	// CommitInfo describes CommitSHA: its author, summary and time.
+	CommitInfo *storage.CommitInfo `json:"commit_info,omitempty"`
 
 	Total     int `json:"total"`
 	Queued    int `json:"queued"`
@@ -93,6 +100,18 @@ type Scan struct {
 	Failed    int `json:"failed"`
 	Drifted   int `json:"drifted"`
 	Errored   int `json:"errored"`
+	// SkippedStale counts stacks a scheduled scan left out because nothing
+	// changed them within the project's skip_untouched_days.
+	SkippedStale int `json:"skipped_stale,omitempty"`
+	// Phases holds the timings of the scan's setup phases, in order.
+	Phases []ScanPhase `json:"phases,omitempty"`
+	// Warnings are workspace problems found before planning, such as
+	// committed secrets.
+	Warnings []ScanWarning `json:"warnings,omitempty"`
+	// RetryOf is the failed scan this scan automatically retries, and
+	// RetryScanID the retry started for this scan.
+	RetryOf     string `json:"retry_of,omitempty"`
+	RetryScanID string `json:"retry_scan_id,omitempty"`
 }
 
 func (q *Queue) StartScan(ctx context.Context, projectName, trigger, commit, actor string, total int) (*Scan, error) {
@@ -152,8 +171,9 @@ func (q *Queue) StartScan(ctx context.Context, projectName, trigger, commit, act
 		"workspace":  "",
 		"commit_sha": "",
 	})
-	pipe.Expire(ctx, scanKey, scanRetention)
-	pipe.Set(ctx, keyScanRepo+projectName, scanID, scanRetention)
+	pipe.Expire(ctx, scanKey, q.scanTTL())
+	pipe.Set(ctx, keyScanRepo+projectName, scanID, q.scanTTL())
+	q.addScanHistory(ctx, pipe, scan)
 	pipe.ZAdd(ctx, keyRunningScans, redis.Z{
 		Score:  float64(scan.StartedAt.Unix()),
 		Member: scan.ID,
@@ -177,7 +197,7 @@ func (q *Queue) CancelAndStartScan(ctx context.Context, oldScanID, projectName,
 
 	newScanID := fmt.Sprintf("%s:%d", projectName, time.Now().UnixNano())
 	endedAt := time.Now().Unix()
-	retentionSeconds := int(scanRetention.Seconds())
+	retentionSeconds := int(q.scanTTL().Seconds())
 
 	result, err := cancelAndAcquireScript.Run(ctx, q.client,
 		[]string{
@@ -252,7 +272,8 @@ func (q *Queue) CancelAndStartScan(ctx context.Context, oldScanID, projectName,
 		"workspace":  "",
 		"commit_sha": "",
 	})
-	pipe.Expire(ctx, scanKey, scanRetention)
+	pipe.Expire(ctx, scanKey, q.scanTTL())
+	q.addScanHistory(ctx, pipe, scan)
 	pipe.ZAdd(ctx, keyRunningScans, redis.Z{
 		Score:  float64(scan.StartedAt.Unix()),
 		Member: scan.ID,
@@ -371,6 +392,28 @@ func (q *Queue) SetScanWorkspace(ctx context.Context, scanID, workspacePath, com
 	return err
 }
 
+func (q *Queue) SetScanCommitInfo(ctx context.Context, scanID string, info *storage.CommitInfo) error {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("marshal commit info: %w", err)
+	}
+	return q.client.HSet(ctx, keyScanPrefix+scanID, "commit_info", string(data)).Err()
+}
+
+func (q *Queue) SetScanSkippedStale(ctx context.Context, scanID string, skipped int) error {
+	return q.client.HSet(ctx, keyScanPrefix+scanID, "skipped_stale", skipped).Err()
+}
+
+// LinkScanRetry records retryScanID as the automatic retry of scanID on
+// both scans.
+func (q *Queue) LinkScanRetry(ctx context.Context, scanID, retryScanID string) error {
+	pipe := q.client.Pipeline()
+	pipe.HSet(ctx, keyScanPrefix+scanID, "retry_scan_id", retryScanID)
+	pipe.HSet(ctx, keyScanPrefix+retryScanID, "retry_of", scanID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
 func (q *Queue) FailScan(ctx context.Context, scanID, projectName, errMsg string) error {
 	scanKey := keyScanPrefix + scanID
 	endedAt := time.Now()
@@ -412,7 +455,7 @@ func (q *Queue) CancelScan(ctx context.Context, scanID, projectName, reason stri
 		"error":    reason,
 	})
 	pipe.Del(ctx, keyScanRepo+projectName)
-	pipe.Set(ctx, keyScanLast+projectName, scanID, scanRetention)
+	pipe.Set(ctx, keyScanLast+projectName, scanID, q.scanTTL())
 	pipe.ZRem(ctx, keyRunningScans, scanID)
 	if _, err := pipe.Exec(ctx); err != nil {
 		return err
@@ -486,8 +529,22 @@ func scanFromHash(values map[string]string) (*Scan, error) {
 		Failed:            toInt(values["failed"]),
 		Drifted:           toInt(values["drifted"]),
 		Errored:           toInt(values["errored"]),
+		SkippedStale:      toInt(values["skipped_stale"]),
+		RetryOf:           values["retry_of"],
+		RetryScanID:       values["retry_scan_id"],
 	}
 
+	scan.CommitSkewed = CommitSkewed(scan.Commit, scan.CommitSHA)
+	if raw := values["commit_info"]; raw != "" {
+		var info storage.CommitInfo
+		if json.Unmarshal([]byte(raw), &info) == nil {
+			scan.CommitInfo = &info
+		}
+	}
+	scan.Phases = scanPhasesFromHash(values)
+	if raw := values["warnings"]; raw != "" {
+		_ = json.Unmarshal([]byte(raw), &scan.Warnings)
+	}
 	scan.CreatedAt = time.Unix(toInt64(values["created_at"]), 0)
 	scan.StartedAt = time.Unix(toInt64(values["started_at"]), 0)
 	scan.EndedAt = time.Unix(toInt64(values["ended_at"]), 0)
@@ -495,6 +552,17 @@ func scanFromHash(values map[string]string) (*Scan, error) {
 	return scan, nil
 }
 
+// CommitSkewed reports whether a requested commit differs from the commit a
+// workspace resolved to. Requested commits may be abbreviated.
+func CommitSkewed(requested, resolved string) bool {
+	requested = strings.ToLower(strings.TrimSpace(requested))
+	resolved = strings.ToLower(strings.TrimSpace(resolved))
+	if requested == "" || resolved == "" {
+		return false
+	}
+	return !strings.HasPrefix(resolved, requested)
+}
+
 func toInt(value any) int {
 	switch v := value.(type) {
 	case nil:
@@ -891,3 +891,23 @@ func TestCancelAndStartScanRejectsWrongOwner(t *testing.T) {
 		t.Fatalf("expected ErrProjectLocked, got %v", err)
 	}
 }
+
+func TestCommitSkewed(t *testing.T) {
+	full := "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		requested, resolved string
+		want                bool
+	}{
+		{"", full, false},
+		{full, "", false},
+		{full, full, false},
+		{"0123456", full, false},
+		{"0123456789ABCDEF0123456789ABCDEF01234567", full, false},
+		{"fedcba9", full, true},
+	}
+	for _, tt := range tests {
+		if got := CommitSkewed(tt.requested, tt.resolved); got != tt.want {
+			t.Fatalf("CommitSkewed(%q, %q) = %v, want %v", tt.requested, tt.resolved, got, tt.want)
+		}
+	}
+}
@@ -110,6 +110,16 @@ func (q *Queue) markScanStackScanRunning(ctx context.Context, scanID string) err
 	return q.runScanTransition(ctx, scanID, projectName, "running", 1, "queued", -1)
 }
 
+// publishScanProgress announces the scan's current counters without
+// changing them.
+func (q *Queue) publishScanProgress(ctx context.Context, scanID string) error {
+	projectName, err := q.projectNameForScan(ctx, scanID)
+	if err != nil {
+		return err
+	}
+	return q.runScanTransition(ctx, scanID, projectName)
+}
+
 func (q *Queue) markScanStackScanRetry(ctx context.Context, scanID string) error {
 	projectName, err := q.projectNameForScan(ctx, scanID)
 	if err != nil {
@@ -163,15 +173,20 @@ func (q *Queue) MarkScanEnqueueSkipped(ctx context.Context, scanID string) error
 }
 
 func (q *Queue) publishScanUpdateFromState(ctx context.Context, scanID, projectName string, state scanTransitionState) {
+	var changes []DriftChange
+	if state.EndedAt != nil {
+		changes, _ = q.ScanDriftChanges(ctx, projectName, scanID)
+	}
 	_ = q.PublishScanEvent(ctx, projectName, ScanEvent{
-		ProjectName: projectName,
-		ScanID:      scanID,
-		Status:      state.Status,
-		Completed:   state.Completed,
-		Failed:      state.Failed,
-		Total:       state.Total,
-		DriftedCnt:  state.Drifted,
-		EndedAt:     state.EndedAt,
+		ProjectName:  projectName,
+		ScanID:       scanID,
+		Status:       state.Status,
+		Completed:    state.Completed,
+		Failed:       state.Failed,
+		Total:        state.Total,
+		DriftedCnt:   state.Drifted,
+		EndedAt:      state.EndedAt,
+		DriftChanges: changes,
 	})
 }
 
@@ -0,0 +1,30 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// ScanWarningSecret flags a line in the workspace that looks like a
+// committed secret.
+const ScanWarningSecret = "secret"
+
+// ScanWarning is a problem found in a scan's workspace that is reported
+// alongside, not as, drift.
+type ScanWarning struct {
+	Kind string `json:"kind"`
+	Path string `json:"path"`
+	Line int    `json:"line,omitempty"`
+	// Rule names the check that matched, e.g. "private_key".
+	Rule string `json:"rule,omitempty"`
+}
+
+// SetScanWarnings replaces the warnings recorded on a scan.
+func (q *Queue) SetScanWarnings(ctx context.Context, scanID string, warnings []ScanWarning) error {
+	data, err := json.Marshal(warnings)
+	if err != nil {
+		return fmt.Errorf("marshal scan warnings: %w", err)
+	}
+	return q.client.HSet(ctx, keyScanPrefix+scanID, "warnings", string(data)).Err()
+}
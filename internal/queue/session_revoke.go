@@ -0,0 +1,30 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RevokeSession marks a UI session as logged out on every replica. The
+// record only needs to outlive the session, so ttl is its remaining max age.
+func (q *Queue) RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	return q.client.Set(ctx, keyRevokedSessionPrefix+sessionID, 1, ttl).Err()
+}
+
+// IsSessionRevoked reports whether RevokeSession was called for sessionID.
+func (q *Queue) IsSessionRevoked(ctx context.Context, sessionID string) (bool, error) {
+	err := q.client.Get(ctx, keyRevokedSessionPrefix+sessionID).Err()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,25 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBackendSessionRevocation(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, q Backend) {
+		ctx := context.Background()
+		if revoked, err := q.IsSessionRevoked(ctx, "sess-1"); err != nil || revoked {
+			t.Fatalf("fresh session: revoked=%v err=%v", revoked, err)
+		}
+		if err := q.RevokeSession(ctx, "sess-1", time.Hour); err != nil {
+			t.Fatalf("revoke: %v", err)
+		}
+		if revoked, err := q.IsSessionRevoked(ctx, "sess-1"); err != nil || !revoked {
+			t.Fatalf("revoked session: revoked=%v err=%v", revoked, err)
+		}
+		if revoked, err := q.IsSessionRevoked(ctx, "sess-2"); err != nil || revoked {
+			t.Fatalf("other session: revoked=%v err=%v", revoked, err)
+		}
+	})
+}
@@ -0,0 +1,202 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// SnapshotVersion is the format version written by Snapshot.
+const SnapshotVersion = 1
+
+// Snapshot holds the durable scan history kept in Redis: finished scans,
+// finished stack scans, last-scan pointers, drift change logs and the
+// indexes that list them.
+// Work queues, locks, claims, inflight markers, running scans and the
+// worker registry are deliberately left out so a restore never resurrects
+// work or locks that belonged to the old instance.
+type Snapshot struct {
+	Version   int             `json:"version"`
+	CreatedAt time.Time       `json:"created_at"`
+	Entries   []SnapshotEntry `json:"entries"`
+}
+
+// SnapshotEntry is one Redis key with its value and remaining TTL.
+type SnapshotEntry struct {
+	Key      string            `json:"key"`
+	Type     string            `json:"type"`
+	TTLMilli int64             `json:"ttl_ms,omitempty"`
+	String   string            `json:"string,omitempty"`
+	Hash     map[string]string `json:"hash,omitempty"`
+	Members  []string          `json:"members,omitempty"`
+	Scored   []redis.Z         `json:"scored,omitempty"`
+}
+
+// RestoreStats reports what Restore wrote.
+type RestoreStats struct {
+	Restored int `json:"restored"`
+	Skipped  int `json:"skipped"`
+}
+
+// Snapshot exports the durable scan state.
+func (q *Queue) Snapshot(ctx context.Context) (*Snapshot, error) {
+	snap := &Snapshot{Version: SnapshotVersion, CreatedAt: time.Now().UTC()}
+	var cursor uint64
+	for {
+		keys, next, err := q.client.Scan(ctx, cursor, "driftd:*", 500).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			entry, ok, err := q.snapshotKey(ctx, key)
+			if err != nil {
+				return nil, fmt.Errorf("snapshot %s: %w", key, err)
+			}
+			if ok {
+				snap.Entries = append(snap.Entries, entry)
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+	return snap, nil
+}
+
+func (q *Queue) snapshotKey(ctx context.Context, key string) (SnapshotEntry, bool, error) {
+	typ, err := q.client.Type(ctx, key).Result()
+	if err != nil {
+		return SnapshotEntry{}, false, err
+	}
+	entry := SnapshotEntry{Key: key, Type: typ}
+
+	switch typ {
+	case "hash":
+		if !strings.HasPrefix(key, keyScanPrefix) && !strings.HasPrefix(key, keyDriftState) {
+			return entry, false, nil
+		}
+		values, err := q.client.HGetAll(ctx, key).Result()
+		if err != nil {
+			return entry, false, err
+		}
+		if strings.HasPrefix(key, keyScanPrefix) && !isFinishedStatus(values["status"]) {
+			return entry, false, nil
+		}
+		entry.Hash = values
+	case "string":
+		switch {
+		case strings.HasPrefix(key, keyScanLast):
+		case strings.HasPrefix(key, keyStackScanPrefix) && !strings.HasPrefix(key, keyStackScanInflight):
+		default:
+			return entry, false, nil
+		}
+		value, err := q.client.Get(ctx, key).Result()
+		if err != nil {
+			return entry, false, err
+		}
+		if strings.HasPrefix(key, keyStackScanPrefix) {
+			stackScan, err := decodeStackScan([]byte(value))
+			if err != nil || !isFinishedStatus(stackScan.Status) {
+				return entry, false, nil
+			}
+		}
+		entry.String = value
+	case "set":
+		if !strings.HasPrefix(key, keyScanStackScans) && !strings.HasPrefix(key, keyProjectStackScans) {
+			return entry, false, nil
+		}
+		members, err := q.client.SMembers(ctx, key).Result()
+		if err != nil {
+			return entry, false, err
+		}
+		entry.Members = members
+	case "zset":
+		if !strings.HasPrefix(key, keyProjectStackScansOrdered) && !strings.HasPrefix(key, keyDriftChanges) {
+			return entry, false, nil
+		}
+		scored, err := q.client.ZRangeWithScores(ctx, key, 0, -1).Result()
+		if err != nil {
+			return entry, false, err
+		}
+		entry.Scored = scored
+	default:
+		return entry, false, nil
+	}
+
+	ttl, err := q.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return entry, false, err
+	}
+	if ttl > 0 {
+		entry.TTLMilli = ttl.Milliseconds()
+	}
+	return entry, true, nil
+}
+
+// Restore imports a snapshot. Existing keys are left untouched unless
+// overwrite is set, so restoring into a live instance never clobbers newer
+// scan state.
+func (q *Queue) Restore(ctx context.Context, snap *Snapshot, overwrite bool) (RestoreStats, error) {
+	var stats RestoreStats
+	if snap == nil {
+		return stats, fmt.Errorf("snapshot is empty")
+	}
+	if snap.Version != SnapshotVersion {
+		return stats, fmt.Errorf("unsupported snapshot version %d", snap.Version)
+	}
+
+	for _, entry := range snap.Entries {
+		if !strings.HasPrefix(entry.Key, "driftd:") {
+			return stats, fmt.Errorf("refusing to restore foreign key %q", entry.Key)
+		}
+		if !overwrite {
+			exists, err := q.client.Exists(ctx, entry.Key).Result()
+			if err != nil {
+				return stats, err
+			}
+			if exists > 0 {
+				stats.Skipped++
+				continue
+			}
+		}
+
+		pipe := q.client.TxPipeline()
+		pipe.Del(ctx, entry.Key)
+		switch entry.Type {
+		case "hash":
+			pipe.HSet(ctx, entry.Key, entry.Hash)
+		case "string":
+			pipe.Set(ctx, entry.Key, entry.String, 0)
+		case "set":
+			members := make([]any, len(entry.Members))
+			for i, m := range entry.Members {
+				members[i] = m
+			}
+			pipe.SAdd(ctx, entry.Key, members...)
+		case "zset":
+			pipe.ZAdd(ctx, entry.Key, entry.Scored...)
+		default:
+			return stats, fmt.Errorf("unsupported type %q for key %q", entry.Type, entry.Key)
+		}
+		if entry.TTLMilli > 0 {
+			pipe.PExpire(ctx, entry.Key, time.Duration(entry.TTLMilli)*time.Millisecond)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return stats, fmt.Errorf("restore %s: %w", entry.Key, err)
+		}
+		stats.Restored++
+	}
+	return stats, nil
+}
+
+func isFinishedStatus(status string) bool {
+	switch status {
+	case StatusCompleted, StatusFailed, StatusCanceled:
+		return true
+	}
+	return false
+}
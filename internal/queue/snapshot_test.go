@@ -0,0 +1,79 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestSnapshotRestoreRoundTrip(t *testing.T) {
+	src := newTestQueue(t)
+	ctx := context.Background()
+
+	scan, err := src.StartScan(ctx, "project", "manual", "", "", 1)
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	if err := src.Enqueue(ctx, &StackScan{ScanID: scan.ID, ProjectName: "project", ProjectURL: "file:///project", StackPath: "envs/dev"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	stackScan := dequeueStackScan(t, src)
+	if err := src.Complete(ctx, stackScan, true); err != nil {
+		t.Fatalf("complete: %v", err)
+	}
+
+	// A second, still running scan must not be carried over.
+	running, err := src.StartScan(ctx, "other", "manual", "", "", 1)
+	if err != nil {
+		t.Fatalf("start running scan: %v", err)
+	}
+
+	snap, err := src.Snapshot(ctx)
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded Snapshot
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	dst := newTestQueue(t)
+	stats, err := dst.Restore(ctx, &decoded, false)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if stats.Restored == 0 || stats.Skipped != 0 {
+		t.Fatalf("unexpected restore stats: %+v", stats)
+	}
+
+	last, err := dst.GetLastScan(ctx, "project")
+	if err != nil {
+		t.Fatalf("last scan after restore: %v", err)
+	}
+	if last.ID != scan.ID || last.Status != ScanStatusCompleted || last.Drifted != 1 {
+		t.Fatalf("unexpected restored scan: %+v", last)
+	}
+	restored, err := dst.GetStackScan(ctx, stackScan.ID)
+	if err != nil || restored.Status != StatusCompleted {
+		t.Fatalf("expected restored stack scan, got %+v %v", restored, err)
+	}
+
+	if _, err := dst.GetScan(ctx, running.ID); err == nil {
+		t.Fatalf("expected running scan to be excluded from snapshot")
+	}
+	if locked, _ := dst.IsProjectLocked(ctx, "other"); locked {
+		t.Fatalf("expected project locks to be excluded from snapshot")
+	}
+
+	stats, err = dst.Restore(ctx, &decoded, false)
+	if err != nil {
+		t.Fatalf("second restore: %v", err)
+	}
+	if stats.Restored != 0 || stats.Skipped == 0 {
+		t.Fatalf("expected existing keys to be skipped, got %+v", stats)
+	}
+}
@@ -2,7 +2,6 @@ package queue
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -17,10 +16,19 @@ const keyClaimPrefix = "driftd:claim:"
 
 // dequeueClaimScript atomically reads a stack scan, checks its status is "pending",
 // and attempts to SET NX EX the claim key. If the claim fails or the status isn't
-// pending, the ID is pushed back to the queue. Returns:
+// pending, the ID is pushed back to the queue. Stack scans of a project listed
+// in KEYS[4] are moved to that project's paused list (ARGV[6] prefix) instead.
+// Stack scans whose scan already runs scan_concurrency stacks (scan hashes
+// under the ARGV[7] prefix) go back to the far end of the queue. A claimed
+// stack scan is counted as running in its scan here rather than afterwards,
+// so two workers cannot both take a scan's last free slot.
+// Returns:
 //
 //	 1 = claimed successfully
 //	 0 = re-pushed to queue (claim failed or not pending)
+//	 2 = re-pushed to queue (schema version outside ARGV[4]..ARGV[5])
+//	 3 = moved to the paused list (project paused)
+//	 4 = re-pushed to queue (scan at its concurrency cap)
 //	-1 = scan data missing (caller should skip)
 var dequeueClaimScript = redis.NewScript(`
 local scan_data = redis.call('GET', KEYS[1])
@@ -34,12 +42,50 @@ if scan['status'] ~= 'pending' then
   return 0
 end
 
+local version = tonumber(scan['schema_version']) or 1
+if version == 0 then
+  version = 1
+end
+if version < tonumber(ARGV[4]) or version > tonumber(ARGV[5]) then
+  redis.call('LPUSH', KEYS[3], ARGV[1])
+  return 2
+end
+
+local project = scan['project_name']
+if project and redis.call('HEXISTS', KEYS[4], project) == 1 then
+  local parked = ARGV[6] .. project
+  redis.call('LREM', parked, 0, ARGV[1])
+  redis.call('LPUSH', parked, ARGV[1])
+  return 3
+end
+
+local limit = tonumber(scan['scan_concurrency']) or 0
+local scan_id = scan['scan_id']
+local scan_key = nil
+if type(scan_id) == 'string' and scan_id ~= '' then
+  scan_key = ARGV[7] .. scan_id
+end
+if limit > 0 and scan_key then
+  local running = tonumber(redis.call('HGET', scan_key, 'running') or '0')
+  if running >= limit then
+    redis.call('LPUSH', KEYS[3], ARGV[1])
+    return 4
+  end
+end
+
 local claimed = redis.call('SET', KEYS[2], ARGV[2], 'NX', 'EX', ARGV[3])
 if not claimed then
   redis.call('LPUSH', KEYS[3], ARGV[1])
   return 0
 end
 
+if scan_key and redis.call('EXISTS', scan_key) == 1 then
+  redis.call('HINCRBY', scan_key, 'running', 1)
+  if redis.call('HINCRBY', scan_key, 'queued', -1) < 0 then
+    redis.call('HSET', scan_key, 'queued', 0)
+  end
+end
+
 return 1
 `)
 
@@ -61,8 +107,68 @@ type StackScan struct {
 	Trigger string `json:"trigger,omitempty"` // "scheduled", "manual", "post-apply"
 	Commit  string `json:"commit,omitempty"`
 	Actor   string `json:"actor,omitempty"`
+
+	// InitArgs and PlanArgs are extra terraform arguments resolved from the
+	// project config when the stack was enqueued.
+	InitArgs []string `json:"init_args,omitempty"`
+	PlanArgs []string `json:"plan_args,omitempty"`
+
+	// Weight is how many worker concurrency slots the scan takes. Zero
+	// means 1.
+	Weight int `json:"weight,omitempty"`
+
+	// SchemaVersion is the JobSchemaVersion of the build that enqueued the
+	// stack scan. Zero means it predates versioning and counts as 1.
+	SchemaVersion int `json:"schema_version,omitempty"`
+
+	// Priority orders claiming: workers take high before normal before low
+	// priority stack scans. Zero means PriorityNormal. The NATS backend
+	// claims in enqueue order regardless.
+	Priority int `json:"priority,omitempty"`
+	// ScanConcurrency caps how many stack scans of the same scan run at
+	// once across all workers. Zero means no cap.
+	ScanConcurrency int `json:"scan_concurrency,omitempty"`
+}
+
+// Stack scan payload versions. Each build stamps JobSchemaVersion on the
+// stack scans it enqueues and only claims versions from MinJobSchemaVersion
+// to JobSchemaVersion, so during a rolling upgrade old and new workers leave
+// each other's jobs alone. Bump JobSchemaVersion when a StackScan field is
+// renamed or changes meaning, and raise MinJobSchemaVersion once older
+// payloads can no longer be processed. Version 2 payloads may be stored
+// gzip-compressed (see encodeStackScan).
+const (
+	JobSchemaVersion    = 2
+	MinJobSchemaVersion = 1
+)
+
+// SupportedJobVersions lists the stack scan versions this build can process.
+func SupportedJobVersions() []int {
+	versions := make([]int, 0, JobSchemaVersion-MinJobSchemaVersion+1)
+	for v := MinJobSchemaVersion; v <= JobSchemaVersion; v++ {
+		versions = append(versions, v)
+	}
+	return versions
+}
+
+// SupportsJobVersion reports whether this build can process a stack scan
+// with the given SchemaVersion.
+func SupportsJobVersion(version int) bool {
+	if version == 0 {
+		version = 1
+	}
+	return version >= MinJobSchemaVersion && version <= JobSchemaVersion
 }
 
+// unsupportedJobBackoff is how long Dequeue waits after handing back a
+// stack scan this build cannot process, so a fleet that is mid-upgrade does
+// not spin on it.
+const unsupportedJobBackoff = 500 * time.Millisecond
+
+// scanConcurrencyBackoff is how long Dequeue leaves a work list alone once
+// every stack scan in it belongs to a scan at its concurrency cap.
+const scanConcurrencyBackoff = time.Second
+
 // ErrAlreadyClaimed is returned when another worker has already claimed the stack scan.
 var ErrAlreadyClaimed = errors.New("stack scan already claimed")
 
@@ -117,6 +223,9 @@ func (q *Queue) CancelStackScan(ctx context.Context, stackScan *StackScan, reaso
 func (q *Queue) Enqueue(ctx context.Context, stackScan *StackScan) error {
 	stackScan.Status = StatusPending
 	stackScan.CreatedAt = time.Now()
+	if stackScan.SchemaVersion == 0 {
+		stackScan.SchemaVersion = JobSchemaVersion
+	}
 	if stackScan.ID == "" {
 		stackScan.ID = fmt.Sprintf("%s:%s:%d:%d", stackScan.ProjectName, stackScan.StackPath, stackScan.CreatedAt.UnixNano(), rand.Int31())
 	}
@@ -149,6 +258,9 @@ func (q *Queue) EnqueueBatch(ctx context.Context, stacks []*StackScan) (*Enqueue
 	for _, ss := range stacks {
 		ss.Status = StatusPending
 		ss.CreatedAt = now
+		if ss.SchemaVersion == 0 {
+			ss.SchemaVersion = JobSchemaVersion
+		}
 		if ss.ID == "" {
 			ss.ID = fmt.Sprintf("%s:%s:%d:%d", ss.ProjectName, ss.StackPath, now.UnixNano(), rand.Int31())
 		}
@@ -171,9 +283,9 @@ func (q *Queue) EnqueueBatch(ctx context.Context, stacks []*StackScan) (*Enqueue
 }
 
 func (q *Queue) enqueueStackScanAtomic(ctx context.Context, stackScan *StackScan) (bool, error) {
-	stackScanData, err := json.Marshal(stackScan)
+	stackScanData, err := q.encodeStackScan(stackScan)
 	if err != nil {
-		return false, fmt.Errorf("failed to marshal stack scan: %w", err)
+		return false, err
 	}
 
 	inflight := inflightKey(stackScan.ProjectName, stackScan.StackPath)
@@ -183,7 +295,7 @@ func (q *Queue) enqueueStackScanAtomic(ctx context.Context, stackScan *StackScan
 	pendingSetKey := keyStackScanPending
 	scanSetKey := keyScanStackScans + stackScan.ScanID
 
-	retentionSeconds := int64(stackScanRetention / time.Second)
+	retentionSeconds := int64(q.stackScanTTL() / time.Second)
 	if retentionSeconds <= 0 {
 		retentionSeconds = 1
 	}
@@ -198,7 +310,7 @@ func (q *Queue) enqueueStackScanAtomic(ctx context.Context, stackScan *StackScan
 			projectZSetKey,
 			pendingSetKey,
 			scanSetKey,
-			keyQueue,
+			queueKeyFor(stackScan.Priority),
 		},
 		stackScan.ID,
 		strconv.FormatInt(retentionSeconds, 10),
@@ -220,15 +332,42 @@ func (q *Queue) enqueueStackScanAtomic(ctx context.Context, stackScan *StackScan
 	}
 }
 
+// workLists are the work lists in the order workers claim from them.
+var workLists = []string{keyQueueHigh, keyQueue, keyQueueLow}
+
 // Dequeue blocks until a stack scan is available, then returns it.
 // The stack scan is atomically claimed via a Lua script that guarantees the item
 // is pushed back to the queue if the claim fails, preventing items from being
-// stranded in the pending set.
+// stranded in the pending set. Higher priority work lists are drained first.
 func (q *Queue) Dequeue(ctx context.Context, workerID string) (*StackScan, error) {
+	// A work list whose stack scans all belong to scans at their
+	// concurrency cap is skipped for a while, so it cannot hold back the
+	// lists behind it.
+	throttled := map[string]time.Time{}
+	capped := map[string]bool{}
 	for {
-		result, err := q.client.BRPop(ctx, time.Second, keyQueue).Result()
+		lists := make([]string, 0, len(workLists))
+		now := time.Now()
+		for _, key := range workLists {
+			if now.Before(throttled[key]) {
+				continue
+			}
+			lists = append(lists, key)
+		}
+		if len(lists) == 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(scanConcurrencyBackoff):
+			}
+			continue
+		}
+		result, err := q.client.BRPop(ctx, time.Second, lists...).Result()
 		if err != nil {
 			if errors.Is(err, redis.Nil) {
+				if ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
 				continue
 			}
 			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
@@ -237,7 +376,14 @@ func (q *Queue) Dequeue(ctx context.Context, workerID string) (*StackScan, error
 			return nil, fmt.Errorf("failed to dequeue: %w", err)
 		}
 
-		stackScanID := result[1]
+		listKey, stackScanID := result[0], result[1]
+		// A blocking pop is not interrupted by cancellation, so the caller may
+		// have stopped claiming while we waited. Return the item to the tail it
+		// was popped from.
+		if ctx.Err() != nil {
+			_ = q.client.RPush(context.Background(), listKey, stackScanID).Err()
+			return nil, ctx.Err()
+		}
 		stackScanKey := keyStackScanPrefix + stackScanID
 		claimKey := keyClaimPrefix + stackScanID
 
@@ -248,14 +394,18 @@ func (q *Queue) Dequeue(ctx context.Context, workerID string) (*StackScan, error
 		claimResult, err := dequeueClaimScript.Run(
 			claimCtx,
 			q.client,
-			[]string{stackScanKey, claimKey, keyQueue},
+			[]string{stackScanKey, claimKey, listKey, keyPausedProjects},
 			stackScanID,
 			workerID,
 			strconv.Itoa(30*60), // 30 minutes in seconds
+			strconv.Itoa(MinJobSchemaVersion),
+			strconv.Itoa(JobSchemaVersion),
+			keyPausedQueuePrefix,
+			keyScanPrefix,
 		).Int64()
 		if err != nil {
 			// Lua script error — push ID back so it isn't lost.
-			_ = q.client.LPush(claimCtx, keyQueue, stackScanID).Err()
+			_ = q.client.LPush(claimCtx, listKey, stackScanID).Err()
 			continue
 		}
 
@@ -264,6 +414,23 @@ func (q *Queue) Dequeue(ctx context.Context, workerID string) (*StackScan, error
 			continue
 		case 0: // re-pushed by Lua (claim failed or not pending)
 			continue
+		case 3: // parked by Lua until the project is resumed
+			continue
+		case 4: // re-pushed by Lua (scan at its concurrency cap)
+			if capped[stackScanID] {
+				// The list came back round to this stack scan.
+				throttled[listKey] = time.Now().Add(scanConcurrencyBackoff)
+				clear(capped)
+			}
+			capped[stackScanID] = true
+			continue
+		case 2: // re-pushed by Lua (schema version not supported here)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(unsupportedJobBackoff):
+			}
+			continue
 		case 1: // claimed
 			stackScan, err := q.GetStackScan(claimCtx, stackScanID)
 			if err != nil {
@@ -272,7 +439,11 @@ func (q *Queue) Dequeue(ctx context.Context, workerID string) (*StackScan, error
 			}
 			if err := q.markRunningAfterClaim(claimCtx, stackScan, workerID); err != nil {
 				_ = q.client.Del(claimCtx, claimKey).Err()
-				_ = q.client.LPush(claimCtx, keyQueue, stackScanID).Err()
+				if stackScan.ScanID != "" {
+					// Hand back the running slot the claim script took.
+					_ = q.markScanStackScanRetry(claimCtx, stackScan.ScanID)
+				}
+				_ = q.client.LPush(claimCtx, listKey, stackScanID).Err()
 				continue
 			}
 			return stackScan, nil
@@ -284,7 +455,8 @@ func (q *Queue) Dequeue(ctx context.Context, workerID string) (*StackScan, error
 
 // markRunningAfterClaim transitions a stack scan to running after the claim key
 // has already been set by the Lua script. This is the second half of the
-// claim-and-mark-running operation.
+// claim-and-mark-running operation; the script has already moved the scan's
+// counters, so only the progress event is left to send.
 func (q *Queue) markRunningAfterClaim(ctx context.Context, stackScan *StackScan, workerID string) error {
 	stackScan.Status = StatusRunning
 	stackScan.StartedAt = time.Now()
@@ -300,7 +472,7 @@ func (q *Queue) markRunningAfterClaim(ctx context.Context, stackScan *StackScan,
 		return err
 	}
 	if stackScan.ScanID != "" {
-		if err := q.markScanStackScanRunning(ctx, stackScan.ScanID); err != nil {
+		if err := q.publishScanProgress(ctx, stackScan.ScanID); err != nil {
 			return err
 		}
 	}
@@ -372,8 +544,8 @@ func (q *Queue) RecoverOrphanedStackScans(ctx context.Context) (int, error) {
 				_ = q.client.SRem(ctx, keyStackScanPending, id).Err()
 				continue
 			}
-			_ = q.client.SetNX(ctx, inflightKey(stackScan.ProjectName, stackScan.StackPath), stackScan.ID, stackScanRetention).Err()
-			if err := q.client.LPush(ctx, keyQueue, stackScan.ID).Err(); err != nil {
+			_ = q.client.SetNX(ctx, inflightKey(stackScan.ProjectName, stackScan.StackPath), stackScan.ID, q.stackScanTTL()).Err()
+			if err := q.client.LPush(ctx, queueKeyFor(stackScan.Priority), stackScan.ID).Err(); err != nil {
 				continue
 			}
 			recovered++
@@ -398,6 +570,11 @@ func (q *Queue) Complete(ctx context.Context, stackScan *StackScan, drifted bool
 	if err := q.removeStackScanRefs(ctx, stackScan); err != nil {
 		return err
 	}
+	state := DriftStateHealthy
+	if drifted {
+		state = DriftStateDrifted
+	}
+	_ = q.recordDriftState(ctx, stackScan, state)
 	if stackScan.ScanID != "" {
 		return q.markScanStackScanCompleted(ctx, stackScan.ScanID, drifted)
 	}
@@ -427,7 +604,7 @@ func (q *Queue) Fail(ctx context.Context, stackScan *StackScan, errMsg string) e
 				return err
 			}
 		}
-		return q.client.LPush(ctx, keyQueue, stackScan.ID).Err()
+		return q.client.LPush(ctx, queueKeyFor(stackScan.Priority), stackScan.ID).Err()
 	}
 
 	stackScan.Status = StatusFailed
@@ -444,6 +621,7 @@ func (q *Queue) Fail(ctx context.Context, stackScan *StackScan, errMsg string) e
 	if err := q.removeStackScanRefs(ctx, stackScan); err != nil {
 		return err
 	}
+	_ = q.recordDriftState(ctx, stackScan, DriftStateError)
 	if stackScan.ScanID != "" {
 		return q.markScanStackScanFailed(ctx, stackScan.ScanID)
 	}
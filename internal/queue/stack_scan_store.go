@@ -2,9 +2,9 @@ package queue
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 
 	"github.com/redis/go-redis/v9"
 )
@@ -19,11 +19,7 @@ func (q *Queue) GetStackScan(ctx context.Context, stackScanID string) (*StackSca
 		return nil, fmt.Errorf("failed to get stack scan: %w", err)
 	}
 
-	var stackScan StackScan
-	if err := json.Unmarshal([]byte(data), &stackScan); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal stack scan: %w", err)
-	}
-	return &stackScan, nil
+	return decodeStackScan([]byte(data))
 }
 
 func (q *Queue) ListProjectStackScans(ctx context.Context, projectName string, limit int) ([]*StackScan, error) {
@@ -58,11 +54,11 @@ func (q *Queue) ListProjectStackScans(ctx context.Context, projectName string, l
 		if err != nil {
 			continue // StackScan expired
 		}
-		var stackScan StackScan
-		if err := json.Unmarshal([]byte(data), &stackScan); err != nil {
+		stackScan, err := decodeStackScan([]byte(data))
+		if err != nil {
 			continue
 		}
-		stackScans = append(stackScans, &stackScan)
+		stackScans = append(stackScans, stackScan)
 	}
 
 	return stackScans, nil
@@ -70,11 +66,11 @@ func (q *Queue) ListProjectStackScans(ctx context.Context, projectName string, l
 
 func (q *Queue) saveStackScan(ctx context.Context, stackScan *StackScan) error {
 	stackScanKey := keyStackScanPrefix + stackScan.ID
-	stackScanData, err := json.Marshal(stackScan)
+	stackScanData, err := q.encodeStackScan(stackScan)
 	if err != nil {
-		return fmt.Errorf("failed to marshal stack scan: %w", err)
+		return err
 	}
-	return q.client.Set(ctx, stackScanKey, stackScanData, stackScanRetention).Err()
+	return q.client.Set(ctx, stackScanKey, stackScanData, q.stackScanTTL()).Err()
 }
 
 func (q *Queue) removeStackScanRefs(ctx context.Context, stackScan *StackScan) error {
@@ -98,3 +94,56 @@ func (q *Queue) ClearInflightForScan(ctx context.Context, scanID string) {
 		q.client.Del(ctx, inflightKey(stackScan.ProjectName, stackScan.StackPath))
 	}
 }
+
+// ListRunningStackScans returns every running stack scan across projects,
+// longest running first.
+func (q *Queue) ListRunningStackScans(ctx context.Context) ([]*StackScan, error) {
+	stackScanIDs, err := q.client.ZRange(ctx, keyRunningStackScans, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list running stack scans: %w", err)
+	}
+	var stackScans []*StackScan
+	for _, id := range stackScanIDs {
+		stackScan, err := q.GetStackScan(ctx, id)
+		if err != nil || stackScan.Status != StatusRunning {
+			continue // finished or expired since the index was read
+		}
+		stackScans = append(stackScans, stackScan)
+	}
+	return stackScans, nil
+}
+
+// ListPendingStackScans returns the unclaimed stack scans in the order
+// workers will claim them, next first. Stack scans of paused projects are
+// left out.
+func (q *Queue) ListPendingStackScans(ctx context.Context) ([]*StackScan, error) {
+	var ids []string
+	for _, key := range workLists {
+		list, err := q.client.LRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pending stack scans: %w", err)
+		}
+		// Workers pop from the tail of each list.
+		slices.Reverse(list)
+		ids = append(ids, list...)
+	}
+	paused, err := q.client.HKeys(ctx, keyPausedProjects).Result()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(ids))
+	var stackScans []*StackScan
+	for i := range ids {
+		id := ids[i]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		stackScan, err := q.GetStackScan(ctx, id)
+		if err != nil || stackScan.Status != StatusPending || slices.Contains(paused, stackScan.ProjectName) {
+			continue
+		}
+		stackScans = append(stackScans, stackScan)
+	}
+	return stackScans, nil
+}
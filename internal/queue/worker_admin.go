@@ -0,0 +1,166 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	keyWorkers         = "driftd:workers"
+	workerAdminChannel = "driftd:workers:admin"
+
+	// WorkerStaleAfter is how long a worker may go without a heartbeat before
+	// it is dropped from the registry.
+	WorkerStaleAfter = time.Minute
+)
+
+// Worker admin actions.
+const (
+	WorkerActionDrain          = "drain"
+	WorkerActionResume         = "resume"
+	WorkerActionSetConcurrency = "set_concurrency"
+)
+
+// WorkerTargetAll addresses every worker process.
+const WorkerTargetAll = "all"
+
+// WorkerInfo is the heartbeat record each worker process keeps in Redis.
+type WorkerInfo struct {
+	ID          string    `json:"id"`
+	Hostname    string    `json:"hostname"`
+	Concurrency int       `json:"concurrency"`
+	Active      int       `json:"active"`
+	Draining    bool      `json:"draining"`
+	StartedAt   time.Time `json:"started_at"`
+	LastSeen    time.Time `json:"last_seen"`
+	// JobVersions are the stack scan schema versions the worker claims.
+	JobVersions []int `json:"job_versions,omitempty"`
+	// SlotsUsed is the concurrency taken by running stack scans, each
+	// counted by its weight.
+	SlotsUsed int `json:"slots_used,omitempty"`
+	// WarmStacks and WarmBytes describe the worker's warm pool of
+	// initialized stacks.
+	WarmStacks int   `json:"warm_stacks,omitempty"`
+	WarmBytes  int64 `json:"warm_bytes,omitempty"`
+}
+
+// WorkerCommand is published on the admin channel to change a running worker.
+type WorkerCommand struct {
+	Action      string `json:"action"`
+	Target      string `json:"target"`
+	Concurrency int    `json:"concurrency,omitempty"`
+}
+
+// Matches reports whether the command addresses the given worker. Targets
+// may be a worker ID, its hostname, or WorkerTargetAll.
+func (c WorkerCommand) Matches(info WorkerInfo) bool {
+	return c.Target == WorkerTargetAll || c.Target == info.ID || (info.Hostname != "" && c.Target == info.Hostname)
+}
+
+// Validate checks the command is well formed.
+func (c WorkerCommand) Validate() error {
+	if c.Target == "" {
+		return fmt.Errorf("worker target is required")
+	}
+	switch c.Action {
+	case WorkerActionDrain, WorkerActionResume:
+		return nil
+	case WorkerActionSetConcurrency:
+		if c.Concurrency < 1 {
+			return fmt.Errorf("concurrency must be at least 1")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown worker action %q", c.Action)
+	}
+}
+
+// HeartbeatWorker records the worker's current state in the registry.
+func (q *Queue) HeartbeatWorker(ctx context.Context, info WorkerInfo) error {
+	if info.LastSeen.IsZero() {
+		info.LastSeen = time.Now()
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("marshal worker info: %w", err)
+	}
+	return q.client.HSet(ctx, keyWorkers, info.ID, data).Err()
+}
+
+// RemoveWorker drops a worker from the registry, typically on shutdown.
+func (q *Queue) RemoveWorker(ctx context.Context, workerID string) error {
+	return q.client.HDel(ctx, keyWorkers, workerID).Err()
+}
+
+// ListWorkers returns live workers sorted by ID and prunes stale entries.
+func (q *Queue) ListWorkers(ctx context.Context) ([]WorkerInfo, error) {
+	entries, err := q.client.HGetAll(ctx, keyWorkers).Result()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	workers := make([]WorkerInfo, 0, len(entries))
+	for id, raw := range entries {
+		var info WorkerInfo
+		if err := json.Unmarshal([]byte(raw), &info); err != nil || now.Sub(info.LastSeen) > WorkerStaleAfter {
+			q.client.HDel(ctx, keyWorkers, id)
+			continue
+		}
+		workers = append(workers, info)
+	}
+	sort.Slice(workers, func(i, j int) bool { return workers[i].ID < workers[j].ID })
+	return workers, nil
+}
+
+// PublishWorkerCommand sends an admin command and returns how many worker
+// processes were subscribed to receive it.
+func (q *Queue) PublishWorkerCommand(ctx context.Context, cmd WorkerCommand) (int64, error) {
+	if err := cmd.Validate(); err != nil {
+		return 0, err
+	}
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return 0, fmt.Errorf("marshal worker command: %w", err)
+	}
+	return q.client.Publish(ctx, workerAdminChannel, data).Result()
+}
+
+// SubscribeWorkerCommands delivers admin commands until ctx is canceled.
+// The returned channel is ready once the subscription is confirmed.
+func (q *Queue) SubscribeWorkerCommands(ctx context.Context) (<-chan WorkerCommand, error) {
+	pubsub := q.client.Subscribe(ctx, workerAdminChannel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	out := make(chan WorkerCommand)
+	go func() {
+		defer close(out)
+		defer pubsub.Close()
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				var cmd WorkerCommand
+				if err := json.Unmarshal([]byte(msg.Payload), &cmd); err != nil {
+					continue
+				}
+				select {
+				case out <- cmd:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestWorkerRegistryPrunesStale(t *testing.T) {
+	q := newTestQueue(t)
+	ctx := context.Background()
+
+	if err := q.HeartbeatWorker(ctx, WorkerInfo{ID: "live", Concurrency: 2}); err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+	if err := q.HeartbeatWorker(ctx, WorkerInfo{ID: "stale", LastSeen: time.Now().Add(-2 * WorkerStaleAfter)}); err != nil {
+		t.Fatalf("heartbeat: %v", err)
+	}
+
+	workers, err := q.ListWorkers(ctx)
+	if err != nil {
+		t.Fatalf("list workers: %v", err)
+	}
+	if len(workers) != 1 || workers[0].ID != "live" || workers[0].Concurrency != 2 {
+		t.Fatalf("expected only live worker, got %+v", workers)
+	}
+	if n, _ := q.client.HLen(ctx, keyWorkers).Result(); n != 1 {
+		t.Fatalf("expected stale worker pruned, %d entries remain", n)
+	}
+}
+
+func TestWorkerCommandValidateAndMatch(t *testing.T) {
+	info := WorkerInfo{ID: "host-1", Hostname: "host"}
+	for _, target := range []string{"host-1", "host", WorkerTargetAll} {
+		if !(WorkerCommand{Action: WorkerActionDrain, Target: target}).Matches(info) {
+			t.Fatalf("expected target %q to match", target)
+		}
+	}
+	if (WorkerCommand{Action: WorkerActionDrain, Target: "other"}).Matches(info) {
+		t.Fatalf("unexpected match for other worker")
+	}
+
+	for _, cmd := range []WorkerCommand{
+		{Action: WorkerActionDrain},
+		{Action: "explode", Target: WorkerTargetAll},
+		{Action: WorkerActionSetConcurrency, Target: WorkerTargetAll},
+	} {
+		if err := cmd.Validate(); err == nil {
+			t.Fatalf("expected %+v to be invalid", cmd)
+		}
+	}
+}
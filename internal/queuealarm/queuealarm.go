@@ -0,0 +1,274 @@
+// Package queuealarm watches how long stack scans wait between enqueue and
+// claim. When the p95 wait stays above a threshold it raises a starvation
+// alarm: a metric, a log line and, optionally, a webhook an autoscaler can
+// act on to add worker capacity.
+package queuealarm
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/metrics"
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+// Webhook events.
+const (
+	EventStarved   = "queue_starved"
+	EventRecovered = "queue_recovered"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the webhook body, prefixed
+// with "sha256=", when a webhook secret is configured.
+const SignatureHeader = "X-Driftd-Signature-256"
+
+const webhookTimeout = 10 * time.Second
+
+// Leader reports whether this replica should send webhooks.
+type Leader interface {
+	IsLeader() bool
+}
+
+// Signal is one evaluation of the queue, and the webhook body.
+type Signal struct {
+	Event   string `json:"event,omitempty"`
+	Starved bool   `json:"starved"`
+	// WaitSeconds is the p95 wait of stack scans claimed within the window,
+	// or the wait of the oldest pending stack scan if that is longer, so a
+	// queue nobody is draining still reads as starved.
+	WaitSeconds          float64 `json:"wait_seconds"`
+	P95Seconds           float64 `json:"p95_seconds"`
+	OldestPendingSeconds float64 `json:"oldest_pending_seconds"`
+	ThresholdSeconds     float64 `json:"threshold_seconds"`
+	Samples              int     `json:"samples"`
+	QueueDepth           int64   `json:"queue_depth"`
+	RunningStackScans    int     `json:"running_stack_scans"`
+	Workers              int     `json:"workers"`
+	// Since is when the wait first went over the threshold.
+	Since     *time.Time `json:"since,omitempty"`
+	Timestamp time.Time  `json:"timestamp"`
+}
+
+type waitSample struct {
+	at   time.Time
+	wait time.Duration
+}
+
+// Monitor evaluates queue wait on an interval. Every replica evaluates and
+// exports the metrics; only the leader sends webhooks.
+type Monitor struct {
+	cfg    config.QueueAlarmConfig
+	queue  queue.Backend
+	client *http.Client
+	leader Leader
+
+	mu          sync.Mutex
+	samples     []waitSample
+	breachSince time.Time
+	firing      bool
+
+	stop   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a monitor for cfg reading from q.
+func New(cfg config.QueueAlarmConfig, q queue.Backend) *Monitor {
+	return &Monitor{
+		cfg:    cfg,
+		queue:  q,
+		client: &http.Client{Timeout: webhookTimeout},
+		stop:   make(chan struct{}),
+	}
+}
+
+// SetLeader limits webhooks to the replica holding the scheduler lease, so
+// several serve replicas don't each page the autoscaler.
+func (m *Monitor) SetLeader(l Leader) {
+	m.leader = l
+}
+
+// Start follows stack events for claim waits and evaluates every interval
+// until Stop.
+func (m *Monitor) Start() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := m.queue.SubscribeProjectEvents(ctx, "")
+	if err != nil {
+		cancel()
+		return fmt.Errorf("subscribe to events: %w", err)
+	}
+	m.cancel = cancel
+
+	m.wg.Add(2)
+	go func() {
+		defer m.wg.Done()
+		for event := range events {
+			if event.Type == "stack_update" && event.Status == "running" && event.QueuedAt != nil && event.RunAt != nil {
+				m.Observe(*event.RunAt, event.RunAt.Sub(*event.QueuedAt))
+			}
+		}
+	}()
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+			}
+			m.Evaluate(ctx, time.Now())
+		}
+	}()
+	return nil
+}
+
+// Stop ends evaluation and waits for a webhook in flight.
+func (m *Monitor) Stop() {
+	close(m.stop)
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+}
+
+// Observe records that a stack scan was claimed at after waiting wait.
+func (m *Monitor) Observe(at time.Time, wait time.Duration) {
+	if wait < 0 {
+		wait = 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.samples = append(m.samples, waitSample{at: at, wait: wait})
+}
+
+// Evaluate reads the queue, updates the alarm and metrics, and sends a
+// webhook when the alarm fires or clears.
+func (m *Monitor) Evaluate(ctx context.Context, now time.Time) Signal {
+	oldest, err := m.queue.OldestPendingStackScanAge(ctx)
+	if err != nil {
+		log.Printf("queue alarm: oldest pending stack scan: %v", err)
+	}
+	sig := Signal{
+		OldestPendingSeconds: oldest.Seconds(),
+		ThresholdSeconds:     m.cfg.Threshold.Seconds(),
+		Timestamp:            now,
+	}
+	sig.QueueDepth, _ = m.queue.QueueDepth(ctx)
+	sig.RunningStackScans, _ = m.queue.RunningStackScanCount(ctx)
+	if workers, err := m.queue.ListWorkers(ctx); err == nil {
+		sig.Workers = len(workers)
+	}
+
+	m.mu.Lock()
+	p95, samples := m.p95Locked(now)
+	sig.P95Seconds = p95.Seconds()
+	sig.Samples = samples
+	wait := max(p95, oldest)
+	sig.WaitSeconds = wait.Seconds()
+
+	var transition string
+	if wait > m.cfg.Threshold {
+		if m.breachSince.IsZero() {
+			m.breachSince = now
+		}
+		since := m.breachSince
+		sig.Since = &since
+		if !m.firing && now.Sub(m.breachSince) >= m.cfg.For {
+			m.firing = true
+			transition = EventStarved
+		}
+	} else {
+		m.breachSince = time.Time{}
+		if m.firing {
+			m.firing = false
+			transition = EventRecovered
+		}
+	}
+	sig.Starved = m.firing
+	m.mu.Unlock()
+
+	metrics.SetQueueWait(wait, sig.Starved)
+	if transition == "" {
+		return sig
+	}
+	sig.Event = transition
+	if transition == EventStarved {
+		log.Printf("Queue starved: stack scans waiting %s (p95 %s, oldest pending %s) over %s threshold; %d queued, %d workers",
+			wait.Round(time.Second), p95.Round(time.Second), oldest.Round(time.Second), m.cfg.Threshold, sig.QueueDepth, sig.Workers)
+	} else {
+		log.Printf("Queue recovered: stack scans waiting %s", wait.Round(time.Second))
+	}
+	if m.leader != nil && !m.leader.IsLeader() {
+		return sig
+	}
+	if err := m.sendWebhook(ctx, sig); err != nil {
+		log.Printf("queue alarm: webhook: %v", err)
+	}
+	return sig
+}
+
+// p95Locked drops samples older than the window and returns the p95 of the
+// rest.
+func (m *Monitor) p95Locked(now time.Time) (time.Duration, int) {
+	cutoff := now.Add(-m.cfg.Window)
+	kept := m.samples[:0]
+	for _, s := range m.samples {
+		if !s.at.Before(cutoff) {
+			kept = append(kept, s)
+		}
+	}
+	m.samples = kept
+	if len(kept) == 0 {
+		return 0, 0
+	}
+	waits := make([]time.Duration, len(kept))
+	for i, s := range kept {
+		waits[i] = s.wait
+	}
+	sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+	idx := int(math.Ceil(0.95*float64(len(waits)))) - 1
+	return waits[max(idx, 0)], len(waits)
+}
+
+func (m *Monitor) sendWebhook(ctx context.Context, sig Signal) error {
+	if m.cfg.WebhookURL == "" {
+		return nil
+	}
+	body, err := json.Marshal(sig)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if m.cfg.WebhookSecret != "" {
+		mac := hmac.New(sha256.New, []byte(m.cfg.WebhookSecret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s returned %s", m.cfg.WebhookURL, resp.Status)
+	}
+	return nil
+}
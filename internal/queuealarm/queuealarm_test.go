@@ -0,0 +1,138 @@
+package queuealarm
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+type webhookRecorder struct {
+	mu      sync.Mutex
+	signals []Signal
+	sigs    []string
+	bodies  [][]byte
+}
+
+func (r *webhookRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	var sig Signal
+	_ = json.Unmarshal(body, &sig)
+	r.mu.Lock()
+	r.signals = append(r.signals, sig)
+	r.sigs = append(r.sigs, req.Header.Get(SignatureHeader))
+	r.bodies = append(r.bodies, body)
+	r.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type staticLeader bool
+
+func (l staticLeader) IsLeader() bool { return bool(l) }
+
+func newTestMonitor(t *testing.T, webhookURL string) *Monitor {
+	t.Helper()
+	q := queue.NewMemory(time.Minute)
+	t.Cleanup(func() { _ = q.Close() })
+	return New(config.QueueAlarmConfig{
+		Enabled:       true,
+		Threshold:     time.Minute,
+		For:           2 * time.Minute,
+		Window:        10 * time.Minute,
+		Interval:      time.Second,
+		WebhookURL:    webhookURL,
+		WebhookSecret: "s3cret",
+	}, q)
+}
+
+func TestMonitorFiresAfterForAndRecovers(t *testing.T) {
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	m := newTestMonitor(t, srv.URL)
+	ctx := context.Background()
+	start := time.Now()
+
+	// Nine fast claims and one slow one: p95 lands on the slow one.
+	for i := 0; i < 9; i++ {
+		m.Observe(start, 5*time.Second)
+	}
+	m.Observe(start, 5*time.Minute)
+
+	sig := m.Evaluate(ctx, start)
+	if sig.Starved || sig.Event != "" {
+		t.Fatalf("alarm fired before for elapsed: %+v", sig)
+	}
+	if sig.P95Seconds != 300 || sig.Samples != 10 {
+		t.Fatalf("unexpected p95: %+v", sig)
+	}
+	sig = m.Evaluate(ctx, start.Add(2*time.Minute))
+	if !sig.Starved || sig.Event != EventStarved || sig.Since == nil || !sig.Since.Equal(start) {
+		t.Fatalf("expected alarm to fire, got %+v", sig)
+	}
+	if sig = m.Evaluate(ctx, start.Add(3*time.Minute)); !sig.Starved || sig.Event != "" {
+		t.Fatalf("expected alarm to stay up without a new event, got %+v", sig)
+	}
+
+	// The slow sample ages out of the window.
+	sig = m.Evaluate(ctx, start.Add(11*time.Minute))
+	if sig.Starved || sig.Event != EventRecovered || sig.Samples != 0 {
+		t.Fatalf("expected recovery, got %+v", sig)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.signals) != 2 || rec.signals[0].Event != EventStarved || rec.signals[1].Event != EventRecovered {
+		t.Fatalf("unexpected webhooks: %+v", rec.signals)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(rec.bodies[0])
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); rec.sigs[0] != want {
+		t.Fatalf("signature = %q, want %q", rec.sigs[0], want)
+	}
+}
+
+func TestMonitorResetsWhenWaitDropsBeforeFor(t *testing.T) {
+	m := newTestMonitor(t, "")
+	ctx := context.Background()
+	start := time.Now()
+
+	m.Observe(start, 5*time.Minute)
+	m.Evaluate(ctx, start)
+	m.Evaluate(ctx, start.Add(11*time.Minute))
+	m.Observe(start.Add(12*time.Minute), 5*time.Minute)
+	sig := m.Evaluate(ctx, start.Add(12*time.Minute))
+	if sig.Starved || sig.Since == nil || !sig.Since.Equal(start.Add(12*time.Minute)) {
+		t.Fatalf("expected breach to restart, got %+v", sig)
+	}
+}
+
+func TestMonitorOnlyLeaderSendsWebhook(t *testing.T) {
+	rec := &webhookRecorder{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+	m := newTestMonitor(t, srv.URL)
+	m.SetLeader(staticLeader(false))
+	m.cfg.For = 0
+	start := time.Now()
+
+	m.Observe(start, 5*time.Minute)
+	if sig := m.Evaluate(context.Background(), start); !sig.Starved {
+		t.Fatalf("expected alarm on follower too, got %+v", sig)
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.signals) != 0 {
+		t.Fatalf("follower sent webhook: %+v", rec.signals)
+	}
+}
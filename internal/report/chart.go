@@ -0,0 +1,69 @@
+package report
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+)
+
+const (
+	chartWidth  = 560
+	chartHeight = 140
+	chartPad    = 8
+)
+
+var (
+	chartBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	chartAxis       = color.RGBA{0x9c, 0xa3, 0xaf, 0xff}
+	chartKnown      = color.RGBA{0xe5, 0xe7, 0xeb, 0xff}
+	chartDrifted    = color.RGBA{0xdc, 0x26, 0x26, 0xff}
+	chartErrored    = color.RGBA{0xf5, 0x9e, 0x0b, 0xff}
+)
+
+// renderTrendPNG draws one bar per day: the grey bar is the number of stacks
+// with a known state, overlaid by drifted stacks in red and errored stacks in
+// amber stacked on top. Email clients strip SVG and scripts, so the graph is
+// a plain image.
+func renderTrendPNG(points []DayPoint) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{chartBackground}, image.Point{}, draw.Src)
+
+	maxY := 1
+	for _, p := range points {
+		maxY = max(maxY, p.Known, p.Drifted+p.Errored)
+	}
+
+	plotW := chartWidth - 2*chartPad
+	plotH := chartHeight - 2*chartPad
+	baseline := chartHeight - chartPad
+	if len(points) > 0 {
+		slot := plotW / len(points)
+		gap := max(slot/5, 1)
+		for i, p := range points {
+			x0 := chartPad + i*slot + gap/2
+			x1 := x0 + slot - gap
+			height := func(n int) int { return n * plotH / maxY }
+
+			fillRect(img, x0, baseline-height(p.Known), x1, baseline, chartKnown)
+			drifted := height(p.Drifted)
+			fillRect(img, x0, baseline-drifted, x1, baseline, chartDrifted)
+			fillRect(img, x0, baseline-drifted-height(p.Errored), x1, baseline-drifted, chartErrored)
+		}
+	}
+	fillRect(img, chartPad, baseline, chartWidth-chartPad, baseline+1, chartAxis)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func fillRect(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	if y1 <= y0 || x1 <= x0 {
+		return
+	}
+	draw.Draw(img, image.Rect(x0, y0, x1, y1), &image.Uniform{c}, image.Point{}, draw.Src)
+}
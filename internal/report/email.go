@@ -0,0 +1,245 @@
+package report
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/severity"
+)
+
+// Sender delivers a complete RFC 5322 message.
+type Sender interface {
+	Send(from string, to []string, msg []byte) error
+}
+
+var emailTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.Format("Jan 2") },
+	"signed": func(n int) string {
+		if n > 0 {
+			return "+" + strconv.Itoa(n)
+		}
+		return strconv.Itoa(n)
+	},
+	"lastDay":       func(t time.Time) time.Time { return t.Add(-day) },
+	"severityLevel": severity.Level,
+}).Parse(`<!DOCTYPE html>
+<html>
+<body style="margin:0;padding:24px;background:#f3f4f6;font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;color:#111827">
+<table role="presentation" width="600" cellpadding="0" cellspacing="0" style="margin:0 auto;background:#ffffff;border-radius:6px;padding:24px">
+<tr><td>
+<h1 style="font-size:20px;margin:0 0 4px">Drift report</h1>
+<p style="margin:0 0 20px;color:#6b7280">{{date .Report.Start}} &ndash; {{date (lastDay .Report.End)}}</p>
+{{if not .Report.Projects}}<p>No projects have scan results yet.</p>{{else}}
+<table role="presentation" width="100%" cellpadding="6" cellspacing="0" style="border-collapse:collapse;font-size:14px;margin-bottom:24px">
+<tr style="text-align:left;border-bottom:1px solid #e5e7eb"><th>Project</th><th>Stacks</th><th>Drifted</th><th>vs last week</th><th>Errored</th><th>Severity</th></tr>
+{{range .Report.Projects}}<tr style="border-bottom:1px solid #f3f4f6"><td><a href="{{$.BaseURL}}/projects/{{.Name}}">{{.Name}}</a></td><td>{{.Stacks}}</td><td>{{.Drifted}}</td><td>{{signed .Change}}</td><td>{{.Errored}}</td><td>{{with severityLevel .Severity}}{{.}}{{else}}-{{end}}</td></tr>
+{{end}}</table>
+{{range $i, $p := .Report.Projects}}<h2 style="font-size:16px;margin:16px 0 4px">{{$p.Name}}</h2>
+<p style="margin:0 0 6px;font-size:12px;color:#6b7280">Stacks per day: <span style="color:#dc2626">drifted</span>, <span style="color:#f59e0b">errored</span>, <span style="color:#9ca3af">scanned</span></p>
+<img src="{{index $.ImageSrcs $i}}" width="560" height="140" alt="Drift trend for {{$p.Name}}" style="display:block;border:1px solid #e5e7eb">
+{{end}}{{end}}
+<p style="margin:24px 0 0;font-size:12px;color:#6b7280">Sent by driftd to {{.Recipient}}. <a href="{{.UnsubscribeURL}}">Unsubscribe</a></p>
+</td></tr>
+</table>
+</body>
+</html>
+`))
+
+type emailData struct {
+	Report         *Report
+	BaseURL        string
+	Recipient      string
+	UnsubscribeURL string
+	// ImageSrcs holds one image URL per project: a cid: reference in emails
+	// and a data: URL in previews.
+	ImageSrcs []template.URL
+}
+
+// unsubscribeURL returns the one-click unsubscribe link for recipient.
+func unsubscribeURL(baseURL, recipient, token string) string {
+	q := url.Values{"email": {recipient}, "token": {token}}
+	return baseURL + "/report/unsubscribe?" + q.Encode()
+}
+
+// renderHTML renders the email body.
+func renderHTML(data emailData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := emailTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// buildMessage assembles a multipart/related message with the HTML body and
+// the trend graphs as inline images.
+func buildMessage(from, to, subject string, html []byte, images map[string][]byte, unsubscribe string, now time.Time) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	part, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeBase64(part, html); err != nil {
+		return nil, err
+	}
+	for id, png := range images {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"image/png"},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-ID":                {"<" + id + ">"},
+			"Content-Disposition":       {`inline; filename="` + id + `.png"`},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64(part, png); err != nil {
+			return nil, err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	headers := []struct{ k, v string }{
+		{"From", from},
+		{"To", to},
+		{"Subject", mime.QEncoding.Encode("utf-8", subject)},
+		{"Date", now.Format(time.RFC1123Z)},
+		{"Message-ID", "<" + randomID() + "@driftd>"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", `multipart/related; boundary="` + mw.Boundary() + `"`},
+		{"List-Unsubscribe", "<" + unsubscribe + ">"},
+		{"List-Unsubscribe-Post", "List-Unsubscribe=One-Click"},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&msg, "%s: %s\r\n", h.k, h.v)
+	}
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+// writeBase64 writes data base64-encoded in 76-character lines.
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := w.Write([]byte(encoded[:76] + "\r\n")); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := w.Write([]byte(encoded + "\r\n"))
+	return err
+}
+
+func randomID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// smtpSender sends through the configured SMTP relay.
+type smtpSender struct {
+	cfg config.SMTPConfig
+}
+
+// NewSMTPSender returns a Sender for cfg. The password is read from
+// password_env at send time when password is empty.
+func NewSMTPSender(cfg config.SMTPConfig) Sender {
+	return &smtpSender{cfg: cfg}
+}
+
+func (s *smtpSender) Send(from string, to []string, msg []byte) error {
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	tlsConfig := &tls.Config{ServerName: s.cfg.Host, MinVersion: tls.VersionTLS12}
+
+	var (
+		client *smtp.Client
+		err    error
+	)
+	if s.cfg.TLS == "tls" {
+		conn, dialErr := tls.DialWithDialer(&net.Dialer{Timeout: 30 * time.Second}, "tcp", addr, tlsConfig)
+		if dialErr != nil {
+			return dialErr
+		}
+		client, err = smtp.NewClient(conn, s.cfg.Host)
+	} else {
+		conn, dialErr := net.DialTimeout("tcp", addr, 30*time.Second)
+		if dialErr != nil {
+			return dialErr
+		}
+		client, err = smtp.NewClient(conn, s.cfg.Host)
+	}
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if s.cfg.TLS == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server %s does not support STARTTLS", s.cfg.Host)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if s.cfg.Username != "" {
+		password := s.cfg.Password
+		if password == "" && s.cfg.PasswordEnv != "" {
+			password = os.Getenv(s.cfg.PasswordEnv)
+		}
+		if err := client.Auth(smtp.PlainAuth("", s.cfg.Username, password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(envelopeAddress(from)); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(envelopeAddress(rcpt)); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// envelopeAddress strips the display name from an address.
+func envelopeAddress(addr string) string {
+	if parsed, err := mail.ParseAddress(addr); err == nil {
+		return parsed.Address
+	}
+	return strings.TrimSpace(addr)
+}
@@ -0,0 +1,240 @@
+// Package report sends the scheduled drift report email: per-project drift
+// trend graphs built from the stack history store.
+package report
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/severity"
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/robfig/cron/v3"
+)
+
+// ErrNoRecipients is returned by Send when nobody is subscribed.
+var ErrNoRecipients = errors.New("no report recipients")
+
+// Service renders and sends the report on its schedule.
+type Service struct {
+	cfg      config.ReportConfig
+	store    storage.Store
+	severity *severity.Policy
+	state    *State
+	sender   Sender
+	cron     *cron.Cron
+	entry    cron.EntryID
+	now      func() time.Time
+
+	sendMu sync.Mutex
+}
+
+// Status describes the report configuration and delivery state.
+type Status struct {
+	Enabled      bool       `json:"enabled"`
+	Schedule     string     `json:"schedule"`
+	Weeks        int        `json:"weeks"`
+	Recipients   []string   `json:"recipients"`
+	Unsubscribed []string   `json:"unsubscribed"`
+	NextRun      *time.Time `json:"next_run,omitempty"`
+	LastSent     *time.Time `json:"last_sent,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+}
+
+// New loads the report state from dataDir. sender is typically
+// NewSMTPSender(cfg.SMTP). Projects are ranked by policy; nil uses the
+// default severity policy.
+func New(cfg config.ReportConfig, store storage.Store, policy *severity.Policy, dataDir string, sender Sender) (*Service, error) {
+	state, err := LoadState(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		cfg:      cfg,
+		store:    store,
+		severity: policy,
+		state:    state,
+		sender:   sender,
+		cron:     cron.New(),
+		now:      time.Now,
+	}, nil
+}
+
+// Start schedules the report.
+func (s *Service) Start() error {
+	entry, err := s.cron.AddFunc(s.cfg.Schedule, func() {
+		sent, err := s.Send(context.Background())
+		if err != nil {
+			log.Printf("Drift report: %v", err)
+			return
+		}
+		log.Printf("Drift report sent to %d recipient(s)", sent)
+	})
+	if err != nil {
+		return err
+	}
+	s.entry = entry
+	s.cron.Start()
+	log.Printf("Scheduled drift report: %s", s.cfg.Schedule)
+	return nil
+}
+
+// Stop stops the schedule and waits for a running send to finish.
+func (s *Service) Stop() {
+	ctx := s.cron.Stop()
+	<-ctx.Done()
+}
+
+// Recipients returns who the next report goes to.
+func (s *Service) Recipients() []string {
+	return s.state.Recipients(s.cfg.Recipients)
+}
+
+// SetRecipients replaces the recipients managed through the API. Recipients
+// from the config file always stay subscribed unless they unsubscribe.
+func (s *Service) SetRecipients(addrs []string) error {
+	return s.state.SetRecipients(addrs)
+}
+
+// VerifyToken checks the token from an unsubscribe link.
+func (s *Service) VerifyToken(addr, token string) error {
+	return s.state.VerifyToken(addr, token)
+}
+
+// Unsubscribe removes addr after checking the token from its email.
+func (s *Service) Unsubscribe(addr, token string) error {
+	if err := s.VerifyToken(addr, token); err != nil {
+		return err
+	}
+	return s.state.Unsubscribe(addr)
+}
+
+// Status reports the schedule, recipients and last delivery.
+func (s *Service) Status() Status {
+	st := Status{
+		Enabled:      true,
+		Schedule:     s.cfg.Schedule,
+		Weeks:        s.cfg.Weeks,
+		Recipients:   s.Recipients(),
+		Unsubscribed: s.state.Unsubscribed(),
+	}
+	if s.entry != 0 {
+		if next := s.cron.Entry(s.entry).Next; !next.IsZero() {
+			st.NextRun = &next
+		}
+	}
+	lastSent, lastErr := s.state.LastSend()
+	if !lastSent.IsZero() {
+		st.LastSent = &lastSent
+	}
+	st.LastError = lastErr
+	return st
+}
+
+// Preview renders the report HTML as a recipient would see it, with images
+// inlined as data URLs.
+func (s *Service) Preview(recipient string) ([]byte, error) {
+	rep, err := Build(s.store, s.severity, s.cfg.Weeks, s.now())
+	if err != nil {
+		return nil, err
+	}
+	images, err := renderImages(rep)
+	if err != nil {
+		return nil, err
+	}
+	data := s.emailData(rep, recipient)
+	for i, png := range images {
+		data.ImageSrcs[i] = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png))
+	}
+	return renderHTML(data)
+}
+
+// Send builds the report and sends one message per recipient so each gets
+// a personal unsubscribe link. It returns how many messages were sent.
+func (s *Service) Send(ctx context.Context) (int, error) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	recipients := s.Recipients()
+	if len(recipients) == 0 {
+		return 0, ErrNoRecipients
+	}
+	now := s.now()
+	rep, err := Build(s.store, s.severity, s.cfg.Weeks, now)
+	if err != nil {
+		return 0, err
+	}
+
+	images, err := renderImages(rep)
+	if err != nil {
+		return 0, err
+	}
+	inline := make(map[string][]byte, len(images))
+	for i, png := range images {
+		inline[imageID(i)] = png
+	}
+
+	subject := fmt.Sprintf("driftd drift report %s - %s", rep.Start.Format("Jan 2"), rep.End.Add(-day).Format("Jan 2"))
+	sent := 0
+	var errs []error
+	for _, rcpt := range recipients {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+		data := s.emailData(rep, rcpt)
+		html, err := renderHTML(data)
+		if err != nil {
+			return sent, err
+		}
+		msg, err := buildMessage(s.cfg.SMTP.From, rcpt, subject, html, inline, data.UnsubscribeURL, now)
+		if err != nil {
+			return sent, err
+		}
+		if err := s.sender.Send(envelopeAddress(s.cfg.SMTP.From), []string{rcpt}, msg); err != nil {
+			errs = append(errs, fmt.Errorf("send to %s: %w", rcpt, err))
+			continue
+		}
+		sent++
+	}
+	sendErr := errors.Join(errs...)
+	if err := s.state.RecordSend(now, sendErr); err != nil {
+		log.Printf("Drift report: failed to record send: %v", err)
+	}
+	return sent, sendErr
+}
+
+func (s *Service) emailData(rep *Report, recipient string) emailData {
+	data := emailData{
+		Report:         rep,
+		BaseURL:        s.cfg.PublicURL,
+		Recipient:      recipient,
+		UnsubscribeURL: unsubscribeURL(s.cfg.PublicURL, recipient, s.state.Token(recipient)),
+	}
+	for i := range rep.Projects {
+		data.ImageSrcs = append(data.ImageSrcs, template.URL("cid:"+imageID(i)))
+	}
+	return data
+}
+
+func renderImages(rep *Report) ([][]byte, error) {
+	images := make([][]byte, len(rep.Projects))
+	for i, p := range rep.Projects {
+		png, err := renderTrendPNG(p.Days)
+		if err != nil {
+			return nil, err
+		}
+		images[i] = png
+	}
+	return images, nil
+}
+
+func imageID(i int) string {
+	return fmt.Sprintf("trend-%d", i)
+}
@@ -0,0 +1,157 @@
+package report
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+type sentMessage struct {
+	from string
+	to   []string
+	msg  []byte
+}
+
+type fakeSender struct {
+	sent []sentMessage
+	err  error
+}
+
+func (f *fakeSender) Send(from string, to []string, msg []byte) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, sentMessage{from: from, to: to, msg: msg})
+	return nil
+}
+
+func newTestService(t *testing.T, sender Sender) *Service {
+	t.Helper()
+	dir := t.TempDir()
+	store := storage.New(dir)
+	if err := store.SaveResult("proj", "envs/dev", &storage.RunResult{Drifted: true, RunAt: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	svc, err := New(config.ReportConfig{
+		Enabled:    true,
+		Schedule:   "0 8 * * 1",
+		Weeks:      1,
+		Recipients: []string{"Ops <ops@example.com>"},
+		PublicURL:  "https://driftd.example.com",
+		SMTP:       config.SMTPConfig{From: "driftd <driftd@example.com>"},
+	}, store, nil, dir, sender)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	return svc
+}
+
+func TestSendBuildsPersonalizedMessage(t *testing.T) {
+	sender := &fakeSender{}
+	svc := newTestService(t, sender)
+	if err := svc.SetRecipients([]string{"dev@example.com"}); err != nil {
+		t.Fatalf("set recipients: %v", err)
+	}
+
+	sent, err := svc.Send(context.Background())
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if sent != 2 || len(sender.sent) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(sender.sent))
+	}
+
+	got := sender.sent[1]
+	if got.from != "driftd@example.com" || len(got.to) != 1 {
+		t.Fatalf("unexpected envelope: %+v", got)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(string(got.msg)))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	unsubscribe := msg.Header.Get("List-Unsubscribe")
+	if !strings.HasPrefix(unsubscribe, "<https://driftd.example.com/report/unsubscribe?") {
+		t.Fatalf("unexpected List-Unsubscribe: %q", unsubscribe)
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/related" {
+		t.Fatalf("unexpected content type %q: %v", mediaType, err)
+	}
+
+	var html string
+	var images int
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next part: %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		switch part.Header.Get("Content-Type") {
+		case "image/png":
+			if part.Header.Get("Content-ID") != "<trend-0>" {
+				t.Fatalf("unexpected Content-ID %q", part.Header.Get("Content-ID"))
+			}
+			images++
+		default:
+			decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(body), "\r\n", ""))
+			if err != nil {
+				t.Fatalf("decode html: %v", err)
+			}
+			html = string(decoded)
+		}
+	}
+	if images != 1 {
+		t.Fatalf("expected 1 inline image, got %d", images)
+	}
+	if !strings.Contains(html, `src="cid:trend-0"`) || !strings.Contains(html, "https://driftd.example.com/projects/proj") {
+		t.Fatalf("unexpected html:\n%s", html)
+	}
+
+	status := svc.Status()
+	if status.LastSent == nil || status.LastError != "" {
+		t.Fatalf("send not recorded: %+v", status)
+	}
+}
+
+func TestSendRecordsFailure(t *testing.T) {
+	svc := newTestService(t, &fakeSender{err: errors.New("connection refused")})
+	if _, err := svc.Send(context.Background()); err == nil {
+		t.Fatalf("expected error")
+	}
+	if status := svc.Status(); status.LastSent != nil || !strings.Contains(status.LastError, "connection refused") {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	token := svc.state.Token("ops@example.com")
+	if err := svc.Unsubscribe("ops@example.com", token); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	if _, err := svc.Send(context.Background()); !errors.Is(err, ErrNoRecipients) {
+		t.Fatalf("expected ErrNoRecipients, got %v", err)
+	}
+}
+
+func TestPreviewInlinesImages(t *testing.T) {
+	svc := newTestService(t, &fakeSender{})
+	html, err := svc.Preview("ops@example.com")
+	if err != nil {
+		t.Fatalf("preview: %v", err)
+	}
+	if !strings.Contains(string(html), `src="data:image/png;base64,`) {
+		t.Fatalf("expected data URL image in preview")
+	}
+}
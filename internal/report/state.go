@@ -0,0 +1,213 @@
+package report
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const stateFileName = "report.json"
+
+// ErrInvalidToken is returned for unsubscribe requests with a bad token.
+var ErrInvalidToken = errors.New("invalid unsubscribe token")
+
+type stateData struct {
+	Version int `json:"version"`
+	// Recipients are added through the API, on top of report.recipients.
+	Recipients   []string  `json:"recipients"`
+	Unsubscribed []string  `json:"unsubscribed"`
+	SigningKey   []byte    `json:"signing_key"`
+	LastSent     time.Time `json:"last_sent,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+// State persists report recipients, unsubscribes and the key that signs
+// unsubscribe links under the data directory.
+type State struct {
+	path string
+	mu   sync.Mutex
+	data stateData
+}
+
+// LoadState reads the report state from dataDir, creating a signing key on
+// first use.
+func LoadState(dataDir string) (*State, error) {
+	s := &State{path: filepath.Join(dataDir, stateFileName)}
+	raw, err := os.ReadFile(s.path)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read report state: %w", err)
+	default:
+		if err := json.Unmarshal(raw, &s.data); err != nil {
+			return nil, fmt.Errorf("failed to parse report state: %w", err)
+		}
+	}
+	if len(s.data.SigningKey) == 0 {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		s.data.SigningKey = key
+		if err := s.saveLocked(); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Recipients returns configured plus added recipients, minus unsubscribed
+// ones, sorted and deduplicated.
+func (s *State) Recipients(configured []string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := []string{}
+	seen := map[string]struct{}{}
+	for _, addr := range append(append([]string{}, configured...), s.data.Recipients...) {
+		key := normalizeAddress(addr)
+		if _, ok := seen[key]; ok || contains(s.data.Unsubscribed, key) {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, addr)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Unsubscribed returns the addresses that opted out.
+func (s *State) Unsubscribed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.data.Unsubscribed...)
+}
+
+// SetRecipients replaces the API-managed recipients. Listing an address that
+// previously unsubscribed subscribes it again.
+func (s *State) SetRecipients(addrs []string) error {
+	for _, addr := range addrs {
+		if _, err := mail.ParseAddress(addr); err != nil {
+			return fmt.Errorf("invalid address %q", addr)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Recipients = append([]string{}, addrs...)
+	kept := s.data.Unsubscribed[:0]
+	for _, addr := range s.data.Unsubscribed {
+		if !containsAddress(addrs, addr) {
+			kept = append(kept, addr)
+		}
+	}
+	s.data.Unsubscribed = kept
+	return s.saveLocked()
+}
+
+// Unsubscribe stops sending to addr. It applies to configured recipients as
+// well as API-managed ones.
+func (s *State) Unsubscribe(addr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := normalizeAddress(addr)
+	if !contains(s.data.Unsubscribed, key) {
+		s.data.Unsubscribed = append(s.data.Unsubscribed, key)
+		sort.Strings(s.data.Unsubscribed)
+	}
+	return s.saveLocked()
+}
+
+// Token signs addr for unsubscribe links.
+func (s *State) Token(addr string) string {
+	s.mu.Lock()
+	key := s.data.SigningKey
+	s.mu.Unlock()
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(normalizeAddress(addr)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyToken checks an unsubscribe token for addr.
+func (s *State) VerifyToken(addr, token string) error {
+	if addr == "" || !hmac.Equal([]byte(s.Token(addr)), []byte(token)) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// RecordSend stores the outcome of the last send.
+func (s *State) RecordSend(at time.Time, sendErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.LastError = ""
+	if sendErr != nil {
+		s.data.LastError = sendErr.Error()
+	} else {
+		s.data.LastSent = at.UTC()
+	}
+	return s.saveLocked()
+}
+
+// LastSend returns the time of the last successful send and the error of the
+// last attempt, if it failed.
+func (s *State) LastSend() (time.Time, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data.LastSent, s.data.LastError
+}
+
+func (s *State) saveLocked() error {
+	s.data.Version = 1
+	raw, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal report state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0750); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write report state: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func normalizeAddress(addr string) string {
+	if parsed, err := mail.ParseAddress(addr); err == nil {
+		addr = parsed.Address
+	}
+	return strings.ToLower(strings.TrimSpace(addr))
+}
+
+func contains(list []string, key string) bool {
+	for _, v := range list {
+		if v == key {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAddress(list []string, addr string) bool {
+	key := normalizeAddress(addr)
+	for _, v := range list {
+		if normalizeAddress(v) == key {
+			return true
+		}
+	}
+	return false
+}
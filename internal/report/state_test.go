@@ -0,0 +1,55 @@
+package report
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStateRecipientsAndUnsubscribe(t *testing.T) {
+	dir := t.TempDir()
+	state, err := LoadState(dir)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	configured := []string{"ops@example.com"}
+	if err := state.SetRecipients([]string{"dev@example.com", "OPS@example.com"}); err != nil {
+		t.Fatalf("set recipients: %v", err)
+	}
+	if got := state.Recipients(configured); !reflect.DeepEqual(got, []string{"dev@example.com", "ops@example.com"}) {
+		t.Fatalf("unexpected recipients: %v", got)
+	}
+	if err := state.SetRecipients([]string{"not an address"}); err == nil {
+		t.Fatalf("expected invalid address error")
+	}
+
+	token := state.Token("ops@example.com")
+	if err := state.VerifyToken("Ops@Example.com", token); err != nil {
+		t.Fatalf("token should not depend on case: %v", err)
+	}
+	if err := state.VerifyToken("dev@example.com", token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken, got %v", err)
+	}
+	if err := state.Unsubscribe("ops@example.com"); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+
+	reloaded, err := LoadState(dir)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got := reloaded.Recipients(configured); !reflect.DeepEqual(got, []string{"dev@example.com"}) {
+		t.Fatalf("unsubscribed recipient still listed: %v", got)
+	}
+	if reloaded.Token("ops@example.com") != token {
+		t.Fatalf("signing key was not persisted")
+	}
+
+	// Adding the address back through the API resubscribes it.
+	if err := reloaded.SetRecipients([]string{"ops@example.com"}); err != nil {
+		t.Fatalf("set recipients: %v", err)
+	}
+	if got := reloaded.Recipients(configured); !reflect.DeepEqual(got, []string{"ops@example.com"}) {
+		t.Fatalf("expected resubscribed recipient, got %v", got)
+	}
+}
@@ -0,0 +1,131 @@
+package report
+
+import (
+	"sort"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/severity"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+const day = 24 * time.Hour
+
+// Report is the content of one report email.
+type Report struct {
+	Start    time.Time
+	End      time.Time
+	Projects []ProjectTrend
+}
+
+// ProjectTrend summarizes one project over the report window.
+type ProjectTrend struct {
+	Name    string
+	Stacks  int
+	Drifted int
+	Errored int
+	// Severity is the sum of the severity scores of the drifted stacks.
+	Severity int
+	// DriftedWeekAgo is the number of stacks drifted at the end of the day a
+	// week before the last day.
+	DriftedWeekAgo int
+	Days           []DayPoint
+}
+
+// Change is the difference in drifted stacks compared to a week ago.
+func (p ProjectTrend) Change() int {
+	return p.Drifted - p.DriftedWeekAgo
+}
+
+// DayPoint is the state of a project's stacks at the end of a UTC day. A
+// stack keeps the outcome of its last run until it runs again.
+type DayPoint struct {
+	Date    time.Time
+	Known   int
+	Drifted int
+	Errored int
+}
+
+// Build computes drift trends for every project with results, covering the
+// weeks before now, most severe drift first. Suppressed stacks are left out.
+// A nil policy scores with the defaults.
+func Build(store storage.Store, policy *severity.Policy, weeks int, now time.Time) (*Report, error) {
+	if policy == nil {
+		policy = severity.New(config.SeverityConfig{})
+	}
+	days := weeks * 7
+	// One extra day so the week-ago comparison also works for one-week
+	// reports; it is not graphed.
+	tracked := days + 1
+	end := now.UTC().Truncate(day).Add(day)
+	start := end.Add(-time.Duration(days) * day)
+	trackStart := end.Add(-time.Duration(tracked) * day)
+
+	repos, err := store.ListRepos()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].Name < repos[j].Name })
+
+	report := &Report{Start: start, End: end}
+	for _, repo := range repos {
+		stacks, err := store.ListStacks(repo.Name)
+		if err != nil {
+			return nil, err
+		}
+		points := make([]DayPoint, tracked)
+		for i := range points {
+			points[i].Date = trackStart.Add(time.Duration(i) * day)
+		}
+		trend := ProjectTrend{Name: repo.Name}
+		for _, st := range stacks {
+			if st.Suppressed {
+				continue
+			}
+			trend.Stacks++
+			if st.Drifted {
+				trend.Drifted++
+			}
+			trend.Severity += policy.Score(st)
+			if st.Error != "" {
+				trend.Errored++
+			}
+			history, err := store.StackHistory(repo.Name, st.Path, time.Time{})
+			if err != nil {
+				return nil, err
+			}
+			addStackHistory(points, history)
+		}
+		trend.DriftedWeekAgo = points[tracked-8].Drifted
+		trend.Days = points[tracked-days:]
+		report.Projects = append(report.Projects, trend)
+	}
+	sort.SliceStable(report.Projects, func(i, j int) bool {
+		return report.Projects[i].Severity > report.Projects[j].Severity
+	})
+	return report, nil
+}
+
+// addStackHistory adds one stack's state at the end of each day to points.
+// history is ordered oldest first.
+func addStackHistory(points []DayPoint, history []storage.HistoryEntry) {
+	next := 0
+	var last *storage.HistoryEntry
+	for i := range points {
+		dayEnd := points[i].Date.Add(day)
+		for next < len(history) && history[next].RunAt.Before(dayEnd) {
+			last = &history[next]
+			next++
+		}
+		if last == nil {
+			continue
+		}
+		points[i].Known++
+		if last.Drifted {
+			points[i].Drifted++
+		}
+		if last.Errored {
+			points[i].Errored++
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package report
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestBuildTracksDailyState(t *testing.T) {
+	store := storage.New(t.TempDir())
+	now := time.Now().UTC()
+	today := now.Truncate(day)
+
+	save := func(stack string, at time.Time, drifted bool, errMsg string) {
+		t.Helper()
+		if err := store.SaveResult("proj", stack, &storage.RunResult{Drifted: drifted, Error: errMsg, RunAt: at}); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	save("envs/dev", today.Add(-10*day+time.Hour), false, "")
+	save("envs/dev", today.Add(-3*day+time.Hour), true, "")
+	save("envs/prod", today.Add(-9*day+time.Hour), true, "")
+	save("envs/prod", today.Add(-2*day+time.Hour), false, "plan failed")
+	save("envs/muted", today.Add(-1*day+time.Hour), true, "")
+	if err := store.SetStackSuppressed("proj", "envs/muted", true, "test"); err != nil {
+		t.Fatalf("suppress: %v", err)
+	}
+
+	rep, err := Build(store, nil, 2, now)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if len(rep.Projects) != 1 {
+		t.Fatalf("expected 1 project, got %d", len(rep.Projects))
+	}
+	p := rep.Projects[0]
+	if len(p.Days) != 14 {
+		t.Fatalf("expected 14 days, got %d", len(p.Days))
+	}
+	if !p.Days[13].Date.Equal(today) {
+		t.Fatalf("expected last day %s, got %s", today, p.Days[13].Date)
+	}
+	if p.Stacks != 2 || p.Drifted != 1 || p.Errored != 1 {
+		t.Fatalf("unexpected totals: %+v", p)
+	}
+	// A week ago only prod was drifted.
+	if p.DriftedWeekAgo != 1 || p.Change() != 0 {
+		t.Fatalf("unexpected week-ago comparison: %d (change %d)", p.DriftedWeekAgo, p.Change())
+	}
+
+	cases := []struct {
+		daysAgo                 int
+		known, drifted, errored int
+	}{
+		{11, 0, 0, 0},
+		{10, 1, 0, 0},
+		{9, 2, 1, 0},
+		{3, 2, 2, 0},
+		{2, 2, 1, 1},
+		{0, 2, 1, 1},
+	}
+	for _, tc := range cases {
+		got := p.Days[13-tc.daysAgo]
+		if got.Known != tc.known || got.Drifted != tc.drifted || got.Errored != tc.errored {
+			t.Fatalf("%d days ago: got %+v, want known=%d drifted=%d errored=%d", tc.daysAgo, got, tc.known, tc.drifted, tc.errored)
+		}
+	}
+}
+
+func TestBuildOrdersProjectsBySeverity(t *testing.T) {
+	store := storage.New(t.TempDir())
+	now := time.Now().UTC()
+	results := map[string]*storage.RunResult{
+		"alpha": {Drifted: true, Changed: 1, RunAt: now},
+		"beta":  {Drifted: true, Destroyed: 2, RunAt: now},
+		"gamma": {RunAt: now},
+	}
+	for project, result := range results {
+		if err := store.SaveResult(project, "envs/prod", result); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+
+	rep, err := Build(store, nil, 1, now)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	var got []string
+	for _, p := range rep.Projects {
+		got = append(got, p.Name)
+	}
+	if len(got) != 3 || got[0] != "beta" || got[1] != "alpha" || got[2] != "gamma" {
+		t.Fatalf("expected projects ordered by severity, got %v", got)
+	}
+	if rep.Projects[0].Severity <= rep.Projects[1].Severity || rep.Projects[2].Severity != 0 {
+		t.Fatalf("unexpected severities: %+v", rep.Projects)
+	}
+}
+
+func TestRenderTrendPNG(t *testing.T) {
+	points := []DayPoint{{Known: 3, Drifted: 1}, {Known: 3, Drifted: 2, Errored: 1}, {}}
+	raw, err := renderTrendPNG(points)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != chartWidth || b.Dy() != chartHeight {
+		t.Fatalf("unexpected size %v", b)
+	}
+}
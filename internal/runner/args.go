@@ -0,0 +1,29 @@
+package runner
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// checkArgFiles rejects -backend-config and -var-file paths that resolve
+// outside projectRoot. Paths are relative to workDir, the stack directory.
+func checkArgFiles(projectRoot, workDir string, args []string) error {
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok || (name != "-backend-config" && name != "-var-file") {
+			continue
+		}
+		if name == "-backend-config" && strings.Contains(value, "=") {
+			continue
+		}
+		if filepath.IsAbs(value) {
+			return fmt.Errorf("argument %q must use a path relative to the stack", arg)
+		}
+		rel, err := filepath.Rel(projectRoot, filepath.Join(workDir, value))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("argument %q points outside the repository", arg)
+		}
+	}
+	return nil
+}
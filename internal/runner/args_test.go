@@ -0,0 +1,72 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunPlanPassesExtraArgs(t *testing.T) {
+	tmp := t.TempDir()
+	workDir := filepath.Join(tmp, "work")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatalf("mkdir workDir: %v", err)
+	}
+	logPath := filepath.Join(tmp, "tf.log")
+	tfBin := filepath.Join(tmp, "terraform")
+	script := `#!/bin/sh
+echo "$*" >> "` + logPath + `"
+exit 0
+`
+	if err := os.WriteFile(tfBin, []byte(script), 0755); err != nil {
+		t.Fatalf("write terraform script: %v", err)
+	}
+	t.Setenv("TF_PLUGIN_CACHE_DIR", filepath.Join(tmp, "cache"))
+
+	opts := planOptions{
+		initArgs: []string{"-backend-config=backend.hcl", "-reconfigure"},
+		planArgs: []string{"-lock=false"},
+	}
+	if out, err := runPlan(context.Background(), workDir, "terraform", tfBin, "", tmp, "work", "run-1", opts); err != nil {
+		t.Fatalf("runPlan error: %v\noutput:\n%s", err, out)
+	}
+
+	logBytes, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(logBytes)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected init and plan, got %q", lines)
+	}
+	if lines[0] != "init -input=false -backend-config=backend.hcl -reconfigure" {
+		t.Fatalf("unexpected init args %q", lines[0])
+	}
+	if lines[1] != "plan -detailed-exitcode -input=false -lock=false" {
+		t.Fatalf("unexpected plan args %q", lines[1])
+	}
+}
+
+func TestCheckArgFiles(t *testing.T) {
+	root := t.TempDir()
+	workDir := filepath.Join(root, "envs", "prod")
+	cases := []struct {
+		arg string
+		ok  bool
+	}{
+		{"-backend-config=backend.hcl", true},
+		{"-backend-config=../shared/backend.hcl", true},
+		{"-backend-config=bucket=state", true},
+		{"-var-file=../../../etc/passwd", false},
+		{"-var-file=/etc/passwd", false},
+		{"-lock=false", true},
+	}
+	for _, tc := range cases {
+		err := checkArgFiles(root, workDir, []string{tc.arg})
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: unexpected result %v", tc.arg, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -295,6 +296,13 @@ func runPlanOnlyProxy(wrapperPath string, args []string, stdout, stderr *os.File
 		return 2
 	}
 
+	if cache := dependencyOutputCacheFromEnv(); cache != nil {
+		return cache.run(target, subcommand, args, stdout, stderr)
+	}
+	return runTerraformTarget(target, args, stdout, stderr)
+}
+
+func runTerraformTarget(target string, args []string, stdout, stderr io.Writer) int {
 	cmd := exec.Command(target, args...)
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = stdout
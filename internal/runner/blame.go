@@ -0,0 +1,202 @@
+package runner
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// maxBlamedResources bounds the blame work for one stack. go-git walks the
+// file's full history, so very large plans only get their first resources
+// attributed.
+const maxBlamedResources = 50
+
+var (
+	resourceBlockPattern = regexp.MustCompile(`^\s*resource\s+"([^"]+)"\s+"([^"]+)"`)
+	heredocPattern       = regexp.MustCompile(`<<-?\s*([A-Za-z_][A-Za-z0-9_]*)\s*$`)
+	resourceIndexPattern = regexp.MustCompile(`\[[^\]]*\]$`)
+)
+
+// resourceDefinition is where a resource is defined, by 1-based line range.
+type resourceDefinition struct {
+	file       string
+	start, end int
+}
+
+// blameResources attributes each changed resource defined in the stack's
+// root module to the newest commit among the lines of its resource block.
+// Resources in modules, data sources and resources whose block cannot be
+// found are left out, as is everything when the workspace has no history.
+func blameResources(projectRoot, workDir string, changes []storage.ResourceChange) []storage.ResourceOwner {
+	if len(changes) == 0 {
+		return nil
+	}
+	repo, err := git.PlainOpen(projectRoot)
+	if err != nil {
+		return nil
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return nil
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil
+	}
+
+	blocks := findResourceBlocks(workDir)
+	blames := make(map[string]*git.BlameResult)
+	summaries := make(map[string]string)
+	var owners []storage.ResourceOwner
+	for _, change := range changes {
+		if len(owners) >= maxBlamedResources {
+			break
+		}
+		if change.Action == "read" {
+			continue
+		}
+		block, ok := blocks[resourceKey(change.Address)]
+		if !ok {
+			continue
+		}
+		rel, err := filepath.Rel(projectRoot, block.file)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		result, ok := blames[rel]
+		if !ok {
+			result, _ = git.Blame(commit, rel)
+			blames[rel] = result
+		}
+		if result == nil || block.end > len(result.Lines) {
+			continue
+		}
+		newest := result.Lines[block.start-1]
+		for _, line := range result.Lines[block.start-1 : block.end] {
+			if line.Date.After(newest.Date) {
+				newest = line
+			}
+		}
+		sha := newest.Hash.String()
+		summary, ok := summaries[sha]
+		if !ok {
+			summary = commitSummary(repo, newest.Hash)
+			summaries[sha] = summary
+		}
+		owners = append(owners, storage.ResourceOwner{
+			Address: change.Address,
+			File:    rel,
+			Line:    block.start,
+			Commit:  sha,
+			Author:  newest.AuthorName,
+			Summary: summary,
+			Time:    newest.Date.UTC(),
+		})
+	}
+	return owners
+}
+
+// commitSummary returns the first line of the commit's message.
+func commitSummary(repo *git.Repository, hash plumbing.Hash) string {
+	c, err := repo.CommitObject(hash)
+	if err != nil {
+		return ""
+	}
+	summary, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+	return strings.TrimSpace(summary)
+}
+
+// resourceKey reduces a root module resource address to "type.name",
+// dropping count and for_each keys. It returns "" for data sources and
+// resources inside modules.
+func resourceKey(address string) string {
+	if strings.HasPrefix(address, "module.") || strings.HasPrefix(address, "data.") {
+		return ""
+	}
+	return resourceIndexPattern.ReplaceAllString(address, "")
+}
+
+// findResourceBlocks maps "type.name" to the resource blocks defined in the
+// .tf files of dir.
+func findResourceBlocks(dir string) map[string]resourceDefinition {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tf"))
+	sort.Strings(files)
+	blocks := make(map[string]resourceDefinition)
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+		var lines []string
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		f.Close()
+
+		for i := 0; i < len(lines); i++ {
+			m := resourceBlockPattern.FindStringSubmatch(lines[i])
+			if m == nil {
+				continue
+			}
+			end := blockEnd(lines, i)
+			key := m[1] + "." + m[2]
+			if _, dup := blocks[key]; !dup {
+				blocks[key] = resourceDefinition{file: file, start: i + 1, end: end + 1}
+			}
+			i = end
+		}
+	}
+	return blocks
+}
+
+// blockEnd returns the index of the line closing the block opened on
+// lines[start], skipping braces in strings, comments and heredocs.
+func blockEnd(lines []string, start int) int {
+	depth := 0
+	opened := false
+	heredoc := ""
+	for i := start; i < len(lines); i++ {
+		line := lines[i]
+		if heredoc != "" {
+			if strings.TrimSpace(line) == heredoc {
+				heredoc = ""
+			}
+			continue
+		}
+		inString := false
+	scan:
+		for j := 0; j < len(line); j++ {
+			switch c := line[j]; {
+			case inString && c == '\\':
+				j++
+			case c == '"':
+				inString = !inString
+			case inString:
+			case c == '#' || (c == '/' && j+1 < len(line) && line[j+1] == '/'):
+				break scan
+			case c == '{':
+				depth++
+				opened = true
+			case c == '}':
+				depth--
+			}
+		}
+		if m := heredocPattern.FindStringSubmatch(line); m != nil {
+			heredoc = m[1]
+		}
+		if opened && depth <= 0 {
+			return i
+		}
+	}
+	return len(lines) - 1
+}
@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestBlameResources(t *testing.T) {
+	root := t.TempDir()
+	repo, err := git.PlainInit(root, false)
+	if err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	commit := func(author, message, contents string, when time.Time) {
+		t.Helper()
+		path := filepath.Join(root, "envs", "prod", "main.tf")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		wt, err := repo.Worktree()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := wt.Add("envs/prod/main.tf"); err != nil {
+			t.Fatal(err)
+		}
+		sig := &object.Signature{Name: author, Email: author + "@example.com", When: when}
+		if _, err := wt.Commit(message, &git.CommitOptions{Author: sig, Committer: sig}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base := time.Now().Add(-48 * time.Hour)
+	commit("alice", "Add buckets", `resource "aws_s3_bucket" "logs" {
+  bucket = "logs"
+}
+
+resource "aws_s3_bucket" "data" {
+  for_each = toset(["a", "b"])
+  bucket   = "data-${each.key}" # {not a brace}
+  policy   = <<POLICY
+{ "Statement": [ {
+POLICY
+}
+`, base)
+	commit("bob", "Tighten data bucket policy\n\nDetails.", `resource "aws_s3_bucket" "logs" {
+  bucket = "logs"
+}
+
+resource "aws_s3_bucket" "data" {
+  for_each = toset(["a", "b"])
+  bucket   = "data-${each.key}" # {not a brace}
+  policy   = <<POLICY
+{ "Statement": [ { "Effect": "Deny" } ] }
+POLICY
+}
+`, base.Add(time.Hour))
+
+	owners := blameResources(root, filepath.Join(root, "envs", "prod"), []storage.ResourceChange{
+		{Address: "aws_s3_bucket.logs", Action: "update"},
+		{Address: `aws_s3_bucket.data["a"]`, Action: "update"},
+		{Address: "module.vpc.aws_vpc.main", Action: "update"},
+		{Address: "aws_s3_bucket.missing", Action: "delete"},
+	})
+	if len(owners) != 2 {
+		t.Fatalf("expected 2 owners, got %+v", owners)
+	}
+	if o := owners[0]; o.Author != "alice" || o.Summary != "Add buckets" || o.File != "envs/prod/main.tf" || o.Line != 1 {
+		t.Fatalf("unexpected logs owner: %+v", o)
+	}
+	if o := owners[1]; o.Author != "bob" || o.Summary != "Tighten data bucket policy" || o.Line != 5 || o.Commit == "" {
+		t.Fatalf("unexpected data owner: %+v", o)
+	}
+}
+
+func TestBlameResourcesWithoutRepository(t *testing.T) {
+	dir := t.TempDir()
+	if owners := blameResources(dir, dir, []storage.ResourceChange{{Address: "null_resource.a", Action: "update"}}); owners != nil {
+		t.Fatalf("expected no owners outside a repository, got %+v", owners)
+	}
+}
@@ -0,0 +1,225 @@
+package runner
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Terragrunt resolves a stack's dependency blocks by running "terraform init"
+// and "terraform output -json" in every upstream stack, once per downstream
+// stack. When a scan covers hundreds of stacks that share a few upstreams,
+// most of that work is repeated. The plan-only wrapper keeps the outputs in a
+// per-scan directory so each upstream is initialized once per worker and scan.
+const (
+	dependencyCacheDirEnv    = "DRIFTD_DEPENDENCY_OUTPUT_CACHE"
+	dependencyCacheCommitEnv = "DRIFTD_DEPENDENCY_OUTPUT_COMMIT"
+
+	// initArgsFile records the init arguments in a working directory so
+	// later output calls hash the same backend configuration.
+	initArgsFile = ".driftd-init-args"
+	// deferredInitFile marks a working directory whose init was skipped
+	// because its outputs were cached. Any other command runs it first.
+	deferredInitFile = ".driftd-deferred-init"
+)
+
+// dependencyCacheMaxAge bounds how long per-scan caches stay on disk.
+const dependencyCacheMaxAge = 12 * time.Hour
+
+// dependencyCacheBase is where per-scan dependency output caches live.
+func dependencyCacheBase() string {
+	return filepath.Join(os.TempDir(), "driftd-tg-outputs")
+}
+
+// prepareDependencyCache returns the cache directory for a scan, creating it
+// and removing caches of scans older than dependencyCacheMaxAge. It returns
+// "" when the directory cannot be created; plans then run uncached.
+func prepareDependencyCache(runID string) string {
+	if runID == "" {
+		return ""
+	}
+	base := dependencyCacheBase()
+	dir := filepath.Join(base, safePath(runID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ""
+	}
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return dir
+	}
+	cutoff := time.Now().Add(-dependencyCacheMaxAge)
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == filepath.Base(dir) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil && info.ModTime().Before(cutoff) {
+			_ = os.RemoveAll(filepath.Join(base, entry.Name()))
+		}
+	}
+	return dir
+}
+
+// dependencyOutputCache is the wrapper's view of a scan's cache.
+type dependencyOutputCache struct {
+	dir    string
+	commit string
+}
+
+func dependencyOutputCacheFromEnv() *dependencyOutputCache {
+	dir := os.Getenv(dependencyCacheDirEnv)
+	if dir == "" {
+		return nil
+	}
+	return &dependencyOutputCache{dir: dir, commit: os.Getenv(dependencyCacheCommitEnv)}
+}
+
+// key identifies the state a working directory reads outputs from: the
+// commit, the terraform files (including terragrunt's generated backend and
+// provider files), the init arguments and the workspace. Two downstream stacks
+// depending on the same upstream produce the same key even though terragrunt
+// copies the upstream into separate download directories. It reports false
+// when no init was recorded, since the backend may then be unknown.
+func (c *dependencyOutputCache) key(workDir string) (string, bool) {
+	initArgs, err := os.ReadFile(filepath.Join(workDir, initArgsFile))
+	if err != nil {
+		return "", false
+	}
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		return "", false
+	}
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && (strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".tf.json")) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "commit=%s\nworkspace=%s\ninit=%s\n", c.commit, os.Getenv("TF_WORKSPACE"), initArgs)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(workDir, name))
+		if err != nil {
+			return "", false
+		}
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(h, "%s=%s\n", name, hex.EncodeToString(sum[:]))
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+func (c *dependencyOutputCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+func (c *dependencyOutputCache) get(key string) ([]byte, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (c *dependencyOutputCache) put(key string, data []byte) {
+	if !json.Valid(data) {
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if writeErr != nil || closeErr != nil {
+		_ = os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
+
+// run executes one terraform command through the cache. Init is deferred in
+// directories whose outputs are cached, "output -json" is answered from the
+// cache when possible, and everything else runs any deferred init first.
+func (c *dependencyOutputCache) run(target, subcommand string, args []string, stdout, stderr io.Writer) int {
+	workDir, err := os.Getwd()
+	if err != nil {
+		return runTerraformTarget(target, args, stdout, stderr)
+	}
+
+	switch {
+	case subcommand == "init":
+		raw, _ := json.Marshal(args)
+		_ = os.WriteFile(filepath.Join(workDir, initArgsFile), raw, 0644)
+		if key, ok := c.key(workDir); ok {
+			if _, hit := c.get(key); hit {
+				if err := os.WriteFile(filepath.Join(workDir, deferredInitFile), raw, 0644); err == nil {
+					return 0
+				}
+			}
+		}
+		_ = os.Remove(filepath.Join(workDir, deferredInitFile))
+		return runTerraformTarget(target, args, stdout, stderr)
+
+	case subcommand == "output" && hasArg(args, "-json"):
+		key, ok := c.key(workDir)
+		if ok {
+			if data, hit := c.get(key); hit {
+				_, _ = stdout.Write(data)
+				return 0
+			}
+		}
+		if code := c.runDeferredInit(target, workDir, stderr); code != 0 {
+			return code
+		}
+		var buf bytes.Buffer
+		code := runTerraformTarget(target, args, io.MultiWriter(stdout, &buf), stderr)
+		if code == 0 && ok {
+			c.put(key, buf.Bytes())
+		}
+		return code
+
+	default:
+		if code := c.runDeferredInit(target, workDir, stderr); code != 0 {
+			return code
+		}
+		return runTerraformTarget(target, args, stdout, stderr)
+	}
+}
+
+// runDeferredInit runs an init skipped earlier in workDir. Its output goes to
+// stderr so it never mixes with machine-readable stdout.
+func (c *dependencyOutputCache) runDeferredInit(target, workDir string, stderr io.Writer) int {
+	marker := filepath.Join(workDir, deferredInitFile)
+	raw, err := os.ReadFile(marker)
+	if err != nil {
+		return 0
+	}
+	_ = os.Remove(marker)
+	var args []string
+	if err := json.Unmarshal(raw, &args); err != nil {
+		_, _ = fmt.Fprintf(stderr, "driftd: invalid deferred init: %v\n", err)
+		return 1
+	}
+	return runTerraformTarget(target, args, stderr, stderr)
+}
+
+func hasArg(args []string, want string) bool {
+	for _, arg := range args {
+		if arg == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package runner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDependencyOutputCacheSharesUpstreamOutputs(t *testing.T) {
+	tmp := t.TempDir()
+	logPath := filepath.Join(tmp, "calls.log")
+	target := filepath.Join(tmp, "terraform")
+	script := `#!/bin/sh
+echo "$1" >> "` + logPath + `"
+if [ "$1" = "output" ]; then
+  echo '{"vpc_id":{"value":"vpc-123"}}'
+fi
+exit 0
+`
+	if err := os.WriteFile(target, []byte(script), 0755); err != nil {
+		t.Fatalf("write fake terraform: %v", err)
+	}
+
+	// Terragrunt copies the same upstream into one download dir per
+	// downstream stack; the copies differ in path only.
+	newCopy := func(name, backend string) string {
+		dir := filepath.Join(tmp, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "backend.tf"), []byte(backend), 0644); err != nil {
+			t.Fatalf("write backend: %v", err)
+		}
+		return dir
+	}
+	vpcA := newCopy("a", `terraform { backend "s3" { key = "vpc" } }`)
+	vpcB := newCopy("b", `terraform { backend "s3" { key = "vpc" } }`)
+	db := newCopy("c", `terraform { backend "s3" { key = "db" } }`)
+
+	cache := &dependencyOutputCache{dir: filepath.Join(tmp, "cache"), commit: "abc123"}
+	if err := os.MkdirAll(cache.dir, 0755); err != nil {
+		t.Fatalf("mkdir cache: %v", err)
+	}
+	run := func(dir string, args ...string) string {
+		t.Helper()
+		t.Chdir(dir)
+		var stdout, stderr bytes.Buffer
+		if code := cache.run(target, firstTerraformSubcommand(args), args, &stdout, &stderr); code != 0 {
+			t.Fatalf("%v in %s exited %d: %s", args, dir, code, stderr.String())
+		}
+		return stdout.String()
+	}
+	calls := func() string {
+		data, _ := os.ReadFile(logPath)
+		_ = os.Remove(logPath)
+		return strings.Join(strings.Fields(string(data)), ",")
+	}
+
+	run(vpcA, "init", "-input=false")
+	first := run(vpcA, "output", "-json")
+	if got := calls(); got != "init,output" {
+		t.Fatalf("expected first copy to run terraform, got %q", got)
+	}
+
+	run(vpcB, "init", "-input=false")
+	if second := run(vpcB, "output", "-json"); second != first {
+		t.Fatalf("expected cached outputs %q, got %q", first, second)
+	}
+	if got := calls(); got != "" {
+		t.Fatalf("expected second copy to be served from cache, got %q", got)
+	}
+
+	// Anything other than output runs the deferred init first.
+	run(vpcB, "plan")
+	if got := calls(); got != "init,plan" {
+		t.Fatalf("expected deferred init before plan, got %q", got)
+	}
+
+	run(db, "init", "-input=false")
+	run(db, "output", "-json")
+	if got := calls(); got != "init,output" {
+		t.Fatalf("expected a different backend to miss the cache, got %q", got)
+	}
+
+	other := &dependencyOutputCache{dir: cache.dir, commit: "def456"}
+	if key, ok := other.key(vpcA); !ok {
+		t.Fatal("expected key for initialized dir")
+	} else if _, hit := other.get(key); hit {
+		t.Fatal("expected another commit to miss the cache")
+	}
+}
@@ -0,0 +1,116 @@
+package runner
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+const (
+	providerLockFile        = ".terraform.lock.hcl"
+	defaultProviderRegistry = "registry.terraform.io"
+)
+
+var (
+	lockProviderBlock = regexp.MustCompile(`(?m)^\s*provider\s+"([^"]+)"\s*\{`)
+	lockVersionAttr   = regexp.MustCompile(`(?m)^\s*version\s*=\s*"([^"]+)"`)
+)
+
+// readProviderLockFile returns provider address -> locked version from the
+// stack's committed dependency lock file. ok is false when the stack has no
+// lock file.
+func readProviderLockFile(stackDir string) (locked map[string]string, ok bool, err error) {
+	data, err := os.ReadFile(filepath.Join(stackDir, providerLockFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return parseProviderLockFile(string(data)), true, nil
+}
+
+func parseProviderLockFile(src string) map[string]string {
+	locked := map[string]string{}
+	blocks := lockProviderBlock.FindAllStringSubmatchIndex(src, -1)
+	for i, block := range blocks {
+		end := len(src)
+		if i+1 < len(blocks) {
+			end = blocks[i+1][0]
+		}
+		addr := normalizeProviderAddress(src[block[2]:block[3]])
+		version := ""
+		if m := lockVersionAttr.FindStringSubmatch(src[block[1]:end]); m != nil {
+			version = m[1]
+		}
+		locked[addr] = version
+	}
+	return locked
+}
+
+// installedProviders lists the providers terraform init placed under
+// <TF_DATA_DIR>/providers/<host>/<namespace>/<type>/<version>/<os_arch>.
+func installedProviders(dataDir string) map[string]string {
+	installed := map[string]string{}
+	root := filepath.Join(dataDir, "providers")
+	for _, host := range readDirNames(root) {
+		for _, namespace := range readDirNames(filepath.Join(root, host)) {
+			for _, typ := range readDirNames(filepath.Join(root, host, namespace)) {
+				versions := readDirNames(filepath.Join(root, host, namespace, typ))
+				if len(versions) == 0 {
+					continue
+				}
+				sort.Strings(versions)
+				addr := normalizeProviderAddress(host + "/" + namespace + "/" + typ)
+				installed[addr] = versions[len(versions)-1]
+			}
+		}
+	}
+	return installed
+}
+
+func readDirNames(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Type()&os.ModeSymlink != 0 {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+func normalizeProviderAddress(addr string) string {
+	addr = strings.ToLower(strings.TrimSpace(addr))
+	if strings.Count(addr, "/") == 1 {
+		addr = defaultProviderRegistry + "/" + addr
+	}
+	return addr
+}
+
+// compareProviderLocks reports providers whose installed version differs from
+// the lock file, providers installed without a lock entry, and locked
+// providers that were not installed.
+func compareProviderLocks(locked, installed map[string]string) []storage.ProviderLockMismatch {
+	var out []storage.ProviderLockMismatch
+	for addr, version := range installed {
+		if lockedVersion, ok := locked[addr]; !ok || lockedVersion != version {
+			out = append(out, storage.ProviderLockMismatch{Provider: addr, Locked: locked[addr], Installed: version})
+		}
+	}
+	for addr, version := range locked {
+		if _, ok := installed[addr]; !ok {
+			out = append(out, storage.ProviderLockMismatch{Provider: addr, Locked: version})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Provider < out[j].Provider })
+	return out
+}
@@ -0,0 +1,95 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testLockFile = `# This file is maintained automatically by "terraform init".
+
+provider "registry.terraform.io/hashicorp/aws" {
+  version     = "5.31.0"
+  constraints = "~> 5.0"
+  hashes = [
+    "h1:abc=",
+  ]
+}
+
+provider "registry.terraform.io/hashicorp/random" {
+  version = "3.6.0"
+}
+
+provider "registry.terraform.io/hashicorp/null" {
+  version = "3.2.2"
+}
+`
+
+func TestParseProviderLockFile(t *testing.T) {
+	locked := parseProviderLockFile(testLockFile)
+	if len(locked) != 3 {
+		t.Fatalf("expected 3 providers, got %v", locked)
+	}
+	if locked["registry.terraform.io/hashicorp/aws"] != "5.31.0" || locked["registry.terraform.io/hashicorp/random"] != "3.6.0" {
+		t.Fatalf("unexpected locked versions: %v", locked)
+	}
+}
+
+func TestCompareProviderLocks(t *testing.T) {
+	locked := parseProviderLockFile(testLockFile)
+	installed := map[string]string{
+		"registry.terraform.io/hashicorp/aws":    "5.31.0",
+		"registry.terraform.io/hashicorp/random": "3.6.1",
+		"registry.terraform.io/hashicorp/tls":    "4.0.5",
+	}
+	got := compareProviderLocks(locked, installed)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 mismatches, got %+v", got)
+	}
+	if got[0].Provider != "registry.terraform.io/hashicorp/null" || got[0].Locked != "3.2.2" || got[0].Installed != "" {
+		t.Fatalf("expected missing null provider, got %+v", got[0])
+	}
+	if got[1].Provider != "registry.terraform.io/hashicorp/random" || got[1].Locked != "3.6.0" || got[1].Installed != "3.6.1" {
+		t.Fatalf("expected random version mismatch, got %+v", got[1])
+	}
+	if got[2].Provider != "registry.terraform.io/hashicorp/tls" || got[2].Locked != "" {
+		t.Fatalf("expected unlocked tls provider, got %+v", got[2])
+	}
+
+	if diff := compareProviderLocks(map[string]string{"registry.terraform.io/hashicorp/aws": "5.31.0"}, map[string]string{"registry.terraform.io/hashicorp/aws": "5.31.0"}); len(diff) != 0 {
+		t.Fatalf("expected no mismatches, got %+v", diff)
+	}
+}
+
+func TestRunPlanReportsInstalledProviders(t *testing.T) {
+	tmp := t.TempDir()
+	workDir := filepath.Join(tmp, "work")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	t.Setenv("TF_PLUGIN_CACHE_DIR", filepath.Join(tmp, "cache"))
+
+	// Fake terraform that installs one provider into TF_DATA_DIR on init.
+	tfBin := filepath.Join(tmp, "terraform")
+	script := `#!/bin/sh
+if [ "$1" = "init" ]; then
+  mkdir -p "$TF_DATA_DIR/providers/registry.terraform.io/hashicorp/aws/5.32.0/linux_amd64"
+fi
+exit 0
+`
+	if err := os.WriteFile(tfBin, []byte(script), 0755); err != nil {
+		t.Fatalf("write fake terraform: %v", err)
+	}
+
+	var installed map[string]string
+	_, err := runPlan(context.Background(), workDir, "terraform", tfBin, "", tmp, "envs/dev", "run-1", planOptions{
+		onProviders: func(p map[string]string) { installed = p },
+	})
+	if err != nil {
+		t.Fatalf("run plan: %v", err)
+	}
+	if installed["registry.terraform.io/hashicorp/aws"] != "5.32.0" {
+		t.Fatalf("expected installed aws provider, got %v", installed)
+	}
+}
@@ -0,0 +1,270 @@
+package runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+// NoiseHeuristics select which plan differences count as semantic no-ops.
+// A drifted terraform/terragrunt result whose every change is such a no-op
+// is saved as noisy-clean instead of drifted.
+type NoiseHeuristics struct {
+	// Whitespace ignores values and lines that differ only in whitespace.
+	Whitespace bool
+	// JSON ignores string changes between JSON documents that decode to the
+	// same value, such as re-marshaled IAM policies.
+	JSON bool
+	// Ordering ignores list elements and lines that were removed and added
+	// back in a different position.
+	Ordering bool
+}
+
+func (h NoiseHeuristics) enabled() bool {
+	return h.Whitespace || h.JSON || h.Ordering
+}
+
+// applyNoiseReduction clears Drifted on results whose plan only contains
+// no-op updates. Counts, resource changes and the plan text are kept so the
+// raw diff stays reviewable.
+func applyNoiseReduction(result *storage.RunResult, h NoiseHeuristics) {
+	if !result.Drifted || result.Error != "" || !isNoiseOnly(result, h) {
+		return
+	}
+	result.Drifted = false
+	result.NoisyClean = true
+}
+
+// isNoiseOnly reports whether every planned change in the result is an
+// in-place update the heuristics consider a no-op. Anything it cannot
+// account for, including output changes, counts as real drift.
+func isNoiseOnly(result *storage.RunResult, h NoiseHeuristics) bool {
+	if !h.enabled() || result.Added > 0 || result.Destroyed > 0 || result.Changed == 0 {
+		return false
+	}
+	clean := ansiEscapePattern.ReplaceAllString(result.PlanOutput, "")
+	if strings.Contains(clean, "Changes to Outputs:") {
+		return false
+	}
+	updates := 0
+	for _, block := range splitResourceBlocks(clean) {
+		switch block.action {
+		case "read":
+			continue
+		case "update":
+			if !isNoiseBlock(block.lines, h) {
+				return false
+			}
+			updates++
+		default:
+			return false
+		}
+	}
+	return updates == result.Changed
+}
+
+type resourceBlock struct {
+	action string
+	lines  []string
+}
+
+// splitResourceBlocks returns the body lines under each resource header. A
+// body ends at the next header, a comment line other than terraform's
+// "# (...)" notes, or unindented text such as the "Plan:" line.
+func splitResourceBlocks(output string) []resourceBlock {
+	var blocks []resourceBlock
+	var cur *resourceBlock
+	for _, line := range strings.Split(output, "\n") {
+		if rc, ok := ParseResourceHeader(line); ok {
+			blocks = append(blocks, resourceBlock{action: rc.Action})
+			cur = &blocks[len(blocks)-1]
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		if (strings.HasPrefix(trimmed, "# ") && !strings.HasPrefix(trimmed, "# (")) ||
+			(line[0] != ' ' && line[0] != '\t' && !strings.HasPrefix(trimmed, "-/+") && !strings.HasPrefix(trimmed, "+/-")) {
+			cur = nil
+			continue
+		}
+		cur.lines = append(cur.lines, line)
+	}
+	return blocks
+}
+
+// isNoiseBlock checks one update body. "~ name = old -> new" lines must be
+// no-op string changes; "-" and "+" lines must cancel out once normalized.
+// Unmarked lines are unchanged context and "~" lines opening a nested
+// block only frame the changes inside them.
+func isNoiseBlock(lines []string, h NoiseHeuristics) bool {
+	var removed, added []string
+	changes := 0
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "-/+"), strings.HasPrefix(trimmed, "+/-"):
+			return false
+		case strings.HasPrefix(trimmed, "~ "):
+			if strings.HasPrefix(trimmed, "~ resource ") || strings.HasPrefix(trimmed, "~ data ") {
+				continue
+			}
+			rest := strings.TrimPrefix(trimmed, "~ ")
+			if opensNestedBlock(rest) {
+				continue
+			}
+			if !isNoiseValueChange(rest, h) {
+				return false
+			}
+			changes++
+		case strings.HasPrefix(trimmed, "- "), trimmed == "-":
+			removed = append(removed, diffLineContent(line, "-"))
+		case strings.HasPrefix(trimmed, "+ "), trimmed == "+":
+			added = append(added, diffLineContent(line, "+"))
+		}
+	}
+	if len(removed) != len(added) || !linesCancel(removed, added, h) {
+		return false
+	}
+	return changes+len(removed) > 0
+}
+
+// linesCancel reports whether the removed lines come back as the added ones.
+// With Ordering they may come back anywhere. Without it each pair must line
+// up and differ only in whitespace: a line removed and added back unchanged
+// is a moved element.
+func linesCancel(removed, added []string, h NoiseHeuristics) bool {
+	if h.Ordering {
+		removed = normalizeLines(removed, h.Whitespace)
+		added = normalizeLines(added, h.Whitespace)
+		sort.Strings(removed)
+		sort.Strings(added)
+		return reflect.DeepEqual(removed, added)
+	}
+	if !h.Whitespace {
+		return len(removed) == 0
+	}
+	for i := range removed {
+		if removed[i] == added[i] || collapseWhitespace(removed[i]) != collapseWhitespace(added[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func normalizeLines(lines []string, whitespace bool) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = line
+		if whitespace {
+			out[i] = collapseWhitespace(line)
+		}
+	}
+	return out
+}
+
+func collapseWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// opensNestedBlock reports whether a "~" line only opens a nested value,
+// e.g. `tags = {`, `policy = jsonencode(` or `content = <<-EOT`.
+func opensNestedBlock(rest string) bool {
+	if strings.HasSuffix(rest, "{") || strings.HasSuffix(rest, "[") || strings.HasSuffix(rest, "(") {
+		return !strings.Contains(rest, " -> ")
+	}
+	if i := strings.Index(rest, "<<"); i >= 0 {
+		marker := strings.TrimLeft(rest[i+2:], "-~")
+		return marker != "" && !strings.ContainsAny(marker, " \"")
+	}
+	return false
+}
+
+// isNoiseValueChange checks `name = "old" -> "new"`. Only quoted strings
+// qualify; unknown, sensitive and non-string values are real changes.
+func isNoiseValueChange(rest string, h NoiseHeuristics) bool {
+	_, value, ok := strings.Cut(rest, " = ")
+	if !ok {
+		return false
+	}
+	value = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "# forces replacement"))
+	oldQuoted, err := strconv.QuotedPrefix(value)
+	if err != nil {
+		return false
+	}
+	newQuoted, ok := strings.CutPrefix(value[len(oldQuoted):], " -> ")
+	if !ok {
+		return false
+	}
+	oldValue, err1 := strconv.Unquote(oldQuoted)
+	newValue, err2 := strconv.Unquote(newQuoted)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	if h.Whitespace && collapseWhitespace(oldValue) == collapseWhitespace(newValue) {
+		return true
+	}
+	return h.JSON && jsonEquivalent(oldValue, newValue, h.Ordering)
+}
+
+// diffLineContent strips the marker and trailing whitespace. Trailing commas
+// are dropped so a moved last list element still matches its copy.
+func diffLineContent(line, marker string) string {
+	content := strings.TrimPrefix(strings.TrimLeft(line, " \t"), marker)
+	return strings.TrimSuffix(strings.TrimRight(content, " \t"), ",")
+}
+
+// jsonEquivalent reports whether a and b are JSON documents with the same
+// value. Object key order never matters; with ignoreOrder arrays are compared
+// as multisets.
+func jsonEquivalent(a, b string, ignoreOrder bool) bool {
+	var va, vb any
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	if ignoreOrder {
+		va, vb = sortJSONArrays(va), sortJSONArrays(vb)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func sortJSONArrays(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, item := range t {
+			t[k] = sortJSONArrays(item)
+		}
+		return t
+	case []any:
+		keys := make([][]byte, len(t))
+		for i, item := range t {
+			t[i] = sortJSONArrays(item)
+			keys[i], _ = json.Marshal(t[i])
+		}
+		sort.Sort(jsonArraySorter{items: t, keys: keys})
+		return t
+	default:
+		return v
+	}
+}
+
+type jsonArraySorter struct {
+	items []any
+	keys  [][]byte
+}
+
+func (s jsonArraySorter) Len() int           { return len(s.items) }
+func (s jsonArraySorter) Less(i, j int) bool { return bytes.Compare(s.keys[i], s.keys[j]) < 0 }
+func (s jsonArraySorter) Swap(i, j int) {
+	s.items[i], s.items[j] = s.items[j], s.items[i]
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+}
@@ -0,0 +1,168 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+const policyReorderPlan = `Terraform will perform the following actions:
+
+  # aws_iam_policy.deploy will be updated in-place
+  ~ resource "aws_iam_policy" "deploy" {
+        id     = "arn:aws:iam::123:policy/deploy"
+      ~ policy = jsonencode(
+          ~ {
+              ~ Statement = [
+                  ~ {
+                      ~ Action   = [
+                          - "s3:GetObject",
+                            "s3:PutObject",
+                          + "s3:GetObject",
+                        ]
+                        # (2 unchanged attributes hidden)
+                    },
+                ]
+                # (1 unchanged attribute hidden)
+            }
+        )
+        # (3 unchanged attributes hidden)
+    }
+
+  # aws_iam_role.app will be updated in-place
+  ~ resource "aws_iam_role" "app" {
+      ~ assume_role_policy = "{\"Version\":\"2012-10-17\",\"Statement\":[]}" -> "{\"Statement\":[],\"Version\":\"2012-10-17\"}"
+      ~ description        = "app  role" -> "app role"
+        name               = "app"
+    }
+
+Plan: 0 to add, 2 to change, 0 to destroy.
+`
+
+func noiseResult(plan string, changed int) *storage.RunResult {
+	return &storage.RunResult{Drifted: true, Changed: changed, PlanOutput: plan}
+}
+
+func TestApplyNoiseReduction(t *testing.T) {
+	all := NoiseHeuristics{Whitespace: true, JSON: true, Ordering: true}
+
+	result := noiseResult("\x1b[1m"+policyReorderPlan, 2)
+	applyNoiseReduction(result, all)
+	if result.Drifted || !result.NoisyClean {
+		t.Fatalf("expected noisy-clean result, got %+v", result)
+	}
+	if result.PlanOutput == "" || result.Changed != 2 {
+		t.Fatalf("expected counts and plan to be kept, got %+v", result)
+	}
+
+	for name, h := range map[string]NoiseHeuristics{
+		"disabled":      {},
+		"no ordering":   {Whitespace: true, JSON: true},
+		"no json":       {Whitespace: true, Ordering: true},
+		"no whitespace": {JSON: true, Ordering: true},
+	} {
+		result := noiseResult(policyReorderPlan, 2)
+		applyNoiseReduction(result, h)
+		if !result.Drifted || result.NoisyClean {
+			t.Fatalf("%s: expected drift to be kept, got %+v", name, result)
+		}
+	}
+}
+
+func TestApplyNoiseReductionHeredocWhitespace(t *testing.T) {
+	plan := `  # aws_ssm_document.doc will be updated in-place
+  ~ resource "aws_ssm_document" "doc" {
+      ~ content = <<-EOT
+          - schemaVersion:  "2.2"
+          + schemaVersion: "2.2"
+            mainSteps: []
+        EOT
+    }
+
+Plan: 0 to add, 1 to change, 0 to destroy.
+`
+	result := noiseResult(plan, 1)
+	applyNoiseReduction(result, NoiseHeuristics{Whitespace: true})
+	if result.Drifted || !result.NoisyClean {
+		t.Fatalf("expected whitespace-only heredoc change to be noisy-clean, got %+v", result)
+	}
+
+	result = noiseResult(plan, 1)
+	applyNoiseReduction(result, NoiseHeuristics{Ordering: true})
+	if !result.Drifted {
+		t.Fatalf("expected whitespace change to need the whitespace heuristic")
+	}
+}
+
+func TestApplyNoiseReductionKeepsRealChanges(t *testing.T) {
+	all := NoiseHeuristics{Whitespace: true, JSON: true, Ordering: true}
+	cases := map[string]*storage.RunResult{
+		"value change": noiseResult(`  # aws_instance.web will be updated in-place
+  ~ resource "aws_instance" "web" {
+      ~ instance_type = "t2.micro" -> "t2.small"
+    }
+
+Plan: 0 to add, 1 to change, 0 to destroy.
+`, 1),
+		"list element added": noiseResult(`  # aws_security_group.sg will be updated in-place
+  ~ resource "aws_security_group" "sg" {
+      ~ cidr_blocks = [
+            "10.0.0.0/8",
+          + "192.168.0.0/16",
+        ]
+    }
+
+Plan: 0 to add, 1 to change, 0 to destroy.
+`, 1),
+		"sensitive value": noiseResult(`  # aws_db_instance.db will be updated in-place
+  ~ resource "aws_db_instance" "db" {
+      ~ password = (sensitive value)
+    }
+
+Plan: 0 to add, 1 to change, 0 to destroy.
+`, 1),
+		"unknown value": noiseResult(`  # aws_instance.web will be updated in-place
+  ~ resource "aws_instance" "web" {
+      ~ user_data = "a" -> (known after apply)
+    }
+
+Plan: 0 to add, 1 to change, 0 to destroy.
+`, 1),
+		"output change": noiseResult(policyReorderPlan+`
+Changes to Outputs:
+  ~ policy = "a" -> "b"
+`, 2),
+		"unparsed update": noiseResult(policyReorderPlan, 3),
+	}
+	replace := noiseResult(`  # aws_instance.web must be replaced
+-/+ resource "aws_instance" "web" {
+      ~ ami = "ami-1" -> "ami-1 " # forces replacement
+    }
+
+Plan: 1 to add, 0 to change, 1 to destroy.
+`, 0)
+	replace.Added, replace.Destroyed = 1, 1
+	cases["replace"] = replace
+
+	for name, result := range cases {
+		applyNoiseReduction(result, all)
+		if !result.Drifted || result.NoisyClean {
+			t.Fatalf("%s: expected drift to be kept, got %+v", name, result)
+		}
+	}
+}
+
+func TestJSONEquivalent(t *testing.T) {
+	if !jsonEquivalent(`{"a":[1,2],"b":{"c":true}}`, `{ "b": {"c": true}, "a": [1, 2] }`, false) {
+		t.Fatalf("expected key order and formatting to be ignored")
+	}
+	if jsonEquivalent(`{"a":[1,2]}`, `{"a":[2,1]}`, false) {
+		t.Fatalf("expected array order to matter without ordering")
+	}
+	if !jsonEquivalent(`{"a":[1,{"x":2}]}`, `{"a":[{"x":2},1]}`, true) {
+		t.Fatalf("expected array order to be ignored with ordering")
+	}
+	if jsonEquivalent(`{"a":1}`, `not json`, true) {
+		t.Fatalf("expected invalid JSON to never match")
+	}
+}
@@ -0,0 +1,88 @@
+package runner
+
+import (
+	"bufio"
+	"sort"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/stack"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+const outputChangesHeader = "Changes to Outputs:"
+
+var outputActions = map[byte]string{'+': "create", '~': "update", '-': "delete"}
+
+// parseOutputChanges reads the "Changes to Outputs:" section terraform
+// prints after the resource changes, the text form of the JSON plan's
+// output_changes. Each output is one line at the section's base indent;
+// deeper lines are its multi-line value. output must be free of ANSI
+// escapes.
+func parseOutputChanges(output string) []storage.OutputChange {
+	var out []storage.OutputChange
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	inSection := false
+	baseIndent := -1
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if !inSection {
+			if trimmed == outputChangesHeader {
+				inSection, baseIndent = true, -1
+			}
+			continue
+		}
+		if trimmed == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent == 0 {
+			// Unindented text such as "Plan:" or a note ends the section;
+			// terragrunt runs can print a second plan further down.
+			inSection = trimmed == outputChangesHeader
+			baseIndent = -1
+			continue
+		}
+		if baseIndent < 0 {
+			baseIndent = indent
+		}
+		if indent != baseIndent || len(trimmed) < 3 {
+			continue
+		}
+		action, ok := outputActions[trimmed[0]]
+		if !ok {
+			continue
+		}
+		name := strings.TrimSpace(trimmed[1:])
+		if end := strings.IndexAny(name, " ="); end >= 0 {
+			name = name[:end]
+		}
+		if name != "" {
+			out = append(out, storage.OutputChange{Name: name, Action: action})
+		}
+	}
+	return out
+}
+
+// applyOutputConsumers records which stacks of the project read each
+// changed output. Stacks that take all of the stack's outputs consume every
+// change.
+func applyOutputConsumers(changes []storage.OutputChange, consumers map[string][]string) {
+	if len(consumers) == 0 {
+		return
+	}
+	all := consumers[stack.AllOutputs]
+	for i := range changes {
+		seen := map[string]bool{}
+		var list []string
+		for _, c := range append(append([]string{}, consumers[changes[i].Name]...), all...) {
+			if !seen[c] {
+				seen[c] = true
+				list = append(list, c)
+			}
+		}
+		sort.Strings(list)
+		changes[i].Consumers = list
+	}
+}
@@ -0,0 +1,61 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestParseOutputChanges(t *testing.T) {
+	plan := `  # aws_vpc.main will be updated in-place
+  ~ resource "aws_vpc" "main" {
+      ~ cidr_block = "10.0.0.0/16" -> "10.1.0.0/16"
+    }
+
+Plan: 0 to add, 1 to change, 0 to destroy.
+
+Changes to Outputs:
+  ~ cidr       = "10.0.0.0/16" -> "10.1.0.0/16"
+  + endpoints  = {
+      + api = "https://api"
+    }
+  - legacy_id  = "vpc-1" -> null
+  ~ subnet_ids = [
+      - "subnet-1",
+      + "subnet-2",
+    ]
+
+─────────────────────────────────────────────────────────────────────────────
+
+Note: You didn't use the -out option to save this plan.
+`
+	got := parseOutputChanges(plan)
+	want := []storage.OutputChange{
+		{Name: "cidr", Action: "update"},
+		{Name: "endpoints", Action: "create"},
+		{Name: "legacy_id", Action: "delete"},
+		{Name: "subnet_ids", Action: "update"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("output changes = %+v, want %+v", got, want)
+	}
+
+	if got := parseOutputChanges("No changes. Your infrastructure matches the configuration.\n"); got != nil {
+		t.Fatalf("expected no output changes, got %+v", got)
+	}
+}
+
+func TestApplyOutputConsumers(t *testing.T) {
+	changes := []storage.OutputChange{{Name: "vpc_id", Action: "update"}, {Name: "cidr", Action: "update"}}
+	applyOutputConsumers(changes, map[string][]string{
+		"vpc_id": {"envs/app", "envs/db"},
+		"*":      {"envs/db", "envs/cache"},
+	})
+	if want := []string{"envs/app", "envs/cache", "envs/db"}; !reflect.DeepEqual(changes[0].Consumers, want) {
+		t.Fatalf("vpc_id consumers = %v, want %v", changes[0].Consumers, want)
+	}
+	if want := []string{"envs/cache", "envs/db"}; !reflect.DeepEqual(changes[1].Consumers, want) {
+		t.Fatalf("cidr consumers = %v, want %v", changes[1].Consumers, want)
+	}
+}
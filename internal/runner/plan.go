@@ -7,12 +7,44 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
-	"strconv"
+	"sort"
 	"strings"
 )
 
-func planStack(ctx context.Context, workDir, projectRoot, stackPath, tfVersion, tgVersion, runID string) (string, error) {
+// planOptions carries per-project knobs that change how a stack is planned.
+type planOptions struct {
+	// fetchDependencyOutputFromState makes terragrunt read dependency outputs
+	// straight from remote state rather than invoking terraform output.
+	fetchDependencyOutputFromState bool
+	// dependencyCacheDir, when set, lets the plan-only wrapper share
+	// dependency outputs between the stacks of one scan. dependencyCommit
+	// is part of every cache key.
+	dependencyCacheDir string
+	dependencyCommit   string
+	// onProviders receives the providers installed by terraform init for
+	// each attempt, before the attempt's data dir is removed.
+	onProviders func(installed map[string]string)
+	// initArgs and planArgs are appended to the terraform init and plan
+	// commands. Terragrunt stacks only get planArgs.
+	initArgs []string
+	planArgs []string
+	// onEnv receives the environment of each terraform or terragrunt
+	// command before it runs, so the last call is the command that failed.
+	onEnv func(env []string)
+	// warm, when set, replaces the per-attempt data directory and provider
+	// cache of terraform stacks. Init is skipped when warmFingerprint
+	// matches the one warm was initialized with.
+	warm            *WarmWorkspace
+	warmFingerprint string
+}
+
+func (o planOptions) reportEnv(env []string) {
+	if o.onEnv != nil {
+		o.onEnv(env)
+	}
+}
+
+func planStack(ctx context.Context, workDir, projectRoot, stackPath, tfVersion, tgVersion, runID string, opts planOptions) (string, error) {
 	tool := detectTool(workDir)
 
 	tfBin, err := ensureTerraformBinary(ctx, workDir, tfVersion)
@@ -32,7 +64,7 @@ func planStack(ctx context.Context, workDir, projectRoot, stackPath, tfVersion,
 		}
 	}
 
-	return runPlan(ctx, workDir, tool, tfBin, tgBin, projectRoot, stackPath, runID)
+	return runPlan(ctx, workDir, tool, tfBin, tgBin, projectRoot, stackPath, runID, opts)
 }
 
 func detectTool(stackDir string) string {
@@ -40,32 +72,28 @@ func detectTool(stackDir string) string {
 	if _, err := os.Stat(tgPath); err == nil {
 		return "terragrunt"
 	}
+	if hasPulumiProject(stackDir) {
+		return pulumiTool
+	}
 	return "terraform"
 }
 
-func runPlan(ctx context.Context, workDir, tool, tfBin, tgBin, projectRoot, stackPath, runID string) (string, error) {
+func runPlan(ctx context.Context, workDir, tool, tfBin, tgBin, projectRoot, stackPath, runID string, opts planOptions) (string, error) {
 	dataKey := runID
 	if dataKey == "" {
 		dataKey = filepath.Base(projectRoot)
 	}
-	pluginCacheBase := os.Getenv("TF_PLUGIN_CACHE_DIR")
-	if pluginCacheBase == "" {
-		pluginCacheBase = "/cache/terraform/plugins"
-	}
-	// If the base cache dir can't be created, fall back to per-run cache.
-	if err := os.MkdirAll(pluginCacheBase, 0755); err != nil {
-		pluginCacheBase = ""
-	}
+	pluginCacheBase := pluginCacheBaseDir()
 
 	// Provider download / install can occasionally fail with a checksum mismatch under concurrency
 	// when using a shared TF_PLUGIN_CACHE_DIR. Retry once with an isolated cache to self-heal.
-	out, err := runPlanOnce(ctx, workDir, tool, tfBin, tgBin, stackPath, dataKey, pluginCacheBase, false)
+	out, err := runPlanOnce(ctx, workDir, tool, tfBin, tgBin, stackPath, dataKey, pluginCacheBase, false, opts)
 	if err == nil || !shouldRetryWithIsolatedCache(out) {
 		return cleanTerragruntOutput(tool, out), err
 	}
 
 	// Retry with a per-run cache (and a fresh TF_DATA_DIR / TG_DOWNLOAD_DIR).
-	out2, err2 := runPlanOnce(ctx, workDir, tool, tfBin, tgBin, stackPath, dataKey, "", true)
+	out2, err2 := runPlanOnce(ctx, workDir, tool, tfBin, tgBin, stackPath, dataKey, "", true, opts)
 	// Prefer retry output; it usually includes the original error plus the new attempt.
 	if out2 != "" {
 		out = out + "\n\n--- retry (fresh plugin cache) ---\n\n" + out2
@@ -73,6 +101,20 @@ func runPlan(ctx context.Context, workDir, tool, tfBin, tgBin, projectRoot, stac
 	return cleanTerragruntOutput(tool, out), err2
 }
 
+// pluginCacheBaseDir returns the base directory of the per-stack plugin
+// caches and the shared provider store, or "" when it cannot be created and
+// each run falls back to its own cache.
+func pluginCacheBaseDir() string {
+	base := os.Getenv("TF_PLUGIN_CACHE_DIR")
+	if base == "" {
+		base = "/cache/terraform/plugins"
+	}
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return ""
+	}
+	return base
+}
+
 func cleanTerragruntOutput(tool, output string) string {
 	if tool != "terragrunt" {
 		return output
@@ -124,27 +166,60 @@ func runPlanOnce(
 	ctx context.Context,
 	workDir, tool, tfBin, tgBin, stackPath, dataKey, pluginCacheBase string,
 	isRetry bool,
+	opts planOptions,
 ) (string, error) {
 	var output bytes.Buffer
 
-	// Unique TF_DATA_DIR per attempt prevents cross-attempt contamination and avoids collisions.
-	base := filepath.Join(os.TempDir(), "driftd-tfdata", safePath(stackPath), safePath(dataKey))
-	if err := os.MkdirAll(base, 0755); err != nil {
-		return "", fmt.Errorf("create TF_DATA_DIR base: %w", err)
+	// The retry after a provider install failure always starts cold.
+	warm := opts.warm
+	if tool != "terraform" || isRetry {
+		warm = nil
 	}
-	dataDir, err := os.MkdirTemp(base, "run-*")
-	if err != nil {
-		return "", fmt.Errorf("create TF_DATA_DIR: %w", err)
+
+	var dataDir string
+	if warm != nil {
+		dataDir = warm.dataDir()
+		if err := os.MkdirAll(dataDir, 0755); err != nil {
+			return "", fmt.Errorf("create TF_DATA_DIR: %w", err)
+		}
+	} else {
+		// Unique TF_DATA_DIR per attempt prevents cross-attempt contamination and avoids collisions.
+		base := filepath.Join(os.TempDir(), "driftd-tfdata", safePath(stackPath), safePath(dataKey))
+		if err := os.MkdirAll(base, 0755); err != nil {
+			return "", fmt.Errorf("create TF_DATA_DIR base: %w", err)
+		}
+		var err error
+		dataDir, err = os.MkdirTemp(base, "run-*")
+		if err != nil {
+			return "", fmt.Errorf("create TF_DATA_DIR: %w", err)
+		}
+		defer os.RemoveAll(dataDir)
 	}
-	defer os.RemoveAll(dataDir)
 
 	// Default to a per-stack plugin cache under the configured base directory.
 	// This avoids concurrent writers fighting over the same cached provider paths.
+	// Providers already downloaded by other stacks are seeded from the
+	// shared store, and new ones are published to it after a good plan.
 	pluginCacheDir := ""
-	if pluginCacheBase != "" {
+	sharedDir := ""
+	if warm != nil {
+		// Providers in the warm data directory link into its own cache, so
+		// the cache lives as long as the directory.
+		pluginCacheDir = warm.pluginDir()
+		if err := os.MkdirAll(pluginCacheDir, 0755); err != nil {
+			pluginCacheDir = ""
+		} else if pluginCacheBase != "" {
+			sharedDir = filepath.Join(pluginCacheBase, sharedProviderDir)
+			seedPluginCache(sharedDir, pluginCacheDir)
+		}
+	} else if pluginCacheBase != "" {
 		pluginCacheDir = filepath.Join(pluginCacheBase, safePath(dataKey), safePath(stackPath))
 		if err := os.MkdirAll(pluginCacheDir, 0755); err != nil {
 			pluginCacheDir = ""
+		} else {
+			defer os.RemoveAll(pluginCacheDir)
+			sharedDir = filepath.Join(pluginCacheBase, sharedProviderDir)
+			seedPluginCache(sharedDir, pluginCacheDir)
 		}
 	}
 	if pluginCacheDir == "" {
@@ -164,13 +239,14 @@ func runPlanOnce(
 		}
 	}
 
-	if tool == "terraform" {
+	initTerraform := func() error {
 		args := []string{"init", "-input=false"}
 		if isRetry {
 			// Attempt to refresh provider packages if the first attempt hit a mismatch.
 			args = append(args, "-upgrade")
 		}
-		initCmd := exec.CommandContext(ctx, tfBin, args...)
+		args = append(args, opts.initArgs...)
+		initCmd := prepareCancel(exec.CommandContext(ctx, tfBin, args...))
 		initCmd.Dir = workDir
 		initCmd.Env = append(filteredEnv(),
 			fmt.Sprintf("TF_DATA_DIR=%s", dataDir),
@@ -178,14 +254,53 @@ func runPlanOnce(
 		)
 		initCmd.Stdout = &output
 		initCmd.Stderr = &output
+		opts.reportEnv(initCmd.Env)
+		if warm != nil {
+			warm.Fingerprint = ""
+		}
 		if err := initCmd.Run(); err != nil {
-			return output.String(), fmt.Errorf("terraform init failed: %w", err)
+			return fmt.Errorf("terraform init failed: %w", err)
+		}
+		if warm != nil {
+			warm.Fingerprint = opts.warmFingerprint
+			warm.saveLockFile(workDir)
+		}
+		return nil
+	}
+	if warm != nil {
+		warm.Hit = warm.Fingerprint != "" && warm.Fingerprint == opts.warmFingerprint && warm.restoreLockFile(workDir)
+	}
+	if tool == "terraform" && (warm == nil || !warm.Hit) {
+		if err := initTerraform(); err != nil {
+			return output.String(), err
 		}
 	}
 
+	err := runPlanCommand(ctx, workDir, tool, tfBin, tgBin, dataDir, pluginCacheDir, tgDownloadDir, &output, opts)
+	if warm != nil && warm.Hit && !planCompleted(err) && ctx.Err() == nil {
+		// The warm init no longer fits the stack, e.g. a local module now
+		// calls another module. Initialize and plan again.
+		warm.Hit = false
+		output.Reset()
+		if err := initTerraform(); err != nil {
+			return output.String(), err
+		}
+		err = runPlanCommand(ctx, workDir, tool, tfBin, tgBin, dataDir, pluginCacheDir, tgDownloadDir, &output, opts)
+	}
+	if opts.onProviders != nil {
+		opts.onProviders(installedProviders(dataDir))
+	}
+	if sharedDir != "" && planCompleted(err) {
+		publishPluginCache(pluginCacheDir, sharedDir)
+	}
+	return output.String(), err
+}
+
+// runPlanCommand runs terraform or terragrunt plan in workDir.
+func runPlanCommand(ctx context.Context, workDir, tool, tfBin, tgBin, dataDir, pluginCacheDir, tgDownloadDir string, output *bytes.Buffer, opts planOptions) error {
 	var planCmd *exec.Cmd
 	if tool == "terragrunt" {
-		planCmd = exec.CommandContext(ctx, tgBin, "plan", "-detailed-exitcode", "-input=false")
+		planCmd = exec.CommandContext(ctx, tgBin, append([]string{"plan", "-detailed-exitcode", "-input=false"}, opts.planArgs...)...)
 		planCmd.Env = append(filteredEnv(),
 			fmt.Sprintf("TG_TF_PATH=%s", tfBin),
 			fmt.Sprintf("TG_DOWNLOAD_DIR=%s", tgDownloadDir),
@@ -194,36 +309,31 @@ func runPlanOnce(
 			fmt.Sprintf("TF_DATA_DIR=%s", dataDir),
 			fmt.Sprintf("TF_PLUGIN_CACHE_DIR=%s", pluginCacheDir),
 		)
+		if opts.fetchDependencyOutputFromState {
+			planCmd.Env = append(planCmd.Env,
+				"TG_DEPENDENCY_FETCH_OUTPUT_FROM_STATE=true",
+				"TERRAGRUNT_FETCH_DEPENDENCY_OUTPUT_FROM_STATE=true",
+			)
+		}
+		if opts.dependencyCacheDir != "" {
+			planCmd.Env = append(planCmd.Env,
+				fmt.Sprintf("%s=%s", dependencyCacheDirEnv, opts.dependencyCacheDir),
+				fmt.Sprintf("%s=%s", dependencyCacheCommitEnv, opts.dependencyCommit),
+			)
+		}
 	} else {
-		planCmd = exec.CommandContext(ctx, tfBin, "plan", "-detailed-exitcode", "-input=false")
+		planCmd = exec.CommandContext(ctx, tfBin, append([]string{"plan", "-detailed-exitcode", "-input=false"}, opts.planArgs...)...)
 		planCmd.Env = append(filteredEnv(),
 			fmt.Sprintf("TF_DATA_DIR=%s", dataDir),
 			fmt.Sprintf("TF_PLUGIN_CACHE_DIR=%s", pluginCacheDir),
 		)
 	}
+	prepareCancel(planCmd)
 	planCmd.Dir = workDir
-	planCmd.Stdout = &output
-	planCmd.Stderr = &output
-
-	err = planCmd.Run()
-	return output.String(), err
-}
-
-var planSummaryRegex = regexp.MustCompile(`Plan: (\d+) to add, (\d+) to change, (\d+) to destroy`)
-
-func parsePlanSummary(output string) (added, changed, destroyed int) {
-	matches := planSummaryRegex.FindStringSubmatch(output)
-	if len(matches) == 4 {
-		added, _ = strconv.Atoi(matches[1])
-		changed, _ = strconv.Atoi(matches[2])
-		destroyed, _ = strconv.Atoi(matches[3])
-	}
-
-	if strings.Contains(output, "No changes.") || strings.Contains(output, "no differences") {
-		return 0, 0, 0
-	}
-
-	return added, changed, destroyed
+	planCmd.Stdout = output
+	planCmd.Stderr = output
+	opts.reportEnv(planCmd.Env)
+	return planCmd.Run()
 }
 
 func filteredEnv() []string {
@@ -252,6 +362,8 @@ func filteredEnv() []string {
 	allowedPrefixes := []string{
 		"TF_",
 		"TERRAGRUNT_",
+		// Pulumi backend login, access token and secrets passphrase.
+		"PULUMI_",
 		// Common cloud/provider credentials.
 		"AWS_",
 		"GOOGLE_",
@@ -289,6 +401,21 @@ func filteredEnv() []string {
 	return out
 }
 
+// envNames returns the sorted, de-duplicated names of env entries.
+func envNames(env []string) []string {
+	seen := make(map[string]bool, len(env))
+	names := make([]string, 0, len(env))
+	for _, entry := range env {
+		name, _, _ := strings.Cut(entry, "=")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 func safePath(path string) string {
 	return strings.ReplaceAll(path, string(os.PathSeparator), "__")
 }
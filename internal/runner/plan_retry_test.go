@@ -65,7 +65,7 @@ exit 0
 
 	t.Setenv("TF_PLUGIN_CACHE_DIR", sharedCache)
 
-	out, err := runPlan(context.Background(), workDir, "terraform", tfBin, "", projectRoot, "envs/dev/app", "run-1")
+	out, err := runPlan(context.Background(), workDir, "terraform", tfBin, "", projectRoot, "envs/dev/app", "run-1", planOptions{})
 	if err != nil {
 		t.Fatalf("runPlan error: %v\noutput:\n%s", err, out)
 	}
@@ -117,3 +117,51 @@ exit 0
 		t.Fatalf("expected different TF_DATA_DIR per attempt, got same %q\nlog:\n%s", dataDirs[0], log)
 	}
 }
+
+func TestRunPlan_TerragruntFetchDependencyOutputFromState(t *testing.T) {
+	tmp := t.TempDir()
+	workDir := filepath.Join(tmp, "work")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatalf("mkdir workDir: %v", err)
+	}
+	logPath := filepath.Join(tmp, "tg.log")
+	tgBin := filepath.Join(tmp, "terragrunt")
+
+	script := `#!/bin/sh
+echo "TG_DEPENDENCY_FETCH_OUTPUT_FROM_STATE=${TG_DEPENDENCY_FETCH_OUTPUT_FROM_STATE:-}" >> "` + logPath + `"
+echo "TERRAGRUNT_FETCH_DEPENDENCY_OUTPUT_FROM_STATE=${TERRAGRUNT_FETCH_DEPENDENCY_OUTPUT_FROM_STATE:-}" >> "` + logPath + `"
+echo "No changes."
+exit 0
+`
+	if err := os.WriteFile(tgBin, []byte(script), 0755); err != nil {
+		t.Fatalf("write terragrunt script: %v", err)
+	}
+	t.Setenv("TF_PLUGIN_CACHE_DIR", filepath.Join(tmp, "plugin-cache"))
+	t.Setenv("TERRAGRUNT_FETCH_DEPENDENCY_OUTPUT_FROM_STATE", "")
+
+	for _, enabled := range []bool{false, true} {
+		if err := os.Remove(logPath); err != nil && !os.IsNotExist(err) {
+			t.Fatalf("reset log: %v", err)
+		}
+		opts := planOptions{fetchDependencyOutputFromState: enabled}
+		out, err := runPlan(context.Background(), workDir, "terragrunt", "terraform", tgBin, tmp, "envs/dev/app", "run-1", opts)
+		if err != nil {
+			t.Fatalf("runPlan error: %v\noutput:\n%s", err, out)
+		}
+
+		logBytes, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatalf("read log: %v", err)
+		}
+		log := string(logBytes)
+		want := ""
+		if enabled {
+			want = "true"
+		}
+		for _, name := range []string{"TG_DEPENDENCY_FETCH_OUTPUT_FROM_STATE", "TERRAGRUNT_FETCH_DEPENDENCY_OUTPUT_FROM_STATE"} {
+			if !strings.Contains(log, name+"="+want+"\n") {
+				t.Fatalf("enabled=%v: expected %s=%q\nlog:\n%s", enabled, name, want, log)
+			}
+		}
+	}
+}
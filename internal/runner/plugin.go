@@ -0,0 +1,109 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// PluginProtocolVersion is sent in every PluginJob. It changes only when a
+// field is removed or changes meaning.
+const PluginProtocolVersion = 1
+
+// maxPluginStderr bounds how much plugin stderr ends up in an error message.
+const maxPluginStderr = 4096
+
+// Plugin is an external runner binary that replaces terraform/terragrunt for
+// a project.
+type Plugin struct {
+	Command string
+	Args    []string
+	// PassEnv names extra environment variables passed to the plugin.
+	PassEnv []string
+}
+
+// PluginJob is written as JSON to the plugin's stdin. The plugin runs with
+// WorkDir as its working directory.
+type PluginJob struct {
+	ProtocolVersion   int    `json:"protocol_version"`
+	ProjectName       string `json:"project_name"`
+	ProjectURL        string `json:"project_url,omitempty"`
+	StackPath         string `json:"stack_path"`
+	WorkDir           string `json:"work_dir"`
+	ProjectRoot       string `json:"project_root"`
+	RunID             string `json:"run_id,omitempty"`
+	TerraformVersion  string `json:"terraform_version,omitempty"`
+	TerragruntVersion string `json:"terragrunt_version,omitempty"`
+}
+
+// PluginResult is read as JSON from the plugin's stdout. A stack counts as
+// drifted when Drifted is set or any resource count is non-zero. Error
+// reports a failed scan while still keeping PlanOutput.
+type PluginResult struct {
+	Drifted    bool   `json:"drifted"`
+	Added      int    `json:"added"`
+	Changed    int    `json:"changed"`
+	Destroyed  int    `json:"destroyed"`
+	PlanOutput string `json:"plan_output"`
+	Error      string `json:"error,omitempty"`
+}
+
+// runPlugin execs the plugin for one stack. A non-zero exit or unreadable
+// output is returned as an error with the tail of the plugin's stderr.
+func runPlugin(ctx context.Context, plugin *Plugin, job PluginJob) (*PluginResult, error) {
+	input, err := json.Marshal(job)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := prepareCancel(exec.CommandContext(ctx, plugin.Command, plugin.Args...))
+	cmd.Dir = job.WorkDir
+	cmd.Env = pluginEnv(plugin.PassEnv)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = fmt.Errorf("exit code %d", exitErr.ExitCode())
+		}
+		return nil, fmt.Errorf("runner plugin failed: %w%s", err, stderrTail(stderr.String()))
+	}
+
+	var result PluginResult
+	if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+		return nil, fmt.Errorf("runner plugin returned invalid result: %w%s", err, stderrTail(stderr.String()))
+	}
+	if result.Added > 0 || result.Changed > 0 || result.Destroyed > 0 {
+		result.Drifted = true
+	}
+	return &result, nil
+}
+
+func pluginEnv(passEnv []string) []string {
+	env := filteredEnv()
+	for _, name := range passEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+func stderrTail(stderr string) string {
+	stderr = strings.TrimSpace(stderr)
+	if stderr == "" {
+		return ""
+	}
+	if len(stderr) > maxPluginStderr {
+		stderr = "..." + stderr[len(stderr)-maxPluginStderr:]
+	}
+	return ": " + stderr
+}
@@ -0,0 +1,141 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func writePlugin(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plugin")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatalf("write plugin: %v", err)
+	}
+	return path
+}
+
+func TestRunWithPlugin(t *testing.T) {
+	workspace := t.TempDir()
+	stackDir := filepath.Join(workspace, "stacks/app")
+	if err := os.MkdirAll(stackDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	jobFile := filepath.Join(t.TempDir(), "job.json")
+	t.Setenv("DRIFTD_TEST_PLUGIN_TOKEN", "s3cret")
+
+	plugin := writePlugin(t, `cat > "`+jobFile+`"
+printf '{"added":1,"plan_output":"token=%s cwd=%s"}' "$DRIFTD_TEST_PLUGIN_TOKEN" "$(pwd)"
+`)
+	store := storage.New(t.TempDir())
+	r := New(store)
+	result, err := r.Run(context.Background(), &RunParams{
+		ProjectName:    "project",
+		StackPath:      "stacks/app",
+		RunID:          "scan-1",
+		TFVersion:      "1.6.0",
+		WorkspacePath:  workspace,
+		RedactPatterns: []string{"s3cret"},
+		Plugin:         &Plugin{Command: plugin, PassEnv: []string{"DRIFTD_TEST_PLUGIN_TOKEN"}},
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result.Error != "" || !result.Drifted || result.Added != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if strings.Contains(result.PlanOutput, "s3cret") || !strings.Contains(result.PlanOutput, "token=") {
+		t.Fatalf("expected redacted plan output, got %q", result.PlanOutput)
+	}
+	if !strings.Contains(result.PlanOutput, "cwd="+stackDir) {
+		t.Fatalf("plugin did not run in the stack directory: %q", result.PlanOutput)
+	}
+
+	raw, err := os.ReadFile(jobFile)
+	if err != nil {
+		t.Fatalf("read job: %v", err)
+	}
+	var job PluginJob
+	if err := json.Unmarshal(raw, &job); err != nil {
+		t.Fatalf("decode job: %v", err)
+	}
+	if job.ProtocolVersion != PluginProtocolVersion || job.StackPath != "stacks/app" || job.WorkDir != stackDir || job.ProjectRoot != workspace || job.TerraformVersion != "1.6.0" {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+
+	saved, err := store.GetResult("project", "stacks/app")
+	if err != nil || !saved.Drifted {
+		t.Fatalf("expected saved drifted result, got %+v (%v)", saved, err)
+	}
+	if saved.ScanID != "scan-1" || saved.TerraformVersion != "1.6.0" {
+		t.Fatalf("expected the result to record its scan and versions, got %+v", saved)
+	}
+	if _, err := store.GetScanResult("project", "stacks/app", "scan-1"); err != nil {
+		t.Fatalf("expected a snapshot for scan-1: %v", err)
+	}
+}
+
+func TestRunWithPluginDuringFreeze(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workspace, "app"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	plugin := writePlugin(t, `printf '{"drifted":true,"changed":1}'
+`)
+	store := storage.New(t.TempDir())
+	result, err := New(store).Run(context.Background(), &RunParams{
+		ProjectName:   "project",
+		StackPath:     "app",
+		WorkspacePath: workspace,
+		Plugin:        &Plugin{Command: plugin},
+		Frozen:        true,
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !result.Drifted || !result.ObservedDuringFreeze {
+		t.Fatalf("expected drift observed during the freeze, got %+v", result)
+	}
+	saved, err := store.GetResult("project", "app")
+	if err != nil || !saved.ObservedDuringFreeze {
+		t.Fatalf("expected the saved result to be flagged, got %+v (%v)", saved, err)
+	}
+}
+
+func TestRunWithPluginFailures(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workspace, "app"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{"exit code", "echo 'synth failed' >&2\nexit 3\n", "exit code 3: synth failed"},
+		{"invalid output", "echo not json\n", "invalid result"},
+		{"reported error", `echo '{"drifted":true,"plan_output":"partial","error":"provider auth failed"}'` + "\n", "provider auth failed"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := New(storage.New(t.TempDir()))
+			result, err := r.Run(context.Background(), &RunParams{
+				ProjectName:   "project",
+				StackPath:     "app",
+				WorkspacePath: workspace,
+				Plugin:        &Plugin{Command: writePlugin(t, tc.script)},
+			})
+			if err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if result.Drifted || !strings.Contains(result.Error, tc.want) {
+				t.Fatalf("expected error containing %q, got %+v", tc.want, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package runner
+
+import (
+	"os/exec"
+	"time"
+)
+
+// killWaitDelay bounds how long Wait blocks on output pipes after a canceled
+// command was killed, in case something outside its process group still
+// holds them open.
+const killWaitDelay = 10 * time.Second
+
+// prepareCancel makes a CommandContext command stop promptly, together with
+// its children, when its context ends.
+func prepareCancel(cmd *exec.Cmd) *exec.Cmd {
+	setProcessGroup(cmd)
+	cmd.WaitDelay = killWaitDelay
+	return cmd
+}
@@ -0,0 +1,46 @@
+//go:build unix
+
+package runner
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestRunCanceledKillsProcessGroup(t *testing.T) {
+	workspace := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workspace, "app"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// The background sleep inherits stdout, so Run only returns promptly if
+	// the whole process group is killed.
+	plugin := writePlugin(t, "sleep 30 &\nwait\n")
+	store := storage.New(t.TempDir())
+	r := New(store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := r.Run(ctx, &RunParams{
+		ProjectName:   "project",
+		StackPath:     "app",
+		WorkspacePath: workspace,
+		Plugin:        &Plugin{Command: plugin},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("canceled run took %s", elapsed)
+	}
+	if _, err := store.GetResult("project", "app"); err == nil {
+		t.Fatal("expected no result to be saved for a canceled run")
+	}
+}
@@ -0,0 +1,136 @@
+package runner
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// sharedProviderDir holds unpacked providers shared by every stack on a
+// worker, laid out like a plugin cache:
+// <host>/<namespace>/<type>/<version>/<os_arch>. Entries are written once,
+// after a plan that used them succeeded, and never modified, so stacks can
+// symlink them without coordinating.
+const sharedProviderDir = ".shared"
+
+// seedPluginCache links every provider in the shared store into a stack's
+// plugin cache so terraform init finds them instead of downloading. Terraform
+// only takes cached providers whose checksums match the stack's lock file.
+func seedPluginCache(sharedDir, cacheDir string) {
+	walkProviderPackages(sharedDir, func(rel string) {
+		target := filepath.Join(cacheDir, rel)
+		if _, err := os.Lstat(target); err == nil {
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return
+		}
+		_ = os.Symlink(filepath.Join(sharedDir, rel), target)
+	})
+}
+
+// publishPluginCache copies providers a stack downloaded into the shared
+// store. Links seeded from the store are skipped, as are packages another
+// stack published first.
+func publishPluginCache(cacheDir, sharedDir string) {
+	walkProviderPackages(cacheDir, func(rel string) {
+		src := filepath.Join(cacheDir, rel)
+		if info, err := os.Lstat(src); err != nil || !info.IsDir() {
+			return
+		}
+		target := filepath.Join(sharedDir, rel)
+		if _, err := os.Lstat(target); err == nil {
+			return
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return
+		}
+		tmp, err := os.MkdirTemp(filepath.Dir(target), ".tmp-"+filepath.Base(target)+"-*")
+		if err != nil {
+			return
+		}
+		if err := copyTree(src, tmp); err != nil || os.Rename(tmp, target) != nil {
+			os.RemoveAll(tmp)
+		}
+	})
+}
+
+// walkProviderPackages calls fn with the path of each
+// <host>/<namespace>/<type>/<version>/<os_arch> package under root,
+// relative to root.
+func walkProviderPackages(root string, fn func(rel string)) {
+	for _, host := range readDirNames(root) {
+		for _, namespace := range readDirNames(filepath.Join(root, host)) {
+			for _, typ := range readDirNames(filepath.Join(root, host, namespace)) {
+				for _, version := range readDirNames(filepath.Join(root, host, namespace, typ)) {
+					for _, platform := range readDirNames(filepath.Join(root, host, namespace, typ, version)) {
+						if strings.HasPrefix(platform, ".tmp-") {
+							continue
+						}
+						fn(filepath.Join(host, namespace, typ, version, platform))
+					}
+				}
+			}
+		}
+	}
+}
+
+// copyTree copies the regular files and directories under src into dst,
+// hard-linking files where the filesystem allows it.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, 0755)
+		case entry.Type().IsRegular():
+			if os.Link(path, target) == nil {
+				return nil
+			}
+			return copyFile(path, target)
+		default:
+			return errors.New("unexpected file in provider package: " + rel)
+		}
+	})
+}
+
+func copyFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// planCompleted reports whether a plan got past provider installation:
+// terraform exits 0 for no changes and 2 for changes.
+func planCompleted(err error) bool {
+	if err == nil {
+		return true
+	}
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && exitErr.ExitCode() == 2
+}
@@ -0,0 +1,77 @@
+package runner
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func writeProviderPackage(t *testing.T, root, rel, content string) {
+	t.Helper()
+	dir := filepath.Join(root, rel)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "terraform-provider"), []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPluginCacheSeedAndPublish(t *testing.T) {
+	base := t.TempDir()
+	shared := filepath.Join(base, sharedProviderDir)
+	aws := filepath.Join("registry.terraform.io", "hashicorp", "aws", "5.0.0", "linux_amd64")
+	random := filepath.Join("registry.terraform.io", "hashicorp", "random", "3.6.0", "linux_amd64")
+	writeProviderPackage(t, shared, aws, "aws")
+
+	cache := filepath.Join(base, "run-1", "envs__prod")
+	seedPluginCache(shared, cache)
+	data, err := os.ReadFile(filepath.Join(cache, aws, "terraform-provider"))
+	if err != nil || string(data) != "aws" {
+		t.Fatalf("expected seeded aws provider, got %q (%v)", data, err)
+	}
+	if info, err := os.Lstat(filepath.Join(cache, aws)); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("expected seeded package to be a link")
+	}
+
+	// terraform init downloads a provider the store doesn't have yet.
+	writeProviderPackage(t, cache, random, "random")
+	publishPluginCache(cache, shared)
+
+	data, err = os.ReadFile(filepath.Join(shared, random, "terraform-provider"))
+	if err != nil || string(data) != "random" {
+		t.Fatalf("expected published random provider, got %q (%v)", data, err)
+	}
+	if info, err := os.Lstat(filepath.Join(shared, aws)); err != nil || !info.IsDir() {
+		t.Fatalf("expected shared aws package to stay a directory: %v", err)
+	}
+
+	// Published packages are never overwritten.
+	other := filepath.Join(base, "run-2", "envs__dev")
+	writeProviderPackage(t, other, random, "changed")
+	publishPluginCache(other, shared)
+	data, _ = os.ReadFile(filepath.Join(shared, random, "terraform-provider"))
+	if string(data) != "random" {
+		t.Fatalf("expected first published package to win, got %q", data)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(filepath.Join(shared, random)))
+	if len(entries) != 1 {
+		t.Fatalf("expected no leftover temp dirs, got %d entries", len(entries))
+	}
+}
+
+func TestPlanCompleted(t *testing.T) {
+	if !planCompleted(nil) {
+		t.Fatalf("expected nil error to count as completed")
+	}
+	drift := exec.Command("sh", "-c", "exit 2").Run()
+	if !planCompleted(drift) {
+		t.Fatalf("expected exit code 2 to count as completed")
+	}
+	failed := exec.Command("sh", "-c", "exit 1").Run()
+	if planCompleted(failed) || planCompleted(errors.New("boom")) {
+		t.Fatalf("expected failures not to count as completed")
+	}
+}
@@ -0,0 +1,187 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+const pulumiTool = "pulumi"
+
+// pulumiPreview is the subset of `pulumi preview --json` output driftd uses.
+type pulumiPreview struct {
+	Steps         []pulumiStep      `json:"steps"`
+	ChangeSummary map[string]int    `json:"changeSummary"`
+	Diagnostics   []pulumiDiagnosis `json:"diagnostics"`
+}
+
+type pulumiStep struct {
+	Op          string   `json:"op"`
+	URN         string   `json:"urn"`
+	DiffReasons []string `json:"diffReasons"`
+}
+
+type pulumiDiagnosis struct {
+	URN      string `json:"urn"`
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+}
+
+// pulumiOpSymbols covers the steps shown in plan output. A replacement also
+// emits create-replacement and delete-replaced steps, which are left out.
+var pulumiOpSymbols = map[string]string{
+	"create":  "+",
+	"import":  "=",
+	"update":  "~",
+	"delete":  "-",
+	"replace": "+-",
+}
+
+// runPulumi previews the Pulumi project in workDir with a refresh, so changes
+// made outside Pulumi show up as drift, and maps the preview onto result.
+func (r *Runner) runPulumi(ctx context.Context, params *RunParams, workDir string, result *storage.RunResult) {
+	stackName, err := selectPulumiStack(workDir, params.PulumiStack)
+	if err != nil {
+		result.Error = err.Error()
+		return
+	}
+	args := []string{"preview", "--json", "--non-interactive", "--refresh"}
+	if stackName != "" {
+		args = append(args, "--stack", stackName)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := prepareCancel(exec.CommandContext(ctx, pulumiTool, args...))
+	cmd.Dir = workDir
+	cmd.Env = filteredEnv()
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	runErr := cmd.Run()
+
+	var preview pulumiPreview
+	parseErr := json.Unmarshal(stdout.Bytes(), &preview)
+	if parseErr == nil {
+		result.PlanOutput = renderPulumiPreview(stackName, &preview)
+	}
+	if stderr.Len() > 0 {
+		if result.PlanOutput != "" {
+			result.PlanOutput += "\n"
+		}
+		result.PlanOutput += stderr.String()
+	}
+
+	if runErr != nil {
+		var exitErr *exec.ExitError
+		if errors.As(runErr, &exitErr) {
+			result.Error = fmt.Sprintf("pulumi preview failed with exit code %d", exitErr.ExitCode())
+		} else {
+			result.Error = fmt.Sprintf("pulumi preview failed: %v", runErr)
+		}
+		return
+	}
+	if parseErr != nil {
+		result.Error = fmt.Sprintf("failed to parse pulumi preview output: %v", parseErr)
+		return
+	}
+	result.Added, result.Changed, result.Destroyed = pulumiChangeCounts(preview.ChangeSummary)
+	result.Drifted = result.Added > 0 || result.Changed > 0 || result.Destroyed > 0
+}
+
+// selectPulumiStack returns the configured stack, or the project's only
+// Pulumi.<stack>.yaml. An empty result leaves the choice to pulumi.
+func selectPulumiStack(workDir, configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+	var stacks []string
+	for _, pattern := range []string{"Pulumi.*.yaml", "Pulumi.*.yml"} {
+		matches, _ := filepath.Glob(filepath.Join(workDir, pattern))
+		for _, m := range matches {
+			name := strings.TrimPrefix(filepath.Base(m), "Pulumi.")
+			stacks = append(stacks, strings.TrimSuffix(strings.TrimSuffix(name, ".yaml"), ".yml"))
+		}
+	}
+	if len(stacks) > 1 {
+		sort.Strings(stacks)
+		return "", fmt.Errorf("pulumi project has several stacks (%s); set pulumi.stack", strings.Join(stacks, ", "))
+	}
+	if len(stacks) == 1 {
+		return stacks[0], nil
+	}
+	return "", nil
+}
+
+// pulumiChangeCounts maps Pulumi operations onto terraform's add/change/destroy
+// counts. A replacement counts as both an add and a destroy, as in a
+// terraform plan.
+func pulumiChangeCounts(summary map[string]int) (added, changed, destroyed int) {
+	added = summary["create"] + summary["replace"] + summary["import"]
+	changed = summary["update"]
+	destroyed = summary["delete"] + summary["replace"]
+	return added, changed, destroyed
+}
+
+func renderPulumiPreview(stackName string, preview *pulumiPreview) string {
+	var b strings.Builder
+	if stackName != "" {
+		fmt.Fprintf(&b, "Previewing stack %s\n\n", stackName)
+	}
+	for _, step := range preview.Steps {
+		symbol, ok := pulumiOpSymbols[step.Op]
+		if !ok {
+			continue
+		}
+		typ, name := splitPulumiURN(step.URN)
+		fmt.Fprintf(&b, "%3s %-9s %s %s", symbol, step.Op, typ, name)
+		if len(step.DiffReasons) > 0 {
+			fmt.Fprintf(&b, " [diff: %s]", strings.Join(step.DiffReasons, ", "))
+		}
+		b.WriteString("\n")
+	}
+	summary := preview.ChangeSummary
+	fmt.Fprintf(&b, "\nResources: %d to create, %d to update, %d to delete, %d to replace, %d unchanged\n",
+		summary["create"], summary["update"], summary["delete"], summary["replace"], summary["same"])
+	for _, d := range preview.Diagnostics {
+		if d.Severity != "error" && d.Severity != "warning" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s: %s", d.Severity, strings.TrimSpace(d.Message))
+		if _, name := splitPulumiURN(d.URN); name != "" {
+			fmt.Fprintf(&b, " (%s)", name)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// splitPulumiURN returns the resource type and name from
+// urn:pulumi:<stack>::<project>::<parent$type>::<name>.
+func splitPulumiURN(urn string) (typ, name string) {
+	parts := strings.Split(urn, "::")
+	if len(parts) < 4 {
+		return "", urn
+	}
+	typ = parts[len(parts)-2]
+	if i := strings.LastIndex(typ, "$"); i >= 0 {
+		typ = typ[i+1:]
+	}
+	return typ, parts[len(parts)-1]
+}
+
+func hasPulumiProject(dir string) bool {
+	for _, name := range []string{"Pulumi.yaml", "Pulumi.yml"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
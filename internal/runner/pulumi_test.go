@@ -0,0 +1,115 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+const testPulumiPreview = `{
+  "steps": [
+    {"op": "same", "urn": "urn:pulumi:prod::web::pulumi:pulumi:Stack::web-prod"},
+    {"op": "update", "urn": "urn:pulumi:prod::web::pulumi:pulumi:Stack$aws:s3/bucket:Bucket::logs", "diffReasons": ["tags"]},
+    {"op": "replace", "urn": "urn:pulumi:prod::web::aws:ec2/instance:Instance::web"},
+    {"op": "create-replacement", "urn": "urn:pulumi:prod::web::aws:ec2/instance:Instance::web"}
+  ],
+  "changeSummary": {"same": 3, "update": 1, "replace": 1},
+  "diagnostics": [{"message": "deprecated argument", "severity": "warning"}]
+}`
+
+// writeFakePulumi puts a pulumi binary on PATH that records its arguments
+// and prints script's output.
+func writeFakePulumi(t *testing.T, script string) string {
+	t.Helper()
+	binDir := t.TempDir()
+	argsFile := filepath.Join(binDir, "args")
+	body := "#!/bin/sh\necho \"$@\" > \"" + argsFile + "\"\n" + script
+	if err := os.WriteFile(filepath.Join(binDir, "pulumi"), []byte(body), 0755); err != nil {
+		t.Fatalf("write fake pulumi: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return argsFile
+}
+
+func newPulumiWorkspace(t *testing.T, files ...string) string {
+	t.Helper()
+	workspace := t.TempDir()
+	dir := filepath.Join(workspace, "apps/web")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range append([]string{"Pulumi.yaml"}, files...) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("name: web\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return workspace
+}
+
+func TestRunPulumiPreview(t *testing.T) {
+	workspace := newPulumiWorkspace(t, "Pulumi.prod.yaml")
+	argsFile := writeFakePulumi(t, "cat <<'JSON'\n"+testPulumiPreview+"\nJSON\n")
+
+	r := New(storage.New(t.TempDir()))
+	result, err := r.Run(context.Background(), &RunParams{ProjectName: "project", StackPath: "apps/web", WorkspacePath: workspace})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if result.Error != "" {
+		t.Fatalf("unexpected error: %s\n%s", result.Error, result.PlanOutput)
+	}
+	if !result.Drifted || result.Added != 1 || result.Changed != 1 || result.Destroyed != 1 {
+		t.Fatalf("unexpected counts: %+v", result)
+	}
+	for _, want := range []string{
+		"~ update    aws:s3/bucket:Bucket logs [diff: tags]",
+		"+- replace   aws:ec2/instance:Instance web",
+		"Resources: 0 to create, 1 to update, 0 to delete, 1 to replace, 3 unchanged",
+		"warning: deprecated argument",
+	} {
+		if !strings.Contains(result.PlanOutput, want) {
+			t.Fatalf("plan output missing %q:\n%s", want, result.PlanOutput)
+		}
+	}
+	if strings.Contains(result.PlanOutput, "create-replacement") {
+		t.Fatalf("replacement sub-steps should not be listed:\n%s", result.PlanOutput)
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatalf("read args: %v", err)
+	}
+	if got := strings.TrimSpace(string(args)); got != "preview --json --non-interactive --refresh --stack prod" {
+		t.Fatalf("unexpected pulumi args: %q", got)
+	}
+}
+
+func TestRunPulumiFailures(t *testing.T) {
+	t.Run("preview error", func(t *testing.T) {
+		workspace := newPulumiWorkspace(t)
+		writeFakePulumi(t, `echo '{"steps":[],"changeSummary":{},"diagnostics":[{"message":"no credentials","severity":"error"}]}'`+"\nexit 255\n")
+		r := New(storage.New(t.TempDir()))
+		result, _ := r.Run(context.Background(), &RunParams{ProjectName: "project", StackPath: "apps/web", WorkspacePath: workspace})
+		if result.Drifted || result.Error != "pulumi preview failed with exit code 255" || !strings.Contains(result.PlanOutput, "error: no credentials") {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("ambiguous stack", func(t *testing.T) {
+		workspace := newPulumiWorkspace(t, "Pulumi.dev.yaml", "Pulumi.prod.yaml")
+		writeFakePulumi(t, "exit 0\n")
+		r := New(storage.New(t.TempDir()))
+		result, _ := r.Run(context.Background(), &RunParams{ProjectName: "project", StackPath: "apps/web", WorkspacePath: workspace})
+		if !strings.Contains(result.Error, "several stacks (dev, prod)") {
+			t.Fatalf("unexpected result: %+v", result)
+		}
+		result, _ = r.Run(context.Background(), &RunParams{ProjectName: "project", StackPath: "apps/web", WorkspacePath: workspace, PulumiStack: "dev"})
+		if !strings.Contains(result.Error, "failed to parse pulumi preview output") {
+			t.Fatalf("expected configured stack to be used, got %+v", result)
+		}
+	})
+}
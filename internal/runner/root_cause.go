@@ -0,0 +1,159 @@
+package runner
+
+import (
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+// Root-cause hints attached to drifted results. They are guesses from the
+// shape of the plan, shown next to the drift summary to point reviewers in
+// a direction, not conclusions.
+const (
+	RootCauseModuleChange       = "likely module source change"
+	RootCauseProviderUpgrade    = "likely provider version change"
+	RootCauseDeletedOutside     = "likely deleted outside Terraform"
+	RootCauseConsoleEdit        = "likely manual console edit"
+	RootCauseExternalAutomation = "likely external automation"
+	RootCauseAutoscaling        = "likely autoscaling"
+)
+
+// tagAttributes are changed by hand in cloud consoles far more often than
+// anything else.
+var tagAttributes = map[string]bool{"tags": true, "tags_all": true, "labels": true}
+
+// imageAttributes are rolled forward by image pipelines and patching
+// automation outside Terraform.
+var imageAttributes = map[string]bool{
+	"ami":                     true,
+	"image_id":                true,
+	"image":                   true,
+	"latest_version":          true,
+	"default_version":         true,
+	"task_definition":         true,
+	"source_image":            true,
+	"source_image_id":         true,
+	"launch_template_version": true,
+}
+
+// scalingAttributes are adjusted by autoscalers.
+var scalingAttributes = map[string]bool{
+	"desired_count":    true,
+	"desired_capacity": true,
+	"desired_size":     true,
+	"node_count":       true,
+	"replicas":         true,
+	"capacity":         true,
+}
+
+// applyRootCauseHint sets RootCauseHint on drifted results from the first
+// heuristic that matches. Results without drift get no hint.
+func applyRootCauseHint(result *storage.RunResult) {
+	result.RootCauseHint = ""
+	if !result.Drifted || result.Error != "" {
+		return
+	}
+	result.RootCauseHint = rootCauseHint(result)
+}
+
+func rootCauseHint(result *storage.RunResult) string {
+	if len(result.ModuleSourceChanges) > 0 {
+		return RootCauseModuleChange
+	}
+	if len(result.ProviderLockDrift) > 0 {
+		return RootCauseProviderUpgrade
+	}
+	clean := ansiEscapePattern.ReplaceAllString(result.PlanOutput, "")
+	if strings.Contains(clean, " has been deleted") {
+		return RootCauseDeletedOutside
+	}
+
+	var changed [][]string
+	for _, block := range splitResourceBlocks(clean) {
+		switch block.action {
+		case "read":
+			continue
+		case "update", "replace":
+			attrs := changedAttributes(block.lines)
+			if len(attrs) == 0 {
+				return ""
+			}
+			changed = append(changed, attrs)
+		default:
+			return ""
+		}
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+	for _, attrs := range changed {
+		if anyAttribute(attrs, imageAttributes) {
+			return RootCauseExternalAutomation
+		}
+	}
+	switch {
+	case onlyAttributes(changed, tagAttributes):
+		return RootCauseConsoleEdit
+	case onlyAttributes(changed, scalingAttributes):
+		return RootCauseAutoscaling
+	}
+	return ""
+}
+
+// changedAttributes returns the names of the top-level attributes and
+// blocks a resource body marks as changed.
+func changedAttributes(lines []string) []string {
+	attrIndent := -1
+	var attrs []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if strings.HasPrefix(trimmed, "~ resource ") || strings.HasPrefix(trimmed, "-/+ resource ") || strings.HasPrefix(trimmed, "+/- resource ") {
+			// Attribute markers line up four columns right of the
+			// "resource" keyword.
+			attrIndent = indent + 4
+			if !strings.HasPrefix(trimmed, "~") {
+				attrIndent = indent + 6
+			}
+			continue
+		}
+		if attrIndent < 0 || indent != attrIndent || len(trimmed) < 2 {
+			continue
+		}
+		switch trimmed[0] {
+		case '~', '+', '-':
+		default:
+			continue
+		}
+		name := strings.TrimSpace(trimmed[1:])
+		if end := strings.IndexAny(name, " ={"); end >= 0 {
+			name = name[:end]
+		}
+		if name = strings.Trim(name, `"`); name != "" {
+			attrs = append(attrs, name)
+		}
+	}
+	return attrs
+}
+
+func anyAttribute(attrs []string, set map[string]bool) bool {
+	for _, a := range attrs {
+		if set[a] {
+			return true
+		}
+	}
+	return false
+}
+
+// onlyAttributes reports whether every changed resource only touches
+// attributes in set.
+func onlyAttributes(resources [][]string, set map[string]bool) bool {
+	for _, attrs := range resources {
+		for _, a := range attrs {
+			if !set[a] {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/stack"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+const tagsOnlyPlan = `Terraform will perform the following actions:
+
+  # aws_instance.web will be updated in-place
+  ~ resource "aws_instance" "web" {
+        id   = "i-0123"
+      ~ tags = {
+          + "Owner" = "alice"
+        }
+      ~ tags_all = {
+          + "Owner" = "alice"
+        }
+        # (12 unchanged attributes hidden)
+    }
+
+Plan: 0 to add, 1 to change, 0 to destroy.
+`
+
+const amiReplacePlan = `Terraform will perform the following actions:
+
+  # aws_instance.web must be replaced
+-/+ resource "aws_instance" "web" {
+      ~ ami  = "ami-111" -> "ami-222" # forces replacement
+      ~ id   = "i-0123" -> (known after apply)
+        tags = {}
+    }
+
+  # aws_s3_bucket.logs will be updated in-place
+  ~ resource "aws_s3_bucket" "logs" {
+      ~ tags = {
+          - "Team" = "ops" -> null
+        }
+    }
+
+Plan: 1 to add, 1 to change, 1 to destroy.
+`
+
+const scalingPlan = `  # aws_ecs_service.api will be updated in-place
+  ~ resource "aws_ecs_service" "api" {
+      ~ desired_count = 6 -> 3
+        name          = "api"
+    }
+
+Plan: 0 to add, 1 to change, 0 to destroy.
+`
+
+const deletedPlan = `Note: Objects have changed outside of Terraform
+
+  # aws_sqs_queue.jobs has been deleted
+  - resource "aws_sqs_queue" "jobs" {
+      - name = "jobs" -> null
+    }
+
+Terraform will perform the following actions:
+
+  # aws_sqs_queue.jobs will be created
+  + resource "aws_sqs_queue" "jobs" {
+      + name = "jobs"
+    }
+
+Plan: 1 to add, 0 to change, 0 to destroy.
+`
+
+const mixedPlan = `  # aws_security_group.app will be updated in-place
+  ~ resource "aws_security_group" "app" {
+      ~ ingress = [
+          + {
+              + cidr_blocks = ["0.0.0.0/0"]
+            },
+        ]
+      ~ tags    = {
+          + "Owner" = "alice"
+        }
+    }
+
+Plan: 0 to add, 1 to change, 0 to destroy.
+`
+
+func TestApplyRootCauseHint(t *testing.T) {
+	cases := []struct {
+		name   string
+		result storage.RunResult
+		want   string
+	}{
+		{"tags only", storage.RunResult{Drifted: true, PlanOutput: "\x1b[1m" + tagsOnlyPlan}, RootCauseConsoleEdit},
+		{"image change", storage.RunResult{Drifted: true, PlanOutput: amiReplacePlan}, RootCauseExternalAutomation},
+		{"scaling", storage.RunResult{Drifted: true, PlanOutput: scalingPlan}, RootCauseAutoscaling},
+		{"deleted outside", storage.RunResult{Drifted: true, PlanOutput: deletedPlan}, RootCauseDeletedOutside},
+		{"mixed changes", storage.RunResult{Drifted: true, PlanOutput: mixedPlan}, ""},
+		{"module change", storage.RunResult{
+			Drifted:             true,
+			PlanOutput:          tagsOnlyPlan,
+			ModuleSourceChanges: []stack.ModuleSourceChange{{Module: "vpc"}},
+		}, RootCauseModuleChange},
+		{"provider lock drift", storage.RunResult{
+			Drifted:           true,
+			PlanOutput:        mixedPlan,
+			ProviderLockDrift: []storage.ProviderLockMismatch{{Provider: "hashicorp/aws", Locked: "5.0.0", Installed: "5.1.0"}},
+		}, RootCauseProviderUpgrade},
+		{"not drifted", storage.RunResult{PlanOutput: tagsOnlyPlan, RootCauseHint: "stale"}, ""},
+		{"errored", storage.RunResult{Drifted: true, Error: "plan failed", PlanOutput: tagsOnlyPlan}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := tc.result
+			applyRootCauseHint(&result)
+			if result.RootCauseHint != tc.want {
+				t.Fatalf("expected hint %q, got %q", tc.want, result.RootCauseHint)
+			}
+		})
+	}
+}
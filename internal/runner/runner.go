@@ -2,13 +2,16 @@ package runner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"time"
 
 	"github.com/driftdhq/driftd/internal/pathutil"
+	"github.com/driftdhq/driftd/internal/stack"
 	"github.com/driftdhq/driftd/internal/storage"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 )
@@ -23,17 +26,55 @@ func New(s *storage.Storage) *Runner {
 
 // RunParams contains all parameters needed to execute a plan.
 type RunParams struct {
-	ProjectName   string
-	ProjectURL    string
-	StackPath     string
-	TFVersion     string
-	TGVersion     string
-	RunID         string
+	ProjectName string
+	ProjectURL  string
+	StackPath   string
+	TFVersion   string
+	TGVersion   string
+	RunID       string
+	// CommitSHA is the commit the scan checked out, recorded on the result
+	// together with CommitInfo.
+	CommitSHA     string
+	CommitInfo    *storage.CommitInfo
 	Auth          transport.AuthMethod
 	WorkspacePath string
 	CloneDepth    int
 	// BlockExternalDataSource blocks stacks that use Terraform data "external".
 	BlockExternalDataSource bool
+	// TerragruntFetchDependencyOutputFromState reads terragrunt dependency
+	// outputs from upstream remote state during plan.
+	TerragruntFetchDependencyOutputFromState bool
+	// RedactPatterns are project-specific regexes applied to plan output in
+	// addition to the built-in redaction.
+	RedactPatterns []string
+	// PulumiStack selects the stack previewed in Pulumi projects.
+	PulumiStack string
+	// Plugin, when set, runs the stack through an external runner binary
+	// instead of terraform/terragrunt.
+	Plugin *Plugin
+	// InitArgs and PlanArgs are extra terraform arguments from the project
+	// config. InitArgs only apply to terraform stacks; terragrunt runs its
+	// own init.
+	InitArgs []string
+	PlanArgs []string
+	// Noise selects the heuristics that turn no-op plan differences into a
+	// noisy-clean result.
+	Noise NoiseHeuristics
+	// BlameDrift attributes drifted resources to the last commit that
+	// touched their resource blocks.
+	BlameDrift bool
+	// Frozen is set when the stack's environment is frozen; drift the run
+	// finds is recorded as observed during the freeze.
+	Frozen bool
+	// TerragruntCacheDependencyOutputs shares terragrunt dependency outputs
+	// between the stacks of one scan on this worker.
+	TerragruntCacheDependencyOutputs bool
+	// LinkVars names the variables console links reference; their values
+	// are read from the stack's tfvars and terragrunt inputs.
+	LinkVars []string
+	// Warm, when set, is the worker's warm workspace for a hot terraform
+	// stack. The run updates its Fingerprint and Hit.
+	Warm *WarmWorkspace
 }
 
 func (r *Runner) Run(ctx context.Context, params *RunParams) (*storage.RunResult, error) {
@@ -46,6 +87,12 @@ func (r *Runner) Run(ctx context.Context, params *RunParams) (*storage.RunResult
 		return result, nil
 	}
 
+	redactPatterns, err := CompileRedactPatterns(params.RedactPatterns)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+
 	projectRoot, cleanup, err := r.prepareProjectRoot(ctx, params.ProjectURL, params.WorkspacePath, params.Auth, params.CloneDepth)
 	if err != nil {
 		result.Error = err.Error()
@@ -60,20 +107,72 @@ func (r *Runner) Run(ctx context.Context, params *RunParams) (*storage.RunResult
 		result.Error = fmt.Sprintf("stack path not found: %s", params.StackPath)
 		return result, nil
 	}
+	if tags, err := stack.ParseTags(workDir); err == nil {
+		result.Tags = tags
+	}
+	result.LinkVars = stack.ParseVars(workDir, params.LinkVars)
+	if sources, err := stack.ParseModuleSources(projectRoot, workDir); err == nil {
+		result.ModuleSources = sources
+		result.ModuleSourceChanges = r.moduleSourceChanges(params.ProjectName, params.StackPath, sources)
+	}
+	if outputs, err := stack.ParseOutputs(workDir); err == nil {
+		result.Outputs = outputs
+	}
+	result.BackendType = stack.ParseBackendType(workDir)
 	if err := enforceExternalDataSourcePolicy(workDir, params.BlockExternalDataSource); err != nil {
 		result.Error = err.Error()
 		return result, nil
 	}
 
-	output, err := planStack(ctx, workDir, projectRoot, params.StackPath, params.TFVersion, params.TGVersion, params.RunID)
-	result.PlanOutput = RedactPlanOutput(output)
+	if params.Plugin != nil {
+		r.runWithPlugin(ctx, params, projectRoot, workDir, result, redactPatterns)
+		return r.saveResult(ctx, params, result)
+	}
+	if detectTool(workDir) == pulumiTool {
+		r.runPulumi(ctx, params, workDir, result)
+		result.PlanOutput = RedactPlanOutput(result.PlanOutput, redactPatterns...)
+		return r.saveResult(ctx, params, result)
+	}
+
+	for _, args := range [][]string{params.InitArgs, params.PlanArgs} {
+		if err := checkArgFiles(projectRoot, workDir, args); err != nil {
+			result.Error = err.Error()
+			return r.saveResult(ctx, params, result)
+		}
+	}
+
+	locked, hasLockFile, lockErr := readProviderLockFile(workDir)
+	var installed map[string]string
+	var env []string
+	dependencyCacheDir := ""
+	if params.TerragruntCacheDependencyOutputs {
+		dependencyCacheDir = prepareDependencyCache(params.RunID)
+	}
+	warmFingerprint := ""
+	if params.Warm != nil {
+		warmFingerprint = initFingerprint(workDir, params.TFVersion, params.InitArgs)
+	}
+	output, err := planStack(ctx, workDir, projectRoot, params.StackPath, params.TFVersion, params.TGVersion, params.RunID, planOptions{
+		fetchDependencyOutputFromState: params.TerragruntFetchDependencyOutputFromState,
+		onProviders:                    func(p map[string]string) { installed = p },
+		onEnv:                          func(e []string) { env = e },
+		initArgs:                       params.InitArgs,
+		planArgs:                       params.PlanArgs,
+
+		dependencyCacheDir: dependencyCacheDir,
+		dependencyCommit:   params.CommitSHA,
+
+		warm:            params.Warm,
+		warmFingerprint: warmFingerprint,
+	})
+	result.PlanOutput = RedactPlanOutput(output, redactPatterns...)
 
 	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			// Exit code 2 means changes detected (drift)
 			if exitErr.ExitCode() == 2 {
 				result.Drifted = true
-				result.Added, result.Changed, result.Destroyed = parsePlanSummary(output)
+				applyPlanSummary(result, summarizePlan(result.PlanOutput))
 			} else {
 				result.Error = fmt.Sprintf("plan failed with exit code %d", exitErr.ExitCode())
 			}
@@ -82,13 +181,89 @@ func (r *Runner) Run(ctx context.Context, params *RunParams) (*storage.RunResult
 		}
 	} else {
 		// Exit code 0 - check if there are still changes (some tf versions)
-		result.Added, result.Changed, result.Destroyed = parsePlanSummary(output)
+		applyPlanSummary(result, summarizePlan(result.PlanOutput))
 		result.Drifted = result.Added > 0 || result.Changed > 0 || result.Destroyed > 0
 	}
+	applyNoiseReduction(result, params.Noise)
+	if len(result.OutputChanges) > 0 {
+		applyOutputConsumers(result.OutputChanges, stack.OutputConsumers(projectRoot, params.StackPath))
+	}
 
-	if saveErr := r.storage.SaveResult(params.ProjectName, params.StackPath, result); saveErr != nil {
-		return result, fmt.Errorf("failed to save result: %w", saveErr)
+	if result.Error != "" {
+		result.EnvNames = envNames(env)
+	}
+	if params.BlameDrift && result.Drifted && result.Error == "" {
+		result.ResourceOwners = blameResources(projectRoot, workDir, result.ResourceChanges)
+	}
+	// Only compare against providers from an init that got as far as planning;
+	// a failed init leaves a partial install that would read as lock drift.
+	if hasLockFile && lockErr == nil && installed != nil && result.Error == "" {
+		result.ProviderLockDrift = compareProviderLocks(locked, installed)
 	}
 
+	return r.saveResult(ctx, params, result)
+}
+
+func applyPlanSummary(result *storage.RunResult, summary planSummary) {
+	result.Added = summary.Added
+	result.Changed = summary.Changed
+	result.Destroyed = summary.Destroyed
+	result.ResourceChanges = summary.Resources
+	result.OutputChanges = summary.Outputs
+}
+
+func (r *Runner) saveResult(ctx context.Context, params *RunParams, result *storage.RunResult) (*storage.RunResult, error) {
+	if errors.Is(ctx.Err(), context.Canceled) {
+		// A canceled run says nothing about the stack; keep the last result.
+		return result, ctx.Err()
+	}
+	applyRootCauseHint(result)
+	// Set here so drift from plugin and Pulumi stacks is flagged too.
+	result.ObservedDuringFreeze = params.Frozen && result.Drifted
+	result.ScanID = params.RunID
+	result.CommitSHA = params.CommitSHA
+	result.CommitInfo = params.CommitInfo
+	result.TerraformVersion = params.TFVersion
+	result.TerragruntVersion = params.TGVersion
+	if err := r.storage.SaveResult(params.ProjectName, params.StackPath, result); err != nil {
+		return result, fmt.Errorf("failed to save result: %w", err)
+	}
 	return result, nil
 }
+
+// runWithPlugin fills result from the project's runner plugin.
+func (r *Runner) runWithPlugin(ctx context.Context, params *RunParams, projectRoot, workDir string, result *storage.RunResult, redactPatterns []*regexp.Regexp) {
+	absRoot, _ := filepath.Abs(projectRoot)
+	absWorkDir, _ := filepath.Abs(workDir)
+	out, err := runPlugin(ctx, params.Plugin, PluginJob{
+		ProtocolVersion:   PluginProtocolVersion,
+		ProjectName:       params.ProjectName,
+		ProjectURL:        params.ProjectURL,
+		StackPath:         params.StackPath,
+		WorkDir:           absWorkDir,
+		ProjectRoot:       absRoot,
+		RunID:             params.RunID,
+		TerraformVersion:  params.TFVersion,
+		TerragruntVersion: params.TGVersion,
+	})
+	if err != nil {
+		result.Error = RedactPlanOutput(err.Error(), redactPatterns...)
+		return
+	}
+	// Errored runs are never reported as drifted, as with terraform.
+	result.Drifted = out.Drifted && out.Error == ""
+	result.Added, result.Changed, result.Destroyed = out.Added, out.Changed, out.Destroyed
+	result.PlanOutput = RedactPlanOutput(out.PlanOutput, redactPatterns...)
+	result.Error = RedactPlanOutput(out.Error, redactPatterns...)
+}
+
+// moduleSourceChanges compares sources with the stack's previous result.
+// Nothing is reported for a stack's first run or when the previous result
+// has no module sources recorded.
+func (r *Runner) moduleSourceChanges(projectName, stackPath string, sources []stack.ModuleSource) []stack.ModuleSourceChange {
+	prev, err := r.storage.GetResult(projectName, stackPath)
+	if err != nil || prev == nil || len(prev.ModuleSources) == 0 {
+		return nil
+	}
+	return stack.CompareModuleSources(prev.ModuleSources, sources)
+}
@@ -1,12 +1,16 @@
 package runner
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/driftdhq/driftd/internal/storage"
 )
 
 func TestParsePlanSummary(t *testing.T) {
@@ -42,7 +46,8 @@ func TestParsePlanSummary(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			added, changed, destroyed := parsePlanSummary(tt.output)
+			summary := summarizePlan(tt.output)
+			added, changed, destroyed := summary.Added, summary.Changed, summary.Destroyed
 			if added != tt.added || changed != tt.changed || destroyed != tt.destroyed {
 				t.Fatalf("got %d/%d/%d, want %d/%d/%d", added, changed, destroyed, tt.added, tt.changed, tt.destroyed)
 			}
@@ -324,3 +329,82 @@ func TestCleanTerragruntOutput(t *testing.T) {
 func execCommand(name string, args ...string) *exec.Cmd {
 	return exec.Command(name, args...)
 }
+
+func TestRunRecordsModuleSourceChanges(t *testing.T) {
+	workspace := t.TempDir()
+	stackDir := filepath.Join(workspace, "envs/prod")
+	if err := os.MkdirAll(stackDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeModule := func(version string) {
+		t.Helper()
+		src := `module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "` + version + `"
+}
+`
+		if err := os.WriteFile(filepath.Join(stackDir, "main.tf"), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := New(storage.New(t.TempDir()))
+	params := &RunParams{ProjectName: "project", StackPath: "envs/prod", WorkspacePath: workspace}
+
+	writeModule("5.1.0")
+	result, _ := r.Run(context.Background(), params)
+	if len(result.ModuleSources) != 1 || len(result.ModuleSourceChanges) != 0 {
+		t.Fatalf("first run: unexpected module data %+v %+v", result.ModuleSources, result.ModuleSourceChanges)
+	}
+
+	writeModule("5.2.0")
+	result, _ = r.Run(context.Background(), params)
+	if len(result.ModuleSourceChanges) != 1 {
+		t.Fatalf("expected module change, got %+v", result.ModuleSourceChanges)
+	}
+	change := result.ModuleSourceChanges[0]
+	if change.Module != "module.vpc" || change.Previous.Version != "5.1.0" || change.Current.Version != "5.2.0" {
+		t.Fatalf("unexpected change: %+v", change)
+	}
+
+	result, _ = r.Run(context.Background(), params)
+	if len(result.ModuleSourceChanges) != 0 {
+		t.Fatalf("expected no change on unchanged rerun, got %+v", result.ModuleSourceChanges)
+	}
+}
+
+func TestRunPlanReportsFailingCommandEnv(t *testing.T) {
+	tmp := t.TempDir()
+	workDir := filepath.Join(tmp, "work")
+	if err := os.MkdirAll(workDir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	t.Setenv("TF_VAR_region", "us-east-1")
+	t.Setenv("SHOULD_NOT_LEAK", "nope")
+
+	tfBin := filepath.Join(tmp, "terraform")
+	if err := os.WriteFile(tfBin, []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatalf("write fake terraform: %v", err)
+	}
+
+	var env []string
+	if _, err := runPlan(context.Background(), workDir, "terraform", tfBin, "", tmp, "envs/dev", "run-1", planOptions{
+		onEnv: func(e []string) { env = e },
+	}); err == nil {
+		t.Fatal("expected init to fail")
+	}
+	names := envNames(env)
+	for _, want := range []string{"TF_DATA_DIR", "TF_PLUGIN_CACHE_DIR", "TF_VAR_region"} {
+		if !slices.Contains(names, want) {
+			t.Fatalf("expected %s in env names, got %v", want, names)
+		}
+	}
+	for _, name := range names {
+		if name == "SHOULD_NOT_LEAK" || strings.Contains(name, "=") {
+			t.Fatalf("unexpected env name %q", name)
+		}
+	}
+	if !slices.IsSorted(names) {
+		t.Fatalf("expected sorted names, got %v", names)
+	}
+}
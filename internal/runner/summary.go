@@ -0,0 +1,121 @@
+package runner
+
+import (
+	"bufio"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+var (
+	ansiEscapePattern = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+	// planSummaryPattern matches the summary line from terraform 0.12 on,
+	// including the "to import" prefix (1.5+) and "to forget" suffix (1.7+).
+	planSummaryPattern = regexp.MustCompile(`Plan: (?:(\d+) to import, )?(\d+) to add, (\d+) to change, (\d+) to destroy(?:, (\d+) to forget)?`)
+	deposedPattern     = regexp.MustCompile(` \(deposed object [0-9a-f]+\)$`)
+)
+
+// resourceActionPhrases maps the "# <address> <phrase>" headers terraform
+// prints above each resource to an action. Refresh notes such as "has
+// changed" or "has been deleted" describe state, not planned changes, and
+// are left out.
+var resourceActionPhrases = []struct {
+	phrase string
+	action string
+}{
+	{" will be created", "create"},
+	{" will be updated in-place", "update"},
+	{" will be destroyed", "delete"},
+	{" is tainted, so must be replaced", "replace"},
+	{" must be replaced", "replace"},
+	{" will be replaced, as requested", "replace"},
+	{" will be replaced due to changes in replace_triggered_by", "replace"},
+	{" will be read during apply", "read"},
+	{" will be imported", "import"},
+	{" will no longer be managed by Terraform", "forget"},
+}
+
+// planSummary is what driftd reads from plain-text plan output.
+type planSummary struct {
+	Added     int
+	Changed   int
+	Destroyed int
+	Resources []storage.ResourceChange
+	Outputs   []storage.OutputChange
+}
+
+// summarizePlan parses terraform/terragrunt text output. The "Plan:" line is
+// authoritative for counts; when it is missing, counts are derived from the
+// resource headers. A "No changes." plan reports no resources.
+func summarizePlan(output string) planSummary {
+	clean := ansiEscapePattern.ReplaceAllString(output, "")
+
+	var summary planSummary
+	summary.Resources = parseResourceChanges(clean)
+	summary.Outputs = parseOutputChanges(clean)
+
+	matches := planSummaryPattern.FindAllStringSubmatch(clean, -1)
+	switch {
+	case len(matches) > 0:
+		last := matches[len(matches)-1]
+		summary.Added, _ = strconv.Atoi(last[2])
+		summary.Changed, _ = strconv.Atoi(last[3])
+		summary.Destroyed, _ = strconv.Atoi(last[4])
+	case strings.Contains(clean, "No changes.") || strings.Contains(clean, "no differences"):
+		summary.Resources = nil
+	default:
+		for _, rc := range summary.Resources {
+			switch rc.Action {
+			case "create":
+				summary.Added++
+			case "update":
+				summary.Changed++
+			case "delete":
+				summary.Destroyed++
+			case "replace":
+				summary.Added++
+				summary.Destroyed++
+			}
+		}
+	}
+	return summary
+}
+
+func parseResourceChanges(output string) []storage.ResourceChange {
+	var out []storage.ResourceChange
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if rc, ok := ParseResourceHeader(scanner.Text()); ok {
+			out = append(out, rc)
+		}
+	}
+	return out
+}
+
+// ParseResourceHeader reads a "# <address> <phrase>" line that terraform
+// prints above a planned resource change. line must be free of ANSI escapes.
+func ParseResourceHeader(line string) (storage.ResourceChange, bool) {
+	header := strings.TrimSpace(line)
+	if !strings.HasPrefix(header, "# ") {
+		return storage.ResourceChange{}, false
+	}
+	header = strings.TrimPrefix(header, "# ")
+	if i := strings.Index(header, " has moved to "); i > 0 {
+		return storage.ResourceChange{Address: header[:i], Action: "move"}, true
+	}
+	for _, p := range resourceActionPhrases {
+		if !strings.HasSuffix(header, p.phrase) {
+			continue
+		}
+		address := strings.TrimSuffix(header, p.phrase)
+		address = deposedPattern.ReplaceAllString(address, "")
+		if address == "" || strings.HasPrefix(address, "(") {
+			return storage.ResourceChange{}, false
+		}
+		return storage.ResourceChange{Address: address, Action: p.action}, true
+	}
+	return storage.ResourceChange{}, false
+}
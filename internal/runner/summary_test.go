@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+const tf012Plan = `An execution plan has been generated and is shown below.
+Resource actions are indicated with the following symbols:
+  + create
+  ~ update in-place
+-/+ destroy and then create replacement
+
+Terraform will perform the following actions:
+
+  # aws_instance.web will be updated in-place
+  ~ resource "aws_instance" "web" {
+      ~ instance_type = "t2.micro" -> "t2.small"
+    }
+
+  # aws_security_group.sg must be replaced
+-/+ resource "aws_security_group" "sg" {
+    }
+
+  # aws_s3_bucket.logs["eu west"] will be created
+  + resource "aws_s3_bucket" "logs" {
+    }
+
+Plan: 2 to add, 1 to change, 1 to destroy.
+`
+
+const tf1xPlan = "\x1b[0m\x1b[1mNote: Objects have changed outside of Terraform\x1b[0m\n\n" +
+	"  \x1b[1m# aws_iam_role.ops\x1b[0m has changed\n" +
+	"  \x1b[1m# module.db.aws_db_instance.main\x1b[0m has been deleted\n\n" +
+	"Terraform will perform the following actions:\n\n" +
+	"\x1b[1m  # aws_instance.imported\x1b[0m will be imported\n" +
+	"\x1b[1m  # aws_instance.old\x1b[0m (deposed object 1a2b3c4d) will be destroyed\n" +
+	"\x1b[1m  # aws_instance.tainted\x1b[0m is tainted, so must be replaced\n" +
+	"\x1b[1m  # aws_instance.forgotten\x1b[0m will no longer be managed by Terraform\n" +
+	"\x1b[1m  # aws_instance.a\x1b[0m has moved to \x1b[1maws_instance.b\x1b[0m\n" +
+	"\x1b[1m  # data.aws_ami.latest\x1b[0m will be read during apply\n" +
+	"  # (config refers to values not yet known)\n\n" +
+	"\x1b[1mPlan:\x1b[0m 1 to import, 1 to add, 0 to change, 2 to destroy, 1 to forget.\n"
+
+func TestSummarizePlanFormats(t *testing.T) {
+	tests := []struct {
+		name      string
+		output    string
+		added     int
+		changed   int
+		destroyed int
+		resources []storage.ResourceChange
+	}{
+		{
+			name:   "terraform 0.12",
+			output: tf012Plan,
+			added:  2, changed: 1, destroyed: 1,
+			resources: []storage.ResourceChange{
+				{Address: "aws_instance.web", Action: "update"},
+				{Address: "aws_security_group.sg", Action: "replace"},
+				{Address: `aws_s3_bucket.logs["eu west"]`, Action: "create"},
+			},
+		},
+		{
+			name:   "terraform 1.x with color, import and forget",
+			output: tf1xPlan,
+			added:  1, changed: 0, destroyed: 2,
+			resources: []storage.ResourceChange{
+				{Address: "aws_instance.imported", Action: "import"},
+				{Address: "aws_instance.old", Action: "delete"},
+				{Address: "aws_instance.tainted", Action: "replace"},
+				{Address: "aws_instance.forgotten", Action: "forget"},
+				{Address: "aws_instance.a", Action: "move"},
+				{Address: "data.aws_ami.latest", Action: "read"},
+			},
+		},
+		{
+			name:   "headers without a summary line",
+			output: "  # aws_instance.a will be created\n  # aws_instance.b must be replaced\n  # aws_instance.c will be destroyed\n",
+			added:  2, changed: 0, destroyed: 2,
+			resources: []storage.ResourceChange{
+				{Address: "aws_instance.a", Action: "create"},
+				{Address: "aws_instance.b", Action: "replace"},
+				{Address: "aws_instance.c", Action: "delete"},
+			},
+		},
+		{
+			name:   "terraform 0.12 no changes",
+			output: "No changes. Infrastructure is up-to-date.\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := summarizePlan(tt.output)
+			if got.Added != tt.added || got.Changed != tt.changed || got.Destroyed != tt.destroyed {
+				t.Fatalf("got %d/%d/%d, want %d/%d/%d", got.Added, got.Changed, got.Destroyed, tt.added, tt.changed, tt.destroyed)
+			}
+			if !reflect.DeepEqual(got.Resources, tt.resources) {
+				t.Fatalf("resources = %+v, want %+v", got.Resources, tt.resources)
+			}
+		})
+	}
+}
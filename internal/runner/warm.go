@@ -0,0 +1,195 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/pathutil"
+)
+
+// WarmWorkspace is a terraform data directory and provider cache kept
+// between scans for a hot stack. A run whose init inputs match Fingerprint
+// plans without terraform init; any other run initializes it again in
+// place, which reuses the providers and modules already there. Terragrunt,
+// Pulumi and plugin stacks ignore it.
+type WarmWorkspace struct {
+	// Dir holds the data directory, provider cache and lock file.
+	Dir string
+	// Fingerprint identifies the init inputs Dir was last initialized
+	// with. Empty means it holds no usable init.
+	Fingerprint string
+	// Hit is set when the last run planned without init.
+	Hit bool
+}
+
+// WarmEligible reports whether the stack in stackDir is planned with
+// terraform init, the step a warm workspace saves.
+func WarmEligible(stackDir string) bool {
+	return detectTool(stackDir) == "terraform"
+}
+
+func (w *WarmWorkspace) dataDir() string   { return filepath.Join(w.Dir, "data") }
+func (w *WarmWorkspace) pluginDir() string { return filepath.Join(w.Dir, "plugins") }
+func (w *WarmWorkspace) lockFile() string  { return filepath.Join(w.Dir, providerLockFile) }
+
+// restoreLockFile gives workDir the lock file written by the warm init when
+// the repository does not commit one; init would otherwise have created it.
+func (w *WarmWorkspace) restoreLockFile(workDir string) bool {
+	dst := filepath.Join(workDir, providerLockFile)
+	if _, err := os.Stat(dst); err == nil {
+		return true
+	}
+	err := copyFile(w.lockFile(), dst)
+	return err == nil || errors.Is(err, os.ErrNotExist)
+}
+
+// saveLockFile keeps the lock file init left in workDir for later runs.
+func (w *WarmWorkspace) saveLockFile(workDir string) {
+	_ = os.Remove(w.lockFile())
+	_ = copyFile(filepath.Join(workDir, providerLockFile), w.lockFile())
+}
+
+// InitWarm runs the terraform init a scan of the stack at stackPath in
+// projectRoot would start with, so the stack's next scan plans without it.
+// It writes the stack's lock file when the repository does not commit one;
+// run it on a copy of the scan workspace. A workspace that already matches
+// the stack's init inputs is left alone.
+func InitWarm(ctx context.Context, w *WarmWorkspace, projectRoot, stackPath, tfVersion string, initArgs []string) error {
+	if !pathutil.IsSafeStackPath(stackPath) {
+		return fmt.Errorf("invalid stack path")
+	}
+	workDir := filepath.Join(projectRoot, stackPath)
+	if _, err := os.Stat(workDir); err != nil {
+		return fmt.Errorf("stack path not found: %s", stackPath)
+	}
+	if !WarmEligible(workDir) {
+		return nil
+	}
+	fingerprint := initFingerprint(workDir, tfVersion, initArgs)
+	if fingerprint != "" && fingerprint == w.Fingerprint {
+		return nil
+	}
+	if err := checkArgFiles(projectRoot, workDir, initArgs); err != nil {
+		return err
+	}
+	tfBin, err := ensureTerraformBinary(ctx, workDir, tfVersion)
+	if err != nil {
+		return fmt.Errorf("failed to install terraform: %v", err)
+	}
+	tfBin, err = ensurePlanOnlyWrapper(workDir, tfBin)
+	if err != nil {
+		return fmt.Errorf("failed to create terraform wrapper: %v", err)
+	}
+	return initWarm(ctx, w, workDir, tfBin, fingerprint, initArgs)
+}
+
+// initWarm runs terraform init in workDir against the warm data directory
+// and provider cache, the same way a cold warm run does.
+func initWarm(ctx context.Context, w *WarmWorkspace, workDir, tfBin, fingerprint string, initArgs []string) error {
+	dataDir := w.dataDir()
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		return fmt.Errorf("create TF_DATA_DIR: %w", err)
+	}
+	pluginCacheDir := w.pluginDir()
+	if err := os.MkdirAll(pluginCacheDir, 0755); err != nil {
+		return fmt.Errorf("create plugin cache: %w", err)
+	}
+	sharedDir := ""
+	if base := pluginCacheBaseDir(); base != "" {
+		sharedDir = filepath.Join(base, sharedProviderDir)
+		seedPluginCache(sharedDir, pluginCacheDir)
+	}
+
+	var output bytes.Buffer
+	cmd := prepareCancel(exec.CommandContext(ctx, tfBin, append([]string{"init", "-input=false"}, initArgs...)...))
+	cmd.Dir = workDir
+	cmd.Env = append(filteredEnv(),
+		fmt.Sprintf("TF_DATA_DIR=%s", dataDir),
+		fmt.Sprintf("TF_PLUGIN_CACHE_DIR=%s", pluginCacheDir),
+	)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	w.Fingerprint = ""
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("terraform init failed: %w\n%s", err, strings.TrimSpace(output.String()))
+	}
+	w.Fingerprint = fingerprint
+	w.saveLockFile(workDir)
+	if sharedDir != "" {
+		publishPluginCache(pluginCacheDir, sharedDir)
+	}
+	return nil
+}
+
+// CopyWorkspace copies the scan workspace src to dst without its git
+// directory, for a warm init that must not write into src.
+func CopyWorkspace(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case entry.IsDir() && entry.Name() == ".git" && rel != ".":
+			return filepath.SkipDir
+		case entry.IsDir():
+			return os.MkdirAll(target, 0755)
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case entry.Type().IsRegular():
+			return copyFile(path, target)
+		default:
+			return nil
+		}
+	})
+}
+
+// initFingerprint hashes what terraform init depends on: the terraform
+// version, the init arguments and the stack's own configuration and lock
+// file. Changes in local modules are not covered; a warm plan that fails
+// is retried after a fresh init.
+func initFingerprint(workDir, tfVersion string, initArgs []string) string {
+	h := sha256.New()
+	io.WriteString(h, tfVersion+"\x00"+strings.Join(initArgs, "\x00")+"\x00")
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		return ""
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.Type().IsRegular() && (strings.HasSuffix(name, ".tf") || strings.HasSuffix(name, ".tf.json") || name == providerLockFile) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(workDir, name))
+		if err != nil {
+			return ""
+		}
+		io.WriteString(h, name+"\x00")
+		h.Write(data)
+		io.WriteString(h, "\x00")
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
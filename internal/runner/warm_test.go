@@ -0,0 +1,149 @@
+package runner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunPlanWithWarmWorkspace(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TF_PLUGIN_CACHE_DIR", filepath.Join(tmp, "plugin-cache"))
+	logPath := filepath.Join(tmp, "tf.log")
+	tfBin := filepath.Join(tmp, "terraform")
+
+	// Init marks the data dir and writes a lock file; plan needs the mark
+	// and fails while the data dir is marked stale.
+	script := `#!/bin/sh
+set -eu
+cmd="$1"
+echo "$cmd" >> "` + logPath + `"
+if [ "$cmd" = "init" ]; then
+  touch "$TF_DATA_DIR/initialized"
+  rm -f "$TF_DATA_DIR/stale"
+  echo lock > .terraform.lock.hcl
+  exit 0
+fi
+[ -f "$TF_DATA_DIR/initialized" ] || exit 1
+[ ! -f "$TF_DATA_DIR/stale" ] || exit 1
+[ -f .terraform.lock.hcl ] || exit 1
+echo "No changes."
+`
+	if err := os.WriteFile(tfBin, []byte(script), 0755); err != nil {
+		t.Fatalf("write terraform script: %v", err)
+	}
+
+	newWorkDir := func(name, config string) string {
+		dir := filepath.Join(tmp, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(config), 0644); err != nil {
+			t.Fatalf("write main.tf: %v", err)
+		}
+		return dir
+	}
+	warm := &WarmWorkspace{Dir: filepath.Join(tmp, "warm")}
+	plan := func(workDir string) string {
+		t.Helper()
+		if err := os.Truncate(logPath, 0); err != nil && !os.IsNotExist(err) {
+			t.Fatalf("truncate log: %v", err)
+		}
+		opts := planOptions{warm: warm, warmFingerprint: initFingerprint(workDir, "1.6.0", nil)}
+		if out, err := runPlan(context.Background(), workDir, "terraform", tfBin, "", tmp, "envs/app", "run", opts); err != nil {
+			t.Fatalf("runPlan: %v\n%s", err, out)
+		}
+		log, _ := os.ReadFile(logPath)
+		return strings.Join(strings.Fields(string(log)), ",")
+	}
+
+	if got := plan(newWorkDir("scan-1", "# v1\n")); got != "init,plan" || warm.Hit {
+		t.Fatalf("cold run = %s (hit %v), want init,plan", got, warm.Hit)
+	}
+	// A later scan with the same init inputs skips init and gets the lock
+	// file the warm init wrote.
+	if got := plan(newWorkDir("scan-2", "# v1\n")); got != "plan" || !warm.Hit {
+		t.Fatalf("warm run = %s (hit %v), want plan", got, warm.Hit)
+	}
+	// Changed configuration initializes the workspace again.
+	if got := plan(newWorkDir("scan-3", "# v2\n")); got != "init,plan" || warm.Hit {
+		t.Fatalf("changed run = %s (hit %v), want init,plan", got, warm.Hit)
+	}
+	// A warm plan that fails is retried after a fresh init.
+	if err := os.WriteFile(filepath.Join(warm.dataDir(), "stale"), nil, 0644); err != nil {
+		t.Fatalf("mark stale: %v", err)
+	}
+	if got := plan(newWorkDir("scan-4", "# v2\n")); got != "plan,init,plan" || warm.Hit {
+		t.Fatalf("stale run = %s (hit %v), want plan,init,plan", got, warm.Hit)
+	}
+}
+
+func TestInitWarmInWorkspaceCopy(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TF_PLUGIN_CACHE_DIR", filepath.Join(tmp, "plugin-cache"))
+	logPath := filepath.Join(tmp, "tf.log")
+	tfBin := filepath.Join(tmp, "terraform")
+	script := `#!/bin/sh
+set -eu
+echo "$1" >> "` + logPath + `"
+touch "$TF_DATA_DIR/initialized"
+echo lock > .terraform.lock.hcl
+`
+	if err := os.WriteFile(tfBin, []byte(script), 0755); err != nil {
+		t.Fatalf("write terraform script: %v", err)
+	}
+
+	src := filepath.Join(tmp, "scan", "project")
+	for path, content := range map[string]string{
+		"envs/app/main.tf":  "# v1\n",
+		"modules/m/main.tf": "# module\n",
+		".git/HEAD":         "ref: refs/heads/main\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(src, path)), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(src, path), []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	dst := filepath.Join(tmp, "copy")
+	if err := CopyWorkspace(src, dst); err != nil {
+		t.Fatalf("copy workspace: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "modules", "m", "main.tf")); err != nil {
+		t.Fatalf("expected module in copy: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, ".git")); !os.IsNotExist(err) {
+		t.Fatalf("expected copy without .git, got %v", err)
+	}
+
+	warm := &WarmWorkspace{Dir: filepath.Join(tmp, "warm")}
+	workDir := filepath.Join(dst, "envs", "app")
+	fingerprint := initFingerprint(workDir, "1.6.0", nil)
+	if err := initWarm(context.Background(), warm, workDir, tfBin, fingerprint, nil); err != nil {
+		t.Fatalf("initWarm: %v", err)
+	}
+	if warm.Fingerprint != fingerprint {
+		t.Fatalf("fingerprint = %q, want %q", warm.Fingerprint, fingerprint)
+	}
+	if _, err := os.Stat(filepath.Join(warm.dataDir(), "initialized")); err != nil {
+		t.Fatalf("expected init in the warm data dir: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(src, "envs", "app", providerLockFile)); !os.IsNotExist(err) {
+		t.Fatalf("expected the scan workspace untouched, got %v", err)
+	}
+
+	// A scan of the stack at the same inputs now plans without init.
+	if err := os.Truncate(logPath, 0); err != nil {
+		t.Fatalf("truncate log: %v", err)
+	}
+	opts := planOptions{warm: warm, warmFingerprint: initFingerprint(filepath.Join(src, "envs", "app"), "1.6.0", nil)}
+	if out, err := runPlan(context.Background(), filepath.Join(src, "envs", "app"), "terraform", tfBin, "", src, "envs/app", "run", opts); err != nil {
+		t.Fatalf("runPlan: %v\n%s", err, out)
+	}
+	if log, _ := os.ReadFile(logPath); strings.TrimSpace(string(log)) != "plan" || !warm.Hit {
+		t.Fatalf("run after warm init = %q (hit %v), want plan", log, warm.Hit)
+	}
+}
@@ -0,0 +1,251 @@
+// Package scaneta estimates when queued stack scans will start and when a
+// scan will finish.
+//
+// Each stack is expected to take as long as its recent completed runs. The
+// queue is replayed in claim order over the concurrency slots of the
+// workers that are not draining, starting from the stack scans running now.
+// Weights are not modelled: every stack scan takes one slot.
+package scaneta
+
+import (
+	"container/heap"
+	"context"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+const (
+	// historyLimit is how many recent stack scans per project are read for
+	// durations.
+	historyLimit = 200
+	// stackSamples is how many of a stack's latest runs are averaged.
+	stackSamples = 5
+	// DefaultStackDuration is assumed for stacks of projects with no
+	// completed runs to go by.
+	DefaultStackDuration = 3 * time.Minute
+)
+
+// Stack is the estimate for one unfinished stack scan.
+type Stack struct {
+	StackScanID string
+	StackPath   string
+	Status      string
+	// Position counts the pending stack scans ahead of this one. It is
+	// zero for running stack scans.
+	Position int
+	// Duration is how long the stack is expected to take in total.
+	Duration time.Duration
+	// StartsAt and FinishesAt are zero when no worker can take the stack.
+	StartsAt   time.Time
+	FinishesAt time.Time
+}
+
+// Scan is the estimate for a scan's unfinished stack scans.
+type Scan struct {
+	ScanID string
+	Status string
+	// Slots is the concurrency of the workers that are not draining.
+	Slots int
+	// Pending counts every stack scan waiting in the queue.
+	Pending int
+	// FinishesAt is when the last of the scan's stacks is expected to
+	// finish. It is zero when the scan has ended or no worker is available.
+	FinishesAt time.Time
+	Stacks     []Stack
+}
+
+// Estimator computes scan ETAs from a queue backend.
+type Estimator struct {
+	queue queue.Backend
+	now   func() time.Time
+}
+
+// New returns an Estimator reading from q.
+func New(q queue.Backend) *Estimator {
+	return &Estimator{queue: q, now: time.Now}
+}
+
+// Scan estimates when scan's unfinished stack scans start and finish.
+func (e *Estimator) Scan(ctx context.Context, scan *queue.Scan) (*Scan, error) {
+	out := &Scan{ScanID: scan.ID, Status: scan.Status}
+	if scan.Status != queue.ScanStatusRunning {
+		return out, nil
+	}
+	own, err := e.queue.ListScanStackScans(ctx, scan.ID)
+	if err != nil {
+		return nil, err
+	}
+	running, err := e.queue.ListRunningStackScans(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := e.queue.ListPendingStackScans(ctx)
+	if err != nil {
+		return nil, err
+	}
+	workers, err := e.queue.ListWorkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out.Pending = len(pending)
+	for _, w := range workers {
+		if !w.Draining {
+			out.Slots += w.Concurrency
+		}
+	}
+
+	// Stack scans queued after the scan's last one cannot delay it.
+	last := -1
+	for i, ss := range pending {
+		if ss.ScanID == scan.ID {
+			last = i
+		}
+	}
+	pending = pending[:last+1]
+
+	durations := newHistory(e.queue)
+	now := e.now()
+	estimates := make(map[string]Stack, len(running)+len(pending))
+
+	remaining := make([]time.Duration, 0, len(running))
+	for _, ss := range running {
+		d, err := durations.estimate(ctx, ss.ProjectName, ss.StackPath)
+		if err != nil {
+			return nil, err
+		}
+		// A stack running past its estimate is assumed about to finish.
+		left := max(d-now.Sub(ss.StartedAt), 0)
+		remaining = append(remaining, left)
+		estimates[ss.ID] = Stack{Duration: d, StartsAt: ss.StartedAt, FinishesAt: now.Add(left)}
+	}
+
+	if out.Slots > 0 {
+		// Each slot frees up when one of the running stacks finishes. With
+		// more running than slots, e.g. after a worker started draining,
+		// the first pending stack waits for the surplus to finish too.
+		slices.Sort(remaining)
+		if surplus := len(remaining) - out.Slots; surplus > 0 {
+			remaining = remaining[surplus:]
+		}
+		slots := make(slotHeap, out.Slots)
+		copy(slots, remaining)
+		heap.Init(&slots)
+		for i, ss := range pending {
+			d, err := durations.estimate(ctx, ss.ProjectName, ss.StackPath)
+			if err != nil {
+				return nil, err
+			}
+			start := heap.Pop(&slots).(time.Duration)
+			heap.Push(&slots, start+d)
+			estimates[ss.ID] = Stack{Position: i, Duration: d, StartsAt: now.Add(start), FinishesAt: now.Add(start + d)}
+		}
+	}
+
+	for _, ss := range own {
+		if ss.Status != queue.StatusPending && ss.Status != queue.StatusRunning {
+			continue
+		}
+		// Stacks of a paused project, or any stack when no worker is
+		// available, keep a zero StartsAt and FinishesAt.
+		est := estimates[ss.ID]
+		est.StackScanID = ss.ID
+		est.StackPath = ss.StackPath
+		est.Status = ss.Status
+		out.Stacks = append(out.Stacks, est)
+	}
+	sort.SliceStable(out.Stacks, func(i, j int) bool {
+		a, b := out.Stacks[i], out.Stacks[j]
+		if a.Status != b.Status {
+			return a.Status == queue.StatusRunning
+		}
+		if a.StartsAt.IsZero() != b.StartsAt.IsZero() {
+			return !a.StartsAt.IsZero()
+		}
+		return a.Position < b.Position
+	})
+
+	for _, st := range out.Stacks {
+		if st.FinishesAt.IsZero() {
+			out.FinishesAt = time.Time{}
+			break
+		}
+		if st.FinishesAt.After(out.FinishesAt) {
+			out.FinishesAt = st.FinishesAt
+		}
+	}
+	return out, nil
+}
+
+// history estimates stack durations from recent completed runs, reading
+// each project's history once.
+type history struct {
+	queue    queue.Backend
+	projects map[string]*projectHistory
+}
+
+type projectHistory struct {
+	// stacks holds each stack's durations, newest first.
+	stacks map[string][]time.Duration
+	median time.Duration
+}
+
+func newHistory(q queue.Backend) *history {
+	return &history{queue: q, projects: make(map[string]*projectHistory)}
+}
+
+// estimate is the mean of the stack's latest runs, the project's median
+// run when the stack has none, or DefaultStackDuration.
+func (h *history) estimate(ctx context.Context, projectName, stackPath string) (time.Duration, error) {
+	p, ok := h.projects[projectName]
+	if !ok {
+		stackScans, err := h.queue.ListProjectStackScans(ctx, projectName, historyLimit)
+		if err != nil {
+			return 0, err
+		}
+		p = &projectHistory{stacks: make(map[string][]time.Duration)}
+		var all []time.Duration
+		for _, ss := range stackScans {
+			if ss.Status != queue.StatusCompleted || ss.StartedAt.IsZero() || ss.CompletedAt.Before(ss.StartedAt) {
+				continue
+			}
+			d := ss.CompletedAt.Sub(ss.StartedAt)
+			p.stacks[ss.StackPath] = append(p.stacks[ss.StackPath], d)
+			all = append(all, d)
+		}
+		if len(all) > 0 {
+			slices.Sort(all)
+			p.median = all[len(all)/2]
+		}
+		h.projects[projectName] = p
+	}
+
+	if runs := p.stacks[stackPath]; len(runs) > 0 {
+		runs = runs[:min(len(runs), stackSamples)]
+		var total time.Duration
+		for _, d := range runs {
+			total += d
+		}
+		return total / time.Duration(len(runs)), nil
+	}
+	if p.median > 0 {
+		return p.median, nil
+	}
+	return DefaultStackDuration, nil
+}
+
+// slotHeap holds the offsets from now at which worker slots free up.
+type slotHeap []time.Duration
+
+func (h slotHeap) Len() int           { return len(h) }
+func (h slotHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h slotHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *slotHeap) Push(x any)        { *h = append(*h, x.(time.Duration)) }
+func (h *slotHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,104 @@
+package scaneta
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+// fakeQueue serves the listings the estimator reads; other Backend methods
+// are not called.
+type fakeQueue struct {
+	queue.Backend
+	own     []*queue.StackScan
+	running []*queue.StackScan
+	pending []*queue.StackScan
+	history map[string][]*queue.StackScan
+	workers []queue.WorkerInfo
+}
+
+func (f *fakeQueue) ListScanStackScans(context.Context, string) ([]*queue.StackScan, error) {
+	return f.own, nil
+}
+
+func (f *fakeQueue) ListRunningStackScans(context.Context) ([]*queue.StackScan, error) {
+	return f.running, nil
+}
+
+func (f *fakeQueue) ListPendingStackScans(context.Context) ([]*queue.StackScan, error) {
+	return f.pending, nil
+}
+
+func (f *fakeQueue) ListWorkers(context.Context) ([]queue.WorkerInfo, error) {
+	return f.workers, nil
+}
+
+func (f *fakeQueue) ListProjectStackScans(_ context.Context, project string, _ int) ([]*queue.StackScan, error) {
+	return f.history[project], nil
+}
+
+func completed(path string, d time.Duration) *queue.StackScan {
+	start := time.Unix(1000, 0)
+	return &queue.StackScan{StackPath: path, Status: queue.StatusCompleted, StartedAt: start, CompletedAt: start.Add(d)}
+}
+
+func TestEstimateScan(t *testing.T) {
+	now := time.Unix(100000, 0)
+	running := &queue.StackScan{ID: "r1", ScanID: "other", ProjectName: "infra", StackPath: "net", Status: queue.StatusRunning, StartedAt: now.Add(-4 * time.Minute)}
+	mine := []*queue.StackScan{
+		{ID: "p1", ScanID: "scan", ProjectName: "app", StackPath: "api", Status: queue.StatusPending},
+		{ID: "p2", ScanID: "scan", ProjectName: "app", StackPath: "web", Status: queue.StatusPending},
+		{ID: "done", ScanID: "scan", ProjectName: "app", StackPath: "db", Status: queue.StatusCompleted},
+	}
+	other := &queue.StackScan{ID: "x", ScanID: "other", ProjectName: "infra", StackPath: "dns", Status: queue.StatusPending}
+	f := &fakeQueue{
+		own:     mine,
+		running: []*queue.StackScan{running},
+		// The other scan's dns stack is ahead of web; the one after does
+		// not count.
+		pending: []*queue.StackScan{mine[0], other, mine[1], {ID: "later", ProjectName: "infra", StackPath: "late", Status: queue.StatusPending}},
+		history: map[string][]*queue.StackScan{
+			"infra": {completed("net", 10*time.Minute), completed("net", 6*time.Minute), completed("dns", 2*time.Minute)},
+			// web has no runs and uses the project median.
+			"app": {completed("api", 5*time.Minute), completed("db", time.Minute), completed("db", 3*time.Minute)},
+		},
+		workers: []queue.WorkerInfo{{Concurrency: 2}, {Concurrency: 4, Draining: true}},
+	}
+	e := New(f)
+	e.now = func() time.Time { return now }
+
+	eta, err := e.Scan(context.Background(), &queue.Scan{ID: "scan", Status: queue.ScanStatusRunning})
+	if err != nil {
+		t.Fatalf("estimate: %v", err)
+	}
+	if eta.Slots != 2 || eta.Pending != 4 || len(eta.Stacks) != 2 {
+		t.Fatalf("unexpected estimate: %+v", eta)
+	}
+	// Slots: net has 4m left (8m mean), the other is free. api starts now
+	// and takes 5m; dns waits for net (4m..6m); web (median 3m) starts
+	// when api frees at 5m.
+	api, web := eta.Stacks[0], eta.Stacks[1]
+	if api.StackPath != "api" || api.Position != 0 || !api.StartsAt.Equal(now) || api.Duration != 5*time.Minute {
+		t.Fatalf("unexpected api estimate: %+v", api)
+	}
+	if web.StackPath != "web" || web.Position != 2 || !web.StartsAt.Equal(now.Add(5*time.Minute)) || web.Duration != 3*time.Minute {
+		t.Fatalf("unexpected web estimate: %+v", web)
+	}
+	if !eta.FinishesAt.Equal(now.Add(8 * time.Minute)) {
+		t.Fatalf("finishes at %s, want +8m", eta.FinishesAt.Sub(now))
+	}
+}
+
+func TestEstimateScanWithoutWorkers(t *testing.T) {
+	ss := &queue.StackScan{ID: "p1", ScanID: "scan", ProjectName: "app", StackPath: "api", Status: queue.StatusPending}
+	f := &fakeQueue{own: []*queue.StackScan{ss}, pending: []*queue.StackScan{ss}}
+	eta, err := New(f).Scan(context.Background(), &queue.Scan{ID: "scan", Status: queue.ScanStatusRunning})
+	if err != nil {
+		t.Fatalf("estimate: %v", err)
+	}
+	if !eta.FinishesAt.IsZero() || len(eta.Stacks) != 1 || !eta.Stacks[0].StartsAt.IsZero() || eta.Stacks[0].Duration != 0 {
+		t.Fatalf("expected no ETA without workers: %+v", eta)
+	}
+}
@@ -0,0 +1,197 @@
+// Package scanlimit caps how many scans may start per trigger within a
+// clock hour, per project and across all projects, so runaway automation
+// cannot flood the queue.
+//
+// Limits come from the config file. Overrides saved through the settings
+// API live in a file under the data directory and replace the configured
+// limit of the triggers they name. Counters live in the queue backend so
+// every replica enforces the same budget.
+package scanlimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+// Window is the period limits apply to. Counters reset on the hour.
+const Window = time.Hour
+
+const settingsFileName = "scan_limits.json"
+
+// Settings are limit overrides edited at runtime. A 0 lifts a configured
+// limit for that trigger.
+type Settings struct {
+	Global   map[string]int            `json:"global,omitempty"`
+	Projects map[string]map[string]int `json:"projects,omitempty"`
+}
+
+// Validate reports the first negative or unnamed limit.
+func (s Settings) Validate() error {
+	if err := config.ValidateScanLimits(s.Global); err != nil {
+		return fmt.Errorf("global: %w", err)
+	}
+	for project, limits := range s.Projects {
+		if err := config.ValidateScanLimits(limits); err != nil {
+			return fmt.Errorf("projects.%s: %w", project, err)
+		}
+	}
+	return nil
+}
+
+// Error is returned when a scan start would exceed a limit.
+type Error struct {
+	// Project is empty when the global limit was reached.
+	Project string
+	Trigger string
+	Limit   int
+	ResetAt time.Time
+}
+
+func (e *Error) Error() string {
+	scope := "global"
+	if e.Project != "" {
+		scope = "project " + e.Project
+	}
+	return fmt.Sprintf("%s scan limit reached: at most %d %s scans per hour; resets at %s",
+		scope, e.Limit, e.Trigger, e.ResetAt.UTC().Format(time.RFC3339))
+}
+
+// Limiter enforces the limits. The settings file is re-read on every call so
+// an override saved through one server applies to all of them.
+type Limiter struct {
+	cfg  *config.Config
+	q    queue.Backend
+	path string
+	mu   sync.Mutex
+	now  func() time.Time
+}
+
+// New returns a Limiter using the limits in cfg, the overrides stored in
+// cfg.DataDir and counters in q.
+func New(cfg *config.Config, q queue.Backend) *Limiter {
+	return &Limiter{
+		cfg:  cfg,
+		q:    q,
+		path: filepath.Join(cfg.DataDir, settingsFileName),
+		now:  time.Now,
+	}
+}
+
+// Settings returns the saved overrides.
+func (l *Limiter) Settings() (Settings, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	raw, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Settings{}, nil
+		}
+		return Settings{}, fmt.Errorf("failed to read scan limits: %w", err)
+	}
+	var s Settings
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return Settings{}, fmt.Errorf("failed to parse scan limits: %w", err)
+	}
+	return s, nil
+}
+
+// SetSettings replaces the saved overrides.
+func (l *Limiter) SetSettings(s Settings) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal scan limits: %w", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(l.path), 0750); err != nil {
+		return fmt.Errorf("failed to create data directory: %w", err)
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0600); err != nil {
+		return fmt.Errorf("failed to write scan limits: %w", err)
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// Effective returns the global limits and those of project, with overrides
+// applied on top of the config file.
+func (l *Limiter) Effective(project *config.ProjectConfig) (global, perProject map[string]int) {
+	s, err := l.Settings()
+	if err != nil {
+		log.Printf("Ignoring scan limit overrides: %v", err)
+	}
+	global = merge(l.cfg.ScanLimits.PerHour, s.Global)
+	if project != nil {
+		perProject = merge(project.ScanLimits.PerHour, s.Projects[project.Name])
+	}
+	return global, perProject
+}
+
+// Reserve counts a scan start for project and trigger against every limit in
+// force. The returned release gives the start back when the scan does not
+// start after all.
+func (l *Limiter) Reserve(ctx context.Context, project *config.ProjectConfig, trigger string) (release func(), err error) {
+	global, perProject := l.Effective(project)
+
+	now := l.now()
+	windowStart := now.Truncate(Window)
+	resetAt := windowStart.Add(Window)
+	ttl := resetAt.Sub(now) + time.Minute
+
+	var acquired []string
+	release = func() {
+		for _, key := range acquired {
+			if err := l.q.ReleaseScanStart(context.Background(), key); err != nil {
+				log.Printf("Failed to release scan start %s: %v", key, err)
+			}
+		}
+	}
+	acquire := func(scope string, limit int, projectName string) error {
+		if limit <= 0 {
+			return nil
+		}
+		key := fmt.Sprintf("%s:%s:%d", scope, trigger, windowStart.Unix())
+		ok, err := l.q.AcquireScanStart(ctx, key, limit, ttl)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return &Error{Project: projectName, Trigger: trigger, Limit: limit, ResetAt: resetAt}
+		}
+		acquired = append(acquired, key)
+		return nil
+	}
+
+	if err := acquire("project:"+project.Name, perProject[trigger], project.Name); err != nil {
+		release()
+		return nil, err
+	}
+	if err := acquire("global", global[trigger], ""); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+func merge(base, overrides map[string]int) map[string]int {
+	out := make(map[string]int, len(base)+len(overrides))
+	for trigger, limit := range base {
+		out[trigger] = limit
+	}
+	for trigger, limit := range overrides {
+		out[trigger] = limit
+	}
+	return out
+}
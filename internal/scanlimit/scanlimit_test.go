@@ -0,0 +1,97 @@
+package scanlimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+func newTestLimiter(t *testing.T, cfg *config.Config) *Limiter {
+	t.Helper()
+	q := queue.NewMemory(2 * time.Minute)
+	t.Cleanup(func() { _ = q.Close() })
+	cfg.DataDir = t.TempDir()
+	l := New(cfg, q)
+	l.now = func() time.Time { return time.Date(2026, 3, 1, 10, 20, 0, 0, time.UTC) }
+	return l
+}
+
+func TestReserve(t *testing.T) {
+	ctx := context.Background()
+	project := &config.ProjectConfig{
+		Name:       "infra",
+		ScanLimits: config.ScanLimitsConfig{PerHour: map[string]int{"manual": 2}},
+	}
+	other := &config.ProjectConfig{Name: "other"}
+	l := newTestLimiter(t, &config.Config{
+		ScanLimits: config.ScanLimitsConfig{PerHour: map[string]int{"manual": 3}},
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := l.Reserve(ctx, project, "manual"); err != nil {
+			t.Fatalf("reserve %d: %v", i, err)
+		}
+	}
+	_, err := l.Reserve(ctx, project, "manual")
+	var limitErr *Error
+	if !errors.As(err, &limitErr) {
+		t.Fatalf("expected project limit error, got %v", err)
+	}
+	if limitErr.Project != "infra" || limitErr.Limit != 2 {
+		t.Fatalf("unexpected limit error: %+v", limitErr)
+	}
+	if want := time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC); !limitErr.ResetAt.Equal(want) {
+		t.Fatalf("expected reset at %s, got %s", want, limitErr.ResetAt)
+	}
+
+	// Other triggers are not limited.
+	if _, err := l.Reserve(ctx, project, "webhook"); err != nil {
+		t.Fatalf("reserve webhook: %v", err)
+	}
+
+	// The third manual start overall reaches the global limit.
+	release, err := l.Reserve(ctx, other, "manual")
+	if err != nil {
+		t.Fatalf("reserve other: %v", err)
+	}
+	if _, err := l.Reserve(ctx, other, "manual"); !errors.As(err, &limitErr) || limitErr.Project != "" {
+		t.Fatalf("expected global limit error, got %v", err)
+	}
+	release()
+	if _, err := l.Reserve(ctx, other, "manual"); err != nil {
+		t.Fatalf("expected released start to be reusable: %v", err)
+	}
+}
+
+func TestSettingsOverrideConfig(t *testing.T) {
+	ctx := context.Background()
+	project := &config.ProjectConfig{
+		Name:       "infra",
+		ScanLimits: config.ScanLimitsConfig{PerHour: map[string]int{"manual": 1}},
+	}
+	l := newTestLimiter(t, &config.Config{})
+
+	if err := l.SetSettings(Settings{Projects: map[string]map[string]int{"infra": {"manual": 0}}}); err != nil {
+		t.Fatalf("set settings: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := l.Reserve(ctx, project, "manual"); err != nil {
+			t.Fatalf("expected override to lift the limit: %v", err)
+		}
+	}
+
+	if err := l.SetSettings(Settings{Global: map[string]int{"manual": -1}}); err == nil {
+		t.Fatalf("expected negative limit to be rejected")
+	}
+	s, err := l.Settings()
+	if err != nil {
+		t.Fatalf("settings: %v", err)
+	}
+	if _, ok := s.Projects["infra"]["manual"]; !ok || s.Global != nil {
+		t.Fatalf("expected previous overrides to be kept, got %+v", s)
+	}
+}
@@ -0,0 +1,148 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+// LeaderRole names the lease replicas compete for to fire cron triggers.
+const LeaderRole = "scheduler"
+
+// Elector holds the scheduler leader lease for this replica. Every replica
+// keeps its cron entries; only the lease holder starts scans, so a replica
+// that stops renewing hands scheduling to the next one to acquire the lease
+// within one TTL.
+type Elector struct {
+	queue queue.Backend
+	id    string
+	ttl   time.Duration
+
+	leading atomic.Bool
+	since   atomic.Int64
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewElector returns an elector identified by the host name and process ID.
+func NewElector(q queue.Backend, ttl time.Duration) *Elector {
+	hostname, _ := os.Hostname()
+	return &Elector{
+		queue: q,
+		id:    fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		ttl:   ttl,
+		stop:  make(chan struct{}),
+	}
+}
+
+// ID identifies this replica in the lease.
+func (e *Elector) ID() string {
+	return e.id
+}
+
+// IsLeader reports whether this replica holds the lease. A nil elector is
+// always the leader, for single-replica setups.
+func (e *Elector) IsLeader() bool {
+	return e == nil || e.leading.Load()
+}
+
+// Start tries to take the lease immediately, then renews or retries at a
+// third of the TTL.
+func (e *Elector) Start() {
+	e.tick()
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		ticker := time.NewTicker(e.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+				e.tick()
+			}
+		}
+	}()
+}
+
+// Stop releases the lease so another replica can take over without waiting
+// for it to expire.
+func (e *Elector) Stop() {
+	close(e.stop)
+	e.wg.Wait()
+	if e.leading.Swap(false) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := e.queue.ReleaseLeaderLease(ctx, LeaderRole, e.id); err != nil && !errors.Is(err, queue.ErrLeaderLeaseNotOwned) {
+			log.Printf("Failed to release scheduler leadership: %v", err)
+		}
+	}
+}
+
+func (e *Elector) tick() {
+	ctx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+	defer cancel()
+
+	if e.leading.Load() {
+		err := e.queue.RenewLeaderLease(ctx, LeaderRole, e.id, e.ttl)
+		if err == nil {
+			return
+		}
+		// Stop scheduling even on transient errors: the lease may expire
+		// before the next renewal and another replica take over.
+		e.leading.Store(false)
+		log.Printf("Lost scheduler leadership: %v", err)
+	}
+
+	acquired, err := e.queue.AcquireLeaderLease(ctx, LeaderRole, e.id, e.ttl)
+	if err != nil {
+		log.Printf("Failed to acquire scheduler leadership: %v", err)
+		return
+	}
+	if acquired {
+		e.since.Store(time.Now().UnixMilli())
+		e.leading.Store(true)
+		log.Printf("Became scheduler leader (%s)", e.id)
+	}
+}
+
+// LeaderStatus describes scheduler leadership as seen by one replica.
+type LeaderStatus struct {
+	Leader    string     `json:"leader"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Replica   string     `json:"replica"`
+	IsLeader  bool       `json:"is_leader"`
+	// LeaderSince is set when this replica is the leader.
+	LeaderSince *time.Time `json:"leader_since,omitempty"`
+}
+
+// Status reads the current lease holder from the queue. Leader is empty while
+// no replica holds the lease.
+func (e *Elector) Status(ctx context.Context) (LeaderStatus, error) {
+	status := LeaderStatus{Replica: e.id, IsLeader: e.leading.Load()}
+	if status.IsLeader {
+		since := time.UnixMilli(e.since.Load())
+		status.LeaderSince = &since
+	}
+	lease, err := e.queue.GetLeaderLease(ctx, LeaderRole)
+	if err != nil {
+		return status, err
+	}
+	if lease != nil {
+		status.Leader = lease.Owner
+		if !lease.ExpiresAt.IsZero() {
+			expires := lease.ExpiresAt
+			status.ExpiresAt = &expires
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/projects"
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+func TestElectorTakesOverFromLostLeader(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	q, err := queue.New(mr.Addr(), "", 0, time.Minute)
+	if err != nil {
+		t.Fatalf("queue: %v", err)
+	}
+	defer q.Close()
+
+	a := &Elector{queue: q, id: "replica-a", ttl: 3 * time.Second, stop: make(chan struct{})}
+	b := &Elector{queue: q, id: "replica-b", ttl: 3 * time.Second, stop: make(chan struct{})}
+	a.tick()
+	b.tick()
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("expected replica-a to lead, got a=%v b=%v", a.IsLeader(), b.IsLeader())
+	}
+
+	// replica-a stops renewing, e.g. because its process hung.
+	mr.FastForward(4 * time.Second)
+	b.tick()
+	if !b.IsLeader() {
+		t.Fatalf("expected replica-b to take over the expired lease")
+	}
+	a.tick()
+	if a.IsLeader() {
+		t.Fatalf("expected replica-a to notice it lost the lease")
+	}
+
+	status, err := a.Status(context.Background())
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.Leader != "replica-b" || status.Replica != "replica-a" || status.IsLeader {
+		t.Fatalf("unexpected status %+v", status)
+	}
+}
+
+func TestElectorStopReleasesLease(t *testing.T) {
+	q := newTestQueue(t)
+	a := NewElector(q, 3*time.Second)
+	a.Start()
+	if !a.IsLeader() {
+		t.Fatalf("expected the only replica to lead")
+	}
+	a.Stop()
+
+	lease, err := q.GetLeaderLease(context.Background(), LeaderRole)
+	if err != nil || lease != nil {
+		t.Fatalf("expected the lease to be released, got %+v (%v)", lease, err)
+	}
+}
+
+func TestSchedulerSkipsScansWhenNotLeader(t *testing.T) {
+	q := newTestQueue(t)
+	// The project name hashes to a jitter of a few milliseconds.
+	cfg := &config.Config{
+		DataDir: t.TempDir(),
+		Projects: []config.ProjectConfig{
+			{Name: "maint-633", URL: "https://github.com/org/project.git", Schedule: "0 * * * *"},
+		},
+	}
+	if ok, err := q.AcquireLeaderLease(context.Background(), LeaderRole, "other-replica", time.Minute); err != nil || !ok {
+		t.Fatalf("acquire: %v %v", ok, err)
+	}
+	provider := &countingProvider{Provider: projects.NewCombinedProvider(cfg, nil, nil, cfg.DataDir)}
+	elector := NewElector(q, 3*time.Second)
+	elector.tick()
+
+	s := New(cfg, provider, newTestOrchestrator(cfg, q))
+	s.SetElector(elector)
+	s.enqueueProjectScans("maint-633")
+
+	if provider.gets != 0 {
+		t.Fatalf("expected follower to skip the scheduled scan, provider called %d times", provider.gets)
+	}
+}
@@ -2,18 +2,24 @@ package scheduler
 
 import (
 	"context"
+	"errors"
 	"hash/fnv"
 	"log"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/maintenance"
 	"github.com/driftdhq/driftd/internal/orchestrate"
 	"github.com/driftdhq/driftd/internal/projects"
 	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/scanlimit"
 	"github.com/robfig/cron/v3"
 )
 
+// scheduledScanMaxJitter applies when scheduler.max_jitter is unset, as in
+// configs built without config.Load.
 const scheduledScanMaxJitter = 20 * time.Second
 
 type Scheduler struct {
@@ -21,9 +27,16 @@ type Scheduler struct {
 	cfg          *config.Config
 	provider     projects.Provider
 	orchestrator *orchestrate.ScanOrchestrator
+	maintenance  *maintenance.Mode
+	elector      *Elector
+	pauses       queue.Backend
 
-	mu      sync.Mutex
-	entries map[string]cron.EntryID
+	mu        sync.Mutex
+	entries   map[string]cron.EntryID
+	schedules map[string]cron.Schedule
+
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
 func New(cfg *config.Config, provider projects.Provider, orch *orchestrate.ScanOrchestrator) *Scheduler {
@@ -33,9 +46,30 @@ func New(cfg *config.Config, provider projects.Provider, orch *orchestrate.ScanO
 		provider:     provider,
 		orchestrator: orch,
 		entries:      make(map[string]cron.EntryID),
+		schedules:    make(map[string]cron.Schedule),
+		stop:         make(chan struct{}),
 	}
 }
 
+// SetMaintenance pauses scheduled scans while m reports a maintenance window.
+// Call it before Start.
+func (s *Scheduler) SetMaintenance(m *maintenance.Mode) {
+	s.maintenance = m
+}
+
+// SetPauses skips scheduled scans of projects paused in q. Call it before
+// Start.
+func (s *Scheduler) SetPauses(q queue.Backend) {
+	s.pauses = q
+}
+
+// SetElector makes the scheduler start scans only while e holds the leader
+// lease. Without an elector every replica starts scans. Call it before
+// Start.
+func (s *Scheduler) SetElector(e *Elector) {
+	s.elector = e
+}
+
 func (s *Scheduler) Start() error {
 	projects, err := s.provider.List()
 	if err != nil {
@@ -54,7 +88,10 @@ func (s *Scheduler) Start() error {
 	return nil
 }
 
+// Stop ends the schedule. Scans still waiting out their stagger or jitter
+// are dropped rather than holding up shutdown.
 func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
 	ctx := s.cron.Stop()
 	<-ctx.Done()
 }
@@ -89,16 +126,19 @@ func (s *Scheduler) scheduleRepo(name, schedule string) error {
 	if entryID, ok := s.entries[name]; ok {
 		s.cron.Remove(entryID)
 		delete(s.entries, name)
+		delete(s.schedules, name)
 	}
 
-	projectName := name
-	entryID, err := s.cron.AddFunc(schedule, func() {
-		s.enqueueProjectScans(projectName)
-	})
+	sched, err := cron.ParseStandard(schedule)
 	if err != nil {
 		return err
 	}
+	projectName := name
+	entryID := s.cron.Schedule(sched, cron.FuncJob(func() {
+		s.enqueueProjectScans(projectName)
+	}))
 	s.entries[name] = entryID
+	s.schedules[name] = sched
 	log.Printf("Scheduled scans for %s: %s", name, schedule)
 	return nil
 }
@@ -110,18 +150,39 @@ func (s *Scheduler) unscheduleRepo(name string) {
 	if entryID, ok := s.entries[name]; ok {
 		s.cron.Remove(entryID)
 		delete(s.entries, name)
+		delete(s.schedules, name)
 		log.Printf("Removed schedule for %s", name)
 	}
 }
 
 func (s *Scheduler) enqueueProjectScans(projectName string) {
-	if delay := scheduledScanJitter(projectName); delay > 0 {
+	if delay := s.scheduledDelay(projectName, time.Now()); delay > 0 {
 		timer := time.NewTimer(delay)
 		defer timer.Stop()
-		<-timer.C
+		select {
+		case <-timer.C:
+		case <-s.stop:
+			return
+		}
+	}
+
+	// Checked after the delay so a replica that lost the lease meanwhile
+	// doesn't fire alongside the new leader.
+	if !s.elector.IsLeader() {
+		return
+	}
+	if s.maintenance.Active() {
+		log.Printf("Skipping scheduled scan for %s: maintenance mode", projectName)
+		return
 	}
 
 	ctx := context.Background()
+	if s.pauses != nil {
+		if pause, err := s.pauses.GetProjectPause(ctx, projectName); err == nil && pause != nil {
+			log.Printf("Skipping scheduled scan for %s: project paused", projectName)
+			return
+		}
+	}
 	projectCfg, err := s.provider.Get(projectName)
 	if err != nil || projectCfg == nil {
 		log.Printf("Failed to find project config for %s: %v", projectName, err)
@@ -130,8 +191,13 @@ func (s *Scheduler) enqueueProjectScans(projectName string) {
 
 	_, result, err := s.orchestrator.StartAndEnqueue(ctx, projectCfg, "scheduled", "", "")
 	if err != nil {
+		var limitErr *scanlimit.Error
 		if err == queue.ErrProjectLocked {
 			log.Printf("Skipping scheduled scan for %s: project already running", projectName)
+		} else if errors.As(err, &limitErr) {
+			log.Printf("Skipping scheduled scan for %s: %v", projectName, err)
+		} else if errors.Is(err, orchestrate.ErrNoStacksEnqueued) {
+			log.Printf("Skipping scheduled scan for %s: no stacks to scan", projectName)
 		} else {
 			log.Printf("Failed to start scan for %s: %v", projectName, err)
 		}
@@ -141,12 +207,58 @@ func (s *Scheduler) enqueueProjectScans(projectName string) {
 	log.Printf("Enqueued %d scheduled stacks for %s", len(result.StackIDs), projectName)
 }
 
-func scheduledScanJitter(projectName string) time.Duration {
-	if projectName == "" || scheduledScanMaxJitter <= 0 {
+// scheduledDelay is how long a scheduled scan of projectName fired at now
+// waits: its stagger slot plus its jitter.
+func (s *Scheduler) scheduledDelay(projectName string, now time.Time) time.Duration {
+	return s.staggerOffset(projectName, now.Truncate(time.Minute)) + scheduledScanJitter(projectName, s.maxJitter(projectName))
+}
+
+// maxJitter is the project's schedule_jitter, or scheduler.max_jitter.
+// Negative values turn jitter off.
+func (s *Scheduler) maxJitter(projectName string) time.Duration {
+	max := s.cfg.Scheduler.MaxJitter
+	if project := s.cfg.GetProject(projectName); project != nil && project.ScheduleJitter != 0 {
+		max = project.ScheduleJitter
+	}
+	if max == 0 {
+		max = scheduledScanMaxJitter
+	}
+	return max
+}
+
+// staggerOffset places projectName among the scheduled projects whose
+// schedules fire at tick, sorted by name, and spreads them evenly across
+// scheduler.stagger_window. A project firing alone starts at once.
+func (s *Scheduler) staggerOffset(projectName string, tick time.Time) time.Duration {
+	window := s.cfg.Scheduler.StaggerWindow
+	if window <= 0 {
+		return 0
+	}
+	s.mu.Lock()
+	cohort := make([]string, 0, len(s.schedules))
+	for name, sched := range s.schedules {
+		if sched.Next(tick.Add(-time.Second)).Equal(tick) {
+			cohort = append(cohort, name)
+		}
+	}
+	s.mu.Unlock()
+	if len(cohort) < 2 {
+		return 0
+	}
+	sort.Strings(cohort)
+	i := sort.SearchStrings(cohort, projectName)
+	if i == len(cohort) || cohort[i] != projectName {
+		return 0
+	}
+	return window * time.Duration(i) / time.Duration(len(cohort))
+}
+
+func scheduledScanJitter(projectName string, max time.Duration) time.Duration {
+	if projectName == "" || max <= 0 {
 		return 0
 	}
 
 	h := fnv.New64a()
 	_, _ = h.Write([]byte(projectName))
-	return time.Duration(h.Sum64() % uint64(scheduledScanMaxJitter))
+	return time.Duration(h.Sum64() % uint64(max))
 }
@@ -1,11 +1,13 @@
 package scheduler
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/maintenance"
 	"github.com/driftdhq/driftd/internal/orchestrate"
 	"github.com/driftdhq/driftd/internal/projects"
 	"github.com/driftdhq/driftd/internal/queue"
@@ -262,8 +264,8 @@ func TestSchedulerEmptyScheduleSkipped(t *testing.T) {
 }
 
 func TestScheduledScanJitterDeterministicAndBounded(t *testing.T) {
-	first := scheduledScanJitter("project-a")
-	second := scheduledScanJitter("project-a")
+	first := scheduledScanJitter("project-a", scheduledScanMaxJitter)
+	second := scheduledScanJitter("project-a", scheduledScanMaxJitter)
 
 	if first != second {
 		t.Fatalf("expected deterministic jitter, got %s and %s", first, second)
@@ -271,7 +273,7 @@ func TestScheduledScanJitterDeterministicAndBounded(t *testing.T) {
 	if first < 0 || first >= scheduledScanMaxJitter {
 		t.Fatalf("jitter out of range: %s", first)
 	}
-	if got := scheduledScanJitter(""); got != 0 {
+	if got := scheduledScanJitter("", scheduledScanMaxJitter); got != 0 {
 		t.Fatalf("expected no jitter for empty project name, got %s", got)
 	}
 }
@@ -287,9 +289,154 @@ func TestScheduledScanJitterVariesAcrossRepos(t *testing.T) {
 	}
 	seen := make(map[time.Duration]struct{}, len(projects))
 	for _, projectName := range projects {
-		seen[scheduledScanJitter(projectName)] = struct{}{}
+		seen[scheduledScanJitter(projectName, scheduledScanMaxJitter)] = struct{}{}
 	}
 	if len(seen) < 2 {
 		t.Fatalf("expected at least two distinct jitter buckets across projects, got %d", len(seen))
 	}
 }
+
+func TestScheduledScanMaxJitter(t *testing.T) {
+	cfg := &config.Config{
+		Scheduler: config.SchedulerConfig{MaxJitter: 5 * time.Minute},
+		Projects: []config.ProjectConfig{
+			{Name: "global", Schedule: "0 * * * *"},
+			{Name: "override", Schedule: "0 * * * *", ScheduleJitter: time.Minute},
+			{Name: "disabled", Schedule: "0 * * * *", ScheduleJitter: -1},
+		},
+	}
+	s := New(cfg, projects.NewCombinedProvider(cfg, nil, nil, cfg.DataDir), newTestOrchestrator(cfg, newTestQueue(t)))
+
+	if got := s.maxJitter("global"); got != 5*time.Minute {
+		t.Fatalf("expected global max jitter, got %s", got)
+	}
+	if got := s.maxJitter("override"); got != time.Minute {
+		t.Fatalf("expected project max jitter, got %s", got)
+	}
+	if got := scheduledScanJitter("disabled", s.maxJitter("disabled")); got != 0 {
+		t.Fatalf("expected no jitter for disabled project, got %s", got)
+	}
+
+	cfg.Scheduler.MaxJitter = 0
+	if got := s.maxJitter("global"); got != scheduledScanMaxJitter {
+		t.Fatalf("expected default max jitter, got %s", got)
+	}
+}
+
+func TestSchedulerStaggerOffsets(t *testing.T) {
+	cfg := &config.Config{
+		Scheduler: config.SchedulerConfig{StaggerWindow: time.Hour},
+		Projects: []config.ProjectConfig{
+			{Name: "a", Schedule: "0 * * * *"},
+			{Name: "b", Schedule: "0 * * * *"},
+			{Name: "c", Schedule: "0 * * * *"},
+			{Name: "d", Schedule: "0 * * * *"},
+			{Name: "e", Schedule: "30 * * * *"},
+		},
+	}
+	s := New(cfg, projects.NewCombinedProvider(cfg, nil, nil, cfg.DataDir), newTestOrchestrator(cfg, newTestQueue(t)))
+	if err := s.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer s.Stop()
+
+	tick := time.Date(2026, 3, 1, 9, 0, 0, 0, time.Local)
+	for i, name := range []string{"a", "b", "c", "d"} {
+		if got, want := s.staggerOffset(name, tick), time.Duration(i)*15*time.Minute; got != want {
+			t.Fatalf("%s: expected offset %s, got %s", name, want, got)
+		}
+	}
+	// e fires alone at half past and is not part of the top-of-hour cohort.
+	if got := s.staggerOffset("e", tick.Add(30*time.Minute)); got != 0 {
+		t.Fatalf("expected no offset for a lone project, got %s", got)
+	}
+
+	cfg.Scheduler.StaggerWindow = 0
+	if got := s.staggerOffset("d", tick); got != 0 {
+		t.Fatalf("expected no offset with staggering off, got %s", got)
+	}
+}
+
+func TestSchedulerStopAbortsPendingScan(t *testing.T) {
+	cfg := &config.Config{
+		Scheduler: config.SchedulerConfig{MaxJitter: time.Hour},
+		Projects: []config.ProjectConfig{
+			{Name: "slow", URL: "https://github.com/org/project.git", Schedule: "0 * * * *"},
+		},
+	}
+	provider := &countingProvider{Provider: projects.NewCombinedProvider(cfg, nil, nil, cfg.DataDir)}
+	s := New(cfg, provider, newTestOrchestrator(cfg, newTestQueue(t)))
+
+	done := make(chan struct{})
+	go func() {
+		s.enqueueProjectScans("slow")
+		close(done)
+	}()
+	s.Stop()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending scheduled scan did not return after Stop")
+	}
+	if provider.gets != 0 {
+		t.Fatalf("expected pending scan to be dropped, provider called %d times", provider.gets)
+	}
+}
+
+type countingProvider struct {
+	projects.Provider
+	gets int
+}
+
+func (p *countingProvider) Get(name string) (*config.ProjectConfig, error) {
+	p.gets++
+	return p.Provider.Get(name)
+}
+
+func TestSchedulerSkipsScansDuringMaintenance(t *testing.T) {
+	q := newTestQueue(t)
+	// The project name hashes to a jitter of a few milliseconds.
+	cfg := &config.Config{
+		DataDir: t.TempDir(),
+		Projects: []config.ProjectConfig{
+			{Name: "maint-633", URL: "https://github.com/org/project.git", Schedule: "0 * * * *"},
+		},
+	}
+	provider := &countingProvider{Provider: projects.NewCombinedProvider(cfg, nil, nil, cfg.DataDir)}
+	mode := maintenance.New(cfg.DataDir)
+	if _, err := mode.Enable("redis upgrade", "ops", nil); err != nil {
+		t.Fatalf("enable maintenance: %v", err)
+	}
+
+	s := New(cfg, provider, newTestOrchestrator(cfg, q))
+	s.SetMaintenance(mode)
+	s.enqueueProjectScans("maint-633")
+
+	if provider.gets != 0 {
+		t.Fatalf("expected scheduled scan to be skipped, provider called %d times", provider.gets)
+	}
+}
+
+func TestSchedulerSkipsPausedProjects(t *testing.T) {
+	q := newTestQueue(t)
+	// Same name as the maintenance test, for its short jitter.
+	cfg := &config.Config{
+		DataDir: t.TempDir(),
+		Projects: []config.ProjectConfig{
+			{Name: "maint-633", URL: "https://github.com/org/project.git", Schedule: "0 * * * *"},
+		},
+	}
+	provider := &countingProvider{Provider: projects.NewCombinedProvider(cfg, nil, nil, cfg.DataDir)}
+	if err := q.PauseProject(context.Background(), queue.ProjectPause{Project: "maint-633"}); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+
+	s := New(cfg, provider, newTestOrchestrator(cfg, q))
+	s.SetPauses(q)
+	s.enqueueProjectScans("maint-633")
+
+	if provider.gets != 0 {
+		t.Fatalf("expected scheduled scan to be skipped, provider called %d times", provider.gets)
+	}
+}
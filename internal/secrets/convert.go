@@ -19,14 +19,19 @@ func ProjectConfigFromEntry(entry *ProjectEntry, creds *ProjectCredentials, inte
 		URL:         entry.URL,
 		CloneURL:    entry.URL,
 		Branch:      entry.Branch,
+		RootPath:    entry.RootPath,
 		IgnorePaths: entry.IgnorePaths,
 		Schedule:    entry.Schedule,
+		Chain:       entry.Chain,
+
+		TerraformVersion:  entry.TerraformVersion,
+		TerragruntVersion: entry.TerragruntVersion,
 	}
 	cancel := entry.CancelInflightOnNewTrigger
 	cfg.CancelInflightOnNewTrigger = &cancel
 
 	if integration != nil {
-		gitCfg, err := gitConfigFromIntegration(entry, integration, dataDir)
+		gitCfg, err := GitConfigFromIntegration(integration)
 		if err != nil {
 			return nil, err
 		}
@@ -100,12 +105,19 @@ func writeSSHCredentials(projectName, dataDir string, creds *ProjectCredentials)
 	return keyPath, knownHostsPath, nil
 }
 
-func gitConfigFromIntegration(entry *ProjectEntry, integration *IntegrationEntry, dataDir string) (*config.GitAuthConfig, error) {
+// GitConfigFromIntegration returns the git auth config an integration gives
+// the projects that use it.
+func GitConfigFromIntegration(integration *IntegrationEntry) (*config.GitAuthConfig, error) {
 	if integration == nil {
 		return nil, fmt.Errorf("integration required")
 	}
 
-	gitCfg := &config.GitAuthConfig{Type: integration.Type}
+	gitCfg := &config.GitAuthConfig{
+		Type:             integration.Type,
+		ProxyURL:         integration.ProxyURL,
+		ProxyPasswordEnv: integration.ProxyPasswordEnv,
+		CABundlePath:     integration.CABundlePath,
+	}
 
 	switch integration.Type {
 	case "github_app":
@@ -54,9 +54,16 @@ type IntegrationEntry struct {
 	SSH       *IntegrationSSH       `json:"ssh,omitempty"`
 	HTTPS     *IntegrationHTTPS     `json:"https,omitempty"`
 
+	// Proxy and CA settings apply to every integration type.
+	ProxyURL         string `json:"proxy_url,omitempty"`
+	ProxyPasswordEnv string `json:"proxy_password_env,omitempty"`
+	CABundlePath     string `json:"ca_bundle_path,omitempty"`
+
 	// Metadata
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// Revision counts the entry's writes, as for ProjectEntry.
+	Revision int64 `json:"revision"`
 }
 
 type integrationStoreData struct {
@@ -101,6 +108,9 @@ func (s *IntegrationStore) Load() error {
 
 	s.integrations = make(map[string]*IntegrationEntry, len(storeData.Integrations))
 	for _, entry := range storeData.Integrations {
+		if entry.Revision == 0 {
+			entry.Revision = 1
+		}
 		s.integrations[entry.ID] = entry
 	}
 
@@ -199,12 +209,14 @@ func (s *IntegrationStore) Add(entry *IntegrationEntry) error {
 	now := time.Now()
 	entry.CreatedAt = now
 	entry.UpdatedAt = now
+	entry.Revision = 1
 	s.integrations[entry.ID] = entry
 
 	return s.saveLocked()
 }
 
-// Update updates an existing integration entry.
+// Update updates an existing integration entry. A non-zero entry.Revision
+// must match the stored one, as in ProjectStore.Update.
 func (s *IntegrationStore) Update(id string, entry *IntegrationEntry) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -213,10 +225,14 @@ func (s *IntegrationStore) Update(id string, entry *IntegrationEntry) error {
 	if !ok {
 		return ErrIntegrationNotFound
 	}
+	if entry.Revision != 0 && entry.Revision != existing.Revision {
+		return ErrRevisionConflict
+	}
 
 	entry.ID = existing.ID
 	entry.CreatedAt = existing.CreatedAt
 	entry.UpdatedAt = time.Now()
+	entry.Revision = existing.Revision + 1
 	s.integrations[id] = entry
 
 	return s.saveLocked()
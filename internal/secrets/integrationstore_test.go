@@ -76,6 +76,12 @@ func TestIntegrationStoreUpdateDelete(t *testing.T) {
 	if err := store.Update("int-1", updated); err != nil {
 		t.Fatalf("update: %v", err)
 	}
+	if updated.Revision != 2 {
+		t.Fatalf("expected revision 2 after update, got %d", updated.Revision)
+	}
+	if err := store.Update("int-1", &IntegrationEntry{Name: "stale", Type: "https", Revision: 1}); err != ErrRevisionConflict {
+		t.Fatalf("expected ErrRevisionConflict for a stale revision, got %v", err)
+	}
 
 	got, err := store.Get("int-1")
 	if err != nil {
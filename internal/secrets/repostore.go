@@ -8,6 +8,8 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
 )
 
 const (
@@ -18,6 +20,9 @@ const (
 var (
 	ErrProjectNotFound      = errors.New("project not found")
 	ErrProjectAlreadyExists = errors.New("project already exists")
+	// ErrRevisionConflict is returned by Update when the entry was changed
+	// since the caller read it.
+	ErrRevisionConflict = errors.New("revision conflict")
 )
 
 // ProjectCredentials holds the sensitive credentials for a repository.
@@ -33,6 +38,11 @@ type ProjectCredentials struct {
 	// For https auth
 	HTTPSToken    string `json:"https_token,omitempty"`
 	HTTPSUsername string `json:"https_username,omitempty"`
+
+	// WebhookSecrets verify this project's webhooks instead of the global
+	// webhook secret. The first is current; a second is the previous one,
+	// still accepted while the provider side is rotated.
+	WebhookSecrets []string `json:"webhook_secrets,omitempty"`
 }
 
 // ProjectGitHubApp holds GitHub App configuration (non-sensitive parts).
@@ -52,11 +62,18 @@ type ProjectEntry struct {
 	Name                       string           `json:"name"`
 	URL                        string           `json:"url"`
 	Branch                     string           `json:"branch,omitempty"`
+	RootPath                   string           `json:"root_path,omitempty"`
 	IgnorePaths                []string         `json:"ignore_paths,omitempty"`
 	IntegrationID              string           `json:"integration_id,omitempty"`
 	Git                        ProjectGitConfig `json:"git"`
 	Schedule                   string           `json:"schedule,omitempty"`
 	CancelInflightOnNewTrigger bool             `json:"cancel_inflight_on_new_trigger,omitempty"`
+	// Chain starts scans of other projects after this one completes.
+	Chain []config.ChainTrigger `json:"chain,omitempty"`
+	// TerraformVersion and TerragruntVersion apply to stacks without a
+	// version file.
+	TerraformVersion  string `json:"terraform_version,omitempty"`
+	TerragruntVersion string `json:"terragrunt_version,omitempty"`
 
 	// EncryptedCredentials holds the encrypted credentials blob.
 	EncryptedCredentials string `json:"encrypted_credentials,omitempty"`
@@ -64,6 +81,9 @@ type ProjectEntry struct {
 	// Metadata
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+	// Revision counts the entry's writes, starting at 1. Update rejects an
+	// entry whose non-zero Revision is not the stored one.
+	Revision int64 `json:"revision"`
 }
 
 // projectStoreData is the on-disk format for the project store.
@@ -113,6 +133,10 @@ func (rs *ProjectStore) Load() error {
 
 	rs.projects = make(map[string]*ProjectEntry, len(storeData.Projects))
 	for _, project := range storeData.Projects {
+		// Entries written before revisions existed start at 1.
+		if project.Revision == 0 {
+			project.Revision = 1
+		}
 		rs.projects[project.Name] = project
 	}
 
@@ -211,18 +235,38 @@ func (rs *ProjectStore) GetWithCredentials(name string) (*ProjectEntry, *Project
 	entry := *project
 	entry.EncryptedCredentials = ""
 
+	creds, err := rs.decryptCredentials(project)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &entry, creds, nil
+}
+
+func (rs *ProjectStore) decryptCredentials(project *ProjectEntry) (*ProjectCredentials, error) {
 	var creds ProjectCredentials
-	if project.EncryptedCredentials != "" {
-		decrypted, err := rs.encryptor.Decrypt(project.EncryptedCredentials)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to decrypt credentials: %w", err)
-		}
-		if err := json.Unmarshal(decrypted, &creds); err != nil {
-			return nil, nil, fmt.Errorf("failed to parse credentials: %w", err)
-		}
+	if project.EncryptedCredentials == "" {
+		return &creds, nil
 	}
+	decrypted, err := rs.encryptor.Decrypt(project.EncryptedCredentials)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials: %w", err)
+	}
+	if err := json.Unmarshal(decrypted, &creds); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials: %w", err)
+	}
+	return &creds, nil
+}
 
-	return &entry, &creds, nil
+func (rs *ProjectStore) encryptCredentials(creds *ProjectCredentials) (string, error) {
+	credsJSON, err := json.Marshal(creds)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+	encrypted, err := rs.encryptor.Encrypt(credsJSON)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt credentials: %w", err)
+	}
+	return encrypted, nil
 }
 
 // Add adds a new repository with encrypted credentials.
@@ -236,13 +280,9 @@ func (rs *ProjectStore) Add(entry *ProjectEntry, creds *ProjectCredentials) erro
 
 	// Encrypt credentials
 	if creds != nil {
-		credsJSON, err := json.Marshal(creds)
-		if err != nil {
-			return fmt.Errorf("failed to marshal credentials: %w", err)
-		}
-		encrypted, err := rs.encryptor.Encrypt(credsJSON)
+		encrypted, err := rs.encryptCredentials(creds)
 		if err != nil {
-			return fmt.Errorf("failed to encrypt credentials: %w", err)
+			return err
 		}
 		entry.EncryptedCredentials = encrypted
 	}
@@ -250,13 +290,16 @@ func (rs *ProjectStore) Add(entry *ProjectEntry, creds *ProjectCredentials) erro
 	now := time.Now().UTC()
 	entry.CreatedAt = now
 	entry.UpdatedAt = now
+	entry.Revision = 1
 
 	rs.projects[entry.Name] = entry
 
 	return rs.saveLocked()
 }
 
-// Update updates an existing repository.
+// Update updates an existing repository. When entry.Revision is set it
+// must match the stored revision, or ErrRevisionConflict is returned and
+// nothing is written. On success entry.Revision holds the new revision.
 func (rs *ProjectStore) Update(name string, entry *ProjectEntry, creds *ProjectCredentials) error {
 	rs.mu.Lock()
 	defer rs.mu.Unlock()
@@ -265,20 +308,31 @@ func (rs *ProjectStore) Update(name string, entry *ProjectEntry, creds *ProjectC
 	if !ok {
 		return ErrProjectNotFound
 	}
+	if entry.Revision != 0 && entry.Revision != existing.Revision {
+		return ErrRevisionConflict
+	}
 
 	// Preserve created timestamp
 	entry.CreatedAt = existing.CreatedAt
 	entry.UpdatedAt = time.Now().UTC()
+	entry.Revision = existing.Revision + 1
 
 	// Encrypt credentials if provided, otherwise keep existing
 	if creds != nil {
-		credsJSON, err := json.Marshal(creds)
-		if err != nil {
-			return fmt.Errorf("failed to marshal credentials: %w", err)
+		// New git credentials keep the project's webhook secrets, which
+		// are rotated on their own with SetWebhookSecrets.
+		if creds.WebhookSecrets == nil {
+			previous, err := rs.decryptCredentials(existing)
+			if err != nil {
+				return err
+			}
+			withSecrets := *creds
+			withSecrets.WebhookSecrets = previous.WebhookSecrets
+			creds = &withSecrets
 		}
-		encrypted, err := rs.encryptor.Encrypt(credsJSON)
+		encrypted, err := rs.encryptCredentials(creds)
 		if err != nil {
-			return fmt.Errorf("failed to encrypt credentials: %w", err)
+			return err
 		}
 		entry.EncryptedCredentials = encrypted
 	} else {
@@ -294,6 +348,37 @@ func (rs *ProjectStore) Update(name string, entry *ProjectEntry, creds *ProjectC
 	return rs.saveLocked()
 }
 
+// SetWebhookSecrets replaces a project's webhook secrets and keeps its git
+// credentials. An empty list removes the project's override.
+func (rs *ProjectStore) SetWebhookSecrets(name string, webhookSecrets []string) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	project, ok := rs.projects[name]
+	if !ok {
+		return ErrProjectNotFound
+	}
+	creds, err := rs.decryptCredentials(project)
+	if err != nil {
+		return err
+	}
+	creds.WebhookSecrets = nil
+	if len(webhookSecrets) > 0 {
+		creds.WebhookSecrets = append([]string(nil), webhookSecrets...)
+	}
+	encrypted, err := rs.encryptCredentials(creds)
+	if err != nil {
+		return err
+	}
+
+	updated := *project
+	updated.EncryptedCredentials = encrypted
+	updated.UpdatedAt = time.Now().UTC()
+	updated.Revision++
+	rs.projects[name] = &updated
+	return rs.saveLocked()
+}
+
 // Delete removes a repository by name.
 func (rs *ProjectStore) Delete(name string) error {
 	rs.mu.Lock()
@@ -275,3 +275,95 @@ func TestProjectStore_List(t *testing.T) {
 		}
 	}
 }
+
+func TestProjectStore_SetWebhookSecrets(t *testing.T) {
+	store, tmpDir := setupTestProjectStore(t)
+	defer os.RemoveAll(tmpDir)
+
+	entry := &ProjectEntry{Name: "test-project", URL: "https://github.com/example/project.git", Git: ProjectGitConfig{Type: "https"}}
+	if err := store.Add(entry, &ProjectCredentials{HTTPSToken: "token"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if err := store.SetWebhookSecrets("test-project", []string{"new", "old"}); err != nil {
+		t.Fatalf("SetWebhookSecrets() error = %v", err)
+	}
+	_, creds, err := store.GetWithCredentials("test-project")
+	if err != nil {
+		t.Fatalf("GetWithCredentials() error = %v", err)
+	}
+	if creds.HTTPSToken != "token" || len(creds.WebhookSecrets) != 2 || creds.WebhookSecrets[0] != "new" {
+		t.Fatalf("unexpected credentials: %+v", creds)
+	}
+
+	// Replacing git credentials keeps the webhook secrets.
+	update := &ProjectEntry{Name: "test-project", URL: entry.URL, Git: ProjectGitConfig{Type: "https"}}
+	if err := store.Update("test-project", update, &ProjectCredentials{HTTPSToken: "rotated"}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	_, creds, _ = store.GetWithCredentials("test-project")
+	if creds.HTTPSToken != "rotated" || len(creds.WebhookSecrets) != 2 {
+		t.Fatalf("webhook secrets lost on update: %+v", creds)
+	}
+
+	if err := store.SetWebhookSecrets("test-project", nil); err != nil {
+		t.Fatalf("SetWebhookSecrets() error = %v", err)
+	}
+	_, creds, _ = store.GetWithCredentials("test-project")
+	if creds.WebhookSecrets != nil || creds.HTTPSToken != "rotated" {
+		t.Fatalf("unexpected credentials after clearing: %+v", creds)
+	}
+	if err := store.SetWebhookSecrets("missing", []string{"x"}); err != ErrProjectNotFound {
+		t.Fatalf("expected ErrProjectNotFound, got %v", err)
+	}
+}
+
+func TestProjectStore_Revisions(t *testing.T) {
+	store, tmpDir := setupTestProjectStore(t)
+	defer os.RemoveAll(tmpDir)
+
+	if err := store.Add(&ProjectEntry{Name: "test-project", URL: "https://github.com/example/project.git"}, nil); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	got, _ := store.Get("test-project")
+	if got.Revision != 1 {
+		t.Fatalf("expected revision 1 after add, got %d", got.Revision)
+	}
+
+	update := &ProjectEntry{Name: "test-project", URL: "https://github.com/example/a.git", Revision: 1}
+	if err := store.Update("test-project", update, nil); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if update.Revision != 2 {
+		t.Fatalf("expected revision 2 after update, got %d", update.Revision)
+	}
+
+	// A second writer still holding revision 1 loses.
+	stale := &ProjectEntry{Name: "test-project", URL: "https://github.com/example/b.git", Revision: 1}
+	if err := store.Update("test-project", stale, nil); err != ErrRevisionConflict {
+		t.Fatalf("expected ErrRevisionConflict, got %v", err)
+	}
+	got, _ = store.Get("test-project")
+	if got.URL != "https://github.com/example/a.git" || got.Revision != 2 {
+		t.Fatalf("stale update was written: %+v", got)
+	}
+
+	if err := store.SetWebhookSecrets("test-project", []string{"s3cret"}); err != nil {
+		t.Fatalf("SetWebhookSecrets() error = %v", err)
+	}
+	got, _ = store.Get("test-project")
+	if got.Revision != 3 {
+		t.Fatalf("expected webhook secret change to bump revision, got %d", got.Revision)
+	}
+
+	// Files written before revisions existed load at revision 1.
+	legacy := `{"version":1,"projects":[{"name":"old","url":"https://github.com/example/old.git","git":{}}]}`
+	if err := os.WriteFile(filepath.Join(tmpDir, ProjectsFileName), []byte(legacy), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got, _ := store.Get("old"); got == nil || got.Revision != 1 {
+		t.Fatalf("expected legacy entry at revision 1, got %+v", got)
+	}
+}
@@ -0,0 +1,185 @@
+// Package severity scores drifted stacks so the most dangerous drift is
+// listed first. A stack's score is the sum of its resource changes, each
+// weighed by action and multiplied by its resource type, then multiplied by
+// the stack's tags.
+package severity
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+// Levels, from most to least severe. A healthy stack has LevelNone.
+const (
+	LevelCritical = "critical"
+	LevelHigh     = "high"
+	LevelMedium   = "medium"
+	LevelLow      = "low"
+	LevelNone     = ""
+)
+
+var defaultActions = map[string]int{
+	"create":  1,
+	"update":  2,
+	"replace": 8,
+	"delete":  10,
+	"forget":  3,
+	"read":    0,
+	"import":  0,
+	"move":    0,
+}
+
+// Access control and network exposure weigh more than other resources.
+var defaultResourceTypes = map[string]int{
+	"aws_iam_*":                  3,
+	"aws_security_group*":        3,
+	"aws_vpc_security_group_*":   3,
+	"aws_kms_*":                  3,
+	"google_*_iam_*":             3,
+	"google_compute_firewall*":   3,
+	"azurerm_role_*":             3,
+	"azurerm_network_security_*": 3,
+	"azurerm_key_vault_access_*": 3,
+	"kubernetes_*role*":          3,
+	"kubernetes_network_policy*": 3,
+}
+
+var defaultTags = map[string]int{
+	"tier=critical": 3,
+}
+
+// Policy scores stacks. The zero value is not usable; call New.
+type Policy struct {
+	actions       map[string]int
+	resourceTypes map[string]int
+	tags          map[string]int
+}
+
+// New returns the default policy with cfg's weights applied on top.
+func New(cfg config.SeverityConfig) *Policy {
+	return &Policy{
+		actions:       merge(defaultActions, cfg.Actions),
+		resourceTypes: merge(defaultResourceTypes, cfg.ResourceTypes),
+		tags:          merge(defaultTags, cfg.Tags),
+	}
+}
+
+// Score returns the severity of a stack's last result. Stacks that are not
+// drifted, or are suppressed, score 0; drifted stacks score at least 1.
+func (p *Policy) Score(st storage.StackStatus) int {
+	if !st.Drifted || st.Suppressed {
+		return 0
+	}
+	return p.score(st.ResourceChanges, st.Added, st.Changed, st.Destroyed, st.Tags)
+}
+
+// ScoreResult is Score for a stored result.
+func (p *Policy) ScoreResult(result *storage.RunResult) int {
+	if result == nil || !result.Drifted {
+		return 0
+	}
+	return p.score(result.ResourceChanges, result.Added, result.Changed, result.Destroyed, result.Tags)
+}
+
+// Apply sets Severity on every stack.
+func (p *Policy) Apply(stacks []storage.StackStatus) {
+	for i := range stacks {
+		stacks[i].Severity = p.Score(stacks[i])
+	}
+}
+
+func (p *Policy) score(changes []storage.ResourceChange, added, changed, destroyed int, tags map[string]string) int {
+	points := 0
+	if len(changes) > 0 {
+		for _, change := range changes {
+			points += p.actions[change.Action] * p.resourceWeight(ResourceType(change.Address))
+		}
+	} else {
+		// Results from before resource changes were recorded only have counts.
+		points = added*p.actions["create"] + changed*p.actions["update"] + destroyed*p.actions["delete"]
+	}
+	points *= p.tagWeight(tags)
+	if points < 1 {
+		points = 1
+	}
+	return points
+}
+
+// resourceWeight is the largest multiplier whose pattern matches
+// resourceType, or 1.
+func (p *Policy) resourceWeight(resourceType string) int {
+	weight, matched := 0, false
+	for pattern, w := range p.resourceTypes {
+		if ok, _ := path.Match(pattern, resourceType); ok {
+			matched = true
+			weight = max(weight, w)
+		}
+	}
+	if !matched {
+		return 1
+	}
+	return weight
+}
+
+// tagWeight is the largest multiplier of the stack's tags, or 1.
+func (p *Policy) tagWeight(tags map[string]string) int {
+	weight, matched := 0, false
+	for key, value := range tags {
+		if w, ok := p.tags[key+"="+value]; ok {
+			matched = true
+			weight = max(weight, w)
+		}
+	}
+	if !matched {
+		return 1
+	}
+	return weight
+}
+
+// Level buckets a score for display.
+func Level(score int) string {
+	switch {
+	case score >= 100:
+		return LevelCritical
+	case score >= 30:
+		return LevelHigh
+	case score >= 10:
+		return LevelMedium
+	case score > 0:
+		return LevelLow
+	}
+	return LevelNone
+}
+
+var indexPattern = regexp.MustCompile(`\[[^\]]*\]`)
+
+// ResourceType returns the type in a resource address, such as
+// aws_iam_role for module.app.aws_iam_role.this["x"].
+func ResourceType(address string) string {
+	parts := strings.Split(indexPattern.ReplaceAllString(address, ""), ".")
+	for len(parts) >= 2 && parts[0] == "module" {
+		parts = parts[2:]
+	}
+	if len(parts) > 0 && parts[0] == "data" {
+		parts = parts[1:]
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return parts[0]
+}
+
+func merge(defaults, overrides map[string]int) map[string]int {
+	out := make(map[string]int, len(defaults)+len(overrides))
+	for key, weight := range defaults {
+		out[key] = weight
+	}
+	for key, weight := range overrides {
+		out[key] = weight
+	}
+	return out
+}
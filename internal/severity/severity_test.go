@@ -0,0 +1,98 @@
+package severity
+
+import (
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func TestResourceType(t *testing.T) {
+	cases := map[string]string{
+		"aws_iam_role.deploy":                            "aws_iam_role",
+		`module.app.aws_security_group.this["a.b"]`:      "aws_security_group",
+		"module.a.module.b.aws_s3_bucket.logs[0]":        "aws_s3_bucket",
+		"data.aws_iam_policy_document.assume":            "aws_iam_policy_document",
+		`module.app["blue"].google_project_iam_member.x`: "google_project_iam_member",
+	}
+	for address, want := range cases {
+		if got := ResourceType(address); got != want {
+			t.Errorf("ResourceType(%q) = %q, want %q", address, got, want)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	policy := New(config.SeverityConfig{})
+
+	bucket := storage.StackStatus{Drifted: true, ResourceChanges: []storage.ResourceChange{
+		{Address: "aws_s3_bucket.logs", Action: "update"},
+	}}
+	role := storage.StackStatus{Drifted: true, ResourceChanges: []storage.ResourceChange{
+		{Address: "aws_iam_role.deploy", Action: "update"},
+	}}
+	destroy := storage.StackStatus{Drifted: true, ResourceChanges: []storage.ResourceChange{
+		{Address: "aws_s3_bucket.logs", Action: "delete"},
+	}}
+	if policy.Score(bucket) >= policy.Score(role) {
+		t.Fatalf("expected IAM change to outweigh bucket change: %d vs %d", policy.Score(bucket), policy.Score(role))
+	}
+	if policy.Score(role) >= policy.Score(destroy) {
+		t.Fatalf("expected destroy to outweigh update: %d vs %d", policy.Score(role), policy.Score(destroy))
+	}
+
+	critical := destroy
+	critical.Tags = map[string]string{"tier": "critical"}
+	if got, want := policy.Score(critical), 3*policy.Score(destroy); got != want {
+		t.Fatalf("expected tier=critical to triple the score: got %d, want %d", got, want)
+	}
+
+	// Older results only have counts.
+	counts := storage.StackStatus{Drifted: true, Added: 1, Changed: 1, Destroyed: 1}
+	if got := policy.Score(counts); got != 13 {
+		t.Fatalf("expected score from counts 13, got %d", got)
+	}
+
+	moved := storage.StackStatus{Drifted: true, ResourceChanges: []storage.ResourceChange{{Address: "aws_s3_bucket.a", Action: "move"}}}
+	if got := policy.Score(moved); got != 1 {
+		t.Fatalf("expected drifted stacks to score at least 1, got %d", got)
+	}
+	if got := policy.Score(storage.StackStatus{}); got != 0 {
+		t.Fatalf("expected healthy stack to score 0, got %d", got)
+	}
+	suppressed := destroy
+	suppressed.Suppressed = true
+	if got := policy.Score(suppressed); got != 0 {
+		t.Fatalf("expected suppressed stack to score 0, got %d", got)
+	}
+}
+
+func TestPolicyOverrides(t *testing.T) {
+	policy := New(config.SeverityConfig{
+		Actions:       map[string]int{"update": 5},
+		ResourceTypes: map[string]int{"aws_iam_*": 1, "aws_s3_*": 4},
+		Tags:          map[string]int{"env=prod": 2},
+	})
+	st := storage.StackStatus{
+		Drifted: true,
+		Tags:    map[string]string{"env": "prod"},
+		ResourceChanges: []storage.ResourceChange{
+			{Address: "aws_iam_role.deploy", Action: "update"},
+			{Address: "aws_s3_bucket.logs", Action: "update"},
+			{Address: "aws_instance.web", Action: "delete"},
+		},
+	}
+	// (5*1 + 5*4 + 10*1) * 2
+	if got := policy.Score(st); got != 70 {
+		t.Fatalf("expected 70, got %d", got)
+	}
+}
+
+func TestLevel(t *testing.T) {
+	cases := map[int]string{0: LevelNone, 1: LevelLow, 10: LevelMedium, 30: LevelHigh, 100: LevelCritical}
+	for score, want := range cases {
+		if got := Level(score); got != want {
+			t.Errorf("Level(%d) = %q, want %q", score, got, want)
+		}
+	}
+}
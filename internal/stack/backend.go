@@ -0,0 +1,51 @@
+package stack
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	backendBlockPattern     = regexp.MustCompile(`(?m)^\s*backend\s+"([^"]+)"\s*\{`)
+	cloudBlockPattern       = regexp.MustCompile(`(?m)^\s*cloud\s*\{`)
+	remoteStateBlockPattern = regexp.MustCompile(`(?m)^\s*remote_state\s*\{`)
+	backendAttrPattern      = regexp.MustCompile(`(?m)^\s*backend\s*=\s*"([^"]*)"`)
+)
+
+// ParseBackendType returns the state backend the stack in stackDir declares:
+// the label of a terraform backend block, "cloud" for an HCP Terraform cloud
+// block, or the backend of a terragrunt remote_state block. It returns ""
+// when the stack declares none itself, which for terraform means local
+// state and for terragrunt usually means remote_state comes from an
+// included parent config.
+func ParseBackendType(stackDir string) string {
+	if data, err := os.ReadFile(filepath.Join(stackDir, "terragrunt.hcl")); err == nil {
+		for _, body := range blockBodies(string(data), remoteStateBlockPattern) {
+			if m := backendAttrPattern.FindStringSubmatch(body.text); m != nil {
+				return m[1]
+			}
+		}
+	}
+	entries, err := os.ReadDir(stackDir)
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".tf") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(stackDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if m := backendBlockPattern.FindStringSubmatch(string(data)); m != nil {
+			return m[1]
+		}
+		if cloudBlockPattern.Match(data) {
+			return "cloud"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+package stack
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestParseBackendType(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"s3/backend.tf": `terraform {
+  backend "s3" {
+    bucket = "state"
+  }
+}
+`,
+		"hcp/main.tf": `terraform {
+  cloud {
+    organization = "acme"
+  }
+}
+`,
+		"tg/terragrunt.hcl": `remote_state {
+  backend = "gcs"
+  generate = {
+    path = "backend.tf"
+  }
+}
+`,
+		"local/main.tf": `resource "null_resource" "x" {}
+`,
+	})
+
+	for dir, want := range map[string]string{"s3": "s3", "hcp": "cloud", "tg": "gcs", "local": ""} {
+		if got := ParseBackendType(filepath.Join(root, dir)); got != want {
+			t.Errorf("%s: backend = %q, want %q", dir, got, want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -21,43 +22,50 @@ var defaultIgnore = []string{
 	"**/node_modules/**",
 }
 
+// IgnoreMatch records a path that discovery skipped because of a configured
+// ignore pattern. Built-in ignores (.terraform, vendor, ...) are not reported.
+type IgnoreMatch struct {
+	Path    string
+	Pattern string
+}
+
 func Discover(projectDir, rootPath string, ignore []string) ([]string, error) {
+	stacks, _, err := discover(projectDir, rootPath, ignore, false)
+	return stacks, err
+}
+
+// DiscoverWithIgnored behaves like Discover and additionally returns the
+// directories and stack files excluded by the configured ignore patterns.
+func DiscoverWithIgnored(projectDir, rootPath string, ignore []string) ([]string, []IgnoreMatch, error) {
+	return discover(projectDir, rootPath, ignore, true)
+}
+
+func discover(projectDir, rootPath string, ignore []string, trackIgnored bool) ([]string, []IgnoreMatch, error) {
 	patterns := append([]string{}, defaultIgnore...)
 	patterns = append(patterns, ignore...)
-	scopeRoot := ""
+	scopeRoot, err := cleanRootPath(rootPath)
+	if err != nil {
+		return nil, nil, err
+	}
 	walkRoot := projectDir
-	if rootPath != "" {
-		if filepath.IsAbs(rootPath) {
-			return nil, fmt.Errorf("root path must be relative: %q", rootPath)
-		}
-		clean := filepath.Clean(rootPath)
-		if clean == "." {
-			return nil, fmt.Errorf("root path must not be '.'")
-		}
-		if clean == ".." || strings.HasPrefix(clean, ".."+string(os.PathSeparator)) {
-			return nil, fmt.Errorf("root path must not traverse outside repository: %q", rootPath)
-		}
-		scopeRoot = filepath.ToSlash(clean)
-		walkRoot = filepath.Join(projectDir, clean)
+	if scopeRoot != "" {
+		walkRoot = filepath.Join(projectDir, filepath.FromSlash(scopeRoot))
 		info, err := os.Stat(walkRoot)
 		if err != nil {
 			if os.IsNotExist(err) {
-				return nil, fmt.Errorf("root path does not exist: %q", scopeRoot)
+				return nil, nil, fmt.Errorf("root path does not exist: %q", scopeRoot)
 			}
-			return nil, err
+			return nil, nil, err
 		}
 		if !info.IsDir() {
-			return nil, fmt.Errorf("root path is not a directory: %q", scopeRoot)
+			return nil, nil, fmt.Errorf("root path is not a directory: %q", scopeRoot)
 		}
 	}
 
-	seenTG := map[string]struct{}{}
-	seenTF := map[string]struct{}{}
-	var terragruntStacks []string
-	var terraformStacks []string
-	rootHasTerragrunt := false
+	c := newCollector(scopeRoot)
+	var ignored []IgnoreMatch
 
-	err := filepath.WalkDir(walkRoot, func(path string, d fs.DirEntry, err error) error {
+	err = filepath.WalkDir(walkRoot, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -68,6 +76,11 @@ func Discover(projectDir, rootPath string, ignore []string) ([]string, error) {
 
 		rel = filepath.ToSlash(rel)
 		if shouldIgnore(rel, patterns) {
+			if trackIgnored && (d.IsDir() || isStackFile(d.Name())) {
+				if pattern, ok := matchingPattern(rel, ignore); ok && !shouldIgnore(rel, defaultIgnore) {
+					ignored = append(ignored, IgnoreMatch{Path: rel, Pattern: pattern})
+				}
+			}
 			if d.IsDir() {
 				return filepath.SkipDir
 			}
@@ -77,36 +90,139 @@ func Discover(projectDir, rootPath string, ignore []string) ([]string, error) {
 		if d.IsDir() {
 			return nil
 		}
-
-		dir := filepath.ToSlash(filepath.Dir(rel))
-		normalizedDir := dir
-		if normalizedDir == "." {
-			normalizedDir = ""
-		}
-		base := filepath.Base(rel)
-		if base == "terragrunt.hcl" {
-			if normalizedDir == scopeRoot {
-				rootHasTerragrunt = true
-			}
-			addStack(dir, seenTG, &terragruntStacks)
-			return nil
-		}
-		if strings.HasSuffix(base, ".tf") {
-			addStack(dir, seenTF, &terraformStacks)
-			return nil
-		}
+		c.add(rel)
 		return nil
 	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return c.stacks(), ignored, nil
+}
+
+// DiscoverFiles runs discovery over a list of slash-separated file paths
+// relative to the repository root, such as the file list of a git commit,
+// instead of walking a checkout.
+func DiscoverFiles(files []string, rootPath string, ignore []string) ([]string, error) {
+	patterns := append([]string{}, defaultIgnore...)
+	patterns = append(patterns, ignore...)
+	scopeRoot, err := cleanRootPath(rootPath)
 	if err != nil {
 		return nil, err
 	}
-	if rootHasTerragrunt && len(terragruntStacks) > 0 {
-		sort.Strings(terragruntStacks)
-		return filterParentStacks(terragruntStacks), nil
+
+	c := newCollector(scopeRoot)
+	rootFound := scopeRoot == ""
+	for _, file := range files {
+		if scopeRoot != "" {
+			if !strings.HasPrefix(file, scopeRoot+"/") {
+				continue
+			}
+			rootFound = true
+		}
+		if ignoredPath(file, patterns) {
+			continue
+		}
+		c.add(file)
+	}
+	if !rootFound {
+		return nil, fmt.Errorf("root path does not exist: %q", scopeRoot)
+	}
+	return c.stacks(), nil
+}
+
+// ignoredPath reports whether file or any of its parent directories matches
+// an ignore pattern, mirroring the walk that skips ignored directories.
+func ignoredPath(file string, patterns []string) bool {
+	for p := file; p != "." && p != ""; p = path.Dir(p) {
+		if shouldIgnore(p, patterns) {
+			return true
+		}
+	}
+	return false
+}
+
+func cleanRootPath(rootPath string) (string, error) {
+	if rootPath == "" {
+		return "", nil
+	}
+	if filepath.IsAbs(rootPath) {
+		return "", fmt.Errorf("root path must be relative: %q", rootPath)
+	}
+	clean := filepath.Clean(rootPath)
+	if clean == "." {
+		return "", fmt.Errorf("root path must not be '.'")
+	}
+	if clean == ".." || strings.HasPrefix(clean, ".."+string(os.PathSeparator)) {
+		return "", fmt.Errorf("root path must not traverse outside repository: %q", rootPath)
+	}
+	return filepath.ToSlash(clean), nil
+}
+
+// collector accumulates stack directories from the files seen during
+// discovery.
+type collector struct {
+	scopeRoot         string
+	seenTG            map[string]struct{}
+	seenTF            map[string]struct{}
+	seenPulumi        map[string]struct{}
+	terragruntStacks  []string
+	terraformStacks   []string
+	pulumiStacks      []string
+	rootHasTerragrunt bool
+}
+
+func newCollector(scopeRoot string) *collector {
+	return &collector{
+		scopeRoot:  scopeRoot,
+		seenTG:     map[string]struct{}{},
+		seenTF:     map[string]struct{}{},
+		seenPulumi: map[string]struct{}{},
+	}
+}
+
+func (c *collector) add(rel string) {
+	dir := path.Dir(rel)
+	normalizedDir := dir
+	if normalizedDir == "." {
+		normalizedDir = ""
+	}
+	base := path.Base(rel)
+	if base == "terragrunt.hcl" {
+		if normalizedDir == c.scopeRoot {
+			c.rootHasTerragrunt = true
+		}
+		addStack(dir, c.seenTG, &c.terragruntStacks)
+		return
+	}
+	if IsPulumiProjectFile(base) {
+		addStack(dir, c.seenPulumi, &c.pulumiStacks)
+		return
+	}
+	if strings.HasSuffix(base, ".tf") {
+		addStack(dir, c.seenTF, &c.terraformStacks)
+	}
+}
+
+func (c *collector) stacks() []string {
+	// Pulumi projects are stacks of their own, so they are kept alongside
+	// terragrunt-only discovery.
+	all := append(append([]string{}, c.terragruntStacks...), c.pulumiStacks...)
+	if !c.rootHasTerragrunt || len(c.terragruntStacks) == 0 {
+		all = append(all, c.terraformStacks...)
 	}
-	all := append(terragruntStacks, terraformStacks...)
 	sort.Strings(all)
-	return filterParentStacks(all), nil
+	return filterParentStacks(dedupeSorted(all))
+}
+
+func dedupeSorted(stacks []string) []string {
+	out := stacks[:0]
+	for i, stack := range stacks {
+		if i > 0 && stack == stacks[i-1] {
+			continue
+		}
+		out = append(out, stack)
+	}
+	return out
 }
 
 func filterParentStacks(stacks []string) []string {
@@ -145,15 +261,30 @@ func addStack(dir string, seen map[string]struct{}, stacks *[]string) {
 }
 
 func shouldIgnore(path string, patterns []string) bool {
+	_, ok := matchingPattern(path, patterns)
+	return ok
+}
+
+func matchingPattern(path string, patterns []string) (string, bool) {
 	for _, p := range patterns {
 		if p == "" {
 			continue
 		}
 		if matchGlob(p, path) {
-			return true
+			return p, true
 		}
 	}
-	return false
+	return "", false
+}
+
+func isStackFile(name string) bool {
+	return name == "terragrunt.hcl" || strings.HasSuffix(name, ".tf") || IsPulumiProjectFile(name)
+}
+
+// IsPulumiProjectFile reports whether name is a Pulumi project file. The
+// directory holding it is scanned with pulumi preview.
+func IsPulumiProjectFile(name string) bool {
+	return name == "Pulumi.yaml" || name == "Pulumi.yml"
 }
 
 func matchGlob(pattern, path string) bool {
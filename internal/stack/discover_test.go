@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -69,6 +70,34 @@ func TestDiscoverPrefersTerragruntWhenRootConfigPresent(t *testing.T) {
 	}
 }
 
+func TestDiscoverPulumiProjects(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "terragrunt.hcl"))
+	writeFile(t, filepath.Join(dir, "envs/prod/terragrunt.hcl"))
+	writeFile(t, filepath.Join(dir, "apps/web/Pulumi.yaml"))
+	writeFile(t, filepath.Join(dir, "apps/web/Pulumi.prod.yaml"))
+	writeFile(t, filepath.Join(dir, "apps/web/node_modules/dep/Pulumi.yaml"))
+	writeFile(t, filepath.Join(dir, "apps/api/Pulumi.yml"))
+
+	stacks, err := Discover(dir, "", nil)
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	want := []string{"apps/api", "apps/web", "envs/prod"}
+	if strings.Join(stacks, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, stacks)
+	}
+
+	files := []string{"apps/web/Pulumi.yaml", "apps/web/index.ts", "apps/api/Pulumi.yml", "terragrunt.hcl", "envs/prod/terragrunt.hcl"}
+	fromFiles, err := DiscoverFiles(files, "", nil)
+	if err != nil {
+		t.Fatalf("discover files: %v", err)
+	}
+	if strings.Join(fromFiles, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v from files, got %v", want, fromFiles)
+	}
+}
+
 func writeFile(t *testing.T, path string) {
 	t.Helper()
 	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
@@ -110,3 +139,105 @@ func TestDiscoverScopedRootMissing(t *testing.T) {
 		t.Fatalf("expected error for missing root path")
 	}
 }
+
+func TestDiscoverWithIgnoredReportsConfiguredMatches(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "envs/prod/main.tf"))
+	writeFile(t, filepath.Join(dir, "envs/legacy.tf"))
+	writeFile(t, filepath.Join(dir, "modules/shared/main.tf"))
+	writeFile(t, filepath.Join(dir, ".terraform/ignored.tf"))
+
+	stacks, ignored, err := DiscoverWithIgnored(dir, "", []string{"**/modules/**", "envs/*.tf"})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	if len(stacks) != 1 || stacks[0] != "envs/prod" {
+		t.Fatalf("expected only envs/prod stack, got %v", stacks)
+	}
+
+	want := []IgnoreMatch{
+		{Path: "envs/legacy.tf", Pattern: "envs/*.tf"},
+		{Path: "modules", Pattern: "**/modules/**"},
+	}
+	if len(ignored) != len(want) {
+		t.Fatalf("expected %d ignore matches, got %v", len(want), ignored)
+	}
+	for i := range want {
+		if ignored[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, ignored)
+		}
+	}
+}
+
+func TestDiscoverFilesMatchesDiscover(t *testing.T) {
+	files := []string{
+		"terragrunt.hcl",
+		"envs/prod/terragrunt.hcl",
+		"envs/dev/terragrunt.hcl",
+		"envs/dev/.terragrunt-cache/x/main.tf",
+		"modules/shared/main.tf",
+		"legacy/old/main.tf",
+	}
+	dir := t.TempDir()
+	for _, f := range files {
+		writeFile(t, filepath.Join(dir, f))
+	}
+
+	want, err := Discover(dir, "", []string{"legacy"})
+	if err != nil {
+		t.Fatalf("discover: %v", err)
+	}
+	got, err := DiscoverFiles(files, "", []string{"legacy"})
+	if err != nil {
+		t.Fatalf("discover files: %v", err)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	if _, err := DiscoverFiles(files, "missing", nil); err == nil {
+		t.Fatalf("expected error for missing root path")
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.tf"), []byte(`# driftd:team=payments tier=critical
+resource "null_resource" "a" {}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "locals.tf"), []byte(`locals {
+  driftd_tags = {
+    owner = "alice"
+    Tier  = "low"
+  }
+}
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := ParseTags(dir)
+	if err != nil {
+		t.Fatalf("parse tags: %v", err)
+	}
+	want := map[string]string{"team": "payments", "tier": "critical", "owner": "alice"}
+	if len(tags) != len(want) {
+		t.Fatalf("expected %v, got %v", want, tags)
+	}
+	for k, v := range want {
+		if tags[k] != v {
+			t.Fatalf("expected %s=%s, got %v", k, v, tags)
+		}
+	}
+
+	if !MatchTags(tags, map[string]string{"tier": "CRITICAL", "team": ""}) {
+		t.Fatalf("expected filter to match")
+	}
+	if MatchTags(tags, map[string]string{"env": ""}) {
+		t.Fatalf("expected missing key to fail filter")
+	}
+	if key, value, ok := ParseTagFilter("Tier:critical"); !ok || key != "tier" || value != "critical" {
+		t.Fatalf("unexpected filter parse: %q %q %v", key, value, ok)
+	}
+}
@@ -0,0 +1,115 @@
+package stack
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// terragruntDirPrefix is the interpolation terragrunt sources commonly use to
+// point at a path relative to the stack.
+const terragruntDirPrefix = "${get_terragrunt_dir()}/"
+
+// ModuleIndex maps repository-relative local module directories to the stacks
+// that call them, directly or through other local modules.
+type ModuleIndex map[string][]string
+
+// BuildModuleIndex resolves the local modules of each stack in projectDir.
+// Module calls and terragrunt terraform sources are followed while they stay
+// inside projectDir; remote sources are ignored.
+func BuildModuleIndex(projectDir string, stacks []string) ModuleIndex {
+	root, err := filepath.Abs(projectDir)
+	if err != nil {
+		return nil
+	}
+	index := ModuleIndex{}
+	for _, stackPath := range stacks {
+		dirs := map[string]bool{}
+		collectLocalModules(root, filepath.Join(root, filepath.FromSlash(stackPath)), dirs, 0)
+		for dir := range dirs {
+			index[dir] = append(index[dir], stackPath)
+		}
+	}
+	if len(index) == 0 {
+		return nil
+	}
+	for dir := range index {
+		sort.Strings(index[dir])
+	}
+	return index
+}
+
+// Dependents returns the stacks that use a local module containing file.
+func (idx ModuleIndex) Dependents(file string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for dir, stacks := range idx {
+		if !strings.HasPrefix(file, dir+"/") {
+			continue
+		}
+		for _, s := range stacks {
+			if !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// collectLocalModules records in dirs the repository-relative paths of the
+// local modules called from dir.
+func collectLocalModules(root, dir string, dirs map[string]bool, depth int) {
+	if depth > maxModuleDepth {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !isStackFile(entry.Name()) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		src := string(data)
+		var sources []string
+		if entry.Name() == "terragrunt.hcl" {
+			for _, body := range blockBodies(src, terraformBlockPattern) {
+				if m := sourceAttrPattern.FindStringSubmatch(body.text); m != nil {
+					sources = append(sources, strings.ReplaceAll(strings.TrimPrefix(m[1], terragruntDirPrefix), "//", "/"))
+				}
+			}
+		} else {
+			for _, body := range blockBodies(src, moduleBlockPattern) {
+				if m := sourceAttrPattern.FindStringSubmatch(body.text); m != nil {
+					sources = append(sources, m[1])
+				}
+			}
+		}
+		for _, source := range sources {
+			if !isLocalModuleSource(source) {
+				continue
+			}
+			child := filepath.Join(dir, filepath.FromSlash(source))
+			rel, err := filepath.Rel(root, child)
+			if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			rel = filepath.ToSlash(rel)
+			if dirs[rel] {
+				continue
+			}
+			if info, err := os.Stat(child); err != nil || !info.IsDir() {
+				continue
+			}
+			dirs[rel] = true
+			collectLocalModules(root, child, dirs, depth+1)
+		}
+	}
+}
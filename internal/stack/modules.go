@@ -0,0 +1,261 @@
+package stack
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxModuleDepth bounds how far local module calls are followed.
+const maxModuleDepth = 8
+
+var (
+	moduleBlockPattern    = regexp.MustCompile(`(?m)^\s*module\s+"([^"]+)"\s*\{`)
+	terraformBlockPattern = regexp.MustCompile(`(?m)^\s*terraform\s*\{`)
+	sourceAttrPattern     = regexp.MustCompile(`(?m)^\s*source\s*=\s*"([^"]*)"`)
+	versionAttrPattern    = regexp.MustCompile(`(?m)^\s*version\s*=\s*"([^"]*)"`)
+)
+
+// ModuleSource is a module call and the source it resolves to. Module is the
+// Terraform address, e.g. "module.network.module.vpc"; calls made through
+// local modules are followed so remote sources nested inside them are
+// included. The terragrunt.hcl terraform source is recorded as "terraform".
+type ModuleSource struct {
+	Module  string `json:"module"`
+	Source  string `json:"source"`
+	Version string `json:"version,omitempty"`
+}
+
+// ModuleSourceChange is a module whose source or version differs from the
+// previous scan. Previous is nil for a new module call and Current is nil for
+// a removed one.
+type ModuleSourceChange struct {
+	Module   string        `json:"module"`
+	Previous *ModuleSource `json:"previous,omitempty"`
+	Current  *ModuleSource `json:"current,omitempty"`
+}
+
+// ParseModuleSources lists the module sources referenced by the stack in
+// stackDir, sorted by module address. Local modules are only followed while
+// they stay inside projectDir.
+func ParseModuleSources(projectDir, stackDir string) ([]ModuleSource, error) {
+	root, err := filepath.Abs(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	r := &moduleResolver{root: root, visiting: map[string]bool{}}
+	if err := r.resolve(stackDir, "", 0); err != nil {
+		return nil, err
+	}
+	sort.Slice(r.out, func(i, j int) bool { return r.out[i].Module < r.out[j].Module })
+	return r.out, nil
+}
+
+// DiscoverModuleSources resolves module sources for each discovered stack.
+// Stacks whose files cannot be read or that call no modules are omitted.
+func DiscoverModuleSources(projectDir string, stacks []string) map[string][]ModuleSource {
+	out := map[string][]ModuleSource{}
+	for _, stackPath := range stacks {
+		sources, err := ParseModuleSources(projectDir, filepath.Join(projectDir, filepath.FromSlash(stackPath)))
+		if err != nil || len(sources) == 0 {
+			continue
+		}
+		out[stackPath] = sources
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// CompareModuleSources reports module calls that were added, removed, or
+// whose source or version changed between previous and current.
+func CompareModuleSources(previous, current []ModuleSource) []ModuleSourceChange {
+	prev := make(map[string]ModuleSource, len(previous))
+	for _, m := range previous {
+		prev[m.Module] = m
+	}
+	var out []ModuleSourceChange
+	for _, m := range current {
+		old, ok := prev[m.Module]
+		delete(prev, m.Module)
+		if ok && old == m {
+			continue
+		}
+		change := ModuleSourceChange{Module: m.Module, Current: &m}
+		if ok {
+			change.Previous = &old
+		}
+		out = append(out, change)
+	}
+	for _, old := range prev {
+		out = append(out, ModuleSourceChange{Module: old.Module, Previous: &old})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Module < out[j].Module })
+	return out
+}
+
+// SplitModuleSource separates a module source from the ref it pins: the
+// "ref" query parameter of a git or archive source, or else version. The
+// returned base drops the "git::" forced getter and the query string.
+func SplitModuleSource(source, version string) (base, ref string) {
+	base = strings.TrimPrefix(strings.TrimSpace(source), "git::")
+	if i := strings.Index(base, "?"); i >= 0 {
+		if query, err := url.ParseQuery(base[i+1:]); err == nil {
+			ref = query.Get("ref")
+		}
+		base = base[:i]
+	}
+	if ref == "" {
+		ref = strings.TrimSpace(version)
+	}
+	return base, ref
+}
+
+// Uses reports whether m calls source, either exactly or a subdirectory of
+// it ("//path"), pinned at ref. A ref given in source's query is used when
+// ref is empty; no ref at all matches any ref.
+func (m ModuleSource) Uses(source, ref string) bool {
+	base, pinned := SplitModuleSource(m.Source, m.Version)
+	want, wantRef := SplitModuleSource(source, "")
+	if ref == "" {
+		ref = wantRef
+	}
+	if want == "" || (base != want && !strings.HasPrefix(base, want+"//")) {
+		return false
+	}
+	return ref == "" || pinned == ref
+}
+
+type moduleResolver struct {
+	root     string
+	visiting map[string]bool
+	out      []ModuleSource
+}
+
+func (r *moduleResolver) resolve(dir, prefix string, depth int) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if r.visiting[abs] || depth > maxModuleDepth {
+		return nil
+	}
+	r.visiting[abs] = true
+	defer delete(r.visiting, abs)
+
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !isStackFile(entry.Name()) {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(abs, name))
+		if err != nil {
+			return err
+		}
+		src := string(data)
+		if name == "terragrunt.hcl" {
+			if depth == 0 {
+				for _, body := range blockBodies(src, terraformBlockPattern) {
+					if m := sourceAttrPattern.FindStringSubmatch(body.text); m != nil {
+						r.out = append(r.out, ModuleSource{Module: "terraform", Source: m[1]})
+					}
+				}
+			}
+			continue
+		}
+		for _, body := range blockBodies(src, moduleBlockPattern) {
+			m := sourceAttrPattern.FindStringSubmatch(body.text)
+			if m == nil {
+				continue
+			}
+			mod := ModuleSource{Module: prefix + "module." + body.label, Source: m[1]}
+			if v := versionAttrPattern.FindStringSubmatch(body.text); v != nil {
+				mod.Version = v[1]
+			}
+			r.out = append(r.out, mod)
+			if isLocalModuleSource(mod.Source) {
+				child := filepath.Join(abs, filepath.FromSlash(mod.Source))
+				if !r.inRoot(child) {
+					continue
+				}
+				if err := r.resolve(child, mod.Module+".", depth+1); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (r *moduleResolver) inRoot(path string) bool {
+	rel, err := filepath.Rel(r.root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func isLocalModuleSource(source string) bool {
+	return strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
+
+type blockBody struct {
+	label string
+	text  string
+}
+
+// blockBodies returns the top-level attributes of each block matched by
+// pattern. Nested blocks are dropped so their source and version attributes
+// are not mistaken for the block's own.
+func blockBodies(src string, pattern *regexp.Regexp) []blockBody {
+	var out []blockBody
+	for _, loc := range pattern.FindAllStringSubmatchIndex(src, -1) {
+		label := ""
+		if len(loc) >= 4 && loc[2] >= 0 {
+			label = src[loc[2]:loc[3]]
+		}
+		var body strings.Builder
+		depth := 1
+		inString := false
+		for i := loc[1]; i < len(src) && depth > 0; i++ {
+			c := src[i]
+			switch {
+			case inString:
+				if c == '\\' && i+1 < len(src) {
+					if depth == 1 {
+						body.WriteByte(c)
+						body.WriteByte(src[i+1])
+					}
+					i++
+					continue
+				}
+				if c == '"' {
+					inString = false
+				}
+			case c == '"':
+				inString = true
+			case c == '{':
+				depth++
+				continue
+			case c == '}':
+				depth--
+				continue
+			}
+			if depth == 1 {
+				body.WriteByte(c)
+			}
+		}
+		out = append(out, blockBody{label: label, text: body.String()})
+	}
+	return out
+}
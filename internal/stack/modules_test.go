@@ -0,0 +1,164 @@
+package stack
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseModuleSources(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"envs/prod/main.tf": `module "vpc" {
+  source  = "terraform-aws-modules/vpc/aws"
+  version = "5.1.0"
+
+  tags = {
+    source = "not-a-module"
+  }
+}
+
+module "network" {
+  source = "../../modules/network"
+  name   = "prod-${var.region}"
+}
+`,
+		"modules/network/main.tf": `module "subnets" {
+  source = "git::https://example.com/subnets.git?ref=v1.2.0"
+}
+
+module "loop" {
+  source = "../network"
+}
+`,
+		"modules/outside/main.tf": `module "x" { source = "../../../escape" }`,
+	})
+
+	got, err := ParseModuleSources(root, filepath.Join(root, "envs/prod"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := []ModuleSource{
+		{Module: "module.network", Source: "../../modules/network"},
+		{Module: "module.network.module.loop", Source: "../network"},
+		{Module: "module.network.module.subnets", Source: "git::https://example.com/subnets.git?ref=v1.2.0"},
+		{Module: "module.vpc", Source: "terraform-aws-modules/vpc/aws", Version: "5.1.0"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected sources:\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestParseModuleSourcesTerragrunt(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"live/app/terragrunt.hcl": `include "root" {
+  path = find_in_parent_folders()
+}
+
+terraform {
+  source = "git::https://example.com/app.git//stack?ref=v3.0.0"
+}
+`,
+	})
+	got, err := ParseModuleSources(root, filepath.Join(root, "live/app"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(got) != 1 || got[0].Module != "terraform" || got[0].Source != "git::https://example.com/app.git//stack?ref=v3.0.0" {
+		t.Fatalf("unexpected sources: %+v", got)
+	}
+}
+
+func TestCompareModuleSources(t *testing.T) {
+	previous := []ModuleSource{
+		{Module: "module.dns", Source: "./dns"},
+		{Module: "module.old", Source: "hashicorp/consul/aws", Version: "0.1.0"},
+		{Module: "module.vpc", Source: "terraform-aws-modules/vpc/aws", Version: "5.1.0"},
+	}
+	current := []ModuleSource{
+		{Module: "module.dns", Source: "./dns"},
+		{Module: "module.new", Source: "git::https://example.com/new.git?ref=v1"},
+		{Module: "module.vpc", Source: "terraform-aws-modules/vpc/aws", Version: "5.2.0"},
+	}
+	changes := CompareModuleSources(previous, current)
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %+v", changes)
+	}
+	if changes[0].Module != "module.new" || changes[0].Previous != nil || changes[0].Current == nil {
+		t.Fatalf("expected added module, got %+v", changes[0])
+	}
+	if changes[1].Module != "module.old" || changes[1].Previous == nil || changes[1].Current != nil {
+		t.Fatalf("expected removed module, got %+v", changes[1])
+	}
+	if changes[2].Module != "module.vpc" || changes[2].Previous.Version != "5.1.0" || changes[2].Current.Version != "5.2.0" {
+		t.Fatalf("expected version change, got %+v", changes[2])
+	}
+	if diff := CompareModuleSources(current, current); len(diff) != 0 {
+		t.Fatalf("expected no changes, got %+v", diff)
+	}
+}
+
+func TestBuildModuleIndex(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"envs/prod/main.tf":          "module \"network\" {\n  source = \"../../modules/network\"\n}\n",
+		"envs/dev/terragrunt.hcl":    "terraform {\n  source = \"${get_terragrunt_dir()}/../../modules//network\"\n}\n",
+		"envs/qa/main.tf":            "module \"vpc\" {\n  source = \"terraform-aws-modules/vpc/aws\"\n}\n",
+		"modules/network/main.tf":    "module \"subnets\" {\n  source = \"../subnets\"\n}\n",
+		"modules/subnets/main.tf":    "resource \"null_resource\" \"x\" {}\n",
+		"modules/network/outside.tf": "module \"escape\" {\n  source = \"../../../elsewhere\"\n}\n",
+	})
+
+	index := BuildModuleIndex(root, []string{"envs/prod", "envs/dev", "envs/qa"})
+	want := ModuleIndex{
+		"modules/network": {"envs/dev", "envs/prod"},
+		"modules/subnets": {"envs/dev", "envs/prod"},
+	}
+	if !reflect.DeepEqual(index, want) {
+		t.Fatalf("index = %v, want %v", index, want)
+	}
+	if got := index.Dependents("modules/subnets/main.tf"); !reflect.DeepEqual(got, []string{"envs/dev", "envs/prod"}) {
+		t.Fatalf("dependents = %v", got)
+	}
+	if got := index.Dependents("modules/networking/main.tf"); got != nil {
+		t.Fatalf("expected no dependents for a sibling prefix, got %v", got)
+	}
+}
+
+func TestModuleSourceUses(t *testing.T) {
+	git := ModuleSource{Module: "module.vpc", Source: "git::https://github.com/org/modules.git//vpc?ref=v1.2.0"}
+	registry := ModuleSource{Module: "module.eks", Source: "terraform-aws-modules/eks/aws", Version: "20.1.0"}
+	for _, tc := range []struct {
+		module      ModuleSource
+		source, ref string
+		want        bool
+	}{
+		{git, "https://github.com/org/modules.git", "v1.2.0", true},
+		{git, "git::https://github.com/org/modules.git//vpc", "", true},
+		{git, "https://github.com/org/modules.git?ref=v1.2.0", "", true},
+		{git, "https://github.com/org/modules.git", "v1.3.0", false},
+		{git, "https://github.com/org/modules", "", false},
+		{registry, "terraform-aws-modules/eks/aws", "20.1.0", true},
+		{registry, "terraform-aws-modules/eks/aws", "19.0.0", false},
+		{registry, "", "", false},
+	} {
+		if got := tc.module.Uses(tc.source, tc.ref); got != tc.want {
+			t.Errorf("%s Uses(%q, %q) = %v, want %v", tc.module.Source, tc.source, tc.ref, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package stack
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// AllOutputs is the OutputConsumers key for stacks that read a dependency's
+// outputs as a whole, e.g. `inputs = dependency.vpc.outputs`.
+const AllOutputs = "*"
+
+var (
+	outputBlockPattern     = regexp.MustCompile(`(?m)^\s*output\s+"([^"]+)"\s*\{`)
+	dependencyBlockPattern = regexp.MustCompile(`(?m)^\s*dependency\s+"([^"]+)"\s*\{`)
+	configPathAttrPattern  = regexp.MustCompile(`(?m)^\s*config_path\s*=\s*"([^"]*)"`)
+)
+
+// ParseOutputs lists the outputs declared by the .tf files in stackDir,
+// sorted. Outputs of called modules are not root outputs and are left out,
+// as are terragrunt stacks, whose outputs live in the module they source.
+func ParseOutputs(stackDir string) ([]string, error) {
+	entries, err := os.ReadDir(stackDir)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".tf") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(stackDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range outputBlockPattern.FindAllStringSubmatch(string(data), -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				out = append(out, m[1])
+			}
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// OutputConsumers maps the outputs of the stack at stackPath to the other
+// stacks in projectDir that read them through a terragrunt dependency block.
+// Stacks that take the dependency's outputs whole are listed under
+// AllOutputs. Consumers reading the stack's remote state directly, through
+// terraform_remote_state, cannot be told apart by path and are not found.
+func OutputConsumers(projectDir, stackPath string) map[string][]string {
+	root, err := filepath.Abs(projectDir)
+	if err != nil {
+		return nil
+	}
+	target := filepath.Clean(filepath.FromSlash(stackPath))
+	consumers := map[string][]string{}
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		if rel != "." && shouldIgnore(filepath.ToSlash(rel), defaultIgnore) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || d.Name() != "terragrunt.hcl" {
+			return nil
+		}
+		dir := filepath.Dir(rel)
+		if dir == target {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		for _, name := range consumedOutputs(string(data), dir, target) {
+			consumers[name] = append(consumers[name], filepath.ToSlash(dir))
+		}
+		return nil
+	})
+	if len(consumers) == 0 {
+		return nil
+	}
+	for name := range consumers {
+		sort.Strings(consumers[name])
+	}
+	return consumers
+}
+
+// consumedOutputs returns the outputs of target that a terragrunt.hcl in
+// dir reads through dependency blocks pointing at target.
+func consumedOutputs(src, dir, target string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, body := range blockBodies(src, dependencyBlockPattern) {
+		m := configPathAttrPattern.FindStringSubmatch(body.text)
+		if m == nil {
+			continue
+		}
+		configPath := strings.TrimPrefix(m[1], terragruntDirPrefix)
+		if filepath.Clean(filepath.Join(dir, filepath.FromSlash(configPath))) != target {
+			continue
+		}
+		ref := regexp.MustCompile(`dependency\.` + regexp.QuoteMeta(body.label) + `\.outputs(?:\.([A-Za-z_][A-Za-z0-9_-]*))?`)
+		for _, r := range ref.FindAllStringSubmatch(src, -1) {
+			name := r[1]
+			if name == "" {
+				name = AllOutputs
+			}
+			if !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,89 @@
+package stack
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseOutputs(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"vpc/outputs.tf": `output "vpc_id" {
+  value = aws_vpc.main.id
+}
+
+output "subnet_ids" {
+  value = aws_subnet.private[*].id
+}
+`,
+		"vpc/main.tf": `module "nat" {
+  source = "./nat"
+}
+`,
+		"vpc/nat/outputs.tf": `output "nat_ip" {
+  value = aws_eip.nat.public_ip
+}
+`,
+	})
+
+	got, err := ParseOutputs(filepath.Join(root, "vpc"))
+	if err != nil {
+		t.Fatalf("parse outputs: %v", err)
+	}
+	if want := []string{"subnet_ids", "vpc_id"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("outputs = %v, want %v", got, want)
+	}
+}
+
+func TestOutputConsumers(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"envs/prod/vpc/terragrunt.hcl": `terraform {
+  source = "../../../modules/vpc"
+}
+`,
+		"envs/prod/app/terragrunt.hcl": `dependency "network" {
+  config_path = "../vpc"
+}
+
+inputs = {
+  vpc_id  = dependency.network.outputs.vpc_id
+  subnets = dependency.network.outputs.subnet_ids
+}
+`,
+		"envs/prod/db/terragrunt.hcl": `dependency "vpc" {
+  config_path = "${get_terragrunt_dir()}/../vpc"
+}
+
+inputs = dependency.vpc.outputs
+`,
+		"envs/staging/app/terragrunt.hcl": `dependency "network" {
+  config_path = "../vpc"
+}
+
+inputs = {
+  vpc_id = dependency.network.outputs.vpc_id
+}
+`,
+		"envs/prod/.terragrunt-cache/x/terragrunt.hcl": `dependency "network" {
+  config_path = "../../vpc"
+}
+
+inputs = { vpc_id = dependency.network.outputs.vpc_id }
+`,
+	})
+
+	got := OutputConsumers(root, "envs/prod/vpc")
+	want := map[string][]string{
+		"vpc_id":     {"envs/prod/app"},
+		"subnet_ids": {"envs/prod/app"},
+		AllOutputs:   {"envs/prod/db"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("consumers = %v, want %v", got, want)
+	}
+	if got := OutputConsumers(root, "envs/prod/app"); got != nil {
+		t.Fatalf("expected no consumers of app, got %v", got)
+	}
+}
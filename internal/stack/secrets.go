@@ -0,0 +1,133 @@
+package stack
+
+import (
+	"bufio"
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Secret rules reported by FindSecrets.
+const (
+	SecretRulePrivateKey   = "private_key"
+	SecretRuleAWSAccessKey = "aws_access_key"
+	SecretRuleTFVars       = "tfvars_secret"
+	SecretRuleCustom       = "custom"
+)
+
+const (
+	// MaxSecretFindings caps how many findings FindSecrets returns.
+	MaxSecretFindings = 50
+	// secretScanMaxFileBytes skips files too large to be hand-written config.
+	secretScanMaxFileBytes = 1 << 20
+)
+
+var (
+	privateKeyHeaderPattern = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY( BLOCK)?-----`)
+	awsAccessKeyIDPattern   = regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)
+	// tfvarsSecretPattern matches a literal, non-empty value assigned to a
+	// secret-looking variable, in HCL or JSON tfvars.
+	tfvarsSecretPattern = regexp.MustCompile(`(?i)^\s*"?[a-z0-9_-]*(?:password|passwd|secret|token|private_key|access_key)[a-z0-9_-]*"?\s*[=:]\s*"[^"\s]+"`)
+)
+
+// SecretFinding is a line that looks like a committed secret. The secret
+// itself is never recorded.
+type SecretFinding struct {
+	Path string
+	Line int
+	Rule string
+}
+
+// FindSecrets walks the checked-out project (limited to rootPath when set)
+// for committed private keys, AWS access key IDs, literal secrets in tfvars
+// files and lines matching any extra pattern. Build and vendor directories
+// are skipped, as are binary files and files over 1 MiB. At most
+// MaxSecretFindings findings are returned; paths are relative to projectDir.
+func FindSecrets(projectDir, rootPath string, extra []*regexp.Regexp) ([]SecretFinding, error) {
+	scopeRoot, err := cleanRootPath(rootPath)
+	if err != nil {
+		return nil, err
+	}
+	walkRoot := projectDir
+	if scopeRoot != "" {
+		walkRoot = filepath.Join(projectDir, filepath.FromSlash(scopeRoot))
+	}
+
+	var findings []SecretFinding
+	err = filepath.WalkDir(walkRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(projectDir, path)
+		if rel == "." {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if shouldIgnore(rel, defaultIgnore) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		for _, f := range scanFileForSecrets(path, rel, extra) {
+			if len(findings) == MaxSecretFindings {
+				return filepath.SkipAll
+			}
+			findings = append(findings, f)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return findings, nil
+}
+
+func scanFileForSecrets(path, rel string, extra []*regexp.Regexp) []SecretFinding {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > secretScanMaxFileBytes {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || bytes.IndexByte(data[:min(len(data), 8000)], 0) >= 0 {
+		return nil
+	}
+	tfvars := isTFVarsFile(filepath.Base(path))
+
+	var findings []SecretFinding
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), secretScanMaxFileBytes)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		rule := ""
+		switch {
+		case privateKeyHeaderPattern.MatchString(text):
+			rule = SecretRulePrivateKey
+		case awsAccessKeyIDPattern.MatchString(text):
+			rule = SecretRuleAWSAccessKey
+		case tfvars && tfvarsSecretPattern.MatchString(text):
+			rule = SecretRuleTFVars
+		default:
+			for _, re := range extra {
+				if re.MatchString(text) {
+					rule = SecretRuleCustom
+					break
+				}
+			}
+		}
+		if rule != "" {
+			findings = append(findings, SecretFinding{Path: rel, Line: line, Rule: rule})
+		}
+	}
+	return findings
+}
+
+func isTFVarsFile(name string) bool {
+	return strings.HasSuffix(name, ".tfvars") || strings.HasSuffix(name, ".tfvars.json")
+}
@@ -0,0 +1,138 @@
+package stack
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxTagValueLen bounds tag values so a stray comment cannot bloat results.
+const maxTagValueLen = 128
+
+var (
+	tagCommentPattern = regexp.MustCompile(`^\s*(?:#|//)\s*driftd:(.*)$`)
+	tagKeyPattern     = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+	tagLocalsPattern  = regexp.MustCompile(`(?s)driftd_tags\s*=\s*\{(.*?)\}`)
+	tagLocalsEntry    = regexp.MustCompile(`([A-Za-z0-9_.-]+)\s*=\s*"([^"]*)"`)
+)
+
+// ParseTags reads lightweight metadata from the stack's own .tf and
+// terragrunt.hcl files. Two forms are recognised:
+//
+//	# driftd:team=payments tier=critical
+//	locals { driftd_tags = { team = "payments", tier = "critical" } }
+//
+// Keys are case-insensitive and normalised to lowercase. When the same key is
+// set more than once, the value from the file that sorts last wins.
+func ParseTags(stackDir string) (map[string]string, error) {
+	entries, err := os.ReadDir(stackDir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !isStackFile(entry.Name()) {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	tags := map[string]string{}
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(stackDir, name))
+		if err != nil {
+			return nil, err
+		}
+		parseTagSource(string(data), tags)
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	return tags, nil
+}
+
+// DiscoverTags parses tags for each discovered stack. Stacks whose files
+// cannot be read or carry no tags are omitted.
+func DiscoverTags(projectDir string, stacks []string) map[string]map[string]string {
+	out := map[string]map[string]string{}
+	for _, stackPath := range stacks {
+		tags, err := ParseTags(filepath.Join(projectDir, filepath.FromSlash(stackPath)))
+		if err != nil || len(tags) == 0 {
+			continue
+		}
+		out[stackPath] = tags
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+func parseTagSource(src string, tags map[string]string) {
+	scanner := bufio.NewScanner(strings.NewReader(src))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		m := tagCommentPattern.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		for _, field := range strings.Fields(m[1]) {
+			key, value, ok := strings.Cut(field, "=")
+			if ok {
+				setTag(tags, key, strings.Trim(value, `"'`))
+			}
+		}
+	}
+	for _, block := range tagLocalsPattern.FindAllStringSubmatch(src, -1) {
+		for _, entry := range tagLocalsEntry.FindAllStringSubmatch(block[1], -1) {
+			setTag(tags, entry[1], entry[2])
+		}
+	}
+}
+
+func setTag(tags map[string]string, key, value string) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	value = strings.TrimSpace(value)
+	if !tagKeyPattern.MatchString(key) || value == "" || len(value) > maxTagValueLen {
+		return
+	}
+	tags[key] = value
+}
+
+// ParseTagFilter parses a "key:value" or "key=value" filter expression. A bare
+// key matches any stack that has the tag set.
+func ParseTagFilter(expr string) (key, value string, ok bool) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return "", "", false
+	}
+	if i := strings.IndexAny(expr, ":="); i >= 0 {
+		key, value = expr[:i], strings.TrimSpace(expr[i+1:])
+	} else {
+		key = expr
+	}
+	key = strings.ToLower(strings.TrimSpace(key))
+	if !tagKeyPattern.MatchString(key) {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+// MatchTags reports whether tags satisfy every filter. Filter values are
+// compared case-insensitively; an empty value only requires the key.
+func MatchTags(tags, filters map[string]string) bool {
+	for key, want := range filters {
+		got, ok := tags[key]
+		if !ok {
+			return false
+		}
+		if want != "" && !strings.EqualFold(got, want) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package stack
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	varAssignPattern   = regexp.MustCompile(`(?m)^\s*([A-Za-z_][A-Za-z0-9_-]*)\s*=\s*"([^"\n]*)"\s*$`)
+	inputsBlockPattern = regexp.MustCompile(`(?m)^\s*inputs\s*=\s*\{`)
+	inputsEntryPattern = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_-]*)\s*=\s*"([^"\n]*)"`)
+)
+
+// ParseVars returns the string values of the named variables as the stack
+// sets them: terragrunt inputs, then terraform.tfvars, terraform.tfvars.json
+// and *.auto.tfvars(.json) in lexical order, later files winning as in
+// terraform. Only literal strings are read; values built from expressions
+// or interpolations are skipped.
+func ParseVars(stackDir string, names []string) map[string]string {
+	if len(names) == 0 {
+		return nil
+	}
+	want := make(map[string]bool, len(names))
+	for _, name := range names {
+		want[name] = true
+	}
+	vars := map[string]string{}
+	set := func(name, value string) {
+		if want[name] && value != "" && !strings.Contains(value, "${") {
+			vars[name] = value
+		}
+	}
+
+	if data, err := os.ReadFile(filepath.Join(stackDir, "terragrunt.hcl")); err == nil {
+		for _, body := range blockBodies(string(data), inputsBlockPattern) {
+			for _, m := range inputsEntryPattern.FindAllStringSubmatch(body.text, -1) {
+				set(m[1], m[2])
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(stackDir)
+	if err != nil {
+		return nilIfEmpty(vars)
+	}
+	var auto []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && (strings.HasSuffix(name, ".auto.tfvars") || strings.HasSuffix(name, ".auto.tfvars.json")) {
+			auto = append(auto, name)
+		}
+	}
+	sort.Strings(auto)
+	for _, name := range append([]string{"terraform.tfvars", "terraform.tfvars.json"}, auto...) {
+		data, err := os.ReadFile(filepath.Join(stackDir, name))
+		if err != nil {
+			continue
+		}
+		if strings.HasSuffix(name, ".json") {
+			var values map[string]any
+			if json.Unmarshal(data, &values) != nil {
+				continue
+			}
+			for key, value := range values {
+				if s, ok := value.(string); ok {
+					set(key, s)
+				}
+			}
+			continue
+		}
+		for _, m := range varAssignPattern.FindAllStringSubmatch(string(data), -1) {
+			set(m[1], m[2])
+		}
+	}
+	return nilIfEmpty(vars)
+}
+
+func nilIfEmpty(vars map[string]string) map[string]string {
+	if len(vars) == 0 {
+		return nil
+	}
+	return vars
+}
@@ -0,0 +1,46 @@
+package stack
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseVars(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"terragrunt.hcl": `
+include "root" { path = find_in_parent_folders() }
+inputs = { region = "us-west-2", account_id = "111111111111", env = "prod" }
+`,
+		"terraform.tfvars": `
+region  = "us-east-1"
+name    = "${var.env}-app"
+tags = {
+  team = "payments"
+}
+`,
+		"b.auto.tfvars.json": `{"account_id": "222222222222", "count": 3}`,
+		"a.auto.tfvars":      `account_id = "333333333333"`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	got := ParseVars(dir, []string{"region", "account_id", "env", "name", "count", "missing"})
+	want := map[string]string{"region": "us-east-1", "account_id": "222222222222", "env": "prod"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for key, value := range want {
+		if got[key] != value {
+			t.Errorf("%s = %q, want %q", key, got[key], value)
+		}
+	}
+
+	if vars := ParseVars(dir, nil); vars != nil {
+		t.Fatalf("expected no vars without names, got %v", vars)
+	}
+}
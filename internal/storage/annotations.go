@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const annotationsFile = "annotations.json"
+
+// StackAnnotations holds operator-set state for a stack that lives alongside
+// its scan results and survives new runs.
+type StackAnnotations struct {
+	Suppressed     bool      `json:"suppressed,omitempty"`
+	SuppressedBy   string    `json:"suppressed_by,omitempty"`
+	SuppressedAt   time.Time `json:"suppressed_at,omitempty"`
+	Acknowledged   bool      `json:"acknowledged,omitempty"`
+	AcknowledgedBy string    `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt time.Time `json:"acknowledged_at,omitempty"`
+}
+
+// SetStackSuppressed marks a stack as suppressed (muted from project drift
+// totals) or clears the flag.
+func (s *Storage) SetStackSuppressed(projectName, stackPath string, suppressed bool, actor string) error {
+	return s.updateAnnotations(projectName, stackPath, func(a *StackAnnotations) {
+		a.Suppressed = suppressed
+		a.SuppressedBy = ""
+		a.SuppressedAt = time.Time{}
+		if suppressed {
+			a.SuppressedBy = actor
+			a.SuppressedAt = time.Now()
+		}
+	})
+}
+
+// SetStackAcknowledged records that the current drift on a stack has been
+// seen. The acknowledgement is cleared once the stack reports no drift.
+func (s *Storage) SetStackAcknowledged(projectName, stackPath string, acknowledged bool, actor string) error {
+	return s.updateAnnotations(projectName, stackPath, func(a *StackAnnotations) {
+		a.Acknowledged = acknowledged
+		a.AcknowledgedBy = ""
+		a.AcknowledgedAt = time.Time{}
+		if acknowledged {
+			a.AcknowledgedBy = actor
+			a.AcknowledgedAt = time.Now()
+		}
+	})
+}
+
+// GetStackAnnotations returns the annotations for a stack. A stack without
+// annotations yields a zero value.
+func (s *Storage) GetStackAnnotations(projectName, stackPath string) (*StackAnnotations, error) {
+	if err := validateProjectName(projectName); err != nil {
+		return nil, err
+	}
+	if err := validateStackPath(stackPath); err != nil {
+		return nil, err
+	}
+	return s.readAnnotations(projectName, stackPath)
+}
+
+func (s *Storage) readAnnotations(projectName, stackPath string) (*StackAnnotations, error) {
+	relPath := filepath.Join(projectName, safePath(stackPath), annotationsFile)
+	data, err := readFileUnder(s.resultsDir(), relPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &StackAnnotations{}, nil
+		}
+		return nil, err
+	}
+	var a StackAnnotations
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (s *Storage) updateAnnotations(projectName, stackPath string, mutate func(*StackAnnotations)) error {
+	if err := validateProjectName(projectName); err != nil {
+		return err
+	}
+	if err := validateStackPath(stackPath); err != nil {
+		return err
+	}
+
+	lock := s.stackLock(projectName, stackPath)
+	lock.Lock()
+	defer lock.Unlock()
+	defer s.cache.invalidate(projectName)
+
+	a, err := s.readAnnotations(projectName, stackPath)
+	if err != nil {
+		return err
+	}
+	mutate(a)
+
+	dir := s.stackDir(s.resultsDir(), projectName, stackPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.writeFileAtomic(filepath.Join(dir, annotationsFile), data, 0600)
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSuppressedStacksExcludedFromProjectDrift(t *testing.T) {
+	s := New(t.TempDir())
+	s.SaveResult("repo1", "envs/dev", &RunResult{Drifted: true, RunAt: time.Now()})
+	s.SaveResult("repo1", "envs/prod", &RunResult{Drifted: false, RunAt: time.Now()})
+
+	if err := s.SetStackSuppressed("repo1", "envs/dev", true, "alice"); err != nil {
+		t.Fatalf("suppress: %v", err)
+	}
+
+	projects, err := s.ListRepos()
+	if err != nil {
+		t.Fatalf("list projects: %v", err)
+	}
+	if len(projects) != 1 || projects[0].Drifted || projects[0].DriftedStacks != 0 {
+		t.Fatalf("expected suppressed drift to be excluded, got %+v", projects)
+	}
+
+	stacks, err := s.ListStacks("repo1")
+	if err != nil {
+		t.Fatalf("list stacks: %v", err)
+	}
+	for _, st := range stacks {
+		if st.Path == "envs/dev" && (!st.Suppressed || !st.Drifted) {
+			t.Fatalf("expected envs/dev drifted and suppressed, got %+v", st)
+		}
+	}
+
+	a, err := s.GetStackAnnotations("repo1", "envs/dev")
+	if err != nil {
+		t.Fatalf("get annotations: %v", err)
+	}
+	if a.SuppressedBy != "alice" || a.SuppressedAt.IsZero() {
+		t.Fatalf("expected suppression metadata, got %+v", a)
+	}
+
+	if err := s.SetStackSuppressed("repo1", "envs/dev", false, "alice"); err != nil {
+		t.Fatalf("unsuppress: %v", err)
+	}
+	projects, _ = s.ListRepos()
+	if !projects[0].Drifted {
+		t.Fatalf("expected project drifted after unsuppress")
+	}
+}
+
+func TestAcknowledgementClearedWhenStackHealthy(t *testing.T) {
+	s := New(t.TempDir())
+	s.SaveResult("repo1", "envs/dev", &RunResult{Drifted: true, RunAt: time.Now()})
+
+	if err := s.SetStackAcknowledged("repo1", "envs/dev", true, "bob"); err != nil {
+		t.Fatalf("acknowledge: %v", err)
+	}
+
+	// Still drifted: acknowledgement sticks.
+	s.SaveResult("repo1", "envs/dev", &RunResult{Drifted: true, RunAt: time.Now()})
+	a, _ := s.GetStackAnnotations("repo1", "envs/dev")
+	if !a.Acknowledged || a.AcknowledgedBy != "bob" {
+		t.Fatalf("expected acknowledgement to persist while drifted, got %+v", a)
+	}
+
+	s.SaveResult("repo1", "envs/dev", &RunResult{Drifted: false, RunAt: time.Now()})
+	a, _ = s.GetStackAnnotations("repo1", "envs/dev")
+	if a.Acknowledged {
+		t.Fatalf("expected acknowledgement cleared after healthy run, got %+v", a)
+	}
+}
+
+func TestAnnotationsRejectInvalidStackPath(t *testing.T) {
+	s := New(t.TempDir())
+	if err := s.SetStackSuppressed("repo1", "../escape", true, ""); err != ErrInvalidStackPath {
+		t.Fatalf("expected ErrInvalidStackPath, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"sync"
+	"time"
+)
+
+// racyModWindow is how recently a stack directory may have changed for its
+// cached status to be distrusted. Network filesystems store modification
+// times coarsely, so a write right after a read can leave the time as is.
+const racyModWindow = 2 * time.Second
+
+// listingCache keeps ListStacks results in memory. A listing is served as is
+// for ttl; after that the project's stack directories are listed again and
+// only those whose modification time changed are re-read. Writes through
+// the same Storage invalidate the listing at once; results written by other
+// processes show up within ttl.
+type listingCache struct {
+	ttl      time.Duration
+	mu       sync.Mutex
+	projects map[string]*projectListing
+}
+
+type projectListing struct {
+	loadedAt time.Time
+	// stale is set by a local write. The listing is rebuilt on the next
+	// read, still reusing unchanged directories.
+	stale  bool
+	stacks []StackStatus
+	// dirs holds the status read from each stack directory, by path.
+	dirs map[string]cachedStackDir
+}
+
+type cachedStackDir struct {
+	modTime time.Time
+	status  StackStatus
+}
+
+func newListingCache(ttl time.Duration) *listingCache {
+	return &listingCache{ttl: ttl, projects: make(map[string]*projectListing)}
+}
+
+// get returns the cached listing when it is still fresh, and otherwise the
+// cached directories it can be rebuilt from.
+func (c *listingCache) get(projectName string, now time.Time) ([]StackStatus, map[string]cachedStackDir, bool) {
+	if c == nil {
+		return nil, nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	listing, ok := c.projects[projectName]
+	if !ok {
+		return nil, nil, false
+	}
+	if !listing.stale && now.Sub(listing.loadedAt) < c.ttl {
+		return listing.stacks, nil, true
+	}
+	return nil, listing.dirs, false
+}
+
+func (c *listingCache) put(projectName string, now time.Time, stacks []StackStatus, dirs map[string]cachedStackDir) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.projects[projectName] = &projectListing{loadedAt: now, stacks: stacks, dirs: dirs}
+}
+
+func (c *listingCache) invalidate(projectName string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if listing, ok := c.projects[projectName]; ok {
+		listing.stale = true
+	}
+}
+
+// reusable reports whether a cached directory status still matches the
+// directory's modification time.
+func (d cachedStackDir) reusable(modTime, now time.Time) bool {
+	return !modTime.IsZero() && d.modTime.Equal(modTime) && now.Sub(modTime) >= racyModWindow
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func stackDrifted(t *testing.T, s *Storage, projectName, stackPath string) bool {
+	t.Helper()
+	stacks, err := s.ListStacks(projectName)
+	if err != nil {
+		t.Fatalf("list stacks: %v", err)
+	}
+	for _, st := range stacks {
+		if st.Path == stackPath {
+			return st.Drifted
+		}
+	}
+	t.Fatalf("stack %s not listed", stackPath)
+	return false
+}
+
+func TestListStacksCache(t *testing.T) {
+	dir := t.TempDir()
+	s := NewWithOptions(dir, Options{CacheTTL: time.Hour})
+	other := New(dir)
+
+	if err := s.SaveResult("project", "envs/prod", &RunResult{RunAt: time.Now()}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if stackDrifted(t, s, "project", "envs/prod") {
+		t.Fatal("expected clean stack")
+	}
+
+	// Writes through the same Storage are visible at once.
+	if err := s.SaveResult("project", "envs/prod", &RunResult{Drifted: true, RunAt: time.Now()}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if !stackDrifted(t, s, "project", "envs/prod") {
+		t.Fatal("expected local write to invalidate the listing")
+	}
+	if err := s.SetStackSuppressed("project", "envs/prod", true, "alice"); err != nil {
+		t.Fatalf("suppress: %v", err)
+	}
+	stacks, _ := s.ListStacks("project")
+	if len(stacks) != 1 || !stacks[0].Suppressed {
+		t.Fatalf("expected suppression to be listed, got %+v", stacks)
+	}
+
+	// Writes by another process are served from cache until the TTL.
+	if err := other.SaveResult("project", "envs/prod", &RunResult{RunAt: time.Now()}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if !stackDrifted(t, s, "project", "envs/prod") {
+		t.Fatal("expected cached listing within the TTL")
+	}
+
+	// Callers may modify what they get back.
+	stacks, _ = s.ListStacks("project")
+	stacks[0].Severity = 99
+	if again, _ := s.ListStacks("project"); again[0].Severity != 0 {
+		t.Fatal("expected ListStacks to return a copy of the cache")
+	}
+}
+
+func TestListStacksCacheRereadsChangedDirectories(t *testing.T) {
+	dir := t.TempDir()
+	s := NewWithOptions(dir, Options{CacheTTL: time.Nanosecond})
+	other := New(dir)
+
+	for _, stackPath := range []string{"a", "b"} {
+		if err := other.SaveResult("project", stackPath, &RunResult{RunAt: time.Now()}); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+	old := time.Now().Add(-time.Hour)
+	stackDir := func(stackPath string) string {
+		return s.stackDir(s.resultsDir(), "project", stackPath)
+	}
+	for _, stackPath := range []string{"a", "b"} {
+		if err := os.Chtimes(stackDir(stackPath), old, old); err != nil {
+			t.Fatalf("chtimes: %v", err)
+		}
+	}
+	if _, err := s.ListStacks("project"); err != nil {
+		t.Fatalf("list stacks: %v", err)
+	}
+
+	// An unchanged directory is not read again: corrupting its status
+	// without touching the directory goes unnoticed.
+	if err := os.WriteFile(filepath.Join(stackDir("a"), "status.json"), []byte(`{"drifted": true}`), 0600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Chtimes(stackDir("a"), old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	if err := other.SaveResult("project", "b", &RunResult{Drifted: true, RunAt: time.Now()}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if stackDrifted(t, s, "project", "a") {
+		t.Fatal("expected unchanged directory to be served from cache")
+	}
+	if !stackDrifted(t, s, "project", "b") {
+		t.Fatal("expected changed directory to be read again")
+	}
+}
+
+func TestFsyncIntervalFlushesOnClose(t *testing.T) {
+	dir := t.TempDir()
+	s := NewWithOptions(dir, Options{Fsync: FsyncInterval, FsyncInterval: time.Hour})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stackPath := filepath.Join("envs", string(rune('a'+i)))
+			if err := s.SaveResult("project", stackPath, &RunResult{Drifted: true, ScanID: "scan-1", RunAt: time.Now()}); err != nil {
+				t.Errorf("save: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.syncMu.Lock()
+	pending := len(s.pendingSync)
+	s.syncMu.Unlock()
+	if pending == 0 {
+		t.Fatal("expected writes to wait for the next flush")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+	if len(s.pendingSync) != 0 {
+		t.Fatalf("expected close to flush pending writes, %d left", len(s.pendingSync))
+	}
+
+	stacks, err := New(dir).ListStacks("project")
+	if err != nil || len(stacks) != 20 {
+		t.Fatalf("expected 20 stacks, got %d (%v)", len(stacks), err)
+	}
+	for _, st := range stacks {
+		history, err := s.StackHistory("project", st.Path, time.Time{})
+		if err != nil || len(history) != 1 {
+			t.Fatalf("expected one history entry for %s, got %d (%v)", st.Path, len(history), err)
+		}
+	}
+}
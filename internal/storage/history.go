@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	historyFile = "history.jsonl"
+
+	// HistoryRetention is how far back per-stack run history is kept.
+	HistoryRetention = 30 * 24 * time.Hour
+	// maxHistoryEntries caps the history of stacks scanned very frequently.
+	maxHistoryEntries = 2000
+)
+
+// HistoryEntry is the outcome of a single stack run.
+type HistoryEntry struct {
+	RunAt   time.Time `json:"run_at"`
+	Drifted bool      `json:"drifted,omitempty"`
+	Errored bool      `json:"errored,omitempty"`
+	// ScanID is the scan that produced the run, when known. Its result can
+	// be read back with GetScanResult while the snapshot is retained.
+	ScanID string `json:"scan_id,omitempty"`
+}
+
+// StackHistory returns the stack's run outcomes at or after since, oldest
+// first. A stack without history yields an empty slice.
+func (s *Storage) StackHistory(projectName, stackPath string, since time.Time) ([]HistoryEntry, error) {
+	if err := validateProjectName(projectName); err != nil {
+		return nil, err
+	}
+	if err := validateStackPath(stackPath); err != nil {
+		return nil, err
+	}
+	entries, err := s.readHistory(projectName, stackPath)
+	if err != nil {
+		return nil, err
+	}
+	out := entries[:0]
+	for _, entry := range entries {
+		if !entry.RunAt.Before(since) {
+			out = append(out, entry)
+		}
+	}
+	return out, nil
+}
+
+// appendHistory records a run, drops entries past HistoryRetention and
+// returns the entries kept. Callers must hold the stack's lock.
+func (s *Storage) appendHistory(projectName, stackPath string, result *RunResult) ([]HistoryEntry, error) {
+	entries, err := s.readHistory(projectName, stackPath)
+	if err != nil {
+		return nil, err
+	}
+	runAt := result.RunAt
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+	entries = append(entries, HistoryEntry{
+		RunAt:   runAt.UTC(),
+		Drifted: result.Drifted,
+		Errored: result.Error != "",
+		ScanID:  result.ScanID,
+	})
+	return s.writeHistory(projectName, stackPath, entries)
+}
+
+// writeHistory drops entries past HistoryRetention or over the cap, writes
+// the rest and returns them. Callers must hold the stack's lock.
+func (s *Storage) writeHistory(projectName, stackPath string, entries []HistoryEntry) ([]HistoryEntry, error) {
+	cutoff := time.Now().Add(-HistoryRetention)
+	kept := entries[:0]
+	for _, entry := range entries {
+		if entry.RunAt.After(cutoff) {
+			kept = append(kept, entry)
+		}
+	}
+	if len(kept) > maxHistoryEntries {
+		kept = kept[len(kept)-maxHistoryEntries:]
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, entry := range kept {
+		if err := enc.Encode(entry); err != nil {
+			return nil, err
+		}
+	}
+	path := filepath.Join(s.stackDir(s.resultsDir(), projectName, stackPath), historyFile)
+	return kept, s.writeFileAtomic(path, buf.Bytes(), 0600)
+}
+
+func (s *Storage) readHistory(projectName, stackPath string) ([]HistoryEntry, error) {
+	relPath := filepath.Join(projectName, safePath(stackPath), historyFile)
+	data, err := readFileUnder(s.resultsDir(), relPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []HistoryEntry{}, nil
+		}
+		return nil, err
+	}
+	var entries []HistoryEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var entry HistoryEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ImportOutcome reports what ImportResult did with a result.
+type ImportOutcome struct {
+	// History is set when the run was added to the stack's history. Runs
+	// already recorded at the same time or past HistoryRetention are not.
+	History bool
+	// Latest is set when the result became the stack's current result.
+	Latest bool
+}
+
+// ImportResult records a result produced outside driftd, such as history
+// migrated from another drift tool. Unlike SaveResult, results may arrive in
+// any order: the run is inserted into the history by RunAt, and it only
+// replaces the stack's current result when it is newer. Importing the same
+// result twice records it once.
+func (s *Storage) ImportResult(projectName, stackPath string, result *RunResult) (ImportOutcome, error) {
+	var outcome ImportOutcome
+	if err := validateProjectName(projectName); err != nil {
+		return outcome, err
+	}
+	if err := validateStackPath(stackPath); err != nil {
+		return outcome, err
+	}
+	if result.RunAt.IsZero() {
+		return outcome, errors.New("imported result needs a run time")
+	}
+	runAt := result.RunAt.UTC()
+
+	dir := s.stackDir(s.resultsDir(), projectName, stackPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return outcome, err
+	}
+	defer s.cache.invalidate(projectName)
+
+	lock := s.stackLock(projectName, stackPath)
+	lock.Lock()
+	defer lock.Unlock()
+
+	current, err := s.readResult(projectName, stackPath, false)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return outcome, err
+	}
+	if current == nil || current.RunAt.Before(runAt) {
+		statusData, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return outcome, err
+		}
+		planOutput, err := s.encodePlanOutput(result.PlanOutput)
+		if err != nil {
+			return outcome, err
+		}
+		if err := s.writeFileAtomic(filepath.Join(dir, "status.json"), statusData, 0600); err != nil {
+			return outcome, err
+		}
+		if err := s.writeFileAtomic(filepath.Join(dir, "plan.txt"), []byte(planOutput), 0600); err != nil {
+			return outcome, err
+		}
+		outcome.Latest = true
+	}
+
+	if runAt.Before(time.Now().Add(-HistoryRetention)) {
+		return outcome, nil
+	}
+	entries, err := s.readHistory(projectName, stackPath)
+	if err != nil {
+		return outcome, err
+	}
+	i := sort.Search(len(entries), func(i int) bool { return !entries[i].RunAt.Before(runAt) })
+	if i < len(entries) && entries[i].RunAt.Equal(runAt) {
+		return outcome, nil
+	}
+	entries = append(entries, HistoryEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = HistoryEntry{
+		RunAt:   runAt,
+		Drifted: result.Drifted,
+		Errored: result.Error != "",
+		ScanID:  result.ScanID,
+	}
+	if _, err := s.writeHistory(projectName, stackPath, entries); err != nil {
+		return outcome, err
+	}
+	outcome.History = true
+	return outcome, nil
+}
+
+// ValidateResultKey reports whether results can be stored for projectName
+// and stackPath.
+func ValidateResultKey(projectName, stackPath string) error {
+	if err := validateProjectName(projectName); err != nil {
+		return err
+	}
+	return validateStackPath(stackPath)
+}
@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const projectMetadataFile = "metadata.json"
+
+// ProjectMetadata is operator-maintained context shown on a project's page:
+// what it is, who owns it and where to look when it drifts.
+type ProjectMetadata struct {
+	Description  string    `json:"description,omitempty"`
+	Owner        string    `json:"owner,omitempty"`
+	RunbookURL   string    `json:"runbook_url,omitempty"`
+	DashboardURL string    `json:"dashboard_url,omitempty"`
+	UpdatedBy    string    `json:"updated_by,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at,omitempty"`
+}
+
+// Empty reports whether no field meant for display is set.
+func (m *ProjectMetadata) Empty() bool {
+	return m == nil || (m.Description == "" && m.Owner == "" && m.RunbookURL == "" && m.DashboardURL == "")
+}
+
+// GetProjectMetadata returns a project's metadata. A project without
+// metadata yields a zero value.
+func (s *Storage) GetProjectMetadata(projectName string) (*ProjectMetadata, error) {
+	if err := validateProjectName(projectName); err != nil {
+		return nil, err
+	}
+	data, err := readFileUnder(s.resultsDir(), filepath.Join(projectName, projectMetadataFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &ProjectMetadata{}, nil
+		}
+		return nil, err
+	}
+	var m ProjectMetadata
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// SetProjectMetadata replaces a project's metadata, stamping who changed it.
+func (s *Storage) SetProjectMetadata(projectName string, metadata ProjectMetadata, actor string) error {
+	if err := validateProjectName(projectName); err != nil {
+		return err
+	}
+	metadata.UpdatedBy = actor
+	metadata.UpdatedAt = time.Now()
+
+	dir := filepath.Join(s.resultsDir(), projectName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return s.writeFileAtomic(filepath.Join(dir, projectMetadataFile), data, 0600)
+}
@@ -0,0 +1,37 @@
+package storage
+
+import "testing"
+
+func TestProjectMetadataRoundTrip(t *testing.T) {
+	s := New(t.TempDir())
+
+	m, err := s.GetProjectMetadata("repo1")
+	if err != nil || !m.Empty() {
+		t.Fatalf("expected empty metadata, got %+v (%v)", m, err)
+	}
+
+	if err := s.SetProjectMetadata("repo1", ProjectMetadata{
+		Description: "Core networking",
+		Owner:       "team-platform",
+		RunbookURL:  "https://wiki.example.com/runbooks/network",
+	}, "alice"); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	m, err = s.GetProjectMetadata("repo1")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if m.Owner != "team-platform" || m.RunbookURL == "" || m.UpdatedBy != "alice" || m.UpdatedAt.IsZero() {
+		t.Fatalf("unexpected metadata %+v", m)
+	}
+
+	s.SaveResult("repo1", "envs/dev", &RunResult{})
+	stacks, err := s.ListStacks("repo1")
+	if err != nil || len(stacks) != 1 {
+		t.Fatalf("metadata file must not be listed as a stack, got %+v (%v)", stacks, err)
+	}
+
+	if err := s.SetProjectMetadata("../etc", ProjectMetadata{}, ""); err != ErrInvalidProjectName {
+		t.Fatalf("expected ErrInvalidProjectName, got %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	runsDir = "runs"
+	// maxRunSnapshots caps how many per-scan results are kept for each
+	// stack. History entries outlive their snapshot past this cap.
+	maxRunSnapshots = 200
+)
+
+// ErrScanResultNotFound is returned when a stack has no retained result for
+// the requested scan.
+var ErrScanResultNotFound = errors.New("no result retained for this scan")
+
+// GetScanResult returns the result the stack recorded in scan scanID, as it
+// was reported at the time.
+func (s *Storage) GetScanResult(projectName, stackPath, scanID string) (*RunResult, error) {
+	if err := validateProjectName(projectName); err != nil {
+		return nil, err
+	}
+	if err := validateStackPath(stackPath); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(scanID) == "" {
+		return nil, ErrScanResultNotFound
+	}
+
+	runRelDir := filepath.Join(projectName, safePath(stackPath), runsDir, safePath(scanID))
+	statusData, err := readFileUnder(s.resultsDir(), filepath.Join(runRelDir, "status.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrScanResultNotFound
+		}
+		return nil, err
+	}
+	var result RunResult
+	if err := json.Unmarshal(statusData, &result); err != nil {
+		return nil, err
+	}
+	if planData, err := readFileUnder(s.resultsDir(), filepath.Join(runRelDir, "plan.txt")); err == nil {
+		result.PlanOutput = s.decodePlanOutput(string(planData))
+	}
+	return &result, nil
+}
+
+// saveRunSnapshot copies the stored status and encoded plan of a result
+// under runs/<scan>.
+func (s *Storage) saveRunSnapshot(stackDir, scanID string, statusData []byte, planOutput string) error {
+	dir := filepath.Join(stackDir, runsDir, safePath(scanID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := s.writeFileAtomic(filepath.Join(dir, "status.json"), statusData, 0600); err != nil {
+		return err
+	}
+	return s.writeFileAtomic(filepath.Join(dir, "plan.txt"), []byte(planOutput), 0600)
+}
+
+// pruneRunSnapshots removes snapshots whose scans are no longer among the
+// newest maxRunSnapshots history entries.
+func pruneRunSnapshots(stackDir string, history []HistoryEntry) {
+	entries, err := os.ReadDir(filepath.Join(stackDir, runsDir))
+	if err != nil {
+		return
+	}
+	if len(history) > maxRunSnapshots {
+		history = history[len(history)-maxRunSnapshots:]
+	}
+	keep := make(map[string]struct{}, len(history))
+	for _, entry := range history {
+		if entry.ScanID != "" {
+			keep[safePath(entry.ScanID)] = struct{}{}
+		}
+	}
+	for _, entry := range entries {
+		if _, ok := keep[entry.Name()]; !ok {
+			_ = os.RemoveAll(filepath.Join(stackDir, runsDir, entry.Name()))
+		}
+	}
+}
@@ -9,16 +9,28 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/driftdhq/driftd/internal/pathutil"
 	"github.com/driftdhq/driftd/internal/secrets"
+	"github.com/driftdhq/driftd/internal/stack"
 )
 
 type Storage struct {
 	dataDir              string
 	planEncryptor        *secrets.Encryptor
 	planEncryptorInitErr error
+	stackLocks           [stackLockStripes]sync.Mutex
+
+	fsync       string
+	syncMu      sync.Mutex
+	pendingSync map[string]struct{}
+	stopFlush   chan struct{}
+	flushDone   chan struct{}
+	closeOnce   sync.Once
+
+	cache *listingCache
 }
 
 type Store interface {
@@ -26,6 +38,20 @@ type Store interface {
 	GetResult(projectName, stackPath string) (*RunResult, error)
 	ListRepos() ([]ProjectStatus, error)
 	ListStacks(projectName string) ([]StackStatus, error)
+	SetStackSuppressed(projectName, stackPath string, suppressed bool, actor string) error
+	SetStackAcknowledged(projectName, stackPath string, acknowledged bool, actor string) error
+	StackHistory(projectName, stackPath string, since time.Time) ([]HistoryEntry, error)
+	GetScanResult(projectName, stackPath, scanID string) (*RunResult, error)
+	GetProjectMetadata(projectName string) (*ProjectMetadata, error)
+	SetProjectMetadata(projectName string, metadata ProjectMetadata, actor string) error
+}
+
+// CommitInfo describes the commit a scan checked out.
+type CommitInfo struct {
+	Author string `json:"author,omitempty"`
+	// Summary is the first line of the commit message.
+	Summary string    `json:"summary,omitempty"`
+	Time    time.Time `json:"time"`
 }
 
 type RunResult struct {
@@ -36,6 +62,95 @@ type RunResult struct {
 	PlanOutput string    `json:"-"`
 	Error      string    `json:"error,omitempty"`
 	RunAt      time.Time `json:"run_at"`
+	// Tags are parsed from the stack's driftd metadata at plan time.
+	Tags map[string]string `json:"tags,omitempty"`
+	// ProviderLockDrift lists differences between the committed
+	// .terraform.lock.hcl and the providers terraform init installed. It is
+	// reported separately from resource drift and does not set Drifted.
+	ProviderLockDrift []ProviderLockMismatch `json:"provider_lock_drift,omitempty"`
+	// ModuleSources are the module sources the stack referenced at plan time.
+	ModuleSources []stack.ModuleSource `json:"module_sources,omitempty"`
+	// ModuleSourceChanges lists module sources that changed since the
+	// previous run, a likely cause of new plan output.
+	ModuleSourceChanges []stack.ModuleSourceChange `json:"module_source_changes,omitempty"`
+	// ResourceChanges lists the resource actions parsed from the plan text.
+	ResourceChanges []ResourceChange `json:"resource_changes,omitempty"`
+	// ScanID, CommitSHA and the tool versions record which scan produced
+	// the result and what it ran, so past results can be reviewed after
+	// the scan itself has expired from the queue.
+	ScanID            string `json:"scan_id,omitempty"`
+	CommitSHA         string `json:"commit_sha,omitempty"`
+	TerraformVersion  string `json:"terraform_version,omitempty"`
+	TerragruntVersion string `json:"terragrunt_version,omitempty"`
+	// CommitInfo describes CommitSHA when the scan could read it.
+	CommitInfo *CommitInfo `json:"commit_info,omitempty"`
+	// NoisyClean marks a plan whose changes were all recognized as no-ops
+	// (whitespace, JSON re-marshaling, reordering). Drifted is false; the
+	// plan output is kept as planned.
+	NoisyClean bool `json:"noisy_clean,omitempty"`
+	// RootCauseHint is a guess at what caused the drift, such as "likely
+	// manual console edit", from the shape of the plan.
+	RootCauseHint string `json:"root_cause_hint,omitempty"`
+	// Outputs are the outputs the stack declared at plan time.
+	Outputs []string `json:"outputs,omitempty"`
+	// OutputChanges lists the outputs the plan would change. Other stacks
+	// and services read outputs through remote state, so these changes
+	// reach past the stack itself.
+	OutputChanges []OutputChange `json:"output_changes,omitempty"`
+	// BackendType is the state backend the stack declares, e.g. "s3", or
+	// empty when it declares none itself.
+	BackendType string `json:"backend_type,omitempty"`
+	// EnvNames are the names, never the values, of the environment
+	// variables the failing terraform or terragrunt command ran with. Only
+	// failed runs record them.
+	EnvNames []string `json:"env_names,omitempty"`
+	// ResourceOwners attribute drifted resources to the last commit that
+	// touched their HCL block. Only set for projects with blame_drift.
+	ResourceOwners []ResourceOwner `json:"resource_owners,omitempty"`
+	// ObservedDuringFreeze marks drift found while the stack's environment
+	// was frozen. It is recorded as usual but does not notify.
+	ObservedDuringFreeze bool `json:"observed_during_freeze,omitempty"`
+	// LinkVars are the stack's values of the variables the project's
+	// console links reference.
+	LinkVars map[string]string `json:"link_vars,omitempty"`
+}
+
+// ResourceOwner is the last commit to touch the block defining a drifted
+// resource. File is relative to the repository root and Line is where the
+// block starts.
+type ResourceOwner struct {
+	Address string    `json:"address"`
+	File    string    `json:"file"`
+	Line    int       `json:"line"`
+	Commit  string    `json:"commit"`
+	Author  string    `json:"author"`
+	Summary string    `json:"summary,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// ResourceChange is one resource action from a plan. Action is one of
+// create, update, delete, replace, read, import, forget or move.
+type ResourceChange struct {
+	Address string `json:"address"`
+	Action  string `json:"action"`
+}
+
+// OutputChange is one root module output a plan would change. Action is
+// create, update or delete. Consumers are the stacks of the same project
+// that read the output through a terragrunt dependency block.
+type OutputChange struct {
+	Name      string   `json:"name"`
+	Action    string   `json:"action"`
+	Consumers []string `json:"consumers,omitempty"`
+}
+
+// ProviderLockMismatch is one provider whose installed version does not match
+// the dependency lock file. Locked is empty for a provider missing from the
+// lock file; Installed is empty for a locked provider that was not installed.
+type ProviderLockMismatch struct {
+	Provider  string `json:"provider"`
+	Locked    string `json:"locked,omitempty"`
+	Installed string `json:"installed,omitempty"`
 }
 
 type ProjectStatus struct {
@@ -46,13 +161,34 @@ type ProjectStatus struct {
 }
 
 type StackStatus struct {
-	Path      string
-	Drifted   bool
-	Added     int
-	Changed   int
-	Destroyed int
-	Error     string
-	RunAt     time.Time
+	Path         string
+	Drifted      bool
+	Added        int
+	Changed      int
+	Destroyed    int
+	Error        string
+	RunAt        time.Time
+	Suppressed   bool
+	Acknowledged bool
+	Tags         map[string]string
+	// ProviderLockDrift counts provider lock mismatches from the last run.
+	ProviderLockDrift int
+	// ModuleSourceChanges counts module sources changed in the last run.
+	ModuleSourceChanges int
+	// NoisyClean is set when the last plan only had no-op changes.
+	NoisyClean bool
+	// RootCauseHint is the last drifted plan's root-cause hint.
+	RootCauseHint string
+	// OutputChanges are the output changes of the last plan.
+	OutputChanges []OutputChange
+	// ResourceChanges are the resource actions of the last plan.
+	ResourceChanges []ResourceChange
+	// ObservedDuringFreeze is set when the last drift was found during an
+	// environment freeze.
+	ObservedDuringFreeze bool
+	// Severity is the drift severity score. ListStacks leaves it 0; the
+	// severity package fills it in.
+	Severity int
 }
 
 var (
@@ -64,12 +200,34 @@ var (
 const encryptedPlanPrefix = "enc:v1:"
 
 func New(dataDir string) *Storage {
+	return NewWithOptions(dataDir, Options{})
+}
+
+// NewWithOptions returns a Storage with the given fsync policy and listing
+// cache. Call Close before exiting so FsyncInterval writes are synced.
+func NewWithOptions(dataDir string, opts Options) *Storage {
 	planEncryptor, planEncryptorInitErr := loadPlanEncryptorFromEnv()
-	return &Storage{
+	s := &Storage{
 		dataDir:              dataDir,
 		planEncryptor:        planEncryptor,
 		planEncryptorInitErr: planEncryptorInitErr,
+		fsync:                opts.Fsync,
+	}
+	switch s.fsync {
+	case FsyncInterval:
+		interval := opts.FsyncInterval
+		if interval <= 0 {
+			interval = defaultFsyncInterval
+		}
+		s.startFlusher(interval)
+	case FsyncNever:
+	default:
+		s.fsync = FsyncAlways
 	}
+	if opts.CacheTTL > 0 {
+		s.cache = newListingCache(opts.CacheTTL)
+	}
+	return s
 }
 
 func (s *Storage) resultsDir() string {
@@ -97,58 +255,42 @@ func (s *Storage) SaveResult(projectName, stackPath string, result *RunResult) e
 		return err
 	}
 
-	statusPath := filepath.Join(dir, "status.json")
 	statusData, err := json.MarshalIndent(result, "", "  ")
 	if err != nil {
 		return err
 	}
-	if err := writeFileAtomic(statusPath, statusData, 0600); err != nil {
-		return err
-	}
-
-	planPath := filepath.Join(dir, "plan.txt")
 	planOutput, err := s.encodePlanOutput(result.PlanOutput)
 	if err != nil {
 		return err
 	}
-	if err := writeFileAtomic(planPath, []byte(planOutput), 0600); err != nil {
-		return err
-	}
-
-	return nil
-}
+	defer s.cache.invalidate(projectName)
 
-func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
-	dir := filepath.Dir(path)
-	base := filepath.Base(path)
-
-	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
-	if err != nil {
-		return err
+	writes := []func() error{
+		func() error { return s.writeFileAtomic(filepath.Join(dir, "status.json"), statusData, 0600) },
+		func() error { return s.writeFileAtomic(filepath.Join(dir, "plan.txt"), []byte(planOutput), 0600) },
 	}
-	tmpName := tmp.Name()
-
-	if _, err := tmp.Write(data); err != nil {
-		tmp.Close()
-		_ = os.Remove(tmpName)
-		return err
+	if result.ScanID != "" {
+		writes = append(writes, func() error { return s.saveRunSnapshot(dir, result.ScanID, statusData, planOutput) })
 	}
-	if err := tmp.Sync(); err != nil {
-		tmp.Close()
-		_ = os.Remove(tmpName)
+	if err := runParallel(writes...); err != nil {
 		return err
 	}
-	if err := tmp.Close(); err != nil {
-		_ = os.Remove(tmpName)
-		return err
+
+	lock := s.stackLock(projectName, stackPath)
+	lock.Lock()
+	history, err := s.appendHistory(projectName, stackPath, result)
+	if err == nil {
+		pruneRunSnapshots(dir, history)
 	}
-	if err := os.Chmod(tmpName, perm); err != nil {
-		_ = os.Remove(tmpName)
+	lock.Unlock()
+	if err != nil {
 		return err
 	}
-	if err := os.Rename(tmpName, path); err != nil {
-		_ = os.Remove(tmpName)
-		return err
+
+	if !result.Drifted {
+		if a, err := s.readAnnotations(projectName, stackPath); err == nil && a.Acknowledged {
+			return s.SetStackAcknowledged(projectName, stackPath, false, "")
+		}
 	}
 
 	return nil
@@ -161,6 +303,13 @@ func (s *Storage) GetResult(projectName, stackPath string) (*RunResult, error) {
 	if err := validateStackPath(stackPath); err != nil {
 		return nil, err
 	}
+	return s.readResult(projectName, stackPath, true)
+}
+
+// readResult reads a stack's latest result, with its plan output only when
+// withPlan is set: plans can be large and encrypted, and listings never
+// need them.
+func (s *Storage) readResult(projectName, stackPath string, withPlan bool) (*RunResult, error) {
 
 	// Prefer the new layout under <data_dir>/results, but support legacy reads
 	// from <data_dir>/<project>/<stack> for existing installations.
@@ -183,6 +332,9 @@ func (s *Storage) GetResult(projectName, stackPath string) (*RunResult, error) {
 		return nil, err
 	}
 
+	if !withPlan {
+		return &result, nil
+	}
 	planRelPath := filepath.Join(stackRelDir, "plan.txt")
 	planData, err := readFileUnder(baseDir, planRelPath)
 	if err == nil {
@@ -224,7 +376,7 @@ func (s *Storage) ListRepos() ([]ProjectStatus, error) {
 		}
 		driftedCount := 0
 		for _, stack := range stacks {
-			if stack.Drifted {
+			if stack.Drifted && !stack.Suppressed {
 				driftedCount++
 			}
 		}
@@ -243,7 +395,14 @@ func (s *Storage) ListStacks(projectName string) ([]StackStatus, error) {
 		return nil, err
 	}
 
+	now := time.Now()
+	cached, previous, fresh := s.cache.get(projectName, now)
+	if fresh {
+		return copyStacks(cached), nil
+	}
+
 	merged := map[string]StackStatus{}
+	dirs := map[string]cachedStackDir{}
 
 	// Load legacy first, then results/ overwrites.
 	for _, base := range []string{s.dataDir, s.resultsDir()} {
@@ -262,11 +421,23 @@ func (s *Storage) ListStacks(projectName string) ([]StackStatus, error) {
 			if err := validateStackPath(stackPath); err != nil {
 				continue
 			}
-			result, err := s.GetResult(projectName, stackPath)
+			dir := filepath.Join(base, projectName, entry.Name())
+			var modTime time.Time
+			if s.cache != nil {
+				if info, err := entry.Info(); err == nil {
+					modTime = info.ModTime()
+				}
+				if d, ok := previous[dir]; ok && d.reusable(modTime, now) {
+					merged[stackPath] = d.status
+					dirs[dir] = d
+					continue
+				}
+			}
+			result, err := s.readResult(projectName, stackPath, false)
 			if err != nil {
 				continue
 			}
-			merged[stackPath] = StackStatus{
+			status := StackStatus{
 				Path:      stackPath,
 				Drifted:   result.Drifted,
 				Added:     result.Added,
@@ -274,20 +445,48 @@ func (s *Storage) ListStacks(projectName string) ([]StackStatus, error) {
 				Destroyed: result.Destroyed,
 				Error:     result.Error,
 				RunAt:     result.RunAt,
+				Tags:      result.Tags,
+
+				ProviderLockDrift:   len(result.ProviderLockDrift),
+				ModuleSourceChanges: len(result.ModuleSourceChanges),
+				NoisyClean:          result.NoisyClean,
+				RootCauseHint:       result.RootCauseHint,
+				OutputChanges:       result.OutputChanges,
+				ResourceChanges:     result.ResourceChanges,
+
+				ObservedDuringFreeze: result.ObservedDuringFreeze,
 			}
+			if a, err := s.readAnnotations(projectName, stackPath); err == nil {
+				status.Suppressed = a.Suppressed
+				status.Acknowledged = a.Acknowledged
+			}
+			merged[stackPath] = status
+			dirs[dir] = cachedStackDir{modTime: modTime, status: status}
 		}
 	}
 
-	if len(merged) == 0 {
-		return nil, nil
+	var stacks []StackStatus
+	if len(merged) > 0 {
+		stacks = make([]StackStatus, 0, len(merged))
+		for _, st := range merged {
+			stacks = append(stacks, st)
+		}
 	}
-	stacks := make([]StackStatus, 0, len(merged))
-	for _, st := range merged {
-		stacks = append(stacks, st)
+	if s.cache != nil {
+		s.cache.put(projectName, now, stacks, dirs)
+		return copyStacks(stacks), nil
 	}
 	return stacks, nil
 }
 
+// copyStacks copies a cached listing so callers may modify its elements.
+func copyStacks(stacks []StackStatus) []StackStatus {
+	if stacks == nil {
+		return nil
+	}
+	return append(make([]StackStatus, 0, len(stacks)), stacks...)
+}
+
 func decodeSafePath(value string) (string, error) {
 	data, err := base64.RawURLEncoding.DecodeString(value)
 	if err != nil {
@@ -299,7 +498,7 @@ func decodeSafePath(value string) (string, error) {
 
 func isReservedProjectDir(name string) bool {
 	switch name {
-	case "workspaces", "results":
+	case "workspaces", "results", "canary":
 		return true
 	default:
 		return false
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -498,3 +499,131 @@ func TestListStacksRejectsInvalidProjectName(t *testing.T) {
 		t.Fatalf("expected ErrInvalidProjectName, got %v", err)
 	}
 }
+
+func TestStackHistory(t *testing.T) {
+	s := New(t.TempDir())
+	now := time.Now()
+
+	runs := []*RunResult{
+		{RunAt: now.Add(-40 * 24 * time.Hour), Drifted: true},
+		{RunAt: now.Add(-2 * time.Hour), Drifted: true},
+		{RunAt: now.Add(-time.Hour), Error: "plan failed"},
+		{RunAt: now},
+	}
+	for _, run := range runs {
+		if err := s.SaveResult("project", "envs/prod", run); err != nil {
+			t.Fatalf("save result: %v", err)
+		}
+	}
+
+	history, err := s.StackHistory("project", "envs/prod", now.Add(-HistoryRetention))
+	if err != nil {
+		t.Fatalf("stack history: %v", err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected runs older than retention to be pruned, got %+v", history)
+	}
+	if !history[0].Drifted || !history[1].Errored || history[2].Drifted || history[2].Errored {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+
+	recent, err := s.StackHistory("project", "envs/prod", now.Add(-90*time.Minute))
+	if err != nil {
+		t.Fatalf("stack history: %v", err)
+	}
+	if len(recent) != 2 {
+		t.Fatalf("expected 2 recent entries, got %+v", recent)
+	}
+
+	stacks, err := s.ListStacks("project")
+	if err != nil || len(stacks) != 1 {
+		t.Fatalf("history file must not affect stack listing: %v %+v", err, stacks)
+	}
+}
+
+func TestGetScanResult(t *testing.T) {
+	s := New(t.TempDir())
+	now := time.Now()
+
+	first := &RunResult{RunAt: now.Add(-time.Hour), Drifted: true, Added: 1, PlanOutput: "Plan: 1 to add", ScanID: "project:1", CommitSHA: "aaa", TerraformVersion: "1.5.7"}
+	second := &RunResult{RunAt: now, PlanOutput: "No changes.", ScanID: "project:2", CommitSHA: "bbb", TerraformVersion: "1.6.0"}
+	for _, run := range []*RunResult{first, second} {
+		if err := s.SaveResult("project", "envs/prod", run); err != nil {
+			t.Fatalf("save result: %v", err)
+		}
+	}
+
+	got, err := s.GetScanResult("project", "envs/prod", "project:1")
+	if err != nil {
+		t.Fatalf("get scan result: %v", err)
+	}
+	if !got.Drifted || got.PlanOutput != "Plan: 1 to add" || got.CommitSHA != "aaa" || got.TerraformVersion != "1.5.7" {
+		t.Fatalf("unexpected pinned result: %+v", got)
+	}
+	if latest, err := s.GetResult("project", "envs/prod"); err != nil || latest.ScanID != "project:2" {
+		t.Fatalf("expected latest result from project:2, got %+v (%v)", latest, err)
+	}
+	if _, err := s.GetScanResult("project", "envs/prod", "project:3"); !errors.Is(err, ErrScanResultNotFound) {
+		t.Fatalf("expected ErrScanResultNotFound, got %v", err)
+	}
+
+	history, err := s.StackHistory("project", "envs/prod", time.Time{})
+	if err != nil || len(history) != 2 || history[0].ScanID != "project:1" {
+		t.Fatalf("expected history to carry scan IDs, got %+v (%v)", history, err)
+	}
+}
+
+func TestPruneRunSnapshots(t *testing.T) {
+	s := New(t.TempDir())
+	for i := 0; i < 3; i++ {
+		run := &RunResult{RunAt: time.Now(), ScanID: fmt.Sprintf("project:%d", i)}
+		if err := s.SaveResult("project", "envs/prod", run); err != nil {
+			t.Fatalf("save result: %v", err)
+		}
+	}
+
+	// Only the newest scan is still in history.
+	dir := s.stackDir(s.resultsDir(), "project", "envs/prod")
+	pruneRunSnapshots(dir, []HistoryEntry{{ScanID: "project:2"}})
+
+	if _, err := s.GetScanResult("project", "envs/prod", "project:0"); !errors.Is(err, ErrScanResultNotFound) {
+		t.Fatalf("expected project:0 to be pruned, got %v", err)
+	}
+	if _, err := s.GetScanResult("project", "envs/prod", "project:2"); err != nil {
+		t.Fatalf("expected project:2 to be kept: %v", err)
+	}
+}
+
+func TestImportResultKeepsNewerCurrentResult(t *testing.T) {
+	s := New(t.TempDir())
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := s.SaveResult("project", "envs/prod", &RunResult{RunAt: now, Drifted: true}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	outcome, err := s.ImportResult("project", "envs/prod", &RunResult{RunAt: now.Add(-time.Hour), Error: "old failure"})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if outcome.Latest || !outcome.History {
+		t.Fatalf("expected an older import to only add history, got %+v", outcome)
+	}
+	current, err := s.GetResult("project", "envs/prod")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if !current.Drifted || current.Error != "" {
+		t.Fatalf("current result was replaced: %+v", current)
+	}
+	history, err := s.StackHistory("project", "envs/prod", time.Time{})
+	if err != nil {
+		t.Fatalf("history: %v", err)
+	}
+	if len(history) != 2 || !history[0].Errored || !history[1].Drifted {
+		t.Fatalf("expected imported run first in history, got %+v", history)
+	}
+
+	if _, err := s.ImportResult("project", "envs/prod", &RunResult{}); err == nil {
+		t.Fatalf("expected an error for a result without a run time")
+	}
+}
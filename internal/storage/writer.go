@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"errors"
+	"hash/fnv"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Fsync policies for result files.
+const (
+	// FsyncAlways syncs every file before it is renamed into place.
+	FsyncAlways = "always"
+	// FsyncInterval renames files into place unsynced and syncs them, and
+	// their directories, in one batch every Options.FsyncInterval. A crash
+	// loses at most the last interval of results.
+	FsyncInterval = "interval"
+	// FsyncNever leaves flushing to the operating system.
+	FsyncNever = "never"
+)
+
+const (
+	defaultFsyncInterval = time.Second
+	// stackLockStripes is how many locks serialize writes to one stack's
+	// history and annotations. Writes to different stacks rarely share one.
+	stackLockStripes = 64
+)
+
+// Options tunes how results are written and read. The zero value syncs every
+// write and does not cache listings.
+type Options struct {
+	// Fsync is FsyncAlways, FsyncInterval or FsyncNever; empty means
+	// FsyncAlways.
+	Fsync string
+	// FsyncInterval is how often FsyncInterval syncs pending files.
+	FsyncInterval time.Duration
+	// CacheTTL is how long ListStacks serves a project from memory before
+	// looking at its stack directories again. 0 disables the cache.
+	CacheTTL time.Duration
+}
+
+// stackLock returns the lock guarding read-modify-write updates of a
+// stack's files.
+func (s *Storage) stackLock(projectName, stackPath string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(projectName))
+	h.Write([]byte{0})
+	h.Write([]byte(stackPath))
+	return &s.stackLocks[h.Sum32()%stackLockStripes]
+}
+
+// startFlusher syncs pending files every interval until Close.
+func (s *Storage) startFlusher(interval time.Duration) {
+	s.pendingSync = make(map[string]struct{})
+	s.stopFlush = make(chan struct{})
+	s.flushDone = make(chan struct{})
+	go func() {
+		defer close(s.flushDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopFlush:
+				return
+			case <-ticker.C:
+				if err := s.Flush(); err != nil {
+					log.Printf("storage: fsync: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Flush syncs the files written since the last flush under FsyncInterval.
+// It is a no-op for the other policies.
+func (s *Storage) Flush() error {
+	s.syncMu.Lock()
+	pending := s.pendingSync
+	if len(pending) > 0 {
+		s.pendingSync = make(map[string]struct{})
+	}
+	s.syncMu.Unlock()
+
+	var errs []error
+	dirs := make(map[string]struct{})
+	for path := range pending {
+		// A file replaced or pruned since it was written needs no sync.
+		if err := syncPath(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+		dirs[filepath.Dir(path)] = struct{}{}
+	}
+	// Syncing the directories makes the renames durable.
+	for dir := range dirs {
+		if err := syncPath(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops the background flusher and syncs anything still pending.
+func (s *Storage) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		if s.stopFlush != nil {
+			close(s.stopFlush)
+			<-s.flushDone
+		}
+		err = s.Flush()
+	})
+	return err
+}
+
+func syncPath(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+func (s *Storage) writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	base := filepath.Base(path)
+
+	tmp, err := os.CreateTemp(dir, "."+base+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if s.fsync == FsyncAlways {
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			_ = os.Remove(tmpName)
+			return err
+		}
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+
+	if s.fsync == FsyncInterval {
+		s.syncMu.Lock()
+		s.pendingSync[path] = struct{}{}
+		s.syncMu.Unlock()
+	}
+	return nil
+}
+
+// runParallel runs the writes concurrently and returns the first error.
+func runParallel(writes ...func() error) error {
+	errs := make([]error, len(writes))
+	var wg sync.WaitGroup
+	for i, write := range writes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = write()
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package vcs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+// Bitbucket implements Provider for Bitbucket Cloud.
+type Bitbucket struct{}
+
+func (Bitbucket) Name() string { return "bitbucket" }
+
+func (Bitbucket) MatchesHost(host string) bool {
+	return matchesHost(host, "bitbucket.org", "bitbucket")
+}
+
+// AppToken is unsupported: driftd has no Bitbucket app integration.
+func (Bitbucket) AppToken(context.Context, *config.GitAuthConfig) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (Bitbucket) PublishStatus(context.Context, *config.GitAuthConfig, string, int64, DeploymentStatus) error {
+	return ErrUnsupported
+}
+
+func (Bitbucket) CommitURL(webURL, sha string) string {
+	return webURL + "/commits/" + sha
+}
+
+func (Bitbucket) VerifySignature(r *http.Request, body io.Reader, secret string) error {
+	sig := r.Header.Get("X-Hub-Signature")
+	if sig == "" {
+		return fmt.Errorf("missing signature")
+	}
+	return verifyHMACSHA256(sig, body, secret)
+}
+
+func (Bitbucket) DeliveryID(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get("X-Request-UUID"))
+}
+
+type bitbucketPushPayload struct {
+	Actor struct {
+		Nickname    string `json:"nickname"`
+		DisplayName string `json:"display_name"`
+	} `json:"actor"`
+	Repository struct {
+		Name     string `json:"name"`
+		FullName string `json:"full_name"`
+		Links    struct {
+			HTML struct {
+				Href string `json:"href"`
+			} `json:"html"`
+		} `json:"links"`
+		MainBranch struct {
+			Name string `json:"name"`
+		} `json:"mainbranch"`
+	} `json:"repository"`
+	Push struct {
+		Changes []struct {
+			New *struct {
+				Type   string `json:"type"`
+				Name   string `json:"name"`
+				Target struct {
+					Hash string `json:"hash"`
+				} `json:"target"`
+			} `json:"new"`
+			Commits []struct {
+				Message string `json:"message"`
+			} `json:"commits"`
+		} `json:"changes"`
+	} `json:"push"`
+}
+
+// ParsePush handles repo:push events. Bitbucket does not list changed files,
+// so FilesKnown is false and callers scan the whole project.
+func (Bitbucket) ParsePush(r *http.Request, body []byte) (*PushEvent, error) {
+	if r.Header.Get("X-Event-Key") != "repo:push" {
+		return nil, ErrIgnoredEvent
+	}
+	var payload bitbucketPushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	for i := len(payload.Push.Changes) - 1; i >= 0; i-- {
+		change := payload.Push.Changes[i].New
+		if change == nil || change.Type != "branch" {
+			continue
+		}
+		pusher := payload.Actor.Nickname
+		if pusher == "" {
+			pusher = payload.Actor.DisplayName
+		}
+		// Bitbucket lists a change's commits newest first.
+		commits := payload.Push.Changes[i].Commits
+		messages := make([]string, 0, len(commits))
+		for j := len(commits) - 1; j >= 0; j-- {
+			messages = append(messages, commits[j].Message)
+		}
+		return &PushEvent{
+			Branch:         change.Name,
+			DefaultBranch:  payload.Repository.MainBranch.Name,
+			RepoName:       payload.Repository.Name,
+			RepoURLs:       appendNonEmpty(nil, payload.Repository.Links.HTML.Href),
+			HeadCommit:     change.Target.Hash,
+			Pusher:         pusher,
+			CommitMessages: messages,
+		}, nil
+	}
+	return nil, ErrIgnoredEvent
+}
@@ -0,0 +1,136 @@
+package vcs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/gitauth"
+)
+
+// GitHub implements Provider for github.com and GitHub Enterprise.
+type GitHub struct{}
+
+func (GitHub) Name() string { return "github" }
+
+func (GitHub) MatchesHost(host string) bool {
+	return matchesHost(host, "github.com", "github")
+}
+
+func (GitHub) AppToken(ctx context.Context, auth *config.GitAuthConfig) (string, error) {
+	if auth == nil || auth.Type != "github_app" || auth.GitHubApp == nil {
+		return "", ErrUnsupported
+	}
+	return gitauth.GitHubAppToken(ctx, auth.GitHubApp)
+}
+
+func (g GitHub) PublishStatus(ctx context.Context, auth *config.GitAuthConfig, repoURL string, deploymentID int64, status DeploymentStatus) error {
+	token, err := g.AppToken(ctx, auth)
+	if err != nil {
+		return err
+	}
+	return CreateGitHubDeploymentStatus(ctx, auth.GitHubApp.APIBaseURL, token, repoURL, deploymentID, status)
+}
+
+func (GitHub) CommitURL(webURL, sha string) string {
+	return webURL + "/commit/" + sha
+}
+
+func (GitHub) VerifySignature(r *http.Request, body io.Reader, secret string) error {
+	sig := r.Header.Get("X-Hub-Signature-256")
+	if sig == "" {
+		return fmt.Errorf("missing signature")
+	}
+	return verifyHMACSHA256(sig, body, secret)
+}
+
+func (GitHub) DeliveryID(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get("X-GitHub-Delivery"))
+}
+
+// GitHubPushPayload is the subset of the GitHub push event driftd reads.
+type GitHubPushPayload struct {
+	Ref        string `json:"ref"`
+	Before     string `json:"before"`
+	Forced     bool   `json:"forced"`
+	Repository struct {
+		Name          string `json:"name"`
+		FullName      string `json:"full_name"`
+		DefaultBranch string `json:"default_branch"`
+		CloneURL      string `json:"clone_url"`
+		SSHURL        string `json:"ssh_url"`
+		HTMLURL       string `json:"html_url"`
+	} `json:"repository"`
+	HeadCommit struct {
+		ID string `json:"id"`
+	} `json:"head_commit"`
+	Pusher struct {
+		Name string `json:"name"`
+	} `json:"pusher"`
+	Commits []struct {
+		Message  string   `json:"message"`
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+		Removed  []string `json:"removed"`
+	} `json:"commits"`
+}
+
+// CommitMessages returns the messages of the pushed commits, in order.
+func (p GitHubPushPayload) CommitMessages() []string {
+	messages := make([]string, 0, len(p.Commits))
+	for _, commit := range p.Commits {
+		messages = append(messages, commit.Message)
+	}
+	return messages
+}
+
+// githubMaxPushCommits is the most commits GitHub lists in a push payload;
+// larger pushes are truncated.
+const githubMaxPushCommits = 2048
+
+// Truncated reports whether GitHub cut the commit list short.
+func (p GitHubPushPayload) Truncated() bool {
+	return len(p.Commits) >= githubMaxPushCommits
+}
+
+// ChangedFiles returns every path touched by the pushed commits, in order.
+func (p GitHubPushPayload) ChangedFiles() []string {
+	var files []string
+	for _, commit := range p.Commits {
+		files = append(files, commit.Added...)
+		files = append(files, commit.Modified...)
+		files = append(files, commit.Removed...)
+	}
+	return files
+}
+
+func (GitHub) ParsePush(r *http.Request, body []byte) (*PushEvent, error) {
+	if r.Header.Get("X-GitHub-Event") != "push" {
+		return nil, ErrIgnoredEvent
+	}
+	var payload GitHubPushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	if !strings.HasPrefix(payload.Ref, "refs/heads/") {
+		return nil, ErrIgnoredEvent
+	}
+	return &PushEvent{
+		Branch:         strings.TrimPrefix(payload.Ref, "refs/heads/"),
+		DefaultBranch:  payload.Repository.DefaultBranch,
+		RepoName:       payload.Repository.Name,
+		RepoURLs:       appendNonEmpty(nil, payload.Repository.CloneURL, payload.Repository.SSHURL, payload.Repository.HTMLURL),
+		HeadCommit:     payload.HeadCommit.ID,
+		Pusher:         payload.Pusher.Name,
+		ChangedFiles:   payload.ChangedFiles(),
+		FilesKnown:     true,
+		BeforeCommit:   beforeCommit(payload.Before),
+		FilesTruncated: payload.Truncated(),
+		Forced:         payload.Forced,
+		CommitMessages: payload.CommitMessages(),
+	}, nil
+}
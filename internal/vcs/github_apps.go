@@ -0,0 +1,101 @@
+package vcs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// GitHubAppManifest describes the GitHub App created through the manifest
+// flow. See https://docs.github.com/en/apps/sharing-github-apps/registering-a-github-app-from-a-manifest.
+type GitHubAppManifest struct {
+	Name string `json:"name,omitempty"`
+	// URL is the app's homepage.
+	URL string `json:"url"`
+	// RedirectURL receives the temporary code after the app is created.
+	RedirectURL string `json:"redirect_url"`
+	// SetupURL receives the installation ID after the app is installed.
+	SetupURL           string            `json:"setup_url,omitempty"`
+	SetupOnUpdate      bool              `json:"setup_on_update,omitempty"`
+	Public             bool              `json:"public"`
+	DefaultPermissions map[string]string `json:"default_permissions"`
+}
+
+// GitHubAppCredentials is what GitHub returns when a manifest code is
+// converted. PEM is the app's private key and is only returned once.
+type GitHubAppCredentials struct {
+	ID            int64  `json:"id"`
+	Slug          string `json:"slug"`
+	Name          string `json:"name"`
+	HTMLURL       string `json:"html_url"`
+	PEM           string `json:"pem"`
+	WebhookSecret string `json:"webhook_secret"`
+	ClientID      string `json:"client_id"`
+	ClientSecret  string `json:"client_secret"`
+	Owner         struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+}
+
+// GitHubWebBaseURL returns the web URL matching a GitHub API base URL:
+// github.com for the public API and the host root for GitHub Enterprise
+// Server ("https://ghe.example.com/api/v3").
+func GitHubWebBaseURL(apiBaseURL string) string {
+	apiBaseURL = strings.TrimRight(apiBaseURL, "/")
+	if apiBaseURL == "" || apiBaseURL == defaultGitHubAPIBaseURL {
+		return "https://github.com"
+	}
+	return strings.TrimSuffix(apiBaseURL, "/api/v3")
+}
+
+// GitHubAppManifestURL returns the page the manifest form is posted to. The
+// app is owned by organization, or by the signed-in user when it is empty.
+func GitHubAppManifestURL(apiBaseURL, organization, state string) string {
+	target := GitHubWebBaseURL(apiBaseURL) + "/settings/apps/new"
+	if organization != "" {
+		target = fmt.Sprintf("%s/organizations/%s/settings/apps/new", GitHubWebBaseURL(apiBaseURL), url.PathEscape(organization))
+	}
+	return target + "?state=" + url.QueryEscape(state)
+}
+
+// ConvertGitHubAppManifest exchanges the code GitHub sent to the manifest's
+// redirect URL for the new app's credentials. The code expires after an
+// hour and can be used once.
+func ConvertGitHubAppManifest(ctx context.Context, apiBaseURL, code string) (*GitHubAppCredentials, error) {
+	if apiBaseURL == "" {
+		apiBaseURL = defaultGitHubAPIBaseURL
+	}
+	endpoint := fmt.Sprintf("%s/app-manifests/%s/conversions", strings.TrimRight(apiBaseURL, "/"), url.PathEscape(code))
+	client := &http.Client{Timeout: 30 * time.Second}
+	var creds GitHubAppCredentials
+	if err := githubRequest(ctx, client, http.MethodPost, endpoint, "", nil, &creds, ""); err != nil {
+		return nil, fmt.Errorf("convert app manifest: %w", err)
+	}
+	if creds.ID == 0 || creds.PEM == "" {
+		return nil, fmt.Errorf("convert app manifest: response is missing the app ID or private key")
+	}
+	return &creds, nil
+}
+
+// GitHubAppInstallationAccount returns the account an installation of the
+// app belongs to. appJWT is signed with the app's private key, so GitHub
+// answers 404 for installations of other apps.
+func GitHubAppInstallationAccount(ctx context.Context, apiBaseURL, appJWT string, installationID int64) (string, error) {
+	if apiBaseURL == "" {
+		apiBaseURL = defaultGitHubAPIBaseURL
+	}
+	endpoint := fmt.Sprintf("%s/app/installations/%d", strings.TrimRight(apiBaseURL, "/"), installationID)
+	client := &http.Client{Timeout: 30 * time.Second}
+	var installation struct {
+		Account struct {
+			Login string `json:"login"`
+		} `json:"account"`
+	}
+	if err := githubRequest(ctx, client, http.MethodGet, endpoint, appJWT, nil, &installation, ""); err != nil {
+		return "", fmt.Errorf("get installation: %w", err)
+	}
+	return installation.Account.Login, nil
+}
@@ -0,0 +1,134 @@
+package vcs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DeploymentStatusPrefix starts the description of every deployment status
+// driftd posts. Deliveries for those statuses are ignored so a successful
+// verification does not trigger another one.
+const DeploymentStatusPrefix = "driftd verification"
+
+// Deployment status states driftd reports.
+const (
+	DeploymentStateSuccess = "success"
+	DeploymentStateFailure = "failure"
+	DeploymentStateError   = "error"
+)
+
+// GitHubDeploymentStatusPayload is the subset of the GitHub deployment_status
+// event driftd reads.
+type GitHubDeploymentStatusPayload struct {
+	DeploymentStatus struct {
+		State       string `json:"state"`
+		Description string `json:"description"`
+		Environment string `json:"environment"`
+	} `json:"deployment_status"`
+	Deployment struct {
+		ID          int64  `json:"id"`
+		SHA         string `json:"sha"`
+		Ref         string `json:"ref"`
+		Environment string `json:"environment"`
+		Creator     struct {
+			Login string `json:"login"`
+		} `json:"creator"`
+	} `json:"deployment"`
+	Repository struct {
+		Name     string `json:"name"`
+		CloneURL string `json:"clone_url"`
+		SSHURL   string `json:"ssh_url"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+// DeploymentEvent is a successful GitHub deployment to an environment.
+type DeploymentEvent struct {
+	ID          int64
+	Environment string
+	SHA         string
+	Ref         string
+	RepoName    string
+	RepoURLs    []string
+	Actor       string
+}
+
+// ParseGitHubDeploymentStatus decodes a deployment_status webhook. It returns
+// ErrIgnoredEvent for other events, for states other than success, and for
+// statuses driftd posted itself.
+func ParseGitHubDeploymentStatus(r *http.Request, body []byte) (*DeploymentEvent, error) {
+	if r.Header.Get("X-GitHub-Event") != "deployment_status" {
+		return nil, ErrIgnoredEvent
+	}
+	var payload GitHubDeploymentStatusPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	status := payload.DeploymentStatus
+	if status.State != DeploymentStateSuccess || strings.HasPrefix(status.Description, DeploymentStatusPrefix) {
+		return nil, ErrIgnoredEvent
+	}
+	env := payload.Deployment.Environment
+	if status.Environment != "" {
+		env = status.Environment
+	}
+	if payload.Deployment.ID == 0 || env == "" {
+		return nil, fmt.Errorf("invalid payload: missing deployment or environment")
+	}
+	actor := payload.Deployment.Creator.Login
+	if actor == "" {
+		actor = payload.Sender.Login
+	}
+	return &DeploymentEvent{
+		ID:          payload.Deployment.ID,
+		Environment: env,
+		SHA:         payload.Deployment.SHA,
+		Ref:         payload.Deployment.Ref,
+		RepoName:    payload.Repository.Name,
+		RepoURLs:    appendNonEmpty(nil, payload.Repository.CloneURL, payload.Repository.SSHURL, payload.Repository.HTMLURL),
+		Actor:       actor,
+	}, nil
+}
+
+// DeploymentStatus is a status driftd adds to a GitHub deployment.
+type DeploymentStatus struct {
+	State       string `json:"state"`
+	Description string `json:"description"`
+	LogURL      string `json:"log_url,omitempty"`
+	// AutoInactive is always false: a verification must not retire
+	// earlier deployments.
+	AutoInactive bool `json:"auto_inactive"`
+}
+
+// maxDeploymentDescription is GitHub's limit on status descriptions.
+const maxDeploymentDescription = 140
+
+// CreateGitHubDeploymentStatus adds status to deployment deploymentID of the
+// repository. The token needs the "Deployments: write" permission.
+func CreateGitHubDeploymentStatus(ctx context.Context, apiBaseURL, token, repoURL string, deploymentID int64, status DeploymentStatus) error {
+	owner, repo, ok := GitHubRepoSlug(repoURL)
+	if !ok {
+		return fmt.Errorf("not a GitHub repository URL: %q", repoURL)
+	}
+	if apiBaseURL == "" {
+		apiBaseURL = defaultGitHubAPIBaseURL
+	}
+	if len(status.Description) > maxDeploymentDescription {
+		status.Description = status.Description[:maxDeploymentDescription-3] + "..."
+	}
+	status.AutoInactive = false
+	endpoint := fmt.Sprintf("%s/repos/%s/%s/deployments/%d/statuses", strings.TrimRight(apiBaseURL, "/"), url.PathEscape(owner), url.PathEscape(repo), deploymentID)
+	client := &http.Client{Timeout: 30 * time.Second}
+	if err := githubRequest(ctx, client, http.MethodPost, endpoint, token, status, nil, "deployments write"); err != nil {
+		return fmt.Errorf("create deployment status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultGitHubAPIBaseURL = "https://api.github.com"
+
+// Outcomes reported by EnsureGitHubWebhook.
+const (
+	WebhookCreated = "created"
+	WebhookUpdated = "updated"
+)
+
+// GitHubWebhook describes the repository push hook driftd manages.
+type GitHubWebhook struct {
+	// TargetURL is the driftd endpoint GitHub delivers to.
+	TargetURL string
+	Secret    string
+	// Events defaults to push only.
+	Events []string
+}
+
+type githubHook struct {
+	ID     int64    `json:"id,omitempty"`
+	Name   string   `json:"name,omitempty"`
+	Active bool     `json:"active"`
+	Events []string `json:"events"`
+	Config struct {
+		URL         string `json:"url"`
+		ContentType string `json:"content_type"`
+		Secret      string `json:"secret,omitempty"`
+		InsecureSSL string `json:"insecure_ssl"`
+	} `json:"config"`
+}
+
+// GitHubRepoSlug extracts owner and repository name from a GitHub clone or
+// web URL.
+func GitHubRepoSlug(repoURL string) (owner, repo string, ok bool) {
+	web, ok := WebURL(repoURL)
+	if !ok {
+		return "", "", false
+	}
+	u, err := url.Parse(web)
+	if err != nil || !(GitHub{}).MatchesHost(u.Host) {
+		return "", "", false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// EnsureGitHubWebhook creates the push hook on the repository, or updates the
+// existing hook that already targets hook.TargetURL. The secret is always
+// rewritten because GitHub never returns it. The token needs the
+// "Repository webhooks: write" permission.
+func EnsureGitHubWebhook(ctx context.Context, apiBaseURL, token, repoURL string, hook GitHubWebhook) (string, error) {
+	owner, repo, ok := GitHubRepoSlug(repoURL)
+	if !ok {
+		return "", fmt.Errorf("not a GitHub repository URL: %q", repoURL)
+	}
+	if apiBaseURL == "" {
+		apiBaseURL = defaultGitHubAPIBaseURL
+	}
+	hooksURL := fmt.Sprintf("%s/repos/%s/%s/hooks", strings.TrimRight(apiBaseURL, "/"), url.PathEscape(owner), url.PathEscape(repo))
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var existing []githubHook
+	if err := githubRequest(ctx, client, http.MethodGet, hooksURL+"?per_page=100", token, nil, &existing, "repository webhooks write"); err != nil {
+		return "", fmt.Errorf("list hooks: %w", err)
+	}
+
+	desired := githubHook{Name: "web", Active: true, Events: hook.Events}
+	if len(desired.Events) == 0 {
+		desired.Events = []string{"push"}
+	}
+	desired.Config.URL = hook.TargetURL
+	desired.Config.ContentType = "json"
+	desired.Config.Secret = hook.Secret
+	desired.Config.InsecureSSL = "0"
+
+	for _, h := range existing {
+		if h.Config.URL != hook.TargetURL {
+			continue
+		}
+		desired.Name = ""
+		if err := githubRequest(ctx, client, http.MethodPatch, fmt.Sprintf("%s/%d", hooksURL, h.ID), token, desired, nil, "repository webhooks write"); err != nil {
+			return "", fmt.Errorf("update hook: %w", err)
+		}
+		return WebhookUpdated, nil
+	}
+	if err := githubRequest(ctx, client, http.MethodPost, hooksURL, token, desired, nil, "repository webhooks write"); err != nil {
+		return "", fmt.Errorf("create hook: %w", err)
+	}
+	return WebhookCreated, nil
+}
+
+// githubRequest sends a GitHub API request, authenticated when token is set.
+// permission names the app permission a 403 or 404 most likely means is
+// missing, if any.
+func githubRequest(ctx context.Context, client *http.Client, method, endpoint, token string, body, out any, permission string) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		if permission != "" && (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusNotFound) {
+			return fmt.Errorf("github returned %s (the app needs %s permission)", resp.Status, permission)
+		}
+		return fmt.Errorf("github returned %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
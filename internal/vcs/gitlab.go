@@ -0,0 +1,114 @@
+package vcs
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+// GitLab implements Provider for gitlab.com and self-managed GitLab.
+type GitLab struct{}
+
+func (GitLab) Name() string { return "gitlab" }
+
+func (GitLab) MatchesHost(host string) bool {
+	return matchesHost(host, "gitlab.com", "gitlab")
+}
+
+// AppToken is unsupported: driftd has no GitLab app integration.
+func (GitLab) AppToken(context.Context, *config.GitAuthConfig) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (GitLab) PublishStatus(context.Context, *config.GitAuthConfig, string, int64, DeploymentStatus) error {
+	return ErrUnsupported
+}
+
+func (GitLab) CommitURL(webURL, sha string) string {
+	return webURL + "/-/commit/" + sha
+}
+
+// VerifySignature compares the X-Gitlab-Token header; GitLab does not sign
+// payloads.
+func (GitLab) VerifySignature(r *http.Request, body io.Reader, secret string) error {
+	token := r.Header.Get("X-Gitlab-Token")
+	if token == "" {
+		return fmt.Errorf("missing signature")
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (GitLab) DeliveryID(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get("X-Gitlab-Event-UUID"))
+}
+
+type gitLabPushPayload struct {
+	ObjectKind   string `json:"object_kind"`
+	Ref          string `json:"ref"`
+	CheckoutSHA  string `json:"checkout_sha"`
+	Before       string `json:"before"`
+	After        string `json:"after"`
+	UserUsername string `json:"user_username"`
+	Project      struct {
+		Name          string `json:"name"`
+		DefaultBranch string `json:"default_branch"`
+		GitHTTPURL    string `json:"git_http_url"`
+		GitSSHURL     string `json:"git_ssh_url"`
+		WebURL        string `json:"web_url"`
+	} `json:"project"`
+	Commits []struct {
+		Message  string   `json:"message"`
+		Added    []string `json:"added"`
+		Modified []string `json:"modified"`
+		Removed  []string `json:"removed"`
+	} `json:"commits"`
+	// TotalCommitsCount counts every pushed commit; Commits lists at most
+	// 20 of them.
+	TotalCommitsCount int `json:"total_commits_count"`
+}
+
+func (GitLab) ParsePush(r *http.Request, body []byte) (*PushEvent, error) {
+	if r.Header.Get("X-Gitlab-Event") != "Push Hook" {
+		return nil, ErrIgnoredEvent
+	}
+	var payload gitLabPushPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	if payload.ObjectKind != "push" || !strings.HasPrefix(payload.Ref, "refs/heads/") {
+		return nil, ErrIgnoredEvent
+	}
+	head := payload.CheckoutSHA
+	if head == "" {
+		head = payload.After
+	}
+	var files, messages []string
+	for _, commit := range payload.Commits {
+		messages = append(messages, commit.Message)
+		files = append(files, commit.Added...)
+		files = append(files, commit.Modified...)
+		files = append(files, commit.Removed...)
+	}
+	return &PushEvent{
+		Branch:         strings.TrimPrefix(payload.Ref, "refs/heads/"),
+		DefaultBranch:  payload.Project.DefaultBranch,
+		RepoName:       payload.Project.Name,
+		RepoURLs:       appendNonEmpty(nil, payload.Project.GitHTTPURL, payload.Project.GitSSHURL, payload.Project.WebURL),
+		HeadCommit:     head,
+		Pusher:         payload.UserUsername,
+		ChangedFiles:   files,
+		FilesKnown:     true,
+		BeforeCommit:   beforeCommit(payload.Before),
+		FilesTruncated: payload.TotalCommitsCount > len(payload.Commits),
+		CommitMessages: messages,
+	}, nil
+}
@@ -0,0 +1,217 @@
+// Package vcs hides the differences between git hosting providers: commit
+// links, webhook verification, push event parsing, API tokens and status
+// publishing.
+package vcs
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+// ErrIgnoredEvent is returned for webhook events that do not trigger scans,
+// such as tag pushes or non-push events.
+var ErrIgnoredEvent = errors.New("event ignored")
+
+// ErrInvalidSignature is returned when a webhook signature does not verify.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// ErrUnsupported is returned by providers for API features they, or the
+// project's git auth type, do not offer.
+var ErrUnsupported = errors.New("not supported by provider")
+
+// Provider implements the hosting-specific parts of driftd.
+type Provider interface {
+	// Name is the provider identifier used in webhook routes and config.
+	Name() string
+	// MatchesHost reports whether repositories on host are served by this provider.
+	MatchesHost(host string) bool
+	// CommitURL builds a web link for sha given the repository web URL.
+	CommitURL(webURL, sha string) string
+	// VerifySignature checks the provider-native webhook signature or token.
+	// Signed payloads are hashed as body is read; read errors are returned
+	// unchanged so callers can tell an oversized body from a bad signature.
+	VerifySignature(r *http.Request, body io.Reader, secret string) error
+	// DeliveryID returns the provider's unique delivery ID, if any.
+	DeliveryID(r *http.Request) string
+	// ParsePush decodes a push webhook. It returns ErrIgnoredEvent for
+	// anything other than a branch push.
+	ParsePush(r *http.Request, body []byte) (*PushEvent, error)
+	// AppToken mints a short-lived API token from the project's git app
+	// credentials. It returns ErrUnsupported when auth is not an app.
+	AppToken(ctx context.Context, auth *config.GitAuthConfig) (string, error)
+	// PublishStatus reports a verification result on deployment
+	// deploymentID of repoURL, authenticating with AppToken.
+	PublishStatus(ctx context.Context, auth *config.GitAuthConfig, repoURL string, deploymentID int64, status DeploymentStatus) error
+}
+
+// PushEvent is the provider-neutral form of a branch push.
+type PushEvent struct {
+	Branch        string
+	DefaultBranch string
+	RepoName      string
+	// RepoURLs holds the clone, SSH, and web URLs used to match projects.
+	RepoURLs   []string
+	HeadCommit string
+	Pusher     string
+	// ChangedFiles lists paths touched by the pushed commits. FilesKnown is
+	// false when the provider does not include them in the payload.
+	ChangedFiles []string
+	FilesKnown   bool
+	// BeforeCommit is the branch head before the push, or "" for a new
+	// branch. FilesTruncated is set when the provider cut the commit list
+	// short, and Forced when the push rewrote history; in both cases
+	// ChangedFiles may miss files and the before..after range is exact.
+	BeforeCommit   string
+	FilesTruncated bool
+	Forced         bool
+	// CommitMessages holds the messages of the pushed commits, oldest first.
+	CommitMessages []string
+}
+
+// zeroSHA is the before commit providers send for a newly created branch.
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// beforeCommit returns sha unless it is empty or the zero SHA.
+func beforeCommit(sha string) string {
+	if sha = strings.TrimSpace(sha); sha == zeroSHA {
+		return ""
+	}
+	return sha
+}
+
+var providers = []Provider{GitHub{}, GitLab{}, Bitbucket{}}
+
+// Providers returns all built-in providers.
+func Providers() []Provider {
+	return append([]Provider(nil), providers...)
+}
+
+// ByName returns the provider with the given name, or nil.
+func ByName(name string) Provider {
+	for _, p := range providers {
+		if p.Name() == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// ForURL picks the provider hosting repoURL, or nil when the host is unknown.
+func ForURL(repoURL string) Provider {
+	web, ok := WebURL(repoURL)
+	if !ok {
+		return nil
+	}
+	u, err := url.Parse(web)
+	if err != nil {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, p := range providers {
+		if p.MatchesHost(host) {
+			return p
+		}
+	}
+	return nil
+}
+
+// CommitURL returns a web link to sha in repoURL, or "" when the provider
+// cannot be determined.
+func CommitURL(repoURL, sha string) string {
+	if repoURL == "" || sha == "" {
+		return ""
+	}
+	p := ForURL(repoURL)
+	if p == nil {
+		return ""
+	}
+	web, _ := WebURL(repoURL)
+	return p.CommitURL(web, sha)
+}
+
+// WebURL converts an HTTPS, SSH, or scp-style clone URL into the https web
+// URL of the repository.
+func WebURL(repoURL string) (string, bool) {
+	clean := strings.TrimSpace(repoURL)
+	clean = strings.TrimSuffix(strings.TrimSuffix(clean, "/"), ".git")
+	if clean == "" {
+		return "", false
+	}
+
+	var host, path string
+	switch {
+	case strings.Contains(clean, "://"):
+		u, err := url.Parse(clean)
+		if err != nil || u.Host == "" {
+			return "", false
+		}
+		host = u.Hostname()
+		if u.Scheme == "http" || u.Scheme == "https" {
+			host = u.Host
+		}
+		path = u.Path
+	default:
+		// scp-style: git@host:owner/repo
+		at := strings.Index(clean, "@")
+		colon := strings.Index(clean, ":")
+		if colon <= at+1 {
+			return "", false
+		}
+		host = clean[at+1 : colon]
+		path = clean[colon+1:]
+	}
+	path = strings.Trim(path, "/")
+	if host == "" || path == "" {
+		return "", false
+	}
+	return "https://" + host + "/" + path, true
+}
+
+// matchesHost reports whether host is domain or a subdomain of it, or a
+// self-managed instance named after the provider, such as
+// gitlab.example.com for label "gitlab".
+func matchesHost(host, domain, label string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == domain || strings.HasSuffix(host, "."+domain) {
+		return true
+	}
+	first, _, ok := strings.Cut(host, ".")
+	return ok && first == label
+}
+
+func verifyHMACSHA256(header string, body io.Reader, secret string) error {
+	algo, sig, ok := strings.Cut(header, "=")
+	if !ok || algo != "sha256" {
+		return ErrInvalidSignature
+	}
+	provided, err := hex.DecodeString(sig)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	if _, err := io.Copy(mac, body); err != nil {
+		return err
+	}
+	if !hmac.Equal(mac.Sum(nil), provided) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func appendNonEmpty(values []string, more ...string) []string {
+	for _, v := range more {
+		if strings.TrimSpace(v) != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,328 @@
+package vcs
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+func TestCommitURL(t *testing.T) {
+	tests := map[string]string{
+		"git@github.com:org/infra.git":              "https://github.com/org/infra/commit/abc",
+		"https://github.com/org/infra.git":          "https://github.com/org/infra/commit/abc",
+		"http://github.com/org/infra":               "https://github.com/org/infra/commit/abc",
+		"ssh://git@github.example.com/org/infra":    "https://github.example.com/org/infra/commit/abc",
+		"git@gitlab.com:group/sub/infra.git":        "https://gitlab.com/group/sub/infra/-/commit/abc",
+		"https://gitlab.com/group/infra":            "https://gitlab.com/group/infra/-/commit/abc",
+		"https://bitbucket.org/team/infra.git":      "https://bitbucket.org/team/infra/commits/abc",
+		"https://user@bitbucket.org/team/infra.git": "https://bitbucket.org/team/infra/commits/abc",
+		"https://git.example.com/org/infra":         "",
+		"file:///tmp/infra":                         "",
+	}
+	for repoURL, want := range tests {
+		if got := CommitURL(repoURL, "abc"); got != want {
+			t.Errorf("CommitURL(%q) = %q, want %q", repoURL, got, want)
+		}
+	}
+}
+
+func sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{}`)
+
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("X-Hub-Signature-256", sign(body, "s"))
+	if err := (GitHub{}).VerifySignature(r, bytes.NewReader(body), "s"); err != nil {
+		t.Fatalf("github: %v", err)
+	}
+	if err := (GitHub{}).VerifySignature(r, bytes.NewReader(body), "other"); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("github: expected invalid signature, got %v", err)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("X-Gitlab-Token", "s")
+	if err := (GitLab{}).VerifySignature(r, bytes.NewReader(body), "s"); err != nil {
+		t.Fatalf("gitlab: %v", err)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("X-Hub-Signature", sign(body, "s"))
+	if err := (Bitbucket{}).VerifySignature(r, bytes.NewReader(body), "s"); err != nil {
+		t.Fatalf("bitbucket: %v", err)
+	}
+}
+
+func TestGitLabParsePush(t *testing.T) {
+	body := []byte(`{
+		"object_kind": "push",
+		"ref": "refs/heads/main",
+		"before": "0000000000000000000000000000000000000000",
+		"checkout_sha": "abc123",
+		"user_username": "alice",
+		"total_commits_count": 1,
+		"project": {"name": "infra", "default_branch": "main", "git_http_url": "https://gitlab.com/g/infra.git"},
+		"commits": [{"added": ["a.tf"], "modified": ["b.tf"], "removed": []}]
+	}`)
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("X-Gitlab-Event", "Push Hook")
+	push, err := (GitLab{}).ParsePush(r, body)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if push.Branch != "main" || push.HeadCommit != "abc123" || push.Pusher != "alice" || !push.FilesKnown {
+		t.Fatalf("unexpected push: %+v", push)
+	}
+	if strings.Join(push.ChangedFiles, ",") != "a.tf,b.tf" {
+		t.Fatalf("unexpected files: %v", push.ChangedFiles)
+	}
+	if push.BeforeCommit != "" || push.FilesTruncated {
+		t.Fatalf("unexpected commit range: %+v", push)
+	}
+
+	r.Header.Set("X-Gitlab-Event", "Tag Push Hook")
+	if _, err := (GitLab{}).ParsePush(r, body); !errors.Is(err, ErrIgnoredEvent) {
+		t.Fatalf("expected tag push ignored, got %v", err)
+	}
+}
+
+func TestGitHubParsePushCommitRange(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("X-GitHub-Event", "push")
+	parse := func(payload map[string]any) *PushEvent {
+		t.Helper()
+		payload["ref"] = "refs/heads/main"
+		body, _ := json.Marshal(payload)
+		push, err := (GitHub{}).ParsePush(r, body)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		return push
+	}
+
+	push := parse(map[string]any{"before": "abc", "forced": true, "commits": []map[string]any{{"modified": []string{"a.tf"}}}})
+	if push.BeforeCommit != "abc" || !push.Forced || push.FilesTruncated {
+		t.Fatalf("unexpected forced push: %+v", push)
+	}
+
+	commits := make([]map[string]any, githubMaxPushCommits)
+	for i := range commits {
+		commits[i] = map[string]any{"modified": []string{"a.tf"}}
+	}
+	if push := parse(map[string]any{"before": "abc", "commits": commits}); !push.FilesTruncated {
+		t.Fatalf("expected truncated push")
+	}
+
+	// A new branch has no range to diff.
+	if push := parse(map[string]any{"before": strings.Repeat("0", 40)}); push.BeforeCommit != "" {
+		t.Fatalf("expected zero before commit dropped, got %q", push.BeforeCommit)
+	}
+}
+
+func TestBitbucketParsePush(t *testing.T) {
+	body := []byte(`{
+		"actor": {"nickname": "bob"},
+		"repository": {"name": "infra", "links": {"html": {"href": "https://bitbucket.org/team/infra"}}, "mainbranch": {"name": "main"}},
+		"push": {"changes": [{"new": {"type": "branch", "name": "main", "target": {"hash": "def456"}}}]}
+	}`)
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	r.Header.Set("X-Event-Key", "repo:push")
+	push, err := (Bitbucket{}).ParsePush(r, body)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if push.Branch != "main" || push.HeadCommit != "def456" || push.Pusher != "bob" || push.FilesKnown {
+		t.Fatalf("unexpected push: %+v", push)
+	}
+	if len(push.RepoURLs) != 1 || push.RepoURLs[0] != "https://bitbucket.org/team/infra" {
+		t.Fatalf("unexpected repo urls: %v", push.RepoURLs)
+	}
+}
+
+func TestForURL(t *testing.T) {
+	if p := ForURL("git@gitlab.example.com:g/infra.git"); p == nil || p.Name() != "gitlab" {
+		t.Fatalf("expected gitlab provider, got %v", p)
+	}
+	for _, repoURL := range []string{
+		"https://git.example.com/org/infra",
+		"https://notgithub.com/org/infra",
+		"git@mygitlab.example.com:g/infra.git",
+	} {
+		if p := ForURL(repoURL); p != nil {
+			t.Fatalf("expected no provider for %s, got %s", repoURL, p.Name())
+		}
+	}
+	for repoURL, want := range map[string]string{
+		"https://github.com/org/infra":          "github",
+		"https://GitHub.com/org/infra":          "github",
+		"git@ssh.github.com:org/infra.git":      "github",
+		"https://github.corp.example/org/infra": "github",
+		"https://bitbucket.org/team/infra":      "bitbucket",
+		"https://gitlab.com/group/sub/infra":    "gitlab",
+	} {
+		if p := ForURL(repoURL); p == nil || p.Name() != want {
+			t.Fatalf("expected %s provider for %s, got %v", want, repoURL, p)
+		}
+	}
+	if ByName("bitbucket") == nil || ByName("svn") != nil {
+		t.Fatalf("unexpected ByName results")
+	}
+}
+
+func TestProviderAppTokenUnsupported(t *testing.T) {
+	ctx := context.Background()
+	https := &config.GitAuthConfig{Type: "https"}
+	if _, err := (GitHub{}).AppToken(ctx, https); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported for https auth, got %v", err)
+	}
+	if err := (GitHub{}).PublishStatus(ctx, nil, "https://github.com/o/r", 1, DeploymentStatus{}); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported without git auth, got %v", err)
+	}
+	app := &config.GitAuthConfig{Type: "github_app", GitHubApp: &config.GitHubAppConfig{AppID: 1, InstallationID: 2}}
+	for _, p := range []Provider{GitLab{}, Bitbucket{}} {
+		if _, err := p.AppToken(ctx, app); !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("%s: expected ErrUnsupported, got %v", p.Name(), err)
+		}
+		if err := p.PublishStatus(ctx, app, "https://example.com/o/r", 1, DeploymentStatus{}); !errors.Is(err, ErrUnsupported) {
+			t.Fatalf("%s: expected ErrUnsupported, got %v", p.Name(), err)
+		}
+	}
+}
+
+func TestEnsureGitHubWebhook(t *testing.T) {
+	var hooks []githubHook
+	var methods []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, "/repos/acme/infra/hooks") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		methods = append(methods, r.Method)
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(hooks)
+		case http.MethodPost:
+			var h githubHook
+			json.NewDecoder(r.Body).Decode(&h)
+			h.ID = 7
+			hooks = append(hooks, h)
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodPatch:
+			if r.URL.Path != "/repos/acme/infra/hooks/7" {
+				t.Errorf("unexpected patch path %s", r.URL.Path)
+			}
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer srv.Close()
+
+	hook := GitHubWebhook{TargetURL: "https://driftd.example.com/api/webhooks/github", Secret: "s"}
+	outcome, err := EnsureGitHubWebhook(context.Background(), srv.URL, "tok", "git@github.com:acme/infra.git", hook)
+	if err != nil || outcome != WebhookCreated {
+		t.Fatalf("expected created, got %q %v", outcome, err)
+	}
+	if len(hooks) != 1 || hooks[0].Config.Secret != "s" || hooks[0].Events[0] != "push" {
+		t.Fatalf("unexpected hook payload: %+v", hooks)
+	}
+	outcome, err = EnsureGitHubWebhook(context.Background(), srv.URL, "tok", "https://github.com/acme/infra", hook)
+	if err != nil || outcome != WebhookUpdated {
+		t.Fatalf("expected updated, got %q %v", outcome, err)
+	}
+	if strings.Join(methods, ",") != "GET,POST,GET,PATCH" {
+		t.Fatalf("unexpected request sequence: %v", methods)
+	}
+
+	if _, err := EnsureGitHubWebhook(context.Background(), srv.URL, "tok", "https://gitlab.com/acme/infra", hook); err == nil {
+		t.Fatalf("expected error for non-GitHub URL")
+	}
+}
+
+func TestParseGitHubDeploymentStatus(t *testing.T) {
+	body := []byte(`{
+		"deployment_status": {"state": "success", "environment": "prod"},
+		"deployment": {"id": 42, "sha": "abc", "ref": "main", "environment": "staging", "creator": {"login": "octo"}},
+		"repository": {"name": "infra", "clone_url": "https://github.com/acme/infra.git"}
+	}`)
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("X-GitHub-Event", "deployment_status")
+	dep, err := ParseGitHubDeploymentStatus(req, body)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if dep.ID != 42 || dep.Environment != "prod" || dep.SHA != "abc" || dep.Actor != "octo" || len(dep.RepoURLs) != 1 {
+		t.Fatalf("unexpected event %+v", dep)
+	}
+
+	for name, payload := range map[string]string{
+		"pending": `{"deployment_status": {"state": "pending"}, "deployment": {"id": 1, "environment": "prod"}}`,
+		"own":     `{"deployment_status": {"state": "success", "description": "driftd verification: no drift"}, "deployment": {"id": 1, "environment": "prod"}}`,
+	} {
+		if _, err := ParseGitHubDeploymentStatus(req, []byte(payload)); !errors.Is(err, ErrIgnoredEvent) {
+			t.Fatalf("%s: expected ignored event, got %v", name, err)
+		}
+	}
+	push := httptest.NewRequest(http.MethodPost, "/", nil)
+	push.Header.Set("X-GitHub-Event", "push")
+	if _, err := ParseGitHubDeploymentStatus(push, body); !errors.Is(err, ErrIgnoredEvent) {
+		t.Fatalf("expected push to be ignored, got %v", err)
+	}
+}
+
+func TestCreateGitHubDeploymentStatus(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/repos/acme/infra/deployments/42/statuses" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	status := DeploymentStatus{State: DeploymentStateSuccess, Description: strings.Repeat("x", 200), LogURL: "https://driftd.example.com", AutoInactive: true}
+	if err := CreateGitHubDeploymentStatus(context.Background(), srv.URL, "tok", "https://github.com/acme/infra", 42, status); err != nil {
+		t.Fatalf("create status: %v", err)
+	}
+	if got["state"] != "success" || got["auto_inactive"] != false || len(got["description"].(string)) != maxDeploymentDescription {
+		t.Fatalf("unexpected status payload %+v", got)
+	}
+
+	err := CreateGitHubDeploymentStatus(context.Background(), srv.URL, "tok", "https://github.com/acme/infra", 7, status)
+	if err == nil || !strings.Contains(err.Error(), "deployments write") {
+		t.Fatalf("expected permission hint, got %v", err)
+	}
+}
+
+func TestGitHubAppManifestURL(t *testing.T) {
+	tests := []struct {
+		apiBaseURL, organization, want string
+	}{
+		{"", "", "https://github.com/settings/apps/new?state=s1"},
+		{"https://api.github.com/", "acme", "https://github.com/organizations/acme/settings/apps/new?state=s1"},
+		{"https://ghe.example.com/api/v3", "", "https://ghe.example.com/settings/apps/new?state=s1"},
+	}
+	for _, tt := range tests {
+		if got := GitHubAppManifestURL(tt.apiBaseURL, tt.organization, "s1"); got != tt.want {
+			t.Errorf("GitHubAppManifestURL(%q, %q) = %q, want %q", tt.apiBaseURL, tt.organization, got, tt.want)
+		}
+	}
+}
@@ -14,6 +14,12 @@ type Versions struct {
 }
 
 func Detect(projectDir string, stacks []string) (*Versions, error) {
+	return DetectWithDefaults(projectDir, stacks, "", "")
+}
+
+// DetectWithDefaults is Detect with project-level default versions used
+// for stacks that have no version file of their own or at the repo root.
+func DetectWithDefaults(projectDir string, stacks []string, tfFallback, tgFallback string) (*Versions, error) {
 	tfRoot := readVersionFile(filepath.Join(projectDir, ".terraform-version"))
 	tgRoot := readVersionFile(filepath.Join(projectDir, ".terragrunt-version"))
 
@@ -29,6 +35,9 @@ func Detect(projectDir string, stacks []string) (*Versions, error) {
 		if tf == "" {
 			tf = tfRoot
 		}
+		if tf == "" {
+			tf = tfFallback
+		}
 		if tf != "" {
 			stackTF[stack] = tf
 			tfSet[tf] = struct{}{}
@@ -38,6 +47,9 @@ func Detect(projectDir string, stacks []string) (*Versions, error) {
 		if tg == "" {
 			tg = tgRoot
 		}
+		if tg == "" {
+			tg = tgFallback
+		}
 		if tg != "" {
 			stackTG[stack] = tg
 			tgSet[tg] = struct{}{}
@@ -57,6 +69,15 @@ func Detect(projectDir string, stacks []string) (*Versions, error) {
 		tgStack = dropDefault(tgStack, tgRoot)
 	}
 
+	if tfDefault == "" && tfFallback != "" {
+		tfDefault = tfFallback
+		tfStack = dropDefault(tfStack, tfFallback)
+	}
+	if tgDefault == "" && tgFallback != "" {
+		tgDefault = tgFallback
+		tgStack = dropDefault(tgStack, tgFallback)
+	}
+
 	return &Versions{
 		DefaultTerraform:  tfDefault,
 		DefaultTerragrunt: tgDefault,
@@ -140,3 +140,26 @@ func TestNoVersionFiles(t *testing.T) {
 		t.Fatalf("expected empty stack maps")
 	}
 }
+
+func TestDetectWithDefaultsOnlyFillsMissingVersions(t *testing.T) {
+	project := t.TempDir()
+	ensureDir(t, filepath.Join(project, "envs/dev"))
+	ensureDir(t, filepath.Join(project, "envs/prod"))
+	writeFile(t, filepath.Join(project, "envs/prod", ".terraform-version"), "1.5.7")
+	writeFile(t, filepath.Join(project, ".terragrunt-version"), "0.56.4")
+
+	versions, err := DetectWithDefaults(project, []string{"envs/dev", "envs/prod"}, "1.6.2", "0.50.0")
+	if err != nil {
+		t.Fatalf("detect: %v", err)
+	}
+
+	if versions.DefaultTerraform != "1.6.2" {
+		t.Fatalf("expected project default tf 1.6.2, got %q", versions.DefaultTerraform)
+	}
+	if len(versions.StackTerraform) != 1 || versions.StackTerraform["envs/prod"] != "1.5.7" {
+		t.Fatalf("expected only envs/prod to keep its own version, got %+v", versions.StackTerraform)
+	}
+	if versions.DefaultTerragrunt != "0.56.4" {
+		t.Fatalf("expected repo tg version to win over project default, got %q", versions.DefaultTerragrunt)
+	}
+}
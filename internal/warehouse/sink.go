@@ -0,0 +1,223 @@
+package warehouse
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+)
+
+// Sink stores an export file under a slash-separated key.
+type Sink interface {
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// NewSink returns the sink for cfg.Destination: an S3-compatible bucket for
+// s3:// and a local directory otherwise.
+func NewSink(cfg config.WarehouseExportConfig) (Sink, error) {
+	dest := strings.TrimSpace(cfg.Destination)
+	switch {
+	case dest == "":
+		return nil, fmt.Errorf("warehouse_export.destination is required")
+	case strings.HasPrefix(dest, "s3://"):
+		return newS3Sink(dest, cfg.S3)
+	case strings.HasPrefix(dest, "file://"):
+		return &dirSink{dir: strings.TrimPrefix(dest, "file://")}, nil
+	case strings.Contains(dest, "://"):
+		return nil, fmt.Errorf("unsupported warehouse_export.destination %q", dest)
+	default:
+		return &dirSink{dir: dest}, nil
+	}
+}
+
+// dirSink writes files under a directory, such as a mounted bucket.
+type dirSink struct {
+	dir string
+}
+
+func (d *dirSink) Put(_ context.Context, key string, data []byte) error {
+	target := filepath.Join(d.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	tmp := target + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+// s3Sink uploads with a SigV4-signed PutObject, path-style, so it also
+// works against S3-compatible stores.
+type s3Sink struct {
+	endpoint *url.URL
+	bucket   string
+	prefix   string
+	region   string
+
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+
+	client *http.Client
+	now    func() time.Time
+}
+
+func newS3Sink(dest string, cfg config.S3ExportConfig) (*s3Sink, error) {
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(dest, "s3://"), "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("warehouse_export.destination must name a bucket")
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + region + ".amazonaws.com"
+	}
+	u, err := url.Parse(strings.TrimRight(endpoint, "/"))
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, fmt.Errorf("invalid warehouse_export.s3.endpoint %q", endpoint)
+	}
+	s := &s3Sink{
+		endpoint:        u,
+		bucket:          bucket,
+		prefix:          strings.Trim(prefix, "/"),
+		region:          region,
+		accessKeyID:     os.Getenv(envOr(cfg.AccessKeyIDEnv, "AWS_ACCESS_KEY_ID")),
+		secretAccessKey: os.Getenv(envOr(cfg.SecretAccessKeyEnv, "AWS_SECRET_ACCESS_KEY")),
+		sessionToken:    os.Getenv(envOr(cfg.SessionTokenEnv, "AWS_SESSION_TOKEN")),
+		client:          &http.Client{Timeout: 5 * time.Minute},
+		now:             time.Now,
+	}
+	if s.accessKeyID == "" || s.secretAccessKey == "" {
+		return nil, fmt.Errorf("warehouse export: S3 access key and secret are not set")
+	}
+	return s, nil
+}
+
+func envOr(name, fallback string) string {
+	if name == "" {
+		return fallback
+	}
+	return name
+}
+
+func (s *s3Sink) Put(ctx context.Context, key string, data []byte) error {
+	objectPath := "/" + s.bucket + "/" + path.Join(s.prefix, key)
+	u := *s.endpoint
+	u.Path = s.endpoint.Path + objectPath
+	u.RawPath = awsURIEncode(u.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	s.sign(req, data)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("put object: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// sign adds AWS Signature Version 4 headers for the S3 service.
+func (s *s3Sink) sign(req *http.Request, payload []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	headers := map[string]string{
+		"content-type":         req.Header.Get("Content-Type"),
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	if s.sessionToken != "" {
+		headers["x-amz-security-token"] = s.sessionToken
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(headers[name]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsURIEncode(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.accessKeyID, scope, signedHeaders, signature))
+}
+
+// awsURIEncode escapes every byte of p but unreserved characters and '/',
+// as SigV4 canonical requests require.
+func awsURIEncode(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,397 @@
+// Package warehouse exports scan and stack result summaries as
+// newline-delimited JSON, partitioned by date, so drift trends can be queried
+// from a data warehouse instead of the driftd API.
+package warehouse
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/metrics"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+const (
+	stateFileName = "warehouse_export.json"
+
+	// Record types, which are also the table directories.
+	RecordScans        = "scans"
+	RecordStackResults = "stack_results"
+
+	// scanListLimit is how many recent scans per project each pass reads.
+	scanListLimit = 200
+)
+
+// Leader reports whether this replica should export. The scheduler's
+// elector satisfies it.
+type Leader interface {
+	IsLeader() bool
+}
+
+// ScanRecord is one finished scan.
+type ScanRecord struct {
+	ScanID          string    `json:"scan_id"`
+	Project         string    `json:"project"`
+	Trigger         string    `json:"trigger,omitempty"`
+	Actor           string    `json:"actor,omitempty"`
+	Status          string    `json:"status"`
+	CommitSHA       string    `json:"commit_sha,omitempty"`
+	StartedAt       time.Time `json:"started_at"`
+	EndedAt         time.Time `json:"ended_at"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Stacks          int       `json:"stacks"`
+	Completed       int       `json:"completed"`
+	Failed          int       `json:"failed"`
+	Drifted         int       `json:"drifted"`
+	Errored         int       `json:"errored"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// StackResultRecord is one stack run. Change counts are filled in while
+// the run's result is still retained.
+type StackResultRecord struct {
+	Project     string            `json:"project"`
+	StackPath   string            `json:"stack_path"`
+	Environment string            `json:"environment,omitempty"`
+	ScanID      string            `json:"scan_id,omitempty"`
+	RunAt       time.Time         `json:"run_at"`
+	Drifted     bool              `json:"drifted"`
+	Errored     bool              `json:"errored"`
+	Added       *int              `json:"added,omitempty"`
+	Changed     *int              `json:"changed,omitempty"`
+	Destroyed   *int              `json:"destroyed,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+// Stats counts the records one export wrote.
+type Stats struct {
+	Scans        int `json:"scans"`
+	StackResults int `json:"stack_results"`
+	Files        int `json:"files"`
+}
+
+// exportState is what has been exported: the last run of each stack and
+// the last scan end of each project.
+type exportState struct {
+	Stacks map[string]time.Time `json:"stacks"`
+	Scans  map[string]time.Time `json:"scans"`
+}
+
+// Exporter writes new results to a Sink on an interval. Export is at least
+// once: a pass that fails part way is retried whole, so consumers should
+// dedupe scans on scan_id and stack results on project, stack_path and
+// run_at.
+type Exporter struct {
+	cfg       *config.Config
+	store     storage.Store
+	queue     queue.Backend
+	sink      Sink
+	statePath string
+	leader    Leader
+
+	mu    sync.Mutex
+	state exportState
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New returns an exporter for cfg.WarehouseExport, with its progress kept
+// under cfg.DataDir.
+func New(cfg *config.Config, store storage.Store, q queue.Backend) (*Exporter, error) {
+	sink, err := NewSink(cfg.WarehouseExport)
+	if err != nil {
+		return nil, err
+	}
+	e := &Exporter{
+		cfg:       cfg,
+		store:     store,
+		queue:     q,
+		sink:      sink,
+		statePath: filepath.Join(cfg.DataDir, stateFileName),
+		stop:      make(chan struct{}),
+	}
+	raw, err := os.ReadFile(e.statePath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return nil, fmt.Errorf("failed to read warehouse export state: %w", err)
+	default:
+		if err := json.Unmarshal(raw, &e.state); err != nil {
+			return nil, fmt.Errorf("failed to parse warehouse export state: %w", err)
+		}
+	}
+	if e.state.Stacks == nil {
+		e.state.Stacks = map[string]time.Time{}
+	}
+	if e.state.Scans == nil {
+		e.state.Scans = map[string]time.Time{}
+	}
+	return e, nil
+}
+
+// SetLeader limits exporting to the replica holding the scheduler lease.
+func (e *Exporter) SetLeader(l Leader) {
+	e.leader = l
+}
+
+// Start exports every interval until Stop.
+func (e *Exporter) Start() {
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		ticker := time.NewTicker(e.cfg.WarehouseExport.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.stop:
+				return
+			case <-ticker.C:
+			}
+			if e.leader != nil && !e.leader.IsLeader() {
+				continue
+			}
+			stats, err := e.Export(context.Background(), time.Now())
+			if err != nil {
+				log.Printf("Warehouse export: %v", err)
+			}
+			if stats.Files > 0 {
+				log.Printf("Warehouse export: %d scans, %d stack results in %d files", stats.Scans, stats.StackResults, stats.Files)
+			}
+		}
+	}()
+}
+
+// Stop waits for a running export to finish.
+func (e *Exporter) Stop() {
+	close(e.stop)
+	e.wg.Wait()
+}
+
+// Export writes every scan and stack run not yet exported, one file per
+// record type and UTC day, named for now. Progress is saved only once every
+// file is written.
+func (e *Exporter) Export(ctx context.Context, now time.Time) (Stats, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var stats Stats
+	projects, err := e.store.ListRepos()
+	if err != nil {
+		return stats, err
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+
+	next := exportState{Stacks: map[string]time.Time{}, Scans: map[string]time.Time{}}
+	for k, v := range e.state.Stacks {
+		next.Stacks[k] = v
+	}
+	for k, v := range e.state.Scans {
+		next.Scans[k] = v
+	}
+	files := map[string]*bytes.Buffer{}
+	add := func(recordType string, day time.Time, record any) error {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		key := partitionKey(recordType, day, now)
+		buf := files[key]
+		if buf == nil {
+			buf = &bytes.Buffer{}
+			files[key] = buf
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+		return nil
+	}
+
+	var errs []error
+	for _, project := range projects {
+		scans, err := e.newScans(ctx, project.Name, next.Scans)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", project.Name, err))
+		}
+		for _, rec := range scans {
+			if err := add(RecordScans, rec.EndedAt, rec); err != nil {
+				return stats, err
+			}
+			stats.Scans++
+		}
+		results, err := e.newStackResults(project.Name, next.Stacks)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", project.Name, err))
+		}
+		for _, rec := range results {
+			if err := add(RecordStackResults, rec.RunAt, rec); err != nil {
+				return stats, err
+			}
+			stats.StackResults++
+		}
+	}
+
+	keys := make([]string, 0, len(files))
+	for key := range files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		data, err := gzipBytes(files[key].Bytes())
+		if err != nil {
+			return Stats{}, err
+		}
+		if err := e.sink.Put(ctx, key, data); err != nil {
+			metrics.ObserveWarehouseExportError()
+			return Stats{}, errors.Join(append(errs, fmt.Errorf("write %s: %w", key, err))...)
+		}
+		stats.Files++
+	}
+	if err := e.saveState(next); err != nil {
+		return stats, err
+	}
+	metrics.ObserveWarehouseExport(RecordScans, stats.Scans)
+	metrics.ObserveWarehouseExport(RecordStackResults, stats.StackResults)
+	return stats, errors.Join(errs...)
+}
+
+// newScans returns the project's scans that ended after the last exported
+// one, oldest first, and advances seen.
+func (e *Exporter) newScans(ctx context.Context, projectName string, seen map[string]time.Time) ([]ScanRecord, error) {
+	scans, err := e.queue.ListProjectScans(ctx, projectName, scanListLimit)
+	if err != nil {
+		return nil, err
+	}
+	last := seen[projectName]
+	var out []ScanRecord
+	for _, scan := range scans {
+		if scan.EndedAt.IsZero() || scan.EndedAt.Unix() <= 0 || !scan.EndedAt.After(last) {
+			continue
+		}
+		out = append(out, ScanRecord{
+			ScanID:          scan.ID,
+			Project:         projectName,
+			Trigger:         scan.Trigger,
+			Actor:           scan.Actor,
+			Status:          scan.Status,
+			CommitSHA:       scan.CommitSHA,
+			StartedAt:       scan.StartedAt.UTC(),
+			EndedAt:         scan.EndedAt.UTC(),
+			DurationSeconds: scan.EndedAt.Sub(scan.StartedAt).Seconds(),
+			Stacks:          scan.Total,
+			Completed:       scan.Completed,
+			Failed:          scan.Failed,
+			Drifted:         scan.Drifted,
+			Errored:         scan.Errored,
+			Error:           scan.Error,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].EndedAt.Before(out[j].EndedAt) })
+	if len(out) > 0 {
+		seen[projectName] = out[len(out)-1].EndedAt
+	}
+	return out, nil
+}
+
+// newStackResults returns the project's stack runs after the last exported
+// run of each stack, and advances seen.
+func (e *Exporter) newStackResults(projectName string, seen map[string]time.Time) ([]StackResultRecord, error) {
+	stacks, err := e.store.ListStacks(projectName)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(stacks, func(i, j int) bool { return stacks[i].Path < stacks[j].Path })
+	var out []StackResultRecord
+	var errs []error
+	for _, st := range stacks {
+		key := projectName + "\x00" + st.Path
+		last := seen[key]
+		history, err := e.store.StackHistory(projectName, st.Path, last)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", st.Path, err))
+			continue
+		}
+		for _, entry := range history {
+			if !entry.RunAt.After(last) {
+				continue
+			}
+			rec := StackResultRecord{
+				Project:     projectName,
+				StackPath:   st.Path,
+				Environment: e.cfg.StackEnvironment(st.Path),
+				ScanID:      entry.ScanID,
+				RunAt:       entry.RunAt.UTC(),
+				Drifted:     entry.Drifted,
+				Errored:     entry.Errored,
+				Tags:        st.Tags,
+			}
+			e.fillCounts(&rec, st)
+			out = append(out, rec)
+			seen[key] = entry.RunAt
+		}
+	}
+	return out, errors.Join(errs...)
+}
+
+// fillCounts adds change counts from the run's retained result, or from the
+// stack's latest result when the record is that run.
+func (e *Exporter) fillCounts(rec *StackResultRecord, latest storage.StackStatus) {
+	if latest.RunAt.Equal(rec.RunAt) {
+		rec.Added, rec.Changed, rec.Destroyed = &latest.Added, &latest.Changed, &latest.Destroyed
+		return
+	}
+	if rec.ScanID == "" {
+		return
+	}
+	result, err := e.store.GetScanResult(rec.Project, rec.StackPath, rec.ScanID)
+	if err != nil {
+		return
+	}
+	rec.Added, rec.Changed, rec.Destroyed = &result.Added, &result.Changed, &result.Destroyed
+}
+
+func (e *Exporter) saveState(next exportState) error {
+	data, err := json.Marshal(next)
+	if err != nil {
+		return err
+	}
+	tmp := e.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write warehouse export state: %w", err)
+	}
+	if err := os.Rename(tmp, e.statePath); err != nil {
+		return fmt.Errorf("failed to write warehouse export state: %w", err)
+	}
+	e.state = next
+	return nil
+}
+
+// partitionKey is <type>/dt=<YYYY-MM-DD>/<now>.ndjson.gz, the Hive layout
+// BigQuery and Snowflake external tables read.
+func partitionKey(recordType string, day, now time.Time) string {
+	return path.Join(recordType, "dt="+day.UTC().Format("2006-01-02"), fmt.Sprintf("%d.ndjson.gz", now.UnixNano()))
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
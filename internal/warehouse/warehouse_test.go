@@ -0,0 +1,175 @@
+package warehouse
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/storage"
+)
+
+func newTestExporter(t *testing.T, dest string) (*Exporter, *config.Config, *storage.Storage, *queue.MemoryQueue) {
+	t.Helper()
+	q := queue.NewMemory(time.Minute)
+	t.Cleanup(func() { _ = q.Close() })
+	dataDir := t.TempDir()
+	cfg := &config.Config{DataDir: dataDir}
+	cfg.WarehouseExport = config.WarehouseExportConfig{Enabled: true, Interval: time.Hour, Destination: dest}
+	store := storage.New(dataDir)
+	e, err := New(cfg, store, q)
+	if err != nil {
+		t.Fatalf("new exporter: %v", err)
+	}
+	return e, cfg, store, q
+}
+
+// readExport decodes every record of recordType written under dir.
+func readExport(t *testing.T, dir, recordType string) []map[string]any {
+	t.Helper()
+	files, _ := filepath.Glob(filepath.Join(dir, recordType, "dt=*", "*.ndjson.gz"))
+	var records []map[string]any
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("gzip: %v", err)
+		}
+		sc := bufio.NewScanner(zr)
+		for sc.Scan() {
+			var rec map[string]any
+			if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+				t.Fatalf("decode %s: %v", file, err)
+			}
+			records = append(records, rec)
+		}
+		f.Close()
+	}
+	return records
+}
+
+func TestExportWritesNewRecordsOnce(t *testing.T) {
+	dest := t.TempDir()
+	e, cfg, store, q := newTestExporter(t, dest)
+	ctx := context.Background()
+
+	day := time.Now().UTC().Truncate(24 * time.Hour).Add(-72*time.Hour + 12*time.Hour)
+	if err := store.SaveResult("infra", "envs/prod", &storage.RunResult{RunAt: day, Drifted: true, Changed: 2}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := store.SaveResult("infra", "envs/prod", &storage.RunResult{RunAt: day.Add(24 * time.Hour)}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	scan, err := q.StartScan(ctx, "infra", "manual", "", "", 1)
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	if err := q.FailScan(ctx, scan.ID, "infra", "clone failed"); err != nil {
+		t.Fatalf("fail scan: %v", err)
+	}
+
+	stats, err := e.Export(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("export: %v", err)
+	}
+	if stats.StackResults != 2 || stats.Scans != 1 || stats.Files != 3 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "stack_results", "dt="+day.Add(24*time.Hour).Format("2006-01-02"))); err != nil {
+		t.Fatalf("expected a partition per run day: %v", err)
+	}
+	results := readExport(t, dest, RecordStackResults)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 stack results, got %v", results)
+	}
+	scans := readExport(t, dest, RecordScans)
+	if len(scans) != 1 || scans[0]["scan_id"] != scan.ID || scans[0]["status"] != queue.ScanStatusFailed {
+		t.Fatalf("unexpected scans: %v", scans)
+	}
+
+	if stats, err := e.Export(ctx, time.Now()); err != nil || stats.Files != 0 {
+		t.Fatalf("expected nothing new, got %+v %v", stats, err)
+	}
+
+	// Progress survives a restart.
+	if err := store.SaveResult("infra", "envs/prod", &storage.RunResult{RunAt: day.Add(48 * time.Hour), Drifted: true, Added: 1}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	restarted, err := New(cfg, store, q)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	stats, err = restarted.Export(ctx, time.Now())
+	if err != nil || stats.StackResults != 1 || stats.Scans != 0 {
+		t.Fatalf("expected only the new run after restart, got %+v %v", stats, err)
+	}
+	var latest map[string]any
+	for _, rec := range readExport(t, dest, RecordStackResults) {
+		if strings.HasPrefix(rec["run_at"].(string), day.Add(48*time.Hour).Format("2006-01-02")) {
+			latest = rec
+		}
+	}
+	if latest == nil || latest["drifted"] != true || latest["added"] != float64(1) {
+		t.Fatalf("unexpected latest record: %v", latest)
+	}
+}
+
+func TestS3SinkSignsPut(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	t.Setenv("TEST_AK", "AKIDEXAMPLE")
+	t.Setenv("TEST_SK", "secret")
+
+	sink, err := NewSink(config.WarehouseExportConfig{
+		Destination: "s3://analytics/driftd/",
+		S3: config.S3ExportConfig{
+			Endpoint:           srv.URL,
+			Region:             "eu-west-1",
+			AccessKeyIDEnv:     "TEST_AK",
+			SecretAccessKeyEnv: "TEST_SK",
+		},
+	})
+	if err != nil {
+		t.Fatalf("sink: %v", err)
+	}
+	if err := sink.Put(context.Background(), "scans/dt=2026-03-01/1.ndjson.gz", []byte("data")); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if got.Method != http.MethodPut || got.URL.Path != "/analytics/driftd/scans/dt=2026-03-01/1.ndjson.gz" || !bytes.Equal(body, []byte("data")) {
+		t.Fatalf("unexpected request %s %s", got.Method, got.URL.Path)
+	}
+	auth := got.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") || !strings.Contains(auth, "/eu-west-1/s3/aws4_request") ||
+		!strings.Contains(auth, "SignedHeaders=content-type;host;x-amz-content-sha256;x-amz-date") {
+		t.Fatalf("unexpected authorization: %q", auth)
+	}
+	if got.URL.RawPath != "/analytics/driftd/scans/dt%3D2026-03-01/1.ndjson.gz" {
+		t.Fatalf("path not SigV4 encoded: %q", got.URL.RawPath)
+	}
+	if got.Header.Get("X-Amz-Content-Sha256") != sha256Hex([]byte("data")) {
+		t.Fatalf("payload hash not sent")
+	}
+
+	if _, err := NewSink(config.WarehouseExportConfig{Destination: "gs://bucket"}); err == nil {
+		t.Fatal("expected unsupported scheme error")
+	}
+}
@@ -17,6 +17,8 @@ func (w *Worker) resolveScanContext(ctx context.Context, job *queue.StackScan) (
 		ProjectURL:  job.ProjectURL,
 		StackPath:   job.StackPath,
 		ScanID:      job.ScanID,
+		InitArgs:    job.InitArgs,
+		PlanArgs:    job.PlanArgs,
 	}
 
 	if job.ScanID != "" {
@@ -28,6 +30,7 @@ func (w *Worker) resolveScanContext(ctx context.Context, job *queue.StackScan) (
 				return nil, errScanCanceled
 			}
 			sc.CommitSHA = scan.CommitSHA
+			sc.CommitInfo = scan.CommitInfo
 			sc.WorkspacePath = scan.WorkspacePath
 
 			if v, ok := scan.StackTFVersions[job.StackPath]; ok {
@@ -43,31 +46,34 @@ func (w *Worker) resolveScanContext(ctx context.Context, job *queue.StackScan) (
 		}
 	}
 
-	if err := w.resolveAuth(ctx, job, sc); err != nil {
+	sc.Project = w.projectConfig(job.ProjectName)
+
+	if err := w.resolveAuth(ctx, sc); err != nil {
 		return nil, err
 	}
 
 	return sc, nil
 }
 
-func (w *Worker) resolveAuth(ctx context.Context, job *queue.StackScan, sc *ScanContext) error {
-	if w.cfg == nil || sc.WorkspacePath != "" {
+func (w *Worker) projectConfig(name string) *config.ProjectConfig {
+	if w.cfg == nil {
 		return nil
 	}
-
-	var projectCfg *config.ProjectConfig
 	if w.provider != nil {
-		if resolved, err := w.provider.Get(job.ProjectName); err == nil {
-			projectCfg = resolved
+		if resolved, err := w.provider.Get(name); err == nil {
+			return resolved
 		}
-	} else {
-		projectCfg = w.cfg.GetProject(job.ProjectName)
+		return nil
 	}
-	if projectCfg == nil {
+	return w.cfg.GetProject(name)
+}
+
+func (w *Worker) resolveAuth(ctx context.Context, sc *ScanContext) error {
+	if sc.Project == nil || sc.WorkspacePath != "" {
 		return nil
 	}
 
-	authMethod, authErr := gitauth.AuthMethod(ctx, projectCfg)
+	authMethod, authErr := gitauth.AuthMethod(ctx, sc.Project)
 	if authErr != nil {
 		return authErr
 	}
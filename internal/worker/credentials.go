@@ -0,0 +1,33 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// checkCredentials runs the project's credential check before any stack is
+// planned. It returns an error only in strict mode, when the credentials
+// are not plan-only or could not be checked.
+func (w *Worker) checkCredentials(ctx context.Context, sc *ScanContext) error {
+	if sc.Project == nil || !sc.Project.CredentialCheck.Enabled() {
+		return nil
+	}
+	strict := sc.Project.CredentialCheck.Strict()
+	result, err := w.creds.Check(ctx, sc.Project)
+	if err != nil {
+		if strict {
+			return fmt.Errorf("credential check failed: %v", err)
+		}
+		log.Printf("Credential check for project %s failed: %v", sc.Project.Name, err)
+		return nil
+	}
+	if result.ReadOnly() {
+		return nil
+	}
+	if strict {
+		return fmt.Errorf("refusing to scan: %s", result.Problem())
+	}
+	log.Printf("Warning: project %s: %s", sc.Project.Name, result.Problem())
+	return nil
+}
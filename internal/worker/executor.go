@@ -2,7 +2,9 @@ package worker
 
 import (
 	"context"
+	"path/filepath"
 
+	"github.com/driftdhq/driftd/internal/config"
 	"github.com/driftdhq/driftd/internal/runner"
 	"github.com/driftdhq/driftd/internal/storage"
 )
@@ -14,6 +16,41 @@ func (w *Worker) executePlan(ctx context.Context, sc *ScanContext) (*storage.Run
 		cloneDepth = w.cfg.Worker.CloneDepth
 		blockExternalDataSource = w.cfg.Worker.BlockExternalDataSource
 	}
+	fetchDependencyOutputFromState := false
+	cacheDependencyOutputs := false
+	var linkVars []string
+	var redactPatterns []string
+	var plugin *runner.Plugin
+	pulumiStack := ""
+	var noise runner.NoiseHeuristics
+	blameDrift := false
+	if sc.Project != nil {
+		noise = runner.NoiseHeuristics{
+			Whitespace: sc.Project.NoiseReduction.Whitespace,
+			JSON:       sc.Project.NoiseReduction.JSON,
+			Ordering:   sc.Project.NoiseReduction.Ordering,
+		}
+		fetchDependencyOutputFromState = sc.Project.Terragrunt.FetchDependencyOutputFromState
+		cacheDependencyOutputs = sc.Project.Terragrunt.CacheDependencyOutputs
+		linkVars = config.ConsoleLinkVars(sc.Project.ConsoleLinks)
+		redactPatterns = sc.Project.RedactPatterns
+		pulumiStack = sc.Project.Pulumi.Stack
+		blameDrift = sc.Project.BlameDrift
+		if p := sc.Project.Runner; p != nil {
+			plugin = &runner.Plugin{Command: p.Command, Args: p.Args, PassEnv: p.PassEnv}
+		}
+	}
+
+	var warm *runner.WarmWorkspace
+	if w.warm != nil && plugin == nil && sc.WorkspacePath != "" && runner.WarmEligible(filepath.Join(sc.WorkspacePath, sc.StackPath)) {
+		init := warmInit{commit: sc.CommitSHA, tfVersion: sc.TFVersion, initArgs: sc.InitArgs}
+		if warm = w.warm.acquire(sc.ProjectName, sc.StackPath, init); warm != nil {
+			defer w.warm.release(sc.ProjectName, sc.StackPath)
+		}
+	}
+	if w.warm != nil && plugin == nil && sc.WorkspacePath != "" {
+		w.refreshWarm(sc)
+	}
 
 	return w.runner.Run(ctx, &runner.RunParams{
 		ProjectName:             sc.ProjectName,
@@ -22,9 +59,34 @@ func (w *Worker) executePlan(ctx context.Context, sc *ScanContext) (*storage.Run
 		TFVersion:               sc.TFVersion,
 		TGVersion:               sc.TGVersion,
 		RunID:                   sc.ScanID,
+		CommitSHA:               sc.CommitSHA,
+		CommitInfo:              sc.CommitInfo,
 		Auth:                    sc.Auth,
 		WorkspacePath:           sc.WorkspacePath,
 		CloneDepth:              cloneDepth,
 		BlockExternalDataSource: blockExternalDataSource,
+
+		TerragruntFetchDependencyOutputFromState: fetchDependencyOutputFromState,
+		RedactPatterns:                           redactPatterns,
+		PulumiStack:                              pulumiStack,
+		Plugin:                                   plugin,
+		InitArgs:                                 sc.InitArgs,
+		PlanArgs:                                 sc.PlanArgs,
+		Noise:                                    noise,
+		BlameDrift:                               blameDrift,
+		Frozen:                                   w.environmentFrozen(sc.StackPath),
+
+		TerragruntCacheDependencyOutputs: cacheDependencyOutputs,
+		LinkVars:                         linkVars,
+		Warm:                             warm,
 	})
 }
+
+// environmentFrozen reports whether the environment of stackPath is frozen.
+func (w *Worker) environmentFrozen(stackPath string) bool {
+	if w.cfg == nil || w.freeze == nil {
+		return false
+	}
+	_, frozen := w.freeze.Frozen(w.cfg.StackEnvironment(stackPath))
+	return frozen
+}
@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+// trackJob registers the cancel func of a running stack scan under its scan
+// so a cancellation notice can preempt it. The returned func unregisters it.
+func (w *Worker) trackJob(scanID string, cancel context.CancelCauseFunc) func() {
+	w.jobsMu.Lock()
+	defer w.jobsMu.Unlock()
+	if w.jobs == nil {
+		w.jobs = make(map[string]map[uint64]context.CancelCauseFunc)
+	}
+	w.nextJob++
+	id := w.nextJob
+	if w.jobs[scanID] == nil {
+		w.jobs[scanID] = make(map[uint64]context.CancelCauseFunc)
+	}
+	w.jobs[scanID][id] = cancel
+	return func() {
+		w.jobsMu.Lock()
+		defer w.jobsMu.Unlock()
+		delete(w.jobs[scanID], id)
+		if len(w.jobs[scanID]) == 0 {
+			delete(w.jobs, scanID)
+		}
+	}
+}
+
+// preemptScan cancels every running stack scan of scanID and reports how
+// many were stopped.
+func (w *Worker) preemptScan(scanID string) int {
+	w.jobsMu.Lock()
+	defer w.jobsMu.Unlock()
+	for _, cancel := range w.jobs[scanID] {
+		cancel(errScanCanceled)
+	}
+	return len(w.jobs[scanID])
+}
+
+// cancelLoop preempts running stack scans as soon as their scan is
+// canceled. Missed notices are caught by watchScanCancel's polling. cancels
+// is the subscription Start made, or nil when it failed.
+func (w *Worker) cancelLoop(cancels <-chan queue.ScanCancel) {
+	defer w.wg.Done()
+
+	for {
+		if cancels == nil {
+			var err error
+			cancels, err = w.queue.SubscribeScanCancels(w.ctx)
+			if err != nil {
+				if w.ctx.Err() != nil {
+					return
+				}
+				log.Printf("Worker %s scan cancel subscribe error: %v", w.id, err)
+				cancels = nil
+				select {
+				case <-w.ctx.Done():
+					return
+				case <-time.After(5 * time.Second):
+				}
+				continue
+			}
+		}
+		for c := range cancels {
+			if n := w.preemptScan(c.ScanID); n > 0 {
+				log.Printf("Preempting %d stack scans of canceled scan %s", n, c.ScanID)
+			}
+		}
+		cancels = nil
+		if w.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// cancelStack records a preempted stack scan as canceled rather than failed.
+func (w *Worker) cancelStack(job *queue.StackScan) {
+	if err := w.queue.CancelStackScan(w.ctx, job, "scan canceled"); err != nil {
+		log.Printf("Failed to mark stack scan %s as canceled: %v", job.ID, err)
+	}
+	now := time.Now()
+	_ = w.queue.PublishStackEvent(w.ctx, job.ProjectName, queue.StackEvent{
+		ProjectName: job.ProjectName,
+		ScanID:      job.ScanID,
+		StackPath:   job.StackPath,
+		Status:      queue.StatusCanceled,
+		RunAt:       &now,
+	})
+}
@@ -13,13 +13,18 @@ func (w *Worker) processStackScan(job *queue.StackScan) {
 	log.Printf("Processing stack scan %s: %s/%s", job.ID, job.ProjectName, job.StackPath)
 
 	now := time.Now()
-	_ = w.queue.PublishStackEvent(w.ctx, job.ProjectName, queue.StackEvent{
+	event := queue.StackEvent{
 		ProjectName: job.ProjectName,
 		ScanID:      job.ScanID,
 		StackPath:   job.StackPath,
 		Status:      "running",
 		RunAt:       &now,
-	})
+	}
+	// A retry's CreatedAt includes its earlier attempts, not queue wait.
+	if job.Retries == 0 && !job.CreatedAt.IsZero() {
+		event.QueuedAt = &job.CreatedAt
+	}
+	_ = w.queue.PublishStackEvent(w.ctx, job.ProjectName, event)
 
 	sc, err := w.resolveScanContext(w.ctx, job)
 	if err != nil {
@@ -29,18 +34,30 @@ func (w *Worker) processStackScan(job *queue.StackScan) {
 		w.failStack(job, nil, err.Error())
 		return
 	}
+	if err := w.checkCredentials(w.ctx, sc); err != nil {
+		w.failStack(job, sc, err.Error())
+		return
+	}
 
 	timeout := 30 * time.Minute
 	if w.cfg != nil && w.cfg.Worker.StackTimeout > 0 {
 		timeout = w.cfg.Worker.StackTimeout
 	}
-	ctx, cancel := context.WithTimeout(w.ctx, timeout)
+	jobCtx, preempt := context.WithCancelCause(w.ctx)
+	defer preempt(nil)
+	ctx, cancel := context.WithTimeout(jobCtx, timeout)
 	defer cancel()
 	if job.ScanID != "" {
-		go w.watchScanCancel(ctx, cancel, job.ScanID)
+		defer w.trackJob(job.ScanID, preempt)()
+		go w.watchScanCancel(ctx, preempt, job.ScanID)
 	}
 
 	result, execErr := w.executePlan(ctx, sc)
+	if errors.Is(context.Cause(jobCtx), errScanCanceled) {
+		log.Printf("Stack scan %s preempted: scan %s was canceled", job.ID, job.ScanID)
+		w.cancelStack(job)
+		return
+	}
 	w.reportResult(job, sc, result, execErr)
 }
 
@@ -54,7 +71,7 @@ func scanEndedAt(scan *queue.Scan) *time.Time {
 	return &scan.EndedAt
 }
 
-func (w *Worker) watchScanCancel(ctx context.Context, cancel context.CancelFunc, scanID string) {
+func (w *Worker) watchScanCancel(ctx context.Context, cancel context.CancelCauseFunc, scanID string) {
 	ticker := time.NewTicker(3 * time.Second)
 	defer ticker.Stop()
 
@@ -70,7 +87,7 @@ func (w *Worker) watchScanCancel(ctx context.Context, cancel context.CancelFunc,
 			continue
 		}
 		if scan.Status == queue.ScanStatusCanceled {
-			cancel()
+			cancel(errScanCanceled)
 			return
 		}
 	}
@@ -78,5 +78,20 @@ func (w *Worker) publishStackCompletion(job *queue.StackScan, sc *ScanContext, r
 		Status:      "completed",
 		Drifted:     &drifted,
 		RunAt:       &now,
+
+		RootCauseHint: result.RootCauseHint,
+		OutputChanges: outputNames(result.OutputChanges),
+		Frozen:        result.ObservedDuringFreeze,
 	})
 }
+
+func outputNames(changes []storage.OutputChange) []string {
+	if len(changes) == 0 {
+		return nil
+	}
+	names := make([]string, len(changes))
+	for i, c := range changes {
+		names[i] = c.Name
+	}
+	return names
+}
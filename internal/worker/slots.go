@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// slots is a weighted semaphore sized to the worker's concurrency. A heavy
+// stack scan takes several slots, so fewer scans run beside it. Waiters are
+// served in arrival order so lighter scans claimed later cannot starve a
+// heavy one.
+type slots struct {
+	mu      sync.Mutex
+	size    int
+	used    int
+	waiters list.List // of *slotWaiter
+}
+
+type slotWaiter struct {
+	n       int
+	granted int
+	ready   chan struct{}
+}
+
+func newSlots(size int) *slots {
+	return &slots{size: size}
+}
+
+// acquire blocks until n slots are free and returns how many it took. A
+// weight above the current size takes every slot instead of waiting forever.
+func (s *slots) acquire(ctx context.Context, n int) (int, error) {
+	if n < 1 {
+		n = 1
+	}
+	s.mu.Lock()
+	if s.waiters.Len() == 0 {
+		if need := min(n, s.size); s.size-s.used >= need {
+			s.used += need
+			s.mu.Unlock()
+			return need, nil
+		}
+	}
+	w := &slotWaiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return w.granted, nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-w.ready:
+			// Granted while canceling; hand the slots back.
+			s.used -= w.granted
+		default:
+			s.waiters.Remove(elem)
+		}
+		s.grantLocked()
+		s.mu.Unlock()
+		return 0, ctx.Err()
+	}
+}
+
+// release returns n slots taken by acquire.
+func (s *slots) release(n int) {
+	s.mu.Lock()
+	s.used -= n
+	s.grantLocked()
+	s.mu.Unlock()
+}
+
+// resize changes the number of slots. Scans already running keep theirs, so
+// usage may exceed a lowered size until they finish.
+func (s *slots) resize(size int) {
+	s.mu.Lock()
+	s.size = size
+	s.grantLocked()
+	s.mu.Unlock()
+}
+
+func (s *slots) inUse() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.used
+}
+
+func (s *slots) grantLocked() {
+	for front := s.waiters.Front(); front != nil; front = s.waiters.Front() {
+		w := front.Value.(*slotWaiter)
+		need := min(w.n, s.size)
+		if need < 1 || s.size-s.used < need {
+			return
+		}
+		s.used += need
+		w.granted = need
+		s.waiters.Remove(front)
+		close(w.ready)
+	}
+}
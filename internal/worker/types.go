@@ -1,7 +1,9 @@
 package worker
 
 import (
+	"github.com/driftdhq/driftd/internal/config"
 	"github.com/driftdhq/driftd/internal/queue"
+	"github.com/driftdhq/driftd/internal/storage"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 )
 
@@ -11,9 +13,13 @@ type ScanContext struct {
 	StackPath     string
 	ScanID        string
 	CommitSHA     string
+	CommitInfo    *storage.CommitInfo
 	WorkspacePath string
 	TFVersion     string
 	TGVersion     string
 	Auth          transport.AuthMethod
 	Scan          *queue.Scan
+	Project       *config.ProjectConfig
+	InitArgs      []string
+	PlanArgs      []string
 }
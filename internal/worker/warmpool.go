@@ -0,0 +1,367 @@
+package worker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/runner"
+)
+
+// warmDecayRuns is how many runs the pool counts between halving every
+// stack's run count. Recent runs outweigh old ones, and stacks that stopped
+// being planned drop out of the counts.
+const warmDecayRuns = 500
+
+// warmPool keeps a warm workspace for each of the worker's most frequently
+// planned stacks and holds their total size under a disk budget. Run counts
+// and workspaces live for the worker process; the directory is emptied on
+// start.
+type warmPool struct {
+	dir      string
+	stacks   int
+	maxBytes int64
+
+	mu      sync.Mutex
+	runs    map[string]int
+	counted int
+	entries map[string]*warmEntry
+}
+
+type warmEntry struct {
+	project string
+	stack   string
+	ws      *runner.WarmWorkspace
+	inUse   bool
+	size    int64
+	// init is what the workspace was last initialized from, replayed when
+	// the pool refreshes it at a newer commit.
+	init warmInit
+}
+
+// warmInit is the commit and init inputs of a warm workspace's last init.
+type warmInit struct {
+	commit    string
+	tfVersion string
+	initArgs  []string
+}
+
+// warmRefresh is an idle warm workspace lent out to be initialized at a
+// newer commit.
+type warmRefresh struct {
+	stack string
+	init  warmInit
+	ws    *runner.WarmWorkspace
+}
+
+func newWarmPool(cfg config.WarmPoolConfig, workerID string) *warmPool {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "driftd-warm")
+	}
+	return &warmPool{
+		dir:      filepath.Join(dir, workerID),
+		stacks:   cfg.Stacks,
+		maxBytes: cfg.MaxDiskBytes,
+		runs:     map[string]int{},
+		entries:  map[string]*warmEntry{},
+	}
+}
+
+// reset removes workspaces left by an earlier run of the worker.
+func (p *warmPool) reset() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries = map[string]*warmEntry{}
+	if err := os.RemoveAll(p.dir); err != nil {
+		return err
+	}
+	return os.MkdirAll(p.dir, 0755)
+}
+
+// acquire counts a run of the stack and returns its warm workspace when the
+// stack is hot, or nil. A workspace is lent to one run at a time, which
+// initializes it from init.
+func (p *warmPool) acquire(projectName, stackPath string, init warmInit) *runner.WarmWorkspace {
+	key := projectName + "/" + stackPath
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.runs[key]++
+	if p.counted++; p.counted%warmDecayRuns == 0 {
+		p.decayLocked()
+	}
+	if !p.isHotLocked(key) {
+		return nil
+	}
+	e := p.entries[key]
+	if e == nil {
+		sum := sha256.Sum256([]byte(key))
+		e = &warmEntry{
+			project: projectName,
+			stack:   stackPath,
+			ws:      &runner.WarmWorkspace{Dir: filepath.Join(p.dir, hex.EncodeToString(sum[:8]))},
+		}
+		p.entries[key] = e
+	}
+	if e.inUse {
+		return nil
+	}
+	e.inUse = true
+	e.init = init
+	return e.ws
+}
+
+// stale reports whether the project has an idle warm workspace last
+// initialized at another commit.
+func (p *warmPool) stale(projectName, commit string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, e := range p.entries {
+		if e.project == projectName && !e.inUse && e.init.commit != "" && e.init.commit != commit {
+			return true
+		}
+	}
+	return false
+}
+
+// takeStale lends out the project's idle warm workspaces last initialized at
+// another commit. Stacks in skip are moved to commit without being lent,
+// since their own runs initialize them. The caller records each successful
+// refresh with markRefreshed and returns each workspace with release.
+func (p *warmPool) takeStale(projectName, commit string, skip map[string]bool) []warmRefresh {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var refreshes []warmRefresh
+	for _, e := range p.entries {
+		if e.project != projectName || e.inUse || e.init.commit == "" || e.init.commit == commit {
+			continue
+		}
+		if skip[e.stack] {
+			e.init.commit = commit
+			continue
+		}
+		e.inUse = true
+		refreshes = append(refreshes, warmRefresh{stack: e.stack, init: e.init, ws: e.ws})
+	}
+	sort.Slice(refreshes, func(i, j int) bool { return refreshes[i].stack < refreshes[j].stack })
+	return refreshes
+}
+
+// markRefreshed records that a workspace lent by takeStale was initialized
+// at commit. Workspaces whose refresh failed keep their old commit, so the
+// next scan refreshes them again.
+func (p *warmPool) markRefreshed(projectName, stackPath, commit string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e := p.entries[projectName+"/"+stackPath]; e != nil {
+		e.init.commit = commit
+	}
+}
+
+// release returns a workspace after its run and drops workspaces of stacks
+// that are no longer hot or do not fit the disk budget.
+func (p *warmPool) release(projectName, stackPath string) {
+	key := projectName + "/" + stackPath
+	p.mu.Lock()
+	e := p.entries[key]
+	if e == nil {
+		p.mu.Unlock()
+		return
+	}
+	dir := e.ws.Dir
+	p.mu.Unlock()
+
+	// Measure outside the lock; the entry is still marked in use.
+	size := dirSize(dir)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e.size = size
+	e.inUse = false
+	p.pruneLocked()
+}
+
+// stats returns the number of warm workspaces and their size in bytes.
+func (p *warmPool) stats() (int, int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var total int64
+	for _, e := range p.entries {
+		total += e.size
+	}
+	return len(p.entries), total
+}
+
+// isHotLocked reports whether key is among the pool's most planned stacks.
+func (p *warmPool) isHotLocked(key string) bool {
+	if p.stacks <= 0 {
+		return false
+	}
+	runs := p.runs[key]
+	above := 0
+	for other, n := range p.runs {
+		if other != key && (n > runs || (n == runs && other < key)) {
+			above++
+			if above >= p.stacks {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// decayLocked halves every run count and forgets stacks whose count reaches
+// zero, which bounds the counts to the stacks planned recently.
+func (p *warmPool) decayLocked() {
+	for key, n := range p.runs {
+		if n /= 2; n == 0 {
+			delete(p.runs, key)
+		} else {
+			p.runs[key] = n
+		}
+	}
+}
+
+// pruneLocked removes idle workspaces of stacks that are no longer hot,
+// then the least planned idle ones until the pool fits its budget.
+func (p *warmPool) pruneLocked() {
+	var total int64
+	keys := make([]string, 0, len(p.entries))
+	for key, e := range p.entries {
+		if !e.inUse && !p.isHotLocked(key) {
+			p.removeLocked(key, "no longer hot")
+			continue
+		}
+		total += e.size
+		keys = append(keys, key)
+	}
+	if p.maxBytes <= 0 || total <= p.maxBytes {
+		return
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if p.runs[keys[i]] != p.runs[keys[j]] {
+			return p.runs[keys[i]] < p.runs[keys[j]]
+		}
+		return keys[i] > keys[j]
+	})
+	for _, key := range keys {
+		if total <= p.maxBytes {
+			return
+		}
+		e := p.entries[key]
+		if e.inUse {
+			continue
+		}
+		total -= e.size
+		p.removeLocked(key, "over the disk budget")
+	}
+}
+
+func (p *warmPool) removeLocked(key, reason string) {
+	e := p.entries[key]
+	delete(p.entries, key)
+	if err := os.RemoveAll(e.ws.Dir); err != nil {
+		log.Printf("Warm pool: remove %s: %v", key, err)
+		return
+	}
+	if e.size > 0 {
+		log.Printf("Warm pool: dropped %s (%s)", key, reason)
+	}
+}
+
+// dirSize sums the sizes of the regular files under dir. Symlinks are not
+// followed.
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// refreshWarm brings the project's other hot stacks up to the scan's commit
+// in the background, so their next scan can plan without init. Stacks of
+// the same scan are left to their own runs. One refresh runs at a time.
+func (w *Worker) refreshWarm(sc *ScanContext) {
+	if sc.CommitSHA == "" || !w.warm.stale(sc.ProjectName, sc.CommitSHA) {
+		return
+	}
+	if !w.warmRefreshing.CompareAndSwap(false, true) {
+		return
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer w.warmRefreshing.Store(false)
+		w.refreshWarmStacks(w.ctx, sc)
+	}()
+}
+
+// refreshWarmStacks initializes the refreshed stacks in a copy of the scan
+// workspace, taken while the scan still holds it, so the scan's own runs
+// never see the lock files init writes.
+func (w *Worker) refreshWarmStacks(ctx context.Context, sc *ScanContext) {
+	stackScans, err := w.queue.ListScanStackScans(ctx, sc.ScanID)
+	if err != nil {
+		log.Printf("Warm pool: list stack scans of %s: %v", sc.ScanID, err)
+		return
+	}
+	skip := make(map[string]bool, len(stackScans))
+	for _, ss := range stackScans {
+		skip[ss.StackPath] = true
+	}
+	refreshes := w.warm.takeStale(sc.ProjectName, sc.CommitSHA, skip)
+	if len(refreshes) == 0 {
+		return
+	}
+	defer func() {
+		for _, r := range refreshes {
+			w.warm.release(sc.ProjectName, r.stack)
+		}
+	}()
+
+	root, err := os.MkdirTemp("", "driftd-warm-init-*")
+	if err != nil {
+		log.Printf("Warm pool: refresh %s: %v", sc.ProjectName, err)
+		return
+	}
+	defer os.RemoveAll(root)
+	if err := runner.CopyWorkspace(sc.WorkspacePath, root); err != nil {
+		log.Printf("Warm pool: copy workspace of %s: %v", sc.ScanID, err)
+		return
+	}
+	refreshed := 0
+	for _, r := range refreshes {
+		if ctx.Err() != nil {
+			return
+		}
+		// Sparse workspaces hold only the scan's stacks.
+		if _, err := os.Stat(filepath.Join(root, r.stack)); err != nil {
+			continue
+		}
+		if err := w.initWarm(ctx, r.ws, root, r.stack, r.init.tfVersion, r.init.initArgs); err != nil {
+			log.Printf("Warm pool: refresh %s/%s: %v", sc.ProjectName, r.stack, err)
+			continue
+		}
+		w.warm.markRefreshed(sc.ProjectName, r.stack, sc.CommitSHA)
+		refreshed++
+	}
+	if refreshed > 0 {
+		log.Printf("Warm pool: initialized %d stacks of %s at %s", refreshed, sc.ProjectName, sc.CommitSHA)
+	}
+}
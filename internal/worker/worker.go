@@ -6,79 +6,295 @@ import (
 	"log"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/credcheck"
+	"github.com/driftdhq/driftd/internal/freeze"
 	"github.com/driftdhq/driftd/internal/projects"
 	"github.com/driftdhq/driftd/internal/queue"
 	"github.com/driftdhq/driftd/internal/runner"
 	"github.com/driftdhq/driftd/internal/storage"
 )
 
-const recoveryInterval = 10 * time.Second
+const (
+	recoveryInterval  = 10 * time.Second
+	heartbeatInterval = 10 * time.Second
+)
 
 type Worker struct {
-	id          string
-	queue       *queue.Queue
-	runner      Runner
+	id        string
+	hostname  string
+	startedAt time.Time
+	queue     queue.Backend
+	runner    Runner
+	wg        sync.WaitGroup
+	ctx       context.Context
+	cancel    context.CancelFunc
+	cfg       *config.Config
+	provider  projects.Provider
+	prewarm   func(ctx context.Context) error
+	creds     *credcheck.Checker
+	freeze    *freeze.Store
+
+	// mu guards the claim loops. Each loop has its own cancel func so loops
+	// can be stopped from claiming new work while in-flight scans finish.
+	mu          sync.Mutex
 	concurrency int
-	wg          sync.WaitGroup
-	ctx         context.Context
-	cancel      context.CancelFunc
-	cfg         *config.Config
-	provider    projects.Provider
-	prewarm     func(ctx context.Context) error
+	draining    bool
+	loops       []context.CancelFunc
+	nextLoop    int
+	active      atomic.Int32
+	// slots limits running stack scans by weight, not count.
+	slots *slots
+
+	// jobs holds the cancel funcs of running stack scans by scan ID.
+	jobsMu  sync.Mutex
+	jobs    map[string]map[uint64]context.CancelCauseFunc
+	nextJob uint64
+
+	// warm keeps hot stacks initialized; nil when the pool is disabled.
+	warm *warmPool
+	// warmRefreshing is set while initWarm brings hot stacks up to a new
+	// commit in the background.
+	warmRefreshing atomic.Bool
+	initWarm       func(ctx context.Context, ws *runner.WarmWorkspace, projectRoot, stackPath, tfVersion string, initArgs []string) error
 }
 
 type Runner interface {
 	Run(ctx context.Context, params *runner.RunParams) (*storage.RunResult, error)
 }
 
-func New(q *queue.Queue, r Runner, concurrency int, cfg *config.Config, provider projects.Provider) *Worker {
+func New(q queue.Backend, r Runner, concurrency int, cfg *config.Config, provider projects.Provider) *Worker {
 	hostname, _ := os.Hostname()
 	workerID := fmt.Sprintf("%s-%d", hostname, os.Getpid())
 
 	ctx, cancel := context.WithCancel(context.Background())
 
+	var frozen *freeze.Store
+	if cfg != nil && cfg.DataDir != "" {
+		frozen = freeze.New(cfg.DataDir)
+	}
+	var warm *warmPool
+	if cfg != nil && cfg.Worker.WarmPool.Enabled {
+		warm = newWarmPool(cfg.Worker.WarmPool, workerID)
+	}
 	return &Worker{
 		id:          workerID,
+		hostname:    hostname,
 		queue:       q,
 		runner:      r,
 		concurrency: concurrency,
+		slots:       newSlots(concurrency),
 		ctx:         ctx,
 		cancel:      cancel,
 		cfg:         cfg,
 		provider:    provider,
 		prewarm:     runner.EnsureDefaultBinaries,
+		initWarm:    runner.InitWarm,
+		creds:       credcheck.New(),
+		freeze:      frozen,
+		warm:        warm,
 	}
 }
 
+// ID returns the worker process identifier used for admin commands.
+func (w *Worker) ID() string {
+	return w.id
+}
+
 func (w *Worker) Start() {
 	log.Printf("Starting worker %s with concurrency %d", w.id, w.concurrency)
+	w.startedAt = time.Now()
 
 	if w.prewarm != nil {
 		if err := w.prewarm(w.ctx); err != nil {
 			log.Printf("Warning: prewarm binaries failed: %v", err)
 		}
 	}
+	if w.warm != nil {
+		if err := w.warm.reset(); err != nil {
+			log.Printf("Warning: warm pool disabled: %v", err)
+			w.warm = nil
+		}
+	}
 
 	// Single recovery goroutine instead of per-worker recovery
 	w.wg.Add(1)
 	go w.recoveryLoop()
-
-	for i := 0; i < w.concurrency; i++ {
-		w.wg.Add(1)
-		go w.processLoop(i)
+	w.wg.Add(1)
+	go w.adminLoop()
+	// Subscribe before any slot starts, so a scan canceled right after
+	// Start still preempts its running stack scans.
+	cancels, err := w.queue.SubscribeScanCancels(w.ctx)
+	if err != nil {
+		log.Printf("Worker %s scan cancel subscribe error: %v", w.id, err)
 	}
+	w.wg.Add(1)
+	go w.cancelLoop(cancels)
+	w.wg.Add(1)
+	go w.heartbeatLoop()
+
+	w.mu.Lock()
+	w.resizeLocked(w.concurrency)
+	w.mu.Unlock()
 }
 
 func (w *Worker) Stop() {
 	log.Printf("Stopping worker %s", w.id)
 	w.cancel()
 	w.wg.Wait()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := w.queue.RemoveWorker(ctx, w.id); err != nil {
+		log.Printf("Worker %s deregistration failed: %v", w.id, err)
+	}
 	log.Printf("Worker %s stopped", w.id)
 }
 
+// Drain stops claiming new stack scans. In-flight scans run to completion.
+func (w *Worker) Drain() {
+	w.mu.Lock()
+	w.draining = true
+	w.resizeLocked(0)
+	w.mu.Unlock()
+	log.Printf("Worker %s draining (%d in flight)", w.id, w.active.Load())
+	w.heartbeat()
+}
+
+// Resume undoes Drain and restarts claiming at the current concurrency.
+func (w *Worker) Resume() {
+	w.mu.Lock()
+	w.draining = false
+	w.resizeLocked(w.concurrency)
+	w.mu.Unlock()
+	log.Printf("Worker %s resumed with concurrency %d", w.id, w.concurrency)
+	w.heartbeat()
+}
+
+// SetConcurrency changes how many stack scan slots the worker has. Lowering
+// it lets surplus in-flight scans finish before their loops exit.
+func (w *Worker) SetConcurrency(n int) {
+	if n < 1 {
+		return
+	}
+	w.mu.Lock()
+	w.concurrency = n
+	w.slots.resize(n)
+	if !w.draining {
+		w.resizeLocked(n)
+	}
+	w.mu.Unlock()
+	log.Printf("Worker %s concurrency set to %d", w.id, n)
+	w.heartbeat()
+}
+
+// Info returns the worker's current registry record.
+func (w *Worker) Info() queue.WorkerInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	info := queue.WorkerInfo{
+		ID:          w.id,
+		Hostname:    w.hostname,
+		Concurrency: w.concurrency,
+		Active:      int(w.active.Load()),
+		SlotsUsed:   w.slots.inUse(),
+		Draining:    w.draining,
+		StartedAt:   w.startedAt,
+		LastSeen:    time.Now(),
+		JobVersions: queue.SupportedJobVersions(),
+	}
+	if w.warm != nil {
+		info.WarmStacks, info.WarmBytes = w.warm.stats()
+	}
+	return info
+}
+
+func (w *Worker) resizeLocked(n int) {
+	if w.ctx.Err() != nil {
+		return
+	}
+	for len(w.loops) < n {
+		ctx, cancel := context.WithCancel(w.ctx)
+		w.loops = append(w.loops, cancel)
+		w.wg.Add(1)
+		go w.processLoop(ctx, w.nextLoop)
+		w.nextLoop++
+	}
+	for len(w.loops) > n {
+		last := len(w.loops) - 1
+		w.loops[last]()
+		w.loops = w.loops[:last]
+	}
+}
+
+func (w *Worker) heartbeat() {
+	ctx, cancel := context.WithTimeout(w.ctx, 5*time.Second)
+	defer cancel()
+	if err := w.queue.HeartbeatWorker(ctx, w.Info()); err != nil && w.ctx.Err() == nil {
+		log.Printf("Worker %s heartbeat failed: %v", w.id, err)
+	}
+}
+
+func (w *Worker) heartbeatLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		w.heartbeat()
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// adminLoop applies drain/resume/concurrency commands published on the
+// worker admin channel.
+func (w *Worker) adminLoop() {
+	defer w.wg.Done()
+
+	for {
+		cmds, err := w.queue.SubscribeWorkerCommands(w.ctx)
+		if err != nil {
+			if w.ctx.Err() != nil {
+				return
+			}
+			log.Printf("Worker %s admin subscribe error: %v", w.id, err)
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+			continue
+		}
+		for cmd := range cmds {
+			w.applyCommand(cmd)
+		}
+		if w.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+func (w *Worker) applyCommand(cmd queue.WorkerCommand) {
+	if !cmd.Matches(w.Info()) {
+		return
+	}
+	switch cmd.Action {
+	case queue.WorkerActionDrain:
+		w.Drain()
+	case queue.WorkerActionResume:
+		w.Resume()
+	case queue.WorkerActionSetConcurrency:
+		w.SetConcurrency(cmd.Concurrency)
+	}
+}
+
 func (w *Worker) recoveryLoop() {
 	defer w.wg.Done()
 
@@ -110,7 +326,9 @@ func (w *Worker) recoveryLoop() {
 	}
 }
 
-func (w *Worker) processLoop(workerNum int) {
+// processLoop claims and runs stack scans until claimCtx is canceled. Scans
+// run under the worker context, so stopping a loop never interrupts one.
+func (w *Worker) processLoop(claimCtx context.Context, workerNum int) {
 	defer w.wg.Done()
 
 	workerID := fmt.Sprintf("%s-%d", w.id, workerNum)
@@ -118,18 +336,24 @@ func (w *Worker) processLoop(workerNum int) {
 
 	for {
 		select {
-		case <-w.ctx.Done():
+		case <-claimCtx.Done():
 			log.Printf("Worker goroutine %s shutting down", workerID)
 			return
 		default:
 		}
 
-		dequeueCtx, cancel := context.WithTimeout(w.ctx, 30*time.Second)
+		// Take a slot before claiming, so a full worker leaves stack scans
+		// in the queue for others instead of holding claims it cannot run.
+		if _, err := w.slots.acquire(claimCtx, 1); err != nil {
+			continue
+		}
+		dequeueCtx, cancel := context.WithTimeout(claimCtx, 30*time.Second)
 		job, err := w.queue.Dequeue(dequeueCtx, workerID)
 		cancel()
 
 		if err != nil {
-			if err == context.Canceled || err == context.DeadlineExceeded {
+			w.slots.release(1)
+			if err == context.Canceled || err == context.DeadlineExceeded || claimCtx.Err() != nil {
 				continue
 			}
 			log.Printf("Worker %s dequeue error: %v", workerID, err)
@@ -137,6 +361,21 @@ func (w *Worker) processLoop(workerNum int) {
 			continue
 		}
 
+		// A heavy stack scan trades its slot for its full weight. Loops that
+		// have not claimed yet queue behind it for their slot, so it only
+		// waits for scans already running.
+		weight := 1
+		if job.Weight > 1 {
+			w.slots.release(1)
+			weight, err = w.slots.acquire(w.ctx, job.Weight)
+			if err != nil {
+				log.Printf("Worker %s stopped before stack scan %s got its slots", workerID, job.ID)
+				return
+			}
+		}
+		w.active.Add(1)
 		w.processStackScan(job)
+		w.active.Add(-1)
+		w.slots.release(weight)
 	}
 }
@@ -3,11 +3,12 @@ package worker
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
 
-	"github.com/alicebob/miniredis/v2"
 	"github.com/driftdhq/driftd/internal/config"
 	"github.com/driftdhq/driftd/internal/queue"
 	"github.com/driftdhq/driftd/internal/runner"
@@ -67,20 +68,11 @@ func (m *mockRunner) getCalls() []runCall {
 	return append([]runCall{}, m.calls...)
 }
 
-func newTestQueue(t *testing.T) *queue.Queue {
+func newTestQueue(t *testing.T) *queue.MemoryQueue {
 	t.Helper()
-	mr, err := miniredis.Run()
-	if err != nil {
-		t.Fatalf("miniredis: %v", err)
-	}
-	q, err := queue.New(mr.Addr(), "", 0, time.Minute)
-	if err != nil {
-		mr.Close()
-		t.Fatalf("queue: %v", err)
-	}
+	q := queue.NewMemory(time.Minute)
 	t.Cleanup(func() {
 		_ = q.Close()
-		mr.Close()
 	})
 	return q
 }
@@ -526,3 +518,444 @@ func TestWorkerConcurrency(t *testing.T) {
 		t.Errorf("expected at least 3 runner calls, got %d", len(calls))
 	}
 }
+
+func TestWorkerAdminCommands(t *testing.T) {
+	q := newTestQueue(t)
+	r := newMockRunner()
+	w := New(q, r, 2, nil, nil)
+	w.prewarm = nil
+	w.Start()
+	defer w.Stop()
+
+	ctx := context.Background()
+	publish := func(cmd queue.WorkerCommand) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			n, err := q.PublishWorkerCommand(ctx, cmd)
+			if err != nil {
+				t.Fatalf("publish %s: %v", cmd.Action, err)
+			}
+			if n > 0 {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("worker never subscribed to admin channel")
+	}
+	waitFor := func(desc string, cond func(queue.WorkerInfo) bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if cond(w.Info()) {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("timed out waiting for %s: %+v", desc, w.Info())
+	}
+
+	publish(queue.WorkerCommand{Action: queue.WorkerActionSetConcurrency, Target: queue.WorkerTargetAll, Concurrency: 4})
+	waitFor("concurrency 4", func(info queue.WorkerInfo) bool { return info.Concurrency == 4 })
+
+	publish(queue.WorkerCommand{Action: queue.WorkerActionDrain, Target: w.ID()})
+	waitFor("draining", func(info queue.WorkerInfo) bool { return info.Draining })
+
+	job := &queue.StackScan{ProjectName: "project", ProjectURL: "https://github.com/org/project.git", StackPath: "envs/dev"}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	if calls := r.getCalls(); len(calls) != 0 {
+		t.Fatalf("drained worker claimed a stack scan: %+v", calls)
+	}
+
+	publish(queue.WorkerCommand{Action: queue.WorkerActionResume, Target: w.ID()})
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) && len(r.getCalls()) == 0 {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if len(r.getCalls()) != 1 {
+		t.Fatalf("expected resumed worker to process the stack scan")
+	}
+
+	workers, err := q.ListWorkers(ctx)
+	if err != nil || len(workers) != 1 || workers[0].ID != w.ID() {
+		t.Fatalf("expected worker registered, got %+v (%v)", workers, err)
+	}
+}
+
+type blockingRunner struct {
+	started chan struct{}
+	done    chan error
+}
+
+func (b *blockingRunner) Run(ctx context.Context, params *runner.RunParams) (*storage.RunResult, error) {
+	close(b.started)
+	<-ctx.Done()
+	b.done <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func TestWorkerPreemptsRunningStackScanWhenScanCanceled(t *testing.T) {
+	q := newTestQueue(t)
+	r := &blockingRunner{started: make(chan struct{}), done: make(chan error, 1)}
+
+	w := New(q, r, 1, nil, nil)
+	w.Start()
+	defer w.Stop()
+
+	ctx := context.Background()
+	scan, err := q.StartScan(ctx, "project", "manual", "", "", 1)
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	job := &queue.StackScan{
+		ScanID:      scan.ID,
+		ProjectName: "project",
+		ProjectURL:  "https://github.com/org/project.git",
+		StackPath:   "stack",
+	}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	select {
+	case <-r.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runner never started")
+	}
+	canceledAt := time.Now()
+	if err := q.CancelScan(ctx, scan.ID, "project", "test cancel"); err != nil {
+		t.Fatalf("cancel scan: %v", err)
+	}
+
+	select {
+	case <-r.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runner was not preempted")
+	}
+	if elapsed := time.Since(canceledAt); elapsed >= 2*time.Second {
+		t.Fatalf("preemption took %s, expected it before the cancel poll", elapsed)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		got, err := q.GetStackScan(ctx, job.ID)
+		if err != nil {
+			t.Fatalf("get job: %v", err)
+		}
+		if got.Status == queue.StatusCanceled {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	got, _ := q.GetStackScan(ctx, job.ID)
+	t.Fatalf("job status: got %s, want canceled", got.Status)
+}
+
+func TestWorkerLeavesStackScansQueuedWhileFull(t *testing.T) {
+	q := newTestQueue(t)
+	r := &blockingRunner{started: make(chan struct{}), done: make(chan error, 1)}
+
+	w := New(q, r, 2, nil, nil)
+	w.Start()
+	defer w.Stop()
+
+	// The first stack scan takes both slots.
+	ctx := context.Background()
+	first := &queue.StackScan{ProjectName: "project", ProjectURL: "https://github.com/org/project.git", StackPath: "a", Weight: 2}
+	if err := q.Enqueue(ctx, first); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	select {
+	case <-r.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("runner never started")
+	}
+
+	second := &queue.StackScan{ProjectName: "project", ProjectURL: "https://github.com/org/project.git", StackPath: "b"}
+	if err := q.Enqueue(ctx, second); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	got, err := q.GetStackScan(ctx, second.ID)
+	if err != nil {
+		t.Fatalf("get stack scan: %v", err)
+	}
+	if got.Status != queue.StatusPending || got.WorkerID != "" {
+		t.Fatalf("expected the second stack scan to stay queued, got %s claimed by %q", got.Status, got.WorkerID)
+	}
+}
+
+func TestSlotsServeHeavyScansInOrder(t *testing.T) {
+	s := newSlots(4)
+	ctx := context.Background()
+
+	light, err := s.acquire(ctx, 1)
+	if err != nil || light != 1 {
+		t.Fatalf("acquire light: %d %v", light, err)
+	}
+
+	heavyDone := make(chan int, 1)
+	go func() {
+		n, _ := s.acquire(ctx, 4)
+		heavyDone <- n
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		s.mu.Lock()
+		queued := s.waiters.Len()
+		s.mu.Unlock()
+		if queued == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("heavy scan never queued")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// A light scan arriving after the heavy one queues behind it even though
+	// a slot is free.
+	lateCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.acquire(lateCtx, 1); err == nil {
+		t.Fatalf("expected the later light scan to wait behind the heavy one")
+	}
+
+	s.release(light)
+	select {
+	case n := <-heavyDone:
+		if n != 4 {
+			t.Fatalf("heavy scan took %d slots, want 4", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("heavy scan was not granted its slots")
+	}
+	if s.inUse() != 4 {
+		t.Fatalf("expected 4 slots in use, got %d", s.inUse())
+	}
+	s.release(4)
+
+	// Weights above the size take every slot instead of waiting forever.
+	s.resize(2)
+	if n, err := s.acquire(ctx, 8); err != nil || n != 2 {
+		t.Fatalf("oversized acquire = %d %v, want 2", n, err)
+	}
+}
+
+func TestWorkerRefusesPrivilegedCredentialsInStrictMode(t *testing.T) {
+	q := newTestQueue(t)
+	r := newMockRunner()
+
+	cfg := &config.Config{
+		Projects: []config.ProjectConfig{
+			{
+				Name: "project",
+				URL:  "https://github.com/org/project.git",
+				CredentialCheck: config.CredentialCheckConfig{
+					Mode:    config.CredentialCheckStrict,
+					Command: []string{"sh", "-c", "echo AdministratorAccess attached; exit 1"},
+				},
+			},
+		},
+	}
+
+	w := New(q, r, 1, cfg, nil)
+	w.Start()
+	defer w.Stop()
+
+	ctx := context.Background()
+	job := &queue.StackScan{
+		ProjectName: "project",
+		ProjectURL:  "https://github.com/org/project.git",
+		StackPath:   "stack",
+	}
+	if err := q.Enqueue(ctx, job); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var got *queue.StackScan
+	for time.Now().Before(deadline) {
+		var err error
+		got, err = q.GetStackScan(ctx, job.ID)
+		if err != nil {
+			t.Fatalf("get job: %v", err)
+		}
+		if got.Status == queue.StatusFailed {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if got.Status != queue.StatusFailed {
+		t.Fatalf("job status: got %s, want failed", got.Status)
+	}
+	if got.Error != "refusing to scan: credentials are not read-only: AdministratorAccess attached" {
+		t.Errorf("job error: got %q", got.Error)
+	}
+	if calls := r.getCalls(); len(calls) != 0 {
+		t.Fatalf("expected no plan with privileged credentials, got %d calls", len(calls))
+	}
+}
+
+func TestWarmPoolKeepsMostPlannedStacksWithinBudget(t *testing.T) {
+	pool := newWarmPool(config.WarmPoolConfig{Stacks: 2, MaxDiskBytes: 1500, Dir: t.TempDir()}, "worker-1")
+	if err := pool.reset(); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	run := func(stack string, bytes int) *runner.WarmWorkspace {
+		t.Helper()
+		ws := pool.acquire("proj", stack, warmInit{})
+		if ws != nil {
+			if err := os.MkdirAll(ws.Dir, 0755); err != nil {
+				t.Fatalf("mkdir: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(ws.Dir, "data.bin"), make([]byte, bytes), 0644); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			pool.release("proj", stack)
+		}
+		return ws
+	}
+
+	first := run("a", 500)
+	if first == nil || run("a", 500) != first {
+		t.Fatal("expected stack a to keep one warm workspace")
+	}
+	run("b", 500)
+	run("b", 500)
+	if ws := run("c", 500); ws != nil {
+		t.Fatal("expected the least planned stack c to stay cold")
+	}
+	if n, size := pool.stats(); n != 2 || size != 1000 {
+		t.Fatalf("stats = %d workspaces, %d bytes; want 2, 1000", n, size)
+	}
+
+	// Going over the budget drops the less planned b, not a.
+	second := pool.acquire("proj", "b", warmInit{})
+	pool.release("proj", "b")
+	run("a", 1200)
+	if n, size := pool.stats(); n != 1 || size != 1200 {
+		t.Fatalf("stats = %d workspaces, %d bytes; want 1, 1200", n, size)
+	}
+	if _, err := os.Stat(second.Dir); !os.IsNotExist(err) {
+		t.Fatalf("expected stack b's workspace to be removed, got %v", err)
+	}
+
+	// A workspace is lent to one run at a time.
+	if pool.acquire("proj", "a", warmInit{}) == nil || pool.acquire("proj", "a", warmInit{}) != nil {
+		t.Fatal("expected a busy warm workspace to be withheld")
+	}
+}
+
+func TestWarmPoolDecaysRunCounts(t *testing.T) {
+	pool := newWarmPool(config.WarmPoolConfig{Stacks: 1, Dir: t.TempDir()}, "worker-1")
+	if err := pool.reset(); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	run := func(stack string) *runner.WarmWorkspace {
+		ws := pool.acquire("proj", stack, warmInit{})
+		if ws != nil {
+			pool.release("proj", stack)
+		}
+		return ws
+	}
+
+	// A stack planned often long ago gives way to the one planned now.
+	for i := 0; i < 100; i++ {
+		run("old")
+	}
+	for i := 0; i < 8*warmDecayRuns; i++ {
+		run(fmt.Sprintf("once-%d", i))
+	}
+	run("new")
+	if run("new") == nil {
+		t.Fatal("expected the recently planned stack to be hot")
+	}
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	if len(pool.runs) > warmDecayRuns {
+		t.Fatalf("expected decay to bound the run counts, got %d stacks", len(pool.runs))
+	}
+}
+
+func TestWarmPoolRefreshesHotStacksAtNewCommit(t *testing.T) {
+	q := newTestQueue(t)
+	cfg := &config.Config{Worker: config.WorkerConfig{WarmPool: config.WarmPoolConfig{Enabled: true, Stacks: 3, Dir: t.TempDir()}}}
+	w := New(q, newMockRunner(), 1, cfg, nil)
+	if err := w.warm.reset(); err != nil {
+		t.Fatalf("reset: %v", err)
+	}
+	var mu sync.Mutex
+	var initialized []string
+	failStack := "c"
+	w.initWarm = func(ctx context.Context, ws *runner.WarmWorkspace, projectRoot, stackPath, tfVersion string, initArgs []string) error {
+		if _, err := os.Stat(filepath.Join(projectRoot, stackPath, "main.tf")); err != nil {
+			t.Errorf("expected %s in the workspace copy: %v", stackPath, err)
+		}
+		if tfVersion != "1.6.0" || len(initArgs) != 1 || initArgs[0] != "-backend=false" {
+			t.Errorf("init of %s: version %q args %v", stackPath, tfVersion, initArgs)
+		}
+		mu.Lock()
+		initialized = append(initialized, stackPath)
+		mu.Unlock()
+		if stackPath == failStack {
+			return fmt.Errorf("init failed")
+		}
+		return nil
+	}
+
+	workspace := t.TempDir()
+	for _, stack := range []string{"a", "b", "c"} {
+		if err := os.MkdirAll(filepath.Join(workspace, stack), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(workspace, stack, "main.tf"), []byte("# stack\n"), 0644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		init := warmInit{commit: "c1", tfVersion: "1.6.0", initArgs: []string{"-backend=false"}}
+		if w.warm.acquire("project", stack, init) == nil {
+			t.Fatalf("expected stack %s to be hot", stack)
+		}
+		w.warm.release("project", stack)
+	}
+
+	// A scan at c2 covers b, so the refresh initializes a and c.
+	ctx := context.Background()
+	scan, err := q.StartScan(ctx, "project", "push", "", "", 1)
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	if err := q.Enqueue(ctx, &queue.StackScan{ScanID: scan.ID, ProjectName: "project", StackPath: "b"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	sc := &ScanContext{ProjectName: "project", ScanID: scan.ID, CommitSHA: "c2", WorkspacePath: workspace}
+	if !w.warm.stale("project", "c2") {
+		t.Fatal("expected warm workspaces from c1 to be stale at c2")
+	}
+	w.refreshWarmStacks(ctx, sc)
+	if len(initialized) != 2 || initialized[0] != "a" || initialized[1] != "c" {
+		t.Fatalf("initialized %v, want [a c]", initialized)
+	}
+	// c failed to initialize, so it stays at c1 and is retried.
+	if !w.warm.stale("project", "c2") {
+		t.Fatal("expected the failed refresh to leave its workspace stale")
+	}
+	failStack = ""
+	initialized = nil
+	w.refreshWarmStacks(ctx, sc)
+	if len(initialized) != 1 || initialized[0] != "c" {
+		t.Fatalf("initialized %v, want [c]", initialized)
+	}
+	if w.warm.stale("project", "c2") {
+		t.Fatal("expected no stale warm workspaces after the refresh")
+	}
+	if got := w.warm.acquire("project", "a", warmInit{commit: "c2"}); got == nil {
+		t.Fatal("expected the refreshed workspace to be returned to the pool")
+	}
+	if _, err := os.Stat(filepath.Join(workspace, "a", ".terraform.lock.hcl")); !os.IsNotExist(err) {
+		t.Fatalf("expected the scan workspace untouched, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+// Package workspaceprune deletes old scan workspaces on an interval. Unlike
+// the per-project retention applied after each scan, it works by the status
+// of the scan that created a workspace, so failed and canceled scans can be
+// cleared quickly while completed ones are kept for longer.
+package workspaceprune
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/metrics"
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+// StatusExpired labels workspaces whose scan is no longer in the queue.
+// They follow the completed policy.
+const StatusExpired = "expired"
+
+// Leader reports whether this replica should prune. Workspaces live on the
+// shared data_dir, so one replica is enough.
+type Leader interface {
+	IsLeader() bool
+}
+
+// Stats is the outcome of one pruning pass.
+type Stats struct {
+	Deleted        int              `json:"deleted"`
+	ReclaimedBytes int64            `json:"reclaimed_bytes"`
+	ByStatus       map[string]int   `json:"by_status,omitempty"`
+	BytesByStatus  map[string]int64 `json:"bytes_by_status,omitempty"`
+}
+
+// Pruner deletes scan workspaces under <data_dir>/workspaces/scans.
+type Pruner struct {
+	cfg     config.WorkspacePruneConfig
+	dataDir string
+	queue   queue.Backend
+	leader  Leader
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New returns a pruner for cfg's workspace.prune policy.
+func New(cfg *config.Config, q queue.Backend) *Pruner {
+	return &Pruner{
+		cfg:     cfg.Workspace.Prune,
+		dataDir: cfg.DataDir,
+		queue:   q,
+		stop:    make(chan struct{}),
+	}
+}
+
+// SetLeader limits pruning to the replica holding the scheduler lease.
+func (p *Pruner) SetLeader(l Leader) {
+	p.leader = l
+}
+
+// Start prunes every interval until Stop.
+func (p *Pruner) Start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+			}
+			if p.leader != nil && !p.leader.IsLeader() {
+				continue
+			}
+			stats := p.Prune(context.Background(), time.Now())
+			if stats.Deleted > 0 {
+				log.Printf("Pruned %d scan workspaces, reclaimed %d bytes", stats.Deleted, stats.ReclaimedBytes)
+			}
+		}
+	}()
+}
+
+// Stop ends pruning and waits for a pass in progress.
+func (p *Pruner) Stop() {
+	close(p.stop)
+	p.wg.Wait()
+}
+
+// Prune deletes the workspaces whose policy has run out at now. Workspaces
+// of running scans, and of each project's active scan, are never deleted,
+// and neither is a workspace whose scan could not be read.
+func (p *Pruner) Prune(ctx context.Context, now time.Time) Stats {
+	stats := Stats{ByStatus: map[string]int{}, BytesByStatus: map[string]int64{}}
+	base := filepath.Join(p.dataDir, "workspaces", "scans")
+	projects, err := os.ReadDir(base)
+	if err != nil {
+		return stats
+	}
+	for _, project := range projects {
+		if !project.IsDir() {
+			continue
+		}
+		activeID := ""
+		if active, err := p.queue.GetActiveScan(ctx, project.Name()); err == nil && active != nil {
+			activeID = active.ID
+		}
+		projectDir := filepath.Join(base, project.Name())
+		entries, err := os.ReadDir(projectDir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() || entry.Name() == activeID {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			status, ended, ok := p.scanState(ctx, entry.Name(), info.ModTime())
+			if !ok || !p.expired(status, ended, now) {
+				continue
+			}
+			path := filepath.Join(projectDir, entry.Name())
+			size := dirSize(path)
+			if err := os.RemoveAll(path); err != nil {
+				log.Printf("workspace prune: remove %s: %v", path, err)
+				continue
+			}
+			stats.Deleted++
+			stats.ReclaimedBytes += size
+			stats.ByStatus[status]++
+			stats.BytesByStatus[status] += size
+			metrics.ObserveWorkspacePrune(status, size)
+		}
+	}
+	return stats
+}
+
+// scanState returns the status of the scan that owns a workspace and when
+// it ended. ok is false when the workspace must be kept regardless.
+func (p *Pruner) scanState(ctx context.Context, scanID string, modTime time.Time) (status string, ended time.Time, ok bool) {
+	scan, err := p.queue.GetScan(ctx, scanID)
+	if errors.Is(err, queue.ErrScanNotFound) {
+		return StatusExpired, modTime, true
+	}
+	if err != nil || scan == nil || scan.Status == queue.ScanStatusRunning {
+		return "", time.Time{}, false
+	}
+	ended = scan.EndedAt
+	if ended.IsZero() || ended.Unix() <= 0 {
+		ended = modTime
+	}
+	return scan.Status, ended, true
+}
+
+func (p *Pruner) expired(status string, ended, now time.Time) bool {
+	keep := p.cfg.CompletedAfter
+	if status == queue.ScanStatusFailed || status == queue.ScanStatusCanceled {
+		keep = p.cfg.FailedAfter
+	}
+	return keep >= 0 && now.Sub(ended) >= keep
+}
+
+// dirSize sums the sizes of the regular files under path without following
+// symlinks.
+func dirSize(path string) int64 {
+	var total int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total
+}
@@ -0,0 +1,133 @@
+package workspaceprune
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/driftdhq/driftd/internal/config"
+	"github.com/driftdhq/driftd/internal/queue"
+)
+
+func newTestPruner(t *testing.T, failedAfter, completedAfter time.Duration) (*Pruner, *queue.MemoryQueue) {
+	t.Helper()
+	q := queue.NewMemory(time.Minute)
+	t.Cleanup(func() { _ = q.Close() })
+	cfg := &config.Config{DataDir: t.TempDir()}
+	cfg.Workspace.Prune = config.WorkspacePruneConfig{
+		Enabled:        true,
+		Interval:       time.Hour,
+		FailedAfter:    failedAfter,
+		CompletedAfter: completedAfter,
+	}
+	return New(cfg, q), q
+}
+
+func writeWorkspace(t *testing.T, p *Pruner, project, scanID string) string {
+	t.Helper()
+	dir := filepath.Join(p.dataDir, "workspaces", "scans", project, scanID)
+	if err := os.MkdirAll(filepath.Join(dir, "envs"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "envs", "main.tf"), []byte("# 10 bytes"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	return dir
+}
+
+func startScan(t *testing.T, q *queue.MemoryQueue, project string) *queue.Scan {
+	t.Helper()
+	scan, err := q.StartScan(context.Background(), project, "manual", "", "", 1)
+	if err != nil {
+		t.Fatalf("start scan: %v", err)
+	}
+	return scan
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestPruneByScanStatus(t *testing.T) {
+	p, q := newTestPruner(t, 24*time.Hour, 7*24*time.Hour)
+	ctx := context.Background()
+
+	failed := startScan(t, q, "failed")
+	if err := q.FailScan(ctx, failed.ID, "failed", "boom"); err != nil {
+		t.Fatalf("fail scan: %v", err)
+	}
+	canceled := startScan(t, q, "canceled")
+	if err := q.CancelScan(ctx, canceled.ID, "canceled", ""); err != nil {
+		t.Fatalf("cancel scan: %v", err)
+	}
+	running := startScan(t, q, "running")
+
+	failedDir := writeWorkspace(t, p, "failed", failed.ID)
+	canceledDir := writeWorkspace(t, p, "canceled", canceled.ID)
+	runningDir := writeWorkspace(t, p, "running", running.ID)
+	expiredDir := writeWorkspace(t, p, "expired", "gone-from-queue")
+
+	stats := p.Prune(ctx, time.Now().Add(time.Hour))
+	if stats.Deleted != 0 {
+		t.Fatalf("pruned before failed_after: %+v", stats)
+	}
+
+	stats = p.Prune(ctx, time.Now().Add(25*time.Hour))
+	if stats.Deleted != 2 || stats.ByStatus[queue.ScanStatusFailed] != 1 || stats.ByStatus[queue.ScanStatusCanceled] != 1 {
+		t.Fatalf("unexpected stats after failed_after: %+v", stats)
+	}
+	if stats.ReclaimedBytes != 20 {
+		t.Fatalf("reclaimed = %d, want 20", stats.ReclaimedBytes)
+	}
+	if exists(failedDir) || exists(canceledDir) {
+		t.Fatal("failed and canceled workspaces were not removed")
+	}
+	if !exists(expiredDir) {
+		t.Fatal("workspace of expired scan removed before completed_after")
+	}
+
+	stats = p.Prune(ctx, time.Now().Add(8*24*time.Hour))
+	if stats.Deleted != 1 || stats.ByStatus[StatusExpired] != 1 || exists(expiredDir) {
+		t.Fatalf("expired workspace not pruned after completed_after: %+v", stats)
+	}
+	if !exists(runningDir) {
+		t.Fatal("workspace of running scan was removed")
+	}
+}
+
+func TestPruneKeepsActiveScanWorkspace(t *testing.T) {
+	p, q := newTestPruner(t, 0, 0)
+	ctx := context.Background()
+
+	active := startScan(t, q, "project")
+	activeDir := writeWorkspace(t, p, "project", active.ID)
+	oldDir := writeWorkspace(t, p, "project", "old-scan")
+
+	stats := p.Prune(ctx, time.Now().Add(time.Minute))
+	if stats.Deleted != 1 || exists(oldDir) {
+		t.Fatalf("expected only the old workspace pruned: %+v", stats)
+	}
+	if !exists(activeDir) {
+		t.Fatal("active scan workspace was removed")
+	}
+}
+
+func TestPruneNegativeKeeps(t *testing.T) {
+	p, q := newTestPruner(t, -1, -1)
+	ctx := context.Background()
+
+	failed := startScan(t, q, "project")
+	if err := q.FailScan(ctx, failed.ID, "project", "boom"); err != nil {
+		t.Fatalf("fail scan: %v", err)
+	}
+	failedDir := writeWorkspace(t, p, "project", failed.ID)
+	expiredDir := writeWorkspace(t, p, "project", "gone-from-queue")
+
+	stats := p.Prune(ctx, time.Now().Add(365*24*time.Hour))
+	if stats.Deleted != 0 || !exists(failedDir) || !exists(expiredDir) {
+		t.Fatalf("negative policy pruned workspaces: %+v", stats)
+	}
+}